@@ -143,6 +143,17 @@ const (
 	// Note: The instruction list is too long to enumerate in godoc.
 	// See https://github.com/WebAssembly/spec/blob/wg-2.0.draft1/proposals/simd/SIMD.md
 	CoreFeatureSIMD
+
+	// CoreFeatureCustomPageSizes allows a memory to define its page size
+	// ("custom-page-sizes"), so that it can be smaller than 65536 bytes. This
+	// is neither included in CoreFeaturesV1 nor CoreFeaturesV2.
+	//
+	// The only page sizes allowed are 1 and 65536 bytes. A memory with 1 byte
+	// pages grows by as many bytes as needed, e.g. for embedded guests with a
+	// tiny footprint, and "memory.size" and "memory.grow" count bytes.
+	//
+	// See https://github.com/WebAssembly/custom-page-sizes/blob/main/proposals/custom-page-sizes/Overview.md
+	CoreFeatureCustomPageSizes
 )
 
 // SetEnabled enables or disables the feature or group of features.
@@ -207,6 +218,9 @@ func featureName(f CoreFeatures) string {
 	case CoreFeatureSIMD:
 		// match https://github.com/WebAssembly/spec/blob/wg-2.0.draft1/proposals/simd/SIMD.md
 		return "simd"
+	case CoreFeatureCustomPageSizes:
+		// match https://github.com/WebAssembly/custom-page-sizes/blob/main/proposals/custom-page-sizes/Overview.md
+		return "custom-page-sizes"
 	}
 	return ""
 }
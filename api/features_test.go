@@ -61,6 +61,7 @@ func TestCoreFeatures_String(t *testing.T) {
 		{name: "sign-extension-ops", feature: CoreFeatureSignExtensionOps, expected: "sign-extension-ops"},
 		{name: "multi-value", feature: CoreFeatureMultiValue, expected: "multi-value"},
 		{name: "simd", feature: CoreFeatureSIMD, expected: "simd"},
+		{name: "custom-page-sizes", feature: CoreFeatureCustomPageSizes, expected: "custom-page-sizes"},
 		{name: "features", feature: CoreFeatureMutableGlobal | CoreFeatureMultiValue, expected: "multi-value|mutable-global"},
 		{name: "undefined", feature: 1 << 63, expected: ""},
 		{
@@ -3,8 +3,10 @@ package api
 
 import (
 	"context"
+	"encoding/binary"
 	"fmt"
 	"math"
+	"time"
 )
 
 // ExternType classifies imports and exports with their respective types.
@@ -59,6 +61,8 @@ func ExternTypeName(et ExternType) string {
 //   - ValueTypeF64 - EncodeF64 DecodeF64 from float64
 //   - ValueTypeExternref - unintptr(unsafe.Pointer(p)) where p is any pointer
 //     type in Go (e.g. *string)
+//   - ValueTypeV128 - EncodeV128 DecodeV128 from [16]byte, occupying two
+//     uint64 values: the low then the high 64 bits
 //
 // e.g. Given a Text Format type use (param i64) (result i64), no conversion is
 // necessary.
@@ -105,6 +109,20 @@ const (
 	//
 	// Note: The usage of this type is toggled with api.CoreFeatureBulkMemoryOperations.
 	ValueTypeExternref ValueType = 0x6f
+
+	// ValueTypeV128 is a 128-bit vector type, defined by the SIMD feature.
+	//
+	// Note: Unlike other types, a ValueTypeV128 value occupies two uint64
+	// values in params and results: the lower 64 bits followed by the higher
+	// 64 bits. For example, calling a function with the signature
+	// (param i32 v128) (result v128) looks like this:
+	//
+	//	lo, hi := api.EncodeV128(input)
+	//	results, _ := fn.Call(ctx, api.EncodeU32(x), lo, hi)
+	//	result := api.DecodeV128(results[0], results[1])
+	//
+	// Note: The usage of this type is toggled with api.CoreFeatureSIMD.
+	ValueTypeV128 ValueType = 0x7b
 )
 
 // ValueTypeName returns the type name of the given ValueType as a string.
@@ -123,6 +141,8 @@ func ValueTypeName(t ValueType) string {
 		return "f64"
 	case ValueTypeExternref:
 		return "externref"
+	case ValueTypeV128:
+		return "v128"
 	}
 	return "unknown"
 }
@@ -160,6 +180,46 @@ type Module interface {
 	// ExportedGlobal a global exported from this module or nil if it wasn't.
 	ExportedGlobal(name string) Global
 
+	// Allocator returns an Allocator backed by conventional functions
+	// exported from this module, or nil if none were found.
+	//
+	// See Allocator for the supported conventions.
+	Allocator() Allocator
+
+	// CPUTime returns the cumulative time spent executing functions of this
+	// module, or zero unless enabled with wazero.RuntimeConfig
+	// WithCPUTimeAccounting, or wazero.ModuleConfig WithSysCputime.
+	//
+	// See CPUTime for how time is attributed.
+	CPUTime() CPUTime
+
+	// MemoryStats returns statistics about the memory of this module, or the
+	// zero value if it has no memory.
+	MemoryStats() MemoryStats
+
+	// Clone returns a new instance of this module with a copy of its state,
+	// i.e. the globals, tables and memory it defines. This is much faster
+	// than instantiating it again, as the clone shares its compiled code and
+	// doesn't run its start function. For example, a module initialized once
+	// can be cloned to handle each request in isolation.
+	//
+	// On darwin, freebsd and linux, the memory of the clone is a copy-on-write
+	// mapping of a snapshot of this module's memory, so its pages are only
+	// copied once written. The snapshot is taken by the first clone after the
+	// memory of this module may have changed, and is shared by later clones.
+	// Otherwise, the memory is copied.
+	//
+	// # Notes
+	//
+	//   - The clone has the same name, imports and system context, e.g. args
+	//     and open files, as this module. The system context is closed with
+	//     the last of this module and its clones.
+	//   - The clone isn't in the namespace of this module, so it can't be
+	//     imported, and isn't closed with it, nor by Runtime.Close. Close it
+	//     when done to release its memory, after which views of it returned
+	//     by Memory.Read are invalid.
+	Clone(ctx context.Context) (Module, error)
+
 	// CloseWithExitCode releases resources allocated for this Module. Use a non-zero exitCode parameter to indicate a
 	// failure to ExportedFunction callers.
 	//
@@ -175,6 +235,34 @@ type Module interface {
 	Closer
 }
 
+// Allocator reserves and releases regions of a module's memory by calling
+// functions the guest exports for that purpose. This allows the host to pass
+// data, such as strings, to the guest without hard-coding the names of
+// allocator functions, which differ per toolchain.
+//
+// The following conventions are detected, in order of precedence:
+//
+//   - "malloc" (i32) -> i32 and "free" (i32): C, Zig and TinyGo (undocumented,
+//     see tinygo-org/tinygo#2788).
+//   - "canonical_abi_realloc" (i32 i32 i32 i32) -> i32 and
+//     "canonical_abi_free" (i32 i32 i32): wit-bindgen generated guests.
+//   - "allocate" (i32) -> i32 and "deallocate" (i32 i32): a common Rust idiom.
+//
+// # Notes
+//
+//   - This is an interface for decoupling, not third-party implementations. All implementations are in wazero.
+//   - Memory reserved by Allocate is unknown to the guest's garbage collector,
+//     if it has one. Call Free when the host no longer needs it.
+type Allocator interface {
+	// Allocate reserves size bytes in the guest memory and returns the offset
+	// of the first byte.
+	Allocate(ctx context.Context, size uint32) (offset uint32, err error)
+
+	// Free releases memory previously returned by Allocate. The size must be
+	// the same as the one passed to Allocate, as some conventions require it.
+	Free(ctx context.Context, offset, size uint32) error
+}
+
 // Closer closes a resource.
 //
 // Note: This is an interface for decoupling, not third-party implementations. All implementations are in wazero.
@@ -213,7 +301,8 @@ type ExportDefinition interface {
 }
 
 // MemoryDefinition is a WebAssembly memory exported in a module
-// (wazero.CompiledModule). Units are in pages (64KB).
+// (wazero.CompiledModule). Units are in pages (64KB), unless the memory has a
+// custom page size. See PageSize.
 //
 // See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#exports%E2%91%A0
 type MemoryDefinition interface {
@@ -225,6 +314,12 @@ type MemoryDefinition interface {
 	// Max returns the possibly zero max count of 64KB pages, or false if
 	// unbounded.
 	Max() (uint32, bool)
+
+	// PageSize returns the size of a page in bytes, which is 65536 unless
+	// the memory defines a custom page size, e.g. 1.
+	//
+	// See CoreFeatureCustomPageSizes
+	PageSize() uint32
 }
 
 // FunctionDefinition is a WebAssembly function exported in a module
@@ -245,6 +340,10 @@ type FunctionDefinition interface {
 	// prefixing '$' to its position in the index namespace. Ex ".$0" is the
 	// first function (possibly imported) in an unnamed module.
 	//
+	// When the function name was mangled by the Rust or a C++ compiler, it is
+	// demangled. e.g. "_ZN4core3fmt5write17h0123456789abcdefE" becomes
+	// "core::fmt::write::h0123456789abcdef".
+	//
 	// The format is dot-delimited module and function name, but there are no
 	// restrictions on the module and function name. This means either can be
 	// empty or include dots. e.g. "x.x.x" could mean module "x" and name "x.x",
@@ -284,6 +383,54 @@ type FunctionDefinition interface {
 	ResultNames() []string
 }
 
+// MemoryStats are statistics about the memory of a Module, as returned by
+// Module.MemoryStats.
+//
+// Note: When the memory is imported, Size, PeakSize and GrowCount include
+// changes made by any module sharing it.
+type MemoryStats struct {
+	// Size is the current size of the memory in bytes.
+	//
+	// Note: This is uint64 as the maximum memory size, 4GiB, overflows uint32.
+	Size uint64
+
+	// PeakSize is the largest Size observed in bytes.
+	PeakSize uint64
+
+	// GrowCount is the number of times the memory grew, e.g. via the
+	// "memory.grow" instruction or Memory.Grow, excluding zero deltas and
+	// failed attempts.
+	GrowCount uint32
+
+	// DataSegmentSize is the total bytes of the active data segments copied
+	// into the memory when the module was instantiated.
+	DataSegmentSize uint32
+}
+
+// CPUTime is the cumulative time spent in calls to functions exported from a
+// Module, as returned by Module.CPUTime.
+//
+// # Notes
+//
+//   - Time is the CPU time of the thread executing a function, so excludes
+//     time it was blocked, e.g. in a host function sleeping or waiting for
+//     I/O. Platforms other than Linux and Windows don't measure the CPU time
+//     of a thread, so use the wall clock instead.
+//   - Work a host function does in other goroutines isn't included.
+//   - When a host function calls back into a module, e.g. via an exported
+//     function, the time of that call is attributed to the called module and
+//     not the host function.
+//   - A call from wasm to a function imported from another wasm module is
+//     attributed to the caller, as it is executed the same as a local one.
+type CPUTime struct {
+	// Guest is the time spent executing wasm functions.
+	Guest time.Duration
+
+	// Host is the time spent in host functions called by wasm functions,
+	// e.g. those defined with wazero.HostModuleBuilder.
+	Host time.Duration
+}
+
 // Function is a WebAssembly function exported from an instantiated module
 // (wazero.Runtime InstantiateModule).
 //
@@ -307,6 +454,9 @@ type Function interface {
 	//
 	// To safely encode/decode params/results expressed as uint64, users are encouraged to
 	// use api.EncodeXXX or DecodeXXX functions. See the docs on api.ValueType.
+	//
+	// Note: A ValueTypeV128 param or result occupies two uint64 values, so the
+	// count of params and results can be larger than ParamTypes and ResultTypes.
 	Call(ctx context.Context, params ...uint64) ([]uint64, error)
 }
 
@@ -445,7 +595,8 @@ type Memory interface {
 	// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#-hrefsyntax-instr-memorymathsfmemorysize%E2%91%A0
 	Size() uint32
 
-	// Grow increases memory by the delta in pages (65536 bytes per page).
+	// Grow increases memory by the delta in pages (65536 bytes per page, unless
+	// MemoryDefinition.PageSize is custom).
 	// The return val is the previous memory size in pages, or false if the
 	// delta was ignored as it exceeds MemoryDefinition.Max.
 	//
@@ -549,6 +700,21 @@ type Memory interface {
 
 	// WriteString writes the string to the underlying buffer at the offset or returns false if out of range.
 	WriteString(offset uint32, v string) bool
+
+	// Protect makes the pages (65536 bytes each) overlapping byteCount bytes at the offset read-only, or writable
+	// again if readOnly is false. This returns false if out of range, or if the memory isn't protectable, as the
+	// runtime wasn't configured with wazero.RuntimeConfig WithMemoryProtection.
+	//
+	// For example, to publish a configuration the guest can read, but not corrupt:
+	//	memory.Write(offset, config)
+	//	memory.Protect(offset, uint32(len(config)), true)
+	//
+	// # Notes
+	//
+	//   - A guest store, "memory.init", "memory.copy" or "memory.fill" which writes a read-only page traps.
+	//   - The Write* methods return false when writing a read-only page, but views returned by Read can still be
+	//     written to by the host.
+	Protect(offset, byteCount uint32, readOnly bool) bool
 }
 
 // EncodeExternref encodes the input as a ValueTypeExternref.
@@ -617,3 +783,178 @@ func EncodeF64(input float64) uint64 {
 func DecodeF64(input uint64) float64 {
 	return math.Float64frombits(input)
 }
+
+// EncodeV128 encodes the input as a ValueTypeV128, returning the lower and
+// higher 64 bits in little-endian order, which are passed in that order.
+//
+// See DecodeV128
+func EncodeV128(input [16]byte) (lo, hi uint64) {
+	lo = binary.LittleEndian.Uint64(input[:8])
+	hi = binary.LittleEndian.Uint64(input[8:])
+	return
+}
+
+// DecodeV128 decodes the lower and higher 64 bits of a ValueTypeV128.
+//
+// See EncodeV128
+func DecodeV128(lo, hi uint64) (ret [16]byte) {
+	binary.LittleEndian.PutUint64(ret[:8], lo)
+	binary.LittleEndian.PutUint64(ret[8:], hi)
+	return
+}
+
+// EncodeParams encodes the Go values as params of a call to the function,
+// according to its ParamTypes. For example:
+//
+//	params, err := api.EncodeParams(fn.Definition(), int32(1), float64(2.5))
+//	if err != nil {
+//		return err
+//	}
+//	results, err := fn.Call(ctx, params...)
+//
+// See EncodeValues for the supported Go types.
+func EncodeParams(def FunctionDefinition, params ...interface{}) ([]uint64, error) {
+	return EncodeValues(def.ParamTypes(), params...)
+}
+
+// DecodeParams decodes the params of a call to the function, according to its
+// ParamTypes. For example, this can be used by a listener or a GoFunction.
+//
+// See DecodeValues for the resulting Go types.
+func DecodeParams(def FunctionDefinition, params []uint64) ([]interface{}, error) {
+	return DecodeValues(def.ParamTypes(), params)
+}
+
+// EncodeResults encodes the Go values as results of the function, according
+// to its ResultTypes. For example, this can be used by a GoFunction.
+//
+// See EncodeValues for the supported Go types.
+func EncodeResults(def FunctionDefinition, results ...interface{}) ([]uint64, error) {
+	return EncodeValues(def.ResultTypes(), results...)
+}
+
+// DecodeResults decodes the results of a call to the function, according to
+// its ResultTypes. For example:
+//
+//	results, err := fn.Call(ctx, params...)
+//	if err != nil {
+//		return err
+//	}
+//	values, err := api.DecodeResults(fn.Definition(), results)
+//
+// See DecodeValues for the resulting Go types.
+func DecodeResults(def FunctionDefinition, results []uint64) ([]interface{}, error) {
+	return DecodeValues(def.ResultTypes(), results)
+}
+
+// EncodeValues encodes the Go values according to the types, returning an
+// error if the count or a Go type doesn't match. The Go types accepted for
+// each ValueType are:
+//
+//   - ValueTypeI32 - int32 or uint32
+//   - ValueTypeI64 - int64 or uint64
+//   - ValueTypeF32 - float32
+//   - ValueTypeF64 - float64
+//   - ValueTypeExternref and funcref - uintptr
+//   - ValueTypeV128 - [16]byte, encoded as two values. See EncodeV128
+func EncodeValues(types []ValueType, values ...interface{}) ([]uint64, error) {
+	if len(types) != len(values) {
+		return nil, fmt.Errorf("expected %d values, but passed %d", len(types), len(values))
+	}
+	ret := make([]uint64, 0, len(types))
+	for i, t := range types {
+		v := values[i]
+		switch t {
+		case ValueTypeI32:
+			switch v := v.(type) {
+			case int32:
+				ret = append(ret, EncodeI32(v))
+				continue
+			case uint32:
+				ret = append(ret, EncodeU32(v))
+				continue
+			}
+		case ValueTypeI64:
+			switch v := v.(type) {
+			case int64:
+				ret = append(ret, EncodeI64(v))
+				continue
+			case uint64:
+				ret = append(ret, v)
+				continue
+			}
+		case ValueTypeF32:
+			if v, ok := v.(float32); ok {
+				ret = append(ret, EncodeF32(v))
+				continue
+			}
+		case ValueTypeF64:
+			if v, ok := v.(float64); ok {
+				ret = append(ret, EncodeF64(v))
+				continue
+			}
+		case ValueTypeExternref, 0x70: // funcref
+			if v, ok := v.(uintptr); ok {
+				ret = append(ret, EncodeExternref(v))
+				continue
+			}
+		case ValueTypeV128:
+			if v, ok := v.([16]byte); ok {
+				lo, hi := EncodeV128(v)
+				ret = append(ret, lo, hi)
+				continue
+			}
+		default:
+			return nil, fmt.Errorf("value[%d] has unsupported type %s", i, ValueTypeName(t))
+		}
+		return nil, fmt.Errorf("value[%d] is %T, but expected %s", i, v, ValueTypeName(t))
+	}
+	return ret, nil
+}
+
+// DecodeValues decodes the encoded values according to the types, returning
+// an error if the count doesn't match. The Go types returned for each
+// ValueType are:
+//
+//   - ValueTypeI32 - int32
+//   - ValueTypeI64 - int64
+//   - ValueTypeF32 - float32
+//   - ValueTypeF64 - float64
+//   - ValueTypeExternref and funcref - uintptr
+//   - ValueTypeV128 - [16]byte, decoded from two values. See DecodeV128
+func DecodeValues(types []ValueType, encoded []uint64) ([]interface{}, error) {
+	count := len(types)
+	for _, t := range types {
+		if t == ValueTypeV128 {
+			count++
+		}
+	}
+	if count != len(encoded) {
+		return nil, fmt.Errorf("expected %d encoded values, but passed %d", count, len(encoded))
+	}
+
+	ret := make([]interface{}, 0, len(types))
+	i := 0
+	for _, t := range types {
+		v := encoded[i]
+		i++
+		switch t {
+		case ValueTypeI32:
+			ret = append(ret, DecodeI32(v))
+		case ValueTypeI64:
+			ret = append(ret, int64(v))
+		case ValueTypeF32:
+			ret = append(ret, DecodeF32(v))
+		case ValueTypeF64:
+			ret = append(ret, DecodeF64(v))
+		case ValueTypeExternref, 0x70: // funcref
+			ret = append(ret, DecodeExternref(v))
+		case ValueTypeV128:
+			ret = append(ret, DecodeV128(v, encoded[i]))
+			i++
+		default:
+			return nil, fmt.Errorf("value[%d] has unsupported type %s", len(ret), ValueTypeName(t))
+		}
+	}
+	return ret, nil
+}
@@ -42,6 +42,7 @@ func TestValueTypeName(t *testing.T) {
 		{"f32", ValueTypeF32, "f32"},
 		{"f64", ValueTypeF64, "f64"},
 		{"externref", ValueTypeExternref, "externref"},
+		{"v128", ValueTypeV128, "v128"},
 		{"unknown", 100, "unknown"},
 	}
 
@@ -66,6 +67,23 @@ func TestEncodeDecodeExternRef(t *testing.T) {
 	}
 }
 
+func TestEncodeDecodeV128(t *testing.T) {
+	for _, v := range [][16]byte{
+		{},
+		{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+	} {
+		t.Run(fmt.Sprintf("%x", v), func(t *testing.T) {
+			lo, hi := EncodeV128(v)
+			require.Equal(t, v, DecodeV128(lo, hi))
+		})
+	}
+
+	lo, hi := EncodeV128([16]byte{1, 0, 0, 0, 0, 0, 0, 0, 2})
+	require.Equal(t, uint64(1), lo)
+	require.Equal(t, uint64(2), hi)
+}
+
 func TestEncodeDecodeF32(t *testing.T) {
 	for _, v := range []float32{
 		0, 100, -100, 1, -1,
@@ -189,3 +207,85 @@ func TestEncodeI64(t *testing.T) {
 		})
 	}
 }
+
+func TestEncodeDecodeValues(t *testing.T) {
+	types := []ValueType{ValueTypeI32, ValueTypeI64, ValueTypeF32, ValueTypeF64, ValueTypeExternref, ValueTypeV128, ValueTypeI32}
+	v128 := [16]byte{1, 0, 0, 0, 0, 0, 0, 0, 2}
+
+	encoded, err := EncodeValues(types, int32(-1), int64(-2), float32(3.5), float64(4.5), uintptr(5), v128, uint32(6))
+	require.NoError(t, err)
+	require.Equal(t, []uint64{
+		EncodeI32(-1), EncodeI64(-2), EncodeF32(3.5), EncodeF64(4.5), 5, 1, 2, 6,
+	}, encoded)
+
+	decoded, err := DecodeValues(types, encoded)
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{
+		int32(-1), int64(-2), float32(3.5), float64(4.5), uintptr(5), v128, int32(6),
+	}, decoded)
+}
+
+func TestEncodeValues_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		types       []ValueType
+		values      []interface{}
+		expectedErr string
+	}{
+		{
+			name:        "count",
+			types:       []ValueType{ValueTypeI32},
+			expectedErr: "expected 1 values, but passed 0",
+		},
+		{
+			name:        "type",
+			types:       []ValueType{ValueTypeI32, ValueTypeF32},
+			values:      []interface{}{int32(1), float64(2)},
+			expectedErr: "value[1] is float64, but expected f32",
+		},
+		{
+			name:        "unsupported",
+			types:       []ValueType{100},
+			values:      []interface{}{int32(1)},
+			expectedErr: "value[0] has unsupported type unknown",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := EncodeValues(tc.types, tc.values...)
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestDecodeValues_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		types       []ValueType
+		encoded     []uint64
+		expectedErr string
+	}{
+		{
+			name:        "count",
+			types:       []ValueType{ValueTypeI32, ValueTypeV128},
+			encoded:     []uint64{1, 2},
+			expectedErr: "expected 3 encoded values, but passed 2",
+		},
+		{
+			name:        "unsupported",
+			types:       []ValueType{100},
+			encoded:     []uint64{1},
+			expectedErr: "value[0] has unsupported type unknown",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := DecodeValues(tc.types, tc.encoded)
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
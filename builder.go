@@ -2,6 +2,8 @@ package wazero
 
 import (
 	"context"
+	"fmt"
+	"sort"
 
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/internal/wasm"
@@ -178,6 +180,17 @@ type HostModuleBuilder interface {
 	// NewFunctionBuilder begins the definition of a host function.
 	NewFunctionBuilder() HostFunctionBuilder
 
+	// ExportMemory exports the memory under the name, sharing it with the
+	// modules importing it, so that they can exchange data without copying.
+	//
+	// The memory is either one returned by Runtime.NewMemory or the memory of
+	// a module, in the same Runtime. To export it under several module names,
+	// export it from several host modules.
+	//
+	// Note: Compile errs if the memory wasn't created by wazero, or if this
+	// exports different memories, as a module can only have one.
+	ExportMemory(name string, memory api.Memory) HostModuleBuilder
+
 	// Compile returns a CompiledModule that can instantiated in any namespace (Namespace).
 	//
 	// Note: Closing the Namespace has the same effect as closing the result.
@@ -213,6 +226,7 @@ type hostModuleBuilder struct {
 	moduleName   string
 	nameToGoFunc map[string]interface{}
 	funcToNames  map[string]*wasm.HostFuncNames
+	nameToMemory map[string]api.Memory
 }
 
 // NewHostModuleBuilder implements Runtime.NewHostModuleBuilder
@@ -222,6 +236,7 @@ func (r *runtime) NewHostModuleBuilder(moduleName string) HostModuleBuilder {
 		moduleName:   moduleName,
 		nameToGoFunc: map[string]interface{}{},
 		funcToNames:  map[string]*wasm.HostFuncNames{},
+		nameToMemory: map[string]api.Memory{},
 	}
 }
 
@@ -311,28 +326,65 @@ func (b *hostModuleBuilder) NewFunctionBuilder() HostFunctionBuilder {
 	return &hostFunctionBuilder{b: b}
 }
 
+// ExportMemory implements HostModuleBuilder.ExportMemory
+func (b *hostModuleBuilder) ExportMemory(name string, memory api.Memory) HostModuleBuilder {
+	b.nameToMemory[name] = memory
+	return b
+}
+
 // Compile implements HostModuleBuilder.Compile
 func (b *hostModuleBuilder) Compile(ctx context.Context) (CompiledModule, error) {
 	module, err := wasm.NewHostModule(b.moduleName, b.nameToGoFunc, b.funcToNames, b.r.enabledFeatures)
 	if err != nil {
 		return nil, err
+	} else if err = b.exportMemory(module); err != nil {
+		return nil, err
 	} else if err = module.Validate(b.r.enabledFeatures); err != nil {
 		return nil, err
 	}
 
 	c := &compiledModule{module: module, compiledEngine: b.r.store.Engine}
-	listeners, err := buildListeners(ctx, module)
+	listeners, err := buildListeners(ctx, b.r.listenerFactory, module)
 	if err != nil {
 		return nil, err
 	}
 
-	if err = b.r.store.Engine.CompileModule(ctx, module, listeners); err != nil {
+	// Host functions are implemented in Go, so there is no guest code to interrupt.
+	if err = b.r.store.Engine.CompileModule(ctx, module, listeners, false); err != nil {
 		return nil, err
 	}
 
+	b.r.leaks.trackCompiled(c)
+	notifyCompiled(ctx, b.r.store, c)
 	return c, nil
 }
 
+// exportMemory adds the memory exported with ExportMemory, if any, to the module.
+func (b *hostModuleBuilder) exportMemory(module *wasm.Module) error {
+	if len(b.nameToMemory) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(b.nameToMemory))
+	for name := range b.nameToMemory {
+		names = append(names, name)
+	}
+	sort.Strings(names) // for consistent errors and exports
+
+	var memory *wasm.MemoryInstance
+	for _, name := range names {
+		mem, ok := b.nameToMemory[name].(*wasm.MemoryInstance)
+		if !ok {
+			return fmt.Errorf("memory[%s.%s] wasn't created by wazero", b.moduleName, name)
+		} else if memory != nil && mem != memory {
+			return fmt.Errorf("memory[%s.%s] differs from memory[%s.%s]: a module can only export one memory",
+				b.moduleName, name, b.moduleName, names[0])
+		}
+		memory = mem
+	}
+	module.ExportHostMemory(names, memory)
+	return nil
+}
+
 // Instantiate implements HostModuleBuilder.Instantiate
 func (b *hostModuleBuilder) Instantiate(ctx context.Context, ns Namespace) (api.Module, error) {
 	if compiled, err := b.Compile(ctx); err != nil {
@@ -264,6 +264,22 @@ func TestNewHostModuleBuilder_Compile(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "ExportMemory twice",
+			input: func(r Runtime) HostModuleBuilder {
+				mem, err := r.NewMemory(1, 2)
+				require.NoError(t, err)
+				// Intentionally out of order
+				return r.NewHostModuleBuilder("").ExportMemory("2", mem).ExportMemory("1", mem)
+			},
+			expected: &wasm.Module{
+				MemorySection: &wasm.Memory{Min: 1, Cap: 1, Max: 2, IsMaxEncoded: true},
+				ExportSection: []*wasm.Export{
+					{Name: "1", Type: wasm.ExternTypeMemory, Index: 0},
+					{Name: "2", Type: wasm.ExternTypeMemory, Index: 0},
+				},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -312,6 +328,22 @@ func TestNewHostModuleBuilder_Compile_Errors(t *testing.T) {
 	have ()
 	want (i32)`,
 		},
+		{
+			name: "memory not created by wazero",
+			input: func(rt Runtime) HostModuleBuilder {
+				return rt.NewHostModuleBuilder("env").ExportMemory("memory", struct{ api.Memory }{})
+			},
+			expectedErr: "memory[env.memory] wasn't created by wazero",
+		},
+		{
+			name: "different memories",
+			input: func(rt Runtime) HostModuleBuilder {
+				mem1, _ := rt.NewMemory(1, 1)
+				mem2, _ := rt.NewMemory(1, 1)
+				return rt.NewHostModuleBuilder("env").ExportMemory("a", mem1).ExportMemory("b", mem2)
+			},
+			expectedErr: "memory[env.b] differs from memory[env.a]: a module can only export one memory",
+		},
 	}
 
 	for _, tt := range tests {
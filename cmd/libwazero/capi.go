@@ -0,0 +1,109 @@
+package main
+
+/*
+#include <stdint.h>
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"runtime/cgo"
+	"unsafe"
+)
+
+// The functions below are the C API, which converts C types and errors, then
+// delegates to the function of the same name without the prefix. Functions
+// which can fail return zero or -1, and set *err to a message, unless err is
+// NULL. The message must be freed with wazero_error_free.
+
+//export wazero_runtime_new
+func wazero_runtime_new() C.uintptr_t {
+	return C.uintptr_t(newRuntime())
+}
+
+//export wazero_compile
+func wazero_compile(runtime C.uintptr_t, wasm *C.uint8_t, wasmLen C.size_t, err **C.char) C.uintptr_t {
+	// Copy the binary, as the caller may free it before the compiled module.
+	b := C.GoBytes(unsafe.Pointer(wasm), C.int(wasmLen))
+	h, e := compile(cgo.Handle(runtime), b)
+	if e != nil {
+		setError(err, e)
+		return 0
+	}
+	return C.uintptr_t(h)
+}
+
+//export wazero_instantiate
+func wazero_instantiate(runtime, compiled C.uintptr_t, name *C.char, err **C.char) C.uintptr_t {
+	h, e := instantiate(cgo.Handle(runtime), cgo.Handle(compiled), C.GoString(name))
+	if e != nil {
+		setError(err, e)
+		return 0
+	}
+	return C.uintptr_t(h)
+}
+
+//export wazero_call
+func wazero_call(module C.uintptr_t, name *C.char, params *C.uint64_t, paramsLen C.size_t, results *C.uint64_t, resultsLen C.size_t, err **C.char) C.int {
+	e := call(cgo.Handle(module), C.GoString(name), goUint64s(params, paramsLen), goUint64s(results, resultsLen))
+	return result(err, e)
+}
+
+//export wazero_memory_size
+func wazero_memory_size(module C.uintptr_t) C.uint32_t {
+	return C.uint32_t(memorySize(cgo.Handle(module)))
+}
+
+//export wazero_memory_read
+func wazero_memory_read(module C.uintptr_t, offset C.uint32_t, buf *C.uint8_t, bufLen C.uint32_t, err **C.char) C.int {
+	e := memoryRead(cgo.Handle(module), uint32(offset), goBytes(buf, bufLen))
+	return result(err, e)
+}
+
+//export wazero_memory_write
+func wazero_memory_write(module C.uintptr_t, offset C.uint32_t, buf *C.uint8_t, bufLen C.uint32_t, err **C.char) C.int {
+	e := memoryWrite(cgo.Handle(module), uint32(offset), goBytes(buf, bufLen))
+	return result(err, e)
+}
+
+//export wazero_close
+func wazero_close(handle C.uintptr_t, err **C.char) C.int {
+	return result(err, closeHandle(cgo.Handle(handle)))
+}
+
+//export wazero_error_free
+func wazero_error_free(err *C.char) {
+	C.free(unsafe.Pointer(err))
+}
+
+// result returns -1 and sets err if e is non-nil, or zero otherwise.
+func result(err **C.char, e error) C.int {
+	if e != nil {
+		setError(err, e)
+		return -1
+	}
+	return 0
+}
+
+// setError sets *err to a C copy of the message of e, unless err is NULL.
+func setError(err **C.char, e error) {
+	if err != nil {
+		*err = C.CString(e.Error())
+	}
+}
+
+// goBytes returns a slice backed by the C buffer, which must not be retained.
+func goBytes(buf *C.uint8_t, n C.uint32_t) []byte {
+	if n == 0 {
+		return nil
+	}
+	return unsafe.Slice((*byte)(unsafe.Pointer(buf)), int(n))
+}
+
+// goUint64s returns a slice backed by the C array, which must not be retained.
+func goUint64s(p *C.uint64_t, n C.size_t) []uint64 {
+	if n == 0 {
+		return nil
+	}
+	return unsafe.Slice((*uint64)(unsafe.Pointer(p)), int(n))
+}
@@ -0,0 +1,7 @@
+module github.com/tetratelabs/wazero/cmd/libwazero
+
+go 1.17
+
+require github.com/tetratelabs/wazero v0.0.0
+
+replace github.com/tetratelabs/wazero => ../../
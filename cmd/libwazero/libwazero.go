@@ -0,0 +1,208 @@
+// Package main is built with -buildmode=c-shared into libwazero, a library
+// which exposes a minimal C API to embed wazero in applications not written in
+// Go. See README.md for the API.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime/cgo"
+	"sync"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+func main() {}
+
+// Handles of runtimes, compiled modules and modules are passed to C as
+// cgo.Handle, which are never zero, so zero is returned on error.
+//
+// The live handles are tracked, as using a deleted cgo.Handle panics, and C
+// callers may use a handle after closing it, or close it twice.
+var (
+	handlesMux sync.Mutex
+	handles    = map[cgo.Handle]struct{}{}
+)
+
+// newRuntime returns the handle of a new wazero.Runtime, with WASI
+// instantiated, so that modules compiled by other toolchains can run.
+func newRuntime() cgo.Handle {
+	ctx := context.Background()
+	rt := wazero.NewRuntime(ctx)
+	wasi_snapshot_preview1.MustInstantiate(ctx, rt)
+	return newHandle(rt)
+}
+
+// compile compiles the binary with the runtime of the handle, returning the
+// handle of the wazero.CompiledModule.
+func compile(runtime cgo.Handle, wasm []byte) (cgo.Handle, error) {
+	rt, ok := value(runtime).(wazero.Runtime)
+	if !ok {
+		return 0, errors.New("invalid runtime handle")
+	}
+	compiled, err := rt.CompileModule(context.Background(), wasm)
+	if err != nil {
+		return 0, err
+	}
+	return newHandle(compiled), nil
+}
+
+// instantiate instantiates the compiled module with the runtime of the
+// handle, returning the handle of the api.Module.
+//
+// Like `wazero repl`, "_initialize" is called instead of "_start", so that the
+// caller can call exported functions. Standard I/O is the process's.
+func instantiate(runtime, compiled cgo.Handle, name string) (cgo.Handle, error) {
+	rt, ok := value(runtime).(wazero.Runtime)
+	if !ok {
+		return 0, errors.New("invalid runtime handle")
+	}
+	c, ok := value(compiled).(wazero.CompiledModule)
+	if !ok {
+		return 0, errors.New("invalid compiled module handle")
+	}
+	config := wazero.NewModuleConfig().
+		WithName(name).
+		WithArgs(name).
+		WithStartFunctions("_initialize").
+		WithStdin(os.Stdin).
+		WithStdout(os.Stdout).
+		WithStderr(os.Stderr).
+		WithSysWalltime().
+		WithSysNanotime()
+	mod, err := rt.InstantiateModule(context.Background(), c, config)
+	if err != nil {
+		return 0, err
+	}
+	return newHandle(mod), nil
+}
+
+// call calls the function exported by the module of the handle, writing its
+// results to the start of results, which must be large enough to hold them.
+func call(module cgo.Handle, name string, params, results []uint64) error {
+	mod, ok := value(module).(api.Module)
+	if !ok {
+		return errors.New("invalid module handle")
+	}
+	fn := mod.ExportedFunction(name)
+	if fn == nil {
+		return fmt.Errorf("module[%s] doesn't export function[%s]", mod.Name(), name)
+	}
+	if n := len(fn.Definition().ResultTypes()); n > len(results) {
+		return fmt.Errorf("%s has %d results, but there is space for %d", name, n, len(results))
+	}
+	res, err := fn.Call(context.Background(), params...)
+	if err != nil {
+		return err
+	}
+	copy(results, res)
+	return nil
+}
+
+// memory returns the memory exported by the module of the handle.
+func memory(module cgo.Handle) (api.Memory, error) {
+	mod, ok := value(module).(api.Module)
+	if !ok {
+		return nil, errors.New("invalid module handle")
+	}
+	mem := mod.Memory()
+	if mem == nil {
+		return nil, fmt.Errorf("module[%s] has no memory", mod.Name())
+	}
+	return mem, nil
+}
+
+// memorySize returns the size in bytes of the memory of the module of the
+// handle, or zero if it has none.
+func memorySize(module cgo.Handle) uint32 {
+	mem, err := memory(module)
+	if err != nil {
+		return 0
+	}
+	return mem.Size()
+}
+
+// memoryRead copies the memory of the module of the handle at offset to buf.
+func memoryRead(module cgo.Handle, offset uint32, buf []byte) error {
+	mem, err := memory(module)
+	if err != nil {
+		return err
+	}
+	b, ok := mem.Read(offset, uint32(len(buf)))
+	if !ok {
+		return fmt.Errorf("out of range reading %d bytes at offset %d", len(buf), offset)
+	}
+	copy(buf, b)
+	return nil
+}
+
+// memoryWrite copies b to the memory of the module of the handle at offset.
+func memoryWrite(module cgo.Handle, offset uint32, b []byte) error {
+	mem, err := memory(module)
+	if err != nil {
+		return err
+	}
+	if !mem.Write(offset, b) {
+		return fmt.Errorf("out of range writing %d bytes at offset %d", len(b), offset)
+	}
+	return nil
+}
+
+// closeHandle closes the runtime, compiled module or module of the handle,
+// and deletes the handle.
+//
+// Closing a runtime closes what it compiled and instantiated, but their
+// handles must still be closed to be deleted.
+func closeHandle(h cgo.Handle) (err error) {
+	ctx := context.Background()
+	switch v := value(h).(type) {
+	case wazero.Runtime:
+		err = v.Close(ctx)
+	case wazero.CompiledModule:
+		err = v.Close(ctx)
+	case api.Module:
+		err = v.Close(ctx)
+	default:
+		return errors.New("invalid handle")
+	}
+	if !deleteHandle(h) { // closed concurrently
+		return errors.New("invalid handle")
+	}
+	return
+}
+
+// newHandle returns a new live handle of the value.
+func newHandle(v interface{}) cgo.Handle {
+	h := cgo.NewHandle(v)
+	handlesMux.Lock()
+	handles[h] = struct{}{}
+	handlesMux.Unlock()
+	return h
+}
+
+// value returns the value of the handle, or nil if it isn't live, e.g. zero
+// or closed.
+func value(h cgo.Handle) interface{} {
+	handlesMux.Lock()
+	defer handlesMux.Unlock()
+	if _, ok := handles[h]; !ok {
+		return nil
+	}
+	return h.Value()
+}
+
+// deleteHandle deletes the handle, returning false if it wasn't live.
+func deleteHandle(h cgo.Handle) bool {
+	handlesMux.Lock()
+	defer handlesMux.Unlock()
+	if _, ok := handles[h]; !ok {
+		return false
+	}
+	delete(handles, h)
+	h.Delete()
+	return true
+}
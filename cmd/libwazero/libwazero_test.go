@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/cgo"
+	"testing"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+// addWasm exports "add" and a memory of one page.
+var addWasm = binary.EncodeModule(&wasm.Module{
+	TypeSection: []*wasm.FunctionType{
+		{Params: []api.ValueType{api.ValueTypeI32, api.ValueTypeI32}, Results: []api.ValueType{api.ValueTypeI32}},
+	},
+	FunctionSection: []wasm.Index{0},
+	CodeSection: []*wasm.Code{
+		{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Add, wasm.OpcodeEnd}},
+	},
+	MemorySection: &wasm.Memory{Min: 1, Max: 1},
+	ExportSection: []*wasm.Export{
+		{Name: "add", Type: wasm.ExternTypeFunc, Index: 0},
+		{Name: "memory", Type: wasm.ExternTypeMemory, Index: 0},
+	},
+})
+
+// instantiateAdd returns the handles of a new runtime and addWasm
+// instantiated in it.
+func instantiateAdd(t *testing.T) (runtime, module cgo.Handle) {
+	runtime = newRuntime()
+	t.Cleanup(func() { require.NoError(t, closeHandle(runtime)) })
+
+	compiled, err := compile(runtime, addWasm)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, closeHandle(compiled)) })
+
+	module, err = instantiate(runtime, compiled, "add")
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, closeHandle(module)) })
+	return
+}
+
+func TestCall(t *testing.T) {
+	_, module := instantiateAdd(t)
+
+	results := make([]uint64, 2)
+	require.NoError(t, call(module, "add", []uint64{1, 2}, results))
+	require.Equal(t, []uint64{3, 0}, results)
+
+	err := call(module, "sub", []uint64{1, 2}, results)
+	require.EqualError(t, err, "module[add] doesn't export function[sub]")
+
+	err = call(module, "add", []uint64{1, 2}, nil)
+	require.EqualError(t, err, "add has 1 results, but there is space for 0")
+
+	err = call(module, "add", []uint64{1}, results)
+	require.EqualError(t, err, "expected 2 params, but passed 1")
+}
+
+func TestMemory(t *testing.T) {
+	runtime, module := instantiateAdd(t)
+
+	require.Equal(t, uint32(wasm.MemoryPageSize), memorySize(module))
+	require.NoError(t, memoryWrite(module, 8, []byte("hello")))
+	buf := make([]byte, 5)
+	require.NoError(t, memoryRead(module, 8, buf))
+	require.Equal(t, "hello", string(buf))
+
+	err := memoryRead(module, wasm.MemoryPageSize, buf)
+	require.EqualError(t, err, "out of range reading 5 bytes at offset 65536")
+	err = memoryWrite(module, wasm.MemoryPageSize-1, buf)
+	require.EqualError(t, err, "out of range writing 5 bytes at offset 65535")
+
+	// The runtime isn't a module, so has no memory.
+	require.Zero(t, memorySize(runtime))
+	err = memoryRead(runtime, 0, buf)
+	require.EqualError(t, err, "invalid module handle")
+}
+
+func TestInvalidHandles(t *testing.T) {
+	runtime, module := instantiateAdd(t)
+
+	_, err := compile(0, addWasm)
+	require.EqualError(t, err, "invalid runtime handle")
+	_, err = instantiate(runtime, module, "add")
+	require.EqualError(t, err, "invalid compiled module handle")
+	err = call(runtime, "add", nil, nil)
+	require.EqualError(t, err, "invalid module handle")
+	require.EqualError(t, closeHandle(0), "invalid handle")
+
+	// A handle which was never returned, e.g. a stale or arbitrary value.
+	err = call(cgo.Handle(12345678), "add", nil, nil)
+	require.EqualError(t, err, "invalid module handle")
+	require.EqualError(t, closeHandle(cgo.Handle(12345678)), "invalid handle")
+
+	// A handle of a value which isn't closable is left live.
+	other := newHandle("other")
+	require.EqualError(t, closeHandle(other), "invalid handle")
+	require.True(t, deleteHandle(other))
+}
+
+func TestClosedHandles(t *testing.T) {
+	runtime := newRuntime()
+	t.Cleanup(func() { require.NoError(t, closeHandle(runtime)) })
+	compiled, err := compile(runtime, addWasm)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, closeHandle(compiled)) })
+	module, err := instantiate(runtime, compiled, "add")
+	require.NoError(t, err)
+
+	require.NoError(t, closeHandle(module))
+	// Using or closing a closed handle fails instead of panicking.
+	err = call(module, "add", []uint64{1, 2}, make([]uint64, 1))
+	require.EqualError(t, err, "invalid module handle")
+	require.Zero(t, memorySize(module))
+	require.EqualError(t, closeHandle(module), "invalid handle")
+}
+
+// TestExample builds libwazero, and testdata/example.c with it, which is
+// skipped unless a C compiler is available.
+func TestExample(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping building the shared library in short mode")
+	}
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("skipping as there's no C compiler")
+	}
+
+	dir := t.TempDir()
+	build := exec.Command("go", "build", "-buildmode=c-shared", "-o", filepath.Join(dir, "libwazero.so"), ".")
+	out, err := build.CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	example := filepath.Join(dir, "example")
+	out, err = exec.Command(cc, "-o", example, "-I", dir, filepath.Join("testdata", "example.c"),
+		"-L", dir, "-lwazero", "-Wl,-rpath,"+dir).CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	wasmPath := filepath.Join(dir, "add.wasm")
+	require.NoError(t, os.WriteFile(wasmPath, addWasm, 0o600))
+	var stdout, stderr bytes.Buffer
+	run := exec.Command(example, wasmPath)
+	run.Stdout, run.Stderr = &stdout, &stderr
+	require.NoError(t, run.Run(), stderr.String())
+	require.Equal(t, "add(1, 2) = 3\nmemory[8:13] = hello of 65536 bytes\n", stdout.String())
+	require.Equal(t, "call: module[example] doesn't export function[sub]\n", stderr.String())
+}
@@ -0,0 +1,63 @@
+// example embeds wazero with libwazero. It calls "add" with 1 and 2 in the
+// WebAssembly binary at the path of its argument, which must also export
+// memory, then writes and reads back a string in it.
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+
+#include "libwazero.h"
+
+static int fail(const char *op, char *err) {
+  fprintf(stderr, "%s: %s\n", op, err);
+  wazero_error_free(err);
+  return 1;
+}
+
+int main(int argc, char **argv) {
+  char *err = NULL;
+
+  FILE *f = fopen(argv[1], "rb");
+  if (f == NULL) {
+    perror("fopen");
+    return 1;
+  }
+  uint8_t wasm[4096];
+  size_t wasm_len = fread(wasm, 1, sizeof(wasm), f);
+  fclose(f);
+
+  uintptr_t runtime = wazero_runtime_new();
+  uintptr_t compiled = wazero_compile(runtime, wasm, wasm_len, &err);
+  if (compiled == 0) {
+    return fail("compile", err);
+  }
+  uintptr_t module = wazero_instantiate(runtime, compiled, "example", &err);
+  if (module == 0) {
+    return fail("instantiate", err);
+  }
+
+  uint64_t params[2] = {1, 2};
+  uint64_t results[1];
+  if (wazero_call(module, "add", params, 2, results, 1, &err) != 0) {
+    return fail("call", err);
+  }
+  printf("add(1, 2) = %llu\n", (unsigned long long)results[0]);
+
+  char *hello = "hello";
+  char buf[6] = {0};
+  if (wazero_memory_write(module, 8, (uint8_t *)hello, 5, &err) != 0) {
+    return fail("memory_write", err);
+  }
+  if (wazero_memory_read(module, 8, (uint8_t *)buf, 5, &err) != 0) {
+    return fail("memory_read", err);
+  }
+  printf("memory[8:13] = %s of %u bytes\n", buf, wazero_memory_size(module));
+
+  if (wazero_call(module, "sub", params, 2, results, 1, &err) != 0) {
+    fail("call", err);
+  }
+
+  wazero_close(module, NULL);
+  wazero_close(compiled, NULL);
+  wazero_close(runtime, NULL);
+  return 0;
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bufio"
+	"compress/flate"
+	"encoding/binary"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/tetratelabs/wazero/internal/version"
+)
+
+func doCache(args []string, stdOut, stdErr io.Writer, exit func(code int)) {
+	flags := flag.NewFlagSet("cache", flag.ExitOnError)
+	flags.SetOutput(stdErr)
+
+	var help bool
+	flags.BoolVar(&help, "h", false, "print usage")
+
+	cacheDir := cacheDirFlag(flags)
+
+	var stale bool
+	flags.BoolVar(&stale, "stale", false, "only clear entries which this version of wazero can't use.")
+
+	_ = flags.Parse(args)
+	var command string
+	if flags.NArg() > 0 {
+		// Allow flags after the command, e.g. "cache ls -cachedir=dir".
+		command = flags.Arg(0)
+		_ = flags.Parse(flags.Args()[1:])
+	}
+
+	if help {
+		printCacheUsage(stdErr, flags)
+		exit(0)
+	}
+
+	if command == "" {
+		fmt.Fprintln(stdErr, "missing cache command")
+		printCacheUsage(stdErr, flags)
+		exit(1)
+	}
+
+	switch command {
+	case "ls", "stats", "clear":
+	default:
+		fmt.Fprintf(stdErr, "invalid cache command: %s\n", command)
+		printCacheUsage(stdErr, flags)
+		exit(1)
+	}
+
+	if *cacheDir == "" {
+		fmt.Fprintln(stdErr, "missing cachedir")
+		exit(1)
+	}
+
+	entries, err := readCacheEntries(*cacheDir)
+	if err != nil {
+		fmt.Fprintf(stdErr, "invalid cachedir: %v\n", err)
+		exit(1)
+	}
+
+	switch command {
+	case "ls":
+		w := tabwriter.NewWriter(stdOut, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tSIZE\tPLATFORM\tWAZERO\tFUNCTIONS\tSTATUS")
+		for _, e := range entries {
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%d\t%s\n", e.name, e.size, e.platform, e.version, e.functions, e.status())
+		}
+		_ = w.Flush()
+	case "stats":
+		var size, staleCount, staleSize int64
+		versions := map[string]int{}
+		for _, e := range entries {
+			size += e.size
+			if e.isStale() {
+				staleCount++
+				staleSize += e.size
+			}
+			versions[e.version]++
+		}
+		fmt.Fprintf(stdOut, "entries: %d (%d bytes)\n", len(entries), size)
+		fmt.Fprintf(stdOut, "stale: %d (%d bytes)\n", staleCount, staleSize)
+		names := make([]string, 0, len(versions))
+		for v, n := range versions {
+			names = append(names, fmt.Sprintf("%s (%d)", v, n))
+		}
+		sort.Strings(names)
+		fmt.Fprintf(stdOut, "wazero versions: %s\n", strings.Join(names, ", "))
+	case "clear":
+		var count, size int64
+		for _, e := range entries {
+			if stale && !e.isStale() {
+				continue
+			}
+			if err = os.Remove(filepath.Join(*cacheDir, e.name)); err != nil {
+				fmt.Fprintf(stdErr, "error clearing cache: %v\n", err)
+				exit(1)
+			}
+			count++
+			size += e.size
+		}
+		fmt.Fprintf(stdOut, "removed %d entries (%d bytes)\n", count, size)
+	}
+	exit(0)
+}
+
+// cacheEntry is a file in the compilation cache directory.
+type cacheEntry struct {
+	name string
+	size int64
+	// platform is the GOARCH-GOOS the entry was compiled for.
+	platform string
+	// version is the version of wazero which compiled the entry, or "?" if
+	// the header is invalid.
+	version string
+	// functions is the number of functions compiled.
+	functions uint32
+}
+
+// isStale returns true if this version of wazero, on this platform, won't
+// use the entry.
+func (e *cacheEntry) isStale() bool {
+	return e.platform != runtime.GOARCH+"-"+runtime.GOOS || e.version != version.GetWazeroVersion()
+}
+
+func (e *cacheEntry) status() string {
+	if e.isStale() {
+		return "stale"
+	}
+	return "ok"
+}
+
+// readCacheEntries returns the entries in the compilation cache directory,
+// ignoring any files not named like an entry, i.e. GOARCH-GOOS-key.
+func readCacheEntries(dir string) ([]*cacheEntry, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var ret []*cacheEntry
+	for _, f := range files {
+		parts := strings.Split(f.Name(), "-")
+		if f.IsDir() || len(parts) != 3 || len(parts[2]) != hex.EncodedLen(32) {
+			continue
+		}
+		if _, err = hex.DecodeString(parts[2]); err != nil {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			return nil, err
+		}
+		e := &cacheEntry{name: f.Name(), size: info.Size(), platform: parts[0] + "-" + parts[1], version: "?"}
+		if err = e.readHeader(filepath.Join(dir, f.Name())); err != nil {
+			return nil, err
+		}
+		ret = append(ret, e)
+	}
+	return ret, nil
+}
+
+// readHeader reads the version and number of functions from the header of
+// the entry, which is "WAZERO", the length of the version and the version,
+// a byte and the little-endian uint32 number of functions.
+//
+// Entries compressed with DEFLATE are decompressed first. They start with
+// "ZWAZERO", the length of the name of the compression and the name.
+func (e *cacheEntry) readHeader(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = bufio.NewReader(f)
+	if prefix, _ := r.(*bufio.Reader).Peek(8); len(prefix) == 8 && string(prefix[:7]) == "ZWAZERO" {
+		name := make([]byte, 8+int(prefix[7]))
+		if _, err = io.ReadFull(r, name); err != nil || string(name[8:]) != "deflate" {
+			return nil
+		}
+		fr := flate.NewReader(r)
+		defer fr.Close()
+		r = fr
+	}
+
+	header := make([]byte, 7+255+1+4)
+	n, _ := io.ReadFull(r, header)
+	header = header[:n]
+	if n < 7 || string(header[:6]) != "WAZERO" {
+		return nil
+	}
+	versionEnd := 7 + int(header[6])
+	if n < versionEnd+5 {
+		return nil
+	}
+	e.version = string(header[7:versionEnd])
+	e.functions = binary.LittleEndian.Uint32(header[versionEnd+1:])
+	return nil
+}
+
+func printCacheUsage(stdErr io.Writer, flags *flag.FlagSet) {
+	fmt.Fprintln(stdErr, "wazero CLI")
+	fmt.Fprintln(stdErr)
+	fmt.Fprintln(stdErr, "Usage:\n  wazero cache <options> <command>")
+	fmt.Fprintln(stdErr)
+	fmt.Fprintln(stdErr, "Commands:")
+	fmt.Fprintln(stdErr, "  ls\t\tLists the entries of the compilation cache")
+	fmt.Fprintln(stdErr, "  stats\t\tPrints the number and size of entries, and the versions of wazero which compiled them")
+	fmt.Fprintln(stdErr, "  clear\t\tRemoves entries from the compilation cache")
+	fmt.Fprintln(stdErr)
+	fmt.Fprintln(stdErr, "Options:")
+	flags.PrintDefaults()
+}
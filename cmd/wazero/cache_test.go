@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestCache(t *testing.T) {
+	dir := t.TempDir()
+	cacheDir := filepath.Join(dir, "cache")
+	wasmPath := filepath.Join(dir, "test.wasm")
+	require.NoError(t, os.WriteFile(wasmPath, wasmWasiArg, 0o600))
+
+	exitCode, _, stdErr := runMain(t, []string{"compile", "--cachedir=" + cacheDir, wasmPath})
+	require.Equal(t, 0, exitCode, stdErr)
+
+	// Add an entry compiled by another version of wazero, with 2 functions.
+	staleName := runtime.GOARCH + "-" + runtime.GOOS + "-" + strings.Repeat("ab", 32)
+	stale := append([]byte("WAZERO\x06v0.0.1\x00"), 2, 0, 0, 0)
+	require.NoError(t, os.WriteFile(filepath.Join(cacheDir, staleName), stale, 0o600))
+	// Files not named like an entry are ignored.
+	otherPath := filepath.Join(cacheDir, "README")
+	require.NoError(t, os.WriteFile(otherPath, []byte("not an entry"), 0o600))
+
+	t.Run("ls", func(t *testing.T) {
+		exitCode, stdOut, stdErr := runMain(t, []string{"cache", "ls", "--cachedir=" + cacheDir})
+		require.Equal(t, 0, exitCode, stdErr)
+		lines := strings.Split(strings.TrimSpace(stdOut), "\n")
+		require.Equal(t, 3, len(lines), stdOut)
+		require.True(t, strings.HasPrefix(lines[0], "NAME"), lines[0])
+		require.Equal(t, []string{staleName, "18", runtime.GOARCH + "-" + runtime.GOOS, "v0.0.1", "2", "stale"},
+			strings.Fields(lines[1]))
+		require.True(t, strings.HasSuffix(lines[2], " ok"), lines[2])
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		exitCode, stdOut, stdErr := runMain(t, []string{"cache", "--cachedir=" + cacheDir, "stats"})
+		require.Equal(t, 0, exitCode, stdErr)
+		require.Contains(t, stdOut, "entries: 2 (")
+		require.Contains(t, stdOut, "stale: 1 (18 bytes)\n")
+		require.Contains(t, stdOut, "v0.0.1 (1)")
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		exitCode, stdOut, stdErr := runMain(t, []string{"cache", "clear", "--stale", "--cachedir=" + cacheDir})
+		require.Equal(t, 0, exitCode, stdErr)
+		require.Equal(t, "removed 1 entries (18 bytes)\n", stdOut)
+
+		exitCode, stdOut, stdErr = runMain(t, []string{"cache", "clear", "--cachedir=" + cacheDir})
+		require.Equal(t, 0, exitCode, stdErr)
+		require.Contains(t, stdOut, "removed 1 entries (")
+
+		entries, err := os.ReadDir(cacheDir)
+		require.NoError(t, err)
+		require.Equal(t, 1, len(entries))
+		require.Equal(t, "README", entries[0].Name())
+	})
+}
+
+func TestCache_Compressed(t *testing.T) {
+	cacheDir := t.TempDir()
+
+	// Add an entry compressed with DEFLATE, as configured with
+	// experimental.WithCompilationCacheCompression.
+	var entry bytes.Buffer
+	entry.WriteString("ZWAZERO\x07deflate")
+	w, err := flate.NewWriter(&entry, flate.BestSpeed)
+	require.NoError(t, err)
+	_, err = w.Write(append([]byte("WAZERO\x06v0.0.1\x00"), 2, 0, 0, 0))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	name := runtime.GOARCH + "-" + runtime.GOOS + "-" + strings.Repeat("ab", 32)
+	require.NoError(t, os.WriteFile(filepath.Join(cacheDir, name), entry.Bytes(), 0o600))
+
+	exitCode, stdOut, stdErr := runMain(t, []string{"cache", "ls", "--cachedir=" + cacheDir})
+	require.Equal(t, 0, exitCode, stdErr)
+	lines := strings.Split(strings.TrimSpace(stdOut), "\n")
+	require.Equal(t, 2, len(lines), stdOut)
+	require.Equal(t, []string{name, strconv.Itoa(entry.Len()), runtime.GOARCH + "-" + runtime.GOOS, "v0.0.1", "2", "stale"},
+		strings.Fields(lines[1]))
+}
+
+func TestCache_Errors(t *testing.T) {
+	tests := []struct {
+		message string
+		args    []string
+	}{
+		{
+			message: "missing cache command",
+			args:    []string{},
+		},
+		{
+			message: "invalid cache command: rm",
+			args:    []string{"rm"},
+		},
+		{
+			message: "missing cachedir",
+			args:    []string{"ls"},
+		},
+		{
+			message: "invalid cachedir",
+			args:    []string{"ls", "--cachedir=" + filepath.Join(t.TempDir(), "missing")},
+		},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.message, func(t *testing.T) {
+			exitCode, _, stdErr := runMain(t, append([]string{"cache"}, tt.args...))
+			require.Equal(t, 1, exitCode)
+			require.Contains(t, stdErr, tt.message)
+		})
+	}
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/leb128"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+func doInspect(args []string, stdOut, stdErr io.Writer, exit func(code int)) {
+	flags := flag.NewFlagSet("inspect", flag.ExitOnError)
+	flags.SetOutput(stdErr)
+
+	var help bool
+	flags.BoolVar(&help, "h", false, "print usage")
+
+	var asJSON bool
+	flags.BoolVar(&asJSON, "json", false, "print the result as a JSON object instead of text")
+
+	_ = flags.Parse(args)
+
+	if help {
+		printInspectUsage(stdErr, flags)
+		exit(0)
+	}
+
+	if flags.NArg() < 1 {
+		fmt.Fprintln(stdErr, "missing path to wasm file")
+		printInspectUsage(stdErr, flags)
+		exit(1)
+	}
+	wasmPath := flags.Arg(0)
+
+	wasm, err := readWasm(wasmPath)
+	if err != nil {
+		fmt.Fprintf(stdErr, "error reading wasm binary: %v\n", err)
+		exit(1)
+	}
+
+	i, err := inspect(wasm)
+	if err != nil {
+		fmt.Fprintf(stdErr, "error inspecting wasm binary: %v\n", err)
+		exit(1)
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(stdOut)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(i)
+	} else {
+		i.writeText(stdOut)
+	}
+	exit(0)
+}
+
+// inspection is what "wazero inspect" prints about a module.
+type inspection struct {
+	// Name is the module name in the custom name section, if any.
+	Name string `json:"name,omitempty"`
+	// Features are the names of the api.CoreFeatures the module requires.
+	Features []string  `json:"features"`
+	Sections []section `json:"sections"`
+	Imports  []extern  `json:"imports"`
+	Exports  []extern  `json:"exports"`
+	Memory   *limits   `json:"memory,omitempty"`
+	Tables   []limits  `json:"tables"`
+	Custom   []string  `json:"custom_sections"`
+}
+
+// section is the size in bytes of a section, excluding its ID and size.
+type section struct {
+	ID string `json:"id"`
+	// Name is the name of a custom section.
+	Name string `json:"name,omitempty"`
+	Size uint32 `json:"size"`
+}
+
+// extern is an import or export, and its type in the text format, e.g.
+// "(func (param i32) (result i32))".
+type extern struct {
+	Module string `json:"module,omitempty"`
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+}
+
+// limits are the limits of a memory, in pages, or of a table, in elements.
+type limits struct {
+	// RefType is the element type of a table.
+	RefType string  `json:"reftype,omitempty"`
+	Min     uint32  `json:"min"`
+	Max     *uint32 `json:"max,omitempty"`
+}
+
+// inspect decodes and validates the binary, and returns what it contains.
+func inspect(bin []byte) (*inspection, error) {
+	m, err := decode(bin, api.CoreFeaturesV2|api.CoreFeatureCustomPageSizes)
+	if err != nil {
+		return nil, err
+	}
+
+	i := &inspection{
+		Features: requiredFeatures(bin),
+		Sections: sections(bin),
+		Imports:  []extern{},
+		Exports:  []extern{},
+		Tables:   []limits{},
+		Custom:   []string{},
+	}
+	if m.NameSection != nil {
+		i.Name = m.NameSection.ModuleName
+	}
+	for _, s := range i.Sections {
+		if s.ID == wasm.SectionIDName(wasm.SectionIDCustom) {
+			i.Custom = append(i.Custom, s.Name)
+		}
+	}
+
+	// Build the index namespaces, where imports precede definitions.
+	var funcs []*wasm.FunctionType
+	var tables []*wasm.Table
+	var mem *wasm.Memory
+	var globals []*wasm.GlobalType
+	for _, imp := range m.ImportSection {
+		var typ string
+		switch imp.Type {
+		case wasm.ExternTypeFunc:
+			ft := m.TypeSection[imp.DescFunc]
+			funcs = append(funcs, ft)
+			typ = funcType(ft)
+		case wasm.ExternTypeTable:
+			tables = append(tables, imp.DescTable)
+			typ = tableType(imp.DescTable)
+		case wasm.ExternTypeMemory:
+			mem = imp.DescMem
+			typ = memoryType(imp.DescMem)
+		case wasm.ExternTypeGlobal:
+			globals = append(globals, imp.DescGlobal)
+			typ = globalType(imp.DescGlobal)
+		}
+		i.Imports = append(i.Imports, extern{Module: imp.Module, Name: imp.Name, Type: typ})
+	}
+	for _, idx := range m.FunctionSection {
+		funcs = append(funcs, m.TypeSection[idx])
+	}
+	for _, t := range m.TableSection {
+		tables = append(tables, t)
+		i.Tables = append(i.Tables, tableLimits(t))
+	}
+	if m.MemorySection != nil {
+		mem = m.MemorySection
+		l := memoryLimits(mem)
+		i.Memory = &l
+	}
+	for _, g := range m.GlobalSection {
+		globals = append(globals, g.Type)
+	}
+
+	for _, exp := range m.ExportSection {
+		var typ string
+		switch exp.Type {
+		case wasm.ExternTypeFunc:
+			typ = funcType(funcs[exp.Index])
+		case wasm.ExternTypeTable:
+			typ = tableType(tables[exp.Index])
+		case wasm.ExternTypeMemory:
+			typ = memoryType(mem)
+		case wasm.ExternTypeGlobal:
+			typ = globalType(globals[exp.Index])
+		}
+		i.Exports = append(i.Exports, extern{Name: exp.Name, Type: typ})
+	}
+	return i, nil
+}
+
+// decode decodes and validates the binary with the given features enabled.
+func decode(bin []byte, features api.CoreFeatures) (*wasm.Module, error) {
+	m, err := binary.DecodeModule(bin, features, wasm.MemoryLimitPages, false, false, false)
+	if err != nil {
+		return nil, err
+	}
+	if err = m.Validate(features); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// requiredFeatures returns the names of the features which the binary can't
+// be decoded or validated without.
+func requiredFeatures(bin []byte) []string {
+	ret := []string{}
+	for _, f := range []api.CoreFeatures{
+		// These are mutually dependent, so are only disabled together.
+		api.CoreFeatureBulkMemoryOperations | api.CoreFeatureReferenceTypes,
+		api.CoreFeatureMultiValue,
+		api.CoreFeatureMutableGlobal,
+		api.CoreFeatureNonTrappingFloatToIntConversion,
+		api.CoreFeatureSignExtensionOps,
+		api.CoreFeatureSIMD,
+		api.CoreFeatureCustomPageSizes,
+	} {
+		if _, err := decode(bin, (api.CoreFeaturesV2|api.CoreFeatureCustomPageSizes)&^f); err != nil {
+			ret = append(ret, strings.Split(f.String(), "|")...)
+		}
+	}
+	return ret
+}
+
+// sections returns the sections of the binary, which must be valid.
+func sections(bin []byte) (ret []section) {
+	r := bytes.NewReader(bin[8:]) // skip the magic number and version
+	for r.Len() > 0 {
+		id, _ := r.ReadByte()
+		size, _, _ := leb128.DecodeUint32(r)
+		s := section{ID: wasm.SectionIDName(id), Size: size}
+		content := make([]byte, size)
+		_, _ = io.ReadFull(r, content)
+		if id == wasm.SectionIDCustom {
+			cr := bytes.NewReader(content)
+			n, _, _ := leb128.DecodeUint32(cr)
+			name := make([]byte, n)
+			_, _ = io.ReadFull(cr, name)
+			s.Name = string(name)
+		}
+		ret = append(ret, s)
+	}
+	return
+}
+
+// funcType returns the function type in the text format, e.g.
+// "(func (param i32 i32) (result i32))".
+func funcType(ft *wasm.FunctionType) string {
+	var ret strings.Builder
+	ret.WriteString("(func")
+	writeValueTypes(&ret, "param", ft.Params)
+	writeValueTypes(&ret, "result", ft.Results)
+	ret.WriteByte(')')
+	return ret.String()
+}
+
+func writeValueTypes(ret *strings.Builder, kind string, types []api.ValueType) {
+	if len(types) == 0 {
+		return
+	}
+	ret.WriteString(" (")
+	ret.WriteString(kind)
+	for _, t := range types {
+		ret.WriteByte(' ')
+		ret.WriteString(wasm.ValueTypeName(t))
+	}
+	ret.WriteByte(')')
+}
+
+// tableType returns the table type in the text format, e.g.
+// "(table 1 10 funcref)".
+func tableType(t *wasm.Table) string {
+	l := tableLimits(t)
+	return "(table " + l.String() + " " + l.RefType + ")"
+}
+
+// memoryType returns the memory type in the text format, e.g.
+// "(memory 1 10)".
+func memoryType(m *wasm.Memory) string {
+	l := memoryLimits(m)
+	return "(memory " + l.String() + ")"
+}
+
+// globalType returns the global type in the text format, e.g.
+// "(global (mut i32))".
+func globalType(g *wasm.GlobalType) string {
+	if g.Mutable {
+		return "(global (mut " + wasm.ValueTypeName(g.ValType) + "))"
+	}
+	return "(global " + wasm.ValueTypeName(g.ValType) + ")"
+}
+
+func tableLimits(t *wasm.Table) limits {
+	return limits{RefType: wasm.RefTypeName(t.Type), Min: t.Min, Max: t.Max}
+}
+
+func memoryLimits(m *wasm.Memory) limits {
+	l := limits{Min: m.Min}
+	if m.IsMaxEncoded {
+		max := m.Max
+		l.Max = &max
+	}
+	return l
+}
+
+// String returns the limits in the text format, e.g. "1 10".
+func (l limits) String() string {
+	if l.Max == nil {
+		return fmt.Sprint(l.Min)
+	}
+	return fmt.Sprintf("%d %d", l.Min, *l.Max)
+}
+
+// writeText writes the inspection as indented text.
+func (i *inspection) writeText(w io.Writer) {
+	if i.Name != "" {
+		fmt.Fprintf(w, "name: %s\n", i.Name)
+	}
+	features := "none"
+	if len(i.Features) > 0 {
+		features = strings.Join(i.Features, ", ")
+	}
+	fmt.Fprintf(w, "features: %s\n", features)
+
+	fmt.Fprintln(w, "sections:")
+	for _, s := range i.Sections {
+		if s.ID == wasm.SectionIDName(wasm.SectionIDCustom) {
+			fmt.Fprintf(w, "  custom %q: %d bytes\n", s.Name, s.Size)
+		} else {
+			fmt.Fprintf(w, "  %s: %d bytes\n", s.ID, s.Size)
+		}
+	}
+
+	if len(i.Imports) > 0 {
+		fmt.Fprintln(w, "imports:")
+		for _, e := range i.Imports {
+			fmt.Fprintf(w, "  %s.%s: %s\n", e.Module, e.Name, e.Type)
+		}
+	}
+
+	if len(i.Exports) > 0 {
+		fmt.Fprintln(w, "exports:")
+		for _, e := range i.Exports {
+			fmt.Fprintf(w, "  %s: %s\n", e.Name, e.Type)
+		}
+	}
+
+	if i.Memory != nil {
+		fmt.Fprintf(w, "memory: (memory %s)\n", i.Memory)
+	}
+
+	if len(i.Tables) > 0 {
+		fmt.Fprintln(w, "tables:")
+		for _, t := range i.Tables {
+			fmt.Fprintf(w, "  (table %s %s)\n", t, t.RefType)
+		}
+	}
+}
+
+func printInspectUsage(stdErr io.Writer, flags *flag.FlagSet) {
+	fmt.Fprintln(stdErr, "wazero CLI")
+	fmt.Fprintln(stdErr)
+	fmt.Fprintln(stdErr, "Usage:\n  wazero inspect <options> <path to wasm file>")
+	fmt.Fprintln(stdErr)
+	fmt.Fprintln(stdErr, "Options:")
+	flags.PrintDefaults()
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+// inspectWasm exports a mutable global and a function with multiple results,
+// so requires the features "multi-value" and "mutable-global".
+var inspectWasm = append(binary.EncodeModule(&wasm.Module{
+	TypeSection: []*wasm.FunctionType{
+		{Params: []api.ValueType{api.ValueTypeI32}},
+		{Results: []api.ValueType{api.ValueTypeI32, api.ValueTypeI64}},
+	},
+	ImportSection: []*wasm.Import{
+		{Module: "env", Name: "log", Type: wasm.ExternTypeFunc, DescFunc: 0},
+		{Module: "env", Name: "table", Type: wasm.ExternTypeTable, DescTable: &wasm.Table{Min: 1, Type: wasm.RefTypeFuncref}},
+	},
+	FunctionSection: []wasm.Index{1},
+	MemorySection:   &wasm.Memory{Min: 1, Max: 2, IsMaxEncoded: true},
+	GlobalSection: []*wasm.Global{
+		{
+			Type: &wasm.GlobalType{ValType: api.ValueTypeI32, Mutable: true},
+			Init: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0}},
+		},
+	},
+	CodeSection: []*wasm.Code{
+		{Body: []byte{wasm.OpcodeI32Const, 1, wasm.OpcodeI64Const, 2, wasm.OpcodeEnd}},
+	},
+	ExportSection: []*wasm.Export{
+		{Name: "pair", Type: wasm.ExternTypeFunc, Index: 1},
+		{Name: "memory", Type: wasm.ExternTypeMemory, Index: 0},
+		{Name: "counter", Type: wasm.ExternTypeGlobal, Index: 0},
+		{Name: "table", Type: wasm.ExternTypeTable, Index: 0},
+	},
+	NameSection: &wasm.NameSection{ModuleName: "test"},
+}), wasm.SectionIDCustom, 5, 4, 'm', 'e', 't', 'a')
+
+func TestInspect(t *testing.T) {
+	wasmPath := filepath.Join(t.TempDir(), "test.wasm")
+	require.NoError(t, os.WriteFile(wasmPath, inspectWasm, 0o600))
+
+	t.Run("text", func(t *testing.T) {
+		exitCode, stdOut, stdErr := runMain(t, []string{"inspect", wasmPath})
+		require.Equal(t, 0, exitCode, stdErr)
+		require.Equal(t, `name: test
+features: multi-value, mutable-global
+sections:
+  type: 10 bytes
+  import: 25 bytes
+  function: 2 bytes
+  memory: 4 bytes
+  global: 6 bytes
+  export: 35 bytes
+  code: 8 bytes
+  custom "name": 12 bytes
+  custom "meta": 5 bytes
+imports:
+  env.log: (func (param i32))
+  env.table: (table 1 funcref)
+exports:
+  pair: (func (result i32 i64))
+  memory: (memory 1 2)
+  counter: (global (mut i32))
+  table: (table 1 funcref)
+memory: (memory 1 2)
+`, stdOut)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		exitCode, stdOut, stdErr := runMain(t, []string{"inspect", "-json", wasmPath})
+		require.Equal(t, 0, exitCode, stdErr)
+		require.Equal(t, `{
+  "name": "test",
+  "features": [
+    "multi-value",
+    "mutable-global"
+  ],
+  "sections": [
+    {
+      "id": "type",
+      "size": 10
+    },
+    {
+      "id": "import",
+      "size": 25
+    },
+    {
+      "id": "function",
+      "size": 2
+    },
+    {
+      "id": "memory",
+      "size": 4
+    },
+    {
+      "id": "global",
+      "size": 6
+    },
+    {
+      "id": "export",
+      "size": 35
+    },
+    {
+      "id": "code",
+      "size": 8
+    },
+    {
+      "id": "custom",
+      "name": "name",
+      "size": 12
+    },
+    {
+      "id": "custom",
+      "name": "meta",
+      "size": 5
+    }
+  ],
+  "imports": [
+    {
+      "module": "env",
+      "name": "log",
+      "type": "(func (param i32))"
+    },
+    {
+      "module": "env",
+      "name": "table",
+      "type": "(table 1 funcref)"
+    }
+  ],
+  "exports": [
+    {
+      "name": "pair",
+      "type": "(func (result i32 i64))"
+    },
+    {
+      "name": "memory",
+      "type": "(memory 1 2)"
+    },
+    {
+      "name": "counter",
+      "type": "(global (mut i32))"
+    },
+    {
+      "name": "table",
+      "type": "(table 1 funcref)"
+    }
+  ],
+  "memory": {
+    "min": 1,
+    "max": 2
+  },
+  "tables": [],
+  "custom_sections": [
+    "name",
+    "meta"
+  ]
+}
+`, stdOut)
+	})
+}
+
+func TestInspect_Errors(t *testing.T) {
+	notWasmPath := filepath.Join(t.TempDir(), "bears.wasm")
+	require.NoError(t, os.WriteFile(notWasmPath, []byte("pooh"), 0o600))
+
+	tests := []struct {
+		message string
+		args    []string
+	}{
+		{
+			message: "missing path to wasm file",
+			args:    []string{},
+		},
+		{
+			message: "error reading wasm binary",
+			args:    []string{"non-existent.wasm"},
+		},
+		{
+			message: "error inspecting wasm binary",
+			args:    []string{notWasmPath},
+		},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.message, func(t *testing.T) {
+			exitCode, _, stdErr := runMain(t, append([]string{"inspect"}, tt.args...))
+
+			require.Equal(t, 1, exitCode)
+			require.Contains(t, stdErr, tt.message)
+		})
+	}
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/engine/compiler"
+	"github.com/tetratelabs/wazero/internal/leb128"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+func doObjdump(args []string, stdOut, stdErr io.Writer, exit func(code int)) {
+	flags := flag.NewFlagSet("objdump", flag.ExitOnError)
+	flags.SetOutput(stdErr)
+
+	var help bool
+	flags.BoolVar(&help, "h", false, "print usage")
+
+	var disassemble bool
+	flags.BoolVar(&disassemble, "disassemble", false, "print the instructions of each function")
+	flags.BoolVar(&disassemble, "d", false, "shorthand for disassemble")
+
+	var native bool
+	flags.BoolVar(&native, "native", false, "print the native code the compiler generates for each function, in hex. "+
+		"Only supported on platforms the compiler supports.")
+
+	_ = flags.Parse(args)
+
+	if help {
+		printObjdumpUsage(stdErr, flags)
+		exit(0)
+	}
+
+	if flags.NArg() < 1 {
+		fmt.Fprintln(stdErr, "missing path to wasm file")
+		printObjdumpUsage(stdErr, flags)
+		exit(1)
+	}
+	wasmPath := flags.Arg(0)
+
+	bin, err := readWasm(wasmPath)
+	if err != nil {
+		fmt.Fprintf(stdErr, "error reading wasm binary: %v\n", err)
+		exit(1)
+	}
+
+	m, err := decode(bin, api.CoreFeaturesV2)
+	if err != nil {
+		fmt.Fprintf(stdErr, "error decoding wasm binary: %v\n", err)
+		exit(1)
+	}
+	m.BuildFunctionDefinitions()
+
+	if !disassemble && !native {
+		// Without a function to print, print the section headers.
+		fmt.Fprintln(stdOut, "sections:")
+		for _, s := range sections(bin) {
+			if s.ID == wasm.SectionIDName(wasm.SectionIDCustom) {
+				fmt.Fprintf(stdOut, "  custom %q: %d bytes\n", s.Name, s.Size)
+			} else {
+				fmt.Fprintf(stdOut, "  %s: %d bytes\n", s.ID, s.Size)
+			}
+		}
+		exit(0)
+	}
+
+	imported := m.ImportFuncCount()
+	funcs := m.FunctionDefinitionSection[imported:]
+	if flags.NArg() > 1 {
+		funcs = findFunctions(funcs, flags.Arg(1))
+		if len(funcs) == 0 {
+			fmt.Fprintf(stdErr, "function %s not found\n", flags.Arg(1))
+			exit(1)
+		}
+	}
+
+	var nativeCode [][]byte
+	if native {
+		if !platform.CompilerSupported() {
+			fmt.Fprintln(stdErr, "invalid native: the compiler is not supported on this platform")
+			exit(1)
+		}
+		if nativeCode, err = compiler.NativeCode(context.Background(), api.CoreFeaturesV2, m); err != nil {
+			fmt.Fprintf(stdErr, "error compiling wasm binary: %v\n", err)
+			exit(1)
+		}
+	}
+
+	codeStart := codeSectionStart(bin)
+	for i, def := range funcs {
+		if i > 0 {
+			fmt.Fprintln(stdOut)
+		}
+		fmt.Fprintf(stdOut, "func[%d]%s: %s\n", def.Index(), functionNames(def), funcType(&wasm.FunctionType{Params: def.ParamTypes(), Results: def.ResultTypes()}))
+
+		code := m.CodeSection[def.Index()-imported]
+		if disassemble {
+			if err = disassembleBody(stdOut, code.Body, codeStart+code.BodyOffsetInCodeSection, m.TypeSection); err != nil {
+				fmt.Fprintf(stdErr, "error disassembling func[%d]: %v\n", def.Index(), err)
+				exit(1)
+			}
+		}
+		if native {
+			if disassemble {
+				fmt.Fprintln(stdOut, "native:")
+			}
+			fmt.Fprint(stdOut, hex.Dump(nativeCode[def.Index()-imported]))
+		}
+	}
+	exit(0)
+}
+
+// findFunctions returns the functions with the given index, name or export
+// name.
+func findFunctions(funcs []*wasm.FunctionDefinition, nameOrIndex string) (ret []*wasm.FunctionDefinition) {
+	idx, err := strconv.ParseUint(nameOrIndex, 10, 32)
+	for _, def := range funcs {
+		if err == nil && uint64(def.Index()) == idx {
+			ret = append(ret, def)
+			continue
+		}
+		if def.Name() == nameOrIndex {
+			ret = append(ret, def)
+			continue
+		}
+		for _, name := range def.ExportNames() {
+			if name == nameOrIndex {
+				ret = append(ret, def)
+				break
+			}
+		}
+	}
+	return
+}
+
+// functionNames returns the name and export names of the function, e.g.
+// ` $add (export "add")`, or empty if it has neither.
+func functionNames(def *wasm.FunctionDefinition) string {
+	var ret strings.Builder
+	if name := def.Name(); name != "" {
+		ret.WriteString(" $" + name)
+	}
+	for _, name := range def.ExportNames() {
+		fmt.Fprintf(&ret, " (export %q)", name)
+	}
+	return ret.String()
+}
+
+// codeSectionStart returns the offset of the contents of the code section in
+// the binary, which must be valid.
+func codeSectionStart(bin []byte) uint64 {
+	r := bytes.NewReader(bin[8:]) // skip the magic number and version
+	for r.Len() > 0 {
+		id, _ := r.ReadByte()
+		size, _, _ := leb128.DecodeUint32(r)
+		if id == wasm.SectionIDCode {
+			break
+		}
+		_, _ = r.Seek(int64(size), io.SeekCurrent)
+	}
+	return uint64(len(bin) - r.Len())
+}
+
+// disassembleBody writes the instructions of the function body, one per line,
+// prefixed with their offset in the binary and indented by their depth.
+func disassembleBody(w io.Writer, body []byte, offset uint64, types []*wasm.FunctionType) error {
+	r := bytes.NewReader(body)
+	var depth int
+	for r.Len() > 0 {
+		pc := offset + uint64(len(body)-r.Len())
+		op, _ := r.ReadByte()
+		instr, err := decodeInstruction(op, r, types)
+		if err != nil {
+			return fmt.Errorf("%s at offset %#x: %w", wasm.InstructionName(op), pc, err)
+		}
+		if (op == wasm.OpcodeElse || op == wasm.OpcodeEnd) && depth > 0 {
+			depth--
+		}
+		fmt.Fprintf(w, "  %06x: %s%s\n", pc, strings.Repeat("  ", depth), instr)
+		switch op {
+		case wasm.OpcodeBlock, wasm.OpcodeLoop, wasm.OpcodeIf, wasm.OpcodeElse:
+			depth++
+		}
+	}
+	return nil
+}
+
+// decodeInstruction returns the instruction with the given opcode in the text
+// format, reading its immediates from the reader, e.g. "i32.const 1".
+func decodeInstruction(op wasm.Opcode, r *bytes.Reader, types []*wasm.FunctionType) (string, error) {
+	name := wasm.InstructionName(op)
+	switch op {
+	case wasm.OpcodeBlock, wasm.OpcodeLoop, wasm.OpcodeIf:
+		bt, _, err := leb128.DecodeInt33AsInt64(r)
+		if err != nil {
+			return "", err
+		}
+		return name + blockType(bt, types), nil
+	case wasm.OpcodeBr, wasm.OpcodeBrIf, wasm.OpcodeCall,
+		wasm.OpcodeLocalGet, wasm.OpcodeLocalSet, wasm.OpcodeLocalTee,
+		wasm.OpcodeGlobalGet, wasm.OpcodeGlobalSet,
+		wasm.OpcodeTableGet, wasm.OpcodeTableSet, wasm.OpcodeRefFunc:
+		return immediates(name, r, 1)
+	case wasm.OpcodeBrTable:
+		n, _, err := leb128.DecodeUint32(r)
+		if err != nil {
+			return "", err
+		}
+		return immediates(name, r, int(n)+1)
+	case wasm.OpcodeCallIndirect:
+		typeIdx, _, err := leb128.DecodeUint32(r)
+		if err != nil {
+			return "", err
+		}
+		tableIdx, _, err := leb128.DecodeUint32(r)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s %d (type %d)", name, tableIdx, typeIdx), nil
+	case wasm.OpcodeTypedSelect:
+		n, _, err := leb128.DecodeUint32(r)
+		if err != nil {
+			return "", err
+		}
+		var ret strings.Builder
+		ret.WriteString(name + " (result")
+		for i := uint32(0); i < n; i++ {
+			t, err := r.ReadByte()
+			if err != nil {
+				return "", err
+			}
+			ret.WriteString(" " + wasm.ValueTypeName(t))
+		}
+		ret.WriteByte(')')
+		return ret.String(), nil
+	case wasm.OpcodeMemorySize, wasm.OpcodeMemoryGrow:
+		_, err := r.ReadByte() // reserved memory index
+		return name, err
+	case wasm.OpcodeI32Const:
+		v, _, err := leb128.DecodeInt32(r)
+		return fmt.Sprintf("%s %d", name, v), err
+	case wasm.OpcodeI64Const:
+		v, _, err := leb128.DecodeInt64(r)
+		return fmt.Sprintf("%s %d", name, v), err
+	case wasm.OpcodeF32Const:
+		b, err := readN(r, 4)
+		if err != nil {
+			return "", err
+		}
+		v := math.Float32frombits(uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24)
+		return name + " " + strconv.FormatFloat(float64(v), 'g', -1, 32), nil
+	case wasm.OpcodeF64Const:
+		b, err := readN(r, 8)
+		if err != nil {
+			return "", err
+		}
+		var bits uint64
+		for i := 7; i >= 0; i-- {
+			bits = bits<<8 | uint64(b[i])
+		}
+		return name + " " + strconv.FormatFloat(math.Float64frombits(bits), 'g', -1, 64), nil
+	case wasm.OpcodeRefNull:
+		t, err := r.ReadByte()
+		return name + " " + wasm.RefTypeName(t), err
+	case wasm.OpcodeMiscPrefix:
+		return decodeMiscInstruction(r)
+	case wasm.OpcodeVecPrefix:
+		return decodeVecInstruction(r)
+	}
+	if op >= wasm.OpcodeI32Load && op <= wasm.OpcodeI64Store32 {
+		return memArg(name, r)
+	}
+	if name == "" {
+		return "", fmt.Errorf("unknown opcode %#x", op)
+	}
+	return name, nil
+}
+
+func decodeMiscInstruction(r *bytes.Reader) (string, error) {
+	op, _, err := leb128.DecodeUint32(r)
+	if err != nil {
+		return "", err
+	}
+	name := wasm.MiscInstructionName(wasm.OpcodeMisc(op))
+	switch wasm.OpcodeMisc(op) {
+	case wasm.OpcodeMiscMemoryInit:
+		ret, err := immediates(name, r, 1)
+		if err == nil {
+			_, err = r.ReadByte() // reserved memory index
+		}
+		return ret, err
+	case wasm.OpcodeMiscMemoryCopy:
+		_, err = readN(r, 2) // reserved memory indexes
+		return name, err
+	case wasm.OpcodeMiscMemoryFill:
+		_, err = r.ReadByte() // reserved memory index
+		return name, err
+	case wasm.OpcodeMiscDataDrop, wasm.OpcodeMiscElemDrop,
+		wasm.OpcodeMiscTableGrow, wasm.OpcodeMiscTableSize, wasm.OpcodeMiscTableFill:
+		return immediates(name, r, 1)
+	case wasm.OpcodeMiscTableInit:
+		elemIdx, _, err := leb128.DecodeUint32(r)
+		if err != nil {
+			return "", err
+		}
+		tableIdx, _, err := leb128.DecodeUint32(r)
+		return fmt.Sprintf("%s %d %d", name, tableIdx, elemIdx), err
+	case wasm.OpcodeMiscTableCopy:
+		return immediates(name, r, 2)
+	}
+	if op > math.MaxUint8 || name == "" {
+		return "", fmt.Errorf("unknown misc opcode %#x", op)
+	}
+	return name, nil
+}
+
+func decodeVecInstruction(r *bytes.Reader) (string, error) {
+	op, _, err := leb128.DecodeUint32(r)
+	if err != nil {
+		return "", err
+	}
+	if op > math.MaxUint8 {
+		return "", fmt.Errorf("unknown vector opcode %#x", op)
+	}
+	vecOp := wasm.OpcodeVec(op)
+	name := wasm.VectorInstructionName(vecOp)
+	switch {
+	case vecOp <= wasm.OpcodeVecV128Store,
+		vecOp == wasm.OpcodeVecV128Load32zero, vecOp == wasm.OpcodeVecV128Load64zero:
+		return memArg(name, r)
+	case vecOp >= wasm.OpcodeVecV128Load8Lane && vecOp <= wasm.OpcodeVecV128Store64Lane:
+		ret, err := memArg(name, r)
+		if err != nil {
+			return "", err
+		}
+		lane, err := r.ReadByte()
+		return fmt.Sprintf("%s %d", ret, lane), err
+	case vecOp == wasm.OpcodeVecV128Const:
+		b, err := readN(r, 16)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s i8x16 %s", name, joinBytes(b)), nil
+	case vecOp == wasm.OpcodeVecV128i8x16Shuffle:
+		b, err := readN(r, 16)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s %s", name, joinBytes(b)), nil
+	case vecOp >= wasm.OpcodeVecI8x16ExtractLaneS && vecOp <= wasm.OpcodeVecF64x2ReplaceLane:
+		lane, err := r.ReadByte()
+		return fmt.Sprintf("%s %d", name, lane), err
+	}
+	if name == "" {
+		return "", fmt.Errorf("unknown vector opcode %#x", op)
+	}
+	return name, nil
+}
+
+// blockType returns the block type in the text format, e.g. " (result i32)",
+// or empty if the block has no params or results.
+func blockType(bt int64, types []*wasm.FunctionType) string {
+	switch {
+	case bt == -64: // 0x40
+		return ""
+	case bt < 0: // a single result, encoded as its value type
+		return " (result " + wasm.ValueTypeName(byte(bt&0x7f)) + ")"
+	case bt < int64(len(types)):
+		return fmt.Sprintf(" (type %d)", bt)
+	}
+	return fmt.Sprintf(" (type %d (invalid))", bt)
+}
+
+// immediates returns the instruction with n unsigned immediates read from r.
+func immediates(name string, r *bytes.Reader, n int) (string, error) {
+	var ret strings.Builder
+	ret.WriteString(name)
+	for i := 0; i < n; i++ {
+		v, _, err := leb128.DecodeUint32(r)
+		if err != nil {
+			return "", err
+		}
+		ret.WriteByte(' ')
+		ret.WriteString(strconv.FormatUint(uint64(v), 10))
+	}
+	return ret.String(), nil
+}
+
+// memArg returns the memory instruction with the alignment and offset read
+// from r, e.g. "i32.load offset=4 align=4".
+func memArg(name string, r *bytes.Reader) (string, error) {
+	align, _, err := leb128.DecodeUint32(r)
+	if err != nil {
+		return "", err
+	}
+	offset, _, err := leb128.DecodeUint32(r)
+	if err != nil {
+		return "", err
+	}
+	ret := name
+	if offset != 0 {
+		ret += fmt.Sprintf(" offset=%d", offset)
+	}
+	if align < 32 {
+		ret += fmt.Sprintf(" align=%d", uint64(1)<<align)
+	}
+	return ret, nil
+}
+
+func readN(r *bytes.Reader, n int) ([]byte, error) {
+	b := make([]byte, n)
+	_, err := io.ReadFull(r, b)
+	return b, err
+}
+
+func joinBytes(b []byte) string {
+	s := make([]string, len(b))
+	for i, v := range b {
+		s[i] = strconv.Itoa(int(v))
+	}
+	return strings.Join(s, " ")
+}
+
+func printObjdumpUsage(stdErr io.Writer, flags *flag.FlagSet) {
+	fmt.Fprintln(stdErr, "wazero CLI")
+	fmt.Fprintln(stdErr)
+	fmt.Fprintln(stdErr, "Usage:\n  wazero objdump <options> <path to wasm file> [function index or name]")
+	fmt.Fprintln(stdErr)
+	fmt.Fprintln(stdErr, "Options:")
+	flags.PrintDefaults()
+}
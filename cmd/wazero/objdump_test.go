@@ -0,0 +1,143 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+// objdumpWasm has a function with nested blocks and immediates of different
+// kinds.
+var objdumpWasm = binary.EncodeModule(&wasm.Module{
+	TypeSection: []*wasm.FunctionType{
+		{Params: []api.ValueType{api.ValueTypeI32}, Results: []api.ValueType{api.ValueTypeI32}},
+	},
+	FunctionSection: []wasm.Index{0, 0},
+	MemorySection:   &wasm.Memory{Min: 1},
+	CodeSection: []*wasm.Code{
+		{Body: []byte{
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeIf, wasm.ValueTypeI32,
+			wasm.OpcodeI32Const, 1,
+			wasm.OpcodeElse,
+			wasm.OpcodeLoop, 0x40,
+			wasm.OpcodeBr, 0,
+			wasm.OpcodeEnd,
+			wasm.OpcodeI32Const, 0x7f, // -1
+			wasm.OpcodeEnd,
+			wasm.OpcodeI32Load, 2, 4,
+			wasm.OpcodeEnd,
+		}},
+		{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeCall, 0, wasm.OpcodeEnd}},
+	},
+	ExportSection: []*wasm.Export{
+		{Name: "load", Type: wasm.ExternTypeFunc, Index: 0},
+		{Name: "memory", Type: wasm.ExternTypeMemory, Index: 0},
+	},
+	NameSection: &wasm.NameSection{FunctionNames: wasm.NameMap{{Index: 1, Name: "call_load"}}},
+})
+
+func TestObjdump(t *testing.T) {
+	wasmPath := filepath.Join(t.TempDir(), "test.wasm")
+	require.NoError(t, os.WriteFile(wasmPath, objdumpWasm, 0o600))
+
+	t.Run("sections", func(t *testing.T) {
+		exitCode, stdOut, stdErr := runMain(t, []string{"objdump", wasmPath})
+		require.Equal(t, 0, exitCode, stdErr)
+		require.Equal(t, `sections:
+  type: 6 bytes
+  function: 3 bytes
+  memory: 3 bytes
+  export: 17 bytes
+  code: 29 bytes
+  custom "name": 19 bytes
+`, stdOut)
+	})
+
+	t.Run("disassemble", func(t *testing.T) {
+		exitCode, stdOut, stdErr := runMain(t, []string{"objdump", "-d", wasmPath})
+		require.Equal(t, 0, exitCode, stdErr)
+		require.Equal(t, `func[0] (export "load"): (func (param i32) (result i32))
+  000032: local.get 0
+  000034: if (result i32)
+  000036:   i32.const 1
+  000038: else
+  000039:   loop
+  00003b:     br 0
+  00003d:   end
+  00003e:   i32.const -1
+  000040: end
+  000041: i32.load offset=4 align=4
+  000044: end
+
+func[1] $call_load: (func (param i32) (result i32))
+  000047: local.get 0
+  000049: call 0
+  00004b: end
+`, stdOut)
+	})
+
+	t.Run("disassemble function", func(t *testing.T) {
+		for _, name := range []string{"1", "call_load"} {
+			exitCode, stdOut, stdErr := runMain(t, []string{"objdump", "-d", wasmPath, name})
+			require.Equal(t, 0, exitCode, stdErr)
+			require.Equal(t, `func[1] $call_load: (func (param i32) (result i32))
+  000047: local.get 0
+  000049: call 0
+  00004b: end
+`, stdOut)
+		}
+	})
+
+	t.Run("native", func(t *testing.T) {
+		if !platform.CompilerSupported() {
+			t.Skip()
+		}
+		exitCode, stdOut, stdErr := runMain(t, []string{"objdump", "-native", wasmPath, "load"})
+		require.Equal(t, 0, exitCode, stdErr)
+		require.Contains(t, stdOut, `func[0] (export "load"): (func (param i32) (result i32))
+00000000  `)
+	})
+}
+
+func TestObjdump_Errors(t *testing.T) {
+	wasmPath := filepath.Join(t.TempDir(), "test.wasm")
+	require.NoError(t, os.WriteFile(wasmPath, objdumpWasm, 0o600))
+
+	tests := []struct {
+		message string
+		args    []string
+	}{
+		{
+			message: "missing path to wasm file",
+			args:    []string{},
+		},
+		{
+			message: "error reading wasm binary",
+			args:    []string{"non-existent.wasm"},
+		},
+		{
+			message: "error decoding wasm binary",
+			args:    []string{"testdata/wasi_arg.wat"},
+		},
+		{
+			message: "function add not found",
+			args:    []string{"-d", wasmPath, "add"},
+		},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.message, func(t *testing.T) {
+			exitCode, _, stdErr := runMain(t, append([]string{"objdump"}, tt.args...))
+			require.Equal(t, 1, exitCode)
+			require.Contains(t, stdErr, tt.message)
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+func doRepl(args []string, stdIn io.Reader, stdOut, stdErr io.Writer, exit func(code int)) {
+	flags := flag.NewFlagSet("repl", flag.ExitOnError)
+	flags.SetOutput(stdErr)
+
+	var help bool
+	flags.BoolVar(&help, "h", false, "print usage")
+
+	_ = flags.Parse(args)
+
+	if help {
+		printReplUsage(stdErr, flags)
+		exit(0)
+	}
+
+	if flags.NArg() < 1 {
+		fmt.Fprintln(stdErr, "missing path to wasm file")
+		printReplUsage(stdErr, flags)
+		exit(1)
+	}
+	wasmPath := flags.Arg(0)
+
+	wasm, err := readWasm(wasmPath)
+	if err != nil {
+		fmt.Fprintf(stdErr, "error reading wasm binary: %v\n", err)
+		exit(1)
+	}
+
+	ctx := context.Background()
+	rt := wazero.NewRuntime(ctx)
+	defer rt.Close(ctx)
+
+	code, err := rt.CompileModule(ctx, wasm)
+	if err != nil {
+		fmt.Fprintf(stdErr, "error compiling wasm binary: %v\n", err)
+		exit(1)
+	}
+
+	needsWASI, goModuleName := detectImports(code.ImportedFunctions())
+	if goModuleName != "" {
+		fmt.Fprintln(stdErr, "error instantiating wasm binary: GOOS=js binaries are not supported")
+		exit(1)
+	} else if needsWASI {
+		wasi_snapshot_preview1.MustInstantiate(ctx, rt)
+	}
+
+	// Don't run "_start", as functions are called interactively instead.
+	conf := wazero.NewModuleConfig().
+		WithStartFunctions("_initialize").
+		WithStdout(stdOut).
+		WithStderr(stdErr).
+		WithRandSource(rand.Reader).
+		WithSysNanosleep().
+		WithSysNanotime().
+		WithSysWalltime().
+		WithArgs(filepath.Base(wasmPath))
+	mod, err := rt.InstantiateModule(ctx, code, conf)
+	if err != nil {
+		fmt.Fprintf(stdErr, "error instantiating wasm binary: %v\n", err)
+		exit(1)
+	}
+
+	r := &repl{ctx: ctx, mod: mod, exports: code.ExportedFunctions(), stdOut: stdOut}
+	fmt.Fprintln(stdOut, `Type "help" for a list of commands.`)
+	scanner := bufio.NewScanner(stdIn)
+	for {
+		fmt.Fprint(stdOut, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(stdOut)
+			break
+		}
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "quit" || fields[0] == "exit" {
+			break
+		}
+		if err = r.eval(fields[0], fields[1:]); err != nil {
+			fmt.Fprintf(stdOut, "error: %v\n", err)
+		}
+	}
+	exit(0)
+}
+
+// repl evaluates commands against an instantiated module.
+type repl struct {
+	ctx     context.Context
+	mod     api.Module
+	exports map[string]api.FunctionDefinition
+	stdOut  io.Writer
+}
+
+// eval evaluates the command with the given arguments.
+func (r *repl) eval(command string, args []string) error {
+	switch command {
+	case "help":
+		fmt.Fprint(r.stdOut, `Commands:
+  <function> [params...]    calls the exported function
+  exports                   lists the exported functions
+  memory <offset> <length>  prints a range of memory in hex
+  help                      prints this message
+  quit                      exits
+`)
+		return nil
+	case "exports":
+		names := make([]string, 0, len(r.exports))
+		for name := range r.exports {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			def := r.exports[name]
+			ft := &wasm.FunctionType{Params: def.ParamTypes(), Results: def.ResultTypes()}
+			fmt.Fprintf(r.stdOut, "%s: %s\n", name, funcType(ft))
+		}
+		return nil
+	case "memory":
+		return r.memory(args)
+	}
+	return r.call(command, args)
+}
+
+// call calls the exported function with the parameters, and prints its
+// results separated by spaces.
+func (r *repl) call(name string, args []string) error {
+	def, ok := r.exports[name]
+	if !ok {
+		return fmt.Errorf("unknown command or function %q", name)
+	}
+	types := def.ParamTypes()
+	if len(args) != len(types) {
+		return fmt.Errorf("%s needs %d params, but %d were given", name, len(types), len(args))
+	}
+	params := make([]uint64, len(args))
+	for i, arg := range args {
+		p, err := parseValue(types[i], arg)
+		if err != nil {
+			return fmt.Errorf("param %d: %v", i, err)
+		}
+		params[i] = p
+	}
+
+	results, err := r.mod.ExportedFunction(name).Call(r.ctx, params...)
+	if err != nil {
+		return err
+	}
+	vals := make([]string, len(results))
+	for i, t := range def.ResultTypes() {
+		vals[i] = formatValue(t, results[i])
+	}
+	fmt.Fprintln(r.stdOut, strings.Join(vals, " "))
+	return nil
+}
+
+// memory prints the range of memory given by the offset and length in hex.
+func (r *repl) memory(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("memory needs an offset and length")
+	}
+	offset, err := strconv.ParseUint(args[0], 0, 32)
+	if err != nil {
+		return fmt.Errorf("invalid offset: %v", err)
+	}
+	length, err := strconv.ParseUint(args[1], 0, 32)
+	if err != nil {
+		return fmt.Errorf("invalid length: %v", err)
+	}
+	mem := r.mod.Memory()
+	if mem == nil {
+		return fmt.Errorf("module has no memory")
+	}
+	b, ok := mem.Read(uint32(offset), uint32(length))
+	if !ok {
+		return fmt.Errorf("range [%d, %d) is out of memory size %d", offset, offset+length, mem.Size())
+	}
+	fmt.Fprint(r.stdOut, hex.Dump(b))
+	return nil
+}
+
+// parseValue parses the text as a value of the given type. Integers can be
+// signed or unsigned, and in any base accepted by strconv.ParseInt.
+func parseValue(t api.ValueType, s string) (uint64, error) {
+	switch t {
+	case api.ValueTypeI32:
+		if v, err := strconv.ParseInt(s, 0, 32); err == nil {
+			return api.EncodeI32(int32(v)), nil
+		}
+		v, err := strconv.ParseUint(s, 0, 32)
+		return v, err
+	case api.ValueTypeI64:
+		if v, err := strconv.ParseInt(s, 0, 64); err == nil {
+			return api.EncodeI64(v), nil
+		}
+		return strconv.ParseUint(s, 0, 64)
+	case api.ValueTypeF32:
+		v, err := strconv.ParseFloat(s, 32)
+		return api.EncodeF32(float32(v)), err
+	case api.ValueTypeF64:
+		v, err := strconv.ParseFloat(s, 64)
+		return api.EncodeF64(v), err
+	}
+	return 0, fmt.Errorf("%s values are not supported", api.ValueTypeName(t))
+}
+
+// formatValue formats the value of the given type, with integers signed.
+func formatValue(t api.ValueType, v uint64) string {
+	switch t {
+	case api.ValueTypeI32:
+		return strconv.FormatInt(int64(int32(v)), 10)
+	case api.ValueTypeI64:
+		return strconv.FormatInt(int64(v), 10)
+	case api.ValueTypeF32:
+		return strconv.FormatFloat(float64(math.Float32frombits(uint32(v))), 'g', -1, 32)
+	case api.ValueTypeF64:
+		return strconv.FormatFloat(math.Float64frombits(v), 'g', -1, 64)
+	}
+	return fmt.Sprintf("0x%x", v)
+}
+
+func printReplUsage(stdErr io.Writer, flags *flag.FlagSet) {
+	fmt.Fprintln(stdErr, "wazero CLI")
+	fmt.Fprintln(stdErr)
+	fmt.Fprintln(stdErr, "Usage:\n  wazero repl <options> <path to wasm file>")
+	fmt.Fprintln(stdErr)
+	fmt.Fprintln(stdErr, "Options:")
+	flags.PrintDefaults()
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+// replWasm exports "add", which adds two i64s, "div", which divides two
+// f64s, and "store", which stores an i32 at an offset of its memory.
+var replWasm = binary.EncodeModule(&wasm.Module{
+	TypeSection: []*wasm.FunctionType{
+		{Params: []api.ValueType{api.ValueTypeI64, api.ValueTypeI64}, Results: []api.ValueType{api.ValueTypeI64}},
+		{Params: []api.ValueType{api.ValueTypeF64, api.ValueTypeF64}, Results: []api.ValueType{api.ValueTypeF64}},
+		{Params: []api.ValueType{api.ValueTypeI32, api.ValueTypeI32}},
+	},
+	FunctionSection: []wasm.Index{0, 1, 2},
+	MemorySection:   &wasm.Memory{Min: 1},
+	CodeSection: []*wasm.Code{
+		{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeI64Add, wasm.OpcodeEnd}},
+		{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeF64Div, wasm.OpcodeEnd}},
+		{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Store, 0x2, 0x0, wasm.OpcodeEnd}},
+	},
+	ExportSection: []*wasm.Export{
+		{Name: "add", Type: wasm.ExternTypeFunc, Index: 0},
+		{Name: "div", Type: wasm.ExternTypeFunc, Index: 1},
+		{Name: "store", Type: wasm.ExternTypeFunc, Index: 2},
+	},
+})
+
+func TestRepl(t *testing.T) {
+	wasmPath := filepath.Join(t.TempDir(), "test.wasm")
+	require.NoError(t, os.WriteFile(wasmPath, replWasm, 0o600))
+
+	stdIn := strings.NewReader(`exports
+add 1 -3
+add 0x10 1
+div 1 4
+store 16 0x64636261
+memory 16 4
+memory 65535 2
+store 1
+add one 1
+sub 1 2
+quit
+`)
+	exitCode, stdOut, stdErr := runRepl(t, []string{wasmPath}, stdIn)
+	require.Equal(t, 0, exitCode, stdErr)
+	require.Equal(t, `Type "help" for a list of commands.
+> add: (func (param i64 i64) (result i64))
+div: (func (param f64 f64) (result f64))
+store: (func (param i32 i32))
+> -2
+> 17
+> 0.25
+> 
+> 00000000  61 62 63 64                                       |abcd|
+> error: range [65535, 65537) is out of memory size 65536
+> error: store needs 2 params, but 1 were given
+> error: param 0: strconv.ParseUint: parsing "one": invalid syntax
+> error: unknown command or function "sub"
+> `, stdOut)
+}
+
+func TestRepl_Errors(t *testing.T) {
+	notWasmPath := filepath.Join(t.TempDir(), "bears.wasm")
+	require.NoError(t, os.WriteFile(notWasmPath, []byte("pooh"), 0o600))
+
+	tests := []struct {
+		message string
+		args    []string
+	}{
+		{
+			message: "missing path to wasm file",
+			args:    []string{},
+		},
+		{
+			message: "error reading wasm binary",
+			args:    []string{"non-existent.wasm"},
+		},
+		{
+			message: "error compiling wasm binary",
+			args:    []string{notWasmPath},
+		},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.message, func(t *testing.T) {
+			exitCode, _, stdErr := runRepl(t, tt.args, strings.NewReader(""))
+
+			require.Equal(t, 1, exitCode)
+			require.Contains(t, stdErr, tt.message)
+		})
+	}
+}
+
+// runRepl is like runMain, except it runs the repl command with the given
+// standard input.
+func runRepl(t *testing.T, args []string, stdIn *strings.Reader) (int, string, string) {
+	t.Helper()
+	var exitCode int
+	stdOut := &bytes.Buffer{}
+	stdErr := &bytes.Buffer{}
+	var exited bool
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				exited = true
+			}
+		}()
+		doRepl(args, stdIn, stdOut, stdErr, func(code int) {
+			exitCode = code
+			panic(code)
+		})
+	}()
+
+	require.True(t, exited)
+
+	return exitCode, stdOut.String(), stdErr.String()
+}
@@ -0,0 +1,496 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// defaultTenant is the tenant of all requests when no tenants are configured.
+const defaultTenant = "default"
+
+func doServe(args []string, stdErr io.Writer, exit func(code int)) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	flags.SetOutput(stdErr)
+
+	var help bool
+	flags.BoolVar(&help, "h", false, "print usage")
+
+	var addr string
+	flags.StringVar(&addr, "addr", "localhost:8080", "host:port to serve HTTP on.")
+
+	var tenants sliceFlag
+	flags.Var(&tenants, "tenant", "name:token of a tenant, which authenticates with the header "+
+		"\"Authorization: Bearer <token>\". Each tenant has its own modules, instances and quotas. "+
+		"If none are specified, requests aren't authenticated and share one tenant. Can be specified multiple times.")
+
+	var q quota
+	flags.IntVar(&q.modules, "max-modules", 16, "maximum count of modules each tenant can load.")
+	flags.IntVar(&q.instances, "max-instances", 64, "maximum count of instances each tenant can have at once.")
+	flags.Int64Var(&q.moduleBytes, "max-module-bytes", 64<<20, "maximum size of a wasm binary to load.")
+	flags.DurationVar(&q.timeout, "timeout", 0, "maximum duration of each instantiation or function call, "+
+		"beyond which the instance is closed. If 0, the timeout is disabled.")
+
+	var maxMemoryPages uint
+	flags.UintVar(&maxMemoryPages, "max-memory-pages", 0, "maximum memory of each instance in 64KiB pages, "+
+		"beyond which growing memory fails. If 0, the limit is 65536 pages (4GiB).")
+
+	cacheDir := cacheDirFlag(flags)
+
+	_ = flags.Parse(args)
+
+	if help {
+		printServeUsage(stdErr, flags)
+		exit(0)
+	}
+
+	tokens := map[string]string{}
+	for _, t := range tenants {
+		i := strings.IndexByte(t, ':')
+		if i <= 0 || i == len(t)-1 {
+			fmt.Fprintf(stdErr, "invalid tenant: %s is not in the form name:token\n", t)
+			exit(1)
+		}
+		tokens[t[i+1:]] = t[:i]
+	}
+	if maxMemoryPages > 65536 {
+		fmt.Fprintf(stdErr, "invalid max-memory-pages: %d > 65536\n", maxMemoryPages)
+		exit(1)
+	}
+
+	ctx := maybeUseCacheDir(context.Background(), cacheDir, stdErr, exit)
+
+	rtc := wazero.NewRuntimeConfig()
+	if q.timeout > 0 {
+		rtc = rtc.WithCloseOnContextDone(true)
+	}
+	if maxMemoryPages > 0 {
+		rtc = rtc.WithMemoryLimitPages(uint32(maxMemoryPages))
+	}
+	rt := wazero.NewRuntimeWithConfig(ctx, rtc)
+	defer rt.Close(ctx)
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+	if err := listenAndServe(ctx, addr, newServer(ctx, rt, tokens, q), stdErr); err != nil {
+		fmt.Fprintf(stdErr, "error serving: %v\n", err)
+		exit(1)
+	}
+	exit(0)
+}
+
+// quota limits the resources of each tenant.
+type quota struct {
+	modules, instances int
+	moduleBytes        int64
+	timeout            time.Duration
+}
+
+// server is an http.Handler which loads modules, instantiates them and calls
+// their functions on behalf of tenants.
+type server struct {
+	// ctx is the context of compilation, e.g. with a cache directory.
+	ctx context.Context
+	rt  wazero.Runtime
+	// tokens maps tokens to tenant names, or is empty if requests aren't
+	// authenticated.
+	tokens map[string]string
+	quota  quota
+
+	// mux protects tenants.
+	mux     sync.Mutex
+	tenants map[string]*tenant
+}
+
+// tenant is the state of a tenant, created on its first request.
+type tenant struct {
+	// ns isolates the instances of the tenant from others.
+	ns wazero.Namespace
+
+	// mux protects the fields below.
+	mux       sync.Mutex
+	modules   map[string]wazero.CompiledModule
+	instances map[string]*instance
+	// seq numbers instances, so that each has a unique ID.
+	seq uint64
+}
+
+// instance is an instance of a module of a tenant.
+type instance struct {
+	module string
+	mod    api.Module
+
+	// mux serializes calls, as their output is captured in stdout and stderr.
+	mux            sync.Mutex
+	stdout, stderr bytes.Buffer
+}
+
+func newServer(ctx context.Context, rt wazero.Runtime, tokens map[string]string, q quota) *server {
+	return &server{ctx: ctx, rt: rt, tokens: tokens, quota: q, tenants: map[string]*tenant{}}
+}
+
+// errorResponse is the body of an error response.
+type errorResponse struct {
+	Error string `json:"error"`
+	// ExitCode is set when the instance exited, and so was closed.
+	ExitCode *uint32 `json:"exit_code,omitempty"`
+}
+
+// moduleResponse describes a loaded module.
+type moduleResponse struct {
+	Name    string   `json:"name"`
+	Exports []string `json:"exports"`
+}
+
+// instantiateRequest is the optional body of a request to instantiate a
+// module.
+type instantiateRequest struct {
+	Args []string          `json:"args"`
+	Env  map[string]string `json:"env"`
+}
+
+// instanceResponse describes an instance.
+type instanceResponse struct {
+	ID     string `json:"id"`
+	Module string `json:"module"`
+}
+
+// callRequest is the body of a request to call a function.
+type callRequest struct {
+	// Params are formatted like the params of a function call in "wazero
+	// repl", e.g. "-1" or "1.5".
+	Params []string `json:"params"`
+}
+
+// callResponse is the body of the response to a function call.
+type callResponse struct {
+	Results []string `json:"results"`
+	Stdout  string   `json:"stdout"`
+	Stderr  string   `json:"stderr"`
+}
+
+// ServeHTTP implements http.Handler ServeHTTP with these endpoints:
+//
+//	GET    /modules                     lists loaded modules
+//	PUT    /modules/{name}              loads the wasm binary in the body
+//	DELETE /modules/{name}              unloads a module and closes its instances
+//	POST   /modules/{name}/instances    instantiates a module
+//	GET    /instances                   lists instances
+//	POST   /instances/{id}/calls/{func} calls an exported function
+//	DELETE /instances/{id}              closes an instance
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t, ok := s.tenant(r)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, errors.New("invalid token"))
+		return
+	}
+
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(path) == 1 && path[0] == "modules" && r.Method == http.MethodGet:
+		s.listModules(w, t)
+	case len(path) == 2 && path[0] == "modules" && r.Method == http.MethodPut:
+		s.loadModule(w, r, t, path[1])
+	case len(path) == 2 && path[0] == "modules" && r.Method == http.MethodDelete:
+		s.unloadModule(w, t, path[1])
+	case len(path) == 3 && path[0] == "modules" && path[2] == "instances" && r.Method == http.MethodPost:
+		s.instantiate(w, r, t, path[1])
+	case len(path) == 1 && path[0] == "instances" && r.Method == http.MethodGet:
+		s.listInstances(w, t)
+	case len(path) == 4 && path[0] == "instances" && path[2] == "calls" && r.Method == http.MethodPost:
+		s.call(w, r, t, path[1], path[3])
+	case len(path) == 2 && path[0] == "instances" && r.Method == http.MethodDelete:
+		s.closeInstance(w, t, path[1])
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("no endpoint for %s %s", r.Method, r.URL.Path))
+	}
+}
+
+// tenant returns the tenant authenticated by the request, creating it on its
+// first request, or false if the token is invalid.
+func (s *server) tenant(r *http.Request) (*tenant, bool) {
+	name := defaultTenant
+	if len(s.tokens) > 0 {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		name = ""
+		for t, n := range s.tokens {
+			if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+				name = n
+			}
+		}
+		if name == "" {
+			return nil, false
+		}
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	t, ok := s.tenants[name]
+	if !ok {
+		ns := s.rt.NewNamespace(s.ctx)
+		// Instantiate WASI in each namespace, as they don't share modules.
+		if _, err := wasi_snapshot_preview1.NewBuilder(s.rt).Instantiate(s.ctx, ns); err != nil {
+			panic(err) // only fails if already instantiated.
+		}
+		t = &tenant{ns: ns, modules: map[string]wazero.CompiledModule{}, instances: map[string]*instance{}}
+		s.tenants[name] = t
+	}
+	return t, true
+}
+
+func (s *server) listModules(w http.ResponseWriter, t *tenant) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	modules := make([]moduleResponse, 0, len(t.modules))
+	for name, compiled := range t.modules {
+		modules = append(modules, moduleResponse{Name: name, Exports: exportNames(compiled)})
+	}
+	sort.Slice(modules, func(i, j int) bool { return modules[i].Name < modules[j].Name })
+	writeJSON(w, http.StatusOK, modules)
+}
+
+func (s *server) loadModule(w http.ResponseWriter, r *http.Request, t *tenant, name string) {
+	bin, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.quota.moduleBytes))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if _, ok := t.modules[name]; ok {
+		writeError(w, http.StatusConflict, fmt.Errorf("module[%s] already loaded", name))
+		return
+	} else if len(t.modules) >= s.quota.modules {
+		writeError(w, http.StatusTooManyRequests, fmt.Errorf("quota of %d modules exceeded", s.quota.modules))
+		return
+	}
+	compiled, err := s.rt.CompileModule(s.ctx, bin)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	t.modules[name] = compiled
+	writeJSON(w, http.StatusCreated, moduleResponse{Name: name, Exports: exportNames(compiled)})
+}
+
+func (s *server) unloadModule(w http.ResponseWriter, t *tenant, name string) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	compiled, ok := t.modules[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("module[%s] not loaded", name))
+		return
+	}
+	for id, inst := range t.instances {
+		if inst.module == name {
+			_ = inst.mod.Close(s.ctx)
+			delete(t.instances, id)
+		}
+	}
+	_ = compiled.Close(s.ctx)
+	delete(t.modules, name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) instantiate(w http.ResponseWriter, r *http.Request, t *tenant, name string) {
+	var req instantiateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	compiled, ok := t.modules[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("module[%s] not loaded", name))
+		return
+	} else if len(t.instances) >= s.quota.instances {
+		writeError(w, http.StatusTooManyRequests, fmt.Errorf("quota of %d instances exceeded", s.quota.instances))
+		return
+	}
+
+	t.seq++
+	inst := &instance{module: name}
+	id := fmt.Sprintf("%s-%d", name, t.seq)
+	// Don't run "_start", as functions are called instead, like "wazero repl".
+	config := wazero.NewModuleConfig().
+		WithName(id).
+		WithStartFunctions("_initialize").
+		WithStdout(&inst.stdout).
+		WithStderr(&inst.stderr).
+		WithRandSource(rand.Reader).
+		WithSysNanosleep().
+		WithSysNanotime().
+		WithSysWalltime().
+		WithArgs(append([]string{name}, req.Args...)...)
+	for k, v := range req.Env {
+		config = config.WithEnv(k, v)
+	}
+
+	ctx, cancel := s.withTimeout(r.Context())
+	defer cancel()
+	mod, err := t.ns.InstantiateModule(ctx, compiled, config)
+	if err != nil {
+		writeCallError(w, err)
+		return
+	}
+	inst.mod = mod
+	t.instances[id] = inst
+	writeJSON(w, http.StatusCreated, instanceResponse{ID: id, Module: name})
+}
+
+func (s *server) listInstances(w http.ResponseWriter, t *tenant) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	instances := make([]instanceResponse, 0, len(t.instances))
+	for id, inst := range t.instances {
+		instances = append(instances, instanceResponse{ID: id, Module: inst.module})
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].ID < instances[j].ID })
+	writeJSON(w, http.StatusOK, instances)
+}
+
+func (s *server) call(w http.ResponseWriter, r *http.Request, t *tenant, id, name string) {
+	var req callRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	t.mux.Lock()
+	inst, ok := t.instances[id]
+	t.mux.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("instance[%s] not found", id))
+		return
+	}
+	fn := inst.mod.ExportedFunction(name)
+	if fn == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("instance[%s] doesn't export function[%s]", id, name))
+		return
+	}
+
+	def := fn.Definition()
+	types := def.ParamTypes()
+	if len(req.Params) != len(types) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("%s needs %d params, but %d were given", name, len(types), len(req.Params)))
+		return
+	}
+	params := make([]uint64, len(types))
+	for i, p := range req.Params {
+		v, err := parseValue(types[i], p)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("param %d: %v", i, err))
+			return
+		}
+		params[i] = v
+	}
+
+	ctx, cancel := s.withTimeout(r.Context())
+	defer cancel()
+	inst.mux.Lock()
+	defer inst.mux.Unlock()
+	inst.stdout.Reset()
+	inst.stderr.Reset()
+	results, err := fn.Call(ctx, params...)
+	if err != nil {
+		if _, ok := err.(*sys.ExitError); ok { // the instance was closed.
+			t.mux.Lock()
+			delete(t.instances, id)
+			t.mux.Unlock()
+		}
+		writeCallError(w, err)
+		return
+	}
+
+	res := callResponse{Results: make([]string, len(results)), Stdout: inst.stdout.String(), Stderr: inst.stderr.String()}
+	for i, typ := range def.ResultTypes() {
+		res.Results[i] = formatValue(typ, results[i])
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *server) closeInstance(w http.ResponseWriter, t *tenant, id string) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	inst, ok := t.instances[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("instance[%s] not found", id))
+		return
+	}
+	_ = inst.mod.Close(s.ctx)
+	delete(t.instances, id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// withTimeout returns the context with the timeout of the quota, if any.
+func (s *server) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.quota.timeout > 0 {
+		return context.WithTimeout(ctx, s.quota.timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// exportNames returns the names of the functions compiled exports, sorted.
+func exportNames(compiled wazero.CompiledModule) []string {
+	names := make([]string, 0, len(compiled.ExportedFunctions()))
+	for name := range compiled.ExportedFunctions() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// writeCallError writes the error of a function call, including the exit
+// code if the instance exited.
+func writeCallError(w http.ResponseWriter, err error) {
+	res := errorResponse{Error: err.Error()}
+	status := http.StatusInternalServerError
+	if exitErr, ok := err.(*sys.ExitError); ok {
+		code := exitErr.ExitCode()
+		res.ExitCode = &code
+		if code == sys.ExitCodeDeadlineExceeded {
+			status = http.StatusGatewayTimeout
+		}
+	}
+	writeJSON(w, status, res)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func printServeUsage(stdErr io.Writer, flags *flag.FlagSet) {
+	fmt.Fprintln(stdErr, "wazero CLI")
+	fmt.Fprintln(stdErr)
+	fmt.Fprintln(stdErr, "Usage:\n  wazero serve <options>")
+	fmt.Fprintln(stdErr)
+	fmt.Fprintln(stdErr, "Options:")
+	flags.PrintDefaults()
+}
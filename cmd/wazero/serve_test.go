@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+// serveWasm exports "add", "exit", which exits with code 3, and "spin", which
+// never returns.
+var serveWasm = binary.EncodeModule(&wasm.Module{
+	TypeSection: []*wasm.FunctionType{
+		{Params: []api.ValueType{api.ValueTypeI32}},
+		{Params: []api.ValueType{api.ValueTypeI32, api.ValueTypeI32}, Results: []api.ValueType{api.ValueTypeI32}},
+		{},
+	},
+	ImportSection: []*wasm.Import{
+		{Module: wasi_snapshot_preview1.ModuleName, Name: "proc_exit", Type: wasm.ExternTypeFunc, DescFunc: 0},
+	},
+	FunctionSection: []wasm.Index{1, 2, 2},
+	CodeSection: []*wasm.Code{
+		{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Add, wasm.OpcodeEnd}},
+		{Body: []byte{wasm.OpcodeI32Const, 3, wasm.OpcodeCall, 0, wasm.OpcodeEnd}},
+		{Body: []byte{wasm.OpcodeLoop, 0x40, wasm.OpcodeBr, 0, wasm.OpcodeEnd, wasm.OpcodeEnd}},
+	},
+	ExportSection: []*wasm.Export{
+		{Name: "add", Type: wasm.ExternTypeFunc, Index: 1},
+		{Name: "exit", Type: wasm.ExternTypeFunc, Index: 2},
+		{Name: "spin", Type: wasm.ExternTypeFunc, Index: 3},
+	},
+})
+
+// newTestServer returns a server with the given tokens and quota, which
+// requests are made to with the returned function. It returns the status and
+// body of the response.
+func newTestServer(t *testing.T, tokens map[string]string, q quota) func(method, path, token string, body []byte) (int, string) {
+	ctx := context.Background()
+	rt := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCloseOnContextDone(true))
+	t.Cleanup(func() { _ = rt.Close(ctx) })
+	s := newServer(ctx, rt, tokens, q)
+
+	return func(method, path, token string, body []byte) (int, string) {
+		r := httptest.NewRequest(method, path, bytes.NewReader(body))
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		b, err := io.ReadAll(w.Result().Body)
+		require.NoError(t, err)
+		return w.Result().StatusCode, string(b)
+	}
+}
+
+func jsonBody(t *testing.T, v interface{}) []byte {
+	b, err := json.Marshal(v)
+	require.NoError(t, err)
+	return b
+}
+
+func TestServer(t *testing.T) {
+	do := newTestServer(t, nil, quota{modules: 1, instances: 2, moduleBytes: 1 << 20})
+
+	status, body := do("PUT", "/modules/calc", "", serveWasm)
+	require.Equal(t, http.StatusCreated, status, body)
+	require.Equal(t, `{"name":"calc","exports":["add","exit","spin"]}`+"\n", body)
+
+	status, body = do("GET", "/modules", "", nil)
+	require.Equal(t, http.StatusOK, status, body)
+	require.Equal(t, `[{"name":"calc","exports":["add","exit","spin"]}]`+"\n", body)
+
+	status, body = do("POST", "/modules/calc/instances", "", nil)
+	require.Equal(t, http.StatusCreated, status, body)
+	require.Equal(t, `{"id":"calc-1","module":"calc"}`+"\n", body)
+
+	status, body = do("POST", "/instances/calc-1/calls/add", "", jsonBody(t, callRequest{Params: []string{"1", "-3"}}))
+	require.Equal(t, http.StatusOK, status, body)
+	require.Equal(t, `{"results":["-2"],"stdout":"","stderr":""}`+"\n", body)
+
+	// Exiting closes the instance.
+	status, body = do("POST", "/instances/calc-1/calls/exit", "", nil)
+	require.Equal(t, http.StatusInternalServerError, status, body)
+	require.Equal(t, `{"error":"module \"calc-1\" closed with exit_code(3)","exit_code":3}`+"\n", body)
+
+	status, body = do("GET", "/instances", "", nil)
+	require.Equal(t, http.StatusOK, status, body)
+	require.Equal(t, "[]\n", body)
+
+	status, body = do("POST", "/modules/calc/instances", "", nil)
+	require.Equal(t, http.StatusCreated, status, body)
+	status, body = do("DELETE", "/instances/calc-2", "", nil)
+	require.Equal(t, http.StatusNoContent, status, body)
+
+	// Unloading a module closes its instances.
+	status, body = do("POST", "/modules/calc/instances", "", nil)
+	require.Equal(t, http.StatusCreated, status, body)
+	status, body = do("DELETE", "/modules/calc", "", nil)
+	require.Equal(t, http.StatusNoContent, status, body)
+	status, body = do("GET", "/instances", "", nil)
+	require.Equal(t, http.StatusOK, status, body)
+	require.Equal(t, "[]\n", body)
+}
+
+func TestServer_Errors(t *testing.T) {
+	do := newTestServer(t, nil, quota{modules: 1, instances: 1, moduleBytes: 1 << 10, timeout: 10 * time.Millisecond})
+
+	status, body := do("PUT", "/modules/calc", "", serveWasm)
+	require.Equal(t, http.StatusCreated, status, body)
+	status, body = do("POST", "/modules/calc/instances", "", nil)
+	require.Equal(t, http.StatusCreated, status, body)
+
+	tests := []struct {
+		name, method, path string
+		body               []byte
+		expectedStatus     int
+		expectedBody       string
+	}{
+		{
+			name:           "no endpoint",
+			method:         "GET",
+			path:           "/modules/calc",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error":"no endpoint for GET /modules/calc"}`,
+		},
+		{
+			name:           "module already loaded",
+			method:         "PUT",
+			path:           "/modules/calc",
+			body:           serveWasm,
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"error":"module[calc] already loaded"}`,
+		},
+		{
+			name:           "module quota",
+			method:         "PUT",
+			path:           "/modules/calc2",
+			body:           serveWasm,
+			expectedStatus: http.StatusTooManyRequests,
+			expectedBody:   `{"error":"quota of 1 modules exceeded"}`,
+		},
+		{
+			name:           "module too large",
+			method:         "PUT",
+			path:           "/modules/calc2",
+			body:           make([]byte, 2<<10),
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedBody:   `{"error":"http: request body too large"}`,
+		},
+		{
+			name:           "instance quota",
+			method:         "POST",
+			path:           "/modules/calc/instances",
+			expectedStatus: http.StatusTooManyRequests,
+			expectedBody:   `{"error":"quota of 1 instances exceeded"}`,
+		},
+		{
+			name:           "module not loaded",
+			method:         "POST",
+			path:           "/modules/calc2/instances",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error":"module[calc2] not loaded"}`,
+		},
+		{
+			name:           "instance not found",
+			method:         "POST",
+			path:           "/instances/calc-2/calls/add",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error":"instance[calc-2] not found"}`,
+		},
+		{
+			name:           "function not found",
+			method:         "POST",
+			path:           "/instances/calc-1/calls/sub",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error":"instance[calc-1] doesn't export function[sub]"}`,
+		},
+		{
+			name:           "wrong param count",
+			method:         "POST",
+			path:           "/instances/calc-1/calls/add",
+			body:           jsonBody(t, callRequest{Params: []string{"1"}}),
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"add needs 2 params, but 1 were given"}`,
+		},
+		{
+			name:           "invalid param",
+			method:         "POST",
+			path:           "/instances/calc-1/calls/add",
+			body:           jsonBody(t, callRequest{Params: []string{"1", "one"}}),
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"param 1: strconv.ParseUint: parsing \"one\": invalid syntax"}`,
+		},
+		{
+			name:           "timeout",
+			method:         "POST",
+			path:           "/instances/calc-1/calls/spin",
+			expectedStatus: http.StatusGatewayTimeout,
+			expectedBody:   `{"error":"module \"calc-1\" closed with context deadline exceeded","exit_code":4026531839}`,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			status, body := do(tc.method, tc.path, "", tc.body)
+			require.Equal(t, tc.expectedStatus, status)
+			require.Equal(t, tc.expectedBody+"\n", body)
+		})
+	}
+}
+
+func TestServer_Tenants(t *testing.T) {
+	do := newTestServer(t, map[string]string{"a-token": "a", "b-token": "b"}, quota{modules: 1, instances: 1, moduleBytes: 1 << 20})
+
+	status, body := do("GET", "/modules", "", nil)
+	require.Equal(t, http.StatusUnauthorized, status)
+	require.Equal(t, `{"error":"invalid token"}`+"\n", body)
+	status, _ = do("GET", "/modules", "c-token", nil)
+	require.Equal(t, http.StatusUnauthorized, status)
+
+	// Each tenant has its own modules, instances and quotas.
+	for _, token := range []string{"a-token", "b-token"} {
+		status, body = do("PUT", "/modules/calc", token, serveWasm)
+		require.Equal(t, http.StatusCreated, status, body)
+		status, body = do("POST", "/modules/calc/instances", token, nil)
+		require.Equal(t, http.StatusCreated, status, body)
+		require.Equal(t, `{"id":"calc-1","module":"calc"}`+"\n", body)
+	}
+
+	status, body = do("DELETE", "/modules/calc", "a-token", nil)
+	require.Equal(t, http.StatusNoContent, status, body)
+	status, body = do("GET", "/instances", "b-token", nil)
+	require.Equal(t, http.StatusOK, status, body)
+	require.Equal(t, `[{"id":"calc-1","module":"calc"}]`+"\n", body)
+}
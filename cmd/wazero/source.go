@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/experimental/oci"
+)
+
+// readWasm reads the wasm binary at the path, or fetches it if the path is a
+// reference to a module in an OCI registry, e.g. oci://ghcr.io/org/mod:v1.
+func readWasm(path string) ([]byte, error) {
+	if strings.HasPrefix(path, oci.Scheme) {
+		return oci.Fetch(context.Background(), path, oci.WithCacheDir(ociCacheDir()))
+	}
+	return os.ReadFile(path)
+}
+
+// isRemote returns true if readWasm fetches the path instead of reading it.
+func isRemote(path string) bool {
+	return strings.HasPrefix(path, oci.Scheme)
+}
+
+// wasmName returns the name of the wasm binary at the path, e.g. "mod" for
+// oci://ghcr.io/org/mod:v1.
+func wasmName(path string) string {
+	if strings.HasPrefix(path, oci.Scheme) {
+		if ref, err := oci.ParseReference(path); err == nil {
+			path = ref.Repository
+		}
+	}
+	return filepath.Base(path)
+}
+
+// ociCacheDir returns the directory modules fetched from OCI registries are
+// cached in, or empty if there's no user cache directory.
+func ociCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "wazero", "oci")
+}
+
+// sourceMapLoader returns a loader of the source maps of the wasm binary at
+// the path, which reads them from files relative to it.
+func sourceMapLoader(path string) experimental.SourceMapLoader {
+	return func(_ context.Context, url string) ([]byte, error) {
+		if strings.Contains(url, "://") {
+			return nil, fmt.Errorf("unsupported source map URL: %s", url)
+		}
+		if !filepath.IsAbs(url) {
+			url = filepath.Join(filepath.Dir(path), filepath.FromSlash(url))
+		}
+		return os.ReadFile(url)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestRun_OCI(t *testing.T) {
+	sum := sha256.Sum256(wasmWasiArg)
+	layerDigest := "sha256:" + hex.EncodeToString(sum[:])
+	manifest := fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json",`+
+		`"layers":[{"mediaType":"application/wasm","digest":"%s","size":%d}]}`, layerDigest, len(wasmWasiArg))
+
+	// Serve the module as the tag "v1" of the repository "org/test".
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/org/test/manifests/v1":
+			_, _ = w.Write([]byte(manifest))
+		case "/v2/org/test/blobs/" + layerDigest:
+			_, _ = w.Write(wasmWasiArg)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer registry.Close()
+
+	// Cache modules in a temporary directory instead of the user's.
+	cacheDir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cacheDir)
+	t.Setenv("HOME", cacheDir)
+	t.Setenv("LocalAppData", cacheDir)
+
+	ref := "oci://" + strings.TrimPrefix(registry.URL, "http://") + "/org/test:v1"
+	exitCode, stdOut, stdErr := runMain(t, []string{"run", ref, "hello world"})
+	require.Equal(t, 0, exitCode, stdErr)
+	// The executable name is the last element of the repository.
+	require.Equal(t, "test\x00hello world\x00", stdOut)
+
+	// The module was cached by digest.
+	cached, err := os.ReadFile(filepath.Join(ociCacheDir(), "sha256", strings.TrimPrefix(layerDigest, "sha256:")))
+	require.NoError(t, err)
+	require.Equal(t, wasmWasiArg, cached)
+
+	exitCode, _, stdErr = runMain(t, []string{"run", strings.Replace(ref, ":v1", ":v2", 1)})
+	require.Equal(t, 1, exitCode)
+	require.Contains(t, stdErr, "error reading wasm binary: GET "+registry.URL+"/v2/org/test/manifests/v2: 404 Not Found")
+}
+
+func TestSourceMapLoader(t *testing.T) {
+	dir := t.TempDir()
+	mapPath := filepath.Join(dir, "maps", "calc.wasm.map")
+	require.NoError(t, os.MkdirAll(filepath.Dir(mapPath), 0o700))
+	require.NoError(t, os.WriteFile(mapPath, []byte("{}"), 0o600))
+	load := sourceMapLoader(filepath.Join(dir, "calc.wasm"))
+
+	// Relative URLs are relative to the wasm binary.
+	b, err := load(context.Background(), "maps/calc.wasm.map")
+	require.NoError(t, err)
+	require.Equal(t, "{}", string(b))
+
+	b, err = load(context.Background(), mapPath)
+	require.NoError(t, err)
+	require.Equal(t, "{}", string(b))
+
+	_, err = load(context.Background(), "http://localhost/calc.wasm.map")
+	require.EqualError(t, err, "unsupported source map URL: http://localhost/calc.wasm.map")
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/tetratelabs/wazero/experimental/wasmsign"
+)
+
+// verifyWasm returns an error unless the wasm binary is signed by one of the
+// keys in the files at keyPaths. If signaturePath isn't empty, it is the file
+// of a detached signature, otherwise the signature is embedded.
+func verifyWasm(wasm []byte, keyPaths []string, signaturePath string) error {
+	keys := make([]wasmsign.PublicKey, 0, len(keyPaths))
+	for _, path := range keyPaths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		key, err := wasmsign.ParsePublicKey(b)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		keys = append(keys, wasmsign.PublicKey{Key: key})
+	}
+
+	ctx := context.Background()
+	if signaturePath == "" {
+		return wasmsign.Verify(ctx, wasm, wasmsign.StaticKeys(keys...))
+	}
+	signature, err := os.ReadFile(signaturePath)
+	if err != nil {
+		return err
+	}
+	return wasmsign.VerifyDetached(ctx, wasm, signature, wasmsign.StaticKeys(keys...))
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tetratelabs/wazero/experimental/wasmsign"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestRun_Verify(t *testing.T) {
+	dir := t.TempDir()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	other, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	keyPath := filepath.Join(dir, "key.public")
+	require.NoError(t, os.WriteFile(keyPath, append([]byte{0x01}, pub...), 0o600))
+	otherKeyPath := filepath.Join(dir, "other.public")
+	require.NoError(t, os.WriteFile(otherKeyPath, append([]byte{0x01}, other...), 0o600))
+
+	wasmPath := filepath.Join(dir, "test.wasm")
+	require.NoError(t, os.WriteFile(wasmPath, wasmWasiArg, 0o600))
+
+	signed, err := wasmsign.Sign(wasmWasiArg, priv, nil)
+	require.NoError(t, err)
+	signedPath := filepath.Join(dir, "signed.wasm")
+	require.NoError(t, os.WriteFile(signedPath, signed, 0o600))
+
+	signature, err := wasmsign.SignDetached(wasmWasiArg, priv, nil)
+	require.NoError(t, err)
+	signaturePath := filepath.Join(dir, "test.wasm.sig")
+	require.NoError(t, os.WriteFile(signaturePath, signature, 0o600))
+
+	tests := []struct {
+		name            string
+		args            []string
+		expectedMessage string
+	}{
+		{
+			name: "embedded",
+			args: []string{"--verify-key", keyPath, signedPath},
+		},
+		{
+			name: "detached",
+			args: []string{"--verify-key", keyPath, "--signature", signaturePath, wasmPath},
+		},
+		{
+			name: "any key",
+			args: []string{"--verify-key", otherKeyPath, "--verify-key", keyPath, signedPath},
+		},
+		{
+			name:            "unsigned",
+			args:            []string{"--verify-key", keyPath, wasmPath},
+			expectedMessage: "error verifying wasm binary: module has no signature",
+		},
+		{
+			name:            "untrusted",
+			args:            []string{"--verify-key", otherKeyPath, signedPath},
+			expectedMessage: "error verifying wasm binary: module isn't signed by a trusted key",
+		},
+		{
+			name:            "invalid key",
+			args:            []string{"--verify-key", wasmPath, signedPath},
+			expectedMessage: "error verifying wasm binary: " + wasmPath + ": invalid public key",
+		},
+		{
+			name:            "signature without key",
+			args:            []string{"--signature", signaturePath, wasmPath},
+			expectedMessage: "invalid signature: requires verify-key",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			exitCode, stdOut, stdErr := runMain(t, append(append([]string{"run"}, tc.args...), "hello"))
+			if tc.expectedMessage == "" {
+				require.Equal(t, 0, exitCode, stdErr)
+				require.Contains(t, stdOut, "hello")
+			} else {
+				require.Equal(t, 1, exitCode)
+				require.Contains(t, stdErr, tc.expectedMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// serveWAGI serves HTTP on addr with the handler until the context is done.
+// Each request is cancelled after the timeout, unless zero.
+func serveWAGI(ctx context.Context, addr string, handler http.Handler, timeout time.Duration, stdErr io.Writer) error {
+	if timeout > 0 {
+		h := handler
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+	return listenAndServe(ctx, addr, handler, stdErr)
+}
+
+// listenAndServe serves HTTP on addr with the handler until the context is
+// done, printing the URL served to stdErr once listening.
+func listenAndServe(ctx context.Context, addr string, handler http.Handler, stdErr io.Writer) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{Handler: handler}
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(ln) }()
+	fmt.Fprintf(stdErr, "wazero: serving on http://%s\n", ln.Addr())
+
+	select {
+	case err = <-served:
+		return err
+	case <-ctx.Done():
+		_ = server.Close()
+		if err = <-served; errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestServeWAGI(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// The handler writes whether the request was cancelled by the timeout.
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			_, _ = w.Write([]byte("timeout"))
+		case <-time.After(10 * time.Second):
+			_, _ = w.Write([]byte("no timeout"))
+		}
+	})
+
+	stdErr := &syncBuffer{}
+	served := make(chan error, 1)
+	go func() { served <- serveWAGI(ctx, "127.0.0.1:0", handler, time.Millisecond, stdErr) }()
+
+	var url string
+	for deadline := time.Now().Add(30 * time.Second); url == ""; time.Sleep(10 * time.Millisecond) {
+		if out := stdErr.String(); strings.Contains(out, "\n") {
+			url = strings.TrimSpace(out[strings.Index(out, "http://"):])
+		} else if time.Now().After(deadline) {
+			t.Fatal("timed out waiting to serve")
+		}
+	}
+
+	res, err := http.Get(url)
+	require.NoError(t, err)
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, res.Body.Close())
+	require.NoError(t, err)
+	require.Equal(t, "timeout", string(body))
+
+	// Cancelling the context stops serving.
+	cancel()
+	require.NoError(t, <-served)
+	require.True(t, strings.HasPrefix(stdErr.String(), "wazero: serving on http://127.0.0.1:"))
+}
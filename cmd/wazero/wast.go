@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental/spectest"
+)
+
+func doWast(args []string, stdOut, stdErr io.Writer, exit func(code int)) {
+	flags := flag.NewFlagSet("wast", flag.ExitOnError)
+	flags.SetOutput(stdErr)
+
+	var help bool
+	flags.BoolVar(&help, "h", false, "print usage")
+
+	var useInterpreter bool
+	flags.BoolVar(&useInterpreter, "interpreter", false,
+		"run with the interpreter, even if the compiler is supported on this platform")
+
+	_ = flags.Parse(args)
+
+	if help {
+		printWastUsage(stdErr, flags)
+		exit(0)
+	}
+
+	if flags.NArg() < 1 {
+		fmt.Fprintln(stdErr, "missing path to wast file")
+		printWastUsage(stdErr, flags)
+		exit(1)
+	}
+
+	config := spectest.Config{CoreFeatures: api.CoreFeaturesV2, Interpreter: useInterpreter}
+
+	ctx := context.Background()
+	var failed bool
+	for _, path := range flags.Args() {
+		s, err := runScript(ctx, config, path, stdErr)
+		if err != nil {
+			fmt.Fprintf(stdErr, "error running wast script %s: %v\n", path, err)
+			exit(1)
+		}
+		fmt.Fprintf(stdOut, "%s: %d passed, %d failed, %d skipped\n", s.name, s.passed, s.failed, s.skipped)
+		failed = failed || s.failed > 0
+	}
+	if failed {
+		exit(1)
+	}
+	exit(0)
+}
+
+// scriptResult counts the results of the commands in a script.
+type scriptResult struct {
+	// name is the base name of the ".wast" file of the script.
+	name                    string
+	passed, failed, skipped int
+}
+
+// runScript runs the commands of the script, either in the text format or
+// converted to JSON by wast2json, writing each failure to stdErr with its
+// location in the ".wast" file.
+func runScript(ctx context.Context, config spectest.Config, path string, stdErr io.Writer) (*scriptResult, error) {
+	results, err := spectest.RunScript(ctx, os.DirFS(filepath.Dir(path)), filepath.Base(path), config)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &scriptResult{name: strings.TrimSuffix(filepath.Base(path), ".json") + ".wast"}
+	if len(results) > 0 {
+		ret.name = results[0].Script
+	}
+	for _, r := range results {
+		switch {
+		case r.Err == nil:
+			ret.passed++
+		case errors.Is(r.Err, spectest.ErrSkipped):
+			ret.skipped++
+		default:
+			ret.failed++
+			fmt.Fprintf(stdErr, "%s:%d: %s: %v\n", r.Script, r.Line, r.Command, r.Err)
+		}
+	}
+	return ret, nil
+}
+
+func printWastUsage(stdErr io.Writer, flags *flag.FlagSet) {
+	fmt.Fprintln(stdErr, "wazero CLI")
+	fmt.Fprintln(stdErr)
+	fmt.Fprintln(stdErr, "Usage:\n  wazero wast <options> <path to wast or json file>...")
+	fmt.Fprintln(stdErr)
+	fmt.Fprintln(stdErr, "Scripts are either in the text format, or converted to JSON by wast2json.")
+	fmt.Fprintln(stdErr)
+	fmt.Fprintln(stdErr, "Options:")
+	flags.PrintDefaults()
+}
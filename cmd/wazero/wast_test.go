@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+// addWasm exports a function "add" which adds two i32 params.
+var addWasm = binary.EncodeModule(&wasm.Module{
+	TypeSection: []*wasm.FunctionType{
+		{Params: []api.ValueType{api.ValueTypeI32, api.ValueTypeI32}, Results: []api.ValueType{api.ValueTypeI32}},
+	},
+	FunctionSection: []wasm.Index{0},
+	CodeSection: []*wasm.Code{
+		{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Add, wasm.OpcodeEnd}},
+	},
+	ExportSection: []*wasm.Export{{Name: "add", Type: wasm.ExternTypeFunc, Index: 0}},
+})
+
+// addScript is what wast2json would convert addWast to.
+const addScript = `{"source_filename": "add.wast", "commands": [
+ {"type": "module", "line": 1, "filename": "add.0.wasm"},
+ {"type": "assert_return", "line": 4, "action": {"type": "invoke", "field": "add", "args": [{"type": "i32", "value": "1"}, {"type": "i32", "value": "2"}]}, "expected": [{"type": "i32", "value": "3"}]},
+ {"type": "assert_return", "line": 5, "action": {"type": "invoke", "field": "add", "args": [{"type": "i32", "value": "1"}, {"type": "i32", "value": "1"}]}, "expected": [{"type": "i32", "value": "3"}]},
+ {"type": "assert_malformed", "line": 6, "filename": "add.1.wat", "text": "unknown operator", "module_type": "text"}]}
+`
+
+// addWast is the source of "add.wast", where the assertion on line 5 fails,
+// and the one on line 6 is skipped.
+const addWast = `(module
+  (func (export "add") (param i32 i32) (result i32)
+    (i32.add (local.get 0) (local.get 1))))
+(assert_return (invoke "add" (i32.const 1) (i32.const 2)) (i32.const 3))
+(assert_return (invoke "add" (i32.const 1) (i32.const 1)) (i32.const 3))
+(assert_malformed (module quote "(func (i32.ad))") "unknown operator")
+`
+
+func TestWast(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "add.0.wasm"), addWasm, 0o600))
+	jsonPath := filepath.Join(dir, "add.json")
+	require.NoError(t, os.WriteFile(jsonPath, []byte(addScript), 0o600))
+	wastPath := filepath.Join(dir, "add.wast")
+	require.NoError(t, os.WriteFile(wastPath, []byte(addWast), 0o600))
+
+	for _, path := range []string{jsonPath, wastPath} {
+		for _, args := range [][]string{{"wast"}, {"wast", "-interpreter"}} {
+			exitCode, stdOut, stdErr := runMain(t, append(args, path))
+			require.Equal(t, 1, exitCode)
+			require.Equal(t, "add.wast: 2 passed, 1 failed, 1 skipped\n", stdOut)
+			require.Contains(t, stdErr, "add.wast:5: assert_return: invoke add")
+		}
+	}
+}
+
+func TestWast_Errors(t *testing.T) {
+	malformedPath := filepath.Join(t.TempDir(), "malformed.wast")
+	require.NoError(t, os.WriteFile(malformedPath, []byte("(assert_return (call \"add\"))"), 0o600))
+
+	tests := []struct {
+		message string
+		args    []string
+	}{
+		{
+			message: "missing path to wast file",
+			args:    []string{},
+		},
+		{
+			message: "invalid script malformed.wast: 1:16: expected (invoke ...) or (get ...), but was (call",
+			args:    []string{malformedPath},
+		},
+		{
+			message: "error running wast script",
+			args:    []string{"testdata/wasi_arg.wasm"},
+		},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.message, func(t *testing.T) {
+			exitCode, _, stdErr := runMain(t, append([]string{"wast"}, tt.args...))
+			require.Equal(t, 1, exitCode)
+			require.Contains(t, stdErr, tt.message)
+		})
+	}
+}
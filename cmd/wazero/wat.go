@@ -0,0 +1,151 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+	"github.com/tetratelabs/wazero/internal/wasm/text"
+)
+
+func doWat2Wasm(args []string, stdOut, stdErr io.Writer, exit func(code int)) {
+	flags := flag.NewFlagSet("wat2wasm", flag.ExitOnError)
+	flags.SetOutput(stdErr)
+
+	var help bool
+	flags.BoolVar(&help, "h", false, "print usage")
+
+	var outPath string
+	flags.StringVar(&outPath, "o", "", "path of the wasm binary to write. Defaults to STDOUT.")
+
+	var debugNames bool
+	flags.BoolVar(&debugNames, "debug-names", false, "write the identifiers of the text format "+
+		"to the custom name section of the binary.")
+
+	_ = flags.Parse(args)
+
+	if help {
+		printWat2WasmUsage(stdErr, flags)
+		exit(0)
+	}
+
+	if flags.NArg() < 1 {
+		fmt.Fprintln(stdErr, "missing path to wat file")
+		printWat2WasmUsage(stdErr, flags)
+		exit(1)
+	}
+	watPath := flags.Arg(0)
+
+	source, err := os.ReadFile(watPath)
+	if err != nil {
+		fmt.Fprintf(stdErr, "error reading wat file: %v\n", err)
+		exit(1)
+	}
+
+	m, err := text.DecodeModule(source)
+	if err != nil {
+		fmt.Fprintf(stdErr, "error parsing wat file: %v\n", err)
+		exit(1)
+	}
+	if !debugNames {
+		m.NameSection = nil
+	}
+
+	// The text decoder only checks the syntax, so decode the binary to
+	// validate it, e.g. that function bodies are well-typed.
+	bin := binary.EncodeModule(m)
+	if _, err = decode(bin, api.CoreFeaturesV2|api.CoreFeatureCustomPageSizes); err != nil {
+		fmt.Fprintf(stdErr, "error validating wat file: %v\n", err)
+		exit(1)
+	}
+
+	if outPath == "" {
+		_, _ = stdOut.Write(bin)
+	} else if err = os.WriteFile(outPath, bin, 0o644); err != nil {
+		fmt.Fprintf(stdErr, "error writing wasm binary: %v\n", err)
+		exit(1)
+	}
+	exit(0)
+}
+
+// watToWasm converts a module in the text format to a binary, which is
+// validated when compiled.
+func watToWasm(source []byte) ([]byte, error) {
+	m, err := text.DecodeModule(source)
+	if err != nil {
+		return nil, err
+	}
+	return binary.EncodeModule(m), nil
+}
+
+func printWat2WasmUsage(stdErr io.Writer, flags *flag.FlagSet) {
+	fmt.Fprintln(stdErr, "wazero CLI")
+	fmt.Fprintln(stdErr)
+	fmt.Fprintln(stdErr, "Usage:\n  wazero wat2wasm <options> <path to wat file>")
+	fmt.Fprintln(stdErr)
+	fmt.Fprintln(stdErr, "Options:")
+	flags.PrintDefaults()
+}
+
+func doWasm2Wat(args []string, stdOut, stdErr io.Writer, exit func(code int)) {
+	flags := flag.NewFlagSet("wasm2wat", flag.ExitOnError)
+	flags.SetOutput(stdErr)
+
+	var help bool
+	flags.BoolVar(&help, "h", false, "print usage")
+
+	var outPath string
+	flags.StringVar(&outPath, "o", "", "path of the wat file to write. Defaults to STDOUT.")
+
+	_ = flags.Parse(args)
+
+	if help {
+		printWasm2WatUsage(stdErr, flags)
+		exit(0)
+	}
+
+	if flags.NArg() < 1 {
+		fmt.Fprintln(stdErr, "missing path to wasm file")
+		printWasm2WatUsage(stdErr, flags)
+		exit(1)
+	}
+	wasmPath := flags.Arg(0)
+
+	bin, err := readWasm(wasmPath)
+	if err != nil {
+		fmt.Fprintf(stdErr, "error reading wasm binary: %v\n", err)
+		exit(1)
+	}
+
+	m, err := decode(bin, api.CoreFeaturesV2|api.CoreFeatureCustomPageSizes)
+	if err != nil {
+		fmt.Fprintf(stdErr, "error decoding wasm binary: %v\n", err)
+		exit(1)
+	}
+
+	wat, err := text.EncodeModule(m)
+	if err != nil {
+		fmt.Fprintf(stdErr, "error encoding wat file: %v\n", err)
+		exit(1)
+	}
+
+	if outPath == "" {
+		_, _ = stdOut.Write(wat)
+	} else if err = os.WriteFile(outPath, wat, 0o644); err != nil {
+		fmt.Fprintf(stdErr, "error writing wat file: %v\n", err)
+		exit(1)
+	}
+	exit(0)
+}
+
+func printWasm2WatUsage(stdErr io.Writer, flags *flag.FlagSet) {
+	fmt.Fprintln(stdErr, "wazero CLI")
+	fmt.Fprintln(stdErr)
+	fmt.Fprintln(stdErr, "Usage:\n  wazero wasm2wat <options> <path to wasm file>")
+	fmt.Fprintln(stdErr)
+	fmt.Fprintln(stdErr, "Options:")
+	flags.PrintDefaults()
+}
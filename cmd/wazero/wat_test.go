@@ -0,0 +1,112 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestWat2Wasm(t *testing.T) {
+	dir := t.TempDir()
+	invalidPath := filepath.Join(dir, "invalid.wat")
+	require.NoError(t, os.WriteFile(invalidPath, []byte("(module (func (result i32)))"), 0o600))
+	malformedPath := filepath.Join(dir, "malformed.wat")
+	require.NoError(t, os.WriteFile(malformedPath, []byte("(module (func $f) (func $f))"), 0o600))
+
+	t.Run("stdout", func(t *testing.T) {
+		exitCode, stdOut, stdErr := runMain(t, []string{"wat2wasm", "testdata/wasi_arg.wat"})
+		require.Equal(t, 0, exitCode, stdErr)
+		require.Equal(t, string(wasmWasiArg), stdOut)
+	})
+
+	t.Run("output", func(t *testing.T) {
+		outPath := filepath.Join(dir, "wasi_arg.wasm")
+		exitCode, stdOut, stdErr := runMain(t, []string{"wat2wasm", "-debug-names", "-o", outPath, "testdata/wasi_arg.wat"})
+		require.Equal(t, 0, exitCode, stdErr)
+		require.Equal(t, "", stdOut)
+
+		bin, err := os.ReadFile(outPath)
+		require.NoError(t, err)
+		m, err := decode(bin, 0)
+		require.NoError(t, err)
+		require.Equal(t, "wasi_arg", m.NameSection.ModuleName)
+	})
+
+	tests := []struct {
+		message string
+		args    []string
+	}{
+		{
+			message: "missing path to wat file",
+		},
+		{
+			message: "error reading wat file",
+			args:    []string{"non-existent.wat"},
+		},
+		{
+			message: "error parsing wat file: 1:19: duplicate func $f",
+			args:    []string{malformedPath},
+		},
+		{
+			message: "error validating wat file",
+			args:    []string{invalidPath},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.message, func(t *testing.T) {
+			exitCode, _, stdErr := runMain(t, append([]string{"wat2wasm"}, tc.args...))
+			require.Equal(t, 1, exitCode)
+			require.Contains(t, stdErr, tc.message)
+		})
+	}
+}
+
+func TestWasm2Wat(t *testing.T) {
+	dir := t.TempDir()
+	wasmPath := filepath.Join(dir, "wasi_arg.wasm")
+	require.NoError(t, os.WriteFile(wasmPath, wasmWasiArg, 0o600))
+
+	exitCode, wat, stdErr := runMain(t, []string{"wasm2wat", wasmPath})
+	require.Equal(t, 0, exitCode, stdErr)
+	require.Contains(t, wat, `(export "_start" (func 3))`)
+
+	// The text format converts back to the same binary.
+	watPath := filepath.Join(dir, "wasi_arg.wat")
+	exitCode, stdOut, stdErr := runMain(t, []string{"wasm2wat", "-o", watPath, wasmPath})
+	require.Equal(t, 0, exitCode, stdErr)
+	require.Equal(t, "", stdOut)
+
+	exitCode, stdOut, stdErr = runMain(t, []string{"wat2wasm", watPath})
+	require.Equal(t, 0, exitCode, stdErr)
+	require.Equal(t, string(wasmWasiArg), stdOut)
+
+	tests := []struct {
+		message string
+		args    []string
+	}{
+		{
+			message: "missing path to wasm file",
+		},
+		{
+			message: "error reading wasm binary",
+			args:    []string{"non-existent.wasm"},
+		},
+		{
+			message: "error decoding wasm binary",
+			args:    []string{"testdata/wasi_arg.wat"},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.message, func(t *testing.T) {
+			exitCode, _, stdErr := runMain(t, append([]string{"wasm2wat"}, tc.args...))
+			require.Equal(t, 1, exitCode)
+			require.Contains(t, stdErr, tc.message)
+		})
+	}
+}
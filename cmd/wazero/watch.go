@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"time"
+)
+
+// watchInterval is how often the wasm binary is checked for changes.
+var watchInterval = 250 * time.Millisecond
+
+// watchFlag matches the watch flag, so that it can be removed from the
+// arguments of the child process.
+var watchFlag = regexp.MustCompile(`^--?watch(=.*)?$`)
+
+// newWatchCmd returns the command to run the wasm binary with the given
+// arguments to "wazero run": this executable.
+var newWatchCmd = func(args []string) (*exec.Cmd, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	return exec.Command(exe, append([]string{"run"}, args...)...), nil
+}
+
+// watch runs the wasm binary in a child process, with the given arguments to
+// "wazero run", and restarts it whenever the binary changes, until the
+// context is done. Changes are detected by polling the binary's modification
+// time and size.
+func watch(ctx context.Context, args []string, wasmPath string, stdIn io.Reader, stdOut, stdErr io.Writer) error {
+	last, err := os.Stat(wasmPath)
+	if err != nil {
+		return err
+	}
+	for {
+		cmd, err := newWatchCmd(args)
+		if err != nil {
+			return err
+		}
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = stdIn, stdOut, stdErr
+		if err = cmd.Start(); err != nil {
+			return err
+		}
+		exited := make(chan error, 1)
+		go func() { exited <- cmd.Wait() }()
+
+		running := true
+		for changed := false; !changed; {
+			select {
+			case <-ctx.Done():
+				if running {
+					_ = cmd.Process.Kill()
+					<-exited
+				}
+				return nil
+			case err = <-exited:
+				running = false
+				var exitErr *exec.ExitError
+				if err != nil && !errors.As(err, &exitErr) {
+					return err
+				}
+				fmt.Fprintf(stdErr, "wazero: exited with code %d, waiting for changes to %s\n", cmd.ProcessState.ExitCode(), wasmPath)
+			case <-time.After(watchInterval):
+				info, err := os.Stat(wasmPath)
+				if err != nil { // e.g. while the binary is rebuilt
+					continue
+				}
+				if changed = !info.ModTime().Equal(last.ModTime()) || info.Size() != last.Size(); changed {
+					last = info
+				}
+			}
+		}
+
+		if running {
+			_ = cmd.Process.Kill()
+			<-exited
+		}
+		fmt.Fprintf(stdErr, "wazero: %s changed, restarting\n", wasmPath)
+	}
+}
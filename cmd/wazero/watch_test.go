@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+// watchTestEnv makes the test binary run as the CLI, so that it can be the
+// child process of watch.
+const watchTestEnv = "WAZERO_WATCH_TEST"
+
+// syncBuffer is a bytes.Buffer safe to read while a child process writes it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestWatch(t *testing.T) {
+	oldNewWatchCmd, oldWatchInterval := newWatchCmd, watchInterval
+	t.Cleanup(func() { newWatchCmd, watchInterval = oldNewWatchCmd, oldWatchInterval })
+	newWatchCmd = func(args []string) (*exec.Cmd, error) {
+		cmd := exec.Command(os.Args[0], append([]string{"run"}, args...)...)
+		cmd.Env = append(os.Environ(), watchTestEnv+"=1")
+		return cmd, nil
+	}
+	watchInterval = 10 * time.Millisecond
+
+	wasmPath := filepath.Join(t.TempDir(), "test.wasm")
+	require.NoError(t, os.WriteFile(wasmPath, wasmWasiArg, 0o600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stdOut, stdErr := &syncBuffer{}, &syncBuffer{}
+	done := make(chan error, 1)
+	go func() {
+		done <- watch(ctx, []string{wasmPath, "hello"}, wasmPath, nil, stdOut, stdErr)
+	}()
+
+	waitFor := func(count int) {
+		for deadline := time.Now().Add(30 * time.Second); time.Now().Before(deadline); {
+			if strings.Count(stdErr.String(), "exited with code 0") >= count {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("timed out waiting for %d runs, stderr: %s", count, stdErr.String())
+	}
+	waitFor(1)
+	require.Equal(t, "test.wasm\x00hello\x00", stdOut.String())
+
+	// Add a custom section, so the binary changes but its output doesn't.
+	require.NoError(t, os.WriteFile(wasmPath, append(wasmWasiArg, 0, 3, 1, 'x', 0), 0o600))
+	waitFor(2)
+	require.Equal(t, "test.wasm\x00hello\x00test.wasm\x00hello\x00", stdOut.String())
+	require.Contains(t, stdErr.String(), "test.wasm changed, restarting\n")
+
+	cancel()
+	require.NoError(t, <-done)
+}
@@ -1,22 +1,33 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
-	"io/fs"
+	"net"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"runtime"
+	"runtime/pprof"
 	"strings"
+	"time"
 
 	"github.com/tetratelabs/wazero"
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/experimental/logging"
+	"github.com/tetratelabs/wazero/experimental/profiling"
+	"github.com/tetratelabs/wazero/experimental/wagi"
 	gojs "github.com/tetratelabs/wazero/imports/go"
 	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/imports/wasi_sockets"
 	"github.com/tetratelabs/wazero/internal/version"
+	"github.com/tetratelabs/wazero/internal/wasmruntime"
 	"github.com/tetratelabs/wazero/sys"
 )
 
@@ -46,10 +57,28 @@ func doMain(stdOut io.Writer, stdErr io.Writer, exit func(code int)) {
 
 	subCmd := flag.Arg(0)
 	switch subCmd {
+	case "cache":
+		doCache(flag.Args()[1:], stdOut, stdErr, exit)
 	case "compile":
 		doCompile(flag.Args()[1:], stdErr, exit)
+	case "inspect":
+		doInspect(flag.Args()[1:], stdOut, stdErr, exit)
+	case "objdump":
+		doObjdump(flag.Args()[1:], stdOut, stdErr, exit)
+	case "repl":
+		doRepl(flag.Args()[1:], os.Stdin, stdOut, stdErr, exit)
 	case "run":
 		doRun(flag.Args()[1:], stdOut, stdErr, exit)
+	case "serve":
+		doServe(flag.Args()[1:], stdErr, exit)
+	case "wasm2wat":
+		doWasm2Wat(flag.Args()[1:], stdOut, stdErr, exit)
+	case "wast":
+		doWast(flag.Args()[1:], stdOut, stdErr, exit)
+	case "wat2wasm":
+		doWat2Wasm(flag.Args()[1:], stdOut, stdErr, exit)
+	case "wit-bindgen":
+		doWitBindgen(flag.Args()[1:], stdOut, stdErr, exit)
 	case "version":
 		fmt.Fprintln(stdOut, version.GetWazeroVersion())
 		exit(0)
@@ -69,6 +98,10 @@ func doCompile(args []string, stdErr io.Writer, exit func(code int)) {
 
 	cacheDir := cacheDirFlag(flags)
 
+	var output string
+	flags.StringVar(&output, "o", "", "file to write the native code compiled from wasm to, "+
+		"which \"wazero run\" reuses when passed as its cachefile. Can't be combined with cachedir.")
+
 	_ = flags.Parse(args)
 
 	if help {
@@ -83,10 +116,33 @@ func doCompile(args []string, stdErr io.Writer, exit func(code int)) {
 	}
 	wasmPath := flags.Arg(0)
 
-	wasm, err := os.ReadFile(wasmPath)
+	wasm, err := readWasm(wasmPath)
 	if err != nil {
 		fmt.Fprintf(stdErr, "error reading wasm binary: %v\n", err)
 		exit(1)
+	} else if isWat(wasmPath, wasm) {
+		if wasm, err = watToWasm(wasm); err != nil {
+			fmt.Fprintf(stdErr, "error parsing wat file: %v\n", err)
+			exit(1)
+		}
+	}
+
+	// Note: exit doesn't run deferred functions, so the temporary cache
+	// directory is removed explicitly.
+	removeTmpDir := func() {}
+	if output != "" {
+		if *cacheDir != "" {
+			fmt.Fprintln(stdErr, "invalid o: can't be combined with cachedir")
+			exit(1)
+		}
+		// Compile into an empty cache, so that its only entry is the module.
+		tmpDir, err := os.MkdirTemp("", "wazero")
+		if err != nil {
+			fmt.Fprintf(stdErr, "error creating cache: %v\n", err)
+			exit(1)
+		}
+		removeTmpDir = func() { _ = os.RemoveAll(tmpDir) }
+		*cacheDir = tmpDir
 	}
 
 	ctx := maybeUseCacheDir(context.Background(), cacheDir, stdErr, exit)
@@ -94,12 +150,21 @@ func doCompile(args []string, stdErr io.Writer, exit func(code int)) {
 	rt := wazero.NewRuntime(ctx)
 	defer rt.Close(ctx)
 
-	if _, err = rt.CompileModule(ctx, wasm); err != nil {
+	_, err = rt.CompileModule(ctx, wasm)
+	if err == nil && output != "" {
+		if err = writeCacheFile(*cacheDir, output); err != nil {
+			removeTmpDir()
+			fmt.Fprintf(stdErr, "error writing %s: %v\n", output, err)
+			exit(1)
+		}
+	}
+	removeTmpDir()
+
+	if err != nil {
 		fmt.Fprintf(stdErr, "error compiling wasm binary: %v\n", err)
 		exit(1)
-	} else {
-		exit(0)
 	}
+	exit(0)
 }
 
 func doRun(args []string, stdOut io.Writer, stdErr io.Writer, exit func(code int)) {
@@ -115,11 +180,96 @@ func doRun(args []string, stdOut io.Writer, stdErr io.Writer, exit func(code int
 
 	var mounts sliceFlag
 	flags.Var(&mounts, "mount",
-		"filesystem path to expose to the binary in the form of <host path>[:<wasm path>]. If wasm path is not "+
-			"provided, the host path will be used. Can be specified multiple times.")
+		"filesystem path to expose to the binary in the form of <host path>[:<wasm path>][:ro]. If wasm path is not "+
+			"provided, the host path will be used. If :ro is specified, the path is read-only. Mounts can be nested. "+
+			"Can be specified multiple times.")
+
+	var tcpListeners sliceFlag
+	flags.Var(&tcpListeners, "tcplisten",
+		"host:port of a TCP socket to listen on and expose to the binary as an inherited file descriptor, "+
+			"starting at 3 unless mount is also specified. Can be specified multiple times.")
+
+	var udpListeners sliceFlag
+	flags.Var(&udpListeners, "udplisten",
+		"host:port of a UDP socket to bind and expose to the binary as an inherited file descriptor, "+
+			"after any tcplisten sockets. Can be specified multiple times.")
+
+	var listenAddresses sliceFlag
+	flags.Var(&listenAddresses, "listen",
+		"[tcp://|udp://]host:port of a socket to listen on and expose to the binary as an inherited file descriptor. "+
+			"Like tcplisten, or udplisten with udp://. Can be specified multiple times.")
+
+	var allowNet sliceFlag
+	flags.Var(&allowNet, "allow-net",
+		"host:port of a server the binary is allowed to connect to with wasi_sockets, which is only available when "+
+			"this is set. A host name is resolved each time the binary connects. Can be specified multiple times.")
+
+	var timeout time.Duration
+	flags.DurationVar(&timeout, "timeout", 0, "if a wasm binary runs longer than the given duration string, then exit abruptly. "+
+		"The duration string is an unsigned sequence of decimal numbers, each with optional fraction and a unit suffix, "+
+		"such as \"300ms\", \"1.5h\" or \"2h45m\". Valid time units are \"ns\", \"us\" (or \"µs\"), \"ms\", \"s\", \"m\", \"h\". "+
+		"If the duration is 0, the timeout is disabled. The default is disabled.")
+
+	var maxMemoryPages uint
+	flags.UintVar(&maxMemoryPages, "max-memory-pages", 0, "maximum memory of the wasm binary in 64KiB pages, "+
+		"beyond which growing memory fails. If 0, the limit is 65536 pages (4GiB).")
+
+	var maxStackDepth int
+	flags.IntVar(&maxStackDepth, "max-stack-depth", 0, "maximum depth of nested function calls, "+
+		"beyond which the wasm binary traps with a stack overflow. If 0, the limit is the size of the engine's stack.")
+
+	var fuel uint64
+	flags.Uint64Var(&fuel, "fuel", 0, "units of fuel the wasm binary can consume, beyond which it traps. "+
+		"Each call to a function of the wasm binary, and each iteration of its loops, consumes one unit. "+
+		"The consumed fuel is printed to stderr on exit. If 0, fuel is unlimited.")
+
+	var cpuProfile string
+	flags.StringVar(&cpuProfile, "cpuprofile", "", "file to write a pprof profile of the CPU time of wasm functions to on exit, "+
+		"sampled from their call stacks. Inspect it with \"go tool pprof\".")
+
+	var memProfile string
+	flags.StringVar(&memProfile, "memprofile", "", "file to write a pprof heap profile of the host to on exit, "+
+		"which includes the memory of the wasm binary. Inspect it with \"go tool pprof\".")
+
+	var coredump string
+	flags.StringVar(&coredump, "coredump", "", "file to write a wasm coredump to if the binary traps, with its call "+
+		"stack, locals and memory. Inspect it with a coredump debugger such as wasmgdb.")
+
+	var trace bool
+	flags.BoolVar(&trace, "trace", false, "log each call to and return from a function to stderr.")
+
+	var traceFilters sliceFlag
+	flags.Var(&traceFilters, "trace-filter", "only trace functions matching module:function-glob, where the "+
+		"function glob can use '*' to match any characters and '?' to match one, e.g. wasi_snapshot_preview1:fd_*. "+
+		"Implies trace. Can be specified multiple times to trace functions matching any of them.")
+
+	var traceJSON bool
+	flags.BoolVar(&traceJSON, "trace-json", false, "log traces as one JSON object per line. Implies trace.")
 
 	cacheDir := cacheDirFlag(flags)
 
+	var cacheFile string
+	flags.StringVar(&cacheFile, "cachefile", "", "file written by \"wazero compile -o\" to reuse the native code "+
+		"compiled from the same wasm binary and version of wazero. Can't be combined with cachedir.")
+
+	var watchBinary bool
+	flags.BoolVar(&watchBinary, "watch", false, "rerun the wasm binary with the same options each time it changes, "+
+		"until interrupted. A run in progress is stopped first.")
+
+	var wagiAddr string
+	flags.StringVar(&wagiAddr, "wagi", "", "host:port to serve HTTP on, running the wasm binary once per request "+
+		"until interrupted. Following WAGI, the request is exposed as environment variables, arguments and stdin, "+
+		"and the binary prints the response headers, a blank line and the body to stdout. "+
+		"The timeout applies to each request. Can't be combined with sockets.")
+
+	var verifyKeys sliceFlag
+	flags.Var(&verifyKeys, "verify-key", "file of an Ed25519 public key, in the format of wasmsign2 or PEM, "+
+		"one of which must have signed the wasm binary, or it isn't run. Can be specified multiple times.")
+
+	var signature string
+	flags.StringVar(&signature, "signature", "", "file of a detached signature of the wasm binary, e.g. written by "+
+		"\"wasmsign2 sign -S\", to verify instead of one embedded in it. Requires verify-key.")
+
 	_ = flags.Parse(args)
 
 	if help {
@@ -134,6 +284,36 @@ func doRun(args []string, stdOut io.Writer, stdErr io.Writer, exit func(code int
 	}
 	wasmPath := flags.Arg(0)
 
+	if watchBinary {
+		if isRemote(wasmPath) {
+			fmt.Fprintln(stdErr, "invalid watch: can only watch a file")
+			exit(1)
+		}
+
+		// Rerun with the same arguments, except any before the wasm binary
+		// which enable watching.
+		var runArgs []string
+		for _, arg := range args[:len(args)-flags.NArg()] {
+			if !watchFlag.MatchString(arg) {
+				runArgs = append(runArgs, arg)
+			}
+		}
+		runArgs = append(runArgs, flags.Args()...)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		if err := watch(ctx, runArgs, wasmPath, os.Stdin, stdOut, stdErr); err != nil {
+			fmt.Fprintf(stdErr, "error watching wasm binary: %v\n", err)
+			exit(1)
+		}
+		exit(0)
+	}
+
+	if wagiAddr != "" && len(tcpListeners)+len(udpListeners)+len(listenAddresses) > 0 {
+		fmt.Fprintln(stdErr, "invalid wagi: can't be combined with sockets, as each request would need its own")
+		exit(1)
+	}
+
 	wasmArgs := flags.Args()[1:]
 	if len(wasmArgs) > 1 {
 		// Skip "--" if provided
@@ -153,17 +333,21 @@ func doRun(args []string, stdOut io.Writer, stdErr io.Writer, exit func(code int
 		env = append(env, fields[0], fields[1])
 	}
 
-	var mountFS fs.FS
+	var fsConfig wazero.FSConfig
 	if len(mounts) > 0 {
-		cfs := &compositeFS{
-			paths: map[string]fs.FS{},
-		}
+		fsConfig = wazero.NewFSConfig()
 		for _, mount := range mounts {
 			if len(mount) == 0 {
 				fmt.Fprintln(stdErr, "invalid mount: empty string")
 				exit(1)
 			}
 
+			readOnly := false
+			if trimmed := strings.TrimSuffix(mount, ":ro"); trimmed != mount {
+				mount = trimmed
+				readOnly = true
+			}
+
 			// TODO(anuraaga): Support wasm paths with colon in them.
 			var host, guest string
 			if clnIdx := strings.LastIndexByte(mount, ':'); clnIdx != -1 {
@@ -178,26 +362,116 @@ func doRun(args []string, stdOut io.Writer, stdErr io.Writer, exit func(code int
 				exit(1)
 			}
 
-			// wazero always calls fs.Open with a relative path.
-			if guest[0] == '/' {
-				guest = guest[1:]
+			if readOnly {
+				fsConfig = fsConfig.WithReadOnlyDirMount(host, guest)
+			} else {
+				fsConfig = fsConfig.WithDirMount(host, guest)
 			}
-			cfs.paths[guest] = os.DirFS(host)
 		}
-		mountFS = cfs
 	}
 
-	wasm, err := os.ReadFile(wasmPath)
+	var traceFilter logging.FunctionFilter
+	if len(traceFilters) > 0 {
+		var err error
+		if traceFilter, err = parseTraceFilters(traceFilters); err != nil {
+			fmt.Fprintf(stdErr, "invalid trace-filter: %v\n", err)
+			exit(1)
+		}
+	}
+
+	wasm, err := readWasm(wasmPath)
 	if err != nil {
 		fmt.Fprintf(stdErr, "error reading wasm binary: %v\n", err)
 		exit(1)
 	}
 
-	wasmExe := filepath.Base(wasmPath)
+	// Verify the file as signed, before converting any text format.
+	if len(verifyKeys) > 0 {
+		if err = verifyWasm(wasm, verifyKeys, signature); err != nil {
+			fmt.Fprintf(stdErr, "error verifying wasm binary: %v\n", err)
+			exit(1)
+		}
+	} else if signature != "" {
+		fmt.Fprintln(stdErr, "invalid signature: requires verify-key")
+		exit(1)
+	}
+
+	if isWat(wasmPath, wasm) {
+		if wasm, err = watToWasm(wasm); err != nil {
+			fmt.Fprintf(stdErr, "error parsing wat file: %v\n", err)
+			exit(1)
+		}
+	}
+
+	wasmExe := wasmName(wasmPath)
+
+	removeTmpDir := func() {}
+	if cacheFile != "" {
+		if *cacheDir != "" {
+			fmt.Fprintln(stdErr, "invalid cachefile: can't be combined with cachedir")
+			exit(1)
+		}
+		tmpDir, err := readCacheFile(cacheFile)
+		if err != nil {
+			fmt.Fprintf(stdErr, "invalid cachefile: %v\n", err)
+			exit(1)
+		}
+		// The cache is only read when compiling, so remove it after.
+		removeTmpDir = func() { _ = os.RemoveAll(tmpDir) }
+		*cacheDir = tmpDir
+	}
 
 	ctx := maybeUseCacheDir(context.Background(), cacheDir, stdErr, exit)
 
-	rt := wazero.NewRuntime(ctx)
+	rtc := wazero.NewRuntimeConfig()
+	if timeout > 0 {
+		// Close the module when the timeout elapses, even in an infinite loop.
+		if wagiAddr == "" { // otherwise, per request.
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		rtc = rtc.WithCloseOnContextDone(true)
+	}
+	if maxMemoryPages > 0 {
+		if maxMemoryPages > 65536 {
+			fmt.Fprintf(stdErr, "invalid max-memory-pages: %d > 65536\n", maxMemoryPages)
+			exit(1)
+		}
+		rtc = rtc.WithMemoryLimitPages(uint32(maxMemoryPages))
+	}
+	var listeners listenerFactories
+	if maxStackDepth > 0 {
+		// First, so that other listeners don't see calls which overflow.
+		listeners = append(listeners, stackDepthLimiter(maxStackDepth))
+	}
+	var meter *experimental.Fuel
+	if fuel > 0 {
+		// Also compiles the functions to consume it.
+		meter = &experimental.Fuel{Remaining: fuel}
+		ctx = context.WithValue(ctx, experimental.FuelKey{}, meter)
+	}
+	var profiler *profiling.Profiler
+	if cpuProfile != "" {
+		profiler = profiling.NewProfiler()
+		listeners = append(listeners, profiler)
+	}
+	if trace || traceFilter != nil || traceJSON {
+		var opts []logging.Option
+		if traceFilter != nil {
+			opts = append(opts, traceFilter)
+		}
+		if traceJSON {
+			listeners = append(listeners, logging.NewJSONLoggingListenerFactory(stdErr, opts...))
+		} else {
+			listeners = append(listeners, logging.NewLoggingListenerFactory(stdErr, opts...))
+		}
+	}
+	if len(listeners) > 0 {
+		rtc = rtc.WithFunctionListenerFactory(listeners)
+	}
+
+	rt := wazero.NewRuntimeWithConfig(ctx, rtc)
 	defer rt.Close(ctx)
 
 	// Because we are running a binary directly rather than embedding in an application,
@@ -214,28 +488,126 @@ func doRun(args []string, stdOut io.Writer, stdErr io.Writer, exit func(code int
 	for i := 0; i < len(env); i += 2 {
 		conf = conf.WithEnv(env[i], env[i+1])
 	}
-	if mountFS != nil {
-		conf = conf.WithFS(mountFS)
+	if fsConfig != nil {
+		conf = conf.WithFSConfig(fsConfig)
+	}
+	for _, address := range tcpListeners {
+		conf = conf.WithTCPListener(address)
+	}
+	for _, address := range udpListeners {
+		conf = conf.WithUDPListener(address)
+	}
+	for _, address := range listenAddresses {
+		if strings.HasPrefix(address, "udp://") {
+			conf = conf.WithUDPListener(address[len("udp://"):])
+		} else {
+			conf = conf.WithTCPListener(strings.TrimPrefix(address, "tcp://"))
+		}
+	}
+	if len(allowNet) > 0 {
+		// The guest connects lazily, so only the addresses are checked here.
+		var hosts []string
+		for _, address := range allowNet {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				fmt.Fprintf(stdErr, "invalid allow-net: %v\n", err)
+				exit(1)
+			}
+			if net.ParseIP(host) == nil {
+				hosts = append(hosts, host)
+			}
+		}
+		if _, err := wasi_sockets.NewBuilder(rt).
+			WithAllowedHosts(hosts...).
+			WithAllowedAddresses(allowNet...).
+			Instantiate(ctx, rt); err != nil {
+			fmt.Fprintf(stdErr, "error instantiating wasi_sockets: %v\n", err)
+			exit(1)
+		}
+	}
+	if !isRemote(wasmPath) {
+		ctx = context.WithValue(ctx, experimental.SourceMapLoaderKey{}, sourceMapLoader(wasmPath))
+	}
+	if coredump != "" {
+		ctx = context.WithValue(ctx, experimental.CoredumpHandlerKey{}, experimental.CoredumpHandler(
+			func(_ context.Context, dump []byte) {
+				if err := os.WriteFile(coredump, dump, 0o600); err != nil {
+					fmt.Fprintf(stdErr, "error writing coredump: %v\n", err)
+				}
+			}))
 	}
 
 	code, err := rt.CompileModule(ctx, wasm)
+	removeTmpDir()
 	if err != nil {
 		fmt.Fprintf(stdErr, "error compiling wasm binary: %v\n", err)
 		exit(1)
 	}
 
-	needsWASI, needsGo := detectImports(code.ImportedFunctions())
+	var profiles []func() error
+	if profiler != nil {
+		f, err := os.Create(cpuProfile)
+		if err != nil {
+			fmt.Fprintf(stdErr, "invalid cpuprofile: %v\n", err)
+			exit(1)
+		}
+		_ = profiler.Start(f)
+		profiles = append(profiles, func() error {
+			defer f.Close()
+			return profiler.Stop()
+		})
+	}
+	if memProfile != "" {
+		f, err := os.Create(memProfile)
+		if err != nil {
+			fmt.Fprintf(stdErr, "invalid memprofile: %v\n", err)
+			exit(1)
+		}
+		profiles = append(profiles, func() error {
+			defer f.Close()
+			runtime.GC() // get up-to-date statistics
+			return pprof.WriteHeapProfile(f)
+		})
+	}
+
+	needsWASI, goModuleName := detectImports(code.ImportedFunctions())
 
-	if needsWASI {
+	if wagiAddr != "" {
+		if !needsWASI {
+			fmt.Fprintf(stdErr, "invalid wagi: %s doesn't import WASI\n", wasmExe)
+			exit(1)
+		}
+		wasi_snapshot_preview1.MustInstantiate(ctx, rt)
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+		defer stop()
+		err = serveWAGI(ctx, wagiAddr, wagi.NewHandler(rt, code, conf), timeout, stdErr)
+	} else if needsWASI {
 		wasi_snapshot_preview1.MustInstantiate(ctx, rt)
 		_, err = rt.InstantiateModule(ctx, code, conf)
-	} else if needsGo {
-		gojs.MustInstantiate(ctx, rt)
-		err = gojs.Run(ctx, rt, code, conf)
+	} else if goModuleName != "" {
+		builder := rt.NewHostModuleBuilder(goModuleName)
+		gojs.NewFunctionExporter().ExportFunctions(builder)
+		if _, err = builder.Instantiate(ctx, rt); err == nil {
+			err = gojs.Run(ctx, rt, code, conf)
+		}
 	}
 
-	if err != nil {
-		if exitErr, ok := err.(*sys.ExitError); ok {
+	if meter != nil {
+		fmt.Fprintf(stdErr, "fuel consumed: %d\n", fuel-meter.Remaining)
+	}
+
+	for _, writeProfile := range profiles {
+		if err := writeProfile(); err != nil {
+			fmt.Fprintf(stdErr, "error writing profile: %v\n", err)
+		}
+	}
+
+	if err != nil && wagiAddr != "" {
+		fmt.Fprintf(stdErr, "error serving wagi: %v\n", err)
+		exit(1)
+	} else if err != nil {
+		// Exit with the code of the binary, unless the timeout closed it.
+		if exitErr, ok := err.(*sys.ExitError); ok && exitErr.ExitCode() != sys.ExitCodeDeadlineExceeded {
 			exit(int(exitErr.ExitCode()))
 		}
 		fmt.Fprintf(stdErr, "error instantiating wasm binary: %v\n", err)
@@ -246,21 +618,146 @@ func doRun(args []string, stdOut io.Writer, stdErr io.Writer, exit func(code int
 	exit(0)
 }
 
-func detectImports(imports []api.FunctionDefinition) (needsWASI, needsGo bool) {
+// detectImports returns true if the imports need WASI, or the module name of
+// `GOARCH=wasm GOOS=js` imports, which depends on the version of Go.
+func detectImports(imports []api.FunctionDefinition) (needsWASI bool, goModuleName string) {
 	for _, f := range imports {
 		moduleName, _, _ := f.Import()
 		switch moduleName {
 		case wasi_snapshot_preview1.ModuleName:
 			needsWASI = true
 			return // can't be both WASI and go
-		case "go":
-			needsGo = true
+		case gojs.ModuleName, gojs.LegacyModuleName:
+			goModuleName = moduleName
 			return // can't be both WASI and go
 		}
 	}
 	return
 }
 
+// parseTraceFilters returns a logging.FunctionFilter matching any of the
+// filters in the form module:function-glob. The glob matches the name of the
+// function or any of its exports, e.g. "_start" when it has no name.
+func parseTraceFilters(filters []string) (logging.FunctionFilter, error) {
+	matchers := make([]logging.FunctionFilter, 0, len(filters))
+	for _, f := range filters {
+		i := strings.IndexByte(f, ':')
+		if i < 0 {
+			return nil, fmt.Errorf("%s is not in the form module:function-glob", f)
+		}
+		module, functions := logging.IncludeModules(f[:i]), logging.IncludeFunctions(f[i+1:])
+		matchers = append(matchers, func(def api.FunctionDefinition) bool {
+			if !module(def) {
+				return false
+			}
+			if functions(def) {
+				return true
+			}
+			for _, name := range def.ExportNames() {
+				if functions(exportedAs{def, name}) {
+					return true
+				}
+			}
+			return false
+		})
+	}
+	return func(def api.FunctionDefinition) bool {
+		for _, match := range matchers {
+			if match(def) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// exportedAs is a function definition named as one of its exports.
+type exportedAs struct {
+	api.FunctionDefinition
+	name string
+}
+
+// Name implements api.FunctionDefinition Name.
+func (d exportedAs) Name() string {
+	return d.name
+}
+
+// listenerFactories is an experimental.FunctionListenerFactory which
+// notifies the listeners of each factory in order.
+//
+// Note: Only the first listener can iterate the stack, as the iterator is
+// shared.
+type listenerFactories []experimental.FunctionListenerFactory
+
+// NewListener implements experimental.FunctionListenerFactory NewListener.
+func (f listenerFactories) NewListener(def api.FunctionDefinition) experimental.FunctionListener {
+	var ret multiListener
+	for _, factory := range f {
+		if l := factory.NewListener(def); l != nil {
+			ret = append(ret, l)
+		}
+	}
+	if len(ret) == 0 {
+		return nil
+	}
+	return ret
+}
+
+// multiListener is an experimental.FunctionListener which notifies each
+// listener in order before a call, and in reverse order after it, so that
+// they nest.
+type multiListener []experimental.FunctionListener
+
+// Before implements experimental.FunctionListener Before.
+func (l multiListener) Before(ctx context.Context, mod api.Module, def api.FunctionDefinition, params []uint64, si experimental.StackIterator) context.Context {
+	for _, lsn := range l {
+		ctx = lsn.Before(ctx, mod, def, params, si)
+	}
+	return ctx
+}
+
+// After implements experimental.FunctionListener After.
+func (l multiListener) After(ctx context.Context, mod api.Module, def api.FunctionDefinition, results []uint64) {
+	for i := len(l) - 1; i >= 0; i-- {
+		l[i].After(ctx, mod, def, results)
+	}
+}
+
+// Abort implements experimental.FunctionListener Abort.
+func (l multiListener) Abort(ctx context.Context, mod api.Module, def api.FunctionDefinition, err error) {
+	for i := len(l) - 1; i >= 0; i-- {
+		l[i].Abort(ctx, mod, def, err)
+	}
+}
+
+// stackDepthLimiter is an experimental.FunctionListenerFactory which traps
+// calls nested deeper than its value, as if the stack overflowed.
+type stackDepthLimiter int
+
+// stackDepthKey is a context.Context Value key. Its associated value is the
+// int depth of the current call.
+type stackDepthKey struct{}
+
+// NewListener implements experimental.FunctionListenerFactory NewListener.
+func (l stackDepthLimiter) NewListener(api.FunctionDefinition) experimental.FunctionListener {
+	return l
+}
+
+// Before implements experimental.FunctionListener Before.
+func (l stackDepthLimiter) Before(ctx context.Context, _ api.Module, _ api.FunctionDefinition, _ []uint64, _ experimental.StackIterator) context.Context {
+	depth, _ := ctx.Value(stackDepthKey{}).(int)
+	if depth++; depth > int(l) {
+		panic(wasmruntime.ErrRuntimeStackOverflow)
+	}
+	return context.WithValue(ctx, stackDepthKey{}, depth)
+}
+
+// After implements experimental.FunctionListener After.
+func (l stackDepthLimiter) After(context.Context, api.Module, api.FunctionDefinition, []uint64) {}
+
+// Abort implements experimental.FunctionListener Abort.
+func (l stackDepthLimiter) Abort(context.Context, api.Module, api.FunctionDefinition, error) {}
+
 func cacheDirFlag(flags *flag.FlagSet) *string {
 	return flags.String("cachedir", "", "Writeable directory for native code compiled from wasm. "+
 		"Contents are re-used for the same version of wazero.")
@@ -278,21 +775,89 @@ func maybeUseCacheDir(ctx context.Context, cacheDir *string, stdErr io.Writer, e
 	return ctx
 }
 
+// isWat returns true if the file at path is a module in the WebAssembly text
+// format, either by its extension or because it starts with a parenthesis.
+// Such a file is converted with watToWasm before it is compiled.
+func isWat(path string, wasm []byte) bool {
+	if strings.EqualFold(filepath.Ext(path), ".wat") {
+		return true
+	}
+	return bytes.HasPrefix(bytes.TrimLeft(wasm, " \t\r\n"), []byte{'('})
+}
+
+// cacheFileMagic starts a file written by writeCacheFile. It is followed by
+// the name of the cache entry, a newline and the entry's contents.
+const cacheFileMagic = "wazero-cache\n"
+
+// writeCacheFile writes the only entry of the compilation cache in cacheDir
+// to the file at path.
+func writeCacheFile(cacheDir, path string) error {
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		return err
+	}
+	if len(entries) != 1 {
+		return errors.New("compilation cache is not supported on this platform")
+	}
+	name := entries[0].Name()
+	content, err := os.ReadFile(filepath.Join(cacheDir, name))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append([]byte(cacheFileMagic+name+"\n"), content...), 0o600)
+}
+
+// readCacheFile restores the cache entry of the file at path, written by
+// writeCacheFile, into a new temporary directory, which the caller removes.
+func readCacheFile(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if !bytes.HasPrefix(b, []byte(cacheFileMagic)) {
+		return "", fmt.Errorf("%s was not written by wazero compile", path)
+	}
+	b = b[len(cacheFileMagic):]
+	i := bytes.IndexByte(b, '\n')
+	if i <= 0 || filepath.Base(string(b[:i])) != string(b[:i]) {
+		return "", fmt.Errorf("%s is corrupt", path)
+	}
+	name, content := string(b[:i]), b[i+1:]
+	tmpDir, err := os.MkdirTemp("", "wazero")
+	if err != nil {
+		return "", err
+	}
+	if err = os.WriteFile(filepath.Join(tmpDir, name), content, 0o600); err != nil {
+		os.RemoveAll(tmpDir)
+		return "", err
+	}
+	return tmpDir, nil
+}
+
 func printUsage(stdErr io.Writer) {
 	fmt.Fprintln(stdErr, "wazero CLI")
 	fmt.Fprintln(stdErr)
 	fmt.Fprintln(stdErr, "Usage:\n  wazero <command>")
 	fmt.Fprintln(stdErr)
 	fmt.Fprintln(stdErr, "Commands:")
+	fmt.Fprintln(stdErr, "  cache\t\tManages the compilation cache")
 	fmt.Fprintln(stdErr, "  compile\tPre-compiles a WebAssembly binary")
+	fmt.Fprintln(stdErr, "  inspect\tPrints the contents of a WebAssembly binary")
+	fmt.Fprintln(stdErr, "  objdump\tPrints the instructions of a WebAssembly binary")
+	fmt.Fprintln(stdErr, "  repl\t\tCalls functions of a WebAssembly binary interactively")
 	fmt.Fprintln(stdErr, "  run\t\tRuns a WebAssembly binary")
+	fmt.Fprintln(stdErr, "  serve\t\tServes an HTTP API to call WebAssembly binaries remotely")
 	fmt.Fprintln(stdErr, "  version\tDisplays the version of wazero CLI")
+	fmt.Fprintln(stdErr, "  wasm2wat\tConverts a WebAssembly binary to the text format")
+	fmt.Fprintln(stdErr, "  wast\t\tRuns WebAssembly spec test scripts")
+	fmt.Fprintln(stdErr, "  wat2wasm\tConverts the WebAssembly text format to a binary")
+	fmt.Fprintln(stdErr, "  wit-bindgen\tGenerates Go bindings for WIT interfaces")
 }
 
 func printCompileUsage(stdErr io.Writer, flags *flag.FlagSet) {
 	fmt.Fprintln(stdErr, "wazero CLI")
 	fmt.Fprintln(stdErr)
-	fmt.Fprintln(stdErr, "Usage:\n  wazero compile <options> <path to wasm file>")
+	fmt.Fprintln(stdErr, "Usage:\n  wazero compile <options> <path to wasm or wat file>")
 	fmt.Fprintln(stdErr)
 	fmt.Fprintln(stdErr, "Options:")
 	flags.PrintDefaults()
@@ -301,7 +866,7 @@ func printCompileUsage(stdErr io.Writer, flags *flag.FlagSet) {
 func printRunUsage(stdErr io.Writer, flags *flag.FlagSet) {
 	fmt.Fprintln(stdErr, "wazero CLI")
 	fmt.Fprintln(stdErr)
-	fmt.Fprintln(stdErr, "Usage:\n  wazero run <options> <path to wasm file> [--] <wasm args>")
+	fmt.Fprintln(stdErr, "Usage:\n  wazero run <options> <path to wasm or wat file> [--] <wasm args>")
 	fmt.Fprintln(stdErr)
 	fmt.Fprintln(stdErr, "Options:")
 	flags.PrintDefaults()
@@ -317,35 +882,3 @@ func (f *sliceFlag) Set(s string) error {
 	*f = append(*f, s)
 	return nil
 }
-
-// compositeFS is defined in wazero.go to allow debugging in GoLand.
-type compositeFS struct {
-	paths map[string]fs.FS
-}
-
-func (c *compositeFS) Open(name string) (fs.File, error) {
-	if !fs.ValidPath(name) {
-		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
-	}
-	for path, f := range c.paths {
-		if !strings.HasPrefix(name, path) {
-			continue
-		}
-		rest := name[len(path):]
-		if len(rest) == 0 {
-			// Special case reading directory
-			rest = "."
-		} else {
-			// fs.Open requires a relative path
-			if rest[0] == '/' {
-				rest = rest[1:]
-			}
-		}
-		file, err := f.Open(rest)
-		if err == nil {
-			return file, err
-		}
-	}
-
-	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
-}
@@ -2,15 +2,20 @@ package main
 
 import (
 	"bytes"
+	"compress/gzip"
 	_ "embed"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/tetratelabs/wazero/api"
@@ -18,6 +23,8 @@ import (
 	"github.com/tetratelabs/wazero/internal/platform"
 	"github.com/tetratelabs/wazero/internal/testing/require"
 	"github.com/tetratelabs/wazero/internal/version"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
 )
 
 //go:embed testdata/wasi_arg.wasm
@@ -36,6 +43,11 @@ var bearTxt []byte
 var wasmCat []byte
 
 func TestMain(m *testing.M) {
+	// Run as the CLI when started by "wazero run --watch" in TestWatch.
+	if os.Getenv(watchTestEnv) != "" {
+		main()
+	}
+
 	// For some reason, riscv64 fails to see directory listings.
 	if a := runtime.GOARCH; a == "riscv64" {
 		log.Println("main: skipping due to not yet supported GOARCH:", a)
@@ -132,6 +144,30 @@ func requireChdirToTemp(t *testing.T) (string, string) {
 	return tmpDir, oldwd
 }
 
+func TestCompile_Output(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip("compilation cache is not supported on this platform")
+	}
+
+	tmpDir := t.TempDir()
+	wasmPath := filepath.Join(tmpDir, "test.wasm")
+	require.NoError(t, os.WriteFile(wasmPath, wasmWasiArg, 0o600))
+	cachePath := filepath.Join(tmpDir, "test.cache")
+
+	exitCode, stdOut, stdErr := runMain(t, []string{"compile", "-o", cachePath, wasmPath})
+	require.Equal(t, 0, exitCode, stdErr)
+	require.Zero(t, stdOut)
+
+	cache, err := os.ReadFile(cachePath)
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(cache, []byte(cacheFileMagic)))
+
+	exitCode, stdOut, stdErr = runMain(t, []string{"run", "--cachefile", cachePath, wasmPath, "hello world"})
+	require.Equal(t, 0, exitCode, stdErr)
+	// Executable name is first arg so is printed.
+	require.Equal(t, "test.wasm\x00hello world\x00", stdOut)
+}
+
 func TestCompile_Errors(t *testing.T) {
 	tmpDir := t.TempDir()
 
@@ -141,6 +177,9 @@ func TestCompile_Errors(t *testing.T) {
 	notWasmPath := filepath.Join(tmpDir, "bears.wasm")
 	require.NoError(t, os.WriteFile(notWasmPath, []byte("pooh"), 0o600))
 
+	malformedWatPath := filepath.Join(tmpDir, "malformed.wat")
+	require.NoError(t, os.WriteFile(malformedWatPath, []byte("(module (func $f) (func $f))"), 0o600))
+
 	tests := []struct {
 		message string
 		args    []string
@@ -157,10 +196,18 @@ func TestCompile_Errors(t *testing.T) {
 			message: "error compiling wasm binary",
 			args:    []string{notWasmPath},
 		},
+		{
+			message: "error parsing wat file: 1:19: duplicate func $f",
+			args:    []string{malformedWatPath},
+		},
 		{
 			message: "invalid cachedir",
 			args:    []string{"--cachedir", notWasmPath, wasmPath},
 		},
+		{
+			message: "invalid o",
+			args:    []string{"--cachedir", tmpDir, "-o", filepath.Join(tmpDir, "test.cache"), wasmPath},
+		},
 	}
 
 	for _, tc := range tests {
@@ -221,6 +268,37 @@ func TestRun(t *testing.T) {
 			wazeroOpts: []string{fmt.Sprintf("--mount=%s:/", filepath.Dir(bearPath))},
 			stdOut:     "pooh\n",
 		},
+		{
+			name:       "fd read-only",
+			wasm:       wasmWasiFd,
+			wazeroOpts: []string{fmt.Sprintf("--mount=%s:/:ro", filepath.Dir(bearPath))},
+			stdOut:     "pooh\n",
+		},
+		{
+			name: "fd nested mounts",
+			wasm: wasmWasiFd,
+			wazeroOpts: []string{
+				fmt.Sprintf("--mount=%s:/", filepath.Dir(bearPath)),
+				fmt.Sprintf("--mount=%s:/tmp:ro", existingDir1),
+			},
+			stdOut: "pooh\n",
+		},
+		{
+			name:       "tcplisten",
+			wasm:       wasmWasiArg,
+			wazeroOpts: []string{"--tcplisten=127.0.0.1:0", "--udplisten=127.0.0.1:0"},
+			wasmArgs:   []string{"hello world"},
+			// Executable name is first arg so is printed.
+			stdOut: "test.wasm\x00hello world\x00",
+		},
+		{
+			name:       "listen",
+			wasm:       wasmWasiArg,
+			wazeroOpts: []string{"--listen=127.0.0.1:0", "--listen=udp://127.0.0.1:0"},
+			wasmArgs:   []string{"hello world"},
+			// Executable name is first arg so is printed.
+			stdOut: "test.wasm\x00hello world\x00",
+		},
 		{
 			name:       "GOARCH=wasm GOOS=js",
 			wasm:       wasmCat,
@@ -308,6 +386,15 @@ func TestRun(t *testing.T) {
 	}
 }
 
+func TestRun_Wat(t *testing.T) {
+	exitCode, stdOut, stdErr := runMain(t, []string{"run", "testdata/wasi_arg.wat", "hello world"})
+	require.Equal(t, 0, exitCode, stdErr)
+	require.Equal(t, "wasi_arg.wat\x00hello world\x00", stdOut)
+
+	exitCode, _, stdErr = runMain(t, []string{"compile", "testdata/wasi_arg.wat"})
+	require.Equal(t, 0, exitCode, stdErr)
+}
+
 func TestVersion(t *testing.T) {
 	exitCode, stdOut, stdErr := runMain(t, []string{"version"})
 	require.Equal(t, 0, exitCode)
@@ -322,6 +409,12 @@ func TestRun_Errors(t *testing.T) {
 	notWasmPath := filepath.Join(t.TempDir(), "bears.wasm")
 	require.NoError(t, os.WriteFile(notWasmPath, []byte("pooh"), 0o700))
 
+	malformedWatPath := filepath.Join(t.TempDir(), "malformed.wat")
+	require.NoError(t, os.WriteFile(malformedWatPath, []byte("(module (func $f) (func $f))"), 0o700))
+
+	notWASIPath := filepath.Join(t.TempDir(), "empty.wasm")
+	require.NoError(t, os.WriteFile(notWASIPath, binary.EncodeModule(&wasm.Module{}), 0o700))
+
 	tests := []struct {
 		message string
 		args    []string
@@ -338,6 +431,18 @@ func TestRun_Errors(t *testing.T) {
 			message: "error compiling wasm binary",
 			args:    []string{notWasmPath},
 		},
+		{
+			message: "error reading wasm binary: invalid reference oci://test: missing registry",
+			args:    []string{"oci://test"},
+		},
+		{
+			message: "invalid watch: can only watch a file",
+			args:    []string{"--watch", "oci://ghcr.io/org/test"},
+		},
+		{
+			message: "error parsing wat file: 1:19: duplicate func $f",
+			args:    []string{malformedWatPath},
+		},
 		{
 			message: "invalid environment variable",
 			args:    []string{"--env=ANIMAL", "testdata/wasi_env.wasm"},
@@ -346,10 +451,38 @@ func TestRun_Errors(t *testing.T) {
 			message: "invalid mount",
 			args:    []string{"--mount=.", "testdata/wasi_env.wasm"},
 		},
+		{
+			message: "invalid allow-net",
+			args:    []string{"--allow-net=127.0.0.1", wasmPath},
+		},
+		{
+			message: "tcp listener 127.0.0.1:-1",
+			args:    []string{"--tcplisten=127.0.0.1:-1", wasmPath},
+		},
 		{
 			message: "invalid cachedir",
 			args:    []string{"--cachedir", notWasmPath, wasmPath},
 		},
+		{
+			message: "invalid trace-filter",
+			args:    []string{"--trace-filter=fd_*", wasmPath},
+		},
+		{
+			message: "invalid cachefile",
+			args:    []string{"--cachefile", notWasmPath, wasmPath},
+		},
+		{
+			message: "invalid wagi: can't be combined with sockets",
+			args:    []string{"--wagi=127.0.0.1:0", "--tcplisten=127.0.0.1:0", wasmPath},
+		},
+		{
+			message: "invalid wagi: empty.wasm doesn't import WASI",
+			args:    []string{"--wagi=127.0.0.1:0", notWASIPath},
+		},
+		{
+			message: "error serving wagi",
+			args:    []string{"--wagi=127.0.0.1:-1", wasmPath},
+		},
 	}
 
 	for _, tc := range tests {
@@ -363,6 +496,253 @@ func TestRun_Errors(t *testing.T) {
 	}
 }
 
+// limitsModule returns a WASI command whose "_start" function is body. It
+// also defines a memory and a function $f(n) at index 2 which recurses n
+// times.
+func limitsModule(body ...byte) []byte {
+	return binary.EncodeModule(&wasm.Module{
+		TypeSection: []*wasm.FunctionType{
+			{Params: []api.ValueType{api.ValueTypeI32}},
+			{},
+		},
+		ImportSection: []*wasm.Import{
+			{Module: wasi_snapshot_preview1.ModuleName, Name: "proc_exit", Type: wasm.ExternTypeFunc, DescFunc: 0},
+		},
+		FunctionSection: []wasm.Index{1, 0},
+		MemorySection:   &wasm.Memory{Min: 1},
+		CodeSection: []*wasm.Code{
+			{Body: body},
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeIf, 0x40,
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Sub,
+				wasm.OpcodeCall, 2,
+				wasm.OpcodeEnd,
+				wasm.OpcodeEnd,
+			}},
+		},
+		ExportSection: []*wasm.Export{
+			{Name: "_start", Type: wasm.ExternTypeFunc, Index: 1},
+			{Name: "memory", Type: wasm.ExternTypeMemory, Index: 0},
+		},
+	})
+}
+
+func TestRun_Limits(t *testing.T) {
+	dir := t.TempDir()
+	writeWasm := func(name string, bin []byte) string {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(p, bin, 0o600))
+		return p
+	}
+
+	// loops forever
+	loopPath := writeWasm("loop.wasm", limitsModule(
+		wasm.OpcodeLoop, 0x40, wasm.OpcodeBr, 0, wasm.OpcodeEnd, wasm.OpcodeEnd))
+	// calls $f(50), which recurses to $f(0), so 52 calls deep including "_start"
+	recursePath := writeWasm("recurse.wasm", limitsModule(
+		wasm.OpcodeI32Const, 50, wasm.OpcodeCall, 2, wasm.OpcodeEnd))
+	// traps unless growing the memory by 10 pages succeeds
+	growPath := writeWasm("grow.wasm", limitsModule(
+		wasm.OpcodeI32Const, 10, wasm.OpcodeMemoryGrow, 0, wasm.OpcodeDrop, // result checked below
+		wasm.OpcodeMemorySize, 0, wasm.OpcodeI32Const, 11, wasm.OpcodeI32Ne,
+		wasm.OpcodeIf, 0x40, wasm.OpcodeUnreachable, wasm.OpcodeEnd,
+		wasm.OpcodeEnd))
+
+	tests := []struct {
+		name             string
+		args             []string
+		expectedExitCode int
+		expectedStdErr   string
+	}{
+		{
+			name:             "timeout",
+			args:             []string{"--timeout=10ms", loopPath},
+			expectedExitCode: 1,
+			expectedStdErr:   "context deadline exceeded",
+		},
+		{
+			name: "within max-stack-depth",
+			args: []string{"--max-stack-depth=52", recursePath},
+		},
+		{
+			name:             "exceeds max-stack-depth",
+			args:             []string{"--max-stack-depth=51", recursePath},
+			expectedExitCode: 1,
+			expectedStdErr:   "stack overflow",
+		},
+		{
+			name:           "within fuel",
+			args:           []string{"--fuel=52", recursePath},
+			expectedStdErr: "fuel consumed: 52\n",
+		},
+		{
+			name:             "exceeds fuel",
+			args:             []string{"--fuel=51", recursePath},
+			expectedExitCode: 1,
+			expectedStdErr:   "fuel exhausted",
+		},
+		{
+			name:             "loop exceeds fuel",
+			args:             []string{"--fuel=1000", loopPath},
+			expectedExitCode: 1,
+			expectedStdErr:   "fuel consumed: 1000\n",
+		},
+		{
+			name: "within max-memory-pages",
+			args: []string{"--max-memory-pages=11", growPath},
+		},
+		{
+			name:             "exceeds max-memory-pages",
+			args:             []string{"--max-memory-pages=10", growPath},
+			expectedExitCode: 1,
+			expectedStdErr:   "unreachable",
+		},
+		{
+			name:             "invalid max-memory-pages",
+			args:             []string{"--max-memory-pages=65537", growPath},
+			expectedExitCode: 1,
+			expectedStdErr:   "invalid max-memory-pages",
+		},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.name, func(t *testing.T) {
+			exitCode, _, stdErr := runMain(t, append([]string{"run"}, tt.args...))
+
+			require.Equal(t, tt.expectedExitCode, exitCode, stdErr)
+			require.Contains(t, stdErr, tt.expectedStdErr)
+		})
+	}
+}
+
+func TestRun_AllowNet(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	// connects to the listener with wasi_sockets and writes "hello", or exits
+	// with the errno.
+	watPath := filepath.Join(t.TempDir(), "hello.wat")
+	require.NoError(t, os.WriteFile(watPath, []byte(fmt.Sprintf(`(module
+  (import "wasi_sockets" "tcp_create_socket" (func $socket (param i32 i32) (result i32)))
+  (import "wasi_sockets" "connect" (func $connect (param i32 i32) (result i32)))
+  (import "wasi_snapshot_preview1" "fd_write" (func $fd_write (param i32 i32 i32 i32) (result i32)))
+  (import "wasi_snapshot_preview1" "proc_exit" (func $proc_exit (param i32)))
+  (memory (export "memory") 1)
+  (data (i32.const 0) "\10\00\00\00\05\00\00\00") ;; iovec of "hello"
+  (data (i32.const 16) "hello")
+  (data (i32.const 32) "\00\00\%02x\%02x\7f\00\00\01") ;; 127.0.0.1:port
+  (func (export "_start") (local $errno i32)
+    (local.set $errno (call $socket (i32.const 0) (i32.const 64)))
+    (if (local.get $errno) (then (call $proc_exit (local.get $errno))))
+    (local.set $errno (call $connect (i32.load (i32.const 64)) (i32.const 32)))
+    (if (local.get $errno) (then (call $proc_exit (local.get $errno))))
+    (drop (call $fd_write (i32.load (i32.const 64)) (i32.const 0) (i32.const 1) (i32.const 8)))))
+`, port&0xff, port>>8)), 0o600))
+
+	t.Run("allowed", func(t *testing.T) {
+		received := make(chan string, 1)
+		go func() {
+			conn, err := ln.Accept()
+			if err != nil {
+				received <- err.Error()
+				return
+			}
+			defer conn.Close()
+			b, _ := io.ReadAll(conn)
+			received <- string(b)
+		}()
+
+		exitCode, _, stdErr := runMain(t, []string{"run", "--allow-net=localhost:" + strconv.Itoa(port), watPath})
+		require.Equal(t, 0, exitCode, stdErr)
+		require.Equal(t, "hello", <-received)
+	})
+
+	t.Run("not allowed", func(t *testing.T) {
+		exitCode, _, stdErr := runMain(t, []string{"run", "--allow-net=127.0.0.1:1", watPath})
+		require.Equal(t, int(wasi_snapshot_preview1.ErrnoAcces), exitCode, stdErr)
+	})
+}
+
+func TestRun_Trace(t *testing.T) {
+	wasmPath := filepath.Join(t.TempDir(), "test.wasm")
+	require.NoError(t, os.WriteFile(wasmPath, wasmWasiArg, 0o600))
+
+	t.Run("filter", func(t *testing.T) {
+		exitCode, stdOut, stdErr := runMain(t, []string{"run",
+			"--trace-filter=wasi_snapshot_preview1:args_*", wasmPath, "hello"})
+		require.Equal(t, 0, exitCode, stdErr)
+		require.Equal(t, "test.wasm\x00hello\x00", stdOut)
+		require.Equal(t, `==> wasi_snapshot_preview1.args_get(argv=32768,argv_buf=0)
+<== ESUCCESS
+==> wasi_snapshot_preview1.args_sizes_get(result.argc=32768,result.argv_len=1028)
+<== ESUCCESS
+`, stdErr)
+	})
+
+	t.Run("json by export name", func(t *testing.T) {
+		exitCode, _, stdErr := runMain(t, []string{"run",
+			"--trace-json", "--trace-filter=wasi_snapshot_preview1:fd_write", "--trace-filter=:_start", wasmPath})
+		require.Equal(t, 0, exitCode, stdErr)
+		lines := strings.Split(strings.TrimSuffix(stdErr, "\n"), "\n")
+		require.Equal(t, 4, len(lines), stdErr)
+		require.Contains(t, lines[0], `"direction":"call","module":"","function":"","host":false,"nesting":0`)
+		require.Contains(t, lines[1], `"direction":"call","module":"wasi_snapshot_preview1","function":"fd_write"`)
+		require.Contains(t, lines[2], `"direction":"return","module":"wasi_snapshot_preview1","function":"fd_write"`)
+		require.Contains(t, lines[3], `"direction":"return","module":"","function":""`)
+	})
+}
+
+func TestRun_Profiles(t *testing.T) {
+	dir := t.TempDir()
+	wasmPath := filepath.Join(dir, "loop.wasm")
+	// loops until the timeout, so the profiler takes samples
+	require.NoError(t, os.WriteFile(wasmPath, limitsModule(
+		wasm.OpcodeLoop, 0x40, wasm.OpcodeBr, 0, wasm.OpcodeEnd, wasm.OpcodeEnd), 0o600))
+	cpuProfile := filepath.Join(dir, "cpu.pprof")
+	memProfile := filepath.Join(dir, "mem.pprof")
+
+	exitCode, _, stdErr := runMain(t, []string{"run", "--timeout=100ms", "--max-stack-depth=10",
+		"--cpuprofile", cpuProfile, "--memprofile", memProfile, wasmPath})
+	require.Equal(t, 1, exitCode)
+	require.False(t, strings.Contains(stdErr, "error writing profile"), stdErr)
+
+	for _, p := range []string{cpuProfile, memProfile} {
+		f, err := os.Open(p)
+		require.NoError(t, err)
+		defer f.Close()
+		zr, err := gzip.NewReader(f)
+		require.NoError(t, err)
+		b, err := io.ReadAll(zr)
+		require.NoError(t, err)
+		if p == cpuProfile {
+			// The function name of "_start" is in the string table.
+			require.Contains(t, string(b), ".$1")
+		} else {
+			require.Contains(t, string(b), "alloc_space")
+		}
+	}
+}
+
+func TestRun_Coredump(t *testing.T) {
+	dir := t.TempDir()
+	wasmPath := filepath.Join(dir, "trap.wasm")
+	require.NoError(t, os.WriteFile(wasmPath, limitsModule(wasm.OpcodeUnreachable, wasm.OpcodeEnd), 0o600))
+	coredump := filepath.Join(dir, "core.wasm")
+
+	exitCode, _, stdErr := runMain(t, []string{"run", "--coredump", coredump, wasmPath})
+	require.Equal(t, 1, exitCode)
+	require.Contains(t, stdErr, "unreachable")
+
+	b, err := os.ReadFile(coredump)
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(b, []byte("\x00asm")))
+	require.Contains(t, string(b), "corestack")
+}
+
 var _ api.FunctionDefinition = importer{}
 
 type importer struct {
@@ -385,9 +765,10 @@ func (i importer) ResultNames() []string        { return nil }
 
 func Test_detectImports(t *testing.T) {
 	tests := []struct {
-		message                        string
-		imports                        []api.FunctionDefinition
-		expectNeedsWASI, expectNeedsGo bool
+		message            string
+		imports            []api.FunctionDefinition
+		expectNeedsWASI    bool
+		expectGoModuleName string
 	}{
 		{
 			message: "no imports",
@@ -407,19 +788,26 @@ func Test_detectImports(t *testing.T) {
 		},
 		{
 			message: "GOARCH=wasm GOOS=js",
+			imports: []api.FunctionDefinition{
+				importer{"gojs", "syscall/js.valueCall"},
+			},
+			expectGoModuleName: "gojs",
+		},
+		{
+			message: "GOARCH=wasm GOOS=js before Go 1.21",
 			imports: []api.FunctionDefinition{
 				importer{"go", "syscall/js.valueCall"},
 			},
-			expectNeedsGo: true,
+			expectGoModuleName: "go",
 		},
 	}
 
 	for _, tc := range tests {
 		tt := tc
 		t.Run(tt.message, func(t *testing.T) {
-			needsWASI, needsGo := detectImports(tc.imports)
+			needsWASI, goModuleName := detectImports(tc.imports)
 			require.Equal(t, tc.expectNeedsWASI, needsWASI)
-			require.Equal(t, tc.expectNeedsGo, needsGo)
+			require.Equal(t, tc.expectGoModuleName, goModuleName)
 		})
 	}
 }
@@ -434,9 +822,18 @@ Usage:
   wazero <command>
 
 Commands:
+  cache		Manages the compilation cache
   compile	Pre-compiles a WebAssembly binary
+  inspect	Prints the contents of a WebAssembly binary
+  objdump	Prints the instructions of a WebAssembly binary
+  repl		Calls functions of a WebAssembly binary interactively
   run		Runs a WebAssembly binary
+  serve		Serves an HTTP API to call WebAssembly binaries remotely
   version	Displays the version of wazero CLI
+  wasm2wat	Converts a WebAssembly binary to the text format
+  wast		Runs WebAssembly spec test scripts
+  wat2wasm	Converts the WebAssembly text format to a binary
+  wit-bindgen	Generates Go bindings for WIT interfaces
 `, stdErr)
 }
 
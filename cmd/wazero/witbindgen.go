@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/tetratelabs/wazero/internal/wit"
+)
+
+func doWitBindgen(args []string, stdOut, stdErr io.Writer, exit func(code int)) {
+	flags := flag.NewFlagSet("wit-bindgen", flag.ExitOnError)
+	flags.SetOutput(stdErr)
+
+	var help bool
+	flags.BoolVar(&help, "h", false, "print usage")
+
+	var worldName string
+	flags.StringVar(&worldName, "world", "", "name of the world to generate bindings for. "+
+		"Defaults to the only world of the WIT file.")
+
+	var goPackage string
+	flags.StringVar(&goPackage, "package", "", "name of the generated Go package. "+
+		"Defaults to the name of the world without dashes.")
+
+	var outPath string
+	flags.StringVar(&outPath, "o", "", "path of the generated Go file. Defaults to STDOUT.")
+
+	_ = flags.Parse(args)
+
+	if help {
+		printWitBindgenUsage(stdErr, flags)
+		exit(0)
+	}
+
+	if flags.NArg() < 1 {
+		fmt.Fprintln(stdErr, "missing path to wit file")
+		printWitBindgenUsage(stdErr, flags)
+		exit(1)
+	}
+	witPath := flags.Arg(0)
+
+	source, err := os.ReadFile(witPath)
+	if err != nil {
+		fmt.Fprintf(stdErr, "error reading wit file: %v\n", err)
+		exit(1)
+	}
+
+	pkg, err := wit.Parse(string(source))
+	if err != nil {
+		fmt.Fprintf(stdErr, "error parsing wit file: %v\n", err)
+		exit(1)
+	}
+
+	var world *wit.World
+	switch {
+	case worldName != "":
+		if world = pkg.World(worldName); world == nil {
+			fmt.Fprintf(stdErr, "world %s not found in %s\n", worldName, witPath)
+			exit(1)
+		}
+	case len(pkg.Worlds) == 1:
+		world = pkg.Worlds[0]
+	default:
+		fmt.Fprintf(stdErr, "%s has %d worlds: requires world\n", witPath, len(pkg.Worlds))
+		exit(1)
+	}
+
+	if goPackage == "" {
+		goPackage = strings.ReplaceAll(world.Name, "-", "")
+	}
+
+	code, err := wit.Generate(pkg, world, goPackage)
+	if err != nil {
+		fmt.Fprintf(stdErr, "error generating bindings: %v\n", err)
+		exit(1)
+	}
+
+	if outPath == "" {
+		_, _ = stdOut.Write(code)
+	} else if err = os.WriteFile(outPath, code, 0o644); err != nil {
+		fmt.Fprintf(stdErr, "error writing bindings: %v\n", err)
+		exit(1)
+	}
+	exit(0)
+}
+
+func printWitBindgenUsage(stdErr io.Writer, flags *flag.FlagSet) {
+	fmt.Fprintln(stdErr, "wazero CLI")
+	fmt.Fprintln(stdErr)
+	fmt.Fprintln(stdErr, "Usage:\n  wazero wit-bindgen <options> <path to wit file>")
+	fmt.Fprintln(stdErr)
+	fmt.Fprintln(stdErr, "Options:")
+	flags.PrintDefaults()
+}
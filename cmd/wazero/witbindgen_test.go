@@ -0,0 +1,97 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+const greeterWit = `package example:greeter;
+
+world greeter {
+  import log: func(message: string);
+  export greet: func(name: string) -> string;
+}
+
+world other {
+  export run: func();
+}
+`
+
+func TestWitBindgen(t *testing.T) {
+	dir := t.TempDir()
+	witPath := filepath.Join(dir, "greeter.wit")
+	require.NoError(t, os.WriteFile(witPath, []byte(greeterWit), 0o600))
+	invalidPath := filepath.Join(dir, "invalid.wit")
+	require.NoError(t, os.WriteFile(invalidPath, []byte("world w { import missing; }"), 0o600))
+
+	tests := []struct {
+		name            string
+		args            []string
+		expectedStdOut  []string
+		expectedMessage string
+	}{
+		{
+			name: "world",
+			args: []string{"--world", "greeter", witPath},
+			expectedStdOut: []string{
+				"package greeter\n",
+				"type GreeterImports interface {",
+				"func (w *Greeter) Greet(ctx context.Context, name string) (r string, err error) {",
+			},
+		},
+		{
+			name:           "package",
+			args:           []string{"--world", "other", "--package", "bindings", witPath},
+			expectedStdOut: []string{"package bindings\n", "func (w *Other) Run(ctx context.Context) (err error) {"},
+		},
+		{
+			name:            "missing world",
+			args:            []string{witPath},
+			expectedMessage: witPath + " has 2 worlds: requires world",
+		},
+		{
+			name:            "unknown world",
+			args:            []string{"--world", "unknown", witPath},
+			expectedMessage: "world unknown not found in " + witPath,
+		},
+		{
+			name:            "invalid",
+			args:            []string{invalidPath},
+			expectedMessage: "error parsing wit file: world w: interface missing isn't defined",
+		},
+		{
+			name:            "missing path",
+			expectedMessage: "missing path to wit file",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			exitCode, stdOut, stdErr := runMain(t, append([]string{"wit-bindgen"}, tc.args...))
+			if tc.expectedMessage == "" {
+				require.Equal(t, 0, exitCode, stdErr)
+				for _, expected := range tc.expectedStdOut {
+					require.Contains(t, stdOut, expected)
+				}
+			} else {
+				require.Equal(t, 1, exitCode)
+				require.Contains(t, stdErr, tc.expectedMessage)
+			}
+		})
+	}
+
+	t.Run("output", func(t *testing.T) {
+		outPath := filepath.Join(dir, "greeter.go")
+		exitCode, stdOut, stdErr := runMain(t, []string{"wit-bindgen", "--world", "greeter", "-o", outPath, witPath})
+		require.Equal(t, 0, exitCode, stdErr)
+		require.Equal(t, "", stdOut)
+
+		code, err := os.ReadFile(outPath)
+		require.NoError(t, err)
+		require.Contains(t, string(code), "// Code generated by wazero wit-bindgen. DO NOT EDIT.")
+	})
+}
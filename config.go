@@ -7,13 +7,18 @@ import (
 	"io"
 	"io/fs"
 	"math"
+	"net"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
 	"github.com/tetratelabs/wazero/internal/engine/compiler"
 	"github.com/tetratelabs/wazero/internal/engine/interpreter"
 	"github.com/tetratelabs/wazero/internal/platform"
 	internalsys "github.com/tetratelabs/wazero/internal/sys"
+	"github.com/tetratelabs/wazero/internal/sysfs"
 	"github.com/tetratelabs/wazero/internal/wasm"
 	"github.com/tetratelabs/wazero/sys"
 )
@@ -99,10 +104,260 @@ type RuntimeConfig interface {
 	//
 	// Note: This only takes into effect when the original Wasm binary has the
 	// DWARF "custom sections" that are often stripped, depending on
-	// optimization flags passed to the compiler.
+	// optimization flags passed to the compiler, or a source map loaded via
+	// experimental.SourceMapLoader.
 	WithDebugInfoEnabled(bool) RuntimeConfig
+
+	// WithCloseOnContextDone ensures the executions of functions to be closed under one of the following circumstances:
+	//
+	//   - context.Context passed to the Call method of api.Function is canceled during execution. (i.e. ctx by context.WithCancel)
+	//   - context.Context passed to the Call method of api.Function reaches timeout during execution. (i.e. ctx by context.WithTimeout or context.WithDeadline)
+	//   - Close or CloseWithExitCode of api.Module is explicitly called during execution.
+	//
+	// This is especially useful when one wants to run untrusted Wasm binaries since otherwise, any invocation of
+	// api.Function can potentially block the corresponding Goroutine forever. Moreover, it might block the
+	// entire underlying OS thread which runs the api.Function call. See "Why it's safe to execute runtime-generated
+	// machine codes against async Goroutine preemption" section in internal/engine/compiler/RATIONALE.md for detail.
+	//
+	// Upon the termination of the function executions, api.Module is closed and the error returned by Call is
+	// a sys.ExitError with sys.ExitCodeContextCanceled or sys.ExitCodeDeadlineExceeded, which also matches
+	// context.Canceled or context.DeadlineExceeded via errors.Is.
+	//
+	// Note that this comes with a bit of extra cost when enabled. The reason is that internally this forces
+	// interpreter and compiler runtimes to insert the periodical checks on the conditions above. For that reason,
+	// this is disabled by default.
+	WithCloseOnContextDone(bool) RuntimeConfig
+
+	// WithHostFunctionPanicPolicy controls what happens when a function
+	// defined in Go, e.g. via HostModuleBuilder, panics. Defaults to
+	// HostFunctionPanicPolicyTrap.
+	//
+	// For example, this propagates the panic to the caller of api.Function
+	// Call, which is useful to crash on bugs in host functions:
+	//
+	//	rConfig = wazero.NewRuntimeConfig().
+	//		WithHostFunctionPanicPolicy(wazero.HostFunctionPanicPolicyRepanic)
+	//
+	// Note: A sys.ExitError panicked by a host function, such as
+	// "proc_exit", is not a fault, so always returned as an error.
+	WithHostFunctionPanicPolicy(HostFunctionPanicPolicy) RuntimeConfig
+
+	// WithHostFunctionPanicHandler sets the policy to
+	// HostFunctionPanicPolicyHandler, routing any panic inside a host
+	// function to the given handler.
+	//
+	// For example, this logs the panic and the stack trace where it happened:
+	//
+	//	rConfig = wazero.NewRuntimeConfig().WithHostFunctionPanicHandler(
+	//		func(ctx context.Context, def api.FunctionDefinition, recovered interface{}, stack []byte) error {
+	//			log.Printf("%s panicked: %v\n%s", def.DebugName(), recovered, stack)
+	//			return fmt.Errorf("%s failed", def.DebugName())
+	//		})
+	WithHostFunctionPanicHandler(HostFunctionPanicHandler) RuntimeConfig
+
+	// WithCPUTimeAccounting enables api.Module CPUTime, which tracks the
+	// cumulative CPU time spent executing the functions of each module,
+	// separating wasm from host functions. Defaults to false.
+	//
+	// For example, this is useful to bill or throttle tenants of a platform:
+	//
+	//	rConfig = wazero.NewRuntimeConfig().WithCPUTimeAccounting(true)
+	//	--snip--
+	//	_, err = mod.ExportedFunction("handle").Call(ctx)
+	//	log.Printf("%s used %s", mod.Name(), mod.CPUTime().Guest)
+	//
+	// Note: This is disabled by default, as it reads the CPU clock of the
+	// thread on each call to an exported or host function, and locks the
+	// calling goroutine to its thread while an exported function executes.
+	WithCPUTimeAccounting(bool) RuntimeConfig
+
+	// WithFunctionListenerFactory notifies listeners returned by the factory
+	// of calls to functions of modules compiled by the runtime. This is the
+	// same as adding experimental.FunctionListenerFactoryKey to the context
+	// passed to Runtime.CompileModule and HostModuleBuilder.Compile, except a
+	// factory in the context takes precedence. Defaults to nil.
+	//
+	// For example, this logs all calls to functions in host modules:
+	//
+	//	rConfig = wazero.NewRuntimeConfig().WithFunctionListenerFactory(
+	//		logging.NewHostLoggingListenerFactory(os.Stdout))
+	//
+	// Note: Listeners are compiled into the module, so they apply to all of
+	// its instances. Use the context to use a different factory for a module.
+	WithFunctionListenerFactory(experimental.FunctionListenerFactory) RuntimeConfig
+
+	// WithMemoryListener notifies the listener of loads and stores within its
+	// watch regions by modules compiled by the runtime. This is the same as
+	// adding experimental.MemoryListenerKey to the context passed to
+	// Runtime.CompileModule, except a listener in the context takes
+	// precedence. Defaults to nil.
+	//
+	// Note: See experimental.MemoryListenerKey for the cost of this.
+	WithMemoryListener(experimental.MemoryListener) RuntimeConfig
+
+	// WithTrapHandler invokes the handler when a guest function of a module
+	// compiled by the runtime traps, before its call stack is unwound. The
+	// handler may resume execution in the interpreter, e.g. to debug or
+	// inject faults. Defaults to nil.
+	//
+	// Note: In the interpreter, this slows down execution, as it tracks the
+	// state needed to resume. See experimental.TrapHandler for details.
+	WithTrapHandler(experimental.TrapHandler) RuntimeConfig
+
+	// WithDifferentialExecution calls each exported function on both the
+	// compiler and the interpreter, passing any difference in their results,
+	// traps, memory or globals to the handler. Calls return the results of the
+	// engine of this config. Defaults to nil, which disables this.
+	//
+	// For example, this helps chase a suspected miscompilation in a test:
+	//
+	//	rConfig = wazero.NewRuntimeConfigCompiler().WithDifferentialExecution(
+	//		func(ctx context.Context, def api.FunctionDefinition, divergence string) {
+	//			t.Errorf("%s diverged: %s", def.DebugName(), divergence)
+	//		})
+	//
+	// # Notes
+	//
+	//   - This is for testing and debugging only, as each module is
+	//     instantiated and each call is made twice.
+	//   - Host functions are called on both engines, so their side effects,
+	//     such as writing to stdout, happen twice. Results are only comparable
+	//     when host functions are deterministic.
+	//   - When the compiler isn't supported, both engines are the interpreter.
+	WithDifferentialExecution(DivergenceHandler) RuntimeConfig
+
+	// WithNaNCanonicalization replaces a NaN result of each float instruction
+	// with the canonical NaN, whose sign bit is zero. Defaults to false.
+	//
+	// The bits of a NaN produced by an instruction, e.g. "f32.sqrt" of a
+	// negative number, differ between platforms and engines. Enable this when
+	// results must be identical across them, e.g. in consensus-critical
+	// systems or when replaying execution on another host. Instructions which
+	// only move bits, such as "f32.abs", "f32.neg" and loads, are unaffected.
+	//
+	// Note: This adds a comparison after each float instruction.
+	WithNaNCanonicalization(bool) RuntimeConfig
+
+	// WithMemoryProtection allows api.Memory Protect to make pages of the
+	// memory of modules compiled by the runtime, or created by
+	// Runtime.NewMemory, read-only. Defaults to false.
+	//
+	// For example, this lets a host publish a lookup table the guest can't
+	// corrupt:
+	//
+	//	rConfig = wazero.NewRuntimeConfigCompiler().WithMemoryProtection(true)
+	//	// ... instantiate the module, then write the table at offset
+	//	mod.Memory().Write(offset, table)
+	//	mod.Memory().Protect(offset, uint32(len(table)), true)
+	//
+	// Note: This checks each store, "memory.init", "memory.copy" and
+	// "memory.fill" against the protected pages, so is slower, especially in
+	// the compiler, which leaves native code to check them.
+	WithMemoryProtection(bool) RuntimeConfig
+
+	// WithLeakPolicy controls what Runtime.Close does about resources which
+	// were never closed before it: module instances, compiled modules and
+	// files left open by them. Defaults to LeakPolicyIgnore.
+	//
+	// For example, this fails a test which forgets to close a module:
+	//
+	//	rConfig = wazero.NewRuntimeConfig().WithLeakPolicy(wazero.LeakPolicyError)
+	//	r := wazero.NewRuntimeWithConfig(ctx, rConfig)
+	//	--snip--
+	//	require.NoError(t, r.Close(ctx))
+	//
+	// Note: Runtime.Close still closes any leaked resources.
+	WithLeakPolicy(LeakPolicy) RuntimeConfig
+
+	// WithLeakHandler sets the policy to LeakPolicyHandler, passing any
+	// resources which were never closed before Runtime.Close to the handler.
+	//
+	// For example, this logs leaks in a long-running service:
+	//
+	//	rConfig = wazero.NewRuntimeConfig().WithLeakHandler(
+	//		func(ctx context.Context, leaks []wazero.Leak) {
+	//			for _, l := range leaks {
+	//				log.Printf("leaked %s\n%s", l, l.Stack)
+	//			}
+	//		})
+	WithLeakHandler(LeakHandler) RuntimeConfig
+
+	// WithLeakStackTraces records the Go stack trace where each module is
+	// instantiated and compiled, so that a Leak includes where it was
+	// created. Defaults to false.
+	//
+	// Note: This is a debug mode, as it captures a stack trace on each
+	// instantiation and compilation. It has no effect with LeakPolicyIgnore.
+	WithLeakStackTraces(bool) RuntimeConfig
 }
 
+// HostFunctionPanicPolicy controls what happens when a host function panics.
+//
+// See RuntimeConfig.WithHostFunctionPanicPolicy
+type HostFunctionPanicPolicy byte
+
+const (
+	// HostFunctionPanicPolicyTrap converts the panic into a trap: the guest
+	// stops executing and api.Function Call returns an error including the
+	// recovered value and the wasm stack trace. This is the default.
+	HostFunctionPanicPolicyTrap HostFunctionPanicPolicy = iota
+
+	// HostFunctionPanicPolicyRepanic propagates the panic, with the original
+	// value, to the caller of api.Function Call.
+	HostFunctionPanicPolicyRepanic
+
+	// HostFunctionPanicPolicyHandler routes the panic to the
+	// HostFunctionPanicHandler. Use RuntimeConfig.WithHostFunctionPanicHandler
+	// instead of setting this directly.
+	HostFunctionPanicPolicyHandler
+)
+
+// HostFunctionPanicHandler is called when the host function def panics with
+// the recovered value. The stack is the Go stack trace of the goroutine at the
+// point of panic, formatted as debug.Stack.
+//
+// The returned error is handled as a trap: api.Function Call returns it,
+// wrapped with the wasm stack trace. When nil, the recovered value is handled
+// as a trap instead.
+//
+// Note: The handler must not call functions in the module, as it is
+// running in the middle of a function call.
+type HostFunctionPanicHandler func(ctx context.Context, def api.FunctionDefinition, recovered interface{}, stack []byte) error
+
+// LeakPolicy controls what Runtime.Close does about resources which were
+// never closed before it.
+//
+// See RuntimeConfig.WithLeakPolicy
+type LeakPolicy byte
+
+const (
+	// LeakPolicyIgnore closes leaked resources silently. This is the default.
+	LeakPolicyIgnore LeakPolicy = iota
+
+	// LeakPolicyError returns a *LeakError from Runtime.Close, unless closing
+	// failed with another error.
+	LeakPolicyError
+
+	// LeakPolicyHandler passes leaked resources to the LeakHandler. Use
+	// RuntimeConfig.WithLeakHandler instead of setting this directly.
+	LeakPolicyHandler
+)
+
+// LeakHandler is called by Runtime.Close with the resources which were never
+// closed before it, before closing them.
+//
+// See RuntimeConfig.WithLeakHandler
+type LeakHandler func(ctx context.Context, leaks []Leak)
+
+// DivergenceHandler is called when a call to the function def diverges
+// between the compiler and the interpreter, with a description of the first
+// difference found, e.g. "result[0] 0x1 != 0x2" or "memory[1024] 0x0 != 0xff".
+//
+// Note: The handler must not call functions in the module.
+//
+// See RuntimeConfig.WithDifferentialExecution
+type DivergenceHandler func(ctx context.Context, def api.FunctionDefinition, divergence string)
+
 // NewRuntimeConfig returns a RuntimeConfig using the compiler if it is supported in this environment,
 // or the interpreter otherwise.
 func NewRuntimeConfig() RuntimeConfig {
@@ -115,6 +370,19 @@ type runtimeConfig struct {
 	memoryCapacityFromMax bool
 	isInterpreter         bool
 	dwarfDisabled         bool // negative as defaults to enabled
+	ensureTermination     bool
+	panicPolicy           HostFunctionPanicPolicy
+	panicHandler          HostFunctionPanicHandler
+	cpuTimeAccounting     bool
+	listenerFactory       experimental.FunctionListenerFactory
+	memoryListener        experimental.MemoryListener
+	trapHandler           experimental.TrapHandler
+	divergenceHandler     DivergenceHandler
+	canonicalizeNaN       bool
+	memoryProtection      bool
+	leakPolicy            LeakPolicy
+	leakHandler           LeakHandler
+	leakStackTraces       bool
 	newEngine             func(context.Context, api.CoreFeatures) wasm.Engine
 }
 
@@ -192,6 +460,99 @@ func (c *runtimeConfig) WithDebugInfoEnabled(dwarfEnabled bool) RuntimeConfig {
 	return ret
 }
 
+// WithCloseOnContextDone implements RuntimeConfig.WithCloseOnContextDone
+func (c *runtimeConfig) WithCloseOnContextDone(ensure bool) RuntimeConfig {
+	ret := c.clone()
+	ret.ensureTermination = ensure
+	return ret
+}
+
+// WithHostFunctionPanicPolicy implements RuntimeConfig.WithHostFunctionPanicPolicy
+func (c *runtimeConfig) WithHostFunctionPanicPolicy(policy HostFunctionPanicPolicy) RuntimeConfig {
+	ret := c.clone()
+	ret.panicPolicy = policy
+	return ret
+}
+
+// WithHostFunctionPanicHandler implements RuntimeConfig.WithHostFunctionPanicHandler
+func (c *runtimeConfig) WithHostFunctionPanicHandler(handler HostFunctionPanicHandler) RuntimeConfig {
+	ret := c.clone()
+	ret.panicPolicy = HostFunctionPanicPolicyHandler
+	ret.panicHandler = handler
+	return ret
+}
+
+// WithCPUTimeAccounting implements RuntimeConfig.WithCPUTimeAccounting
+func (c *runtimeConfig) WithCPUTimeAccounting(enabled bool) RuntimeConfig {
+	ret := c.clone()
+	ret.cpuTimeAccounting = enabled
+	return ret
+}
+
+// WithFunctionListenerFactory implements RuntimeConfig.WithFunctionListenerFactory
+func (c *runtimeConfig) WithFunctionListenerFactory(factory experimental.FunctionListenerFactory) RuntimeConfig {
+	ret := c.clone()
+	ret.listenerFactory = factory
+	return ret
+}
+
+// WithMemoryListener implements RuntimeConfig.WithMemoryListener
+func (c *runtimeConfig) WithMemoryListener(listener experimental.MemoryListener) RuntimeConfig {
+	ret := c.clone()
+	ret.memoryListener = listener
+	return ret
+}
+
+// WithTrapHandler implements RuntimeConfig.WithTrapHandler
+func (c *runtimeConfig) WithTrapHandler(handler experimental.TrapHandler) RuntimeConfig {
+	ret := c.clone()
+	ret.trapHandler = handler
+	return ret
+}
+
+// WithDifferentialExecution implements RuntimeConfig.WithDifferentialExecution
+func (c *runtimeConfig) WithDifferentialExecution(handler DivergenceHandler) RuntimeConfig {
+	ret := c.clone()
+	ret.divergenceHandler = handler
+	return ret
+}
+
+// WithNaNCanonicalization implements RuntimeConfig.WithNaNCanonicalization
+func (c *runtimeConfig) WithNaNCanonicalization(enabled bool) RuntimeConfig {
+	ret := c.clone()
+	ret.canonicalizeNaN = enabled
+	return ret
+}
+
+// WithMemoryProtection implements RuntimeConfig.WithMemoryProtection
+func (c *runtimeConfig) WithMemoryProtection(enabled bool) RuntimeConfig {
+	ret := c.clone()
+	ret.memoryProtection = enabled
+	return ret
+}
+
+// WithLeakPolicy implements RuntimeConfig.WithLeakPolicy
+func (c *runtimeConfig) WithLeakPolicy(policy LeakPolicy) RuntimeConfig {
+	ret := c.clone()
+	ret.leakPolicy = policy
+	return ret
+}
+
+// WithLeakHandler implements RuntimeConfig.WithLeakHandler
+func (c *runtimeConfig) WithLeakHandler(handler LeakHandler) RuntimeConfig {
+	ret := c.clone()
+	ret.leakPolicy = LeakPolicyHandler
+	ret.leakHandler = handler
+	return ret
+}
+
+// WithLeakStackTraces implements RuntimeConfig.WithLeakStackTraces
+func (c *runtimeConfig) WithLeakStackTraces(enabled bool) RuntimeConfig {
+	ret := c.clone()
+	ret.leakStackTraces = enabled
+	return ret
+}
+
 // CompiledModule is a WebAssembly module ready to be instantiated (Runtime.InstantiateModule) as an api.Module.
 //
 // In WebAssembly terminology, this is a decoded, validated, and possibly also compiled module. wazero avoids using
@@ -246,6 +607,8 @@ type compiledModule struct {
 	compiledEngine wasm.Engine
 	// closeWithModule prevents leaking compiled code when a module is compiled implicitly.
 	closeWithModule bool
+	// leaks is non-nil when leak detection is enabled.
+	leaks *leakTracker
 }
 
 // Name implements CompiledModule.Name
@@ -258,6 +621,7 @@ func (c *compiledModule) Name() (moduleName string) {
 
 // Close implements CompiledModule.Close
 func (c *compiledModule) Close(context.Context) error {
+	c.leaks.untrackCompiled(c)
 	c.compiledEngine.DeleteCompiledModule(c.module)
 	// It is possible the underlying may need to return an error later, but in any case this matches api.Module.Close.
 	return nil
@@ -310,6 +674,8 @@ type ModuleConfig interface {
 	// WebAssembly nor WebAssembly System Interfaces (WASI) define this. Regardless, you may choose to set the first
 	// argument to the same value set via WithName.
 	//
+	// Args may contain arbitrary bytes, not only UTF-8, except NULL(0), which terminates each arg.
+	//
 	// Note: This does not default to os.Args as that violates sandboxing.
 	//
 	// See https://linux.die.net/man/3/argv and https://en.wikipedia.org/wiki/Null-terminated_string
@@ -318,7 +684,8 @@ type ModuleConfig interface {
 	// WithEnv sets an environment variable visible to a Module that imports functions. Defaults to none.
 	// Runtime.InstantiateModule errs if the key is empty or contains a NULL(0) or equals("") character.
 	//
-	// Validation is the same as os.Setenv on Linux and replaces any existing value. Unlike exec.Cmd Env, this does not
+	// Validation is the same as os.Setenv on Linux and replaces any existing value. Like args, keys and values may
+	// contain arbitrary bytes, not only UTF-8. Unlike exec.Cmd Env, this does not
 	// default to the current process environment as that would violate sandboxing. This also does not preserve order.
 	//
 	// Environment variables are commonly read by the functions like "environ_get" in "wasi_snapshot_preview1" although
@@ -331,6 +698,46 @@ type ModuleConfig interface {
 	// See https://linux.die.net/man/3/environ and https://en.wikipedia.org/wiki/Null-terminated_string
 	WithEnv(key, value string) ModuleConfig
 
+	// WithHostEnv allows environment variables of the host process to be visible to the Module, when their key matches
+	// an entry in allowList. An entry ending in '*' matches keys with that prefix, e.g. "LC_*". Defaults to none.
+	//
+	// Values are read on each instantiation, not when this is called, and any value set by WithEnv takes precedence.
+	// Host environment variables with keys that are invalid for WithEnv, such as empty, are skipped.
+	//
+	// Note: Use this instead of passing os.Environ to WithEnv, so that secrets in the host environment aren't visible
+	// to the guest by default.
+	WithHostEnv(allowList ...string) ModuleConfig
+
+	// WithMaxArgsSize limits the total size in bytes of args configured by WithArgs, including a null terminator per
+	// arg, as returned by "args_sizes_get" in "wasi_snapshot_preview1". Defaults to math.MaxUint32.
+	//
+	// Runtime.InstantiateModule errs with syscall.E2BIG if this is exceeded, similar to `execve` in POSIX when the
+	// args exceed ARG_MAX.
+	WithMaxArgsSize(size uint32) ModuleConfig
+
+	// WithMaxEnvSize is like WithMaxArgsSize, except it limits environment variables including those configured by
+	// WithHostEnv. The size of each is its key, '=', value and a null terminator.
+	WithMaxEnvSize(size uint32) ModuleConfig
+
+	// WithTimezone exposes the IANA time zone name, e.g. "America/New_York",
+	// to the guest as its local time zone. An empty name means the host's,
+	// read from its "TZ" environment variable or "/etc/localtime", and
+	// otherwise "UTC". Defaults to none, so guests usually format times as UTC.
+	//
+	// This sets the environment variables "TZ" to the name and "ZONEINFO" to
+	// "/usr/share/zoneinfo", unless set by WithEnv. The host's time zone
+	// database, if found, is mounted read-only there, in addition to any
+	// file system configured by WithFS or WithFSConfig.
+	//
+	// For example, a guest compiled with GOOS=wasip1 can load its local time
+	// zone like so, as its time.Local is always UTC:
+	//
+	//	loc, err := time.LoadLocation(os.Getenv("TZ"))
+	//
+	// Note: Guests compiled with GOOS=js, run by the gojs package, use the
+	// offset of "TZ" as time.Local, without needing the database.
+	WithTimezone(name string) ModuleConfig
+
 	// WithFS assigns the file system to use for any paths beginning at "/".
 	// Defaults return fs.ErrNotExist.
 	//
@@ -350,18 +757,100 @@ type ModuleConfig interface {
 	//	// Files relative to "/work/appA" are accessible as "/".
 	//	config := wazero.NewModuleConfig().WithFS(os.DirFS("/work/appA"))
 	//
+	// Writes
+	//
+	// fs.FS is read-only, so opening a file with flags that need write
+	// access, such as create or truncate, fails with syscall.EROFS. To allow
+	// writes, the fs.FS must also implement the same signature as
+	// os.OpenFile: OpenFile(name string, flag int, perm fs.FileMode)
+	// (fs.File, error). Notably, os.DirFS does not. Similarly, links are
+	// supported when the fs.FS implements methods named like and with the
+	// same signature as os.Symlink, os.Readlink and os.Link, and creating,
+	// renaming and removing directories and files with os.Mkdir, os.Rename
+	// and os.Remove. os.Lstat is used, if implemented, to avoid following a
+	// symbolic link when removing it. memfs.FS in the experimental/memfs
+	// package implements all of these in memory.
+	//
 	// Isolation
 	//
 	// os.DirFS documentation includes important notes about isolation, which
 	// also applies to fs.Sub. As of Go 1.19, the built-in file-systems are not
 	// jailed (chroot). See https://github.com/golang/go/issues/42322
 	//
+	// To mount a host directory, prefer WithFSConfig and FSConfig
+	// WithDirMount, which don't follow symbolic links outside it.
+	//
 	// Working Directory "."
 	//
 	// Relative path resolution, such as "./config.yml" to "/config.yml" or
 	// otherwise, is compiler-specific. See /RATIONALE.md for notes.
+	//
+	// Note: This replaces any FSConfig set by WithFSConfig.
 	WithFS(fs.FS) ModuleConfig
 
+	// WithFSConfig configures the file systems a module can access, each
+	// mounted at a guest path. Use this instead of WithFS to combine several
+	// host directories, or to make some of them read-only.
+	//
+	// For example, this mounts the host directory "/work/appA" as "/", and
+	// "/tmp/appA" as "/tmp". Only the latter is writable:
+	//
+	//	config := wazero.NewModuleConfig().WithFSConfig(wazero.NewFSConfig().
+	//		WithReadOnlyDirMount("/work/appA", "/").
+	//		WithDirMount("/tmp/appA", "/tmp"))
+	//
+	// Note: This replaces any fs.FS set by WithFS.
+	WithFSConfig(FSConfig) ModuleConfig
+
+	// WithTCPListener configures a TCP socket to listen on the given address,
+	// e.g. "0.0.0.0:8080", when the module is instantiated. The guest accepts
+	// connections with the "sock_accept" function in "wasi_snapshot_preview1".
+	// This can be called multiple times to listen on multiple addresses.
+	//
+	// # Notes
+	//
+	//   - Like `wasmtime --tcplisten`, sockets are inherited as file
+	//     descriptors, in the order configured, after stdio and the root
+	//     directory if WithFS was configured. For example, guests commonly
+	//     assume the first listener is file descriptor 3, which requires no
+	//     WithFS.
+	//   - Instantiation fails if the address can't be listened on, e.g. as it
+	//     is already in use.
+	//   - The socket is closed when the module is.
+	WithTCPListener(address string) ModuleConfig
+
+	// WithUDPListener is like WithTCPListener, except the socket is a UDP
+	// socket bound to the given address. The guest receives datagrams with the
+	// "sock_recv" function in "wasi_snapshot_preview1".
+	//
+	// Note: "wasi_snapshot_preview1" has no function to send a datagram to an
+	// address, so "sock_send" fails on this socket.
+	WithUDPListener(address string) ModuleConfig
+
+	// WithInheritedFile opens the host file as a file descriptor of the
+	// module, e.g. a pipe, device, socket or memfd. This allows a supervisor
+	// to exchange messages with the guest over descriptors besides stdio.
+	// This can be called multiple times to inherit multiple files.
+	//
+	// For example, this passes the read side of a pipe, which the guest can
+	// read with "fd_read" in "wasi_snapshot_preview1":
+	//
+	//	r, w, err := os.Pipe()
+	//	config := wazero.NewModuleConfig().WithInheritedFile(r)
+	//
+	// # Notes
+	//
+	//   - Files are inherited as file descriptors, in the order configured,
+	//     after stdio, the root directory if WithFS was configured, and any
+	//     sockets, e.g. from WithTCPListener. For example, with none of
+	//     these, the first file is file descriptor 3.
+	//   - "fd_fdstat_get" reports the type of the file, e.g. a character
+	//     device or datagram socket. Pipes have no WASI type, so are unknown.
+	//   - The caller owns the file: it isn't closed when the guest closes its
+	//     file descriptor or the module is closed. This allows the same
+	//     ModuleConfig to be used for more than one module.
+	WithInheritedFile(f *os.File) ModuleConfig
+
 	// WithName configures the module name. Defaults to what was decoded from the name section.
 	WithName(string) ModuleConfig
 
@@ -398,6 +887,9 @@ type ModuleConfig interface {
 	//
 	//   - The caller is responsible to close any io.Reader they supply: It is not closed on api.Module Close.
 	//   - This does not default to os.Stdin as that both violates sandboxing and prevents concurrent modules.
+	//   - When the io.Reader isn't an *os.File, such as an io.Pipe, a guest can still poll it or read it non-blocking,
+	//     e.g. via "poll_oneoff" in "wasi_snapshot_preview1". To support this, once polled, it is read ahead in a
+	//     goroutine, which exits when Read returns an error, such as io.EOF.
 	//
 	// See https://linux.die.net/man/3/stdin
 	WithStdin(io.Reader) ModuleConfig
@@ -457,6 +949,37 @@ type ModuleConfig interface {
 	// See WithNanotime
 	WithSysNanotime() ModuleConfig
 
+	// WithCputime configures the CPU time clock, used to measure how much a
+	// module computed, in nanoseconds. Defaults to a fake result that
+	// increases by 1ms on each reading.
+	//
+	// Here's an example of a clock which advances at a fixed rate per reading,
+	// so that a guest measuring itself behaves deterministically:
+	//	var nanos int64
+	//	moduleConfig = moduleConfig.
+	//		WithCputime(func() int64 {
+	//			nanos += 1000
+	//			return nanos
+	//		}, sys.ClockResolution(1000))
+	//
+	// # Notes:
+	//   - This is read by `clock_time_get` in "wasi_snapshot_preview1" for
+	//     both CLOCK_PROCESS_CPUTIME_ID and CLOCK_THREAD_CPUTIME_ID, as a
+	//     module only has one thread.
+	//   - This does not default to the host CPU time as that violates
+	//     sandboxing.
+	//   - Use WithSysCputime for a usable implementation.
+	WithCputime(sys.Cputime, sys.ClockResolution) ModuleConfig
+
+	// WithSysCputime uses the CPU time consumed by calls to the module for
+	// sys.Cputime, with a resolution of 1us. This is measured like
+	// api.Module CPUTime, as the sum of its guest and host time, including
+	// calls in progress, so it doesn't reveal the CPU time of the host or of
+	// other modules.
+	//
+	// See WithCputime
+	WithSysCputime() ModuleConfig
+
 	// WithNanosleep configures the how to pause the current goroutine for at
 	// least the configured nanoseconds. Defaults to return immediately.
 	//
@@ -492,27 +1015,76 @@ type ModuleConfig interface {
 	// Note: The caller is responsible to close any io.Reader they supply: It
 	// is not closed on api.Module Close.
 	WithRandSource(io.Reader) ModuleConfig
+
+	// WithRandSeed configures a deterministic source of random bytes seeded
+	// with the given value, for use cases such as replay or consensus, which
+	// require each module instance to see the same random bytes. The default
+	// is the same as WithRandSeed(42).
+	//
+	// # Reproducibility
+	//
+	// A new source is created on each instantiation, so every module
+	// instantiated with the same seed reads the same bytes, regardless of
+	// how they are split across calls such as "random_get". These bytes are
+	// the same on all platforms and versions of wazero.
+	//
+	// Note: This replaces any WithRandSource or WithoutRandSource.
+	WithRandSeed(seed int64) ModuleConfig
+
+	// WithoutRandSource configures the module to fail when it reads random
+	// bytes, for use cases which must be reproducible without relying on a
+	// seed. For example, "random_get" in "wasi_snapshot_preview1" returns
+	// ErrnoNotsup, and "seed" in AssemblyScript standard "env" panics.
+	//
+	// Note: This replaces any WithRandSource or WithRandSeed.
+	WithoutRandSource() ModuleConfig
 }
 
 type moduleConfig struct {
-	name               string
-	startFunctions     []string
-	stdin              io.Reader
-	stdout             io.Writer
-	stderr             io.Writer
-	randSource         io.Reader
+	name           string
+	startFunctions []string
+	stdin          io.Reader
+	stdout         io.Writer
+	stderr         io.Writer
+	randSource     io.Reader
+	// randSeed is the seed of a new source of random bytes when randSource
+	// is nil, or nil to use the default.
+	randSeed           *int64
 	walltime           *sys.Walltime
 	walltimeResolution sys.ClockResolution
 	nanotime           *sys.Nanotime
 	nanotimeResolution sys.ClockResolution
+	cputime            *sys.Cputime
+	cputimeResolution  sys.ClockResolution
+	sysCputime         bool // instead of cputime, see WithSysCputime
 	nanosleep          *sys.Nanosleep
 	args               [][]byte
 	// environ is pair-indexed to retain order similar to os.Environ.
 	environ [][]byte
 	// environKeys allow overwriting of existing values.
 	environKeys map[string]int
+	// hostEnvAllowList are patterns of host environment variables to add to
+	// environ on instantiation.
+	hostEnvAllowList []string
+	// maxArgsSize and maxEnvSize limit the null-terminated size of args and
+	// environ.
+	maxArgsSize, maxEnvSize uint32
 	// fs is the file system to open files with
 	fs fs.FS
+	// fsConfig is the mounts to open files with, if fs is nil.
+	fsConfig *fsConfig
+	// listeners are the sockets to open on instantiation, in order.
+	listeners []listenerConfig
+	// inheritedFiles are the host files to open on instantiation, in order.
+	inheritedFiles []*os.File
+	// timezone is the name set by WithTimezone, or nil if not set.
+	timezone *string
+}
+
+// listenerConfig is a socket configured with WithTCPListener or
+// WithUDPListener.
+type listenerConfig struct {
+	network, address string
 }
 
 // NewModuleConfig returns a ModuleConfig that can be used for configuring module instantiation.
@@ -520,6 +1092,8 @@ func NewModuleConfig() ModuleConfig {
 	return &moduleConfig{
 		startFunctions: []string{"_start"},
 		environKeys:    map[string]int{},
+		maxArgsSize:    math.MaxUint32,
+		maxEnvSize:     math.MaxUint32,
 	}
 }
 
@@ -564,10 +1138,72 @@ func (c *moduleConfig) WithEnv(key, value string) ModuleConfig {
 	return ret
 }
 
+// WithHostEnv implements ModuleConfig.WithHostEnv
+func (c *moduleConfig) WithHostEnv(allowList ...string) ModuleConfig {
+	ret := c.clone()
+	ret.hostEnvAllowList = allowList
+	return ret
+}
+
+// WithMaxArgsSize implements ModuleConfig.WithMaxArgsSize
+func (c *moduleConfig) WithMaxArgsSize(size uint32) ModuleConfig {
+	ret := c.clone()
+	ret.maxArgsSize = size
+	return ret
+}
+
+// WithMaxEnvSize implements ModuleConfig.WithMaxEnvSize
+func (c *moduleConfig) WithMaxEnvSize(size uint32) ModuleConfig {
+	ret := c.clone()
+	ret.maxEnvSize = size
+	return ret
+}
+
+// WithTimezone implements ModuleConfig.WithTimezone
+func (c *moduleConfig) WithTimezone(name string) ModuleConfig {
+	ret := c.clone()
+	ret.timezone = &name
+	return ret
+}
+
 // WithFS implements ModuleConfig.WithFS
 func (c *moduleConfig) WithFS(fs fs.FS) ModuleConfig {
 	ret := c.clone()
 	ret.fs = fs
+	ret.fsConfig = nil
+	return ret
+}
+
+// WithFSConfig implements ModuleConfig.WithFSConfig
+func (c *moduleConfig) WithFSConfig(config FSConfig) ModuleConfig {
+	ret := c.clone()
+	ret.fs = nil
+	ret.fsConfig, _ = config.(*fsConfig)
+	return ret
+}
+
+// WithTCPListener implements ModuleConfig.WithTCPListener
+func (c *moduleConfig) WithTCPListener(address string) ModuleConfig {
+	return c.withListener("tcp", address)
+}
+
+// WithUDPListener implements ModuleConfig.WithUDPListener
+func (c *moduleConfig) WithUDPListener(address string) ModuleConfig {
+	return c.withListener("udp", address)
+}
+
+func (c *moduleConfig) withListener(network, address string) ModuleConfig {
+	ret := c.clone()
+	// Copy, so that appending doesn't affect the receiver's slice.
+	ret.listeners = append(append([]listenerConfig(nil), c.listeners...), listenerConfig{network, address})
+	return ret
+}
+
+// WithInheritedFile implements ModuleConfig.WithInheritedFile
+func (c *moduleConfig) WithInheritedFile(f *os.File) ModuleConfig {
+	ret := c.clone()
+	// Copy, so that appending doesn't affect the receiver's slice.
+	ret.inheritedFiles = append(append([]*os.File(nil), c.inheritedFiles...), f)
 	return ret
 }
 
@@ -636,6 +1272,23 @@ func (c *moduleConfig) WithSysNanotime() ModuleConfig {
 	return c.WithNanotime(platform.Nanotime, sys.ClockResolution(1))
 }
 
+// WithCputime implements ModuleConfig.WithCputime
+func (c *moduleConfig) WithCputime(cputime sys.Cputime, resolution sys.ClockResolution) ModuleConfig {
+	ret := c.clone()
+	ret.cputime = &cputime
+	ret.cputimeResolution = resolution
+	ret.sysCputime = false
+	return ret
+}
+
+// WithSysCputime implements ModuleConfig.WithSysCputime
+func (c *moduleConfig) WithSysCputime() ModuleConfig {
+	ret := c.clone()
+	ret.cputime = nil
+	ret.sysCputime = true
+	return ret
+}
+
 // WithNanosleep implements ModuleConfig.WithNanosleep
 func (c *moduleConfig) WithNanosleep(nanosleep sys.Nanosleep) ModuleConfig {
 	ret := *c // copy
@@ -652,12 +1305,27 @@ func (c *moduleConfig) WithSysNanosleep() ModuleConfig {
 func (c *moduleConfig) WithRandSource(source io.Reader) ModuleConfig {
 	ret := c.clone()
 	ret.randSource = source
+	ret.randSeed = nil
 	return ret
 }
 
+// WithRandSeed implements ModuleConfig.WithRandSeed
+func (c *moduleConfig) WithRandSeed(seed int64) ModuleConfig {
+	ret := c.clone()
+	ret.randSource = nil
+	ret.randSeed = &seed
+	return ret
+}
+
+// WithoutRandSource implements ModuleConfig.WithoutRandSource
+func (c *moduleConfig) WithoutRandSource() ModuleConfig {
+	return c.WithRandSource(platform.NoRandSource)
+}
+
 // toSysContext creates a baseline wasm.Context configured by ModuleConfig.
 func (c *moduleConfig) toSysContext() (sysCtx *internalsys.Context, err error) {
 	var environ [][]byte // Intentionally doesn't pre-allocate to reduce logic to default to nil.
+	environ = c.hostEnviron()
 	// Same validation as syscall.Setenv for Linux
 	for i := 0; i < len(c.environ); i += 2 {
 		key, value := c.environ[i], c.environ[i+1]
@@ -682,17 +1350,173 @@ func (c *moduleConfig) toSysContext() (sysCtx *internalsys.Context, err error) {
 		environ = append(environ, result)
 	}
 
-	return internalsys.NewContext(
-		math.MaxUint32,
+	var mounts []sysfs.Mount
+	if c.timezone != nil {
+		var zoneinfo *sysfs.Mount
+		environ, zoneinfo = c.timezoneEnviron(environ)
+		if zoneinfo != nil {
+			mounts = append(mounts, *zoneinfo) // first, so that others win
+		}
+	}
+
+	root := c.fs
+	if c.fsConfig != nil {
+		mounts = append(mounts, c.fsConfig.mounts...)
+	} else if root != nil && mounts != nil {
+		mounts = append(mounts, sysfs.Mount{GuestPath: "/", FS: root})
+	}
+	if c.fsConfig != nil || mounts != nil {
+		if root, err = sysfs.NewRootFS(mounts); err != nil {
+			return
+		}
+	}
+
+	randSource := c.randSource
+	if c.randSeed != nil {
+		randSource = platform.NewSeededRandSource(*c.randSeed)
+	}
+
+	sysCtx, err = internalsys.NewContext(
+		c.maxArgsSize, c.maxEnvSize,
 		c.args,
 		environ,
 		c.stdin,
 		c.stdout,
 		c.stderr,
-		c.randSource,
+		randSource,
 		c.walltime, c.walltimeResolution,
 		c.nanotime, c.nanotimeResolution,
+		c.cputime, c.cputimeResolution,
 		c.nanosleep,
-		c.fs,
+		root,
 	)
+	if err == nil && c.sysCputime {
+		sysCtx.UseModuleCputime()
+	}
+	return
+}
+
+// zoneinfoGuestPath is where WithTimezone mounts the time zone database.
+const zoneinfoGuestPath = "/usr/share/zoneinfo"
+
+// timezoneEnviron returns environ with the variables set by WithTimezone,
+// unless already in it, and a mount of the host's time zone database,
+// or nil if not found.
+func (c *moduleConfig) timezoneEnviron(environ [][]byte) ([][]byte, *sysfs.Mount) {
+	name := *c.timezone
+	if name == "" {
+		name = platform.Timezone()
+	}
+
+	for _, kv := range [][2]string{{"TZ", name}, {"ZONEINFO", zoneinfoGuestPath}} {
+		prefix := kv[0] + "="
+		exists := false
+		for _, e := range environ {
+			if strings.HasPrefix(string(e), prefix) {
+				exists = true // e.g. set by WithEnv
+				break
+			}
+		}
+		if !exists {
+			environ = append(environ, []byte(prefix+kv[1]))
+		}
+	}
+
+	dir := platform.ZoneinfoDir()
+	if dir == "" {
+		return environ, nil
+	}
+	return environ, &sysfs.Mount{GuestPath: zoneinfoGuestPath, FS: sysfs.NewDirFS(dir), ReadOnly: true}
+}
+
+// hostEnviron returns the "key=value" entries of the host environment allowed
+// by WithHostEnv, except those overridden by WithEnv or with invalid keys.
+func (c *moduleConfig) hostEnviron() (environ [][]byte) {
+	if len(c.hostEnvAllowList) == 0 {
+		return
+	}
+	for _, kv := range os.Environ() {
+		i := strings.IndexByte(kv, '=')
+		if i <= 0 { // e.g. "=C:=C:\\" on Windows
+			continue
+		}
+		key := kv[:i]
+		if _, ok := c.environKeys[key]; ok {
+			continue // WithEnv takes precedence.
+		}
+		if hostEnvAllowed(c.hostEnvAllowList, key) {
+			environ = append(environ, []byte(kv))
+		}
+	}
+	return
+}
+
+// hostEnvAllowed returns true if the key matches an entry in allowList, where
+// an entry ending in '*' matches keys with that prefix.
+func hostEnvAllowed(allowList []string, key string) bool {
+	for _, allowed := range allowList {
+		if prefix := strings.TrimSuffix(allowed, "*"); prefix != allowed {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		} else if allowed == key {
+			return true
+		}
+	}
+	return false
+}
+
+// openSockets listens on the sockets configured by WithTCPListener and
+// WithUDPListener, then opens any experimental.Sockets in the context. These
+// are opened as file descriptors in that order.
+func (c *moduleConfig) openSockets(ctx context.Context, fsc *internalsys.FSContext) error {
+	for _, lc := range c.listeners {
+		var err error
+		switch lc.network {
+		case "tcp":
+			var l net.Listener
+			if l, err = net.Listen(lc.network, lc.address); err == nil {
+				if _, err = fsc.OpenListener(l); err != nil {
+					_ = l.Close()
+				}
+			}
+		case "udp":
+			var pc net.PacketConn
+			if pc, err = net.ListenPacket(lc.network, lc.address); err == nil {
+				if _, err = fsc.OpenConn(pc.(*net.UDPConn)); err != nil {
+					_ = pc.Close()
+				}
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("%s listener %s: %w", lc.network, lc.address, err)
+		}
+	}
+
+	sockets, ok := ctx.Value(experimental.SocketsKey{}).(experimental.Sockets)
+	if !ok {
+		return nil
+	}
+	for _, l := range sockets.Listeners {
+		if _, err := fsc.OpenListener(l); err != nil {
+			return fmt.Errorf("failed to open listener %s: %w", l.Addr(), err)
+		}
+	}
+	for _, conn := range sockets.Conns {
+		if _, err := fsc.OpenConn(conn); err != nil {
+			return fmt.Errorf("failed to open conn %s: %w", conn.LocalAddr(), err)
+		}
+	}
+	return nil
+}
+
+// openInheritedFiles opens the files configured by WithInheritedFile in the
+// context, as file descriptors in that order.
+func (c *moduleConfig) openInheritedFiles(fsc *internalsys.FSContext) error {
+	for _, f := range c.inheritedFiles {
+		if _, err := fsc.OpenHostFile(f); err != nil {
+			return fmt.Errorf("failed to open inherited file %s: %w", f.Name(), err)
+		}
+	}
+	return nil
 }
@@ -6,10 +6,17 @@ import (
 	"io"
 	"io/fs"
 	"math"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"syscall"
 	"testing"
 	"testing/fstest"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/platform"
 	internalsys "github.com/tetratelabs/wazero/internal/sys"
 	testfs "github.com/tetratelabs/wazero/internal/testing/fs"
 	"github.com/tetratelabs/wazero/internal/testing/require"
@@ -59,6 +66,78 @@ func TestRuntimeConfig(t *testing.T) {
 				dwarfDisabled: true, // dwarf is a more technical name and ok here.
 			},
 		},
+		{
+			name: "WithCloseOnContextDone",
+			with: func(c RuntimeConfig) RuntimeConfig {
+				return c.WithCloseOnContextDone(true)
+			},
+			expected: &runtimeConfig{
+				ensureTermination: true,
+			},
+		},
+		{
+			name: "WithCPUTimeAccounting",
+			with: func(c RuntimeConfig) RuntimeConfig {
+				return c.WithCPUTimeAccounting(true)
+			},
+			expected: &runtimeConfig{
+				cpuTimeAccounting: true,
+			},
+		},
+		{
+			name: "WithLeakPolicy",
+			with: func(c RuntimeConfig) RuntimeConfig {
+				return c.WithLeakPolicy(LeakPolicyError)
+			},
+			expected: &runtimeConfig{
+				leakPolicy: LeakPolicyError,
+			},
+		},
+		{
+			name: "WithLeakStackTraces",
+			with: func(c RuntimeConfig) RuntimeConfig {
+				return c.WithLeakStackTraces(true)
+			},
+			expected: &runtimeConfig{
+				leakStackTraces: true,
+			},
+		},
+		{
+			name: "WithFunctionListenerFactory",
+			with: func(c RuntimeConfig) RuntimeConfig {
+				return c.WithFunctionListenerFactory(&namesListenerFactory{})
+			},
+			expected: &runtimeConfig{
+				listenerFactory: &namesListenerFactory{},
+			},
+		},
+		{
+			name: "WithMemoryListener",
+			with: func(c RuntimeConfig) RuntimeConfig {
+				return c.WithMemoryListener(noopMemoryListener{})
+			},
+			expected: &runtimeConfig{
+				memoryListener: noopMemoryListener{},
+			},
+		},
+		{
+			name: "WithMemoryProtection",
+			with: func(c RuntimeConfig) RuntimeConfig {
+				return c.WithMemoryProtection(true)
+			},
+			expected: &runtimeConfig{
+				memoryProtection: true,
+			},
+		},
+		{
+			name: "WithHostFunctionPanicPolicy",
+			with: func(c RuntimeConfig) RuntimeConfig {
+				return c.WithHostFunctionPanicPolicy(HostFunctionPanicPolicyRepanic)
+			},
+			expected: &runtimeConfig{
+				panicPolicy: HostFunctionPanicPolicyRepanic,
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -132,11 +211,16 @@ func TestModuleConfig_toSysContext(t *testing.T) {
 	var nt sys.Nanotime = func() int64 {
 		return 0
 	}
+	var ct sys.Cputime = func() int64 {
+		return 0
+	}
 	base := NewModuleConfig()
 	base.(*moduleConfig).walltime = &wt
 	base.(*moduleConfig).walltimeResolution = 1
 	base.(*moduleConfig).nanotime = &nt
 	base.(*moduleConfig).nanotimeResolution = 1
+	base.(*moduleConfig).cputime = &ct
+	base.(*moduleConfig).cputimeResolution = 1
 
 	testFS := testfs.FS{}
 	testFS2 := testfs.FS{"/": &testfs.File{}}
@@ -150,15 +234,16 @@ func TestModuleConfig_toSysContext(t *testing.T) {
 			name:  "empty",
 			input: base,
 			expected: requireSysContext(t,
-				math.MaxUint32, // max
-				nil,            // args
-				nil,            // environ
-				nil,            // stdin
-				nil,            // stdout
-				nil,            // stderr
-				nil,            // randSource
-				&wt, 1,         // walltime, walltimeResolution
+				math.MaxUint32, math.MaxUint32, // maxArgsSize, maxEnvironSize
+				nil,    // args
+				nil,    // environ
+				nil,    // stdin
+				nil,    // stdout
+				nil,    // stderr
+				nil,    // randSource
+				&wt, 1, // walltime, walltimeResolution
 				&nt, 1, // nanotime, nanotimeResolution
+				&ct, 1, // cputime, cputimeResolution
 				nil, // nanosleep
 				nil, // fs
 			),
@@ -167,7 +252,7 @@ func TestModuleConfig_toSysContext(t *testing.T) {
 			name:  "WithArgs",
 			input: base.WithArgs("a", "bc"),
 			expected: requireSysContext(t,
-				math.MaxUint32,      // max
+				math.MaxUint32, math.MaxUint32, // maxArgsSize, maxEnvironSize
 				[]string{"a", "bc"}, // args
 				nil,                 // environ
 				nil,                 // stdin
@@ -176,6 +261,7 @@ func TestModuleConfig_toSysContext(t *testing.T) {
 				nil,                 // randSource
 				&wt, 1,              // walltime, walltimeResolution
 				&nt, 1, // nanotime, nanotimeResolution
+				&ct, 1, // cputime, cputimeResolution
 				nil, // nanosleep
 				nil, // fs
 			),
@@ -184,7 +270,7 @@ func TestModuleConfig_toSysContext(t *testing.T) {
 			name:  "WithArgs empty ok", // Particularly argv[0] can be empty, and we have no rules about others.
 			input: base.WithArgs("", "bc"),
 			expected: requireSysContext(t,
-				math.MaxUint32,     // max
+				math.MaxUint32, math.MaxUint32, // maxArgsSize, maxEnvironSize
 				[]string{"", "bc"}, // args
 				nil,                // environ
 				nil,                // stdin
@@ -193,6 +279,7 @@ func TestModuleConfig_toSysContext(t *testing.T) {
 				nil,                // randSource
 				&wt, 1,             // walltime, walltimeResolution
 				&nt, 1, // nanotime, nanotimeResolution
+				&ct, 1, // cputime, cputimeResolution
 				nil, // nanosleep
 				nil, // fs
 			),
@@ -201,7 +288,7 @@ func TestModuleConfig_toSysContext(t *testing.T) {
 			name:  "WithArgs second call overwrites",
 			input: base.WithArgs("a", "bc").WithArgs("bc", "a"),
 			expected: requireSysContext(t,
-				math.MaxUint32,      // max
+				math.MaxUint32, math.MaxUint32, // maxArgsSize, maxEnvironSize
 				[]string{"bc", "a"}, // args
 				nil,                 // environ
 				nil,                 // stdin
@@ -210,6 +297,7 @@ func TestModuleConfig_toSysContext(t *testing.T) {
 				nil,                 // randSource
 				&wt, 1,              // walltime, walltimeResolution
 				&nt, 1, // nanotime, nanotimeResolution
+				&ct, 1, // cputime, cputimeResolution
 				nil, // nanosleep
 				nil, // fs
 			),
@@ -218,7 +306,7 @@ func TestModuleConfig_toSysContext(t *testing.T) {
 			name:  "WithEnv",
 			input: base.WithEnv("a", "b"),
 			expected: requireSysContext(t,
-				math.MaxUint32,  // max
+				math.MaxUint32, math.MaxUint32, // maxArgsSize, maxEnvironSize
 				nil,             // args
 				[]string{"a=b"}, // environ
 				nil,             // stdin
@@ -227,6 +315,7 @@ func TestModuleConfig_toSysContext(t *testing.T) {
 				nil,             // randSource
 				&wt, 1,          // walltime, walltimeResolution
 				&nt, 1, // nanotime, nanotimeResolution
+				&ct, 1, // cputime, cputimeResolution
 				nil, // nanosleep
 				nil, // fs
 			),
@@ -235,7 +324,7 @@ func TestModuleConfig_toSysContext(t *testing.T) {
 			name:  "WithEnv empty value",
 			input: base.WithEnv("a", ""),
 			expected: requireSysContext(t,
-				math.MaxUint32, // max
+				math.MaxUint32, math.MaxUint32, // maxArgsSize, maxEnvironSize
 				nil,            // args
 				[]string{"a="}, // environ
 				nil,            // stdin
@@ -244,6 +333,7 @@ func TestModuleConfig_toSysContext(t *testing.T) {
 				nil,            // randSource
 				&wt, 1,         // walltime, walltimeResolution
 				&nt, 1, // nanotime, nanotimeResolution
+				&ct, 1, // cputime, cputimeResolution
 				nil, // nanosleep
 				nil, // fs
 			),
@@ -252,7 +342,7 @@ func TestModuleConfig_toSysContext(t *testing.T) {
 			name:  "WithEnv twice",
 			input: base.WithEnv("a", "b").WithEnv("c", "de"),
 			expected: requireSysContext(t,
-				math.MaxUint32,          // max
+				math.MaxUint32, math.MaxUint32, // maxArgsSize, maxEnvironSize
 				nil,                     // args
 				[]string{"a=b", "c=de"}, // environ
 				nil,                     // stdin
@@ -261,6 +351,7 @@ func TestModuleConfig_toSysContext(t *testing.T) {
 				nil,                     // randSource
 				&wt, 1,                  // walltime, walltimeResolution
 				&nt, 1, // nanotime, nanotimeResolution
+				&ct, 1, // cputime, cputimeResolution
 				nil, // nanosleep
 				nil, // fs
 			),
@@ -269,7 +360,7 @@ func TestModuleConfig_toSysContext(t *testing.T) {
 			name:  "WithEnv overwrites",
 			input: base.WithEnv("a", "bc").WithEnv("c", "de").WithEnv("a", "de"),
 			expected: requireSysContext(t,
-				math.MaxUint32,           // max
+				math.MaxUint32, math.MaxUint32, // maxArgsSize, maxEnvironSize
 				nil,                      // args
 				[]string{"a=de", "c=de"}, // environ
 				nil,                      // stdin
@@ -278,6 +369,7 @@ func TestModuleConfig_toSysContext(t *testing.T) {
 				nil,                      // randSource
 				&wt, 1,                   // walltime, walltimeResolution
 				&nt, 1, // nanotime, nanotimeResolution
+				&ct, 1, // cputime, cputimeResolution
 				nil, // nanosleep
 				nil, // fs
 			),
@@ -286,7 +378,7 @@ func TestModuleConfig_toSysContext(t *testing.T) {
 			name:  "WithEnv twice",
 			input: base.WithEnv("a", "b").WithEnv("c", "de"),
 			expected: requireSysContext(t,
-				math.MaxUint32,          // max
+				math.MaxUint32, math.MaxUint32, // maxArgsSize, maxEnvironSize
 				nil,                     // args
 				[]string{"a=b", "c=de"}, // environ
 				nil,                     // stdin
@@ -295,6 +387,7 @@ func TestModuleConfig_toSysContext(t *testing.T) {
 				nil,                     // randSource
 				&wt, 1,                  // walltime, walltimeResolution
 				&nt, 1, // nanotime, nanotimeResolution
+				&ct, 1, // cputime, cputimeResolution
 				nil, // nanosleep
 				nil, // fs
 			),
@@ -303,15 +396,16 @@ func TestModuleConfig_toSysContext(t *testing.T) {
 			name:  "WithFS",
 			input: base.WithFS(testFS),
 			expected: requireSysContext(t,
-				math.MaxUint32, // max
-				nil,            // args
-				nil,            // environ
-				nil,            // stdin
-				nil,            // stdout
-				nil,            // stderr
-				nil,            // randSource
-				&wt, 1,         // walltime, walltimeResolution
+				math.MaxUint32, math.MaxUint32, // maxArgsSize, maxEnvironSize
+				nil,    // args
+				nil,    // environ
+				nil,    // stdin
+				nil,    // stdout
+				nil,    // stderr
+				nil,    // randSource
+				&wt, 1, // walltime, walltimeResolution
 				&nt, 1, // nanotime, nanotimeResolution
+				&ct, 1, // cputime, cputimeResolution
 				nil, // nanosleep
 				testFS,
 			),
@@ -320,32 +414,142 @@ func TestModuleConfig_toSysContext(t *testing.T) {
 			name:  "WithFS overwrites",
 			input: base.WithFS(testFS).WithFS(testFS2),
 			expected: requireSysContext(t,
-				math.MaxUint32, // max
-				nil,            // args
-				nil,            // environ
-				nil,            // stdin
-				nil,            // stdout
-				nil,            // stderr
-				nil,            // randSource
-				&wt, 1,         // walltime, walltimeResolution
+				math.MaxUint32, math.MaxUint32, // maxArgsSize, maxEnvironSize
+				nil,    // args
+				nil,    // environ
+				nil,    // stdin
+				nil,    // stdout
+				nil,    // stderr
+				nil,    // randSource
+				&wt, 1, // walltime, walltimeResolution
+				&nt, 1, // nanotime, nanotimeResolution
+				&ct, 1, // cputime, cputimeResolution
+				nil,     // nanosleep
+				testFS2, // fs
+			),
+		},
+		{
+			name:  "WithFSConfig",
+			input: base.WithFSConfig(NewFSConfig().WithFSMount(testFS, "/")),
+			expected: requireSysContext(t,
+				math.MaxUint32, math.MaxUint32, // maxArgsSize, maxEnvironSize
+				nil,    // args
+				nil,    // environ
+				nil,    // stdin
+				nil,    // stdout
+				nil,    // stderr
+				nil,    // randSource
+				&wt, 1, // walltime, walltimeResolution
+				&nt, 1, // nanotime, nanotimeResolution
+				&ct, 1, // cputime, cputimeResolution
+				nil,    // nanosleep
+				testFS, // fs
+			),
+		},
+		{
+			name:  "WithFSConfig overwrites WithFS",
+			input: base.WithFS(testFS).WithFSConfig(NewFSConfig().WithFSMount(testFS2, "/")),
+			expected: requireSysContext(t,
+				math.MaxUint32, math.MaxUint32, // maxArgsSize, maxEnvironSize
+				nil,    // args
+				nil,    // environ
+				nil,    // stdin
+				nil,    // stdout
+				nil,    // stderr
+				nil,    // randSource
+				&wt, 1, // walltime, walltimeResolution
+				&nt, 1, // nanotime, nanotimeResolution
+				&ct, 1, // cputime, cputimeResolution
+				nil,     // nanosleep
+				testFS2, // fs
+			),
+		},
+		{
+			name:  "WithFS overwrites WithFSConfig",
+			input: base.WithFSConfig(NewFSConfig().WithFSMount(testFS, "/")).WithFS(testFS2),
+			expected: requireSysContext(t,
+				math.MaxUint32, math.MaxUint32, // maxArgsSize, maxEnvironSize
+				nil,    // args
+				nil,    // environ
+				nil,    // stdin
+				nil,    // stdout
+				nil,    // stderr
+				nil,    // randSource
+				&wt, 1, // walltime, walltimeResolution
 				&nt, 1, // nanotime, nanotimeResolution
+				&ct, 1, // cputime, cputimeResolution
 				nil,     // nanosleep
 				testFS2, // fs
 			),
 		},
+		{
+			name:  "WithFSConfig empty",
+			input: base.WithFSConfig(NewFSConfig()),
+			expected: requireSysContext(t,
+				math.MaxUint32, math.MaxUint32, // maxArgsSize, maxEnvironSize
+				nil,    // args
+				nil,    // environ
+				nil,    // stdin
+				nil,    // stdout
+				nil,    // stderr
+				nil,    // randSource
+				&wt, 1, // walltime, walltimeResolution
+				&nt, 1, // nanotime, nanotimeResolution
+				&ct, 1, // cputime, cputimeResolution
+				nil, // nanosleep
+				nil, // fs
+			),
+		},
 		{
 			name:  "WithRandSource",
 			input: base.WithRandSource(rand.Reader),
 			expected: requireSysContext(t,
-				math.MaxUint32, // max
-				nil,            // args
-				nil,            // environ
-				nil,            // stdin
-				nil,            // stdout
-				nil,            // stderr
-				rand.Reader,    // randSource
-				&wt, 1,         // walltime, walltimeResolution
+				math.MaxUint32, math.MaxUint32, // maxArgsSize, maxEnvironSize
+				nil,         // args
+				nil,         // environ
+				nil,         // stdin
+				nil,         // stdout
+				nil,         // stderr
+				rand.Reader, // randSource
+				&wt, 1,      // walltime, walltimeResolution
+				&nt, 1, // nanotime, nanotimeResolution
+				&ct, 1, // cputime, cputimeResolution
+				nil, // nanosleep
+				nil, // fs
+			),
+		},
+		{
+			name:  "WithRandSource overwrites WithRandSeed",
+			input: base.WithRandSeed(1).WithRandSource(rand.Reader),
+			expected: requireSysContext(t,
+				math.MaxUint32, math.MaxUint32, // maxArgsSize, maxEnvironSize
+				nil,         // args
+				nil,         // environ
+				nil,         // stdin
+				nil,         // stdout
+				nil,         // stderr
+				rand.Reader, // randSource
+				&wt, 1,      // walltime, walltimeResolution
 				&nt, 1, // nanotime, nanotimeResolution
+				&ct, 1, // cputime, cputimeResolution
+				nil, // nanosleep
+				nil, // fs
+			),
+		},
+		{
+			name:  "WithoutRandSource",
+			input: base.WithRandSeed(1).WithoutRandSource(),
+			expected: requireSysContext(t,
+				math.MaxUint32, math.MaxUint32, // maxArgsSize, maxEnvironSize
+				nil,                   // args
+				nil,                   // environ
+				nil,                   // stdin
+				nil,                   // stdout
+				nil,                   // stderr
+				platform.NoRandSource, // randSource
+				&wt, 1,                // walltime, walltimeResolution
+				&nt, 1, // nanotime, nanotimeResolution
+				&ct, 1, // cputime, cputimeResolution
 				nil, // nanosleep
 				nil, // fs
 			),
@@ -365,6 +569,156 @@ func TestModuleConfig_toSysContext(t *testing.T) {
 
 // TestModuleConfig_toSysContext_WithWalltime has to test differently because we can't
 // compare function pointers when functions are passed by value.
+func TestModuleConfig_toSysContext_NonUTF8(t *testing.T) {
+	arg := string([]byte{0xff, 0xfe, 'a'})
+	value := string([]byte{0xc0, 0x80})
+
+	sysCtx, err := NewModuleConfig().WithArgs(arg).WithEnv("a", value).(*moduleConfig).toSysContext()
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte(arg)}, sysCtx.Args())
+	require.Equal(t, [][]byte{[]byte("a=" + value)}, sysCtx.Environ())
+}
+
+func TestModuleConfig_toSysContext_WithMaxSize(t *testing.T) {
+	// "a\x00bc\x00" is exactly 5 bytes, so a limit of 5 is inclusive.
+	sysCtx, err := NewModuleConfig().WithArgs("a", "bc").WithMaxArgsSize(5).
+		WithEnv("a", "bc").WithMaxEnvSize(5).(*moduleConfig).toSysContext()
+	require.NoError(t, err)
+	require.Equal(t, uint32(5), sysCtx.ArgsSize())
+	require.Equal(t, uint32(5), sysCtx.EnvironSize())
+
+	_, err = NewModuleConfig().WithArgs("a", "bc").WithMaxArgsSize(4).(*moduleConfig).toSysContext()
+	require.ErrorIs(t, err, syscall.E2BIG)
+}
+
+func TestModuleConfig_toSysContext_WithHostEnv(t *testing.T) {
+	t.Setenv("WAZERO_TEST_A", "a")
+	t.Setenv("WAZERO_TEST_B", "b")
+	t.Setenv("WAZERO_OTHER", "c")
+
+	tests := []struct {
+		name     string
+		input    ModuleConfig
+		expected [][]byte
+	}{
+		{
+			name:  "none",
+			input: NewModuleConfig(),
+		},
+		{
+			name:     "exact",
+			input:    NewModuleConfig().WithHostEnv("WAZERO_TEST_A"),
+			expected: [][]byte{[]byte("WAZERO_TEST_A=a")},
+		},
+		{
+			name:     "prefix",
+			input:    NewModuleConfig().WithHostEnv("WAZERO_TEST_*"),
+			expected: [][]byte{[]byte("WAZERO_TEST_A=a"), []byte("WAZERO_TEST_B=b")},
+		},
+		{
+			name:     "WithEnv takes precedence",
+			input:    NewModuleConfig().WithHostEnv("WAZERO_TEST_*").WithEnv("WAZERO_TEST_A", "z"),
+			expected: [][]byte{[]byte("WAZERO_TEST_B=b"), []byte("WAZERO_TEST_A=z")},
+		},
+		{
+			name:     "WithMaxEnvSize includes host",
+			input:    NewModuleConfig().WithHostEnv("WAZERO_OTHER").WithMaxEnvSize(uint32(len("WAZERO_OTHER=c\x00"))),
+			expected: [][]byte{[]byte("WAZERO_OTHER=c")},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			sysCtx, err := tc.input.(*moduleConfig).toSysContext()
+			require.NoError(t, err)
+
+			// Filter, as the host may have other variables.
+			var actual [][]byte
+			for _, kv := range sysCtx.Environ() {
+				if strings.HasPrefix(string(kv), "WAZERO_") {
+					actual = append(actual, kv)
+				}
+			}
+			require.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestModuleConfig_toSysContext_WithTimezone(t *testing.T) {
+	zoneinfo := t.TempDir()
+	require.NoError(t, os.Mkdir(path.Join(zoneinfo, "Asia"), 0o700))
+	require.NoError(t, os.WriteFile(path.Join(zoneinfo, "Asia", "Tokyo"), []byte("TZif"), 0o600))
+	t.Setenv("ZONEINFO", zoneinfo)
+	t.Setenv("TZ", "Asia/Tokyo")
+
+	tests := []struct {
+		name     string
+		input    ModuleConfig
+		expected [][]byte
+	}{
+		{
+			name:  "none",
+			input: NewModuleConfig(),
+		},
+		{
+			name:     "host",
+			input:    NewModuleConfig().WithTimezone(""),
+			expected: [][]byte{[]byte("TZ=Asia/Tokyo"), []byte("ZONEINFO=/usr/share/zoneinfo")},
+		},
+		{
+			name:     "name",
+			input:    NewModuleConfig().WithTimezone("Europe/Paris"),
+			expected: [][]byte{[]byte("TZ=Europe/Paris"), []byte("ZONEINFO=/usr/share/zoneinfo")},
+		},
+		{
+			name:     "WithEnv takes precedence",
+			input:    NewModuleConfig().WithEnv("TZ", "UTC").WithTimezone("Europe/Paris"),
+			expected: [][]byte{[]byte("TZ=UTC"), []byte("ZONEINFO=/usr/share/zoneinfo")},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			sysCtx, err := tc.input.(*moduleConfig).toSysContext()
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, sysCtx.Environ())
+		})
+	}
+
+	t.Run("mounts zoneinfo", func(t *testing.T) {
+		testFS := fstest.MapFS{"index.html": {Data: []byte("wazero")}}
+
+		for _, config := range []ModuleConfig{
+			NewModuleConfig().WithTimezone(""),
+			NewModuleConfig().WithFS(testFS).WithTimezone(""),
+			NewModuleConfig().WithFSConfig(NewFSConfig().WithFSMount(testFS, "/")).WithTimezone(""),
+		} {
+			sysCtx, err := config.(*moduleConfig).toSysContext()
+			require.NoError(t, err)
+			fsc := sysCtx.FS()
+
+			fd, err := fsc.OpenFile("/usr/share/zoneinfo/Asia/Tokyo", os.O_RDONLY, 0)
+			require.NoError(t, err)
+			b, err := io.ReadAll(fsc.FdReader(fd))
+			require.NoError(t, err)
+			require.Equal(t, "TZif", string(b))
+
+			// The mount is read-only.
+			_, err = fsc.OpenFile("/usr/share/zoneinfo/Asia/Tokyo", os.O_RDWR, 0)
+			require.ErrorIs(t, err, syscall.EROFS)
+
+			if config.(*moduleConfig).fs != nil || config.(*moduleConfig).fsConfig != nil {
+				_, err = fsc.OpenFile("/index.html", os.O_RDONLY, 0)
+				require.NoError(t, err)
+			}
+		}
+	})
+}
+
 func TestModuleConfig_toSysContext_WithWalltime(t *testing.T) {
 	tests := []struct {
 		name               string
@@ -495,6 +849,122 @@ func TestModuleConfig_toSysContext_WithNanotime(t *testing.T) {
 	}
 }
 
+// TestModuleConfig_toSysContext_WithCputime has to test differently because we can't
+// compare function pointers when functions are passed by value.
+func TestModuleConfig_toSysContext_WithCputime(t *testing.T) {
+	tests := []struct {
+		name               string
+		input              ModuleConfig
+		expectedNanos      int64
+		expectedResolution sys.ClockResolution
+		expectedErr        string
+	}{
+		{
+			name: "ok",
+			input: NewModuleConfig().
+				WithCputime(func() int64 {
+					return 1
+				}, 2),
+			expectedNanos:      1,
+			expectedResolution: 2,
+		},
+		{
+			name: "overwrites",
+			input: NewModuleConfig().
+				WithCputime(func() int64 {
+					return 3
+				}, 4).
+				WithCputime(func() int64 {
+					return 1
+				}, 2),
+			expectedNanos:      1,
+			expectedResolution: 2,
+		},
+		{
+			name: "invalid resolution",
+			input: NewModuleConfig().
+				WithCputime(func() int64 {
+					return 1
+				}, 0),
+			expectedErr: "invalid Cputime resolution: 0",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			sysCtx, err := tc.input.(*moduleConfig).toSysContext()
+			if tc.expectedErr == "" {
+				require.Nil(t, err)
+				nanos := sysCtx.Cputime()
+				require.Equal(t, tc.expectedNanos, nanos)
+				require.Equal(t, tc.expectedResolution, sysCtx.CputimeResolution())
+			} else {
+				require.EqualError(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
+
+func TestModuleConfig_toSysContext_WithSysCputime(t *testing.T) {
+	sysCtx, err := NewModuleConfig().WithSysCputime().(*moduleConfig).toSysContext()
+	require.NoError(t, err)
+	require.True(t, sysCtx.ModuleCputime())
+	require.Equal(t, sys.ClockResolution(1000), sysCtx.CputimeResolution())
+
+	// WithCputime overrides it.
+	sysCtx, err = NewModuleConfig().WithSysCputime().
+		WithCputime(func() int64 {
+			return 1
+		}, 2).(*moduleConfig).toSysContext()
+	require.NoError(t, err)
+	require.False(t, sysCtx.ModuleCputime())
+	require.Equal(t, int64(1), sysCtx.Cputime())
+}
+
+// TestModuleConfig_toSysContext_WithRandSeed has to test differently because
+// the source is created on each call to toSysContext.
+func TestModuleConfig_toSysContext_WithRandSeed(t *testing.T) {
+	expected := make([]byte, 8)
+	_, err := io.ReadFull(platform.NewSeededRandSource(7), expected)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		input ModuleConfig
+	}{
+		{
+			name:  "ok",
+			input: NewModuleConfig().WithRandSeed(7),
+		},
+		{
+			name:  "overwrites WithRandSource",
+			input: NewModuleConfig().WithRandSource(rand.Reader).WithRandSeed(7),
+		},
+		{
+			name:  "overwrites WithRandSeed",
+			input: NewModuleConfig().WithRandSeed(1).WithRandSeed(7),
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			// Each context reads the same bytes from a new source.
+			for i := 0; i < 2; i++ {
+				sysCtx, err := tc.input.(*moduleConfig).toSysContext()
+				require.NoError(t, err)
+				actual := make([]byte, 8)
+				_, err = io.ReadFull(sysCtx.RandSource(), actual)
+				require.NoError(t, err)
+				require.Equal(t, expected, actual)
+			}
+		})
+	}
+}
+
 // TestModuleConfig_toSysContext_WithNanosleep has to test differently because
 // we can't compare function pointers when functions are passed by value.
 func TestModuleConfig_toSysContext_WithNanosleep(t *testing.T) {
@@ -537,6 +1007,21 @@ func TestModuleConfig_toSysContext_Errors(t *testing.T) {
 			input:       NewModuleConfig().WithEnv("", "a"),
 			expectedErr: "environ invalid: empty key",
 		},
+		{
+			name:        "WithMaxArgsSize exceeded",
+			input:       NewModuleConfig().WithArgs("a", "bc").WithMaxArgsSize(4),
+			expectedErr: "args invalid: exceeds maximum size: argument list too long",
+		},
+		{
+			name:        "WithMaxEnvSize exceeded",
+			input:       NewModuleConfig().WithEnv("a", "bc").WithMaxEnvSize(4),
+			expectedErr: "environ invalid: exceeds maximum size: argument list too long",
+		},
+		{
+			name:        "WithFSConfig invalid guest path",
+			input:       NewModuleConfig().WithFSConfig(NewFSConfig().WithDirMount(".", "/../tmp")),
+			expectedErr: "invalid guest path: /../tmp",
+		},
 	}
 	for _, tt := range tests {
 		tc := tt
@@ -548,6 +1033,99 @@ func TestModuleConfig_toSysContext_Errors(t *testing.T) {
 	}
 }
 
+func TestModuleConfig_openSockets(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	base := NewModuleConfig().WithTCPListener("127.0.0.1:0")
+	config := base.WithUDPListener("127.0.0.1:0").(*moduleConfig)
+	// The receiver wasn't modified.
+	require.Equal(t, 1, len(base.(*moduleConfig).listeners))
+
+	sysCtx, err := config.toSysContext()
+	require.NoError(t, err)
+	fsc := sysCtx.FS()
+	defer fsc.Close(testCtx)
+
+	ctx := context.WithValue(testCtx, experimental.SocketsKey{}, experimental.Sockets{Conns: []net.Conn{server}})
+	require.NoError(t, config.openSockets(ctx, fsc))
+
+	// Sockets are opened after stdio, in the order configured.
+	l, err := fsc.Listener(3)
+	require.NoError(t, err)
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	udp, err := fsc.Conn(4)
+	require.NoError(t, err)
+	require.Equal(t, "udp", udp.LocalAddr().Network())
+
+	conn, err = fsc.Conn(5)
+	require.NoError(t, err)
+	require.Equal(t, server, conn)
+}
+
+func TestModuleConfig_openSockets_Errors(t *testing.T) {
+	config := NewModuleConfig().WithTCPListener("127.0.0.1:-1").(*moduleConfig)
+
+	sysCtx, err := config.toSysContext()
+	require.NoError(t, err)
+	fsc := sysCtx.FS()
+	defer fsc.Close(testCtx)
+
+	err = config.openSockets(testCtx, fsc)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "tcp listener 127.0.0.1:-1: ")
+}
+
+func TestModuleConfig_openInheritedFiles(t *testing.T) {
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer r.Close()
+	defer w.Close()
+
+	base := NewModuleConfig().WithInheritedFile(r)
+	config := base.WithInheritedFile(w).(*moduleConfig)
+	// The receiver wasn't modified.
+	require.Equal(t, 1, len(base.(*moduleConfig).inheritedFiles))
+
+	sysCtx, err := config.toSysContext()
+	require.NoError(t, err)
+	fsc := sysCtx.FS()
+	require.NoError(t, config.openInheritedFiles(fsc))
+
+	// Files are opened after stdio, in the order configured.
+	_, err = fsc.FdWriter(4).Write([]byte("wazero"))
+	require.NoError(t, err)
+	buf := make([]byte, 6)
+	_, err = io.ReadFull(fsc.FdReader(3), buf)
+	require.NoError(t, err)
+	require.Equal(t, "wazero", string(buf))
+
+	// The files are still open after the context is closed.
+	require.NoError(t, fsc.Close(testCtx))
+	_, err = w.Write([]byte("!"))
+	require.NoError(t, err)
+}
+
+func TestModuleConfig_openInheritedFiles_Errors(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "closed")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	config := NewModuleConfig().WithInheritedFile(f).(*moduleConfig)
+
+	sysCtx, err := config.toSysContext()
+	require.NoError(t, err)
+	fsc := sysCtx.FS()
+	defer fsc.Close(testCtx)
+
+	err = config.openInheritedFiles(fsc)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to open inherited file "+f.Name()+": ")
+}
+
 func TestModuleConfig_clone(t *testing.T) {
 	mc := NewModuleConfig().(*moduleConfig)
 	cloned := mc.clone()
@@ -603,7 +1181,7 @@ func Test_compiledModule_Close(t *testing.T) {
 		var cs []*compiledModule
 		for i := 0; i < 10; i++ {
 			m := &wasm.Module{}
-			err := e.CompileModule(ctx, m, nil)
+			err := e.CompileModule(ctx, m, nil, false)
 			require.NoError(t, err)
 			cs = append(cs, &compiledModule{module: m, compiledEngine: e})
 		}
@@ -623,18 +1201,19 @@ func Test_compiledModule_Close(t *testing.T) {
 // requireSysContext ensures wasm.NewContext doesn't return an error, which makes it usable in test matrices.
 func requireSysContext(
 	t *testing.T,
-	max uint32,
+	maxArgsSize, maxEnvironSize uint32,
 	args, environ []string,
 	stdin io.Reader,
 	stdout, stderr io.Writer,
 	randSource io.Reader,
 	walltime *sys.Walltime, walltimeResolution sys.ClockResolution,
 	nanotime *sys.Nanotime, nanotimeResolution sys.ClockResolution,
+	cputime *sys.Cputime, cputimeResolution sys.ClockResolution,
 	nanosleep *sys.Nanosleep,
 	fs fs.FS,
 ) *internalsys.Context {
 	sysCtx, err := internalsys.NewContext(
-		max,
+		maxArgsSize, maxEnvironSize,
 		toByteSlices(args),
 		toByteSlices(environ),
 		stdin,
@@ -643,9 +1222,18 @@ func requireSysContext(
 		randSource,
 		walltime, walltimeResolution,
 		nanotime, nanotimeResolution,
+		cputime, cputimeResolution,
 		nanosleep,
 		fs,
 	)
 	require.NoError(t, err)
 	return sysCtx
 }
+
+// noopMemoryListener implements experimental.MemoryListener
+type noopMemoryListener struct{}
+
+func (noopMemoryListener) WatchRegions() []experimental.WatchRegion { return nil }
+
+func (noopMemoryListener) OnMemoryAccess(context.Context, api.Module, api.FunctionDefinition, experimental.MemoryAccess) {
+}
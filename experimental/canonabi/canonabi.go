@@ -0,0 +1,601 @@
+// Package canonabi lifts and lowers values with the canonical ABI of the
+// component model, in the memory of a module, so that hosts can exchange
+// strings, lists, records, variants and options with guests compiled for it
+// before wazero runs components. For example, to call a guest export with the
+// WIT signature "greet: func(name: string) -> string":
+//
+//	greet := canonabi.Func{Params: []*canonabi.Type{canonabi.String}, Result: canonabi.String}
+//	greeting, err := canonabi.New(mod).Call(ctx, "greet", greet, "wazero")
+//
+// Memory is allocated with the function "cabi_realloc" the module exports,
+// and strings are encoded in UTF-8.
+//
+// See https://github.com/WebAssembly/component-model/blob/main/design/mvp/CanonicalABI.md
+//
+// Note: This package is experimental, so may be changed or deleted at any time.
+package canonabi
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"unicode/utf8"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/abi"
+)
+
+// ReallocName is the name of the function a module exports to allocate
+// memory, with the signature (old_ptr, old_size, align, new_size i32) -> i32.
+const ReallocName = "cabi_realloc"
+
+// Case is a value of a variant, including options and results.
+type Case struct {
+	// Index is the index of the case.
+	Index uint32
+	// Value is the value of the case, or nil if it has none.
+	Value interface{}
+}
+
+// ABI lifts and lowers values in the memory of a module.
+type ABI struct {
+	mod     api.Module
+	realloc api.Function
+}
+
+// New returns an ABI for the memory of mod. Creating one is cheap, so host
+// functions can create one per call, with the module they are passed.
+func New(mod api.Module) *ABI {
+	return &ABI{mod: mod}
+}
+
+// Alloc allocates size bytes aligned to align with the function
+// "cabi_realloc" of the module, and returns their offset.
+func (a *ABI) Alloc(ctx context.Context, size, align uint32) (uint32, error) {
+	if a.realloc == nil {
+		if a.realloc = a.mod.ExportedFunction(ReallocName); a.realloc == nil {
+			return 0, fmt.Errorf("module[%s] doesn't export function[%s]", a.mod.Name(), ReallocName)
+		}
+	}
+	results, err := a.realloc.Call(ctx, 0, 0, uint64(align), uint64(size))
+	if err != nil {
+		return 0, err
+	}
+	ptr := uint32(results[0])
+	if ptr%align != 0 {
+		return 0, fmt.Errorf("%s returned unaligned pointer %d", ReallocName, ptr)
+	}
+	return ptr, nil
+}
+
+// Lift returns the value of type t passed as the core values flat, which
+// must have the length of t.Flat(). Strings and lists are read from memory.
+func (a *ABI) Lift(t *Type, flat []uint64) (interface{}, error) {
+	if len(flat) != len(t.layout.Flat) {
+		return nil, fmt.Errorf("%s is passed as %d values, but was %d", t, len(t.layout.Flat), len(flat))
+	}
+	return a.lift(t, flat)
+}
+
+func (a *ABI) lift(t *Type, flat []uint64) (interface{}, error) {
+	switch t.kind {
+	case kindString:
+		return a.readString(uint32(flat[0]), uint32(flat[1]))
+	case kindList:
+		return a.readList(t.elem, uint32(flat[0]), uint32(flat[1]))
+	case kindRecord:
+		ret := make([]interface{}, len(t.fields))
+		i := 0
+		for j, f := range t.fields {
+			v, err := a.lift(f, flat[i:i+len(f.layout.Flat)])
+			if err != nil {
+				return nil, err
+			}
+			ret[j] = v
+			i += len(f.layout.Flat)
+		}
+		return ret, nil
+	case kindVariant:
+		disc := uint32(flat[0])
+		if disc >= uint32(t.n) {
+			return nil, fmt.Errorf("invalid discriminant %d of %s", disc, t)
+		}
+		ret := Case{Index: disc}
+		if c := t.fields[disc]; c != nil {
+			v, err := a.lift(c, flat[1:1+len(c.layout.Flat)])
+			if err != nil {
+				return nil, err
+			}
+			ret.Value = v
+		}
+		return ret, nil
+	case kindEnum:
+		disc := uint32(flat[0])
+		if disc >= uint32(t.n) {
+			return nil, fmt.Errorf("invalid discriminant %d of %s", disc, t)
+		}
+		return disc, nil
+	case kindFlags:
+		return uint32(flat[0]) & (math.MaxUint32 >> (32 - t.n)), nil
+	default:
+		return liftPrimitive(t, flat[0])
+	}
+}
+
+func liftPrimitive(t *Type, v uint64) (interface{}, error) {
+	switch t.kind {
+	case kindBool:
+		return uint32(v) != 0, nil
+	case kindS8:
+		return int8(v), nil
+	case kindU8:
+		return uint8(v), nil
+	case kindS16:
+		return int16(v), nil
+	case kindU16:
+		return uint16(v), nil
+	case kindS32:
+		return int32(v), nil
+	case kindU32:
+		return uint32(v), nil
+	case kindS64:
+		return int64(v), nil
+	case kindU64:
+		return v, nil
+	case kindF32:
+		return math.Float32frombits(uint32(v)), nil
+	case kindF64:
+		return math.Float64frombits(v), nil
+	default: // kindChar
+		r := rune(uint32(v))
+		if uint32(v) > utf8.MaxRune || !utf8.ValidRune(r) {
+			return nil, fmt.Errorf("invalid char %#x", uint32(v))
+		}
+		return r, nil
+	}
+}
+
+// Lower returns the core values which pass the value v of type t, writing
+// strings and lists to memory allocated with Alloc.
+func (a *ABI) Lower(ctx context.Context, t *Type, v interface{}) ([]uint64, error) {
+	flat := make([]uint64, len(t.layout.Flat))
+	if err := a.lower(ctx, t, v, flat); err != nil {
+		return nil, err
+	}
+	return flat, nil
+}
+
+func (a *ABI) lower(ctx context.Context, t *Type, v interface{}, flat []uint64) error {
+	switch t.kind {
+	case kindString:
+		s, ok := v.(string)
+		if !ok {
+			return invalidValue(t, "string", v)
+		}
+		ptr, n, err := a.writeString(ctx, s)
+		flat[0], flat[1] = uint64(ptr), uint64(n)
+		return err
+	case kindList:
+		ptr, n, err := a.writeList(ctx, t, v)
+		flat[0], flat[1] = uint64(ptr), uint64(n)
+		return err
+	case kindRecord:
+		fields, err := recordFields(t, v)
+		if err != nil {
+			return err
+		}
+		i := 0
+		for j, f := range t.fields {
+			if err = a.lower(ctx, f, fields[j], flat[i:i+len(f.layout.Flat)]); err != nil {
+				return err
+			}
+			i += len(f.layout.Flat)
+		}
+		return nil
+	case kindVariant:
+		c, err := variantCase(t, v)
+		if err != nil {
+			return err
+		}
+		flat[0] = uint64(c.Index)
+		if payload := t.fields[c.Index]; payload != nil {
+			return a.lower(ctx, payload, c.Value, flat[1:1+len(payload.layout.Flat)])
+		}
+		return nil
+	case kindEnum, kindFlags:
+		u, err := enumValue(t, v)
+		flat[0] = uint64(u)
+		return err
+	default:
+		u, err := lowerPrimitive(t, v)
+		flat[0] = u
+		return err
+	}
+}
+
+func lowerPrimitive(t *Type, v interface{}) (uint64, error) {
+	switch v := v.(type) {
+	case bool:
+		if t.kind == kindBool {
+			if v {
+				return 1, nil
+			}
+			return 0, nil
+		}
+	case int8:
+		if t.kind == kindS8 {
+			return uint64(uint32(v)), nil
+		}
+	case uint8:
+		if t.kind == kindU8 {
+			return uint64(v), nil
+		}
+	case int16:
+		if t.kind == kindS16 {
+			return uint64(uint32(v)), nil
+		}
+	case uint16:
+		if t.kind == kindU16 {
+			return uint64(v), nil
+		}
+	case int32: // also rune
+		if t.kind == kindS32 {
+			return uint64(uint32(v)), nil
+		} else if t.kind == kindChar {
+			if !utf8.ValidRune(v) {
+				return 0, fmt.Errorf("invalid char %#x", v)
+			}
+			return uint64(v), nil
+		}
+	case uint32:
+		if t.kind == kindU32 {
+			return uint64(v), nil
+		}
+	case int64:
+		if t.kind == kindS64 {
+			return uint64(v), nil
+		}
+	case uint64:
+		if t.kind == kindU64 {
+			return v, nil
+		}
+	case float32:
+		if t.kind == kindF32 {
+			return uint64(math.Float32bits(v)), nil
+		}
+	case float64:
+		if t.kind == kindF64 {
+			return math.Float64bits(v), nil
+		}
+	}
+	return 0, invalidValue(t, goTypes[t.kind], v)
+}
+
+// goTypes are the Go types of the values of primitive types.
+var goTypes = map[kind]string{
+	kindBool: "bool", kindS8: "int8", kindU8: "uint8", kindS16: "int16", kindU16: "uint16",
+	kindS32: "int32", kindU32: "uint32", kindS64: "int64", kindU64: "uint64",
+	kindF32: "float32", kindF64: "float64", kindChar: "rune",
+}
+
+func invalidValue(t *Type, expected string, v interface{}) error {
+	return fmt.Errorf("invalid value for %s: expected %s, but was %T", t, expected, v)
+}
+
+func recordFields(t *Type, v interface{}) ([]interface{}, error) {
+	fields, ok := v.([]interface{})
+	if !ok {
+		return nil, invalidValue(t, "[]interface{}", v)
+	}
+	if len(fields) != len(t.fields) {
+		return nil, fmt.Errorf("invalid value for %s: expected %d fields, but was %d", t, len(t.fields), len(fields))
+	}
+	return fields, nil
+}
+
+func variantCase(t *Type, v interface{}) (Case, error) {
+	c, ok := v.(Case)
+	if !ok {
+		return c, invalidValue(t, "canonabi.Case", v)
+	}
+	if c.Index >= uint32(t.n) {
+		return c, fmt.Errorf("invalid value for %s: case %d is out of range", t, c.Index)
+	}
+	return c, nil
+}
+
+func enumValue(t *Type, v interface{}) (uint32, error) {
+	u, ok := v.(uint32)
+	if !ok {
+		return 0, invalidValue(t, "uint32", v)
+	}
+	if t.kind == kindEnum && u >= uint32(t.n) {
+		return 0, fmt.Errorf("invalid value for %s: case %d is out of range", t, u)
+	}
+	if t.kind == kindFlags && u>>t.n != 0 {
+		return 0, fmt.Errorf("invalid value for %s: flags %#x are out of range", t, u)
+	}
+	return u, nil
+}
+
+// Load returns the value of type t in memory at ptr, which must be aligned
+// to t.Alignment().
+func (a *ABI) Load(t *Type, ptr uint32) (interface{}, error) {
+	if ptr%t.layout.Alignment != 0 {
+		return nil, fmt.Errorf("unaligned pointer %d to %s", ptr, t)
+	}
+	return a.load(t, ptr)
+}
+
+func (a *ABI) load(t *Type, ptr uint32) (interface{}, error) {
+	switch t.kind {
+	case kindString, kindList:
+		b, err := a.read(ptr, 8)
+		if err != nil {
+			return nil, err
+		}
+		p, n := binary.LittleEndian.Uint32(b), binary.LittleEndian.Uint32(b[4:])
+		if t.kind == kindString {
+			return a.readString(p, n)
+		}
+		return a.readList(t.elem, p, n)
+	case kindRecord:
+		ret := make([]interface{}, len(t.fields))
+		for i, off := range t.offsets[:len(t.fields)] {
+			v, err := a.load(t.fields[i], ptr+off)
+			if err != nil {
+				return nil, err
+			}
+			ret[i] = v
+		}
+		return ret, nil
+	case kindVariant, kindEnum:
+		disc, err := a.loadUint(ptr, abi.DiscriminantSize(t.n))
+		if err != nil {
+			return nil, err
+		}
+		if disc >= uint64(t.n) {
+			return nil, fmt.Errorf("invalid discriminant %d of %s", disc, t)
+		}
+		if t.kind == kindEnum {
+			return uint32(disc), nil
+		}
+		ret := Case{Index: uint32(disc)}
+		if c := t.fields[disc]; c != nil {
+			if ret.Value, err = a.load(c, ptr+t.payloadOffset); err != nil {
+				return nil, err
+			}
+		}
+		return ret, nil
+	case kindFlags:
+		v, err := a.loadUint(ptr, t.layout.Size)
+		return uint32(v) & (math.MaxUint32 >> (32 - t.n)), err
+	default:
+		v, err := a.loadUint(ptr, t.layout.Size)
+		if err != nil {
+			return nil, err
+		}
+		return liftPrimitive(t, v)
+	}
+}
+
+// Store writes the value v of type t to memory at ptr, which must be aligned
+// to t.Alignment(). Strings and lists are written to memory allocated with
+// Alloc.
+func (a *ABI) Store(ctx context.Context, t *Type, ptr uint32, v interface{}) error {
+	if ptr%t.layout.Alignment != 0 {
+		return fmt.Errorf("unaligned pointer %d to %s", ptr, t)
+	}
+	return a.store(ctx, t, ptr, v)
+}
+
+func (a *ABI) store(ctx context.Context, t *Type, ptr uint32, v interface{}) error {
+	switch t.kind {
+	case kindString, kindList:
+		var p, n uint32
+		var err error
+		if t.kind == kindString {
+			s, ok := v.(string)
+			if !ok {
+				return invalidValue(t, "string", v)
+			}
+			p, n, err = a.writeString(ctx, s)
+		} else {
+			p, n, err = a.writeList(ctx, t, v)
+		}
+		if err != nil {
+			return err
+		}
+		if err = a.storeUint(ptr, 4, uint64(p)); err != nil {
+			return err
+		}
+		return a.storeUint(ptr+4, 4, uint64(n))
+	case kindRecord:
+		fields, err := recordFields(t, v)
+		if err != nil {
+			return err
+		}
+		for i, off := range t.offsets[:len(t.fields)] {
+			if err = a.store(ctx, t.fields[i], ptr+off, fields[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	case kindVariant:
+		c, err := variantCase(t, v)
+		if err != nil {
+			return err
+		}
+		if err = a.storeUint(ptr, abi.DiscriminantSize(t.n), uint64(c.Index)); err != nil {
+			return err
+		}
+		if payload := t.fields[c.Index]; payload != nil {
+			return a.store(ctx, payload, ptr+t.payloadOffset, c.Value)
+		}
+		return nil
+	case kindEnum:
+		u, err := enumValue(t, v)
+		if err != nil {
+			return err
+		}
+		return a.storeUint(ptr, abi.DiscriminantSize(t.n), uint64(u))
+	case kindFlags:
+		u, err := enumValue(t, v)
+		if err != nil {
+			return err
+		}
+		return a.storeUint(ptr, t.layout.Size, uint64(u))
+	default:
+		u, err := lowerPrimitive(t, v)
+		if err != nil {
+			return err
+		}
+		return a.storeUint(ptr, t.layout.Size, u)
+	}
+}
+
+// ReadString returns the UTF-8 string of n bytes in memory at ptr.
+func (a *ABI) ReadString(ptr, n uint32) (string, error) {
+	return a.readString(ptr, n)
+}
+
+// WriteString writes the string to memory allocated with Alloc, and returns
+// its offset and length.
+func (a *ABI) WriteString(ctx context.Context, s string) (ptr, n uint32, err error) {
+	return a.writeString(ctx, s)
+}
+
+func (a *ABI) readString(ptr, n uint32) (string, error) {
+	b, err := a.read(ptr, uint64(n))
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(b) {
+		return "", fmt.Errorf("invalid UTF-8 string at offset %d", ptr)
+	}
+	return string(b), nil
+}
+
+func (a *ABI) writeString(ctx context.Context, s string) (uint32, uint32, error) {
+	if uint64(len(s)) > math.MaxUint32 {
+		return 0, 0, fmt.Errorf("string of %d bytes is too long", len(s))
+	}
+	ptr, err := a.Alloc(ctx, uint32(len(s)), 1)
+	if err != nil {
+		return 0, 0, err
+	}
+	return ptr, uint32(len(s)), a.write(ptr, []byte(s))
+}
+
+func (a *ABI) readList(elem *Type, ptr, n uint32) (interface{}, error) {
+	if ptr%elem.layout.Alignment != 0 {
+		return nil, fmt.Errorf("unaligned pointer %d to %s", ptr, List(elem))
+	}
+	// Check the whole list is in memory before allocating it.
+	b, err := a.read(ptr, uint64(n)*uint64(elem.layout.Size))
+	if err != nil {
+		return nil, err
+	}
+	if elem.kind == kindU8 {
+		return append([]byte{}, b...), nil
+	}
+	ret := make([]interface{}, n)
+	for i := range ret {
+		if ret[i], err = a.load(elem, ptr+uint32(i)*elem.layout.Size); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+func (a *ABI) writeList(ctx context.Context, t *Type, v interface{}) (uint32, uint32, error) {
+	elem := t.elem
+	if elem.kind == kindU8 {
+		b, ok := v.([]byte)
+		if !ok {
+			return 0, 0, invalidValue(t, "[]byte", v)
+		}
+		if uint64(len(b)) > math.MaxUint32 {
+			return 0, 0, fmt.Errorf("%s of %d elements is too long", t, len(b))
+		}
+		ptr, err := a.Alloc(ctx, uint32(len(b)), 1)
+		if err != nil {
+			return 0, 0, err
+		}
+		return ptr, uint32(len(b)), a.write(ptr, b)
+	}
+
+	elems, ok := v.([]interface{})
+	if !ok {
+		return 0, 0, invalidValue(t, "[]interface{}", v)
+	}
+	size := uint64(len(elems)) * uint64(elem.layout.Size)
+	if size > math.MaxUint32 {
+		return 0, 0, fmt.Errorf("%s of %d elements is too long", t, len(elems))
+	}
+	ptr, err := a.Alloc(ctx, uint32(size), elem.layout.Alignment)
+	if err != nil {
+		return 0, 0, err
+	}
+	for i, e := range elems {
+		if err = a.store(ctx, elem, ptr+uint32(i)*elem.layout.Size, e); err != nil {
+			return 0, 0, err
+		}
+	}
+	return ptr, uint32(len(elems)), nil
+}
+
+func (a *ABI) memory() (api.Memory, error) {
+	mem := a.mod.Memory()
+	if mem == nil {
+		return nil, fmt.Errorf("module[%s] has no memory", a.mod.Name())
+	}
+	return mem, nil
+}
+
+func (a *ABI) read(ptr uint32, size uint64) ([]byte, error) {
+	mem, err := a.memory()
+	if err != nil {
+		return nil, err
+	}
+	if size <= math.MaxUint32 {
+		if b, ok := mem.Read(ptr, uint32(size)); ok {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("out of range reading %d bytes at offset %d", size, ptr)
+}
+
+func (a *ABI) write(ptr uint32, b []byte) error {
+	mem, err := a.memory()
+	if err != nil {
+		return err
+	}
+	if !mem.Write(ptr, b) {
+		return fmt.Errorf("out of range writing %d bytes at offset %d", len(b), ptr)
+	}
+	return nil
+}
+
+// loadUint loads the little-endian integer of size bytes at ptr.
+func (a *ABI) loadUint(ptr, size uint32) (uint64, error) {
+	b, err := a.read(ptr, uint64(size))
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for i := int(size) - 1; i >= 0; i-- {
+		v = v<<8 | uint64(b[i])
+	}
+	return v, nil
+}
+
+// storeUint stores v as a little-endian integer of size bytes at ptr.
+func (a *ABI) storeUint(ptr, size uint32, v uint64) error {
+	b := make([]byte, size)
+	for i := range b {
+		b[i] = byte(v >> (8 * i))
+	}
+	return a.write(ptr, b)
+}
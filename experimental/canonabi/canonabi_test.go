@@ -0,0 +1,326 @@
+package canonabi
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/leb128"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+// testCtx is an arbitrary, non-default context. Non-nil also prevents linter errors.
+var testCtx = context.WithValue(context.Background(), struct{}{}, "arbitrary")
+
+// retArea is the offset in memory of results returned by a pointer.
+const retArea = 64
+
+// guestWasm exports these functions, with a "cabi_realloc" which bumps a
+// pointer:
+//   - "greet" calls the host function "greet" (string) -> string.
+//   - "add" (u32, u32) -> u32, with "cabi_post_add", which increments the
+//     exported global "post_calls".
+//   - "identity" (i32) -> i32, which returns its parameter.
+var guestWasm = binary.EncodeModule(guestModule(true))
+
+func guestModule(withRealloc bool) *wasm.Module {
+	i32 := wasm.ValueTypeI32
+	i32Const := func(v int32) []byte {
+		return append([]byte{wasm.OpcodeI32Const}, leb128.EncodeInt32(v)...)
+	}
+	m := &wasm.Module{
+		TypeSection: []*wasm.FunctionType{
+			{Params: []wasm.ValueType{i32, i32, i32}},
+			{Params: []wasm.ValueType{i32, i32, i32, i32}, Results: []wasm.ValueType{i32}},
+			{Params: []wasm.ValueType{i32, i32}, Results: []wasm.ValueType{i32}},
+			{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+			{Params: []wasm.ValueType{i32}},
+		},
+		ImportSection: []*wasm.Import{{Module: "host", Name: "greet", Type: wasm.ExternTypeFunc, DescFunc: 0}},
+		MemorySection: &wasm.Memory{Min: 1},
+		GlobalSection: []*wasm.Global{
+			{ // the heap pointer
+				Type: &wasm.GlobalType{ValType: i32, Mutable: true},
+				Init: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: leb128.EncodeInt32(1024)},
+			},
+			{ // post_calls
+				Type: &wasm.GlobalType{ValType: i32, Mutable: true},
+				Init: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: leb128.EncodeInt32(0)},
+			},
+		},
+		FunctionSection: []wasm.Index{1, 2, 2, 3, 4},
+		CodeSection: []*wasm.Code{
+			// cabi_realloc aligns the heap pointer, and returns it before adding size.
+			{LocalTypes: []wasm.ValueType{i32}, Body: []byte{
+				wasm.OpcodeGlobalGet, 0, wasm.OpcodeLocalGet, 2, wasm.OpcodeI32Add,
+				wasm.OpcodeI32Const, 1, wasm.OpcodeI32Sub,
+				wasm.OpcodeI32Const, 0, wasm.OpcodeLocalGet, 2, wasm.OpcodeI32Sub,
+				wasm.OpcodeI32And, wasm.OpcodeLocalTee, 4,
+				wasm.OpcodeLocalGet, 3, wasm.OpcodeI32Add, wasm.OpcodeGlobalSet, 0,
+				wasm.OpcodeLocalGet, 4, wasm.OpcodeEnd,
+			}},
+			{Body: append(append(append([]byte{ // greet
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1,
+			}, i32Const(retArea)...), wasm.OpcodeCall, 0), append(i32Const(retArea), wasm.OpcodeEnd)...)},
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Add, wasm.OpcodeEnd}}, // add
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeEnd}},                                            // identity
+			{Body: []byte{ // cabi_post_add
+				wasm.OpcodeGlobalGet, 1, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Add, wasm.OpcodeGlobalSet, 1, wasm.OpcodeEnd,
+			}},
+		},
+		ExportSection: []*wasm.Export{
+			{Name: "memory", Type: wasm.ExternTypeMemory},
+			{Name: "post_calls", Type: wasm.ExternTypeGlobal, Index: 1},
+			{Name: "greet", Type: wasm.ExternTypeFunc, Index: 2},
+			{Name: "add", Type: wasm.ExternTypeFunc, Index: 3},
+			{Name: "identity", Type: wasm.ExternTypeFunc, Index: 4},
+			{Name: "cabi_post_add", Type: wasm.ExternTypeFunc, Index: 5},
+		},
+	}
+	if withRealloc {
+		m.ExportSection = append(m.ExportSection, &wasm.Export{Name: ReallocName, Type: wasm.ExternTypeFunc, Index: 1})
+	}
+	return m
+}
+
+var greet = Func{Params: []*Type{String}, Result: String}
+
+// newGuest instantiates guestWasm, with the host function "greet" which
+// returns "Hello, " and the name.
+func newGuest(t *testing.T) api.Module {
+	r := wazero.NewRuntime(testCtx)
+	t.Cleanup(func() { r.Close(testCtx) })
+
+	params, results := greet.ImportSignature()
+	_, err := r.NewHostModuleBuilder("host").NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			abi := New(mod)
+			args, err := abi.LiftParams(greet, stack)
+			if err != nil {
+				panic(err)
+			}
+			if err = abi.LowerResult(ctx, greet, "Hello, "+args[0].(string), stack); err != nil {
+				panic(err)
+			}
+		}), params, results).
+		Export("greet").
+		Instantiate(testCtx, r)
+	require.NoError(t, err)
+
+	mod, err := r.InstantiateModuleFromBinary(testCtx, guestWasm)
+	require.NoError(t, err)
+	return mod
+}
+
+func TestABI_Roundtrip(t *testing.T) {
+	abi := New(newGuest(t))
+
+	tests := []struct {
+		typ   *Type
+		value interface{}
+	}{
+		{typ: Bool, value: true},
+		{typ: S8, value: int8(-5)},
+		{typ: U8, value: uint8(200)},
+		{typ: S16, value: int16(-300)},
+		{typ: U16, value: uint16(60000)},
+		{typ: S32, value: int32(-7)},
+		{typ: U32, value: uint32(1 << 31)},
+		{typ: S64, value: int64(-1 << 40)},
+		{typ: U64, value: uint64(1 << 63)},
+		{typ: F32, value: float32(1.5)},
+		{typ: F64, value: -2.25},
+		{typ: Char, value: '世'},
+		{typ: String, value: "wazero"},
+		{typ: String, value: ""},
+		{typ: List(U8), value: []byte{1, 2, 3}},
+		{typ: List(String), value: []interface{}{"a", "", "wazero"}},
+		{typ: Record(U8, String, U64), value: []interface{}{uint8(1), "x", uint64(2)}},
+		{typ: Tuple(Bool, Char), value: []interface{}{false, 'a'}},
+		{typ: Option(U32), value: Case{Index: 1, Value: uint32(3)}},
+		{typ: Option(U32), value: Case{Index: 0}},
+		{typ: Result(F32, String), value: Case{Index: 0, Value: float32(1.5)}},
+		{typ: Result(F32, String), value: Case{Index: 1, Value: "bad"}},
+		{typ: Result(nil, nil), value: Case{Index: 1}},
+		{typ: Variant(F32, U64, nil), value: Case{Index: 1, Value: uint64(1 << 40)}},
+		{typ: Enum(3), value: uint32(2)},
+		{typ: Flags(3), value: uint32(5)},
+		{
+			typ:   List(Record(S16, Option(String))),
+			value: []interface{}{[]interface{}{int16(-1), Case{Index: 1, Value: "x"}}, []interface{}{int16(2), Case{}}},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(fmt.Sprintf("%s %v", tc.typ, tc.value), func(t *testing.T) {
+			flat, err := abi.Lower(testCtx, tc.typ, tc.value)
+			require.NoError(t, err)
+			require.Equal(t, len(tc.typ.Flat()), len(flat))
+			v, err := abi.Lift(tc.typ, flat)
+			require.NoError(t, err)
+			require.Equal(t, tc.value, v)
+
+			ptr, err := abi.Alloc(testCtx, tc.typ.Size(), tc.typ.Alignment())
+			require.NoError(t, err)
+			require.NoError(t, abi.Store(testCtx, tc.typ, ptr, tc.value))
+			v, err = abi.Load(tc.typ, ptr)
+			require.NoError(t, err)
+			require.Equal(t, tc.value, v)
+		})
+	}
+}
+
+func TestABI_Errors(t *testing.T) {
+	mod := newGuest(t)
+	abi := New(mod)
+	mem := mod.Memory()
+	require.True(t, mem.Write(128, []byte{0xff, 0xfe}))
+	require.True(t, mem.WriteUint32Le(136, 3))
+
+	tests := []struct {
+		name        string
+		fn          func() error
+		expectedErr string
+	}{
+		{
+			name:        "lower wrong type",
+			fn:          func() error { _, err := abi.Lower(testCtx, U32, 1); return err },
+			expectedErr: "invalid value for u32: expected uint32, but was int",
+		},
+		{
+			name:        "lower wrong field count",
+			fn:          func() error { _, err := abi.Lower(testCtx, Tuple(U8, U8), []interface{}{uint8(1)}); return err },
+			expectedErr: "invalid value for tuple<u8, u8>: expected 2 fields, but was 1",
+		},
+		{
+			name:        "lower case out of range",
+			fn:          func() error { _, err := abi.Lower(testCtx, Option(U8), Case{Index: 2}); return err },
+			expectedErr: "invalid value for option<u8>: case 2 is out of range",
+		},
+		{
+			name:        "lower flags out of range",
+			fn:          func() error { _, err := abi.Lower(testCtx, Flags(2), uint32(4)); return err },
+			expectedErr: "invalid value for flags(2): flags 0x4 are out of range",
+		},
+		{
+			name:        "lower invalid char",
+			fn:          func() error { _, err := abi.Lower(testCtx, Char, rune(0xd800)); return err },
+			expectedErr: "invalid char 0xd800",
+		},
+		{
+			name:        "lift wrong count",
+			fn:          func() error { _, err := abi.Lift(String, []uint64{0}); return err },
+			expectedErr: "string is passed as 2 values, but was 1",
+		},
+		{
+			name:        "lift invalid discriminant",
+			fn:          func() error { _, err := abi.Lift(Enum(2), []uint64{2}); return err },
+			expectedErr: "invalid discriminant 2 of enum(2)",
+		},
+		{
+			name:        "lift invalid UTF-8",
+			fn:          func() error { _, err := abi.Lift(String, []uint64{128, 2}); return err },
+			expectedErr: "invalid UTF-8 string at offset 128",
+		},
+		{
+			name:        "lift out of range",
+			fn:          func() error { _, err := abi.Lift(List(U32), []uint64{65532, 2}); return err },
+			expectedErr: "out of range reading 8 bytes at offset 65532",
+		},
+		{
+			name:        "lift unaligned list",
+			fn:          func() error { _, err := abi.Lift(List(U32), []uint64{2, 1}); return err },
+			expectedErr: "unaligned pointer 2 to list<u32>",
+		},
+		{
+			name:        "load invalid discriminant",
+			fn:          func() error { _, err := abi.Load(Option(U8), 136); return err },
+			expectedErr: "invalid discriminant 3 of option<u8>",
+		},
+		{
+			name:        "load unaligned",
+			fn:          func() error { _, err := abi.Load(U32, 2); return err },
+			expectedErr: "unaligned pointer 2 to u32",
+		},
+		{
+			name:        "store out of range",
+			fn:          func() error { return abi.Store(testCtx, U64, 65536, uint64(1)) },
+			expectedErr: "out of range writing 8 bytes at offset 65536",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			require.EqualError(t, tc.fn(), tc.expectedErr)
+		})
+	}
+}
+
+func TestABI_Alloc_noRealloc(t *testing.T) {
+	r := wazero.NewRuntime(testCtx)
+	defer r.Close(testCtx)
+
+	m := guestModule(false)
+	m.ImportSection, m.FunctionSection, m.CodeSection = nil, nil, nil
+	m.ExportSection = m.ExportSection[:2]
+	mod, err := r.InstantiateModule(testCtx, mustCompile(t, r, m), wazero.NewModuleConfig().WithName("guest"))
+	require.NoError(t, err)
+
+	_, err = New(mod).Lower(testCtx, String, "wazero")
+	require.EqualError(t, err, "module[guest] doesn't export function[cabi_realloc]")
+}
+
+func mustCompile(t *testing.T, r wazero.Runtime, m *wasm.Module) wazero.CompiledModule {
+	compiled, err := r.CompileModule(testCtx, binary.EncodeModule(m))
+	require.NoError(t, err)
+	return compiled
+}
+
+func TestABI_Call(t *testing.T) {
+	mod := newGuest(t)
+	abi := New(mod)
+
+	t.Run("flat", func(t *testing.T) {
+		sum, err := abi.Call(testCtx, "add", Func{Params: []*Type{U32, U32}, Result: U32}, uint32(1), uint32(2))
+		require.NoError(t, err)
+		require.Equal(t, uint32(3), sum)
+		// The post-return function was called.
+		require.Equal(t, uint64(1), mod.ExportedGlobal("post_calls").Get())
+	})
+
+	t.Run("host function", func(t *testing.T) {
+		greeting, err := abi.Call(testCtx, "greet", greet, "wazero")
+		require.NoError(t, err)
+		require.Equal(t, "Hello, wazero", greeting)
+	})
+
+	t.Run("params in memory", func(t *testing.T) {
+		params := make([]*Type, 17)
+		args := make([]interface{}, 17)
+		for i := range params {
+			params[i], args[i] = U32, uint32(i)
+		}
+		// identity returns the pointer to the params.
+		ptr, err := abi.Call(testCtx, "identity", Func{Params: params, Result: U32}, args...)
+		require.NoError(t, err)
+		v, err := abi.Load(Record(params...), ptr.(uint32))
+		require.NoError(t, err)
+		require.Equal(t, args, v)
+	})
+
+	t.Run("missing function", func(t *testing.T) {
+		_, err := abi.Call(testCtx, "missing", Func{})
+		require.EqualError(t, err, "module[] doesn't export function[missing]")
+	})
+
+	t.Run("wrong args", func(t *testing.T) {
+		_, err := abi.Call(testCtx, "greet", greet)
+		require.EqualError(t, err, "function[greet] has 1 params, but was called with 0")
+	})
+}
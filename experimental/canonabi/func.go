@@ -0,0 +1,163 @@
+package canonabi
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/abi"
+)
+
+// Func is the type of a function, whose parameters and result are passed
+// with the canonical ABI.
+type Func struct {
+	Params []*Type
+	// Result is the type of the result, or nil if there's none.
+	Result *Type
+}
+
+// ImportSignature returns the core types of the function when imported by a
+// guest, i.e. of a host function. Its result is stored in memory at the
+// pointer passed last if it doesn't fit in one value.
+func (f Func) ImportSignature() (params, results []api.ValueType) {
+	params = f.flatParams()
+	if f.Result != nil {
+		if results = f.Result.layout.Flat; len(results) > abi.MaxFlatResults {
+			params, results = append(params, api.ValueTypeI32), nil
+		}
+	}
+	return
+}
+
+// ExportSignature returns the core types of the function when exported by a
+// guest. Its result is returned as a pointer to memory if it doesn't fit in
+// one value.
+func (f Func) ExportSignature() (params, results []api.ValueType) {
+	params = f.flatParams()
+	if f.Result != nil {
+		if results = f.Result.layout.Flat; len(results) > abi.MaxFlatResults {
+			results = []api.ValueType{api.ValueTypeI32}
+		}
+	}
+	return
+}
+
+// flatParams returns the core types of the parameters, which are passed as a
+// pointer to memory if too many.
+func (f Func) flatParams() []api.ValueType {
+	if f.inMemory() {
+		return []api.ValueType{api.ValueTypeI32}
+	}
+	var ret []api.ValueType
+	for _, p := range f.Params {
+		ret = append(ret, p.layout.Flat...)
+	}
+	return ret
+}
+
+// inMemory returns true if the parameters are passed in memory, as a record.
+func (f Func) inMemory() bool {
+	n := 0
+	for _, p := range f.Params {
+		n += len(p.layout.Flat)
+	}
+	return n > abi.MaxFlatParams
+}
+
+// Call calls the function the module exports with the name and the type f,
+// lowering args, which have the types of f.Params, and returns its lifted
+// result, or nil if it has none. After lifting the result, this calls the
+// function "cabi_post_<name>" if exported, which frees its memory.
+func (a *ABI) Call(ctx context.Context, name string, f Func, args ...interface{}) (interface{}, error) {
+	fn := a.mod.ExportedFunction(name)
+	if fn == nil {
+		return nil, fmt.Errorf("module[%s] doesn't export function[%s]", a.mod.Name(), name)
+	}
+	if len(args) != len(f.Params) {
+		return nil, fmt.Errorf("function[%s] has %d params, but was called with %d", name, len(f.Params), len(args))
+	}
+
+	params, err := a.lowerParams(ctx, f, args)
+	if err != nil {
+		return nil, err
+	}
+	results, err := fn.Call(ctx, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret interface{}
+	if f.Result != nil {
+		if len(f.Result.layout.Flat) > abi.MaxFlatResults {
+			ret, err = a.Load(f.Result, uint32(results[0]))
+		} else {
+			ret, err = a.lift(f.Result, results)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if post := a.mod.ExportedFunction("cabi_post_" + name); post != nil {
+		if _, err = post.Call(ctx, results...); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+func (a *ABI) lowerParams(ctx context.Context, f Func, args []interface{}) ([]uint64, error) {
+	if f.inMemory() {
+		t := Record(f.Params...)
+		ptr, err := a.Alloc(ctx, t.layout.Size, t.layout.Alignment)
+		if err != nil {
+			return nil, err
+		}
+		return []uint64{uint64(ptr)}, a.store(ctx, t, ptr, args)
+	}
+	flat := make([]uint64, len(f.flatParams()))
+	i := 0
+	for j, p := range f.Params {
+		if err := a.lower(ctx, p, args[j], flat[i:i+len(p.layout.Flat)]); err != nil {
+			return nil, err
+		}
+		i += len(p.layout.Flat)
+	}
+	return flat, nil
+}
+
+// LiftParams returns the parameters of a host function with the type f,
+// lifted from the stack of its api.GoModuleFunction.
+func (a *ABI) LiftParams(f Func, stack []uint64) ([]interface{}, error) {
+	if f.inMemory() {
+		v, err := a.Load(Record(f.Params...), uint32(stack[0]))
+		if err != nil {
+			return nil, err
+		}
+		return v.([]interface{}), nil
+	}
+	ret := make([]interface{}, len(f.Params))
+	i := 0
+	for j, p := range f.Params {
+		v, err := a.lift(p, stack[i:i+len(p.layout.Flat)])
+		if err != nil {
+			return nil, err
+		}
+		ret[j] = v
+		i += len(p.layout.Flat)
+	}
+	return ret, nil
+}
+
+// LowerResult lowers the result v of a host function with the type f to the
+// stack of its api.GoModuleFunction, or to memory at the pointer passed last
+// if it doesn't fit in one value.
+func (a *ABI) LowerResult(ctx context.Context, f Func, v interface{}, stack []uint64) error {
+	if f.Result == nil {
+		return nil
+	}
+	if len(f.Result.layout.Flat) > abi.MaxFlatResults {
+		params, _ := f.ImportSignature()
+		return a.Store(ctx, f.Result, uint32(stack[len(params)-1]), v)
+	}
+	return a.lower(ctx, f.Result, v, stack[:1])
+}
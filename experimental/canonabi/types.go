@@ -0,0 +1,195 @@
+package canonabi
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/abi"
+)
+
+type kind uint8
+
+const (
+	kindBool kind = iota
+	kindS8
+	kindU8
+	kindS16
+	kindU16
+	kindS32
+	kindU32
+	kindS64
+	kindU64
+	kindF32
+	kindF64
+	kindChar
+	kindString
+	kindList
+	kindRecord
+	kindVariant
+	kindEnum
+	kindFlags
+)
+
+// Type is the type of a value, which determines how it is lifted and
+// lowered. Types are immutable, and are created with the variables and
+// functions of this package, e.g. List(String).
+type Type struct {
+	kind kind
+	name string
+	// elem is the element of a list.
+	elem *Type
+	// fields are the fields of a record, or the cases of a variant, which are
+	// nil for cases without a value.
+	fields []*Type
+	// n is the count of cases of a variant or enum, or of flags.
+	n int
+	// offsets are the offsets of the fields of a record, followed by the
+	// offset after the last.
+	offsets []uint32
+	// payloadOffset is the offset of the value of a case of a variant.
+	payloadOffset uint32
+
+	layout abi.Layout
+}
+
+// Primitive types. Their values are of the Go type of the same name, except
+// Char, whose values are a rune, and String, whose values are a string.
+var (
+	Bool   = primitive(kindBool, "bool", 1, api.ValueTypeI32)
+	S8     = primitive(kindS8, "s8", 1, api.ValueTypeI32)
+	U8     = primitive(kindU8, "u8", 1, api.ValueTypeI32)
+	S16    = primitive(kindS16, "s16", 2, api.ValueTypeI32)
+	U16    = primitive(kindU16, "u16", 2, api.ValueTypeI32)
+	S32    = primitive(kindS32, "s32", 4, api.ValueTypeI32)
+	U32    = primitive(kindU32, "u32", 4, api.ValueTypeI32)
+	S64    = primitive(kindS64, "s64", 8, api.ValueTypeI64)
+	U64    = primitive(kindU64, "u64", 8, api.ValueTypeI64)
+	F32    = primitive(kindF32, "f32", 4, api.ValueTypeF32)
+	F64    = primitive(kindF64, "f64", 8, api.ValueTypeF64)
+	Char   = primitive(kindChar, "char", 4, api.ValueTypeI32)
+	String = withLayout(&Type{kind: kindString, name: "string"}, abi.Slice)
+)
+
+func primitive(k kind, name string, size uint32, vt api.ValueType) *Type {
+	return withLayout(&Type{kind: k, name: name}, abi.Primitive(size, vt))
+}
+
+// withLayout sets the layout of t, and returns it.
+func withLayout(t *Type, l abi.Layout) *Type {
+	t.layout = l
+	return t
+}
+
+// List returns the type of a list of elem. Its values are []interface{},
+// except for List(U8), whose values are []byte.
+func List(elem *Type) *Type {
+	return withLayout(&Type{kind: kindList, name: "list<" + elem.name + ">", elem: elem}, abi.Slice)
+}
+
+// Record returns the type of a record with the types of its fields, in
+// order. Its values are []interface{} with a value per field.
+func Record(fields ...*Type) *Type {
+	return record("record{"+names(fields)+"}", fields)
+}
+
+// Tuple returns the type of a tuple, which is laid out like a Record.
+func Tuple(fields ...*Type) *Type {
+	return record("tuple<"+names(fields)+">", fields)
+}
+
+func record(name string, fields []*Type) *Type {
+	layouts := make([]abi.Layout, len(fields))
+	for i, f := range fields {
+		layouts[i] = f.layout
+	}
+	l, offsets := abi.Record(layouts)
+	return withLayout(&Type{kind: kindRecord, name: name, fields: fields, offsets: offsets}, l)
+}
+
+// Variant returns the type of a variant with the types of the values of its
+// cases, which are nil for cases without a value. Its values are a Case.
+//
+// This panics if there are no cases.
+func Variant(cases ...*Type) *Type {
+	return variant(kindVariant, "variant{"+names(cases)+"}", cases, len(cases))
+}
+
+// Option returns the type of an option of elem, which is a variant whose case
+// 0 is none and case 1 is some, with a value of elem.
+func Option(elem *Type) *Type {
+	return variant(kindVariant, "option<"+elem.name+">", []*Type{nil, elem}, 2)
+}
+
+// Result returns the type of a result, which is a variant whose case 0 is ok,
+// with a value of ok, and case 1 is an error, with a value of err. Either may
+// be nil for cases without a value.
+func Result(ok, err *Type) *Type {
+	return variant(kindVariant, "result<"+names([]*Type{ok, err})+">", []*Type{ok, err}, 2)
+}
+
+// Enum returns the type of an enum with n cases. Its values are the uint32
+// index of a case.
+//
+// This panics if n is zero.
+func Enum(n int) *Type {
+	return variant(kindEnum, fmt.Sprintf("enum(%d)", n), nil, n)
+}
+
+// Flags returns the type of n flags, which are at most 32. Its values are a
+// uint32, whose bit i is set if flag i is.
+//
+// This panics if n is zero or more than 32.
+func Flags(n int) *Type {
+	if n <= 0 || n > 32 {
+		panic(fmt.Errorf("invalid count of flags %d: must be 1-32", n))
+	}
+	return withLayout(&Type{kind: kindFlags, name: fmt.Sprintf("flags(%d)", n), n: n}, abi.Flags(n))
+}
+
+func variant(k kind, name string, cases []*Type, n int) *Type {
+	if n <= 0 {
+		panic(fmt.Errorf("invalid %s: must have cases", name))
+	}
+	layouts := make([]*abi.Layout, len(cases))
+	for i, c := range cases {
+		if c != nil {
+			layouts[i] = &c.layout
+		}
+	}
+	l, payloadOffset := abi.Variant(n, layouts)
+	return withLayout(&Type{kind: k, name: name, fields: cases, n: n, payloadOffset: payloadOffset}, l)
+}
+
+// Size returns the size of values of the type in memory.
+func (t *Type) Size() uint32 {
+	return t.layout.Size
+}
+
+// Alignment returns the alignment of values of the type in memory.
+func (t *Type) Alignment() uint32 {
+	return t.layout.Alignment
+}
+
+// Flat returns the core types which values of the type are passed as.
+func (t *Type) Flat() []api.ValueType {
+	return t.layout.Flat
+}
+
+// String returns the type in WIT syntax, except records, variants, enums and
+// flags, which are anonymous.
+func (t *Type) String() string {
+	return t.name
+}
+
+func names(types []*Type) string {
+	ret := make([]string, len(types))
+	for i, t := range types {
+		if t == nil {
+			ret[i] = "_"
+		} else {
+			ret[i] = t.name
+		}
+	}
+	return strings.Join(ret, ", ")
+}
@@ -0,0 +1,115 @@
+package canonabi
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestType(t *testing.T) {
+	i32, i64, f32 := api.ValueTypeI32, api.ValueTypeI64, api.ValueTypeF32
+	tests := []struct {
+		typ               *Type
+		expectedString    string
+		expectedSize      uint32
+		expectedAlignment uint32
+		expectedFlat      []api.ValueType
+	}{
+		{typ: U8, expectedString: "u8", expectedSize: 1, expectedAlignment: 1, expectedFlat: []api.ValueType{i32}},
+		{typ: F32, expectedString: "f32", expectedSize: 4, expectedAlignment: 4, expectedFlat: []api.ValueType{f32}},
+		{typ: U64, expectedString: "u64", expectedSize: 8, expectedAlignment: 8, expectedFlat: []api.ValueType{i64}},
+		{typ: String, expectedString: "string", expectedSize: 8, expectedAlignment: 4, expectedFlat: []api.ValueType{i32, i32}},
+		{typ: List(U64), expectedString: "list<u64>", expectedSize: 8, expectedAlignment: 4, expectedFlat: []api.ValueType{i32, i32}},
+		{
+			typ: Record(U8, U64, U8), expectedString: "record{u8, u64, u8}",
+			expectedSize: 24, expectedAlignment: 8, expectedFlat: []api.ValueType{i32, i64, i32},
+		},
+		{
+			typ: Tuple(U8, U16), expectedString: "tuple<u8, u16>",
+			expectedSize: 4, expectedAlignment: 2, expectedFlat: []api.ValueType{i32, i32},
+		},
+		{typ: Record(), expectedString: "record{}", expectedSize: 0, expectedAlignment: 1},
+		{
+			typ: Option(U32), expectedString: "option<u32>",
+			expectedSize: 8, expectedAlignment: 4, expectedFlat: []api.ValueType{i32, i32},
+		},
+		{
+			typ: Result(F32, String), expectedString: "result<f32, string>",
+			expectedSize: 12, expectedAlignment: 4, expectedFlat: []api.ValueType{i32, i32, i32},
+		},
+		{
+			typ: Variant(F32, U64, nil), expectedString: "variant{f32, u64, _}",
+			expectedSize: 16, expectedAlignment: 8, expectedFlat: []api.ValueType{i32, i64},
+		},
+		{typ: Enum(3), expectedString: "enum(3)", expectedSize: 1, expectedAlignment: 1, expectedFlat: []api.ValueType{i32}},
+		{typ: Enum(257), expectedString: "enum(257)", expectedSize: 2, expectedAlignment: 2, expectedFlat: []api.ValueType{i32}},
+		{typ: Flags(9), expectedString: "flags(9)", expectedSize: 2, expectedAlignment: 2, expectedFlat: []api.ValueType{i32}},
+		{typ: Flags(32), expectedString: "flags(32)", expectedSize: 4, expectedAlignment: 4, expectedFlat: []api.ValueType{i32}},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.expectedString, func(t *testing.T) {
+			require.Equal(t, tc.expectedString, tc.typ.String())
+			require.Equal(t, tc.expectedSize, tc.typ.Size())
+			require.Equal(t, tc.expectedAlignment, tc.typ.Alignment())
+			require.Equal(t, tc.expectedFlat, tc.typ.Flat())
+		})
+	}
+}
+
+func TestType_Panics(t *testing.T) {
+	require.EqualError(t, require.CapturePanic(func() { Flags(33) }), "invalid count of flags 33: must be 1-32")
+	require.EqualError(t, require.CapturePanic(func() { Enum(0) }), "invalid enum(0): must have cases")
+	require.EqualError(t, require.CapturePanic(func() { Variant() }), "invalid variant{}: must have cases")
+}
+
+func TestFunc_Signature(t *testing.T) {
+	i32 := api.ValueTypeI32
+	many := make([]*Type, 17)
+	for i := range many {
+		many[i] = U32
+	}
+
+	tests := []struct {
+		name                                        string
+		f                                           Func
+		expectedImportParams, expectedImportResults []api.ValueType
+		expectedExportParams, expectedExportResults []api.ValueType
+	}{
+		{
+			name:                  "flat",
+			f:                     Func{Params: []*Type{U32, U32}, Result: U32},
+			expectedImportParams:  []api.ValueType{i32, i32},
+			expectedImportResults: []api.ValueType{i32},
+			expectedExportParams:  []api.ValueType{i32, i32},
+			expectedExportResults: []api.ValueType{i32},
+		},
+		{
+			name:                  "result in memory",
+			f:                     Func{Params: []*Type{String}, Result: String},
+			expectedImportParams:  []api.ValueType{i32, i32, i32},
+			expectedExportParams:  []api.ValueType{i32, i32},
+			expectedExportResults: []api.ValueType{i32},
+		},
+		{
+			name:                 "params in memory",
+			f:                    Func{Params: many},
+			expectedImportParams: []api.ValueType{i32},
+			expectedExportParams: []api.ValueType{i32},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			params, results := tc.f.ImportSignature()
+			require.Equal(t, tc.expectedImportParams, params)
+			require.Equal(t, tc.expectedImportResults, results)
+			params, results = tc.f.ExportSignature()
+			require.Equal(t, tc.expectedExportParams, params)
+			require.Equal(t, tc.expectedExportResults, results)
+		})
+	}
+}
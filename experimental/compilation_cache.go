@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 
@@ -51,3 +52,62 @@ func WithCompilationCacheDirName(ctx context.Context, dirname string) (context.C
 	ctx = context.WithValue(ctx, compilationcache.FileCachePathKey{}, dirname)
 	return ctx, nil
 }
+
+// CompilationCacheCompression compresses the entries of the compilation cache
+// configured with WithCompilationCacheDirName, as the native code of large
+// modules can be hundreds of megabytes.
+//
+// wazero has no dependencies, so only includes NewCompilationCacheDeflate.
+// Implement this to use other algorithms, e.g. zstd with
+// github.com/klauspost/compress/zstd:
+//
+//	type zstdCompression struct{}
+//
+//	func (zstdCompression) Name() string { return "zstd" }
+//
+//	func (zstdCompression) NewWriter(w io.Writer) (io.WriteCloser, error) {
+//		return zstd.NewWriter(w)
+//	}
+//
+//	func (zstdCompression) NewReader(r io.Reader) (io.ReadCloser, error) {
+//		d, err := zstd.NewReader(r)
+//		if err != nil {
+//			return nil, err
+//		}
+//		return d.IOReadCloser(), nil
+//	}
+type CompilationCacheCompression interface {
+	// Name identifies the compression of an entry, so that it is
+	// decompressed the same way, e.g. "zstd". It must be at most 255 bytes.
+	//
+	// Entries compressed with a compression not configured, except
+	// NewCompilationCacheDeflate, are compiled again.
+	Name() string
+
+	// NewWriter returns a writer which compresses to w when written, until
+	// closed.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+
+	// NewReader returns a reader which decompresses from r.
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+// NewCompilationCacheDeflate returns a CompilationCacheCompression with
+// DEFLATE, optimized for speed. It typically makes entries four times
+// smaller.
+func NewCompilationCacheDeflate() CompilationCacheCompression {
+	return compilationcache.Deflate
+}
+
+// WithCompilationCacheCompression configures the compression of new entries of
+// the compilation cache, which is transparent: entries written before, whether
+// uncompressed or with NewCompilationCacheDeflate, are still read.
+//
+// Usage:
+//
+//	ctx, _ := experimental.WithCompilationCacheDirName(context.Background(), "/home/me/.cache/wazero")
+//	ctx = experimental.WithCompilationCacheCompression(ctx, experimental.NewCompilationCacheDeflate())
+//	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigCompiler())
+func WithCompilationCacheCompression(ctx context.Context, compression CompilationCacheCompression) context.Context {
+	return context.WithValue(ctx, compilationcache.FileCacheCompressionKey{}, compression)
+}
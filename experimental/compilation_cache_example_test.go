@@ -37,6 +37,31 @@ func Example_withCompilationCacheDirName() {
 	//
 }
 
+// This is a basic example of compressing the entries of the file system
+// compilation cache via WithCompilationCacheCompression.
+func Example_withCompilationCacheCompression() {
+	cacheDir, err := os.MkdirTemp("", "example")
+	if err != nil {
+		log.Panicln(err)
+	}
+	defer os.RemoveAll(cacheDir)
+
+	ctx, err := experimental.WithCompilationCacheDirName(context.Background(), cacheDir)
+	if err != nil {
+		log.Panicln(err)
+	}
+	// Compress new entries with DEFLATE. Entries are decompressed when read,
+	// so this is transparent to the runtime.
+	ctx = experimental.WithCompilationCacheCompression(ctx, experimental.NewCompilationCacheDeflate())
+
+	newRuntimeCompileClose(ctx)
+	// This reuses the compressed entry.
+	newRuntimeCompileClose(ctx)
+
+	// Output:
+	//
+}
+
 // fsWasm was generated by the following:
 //
 //	cd testdata; wat2wasm --debug-names fs.wat
@@ -81,3 +81,10 @@ func requireChdirToTemp(t *testing.T) (string, string) {
 	require.NoError(t, os.Chdir(tmpDir))
 	return tmpDir, oldwd
 }
+
+func TestWithCompilationCacheCompression(t *testing.T) {
+	ctx := WithCompilationCacheCompression(context.Background(), NewCompilationCacheDeflate())
+	actual, ok := ctx.Value(compilationcache.FileCacheCompressionKey{}).(compilationcache.Compression)
+	require.True(t, ok)
+	require.Equal(t, "deflate", actual.Name())
+}
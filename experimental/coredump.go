@@ -0,0 +1,40 @@
+package experimental
+
+import "context"
+
+// CoredumpHandlerKey is a context.Context Value key. Its associated value
+// should be a CoredumpHandler.
+//
+// The value is read from the context passed to api.Function Call, including
+// the one passed to wazero.Runtime InstantiateModule for the start functions.
+// For example:
+//
+//	ctx = context.WithValue(ctx, experimental.CoredumpHandlerKey{}, experimental.CoredumpHandler(
+//		func(ctx context.Context, coredump []byte) {
+//			_ = os.WriteFile("core.wasm", coredump, 0o600)
+//		}))
+//	_, err := mod.ExportedFunction("main").Call(ctx)
+type CoredumpHandlerKey struct{}
+
+// CoredumpHandler is called with a wasm coredump when a function call traps,
+// before its frames are unwound. The coredump is a wasm binary in the format
+// of https://github.com/WebAssembly/tool-conventions/blob/main/Coredump.md,
+// which can be inspected postmortem with tools such as wasmgdb or Chrome
+// DevTools.
+//
+// The coredump includes the call stack, with the values of the locals of
+// each frame, and the memories and globals of the modules on it.
+//
+// # Notes
+//
+//   - The coredump isn't retained by the caller, so can be written
+//     asynchronously.
+//   - Nothing is dumped when a module exits, e.g. via the WASI "proc_exit",
+//     as that's not a trap.
+//   - When a host function panics with the error of a nested call which
+//     trapped, only that nested call is dumped.
+//   - The offset of the instruction of each frame is only known when the wasm
+//     binary includes DWARF sections, which tools need to map it to source.
+//   - The values of locals are only known with the interpreter. The compiler
+//     may keep them in registers, so dumps them as missing.
+type CoredumpHandler func(ctx context.Context, coredump []byte)
@@ -0,0 +1,91 @@
+package experimental_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	. "github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+func TestCoredumpHandler(t *testing.T) {
+	// Define a module whose exported function calls one which sets its local
+	// to 7, stores 42 at 8 and traps.
+	bin := binary.EncodeModule(&wasm.Module{
+		TypeSection:     []*wasm.FunctionType{{}, {Params: []api.ValueType{api.ValueTypeI32}}},
+		FunctionSection: []wasm.Index{0, 1},
+		MemorySection:   &wasm.Memory{Min: 1},
+		CodeSection: []*wasm.Code{
+			{Body: []byte{wasm.OpcodeI32Const, 42, wasm.OpcodeCall, 1, wasm.OpcodeEnd}},
+			{LocalTypes: []api.ValueType{api.ValueTypeI32}, Body: []byte{
+				wasm.OpcodeI32Const, 7, wasm.OpcodeLocalSet, 1,
+				wasm.OpcodeI32Const, 8, wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeI32Store, 2, 0,
+				wasm.OpcodeUnreachable,
+				wasm.OpcodeEnd,
+			}},
+		},
+		ExportSection: []*wasm.Export{{Name: "fn", Type: wasm.ExternTypeFunc, Index: 0}},
+	})
+
+	configs := map[string]wazero.RuntimeConfig{"interpreter": wazero.NewRuntimeConfigInterpreter()}
+	if platform.CompilerSupported() {
+		configs["compiler"] = wazero.NewRuntimeConfigCompiler()
+	}
+	for name, config := range configs {
+		name, config := name, config
+		t.Run(name, func(t *testing.T) {
+			var coredumps [][]byte
+			ctx := context.WithValue(context.Background(), CoredumpHandlerKey{}, CoredumpHandler(
+				func(_ context.Context, coredump []byte) {
+					coredumps = append(coredumps, coredump)
+				}))
+
+			r := wazero.NewRuntimeWithConfig(ctx, config)
+			defer r.Close(ctx) // This closes everything this Runtime created.
+
+			m, err := r.InstantiateModuleFromBinary(ctx, bin)
+			require.NoError(t, err)
+
+			// Nothing is dumped without the handler.
+			_, err = m.ExportedFunction("fn").Call(context.Background())
+			require.Error(t, err)
+			require.Equal(t, 0, len(coredumps))
+
+			_, err = m.ExportedFunction("fn").Call(ctx)
+			require.Error(t, err)
+			require.Equal(t, 1, len(coredumps))
+			coredump := coredumps[0]
+
+			require.True(t, bytes.HasPrefix(coredump, []byte("\x00asm\x01\x00\x00\x00")))
+			for _, section := range []string{"core", "coremodules", "coreinstances", "corestack"} {
+				require.True(t, bytes.Contains(coredump, []byte(section)), section)
+			}
+
+			// The frames are from the innermost, whose locals are only known
+			// by the interpreter.
+			frames := []byte{
+				0x00, 0x00, 0x01, 0x00, 0x02, 0x7f, 42, 0x7f, 7, 0x00,
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			}
+			if name == "compiler" {
+				frames = []byte{
+					0x00, 0x00, 0x01, 0x00, 0x02, 0x01, 0x01, 0x00,
+					0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				}
+			}
+			require.True(t, bytes.Contains(coredump, append([]byte{0x04, 'm', 'a', 'i', 'n', 0x02}, frames...)))
+
+			// The memory is dumped as a data segment of the page stored to.
+			require.True(t, bytes.Contains(coredump, []byte{
+				0x00, wasm.OpcodeI32Const, 0x00, wasm.OpcodeEnd, 0x80, 0x80, 0x04, 0, 0, 0, 0, 0, 0, 0, 0, 42,
+			}))
+		})
+	}
+}
@@ -0,0 +1,242 @@
+// Package debugging includes an experimental.FunctionListenerFactory which
+// pauses the execution of guest functions at breakpoints, to inspect them, and
+// an experimental.MemoryListener which notifies watchpoints of accesses to
+// memory.
+//
+// Note: This package is experimental, so may be changed or deleted at any
+// time.
+package debugging
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+)
+
+// Action is what a Handler returns to resume execution.
+type Action int
+
+const (
+	// Continue resumes execution until the next breakpoint.
+	Continue Action = iota
+	// StepIn resumes execution until the next function is entered, or the
+	// current function returns, whichever is first.
+	StepIn
+	// StepOver is like StepIn, except it doesn't stop in functions called by
+	// the current function.
+	StepOver
+	// StepOut resumes execution until the current function returns, or if
+	// stopped at its return, until its caller returns.
+	StepOut
+)
+
+// Reason is why execution stopped.
+type Reason int
+
+const (
+	// ReasonBreakpoint is when a function with a breakpoint is entered.
+	ReasonBreakpoint Reason = iota
+	// ReasonStep is when the Action of the last stop was a step.
+	ReasonStep
+)
+
+// Stop is the state of the execution when it stopped. It is only valid
+// until the Handler returns.
+type Stop struct {
+	// Reason is why execution stopped.
+	Reason Reason
+	// Entry is true when stopped on entry to Function, or false on its
+	// return.
+	Entry bool
+	// Module is the calling module, e.g. to read its memory.
+	Module api.Module
+	// Function is the function entered or returned from.
+	Function api.FunctionDefinition
+	// Params are the api.ValueType encoded parameters of Function, which
+	// are its first locals. These are only set on entry.
+	Params []uint64
+	// Results are the api.ValueType encoded results of Function. These are
+	// only set on a return without Err.
+	Results []uint64
+	// Err is the error when the function didn't return as its frame was
+	// unwound, e.g. due to a trap.
+	Err error
+	// Stack is the functions on the call stack, from the innermost, the
+	// Function, to the outermost.
+	Stack []api.FunctionDefinition
+}
+
+// Memory returns the memory of Module, or nil if it has none.
+func (s *Stop) Memory() api.Memory {
+	return s.Module.Memory()
+}
+
+// Handler is invoked when execution stops, and pauses it until it returns
+// the Action to resume it. For example, an interactive debugger would
+// prompt for commands until the user continues.
+//
+// If the handler panics, the function call fails, like a trap.
+type Handler func(ctx context.Context, stop *Stop) Action
+
+// Debugger is an experimental.FunctionListenerFactory which stops execution
+// on entry to functions with breakpoints, invoking a Handler to inspect it.
+// Breakpoints can be set and cleared at any time, including by the Handler.
+//
+// Here's an example of breaking on each call to "wasi_snapshot_preview1.fd_write":
+//
+//	d := debugging.NewDebugger(func(ctx context.Context, s *debugging.Stop) debugging.Action {
+//		fmt.Println(s.Function.DebugName(), s.Params)
+//		return debugging.Continue
+//	})
+//	d.SetBreakpoint("wasi_snapshot_preview1.fd_write")
+//	ctx = context.WithValue(ctx, experimental.FunctionListenerFactoryKey{}, d)
+//
+// Note: This only stops at function boundaries, so the operand stack, which
+// is empty on entry to a function, can't be inspected.
+type Debugger struct {
+	handler Handler
+
+	// mu protects breakpoints.
+	mu sync.Mutex
+	// breakpoints are the api.FunctionDefinition DebugName of functions to
+	// break on entry to.
+	breakpoints map[string]struct{}
+}
+
+// NewDebugger returns a new Debugger, which invokes the handler when
+// execution of functions compiled with it stops.
+func NewDebugger(handler Handler) *Debugger {
+	return &Debugger{handler: handler, breakpoints: map[string]struct{}{}}
+}
+
+// SetBreakpoint stops execution on entry to the function of the given
+// api.FunctionDefinition DebugName, e.g. "env.abort".
+func (d *Debugger) SetBreakpoint(name string) {
+	d.mu.Lock()
+	d.breakpoints[name] = struct{}{}
+	d.mu.Unlock()
+}
+
+// ClearBreakpoint removes a breakpoint set by SetBreakpoint.
+func (d *Debugger) ClearBreakpoint(name string) {
+	d.mu.Lock()
+	delete(d.breakpoints, name)
+	d.mu.Unlock()
+}
+
+// Breakpoints returns the names of functions with breakpoints, sorted.
+func (d *Debugger) Breakpoints() []string {
+	d.mu.Lock()
+	names := make([]string, 0, len(d.breakpoints))
+	for name := range d.breakpoints {
+		names = append(names, name)
+	}
+	d.mu.Unlock()
+	sort.Strings(names)
+	return names
+}
+
+func (d *Debugger) hasBreakpoint(def api.FunctionDefinition) bool {
+	d.mu.Lock()
+	_, ok := d.breakpoints[def.DebugName()]
+	d.mu.Unlock()
+	return ok
+}
+
+// NewListener implements experimental.FunctionListenerFactory NewListener.
+func (d *Debugger) NewListener(api.FunctionDefinition) experimental.FunctionListener {
+	return (*debugListener)(d)
+}
+
+// sessionKey is a context.Context Value key. Its associated value is the
+// *session of the outermost call with a debugListener.
+type sessionKey struct{}
+
+// session is the state of the debugger for a call from the host, including
+// nested calls.
+type session struct {
+	// stack is the functions called, from the outermost to the innermost.
+	stack []api.FunctionDefinition
+	// stepping is true when the last Action was a step, so execution stops
+	// at the next entry or return where the length of the stack, including
+	// the function, is at most stepDepth.
+	stepping  bool
+	stepDepth int
+	// stepEntries is false when the step only stops at returns.
+	stepEntries bool
+}
+
+// debugListener implements experimental.FunctionListener by stopping at
+// breakpoints and steps.
+type debugListener Debugger
+
+// Before implements experimental.FunctionListener Before.
+func (l *debugListener) Before(ctx context.Context, mod api.Module, def api.FunctionDefinition, params []uint64, _ experimental.StackIterator) context.Context {
+	s, ok := ctx.Value(sessionKey{}).(*session)
+	if !ok {
+		s = &session{}
+		ctx = context.WithValue(ctx, sessionKey{}, s)
+	}
+	s.stack = append(s.stack, def)
+
+	stop := &Stop{Entry: true, Module: mod, Function: def, Params: params}
+	if s.stepping && s.stepEntries && len(s.stack) <= s.stepDepth {
+		stop.Reason = ReasonStep
+	} else if (*Debugger)(l).hasBreakpoint(def) {
+		stop.Reason = ReasonBreakpoint
+	} else {
+		return ctx
+	}
+	l.stop(ctx, s, stop)
+	return ctx
+}
+
+// After implements experimental.FunctionListener After.
+func (l *debugListener) After(ctx context.Context, mod api.Module, def api.FunctionDefinition, results []uint64) {
+	l.exit(ctx, mod, def, results, nil)
+}
+
+// Abort implements experimental.FunctionListener Abort.
+func (l *debugListener) Abort(ctx context.Context, mod api.Module, def api.FunctionDefinition, err error) {
+	l.exit(ctx, mod, def, nil, err)
+}
+
+// exit stops on return from a function if stepping, then pops it from the
+// stack.
+func (l *debugListener) exit(ctx context.Context, mod api.Module, def api.FunctionDefinition, results []uint64, err error) {
+	s := ctx.Value(sessionKey{}).(*session)
+	if s.stepping && len(s.stack) <= s.stepDepth {
+		l.stop(ctx, s, &Stop{Reason: ReasonStep, Module: mod, Function: def, Results: results, Err: err})
+	}
+	s.stack = s.stack[:len(s.stack)-1]
+}
+
+// stop invokes the handler, and sets up the step it returns, if any.
+func (l *debugListener) stop(ctx context.Context, s *session, stop *Stop) {
+	depth := len(s.stack)
+	stop.Stack = make([]api.FunctionDefinition, depth)
+	for i, def := range s.stack {
+		stop.Stack[depth-1-i] = def
+	}
+
+	action := l.handler(ctx, stop)
+
+	s.stepping = action != Continue
+	switch action {
+	case StepIn:
+		// Stop at the next entry or return, which is at most one deeper.
+		s.stepDepth, s.stepEntries = depth+1, true
+	case StepOver:
+		// Skip the entries and returns of functions called by this one.
+		s.stepDepth, s.stepEntries = depth, true
+	case StepOut:
+		s.stepDepth, s.stepEntries = depth, false
+		if !stop.Entry {
+			// Skip the return of functions called by the caller, too.
+			s.stepDepth--
+		}
+	}
+}
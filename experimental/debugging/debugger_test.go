@@ -0,0 +1,216 @@
+package debugging
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+// compile-time check to ensure Debugger implements FunctionListenerFactory
+var _ experimental.FunctionListenerFactory = &Debugger{}
+
+// debuggedWasm is a module whose exported function "a" calls the imported
+// "env.sample", then returns the result of "b" with its param. "b" calls
+// "env.sample", then returns its param plus one.
+var debuggedWasm = binary.EncodeModule(&wasm.Module{
+	TypeSection:     []*wasm.FunctionType{{}, {Params: []api.ValueType{api.ValueTypeI32}, Results: []api.ValueType{api.ValueTypeI32}}},
+	ImportSection:   []*wasm.Import{{Module: "env", Name: "sample", Type: wasm.ExternTypeFunc, DescFunc: 0}},
+	FunctionSection: []wasm.Index{1, 1},
+	CodeSection: []*wasm.Code{
+		{Body: []byte{wasm.OpcodeCall, 0, wasm.OpcodeLocalGet, 0, wasm.OpcodeCall, 2, wasm.OpcodeEnd}},
+		{Body: []byte{wasm.OpcodeCall, 0, wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Add, wasm.OpcodeEnd}},
+	},
+	ExportSection: []*wasm.Export{{Name: "a", Type: wasm.ExternTypeFunc, Index: 1}},
+	NameSection: &wasm.NameSection{
+		ModuleName:    "test",
+		FunctionNames: wasm.NameMap{{Index: 1, Name: "a"}, {Index: 2, Name: "b"}},
+	},
+})
+
+func TestDebugger(t *testing.T) {
+	tests := []struct {
+		name        string
+		breakpoints []string
+		action      Action
+		expected    []string
+	}{
+		{
+			name:        "continue",
+			breakpoints: []string{"test.b", "env.sample"},
+			action:      Continue,
+			expected:    []string{"enter env.sample", "enter test.b", "enter env.sample"},
+		},
+		{
+			name:        "step in",
+			breakpoints: []string{"test.a"},
+			action:      StepIn,
+			expected: []string{
+				"enter test.a",
+				"enter env.sample",
+				"return env.sample",
+				"enter test.b",
+				"enter env.sample",
+				"return env.sample",
+				"return test.b",
+				"return test.a",
+			},
+		},
+		{
+			name:        "step over",
+			breakpoints: []string{"test.b"},
+			action:      StepOver,
+			expected:    []string{"enter test.b", "return test.b", "return test.a"},
+		},
+		{
+			name:        "step over stops at breakpoints",
+			breakpoints: []string{"test.a", "env.sample"},
+			action:      StepOver,
+			expected: []string{
+				"enter test.a",
+				"enter env.sample", // breakpoint
+				"return env.sample",
+				"enter test.b",
+				"enter env.sample", // breakpoint
+				"return env.sample",
+				"return test.b",
+				"return test.a",
+			},
+		},
+		{
+			name:        "step out",
+			breakpoints: []string{"env.sample"},
+			action:      StepOut,
+			expected: []string{
+				"enter env.sample",
+				"return env.sample",
+				"enter env.sample", // breakpoint
+				"return env.sample",
+				"return test.b",
+				"return test.a",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			var stops []string
+			d := NewDebugger(func(_ context.Context, s *Stop) Action {
+				if s.Entry {
+					stops = append(stops, "enter "+s.Function.DebugName())
+				} else {
+					stops = append(stops, "return "+s.Function.DebugName())
+				}
+				return tc.action
+			})
+			for _, name := range tc.breakpoints {
+				d.SetBreakpoint(name)
+			}
+
+			mod := instantiate(t, d)
+			_, err := mod.ExportedFunction("a").Call(testCtx, 41)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, stops)
+		})
+	}
+}
+
+func TestDebugger_Stop(t *testing.T) {
+	var stops []*Stop
+	var stacks [][]string
+	d := NewDebugger(func(_ context.Context, s *Stop) Action {
+		var stack []string
+		for _, def := range s.Stack {
+			stack = append(stack, def.DebugName())
+		}
+		stacks = append(stacks, stack)
+
+		stops = append(stops, &Stop{Reason: s.Reason, Entry: s.Entry, Params: s.Params, Results: s.Results})
+		return StepOver
+	})
+	d.SetBreakpoint("test.b")
+
+	mod := instantiate(t, d)
+	_, err := mod.ExportedFunction("a").Call(testCtx, 41)
+	require.NoError(t, err)
+
+	require.Equal(t, [][]string{{"test.b", "test.a"}, {"test.b", "test.a"}, {"test.a"}}, stacks)
+	require.Equal(t, []*Stop{
+		{Reason: ReasonBreakpoint, Entry: true, Params: []uint64{41}},
+		{Reason: ReasonStep, Results: []uint64{42}},
+		{Reason: ReasonStep, Results: []uint64{42}},
+	}, stops)
+}
+
+func TestDebugger_Abort(t *testing.T) {
+	var errs []error
+	d := NewDebugger(func(_ context.Context, s *Stop) Action {
+		errs = append(errs, s.Err)
+		return StepOut
+	})
+	d.SetBreakpoint("test.b")
+
+	r := wazero.NewRuntimeWithConfig(testCtx, wazero.NewRuntimeConfigInterpreter())
+	defer r.Close(testCtx)
+
+	ctx := context.WithValue(testCtx, experimental.FunctionListenerFactoryKey{}, d)
+	_, err := r.NewHostModuleBuilder("env").
+		NewFunctionBuilder().WithFunc(func(context.Context) {
+		if len(errs) > 0 {
+			panic("boom")
+		}
+	}).Export("sample").
+		Instantiate(ctx, r)
+	require.NoError(t, err)
+	mod, err := r.InstantiateModuleFromBinary(ctx, debuggedWasm)
+	require.NoError(t, err)
+
+	_, err = mod.ExportedFunction("a").Call(testCtx, 41)
+	require.Error(t, err)
+
+	// Stepping out of "test.b" stops when it aborts, then "test.a".
+	require.Equal(t, 3, len(errs))
+	require.Nil(t, errs[0])
+	require.Contains(t, errs[1].Error(), "boom")
+	require.Contains(t, errs[2].Error(), "boom")
+}
+
+func TestDebugger_Breakpoints(t *testing.T) {
+	d := NewDebugger(nil)
+	require.Equal(t, []string{}, d.Breakpoints())
+
+	d.SetBreakpoint("test.b")
+	d.SetBreakpoint("test.a")
+	d.SetBreakpoint("test.b")
+	require.Equal(t, []string{"test.a", "test.b"}, d.Breakpoints())
+
+	d.ClearBreakpoint("test.b")
+	d.ClearBreakpoint("test.c")
+	require.Equal(t, []string{"test.a"}, d.Breakpoints())
+}
+
+// testCtx is an arbitrary, non-default context. Non-nil also prevents linter errors.
+var testCtx = context.WithValue(context.Background(), struct{}{}, "arbitrary")
+
+// instantiate instantiates debuggedWasm with the debugger, closing the
+// runtime when the test ends.
+func instantiate(t *testing.T, d *Debugger) api.Module {
+	ctx := context.WithValue(testCtx, experimental.FunctionListenerFactoryKey{}, d)
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+	t.Cleanup(func() { r.Close(ctx) })
+
+	_, err := r.NewHostModuleBuilder("env").
+		NewFunctionBuilder().WithFunc(func(context.Context) {}).Export("sample").
+		Instantiate(ctx, r)
+	require.NoError(t, err)
+
+	mod, err := r.InstantiateModuleFromBinary(ctx, debuggedWasm)
+	require.NoError(t, err)
+	return mod
+}
@@ -0,0 +1,121 @@
+package debugging
+
+import (
+	"context"
+	"math"
+	"sync"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+)
+
+// WatchKind is the kind of memory access a watchpoint is triggered by.
+type WatchKind int
+
+const (
+	// WatchRead is triggered by loads.
+	WatchRead WatchKind = 1 << iota
+	// WatchWrite is triggered by stores.
+	WatchWrite
+	// WatchReadWrite is triggered by loads and stores.
+	WatchReadWrite = WatchRead | WatchWrite
+)
+
+// WatchHandler is invoked before a guest function accesses memory watched by
+// a watchpoint, with the function and the offset of the access. If the
+// handler panics, the function call fails, like a trap.
+type WatchHandler func(ctx context.Context, mod api.Module, def api.FunctionDefinition, access experimental.MemoryAccess)
+
+// Watchpoints is an experimental.MemoryListener which invokes a WatchHandler
+// when guest functions read or write memory watched by a watchpoint.
+// Watchpoints can be set and cleared at any time, including by a handler.
+//
+// Here's an example of finding which function overwrites a value at 1024:
+//
+//	w := debugging.NewWatchpoints()
+//	w.SetWatchpoint(experimental.WatchRegion{Offset: 1024, Length: 4}, debugging.WatchWrite,
+//		func(ctx context.Context, mod api.Module, def api.FunctionDefinition, access experimental.MemoryAccess) {
+//			fmt.Println(def.DebugName(), "writes", access.Size, "bytes at", access.Offset)
+//		})
+//	rConfig = wazero.NewRuntimeConfigInterpreter().WithMemoryListener(w)
+//
+// # Notes
+//
+//   - As watchpoints can be set after compilation, each load and store is
+//     instrumented, so this is much slower. See experimental.MemoryListenerKey
+//     for the accesses which aren't instrumented.
+//   - Accesses are checked before they're bounds checked, so an access which
+//     traps may trigger a watchpoint.
+type Watchpoints struct {
+	// mu protects watchpoints and nextID.
+	mu sync.Mutex
+	// watchpoints are in the order they were set.
+	watchpoints []*watchpoint
+	nextID      int
+}
+
+type watchpoint struct {
+	id      int
+	region  experimental.WatchRegion
+	kind    WatchKind
+	handler WatchHandler
+}
+
+// NewWatchpoints returns a new Watchpoints with none set.
+func NewWatchpoints() *Watchpoints {
+	return &Watchpoints{}
+}
+
+// SetWatchpoint invokes the handler before each access of the kind to memory
+// overlapping the region, and returns the ID to clear it with
+// ClearWatchpoint.
+func (w *Watchpoints) SetWatchpoint(region experimental.WatchRegion, kind WatchKind, handler WatchHandler) (id int) {
+	w.mu.Lock()
+	w.nextID++
+	id = w.nextID
+	w.watchpoints = append(w.watchpoints, &watchpoint{id: id, region: region, kind: kind, handler: handler})
+	w.mu.Unlock()
+	return
+}
+
+// ClearWatchpoint removes a watchpoint set by SetWatchpoint.
+func (w *Watchpoints) ClearWatchpoint(id int) {
+	w.mu.Lock()
+	for i, wp := range w.watchpoints {
+		if wp.id == id {
+			w.watchpoints = append(w.watchpoints[:i:i], w.watchpoints[i+1:]...)
+			break
+		}
+	}
+	w.mu.Unlock()
+}
+
+// WatchRegions implements experimental.MemoryListener WatchRegions by
+// watching all memory, as watchpoints may be set after compilation.
+func (w *Watchpoints) WatchRegions() []experimental.WatchRegion {
+	return []experimental.WatchRegion{{Offset: 0, Length: math.MaxUint32}}
+}
+
+// OnMemoryAccess implements experimental.MemoryListener OnMemoryAccess by
+// invoking the handler of each watchpoint the access triggers.
+func (w *Watchpoints) OnMemoryAccess(ctx context.Context, mod api.Module, def api.FunctionDefinition, access experimental.MemoryAccess) {
+	kind := WatchRead
+	if access.Store {
+		kind = WatchWrite
+	}
+
+	// Handlers are invoked without the lock, so that they can set or clear
+	// watchpoints.
+	var triggered []WatchHandler
+	w.mu.Lock()
+	for _, wp := range w.watchpoints {
+		if wp.kind&kind != 0 && wp.region.Overlaps(access.Offset, access.Size) {
+			triggered = append(triggered, wp.handler)
+		}
+	}
+	w.mu.Unlock()
+
+	for _, handler := range triggered {
+		handler(ctx, mod, def, access)
+	}
+}
@@ -0,0 +1,108 @@
+package debugging
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+// compile-time check to ensure Watchpoints implements MemoryListener
+var _ experimental.MemoryListener = &Watchpoints{}
+
+// watchedWasm is a module whose exported function "fn" stores 42 at its
+// param, then loads from its param plus 4.
+var watchedWasm = binary.EncodeModule(&wasm.Module{
+	TypeSection:     []*wasm.FunctionType{{Params: []api.ValueType{api.ValueTypeI32}}},
+	FunctionSection: []wasm.Index{0},
+	MemorySection:   &wasm.Memory{Min: 1},
+	CodeSection: []*wasm.Code{{Body: []byte{
+		wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Const, 42,
+		wasm.OpcodeI32Store, 2, 0,
+		wasm.OpcodeLocalGet, 0,
+		wasm.OpcodeI32Load, 2, 4,
+		wasm.OpcodeDrop,
+		wasm.OpcodeEnd,
+	}}},
+	ExportSection: []*wasm.Export{{Name: "fn", Type: wasm.ExternTypeFunc, Index: 0}},
+	NameSection: &wasm.NameSection{
+		ModuleName:    "test",
+		FunctionNames: wasm.NameMap{{Index: 0, Name: "fn"}},
+	},
+})
+
+func TestWatchpoints(t *testing.T) {
+	configs := map[string]wazero.RuntimeConfig{"interpreter": wazero.NewRuntimeConfigInterpreter()}
+	if platform.CompilerSupported() {
+		configs["compiler"] = wazero.NewRuntimeConfigCompiler()
+	}
+	for name, config := range configs {
+		config := config
+		t.Run(name, func(t *testing.T) {
+			var hits []string
+			record := func(name string) WatchHandler {
+				return func(_ context.Context, _ api.Module, def api.FunctionDefinition, access experimental.MemoryAccess) {
+					hits = append(hits, fmt.Sprintf("%s: %s %+v", name, def.DebugName(), access))
+				}
+			}
+
+			ctx := context.Background()
+			w := NewWatchpoints()
+			r := wazero.NewRuntimeWithConfig(ctx, config.WithMemoryListener(w))
+			defer r.Close(ctx) // This closes everything this Runtime created.
+
+			m, err := r.InstantiateModuleFromBinary(ctx, watchedWasm)
+			require.NoError(t, err)
+			fn := m.ExportedFunction("fn")
+
+			// Watchpoints set after compilation are triggered.
+			w.SetWatchpoint(experimental.WatchRegion{Offset: 8, Length: 4}, WatchWrite, record("write"))
+			w.SetWatchpoint(experimental.WatchRegion{Offset: 12, Length: 4}, WatchRead, record("read"))
+			all := w.SetWatchpoint(experimental.WatchRegion{Offset: 0, Length: 16}, WatchReadWrite, record("all"))
+
+			_, err = fn.Call(ctx, 8)
+			require.NoError(t, err)
+			require.Equal(t, []string{
+				"write: test.fn {Offset:8 Size:4 Store:true}",
+				"all: test.fn {Offset:8 Size:4 Store:true}",
+				"read: test.fn {Offset:12 Size:4 Store:false}",
+				"all: test.fn {Offset:12 Size:4 Store:false}",
+			}, hits)
+
+			// Accesses outside the regions or of another kind aren't.
+			hits = nil
+			w.ClearWatchpoint(all)
+			_, err = fn.Call(ctx, 12)
+			require.NoError(t, err)
+			require.Nil(t, hits)
+
+			// A handler can clear its own watchpoint.
+			var once int
+			once = w.SetWatchpoint(experimental.WatchRegion{Offset: 100, Length: 1}, WatchWrite,
+				func(ctx context.Context, mod api.Module, def api.FunctionDefinition, access experimental.MemoryAccess) {
+					record("once")(ctx, mod, def, access)
+					w.ClearWatchpoint(once)
+				})
+			for i := 0; i < 2; i++ {
+				_, err = fn.Call(ctx, 100)
+				require.NoError(t, err)
+			}
+			require.Equal(t, []string{"once: test.fn {Offset:100 Size:4 Store:true}"}, hits)
+
+			// A handler which panics fails the call.
+			w.SetWatchpoint(experimental.WatchRegion{Offset: 200, Length: 4}, WatchRead,
+				func(context.Context, api.Module, api.FunctionDefinition, experimental.MemoryAccess) {
+					panic("watched")
+				})
+			_, err = fn.Call(ctx, 196)
+			require.Contains(t, err.Error(), "watched")
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package experimental
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// EventListenerKey is a context.Context Value key. Its associated value
+// should be an EventListener.
+//
+// The value is read from the context passed to wazero.NewRuntimeWithConfig
+// and receives the events of all modules of that runtime, including host
+// modules. For example:
+//
+//	ctx = context.WithValue(ctx, experimental.EventListenerKey{}, listener)
+//	r := wazero.NewRuntime(ctx)
+type EventListenerKey struct{}
+
+// EventType is the type of Event.
+type EventType uint8
+
+const (
+	// EventModuleCompiled is after a module is compiled, e.g. by
+	// wazero.Runtime CompileModule.
+	EventModuleCompiled EventType = iota + 1
+
+	// EventModuleInstantiated is after a module is instantiated, including
+	// its start function.
+	EventModuleInstantiated
+
+	// EventMemoryGrown is after the memory defined by a module grows, e.g. by
+	// the "memory.grow" instruction or api.Memory Grow.
+	EventMemoryGrown
+
+	// EventModuleClosed is after a module is closed, e.g. by api.Module
+	// CloseWithExitCode or closing the runtime.
+	EventModuleClosed
+)
+
+// String returns the name of the event type, e.g. "module_compiled".
+func (t EventType) String() string {
+	switch t {
+	case EventModuleCompiled:
+		return "module_compiled"
+	case EventModuleInstantiated:
+		return "module_instantiated"
+	case EventMemoryGrown:
+		return "memory_grown"
+	case EventModuleClosed:
+		return "module_closed"
+	}
+	return fmt.Sprintf("event(%d)", t)
+}
+
+// Event is a change in the lifecycle of a module.
+type Event struct {
+	// Type is the type of the event, which defines the fields set.
+	Type EventType
+
+	// ModuleName is the name of the module instance, or the name in the
+	// custom name section, possibly empty, for EventModuleCompiled.
+	ModuleName string
+
+	// Module is the module instance, or nil for EventModuleCompiled.
+	Module api.Module
+
+	// PreviousPages and Pages are the size of the memory before and after
+	// EventMemoryGrown.
+	PreviousPages, Pages uint32
+
+	// ExitCode is the exit code of EventModuleClosed.
+	ExitCode uint32
+}
+
+// EventListener is notified of each Event of a runtime, e.g. to centralize
+// bookkeeping of instances or to export metrics, instead of wrapping every
+// call site which compiles, instantiates or closes modules.
+//
+// # Notes
+//
+//   - The context of EventMemoryGrown is the one the module was instantiated
+//     with, as growth can happen outside a call, e.g. via api.Memory Grow.
+//   - OnEvent can be called concurrently, for example if modules are
+//     instantiated from different goroutines.
+//   - OnEvent must not close the module of the event.
+type EventListener interface {
+	// OnEvent is called after the event occurred.
+	OnEvent(ctx context.Context, event Event)
+}
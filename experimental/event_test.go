@@ -0,0 +1,104 @@
+package experimental_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	. "github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+// compile-time check to ensure eventRecorder implements EventListener
+var _ EventListener = &eventRecorder{}
+
+type eventRecorder struct {
+	events []string
+}
+
+func (r *eventRecorder) OnEvent(_ context.Context, e Event) {
+	switch e.Type {
+	case EventModuleCompiled:
+		r.events = append(r.events, fmt.Sprintf("%s %s", e.Type, e.ModuleName))
+	case EventMemoryGrown:
+		r.events = append(r.events, fmt.Sprintf("%s %s %d->%d", e.Type, e.Module.Name(), e.PreviousPages, e.Pages))
+	case EventModuleClosed:
+		r.events = append(r.events, fmt.Sprintf("%s %s %d", e.Type, e.Module.Name(), e.ExitCode))
+	default:
+		r.events = append(r.events, fmt.Sprintf("%s %s", e.Type, e.Module.Name()))
+	}
+}
+
+func TestEventListener(t *testing.T) {
+	// Define a module whose function grows memory by its param.
+	bin := binary.EncodeModule(&wasm.Module{
+		TypeSection:     []*wasm.FunctionType{{Params: []api.ValueType{api.ValueTypeI32}}},
+		FunctionSection: []wasm.Index{0},
+		MemorySection:   &wasm.Memory{Min: 1, Max: 3, IsMaxEncoded: true},
+		CodeSection: []*wasm.Code{{Body: []byte{
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeMemoryGrow, 0,
+			wasm.OpcodeDrop,
+			wasm.OpcodeEnd,
+		}}},
+		ExportSection: []*wasm.Export{{Name: "grow", Type: wasm.ExternTypeFunc, Index: 0}},
+		NameSection:   &wasm.NameSection{ModuleName: "test"},
+	})
+
+	configs := map[string]wazero.RuntimeConfig{"interpreter": wazero.NewRuntimeConfigInterpreter()}
+	if platform.CompilerSupported() {
+		configs["compiler"] = wazero.NewRuntimeConfigCompiler()
+	}
+
+	for name, config := range configs {
+		config := config
+		t.Run(name, func(t *testing.T) {
+			rec := &eventRecorder{}
+			ctx := context.WithValue(context.Background(), EventListenerKey{}, rec)
+			r := wazero.NewRuntimeWithConfig(ctx, config)
+
+			_, err := r.NewHostModuleBuilder("env").Instantiate(ctx, r)
+			require.NoError(t, err)
+
+			compiled, err := r.CompileModule(ctx, bin)
+			require.NoError(t, err)
+			mod, err := r.InstantiateModule(ctx, compiled, wazero.NewModuleConfig().WithName("a"))
+			require.NoError(t, err)
+
+			grow := mod.ExportedFunction("grow")
+			_, err = grow.Call(ctx, 1)
+			require.NoError(t, err)
+			_, err = grow.Call(ctx, 0) // no-op
+			require.NoError(t, err)
+			_, err = grow.Call(ctx, 2) // exceeds the max
+			require.NoError(t, err)
+			_, ok := mod.Memory().Grow(1)
+			require.True(t, ok)
+
+			require.NoError(t, mod.CloseWithExitCode(ctx, 2))
+			require.NoError(t, r.Close(ctx))
+
+			require.Equal(t, []string{
+				"module_compiled env",
+				"module_instantiated env",
+				"module_compiled test",
+				"module_instantiated a",
+				"memory_grown a 1->2",
+				"memory_grown a 2->3",
+				"module_closed a 2",
+				"module_closed env 0",
+			}, rec.events)
+		})
+	}
+}
+
+func TestEventType_String(t *testing.T) {
+	require.Equal(t, "module_compiled", EventModuleCompiled.String())
+	require.Equal(t, "module_closed", EventModuleClosed.String())
+	require.Equal(t, "event(0)", EventType(0).String())
+}
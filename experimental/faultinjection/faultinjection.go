@@ -0,0 +1,186 @@
+// Package faultinjection includes an experimental.FunctionListenerFactory
+// which changes the outcome of selected calls, e.g. to make the third
+// "fd_write" fail with ENOSPC. This tests how a guest handles errors without
+// modifying it or the host module it imports.
+//
+// Note: This package is experimental, so may be changed or deleted at any
+// time.
+package faultinjection
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+)
+
+// Fault changes the outcome of a call after the function returned, e.g. by
+// replacing its results or what it wrote to memory.
+//
+// # Params
+//
+//   - ctx: the context of the call.
+//   - mod: the calling module, e.g. to write its memory.
+//   - def: the function definition.
+//   - params: api.ValueType encoded parameters the function was called with.
+//   - results: api.ValueType encoded results, which can be overwritten.
+//
+// Note: The function was called, so its side effects, such as writing a
+// file, occurred regardless.
+type Fault func(ctx context.Context, mod api.Module, def api.FunctionDefinition, params, results []uint64)
+
+// Return is a Fault which replaces the results of the call, e.g. with an
+// errno. For example, Return(28) makes a WASI function fail with ENOSPC.
+func Return(results ...uint64) Fault {
+	return func(_ context.Context, _ api.Module, _ api.FunctionDefinition, _, r []uint64) {
+		copy(r, results)
+	}
+}
+
+// ShiftClock is a Fault which adds the duration to the timestamp written by
+// a successful call to "clock_time_get" of "wasi_snapshot_preview1", e.g.
+// to test how the guest handles the clock jumping forward.
+func ShiftClock(d time.Duration) Fault {
+	return func(_ context.Context, mod api.Module, def api.FunctionDefinition, params, results []uint64) {
+		if results[0] != 0 || mod == nil || mod.Memory() == nil { // errno != ESUCCESS
+			return
+		}
+		for i, n := range def.ParamNames() {
+			if n != "result.timestamp" {
+				continue
+			}
+			offset := uint32(params[i])
+			if ts, ok := mod.Memory().ReadUint64Le(offset); ok {
+				mod.Memory().WriteUint64Le(offset, ts+uint64(d))
+			}
+		}
+	}
+}
+
+// Condition returns true if the call should be faulted. call is the count of
+// calls to the function so far, including this one, so one on the first.
+type Condition func(call uint64) bool
+
+// Always is a Condition which faults all calls.
+func Always(uint64) bool {
+	return true
+}
+
+// OnCall is a Condition which faults only the nth calls, counting from one.
+func OnCall(n ...uint64) Condition {
+	return func(call uint64) bool {
+		for _, c := range n {
+			if c == call {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// FromCall is a Condition which faults the nth call, counting from one, and
+// all after it.
+func FromCall(n uint64) Condition {
+	return func(call uint64) bool {
+		return call >= n
+	}
+}
+
+// Injector is an experimental.FunctionListenerFactory which injects faults
+// into calls to functions. For example, this makes the third "fd_write" of a
+// guest fail with ENOSPC:
+//
+//	injector := faultinjection.NewInjector().
+//		Inject("wasi_snapshot_preview1", "fd_write", faultinjection.OnCall(3), faultinjection.Return(28))
+//	ctx = context.WithValue(ctx, experimental.FunctionListenerFactoryKey{}, injector)
+//
+// Note: This must be configured before modules are compiled.
+type Injector struct {
+	rules map[functionKey][]rule
+}
+
+// functionKey is the module and function name of a rule.
+type functionKey struct {
+	moduleName, name string
+}
+
+type rule struct {
+	when  Condition
+	fault Fault
+}
+
+// NewInjector returns an Injector without faults.
+func NewInjector() *Injector {
+	return &Injector{rules: map[functionKey][]rule{}}
+}
+
+// Inject adds a fault to calls to the function named name in the module named
+// moduleName, when the condition returns true. Faults are applied in the
+// order they were added, so later ones see the results of earlier ones.
+func (i *Injector) Inject(moduleName, name string, when Condition, fault Fault) *Injector {
+	key := functionKey{moduleName, name}
+	i.rules[key] = append(i.rules[key], rule{when: when, fault: fault})
+	return i
+}
+
+// NewListener implements experimental.FunctionListenerFactory NewListener.
+func (i *Injector) NewListener(def api.FunctionDefinition) experimental.FunctionListener {
+	rules := i.rules[functionKey{def.ModuleName(), def.Name()}]
+	if len(rules) == 0 {
+		return nil
+	}
+	return &listener{rules: rules}
+}
+
+// callKey is a context.Context Value key. Its associated value is the *call
+// in progress, or nil if it isn't faulted.
+type callKey struct{}
+
+// call is a call which is faulted when it returns.
+type call struct {
+	params []uint64
+	faults []Fault
+}
+
+type listener struct {
+	// calls is first for 64-bit alignment of atomic access.
+	calls uint64
+	rules []rule
+}
+
+// Before implements experimental.FunctionListener Before.
+func (l *listener) Before(ctx context.Context, _ api.Module, _ api.FunctionDefinition, params []uint64, _ experimental.StackIterator) context.Context {
+	n := atomic.AddUint64(&l.calls, 1)
+	var c *call
+	for _, r := range l.rules {
+		if !r.when(n) {
+			continue
+		}
+		if c == nil {
+			// Copy the params, as the engine reuses them for results.
+			c = &call{params: append([]uint64(nil), params...)}
+		}
+		c.faults = append(c.faults, r.fault)
+	}
+	if caller, _ := ctx.Value(callKey{}).(*call); c == nil && caller == nil {
+		return ctx
+	}
+	// Set even if nil, so that a faulted caller isn't mistaken for this call.
+	return context.WithValue(ctx, callKey{}, c)
+}
+
+// After implements experimental.FunctionListener After.
+func (l *listener) After(ctx context.Context, mod api.Module, def api.FunctionDefinition, results []uint64) {
+	c, _ := ctx.Value(callKey{}).(*call)
+	if c == nil {
+		return
+	}
+	for _, f := range c.faults {
+		f(ctx, mod, def, c.params, results)
+	}
+}
+
+// Abort implements experimental.FunctionListener Abort.
+func (l *listener) Abort(context.Context, api.Module, api.FunctionDefinition, error) {}
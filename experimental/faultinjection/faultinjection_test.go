@@ -0,0 +1,136 @@
+package faultinjection
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+// compile-time check to ensure Injector implements FunctionListenerFactory
+var _ experimental.FunctionListenerFactory = &Injector{}
+
+var (
+	i32, i64 = api.ValueTypeI32, api.ValueTypeI64
+	// faultedWasm is a module whose exported function "write" returns the
+	// errno of an empty "fd_write" to stdout, and "now" returns the errno of
+	// "clock_time_get" of the realtime clock, writing the timestamp at 8.
+	faultedWasm = binary.EncodeModule(&wasm.Module{
+		TypeSection: []*wasm.FunctionType{
+			{Params: []api.ValueType{i32, i32, i32, i32}, Results: []api.ValueType{i32}},
+			{Params: []api.ValueType{i32, i64, i32}, Results: []api.ValueType{i32}},
+			{Results: []api.ValueType{i32}},
+		},
+		ImportSection: []*wasm.Import{
+			{Module: "wasi_snapshot_preview1", Name: "fd_write", Type: wasm.ExternTypeFunc, DescFunc: 0},
+			{Module: "wasi_snapshot_preview1", Name: "clock_time_get", Type: wasm.ExternTypeFunc, DescFunc: 1},
+		},
+		FunctionSection: []wasm.Index{2, 2},
+		MemorySection:   &wasm.Memory{Min: 1, Max: 1, IsMaxEncoded: true},
+		CodeSection: []*wasm.Code{
+			{Body: []byte{
+				wasm.OpcodeI32Const, 1, wasm.OpcodeI32Const, 0, wasm.OpcodeI32Const, 0, wasm.OpcodeI32Const, 16,
+				wasm.OpcodeCall, 0,
+				wasm.OpcodeEnd,
+			}},
+			{Body: []byte{
+				wasm.OpcodeI32Const, 0, wasm.OpcodeI64Const, 0, wasm.OpcodeI32Const, 8,
+				wasm.OpcodeCall, 1,
+				wasm.OpcodeEnd,
+			}},
+		},
+		ExportSection: []*wasm.Export{
+			{Name: "write", Type: wasm.ExternTypeFunc, Index: 2},
+			{Name: "now", Type: wasm.ExternTypeFunc, Index: 3},
+		},
+	})
+)
+
+func TestInjector(t *testing.T) {
+	configs := map[string]wazero.RuntimeConfig{"interpreter": wazero.NewRuntimeConfigInterpreter()}
+	if platform.CompilerSupported() {
+		configs["compiler"] = wazero.NewRuntimeConfigCompiler()
+	}
+
+	for name, config := range configs {
+		config := config
+		t.Run(name, func(t *testing.T) {
+			injector := NewInjector().
+				Inject("wasi_snapshot_preview1", "fd_write", OnCall(2), Return(28)).   // ENOSPC
+				Inject("wasi_snapshot_preview1", "fd_write", FromCall(4), Return(29)). // EIO
+				Inject("wasi_snapshot_preview1", "clock_time_get", Always, ShiftClock(time.Hour))
+			ctx := context.WithValue(context.Background(), experimental.FunctionListenerFactoryKey{}, injector)
+
+			r := wazero.NewRuntimeWithConfig(ctx, config)
+			defer r.Close(ctx)
+
+			wasi_snapshot_preview1.MustInstantiate(ctx, r)
+			mod, err := r.InstantiateModuleFromBinary(ctx, faultedWasm)
+			require.NoError(t, err)
+
+			var errnos []uint64
+			for i := 0; i < 5; i++ {
+				results, err := mod.ExportedFunction("write").Call(ctx)
+				require.NoError(t, err)
+				errnos = append(errnos, results[0])
+			}
+			require.Equal(t, []uint64{0, 28, 0, 29, 29}, errnos)
+
+			results, err := mod.ExportedFunction("now").Call(ctx)
+			require.NoError(t, err)
+			require.Equal(t, uint64(0), results[0])
+			ts, ok := mod.Memory().ReadUint64Le(8)
+			require.True(t, ok)
+			require.Equal(t, uint64(platform.FakeEpochNanos+int64(time.Hour)), ts)
+		})
+	}
+}
+
+func TestInjector_NewListener(t *testing.T) {
+	injector := NewInjector().Inject("env", "a", Always, Return(1))
+
+	m := &wasm.Module{
+		TypeSection:     []*wasm.FunctionType{{}},
+		FunctionSection: []wasm.Index{0, 0},
+		CodeSection:     []*wasm.Code{wasm.MustParseGoReflectFuncCode(func() {}), wasm.MustParseGoReflectFuncCode(func() {})},
+		NameSection: &wasm.NameSection{
+			ModuleName:    "env",
+			FunctionNames: wasm.NameMap{{Index: 0, Name: "a"}, {Index: 1, Name: "b"}},
+		},
+	}
+	m.BuildFunctionDefinitions()
+
+	require.NotNil(t, injector.NewListener(m.FunctionDefinitionSection[0]))
+	require.Nil(t, injector.NewListener(m.FunctionDefinitionSection[1]))
+}
+
+func TestConditions(t *testing.T) {
+	tests := []struct {
+		name     string
+		when     Condition
+		expected []bool
+	}{
+		{name: "Always", when: Always, expected: []bool{true, true, true, true}},
+		{name: "OnCall", when: OnCall(1, 3), expected: []bool{true, false, true, false}},
+		{name: "FromCall", when: FromCall(3), expected: []bool{false, false, true, true}},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			var actual []bool
+			for call := uint64(1); call <= 4; call++ {
+				actual = append(actual, tc.when(call))
+			}
+			require.Equal(t, tc.expected, actual)
+		})
+	}
+}
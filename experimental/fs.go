@@ -0,0 +1,22 @@
+package experimental
+
+import "github.com/tetratelabs/wazero/internal/sysfs"
+
+// VerifyDirMountConfinement checks that a directory mounted with
+// wazero.FSConfig WithDirMount or WithReadOnlyDirMount can't be escaped via
+// symbolic links on this host, returning an error describing the first
+// escape, if any. This is intended for security reviews and startup checks
+// of hosts that mount directories a guest can write to.
+//
+// To check, a temporary directory is created in tmpDir, e.g. os.TempDir(),
+// containing a secret file and a mounted directory with symbolic links which
+// point to the secret in various ways, such as with ".." or an absolute host
+// path. Each is then read, written, renamed and used as a parent directory,
+// through the mount. The temporary directory is removed on return.
+//
+// Note: This can't detect escapes by a concurrent process replacing a parent
+// directory with a symbolic link, as resolution isn't atomic. Don't mount a
+// directory that untrusted processes can also write to.
+func VerifyDirMountConfinement(tmpDir string) error {
+	return sysfs.VerifyConfinement(tmpDir)
+}
@@ -0,0 +1,11 @@
+package experimental
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestVerifyDirMountConfinement(t *testing.T) {
+	require.NoError(t, VerifyDirMountConfinement(t.TempDir()))
+}
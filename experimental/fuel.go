@@ -0,0 +1,31 @@
+package experimental
+
+// FuelKey is a context.Context Value key. Its associated value should be a
+// *Fuel.
+//
+// When present in the context passed to wazero.Runtime CompileModule, the
+// module's functions are metered: they consume one unit of fuel when called,
+// and each time a loop starts an iteration. This deterministically bounds the
+// computation of a call, regardless of the host it runs on.
+//
+// When present in the context of a call to a metered function, the call
+// consumes the Fuel, and traps with sys.TrapKindFuelExhausted once none
+// remains. Calls without it aren't limited. For example:
+//
+//	fuel := &experimental.Fuel{Remaining: 1000000}
+//	ctx = context.WithValue(ctx, experimental.FuelKey{}, fuel)
+//	compiled, _ := r.CompileModule(ctx, wasm)
+//	_, err := r.InstantiateModule(ctx, compiled, config) // runs _start
+//
+// Note: Host functions don't consume fuel, so their own work isn't bounded.
+type FuelKey struct{}
+
+// Fuel is the budget of computation of calls to metered functions. See
+// FuelKey.
+//
+// Note: This is not safe for concurrent use, so calls which run at the same
+// time must use different Fuel.
+type Fuel struct {
+	// Remaining is the units of fuel left.
+	Remaining uint64
+}
@@ -0,0 +1,157 @@
+// Package fuzz includes entry points for fuzzers, such as go-fuzz or
+// libFuzzer via go-114-fuzz-build, to run against the binary decoder,
+// validator and compiler of wazero, and helpers to triage the inputs they
+// find.
+//
+// Each entry point has the signature go-fuzz expects: it returns 1 if the
+// input was valid and should be prioritized in the corpus, and 0 otherwise.
+// An entry point only panics if wazero has a bug, e.g.
+//
+//	// Fuzz is the go-fuzz entry point of the integration.
+//	func Fuzz(data []byte) int {
+//		return fuzz.Compile(wazero.NewRuntimeConfigCompiler())(data)
+//	}
+//
+// wazero doesn't include a parser of the text format, so there's no entry
+// point for it.
+//
+// Note: This package is experimental, so may be changed or deleted at any
+// time.
+package fuzz
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+// features are the features enabled by the entry points.
+const features = api.CoreFeaturesV2
+
+// Decode is an entry point for the binary decoder, which returns 1 if data
+// decodes.
+func Decode(data []byte) int {
+	if _, err := decode(data); err != nil {
+		return 0
+	}
+	return 1
+}
+
+// Validate is an entry point for the binary decoder and the validator, which
+// returns 1 if data decodes into a valid module.
+func Validate(data []byte) int {
+	m, err := decode(data)
+	if err != nil {
+		return 0
+	}
+	if err = m.Validate(features); err != nil {
+		return 0
+	}
+	return 1
+}
+
+// Compile returns an entry point for wazero.Runtime CompileModule with the
+// given config, e.g. to fuzz the compiler, which returns 1 if data compiles.
+func Compile(config wazero.RuntimeConfig) func(data []byte) int {
+	return func(data []byte) int {
+		ctx := context.Background()
+		r := wazero.NewRuntimeWithConfig(ctx, config)
+		defer r.Close(ctx)
+		if _, err := r.CompileModule(ctx, data); err != nil {
+			return 0
+		}
+		return 1
+	}
+}
+
+func decode(data []byte) (*wasm.Module, error) {
+	return binary.DecodeModule(data, features, wasm.MemoryLimitPages, false, false, false)
+}
+
+// Normalize returns data decoded and encoded again, which drops custom
+// sections besides the name section and encodes each integer in as few bytes
+// as possible. This helps compare and deduplicate inputs found by a fuzzer.
+//
+// An error is returned if data doesn't decode, or if it uses features which
+// can't be encoded yet, e.g. passive data segments.
+func Normalize(data []byte) ([]byte, error) {
+	m, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	ret := encode(m)
+	if ret == nil {
+		return nil, errNotNormalized
+	}
+	normalized, err := decode(ret)
+	if err != nil {
+		return nil, errNotNormalized
+	}
+	// The code section moves when sections before it are shorter.
+	normalized.CodeSectionOffset = m.CodeSectionOffset
+	if !reflect.DeepEqual(m, normalized) {
+		return nil, errNotNormalized
+	}
+	return ret, nil
+}
+
+var errNotNormalized = errors.New("module can't be normalized")
+
+// encode returns the module encoded, or nil if the encoder panicked on
+// features it doesn't support.
+func encode(m *wasm.Module) (ret []byte) {
+	defer func() {
+		if recover() != nil {
+			ret = nil
+		}
+	}()
+	return binary.EncodeModule(m)
+}
+
+// Minimize returns the smallest input it can find, by removing bytes from
+// data, for which interesting returns true, e.g. because it panics the same
+// way as data. interesting must return true for data.
+//
+// Data is normalized first if it remains interesting, then chunks of bytes
+// are removed as long as the input remains interesting, from halves of it
+// down to single bytes. See https://www.st.cs.uni-saarland.de/papers/tse2002/
+func Minimize(data []byte, interesting func(data []byte) bool) []byte {
+	if normalized, err := Normalize(data); err == nil && interesting(normalized) {
+		data = normalized
+	}
+
+	for n := 2; len(data) >= 2; {
+		chunk := (len(data) + n - 1) / n
+		reduced := false
+		for start := 0; start < len(data); start += chunk {
+			end := start + chunk
+			if end > len(data) {
+				end = len(data)
+			}
+			complement := append(append([]byte{}, data[:start]...), data[end:]...)
+			if interesting(complement) {
+				data = complement
+				if n > 2 {
+					n--
+				}
+				reduced = true
+				break
+			}
+		}
+		if reduced {
+			continue
+		}
+		if n >= len(data) {
+			break
+		}
+		if n *= 2; n > len(data) {
+			n = len(data)
+		}
+	}
+	return data
+}
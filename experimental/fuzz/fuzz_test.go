@@ -0,0 +1,83 @@
+package fuzz
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+var (
+	// validWasm has a function returning its i32 param.
+	validWasm = binary.EncodeModule(&wasm.Module{
+		TypeSection:     []*wasm.FunctionType{{Params: []wasm.ValueType{wasm.ValueTypeI32}, Results: []wasm.ValueType{wasm.ValueTypeI32}}},
+		FunctionSection: []wasm.Index{0},
+		CodeSection:     []*wasm.Code{{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeEnd}}},
+	})
+	// invalidWasm decodes, but its function returns nothing instead of i32.
+	invalidWasm = binary.EncodeModule(&wasm.Module{
+		TypeSection:     []*wasm.FunctionType{{Results: []wasm.ValueType{wasm.ValueTypeI32}}},
+		FunctionSection: []wasm.Index{0},
+		CodeSection:     []*wasm.Code{{Body: []byte{wasm.OpcodeEnd}}},
+	})
+)
+
+func TestEntryPoints(t *testing.T) {
+	compile := Compile(wazero.NewRuntimeConfig())
+	tests := []struct {
+		name                       string
+		data                       []byte
+		decode, validate, compiled int
+	}{
+		{name: "valid", data: validWasm, decode: 1, validate: 1, compiled: 1},
+		{name: "invalid", data: invalidWasm, decode: 1},
+		{name: "truncated", data: validWasm[:len(validWasm)-1]},
+		{name: "empty", data: []byte{}},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.decode, Decode(tt.data))
+			require.Equal(t, tt.validate, Validate(tt.data))
+			require.Equal(t, tt.compiled, compile(tt.data))
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	// Encode the type section with a padded size, and add a custom section.
+	padded := append([]byte{}, validWasm[:8]...)
+	padded = append(padded, wasm.SectionIDType, 0x86, 0x00)
+	padded = append(padded, validWasm[10:]...)
+	padded = append(padded, wasm.SectionIDCustom, 3, 1, 'x', 0)
+	require.NotEqual(t, validWasm, padded)
+	require.Equal(t, 1, Validate(padded))
+
+	normalized, err := Normalize(padded)
+	require.NoError(t, err)
+	require.Equal(t, validWasm, normalized)
+
+	_, err = Normalize(validWasm[:len(validWasm)-1])
+	require.Error(t, err)
+}
+
+func TestMinimize(t *testing.T) {
+	data := append(append([]byte("a long prefix"), validWasm...), "and a suffix"...)
+
+	// Keep only the bytes which make the input interesting.
+	minimized := Minimize(data, func(data []byte) bool {
+		return bytes.Contains(data, validWasm[8:12])
+	})
+	require.Equal(t, validWasm[8:12], minimized)
+
+	// A valid module is normalized first.
+	minimized = Minimize(validWasm, func(data []byte) bool {
+		return Validate(data) == 1
+	})
+	require.Equal(t, 1, Validate(minimized))
+	require.True(t, len(minimized) <= len(validWasm))
+}
@@ -0,0 +1,28 @@
+package experimental
+
+import (
+	"context"
+
+	"github.com/tetratelabs/wazero/internal/gdbjit"
+)
+
+// WithGDBJITInterface registers the machine code of each function compiled
+// with the GDB JIT compilation interface, so that native debuggers attached
+// to the process, such as GDB and LLDB, show the wasm function name of
+// addresses in generated code, e.g. in `info symbol $pc`, instead of an
+// anonymous address.
+//
+// This only affects wazero.NewRuntimeConfigCompiler, and has a cost per
+// function compiled, so is only intended for debugging.
+//
+// Usage:
+//
+//	ctx = experimental.WithGDBJITInterface(ctx)
+//	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigCompiler())
+//
+// Note: The debugger must support the interface, which is enabled by default
+// in GDB. LLDB requires `settings set plugin.jit-loader.gdb.enable on`.
+// See https://sourceware.org/gdb/onlinedocs/gdb/JIT-Interface.html
+func WithGDBJITInterface(ctx context.Context) context.Context {
+	return context.WithValue(ctx, gdbjit.EnabledKey{}, true)
+}
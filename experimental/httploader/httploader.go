@@ -0,0 +1,207 @@
+// Package httploader loads WebAssembly modules from HTTP(S) URLs, for
+// platforms which distribute plugins from web servers or CDNs rather than
+// registries.
+//
+// The SHA-256 of each module must be pinned, so that a compromised or
+// misconfigured server can't serve another module. For example:
+//
+//	l := httploader.NewLoader(httploader.WithCacheDir(dir))
+//	compiled, err := l.Compile(ctx, r, "https://example.com/plugin.wasm",
+//		"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08")
+//
+// With a cache directory, modules are revalidated with If-None-Match, so a
+// module is only downloaded again when its ETag changes.
+//
+// Note: This package is experimental, so may be changed or deleted at any
+// time.
+package httploader
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/tetratelabs/wazero"
+)
+
+// DefaultMaxSize is the default maximum size of a module in bytes.
+const DefaultMaxSize = 64 << 20
+
+var sha256Pattern = regexp.MustCompile(`^[a-f0-9]{64}$`)
+
+// Option configures a Loader, e.g. WithCacheDir.
+type Option func(*Loader)
+
+// WithCacheDir sets a writeable directory to cache modules and their ETags
+// in. Defaults to no cache.
+func WithCacheDir(dir string) Option {
+	return func(l *Loader) {
+		l.cacheDir = dir
+	}
+}
+
+// WithHTTPClient sets the client to request modules with. Defaults to
+// http.DefaultClient.
+func WithHTTPClient(client *http.Client) Option {
+	return func(l *Loader) {
+		l.client = client
+	}
+}
+
+// WithMaxSize sets the maximum size of a module in bytes, beyond which
+// loading it fails. Defaults to DefaultMaxSize.
+func WithMaxSize(size int64) Option {
+	return func(l *Loader) {
+		l.maxSize = size
+	}
+}
+
+// Loader loads modules from URLs. It is safe for concurrent use.
+type Loader struct {
+	client   *http.Client
+	cacheDir string
+	maxSize  int64
+}
+
+// NewLoader returns a Loader configured by the options.
+func NewLoader(opts ...Option) *Loader {
+	l := &Loader{client: http.DefaultClient, maxSize: DefaultMaxSize}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Compile loads the module at the URL, like Load, and compiles it with the
+// runtime.
+func (l *Loader) Compile(ctx context.Context, r wazero.Runtime, url, digest string) (wazero.CompiledModule, error) {
+	wasm, err := l.Load(ctx, url, digest)
+	if err != nil {
+		return nil, err
+	}
+	return r.CompileModule(ctx, wasm)
+}
+
+// Load returns the module at the URL, which must have the digest: its
+// SHA-256 in hex, optionally prefixed with "sha256:".
+func (l *Loader) Load(ctx context.Context, url, digest string) ([]byte, error) {
+	expected := strings.ToLower(strings.TrimPrefix(digest, "sha256:"))
+	if !sha256Pattern.MatchString(expected) {
+		return nil, fmt.Errorf("invalid sha256 %q of %s: expected 64 hex characters", digest, url)
+	}
+
+	cached, etag := l.cached(url)
+	if cached != nil && sum(cached) != expected {
+		// The module changed since it was cached, so don't revalidate it.
+		cached, etag = nil, ""
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	res, err := l.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusNotModified:
+		if cached == nil {
+			return nil, fmt.Errorf("GET %s: unexpected %s", url, res.Status)
+		}
+		return cached, nil
+	case http.StatusOK:
+	default:
+		return nil, fmt.Errorf("GET %s: %s", url, res.Status)
+	}
+
+	wasm, err := io.ReadAll(io.LimitReader(res.Body, l.maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", url, err)
+	} else if int64(len(wasm)) > l.maxSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", url, l.maxSize)
+	} else if actual := sum(wasm); actual != expected {
+		return nil, fmt.Errorf("%s has sha256 %s, expected %s", url, actual, expected)
+	}
+	l.cache(url, wasm, res.Header.Get("ETag"))
+	return wasm, nil
+}
+
+// cached returns the module at the URL and its ETag in the cache, or nil if
+// absent.
+func (l *Loader) cached(url string) (wasm []byte, etag string) {
+	if l.cacheDir == "" {
+		return nil, ""
+	}
+	path := l.cachePath(url)
+	b, err := os.ReadFile(path + ".etag")
+	if err != nil {
+		return nil, ""
+	}
+	if wasm, err = os.ReadFile(path + ".wasm"); err != nil {
+		return nil, ""
+	}
+	return wasm, string(b)
+}
+
+// cache writes the module at the URL and its ETag to the cache, ignoring
+// errors, as caching is an optimization. Modules without an ETag aren't
+// cached, as they can't be revalidated.
+func (l *Loader) cache(url string, wasm []byte, etag string) {
+	if l.cacheDir == "" || etag == "" {
+		return
+	}
+	if err := os.MkdirAll(l.cacheDir, 0o700); err != nil {
+		return
+	}
+	// Write the ETag last, as a module is only read if it has one.
+	path := l.cachePath(url)
+	if writeFile(path+".wasm", wasm) == nil {
+		_ = writeFile(path+".etag", []byte(etag))
+	}
+}
+
+// cachePath returns the path of the files of the URL in the cache, without
+// the extension.
+func (l *Loader) cachePath(url string) string {
+	return filepath.Join(l.cacheDir, sum([]byte(url)))
+}
+
+// writeFile writes to a temporary file, then renames it to the path, so that
+// concurrent readers don't read partial content.
+func writeFile(path string, b []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(b)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+	return nil
+}
+
+// sum returns the SHA-256 of b in hex.
+func sum(b []byte) string {
+	s := sha256.Sum256(b)
+	return hex.EncodeToString(s[:])
+}
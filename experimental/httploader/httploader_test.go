@@ -0,0 +1,157 @@
+package httploader
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+// testCtx is an arbitrary, non-default context. Non-nil also prevents linter errors.
+var testCtx = context.WithValue(context.Background(), struct{}{}, "arbitrary")
+
+// wasm is an empty module.
+var wasm = []byte("\x00asm\x01\x00\x00\x00")
+
+// server serves wasm at "/mod.wasm" with an ETag, counting the responses
+// with and without the module.
+type server struct {
+	*httptest.Server
+	wasm              []byte
+	etag              string
+	served, validated int
+}
+
+func newServer(t *testing.T) *server {
+	s := &server{wasm: wasm, etag: `"v1"`}
+	s.Server = httptest.NewServer(s)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/mod.wasm" {
+		http.NotFound(w, r)
+		return
+	}
+	if s.etag != "" && r.Header.Get("If-None-Match") == s.etag {
+		s.validated++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	s.served++
+	if s.etag != "" {
+		w.Header().Set("ETag", s.etag)
+	}
+	_, _ = w.Write(s.wasm)
+}
+
+func TestLoader_Load(t *testing.T) {
+	s := newServer(t)
+	l := NewLoader()
+
+	for _, digest := range []string{sum(wasm), "sha256:" + sum(wasm)} {
+		b, err := l.Load(testCtx, s.URL+"/mod.wasm", digest)
+		require.NoError(t, err)
+		require.Equal(t, wasm, b)
+	}
+	// Without a cache, the module is served each time.
+	require.Equal(t, 2, s.served)
+}
+
+func TestLoader_Load_Cache(t *testing.T) {
+	s := newServer(t)
+	l := NewLoader(WithCacheDir(t.TempDir()))
+	url := s.URL + "/mod.wasm"
+
+	b, err := l.Load(testCtx, url, sum(wasm))
+	require.NoError(t, err)
+	require.Equal(t, wasm, b)
+
+	// The cached module is revalidated with its ETag.
+	b, err = l.Load(testCtx, url, sum(wasm))
+	require.NoError(t, err)
+	require.Equal(t, wasm, b)
+	require.Equal(t, 1, s.served)
+	require.Equal(t, 1, s.validated)
+
+	// A new version is served once its ETag changes.
+	s.wasm, s.etag = []byte("\x00asm\x01\x00\x00\x00\x00\x00"), `"v2"`
+	b, err = l.Load(testCtx, url, sum(s.wasm))
+	require.NoError(t, err)
+	require.Equal(t, s.wasm, b)
+	require.Equal(t, 2, s.served)
+
+	// A module pinned to another version isn't revalidated, as the cache
+	// can't be used.
+	s.wasm, s.etag = wasm, `"v1"`
+	b, err = l.Load(testCtx, url, sum(wasm))
+	require.NoError(t, err)
+	require.Equal(t, wasm, b)
+	require.Equal(t, 3, s.served)
+	require.Equal(t, 1, s.validated)
+}
+
+func TestLoader_Compile(t *testing.T) {
+	s := newServer(t)
+	r := wazero.NewRuntime(testCtx)
+	defer r.Close(testCtx)
+
+	compiled, err := NewLoader().Compile(testCtx, r, s.URL+"/mod.wasm", sum(wasm))
+	require.NoError(t, err)
+	require.NotNil(t, compiled)
+}
+
+func TestLoader_Load_Errors(t *testing.T) {
+	s := newServer(t)
+	url := s.URL + "/mod.wasm"
+	other := sum([]byte("other"))
+
+	tests := []struct {
+		name, url, digest, expectedErr string
+		opts                           []Option
+	}{
+		{
+			name:        "missing digest",
+			url:         url,
+			expectedErr: fmt.Sprintf(`invalid sha256 "" of %s: expected 64 hex characters`, url),
+		},
+		{
+			name:        "invalid digest",
+			url:         url,
+			digest:      "md5:abc",
+			expectedErr: fmt.Sprintf(`invalid sha256 "md5:abc" of %s: expected 64 hex characters`, url),
+		},
+		{
+			name:        "not found",
+			url:         s.URL + "/other.wasm",
+			digest:      sum(wasm),
+			expectedErr: fmt.Sprintf("GET %s/other.wasm: 404 Not Found", s.URL),
+		},
+		{
+			name:        "digest mismatch",
+			url:         url,
+			digest:      other,
+			expectedErr: fmt.Sprintf("%s has sha256 %s, expected %s", url, sum(wasm), other),
+		},
+		{
+			name:        "too large",
+			url:         url,
+			digest:      sum(wasm),
+			opts:        []Option{WithMaxSize(4)},
+			expectedErr: url + " is larger than 4 bytes",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewLoader(tc.opts...).Load(testCtx, tc.url, tc.digest)
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
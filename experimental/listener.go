@@ -29,27 +29,60 @@ type FunctionListener interface {
 	//
 	//   - ctx: the context of the caller function which must be the same
 	//	   instance or parent of the result.
+	//   - mod: the calling module, e.g. to read memory at offsets in
+	//	   paramValues, or to correlate calls by the instance name. This is
+	//	   the same api.Module a host function receives.
 	//   - def: the function definition.
 	//   - paramValues:  api.ValueType encoded parameters.
-	Before(ctx context.Context, def api.FunctionDefinition, paramValues []uint64) context.Context
+	//   - stackIterator: iterator over the call stack, starting with this
+	//	   function. It is only valid until Before returns.
+	Before(ctx context.Context, mod api.Module, def api.FunctionDefinition, paramValues []uint64, stackIterator StackIterator) context.Context
 
-	// After is invoked after a function is called.
+	// After is invoked after a function returns.
 	//
 	// # Params
 	//
 	//   - ctx: the context returned by Before.
+	//   - mod: the calling module, the same as passed to Before.
 	//   - def: the function definition.
-	//   - err: nil if the function didn't err
-	//   - resultValues: api.ValueType encoded results.
-	After(ctx context.Context, def api.FunctionDefinition, err error, resultValues []uint64)
+	//   - resultValues: api.ValueType encoded results, which can be
+	//	   overwritten to change what the caller receives, e.g. to inject
+	//	   faults.
+	After(ctx context.Context, mod api.Module, def api.FunctionDefinition, resultValues []uint64)
+
+	// Abort is invoked instead of After when a function doesn't return, as
+	// its frame was unwound due to a trap, a panic or the module closing.
+	// When multiple frames are unwound, Abort is invoked for each function
+	// with a listener, starting with the innermost.
+	//
+	// # Params
+	//
+	//   - ctx: the context returned by Before.
+	//   - mod: the calling module, the same as passed to Before.
+	//   - def: the function definition.
+	//   - err: the error returned by the call, e.g. a *sys.ExitError.
+	Abort(ctx context.Context, mod api.Module, def api.FunctionDefinition, err error)
+}
+
+// StackIterator iterates over the functions on the call stack, from the
+// innermost, the function called, to the outermost. For example, this can
+// be used by a profiler to attribute time to the full stack.
+//
+// Note: Functions which called into the host, which then called back into
+// a module, e.g. via api.Function, are not included.
+type StackIterator interface {
+	// Next moves to the next function on the stack, returning false when
+	// there are no more. It must be called before FunctionDefinition.
+	Next() bool
+
+	// FunctionDefinition returns the definition of the current function.
+	FunctionDefinition() api.FunctionDefinition
 }
 
 // TODO: We need to add tests to enginetest to ensure contexts nest. A good test can use a combination of call and call
 // indirect in terms of depth and breadth. The test could show a tree 3 calls deep where the there are a couple calls at
 // each depth under the root. The main thing this can help prevent is accidentally swapping the context internally.
 
-// TODO: Errors aren't handled, and the After hook should accept one along with the result values.
-
 // TODO: The context parameter of the After hook is not the same as the Before hook. This means interceptor patterns
 // are awkward. e.g. something like timing is difficult as it requires propagating a stack. Otherwise, nested calls will
 // overwrite each other's "since" time. Propagating a stack is further awkward as the After hook needs to know the
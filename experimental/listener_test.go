@@ -8,6 +8,7 @@ import (
 	"github.com/tetratelabs/wazero"
 	"github.com/tetratelabs/wazero/api"
 	. "github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/platform"
 	"github.com/tetratelabs/wazero/internal/testing/require"
 	"github.com/tetratelabs/wazero/internal/wasm"
 	"github.com/tetratelabs/wazero/internal/wasm/binary"
@@ -17,19 +18,33 @@ import (
 var _ FunctionListenerFactory = &recorder{}
 
 type recorder struct {
-	m                       map[string]struct{}
-	beforeNames, afterNames []string
+	m                                   map[string]struct{}
+	beforeNames, afterNames, abortNames []string
+	// stacks are the function names of the stack iterated in each Before.
+	stacks [][]string
+	// modules are the modules passed to each Before.
+	modules []api.Module
 }
 
-func (r *recorder) Before(ctx context.Context, def api.FunctionDefinition, _ []uint64) context.Context {
+func (r *recorder) Before(ctx context.Context, mod api.Module, def api.FunctionDefinition, _ []uint64, si StackIterator) context.Context {
 	r.beforeNames = append(r.beforeNames, def.DebugName())
+	r.modules = append(r.modules, mod)
+	var stack []string
+	for si.Next() {
+		stack = append(stack, si.FunctionDefinition().DebugName())
+	}
+	r.stacks = append(r.stacks, stack)
 	return ctx
 }
 
-func (r *recorder) After(_ context.Context, def api.FunctionDefinition, _ error, _ []uint64) {
+func (r *recorder) After(_ context.Context, _ api.Module, def api.FunctionDefinition, _ []uint64) {
 	r.afterNames = append(r.afterNames, def.DebugName())
 }
 
+func (r *recorder) Abort(_ context.Context, _ api.Module, def api.FunctionDefinition, _ error) {
+	r.abortNames = append(r.abortNames, def.DebugName())
+}
+
 func (r *recorder) NewListener(definition api.FunctionDefinition) FunctionListener {
 	r.m[definition.Name()] = struct{}{}
 	return r
@@ -99,4 +114,94 @@ func TestFunctionListenerFactory(t *testing.T) {
 
 	require.Equal(t, []string{"test.fn1", "test.fn2", "test.fn2"}, factory.beforeNames)
 	require.Equal(t, []string{"test.fn2", "test.fn2", "test.fn1"}, factory.afterNames) // after is in the reverse order.
+	require.Equal(t, [][]string{{"test.fn1"}, {"test.fn2", "test.fn1"}, {"test.fn2", "test.fn1"}}, factory.stacks)
+	require.Nil(t, factory.abortNames)
+}
+
+func TestFunctionListener_Abort(t *testing.T) {
+	// Define a module where fn1 calls fn2, which traps.
+	bin := binary.EncodeModule(&wasm.Module{
+		TypeSection:     []*wasm.FunctionType{{}},
+		FunctionSection: []wasm.Index{0, 0},
+		CodeSection: []*wasm.Code{
+			{Body: []byte{wasm.OpcodeCall, 1, wasm.OpcodeEnd}},
+			{Body: []byte{wasm.OpcodeUnreachable, wasm.OpcodeEnd}},
+		},
+		ExportSection: []*wasm.Export{{Name: "fn1", Type: wasm.ExternTypeFunc, Index: 0}},
+		NameSection: &wasm.NameSection{
+			ModuleName:    "test",
+			FunctionNames: wasm.NameMap{{Index: 0, Name: "fn1"}, {Index: 1, Name: "fn2"}},
+		},
+	})
+
+	configs := map[string]wazero.RuntimeConfig{"interpreter": wazero.NewRuntimeConfigInterpreter()}
+	if platform.CompilerSupported() {
+		configs["compiler"] = wazero.NewRuntimeConfigCompiler()
+	}
+	for name, config := range configs {
+		config := config
+		t.Run(name, func(t *testing.T) {
+			factory := &recorder{m: map[string]struct{}{}}
+			ctx := context.WithValue(context.Background(), FunctionListenerFactoryKey{}, factory)
+
+			r := wazero.NewRuntimeWithConfig(ctx, config)
+			defer r.Close(ctx) // This closes everything this Runtime created.
+
+			m, err := r.InstantiateModuleFromBinary(ctx, bin)
+			require.NoError(t, err)
+
+			_, err = m.ExportedFunction("fn1").Call(ctx)
+			require.Error(t, err)
+
+			require.Equal(t, []string{"test.fn1", "test.fn2"}, factory.beforeNames)
+			require.Equal(t, [][]string{{"test.fn1"}, {"test.fn2", "test.fn1"}}, factory.stacks)
+			require.Nil(t, factory.afterNames)
+			require.Equal(t, []string{"test.fn2", "test.fn1"}, factory.abortNames) // innermost first.
+
+			// The next call must not see frames of the aborted one.
+			factory.beforeNames, factory.abortNames, factory.stacks = nil, nil, nil
+			_, err = m.ExportedFunction("fn1").Call(ctx)
+			require.Error(t, err)
+			require.Equal(t, []string{"test.fn2", "test.fn1"}, factory.abortNames)
+		})
+	}
+}
+
+func TestFunctionListener_Module(t *testing.T) {
+	// Define a module with memory, whose exported function is a no-op.
+	bin := binary.EncodeModule(&wasm.Module{
+		TypeSection:     []*wasm.FunctionType{{}},
+		FunctionSection: []wasm.Index{0},
+		MemorySection:   &wasm.Memory{Min: 1, Max: 1, IsMaxEncoded: true},
+		CodeSection:     []*wasm.Code{{Body: []byte{wasm.OpcodeEnd}}},
+		ExportSection:   []*wasm.Export{{Name: "fn", Type: wasm.ExternTypeFunc, Index: 0}},
+	})
+
+	factory := &recorder{m: map[string]struct{}{}}
+	ctx := context.WithValue(context.Background(), FunctionListenerFactoryKey{}, factory)
+
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx) // This closes everything this Runtime created.
+
+	compiled, err := r.CompileModule(ctx, bin)
+	require.NoError(t, err)
+
+	// Instantiate the same compiled module twice, to ensure listeners can
+	// tell calls to each instance apart.
+	var mods []api.Module
+	for _, name := range []string{"a", "b"} {
+		m, err := r.InstantiateModule(ctx, compiled, wazero.NewModuleConfig().WithName(name))
+		require.NoError(t, err)
+		mods = append(mods, m)
+	}
+	for _, m := range []api.Module{mods[1], mods[0]} {
+		_, err = m.ExportedFunction("fn").Call(ctx)
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, 2, len(factory.modules))
+	require.Equal(t, "b", factory.modules[0].Name())
+	require.True(t, mods[1].Memory() == factory.modules[0].Memory())
+	require.Equal(t, "a", factory.modules[1].Name())
+	require.True(t, mods[0].Memory() == factory.modules[1].Memory())
 }
@@ -0,0 +1,109 @@
+package logging
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// FunctionFilter returns true if calls to the function should be logged.
+//
+// Pass filters to NewLoggingListenerFactory or NewHostLoggingListenerFactory
+// to only log a function when all of them return true. For example, to trace
+// a busy Go module without its garbage collector and allocator:
+//
+//	logging.NewLoggingListenerFactory(os.Stdout,
+//		logging.IncludeModules("app"),
+//		logging.ExcludeFunctions("runtime.*", "malloc", "free"))
+type FunctionFilter func(api.FunctionDefinition) bool
+
+// IncludeModules logs only functions defined in the modules of the given
+// names, e.g. "wasi_snapshot_preview1".
+func IncludeModules(names ...string) FunctionFilter {
+	return func(fnd api.FunctionDefinition) bool {
+		return containsString(names, fnd.ModuleName())
+	}
+}
+
+// ExcludeModules logs only functions not defined in the modules of the given
+// names.
+func ExcludeModules(names ...string) FunctionFilter {
+	return func(fnd api.FunctionDefinition) bool {
+		return !containsString(names, fnd.ModuleName())
+	}
+}
+
+// IncludeFunctions logs only functions whose name matches one of the given
+// glob patterns, where '*' matches any characters and '?' matches one. For
+// example, "fd_*" matches "fd_read" and "fd_write".
+func IncludeFunctions(patterns ...string) FunctionFilter {
+	re := globsToRegexp(patterns)
+	return func(fnd api.FunctionDefinition) bool {
+		return re != nil && re.MatchString(fnd.Name())
+	}
+}
+
+// ExcludeFunctions logs only functions whose name doesn't match any of the
+// given glob patterns, as defined by IncludeFunctions.
+func ExcludeFunctions(patterns ...string) FunctionFilter {
+	re := globsToRegexp(patterns)
+	return func(fnd api.FunctionDefinition) bool {
+		return re == nil || !re.MatchString(fnd.Name())
+	}
+}
+
+// MatchFunctions logs only functions whose name matches the regular
+// expression. Use Not to exclude them instead.
+func MatchFunctions(re *regexp.Regexp) FunctionFilter {
+	return func(fnd api.FunctionDefinition) bool {
+		return re.MatchString(fnd.Name())
+	}
+}
+
+// HostFunctions logs only functions defined by the host in Go, which guests
+// import, e.g. "fd_write".
+func HostFunctions() FunctionFilter {
+	return func(fnd api.FunctionDefinition) bool {
+		return fnd.GoFunction() != nil
+	}
+}
+
+// GuestFunctions logs only functions defined by guests in WebAssembly.
+func GuestFunctions() FunctionFilter {
+	return func(fnd api.FunctionDefinition) bool {
+		return fnd.GoFunction() == nil
+	}
+}
+
+// Not logs only functions the filter doesn't log.
+func Not(filter FunctionFilter) FunctionFilter {
+	return func(fnd api.FunctionDefinition) bool {
+		return !filter(fnd)
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// globsToRegexp returns a regular expression matching any of the glob
+// patterns, or nil if there are none.
+func globsToRegexp(patterns []string) *regexp.Regexp {
+	if len(patterns) == 0 {
+		return nil
+	}
+	alternatives := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		p = regexp.QuoteMeta(p)
+		p = strings.ReplaceAll(p, `\*`, `.*`)
+		p = strings.ReplaceAll(p, `\?`, `.`)
+		alternatives = append(alternatives, p)
+	}
+	return regexp.MustCompile(`(?s)^(?:` + strings.Join(alternatives, "|") + `)$`)
+}
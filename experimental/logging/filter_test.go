@@ -0,0 +1,144 @@
+package logging_test
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental/logging"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// filterDefs returns definitions of functions named like a Go guest "app"
+// importing "fd_write" from the host module "wasi_snapshot_preview1".
+func filterDefs() []api.FunctionDefinition {
+	host := &wasm.Module{
+		TypeSection:     []*wasm.FunctionType{{}},
+		FunctionSection: []wasm.Index{0},
+		CodeSection:     []*wasm.Code{wasm.MustParseGoReflectFuncCode(func() {})},
+		NameSection: &wasm.NameSection{
+			ModuleName:    "wasi_snapshot_preview1",
+			FunctionNames: wasm.NameMap{{Index: 0, Name: "fd_write"}},
+		},
+	}
+	host.BuildFunctionDefinitions()
+
+	end := &wasm.Code{Body: []byte{wasm.OpcodeEnd}}
+	guest := &wasm.Module{
+		TypeSection:     []*wasm.FunctionType{{}},
+		FunctionSection: []wasm.Index{0, 0, 0},
+		CodeSection:     []*wasm.Code{end, end, end},
+		NameSection: &wasm.NameSection{
+			ModuleName: "app",
+			FunctionNames: wasm.NameMap{
+				{Index: 0, Name: "main.main"},
+				{Index: 1, Name: "runtime.gcDrain"},
+				{Index: 2, Name: "malloc"},
+			},
+		},
+	}
+	guest.BuildFunctionDefinitions()
+
+	return []api.FunctionDefinition{
+		host.FunctionDefinitionSection[0],
+		guest.FunctionDefinitionSection[0],
+		guest.FunctionDefinitionSection[1],
+		guest.FunctionDefinitionSection[2],
+	}
+}
+
+func TestFunctionFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		filters  []logging.Option
+		expected []string
+	}{
+		{
+			name:     "none",
+			expected: []string{"fd_write", "main.main", "runtime.gcDrain", "malloc"},
+		},
+		{
+			name:     "IncludeModules",
+			filters:  []logging.Option{logging.IncludeModules("app")},
+			expected: []string{"main.main", "runtime.gcDrain", "malloc"},
+		},
+		{
+			name:     "ExcludeModules",
+			filters:  []logging.Option{logging.ExcludeModules("app")},
+			expected: []string{"fd_write"},
+		},
+		{
+			name:     "IncludeFunctions",
+			filters:  []logging.Option{logging.IncludeFunctions("fd_*", "m?in.*")},
+			expected: []string{"fd_write", "main.main"},
+		},
+		{
+			name:     "IncludeFunctions none",
+			filters:  []logging.Option{logging.IncludeFunctions()},
+			expected: nil,
+		},
+		{
+			name:     "ExcludeFunctions",
+			filters:  []logging.Option{logging.ExcludeFunctions("runtime.*", "malloc")},
+			expected: []string{"fd_write", "main.main"},
+		},
+		{
+			name:     "ExcludeFunctions none",
+			filters:  []logging.Option{logging.ExcludeFunctions()},
+			expected: []string{"fd_write", "main.main", "runtime.gcDrain", "malloc"},
+		},
+		{
+			name:     "MatchFunctions",
+			filters:  []logging.Option{logging.MatchFunctions(regexp.MustCompile(`^runtime\.`))},
+			expected: []string{"runtime.gcDrain"},
+		},
+		{
+			name:     "Not MatchFunctions",
+			filters:  []logging.Option{logging.Not(logging.MatchFunctions(regexp.MustCompile(`^runtime\.`)))},
+			expected: []string{"fd_write", "main.main", "malloc"},
+		},
+		{
+			name:     "HostFunctions",
+			filters:  []logging.Option{logging.HostFunctions()},
+			expected: []string{"fd_write"},
+		},
+		{
+			name:     "GuestFunctions",
+			filters:  []logging.Option{logging.GuestFunctions()},
+			expected: []string{"main.main", "runtime.gcDrain", "malloc"},
+		},
+		{
+			name: "all must match",
+			filters: []logging.Option{
+				logging.GuestFunctions(),
+				logging.ExcludeFunctions("runtime.*"),
+			},
+			expected: []string{"main.main", "malloc"},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			lf := logging.NewLoggingListenerFactory(&bytes.Buffer{}, tc.filters...)
+
+			var logged []string
+			for _, def := range filterDefs() {
+				if lf.NewListener(def) != nil {
+					logged = append(logged, def.Name())
+				}
+			}
+			require.Equal(t, tc.expected, logged)
+		})
+	}
+}
+
+func TestNewHostLoggingListenerFactory_filters(t *testing.T) {
+	lf := logging.NewHostLoggingListenerFactory(&bytes.Buffer{}, logging.ExcludeModules("wasi_snapshot_preview1"))
+
+	for _, def := range filterDefs() {
+		require.Nil(t, lf.NewListener(def), def.DebugName())
+	}
+}
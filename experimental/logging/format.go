@@ -0,0 +1,58 @@
+package logging
+
+import (
+	"github.com/tetratelabs/wazero/api"
+)
+
+// Formatter returns the text to log for the parameters or results of a call
+// to the function, e.g. "point={x=1,y=2}" for an ABI which passes a pointer
+// to a struct. mem is the memory of the calling module, or nil if it has
+// none, and vals are the api.ValueType encoded values.
+//
+// Note: Results are only formatted when the call returns without error.
+type Formatter func(def api.FunctionDefinition, mem api.Memory, vals []uint64) string
+
+// formatterKey is the module and function name a Formatter applies to.
+type formatterKey struct {
+	moduleName, name string
+}
+
+// WithParamFormatter logs the parameters of the function named name in the
+// module named moduleName as the formatter returns, instead of each value.
+// For example, this logs the handle of "env.close" by its resource:
+//
+//	logging.NewLoggingListenerFactory(os.Stdout,
+//		logging.WithParamFormatter("env", "close", func(_ api.FunctionDefinition, _ api.Memory, vals []uint64) string {
+//			return "handle=" + resources.Name(uint32(vals[0]))
+//		}))
+//
+// In JSON, "params" is the formatted string instead of an object.
+func WithParamFormatter(moduleName, name string, formatter Formatter) Option {
+	return optionFunc(func(f *loggingListenerFactory) {
+		if f.paramFormatters == nil {
+			f.paramFormatters = map[formatterKey]Formatter{}
+		}
+		f.paramFormatters[formatterKey{moduleName, name}] = formatter
+	})
+}
+
+// WithResultFormatter is like WithParamFormatter, except it formats the
+// results of the function. In JSON, "results" is the formatted string
+// instead of an object.
+func WithResultFormatter(moduleName, name string, formatter Formatter) Option {
+	return optionFunc(func(f *loggingListenerFactory) {
+		if f.resultFormatters == nil {
+			f.resultFormatters = map[formatterKey]Formatter{}
+		}
+		f.resultFormatters[formatterKey{moduleName, name}] = formatter
+	})
+}
+
+// format returns the values as the formatter returns.
+func (l *loggingListener) format(formatter Formatter, mod api.Module, vals []uint64) string {
+	var mem api.Memory
+	if mod != nil {
+		mem = mod.Memory()
+	}
+	return formatter(l.fnd, mem, vals)
+}
@@ -0,0 +1,105 @@
+package logging_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strconv"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental/logging"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	wasmbinary "github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+func TestWithParamFormatter(t *testing.T) {
+	r := wazero.NewRuntime(testCtx)
+	defer r.Close(testCtx)
+
+	mod, err := r.InstantiateModuleFromBinary(testCtx, wasmbinary.EncodeModule(&wasm.Module{
+		MemorySection: &wasm.Memory{Min: 1, Cap: 1, Max: 1},
+	}))
+	require.NoError(t, err)
+	// point at 8
+	mod.Memory().Write(8, []byte{1, 0, 0, 0, 2, 0, 0, 0})
+
+	// formatPoint formats the point the first value points to.
+	formatPoint := func(_ api.FunctionDefinition, mem api.Memory, vals []uint64) string {
+		b, ok := mem.Read(uint32(vals[0]), 8)
+		if !ok {
+			return "point=?"
+		}
+		return "point={x=" + strconv.Itoa(int(binary.LittleEndian.Uint32(b))) +
+			",y=" + strconv.Itoa(int(binary.LittleEndian.Uint32(b[4:]))) + "}"
+	}
+	formatHandle := func(_ api.FunctionDefinition, _ api.Memory, vals []uint64) string {
+		return "handle#" + strconv.Itoa(int(vals[0]))
+	}
+
+	i32 := api.ValueTypeI32
+	m := &wasm.Module{
+		TypeSection:     []*wasm.FunctionType{{Params: []api.ValueType{i32}, Results: []api.ValueType{i32}}},
+		FunctionSection: []wasm.Index{0, 0},
+		CodeSection:     []*wasm.Code{wasm.MustParseGoReflectFuncCode(func() {}), wasm.MustParseGoReflectFuncCode(func() {})},
+		NameSection: &wasm.NameSection{
+			ModuleName:    "env",
+			FunctionNames: wasm.NameMap{{Index: 0, Name: "draw"}, {Index: 1, Name: "erase"}},
+			LocalNames:    wasm.IndirectNameMap{{Index: 1, NameMap: toNameMap([]string{"p"})}},
+		},
+	}
+	m.BuildFunctionDefinitions()
+	draw, erase := m.FunctionDefinitionSection[0], m.FunctionDefinitionSection[1]
+
+	opts := []logging.Option{
+		logging.WithParamFormatter("env", "draw", formatPoint),
+		logging.WithResultFormatter("env", "draw", formatHandle),
+		logging.WithParamFormatter("other", "erase", formatPoint),
+	}
+
+	tests := []struct {
+		name     string
+		json     bool
+		expected string
+	}{
+		{
+			name: "text",
+			expected: `==> env.draw(point={x=1,y=2})
+<== handle#42
+==> env.erase(p=8)
+<== 42
+`,
+		},
+		{
+			name: "json",
+			json: true,
+			expected: `{"time":"","direction":"call","module":"env","function":"draw","host":true,"nesting":0,"params":"point={x=1,y=2}"}
+{"time":"","direction":"return","module":"env","function":"draw","host":true,"nesting":0,"results":"handle#42"}
+{"time":"","direction":"call","module":"env","function":"erase","host":true,"nesting":0,"params":{"p":8}}
+{"time":"","direction":"return","module":"env","function":"erase","host":true,"nesting":0,"results":{"$0":42}}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			lf := logging.NewLoggingListenerFactory(&out, opts...)
+			if tc.json {
+				lf = logging.NewJSONLoggingListenerFactory(&out, opts...)
+			}
+			for _, def := range []api.FunctionDefinition{draw, erase} {
+				l := lf.NewListener(def)
+				ctx := l.Before(testCtx, mod, def, []uint64{8}, nil)
+				l.After(ctx, mod, def, []uint64{42})
+			}
+			if tc.json {
+				require.Equal(t, tc.expected, requireJSONLines(t, out.String()))
+			} else {
+				require.Equal(t, tc.expected, out.String())
+			}
+		})
+	}
+}
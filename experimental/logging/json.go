@@ -0,0 +1,188 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/wasi_snapshot_preview1"
+)
+
+// NewJSONLoggingListenerFactory is like NewLoggingListenerFactory, except it
+// writes one JSON object per line for each call and return, which log
+// pipelines can ingest. For example:
+//
+//	{"time":"2023-01-31T10:00:00.000000001Z","direction":"call","module":"wasi_snapshot_preview1","function":"random_get","host":true,"nesting":1,"params":{"buf":4,"buf_len":4}}
+//	{"time":"2023-01-31T10:00:00.000000002Z","direction":"return","module":"wasi_snapshot_preview1","function":"random_get","host":true,"nesting":1,"results":{"errno":"ESUCCESS"}}
+//
+// The fields are:
+//   - "time": when the event occurred, in RFC 3339 format.
+//   - "direction": "call" before the function runs, or "return" after.
+//   - "module" and "function": the names of the module and function.
+//   - "host": true if the function is defined by the host in Go.
+//   - "nesting": the count of calls this is nested in, zero at the top.
+//   - "params" or "results": each value keyed by its name, or "$" and its
+//     index if unnamed. Numbers are signed, except those which are not
+//     finite or are vectors or references, which are strings. Like text,
+//     some WASI parameters are the decoded payload they point to or their
+//     symbolic names, and stat results are decoded before "errno".
+//   - "error": the first line of the error message, instead of "results",
+//     if the call was aborted, e.g. by a trap.
+//
+// With WithDurations, "return" events also have "duration_ns": how long the
+// call took in nanoseconds. WithTotals adds "total_ns" and "calls": the total
+// of all calls to the function so far.
+//
+// Pass filters to only log functions matching all of them, e.g.
+// HostFunctions.
+func NewJSONLoggingListenerFactory(writer io.Writer, opts ...Option) experimental.FunctionListenerFactory {
+	return newLoggingListenerFactory(&loggingListenerFactory{writer: writer, json: true}, opts)
+}
+
+// writeJSON writes a JSON object for the call or return of the function.
+func (l *loggingListener) writeJSON(before bool, mod api.Module, err error, vals []uint64, results []namedValue, t *timing, nesting int) {
+	var message strings.Builder
+	message.WriteString(`{"time":`)
+	writeJSONString(&message, time.Now().UTC().Format(time.RFC3339Nano))
+	if before {
+		message.WriteString(`,"direction":"call"`)
+	} else {
+		message.WriteString(`,"direction":"return"`)
+	}
+	message.WriteString(`,"module":`)
+	writeJSONString(&message, l.fnd.ModuleName())
+	message.WriteString(`,"function":`)
+	writeJSONString(&message, l.fnd.Name())
+	message.WriteString(`,"host":`)
+	message.WriteString(strconv.FormatBool(l.fnd.GoFunction() != nil))
+	message.WriteString(`,"nesting":`)
+	message.WriteString(strconv.Itoa(nesting))
+
+	switch {
+	case before && l.paramFormatter != nil:
+		message.WriteString(`,"params":`)
+		writeJSONString(&message, l.format(l.paramFormatter, mod, vals))
+	case before:
+		message.WriteString(`,"params":`)
+		decoded, replaced := l.decodeParams(mod, vals)
+		l.writeJSONVals(&message, l.fnd.ParamTypes(), l.fnd.ParamNames(), -1, nil, decoded, replaced, l.redactedParams, vals)
+	case err != nil:
+		message.WriteString(`,"error":`)
+		writeJSONString(&message, errorMessage(err))
+	case l.resultFormatter != nil:
+		message.WriteString(`,"results":`)
+		writeJSONString(&message, l.format(l.resultFormatter, mod, vals))
+	default:
+		message.WriteString(`,"results":`)
+		l.writeJSONVals(&message, l.fnd.ResultTypes(), l.fnd.ResultNames(), l.wasiErrnoPos, results, nil, nil, l.redactedResults, vals)
+	}
+	if t != nil {
+		t.writeJSON(&message)
+	}
+	message.WriteString("}\n")
+
+	_, _ = l.writer.Write([]byte(message.String()))
+}
+
+// writeJSONVals writes the values as a JSON object. errnoPos is the index of
+// a wasi_snapshot_preview1.Errno value or -1. extra are written first, e.g.
+// the results of decodeResults. decoded and replaced are the results of
+// decodeParams, and redacted is the RedactMode of values by index.
+func (l *loggingListener) writeJSONVals(message *strings.Builder, types []api.ValueType, names []string, errnoPos int, extra []namedValue, decoded map[int]string, replaced map[int]bool, redacted map[int]RedactMode, vals []uint64) {
+	message.WriteByte('{')
+	first := true
+	for _, e := range extra {
+		if !first {
+			message.WriteByte(',')
+		}
+		first = false
+		writeJSONString(message, e.name)
+		message.WriteByte(':')
+		writeJSONString(message, e.value)
+	}
+	for i, v := 0, 0; i < len(types); i++ {
+		if replaced[i] {
+			v++
+			continue
+		}
+		if !first {
+			message.WriteByte(',')
+		}
+		first = false
+		if len(names) > 0 {
+			writeJSONString(message, names[i])
+		} else {
+			writeJSONString(message, "$"+strconv.Itoa(i))
+		}
+		message.WriteByte(':')
+		if mode, ok := redacted[i]; ok {
+			// Redact the text form, so that hashes are the same as in text.
+			var val strings.Builder
+			if s, ok := decoded[i]; ok {
+				val.WriteString(s)
+				v++
+			} else if i == errnoPos {
+				val.WriteString(wasi_snapshot_preview1.ErrnoName(uint32(vals[v])))
+				v++
+			} else {
+				v = l.writeVal(&val, types[i], v, vals)
+			}
+			writeJSONString(message, redact(mode, val.String()))
+			continue
+		}
+		if s, ok := decoded[i]; ok {
+			writeJSONString(message, s)
+			v++
+			continue
+		} else if i == errnoPos {
+			writeJSONString(message, wasi_snapshot_preview1.ErrnoName(uint32(vals[v])))
+			v++
+			continue
+		}
+		v = writeJSONVal(message, types[i], v, vals)
+	}
+	message.WriteByte('}')
+}
+
+// writeJSONVal formats the value like writeVal, except values JSON can't
+// represent as a number are quoted.
+func writeJSONVal(message *strings.Builder, t api.ValueType, i int, vals []uint64) int {
+	v := vals[i]
+	i++
+	switch t {
+	case api.ValueTypeI32:
+		message.WriteString(strconv.FormatInt(int64(int32(v)), 10))
+	case api.ValueTypeI64:
+		message.WriteString(strconv.FormatInt(int64(v), 10))
+	case api.ValueTypeF32:
+		writeJSONFloat(message, float64(api.DecodeF32(v)), 32)
+	case api.ValueTypeF64:
+		writeJSONFloat(message, api.DecodeF64(v), 64)
+	case api.ValueTypeV128:
+		writeJSONString(message, fmt.Sprintf("%016x%016x", v, vals[i])) // fixed-width hex
+		i++
+	case api.ValueTypeExternref, 0x70: // wasm.ValueTypeFuncref
+		writeJSONString(message, fmt.Sprintf("%016x", v)) // fixed-width hex
+	}
+	return i
+}
+
+func writeJSONFloat(message *strings.Builder, f float64, bitSize int) {
+	s := strconv.FormatFloat(f, 'g', -1, bitSize)
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		writeJSONString(message, s)
+	} else {
+		message.WriteString(s)
+	}
+}
+
+func writeJSONString(message *strings.Builder, s string) {
+	b, _ := json.Marshal(s) // a string can't fail to marshal.
+	message.Write(b)
+}
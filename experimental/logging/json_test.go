@@ -0,0 +1,167 @@
+package logging_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental/logging"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+var jsonTime = regexp.MustCompile(`"time":"([^"]+)"`)
+
+// requireJSONLines ensures each line is a JSON object with a valid time, and
+// returns the output with the time removed.
+func requireJSONLines(t *testing.T, out string) string {
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+		require.True(t, json.Valid([]byte(line)), line)
+		_, err := time.Parse(time.RFC3339Nano, jsonTime.FindStringSubmatch(line)[1])
+		require.NoError(t, err)
+	}
+	return jsonTime.ReplaceAllString(out, `"time":""`)
+}
+
+func Test_jsonLoggingListener(t *testing.T) {
+	tests := []struct {
+		name                    string
+		moduleName, funcName    string
+		functype                *wasm.FunctionType
+		isHostFunc              bool
+		paramNames, resultNames []string
+		params, results         []uint64
+		err                     error
+		expected                string
+	}{
+		{
+			name:     "v_v",
+			functype: &wasm.FunctionType{},
+			expected: `{"time":"","direction":"call","module":"test","function":"fn","host":false,"nesting":0,"params":{}}
+{"time":"","direction":"return","module":"test","function":"fn","host":false,"nesting":0,"results":{}}
+`,
+		},
+		{
+			name:     "error",
+			functype: &wasm.FunctionType{},
+			err:      io.EOF,
+			expected: `{"time":"","direction":"call","module":"test","function":"fn","host":false,"nesting":0,"params":{}}
+{"time":"","direction":"return","module":"test","function":"fn","host":false,"nesting":0,"error":"EOF"}
+`,
+		},
+		{
+			name:        "wasi",
+			moduleName:  "wasi_snapshot_preview1",
+			funcName:    "random_get",
+			isHostFunc:  true,
+			functype:    &wasm.FunctionType{Params: []api.ValueType{api.ValueTypeI32, api.ValueTypeI32}, Results: []api.ValueType{api.ValueTypeI32}},
+			paramNames:  []string{"buf", "buf_len"},
+			resultNames: []string{"errno"},
+			params:      []uint64{0, 8},
+			results:     []uint64{uint64(21)},
+			expected: `{"time":"","direction":"call","module":"wasi_snapshot_preview1","function":"random_get","host":true,"nesting":0,"params":{"buf":0,"buf_len":8}}
+{"time":"","direction":"return","module":"wasi_snapshot_preview1","function":"random_get","host":true,"nesting":0,"results":{"errno":"EFAULT"}}
+`,
+		},
+		{
+			name: "unnamed",
+			functype: &wasm.FunctionType{
+				Params:  []api.ValueType{api.ValueTypeI32, api.ValueTypeI64, api.ValueTypeF32},
+				Results: []api.ValueType{api.ValueTypeF64, api.ValueTypeV128, api.ValueTypeExternref},
+			},
+			params:  []uint64{math.MaxUint32, 2, api.EncodeF32(1.5)},
+			results: []uint64{api.EncodeF64(math.NaN()), 1, 2, 3},
+			expected: `{"time":"","direction":"call","module":"test","function":"fn","host":false,"nesting":0,"params":{"$0":-1,"$1":2,"$2":1.5}}
+{"time":"","direction":"return","module":"test","function":"fn","host":false,"nesting":0,"results":{"$0":"NaN","$1":"00000000000000010000000000000002","$2":"0000000000000003"}}
+`,
+		},
+		{
+			name:       "escaped",
+			funcName:   `"quoted"`,
+			functype:   &wasm.FunctionType{Params: []api.ValueType{api.ValueTypeI32}},
+			paramNames: []string{"a\nb"},
+			params:     []uint64{1},
+			expected: `{"time":"","direction":"call","module":"test","function":"\"quoted\"","host":false,"nesting":0,"params":{"a\nb":1}}
+{"time":"","direction":"return","module":"test","function":"\"quoted\"","host":false,"nesting":0,"results":{}}
+`,
+		},
+	}
+
+	var out bytes.Buffer
+	lf := logging.NewJSONLoggingListenerFactory(&out)
+	fn := func() {}
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.moduleName == "" {
+				tc.moduleName = "test"
+			}
+			if tc.funcName == "" {
+				tc.funcName = "fn"
+			}
+			m := &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{tc.functype},
+				FunctionSection: []wasm.Index{0},
+				NameSection: &wasm.NameSection{
+					ModuleName:    tc.moduleName,
+					FunctionNames: wasm.NameMap{{Name: tc.funcName}},
+					LocalNames:    wasm.IndirectNameMap{{NameMap: toNameMap(tc.paramNames)}},
+					ResultNames:   wasm.IndirectNameMap{{NameMap: toNameMap(tc.resultNames)}},
+				},
+			}
+
+			if tc.isHostFunc {
+				m.CodeSection = []*wasm.Code{wasm.MustParseGoReflectFuncCode(fn)}
+			} else {
+				m.CodeSection = []*wasm.Code{{Body: []byte{wasm.OpcodeEnd}}}
+			}
+			m.BuildFunctionDefinitions()
+			def := m.FunctionDefinitionSection[0]
+			l := lf.NewListener(m.FunctionDefinitionSection[0])
+
+			out.Reset()
+			ctx := l.Before(testCtx, nil, def, tc.params, nil)
+			if tc.err != nil {
+				l.Abort(ctx, nil, def, tc.err)
+			} else {
+				l.After(ctx, nil, def, tc.results)
+			}
+			require.Equal(t, tc.expected, requireJSONLines(t, out.String()))
+		})
+	}
+}
+
+func Test_jsonLoggingListener_nesting(t *testing.T) {
+	out := bytes.NewBuffer(nil)
+	lf := logging.NewJSONLoggingListenerFactory(out)
+	m := &wasm.Module{
+		TypeSection:     []*wasm.FunctionType{{}},
+		FunctionSection: []wasm.Index{0, 0},
+		CodeSection:     []*wasm.Code{{Body: []byte{wasm.OpcodeEnd}}, {Body: []byte{wasm.OpcodeEnd}}},
+		NameSection: &wasm.NameSection{
+			ModuleName:    "test",
+			FunctionNames: wasm.NameMap{{Index: 0, Name: "fn1"}, {Index: 1, Name: "fn2"}},
+		},
+	}
+	m.BuildFunctionDefinitions()
+	def1 := m.FunctionDefinitionSection[0]
+	l1 := lf.NewListener(def1)
+	def2 := m.FunctionDefinitionSection[1]
+	l2 := lf.NewListener(def2)
+
+	ctx := l1.Before(testCtx, nil, def1, []uint64{}, nil)
+	ctx1 := l2.Before(ctx, nil, def2, []uint64{}, nil)
+	l2.After(ctx1, nil, def2, []uint64{})
+	l1.After(ctx, nil, def1, []uint64{})
+	require.Equal(t, `{"time":"","direction":"call","module":"test","function":"fn1","host":false,"nesting":0,"params":{}}
+{"time":"","direction":"call","module":"test","function":"fn2","host":false,"nesting":1,"params":{}}
+{"time":"","direction":"return","module":"test","function":"fn2","host":false,"nesting":1,"results":{}}
+{"time":"","direction":"return","module":"test","function":"fn1","host":false,"nesting":0,"results":{}}
+`, requireJSONLines(t, out.String()))
+}
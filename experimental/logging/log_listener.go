@@ -6,6 +6,7 @@ import (
 	"io"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/experimental"
@@ -15,9 +16,20 @@ import (
 // NewLoggingListenerFactory is an experimental.FunctionListenerFactory that
 // logs all functions that have a name to the writer.
 //
-// Use NewHostLoggingListenerFactory if only interested in host interactions.
-func NewLoggingListenerFactory(writer io.Writer) experimental.FunctionListenerFactory {
-	return &loggingListenerFactory{writer: writer}
+// Some WASI parameters are logged as the payload they point to in memory,
+// when readable: the path of "path_open", the length and a preview of each
+// iovec of "fd_write" and the subscriptions of "poll_oneoff". Flags, rights,
+// clock IDs, whence and advice are logged by their symbolic names, e.g.
+// "oflags=CREAT|TRUNC", and the stat results of "fd_fdstat_get",
+// "fd_filestat_get" and "path_filestat_get" including their filetype.
+//
+// Use NewHostLoggingListenerFactory if only interested in host interactions,
+// or pass filters to only log functions matching all of them. Pass
+// WithDurations or WithTotals to also log how long calls took, and
+// WithParamFormatter or WithResultFormatter to log the values of functions
+// with a domain-specific ABI meaningfully.
+func NewLoggingListenerFactory(writer io.Writer, opts ...Option) experimental.FunctionListenerFactory {
+	return newLoggingListenerFactory(&loggingListenerFactory{writer: writer}, opts)
 }
 
 // NewHostLoggingListenerFactory is an experimental.FunctionListenerFactory
@@ -28,14 +40,35 @@ func NewLoggingListenerFactory(writer io.Writer) experimental.FunctionListenerFa
 //
 // For example, "_start" is defined by the guest, but exported, so would be
 // written to the writer in order to provide minimal context needed to
-// understand host calls such as "fd_open".
-func NewHostLoggingListenerFactory(writer io.Writer) experimental.FunctionListenerFactory {
-	return &loggingListenerFactory{writer: writer, hostOnly: true}
+// understand host calls such as "fd_open". Pass filters to only log
+// functions matching all of them, too.
+func NewHostLoggingListenerFactory(writer io.Writer, opts ...Option) experimental.FunctionListenerFactory {
+	return newLoggingListenerFactory(&loggingListenerFactory{writer: writer, hostOnly: true}, opts)
+}
+
+func newLoggingListenerFactory(f *loggingListenerFactory, opts []Option) *loggingListenerFactory {
+	for _, opt := range opts {
+		opt.apply(f)
+	}
+	return f
 }
 
 type loggingListenerFactory struct {
 	writer   io.Writer
 	hostOnly bool
+	filters  []FunctionFilter
+	// json is true when writing JSON instead of text.
+	json bool
+	// durations is true when logging how long each call took.
+	durations bool
+	// totals is true when also logging the total of all calls so far.
+	totals bool
+	// paramFormatters and resultFormatters are set by WithParamFormatter
+	// and WithResultFormatter.
+	paramFormatters, resultFormatters map[formatterKey]Formatter
+	// redactedParams and redactedResults are set by RedactParams and
+	// RedactResults.
+	redactedParams, redactedResults map[formatterKey]map[int]RedactMode
 }
 
 // NewListener implements the same method as documented on
@@ -47,6 +80,11 @@ func (f *loggingListenerFactory) NewListener(fnd api.FunctionDefinition) experim
 		!exported { // not callable by the host
 		return nil
 	}
+	for _, filter := range f.filters {
+		if !filter(fnd) {
+			return nil
+		}
+	}
 
 	// special-case formatting of WASI error number until there's a generic way
 	// to stringify parameters or results.
@@ -58,7 +96,21 @@ func (f *loggingListenerFactory) NewListener(fnd api.FunctionDefinition) experim
 			}
 		}
 	}
-	return &loggingListener{writer: f.writer, fnd: fnd, wasiErrnoPos: wasiErrnoPos}
+	return &loggingListener{
+		writer:          f.writer,
+		fnd:             fnd,
+		wasiErrnoPos:    wasiErrnoPos,
+		pointerParams:   bindPointerParams(fnd),
+		symbolicParams:  bindSymbolicParams(fnd),
+		resultParams:    bindResultParams(fnd, wasiErrnoPos),
+		json:            f.json,
+		durations:       f.durations,
+		totals:          f.totals,
+		paramFormatter:  f.paramFormatters[formatterKey{fnd.ModuleName(), fnd.Name()}],
+		resultFormatter: f.resultFormatters[formatterKey{fnd.ModuleName(), fnd.Name()}],
+		redactedParams:  f.redactedParams[formatterKey{fnd.ModuleName(), fnd.Name()}],
+		redactedResults: f.redactedResults[formatterKey{fnd.ModuleName(), fnd.Name()}],
+	}
 }
 
 // nestLevelKey holds state between logger.Before and loggingListener.After to ensure
@@ -68,34 +120,103 @@ type nestLevelKey struct{}
 // loggingListener implements experimental.FunctionListener to log entrance and exit
 // of each function call.
 type loggingListener struct {
+	// totalNanos and calls are first for 64-bit alignment of atomic access.
+	// They accumulate all calls to the function when logging totals.
+	totalNanos int64
+	calls      uint64
+
 	writer io.Writer
 	fnd    api.FunctionDefinition
 
 	// wasiErrnoPos is the result index of wasi_snapshot_preview1.Errno or -1.
 	wasiErrnoPos int
+
+	// pointerParams are parameters logged as the payload they point to in
+	// memory, e.g. the path of "path_open".
+	pointerParams []boundPointerParam
+
+	// symbolicParams are parameters logged as the symbolic name of their
+	// value, e.g. the oflags of "path_open".
+	symbolicParams []boundSymbolicParam
+
+	// resultParams are parameters logged after a successful call as the
+	// result they point to in memory, e.g. the stat of "fd_fdstat_get".
+	resultParams []boundResultParam
+
+	// paramFormatter and resultFormatter format the values instead of
+	// writing each, when not nil.
+	paramFormatter, resultFormatter Formatter
+
+	// redactedParams and redactedResults are the RedactMode of values by
+	// index, or nil if none are redacted.
+	redactedParams, redactedResults map[int]RedactMode
+
+	// json is true when writing JSON instead of text.
+	json bool
+
+	// durations and totals are the same as on loggingListenerFactory.
+	durations, totals bool
 }
 
 // Before logs to stdout the module and function name, prefixed with '-->' and
 // indented based on the call nesting level.
-func (l *loggingListener) Before(ctx context.Context, _ api.FunctionDefinition, vals []uint64) context.Context {
+func (l *loggingListener) Before(ctx context.Context, mod api.Module, _ api.FunctionDefinition, vals []uint64, _ experimental.StackIterator) context.Context {
 	nestLevel, _ := ctx.Value(nestLevelKey{}).(int)
 
-	l.writeIndented(true, nil, vals, nestLevel+1)
+	l.writeIndented(true, mod, nil, vals, nil, nil, nestLevel+1)
 
 	// Increase the next nesting level.
-	return context.WithValue(ctx, nestLevelKey{}, nestLevel+1)
+	ctx = context.WithValue(ctx, nestLevelKey{}, nestLevel+1)
+	ctx = l.saveResultOffsets(ctx, vals)
+	if l.durations {
+		// Start after logging, so the duration excludes it.
+		ctx = context.WithValue(ctx, startKey{}, time.Now())
+	}
+	return ctx
 }
 
 // After logs to stdout the module and function name, prefixed with '<--' and
 // indented based on the call nesting level.
-func (l *loggingListener) After(ctx context.Context, _ api.FunctionDefinition, err error, vals []uint64) {
+func (l *loggingListener) After(ctx context.Context, mod api.Module, _ api.FunctionDefinition, vals []uint64) {
+	l.writeExit(ctx, mod, nil, vals)
+}
+
+// Abort logs to stdout the module and function name, prefixed with '<--' and
+// indented based on the call nesting level, followed by the error.
+func (l *loggingListener) Abort(ctx context.Context, mod api.Module, _ api.FunctionDefinition, err error) {
+	l.writeExit(ctx, mod, err, nil)
+}
+
+func (l *loggingListener) writeExit(ctx context.Context, mod api.Module, err error, vals []uint64) {
 	// Note: We use the nest level directly even though it is the "next" nesting level.
 	// This works because our indent of zero nesting is one tab.
-	l.writeIndented(false, err, vals, ctx.Value(nestLevelKey{}).(int))
+	var t *timing
+	if l.durations {
+		t = l.timing(ctx)
+	}
+	results := l.decodeResults(ctx, mod, err, vals)
+	l.writeIndented(false, mod, err, vals, results, t, ctx.Value(nestLevelKey{}).(int))
+}
+
+// errorMessage returns the first line of the error message, as the rest is
+// usually a wasm stack trace, which is redundant with the log.
+func errorMessage(err error) string {
+	msg := err.Error()
+	if i := strings.IndexByte(msg, '\n'); i >= 0 {
+		msg = msg[:i]
+	}
+	return msg
 }
 
 // writeIndented writes an indented message like this: "-->\t\t\t$indentLevel$funcName\n"
-func (l *loggingListener) writeIndented(before bool, err error, vals []uint64, indentLevel int) {
+// results are those decodeResults returned after the call, and t is the
+// timing of the call when logging durations after it, or nil.
+func (l *loggingListener) writeIndented(before bool, mod api.Module, err error, vals []uint64, results []namedValue, t *timing, indentLevel int) {
+	if l.json {
+		l.writeJSON(before, mod, err, vals, results, t, indentLevel-1)
+		return
+	}
+
 	var message strings.Builder
 	for i := 1; i < indentLevel; i++ {
 		message.WriteByte('\t')
@@ -106,40 +227,70 @@ func (l *loggingListener) writeIndented(before bool, err error, vals []uint64, i
 		} else {
 			message.WriteString("--> ")
 		}
-		l.writeFuncEnter(&message, vals)
+		l.writeFuncEnter(&message, mod, vals)
 	} else { // after
 		if l.fnd.GoFunction() != nil {
 			message.WriteString("<==")
 		} else {
 			message.WriteString("<--")
 		}
-		l.writeFuncExit(&message, err, vals)
+		l.writeFuncExit(&message, mod, err, vals, results)
+		if t != nil {
+			t.writeText(&message)
+		}
 	}
 	message.WriteByte('\n')
 
 	_, _ = l.writer.Write([]byte(message.String()))
 }
 
-func (l *loggingListener) writeFuncEnter(message *strings.Builder, vals []uint64) {
+func (l *loggingListener) writeFuncEnter(message *strings.Builder, mod api.Module, vals []uint64) {
 	valLen := len(vals)
 	message.WriteString(l.fnd.DebugName())
 	message.WriteByte('(')
-	switch valLen {
-	case 0:
-	default:
-		i := l.writeParam(message, 0, vals)
-		for i < valLen {
+	if l.paramFormatter != nil {
+		message.WriteString(l.format(l.paramFormatter, mod, vals))
+		message.WriteByte(')')
+		return
+	}
+	decoded, replaced := l.decodeParams(mod, vals)
+	first := true
+	for i := 0; i < valLen; {
+		if replaced[i] {
+			i++
+			continue
+		}
+		if !first {
 			message.WriteByte(',')
-			i = l.writeParam(message, i, vals)
 		}
+		first = false
+		if mode, ok := l.redactedParams[i]; ok {
+			i = l.writeRedactedParam(message, mode, decoded, i, vals)
+			continue
+		}
+		if s, ok := decoded[i]; ok {
+			message.WriteString(l.fnd.ParamNames()[i])
+			message.WriteByte('=')
+			message.WriteString(s)
+			i++
+			continue
+		}
+		i = l.writeParam(message, i, vals)
 	}
 	message.WriteByte(')')
 }
 
-func (l *loggingListener) writeFuncExit(message *strings.Builder, err error, vals []uint64) {
+func (l *loggingListener) writeFuncExit(message *strings.Builder, mod api.Module, err error, vals []uint64, results []namedValue) {
 	if err != nil {
 		message.WriteString(" error: ")
-		message.WriteString(err.Error())
+		message.WriteString(errorMessage(err))
+		return
+	}
+	if l.resultFormatter != nil {
+		if s := l.format(l.resultFormatter, mod, vals); s != "" {
+			message.WriteByte(' ')
+			message.WriteString(s)
+		}
 		return
 	}
 	valLen := len(vals)
@@ -147,23 +298,33 @@ func (l *loggingListener) writeFuncExit(message *strings.Builder, err error, val
 		return
 	}
 	message.WriteByte(' ')
-	switch valLen {
-	case 1:
+	if valLen == 1 && len(results) == 0 {
 		l.writeResult(message, 0, vals)
-	default:
-		message.WriteByte('(')
-		i := l.writeResult(message, 0, vals)
-		for i < valLen {
-			message.WriteByte(',')
-			i = l.writeResult(message, i, vals)
-		}
-		message.WriteByte(')')
+		return
 	}
+	message.WriteByte('(')
+	// Results written to memory are first, as they are parameters.
+	for _, r := range results {
+		message.WriteString(r.name)
+		message.WriteByte('=')
+		message.WriteString(r.value)
+		message.WriteByte(',')
+	}
+	i := l.writeResult(message, 0, vals)
+	for i < valLen {
+		message.WriteByte(',')
+		i = l.writeResult(message, i, vals)
+	}
+	message.WriteByte(')')
 }
 
 func (l *loggingListener) writeResult(message *strings.Builder, i int, vals []uint64) int {
 	if i == l.wasiErrnoPos {
-		message.WriteString(wasi_snapshot_preview1.ErrnoName(uint32(vals[i])))
+		errno := wasi_snapshot_preview1.ErrnoName(uint32(vals[i]))
+		if mode, ok := l.redactedResults[i]; ok {
+			errno = redact(mode, errno)
+		}
+		message.WriteString(errno)
 		return i + 1
 	}
 
@@ -172,6 +333,12 @@ func (l *loggingListener) writeResult(message *strings.Builder, i int, vals []ui
 		message.WriteByte('=')
 	}
 
+	if mode, ok := l.redactedResults[i]; ok {
+		var val strings.Builder
+		i = l.writeVal(&val, l.fnd.ResultTypes()[i], i, vals)
+		message.WriteString(redact(mode, val.String()))
+		return i
+	}
 	return l.writeVal(message, l.fnd.ResultTypes()[i], i, vals)
 }
 
@@ -183,6 +350,24 @@ func (l *loggingListener) writeParam(message *strings.Builder, i int, vals []uin
 	return l.writeVal(message, l.fnd.ParamTypes()[i], i, vals)
 }
 
+// writeRedactedParam is like writeParam, except the value, which may be
+// decoded, is redacted.
+func (l *loggingListener) writeRedactedParam(message *strings.Builder, mode RedactMode, decoded map[int]string, i int, vals []uint64) int {
+	if len(l.fnd.ParamNames()) > 0 {
+		message.WriteString(l.fnd.ParamNames()[i])
+		message.WriteByte('=')
+	}
+	var val strings.Builder
+	next := i + 1
+	if s, ok := decoded[i]; ok {
+		val.WriteString(s)
+	} else {
+		next = l.writeVal(&val, l.fnd.ParamTypes()[i], i, vals)
+	}
+	message.WriteString(redact(mode, val.String()))
+	return next
+}
+
 // writeVal formats integers as signed even though the call site determines
 // if it is signed or not. This presents a better experience for values that
 // are often signed, such as seek offset. This concedes the rare intentional
@@ -199,7 +384,7 @@ func (l *loggingListener) writeVal(message *strings.Builder, t api.ValueType, i
 		message.WriteString(strconv.FormatFloat(float64(api.DecodeF32(v)), 'g', -1, 32))
 	case api.ValueTypeF64:
 		message.WriteString(strconv.FormatFloat(api.DecodeF64(v), 'g', -1, 64))
-	case 0x7b: // wasm.ValueTypeV128
+	case api.ValueTypeV128:
 		message.WriteString(fmt.Sprintf("%016x%016x", v, vals[i])) // fixed-width hex
 		i++
 	case api.ValueTypeExternref, 0x70: // wasm.ValueTypeFuncref
@@ -3,6 +3,7 @@ package logging_test
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
 	"math"
 	"testing"
@@ -50,6 +51,14 @@ func Test_loggingListener(t *testing.T) {
 			err:      io.EOF,
 			expected: `--> test.fn()
 <-- error: EOF
+`,
+		},
+		{
+			name:     "error stack trace",
+			functype: &wasm.FunctionType{},
+			err:      errors.New("wasm error: unreachable\nwasm stack trace:\n\ttest.fn()"),
+			expected: `--> test.fn()
+<-- error: wasm error: unreachable
 `,
 		},
 		{
@@ -301,8 +310,12 @@ func Test_loggingListener(t *testing.T) {
 			l := lf.NewListener(m.FunctionDefinitionSection[0])
 
 			out.Reset()
-			ctx := l.Before(testCtx, def, tc.params)
-			l.After(ctx, def, tc.err, tc.results)
+			ctx := l.Before(testCtx, nil, def, tc.params, nil)
+			if tc.err != nil {
+				l.Abort(ctx, nil, def, tc.err)
+			} else {
+				l.After(ctx, nil, def, tc.results)
+			}
 			require.Equal(t, tc.expected, out.String())
 		})
 	}
@@ -337,10 +350,10 @@ func Test_loggingListener_indentation(t *testing.T) {
 	def2 := m.FunctionDefinitionSection[1]
 	l2 := lf.NewListener(def2)
 
-	ctx := l1.Before(testCtx, def1, []uint64{})
-	ctx1 := l2.Before(ctx, def2, []uint64{})
-	l2.After(ctx1, def2, nil, []uint64{})
-	l1.After(ctx, def1, nil, []uint64{})
+	ctx := l1.Before(testCtx, nil, def1, []uint64{}, nil)
+	ctx1 := l2.Before(ctx, nil, def2, []uint64{}, nil)
+	l2.After(ctx1, nil, def2, []uint64{})
+	l1.After(ctx, nil, def1, []uint64{})
 	require.Equal(t, `--> test.fn1()
 	--> test.fn2()
 	<--
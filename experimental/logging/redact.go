@@ -0,0 +1,76 @@
+package logging
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// RedactMode is how a value is logged when redacted by RedactParams or
+// RedactResults.
+type RedactMode uint8
+
+const (
+	// Redact logs "[redacted]" instead of the value.
+	Redact RedactMode = iota
+
+	// Hash logs a prefix of the SHA-256 hash of the value instead of it, e.g.
+	// "sha256:2cf24dba5fb0a30e", so that calls with the same secret can be
+	// correlated without revealing it.
+	//
+	// Note: The hash isn't salted, so values with little entropy, such as
+	// small numbers, can be guessed from it.
+	Hash
+)
+
+// RedactParams logs the parameters at the given indexes of the function named
+// name in the module named moduleName according to the mode, so that traces
+// of functions carrying secrets can be shared safely. The value redacted is
+// what would otherwise be logged, so redacting a pointer parameter decoded
+// from memory, such as the iovs of "fd_write", redacts its payload. For
+// example, this hides what is written to files, but not their descriptors:
+//
+//	logging.NewLoggingListenerFactory(os.Stdout,
+//		logging.RedactParams("wasi_snapshot_preview1", "fd_write", logging.Hash, 1))
+//
+// Note: Values formatted by WithParamFormatter are not redacted.
+func RedactParams(moduleName, name string, mode RedactMode, indexes ...int) Option {
+	return optionFunc(func(f *loggingListenerFactory) {
+		if f.redactedParams == nil {
+			f.redactedParams = map[formatterKey]map[int]RedactMode{}
+		}
+		addRedacted(f.redactedParams, formatterKey{moduleName, name}, mode, indexes)
+	})
+}
+
+// RedactResults is like RedactParams, except it redacts the results at the
+// given indexes.
+//
+// Note: Values formatted by WithResultFormatter are not redacted.
+func RedactResults(moduleName, name string, mode RedactMode, indexes ...int) Option {
+	return optionFunc(func(f *loggingListenerFactory) {
+		if f.redactedResults == nil {
+			f.redactedResults = map[formatterKey]map[int]RedactMode{}
+		}
+		addRedacted(f.redactedResults, formatterKey{moduleName, name}, mode, indexes)
+	})
+}
+
+func addRedacted(redacted map[formatterKey]map[int]RedactMode, key formatterKey, mode RedactMode, indexes []int) {
+	m := redacted[key]
+	if m == nil {
+		m = map[int]RedactMode{}
+		redacted[key] = m
+	}
+	for _, i := range indexes {
+		m[i] = mode
+	}
+}
+
+// redact returns the text to log instead of the value.
+func redact(mode RedactMode, val string) string {
+	if mode == Hash {
+		sum := sha256.Sum256([]byte(val))
+		return "sha256:" + hex.EncodeToString(sum[:8])
+	}
+	return "[redacted]"
+}
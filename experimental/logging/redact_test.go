@@ -0,0 +1,114 @@
+package logging_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental/logging"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+func TestRedactParams(t *testing.T) {
+	r := wazero.NewRuntime(testCtx)
+	defer r.Close(testCtx)
+
+	mod, err := r.InstantiateModuleFromBinary(testCtx, binary.EncodeModule(&wasm.Module{
+		MemorySection: &wasm.Memory{Min: 1, Cap: 1, Max: 1},
+	}))
+	require.NoError(t, err)
+	// iovec at 0, pointing to "hello" at 8.
+	mod.Memory().Write(0, []byte{8, 0, 0, 0, 5, 0, 0, 0, 'h', 'e', 'l', 'l', 'o'})
+
+	i32 := api.ValueTypeI32
+	m := &wasm.Module{
+		TypeSection:     []*wasm.FunctionType{{Params: []api.ValueType{i32, i32, i32, i32}, Results: []api.ValueType{i32}}},
+		FunctionSection: []wasm.Index{0},
+		CodeSection:     []*wasm.Code{wasm.MustParseGoReflectFuncCode(func() {})},
+		NameSection: &wasm.NameSection{
+			ModuleName:    "wasi_snapshot_preview1",
+			FunctionNames: wasm.NameMap{{Name: "fd_write"}},
+			LocalNames:    wasm.IndirectNameMap{{NameMap: toNameMap([]string{"fd", "iovs", "iovs_len", "result.nwritten"})}},
+			ResultNames:   wasm.IndirectNameMap{{NameMap: toNameMap([]string{"errno"})}},
+		},
+	}
+	m.BuildFunctionDefinitions()
+	def := m.FunctionDefinitionSection[0]
+
+	tests := []struct {
+		name     string
+		opts     []logging.Option
+		json     bool
+		expected string
+	}{
+		{
+			name: "none",
+			expected: `==> wasi_snapshot_preview1.fd_write(fd=1,iovs=[5:"hello"],result.nwritten=64)
+<== ESUCCESS
+`,
+		},
+		{
+			name: "redact",
+			opts: []logging.Option{logging.RedactParams("wasi_snapshot_preview1", "fd_write", logging.Redact, 0, 3)},
+			expected: `==> wasi_snapshot_preview1.fd_write(fd=[redacted],iovs=[5:"hello"],result.nwritten=[redacted])
+<== ESUCCESS
+`,
+		},
+		{
+			name: "hash decoded",
+			opts: []logging.Option{logging.RedactParams("wasi_snapshot_preview1", "fd_write", logging.Hash, 1)},
+			// sha256 of `[5:"hello"]`
+			expected: `==> wasi_snapshot_preview1.fd_write(fd=1,iovs=sha256:ad9eb51751516d16,result.nwritten=64)
+<== ESUCCESS
+`,
+		},
+		{
+			name: "other function",
+			opts: []logging.Option{logging.RedactParams("wasi_snapshot_preview1", "fd_read", logging.Redact, 1)},
+			expected: `==> wasi_snapshot_preview1.fd_write(fd=1,iovs=[5:"hello"],result.nwritten=64)
+<== ESUCCESS
+`,
+		},
+		{
+			name: "result",
+			opts: []logging.Option{logging.RedactResults("wasi_snapshot_preview1", "fd_write", logging.Redact, 0)},
+			expected: `==> wasi_snapshot_preview1.fd_write(fd=1,iovs=[5:"hello"],result.nwritten=64)
+<== [redacted]
+`,
+		},
+		{
+			name: "json",
+			opts: []logging.Option{
+				logging.RedactParams("wasi_snapshot_preview1", "fd_write", logging.Hash, 1),
+				logging.RedactParams("wasi_snapshot_preview1", "fd_write", logging.Redact, 0),
+				logging.RedactResults("wasi_snapshot_preview1", "fd_write", logging.Redact, 0),
+			},
+			json: true,
+			expected: `{"time":"","direction":"call","module":"wasi_snapshot_preview1","function":"fd_write","host":true,"nesting":0,"params":{"fd":"[redacted]","iovs":"sha256:ad9eb51751516d16","result.nwritten":64}}
+{"time":"","direction":"return","module":"wasi_snapshot_preview1","function":"fd_write","host":true,"nesting":0,"results":{"errno":"[redacted]"}}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			lf := logging.NewLoggingListenerFactory(&out, tc.opts...)
+			if tc.json {
+				lf = logging.NewJSONLoggingListenerFactory(&out, tc.opts...)
+			}
+			l := lf.NewListener(def)
+			ctx := l.Before(testCtx, mod, def, []uint64{1, 0, 1, 64}, nil)
+			l.After(ctx, mod, def, []uint64{0})
+			if tc.json {
+				require.Equal(t, tc.expected, requireJSONLines(t, out.String()))
+			} else {
+				require.Equal(t, tc.expected, out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package logging
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Option configures a factory returned by this package, e.g. a
+// FunctionFilter or WithDurations.
+type Option interface {
+	apply(*loggingListenerFactory)
+}
+
+// apply implements Option.apply
+func (f FunctionFilter) apply(factory *loggingListenerFactory) {
+	factory.filters = append(factory.filters, f)
+}
+
+type optionFunc func(*loggingListenerFactory)
+
+// apply implements Option.apply
+func (o optionFunc) apply(factory *loggingListenerFactory) {
+	o(factory)
+}
+
+// WithDurations logs how long each call took when it returns, measured with
+// the host's monotonic clock. For example, `<== ESUCCESS [1.5µs]`, or the
+// field "duration_ns" in JSON.
+//
+// Note: The duration includes nested calls and logging them, so this is
+// only a rough latency profile.
+func WithDurations() Option {
+	return optionFunc(func(f *loggingListenerFactory) {
+		f.durations = true
+	})
+}
+
+// WithTotals is like WithDurations, except it also logs the total duration
+// and count of calls to the function so far. For example,
+// `<== ESUCCESS [1.5µs, total 4.5µs in 3 calls]`, or the fields "total_ns"
+// and "calls" in JSON.
+func WithTotals() Option {
+	return optionFunc(func(f *loggingListenerFactory) {
+		f.durations, f.totals = true, true
+	})
+}
+
+// startKey is a context.Context Value key. Its associated value is the
+// time.Time Before was called, when logging durations.
+type startKey struct{}
+
+// timing is how long a call took, and when logging totals, all calls to
+// the same function so far.
+type timing struct {
+	elapsed time.Duration
+	// total and calls are zero unless logging totals.
+	total time.Duration
+	calls uint64
+}
+
+// timing returns how long the call took since Before, or nil if unknown.
+func (l *loggingListener) timing(ctx context.Context) *timing {
+	start, ok := ctx.Value(startKey{}).(time.Time)
+	if !ok {
+		return nil
+	}
+	t := &timing{elapsed: time.Since(start)}
+	if l.totals {
+		t.total = time.Duration(atomic.AddInt64(&l.totalNanos, int64(t.elapsed)))
+		t.calls = atomic.AddUint64(&l.calls, 1)
+	}
+	return t
+}
+
+// writeText writes the timing like " [1.5µs, total 4.5µs in 3 calls]".
+func (t *timing) writeText(message *strings.Builder) {
+	message.WriteString(" [")
+	message.WriteString(t.elapsed.String())
+	if t.calls > 0 {
+		message.WriteString(", total ")
+		message.WriteString(t.total.String())
+		message.WriteString(" in ")
+		message.WriteString(strconv.FormatUint(t.calls, 10))
+		if t.calls == 1 {
+			message.WriteString(" call")
+		} else {
+			message.WriteString(" calls")
+		}
+	}
+	message.WriteByte(']')
+}
+
+// writeJSON writes the timing as JSON fields, each preceded by a comma.
+func (t *timing) writeJSON(message *strings.Builder) {
+	message.WriteString(`,"duration_ns":`)
+	message.WriteString(strconv.FormatInt(int64(t.elapsed), 10))
+	if t.calls > 0 {
+		message.WriteString(`,"total_ns":`)
+		message.WriteString(strconv.FormatInt(int64(t.total), 10))
+		message.WriteString(`,"calls":`)
+		message.WriteString(strconv.FormatUint(t.calls, 10))
+	}
+}
@@ -0,0 +1,82 @@
+package logging_test
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"testing"
+
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/experimental/logging"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+var (
+	textDuration = regexp.MustCompile(`\d+(\.\d+)?(ns|µs|ms|s)`)
+	jsonDuration = regexp.MustCompile(`"(duration_ns|total_ns)":\d+`)
+)
+
+// logTwoCalls logs two calls to a host function, the second failing, and
+// returns the output with durations replaced by "D".
+func logTwoCalls(t *testing.T, newFactory func(io.Writer, ...logging.Option) experimental.FunctionListenerFactory, opts ...logging.Option) string {
+	m := &wasm.Module{
+		TypeSection:     []*wasm.FunctionType{{}},
+		FunctionSection: []wasm.Index{0},
+		CodeSection:     []*wasm.Code{wasm.MustParseGoReflectFuncCode(func() {})},
+		NameSection: &wasm.NameSection{
+			ModuleName:    "test",
+			FunctionNames: wasm.NameMap{{Name: "fn"}},
+		},
+	}
+	m.BuildFunctionDefinitions()
+	def := m.FunctionDefinitionSection[0]
+
+	var out bytes.Buffer
+	l := newFactory(&out, opts...).NewListener(def)
+	require.NotNil(t, l)
+
+	ctx := l.Before(testCtx, nil, def, nil, nil)
+	l.After(ctx, nil, def, nil)
+	ctx = l.Before(testCtx, nil, def, nil, nil)
+	l.Abort(ctx, nil, def, io.EOF)
+
+	if out.String()[0] == '{' {
+		return jsonDuration.ReplaceAllString(requireJSONLines(t, out.String()), `"$1":D`)
+	}
+	return textDuration.ReplaceAllString(out.String(), "D")
+}
+
+func TestWithDurations(t *testing.T) {
+	require.Equal(t, `==> test.fn()
+<==
+==> test.fn()
+<== error: EOF
+`, logTwoCalls(t, logging.NewLoggingListenerFactory))
+
+	require.Equal(t, `==> test.fn()
+<== [D]
+==> test.fn()
+<== error: EOF [D]
+`, logTwoCalls(t, logging.NewLoggingListenerFactory, logging.WithDurations()))
+
+	require.Equal(t, `{"time":"","direction":"call","module":"test","function":"fn","host":true,"nesting":0,"params":{}}
+{"time":"","direction":"return","module":"test","function":"fn","host":true,"nesting":0,"results":{},"duration_ns":D}
+{"time":"","direction":"call","module":"test","function":"fn","host":true,"nesting":0,"params":{}}
+{"time":"","direction":"return","module":"test","function":"fn","host":true,"nesting":0,"error":"EOF","duration_ns":D}
+`, logTwoCalls(t, logging.NewJSONLoggingListenerFactory, logging.WithDurations()))
+}
+
+func TestWithTotals(t *testing.T) {
+	require.Equal(t, `==> test.fn()
+<== [D, total D in 1 call]
+==> test.fn()
+<== error: EOF [D, total D in 2 calls]
+`, logTwoCalls(t, logging.NewHostLoggingListenerFactory, logging.WithTotals()))
+
+	require.Equal(t, `{"time":"","direction":"call","module":"test","function":"fn","host":true,"nesting":0,"params":{}}
+{"time":"","direction":"return","module":"test","function":"fn","host":true,"nesting":0,"results":{},"duration_ns":D,"total_ns":D,"calls":1}
+{"time":"","direction":"call","module":"test","function":"fn","host":true,"nesting":0,"params":{}}
+{"time":"","direction":"return","module":"test","function":"fn","host":true,"nesting":0,"error":"EOF","duration_ns":D,"total_ns":D,"calls":2}
+`, logTwoCalls(t, logging.NewJSONLoggingListenerFactory, logging.WithTotals(), logging.HostFunctions()))
+}
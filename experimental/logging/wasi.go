@@ -0,0 +1,395 @@
+package logging
+
+import (
+	"context"
+	"encoding/binary"
+	"strconv"
+	"strings"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/wasi_snapshot_preview1"
+)
+
+// pointerParam is a parameter which is an offset in memory, logged as the
+// payload it points to instead of the offset and its length.
+type pointerParam struct {
+	// name and lenName are the names of the offset and length parameters.
+	name, lenName string
+	// decode returns the payload as text, or false if it can't be read.
+	decode func(mem api.Memory, offset, length uint32) (string, bool)
+}
+
+// wasiPointerParams are the pointerParam of wasi_snapshot_preview1 functions
+// by function name.
+var wasiPointerParams = map[string][]pointerParam{
+	"path_open":   {{name: "path", lenName: "path_len", decode: decodeString}},
+	"fd_write":    {{name: "iovs", lenName: "iovs_len", decode: decodeIovecs}},
+	"poll_oneoff": {{name: "in", lenName: "nsubscriptions", decode: decodeSubscriptions}},
+}
+
+// boundPointerParam is a pointerParam resolved to parameter indexes.
+type boundPointerParam struct {
+	i, lenI int
+	decode  func(mem api.Memory, offset, length uint32) (string, bool)
+}
+
+// bindPointerParams returns the pointer parameters of the function, or nil
+// if there are none or its parameters aren't named.
+func bindPointerParams(fnd api.FunctionDefinition) (ret []boundPointerParam) {
+	if fnd.ModuleName() != "wasi_snapshot_preview1" {
+		return
+	}
+	for _, p := range wasiPointerParams[fnd.Name()] {
+		i, lenI := -1, -1
+		for j, n := range fnd.ParamNames() {
+			switch n {
+			case p.name:
+				i = j
+			case p.lenName:
+				lenI = j
+			}
+		}
+		if i != -1 && lenI != -1 {
+			ret = append(ret, boundPointerParam{i: i, lenI: lenI, decode: p.decode})
+		}
+	}
+	return
+}
+
+// symbolicParam is a parameter logged as the symbolic name of its value
+// instead of a number, e.g. the oflags of "path_open".
+type symbolicParam struct {
+	name string
+	// decode returns the name of the value, or false to log the number, e.g.
+	// when no flags are set.
+	decode func(v uint64) (string, bool)
+}
+
+// Decoders of symbolicParam by the type of the parameter.
+var (
+	decodeAdvice      = enumName(wasi_snapshot_preview1.AdviceName)
+	decodeClockID     = enumName(wasi_snapshot_preview1.ClockIDName)
+	decodeWhence      = enumName(wasi_snapshot_preview1.WhenceName)
+	decodeFdflags     = flagNames(wasi_snapshot_preview1.FdflagsString)
+	decodeFstflags    = flagNames(wasi_snapshot_preview1.FstflagsString)
+	decodeLookupflags = flagNames(wasi_snapshot_preview1.LookupflagsString)
+	decodeOflags      = flagNames(wasi_snapshot_preview1.OflagsString)
+	decodeRiflags     = flagNames(wasi_snapshot_preview1.RiflagsString)
+	decodeRights      = flagNames(wasi_snapshot_preview1.RightsString)
+	decodeSdflags     = flagNames(wasi_snapshot_preview1.SdflagsString)
+)
+
+// wasiSymbolicParams are the symbolicParam of wasi_snapshot_preview1
+// functions by function name.
+var wasiSymbolicParams = map[string][]symbolicParam{
+	"clock_res_get":           {{"id", decodeClockID}},
+	"clock_time_get":          {{"id", decodeClockID}},
+	"fd_advise":               {{"advice", decodeAdvice}},
+	"fd_fdstat_set_flags":     {{"flags", decodeFdflags}},
+	"fd_fdstat_set_rights":    {{"fs_rights_base", decodeRights}, {"fs_rights_inheriting", decodeRights}},
+	"fd_filestat_set_times":   {{"fst_flags", decodeFstflags}},
+	"fd_seek":                 {{"whence", decodeWhence}},
+	"path_filestat_get":       {{"flags", decodeLookupflags}},
+	"path_filestat_set_times": {{"flags", decodeLookupflags}, {"fst_flags", decodeFstflags}},
+	"path_link":               {{"old_flags", decodeLookupflags}},
+	"path_open": {
+		{"dirflags", decodeLookupflags},
+		{"oflags", decodeOflags},
+		{"fs_rights_base", decodeRights},
+		{"fs_rights_inheriting", decodeRights},
+		{"fdflags", decodeFdflags},
+	},
+	"sock_accept":   {{"flags", decodeFdflags}},
+	"sock_recv":     {{"ri_flags", decodeRiflags}},
+	"sock_shutdown": {{"how", decodeSdflags}},
+}
+
+// enumName adapts a function returning the name of an enum value.
+func enumName(name func(uint32) string) func(uint64) (string, bool) {
+	return func(v uint64) (string, bool) {
+		return name(uint32(v)), true
+	}
+}
+
+// flagNames adapts a function returning the names of set flags. Zero is
+// logged as a number, as there are no names.
+func flagNames(names func(uint64) string) func(uint64) (string, bool) {
+	return func(v uint64) (string, bool) {
+		return names(v), v != 0
+	}
+}
+
+// boundSymbolicParam is a symbolicParam resolved to its parameter index.
+type boundSymbolicParam struct {
+	i      int
+	decode func(v uint64) (string, bool)
+}
+
+// bindSymbolicParams returns the symbolic parameters of the function, or nil
+// if there are none or its parameters aren't named.
+func bindSymbolicParams(fnd api.FunctionDefinition) (ret []boundSymbolicParam) {
+	if fnd.ModuleName() != "wasi_snapshot_preview1" {
+		return
+	}
+	for _, p := range wasiSymbolicParams[fnd.Name()] {
+		for j, n := range fnd.ParamNames() {
+			if n == p.name {
+				ret = append(ret, boundSymbolicParam{i: j, decode: p.decode})
+			}
+		}
+	}
+	return
+}
+
+// resultParam is a parameter which is an offset in memory the function
+// writes its result to, logged as the decoded result when it succeeds.
+type resultParam struct {
+	name string
+	// decode returns the result as text, or false if it can't be read.
+	decode func(mem api.Memory, offset uint32) (string, bool)
+}
+
+// wasiResultParams are the resultParam of wasi_snapshot_preview1 functions
+// by function name.
+var wasiResultParams = map[string][]resultParam{
+	"fd_fdstat_get":     {{name: "result.stat", decode: decodeFdstat}},
+	"fd_filestat_get":   {{name: "result.buf", decode: decodeFilestat}},
+	"path_filestat_get": {{name: "result.buf", decode: decodeFilestat}},
+}
+
+// boundResultParam is a resultParam resolved to its parameter index.
+type boundResultParam struct {
+	i      int
+	name   string
+	decode func(mem api.Memory, offset uint32) (string, bool)
+}
+
+// bindResultParams returns the result parameters of the function, or nil if
+// there are none, its parameters aren't named or it doesn't return an errno.
+func bindResultParams(fnd api.FunctionDefinition, errnoPos int) (ret []boundResultParam) {
+	if fnd.ModuleName() != "wasi_snapshot_preview1" || errnoPos == -1 {
+		return
+	}
+	for _, p := range wasiResultParams[fnd.Name()] {
+		for j, n := range fnd.ParamNames() {
+			if n == p.name {
+				ret = append(ret, boundResultParam{i: j, name: p.name, decode: p.decode})
+			}
+		}
+	}
+	return
+}
+
+// resultOffsetsKey is a context.Context Value key. Its associated value is
+// the []uint32 offsets of the result parameters of the call, saved by Before
+// for decodeResults.
+type resultOffsetsKey struct{}
+
+// namedValue is a decoded value and its name.
+type namedValue struct {
+	name, value string
+}
+
+// decodeParams returns the decoded parameters by index, and the indexes of
+// the length parameters of pointers they replace. Both are nil if nothing
+// could be decoded.
+func (l *loggingListener) decodeParams(mod api.Module, vals []uint64) (decoded map[int]string, replaced map[int]bool) {
+	for _, p := range l.symbolicParams {
+		s, ok := p.decode(vals[p.i])
+		if !ok {
+			continue // log the raw value instead.
+		}
+		if decoded == nil {
+			decoded, replaced = map[int]string{}, map[int]bool{}
+		}
+		decoded[p.i] = s
+	}
+
+	if len(l.pointerParams) == 0 || mod == nil || mod.Memory() == nil {
+		return
+	}
+	mem := mod.Memory()
+	for _, p := range l.pointerParams {
+		s, ok := p.decode(mem, uint32(vals[p.i]), uint32(vals[p.lenI]))
+		if !ok {
+			continue // log the raw values instead.
+		}
+		if decoded == nil {
+			decoded, replaced = map[int]string{}, map[int]bool{}
+		}
+		decoded[p.i] = s
+		replaced[p.lenI] = true
+	}
+	return
+}
+
+// saveResultOffsets returns a context with the offsets of the result
+// parameters of the call, if the function has any.
+func (l *loggingListener) saveResultOffsets(ctx context.Context, vals []uint64) context.Context {
+	if len(l.resultParams) == 0 {
+		return ctx
+	}
+	offsets := make([]uint32, len(l.resultParams))
+	for i, p := range l.resultParams {
+		offsets[i] = uint32(vals[p.i])
+	}
+	return context.WithValue(ctx, resultOffsetsKey{}, offsets)
+}
+
+// decodeResults returns the results written to memory by a successful call,
+// or nil if there are none or they couldn't be read.
+func (l *loggingListener) decodeResults(ctx context.Context, mod api.Module, err error, vals []uint64) (ret []namedValue) {
+	if len(l.resultParams) == 0 || err != nil || vals[l.wasiErrnoPos] != 0 || mod == nil || mod.Memory() == nil {
+		return
+	}
+	offsets, ok := ctx.Value(resultOffsetsKey{}).([]uint32)
+	if !ok {
+		return
+	}
+	mem := mod.Memory()
+	for i, p := range l.resultParams {
+		if s, ok := p.decode(mem, offsets[i]); ok {
+			ret = append(ret, namedValue{name: p.name, value: s})
+		}
+	}
+	return
+}
+
+// decodeFdstat returns the type and flags of the fdstat written by
+// fd_fdstat_get, e.g. `{filetype=REGULAR_FILE,fs_flags=APPEND}`. Rights are
+// skipped, as they are usually all those applicable to the filetype, which
+// would make the line unreadable.
+func decodeFdstat(mem api.Memory, offset uint32) (string, bool) {
+	// Offsets are the same as written by fd_fdstat_get.
+	buf, ok := mem.Read(offset, 24)
+	if !ok {
+		return "", false
+	}
+	var ret strings.Builder
+	ret.WriteString("{filetype=")
+	ret.WriteString(wasi_snapshot_preview1.FiletypeName(buf[0]))
+	ret.WriteString(",fs_flags=")
+	ret.WriteString(wasi_snapshot_preview1.FdflagsString(uint64(binary.LittleEndian.Uint16(buf[2:]))))
+	ret.WriteByte('}')
+	return ret.String(), true
+}
+
+// decodeFilestat returns the type and size of the filestat written by
+// fd_filestat_get or path_filestat_get, e.g. `{filetype=REGULAR_FILE,size=5}`.
+func decodeFilestat(mem api.Memory, offset uint32) (string, bool) {
+	// Offsets are the same as written by fd_filestat_get.
+	buf, ok := mem.Read(offset, 64)
+	if !ok {
+		return "", false
+	}
+	var ret strings.Builder
+	ret.WriteString("{filetype=")
+	ret.WriteString(wasi_snapshot_preview1.FiletypeName(buf[16]))
+	ret.WriteString(",size=")
+	ret.WriteString(strconv.FormatUint(binary.LittleEndian.Uint64(buf[32:]), 10))
+	ret.WriteByte('}')
+	return ret.String(), true
+}
+
+// maxPreview is the maximum bytes of an iovec and count of iovecs or
+// subscriptions decoded, beyond which "..." is written.
+const maxPreview = 16
+
+// decodeString returns the string at the offset, e.g. a path.
+func decodeString(mem api.Memory, offset, length uint32) (string, bool) {
+	b, ok := mem.Read(offset, length)
+	return string(b), ok
+}
+
+// decodeIovecs returns the length and quoted preview of each iovec, e.g.
+// `[5:"hello",3:"abc"]`.
+func decodeIovecs(mem api.Memory, offset, length uint32) (string, bool) {
+	if uint64(length)*8 > uint64(mem.Size()) {
+		return "", false
+	}
+	iovs, ok := mem.Read(offset, length*8)
+	if !ok {
+		return "", false
+	}
+
+	var ret strings.Builder
+	ret.WriteByte('[')
+	for i := uint32(0); i < length; i++ {
+		if i > 0 {
+			ret.WriteByte(',')
+		}
+		if i == maxPreview {
+			ret.WriteString("...")
+			break
+		}
+		iov := iovs[i*8:]
+		bufOffset, bufLen := binary.LittleEndian.Uint32(iov), binary.LittleEndian.Uint32(iov[4:])
+		ret.WriteString(strconv.FormatUint(uint64(bufLen), 10))
+		ret.WriteByte(':')
+
+		previewLen := bufLen
+		if previewLen > maxPreview {
+			previewLen = maxPreview
+		}
+		buf, ok := mem.Read(bufOffset, previewLen)
+		if !ok {
+			return "", false
+		}
+		ret.WriteString(strconv.Quote(string(buf)))
+		if previewLen < bufLen {
+			ret.WriteString("...")
+		}
+	}
+	ret.WriteByte(']')
+	return ret.String(), true
+}
+
+// decodeSubscriptions returns the event type and details of each
+// subscription, e.g. `[clock(timeout=1000),fd_read(fd=0)]`.
+func decodeSubscriptions(mem api.Memory, offset, length uint32) (string, bool) {
+	if uint64(length)*48 > uint64(mem.Size()) {
+		return "", false
+	}
+	in, ok := mem.Read(offset, length*48)
+	if !ok {
+		return "", false
+	}
+
+	var ret strings.Builder
+	ret.WriteByte('[')
+	for i := uint32(0); i < length; i++ {
+		if i > 0 {
+			ret.WriteByte(',')
+		}
+		if i == maxPreview {
+			ret.WriteString("...")
+			break
+		}
+		// Offsets are the same as read by poll_oneoff.
+		sub := in[i*48:]
+		switch eventType := sub[8]; eventType {
+		case 0: // clock
+			ret.WriteString("clock(timeout=")
+			ret.WriteString(strconv.FormatUint(binary.LittleEndian.Uint64(sub[24:]), 10))
+			if flags := binary.LittleEndian.Uint16(sub[40:]); flags != 0 {
+				ret.WriteString(",flags=")
+				ret.WriteString(strconv.FormatUint(uint64(flags), 10))
+			}
+			ret.WriteByte(')')
+		case 1, 2: // fd_read, fd_write
+			if eventType == 1 {
+				ret.WriteString("fd_read(fd=")
+			} else {
+				ret.WriteString("fd_write(fd=")
+			}
+			ret.WriteString(strconv.FormatUint(uint64(binary.LittleEndian.Uint32(sub[12:])), 10))
+			ret.WriteByte(')')
+		default:
+			ret.WriteString("unknown(type=")
+			ret.WriteString(strconv.Itoa(int(eventType)))
+			ret.WriteByte(')')
+		}
+	}
+	ret.WriteByte(']')
+	return ret.String(), true
+}
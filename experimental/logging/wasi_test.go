@@ -0,0 +1,292 @@
+package logging_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental/logging"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+func Test_loggingListener_pointerParams(t *testing.T) {
+	r := wazero.NewRuntime(testCtx)
+	defer r.Close(testCtx)
+
+	mod, err := r.InstantiateModuleFromBinary(testCtx, binary.EncodeModule(&wasm.Module{
+		MemorySection: &wasm.Memory{Min: 1, Cap: 1, Max: 1},
+	}))
+	require.NoError(t, err)
+	mem := mod.Memory()
+
+	// path at 0
+	mem.Write(0, []byte("wazero"))
+	// iovecs at 16: "waze", "ro" and 21 bytes, which are truncated.
+	mem.Write(64, []byte("wazeroaaaaaaaaaaaaaaaaaaaa\n"))
+	mem.Write(16, []byte{
+		64, 0, 0, 0, 4, 0, 0, 0,
+		68, 0, 0, 0, 2, 0, 0, 0,
+		70, 0, 0, 0, 21, 0, 0, 0,
+	})
+	// subscriptions at 128, with offsets as read by poll_oneoff.
+	sub := make([]byte, 48*3)
+	sub[8], sub[24], sub[25] = 0, 0xe8, 0x03 // clock timeout=1000
+	sub[48+8], sub[48+12] = 1, 5             // fd_read fd=5
+	sub[96+8] = 7                            // unknown
+	mem.Write(128, sub)
+
+	i32 := api.ValueTypeI32
+	tests := []struct {
+		name       string
+		funcName   string
+		paramNames []string
+		params     []uint64
+		mod        api.Module
+		expected   string
+	}{
+		{
+			name:       "path_open",
+			funcName:   "path_open",
+			paramNames: []string{"fd", "dirflags", "path", "path_len", "oflags"},
+			params:     []uint64{3, 0, 0, 6, 0},
+			mod:        mod,
+			expected:   "==> wasi_snapshot_preview1.path_open(fd=3,dirflags=0,path=wazero,oflags=0)\n",
+		},
+		{
+			name:       "path_open out of range",
+			funcName:   "path_open",
+			paramNames: []string{"fd", "dirflags", "path", "path_len", "oflags"},
+			params:     []uint64{3, 0, uint64(wasm.MemoryPageSize), 6, 0},
+			mod:        mod,
+			expected:   "==> wasi_snapshot_preview1.path_open(fd=3,dirflags=0,path=65536,path_len=6,oflags=0)\n",
+		},
+		{
+			name:       "path_open no module",
+			funcName:   "path_open",
+			paramNames: []string{"fd", "dirflags", "path", "path_len", "oflags"},
+			params:     []uint64{3, 0, 0, 6, 0},
+			expected:   "==> wasi_snapshot_preview1.path_open(fd=3,dirflags=0,path=0,path_len=6,oflags=0)\n",
+		},
+		{
+			name:       "fd_write",
+			funcName:   "fd_write",
+			paramNames: []string{"fd", "iovs", "iovs_len", "result.nwritten"},
+			params:     []uint64{1, 16, 3, 512},
+			mod:        mod,
+			expected:   `==> wasi_snapshot_preview1.fd_write(fd=1,iovs=[4:"waze",2:"ro",21:"aaaaaaaaaaaaaaaa"...],result.nwritten=512)` + "\n",
+		},
+		{
+			name:       "fd_write iovs_len out of range",
+			funcName:   "fd_write",
+			paramNames: []string{"fd", "iovs", "iovs_len", "result.nwritten"},
+			params:     []uint64{1, 16, 1 << 30, 512},
+			mod:        mod,
+			expected:   "==> wasi_snapshot_preview1.fd_write(fd=1,iovs=16,iovs_len=1073741824,result.nwritten=512)\n",
+		},
+		{
+			name:       "path_open flags",
+			funcName:   "path_open",
+			paramNames: []string{"fd", "dirflags", "path", "path_len", "oflags", "fs_rights_base", "fs_rights_inheriting", "fdflags"},
+			params:     []uint64{3, 1, 0, 6, 9, 66, 0, 1 << 2},
+			mod:        mod,
+			expected:   "==> wasi_snapshot_preview1.path_open(fd=3,dirflags=SYMLINK_FOLLOW,path=wazero,oflags=CREAT|TRUNC,fs_rights_base=FD_READ|FD_WRITE,fs_rights_inheriting=0,fdflags=NONBLOCK)\n",
+		},
+		{
+			name:       "path_open unknown oflags",
+			funcName:   "path_open",
+			paramNames: []string{"fd", "dirflags", "path", "path_len", "oflags"},
+			params:     []uint64{3, 0, 0, 6, 0x11},
+			mod:        mod,
+			expected:   "==> wasi_snapshot_preview1.path_open(fd=3,dirflags=0,path=wazero,oflags=CREAT|0x10)\n",
+		},
+		{
+			name:       "fd_seek",
+			funcName:   "fd_seek",
+			paramNames: []string{"fd", "offset", "whence", "result.newoffset"},
+			params:     []uint64{3, 0, 2, 512},
+			expected:   "==> wasi_snapshot_preview1.fd_seek(fd=3,offset=0,whence=END,result.newoffset=512)\n",
+		},
+		{
+			name:       "clock_time_get",
+			funcName:   "clock_time_get",
+			paramNames: []string{"id", "precision", "result.timestamp"},
+			params:     []uint64{1, 0, 512},
+			expected:   "==> wasi_snapshot_preview1.clock_time_get(id=MONOTONIC,precision=0,result.timestamp=512)\n",
+		},
+		{
+			name:       "poll_oneoff",
+			funcName:   "poll_oneoff",
+			paramNames: []string{"in", "out", "nsubscriptions", "result.nevents"},
+			params:     []uint64{128, 512, 3, 1024},
+			mod:        mod,
+			expected:   "==> wasi_snapshot_preview1.poll_oneoff(in=[clock(timeout=1000),fd_read(fd=5),unknown(type=7)],out=512,result.nevents=1024)\n",
+		},
+	}
+
+	var out bytes.Buffer
+	lf := logging.NewLoggingListenerFactory(&out)
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			params := make([]api.ValueType, len(tc.params))
+			for i := range params {
+				params[i] = i32
+			}
+			m := &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{{Params: params, Results: []api.ValueType{i32}}},
+				FunctionSection: []wasm.Index{0},
+				CodeSection:     []*wasm.Code{wasm.MustParseGoReflectFuncCode(func() {})},
+				NameSection: &wasm.NameSection{
+					ModuleName:    "wasi_snapshot_preview1",
+					FunctionNames: wasm.NameMap{{Name: tc.funcName}},
+					LocalNames:    wasm.IndirectNameMap{{NameMap: toNameMap(tc.paramNames)}},
+				},
+			}
+			m.BuildFunctionDefinitions()
+			def := m.FunctionDefinitionSection[0]
+			l := lf.NewListener(def)
+
+			out.Reset()
+			l.Before(testCtx, tc.mod, def, tc.params, nil)
+			require.Equal(t, tc.expected, out.String())
+		})
+	}
+
+	t.Run("json", func(t *testing.T) {
+		lf := logging.NewJSONLoggingListenerFactory(&out)
+		m := &wasm.Module{
+			TypeSection:     []*wasm.FunctionType{{Params: []api.ValueType{i32, i32}}},
+			FunctionSection: []wasm.Index{0},
+			CodeSection:     []*wasm.Code{wasm.MustParseGoReflectFuncCode(func() {})},
+			NameSection: &wasm.NameSection{
+				ModuleName:    "wasi_snapshot_preview1",
+				FunctionNames: wasm.NameMap{{Name: "path_open"}},
+				LocalNames:    wasm.IndirectNameMap{{NameMap: toNameMap([]string{"path", "path_len"})}},
+			},
+		}
+		m.BuildFunctionDefinitions()
+		def := m.FunctionDefinitionSection[0]
+
+		out.Reset()
+		lf.NewListener(def).Before(testCtx, mod, def, []uint64{0, 6}, nil)
+		require.Equal(t, `{"time":"","direction":"call","module":"wasi_snapshot_preview1","function":"path_open","host":true,"nesting":0,"params":{"path":"wazero"}}
+`, requireJSONLines(t, out.String()))
+	})
+}
+
+func Test_loggingListener_resultParams(t *testing.T) {
+	r := wazero.NewRuntime(testCtx)
+	defer r.Close(testCtx)
+
+	mod, err := r.InstantiateModuleFromBinary(testCtx, binary.EncodeModule(&wasm.Module{
+		MemorySection: &wasm.Memory{Min: 1, Cap: 1, Max: 1},
+	}))
+	require.NoError(t, err)
+	mem := mod.Memory()
+
+	// fdstat at 0, with offsets as written by fd_fdstat_get.
+	mem.WriteByte(0, 4)       // filetype=REGULAR_FILE
+	mem.WriteUint16Le(2, 1)   // fs_flags=APPEND
+	mem.WriteUint64Le(8, 0x2) // fs_rights_base=FD_READ
+	// filestat at 64, with offsets as written by fd_filestat_get.
+	mem.WriteByte(64+16, 3)     // filetype=DIRECTORY
+	mem.WriteUint64Le(64+32, 5) // size=5
+
+	i32 := api.ValueTypeI32
+	tests := []struct {
+		name                 string
+		funcName, paramName  string
+		params               []uint64
+		errno                uint64
+		expected, expectJSON string
+	}{
+		{
+			name:      "fd_fdstat_get",
+			funcName:  "fd_fdstat_get",
+			paramName: "result.stat",
+			params:    []uint64{3, 0},
+			expected: `==> wasi_snapshot_preview1.fd_fdstat_get(fd=3,result.stat=0)
+<== (result.stat={filetype=REGULAR_FILE,fs_flags=APPEND},ESUCCESS)
+`,
+			expectJSON: `{"time":"","direction":"call","module":"wasi_snapshot_preview1","function":"fd_fdstat_get","host":true,"nesting":0,"params":{"fd":3,"result.stat":0}}
+{"time":"","direction":"return","module":"wasi_snapshot_preview1","function":"fd_fdstat_get","host":true,"nesting":0,"results":{"result.stat":"{filetype=REGULAR_FILE,fs_flags=APPEND}","errno":"ESUCCESS"}}
+`,
+		},
+		{
+			name:      "fd_filestat_get",
+			funcName:  "fd_filestat_get",
+			paramName: "result.buf",
+			params:    []uint64{3, 64},
+			expected: `==> wasi_snapshot_preview1.fd_filestat_get(fd=3,result.buf=64)
+<== (result.buf={filetype=DIRECTORY,size=5},ESUCCESS)
+`,
+			expectJSON: `{"time":"","direction":"call","module":"wasi_snapshot_preview1","function":"fd_filestat_get","host":true,"nesting":0,"params":{"fd":3,"result.buf":64}}
+{"time":"","direction":"return","module":"wasi_snapshot_preview1","function":"fd_filestat_get","host":true,"nesting":0,"results":{"result.buf":"{filetype=DIRECTORY,size=5}","errno":"ESUCCESS"}}
+`,
+		},
+		{
+			name:      "fd_filestat_get error",
+			funcName:  "fd_filestat_get",
+			paramName: "result.buf",
+			params:    []uint64{3, 64},
+			errno:     8, // EBADF
+			expected: `==> wasi_snapshot_preview1.fd_filestat_get(fd=3,result.buf=64)
+<== EBADF
+`,
+			expectJSON: `{"time":"","direction":"call","module":"wasi_snapshot_preview1","function":"fd_filestat_get","host":true,"nesting":0,"params":{"fd":3,"result.buf":64}}
+{"time":"","direction":"return","module":"wasi_snapshot_preview1","function":"fd_filestat_get","host":true,"nesting":0,"results":{"errno":"EBADF"}}
+`,
+		},
+		{
+			name:      "fd_filestat_get out of range",
+			funcName:  "fd_filestat_get",
+			paramName: "result.buf",
+			params:    []uint64{3, uint64(wasm.MemoryPageSize)},
+			expected: `==> wasi_snapshot_preview1.fd_filestat_get(fd=3,result.buf=65536)
+<== ESUCCESS
+`,
+			expectJSON: `{"time":"","direction":"call","module":"wasi_snapshot_preview1","function":"fd_filestat_get","host":true,"nesting":0,"params":{"fd":3,"result.buf":65536}}
+{"time":"","direction":"return","module":"wasi_snapshot_preview1","function":"fd_filestat_get","host":true,"nesting":0,"results":{"errno":"ESUCCESS"}}
+`,
+		},
+	}
+
+	var out bytes.Buffer
+	for _, tt := range tests {
+		tc := tt
+		m := &wasm.Module{
+			TypeSection:     []*wasm.FunctionType{{Params: []api.ValueType{i32, i32}, Results: []api.ValueType{i32}}},
+			FunctionSection: []wasm.Index{0},
+			CodeSection:     []*wasm.Code{wasm.MustParseGoReflectFuncCode(func() {})},
+			NameSection: &wasm.NameSection{
+				ModuleName:    "wasi_snapshot_preview1",
+				FunctionNames: wasm.NameMap{{Name: tc.funcName}},
+				LocalNames:    wasm.IndirectNameMap{{NameMap: toNameMap([]string{"fd", tc.paramName})}},
+				ResultNames:   wasm.IndirectNameMap{{NameMap: toNameMap([]string{"errno"})}},
+			},
+		}
+		m.BuildFunctionDefinitions()
+		def := m.FunctionDefinitionSection[0]
+
+		t.Run(tc.name, func(t *testing.T) {
+			l := logging.NewLoggingListenerFactory(&out).NewListener(def)
+
+			out.Reset()
+			ctx := l.Before(testCtx, mod, def, tc.params, nil)
+			l.After(ctx, mod, def, []uint64{tc.errno})
+			require.Equal(t, tc.expected, out.String())
+		})
+
+		t.Run(tc.name+" json", func(t *testing.T) {
+			l := logging.NewJSONLoggingListenerFactory(&out).NewListener(def)
+
+			out.Reset()
+			ctx := l.Before(testCtx, mod, def, tc.params, nil)
+			l.After(ctx, mod, def, []uint64{tc.errno})
+			require.Equal(t, tc.expectJSON, requireJSONLines(t, out.String()))
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package memfs
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"syscall"
+	"time"
+)
+
+// file is an open regular file.
+type file struct {
+	fs     *FS
+	name   string
+	n      *node
+	flag   int
+	offset int64
+	closed bool
+}
+
+// Stat implements fs.File
+func (f *file) Stat() (fs.FileInfo, error) {
+	f.fs.mu.RLock()
+	defer f.fs.mu.RUnlock()
+
+	if f.closed {
+		return nil, f.pathError("stat", fs.ErrClosed)
+	}
+	return f.n.stat(f.name), nil
+}
+
+// Read implements fs.File
+func (f *file) Read(p []byte) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	n, err := f.readAt("read", p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+// ReadAt implements io.ReaderAt
+func (f *file) ReadAt(p []byte, off int64) (int, error) {
+	f.fs.mu.RLock()
+	defer f.fs.mu.RUnlock()
+
+	if off < 0 {
+		return 0, f.pathError("readat", syscall.EINVAL)
+	}
+	n, err := f.readAt("readat", p, off)
+	if err == nil && n < len(p) {
+		err = io.EOF // io.ReaderAt requires an error when n < len(p).
+	}
+	return n, err
+}
+
+func (f *file) readAt(op string, p []byte, off int64) (int, error) {
+	if f.closed {
+		return 0, f.pathError(op, fs.ErrClosed)
+	} else if f.flag&(os.O_WRONLY|os.O_RDWR) == os.O_WRONLY {
+		return 0, f.pathError(op, syscall.EBADF)
+	} else if off >= int64(len(f.n.data)) {
+		if len(p) == 0 {
+			return 0, nil
+		}
+		return 0, io.EOF
+	}
+	return copy(p, f.n.data[off:]), nil
+}
+
+// Write implements io.Writer
+func (f *file) Write(p []byte) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	off := f.offset
+	if f.flag&os.O_APPEND != 0 {
+		off = int64(len(f.n.data))
+	}
+	n, err := f.writeAt("write", p, off)
+	f.offset = off + int64(n)
+	return n, err
+}
+
+// WriteAt implements io.WriterAt
+func (f *file) WriteAt(p []byte, off int64) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if off < 0 {
+		return 0, f.pathError("writeat", syscall.EINVAL)
+	} else if f.flag&os.O_APPEND != 0 {
+		// Like os.File, as the position is ambiguous.
+		return 0, f.pathError("writeat", syscall.EINVAL)
+	}
+	return f.writeAt("writeat", p, off)
+}
+
+func (f *file) writeAt(op string, p []byte, off int64) (int, error) {
+	if f.closed {
+		return 0, f.pathError(op, fs.ErrClosed)
+	} else if f.flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return 0, f.pathError(op, syscall.EBADF)
+	}
+
+	if end := off + int64(len(p)); end < off { // overflow
+		return 0, f.pathError(op, syscall.EFBIG)
+	} else if end > int64(len(f.n.data)) {
+		if err := f.fs.resize(f.n, end); err != nil {
+			return 0, f.pathError(op, err)
+		}
+	}
+	copy(f.n.data[off:], p)
+	f.n.modTime = time.Now()
+	return len(p), nil
+}
+
+// grow returns data extended to size, zero-filling any gap.
+func grow(data []byte, size int64) []byte {
+	if size <= int64(cap(data)) {
+		tail := data[len(data):size]
+		for i := range tail {
+			tail[i] = 0
+		}
+		return data[:size]
+	}
+	grown := make([]byte, size, size+size/2)
+	copy(grown, data)
+	return grown
+}
+
+// Seek implements io.Seeker
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if f.closed {
+		return 0, f.pathError("seek", fs.ErrClosed)
+	}
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += int64(len(f.n.data))
+	default:
+		return 0, f.pathError("seek", syscall.EINVAL)
+	}
+	if offset < 0 {
+		return 0, f.pathError("seek", syscall.EINVAL)
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+// Truncate is like os.File Truncate.
+func (f *file) Truncate(size int64) error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if f.closed {
+		return f.pathError("truncate", fs.ErrClosed)
+	} else if size < 0 {
+		return f.pathError("truncate", syscall.EINVAL)
+	} else if f.flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return f.pathError("truncate", syscall.EBADF)
+	}
+
+	if err := f.fs.resize(f.n, size); err != nil {
+		return f.pathError("truncate", err)
+	}
+	f.n.modTime = time.Now()
+	return nil
+}
+
+// Close implements fs.File
+func (f *file) Close() error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if f.closed {
+		return f.pathError("close", fs.ErrClosed)
+	}
+	f.closed = true
+	f.n.opens--
+	f.fs.release(f.n)
+	return nil
+}
+
+func (f *file) pathError(op string, err error) error {
+	return &fs.PathError{Op: op, Path: f.name, Err: err}
+}
+
+// dirFile is an open directory.
+type dirFile struct {
+	fs   *FS
+	name string
+	n    *node
+
+	// entries are read on the first call to ReadDir, so that entries added
+	// or removed during iteration aren't skipped or repeated.
+	entries []fs.DirEntry
+	closed  bool
+}
+
+// Stat implements fs.File
+func (d *dirFile) Stat() (fs.FileInfo, error) {
+	d.fs.mu.RLock()
+	defer d.fs.mu.RUnlock()
+
+	if d.closed {
+		return nil, &fs.PathError{Op: "stat", Path: d.name, Err: fs.ErrClosed}
+	}
+	return d.n.stat(d.name), nil
+}
+
+// Read implements fs.File
+func (d *dirFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: syscall.EISDIR}
+}
+
+// ReadDir implements fs.ReadDirFile
+func (d *dirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	d.fs.mu.Lock()
+	defer d.fs.mu.Unlock()
+
+	if d.closed {
+		return nil, &fs.PathError{Op: "readdir", Path: d.name, Err: fs.ErrClosed}
+	}
+	if d.entries == nil {
+		d.entries = sortedEntries(d.n)
+	}
+
+	if n <= 0 {
+		entries := d.entries
+		d.entries = d.entries[len(d.entries):]
+		return entries, nil
+	} else if len(d.entries) == 0 {
+		return nil, io.EOF
+	} else if n > len(d.entries) {
+		n = len(d.entries)
+	}
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+// Close implements fs.File
+func (d *dirFile) Close() error {
+	d.fs.mu.Lock()
+	defer d.fs.mu.Unlock()
+
+	if d.closed {
+		return &fs.PathError{Op: "close", Path: d.name, Err: fs.ErrClosed}
+	}
+	d.closed = true
+	return nil
+}
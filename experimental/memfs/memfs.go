@@ -0,0 +1,586 @@
+// Package memfs includes an in-memory file system, which gives guests scratch
+// space without touching the host disk.
+package memfs
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// maxSymlinks is the maximum number of symbolic links followed when resolving
+// a path, before failing with syscall.ELOOP. This is the same as Linux.
+const maxSymlinks = 40
+
+// DefaultMaxSize is the default maximum size of all files in bytes.
+const DefaultMaxSize = 1 << 30
+
+// Option configures a FS, e.g. WithMaxSize.
+type Option func(*FS)
+
+// WithMaxSize sets the maximum size of all files in bytes. Beyond it, writes
+// and truncation fail with syscall.ENOSPC, or syscall.EFBIG if the file alone
+// would exceed it. Defaults to DefaultMaxSize.
+//
+// This bounds the host memory used by the file system, regardless of the
+// offsets a guest writes at.
+func WithMaxSize(size int64) Option {
+	return func(m *FS) {
+		m.maxSize = size
+	}
+}
+
+// FS is a writable file system held in memory, which is safe for concurrent
+// use. The zero value is not usable: use New.
+//
+// In addition to fs.FS, this implements the methods wazero.ModuleConfig
+// WithFS uses to write files, which are named like and have the same
+// signature as functions in package os: OpenFile, Mkdir, Remove, Rename,
+// Symlink, Readlink, Link, Lstat and Chtimes. For example:
+//
+//	scratch := memfs.New()
+//	_ = scratch.WriteFile("config.yml", config, 0o644)
+//	mod, err := r.InstantiateModule(ctx, compiled, wazero.NewModuleConfig().WithFS(scratch))
+//
+// # Notes
+//
+//   - Paths are slash-separated and validated with fs.ValidPath, so "." is
+//     the root directory and absolute paths are invalid.
+//   - Errors are *fs.PathError or *os.LinkError wrapping a syscall.Errno,
+//     such as syscall.ENOENT, similar to package os.
+//   - Permission bits are recorded, but not enforced.
+//   - Only the modification time is recorded, as fs.FileInfo has no access
+//     time. It changes when a file is written, or a directory entry is
+//     added or removed.
+//   - The size of all files is limited, see WithMaxSize. Like a disk, a
+//     removed file is counted until it is no longer open.
+type FS struct {
+	mu   sync.RWMutex
+	root *node
+
+	// maxSize is the maximum of size.
+	maxSize int64
+	// size is the length of the data of all regular files which are linked
+	// or open.
+	size int64
+}
+
+// node is a file, directory or symbolic link. Directory entries point to a
+// node, so a file with hard links is the same node in multiple entries.
+type node struct {
+	mode    fs.FileMode
+	modTime time.Time
+
+	// data is the content of a regular file.
+	data []byte
+
+	// entries are the children of a directory, by name.
+	entries map[string]*node
+
+	// target is the contents of a symbolic link.
+	target string
+
+	// links and opens count the directory entries and open files of a
+	// regular file, to know when its data no longer counts towards the size
+	// of the file system.
+	links, opens int
+}
+
+func newNode(mode fs.FileMode) *node {
+	n := &node{mode: mode, modTime: time.Now()}
+	if mode.IsDir() {
+		n.entries = map[string]*node{}
+	}
+	return n
+}
+
+// contains returns true if d is n or any directory under it.
+func (n *node) contains(d *node) bool {
+	if n == d {
+		return true
+	}
+	for _, e := range n.entries {
+		if e.mode.IsDir() && e.contains(d) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *node) stat(name string) fs.FileInfo {
+	return &fileInfo{name: name, size: int64(len(n.data)), mode: n.mode, modTime: n.modTime}
+}
+
+// New returns an empty file system configured by the options, whose root
+// directory has permissions 0o755.
+func New(opts ...Option) *FS {
+	m := &FS{root: newNode(fs.ModeDir | 0o755), maxSize: DefaultMaxSize}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// resize changes the length of the data of the regular file n to size,
+// zero-filling any gap, or fails if the file system would be too large.
+//
+// Note: This must be called while holding m.mu.
+func (m *FS) resize(n *node, size int64) error {
+	delta := size - int64(len(n.data))
+	if size > m.maxSize {
+		return syscall.EFBIG
+	} else if delta > m.maxSize-m.size {
+		return syscall.ENOSPC
+	}
+	if delta > 0 {
+		n.data = grow(n.data, size)
+	} else {
+		n.data = n.data[:size]
+	}
+	m.size += delta
+	return nil
+}
+
+// release stops counting the data of the regular file n, if it has no
+// directory entries, nor is open.
+//
+// Note: This must be called while holding m.mu.
+func (m *FS) release(n *node) {
+	if n.mode.IsRegular() && n.links == 0 && n.opens == 0 {
+		m.size -= int64(len(n.data))
+		n.data = nil
+	}
+}
+
+// Open implements fs.FS
+func (m *FS) Open(name string) (fs.File, error) {
+	return m.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// OpenFile is like os.OpenFile, except the result is an fs.File which is
+// also an io.Writer, io.Seeker, io.ReaderAt and io.WriterAt. A directory is
+// a fs.ReadDirFile, and can only be opened read-only.
+//
+// The flags os.O_CREATE, os.O_EXCL, os.O_TRUNC and os.O_APPEND are supported
+// and others, such as os.O_SYNC, are ignored.
+func (m *FS) OpenFile(name string, flag int, perm fs.FileMode) (fs.File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dir, base, n, err := m.walk("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if n == nil {
+		if flag&os.O_CREATE == 0 {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: syscall.ENOENT}
+		}
+		n = newNode(perm & fs.ModePerm)
+		n.links = 1
+		dir.entries[base] = n
+		dir.modTime = n.modTime
+	} else if flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: syscall.EEXIST}
+	} else if n.mode.IsDir() {
+		if flag&(os.O_WRONLY|os.O_RDWR|os.O_TRUNC) != 0 {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: syscall.EISDIR}
+		}
+		return &dirFile{fs: m, name: base, n: n}, nil
+	} else if flag&os.O_TRUNC != 0 {
+		_ = m.resize(n, 0) // can't fail when shrinking
+		n.modTime = time.Now()
+	}
+	n.opens++
+	return &file{fs: m, name: base, n: n, flag: flag}, nil
+}
+
+// Stat implements fs.StatFS
+func (m *FS) Stat(name string) (fs.FileInfo, error) {
+	return m.stat("stat", name, true)
+}
+
+// Lstat is like Stat, except it doesn't follow a symbolic link.
+func (m *FS) Lstat(name string) (fs.FileInfo, error) {
+	return m.stat("lstat", name, false)
+}
+
+func (m *FS) stat(op, name string, follow bool) (fs.FileInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, base, n, err := m.walk(op, name, follow)
+	if err != nil {
+		return nil, err
+	} else if n == nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: syscall.ENOENT}
+	}
+	return n.stat(base), nil
+}
+
+// ReadDir implements fs.ReadDirFS
+func (m *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	f, err := m.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	d, ok := f.(fs.ReadDirFile)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: syscall.ENOTDIR}
+	}
+	return d.ReadDir(-1)
+}
+
+// ReadFile implements fs.ReadFileFS
+func (m *FS) ReadFile(name string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, _, n, err := m.walk("open", name, true)
+	if err != nil {
+		return nil, err
+	} else if n == nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: syscall.ENOENT}
+	} else if n.mode.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: syscall.EISDIR}
+	}
+	return append([]byte(nil), n.data...), nil
+}
+
+// WriteFile is like os.WriteFile. This is convenient to add files before the
+// file system is used by a guest.
+func (m *FS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	f, err := m.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.(*file).Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Mkdir is like os.Mkdir.
+func (m *FS) Mkdir(name string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dir, base, n, err := m.walk("mkdir", name, false)
+	if err != nil {
+		return err
+	} else if n != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: syscall.EEXIST}
+	}
+	n = newNode(fs.ModeDir | perm&fs.ModePerm)
+	dir.entries[base] = n
+	dir.modTime = n.modTime
+	return nil
+}
+
+// MkdirAll is like os.MkdirAll. This is convenient to add directories before
+// the file system is used by a guest.
+func (m *FS) MkdirAll(name string, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: syscall.EINVAL}
+	}
+	if name == "." {
+		return nil
+	}
+	for i := 0; i <= len(name); i++ {
+		if i < len(name) && name[i] != '/' {
+			continue
+		}
+		if err := m.Mkdir(name[:i], perm); err != nil {
+			if st, statErr := m.Stat(name[:i]); statErr != nil || !st.IsDir() {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Remove is like os.Remove, except it fails with syscall.EINVAL on the root
+// directory.
+func (m *FS) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dir, base, n, err := m.walk("remove", name, false)
+	if err != nil {
+		return err
+	} else if n == nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: syscall.ENOENT}
+	} else if n == m.root {
+		return &fs.PathError{Op: "remove", Path: name, Err: syscall.EINVAL}
+	} else if len(n.entries) > 0 {
+		return &fs.PathError{Op: "remove", Path: name, Err: syscall.ENOTEMPTY}
+	}
+	delete(dir.entries, base)
+	n.links--
+	m.release(n)
+	dir.modTime = time.Now()
+	return nil
+}
+
+// Rename is like os.Rename, so it replaces newName if it exists. A directory
+// can only replace an empty directory, and can't be moved under itself.
+func (m *FS) Rename(oldName, newName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	linkErr := func(err error) error {
+		return &os.LinkError{Op: "rename", Old: oldName, New: newName, Err: err}
+	}
+
+	oldDir, oldBase, n, err := m.walk("rename", oldName, false)
+	if err != nil {
+		return linkErr(syscallErr(err))
+	} else if n == nil {
+		return linkErr(syscall.ENOENT)
+	}
+	newDir, newBase, existing, err := m.walk("rename", newName, false)
+	if err != nil {
+		return linkErr(syscallErr(err))
+	}
+
+	switch {
+	case n == m.root || existing == m.root:
+		return linkErr(syscall.EINVAL)
+	case existing == n:
+		return nil // same file, e.g. a hard link.
+	case n.mode.IsDir() && n.contains(newDir):
+		return linkErr(syscall.EINVAL)
+	case existing == nil:
+	case n.mode.IsDir() && !existing.mode.IsDir():
+		return linkErr(syscall.ENOTDIR)
+	case !n.mode.IsDir() && existing.mode.IsDir():
+		return linkErr(syscall.EISDIR)
+	case len(existing.entries) > 0:
+		return linkErr(syscall.ENOTEMPTY)
+	}
+
+	delete(oldDir.entries, oldBase)
+	newDir.entries[newBase] = n
+	if existing != nil {
+		existing.links--
+		m.release(existing)
+	}
+	now := time.Now()
+	oldDir.modTime, newDir.modTime = now, now
+	return nil
+}
+
+// Symlink is like os.Symlink. Absolute targets are resolved from the root of
+// this file system.
+func (m *FS) Symlink(target, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dir, base, n, err := m.walk("symlink", name, false)
+	if err != nil {
+		return err
+	} else if n != nil {
+		return &fs.PathError{Op: "symlink", Path: name, Err: syscall.EEXIST}
+	}
+	n = newNode(fs.ModeSymlink | 0o777)
+	n.target = target
+	dir.entries[base] = n
+	dir.modTime = n.modTime
+	return nil
+}
+
+// Readlink is like os.Readlink.
+func (m *FS) Readlink(name string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, _, n, err := m.walk("readlink", name, false)
+	if err != nil {
+		return "", err
+	} else if n == nil {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: syscall.ENOENT}
+	} else if n.mode.Type() != fs.ModeSymlink {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: syscall.EINVAL}
+	}
+	return n.target, nil
+}
+
+// Link is like os.Link, except it fails with syscall.EPERM if oldName is a
+// directory.
+func (m *FS) Link(oldName, newName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	linkErr := func(err error) error {
+		return &os.LinkError{Op: "link", Old: oldName, New: newName, Err: err}
+	}
+
+	_, _, n, err := m.walk("link", oldName, false)
+	if err != nil {
+		return linkErr(syscallErr(err))
+	} else if n == nil {
+		return linkErr(syscall.ENOENT)
+	} else if n.mode.IsDir() {
+		return linkErr(syscall.EPERM)
+	}
+	dir, base, existing, err := m.walk("link", newName, false)
+	if err != nil {
+		return linkErr(syscallErr(err))
+	} else if existing != nil {
+		return linkErr(syscall.EEXIST)
+	}
+	dir.entries[base] = n
+	n.links++
+	dir.modTime = time.Now()
+	return nil
+}
+
+// Chtimes is like os.Chtimes, except the access time is ignored.
+func (m *FS) Chtimes(name string, atime, mtime time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, _, n, err := m.walk("chtimes", name, true)
+	if err != nil {
+		return err
+	} else if n == nil {
+		return &fs.PathError{Op: "chtimes", Path: name, Err: syscall.ENOENT}
+	}
+	n.modTime = mtime
+	return nil
+}
+
+// walk resolves name to the directory containing it, its base name in that
+// directory, and its node, which is nil if it doesn't exist. Symbolic links
+// in the directory part of name are followed, as is the last element when
+// follow is true. The root directory has no containing directory.
+//
+// Note: This must be called while holding m.mu.
+func (m *FS) walk(op, name string, follow bool) (dir *node, base string, n *node, err error) {
+	if !fs.ValidPath(name) {
+		err = &fs.PathError{Op: op, Path: name, Err: syscall.EINVAL}
+		return
+	}
+	var links int
+	if dir, base, n, err = m.walkLinks(name, follow, &links); err != nil {
+		err = &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return
+}
+
+// walkLinks implements walk, counting the symbolic links followed in links.
+// Errors are a syscall.Errno.
+func (m *FS) walkLinks(name string, follow bool, links *int) (*node, string, *node, error) {
+	if name == "." {
+		return nil, name, m.root, nil
+	}
+
+	dir, dirPath := m.root, "."
+	elems := strings.Split(name, "/")
+	for i, elem := range elems {
+		last := i == len(elems)-1
+		n := dir.entries[elem]
+		if n != nil && n.mode.Type() == fs.ModeSymlink && (follow || !last) {
+			if *links++; *links > maxSymlinks {
+				return nil, "", nil, syscall.ELOOP
+			}
+			target, ok := resolveLink(dirPath, n.target)
+			if !ok {
+				return nil, "", nil, syscall.ENOENT
+			}
+			var err error
+			if _, _, n, err = m.walkLinks(target, true, links); err != nil {
+				return nil, "", nil, err
+			} else if n == nil {
+				return nil, "", nil, syscall.ENOENT // dangling link.
+			}
+			if !last {
+				dirPath = target
+			}
+		} else if !last {
+			dirPath = path.Join(dirPath, elem)
+		}
+
+		if last {
+			return dir, elem, n, nil
+		} else if n == nil {
+			return nil, "", nil, syscall.ENOENT
+		} else if !n.mode.IsDir() {
+			return nil, "", nil, syscall.ENOTDIR
+		}
+		dir = n
+	}
+	panic("unreachable")
+}
+
+// resolveLink returns the path of the link target relative to the root, or
+// false if it would be outside the root. dirPath is the directory containing
+// the link.
+func resolveLink(dirPath, target string) (string, bool) {
+	if strings.HasPrefix(target, "/") {
+		target = path.Clean(target[1:])
+	} else {
+		target = path.Join(dirPath, target)
+	}
+	if target == ".." || strings.HasPrefix(target, "../") {
+		return "", false
+	}
+	return target, true
+}
+
+// syscallErr returns the syscall.Errno wrapped by a *fs.PathError.
+func syscallErr(err error) error {
+	if pe, ok := err.(*fs.PathError); ok {
+		return pe.Err
+	}
+	return err
+}
+
+// sortedEntries returns the directory entries of n sorted by name.
+func sortedEntries(n *node) []fs.DirEntry {
+	names := make([]string, 0, len(n.entries))
+	for name := range n.entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	entries := make([]fs.DirEntry, len(names))
+	for i, name := range names {
+		entries[i] = fs.FileInfoToDirEntry(n.entries[name].stat(name))
+	}
+	return entries
+}
+
+// fileInfo implements fs.FileInfo
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// Name implements fs.FileInfo
+func (i *fileInfo) Name() string { return i.name }
+
+// Size implements fs.FileInfo
+func (i *fileInfo) Size() int64 { return i.size }
+
+// Mode implements fs.FileInfo
+func (i *fileInfo) Mode() fs.FileMode { return i.mode }
+
+// ModTime implements fs.FileInfo
+func (i *fileInfo) ModTime() time.Time { return i.modTime }
+
+// IsDir implements fs.FileInfo
+func (i *fileInfo) IsDir() bool { return i.mode.IsDir() }
+
+// Sys implements fs.FileInfo
+func (i *fileInfo) Sys() interface{} { return nil }
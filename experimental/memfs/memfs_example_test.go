@@ -0,0 +1,35 @@
+package memfs_test
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/experimental/memfs"
+)
+
+// This shows how to give a guest scratch space pre-populated with a file,
+// and read what it wrote after it ran.
+func ExampleNew() {
+	scratch := memfs.New()
+	if err := scratch.MkdirAll("etc", 0o755); err != nil {
+		log.Panicln(err)
+	}
+	if err := scratch.WriteFile("etc/config.yml", []byte("animals: 1\n"), 0o644); err != nil {
+		log.Panicln(err)
+	}
+
+	// The guest sees the file as "/etc/config.yml", and can create others.
+	_ = wazero.NewModuleConfig().WithFS(scratch)
+
+	// After the guest runs, the host can inspect the file system.
+	b, err := fs.ReadFile(scratch, "etc/config.yml")
+	if err != nil {
+		log.Panicln(err)
+	}
+	fmt.Print(string(b))
+
+	// Output:
+	// animals: 1
+}
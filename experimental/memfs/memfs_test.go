@@ -0,0 +1,437 @@
+package memfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+// requireErrno requires the error wraps the syscall.Errno, like os errors.
+func requireErrno(t *testing.T, expected syscall.Errno, err error) {
+	var errno syscall.Errno
+	require.True(t, errors.As(err, &errno), "%v is not a syscall.Errno", err)
+	require.Equal(t, expected, errno, err.Error())
+}
+
+func TestFS_TestFS(t *testing.T) {
+	m := New()
+	require.NoError(t, m.MkdirAll("dir/sub", 0o755))
+	require.NoError(t, m.WriteFile("file", []byte("wazero"), 0o644))
+	require.NoError(t, m.WriteFile("dir/sub/file", []byte("animals"), 0o644))
+	require.NoError(t, m.Symlink("dir/sub", "link"))
+
+	require.NoError(t, fstest.TestFS(m, "file", "dir/sub/file"))
+}
+
+func TestFS_OpenFile(t *testing.T) {
+	m := New()
+	require.NoError(t, m.WriteFile("file", []byte("wazero"), 0o600))
+	require.NoError(t, m.Mkdir("dir", 0o700))
+
+	t.Run("create", func(t *testing.T) {
+		f, err := m.OpenFile("dir/new", os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o640)
+		require.NoError(t, err)
+		defer f.Close()
+
+		st, err := f.Stat()
+		require.NoError(t, err)
+		require.Equal(t, "new", st.Name())
+		require.Equal(t, fs.FileMode(0o640), st.Mode())
+		require.Equal(t, int64(0), st.Size())
+	})
+
+	t.Run("exclusive", func(t *testing.T) {
+		_, err := m.OpenFile("file", os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o600)
+		requireErrno(t, syscall.EEXIST, err)
+	})
+
+	t.Run("not exist", func(t *testing.T) {
+		_, err := m.OpenFile("missing", os.O_RDWR, 0)
+		requireErrno(t, syscall.ENOENT, err)
+		require.True(t, errors.Is(err, fs.ErrNotExist))
+
+		_, err = m.OpenFile("missing/file", os.O_RDWR|os.O_CREATE, 0o600)
+		requireErrno(t, syscall.ENOENT, err)
+	})
+
+	t.Run("not a directory", func(t *testing.T) {
+		_, err := m.OpenFile("file/file", os.O_RDWR|os.O_CREATE, 0o600)
+		requireErrno(t, syscall.ENOTDIR, err)
+	})
+
+	t.Run("invalid path", func(t *testing.T) {
+		_, err := m.OpenFile("/file", os.O_RDONLY, 0)
+		requireErrno(t, syscall.EINVAL, err)
+	})
+
+	t.Run("directory for writing", func(t *testing.T) {
+		_, err := m.OpenFile("dir", os.O_RDWR, 0)
+		requireErrno(t, syscall.EISDIR, err)
+	})
+
+	t.Run("truncate", func(t *testing.T) {
+		require.NoError(t, m.WriteFile("trunc", []byte("wazero"), 0o600))
+		f, err := m.OpenFile("trunc", os.O_WRONLY|os.O_TRUNC, 0)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		b, err := m.ReadFile("trunc")
+		require.NoError(t, err)
+		require.Equal(t, 0, len(b))
+	})
+}
+
+func TestFS_file(t *testing.T) {
+	m := New()
+
+	f, err := m.OpenFile("file", os.O_RDWR|os.O_CREATE, 0o600)
+	require.NoError(t, err)
+	rw := f.(*file)
+
+	n, err := rw.Write([]byte("wazero"))
+	require.NoError(t, err)
+	require.Equal(t, 6, n)
+
+	// Writing past the end zero-fills the gap.
+	n, err = rw.WriteAt([]byte("!"), 8)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	off, err := rw.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), off)
+
+	b, err := io.ReadAll(rw)
+	require.NoError(t, err)
+	require.Equal(t, []byte("wazero\x00\x00!"), b)
+
+	buf := make([]byte, 4)
+	n, err = rw.ReadAt(buf, 7)
+	require.Equal(t, io.EOF, err)
+	require.Equal(t, []byte{0, '!'}, buf[:n])
+
+	require.NoError(t, rw.Truncate(2))
+	b, err = m.ReadFile("file")
+	require.NoError(t, err)
+	require.Equal(t, "wa", string(b))
+
+	_, err = rw.Seek(-1, io.SeekStart)
+	requireErrno(t, syscall.EINVAL, err)
+
+	require.NoError(t, rw.Close())
+	_, err = rw.Read(buf)
+	require.True(t, errors.Is(err, fs.ErrClosed))
+	require.True(t, errors.Is(rw.Close(), fs.ErrClosed))
+}
+
+func TestFS_file_access(t *testing.T) {
+	m := New()
+	require.NoError(t, m.WriteFile("file", []byte("wazero"), 0o600))
+
+	f, err := m.OpenFile("file", os.O_RDONLY, 0)
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = f.(*file).Write([]byte("!"))
+	requireErrno(t, syscall.EBADF, err)
+
+	f, err = m.OpenFile("file", os.O_WRONLY, 0)
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = f.Read(make([]byte, 1))
+	requireErrno(t, syscall.EBADF, err)
+}
+
+func TestFS_file_append(t *testing.T) {
+	m := New()
+	require.NoError(t, m.WriteFile("file", []byte("wazero"), 0o600))
+
+	f, err := m.OpenFile("file", os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	defer f.Close()
+	w := f.(*file)
+
+	_, err = w.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	_, err = w.Write([]byte("!"))
+	require.NoError(t, err)
+
+	_, err = w.WriteAt([]byte("?"), 0)
+	requireErrno(t, syscall.EINVAL, err)
+
+	b, err := m.ReadFile("file")
+	require.NoError(t, err)
+	require.Equal(t, "wazero!", string(b))
+}
+
+func TestFS_maxSize(t *testing.T) {
+	m := New(WithMaxSize(10))
+	require.NoError(t, m.WriteFile("a", []byte("wazero"), 0o600))
+
+	f, err := m.OpenFile("b", os.O_RDWR|os.O_CREATE, 0o600)
+	require.NoError(t, err)
+	defer f.Close()
+	w := f.(*file)
+
+	// A huge offset fails without allocating, even if the end overflows.
+	_, err = w.WriteAt([]byte("!"), math.MaxInt64-1)
+	requireErrno(t, syscall.EFBIG, err)
+	_, err = w.WriteAt([]byte("!!"), math.MaxInt64)
+	requireErrno(t, syscall.EFBIG, err)
+	_, err = w.Seek(math.MaxInt64, io.SeekStart)
+	require.NoError(t, err)
+	_, err = w.Write([]byte("!"))
+	requireErrno(t, syscall.EFBIG, err)
+	requireErrno(t, syscall.EFBIG, w.Truncate(math.MaxInt64))
+
+	// The file alone fits, but not with the others.
+	requireErrno(t, syscall.ENOSPC, w.Truncate(5))
+	require.NoError(t, w.Truncate(4))
+
+	// Removing a file frees its space once it is closed.
+	g, err := m.Open("a")
+	require.NoError(t, err)
+	require.NoError(t, m.Remove("a"))
+	requireErrno(t, syscall.ENOSPC, w.Truncate(10))
+	require.NoError(t, g.Close())
+	require.NoError(t, w.Truncate(10))
+}
+
+func TestFS_ReadDir(t *testing.T) {
+	m := New()
+	require.NoError(t, m.Mkdir("dir", 0o755))
+	for _, name := range []string{"c", "a", "b"} {
+		require.NoError(t, m.WriteFile("dir/"+name, nil, 0o600))
+	}
+
+	f, err := m.Open("dir")
+	require.NoError(t, err)
+	defer f.Close()
+	d := f.(fs.ReadDirFile)
+
+	entries, err := d.ReadDir(2)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(entries))
+	require.Equal(t, "a", entries[0].Name())
+	require.Equal(t, "b", entries[1].Name())
+
+	// Changes during iteration aren't visible until the directory is reopened.
+	require.NoError(t, m.Remove("dir/c"))
+	entries, err = d.ReadDir(2)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(entries))
+	require.Equal(t, "c", entries[0].Name())
+
+	_, err = d.ReadDir(1)
+	require.Equal(t, io.EOF, err)
+
+	entries, err = m.ReadDir("dir")
+	require.NoError(t, err)
+	require.Equal(t, 2, len(entries))
+
+	_, err = m.ReadDir("dir/a")
+	requireErrno(t, syscall.ENOTDIR, err)
+}
+
+func TestFS_Mkdir(t *testing.T) {
+	m := New()
+	require.NoError(t, m.Mkdir("dir", 0o700))
+
+	st, err := m.Stat("dir")
+	require.NoError(t, err)
+	require.Equal(t, fs.ModeDir|0o700, st.Mode())
+
+	requireErrno(t, syscall.EEXIST, m.Mkdir("dir", 0o700))
+	requireErrno(t, syscall.EEXIST, m.Mkdir(".", 0o700))
+	requireErrno(t, syscall.ENOENT, m.Mkdir("missing/dir", 0o700))
+
+	require.NoError(t, m.MkdirAll("dir/a/b", 0o700))
+	st, err = m.Stat("dir/a/b")
+	require.NoError(t, err)
+	require.True(t, st.IsDir())
+
+	require.NoError(t, m.WriteFile("file", nil, 0o600))
+	requireErrno(t, syscall.EEXIST, m.MkdirAll("file", 0o700))
+}
+
+func TestFS_Remove(t *testing.T) {
+	m := New()
+	require.NoError(t, m.MkdirAll("dir/sub", 0o755))
+	require.NoError(t, m.WriteFile("file", nil, 0o600))
+	require.NoError(t, m.Symlink("dir", "link"))
+
+	requireErrno(t, syscall.ENOTEMPTY, m.Remove("dir"))
+	requireErrno(t, syscall.ENOENT, m.Remove("missing"))
+	requireErrno(t, syscall.EINVAL, m.Remove("."))
+
+	// Removing a link doesn't remove its target.
+	require.NoError(t, m.Remove("link"))
+	_, err := m.Stat("dir")
+	require.NoError(t, err)
+
+	require.NoError(t, m.Remove("file"))
+	require.NoError(t, m.Remove("dir/sub"))
+	require.NoError(t, m.Remove("dir"))
+
+	entries, err := m.ReadDir(".")
+	require.NoError(t, err)
+	require.Equal(t, 0, len(entries))
+}
+
+func TestFS_Rename(t *testing.T) {
+	tests := []struct {
+		name             string
+		oldName, newName string
+		expectedErrno    syscall.Errno
+	}{
+		{name: "file", oldName: "file", newName: "dir/renamed"},
+		{name: "replace file", oldName: "file", newName: "other"},
+		{name: "directory", oldName: "dir", newName: "renamed"},
+		{name: "replace empty directory", oldName: "dir", newName: "empty"},
+		{name: "same file", oldName: "file", newName: "file"},
+		{name: "not exist", oldName: "missing", newName: "file", expectedErrno: syscall.ENOENT},
+		{name: "parent not exist", oldName: "file", newName: "missing/file", expectedErrno: syscall.ENOENT},
+		{name: "directory over file", oldName: "dir", newName: "file", expectedErrno: syscall.ENOTDIR},
+		{name: "file over directory", oldName: "file", newName: "empty", expectedErrno: syscall.EISDIR},
+		{name: "directory over non-empty", oldName: "empty", newName: "dir", expectedErrno: syscall.ENOTEMPTY},
+		{name: "directory under itself", oldName: "dir", newName: "dir/sub/dir", expectedErrno: syscall.EINVAL},
+		{name: "root", oldName: ".", newName: "renamed", expectedErrno: syscall.EINVAL},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			m := New()
+			require.NoError(t, m.MkdirAll("dir/sub", 0o755))
+			require.NoError(t, m.Mkdir("empty", 0o755))
+			require.NoError(t, m.WriteFile("file", []byte("wazero"), 0o600))
+			require.NoError(t, m.WriteFile("other", nil, 0o600))
+
+			err := m.Rename(tc.oldName, tc.newName)
+			if tc.expectedErrno != 0 {
+				requireErrno(t, tc.expectedErrno, err)
+				return
+			}
+			require.NoError(t, err)
+
+			_, err = m.Lstat(tc.newName)
+			require.NoError(t, err)
+			if tc.oldName != tc.newName {
+				_, err = m.Lstat(tc.oldName)
+				requireErrno(t, syscall.ENOENT, err)
+			}
+		})
+	}
+}
+
+func TestFS_links(t *testing.T) {
+	m := New()
+	require.NoError(t, m.MkdirAll("dir/sub", 0o755))
+	require.NoError(t, m.WriteFile("dir/file", []byte("wazero"), 0o600))
+
+	require.NoError(t, m.Symlink("file", "dir/rel"))
+	require.NoError(t, m.Symlink("/dir/file", "abs"))
+	require.NoError(t, m.Symlink("../file", "dir/sub/parent"))
+	require.NoError(t, m.Symlink("../escape", "escape"))
+	require.NoError(t, m.Symlink("loop", "loop"))
+	require.NoError(t, m.Symlink("missing", "dangling"))
+	require.NoError(t, m.Link("dir/file", "hard"))
+
+	for _, name := range []string{"dir/rel", "abs", "dir/sub/parent", "hard"} {
+		b, err := m.ReadFile(name)
+		require.NoError(t, err, name)
+		require.Equal(t, "wazero", string(b), name)
+	}
+
+	_, err := m.Open("escape")
+	requireErrno(t, syscall.ENOENT, err)
+	_, err = m.Open("loop")
+	requireErrno(t, syscall.ELOOP, err)
+	_, err = m.Open("dangling")
+	requireErrno(t, syscall.ENOENT, err)
+
+	target, err := m.Readlink("dir/rel")
+	require.NoError(t, err)
+	require.Equal(t, "file", target)
+	_, err = m.Readlink("hard")
+	requireErrno(t, syscall.EINVAL, err)
+
+	st, err := m.Lstat("abs")
+	require.NoError(t, err)
+	require.Equal(t, fs.ModeSymlink, st.Mode().Type())
+
+	requireErrno(t, syscall.EEXIST, m.Symlink("file", "hard"))
+	requireErrno(t, syscall.EEXIST, m.Link("dir/file", "hard"))
+	requireErrno(t, syscall.EPERM, m.Link("dir", "dir2"))
+	requireErrno(t, syscall.ENOENT, m.Link("missing", "new"))
+
+	// A hard link is the same file, so writes are visible in both.
+	require.NoError(t, m.Remove("dir/file"))
+	require.NoError(t, m.WriteFile("hard", []byte("animals"), 0o600))
+	f, err := m.Open("hard")
+	require.NoError(t, err)
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.Equal(t, "animals", string(b))
+}
+
+func TestFS_modTime(t *testing.T) {
+	m := New()
+	require.NoError(t, m.Mkdir("dir", 0o755))
+
+	epoch := time.Unix(0, 0)
+	require.NoError(t, m.Chtimes("dir", epoch, epoch))
+
+	// Adding an entry updates the directory.
+	require.NoError(t, m.WriteFile("dir/file", nil, 0o600))
+	st, err := m.Stat("dir")
+	require.NoError(t, err)
+	require.True(t, st.ModTime().After(epoch))
+
+	require.NoError(t, m.Chtimes("dir/file", epoch, epoch))
+	st, err = m.Stat("dir/file")
+	require.NoError(t, err)
+	require.Equal(t, epoch, st.ModTime())
+
+	// Writing updates the file.
+	require.NoError(t, m.WriteFile("dir/file", []byte("wazero"), 0o600))
+	st, err = m.Stat("dir/file")
+	require.NoError(t, err)
+	require.True(t, st.ModTime().After(epoch))
+
+	requireErrno(t, syscall.ENOENT, m.Chtimes("missing", epoch, epoch))
+}
+
+func TestFS_concurrent(t *testing.T) {
+	m := New()
+	f, err := m.OpenFile("log", os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	require.NoError(t, err)
+	defer f.Close()
+	w := f.(io.Writer)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_, _ = w.Write([]byte("x"))
+				_, _ = m.Stat("log")
+			}
+		}()
+	}
+	wg.Wait()
+
+	st, err := m.Stat("log")
+	require.NoError(t, err)
+	require.Equal(t, int64(1000), st.Size())
+}
@@ -0,0 +1,63 @@
+package experimental
+
+import (
+	"context"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// MemoryListenerKey is a context.Context Value key. Its associated value
+// should be a MemoryListener.
+//
+// When present in the context passed to wazero.Runtime CompileModule, each
+// load and store of memory by the module's functions is instrumented to
+// notify the listener when it overlaps any of its watch regions. This is much
+// slower, so is only intended for debugging and analysis, e.g. taint tracking
+// or data breakpoints.
+//
+// Note: Bulk memory instructions, such as memory.copy, and host functions
+// are not instrumented.
+type MemoryListenerKey struct{}
+
+// MemoryListener is notified before a guest function loads or stores memory
+// which overlaps any of its watch regions.
+type MemoryListener interface {
+	// WatchRegions returns the regions of memory to watch. This is called
+	// once per module compiled.
+	WatchRegions() []WatchRegion
+
+	// OnMemoryAccess is invoked before a load or store overlapping any of
+	// the watch regions. If it panics, the function call fails, like a trap.
+	//
+	// # Params
+	//
+	//   - ctx: the context of the function call.
+	//   - mod: the calling module. This is the same api.Module a host
+	//	   function receives.
+	//   - def: the definition of the function accessing memory.
+	//   - access: the memory accessed, which may be out of bounds.
+	OnMemoryAccess(ctx context.Context, mod api.Module, def api.FunctionDefinition, access MemoryAccess)
+}
+
+// WatchRegion is a range of memory offsets, from Offset inclusive to
+// Offset+Length exclusive.
+type WatchRegion struct {
+	Offset, Length uint32
+}
+
+// Overlaps returns true if any of the size bytes at the offset are in the
+// region.
+func (r WatchRegion) Overlaps(offset uint64, size uint32) bool {
+	return offset < uint64(r.Offset)+uint64(r.Length) && uint64(r.Offset) < offset+uint64(size)
+}
+
+// MemoryAccess is a load or store of memory.
+type MemoryAccess struct {
+	// Offset is the effective offset in memory: the address operand plus
+	// the static offset of the instruction.
+	Offset uint64
+	// Size is the count of bytes accessed, e.g. 4 for i32.load.
+	Size uint32
+	// Store is true if the memory is written, or false if read.
+	Store bool
+}
@@ -0,0 +1,120 @@
+package experimental_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	. "github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+// compile-time check to ensure memoryRecorder implements MemoryListener
+var _ MemoryListener = &memoryRecorder{}
+
+type memoryRecorder struct {
+	regions  []WatchRegion
+	names    []string
+	accesses []MemoryAccess
+}
+
+func (r *memoryRecorder) WatchRegions() []WatchRegion {
+	return r.regions
+}
+
+func (r *memoryRecorder) OnMemoryAccess(_ context.Context, _ api.Module, def api.FunctionDefinition, access MemoryAccess) {
+	r.names = append(r.names, def.DebugName())
+	r.accesses = append(r.accesses, access)
+}
+
+func TestMemoryListener(t *testing.T) {
+	// Define a module whose function stores 42 at 8, then loads from its
+	// param plus 4 and from 32.
+	bin := binary.EncodeModule(&wasm.Module{
+		TypeSection:     []*wasm.FunctionType{{Params: []api.ValueType{api.ValueTypeI32}}},
+		FunctionSection: []wasm.Index{0},
+		MemorySection:   &wasm.Memory{Min: 1, Max: 1, IsMaxEncoded: true},
+		CodeSection: []*wasm.Code{{Body: []byte{
+			wasm.OpcodeI32Const, 8, wasm.OpcodeI32Const, 42,
+			wasm.OpcodeI32Store, 2, 0,
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeI64Load, 3, 4,
+			wasm.OpcodeDrop,
+			wasm.OpcodeI32Const, 32,
+			wasm.OpcodeI32Load8U, 0, 0,
+			wasm.OpcodeDrop,
+			wasm.OpcodeEnd,
+		}}},
+		ExportSection: []*wasm.Export{{Name: "fn", Type: wasm.ExternTypeFunc, Index: 0}},
+		NameSection: &wasm.NameSection{
+			ModuleName:    "test",
+			FunctionNames: wasm.NameMap{{Index: 0, Name: "fn"}},
+		},
+	})
+
+	configs := map[string]wazero.RuntimeConfig{"interpreter": wazero.NewRuntimeConfigInterpreter()}
+	if platform.CompilerSupported() {
+		configs["compiler"] = wazero.NewRuntimeConfigCompiler()
+	}
+	for name, config := range configs {
+		config := config
+		t.Run(name, func(t *testing.T) {
+			listener := &memoryRecorder{regions: []WatchRegion{{Offset: 8, Length: 4}}}
+			ctx := context.WithValue(context.Background(), MemoryListenerKey{}, listener)
+
+			r := wazero.NewRuntimeWithConfig(ctx, config)
+			defer r.Close(ctx) // This closes everything this Runtime created.
+
+			m, err := r.InstantiateModuleFromBinary(ctx, bin)
+			require.NoError(t, err)
+
+			// The load from 4 overlaps the region, but not the one from 16.
+			_, err = m.ExportedFunction("fn").Call(ctx, 0)
+			require.NoError(t, err)
+			_, err = m.ExportedFunction("fn").Call(ctx, 12)
+			require.NoError(t, err)
+
+			require.Equal(t, []string{"test.fn", "test.fn", "test.fn"}, listener.names)
+			require.Equal(t, []MemoryAccess{
+				{Offset: 8, Size: 4, Store: true},
+				{Offset: 4, Size: 8},
+				{Offset: 8, Size: 4, Store: true},
+			}, listener.accesses)
+
+			// Instrumentation must not change the result of the store.
+			v, ok := m.Memory().ReadUint32Le(8)
+			require.True(t, ok)
+			require.Equal(t, uint32(42), v)
+		})
+	}
+}
+
+func TestWatchRegion_Overlaps(t *testing.T) {
+	r := WatchRegion{Offset: 8, Length: 4}
+
+	tests := []struct {
+		name     string
+		offset   uint64
+		size     uint32
+		expected bool
+	}{
+		{name: "before", offset: 4, size: 4},
+		{name: "start", offset: 5, size: 4, expected: true},
+		{name: "inside", offset: 9, size: 2, expected: true},
+		{name: "end", offset: 11, size: 4, expected: true},
+		{name: "after", offset: 12, size: 4},
+		{name: "around", offset: 0, size: 16, expected: true},
+		{name: "past 4GiB", offset: 1<<32 + 8, size: 4},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, r.Overlaps(tc.offset, tc.size))
+		})
+	}
+}
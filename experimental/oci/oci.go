@@ -0,0 +1,440 @@
+// Package oci fetches WebAssembly modules from OCI registries, such as
+// ghcr.io, so that they can be distributed like container images.
+//
+// Modules are artifacts per the Wasm OCI Artifact layout: a manifest whose
+// single layer has the media type "application/wasm". For example, this
+// fetches and compiles a module pinned by digest, caching it locally:
+//
+//	wasm, err := oci.Fetch(ctx, "ghcr.io/org/mod@sha256:...", oci.WithCacheDir(dir))
+//	if err != nil {
+//		return err
+//	}
+//	compiled, err := r.CompileModule(ctx, wasm)
+//
+// The digests of manifests and layers are always verified. Fetching by tag
+// resolves the tag in the registry each time, but layers already cached
+// aren't downloaded again. Fetching by digest needs no network once cached.
+//
+// Registries are accessed over HTTPS, except on localhost, with anonymous
+// tokens unless credentials are given with WithBasicAuth.
+//
+// Note: This package is experimental, so may be changed or deleted at any
+// time.
+package oci
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Media types of Wasm OCI artifacts.
+const (
+	// MediaTypeWasmConfig is the media type of the config of a module.
+	MediaTypeWasmConfig = "application/vnd.wasm.config.v0+json"
+	// MediaTypeWasm is the media type of the layer of a module.
+	MediaTypeWasm = "application/wasm"
+)
+
+// Media types of manifests, which are requested from registries.
+const (
+	mediaTypeManifest       = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeIndex          = "application/vnd.oci.image.index.v1+json"
+	mediaTypeDockerManifest = "application/vnd.docker.distribution.manifest.v2+json"
+)
+
+// mediaTypeWasmLayers are the media types of layers which are modules. The
+// second is used by Envoy and Istio Wasm plugins.
+var mediaTypeWasmLayers = []string{MediaTypeWasm, "application/vnd.module.wasm.content.layer.v1+wasm"}
+
+// maxManifestSize limits the size of manifests, which are read into memory
+// before their digest is verified.
+const maxManifestSize = 4 << 20
+
+// Option configures Fetch, e.g. WithCacheDir.
+type Option func(*fetcher)
+
+// WithCacheDir sets a writeable directory to cache manifests and modules in,
+// by digest. It can be shared by processes. Defaults to no cache.
+func WithCacheDir(dir string) Option {
+	return func(f *fetcher) {
+		f.cacheDir = dir
+	}
+}
+
+// WithHTTPClient sets the client to access registries with. Defaults to
+// http.DefaultClient.
+func WithHTTPClient(client *http.Client) Option {
+	return func(f *fetcher) {
+		f.client = client
+	}
+}
+
+// WithBasicAuth sets the credentials to authenticate to registries with,
+// e.g. a GitHub user and personal access token for ghcr.io. Defaults to
+// anonymous access.
+func WithBasicAuth(username, password string) Option {
+	return func(f *fetcher) {
+		f.username, f.password = username, password
+	}
+}
+
+// Fetch returns the module of the reference, parsed by ParseReference.
+func Fetch(ctx context.Context, ref string, opts ...Option) ([]byte, error) {
+	r, err := ParseReference(ref)
+	if err != nil {
+		return nil, err
+	}
+	f := &fetcher{client: http.DefaultClient, ref: r}
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	m, err := f.manifest(ctx, r.version(), r.Digest)
+	if err != nil {
+		return nil, err
+	}
+	layer, err := wasmLayer(m)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", r, err)
+	}
+	return f.blob(ctx, layer)
+}
+
+// fetcher fetches the manifests and blobs of a reference.
+type fetcher struct {
+	client             *http.Client
+	cacheDir           string
+	username, password string
+	ref                Reference
+	// authorization is the value of the Authorization header, once a
+	// registry challenged a request.
+	authorization string
+}
+
+// descriptor describes content by digest, such as a layer.
+type descriptor struct {
+	MediaType string    `json:"mediaType"`
+	Digest    string    `json:"digest"`
+	Size      int64     `json:"size"`
+	Platform  *platform `json:"platform,omitempty"`
+}
+
+type platform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+}
+
+// manifest is an image manifest or, if Manifests is set, an index of them.
+type manifest struct {
+	MediaType string       `json:"mediaType"`
+	Config    descriptor   `json:"config"`
+	Layers    []descriptor `json:"layers"`
+	Manifests []descriptor `json:"manifests"`
+}
+
+// manifest fetches the manifest of the version, which must have the digest
+// if set. Indexes are resolved to the manifest of the wasm platform.
+func (f *fetcher) manifest(ctx context.Context, version, digest string) (*manifest, error) {
+	b, err := f.cached(digest)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		res, err := f.get(ctx, "manifests", version, mediaTypeManifest, mediaTypeIndex, mediaTypeDockerManifest)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+
+		if b, err = io.ReadAll(io.LimitReader(res.Body, maxManifestSize+1)); err != nil {
+			return nil, fmt.Errorf("error reading manifest of %s: %w", f.ref, err)
+		} else if len(b) > maxManifestSize {
+			return nil, fmt.Errorf("manifest of %s is larger than %d bytes", f.ref, maxManifestSize)
+		}
+		if actual := digestOf(b); digest == "" {
+			digest = actual
+		} else if actual != digest {
+			return nil, fmt.Errorf("manifest of %s has digest %s, expected %s", f.ref, actual, digest)
+		}
+		f.cache(digest, b)
+	}
+
+	var m manifest
+	if err = json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest of %s: %w", f.ref, err)
+	}
+	if m.MediaType == mediaTypeIndex || len(m.Manifests) > 0 {
+		d, err := wasmManifest(m.Manifests)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.ref, err)
+		}
+		return f.manifest(ctx, d.Digest, d.Digest)
+	}
+	return &m, nil
+}
+
+// wasmManifest returns the manifest of an index for a wasm platform, or the
+// only one.
+func wasmManifest(manifests []descriptor) (descriptor, error) {
+	for _, d := range manifests {
+		if p := d.Platform; p != nil && p.Architecture == "wasm" {
+			return d, nil
+		}
+	}
+	if len(manifests) == 1 {
+		return manifests[0], nil
+	}
+	return descriptor{}, fmt.Errorf("index has %d manifests, but none for the wasm architecture", len(manifests))
+}
+
+// wasmLayer returns the layer of the manifest which is the module.
+func wasmLayer(m *manifest) (descriptor, error) {
+	for _, d := range m.Layers {
+		for _, mediaType := range mediaTypeWasmLayers {
+			if d.MediaType == mediaType {
+				return d, nil
+			}
+		}
+	}
+	return descriptor{}, fmt.Errorf("manifest has no layer of media type %s", MediaTypeWasm)
+}
+
+// blob fetches the content of the descriptor, verifying its size and digest.
+func (f *fetcher) blob(ctx context.Context, d descriptor) ([]byte, error) {
+	if !digestPattern.MatchString(d.Digest) {
+		return nil, fmt.Errorf("layer of %s has invalid digest %s", f.ref, d.Digest)
+	}
+	if b, err := f.cached(d.Digest); err != nil || b != nil {
+		return b, err
+	}
+
+	res, err := f.get(ctx, "blobs", d.Digest, d.MediaType)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	b, err := io.ReadAll(io.LimitReader(res.Body, d.Size+1))
+	if err != nil {
+		return nil, fmt.Errorf("error reading layer of %s: %w", f.ref, err)
+	} else if int64(len(b)) != d.Size {
+		return nil, fmt.Errorf("layer of %s has size %d, expected %d", f.ref, len(b), d.Size)
+	} else if actual := digestOf(b); actual != d.Digest {
+		return nil, fmt.Errorf("layer of %s has digest %s, expected %s", f.ref, actual, d.Digest)
+	}
+	f.cache(d.Digest, b)
+	return b, nil
+}
+
+// get requests the manifest or blob of the repository, authenticating if
+// challenged by the registry.
+func (f *fetcher) get(ctx context.Context, kind, version string, accept ...string) (*http.Response, error) {
+	u := fmt.Sprintf("%s://%s/v2/%s/%s/%s", scheme(f.ref.Registry), f.ref.Registry, f.ref.Repository, kind, version)
+	for authorized := false; ; authorized = true {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", strings.Join(accept, ", "))
+		if f.authorization != "" {
+			req.Header.Set("Authorization", f.authorization)
+		}
+
+		res, err := f.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if res.StatusCode == http.StatusOK {
+			return res, nil
+		}
+		res.Body.Close()
+
+		if res.StatusCode != http.StatusUnauthorized || authorized {
+			return nil, fmt.Errorf("GET %s: %s", u, res.Status)
+		}
+		if err = f.authorize(ctx, res.Header.Get("WWW-Authenticate")); err != nil {
+			return nil, fmt.Errorf("error authenticating to %s: %w", f.ref.Registry, err)
+		}
+	}
+}
+
+// authorize sets the authorization for later requests per the challenge of
+// the registry, which is either Basic or Bearer, where a token is requested
+// from the realm of the challenge.
+func (f *fetcher) authorize(ctx context.Context, challenge string) error {
+	authScheme, params := parseChallenge(challenge)
+	switch strings.ToLower(authScheme) {
+	case "basic":
+		if f.username == "" {
+			return errors.New("registry requires credentials")
+		}
+		req, _ := http.NewRequest(http.MethodGet, "", nil)
+		req.SetBasicAuth(f.username, f.password)
+		f.authorization = req.Header.Get("Authorization")
+		return nil
+	case "bearer":
+	default:
+		return fmt.Errorf("unsupported challenge %q", challenge)
+	}
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Host == "" {
+		return fmt.Errorf("invalid realm in challenge %q", challenge)
+	}
+	query := realm.Query()
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = "repository:" + f.ref.Repository + ":pull"
+	}
+	query.Set("scope", scope)
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return err
+	}
+	if f.username != "" {
+		req.SetBasicAuth(f.username, f.password)
+	}
+	res, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", realm, res.Status)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&token); err != nil {
+		return fmt.Errorf("invalid token: %w", err)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	f.authorization = "Bearer " + token.Token
+	return nil
+}
+
+// parseChallenge parses a WWW-Authenticate header, e.g.
+// `Bearer realm="https://ghcr.io/token",service="ghcr.io"`.
+func parseChallenge(challenge string) (authScheme string, params map[string]string) {
+	authScheme, rest, _ := cut(strings.TrimSpace(challenge), " ")
+	params = map[string]string{}
+	for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimLeft(rest, ", ") {
+		var key string
+		if key, rest, _ = cut(rest, "="); strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end == -1 {
+				end = len(rest) - 1
+			}
+			params[strings.ToLower(strings.TrimSpace(key))] = rest[1 : end+1]
+			rest = rest[min(end+2, len(rest)):]
+		} else {
+			var value string
+			value, rest, _ = cut(rest, ",")
+			params[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+		}
+	}
+	return
+}
+
+// cached returns the content of the digest in the cache, or nil if absent.
+func (f *fetcher) cached(digest string) ([]byte, error) {
+	if f.cacheDir == "" || digest == "" {
+		return nil, nil
+	}
+	b, err := os.ReadFile(f.cachePath(digest))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	} else if digestOf(b) != digest { // e.g. corrupted: fetch it again.
+		return nil, nil
+	}
+	return b, nil
+}
+
+// cache writes the content of the digest to the cache, ignoring errors, as
+// caching is an optimization.
+func (f *fetcher) cache(digest string, b []byte) {
+	if f.cacheDir == "" {
+		return
+	}
+	path := f.cachePath(digest)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return
+	}
+	// Write to a temporary file first, so that concurrent readers don't
+	// read partial content.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return
+	}
+	_, err = io.Copy(tmp, bytes.NewReader(b))
+	if closeErr := tmp.Close(); err == nil && closeErr == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+}
+
+// cachePath returns the path of the content of the digest in the cache, e.g.
+// "<dir>/sha256/<hex>".
+func (f *fetcher) cachePath(digest string) string {
+	algorithm, hash, _ := cut(digest, ":")
+	return filepath.Join(f.cacheDir, algorithm, hash)
+}
+
+// digestOf returns the sha256 digest of the content, e.g. "sha256:...".
+func digestOf(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// scheme returns "http" for registries on localhost, which are usually
+// local development registries without TLS, and "https" otherwise.
+func scheme(registry string) string {
+	host := registry
+	if h, _, err := net.SplitHostPort(registry); err == nil {
+		host = h
+	}
+	if host == "localhost" || net.ParseIP(host).IsLoopback() {
+		return "http"
+	}
+	return "https"
+}
+
+// cut is strings.Cut, which requires Go 1.18.
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
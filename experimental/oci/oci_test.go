@@ -0,0 +1,284 @@
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+// testCtx is an arbitrary, non-default context. Non-nil also prevents linter errors.
+var testCtx = context.WithValue(context.Background(), struct{}{}, "arbitrary")
+
+// wasm is an empty module.
+var wasm = []byte("\x00asm\x01\x00\x00\x00")
+
+// registry is a fake OCI registry, which serves the content of paths under
+// "/v2/", e.g. "org/mod/manifests/v1", and counts the requests for them.
+type registry struct {
+	*httptest.Server
+	content  map[string][]byte
+	requests int32
+	// token, if set, must be sent as a Bearer token, which is issued by
+	// "/token".
+	token string
+}
+
+func newRegistry(t *testing.T) *registry {
+	r := &registry{content: map[string][]byte{}}
+	r.Server = httptest.NewServer(r)
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/token" {
+		if scope := req.URL.Query().Get("scope"); scope != "repository:org/mod:pull" {
+			http.Error(w, "invalid scope "+scope, http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"token": r.token})
+		return
+	}
+
+	atomic.AddInt32(&r.requests, 1)
+	if r.token != "" && req.Header.Get("Authorization") != "Bearer "+r.token {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, r.URL))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	b, ok := r.content[strings.TrimPrefix(req.URL.Path, "/v2/")]
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	_, _ = w.Write(b)
+}
+
+// push adds the module to the repository "org/mod" as the tag, returning the
+// digest of its manifest.
+func (r *registry) push(t *testing.T, tag string, wasm []byte) string {
+	layer := descriptor{MediaType: MediaTypeWasm, Digest: digestOf(wasm), Size: int64(len(wasm))}
+	r.content["org/mod/blobs/"+layer.Digest] = wasm
+	return r.pushManifest(t, tag, manifest{
+		MediaType: mediaTypeManifest,
+		Config:    descriptor{MediaType: MediaTypeWasmConfig, Digest: digestOf([]byte("{}")), Size: 2},
+		Layers:    []descriptor{layer},
+	})
+}
+
+func (r *registry) pushManifest(t *testing.T, tag string, m manifest) string {
+	b, err := json.Marshal(m)
+	require.NoError(t, err)
+	digest := digestOf(b)
+	r.content["org/mod/manifests/"+digest] = b
+	r.content["org/mod/manifests/"+tag] = b
+	return digest
+}
+
+// host returns the host and port of the registry, e.g. "127.0.0.1:1234".
+func (r *registry) host() string {
+	return strings.TrimPrefix(r.URL, "http://")
+}
+
+func TestFetch(t *testing.T) {
+	r := newRegistry(t)
+	digest := r.push(t, "v1", wasm)
+
+	tests := []struct{ name, ref string }{
+		{name: "tag", ref: r.host() + "/org/mod:v1"},
+		{name: "digest", ref: r.host() + "/org/mod@" + digest},
+		{name: "tag and digest", ref: "oci://" + r.host() + "/org/mod:v2@" + digest},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := Fetch(testCtx, tc.ref)
+			require.NoError(t, err)
+			require.Equal(t, wasm, b)
+		})
+	}
+}
+
+func TestFetch_Auth(t *testing.T) {
+	r := newRegistry(t)
+	r.token = "s3cr3t"
+	r.push(t, "v1", wasm)
+
+	b, err := Fetch(testCtx, r.host()+"/org/mod:v1")
+	require.NoError(t, err)
+	require.Equal(t, wasm, b)
+	// One challenged and one authorized request for the manifest, then one
+	// for the layer, reusing the token.
+	require.Equal(t, int32(3), r.requests)
+}
+
+func TestFetch_Index(t *testing.T) {
+	r := newRegistry(t)
+	digest := r.push(t, "wasm", wasm)
+	r.pushManifest(t, "v1", manifest{
+		MediaType: mediaTypeIndex,
+		Manifests: []descriptor{
+			{MediaType: mediaTypeManifest, Digest: digestOf([]byte("amd64")), Platform: &platform{OS: "linux", Architecture: "amd64"}},
+			{MediaType: mediaTypeManifest, Digest: digest, Platform: &platform{OS: "wasip1", Architecture: "wasm"}},
+		},
+	})
+
+	b, err := Fetch(testCtx, r.host()+"/org/mod:v1")
+	require.NoError(t, err)
+	require.Equal(t, wasm, b)
+}
+
+func TestFetch_Cache(t *testing.T) {
+	r := newRegistry(t)
+	digest := r.push(t, "v1", wasm)
+	dir := t.TempDir()
+
+	b, err := Fetch(testCtx, r.host()+"/org/mod:v1", WithCacheDir(dir))
+	require.NoError(t, err)
+	require.Equal(t, wasm, b)
+	require.Equal(t, int32(2), r.requests)
+
+	// A tag is resolved again, but the layer is cached.
+	_, err = Fetch(testCtx, r.host()+"/org/mod:v1", WithCacheDir(dir))
+	require.NoError(t, err)
+	require.Equal(t, int32(3), r.requests)
+
+	// A digest needs no requests once cached.
+	b, err = Fetch(testCtx, r.host()+"/org/mod@"+digest, WithCacheDir(dir))
+	require.NoError(t, err)
+	require.Equal(t, wasm, b)
+	require.Equal(t, int32(3), r.requests)
+
+	// Corrupted content is fetched again.
+	path := filepath.Join(dir, "sha256", strings.TrimPrefix(digestOf(wasm), "sha256:"))
+	require.NoError(t, os.WriteFile(path, []byte("corrupted"), 0o600))
+	b, err = Fetch(testCtx, r.host()+"/org/mod@"+digest, WithCacheDir(dir))
+	require.NoError(t, err)
+	require.Equal(t, wasm, b)
+	require.Equal(t, int32(4), r.requests)
+}
+
+func TestFetch_Errors(t *testing.T) {
+	r := newRegistry(t)
+	digest := r.push(t, "v1", wasm)
+	r.pushManifest(t, "empty", manifest{MediaType: mediaTypeManifest})
+	r.pushManifest(t, "index", manifest{MediaType: mediaTypeIndex, Manifests: []descriptor{{}, {}}})
+
+	// The layer of "corrupted" doesn't match its digest.
+	corrupted := []byte("\x00asm\x01\x00\x00\x01")
+	r.push(t, "corrupted", corrupted)
+	r.content["org/mod/blobs/"+digestOf(corrupted)] = wasm
+
+	otherDigest := digestOf([]byte("other"))
+	host := r.host()
+	tests := []struct{ name, ref, expectedErr string }{
+		{
+			name:        "not found",
+			ref:         host + "/org/mod:v2",
+			expectedErr: fmt.Sprintf("GET http://%s/v2/org/mod/manifests/v2: 404 Not Found", host),
+		},
+		{
+			name:        "digest not found",
+			ref:         host + "/org/mod:v1@" + otherDigest,
+			expectedErr: fmt.Sprintf("GET http://%s/v2/org/mod/manifests/%s: 404 Not Found", host, otherDigest),
+		},
+		{
+			name:        "no wasm layer",
+			ref:         host + "/org/mod:empty",
+			expectedErr: host + "/org/mod:empty: manifest has no layer of media type application/wasm",
+		},
+		{
+			name:        "no wasm manifest",
+			ref:         host + "/org/mod:index",
+			expectedErr: host + "/org/mod:index: index has 2 manifests, but none for the wasm architecture",
+		},
+		{
+			name: "corrupted layer",
+			ref:  host + "/org/mod:corrupted",
+			expectedErr: fmt.Sprintf("layer of %s/org/mod:corrupted has digest %s, expected %s",
+				host, digestOf(wasm), digestOf(corrupted)),
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Fetch(testCtx, tc.ref)
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+
+	t.Run("manifest digest mismatch", func(t *testing.T) {
+		// Serve the manifest of v1 for another digest.
+		r.content["org/mod/manifests/"+otherDigest] = r.content["org/mod/manifests/"+digest]
+		_, err := Fetch(testCtx, host+"/org/mod@"+otherDigest)
+		require.EqualError(t, err, fmt.Sprintf("manifest of %s/org/mod@%s has digest %s, expected %s",
+			host, otherDigest, digest, otherDigest))
+	})
+}
+
+func TestParseReference(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+	tests := []struct {
+		input    string
+		expected Reference
+	}{
+		{input: "ghcr.io/org/mod", expected: Reference{Registry: "ghcr.io", Repository: "org/mod", Tag: "latest"}},
+		{input: "oci://ghcr.io/org/mod:v1", expected: Reference{Registry: "ghcr.io", Repository: "org/mod", Tag: "v1"}},
+		{input: "localhost:5000/mod", expected: Reference{Registry: "localhost:5000", Repository: "mod", Tag: "latest"}},
+		{input: "ghcr.io/org/mod@" + digest, expected: Reference{Registry: "ghcr.io", Repository: "org/mod", Digest: digest}},
+		{input: "ghcr.io/org/mod:v1@" + digest, expected: Reference{Registry: "ghcr.io", Repository: "org/mod", Tag: "v1", Digest: digest}},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.input, func(t *testing.T) {
+			r, err := ParseReference(tc.input)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, r)
+			require.Equal(t, strings.TrimPrefix(tc.input, Scheme), strings.Replace(r.String(), ":latest", "", 1))
+		})
+	}
+}
+
+func TestParseReference_Errors(t *testing.T) {
+	tests := []struct{ input, expectedErr string }{
+		{input: "mod", expectedErr: "invalid reference mod: missing registry"},
+		{input: "/mod", expectedErr: "invalid reference /mod: missing registry"},
+		{input: "ghcr.io/Org/mod", expectedErr: "invalid reference ghcr.io/Org/mod: invalid repository Org/mod"},
+		{input: "ghcr.io/org/mod:", expectedErr: "invalid reference ghcr.io/org/mod:: invalid tag "},
+		{input: "ghcr.io/org/mod@md5:abc", expectedErr: "invalid reference ghcr.io/org/mod@md5:abc: invalid digest md5:abc"},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.input, func(t *testing.T) {
+			_, err := ParseReference(tc.input)
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	authScheme, params := parseChallenge(`Bearer realm="https://ghcr.io/token",service="ghcr.io", scope="repository:org/mod:pull"`)
+	require.Equal(t, "Bearer", authScheme)
+	require.Equal(t, map[string]string{
+		"realm":   "https://ghcr.io/token",
+		"service": "ghcr.io",
+		"scope":   "repository:org/mod:pull",
+	}, params)
+
+	authScheme, params = parseChallenge(`Basic realm=registry`)
+	require.Equal(t, "Basic", authScheme)
+	require.Equal(t, map[string]string{"realm": "registry"}, params)
+}
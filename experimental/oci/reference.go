@@ -0,0 +1,94 @@
+package oci
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Scheme optionally prefixes a reference, e.g. "oci://ghcr.io/org/mod:v1".
+const Scheme = "oci://"
+
+// DefaultTag is the tag of a reference without a tag or digest.
+const DefaultTag = "latest"
+
+var (
+	repositoryPattern = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*$`)
+	tagPattern        = regexp.MustCompile(`^\w[\w.-]{0,127}$`)
+	digestPattern     = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+)
+
+// Reference identifies a module in a registry, by tag or digest, e.g.
+// "ghcr.io/org/mod:v1" or "ghcr.io/org/mod@sha256:...".
+type Reference struct {
+	// Registry is the host and optional port of the registry, e.g. "ghcr.io".
+	Registry string
+	// Repository is the path of the module in the registry, e.g. "org/mod".
+	Repository string
+	// Tag is the tag of the module, e.g. "v1", or DefaultTag if neither it
+	// nor the Digest was given.
+	Tag string
+	// Digest pins the manifest of the module, e.g. "sha256:...". When set, the
+	// Tag is ignored, and the manifest must have this digest.
+	Digest string
+}
+
+// ParseReference parses a reference in the form
+// [oci://]registry/repository[:tag][@digest], where only sha256 digests are
+// supported.
+func ParseReference(s string) (Reference, error) {
+	var r Reference
+	name := strings.TrimPrefix(s, Scheme)
+	if i := strings.IndexByte(name, '@'); i != -1 {
+		name, r.Digest = name[:i], name[i+1:]
+		if !digestPattern.MatchString(r.Digest) {
+			return r, fmt.Errorf("invalid reference %s: invalid digest %s", s, r.Digest)
+		}
+	}
+
+	i := strings.IndexByte(name, '/')
+	if i == -1 {
+		return r, fmt.Errorf("invalid reference %s: missing registry", s)
+	}
+	r.Registry, name = name[:i], name[i+1:]
+	if r.Registry == "" {
+		return r, fmt.Errorf("invalid reference %s: missing registry", s)
+	}
+
+	// A tag follows the last colon, unlike a port, which precedes a slash.
+	if i = strings.LastIndexByte(name, ':'); i != -1 {
+		name, r.Tag = name[:i], name[i+1:]
+		if !tagPattern.MatchString(r.Tag) {
+			return r, fmt.Errorf("invalid reference %s: invalid tag %s", s, r.Tag)
+		}
+	} else if r.Digest == "" {
+		r.Tag = DefaultTag
+	}
+
+	if !repositoryPattern.MatchString(name) {
+		return r, fmt.Errorf("invalid reference %s: invalid repository %s", s, name)
+	}
+	r.Repository = name
+	return r, nil
+}
+
+// String implements fmt.Stringer, returning the reference without Scheme.
+func (r Reference) String() string {
+	s := r.Registry + "/" + r.Repository
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// version returns the digest, if pinned, or otherwise the tag, to fetch the
+// manifest by.
+func (r Reference) version() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	return r.Tag
+}
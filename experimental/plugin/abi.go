@@ -0,0 +1,108 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+const i32 = api.ValueTypeI32
+
+// instantiateABI instantiates the host module ModuleName, whose functions
+// access the state of the current call of the plugin.
+func (p *Plugin) instantiateABI(ctx context.Context) (api.Module, error) {
+	b := p.runtime.NewHostModuleBuilder(ModuleName)
+	b.NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(func(_ context.Context, _ api.Module, stack []uint64) {
+			stack[0] = uint64(len(p.input))
+		}), nil, []api.ValueType{i32}).
+		Export("input_length")
+	b.NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(func(_ context.Context, mod api.Module, stack []uint64) {
+			write(mod, uint32(stack[0]), p.input)
+		}), []api.ValueType{i32}, nil).
+		WithParameterNames("ptr").
+		Export("input_read")
+	b.NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(func(_ context.Context, mod api.Module, stack []uint64) {
+			p.output = read(mod, uint32(stack[0]), uint32(stack[1]))
+		}), []api.ValueType{i32, i32}, nil).
+		WithParameterNames("ptr", "len").
+		Export("output_write")
+	b.NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(func(_ context.Context, mod api.Module, stack []uint64) {
+			message := string(read(mod, uint32(stack[0]), uint32(stack[1])))
+			p.errMessage = &message
+		}), []api.ValueType{i32, i32}, nil).
+		WithParameterNames("ptr", "len").
+		Export("error_write")
+	b.NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(func(_ context.Context, mod api.Module, stack []uint64) {
+			value, ok := p.config[string(read(mod, uint32(stack[0]), uint32(stack[1])))]
+			if !ok {
+				stack[0] = uint64(uint32(0xffffffff)) // -1
+				return
+			}
+			stack[0] = uint64(len(value))
+		}), []api.ValueType{i32, i32}, []api.ValueType{i32}).
+		WithParameterNames("key_ptr", "key_len").
+		Export("config_length")
+	b.NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(func(_ context.Context, mod api.Module, stack []uint64) {
+			value := p.config[string(read(mod, uint32(stack[0]), uint32(stack[1])))]
+			write(mod, uint32(stack[2]), []byte(value))
+		}), []api.ValueType{i32, i32, i32}, nil).
+		WithParameterNames("key_ptr", "key_len", "ptr").
+		Export("config_read")
+	b.NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(func(_ context.Context, mod api.Module, stack []uint64) {
+			line := read(mod, uint32(stack[0]), uint32(stack[1]))
+			_, _ = p.log.Write(append(line, '\n'))
+		}), []api.ValueType{i32, i32}, nil).
+		WithParameterNames("ptr", "len").
+		Export("log")
+	b.NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(func(_ context.Context, _ api.Module, stack []uint64) {
+			stack[0] = uint64(len(p.result))
+		}), nil, []api.ValueType{i32}).
+		Export("result_length")
+	b.NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(func(_ context.Context, mod api.Module, stack []uint64) {
+			write(mod, uint32(stack[0]), p.result)
+		}), []api.ValueType{i32}, nil).
+		WithParameterNames("ptr").
+		Export("result_read")
+	return b.Instantiate(ctx, p.runtime)
+}
+
+// hostFunction returns the function the plugin imports for fn.
+func (p *Plugin) hostFunction(fn HostFunction) api.GoModuleFunction {
+	return api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+		result, err := fn.Fn(ctx, read(mod, uint32(stack[0]), uint32(stack[1])))
+		if err != nil {
+			// Fail the call of the plugin with the error.
+			p.hostErr = fmt.Errorf("host function %s: %w", fn.Name, err)
+			panic(p.hostErr)
+		}
+		p.result = result
+		stack[0] = uint64(len(result))
+	})
+}
+
+// read returns a copy of the memory of the plugin, as it changes after, or
+// traps if out of range.
+func read(mod api.Module, ptr, len uint32) []byte {
+	b, ok := mod.Memory().Read(ptr, len)
+	if !ok {
+		panic(fmt.Errorf("out of range reading %d bytes at offset %d", len, ptr))
+	}
+	return append([]byte{}, b...)
+}
+
+// write writes b to the memory of the plugin, or traps if out of range.
+func write(mod api.Module, ptr uint32, b []byte) {
+	if !mod.Memory().Write(ptr, b) {
+		panic(fmt.Errorf("out of range writing %d bytes at offset %d", len(b), ptr))
+	}
+}
@@ -0,0 +1,139 @@
+package plugin
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tetratelabs/wazero/experimental/httploader"
+	"github.com/tetratelabs/wazero/experimental/oci"
+)
+
+// Manifest describes a plugin: its module, and what it is allowed to do. It
+// is usually read from JSON with LoadManifest, e.g.
+//
+//	{
+//	  "wasm": {"path": "greet.wasm", "hash": "9f86d0..."},
+//	  "allowed_host_functions": ["kv_get"],
+//	  "memory": {"max_pages": 16},
+//	  "config": {"greeting": "Hello"},
+//	  "timeout_ms": 1000
+//	}
+type Manifest struct {
+	// Wasm is the source of the module.
+	Wasm Source `json:"wasm"`
+
+	// AllowedHostFunctions are the names of the host functions, passed to
+	// New with WithHostFunctions, which the plugin may import. A plugin
+	// which imports any other host function fails to load.
+	AllowedHostFunctions []string `json:"allowed_host_functions,omitempty"`
+
+	// Memory limits the memory of the plugin.
+	Memory Memory `json:"memory,omitempty"`
+
+	// Config are variables the plugin reads with "config_length" and
+	// "config_read", e.g. to configure a plugin per tenant.
+	Config map[string]string `json:"config,omitempty"`
+
+	// TimeoutMs is the maximum duration of a call in milliseconds, after
+	// which the plugin is closed. If zero, calls aren't limited.
+	TimeoutMs uint64 `json:"timeout_ms,omitempty"`
+}
+
+// Source is where a module is loaded from. Exactly one of Path, URL or Data
+// must be set.
+type Source struct {
+	// Path is a file to read the module from. If relative, it is relative to
+	// the manifest read by LoadManifest, or otherwise the working directory.
+	Path string `json:"path,omitempty"`
+
+	// URL is an http(s) URL to fetch the module from, which requires Hash,
+	// or a reference to a module in an OCI registry, e.g.
+	// oci://ghcr.io/org/plugin:v1.
+	URL string `json:"url,omitempty"`
+
+	// Data is the module itself, which is base64 encoded in JSON.
+	Data []byte `json:"data,omitempty"`
+
+	// Hash is the SHA-256 of the module in hex. If set, a module with
+	// another hash fails to load.
+	Hash string `json:"hash,omitempty"`
+}
+
+// Memory limits the memory of a plugin.
+type Memory struct {
+	// MaxPages is the maximum memory in 64KiB pages, beyond which growing
+	// memory fails. If zero, the limit is 65536 pages (4GiB).
+	MaxPages uint32 `json:"max_pages,omitempty"`
+}
+
+// LoadManifest reads a Manifest from the JSON file at the path.
+func LoadManifest(path string) (Manifest, error) {
+	var m Manifest
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return m, err
+	}
+	if err = json.Unmarshal(b, &m); err != nil {
+		return m, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+	if m.Wasm.Path != "" && !filepath.IsAbs(m.Wasm.Path) {
+		m.Wasm.Path = filepath.Join(filepath.Dir(path), m.Wasm.Path)
+	}
+	return m, nil
+}
+
+// load returns the module, caching modules fetched from URLs in cacheDir if
+// not empty.
+func (s Source) load(ctx context.Context, cacheDir string) (wasm []byte, err error) {
+	sources := 0
+	for _, set := range []bool{s.Path != "", s.URL != "", s.Data != nil} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return nil, errors.New("invalid manifest: wasm must have one of path, url or data")
+	}
+
+	var name string
+	switch {
+	case s.Path != "":
+		name = s.Path
+		wasm, err = os.ReadFile(s.Path)
+	case strings.HasPrefix(s.URL, oci.Scheme):
+		name = s.URL
+		var opts []oci.Option
+		if cacheDir != "" {
+			opts = append(opts, oci.WithCacheDir(filepath.Join(cacheDir, "oci")))
+		}
+		wasm, err = oci.Fetch(ctx, s.URL, opts...)
+	case s.URL != "":
+		// The loader verifies the hash.
+		var opts []httploader.Option
+		if cacheDir != "" {
+			opts = append(opts, httploader.WithCacheDir(filepath.Join(cacheDir, "http")))
+		}
+		return httploader.NewLoader(opts...).Load(ctx, s.URL, s.Hash)
+	default:
+		name, wasm = "wasm", s.Data
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Hash != "" {
+		expected := strings.ToLower(strings.TrimPrefix(s.Hash, "sha256:"))
+		sum := sha256.Sum256(wasm)
+		if actual := hex.EncodeToString(sum[:]); actual != expected {
+			return nil, fmt.Errorf("%s has sha256 %s, expected %s", name, actual, expected)
+		}
+	}
+	return wasm, nil
+}
@@ -0,0 +1,94 @@
+package plugin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func sum(b []byte) string {
+	s := sha256.Sum256(b)
+	return hex.EncodeToString(s[:])
+}
+
+func TestLoadManifest(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "plugin.wasm"), pluginWasm, 0o600))
+	manifestPath := filepath.Join(dir, "plugin.json")
+	require.NoError(t, os.WriteFile(manifestPath, []byte(fmt.Sprintf(`{
+  "wasm": {"path": "plugin.wasm", "hash": "%s"},
+  "allowed_host_functions": ["upper"],
+  "memory": {"max_pages": 16},
+  "config": {"greeting": "Hello"},
+  "timeout_ms": 1000
+}`, sum(pluginWasm))), 0o600))
+
+	m, err := LoadManifest(manifestPath)
+	require.NoError(t, err)
+	// The path is relative to the manifest.
+	require.Equal(t, Manifest{
+		Wasm:                 Source{Path: filepath.Join(dir, "plugin.wasm"), Hash: sum(pluginWasm)},
+		AllowedHostFunctions: []string{"upper"},
+		Memory:               Memory{MaxPages: 16},
+		Config:               map[string]string{"greeting": "Hello"},
+		TimeoutMs:            1000,
+	}, m)
+
+	p, err := New(testCtx, m, WithHostFunctions(upperFunction))
+	require.NoError(t, err)
+	defer p.Close(testCtx)
+
+	output, err := p.Call(testCtx, "config", nil)
+	require.NoError(t, err)
+	require.Equal(t, "Hello", string(output))
+}
+
+func TestSource_load(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(pluginWasm)
+	}))
+	defer server.Close()
+	url := server.URL + "/plugin.wasm"
+
+	wasm, err := Source{URL: url, Hash: sum(pluginWasm)}.load(testCtx, t.TempDir())
+	require.NoError(t, err)
+	require.Equal(t, pluginWasm, wasm)
+
+	other := sum([]byte("other"))
+	tests := []struct {
+		name        string
+		source      Source
+		expectedErr string
+	}{
+		{
+			name:        "multiple sources",
+			source:      Source{URL: url, Data: pluginWasm},
+			expectedErr: "invalid manifest: wasm must have one of path, url or data",
+		},
+		{
+			name:        "url without hash",
+			source:      Source{URL: url},
+			expectedErr: fmt.Sprintf(`invalid sha256 "" of %s: expected 64 hex characters`, url),
+		},
+		{
+			name:        "data hash mismatch",
+			source:      Source{Data: pluginWasm, Hash: other},
+			expectedErr: fmt.Sprintf("wasm has sha256 %s, expected %s", sum(pluginWasm), other),
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.source.load(testCtx, "")
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
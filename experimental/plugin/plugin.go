@@ -0,0 +1,329 @@
+// Package plugin is a plugin system on wazero: a Manifest describes the
+// module of a plugin and what it is allowed to do, and a Plugin calls its
+// functions with bytes or JSON, without using the low-level API. For example:
+//
+//	m, _ := plugin.LoadManifest("greet.json")
+//	p, err := plugin.New(ctx, m, plugin.WithHostFunctions(plugin.HostFunction{
+//		Name: "kv_get",
+//		Fn:   func(ctx context.Context, key []byte) ([]byte, error) { return kv.Get(ctx, key) },
+//	}))
+//	if err != nil {
+//		return err
+//	}
+//	defer p.Close(ctx)
+//	out, err := p.Call(ctx, "greet", []byte("World"))
+//
+// # ABI
+//
+// A plugin exports its functions with the signature () -> i32, returning zero
+// on success. It reads its input and config, and writes its output, with the
+// functions of the host module "wazero_plugin":
+//
+//   - input_length() -> i32: the length of the input of the call.
+//   - input_read(ptr i32): writes the input to memory at ptr.
+//   - output_write(ptr, len i32): sets the output of the call.
+//   - error_write(ptr, len i32): sets the message of the error of the call,
+//     when the function returns non-zero.
+//   - config_length(key_ptr, key_len i32) -> i32: the length of the value
+//     of the config variable, or -1 if it isn't set.
+//   - config_read(key_ptr, key_len, ptr i32): writes the value of the config
+//     variable to memory at ptr.
+//   - log(ptr, len i32): writes a line to the log, see WithLog.
+//   - result_length() -> i32: the length of the result of the last call to
+//     a host function.
+//   - result_read(ptr i32): writes that result to memory at ptr.
+//
+// Host functions are imported from the module "host", with the signature
+// (ptr, len i32) -> i32: they are passed the bytes at ptr, and return the
+// length of their result, which is read with result_read.
+//
+// Plugins which import WASI, e.g. compiled with TinyGo, can use it, but
+// have no arguments, environment variables, files or stdio. Those which
+// export "_initialize" are initialized when loaded.
+//
+// Note: This package is experimental, so may be changed or deleted at any
+// time.
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+const (
+	// ModuleName is the name of the host module of the ABI of plugins.
+	ModuleName = "wazero_plugin"
+	// HostModuleName is the name of the host module of the functions passed
+	// to WithHostFunctions.
+	HostModuleName = "host"
+)
+
+// functionInitialize is exported by reactors, which don't run main when
+// started.
+const functionInitialize = "_initialize"
+
+// HostFunction is a function of the application which a plugin can call if
+// its Manifest allows it, e.g. to read from a database.
+type HostFunction struct {
+	// Name is the name the plugin imports the function with, from
+	// HostModuleName.
+	Name string
+	// Fn returns the result of the function for the input. An error fails
+	// the call of the plugin, which returns it.
+	Fn func(ctx context.Context, input []byte) ([]byte, error)
+}
+
+// Option configures a Plugin, e.g. WithHostFunctions.
+type Option func(*options)
+
+type options struct {
+	hostFunctions map[string]HostFunction
+	runtimeConfig wazero.RuntimeConfig
+	cacheDir      string
+	log           io.Writer
+}
+
+// WithHostFunctions adds host functions, which plugins can import if their
+// Manifest allows them.
+func WithHostFunctions(fns ...HostFunction) Option {
+	return func(o *options) {
+		for _, fn := range fns {
+			o.hostFunctions[fn.Name] = fn
+		}
+	}
+}
+
+// WithRuntimeConfig sets the config of the runtime of the plugin, e.g. to
+// use a compilation cache. Defaults to wazero.NewRuntimeConfig.
+//
+// The memory limit and closing on context done are overridden by the
+// Manifest.
+func WithRuntimeConfig(config wazero.RuntimeConfig) Option {
+	return func(o *options) {
+		o.runtimeConfig = config
+	}
+}
+
+// WithCacheDir sets a writeable directory to cache modules fetched from URLs
+// in. Defaults to no cache.
+func WithCacheDir(dir string) Option {
+	return func(o *options) {
+		o.cacheDir = dir
+	}
+}
+
+// WithLog sets the writer of the lines the plugin logs. Defaults to
+// io.Discard.
+func WithLog(w io.Writer) Option {
+	return func(o *options) {
+		o.log = w
+	}
+}
+
+// Plugin is a loaded plugin. It is safe for concurrent use, though calls are
+// serialized, as they share the memory of the plugin. Close it to release
+// its resources.
+type Plugin struct {
+	runtime wazero.Runtime
+	module  api.Module
+	config  map[string]string
+	timeout time.Duration
+	log     io.Writer
+
+	// mux serializes calls, which have their own state below.
+	mux sync.Mutex
+	// input and output are the input and output of the current call.
+	input, output []byte
+	// result is the result of the last call to a host function.
+	result []byte
+	// errMessage is set by error_write.
+	errMessage *string
+	// hostErr is the error of a host function, which failed the call.
+	hostErr error
+}
+
+// New loads the plugin described by the manifest.
+func New(ctx context.Context, m Manifest, opts ...Option) (*Plugin, error) {
+	o := &options{hostFunctions: map[string]HostFunction{}, log: io.Discard}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	wasm, err := m.Wasm.load(ctx, o.cacheDir)
+	if err != nil {
+		return nil, err
+	}
+
+	rc := o.runtimeConfig
+	if rc == nil {
+		rc = wazero.NewRuntimeConfig()
+	}
+	if m.Memory.MaxPages > 0 {
+		rc = rc.WithMemoryLimitPages(m.Memory.MaxPages)
+	}
+	if m.TimeoutMs > 0 {
+		rc = rc.WithCloseOnContextDone(true)
+	}
+
+	p := &Plugin{
+		runtime: wazero.NewRuntimeWithConfig(ctx, rc),
+		config:  m.Config,
+		timeout: time.Duration(m.TimeoutMs) * time.Millisecond,
+		log:     o.log,
+	}
+	if p.module, err = p.instantiate(ctx, wasm, m.AllowedHostFunctions, o.hostFunctions); err != nil {
+		_ = p.runtime.Close(ctx)
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Plugin) instantiate(ctx context.Context, wasm []byte, allowed []string, hostFunctions map[string]HostFunction) (api.Module, error) {
+	compiled, err := p.runtime.CompileModule(ctx, wasm)
+	if err != nil {
+		return nil, err
+	}
+
+	isAllowed := map[string]bool{}
+	for _, name := range allowed {
+		isAllowed[name] = true
+	}
+	host := p.runtime.NewHostModuleBuilder(HostModuleName)
+	hasWASI := false
+	for _, def := range compiled.ImportedFunctions() {
+		switch moduleName, name, _ := def.Import(); moduleName {
+		case wasi_snapshot_preview1.ModuleName:
+			hasWASI = true
+		case HostModuleName:
+			fn, ok := hostFunctions[name]
+			if !ok {
+				return nil, fmt.Errorf("plugin imports host function %s, which doesn't exist", name)
+			} else if !isAllowed[name] {
+				return nil, fmt.Errorf("plugin imports host function %s, which the manifest doesn't allow", name)
+			}
+			host.NewFunctionBuilder().
+				WithGoModuleFunction(p.hostFunction(fn), []api.ValueType{i32, i32}, []api.ValueType{i32}).
+				WithParameterNames("ptr", "len").
+				Export(name)
+		}
+	}
+
+	if hasWASI {
+		if _, err = wasi_snapshot_preview1.Instantiate(ctx, p.runtime); err != nil {
+			return nil, err
+		}
+	}
+	if _, err = p.instantiateABI(ctx); err != nil {
+		return nil, err
+	}
+	if _, err = host.Instantiate(ctx, p.runtime); err != nil {
+		return nil, err
+	}
+
+	config := wazero.NewModuleConfig().WithStartFunctions()
+	if _, ok := compiled.ExportedFunctions()[functionInitialize]; ok {
+		config = config.WithStartFunctions(functionInitialize)
+	}
+	return p.runtime.InstantiateModule(ctx, compiled, config)
+}
+
+// FunctionExists returns true if the plugin exports a function with the
+// name.
+func (p *Plugin) FunctionExists(name string) bool {
+	return p.module.ExportedFunction(name) != nil
+}
+
+// Call calls the function of the plugin with the input, and returns its
+// output.
+//
+// If the Manifest has a timeout, a call which exceeds it returns an error,
+// and closes the plugin, as it can't be interrupted otherwise.
+func (p *Plugin) Call(ctx context.Context, name string, input []byte) ([]byte, error) {
+	fn := p.module.ExportedFunction(name)
+	if fn == nil {
+		return nil, fmt.Errorf("plugin doesn't export function[%s]", name)
+	}
+	def := fn.Definition()
+	if len(def.ParamTypes()) != 0 || len(def.ResultTypes()) != 1 || def.ResultTypes()[0] != i32 {
+		return nil, fmt.Errorf("function[%s] has signature %s, expected () -> (i32)", name, signature(def))
+	}
+
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+
+	p.input, p.output, p.result, p.errMessage, p.hostErr = input, nil, nil, nil, nil
+	defer func() {
+		// Don't retain the input or output of the call.
+		p.input, p.output, p.result = nil, nil, nil
+	}()
+
+	results, err := fn.Call(ctx)
+	if p.hostErr != nil {
+		return nil, p.hostErr
+	} else if err != nil {
+		return nil, err
+	}
+	if code := int32(results[0]); code != 0 {
+		if p.errMessage != nil {
+			return nil, errors.New(*p.errMessage)
+		}
+		return nil, fmt.Errorf("%s returned %d", name, code)
+	}
+	return p.output, nil
+}
+
+// CallJSON calls the function of the plugin like Call, with in encoded as
+// JSON, and decodes its output as JSON into out, unless nil.
+func (p *Plugin) CallJSON(ctx context.Context, name string, in, out interface{}) error {
+	input, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	output, err := p.Call(ctx, name, input)
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	if err = json.Unmarshal(output, out); err != nil {
+		return fmt.Errorf("invalid output of %s: %w", name, err)
+	}
+	return nil
+}
+
+// Close closes the plugin.
+func (p *Plugin) Close(ctx context.Context) error {
+	return p.runtime.Close(ctx)
+}
+
+// signature formats the signature of the function, e.g. "(i32) -> (i64)".
+func signature(def api.FunctionDefinition) string {
+	return fmt.Sprintf("(%s) -> (%s)", valueTypes(def.ParamTypes()), valueTypes(def.ResultTypes()))
+}
+
+func valueTypes(types []api.ValueType) string {
+	ret := ""
+	for i, t := range types {
+		if i > 0 {
+			ret += ", "
+		}
+		ret += api.ValueTypeName(t)
+	}
+	return ret
+}
@@ -0,0 +1,291 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/leb128"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+// testCtx is an arbitrary, non-default context. Non-nil also prevents linter errors.
+var testCtx = context.WithValue(context.Background(), struct{}{}, "arbitrary")
+
+// Offsets of constants in the memory of pluginWasm.
+const (
+	offsetBoom     = 1024 // "boom"
+	offsetGreeting = 1032 // "greeting"
+	offsetLog      = 1040 // "hello"
+)
+
+// pluginWasm is a plugin which exports these functions:
+//   - "echo" outputs its input.
+//   - "fail" fails with the message "boom".
+//   - "code" returns 2.
+//   - "config" outputs the config variable "greeting".
+//   - "upper" outputs the result of the host function "upper" of its input.
+//   - "log" logs "hello".
+//   - "grow" fails if memory can't grow by a page.
+//   - "spin" never returns.
+//   - "add" has the wrong signature.
+var pluginWasm = binary.EncodeModule(pluginModule(true))
+
+// pluginModule returns the module of pluginWasm, which only imports the host
+// function "upper" if withHost.
+func pluginModule(withHost bool) *wasm.Module {
+	i32 := wasm.ValueTypeI32
+	abi := func(name string, typ wasm.Index) *wasm.Import {
+		return &wasm.Import{Module: ModuleName, Name: name, Type: wasm.ExternTypeFunc, DescFunc: typ}
+	}
+	i32Const := func(v int32) []byte {
+		return append([]byte{wasm.OpcodeI32Const}, leb128.EncodeInt32(v)...)
+	}
+	concat := func(parts ...[]byte) (ret []byte) {
+		for _, p := range parts {
+			ret = append(ret, p...)
+		}
+		return
+	}
+
+	// Function indexes of the imports.
+	const (
+		inputLength = iota
+		inputRead
+		outputWrite
+		errorWrite
+		configLength
+		configRead
+		log
+		resultRead
+		upper
+	)
+	readInput := []byte{ // local0 = input_length(); input_read(0)
+		wasm.OpcodeCall, inputLength, wasm.OpcodeLocalSet, 0,
+		wasm.OpcodeI32Const, 0, wasm.OpcodeCall, inputRead,
+	}
+	writeOutput := []byte{ // output_write(0, local0); return 0
+		wasm.OpcodeI32Const, 0, wasm.OpcodeLocalGet, 0, wasm.OpcodeCall, outputWrite,
+		wasm.OpcodeI32Const, 0, wasm.OpcodeEnd,
+	}
+
+	m := &wasm.Module{
+		TypeSection: []*wasm.FunctionType{
+			{Results: []wasm.ValueType{i32}},
+			{Params: []wasm.ValueType{i32}},
+			{Params: []wasm.ValueType{i32, i32}},
+			{Params: []wasm.ValueType{i32, i32}, Results: []wasm.ValueType{i32}},
+			{Params: []wasm.ValueType{i32, i32, i32}},
+		},
+		ImportSection: []*wasm.Import{
+			abi("input_length", 0),
+			abi("input_read", 1),
+			abi("output_write", 2),
+			abi("error_write", 2),
+			abi("config_length", 3),
+			abi("config_read", 4),
+			abi("log", 2),
+			abi("result_read", 1),
+		},
+		MemorySection: &wasm.Memory{Min: 1},
+		CodeSection: []*wasm.Code{
+			{LocalTypes: []wasm.ValueType{i32}, Body: concat(readInput, writeOutput)}, // echo
+			{Body: concat(i32Const(offsetBoom), i32Const(4), []byte{ // fail
+				wasm.OpcodeCall, errorWrite, wasm.OpcodeI32Const, 1, wasm.OpcodeEnd,
+			})},
+			{Body: []byte{wasm.OpcodeI32Const, 2, wasm.OpcodeEnd}}, // code
+			{LocalTypes: []wasm.ValueType{i32}, Body: concat( // config
+				i32Const(offsetGreeting), i32Const(8), []byte{wasm.OpcodeCall, configLength, wasm.OpcodeLocalSet, 0},
+				i32Const(offsetGreeting), i32Const(8), []byte{wasm.OpcodeI32Const, 0, wasm.OpcodeCall, configRead},
+				writeOutput,
+			)},
+			{LocalTypes: []wasm.ValueType{i32}, Body: concat(readInput, []byte{ // upper
+				wasm.OpcodeI32Const, 0, wasm.OpcodeLocalGet, 0, wasm.OpcodeCall, upper, wasm.OpcodeLocalSet, 0,
+				wasm.OpcodeI32Const, 0, wasm.OpcodeCall, resultRead,
+			}, writeOutput)},
+			{Body: concat(i32Const(offsetLog), i32Const(5), []byte{ // log
+				wasm.OpcodeCall, log, wasm.OpcodeI32Const, 0, wasm.OpcodeEnd,
+			})},
+			{Body: []byte{ // grow
+				wasm.OpcodeI32Const, 1, wasm.OpcodeMemoryGrow, 0,
+				wasm.OpcodeI32Const, 0x7f, wasm.OpcodeI32Eq, wasm.OpcodeEnd,
+			}},
+			{Body: []byte{wasm.OpcodeLoop, 0x40, wasm.OpcodeBr, 0, wasm.OpcodeEnd, wasm.OpcodeUnreachable, wasm.OpcodeEnd}}, // spin
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Add, wasm.OpcodeEnd}},               // add
+		},
+		DataSection: []*wasm.DataSegment{
+			{OffsetExpression: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: leb128.EncodeInt32(offsetBoom)}, Init: []byte("boom")},
+			{OffsetExpression: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: leb128.EncodeInt32(offsetGreeting)}, Init: []byte("greeting")},
+			{OffsetExpression: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: leb128.EncodeInt32(offsetLog)}, Init: []byte("hello")},
+		},
+	}
+	m.FunctionSection = []wasm.Index{0, 0, 0, 0, 0, 0, 0, 0, 3}
+	if withHost {
+		m.ImportSection = append(m.ImportSection, &wasm.Import{Module: HostModuleName, Name: "upper", Type: wasm.ExternTypeFunc, DescFunc: 3})
+	} else {
+		// Replace the body of "upper", which calls the import.
+		m.CodeSection[4] = &wasm.Code{Body: []byte{wasm.OpcodeI32Const, 0, wasm.OpcodeEnd}}
+	}
+	imported := wasm.Index(len(m.ImportSection))
+	for i, name := range []string{"echo", "fail", "code", "config", "upper", "log", "grow", "spin", "add"} {
+		m.ExportSection = append(m.ExportSection, &wasm.Export{Name: name, Type: wasm.ExternTypeFunc, Index: imported + wasm.Index(i)})
+	}
+	return m
+}
+
+var upperFunction = HostFunction{
+	Name: "upper",
+	Fn: func(_ context.Context, input []byte) ([]byte, error) {
+		if len(input) == 0 {
+			return nil, errors.New("empty input")
+		}
+		return bytes.ToUpper(input), nil
+	},
+}
+
+// newPlugin returns a Plugin of pluginWasm which is allowed to call
+// upperFunction.
+func newPlugin(t *testing.T, m Manifest, opts ...Option) *Plugin {
+	m.Wasm.Data = pluginWasm
+	m.AllowedHostFunctions = append(m.AllowedHostFunctions, "upper")
+	p, err := New(testCtx, m, append(opts, WithHostFunctions(upperFunction))...)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = p.Close(testCtx) })
+	return p
+}
+
+func TestPlugin_Call(t *testing.T) {
+	var log bytes.Buffer
+	p := newPlugin(t, Manifest{Config: map[string]string{"greeting": "Hello"}}, WithLog(&log))
+
+	tests := []struct {
+		name, input, expectedOutput string
+	}{
+		{name: "echo", input: "wazero", expectedOutput: "wazero"},
+		{name: "echo", input: "", expectedOutput: ""},
+		{name: "config", expectedOutput: "Hello"},
+		{name: "upper", input: "wazero", expectedOutput: "WAZERO"},
+		{name: "log", expectedOutput: ""},
+		{name: "grow", expectedOutput: ""},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			output, err := p.Call(testCtx, tc.name, []byte(tc.input))
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedOutput, string(output))
+		})
+	}
+	require.Equal(t, "hello\n", log.String())
+}
+
+func TestPlugin_CallJSON(t *testing.T) {
+	p := newPlugin(t, Manifest{})
+
+	type greeting struct {
+		Name string `json:"name"`
+	}
+	var out greeting
+	require.NoError(t, p.CallJSON(testCtx, "echo", greeting{Name: "wazero"}, &out))
+	require.Equal(t, greeting{Name: "wazero"}, out)
+
+	// The output of "log" is empty, so isn't JSON.
+	err := p.CallJSON(testCtx, "log", nil, &out)
+	require.EqualError(t, err, "invalid output of log: unexpected end of JSON input")
+}
+
+func TestPlugin_Call_Errors(t *testing.T) {
+	p := newPlugin(t, Manifest{Memory: Memory{MaxPages: 1}})
+
+	tests := []struct {
+		name, input, expectedErr string
+	}{
+		{name: "missing", expectedErr: "plugin doesn't export function[missing]"},
+		{name: "add", expectedErr: "function[add] has signature (i32, i32) -> (i32), expected () -> (i32)"},
+		{name: "fail", expectedErr: "boom"},
+		{name: "code", expectedErr: "code returned 2"},
+		{name: "upper", expectedErr: "host function upper: empty input"},
+		{name: "grow", expectedErr: "grow returned 1"},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := p.Call(testCtx, tc.name, []byte(tc.input))
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+
+	// A failed call doesn't affect the next.
+	output, err := p.Call(testCtx, "echo", []byte("ok"))
+	require.NoError(t, err)
+	require.Equal(t, "ok", string(output))
+}
+
+func TestPlugin_Call_Timeout(t *testing.T) {
+	p := newPlugin(t, Manifest{TimeoutMs: 10})
+
+	_, err := p.Call(testCtx, "spin", nil)
+	require.Error(t, err)
+	require.True(t, strings.Contains(err.Error(), "context deadline exceeded"), err.Error())
+}
+
+func TestPlugin_FunctionExists(t *testing.T) {
+	p := newPlugin(t, Manifest{})
+
+	require.True(t, p.FunctionExists("echo"))
+	require.False(t, p.FunctionExists("missing"))
+}
+
+func TestNew_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		manifest    Manifest
+		opts        []Option
+		expectedErr string
+	}{
+		{
+			name:        "no source",
+			expectedErr: "invalid manifest: wasm must have one of path, url or data",
+		},
+		{
+			name:        "invalid wasm",
+			manifest:    Manifest{Wasm: Source{Data: []byte("pooh")}},
+			expectedErr: "invalid binary",
+		},
+		{
+			name:        "host function not allowed",
+			manifest:    Manifest{Wasm: Source{Data: pluginWasm}},
+			opts:        []Option{WithHostFunctions(upperFunction)},
+			expectedErr: "plugin imports host function upper, which the manifest doesn't allow",
+		},
+		{
+			name:        "host function doesn't exist",
+			manifest:    Manifest{Wasm: Source{Data: pluginWasm}, AllowedHostFunctions: []string{"upper"}},
+			expectedErr: "plugin imports host function upper, which doesn't exist",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := New(testCtx, tc.manifest, tc.opts...)
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestNew_WithoutHostFunctions(t *testing.T) {
+	p, err := New(testCtx, Manifest{Wasm: Source{Data: binary.EncodeModule(pluginModule(false))}})
+	require.NoError(t, err)
+	defer p.Close(testCtx)
+
+	output, err := p.Call(testCtx, "echo", []byte("wazero"))
+	require.NoError(t, err)
+	require.Equal(t, "wazero", string(output))
+}
@@ -0,0 +1,181 @@
+package profiling
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+)
+
+// CallGraph is an experimental.FunctionListenerFactory which records the
+// edges between callers and callees, with the count of calls along each.
+// After execution, write the graph with WriteDot or WriteJSON. For example:
+//
+//	g := profiling.NewCallGraph()
+//	ctx = context.WithValue(ctx, experimental.FunctionListenerFactoryKey{}, g)
+//	_, err := r.InstantiateModuleFromBinary(ctx, wasm)
+//	_ = g.WriteDot(out) // then: dot -Tsvg out.dot > out.svg
+//
+// Functions called by the host, e.g. "_start", have no caller, so are only
+// nodes. Host functions are drawn as boxes, to spot host call patterns.
+type CallGraph struct {
+	// mu protects the fields below.
+	mu sync.Mutex
+	// nodeIDs are the indexes of functions in nodes.
+	nodeIDs map[api.FunctionDefinition]int
+	// nodes are the functions called, in the order first called.
+	nodes []*callGraphNode
+	// edgeIDs are the indexes of edges in edges.
+	edgeIDs map[callGraphEdgeKey]int
+	// edges are the edges between callers and callees, in the order first
+	// called.
+	edges []*callGraphEdge
+}
+
+type callGraphNode struct {
+	def   api.FunctionDefinition
+	calls uint64
+}
+
+type callGraphEdgeKey struct {
+	caller, callee int
+}
+
+type callGraphEdge struct {
+	callGraphEdgeKey
+	calls uint64
+}
+
+// NewCallGraph returns a new CallGraph, which records calls to functions
+// compiled with it.
+func NewCallGraph() *CallGraph {
+	return &CallGraph{nodeIDs: map[api.FunctionDefinition]int{}, edgeIDs: map[callGraphEdgeKey]int{}}
+}
+
+// NewListener implements experimental.FunctionListenerFactory NewListener.
+func (g *CallGraph) NewListener(api.FunctionDefinition) experimental.FunctionListener {
+	return (*callGraphListener)(g)
+}
+
+// nodeID returns the ID of the function, adding it if it wasn't yet called.
+// This must be called with the lock held.
+func (g *CallGraph) nodeID(def api.FunctionDefinition) int {
+	id, ok := g.nodeIDs[def]
+	if !ok {
+		id = len(g.nodes)
+		g.nodeIDs[def] = id
+		g.nodes = append(g.nodes, &callGraphNode{def: def})
+	}
+	return id
+}
+
+// add records a call to the callee, from the caller when not nil.
+func (g *CallGraph) add(caller, callee api.FunctionDefinition) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	calleeID := g.nodeID(callee)
+	g.nodes[calleeID].calls++
+	if caller == nil {
+		return
+	}
+	key := callGraphEdgeKey{caller: g.nodeID(caller), callee: calleeID}
+	id, ok := g.edgeIDs[key]
+	if !ok {
+		id = len(g.edges)
+		g.edgeIDs[key] = id
+		g.edges = append(g.edges, &callGraphEdge{callGraphEdgeKey: key})
+	}
+	g.edges[id].calls++
+}
+
+// WriteDot writes the graph recorded so far in the Graphviz dot language.
+// Each node is labeled with the function name and count of calls, and each
+// edge with the count of calls from the caller to the callee.
+func (g *CallGraph) WriteDot(w io.Writer) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	_, _ = bw.WriteString("digraph calls {\n")
+	for i, n := range g.nodes {
+		_, _ = bw.WriteString("\tn")
+		_, _ = bw.WriteString(strconv.Itoa(i))
+		_, _ = bw.WriteString(" [label=")
+		_, _ = bw.WriteString(strconv.Quote(n.def.DebugName() + "\n" + strconv.FormatUint(n.calls, 10) + " calls"))
+		if n.def.GoFunction() != nil {
+			_, _ = bw.WriteString(",shape=box")
+		}
+		_, _ = bw.WriteString("];\n")
+	}
+	for _, e := range g.edges {
+		_, _ = bw.WriteString("\tn")
+		_, _ = bw.WriteString(strconv.Itoa(e.caller))
+		_, _ = bw.WriteString(" -> n")
+		_, _ = bw.WriteString(strconv.Itoa(e.callee))
+		_, _ = bw.WriteString(" [label=")
+		_, _ = bw.WriteString(strconv.FormatUint(e.calls, 10))
+		_, _ = bw.WriteString("];\n")
+	}
+	_, _ = bw.WriteString("}\n")
+	return bw.Flush()
+}
+
+// WriteJSON writes the graph recorded so far as a JSON object, like this:
+//
+//	{"nodes":[{"name":"test.a","host":false,"calls":1},{"name":"env.sample","host":true,"calls":1}],
+//	 "edges":[{"caller":0,"callee":1,"calls":1}]}
+//
+// The caller and callee of each edge are indexes of nodes.
+func (g *CallGraph) WriteJSON(w io.Writer) error {
+	type node struct {
+		Name  string `json:"name"`
+		Host  bool   `json:"host"`
+		Calls uint64 `json:"calls"`
+	}
+	type edge struct {
+		Caller int    `json:"caller"`
+		Callee int    `json:"callee"`
+		Calls  uint64 `json:"calls"`
+	}
+	graph := struct {
+		Nodes []node `json:"nodes"`
+		Edges []edge `json:"edges"`
+	}{Nodes: []node{}, Edges: []edge{}}
+
+	g.mu.Lock()
+	for _, n := range g.nodes {
+		graph.Nodes = append(graph.Nodes, node{Name: n.def.DebugName(), Host: n.def.GoFunction() != nil, Calls: n.calls})
+	}
+	for _, e := range g.edges {
+		graph.Edges = append(graph.Edges, edge{Caller: e.caller, Callee: e.callee, Calls: e.calls})
+	}
+	g.mu.Unlock()
+
+	return json.NewEncoder(w).Encode(graph)
+}
+
+// callerKey is a context.Context Value key. Its associated value is the
+// api.FunctionDefinition of the call in progress.
+type callerKey struct{}
+
+// callGraphListener implements experimental.FunctionListener by recording
+// each call with its caller.
+type callGraphListener CallGraph
+
+// Before implements experimental.FunctionListener Before.
+func (l *callGraphListener) Before(ctx context.Context, _ api.Module, def api.FunctionDefinition, _ []uint64, _ experimental.StackIterator) context.Context {
+	caller, _ := ctx.Value(callerKey{}).(api.FunctionDefinition)
+	(*CallGraph)(l).add(caller, def)
+	return context.WithValue(ctx, callerKey{}, def)
+}
+
+// After implements experimental.FunctionListener After.
+func (l *callGraphListener) After(context.Context, api.Module, api.FunctionDefinition, []uint64) {}
+
+// Abort implements experimental.FunctionListener Abort.
+func (l *callGraphListener) Abort(context.Context, api.Module, api.FunctionDefinition, error) {}
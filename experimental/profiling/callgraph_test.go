@@ -0,0 +1,68 @@
+package profiling
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+// compile-time check to ensure CallGraph implements FunctionListenerFactory
+var _ experimental.FunctionListenerFactory = &CallGraph{}
+
+func TestCallGraph(t *testing.T) {
+	g := NewCallGraph()
+	ctx := context.WithValue(context.Background(), experimental.FunctionListenerFactoryKey{}, g)
+
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+	defer r.Close(ctx)
+
+	_, err := r.NewHostModuleBuilder("env").
+		NewFunctionBuilder().WithFunc(func(context.Context) {}).Export("sample").
+		Instantiate(ctx, r)
+	require.NoError(t, err)
+
+	mod, err := r.InstantiateModuleFromBinary(ctx, profiledWasm)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, err = mod.ExportedFunction("a").Call(ctx)
+		require.NoError(t, err)
+	}
+
+	t.Run("WriteDot", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, g.WriteDot(&buf))
+		require.Equal(t, `digraph calls {
+	n0 [label="test.a\n2 calls"];
+	n1 [label="env.sample\n4 calls",shape=box];
+	n2 [label="test.b\n2 calls"];
+	n0 -> n1 [label=2];
+	n0 -> n2 [label=2];
+	n2 -> n1 [label=2];
+}
+`, buf.String())
+	})
+
+	t.Run("WriteJSON", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, g.WriteJSON(&buf))
+		require.Equal(t, `{"nodes":[{"name":"test.a","host":false,"calls":2},{"name":"env.sample","host":true,"calls":4},{"name":"test.b","host":false,"calls":2}],"edges":[{"caller":0,"callee":1,"calls":2},{"caller":0,"callee":2,"calls":2},{"caller":2,"callee":1,"calls":2}]}
+`, buf.String())
+	})
+}
+
+func TestCallGraph_empty(t *testing.T) {
+	g := NewCallGraph()
+
+	var buf bytes.Buffer
+	require.NoError(t, g.WriteDot(&buf))
+	require.Equal(t, "digraph calls {\n}\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, g.WriteJSON(&buf))
+	require.Equal(t, "{\"nodes\":[],\"edges\":[]}\n", buf.String())
+}
@@ -0,0 +1,145 @@
+package profiling
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+)
+
+// FoldedStacks is an experimental.FunctionListenerFactory which measures how
+// long each function call took, to write in the folded stack format of
+// Brendan Gregg's FlameGraph tools: https://github.com/brendangregg/FlameGraph
+//
+// Unlike Profiler, this measures every call instead of sampling, so is more
+// precise for short runs, but slower. Here's an example of generating a
+// flamegraph of the "_start" function of a module:
+//
+//	f := profiling.NewFoldedStacks()
+//	ctx = context.WithValue(ctx, experimental.FunctionListenerFactoryKey{}, f)
+//	_, err := r.InstantiateModuleFromBinary(ctx, wasm)
+//	_, _ = f.WriteTo(out) // then: flamegraph.pl out.folded > out.svg
+//
+// Note: Durations are measured with the host's monotonic clock, so include
+// time blocked, and the overhead of the listener itself.
+type FoldedStacks struct {
+	// mu protects the fields below.
+	mu sync.Mutex
+	// nanos are the nanoseconds spent in each stack, excluding nested calls,
+	// keyed by the stack in the folded format.
+	nanos map[string]int64
+}
+
+// NewFoldedStacks returns a new FoldedStacks, which measures calls to
+// functions compiled with it.
+func NewFoldedStacks() *FoldedStacks {
+	return &FoldedStacks{nanos: map[string]int64{}}
+}
+
+// WriteTo implements io.WriterTo by writing a line for each stack measured
+// so far, sorted, like "test.a;test.b;env.sample 1500". Each line is the
+// names of the functions, from the outermost to the innermost, separated by
+// semicolons, followed by the nanoseconds spent in the innermost function.
+//
+// As nested calls are on their own lines, the width of each function in the
+// flamegraph is the inclusive duration of its calls.
+func (f *FoldedStacks) WriteTo(w io.Writer) (int64, error) {
+	f.mu.Lock()
+	stacks := make([]string, 0, len(f.nanos))
+	for stack := range f.nanos {
+		stacks = append(stacks, stack)
+	}
+	sort.Strings(stacks)
+	nanos := make([]int64, len(stacks))
+	for i, stack := range stacks {
+		nanos[i] = f.nanos[stack]
+	}
+	f.mu.Unlock()
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for i, stack := range stacks {
+		_, _ = bw.WriteString(stack)
+		_ = bw.WriteByte(' ')
+		_, _ = bw.WriteString(strconv.FormatInt(nanos[i], 10))
+		_ = bw.WriteByte('\n')
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// NewListener implements experimental.FunctionListenerFactory NewListener.
+func (f *FoldedStacks) NewListener(api.FunctionDefinition) experimental.FunctionListener {
+	return (*foldedStacksListener)(f)
+}
+
+// frameKey is a context.Context Value key. Its associated value is the
+// *frame of the call in progress.
+type frameKey struct{}
+
+// frame is a call in progress, measured by a foldedStacksListener.
+type frame struct {
+	// caller is the frame of the calling function, or nil if not measured.
+	caller *frame
+	// stack is the folded stack of this call, ending with its function.
+	stack string
+	start time.Time
+	// nestedNanos are the nanoseconds spent in nested calls so far.
+	nestedNanos int64
+}
+
+// foldedStacksListener implements experimental.FunctionListener by
+// measuring each call.
+type foldedStacksListener FoldedStacks
+
+// Before implements experimental.FunctionListener Before.
+func (l *foldedStacksListener) Before(ctx context.Context, _ api.Module, def api.FunctionDefinition, _ []uint64, _ experimental.StackIterator) context.Context {
+	fr := &frame{stack: def.DebugName()}
+	if caller, ok := ctx.Value(frameKey{}).(*frame); ok {
+		fr.caller, fr.stack = caller, caller.stack+";"+fr.stack
+	}
+	fr.start = time.Now()
+	return context.WithValue(ctx, frameKey{}, fr)
+}
+
+// After implements experimental.FunctionListener After.
+func (l *foldedStacksListener) After(ctx context.Context, _ api.Module, _ api.FunctionDefinition, _ []uint64) {
+	l.add(ctx)
+}
+
+// Abort implements experimental.FunctionListener Abort.
+func (l *foldedStacksListener) Abort(ctx context.Context, _ api.Module, _ api.FunctionDefinition, _ error) {
+	l.add(ctx)
+}
+
+// add adds the duration of the call, excluding nested calls, to its stack,
+// and the rest to the nested duration of its caller.
+func (l *foldedStacksListener) add(ctx context.Context) {
+	fr := ctx.Value(frameKey{}).(*frame)
+	elapsed := int64(time.Since(fr.start))
+	f := (*FoldedStacks)(l)
+	f.mu.Lock()
+	f.nanos[fr.stack] += elapsed - fr.nestedNanos
+	f.mu.Unlock()
+	if fr.caller != nil {
+		fr.caller.nestedNanos += elapsed
+	}
+}
+
+// countingWriter counts the bytes written, to implement io.WriterTo.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
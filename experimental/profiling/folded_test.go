@@ -0,0 +1,63 @@
+package profiling
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+// compile-time check to ensure FoldedStacks implements FunctionListenerFactory
+var _ experimental.FunctionListenerFactory = &FoldedStacks{}
+
+func TestFoldedStacks(t *testing.T) {
+	f := NewFoldedStacks()
+	ctx := context.WithValue(context.Background(), experimental.FunctionListenerFactoryKey{}, f)
+
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+	defer r.Close(ctx)
+
+	_, err := r.NewHostModuleBuilder("env").
+		NewFunctionBuilder().WithFunc(func(context.Context) { time.Sleep(time.Millisecond) }).Export("sample").
+		Instantiate(ctx, r)
+	require.NoError(t, err)
+
+	mod, err := r.InstantiateModuleFromBinary(ctx, profiledWasm)
+	require.NoError(t, err)
+
+	_, err = mod.ExportedFunction("a").Call(ctx)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	n, err := f.WriteTo(&buf)
+	require.NoError(t, err)
+	require.Equal(t, int64(buf.Len()), n)
+
+	var stacks []string
+	nanos := map[string]int64{}
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		i := strings.LastIndexByte(line, ' ')
+		stack := line[:i]
+		v, err := strconv.ParseInt(line[i+1:], 10, 64)
+		require.NoError(t, err)
+		stacks = append(stacks, stack)
+		nanos[stack] = v
+	}
+	require.Equal(t, []string{
+		"test.a",
+		"test.a;env.sample",
+		"test.a;test.b",
+		"test.a;test.b;env.sample",
+	}, stacks)
+
+	// The sleeps are attributed to the host function.
+	for _, stack := range []string{"test.a;env.sample", "test.a;test.b;env.sample"} {
+		require.True(t, nanos[stack] >= int64(time.Millisecond), stack)
+	}
+}
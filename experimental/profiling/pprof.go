@@ -0,0 +1,216 @@
+package profiling
+
+import (
+	"compress/gzip"
+	"io"
+	"time"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// profile accumulates samples of call stacks, to write in the google/pprof
+// format, a gzipped protocol buffer described by profile.proto:
+// https://github.com/google/pprof/blob/main/proto/profile.proto
+//
+// Each function has one location, so their IDs are the same.
+type profile struct {
+	start  time.Time
+	period time.Duration
+
+	// functionIDs are the IDs of functions sampled, starting at one.
+	functionIDs map[api.FunctionDefinition]uint64
+	functions   []api.FunctionDefinition
+	// samples are the counts of stacks sampled, keyed by their location IDs
+	// encoded as a string.
+	samples map[string]*sample
+	// order is the keys of samples, in the order first sampled.
+	order []string
+}
+
+type sample struct {
+	// locationIDs are from the innermost to the outermost function.
+	locationIDs []uint64
+	count       int64
+}
+
+func newProfile(start time.Time, period time.Duration) *profile {
+	return &profile{
+		start:       start,
+		period:      period,
+		functionIDs: map[api.FunctionDefinition]uint64{},
+		samples:     map[string]*sample{},
+	}
+}
+
+// add adds a sample of the stack of frames, from the outermost to the
+// innermost.
+func (p *profile) add(frames []api.FunctionDefinition) {
+	if len(frames) == 0 {
+		return
+	}
+	ids := make([]uint64, 0, len(frames))
+	key := make([]byte, 0, len(frames)*2)
+	for i := len(frames) - 1; i >= 0; i-- {
+		id, ok := p.functionIDs[frames[i]]
+		if !ok {
+			p.functions = append(p.functions, frames[i])
+			id = uint64(len(p.functions))
+			p.functionIDs[frames[i]] = id
+		}
+		ids = append(ids, id)
+		key = appendVarint(key, id)
+	}
+	if s, ok := p.samples[string(key)]; ok {
+		s.count++
+		return
+	}
+	p.samples[string(key)] = &sample{locationIDs: ids, count: 1}
+	p.order = append(p.order, string(key))
+}
+
+// Field numbers of the messages in profile.proto.
+const (
+	profileSampleType   = 1
+	profileSample       = 2
+	profileLocation     = 4
+	profileFunction     = 5
+	profileStringTable  = 6
+	profileTimeNanos    = 9
+	profileDuration     = 10
+	profilePeriodType   = 11
+	profilePeriod       = 12
+	valueTypeType       = 1
+	valueTypeUnit       = 2
+	sampleLocationID    = 1
+	sampleValue         = 2
+	locationID          = 1
+	locationLine        = 4
+	lineFunctionID      = 1
+	functionID          = 1
+	functionName        = 2
+	functionSystemName  = 3
+	functionFilename    = 4
+	wireTypeVarint      = 0
+	wireTypeLengthDelim = 2
+)
+
+// write writes the profile, gzipped, ending at the given time.
+func (p *profile) write(w io.Writer, end time.Time) error {
+	var b protoBuffer
+	strings := stringTable{indexes: map[string]int64{}}
+	strings.index("") // The first string must be empty.
+
+	valueType := func(field int, typ, unit string) {
+		var vt protoBuffer
+		vt.int64(valueTypeType, strings.index(typ))
+		vt.int64(valueTypeUnit, strings.index(unit))
+		b.message(field, vt)
+	}
+	valueType(profileSampleType, "samples", "count")
+	valueType(profileSampleType, "wall", "nanoseconds")
+
+	for _, key := range p.order {
+		s := p.samples[key]
+		var sb protoBuffer
+		sb.packedUint64(sampleLocationID, s.locationIDs)
+		sb.packedUint64(sampleValue, []uint64{uint64(s.count), uint64(s.count * int64(p.period))})
+		b.message(profileSample, sb)
+	}
+
+	for i := range p.functions {
+		id := uint64(i + 1)
+		var line, loc protoBuffer
+		line.uint64(lineFunctionID, id)
+		loc.uint64(locationID, id)
+		loc.message(locationLine, line)
+		b.message(profileLocation, loc)
+	}
+
+	for i, def := range p.functions {
+		var fb protoBuffer
+		fb.uint64(functionID, uint64(i+1))
+		fb.int64(functionName, strings.index(def.DebugName()))
+		fb.int64(functionSystemName, strings.index(def.Name()))
+		fb.int64(functionFilename, strings.index(def.ModuleName()))
+		b.message(profileFunction, fb)
+	}
+
+	// Encode the string table last, as the fields above add to it.
+	for _, s := range strings.strings {
+		b.bytes(profileStringTable, []byte(s))
+	}
+
+	b.int64(profileTimeNanos, p.start.UnixNano())
+	b.int64(profileDuration, int64(end.Sub(p.start)))
+	valueType(profilePeriodType, "wall", "nanoseconds")
+	b.int64(profilePeriod, int64(p.period))
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(b); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// stringTable is the string table of a profile, where fields refer to
+// strings by their index.
+type stringTable struct {
+	strings []string
+	indexes map[string]int64
+}
+
+func (t *stringTable) index(s string) int64 {
+	if i, ok := t.indexes[s]; ok {
+		return i
+	}
+	i := int64(len(t.strings))
+	t.strings = append(t.strings, s)
+	t.indexes[s] = i
+	return i
+}
+
+// protoBuffer is an encoded protocol buffer message. This only implements
+// what's needed for profile.proto, to avoid a dependency.
+type protoBuffer []byte
+
+func (b *protoBuffer) tag(field, wireType int) {
+	*b = appendVarint(*b, uint64(field<<3|wireType))
+}
+
+func (b *protoBuffer) uint64(field int, v uint64) {
+	if v == 0 {
+		return // default value
+	}
+	b.tag(field, wireTypeVarint)
+	*b = appendVarint(*b, v)
+}
+
+func (b *protoBuffer) int64(field int, v int64) {
+	b.uint64(field, uint64(v))
+}
+
+func (b *protoBuffer) bytes(field int, v []byte) {
+	b.tag(field, wireTypeLengthDelim)
+	*b = appendVarint(*b, uint64(len(v)))
+	*b = append(*b, v...)
+}
+
+func (b *protoBuffer) message(field int, m protoBuffer) {
+	b.bytes(field, m)
+}
+
+func (b *protoBuffer) packedUint64(field int, vs []uint64) {
+	var packed []byte
+	for _, v := range vs {
+		packed = appendVarint(packed, v)
+	}
+	b.bytes(field, packed)
+}
+
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
@@ -0,0 +1,207 @@
+// Package profiling includes experimental.FunctionListenerFactory
+// implementations which profile the execution of guest functions.
+//
+// Note: This package is experimental, so may be changed or deleted at any
+// time.
+package profiling
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+)
+
+// DefaultSamplePeriod is the default period between samples of the call
+// stacks, which is the same as the Go CPU profiler: 100 times per second.
+const DefaultSamplePeriod = 10 * time.Millisecond
+
+// Option configures a Profiler, e.g. WithSamplePeriod.
+type Option func(*Profiler)
+
+// WithSamplePeriod samples the call stacks at the given period instead of
+// DefaultSamplePeriod.
+func WithSamplePeriod(period time.Duration) Option {
+	return func(p *Profiler) {
+		p.period = period
+	}
+}
+
+// Profiler is an experimental.FunctionListenerFactory which keeps a shadow
+// call stack of each function call in progress, and while started, samples
+// them on a timer. The result is a google/pprof profile, with the function
+// names of the wasm name section, so can be read with `go tool pprof`.
+//
+// Here's an example of profiling the "_start" function of a module:
+//
+//	p := profiling.NewProfiler()
+//	ctx = context.WithValue(ctx, experimental.FunctionListenerFactoryKey{}, p)
+//	compiled, _ := r.CompileModule(ctx, wasm)
+//
+//	_ = p.Start(f) // f is the file to write the profile to.
+//	_, err := r.InstantiateModule(ctx, compiled, wazero.NewModuleConfig())
+//	_ = p.Stop()
+//
+// Note: Samples are taken with the wall clock, so include time blocked, for
+// example in host functions which sleep. Stacks of concurrent calls are
+// sampled independently, so may add up to more than the duration profiled.
+type Profiler struct {
+	period time.Duration
+
+	// mu protects the fields below.
+	mu sync.Mutex
+	// stacks are the shadow call stacks of all calls in progress.
+	stacks map[*shadowStack]struct{}
+	// w is the writer passed to Start, or nil if not started.
+	w io.Writer
+	// profile accumulates the samples since Start.
+	profile *profile
+	// done is closed to stop the sampling goroutine, which closes stopped
+	// when it exits.
+	done, stopped chan struct{}
+}
+
+// NewProfiler returns a new Profiler, which samples the call stacks of
+// functions compiled with it only after Start.
+func NewProfiler(opts ...Option) *Profiler {
+	p := &Profiler{period: DefaultSamplePeriod, stacks: map[*shadowStack]struct{}{}}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Start begins sampling the call stacks, until Stop writes the profile to
+// the writer. This returns an error if the profiler was already started.
+func (p *Profiler) Start(w io.Writer) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.w != nil {
+		return errors.New("profiler already started")
+	}
+	p.w = w
+	p.profile = newProfile(time.Now(), p.period)
+	p.done, p.stopped = make(chan struct{}), make(chan struct{})
+	go p.run(p.done, p.stopped)
+	return nil
+}
+
+// Stop stops sampling the call stacks and writes the profile to the writer
+// passed to Start. This returns an error if the profiler wasn't started or
+// the profile couldn't be written.
+func (p *Profiler) Stop() error {
+	p.mu.Lock()
+	if p.w == nil {
+		p.mu.Unlock()
+		return errors.New("profiler not started")
+	}
+	close(p.done)
+	stopped := p.stopped
+	p.mu.Unlock()
+	<-stopped // outside the lock, as the goroutine may be sampling.
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	w, prof := p.w, p.profile
+	p.w, p.profile = nil, nil
+	return prof.write(w, time.Now())
+}
+
+// run samples the call stacks each period, until done is closed.
+func (p *Profiler) run(done, stopped chan struct{}) {
+	defer close(stopped)
+	ticker := time.NewTicker(p.period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			p.sample()
+		}
+	}
+}
+
+// sample adds a sample of each call stack in progress to the profile.
+func (p *Profiler) sample() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.profile == nil {
+		return
+	}
+	var frames []api.FunctionDefinition
+	for s := range p.stacks {
+		s.mu.Lock()
+		frames = append(frames[:0], s.frames...)
+		s.mu.Unlock()
+		p.profile.add(frames)
+	}
+}
+
+// NewListener implements experimental.FunctionListenerFactory NewListener.
+func (p *Profiler) NewListener(api.FunctionDefinition) experimental.FunctionListener {
+	return (*profilingListener)(p)
+}
+
+// stackKey is a context.Context Value key. Its associated value is the
+// *shadowStack of the outermost call with a profilingListener.
+type stackKey struct{}
+
+// shadowStack is the stack of functions called, from the outermost to the
+// innermost.
+type shadowStack struct {
+	// mu protects frames, which are read by Profiler.sample.
+	mu     sync.Mutex
+	frames []api.FunctionDefinition
+}
+
+// profilingListener implements experimental.FunctionListener by pushing and
+// popping the function on the shadow stack of the call.
+type profilingListener Profiler
+
+// Before implements experimental.FunctionListener Before.
+func (l *profilingListener) Before(ctx context.Context, _ api.Module, def api.FunctionDefinition, _ []uint64, _ experimental.StackIterator) context.Context {
+	s, ok := ctx.Value(stackKey{}).(*shadowStack)
+	if !ok {
+		s = &shadowStack{}
+		ctx = context.WithValue(ctx, stackKey{}, s)
+		p := (*Profiler)(l)
+		p.mu.Lock()
+		p.stacks[s] = struct{}{}
+		p.mu.Unlock()
+	}
+	s.mu.Lock()
+	s.frames = append(s.frames, def)
+	s.mu.Unlock()
+	return ctx
+}
+
+// After implements experimental.FunctionListener After.
+func (l *profilingListener) After(ctx context.Context, _ api.Module, _ api.FunctionDefinition, _ []uint64) {
+	l.pop(ctx)
+}
+
+// Abort implements experimental.FunctionListener Abort.
+func (l *profilingListener) Abort(ctx context.Context, _ api.Module, _ api.FunctionDefinition, _ error) {
+	l.pop(ctx)
+}
+
+// pop removes the innermost function from the shadow stack of the call, and
+// the stack from the profiler when it is empty.
+func (l *profilingListener) pop(ctx context.Context) {
+	s := ctx.Value(stackKey{}).(*shadowStack)
+	s.mu.Lock()
+	s.frames = s.frames[:len(s.frames)-1]
+	empty := len(s.frames) == 0
+	s.mu.Unlock()
+	if empty {
+		p := (*Profiler)(l)
+		p.mu.Lock()
+		delete(p.stacks, s)
+		p.mu.Unlock()
+	}
+}
@@ -0,0 +1,126 @@
+package profiling
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+// compile-time check to ensure Profiler implements FunctionListenerFactory
+var _ experimental.FunctionListenerFactory = &Profiler{}
+
+// profiledWasm is a module whose exported function "a" calls the imported
+// "env.sample" directly and via "b".
+var profiledWasm = binary.EncodeModule(&wasm.Module{
+	TypeSection:     []*wasm.FunctionType{{}},
+	ImportSection:   []*wasm.Import{{Module: "env", Name: "sample", Type: wasm.ExternTypeFunc, DescFunc: 0}},
+	FunctionSection: []wasm.Index{0, 0},
+	CodeSection: []*wasm.Code{
+		{Body: []byte{wasm.OpcodeCall, 0, wasm.OpcodeCall, 2, wasm.OpcodeEnd}},
+		{Body: []byte{wasm.OpcodeCall, 0, wasm.OpcodeEnd}},
+	},
+	ExportSection: []*wasm.Export{{Name: "a", Type: wasm.ExternTypeFunc, Index: 1}},
+	NameSection: &wasm.NameSection{
+		ModuleName:    "test",
+		FunctionNames: wasm.NameMap{{Index: 1, Name: "a"}, {Index: 2, Name: "b"}},
+	},
+})
+
+func TestProfiler(t *testing.T) {
+	// Use a long period, so that only the host function samples.
+	p := NewProfiler(WithSamplePeriod(time.Hour))
+	ctx := context.WithValue(context.Background(), experimental.FunctionListenerFactoryKey{}, p)
+
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+	defer r.Close(ctx)
+
+	_, err := r.NewHostModuleBuilder("env").
+		NewFunctionBuilder().WithFunc(func(context.Context) { p.sample() }).Export("sample").
+		Instantiate(ctx, r)
+	require.NoError(t, err)
+
+	mod, err := r.InstantiateModuleFromBinary(ctx, profiledWasm)
+	require.NoError(t, err)
+
+	// Calls aren't sampled before Start.
+	_, err = mod.ExportedFunction("a").Call(ctx)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, p.Start(&buf))
+	require.EqualError(t, p.Start(&buf), "profiler already started")
+	for i := 0; i < 2; i++ {
+		_, err = mod.ExportedFunction("a").Call(ctx)
+		require.NoError(t, err)
+	}
+
+	prof := p.profile
+	var names []string
+	for _, def := range prof.functions {
+		names = append(names, def.DebugName())
+	}
+	require.Equal(t, []string{"env.sample", "test.a", "test.b"}, names)
+	require.Equal(t, 2, len(prof.samples))
+	require.Equal(t, &sample{locationIDs: []uint64{1, 2}, count: 2}, prof.samples[prof.order[0]])
+	require.Equal(t, &sample{locationIDs: []uint64{1, 3, 2}, count: 2}, prof.samples[prof.order[1]])
+
+	// All calls returned, so there are no stacks left to sample.
+	require.Equal(t, 0, len(p.stacks))
+
+	require.NoError(t, p.Stop())
+	require.EqualError(t, p.Stop(), "profiler not started")
+
+	zr, err := gzip.NewReader(&buf)
+	require.NoError(t, err)
+	b, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	for _, s := range []string{"samples", "wall", "nanoseconds", "env.sample", "test.a", "test.b"} {
+		require.True(t, bytes.Contains(b, []byte(s)), s)
+	}
+}
+
+func TestProfiler_Abort(t *testing.T) {
+	p := NewProfiler()
+	ctx := context.WithValue(context.Background(), experimental.FunctionListenerFactoryKey{}, p)
+
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+	defer r.Close(ctx)
+
+	_, err := r.NewHostModuleBuilder("env").
+		NewFunctionBuilder().WithFunc(func(context.Context) { panic("boom") }).Export("sample").
+		Instantiate(ctx, r)
+	require.NoError(t, err)
+
+	mod, err := r.InstantiateModuleFromBinary(ctx, profiledWasm)
+	require.NoError(t, err)
+
+	_, err = mod.ExportedFunction("a").Call(ctx)
+	require.Error(t, err)
+
+	// The aborted calls were popped from the stack.
+	require.Equal(t, 0, len(p.stacks))
+}
+
+func TestProtoBuffer(t *testing.T) {
+	var b protoBuffer
+	b.uint64(1, 0) // omitted, as the default value
+	b.uint64(1, 150)
+	b.int64(2, 1)
+	b.bytes(3, []byte("hi"))
+	b.packedUint64(4, []uint64{1, 300})
+	require.Equal(t, protoBuffer{
+		0x08, 0x96, 0x01,
+		0x10, 0x01,
+		0x1a, 0x02, 'h', 'i',
+		0x22, 0x03, 0x01, 0xac, 0x02,
+	}, b)
+}
@@ -0,0 +1,47 @@
+package experimental
+
+import "context"
+
+// ResourceLimiterKey is a context.Context Value key. Its associated value
+// should be a ResourceLimiter.
+//
+// The value is read from the context passed to wazero.Namespace
+// InstantiateModule and applies to the memory and tables defined by that
+// module, for its lifetime. For example:
+//
+//	ctx = context.WithValue(ctx, experimental.ResourceLimiterKey{}, limiter)
+//	mod, err := r.InstantiateModule(ctx, compiled, config)
+type ResourceLimiterKey struct{}
+
+// ResourceLimiter is consulted before a module is instantiated and before its
+// memory or tables grow, allowing the embedder to veto the allocation. This is
+// useful to bound the resources of untrusted modules in multi-tenant hosts.
+//
+// # Notes
+//
+//   - Growth can be vetoed even if it is within the maximum size defined by
+//     the module. The "memory.grow" and "table.grow" instructions return -1 in
+//     that case, as they would when exceeding the maximum.
+//   - MemoryGrowing and TableGrowing can be called concurrently, for example
+//     if a host function grows memory from another goroutine.
+type ResourceLimiter interface {
+	// InstanceCreating is called before a module named moduleName is
+	// instantiated. Returning an error fails the instantiation with it.
+	InstanceCreating(ctx context.Context, moduleName string) error
+
+	// MemoryGrowing is called before a memory grows from current to desired
+	// pages. maximum is the maximum pages of the memory. Returning false
+	// vetoes the growth.
+	//
+	// Note: This is also called with current zero when the memory is
+	// allocated during instantiation, which fails if vetoed.
+	MemoryGrowing(current, desired, maximum uint32) bool
+
+	// TableGrowing is called before a table grows from current to desired
+	// elements. maximum is the maximum elements of the table, or
+	// math.MaxUint32 if unbounded. Returning false vetoes the growth.
+	//
+	// Note: This is also called with current zero when the table is
+	// allocated during instantiation, which fails if vetoed.
+	TableGrowing(current, desired, maximum uint32) bool
+}
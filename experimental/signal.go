@@ -0,0 +1,76 @@
+package experimental
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// Signal is a virtual POSIX signal delivered to a module with Raise. WASI has
+// no signals, so this lets a host, such as a CLI forwarding Ctrl-C, tell a
+// guest to stop.
+type Signal uint32
+
+const (
+	// SignalInt is SIGINT, e.g. sent when the user presses Ctrl-C.
+	SignalInt Signal = 2
+	// SignalTerm is SIGTERM, e.g. sent when the host is asked to terminate.
+	SignalTerm Signal = 15
+)
+
+// String returns the POSIX name of the signal, e.g. "SIGINT".
+func (s Signal) String() string {
+	switch s {
+	case SignalInt:
+		return "SIGINT"
+	case SignalTerm:
+		return "SIGTERM"
+	}
+	return fmt.Sprintf("signal(%d)", uint32(s))
+}
+
+// ExitCode is the exit code of a module closed by this signal. This follows
+// the shell convention of 128 plus the signal number, e.g. 130 for SIGINT.
+func (s Signal) ExitCode() uint32 {
+	return 128 + uint32(s)
+}
+
+// Raise delivers sig to mod, like a process receiving a signal.
+//
+// If handler names a function exported by mod with the signature (i32) -> (),
+// it's called with the signal number, and mod keeps running unless the
+// handler exits. Otherwise, mod is closed with sig.ExitCode(), so calls still
+// running return a sys.ExitError with that code. For example:
+//
+//	c := make(chan os.Signal, 1)
+//	signal.Notify(c, os.Interrupt)
+//	go func() {
+//		<-c
+//		_ = experimental.Raise(ctx, mod, experimental.SignalInt, "")
+//	}()
+//	_, err := mod.ExportedFunction("_start").Call(ctx)
+//
+// # Notes
+//
+//   - Closing only interrupts a running function compiled with
+//     wazero.RuntimeConfig WithCloseOnContextDone. Otherwise, it's noticed
+//     when the function calls a host function or returns.
+//   - The handler runs on the goroutine calling Raise, concurrently with any
+//     function still running. Like a real signal handler, it must be safe for
+//     that, e.g. only setting a flag in memory the guest checks.
+//   - This returns an error if the handler exists but has the wrong
+//     signature, or the error returned by the handler.
+func Raise(ctx context.Context, mod api.Module, sig Signal, handler string) error {
+	if handler != "" {
+		if fn := mod.ExportedFunction(handler); fn != nil {
+			def := fn.Definition()
+			if len(def.ParamTypes()) != 1 || def.ParamTypes()[0] != api.ValueTypeI32 || len(def.ResultTypes()) != 0 {
+				return fmt.Errorf("signal handler %q must have the signature (i32) -> ()", handler)
+			}
+			_, err := fn.Call(ctx, uint64(sig))
+			return err
+		}
+	}
+	return mod.CloseWithExitCode(ctx, sig.ExitCode())
+}
@@ -0,0 +1,119 @@
+package experimental_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	. "github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// signalWasm exports "wait", which spins until "on_signal" stores the signal
+// number in memory and returns it, and "spin", which never returns.
+var signalWasm = binary.EncodeModule(&wasm.Module{
+	TypeSection: []*wasm.FunctionType{
+		{Results: []api.ValueType{api.ValueTypeI32}, ResultNumInUint64: 1},
+		{Params: []api.ValueType{api.ValueTypeI32}, ParamNumInUint64: 1},
+		{},
+	},
+	FunctionSection: []wasm.Index{0, 1, 2},
+	MemorySection:   &wasm.Memory{Min: 1},
+	CodeSection: []*wasm.Code{
+		{Body: []byte{ // wait
+			wasm.OpcodeLoop, 0x40,
+			wasm.OpcodeI32Const, 0,
+			wasm.OpcodeI32Load, 0x2, 0x0,
+			wasm.OpcodeI32Eqz,
+			wasm.OpcodeBrIf, 0,
+			wasm.OpcodeEnd,
+			wasm.OpcodeI32Const, 0,
+			wasm.OpcodeI32Load, 0x2, 0x0,
+			wasm.OpcodeEnd,
+		}},
+		{Body: []byte{ // on_signal
+			wasm.OpcodeI32Const, 0,
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeI32Store, 0x2, 0x0,
+			wasm.OpcodeEnd,
+		}},
+		{Body: []byte{ // spin
+			wasm.OpcodeLoop, 0x40,
+			wasm.OpcodeBr, 0,
+			wasm.OpcodeEnd,
+			wasm.OpcodeEnd,
+		}},
+	},
+	ExportSection: []*wasm.Export{
+		{Name: "wait", Type: api.ExternTypeFunc, Index: 0},
+		{Name: "on_signal", Type: api.ExternTypeFunc, Index: 1},
+		{Name: "spin", Type: api.ExternTypeFunc, Index: 2},
+	},
+})
+
+func TestSignal(t *testing.T) {
+	require.Equal(t, "SIGINT", SignalInt.String())
+	require.Equal(t, "SIGTERM", SignalTerm.String())
+	require.Equal(t, "signal(9)", Signal(9).String())
+	require.Equal(t, uint32(130), SignalInt.ExitCode())
+	require.Equal(t, uint32(143), SignalTerm.ExitCode())
+}
+
+func TestRaise(t *testing.T) {
+	ctx := context.Background()
+
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCloseOnContextDone(true))
+	defer r.Close(ctx)
+
+	compiled, err := r.CompileModule(ctx, signalWasm)
+	require.NoError(t, err)
+
+	t.Run("handler", func(t *testing.T) {
+		mod, err := r.InstantiateModule(ctx, compiled, wazero.NewModuleConfig().WithName("handler"))
+		require.NoError(t, err)
+		defer mod.Close(ctx)
+
+		raised := make(chan error, 1)
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			raised <- Raise(ctx, mod, SignalTerm, "on_signal")
+		}()
+
+		results, err := mod.ExportedFunction("wait").Call(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []uint64{uint64(SignalTerm)}, results)
+		require.NoError(t, <-raised)
+	})
+
+	for _, handler := range []string{"", "missing"} {
+		handler := handler
+		t.Run("close handler="+handler, func(t *testing.T) {
+			mod, err := r.InstantiateModule(ctx, compiled, wazero.NewModuleConfig().WithName("close"))
+			require.NoError(t, err)
+
+			raised := make(chan error, 1)
+			go func() {
+				time.Sleep(10 * time.Millisecond)
+				raised <- Raise(ctx, mod, SignalInt, handler)
+			}()
+
+			_, err = mod.ExportedFunction("spin").Call(ctx)
+			require.Equal(t, sys.NewExitError("close", SignalInt.ExitCode()), err)
+			require.NoError(t, <-raised)
+		})
+	}
+
+	t.Run("invalid handler", func(t *testing.T) {
+		mod, err := r.InstantiateModule(ctx, compiled, wazero.NewModuleConfig().WithName("invalid"))
+		require.NoError(t, err)
+		defer mod.Close(ctx)
+
+		err = Raise(ctx, mod, SignalInt, "wait")
+		require.EqualError(t, err, `signal handler "wait" must have the signature (i32) -> ()`)
+	})
+}
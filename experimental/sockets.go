@@ -0,0 +1,39 @@
+package experimental
+
+import "net"
+
+// SocketsKey is a context.Context Value key. Its associated value should be a
+// Sockets.
+//
+// The value is read from the context passed to wazero.Namespace
+// InstantiateModule, which opens the sockets as file descriptors of that
+// module. For example:
+//
+//	ln, _ := net.Listen("tcp", "127.0.0.1:8080")
+//	ctx = context.WithValue(ctx, experimental.SocketsKey{}, experimental.Sockets{Listeners: []net.Listener{ln}})
+//	mod, err := r.InstantiateModule(ctx, compiled, config)
+type SocketsKey struct{}
+
+// Sockets are network listeners and connections created by the host, which a
+// guest uses via the WASI functions "sock_accept", "sock_recv", "sock_send"
+// and "sock_shutdown". This allows servers compiled to wasm32-wasi to accept
+// real connections, as WASI has no function to open a socket itself.
+//
+// # Notes
+//
+//   - The file descriptors are assigned in order, after stdio, the root
+//     directory (if any) and listeners configured with wazero.ModuleConfig:
+//     first Listeners, then Conns. For example, with no file system or other
+//     listeners configured, the first listener is file descriptor 3.
+//   - The module owns the sockets: they are closed when the guest calls
+//     "fd_close" on them or when the module is closed.
+//   - The same Sockets must not be used to instantiate more than one module.
+type Sockets struct {
+	// Listeners are opened as file descriptors the guest can accept
+	// connections from.
+	Listeners []net.Listener
+
+	// Conns are opened as file descriptors the guest can read from and write
+	// to, e.g. a connection accepted by the host.
+	Conns []net.Conn
+}
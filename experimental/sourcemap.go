@@ -0,0 +1,39 @@
+package experimental
+
+import "context"
+
+// SourceMapLoaderKey is a context.Context Value key. Its associated value
+// should be a SourceMapLoader.
+//
+// The value is read from the context passed to wazero.Runtime CompileModule.
+// For example:
+//
+//	ctx = context.WithValue(ctx, experimental.SourceMapLoaderKey{}, experimental.SourceMapLoader(
+//		func(ctx context.Context, url string) ([]byte, error) {
+//			return os.ReadFile(filepath.Join(dir, url))
+//		}))
+//	compiled, err := r.CompileModule(ctx, wasm)
+type SourceMapLoaderKey struct{}
+
+// SourceMapLoader returns the source map at the URL of the "sourceMappingURL"
+// custom section of a wasm binary, which is typically relative to the binary.
+// This is only called when the binary has that section.
+//
+// The source map is used to translate the offsets of stack traces to
+// positions in the original source, like DWARF, which toolchains such as
+// AssemblyScript and Emscripten can emit instead. For example:
+//
+//	wasm stack trace:
+//		.div(i32,i32) i32
+//			0x2a: assembly/index.ts:3:10
+//
+// # Notes
+//
+//   - The source map must be in the version 3 format, with offsets in the
+//     wasm binary as columns. See https://sourcemaps.info/spec.html
+//   - DWARF takes precedence when the binary includes both.
+//   - Errors loading or decoding the source map are ignored, as it's only
+//     used for stack traces.
+//   - This has no effect if wazero.RuntimeConfig WithDebugInfoEnabled is
+//     false.
+type SourceMapLoader func(ctx context.Context, url string) ([]byte, error)
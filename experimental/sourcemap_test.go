@@ -0,0 +1,94 @@
+package experimental_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	. "github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+func TestSourceMapLoader(t *testing.T) {
+	// Define a module whose function traps after a nop, and whose source map
+	// is "div.wasm.map".
+	bin := binary.EncodeModule(&wasm.Module{
+		TypeSection:     []*wasm.FunctionType{{}},
+		FunctionSection: []wasm.Index{0},
+		CodeSection: []*wasm.Code{
+			{Body: []byte{wasm.OpcodeNop, wasm.OpcodeUnreachable, wasm.OpcodeEnd}},
+		},
+		ExportSection: []*wasm.Export{{Name: "div", Type: wasm.ExternTypeFunc, Index: 0}},
+		NameSection:   &wasm.NameSection{FunctionNames: wasm.NameMap{{Index: 0, Name: "div"}}},
+	})
+	url := "div.wasm.map"
+	bin = append(bin, wasm.SectionIDCustom, byte(1+len("sourceMappingURL")+1+len(url)),
+		byte(len("sourceMappingURL")))
+	bin = append(bin, "sourceMappingURL"...)
+	bin = append(bin, byte(len(url)))
+	bin = append(bin, url...)
+
+	// Map the nop to index.ts:2:3 and the unreachable to index.ts:3:10.
+	m, err := binary.DecodeModule(bin, api.CoreFeaturesV2, wasm.MemoryLimitPages, false, true, false)
+	require.NoError(t, err)
+	body := m.CodeSectionOffset + m.CodeSection[0].BodyOffsetInCodeSection
+	sourceMap := fmt.Sprintf(`{"version":3,"sources":["assembly/index.ts"],"mappings":"%s,%s"}`,
+		vlq(int64(body), 0, 1, 2), vlq(1, 0, 1, 7))
+
+	configs := map[string]wazero.RuntimeConfig{"interpreter": wazero.NewRuntimeConfigInterpreter()}
+	if platform.CompilerSupported() {
+		configs["compiler"] = wazero.NewRuntimeConfigCompiler()
+	}
+	for name, config := range configs {
+		config := config
+		t.Run(name, func(t *testing.T) {
+			var urls []string
+			ctx := context.WithValue(context.Background(), SourceMapLoaderKey{}, SourceMapLoader(
+				func(_ context.Context, url string) ([]byte, error) {
+					urls = append(urls, url)
+					return []byte(sourceMap), nil
+				}))
+
+			r := wazero.NewRuntimeWithConfig(ctx, config)
+			defer r.Close(ctx) // This closes everything this Runtime created.
+
+			mod, err := r.InstantiateModuleFromBinary(ctx, bin)
+			require.NoError(t, err)
+			require.Equal(t, []string{url}, urls)
+
+			_, err = mod.ExportedFunction("div").Call(ctx)
+			require.EqualError(t, err, fmt.Sprintf(`wasm error: unreachable
+wasm stack trace:
+	.div()
+		%#x: assembly/index.ts:3:10`, m.CodeSection[0].BodyOffsetInCodeSection+1))
+		})
+	}
+}
+
+// vlq encodes the fields of a source map segment.
+func vlq(fields ...int64) string {
+	const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+	var ret []byte
+	for _, f := range fields {
+		v := f << 1
+		if f < 0 {
+			v = -f<<1 | 1
+		}
+		for {
+			digit := v & 0x1f
+			if v >>= 5; v != 0 {
+				digit |= 0x20
+			}
+			ret = append(ret, alphabet[digit])
+			if v == 0 {
+				break
+			}
+		}
+	}
+	return string(ret)
+}
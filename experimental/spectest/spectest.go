@@ -0,0 +1,167 @@
+// Package spectest runs the scripts of the WebAssembly specification tests
+// against wazero, so that forks and proposal experiments can be checked
+// against the official test suite, or the parts of it they support.
+//
+// Scripts are either in the text format (%.wast) they're written in, or the
+// JSON format written by wast2json, which also writes the modules the script
+// names next to it. For example, to convert "i32.wast":
+//
+//	wast2json --debug-names --no-check i32.wast
+//
+// See https://github.com/WebAssembly/spec/tree/main/test/core and
+// https://github.com/WebAssembly/wabt/blob/main/docs/wast2json.md
+//
+// Note: This package is experimental, so may be changed or deleted at any
+// time.
+package spectest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/engine/compiler"
+	"github.com/tetratelabs/wazero/internal/engine/interpreter"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/wast"
+)
+
+// ErrSkipped is the Result.Err of assertions which can't be run, as their
+// module is quoted text, e.g. "(assert_malformed (module quote ...) ...)".
+var ErrSkipped = wast.ErrSkipped
+
+// Config configures how scripts are run.
+type Config struct {
+	// CoreFeatures are the features enabled when running scripts, which
+	// should be those the scripts were written for. Defaults to
+	// api.CoreFeaturesV2 when zero.
+	CoreFeatures api.CoreFeatures
+
+	// Interpreter runs scripts with the interpreter, even if the compiler is
+	// supported on this platform.
+	Interpreter bool
+
+	// Scripts are path.Match patterns of the names of the scripts Run runs,
+	// without the ".json" extension, e.g. "simd_*". Defaults to all scripts.
+	Scripts []string
+}
+
+// Result is the result of a command of a script.
+type Result struct {
+	// Script is the name of the ".wast" file the script was converted from.
+	Script string
+	// Line is the line of the command in the ".wast" file.
+	Line int
+	// Command is the type of the command, e.g. "assert_return".
+	Command string
+	// Err is nil if the command passed, ErrSkipped if it couldn't be run, or
+	// otherwise describes why it failed.
+	Err error
+}
+
+// RunScript runs the commands of the script at name in fsys, returning the
+// result of each command. A script with the ".wast" extension is in the text
+// format, otherwise it's in the JSON format, whose modules are read relative
+// to it. An error is returned if the script can't be run at all.
+func RunScript(ctx context.Context, fsys fs.FS, name string, config Config) ([]Result, error) {
+	raw, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	readFile := func(filename string) ([]byte, error) {
+		return fs.ReadFile(fsys, path.Join(path.Dir(name), filename))
+	}
+	var script *wast.Script
+	if path.Ext(name) == ".wast" {
+		var files map[string][]byte
+		if script, files, err = wast.DecodeScript(path.Base(name), raw); err != nil {
+			return nil, fmt.Errorf("invalid script %s: %w", name, err)
+		}
+		readFile = func(filename string) ([]byte, error) {
+			if f, ok := files[filename]; ok {
+				return f, nil
+			}
+			return nil, fmt.Errorf("module %s not found", filename)
+		}
+	} else if err = json.Unmarshal(raw, &script); err != nil {
+		return nil, fmt.Errorf("invalid script %s: %w", name, err)
+	}
+
+	features := config.CoreFeatures
+	if features == 0 {
+		features = api.CoreFeaturesV2
+	}
+	newEngine := interpreter.NewEngine
+	if !config.Interpreter && platform.CompilerSupported() {
+		newEngine = compiler.NewEngine
+	}
+	r, err := wast.NewRunner(ctx, newEngine(ctx, features), features, readFile)
+	if err != nil {
+		return nil, err
+	}
+
+	source := path.Base(strings.ReplaceAll(script.SourceFile, "\\", "/"))
+	ret := make([]Result, 0, len(script.Commands))
+	for i := range script.Commands {
+		c := &script.Commands[i]
+		ret = append(ret, Result{Script: source, Line: c.Line, Command: c.CommandType, Err: r.Exec(c)})
+	}
+	return ret, nil
+}
+
+// Run runs each script in the root of fsys which matches Config.Scripts as a
+// subtest of t, and each of its commands as a subtest of that. Failed
+// commands fail the test and skipped commands skip it.
+func Run(t *testing.T, fsys fs.FS, config Config) {
+	t.Helper()
+	scripts, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ran bool
+	for _, name := range scripts {
+		if !matchScript(strings.TrimSuffix(name, ".json"), config.Scripts) {
+			continue
+		}
+		ran = true
+		t.Run(strings.TrimSuffix(name, ".json"), func(t *testing.T) {
+			results, err := RunScript(context.Background(), fsys, name, config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, r := range results {
+				r := r
+				t.Run(fmt.Sprintf("%s/line:%d", r.Command, r.Line), func(t *testing.T) {
+					switch {
+					case errors.Is(r.Err, ErrSkipped):
+						t.Skip(r.Err)
+					case r.Err != nil:
+						t.Errorf("%s:%d: %v", r.Script, r.Line, r.Err)
+					}
+				})
+			}
+		})
+	}
+	if !ran {
+		t.Fatal("no scripts matched")
+	}
+}
+
+func matchScript(name string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package spectest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+// v1 are the scripts of the WebAssembly 1.0 specification tests.
+var v1 = os.DirFS("../../internal/integration_test/spectest/v1/testdata")
+
+func TestRunScript(t *testing.T) {
+	// The script in the text format has the same results as when converted
+	// to JSON by wast2json.
+	for _, name := range []string{"i32.json", "i32.wast"} {
+		for _, interpreter := range []bool{false, true} {
+			results, err := RunScript(context.Background(), v1, name, Config{
+				CoreFeatures: api.CoreFeaturesV1,
+				Interpreter:  interpreter,
+			})
+			require.NoError(t, err)
+			require.Equal(t, 443, len(results))
+
+			require.Equal(t, Result{Script: "i32.wast", Line: 3, Command: "module"}, results[0])
+			for _, r := range results {
+				if !errors.Is(r.Err, ErrSkipped) {
+					require.NoError(t, r.Err, "%s:%d", r.Script, r.Line)
+				}
+			}
+		}
+	}
+}
+
+func TestRunScript_Errors(t *testing.T) {
+	_, err := RunScript(context.Background(), v1, "missing.json", Config{})
+	require.Error(t, err)
+
+	_, err = RunScript(context.Background(), v1, "i32.0.wasm", Config{})
+	require.Contains(t, err.Error(), "invalid script i32.0.wasm")
+
+	_, err = RunScript(context.Background(), v1, "address.1.wat", Config{})
+	require.Contains(t, err.Error(), "invalid script address.1.wat")
+}
+
+func TestRun(t *testing.T) {
+	Run(t, v1, Config{CoreFeatures: api.CoreFeaturesV1, Scripts: []string{"nop", "i64"}})
+}
+
+func Test_matchScript(t *testing.T) {
+	require.True(t, matchScript("i32", nil))
+	require.True(t, matchScript("simd_lane", []string{"i32", "simd_*"}))
+	require.False(t, matchScript("i32", []string{"simd_*"}))
+}
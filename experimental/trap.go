@@ -0,0 +1,79 @@
+package experimental
+
+import (
+	"context"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// TrapHandler is invoked at the point a guest function traps, before its call
+// stack is unwound, and returns whether to unwind or resume. This is set with
+// wazero.RuntimeConfig WithTrapHandler.
+//
+// For example, this resumes a division by zero as a division by one, to
+// continue testing code paths past it:
+//
+//	rConfig = wazero.NewRuntimeConfigInterpreter().WithTrapHandler(
+//		func(ctx context.Context, trap *experimental.Trap) experimental.TrapAction {
+//			if trap.Resumable && trap.Err.Error() == "integer divide by zero" {
+//				trap.Values[len(trap.Values)-1] = 1 // the divisor
+//				return experimental.TrapResume
+//			}
+//			return experimental.TrapUnwind
+//		})
+//
+// # Notes
+//
+//   - The handler is invoked once per trap, in the goroutine of the call, so
+//     the trap may be inspected with the memory and globals of Trap Module.
+//   - If the handler panics, the function call fails with its value instead
+//     of the trap.
+//   - Exits, such as by the WASI function proc_exit, and panics of host
+//     functions aren't traps, so aren't handled.
+type TrapHandler func(ctx context.Context, trap *Trap) TrapAction
+
+// TrapAction is what a TrapHandler returns.
+type TrapAction int
+
+const (
+	// TrapUnwind unwinds the call stack, so that the function call fails with
+	// the trap as usual.
+	TrapUnwind TrapAction = iota
+	// TrapResume retries the instruction which trapped, typically after the
+	// handler changed the state of the module or Trap Values. This is the
+	// same as TrapUnwind if Trap Resumable is false.
+	//
+	// Note: If the state didn't change, the instruction traps again, and the
+	// handler is invoked again.
+	TrapResume
+)
+
+// Trap is the state of the execution when a function trapped. It is only
+// valid until the TrapHandler returns.
+type Trap struct {
+	// Err is the cause of the trap, e.g. "integer divide by zero". The
+	// function call fails with this error, wrapped with a stack trace, unless
+	// the trap is resumed.
+	Err error
+	// Module is the instance of the module which defines Function, e.g. to
+	// read or write its memory.
+	Module api.Module
+	// Function is the function executing the instruction which trapped.
+	Function api.FunctionDefinition
+	// Offset is the offset of the instruction in the code section of the
+	// binary, or zero if it isn't known. This is the same as in stack traces,
+	// so is only known when the binary has DWARF or a source map.
+	Offset uint64
+	// Stack is the functions on the call stack, from the innermost, the
+	// Function, to the outermost.
+	Stack []api.FunctionDefinition
+	// Values are the api.ValueType encoded values of the frame of Function:
+	// its parameters and locals, followed by its operand stack, whose last
+	// values are the operands of the instruction which trapped. These may be
+	// changed before resuming. This is only set when Resumable is true.
+	Values []uint64
+	// Resumable is true if the handler may return TrapResume. This is only
+	// true in the interpreter, except for a stack overflow, as the compiler
+	// can't recover the state of a native function.
+	Resumable bool
+}
@@ -0,0 +1,145 @@
+package experimental_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	. "github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+// trapWasm is a module whose exported function "div" returns the result of
+// "inner", which divides its params, and "load" loads an i32 at its param.
+var trapWasm = binary.EncodeModule(&wasm.Module{
+	TypeSection: []*wasm.FunctionType{
+		{Params: []api.ValueType{api.ValueTypeI32, api.ValueTypeI32}, Results: []api.ValueType{api.ValueTypeI32}},
+		{Params: []api.ValueType{api.ValueTypeI32}, Results: []api.ValueType{api.ValueTypeI32}},
+	},
+	FunctionSection: []wasm.Index{0, 0, 1},
+	MemorySection:   &wasm.Memory{Min: 1},
+	CodeSection: []*wasm.Code{
+		{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeCall, 1, wasm.OpcodeEnd}},
+		{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeI32DivU, wasm.OpcodeEnd}},
+		{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Load, 2, 0, wasm.OpcodeEnd}},
+	},
+	ExportSection: []*wasm.Export{
+		{Name: "div", Type: wasm.ExternTypeFunc, Index: 0},
+		{Name: "load", Type: wasm.ExternTypeFunc, Index: 2},
+	},
+	NameSection: &wasm.NameSection{
+		ModuleName:    "test",
+		FunctionNames: wasm.NameMap{{Index: 0, Name: "div"}, {Index: 1, Name: "inner"}, {Index: 2, Name: "load"}},
+	},
+})
+
+func TestTrapHandler(t *testing.T) {
+	configs := map[string]wazero.RuntimeConfig{"interpreter": wazero.NewRuntimeConfigInterpreter()}
+	if platform.CompilerSupported() {
+		configs["compiler"] = wazero.NewRuntimeConfigCompiler()
+	}
+	for name, config := range configs {
+		name, config := name, config
+		resumable := name == "interpreter"
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			var handler TrapHandler
+			r := wazero.NewRuntimeWithConfig(ctx, config.WithTrapHandler(
+				func(ctx context.Context, trap *Trap) TrapAction {
+					return handler(ctx, trap)
+				}))
+			defer r.Close(ctx) // This closes everything this Runtime created.
+
+			m, err := r.InstantiateModuleFromBinary(ctx, trapWasm)
+			require.NoError(t, err)
+
+			t.Run("unwind", func(t *testing.T) {
+				var traps []*Trap
+				handler = func(_ context.Context, trap *Trap) TrapAction {
+					traps = append(traps, trap)
+					return TrapUnwind
+				}
+
+				_, err := m.ExportedFunction("div").Call(ctx, 1, 0)
+				require.EqualError(t, err, `wasm error: integer divide by zero
+wasm stack trace:
+	test.inner(i32,i32) i32
+	test.div(i32,i32) i32`)
+
+				require.Equal(t, 1, len(traps))
+				trap := traps[0]
+				require.EqualError(t, trap.Err, "integer divide by zero")
+				require.Equal(t, "test", trap.Module.Name())
+				require.Equal(t, "test.inner", trap.Function.DebugName())
+				require.Equal(t, 2, len(trap.Stack))
+				require.Equal(t, "test.inner", trap.Stack[0].DebugName())
+				require.Equal(t, "test.div", trap.Stack[1].DebugName())
+				require.Equal(t, resumable, trap.Resumable)
+				if resumable {
+					// The params, followed by the operands of the division.
+					require.Equal(t, []uint64{1, 0, 1, 0}, trap.Values)
+				} else {
+					require.Nil(t, trap.Values)
+				}
+			})
+
+			t.Run("resume division", func(t *testing.T) {
+				handler = func(_ context.Context, trap *Trap) TrapAction {
+					if trap.Resumable {
+						trap.Values[len(trap.Values)-1] = 2 // the divisor
+					}
+					return TrapResume
+				}
+
+				results, err := m.ExportedFunction("div").Call(ctx, 10, 0)
+				if resumable {
+					require.NoError(t, err)
+					require.Equal(t, []uint64{5}, results)
+				} else {
+					require.Contains(t, err.Error(), "integer divide by zero")
+				}
+			})
+
+			t.Run("resume load", func(t *testing.T) {
+				handler = func(_ context.Context, trap *Trap) TrapAction {
+					// Loads at the offset 8 instead, after writing to it.
+					require.True(t, trap.Module.Memory().WriteUint32Le(8, 42))
+					if trap.Resumable {
+						trap.Values[len(trap.Values)-1] = 8 // the address
+					}
+					return TrapResume
+				}
+
+				results, err := m.ExportedFunction("load").Call(ctx, 1<<20)
+				if resumable {
+					require.NoError(t, err)
+					require.Equal(t, []uint64{42}, results)
+				} else {
+					require.Contains(t, err.Error(), "out of bounds memory access")
+				}
+			})
+
+			t.Run("panic", func(t *testing.T) {
+				handler = func(context.Context, *Trap) TrapAction {
+					panic("boom")
+				}
+
+				_, err := m.ExportedFunction("div").Call(ctx, 1, 0)
+				require.EqualError(t, err, `boom (recovered by wazero)
+wasm stack trace:
+	test.inner(i32,i32) i32
+	test.div(i32,i32) i32`)
+			})
+
+			// Calls which don't trap don't invoke the handler.
+			handler = nil
+			results, err := m.ExportedFunction("div").Call(ctx, 10, 5)
+			require.NoError(t, err)
+			require.Equal(t, []uint64{2}, results)
+		})
+	}
+}
@@ -0,0 +1,255 @@
+// Package wagi runs a WASI command per HTTP request, following the WebAssembly
+// Gateway Interface (WAGI), which is like CGI: the request is mapped to the
+// environment variables, arguments and stdin of the command, and the response
+// is read from its stdout.
+//
+// For example, this serves a command which prints "Hello, world!":
+//
+//	wasi_snapshot_preview1.MustInstantiate(ctx, r)
+//	compiled, _ := r.CompileModule(ctx, wasm)
+//	http.ListenAndServe(":8080", wagi.NewHandler(r, compiled, wazero.NewModuleConfig()))
+//
+// The command prints headers, a blank line, then the body:
+//
+//	Content-Type: text/plain
+//
+//	Hello, world!
+//
+// See https://github.com/deislabs/wagi/blob/main/docs/writing_modules.md
+//
+// Note: This package is experimental, so may be changed or deleted at any
+// time.
+package wagi
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// instances counts the instances of all handlers, so that each has a unique
+// module name, even when handlers share a namespace.
+var instances uint64
+
+// NewHandler returns an http.Handler which instantiates compiled in ns for
+// each request, with the given config, and writes the response the command
+// prints to stdout. ns must have instantiated wasi_snapshot_preview1.
+//
+// Per request, the config is overridden as follows:
+//   - WithName is unique, as concurrent instances can't share a name.
+//   - WithStdin reads the request body.
+//   - WithStdout is captured to parse the response.
+//   - WithArgs are the path, followed by each query parameter, e.g.
+//     ["/hello", "name=wazero"] for "/hello?name=wazero".
+//   - WithEnv adds the CGI variables, e.g. REQUEST_METHOD and
+//     QUERY_STRING, the WAGI variables, e.g. X_FULL_URL, and HTTP_ prefixed
+//     variables for each request header, e.g. HTTP_USER_AGENT.
+//
+// The response is 500 if the command fails, exits with a non-zero code, or
+// prints neither a "Content-Type" nor a "Location" header. A "Status" header,
+// e.g. "Status: 404 Not Found", sets the status code, which defaults to 302
+// with a "Location" header or 200 otherwise.
+//
+// The instance is closed when the command returns, and can't be invoked by
+// the handler afterwards. The request context is used to instantiate it, so
+// it is closed when the client disconnects if the runtime was configured
+// with wazero.RuntimeConfig WithCloseOnContextDone.
+//
+// When mounted under a prefix with http.StripPrefix, the prefix is exposed
+// as SCRIPT_NAME, and the rest of the path as PATH_INFO.
+func NewHandler(ns wazero.Namespace, compiled wazero.CompiledModule, config wazero.ModuleConfig) http.Handler {
+	name := compiled.Name()
+	if name == "" {
+		name = "wagi"
+	}
+	return &handler{ns: ns, compiled: compiled, config: config, name: name}
+}
+
+type handler struct {
+	ns       wazero.Namespace
+	compiled wazero.CompiledModule
+	config   wazero.ModuleConfig
+	// name is the prefix of the module name of each instance.
+	name string
+}
+
+// ServeHTTP implements http.Handler ServeHTTP
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var stdout bytes.Buffer
+	config := h.config.
+		WithName(fmt.Sprintf("%s-%d", h.name, atomic.AddUint64(&instances, 1))).
+		WithStdin(r.Body).
+		WithStdout(&stdout).
+		WithArgs(args(r)...)
+	env := environ(r)
+	for i := 0; i < len(env); i += 2 {
+		config = config.WithEnv(env[i], env[i+1])
+	}
+
+	if err := h.run(r.Context(), config); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	status, header, body, err := parseResponse(&stdout)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for k, v := range header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(status)
+	_, _ = io.Copy(w, body)
+}
+
+// run instantiates the command, which runs when started, and closes it.
+func (h *handler) run(ctx context.Context, config wazero.ModuleConfig) error {
+	mod, err := h.ns.InstantiateModule(ctx, h.compiled, config)
+	if exitErr, ok := err.(*sys.ExitError); ok {
+		if code := exitErr.ExitCode(); code != 0 {
+			return fmt.Errorf("module[%s] exited with code %d", exitErr.ModuleName(), code)
+		}
+		return nil // already closed
+	} else if err != nil {
+		return err
+	}
+	return mod.Close(ctx)
+}
+
+// args returns the arguments of the command: the unescaped path and query
+// parameters.
+func args(r *http.Request) []string {
+	args := []string{r.URL.Path}
+	if r.URL.RawQuery == "" {
+		return args
+	}
+	for _, param := range strings.Split(r.URL.RawQuery, "&") {
+		if p, err := url.QueryUnescape(param); err == nil {
+			param = p
+		}
+		args = append(args, param)
+	}
+	return args
+}
+
+// environ returns the environment variables of the request as key, value
+// pairs, in a deterministic order.
+func environ(r *http.Request) []string {
+	scriptName, rawPathInfo := splitPath(r)
+	serverName, serverPort := splitHostPort(r.Host)
+	remoteAddr, _ := splitHostPort(r.RemoteAddr)
+	protocol := "http"
+	if r.TLS != nil {
+		protocol = "https"
+	}
+	matchedRoute := scriptName
+	if matchedRoute == "" {
+		matchedRoute = "/"
+	}
+	contentLength := ""
+	if r.ContentLength > 0 {
+		contentLength = strconv.FormatInt(r.ContentLength, 10)
+	}
+
+	env := []string{
+		"AUTH_TYPE", "",
+		"CONTENT_LENGTH", contentLength,
+		"CONTENT_TYPE", r.Header.Get("Content-Type"),
+		"GATEWAY_INTERFACE", "CGI/1.1",
+		"PATH_INFO", r.URL.Path,
+		"PATH_TRANSLATED", r.URL.Path,
+		"QUERY_STRING", r.URL.RawQuery,
+		"REMOTE_ADDR", remoteAddr,
+		"REMOTE_HOST", remoteAddr,
+		"REMOTE_USER", "",
+		"REQUEST_METHOD", r.Method,
+		"SCRIPT_NAME", scriptName,
+		"SERVER_NAME", serverName,
+		"SERVER_PORT", serverPort,
+		"SERVER_PROTOCOL", r.Proto,
+		"SERVER_SOFTWARE", "WAGI/1",
+		"X_FULL_URL", fmt.Sprintf("%s://%s%s", protocol, r.Host, r.RequestURI),
+		"X_MATCHED_ROUTE", matchedRoute,
+		"X_RAW_PATH_INFO", rawPathInfo,
+	}
+	keys := make([]string, 0, len(r.Header))
+	for k := range r.Header {
+		switch k {
+		case "Authorization", "Content-Length", "Content-Type": // not exposed by CGI
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		env = append(env, "HTTP_"+strings.ToUpper(strings.ReplaceAll(k, "-", "_")), strings.Join(r.Header[k], ", "))
+	}
+	return env
+}
+
+// splitPath returns the prefix stripped from the path of the request, e.g.
+// by http.StripPrefix, and the rest of the path before unescaping.
+func splitPath(r *http.Request) (scriptName, rawPathInfo string) {
+	rawPathInfo = r.URL.EscapedPath()
+	requestPath := r.RequestURI
+	if i := strings.IndexByte(requestPath, '?'); i >= 0 {
+		requestPath = requestPath[:i]
+	}
+	if u, err := url.ParseRequestURI(requestPath); err == nil && strings.HasSuffix(u.Path, r.URL.Path) {
+		scriptName = u.Path[:len(u.Path)-len(r.URL.Path)]
+	}
+	return
+}
+
+// splitHostPort is like net.SplitHostPort, except it returns the address as
+// the host when it has no port.
+func splitHostPort(address string) (host, port string) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address, ""
+	}
+	return
+}
+
+// parseResponse parses the headers and body printed by the command.
+func parseResponse(stdout io.Reader) (status int, header http.Header, body io.Reader, err error) {
+	br := bufio.NewReader(stdout)
+	mimeHeader, err := textproto.NewReader(br).ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return 0, nil, nil, fmt.Errorf("invalid response headers: %w", err)
+	}
+	header = http.Header(mimeHeader)
+
+	status = http.StatusOK
+	if s := header.Get("Status"); s != "" {
+		header.Del("Status")
+		code := s
+		if i := strings.IndexByte(s, ' '); i >= 0 {
+			code = s[:i]
+		}
+		if status, err = strconv.Atoi(code); err != nil || status < 100 || status > 999 {
+			return 0, nil, nil, fmt.Errorf("invalid response status: %s", s)
+		}
+	} else if header.Get("Location") != "" {
+		status = http.StatusFound
+	}
+
+	if header.Get("Content-Type") == "" && header.Get("Location") == "" {
+		return 0, nil, nil, fmt.Errorf("response has neither Content-Type nor Location header")
+	}
+	return status, header, br, nil
+}
@@ -0,0 +1,189 @@
+package wagi
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	binaryformat "github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+// testCtx is an arbitrary, non-default context. Non-nil also prevents linter errors.
+var testCtx = context.WithValue(context.Background(), struct{}{}, "arbitrary")
+
+// echoWasm is a command which prints the first 1000 bytes of stdin, or exits
+// with code 1 if stdin is empty.
+var echoWasm = binaryformat.EncodeModule(&wasm.Module{
+	TypeSection: []*wasm.FunctionType{
+		{Params: []wasm.ValueType{wasm.ValueTypeI32, wasm.ValueTypeI32, wasm.ValueTypeI32, wasm.ValueTypeI32}, Results: []wasm.ValueType{wasm.ValueTypeI32}},
+		{Params: []wasm.ValueType{wasm.ValueTypeI32}},
+		{},
+	},
+	ImportSection: []*wasm.Import{
+		{Module: wasi_snapshot_preview1.ModuleName, Name: "fd_read", Type: wasm.ExternTypeFunc, DescFunc: 0},
+		{Module: wasi_snapshot_preview1.ModuleName, Name: "fd_write", Type: wasm.ExternTypeFunc, DescFunc: 0},
+		{Module: wasi_snapshot_preview1.ModuleName, Name: "proc_exit", Type: wasm.ExternTypeFunc, DescFunc: 1},
+	},
+	FunctionSection: []wasm.Index{2},
+	MemorySection:   &wasm.Memory{Min: 1, Cap: 1, Max: 1, IsMaxEncoded: true},
+	CodeSection: []*wasm.Code{{Body: []byte{
+		// fd_read(stdin, iovs=0, iovs_len=1, nread=8)
+		wasm.OpcodeI32Const, 0, wasm.OpcodeI32Const, 0, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Const, 8,
+		wasm.OpcodeCall, 0, wasm.OpcodeDrop,
+		// exit with code 1 if nread is zero
+		wasm.OpcodeI32Const, 8, wasm.OpcodeI32Load, 2, 0, wasm.OpcodeI32Eqz,
+		wasm.OpcodeIf, 0x40, wasm.OpcodeI32Const, 1, wasm.OpcodeCall, 2, wasm.OpcodeEnd,
+		// set the length of the iovec to nread
+		wasm.OpcodeI32Const, 4, wasm.OpcodeI32Const, 8, wasm.OpcodeI32Load, 2, 0, wasm.OpcodeI32Store, 2, 0,
+		// fd_write(stdout, iovs=0, iovs_len=1, nwritten=12)
+		wasm.OpcodeI32Const, 1, wasm.OpcodeI32Const, 0, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Const, 12,
+		wasm.OpcodeCall, 1, wasm.OpcodeDrop,
+		wasm.OpcodeEnd,
+	}}},
+	DataSection: []*wasm.DataSegment{{
+		OffsetExpression: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0}},
+		Init:             []byte{16, 0, 0, 0, 0xe8, 3, 0, 0}, // iovec of 1000 bytes at 16
+	}},
+	ExportSection: []*wasm.Export{
+		{Name: "_start", Type: wasm.ExternTypeFunc, Index: 3},
+		{Name: "memory", Type: wasm.ExternTypeMemory, Index: 0},
+	},
+	NameSection: &wasm.NameSection{ModuleName: "echo"},
+})
+
+func TestNewHandler(t *testing.T) {
+	r := wazero.NewRuntime(testCtx)
+	defer r.Close(testCtx)
+
+	wasi_snapshot_preview1.MustInstantiate(testCtx, r)
+	compiled, err := r.CompileModule(testCtx, echoWasm)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(NewHandler(r, compiled, wazero.NewModuleConfig()))
+	defer server.Close()
+
+	tests := []struct {
+		name, response         string
+		expectedStatus         int
+		expectedBody           string
+		expectedHeader, header string
+	}{
+		{
+			name:           "content type",
+			response:       "Content-Type: text/plain\nX-Echo: wazero\n\nhello",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "hello",
+			header:         "X-Echo",
+			expectedHeader: "wazero",
+		},
+		{
+			name:           "status",
+			response:       "Content-Type: text/plain\r\nStatus: 404 Not Found\r\n\r\nnot found",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "not found",
+			header:         "Status",
+		},
+		{
+			name:           "location",
+			response:       "Location: /wazero\n\n",
+			expectedStatus: http.StatusFound,
+			header:         "Location",
+			expectedHeader: "/wazero",
+		},
+		{
+			name:           "exit code",
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   "exited with code 1\n",
+		},
+		{
+			name:           "no content type",
+			response:       "X-Echo: wazero\n\nhello",
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   "response has neither Content-Type nor Location header\n",
+		},
+		{
+			name:           "no headers",
+			response:       "hello",
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   "invalid response headers: malformed MIME header: missing colon: \"hello\"\n",
+		},
+		{
+			name:           "invalid status",
+			response:       "Content-Type: text/plain\nStatus: OK\n\n",
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   "invalid response status: OK\n",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			}}
+			res, err := client.Post(server.URL+"/echo", "text/plain", strings.NewReader(tc.response))
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			require.Equal(t, tc.expectedStatus, res.StatusCode)
+			body, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			require.True(t, strings.HasSuffix(string(body), tc.expectedBody), string(body))
+			if tc.header != "" {
+				require.Equal(t, tc.expectedHeader, res.Header.Get(tc.header))
+			}
+		})
+	}
+}
+
+func TestArgs(t *testing.T) {
+	r := httptest.NewRequest("GET", "/hello%20world?name=wazero&greeting=hi%21&flag", nil)
+	require.Equal(t, []string{"/hello world", "name=wazero", "greeting=hi!", "flag"}, args(r))
+
+	r = httptest.NewRequest("GET", "/", nil)
+	require.Equal(t, []string{"/"}, args(r))
+}
+
+func TestEnviron(t *testing.T) {
+	r := httptest.NewRequest("POST", "/app/hello%2Fworld?name=wazero", strings.NewReader("body"))
+	r.Header.Set("Content-Type", "text/plain")
+	r.Header.Set("User-Agent", "test")
+	r.Header.Set("Authorization", "secret")
+	r.Header.Set("X-Forwarded-For", "10.0.0.1")
+
+	var env []string
+	http.StripPrefix("/app", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		env = environ(r)
+	})).ServeHTTP(httptest.NewRecorder(), r)
+
+	require.Equal(t, []string{
+		"AUTH_TYPE", "",
+		"CONTENT_LENGTH", "4",
+		"CONTENT_TYPE", "text/plain",
+		"GATEWAY_INTERFACE", "CGI/1.1",
+		"PATH_INFO", "/hello/world",
+		"PATH_TRANSLATED", "/hello/world",
+		"QUERY_STRING", "name=wazero",
+		"REMOTE_ADDR", "192.0.2.1",
+		"REMOTE_HOST", "192.0.2.1",
+		"REMOTE_USER", "",
+		"REQUEST_METHOD", "POST",
+		"SCRIPT_NAME", "/app",
+		"SERVER_NAME", "example.com",
+		"SERVER_PORT", "",
+		"SERVER_PROTOCOL", "HTTP/1.1",
+		"SERVER_SOFTWARE", "WAGI/1",
+		"X_FULL_URL", "http://example.com/app/hello%2Fworld?name=wazero",
+		"X_MATCHED_ROUTE", "/app",
+		"X_RAW_PATH_INFO", "/hello%2Fworld",
+		"HTTP_USER_AGENT", "test",
+		"HTTP_X_FORWARDED_FOR", "10.0.0.1",
+	}, env)
+}
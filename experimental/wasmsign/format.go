@@ -0,0 +1,180 @@
+package wasmsign
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/tetratelabs/wazero/internal/leb128"
+)
+
+var (
+	magic   = []byte{0x00, 0x61, 0x73, 0x6D}
+	version = []byte{0x01, 0x00, 0x00, 0x00}
+)
+
+// SectionName is the name of the custom section of an embedded signature.
+const SectionName = "signature"
+
+const (
+	// domain prefixes signed messages, so that they can't be confused with
+	// other messages signed by the same key.
+	domain            = "wasmsig"
+	specVersion       = 0x01
+	contentTypeModule = 0x01
+	hashSHA256        = 0x01
+	signatureEd25519  = 0x01
+	sectionIDCustom   = 0x00
+)
+
+// signedHashes are hashes of the sections of a module, and signatures of
+// them.
+type signedHashes struct {
+	hashes     [][]byte
+	signatures []sig
+}
+
+type sig struct {
+	keyID     []byte
+	algorithm byte
+	signature []byte
+}
+
+// split returns the content of the signature section of the module, or nil if
+// it has none, and the sections after it.
+func split(wasm []byte) (signature, sections []byte, err error) {
+	if len(wasm) < len(magic)+len(version) || !bytes.Equal(wasm[:len(magic)], magic) {
+		return nil, nil, errors.New("invalid magic number")
+	}
+	if !bytes.Equal(wasm[len(magic):len(magic)+len(version)], version) {
+		return nil, nil, errors.New("invalid version header")
+	}
+	sections = wasm[len(magic)+len(version):]
+	if len(sections) == 0 || sections[0] != sectionIDCustom {
+		return nil, sections, nil
+	}
+
+	d := decoder{b: sections[1:]}
+	content := d.bytes()
+	if d.err != nil {
+		return nil, nil, fmt.Errorf("invalid custom section: %w", d.err)
+	}
+	rest := d.b
+	d = decoder{b: content}
+	if name := d.bytes(); d.err != nil {
+		return nil, nil, fmt.Errorf("invalid custom section: %w", d.err)
+	} else if string(name) != SectionName {
+		return nil, sections, nil
+	}
+	return d.b, rest, nil
+}
+
+// decodeHeader decodes the content of a signature section.
+func decodeHeader(b []byte) ([]signedHashes, error) {
+	d := decoder{b: b}
+	if v := d.byte(); d.err == nil && v != specVersion {
+		return nil, fmt.Errorf("unsupported signature version %#x", v)
+	}
+	if t := d.byte(); d.err == nil && t != contentTypeModule {
+		return nil, fmt.Errorf("unsupported signature content type %#x", t)
+	}
+	if h := d.byte(); d.err == nil && h != hashSHA256 {
+		return nil, fmt.Errorf("unsupported signature hash function %#x", h)
+	}
+
+	var ret []signedHashes
+	for i, n := uint32(0), d.uint32(); d.err == nil && i < n; i++ {
+		var sh signedHashes
+		for j, hn := uint32(0), d.uint32(); d.err == nil && j < hn; j++ {
+			sh.hashes = append(sh.hashes, d.next(32))
+		}
+		for j, sn := uint32(0), d.uint32(); d.err == nil && j < sn; j++ {
+			sh.signatures = append(sh.signatures, sig{keyID: d.bytes(), algorithm: d.byte(), signature: d.bytes()})
+		}
+		ret = append(ret, sh)
+	}
+	if d.err == nil && len(d.b) > 0 {
+		d.err = fmt.Errorf("%d bytes after the signatures", len(d.b))
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("invalid signature: %w", d.err)
+	}
+	return ret, nil
+}
+
+// encodeHeader encodes the content of a signature section.
+func encodeHeader(h []signedHashes) []byte {
+	ret := []byte{specVersion, contentTypeModule, hashSHA256}
+	ret = append(ret, leb128.EncodeUint32(uint32(len(h)))...)
+	for _, sh := range h {
+		ret = append(ret, leb128.EncodeUint32(uint32(len(sh.hashes)))...)
+		for _, hash := range sh.hashes {
+			ret = append(ret, hash...)
+		}
+		ret = append(ret, leb128.EncodeUint32(uint32(len(sh.signatures)))...)
+		for _, s := range sh.signatures {
+			ret = appendBytes(ret, s.keyID)
+			ret = append(ret, s.algorithm)
+			ret = appendBytes(ret, s.signature)
+		}
+	}
+	return ret
+}
+
+// encodeSection encodes a signature section with the content.
+func encodeSection(content []byte) []byte {
+	payload := appendBytes(nil, []byte(SectionName))
+	payload = append(payload, content...)
+	return appendBytes([]byte{sectionIDCustom}, payload)
+}
+
+// appendBytes appends b to buf, prefixed with its length.
+func appendBytes(buf, b []byte) []byte {
+	buf = append(buf, leb128.EncodeUint32(uint32(len(b)))...)
+	return append(buf, b...)
+}
+
+// decoder decodes values from b until the first error, after which it
+// returns zero values.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) byte() byte {
+	if b := d.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder) uint32() uint32 {
+	if d.err != nil {
+		return 0
+	}
+	v, n, err := leb128.LoadUint32(d.b)
+	if err != nil {
+		d.err = err
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+// bytes decodes bytes prefixed with their length.
+func (d *decoder) bytes() []byte {
+	return d.next(int(d.uint32()))
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if len(d.b) < n {
+		d.err = errors.New("unexpected end of input")
+		return nil
+	}
+	ret := d.b[:n:n]
+	d.b = d.b[n:]
+	return ret
+}
@@ -0,0 +1,206 @@
+// Package wasmsign verifies signatures of WebAssembly modules, compatible with
+// wasmsign2, so that hosts can enforce the provenance of third-party plugins
+// before compiling them. For example:
+//
+//	key, _ := wasmsign.ParsePublicKey(pub)
+//	keys := wasmsign.StaticKeys(wasmsign.PublicKey{Key: key})
+//	if err := wasmsign.Verify(ctx, wasm, keys); err != nil {
+//		return err
+//	}
+//	compiled, err := r.CompileModule(ctx, wasm)
+//
+// A signature is either embedded, as the first section of the module, a
+// custom section named "signature", or detached, as the content of that
+// section in a separate file. It signs the SHA-256 of all sections of the
+// module after any signature section, with Ed25519.
+//
+// Only signatures of whole modules are verified. Signatures of parts of a
+// module, delimited by custom sections, are ignored.
+//
+// See https://github.com/WebAssembly/tool-conventions/blob/main/Signatures.md
+//
+// Note: This package is experimental, so may be changed or deleted at any
+// time.
+package wasmsign
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// ErrNoSignature is returned by Verify when the module has no signature.
+var ErrNoSignature = errors.New("module has no signature")
+
+// ErrUntrusted is returned when no signature of the module is by a trusted
+// key.
+var ErrUntrusted = errors.New("module isn't signed by a trusted key")
+
+// PublicKey is a trusted key, with an optional ID, e.g. "plugins-2023".
+type PublicKey struct {
+	// ID identifies the key in signatures. If empty, the key is tried for
+	// all signatures.
+	ID []byte
+	// Key verifies signatures.
+	Key ed25519.PublicKey
+}
+
+// KeyProvider returns the trusted keys which may have made a signature with
+// the key ID, which is empty if the signature doesn't have one. Returning an
+// error fails verification, e.g. if a key server is unavailable.
+type KeyProvider func(ctx context.Context, keyID []byte) ([]ed25519.PublicKey, error)
+
+// StaticKeys returns a KeyProvider of the keys: those without an ID, or with
+// the key ID of the signature.
+func StaticKeys(keys ...PublicKey) KeyProvider {
+	return func(_ context.Context, keyID []byte) (ret []ed25519.PublicKey, _ error) {
+		for _, k := range keys {
+			if len(k.ID) == 0 || len(keyID) == 0 || bytes.Equal(k.ID, keyID) {
+				ret = append(ret, k.Key)
+			}
+		}
+		return
+	}
+}
+
+// ParsePublicKey parses an Ed25519 public key, either in the format of
+// wasmsign2, which is 0x01 followed by the 32-byte key, or PEM encoded, e.g.
+// by `openssl pkey -pubout`.
+func ParsePublicKey(b []byte) (ed25519.PublicKey, error) {
+	if block, _ := pem.Decode(b); block != nil {
+		k, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key: %w", err)
+		}
+		if key, ok := k.(ed25519.PublicKey); ok {
+			return key, nil
+		}
+		return nil, fmt.Errorf("invalid public key: %T isn't Ed25519", k)
+	}
+	if len(b) != 1+ed25519.PublicKeySize || b[0] != signatureEd25519 {
+		return nil, errors.New("invalid public key: expected 0x01 followed by 32 bytes")
+	}
+	return ed25519.PublicKey(b[1:]), nil
+}
+
+// Verify returns nil if the module has an embedded signature by a trusted
+// key, or an error, e.g. ErrNoSignature or ErrUntrusted.
+func Verify(ctx context.Context, wasm []byte, keys KeyProvider) error {
+	signature, sections, err := split(wasm)
+	if err != nil {
+		return err
+	} else if signature == nil {
+		return ErrNoSignature
+	}
+	return verify(ctx, signature, sections, keys)
+}
+
+// VerifyDetached is like Verify, except the signature is detached, e.g. read
+// from a ".sig" file. Any embedded signature is ignored.
+func VerifyDetached(ctx context.Context, wasm, signature []byte, keys KeyProvider) error {
+	_, sections, err := split(wasm)
+	if err != nil {
+		return err
+	}
+	return verify(ctx, signature, sections, keys)
+}
+
+func verify(ctx context.Context, signature, sections []byte, keys KeyProvider) error {
+	h, err := decodeHeader(signature)
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.Sum256(sections)
+	for _, sh := range h {
+		if len(sh.hashes) != 1 || !bytes.Equal(sh.hashes[0], hash[:]) {
+			continue // e.g. of part of the module.
+		}
+		msg := message(sh.hashes)
+		for _, s := range sh.signatures {
+			if s.algorithm != signatureEd25519 {
+				continue
+			}
+			trusted, err := keys(ctx, s.keyID)
+			if err != nil {
+				return err
+			}
+			for _, key := range trusted {
+				if len(key) == ed25519.PublicKeySize && ed25519.Verify(key, msg, s.signature) {
+					return nil
+				}
+			}
+		}
+	}
+	return ErrUntrusted
+}
+
+// Sign returns the module with an embedded signature by the key, with the
+// optional key ID. Signing a signed module adds the signature to the others.
+func Sign(wasm []byte, key ed25519.PrivateKey, keyID []byte) ([]byte, error) {
+	signature, sections, err := split(wasm)
+	if err != nil {
+		return nil, err
+	}
+	if signature, err = sign(signature, sections, key, keyID); err != nil {
+		return nil, err
+	}
+
+	ret := append([]byte{}, wasm[:len(magic)+len(version)]...)
+	ret = append(ret, encodeSection(signature)...)
+	return append(ret, sections...), nil
+}
+
+// SignDetached returns a detached signature of the module by the key, with
+// the optional key ID. Any embedded signature is ignored.
+func SignDetached(wasm []byte, key ed25519.PrivateKey, keyID []byte) ([]byte, error) {
+	_, sections, err := split(wasm)
+	if err != nil {
+		return nil, err
+	}
+	return sign(nil, sections, key, keyID)
+}
+
+// sign returns the signature, which is empty if nil, with a signature of the
+// sections by the key added.
+func sign(signature, sections []byte, key ed25519.PrivateKey, keyID []byte) ([]byte, error) {
+	var h []signedHashes
+	if signature != nil {
+		var err error
+		if h, err = decodeHeader(signature); err != nil {
+			return nil, err
+		}
+	}
+
+	hash := sha256.Sum256(sections)
+	i := 0
+	for ; i < len(h); i++ {
+		if len(h[i].hashes) == 1 && bytes.Equal(h[i].hashes[0], hash[:]) {
+			break
+		}
+	}
+	if i == len(h) {
+		h = append(h, signedHashes{hashes: [][]byte{hash[:]}})
+	}
+	h[i].signatures = append(h[i].signatures, sig{
+		keyID:     keyID,
+		algorithm: signatureEd25519,
+		signature: ed25519.Sign(key, message(h[i].hashes)),
+	})
+	return encodeHeader(h), nil
+}
+
+// message returns the message which is signed for the hashes.
+func message(hashes [][]byte) []byte {
+	msg := []byte(domain)
+	msg = append(msg, specVersion, contentTypeModule, hashSHA256)
+	for _, h := range hashes {
+		msg = append(msg, h...)
+	}
+	return msg
+}
@@ -0,0 +1,170 @@
+package wasmsign
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+// testCtx is an arbitrary, non-default context. Non-nil also prevents linter errors.
+var testCtx = context.WithValue(context.Background(), struct{}{}, "arbitrary")
+
+// wasm is a module with only a custom section named "name".
+var wasm = []byte("\x00asm\x01\x00\x00\x00\x00\x05\x04name")
+
+func newKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return pub, priv
+}
+
+func TestSign_Verify(t *testing.T) {
+	pub, priv := newKey(t)
+
+	signed, err := Sign(wasm, priv, []byte("key1"))
+	require.NoError(t, err)
+
+	// The signed module is still valid.
+	r := wazero.NewRuntime(testCtx)
+	defer r.Close(testCtx)
+	_, err = r.CompileModule(testCtx, signed)
+	require.NoError(t, err)
+
+	require.NoError(t, Verify(testCtx, signed, StaticKeys(PublicKey{Key: pub})))
+	require.NoError(t, Verify(testCtx, signed, StaticKeys(PublicKey{ID: []byte("key1"), Key: pub})))
+
+	// A key with another ID isn't tried.
+	err = Verify(testCtx, signed, StaticKeys(PublicKey{ID: []byte("key2"), Key: pub}))
+	require.Equal(t, ErrUntrusted, err)
+}
+
+func TestSign_Multiple(t *testing.T) {
+	pub1, priv1 := newKey(t)
+	pub2, priv2 := newKey(t)
+
+	signed, err := Sign(wasm, priv1, nil)
+	require.NoError(t, err)
+	signed, err = Sign(signed, priv2, nil)
+	require.NoError(t, err)
+
+	// Either key verifies the module.
+	require.NoError(t, Verify(testCtx, signed, StaticKeys(PublicKey{Key: pub1})))
+	require.NoError(t, Verify(testCtx, signed, StaticKeys(PublicKey{Key: pub2})))
+}
+
+func TestVerify_Errors(t *testing.T) {
+	pub, priv := newKey(t)
+	other, _ := newKey(t)
+
+	signed, err := Sign(wasm, priv, nil)
+	require.NoError(t, err)
+	tampered := append(append([]byte{}, signed...), 0x00, 0x02, 0x01, 'x')
+
+	errKeys := errors.New("key server unavailable")
+
+	tests := []struct {
+		name        string
+		wasm        []byte
+		keys        KeyProvider
+		expectedErr string
+	}{
+		{
+			name:        "invalid magic",
+			wasm:        []byte("\x00bsm\x01\x00\x00\x00"),
+			keys:        StaticKeys(PublicKey{Key: pub}),
+			expectedErr: "invalid magic number",
+		},
+		{
+			name:        "unsigned",
+			wasm:        wasm,
+			keys:        StaticKeys(PublicKey{Key: pub}),
+			expectedErr: ErrNoSignature.Error(),
+		},
+		{
+			name:        "untrusted",
+			wasm:        signed,
+			keys:        StaticKeys(PublicKey{Key: other}),
+			expectedErr: ErrUntrusted.Error(),
+		},
+		{
+			name:        "no keys",
+			wasm:        signed,
+			keys:        StaticKeys(),
+			expectedErr: ErrUntrusted.Error(),
+		},
+		{
+			name:        "tampered",
+			wasm:        tampered,
+			keys:        StaticKeys(PublicKey{Key: pub}),
+			expectedErr: ErrUntrusted.Error(),
+		},
+		{
+			name: "key provider error",
+			wasm: signed,
+			keys: func(context.Context, []byte) ([]ed25519.PublicKey, error) {
+				return nil, errKeys
+			},
+			expectedErr: errKeys.Error(),
+		},
+		{
+			name:        "invalid signature",
+			wasm:        append(append([]byte{}, wasm[:8]...), encodeSection([]byte{specVersion, contentTypeModule, hashSHA256, 1, 1})...),
+			keys:        StaticKeys(PublicKey{Key: pub}),
+			expectedErr: "invalid signature: unexpected end of input",
+		},
+		{
+			name:        "unsupported version",
+			wasm:        append(append([]byte{}, wasm[:8]...), encodeSection([]byte{2})...),
+			keys:        StaticKeys(PublicKey{Key: pub}),
+			expectedErr: "unsupported signature version 0x2",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			err := Verify(testCtx, tc.wasm, tc.keys)
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestVerifyDetached(t *testing.T) {
+	pub, priv := newKey(t)
+	other, otherPriv := newKey(t)
+
+	signature, err := SignDetached(wasm, priv, nil)
+	require.NoError(t, err)
+	require.NoError(t, VerifyDetached(testCtx, wasm, signature, StaticKeys(PublicKey{Key: pub})))
+
+	// An embedded signature is ignored.
+	signed, err := Sign(wasm, otherPriv, nil)
+	require.NoError(t, err)
+	require.NoError(t, VerifyDetached(testCtx, signed, signature, StaticKeys(PublicKey{Key: pub})))
+	err = VerifyDetached(testCtx, signed, signature, StaticKeys(PublicKey{Key: other}))
+	require.Equal(t, ErrUntrusted, err)
+}
+
+func TestParsePublicKey(t *testing.T) {
+	pub, _ := newKey(t)
+
+	key, err := ParsePublicKey(append([]byte{0x01}, pub...))
+	require.NoError(t, err)
+	require.Equal(t, pub, key)
+
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+	key, err = ParsePublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	require.NoError(t, err)
+	require.Equal(t, pub, key)
+
+	_, err = ParsePublicKey(pub)
+	require.EqualError(t, err, "invalid public key: expected 0x01 followed by 32 bytes")
+}
@@ -0,0 +1,119 @@
+// Package watchdog includes an experimental.FunctionListenerFactory which
+// reports function calls that take too long, e.g. to surface hangs of guests
+// in production.
+//
+// Note: This package is experimental, so may be changed or deleted at any
+// time.
+package watchdog
+
+import (
+	"context"
+	"time"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+)
+
+// Call is a function call which hasn't returned within the threshold.
+type Call struct {
+	// Module is the calling module.
+	Module api.Module
+	// Function is the function called.
+	Function api.FunctionDefinition
+	// Params are the api.ValueType encoded parameters of the call.
+	Params []uint64
+	// Stack is the functions on the call stack when the call started, from
+	// the innermost, the Function, to the outermost. Functions excluded by
+	// filters are not included.
+	Stack []api.FunctionDefinition
+	// Start is when the call started.
+	Start time.Time
+}
+
+// Callback is invoked with a call that hasn't returned within the threshold,
+// for example, to log it or increment a metric.
+//
+// Note: This is invoked on a different goroutine than the call, which is
+// still in progress, so must not call functions of the module.
+type Callback func(ctx context.Context, call *Call)
+
+// NewListenerFactory returns an experimental.FunctionListenerFactory which
+// invokes the callback when a function hasn't returned within the threshold.
+// It is invoked at most once per call, even if the call never returns.
+//
+// Pass filters to only watch functions matching all of them, such as those
+// in the logging package. For example, this logs exported functions which
+// take longer than a second:
+//
+//	f := watchdog.NewListenerFactory(time.Second, func(ctx context.Context, c *watchdog.Call) {
+//		log.Printf("%s has been running since %s", c.Function.DebugName(), c.Start)
+//	}, func(def api.FunctionDefinition) bool {
+//		return len(def.ExportNames()) > 0
+//	})
+//
+// Note: A call which takes too long also delays its callers, so they are
+// reported too, unless excluded by filters.
+func NewListenerFactory(threshold time.Duration, callback Callback, filters ...func(api.FunctionDefinition) bool) experimental.FunctionListenerFactory {
+	return &listenerFactory{threshold: threshold, callback: callback, filters: filters}
+}
+
+type listenerFactory struct {
+	threshold time.Duration
+	callback  Callback
+	filters   []func(api.FunctionDefinition) bool
+}
+
+// NewListener implements experimental.FunctionListenerFactory NewListener.
+func (f *listenerFactory) NewListener(def api.FunctionDefinition) experimental.FunctionListener {
+	for _, filter := range f.filters {
+		if !filter(def) {
+			return nil
+		}
+	}
+	return f
+}
+
+// callKey is a context.Context Value key. Its associated value is the
+// *call in progress.
+type callKey struct{}
+
+// call is a call in progress.
+type call struct {
+	// caller is the call of the calling function, or nil if not watched.
+	caller *call
+	def    api.FunctionDefinition
+	timer  *time.Timer
+}
+
+// Before implements experimental.FunctionListener Before.
+func (f *listenerFactory) Before(ctx context.Context, mod api.Module, def api.FunctionDefinition, params []uint64, _ experimental.StackIterator) context.Context {
+	c := &call{def: def}
+	c.caller, _ = ctx.Value(callKey{}).(*call)
+	ctx = context.WithValue(ctx, callKey{}, c)
+
+	start := time.Now()
+	// Copy the params, as the engine reuses them after the call.
+	params = append([]uint64(nil), params...)
+	c.timer = time.AfterFunc(f.threshold, func() {
+		f.callback(ctx, &Call{Module: mod, Function: def, Params: params, Stack: c.stack(), Start: start})
+	})
+	return ctx
+}
+
+// After implements experimental.FunctionListener After.
+func (f *listenerFactory) After(ctx context.Context, _ api.Module, _ api.FunctionDefinition, _ []uint64) {
+	ctx.Value(callKey{}).(*call).timer.Stop()
+}
+
+// Abort implements experimental.FunctionListener Abort.
+func (f *listenerFactory) Abort(ctx context.Context, _ api.Module, _ api.FunctionDefinition, _ error) {
+	ctx.Value(callKey{}).(*call).timer.Stop()
+}
+
+// stack returns the functions of the call and its callers, innermost first.
+func (c *call) stack() (stack []api.FunctionDefinition) {
+	for ; c != nil; c = c.caller {
+		stack = append(stack, c.def)
+	}
+	return
+}
@@ -0,0 +1,131 @@
+package watchdog
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+// watchedWasm is a module whose exported function "fast" returns, and whose
+// exported function "slow" calls the imported "env.sleep" with its param.
+var watchedWasm = binary.EncodeModule(&wasm.Module{
+	TypeSection:     []*wasm.FunctionType{{Params: []api.ValueType{api.ValueTypeI32}}},
+	ImportSection:   []*wasm.Import{{Module: "env", Name: "sleep", Type: wasm.ExternTypeFunc, DescFunc: 0}},
+	FunctionSection: []wasm.Index{0, 0},
+	CodeSection: []*wasm.Code{
+		{Body: []byte{wasm.OpcodeEnd}},
+		{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeCall, 0, wasm.OpcodeEnd}},
+	},
+	ExportSection: []*wasm.Export{
+		{Name: "fast", Type: wasm.ExternTypeFunc, Index: 1},
+		{Name: "slow", Type: wasm.ExternTypeFunc, Index: 2},
+	},
+	NameSection: &wasm.NameSection{
+		ModuleName:    "test",
+		FunctionNames: wasm.NameMap{{Index: 1, Name: "fast"}, {Index: 2, Name: "slow"}},
+	},
+})
+
+// recorder records the calls reported, which happen on another goroutine.
+type recorder struct {
+	mu    sync.Mutex
+	calls []*Call
+}
+
+func (r *recorder) callback(_ context.Context, c *Call) {
+	r.mu.Lock()
+	r.calls = append(r.calls, c)
+	r.mu.Unlock()
+}
+
+func (r *recorder) names() (names [][]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.calls {
+		var stack []string
+		for _, def := range c.Stack {
+			stack = append(stack, def.DebugName())
+		}
+		names = append(names, stack)
+	}
+	return
+}
+
+func TestNewListenerFactory(t *testing.T) {
+	const threshold = 20 * time.Millisecond
+
+	tests := []struct {
+		name     string
+		filters  []func(api.FunctionDefinition) bool
+		fn       string
+		expected [][]string
+	}{
+		{
+			name: "fast",
+			fn:   "fast",
+		},
+		{
+			name: "slow",
+			fn:   "slow",
+			expected: [][]string{
+				{"test.slow"},
+				{"env.sleep", "test.slow"},
+			},
+		},
+		{
+			name: "slow filtered",
+			fn:   "slow",
+			filters: []func(api.FunctionDefinition) bool{func(def api.FunctionDefinition) bool {
+				return def.GoFunction() != nil
+			}},
+			expected: [][]string{{"env.sleep"}},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			rec := &recorder{}
+			f := NewListenerFactory(threshold, rec.callback, tc.filters...)
+			ctx := context.WithValue(context.Background(), experimental.FunctionListenerFactoryKey{}, f)
+
+			r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+			defer r.Close(ctx)
+
+			_, err := r.NewHostModuleBuilder("env").
+				NewFunctionBuilder().WithFunc(func(_ context.Context, ms uint32) {
+				time.Sleep(time.Duration(ms) * time.Millisecond)
+			}).Export("sleep").
+				Instantiate(ctx, r)
+			require.NoError(t, err)
+			mod, err := r.InstantiateModuleFromBinary(ctx, watchedWasm)
+			require.NoError(t, err)
+
+			_, err = mod.ExportedFunction(tc.fn).Call(ctx, uint64(5*threshold/time.Millisecond))
+			require.NoError(t, err)
+
+			// Wait past the threshold to ensure returned calls aren't reported.
+			time.Sleep(2 * threshold)
+
+			names := rec.names()
+			// The order of timers firing at the same time is undefined.
+			if len(names) == 2 && len(names[0]) == 2 {
+				names[0], names[1] = names[1], names[0]
+			}
+			require.Equal(t, tc.expected, names)
+			for _, c := range rec.calls {
+				require.Equal(t, []uint64{uint64(5 * threshold / time.Millisecond)}, c.Params)
+				require.Equal(t, c.Stack[0], c.Function)
+				require.Equal(t, "test", c.Module.Name())
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package wazerohttp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// ABI maps a request to a call of a function exported by the guest, and the
+// response from its results, e.g. Exports or WASIHTTP.
+//
+// In both, the request and response are in HTTP/1.1 format, as written by
+// http.Request Write and read by http.ReadResponse, for example:
+//
+//	HTTP/1.1 200 OK
+//	Content-Type: text/plain
+//	Content-Length: 5
+//
+//	hello
+type ABI interface {
+	// validate returns an error if compiled doesn't export the functions
+	// the ABI calls.
+	validate(compiled wazero.CompiledModule) error
+
+	// configure returns the config of a new instance.
+	configure(config wazero.ModuleConfig, inst *instance) wazero.ModuleConfig
+
+	// handle calls the guest with the request in HTTP/1.1 format, returning
+	// the response in the same format.
+	handle(ctx context.Context, inst *instance, req []byte) ([]byte, error)
+}
+
+// Exports is an ABI which passes the request and response in the memory of
+// the guest, allocated with the functions it exports as malloc and free.
+// The functions have these signatures:
+//
+//	(func $handle (param $ptr i32) (param $len i32) (result (; ptr<<32|len ;) i64))
+//	(func $malloc (param $size i32) (result (; ptr ;) i32))
+//	(func $free (param $ptr i32))
+//
+// The request is freed after handle returns, and the response after it is
+// read. This matches the conventions of TinyGo guests, for example:
+//
+//	//export handle
+//	func handle(ptr, size uint32) uint64
+//
+// See imports/tinygo
+func Exports(handle, malloc, free string) ABI {
+	return &exportsABI{handleFn: handle, mallocFn: malloc, freeFn: free}
+}
+
+type exportsABI struct {
+	handleFn, mallocFn, freeFn string
+}
+
+// validate implements ABI.validate
+func (a *exportsABI) validate(compiled wazero.CompiledModule) error {
+	i32, i64 := api.ValueTypeI32, api.ValueTypeI64
+	return validateExports(compiled,
+		export{name: a.handleFn, params: []api.ValueType{i32, i32}, results: []api.ValueType{i64}},
+		export{name: a.mallocFn, params: []api.ValueType{i32}, results: []api.ValueType{i32}},
+		export{name: a.freeFn, params: []api.ValueType{i32}},
+	)
+}
+
+// configure implements ABI.configure
+func (a *exportsABI) configure(config wazero.ModuleConfig, _ *instance) wazero.ModuleConfig {
+	return config
+}
+
+// handle implements ABI.handle
+func (a *exportsABI) handle(ctx context.Context, inst *instance, req []byte) ([]byte, error) {
+	mod := inst.mod
+	malloc, free := mod.ExportedFunction(a.mallocFn), mod.ExportedFunction(a.freeFn)
+
+	results, err := malloc.Call(ctx, uint64(len(req)))
+	if err != nil {
+		return nil, err
+	}
+	reqPtr := uint32(results[0])
+	if reqPtr == 0 && len(req) != 0 {
+		return nil, fmt.Errorf("%s(%d) failed", a.mallocFn, len(req))
+	}
+	if !mod.Memory().Write(reqPtr, req) {
+		return nil, fmt.Errorf("request of %d bytes at %d out of range of memory size %d", len(req), reqPtr, mod.Memory().Size())
+	}
+
+	results, err = mod.ExportedFunction(a.handleFn).Call(ctx, uint64(reqPtr), uint64(len(req)))
+	if err != nil {
+		return nil, err
+	}
+	if _, err = free.Call(ctx, uint64(reqPtr)); err != nil {
+		return nil, err
+	}
+
+	resPtr, resLen := uint32(results[0]>>32), uint32(results[0])
+	b, ok := mod.Memory().Read(resPtr, resLen)
+	if !ok {
+		return nil, fmt.Errorf("response of %d bytes at %d out of range of memory size %d", resLen, resPtr, mod.Memory().Size())
+	}
+	res := append([]byte(nil), b...) // copy before freeing
+	if _, err = free.Call(ctx, uint64(resPtr)); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// WASIHTTP is an ABI for guests written for the incoming-handler of
+// wasi-http, which is defined in WIT for the component model. As wazero
+// doesn't implement it, this uses wasi_snapshot_preview1 conventions instead,
+// like imports/wasi_http: the guest exports "handle" with no parameters or
+// results, which reads the request from stdin and writes the response to
+// stdout. Instantiate wasi_snapshot_preview1 first.
+//
+// Stderr isn't redirected, so the guest can log to the stderr of the
+// ModuleConfig.
+//
+// See https://github.com/WebAssembly/wasi-http
+func WASIHTTP() ABI {
+	return wasiHTTPABI{}
+}
+
+// wasiHTTPHandle is the name of the function exported by WASIHTTP guests.
+const wasiHTTPHandle = "handle"
+
+type wasiHTTPABI struct{}
+
+// validate implements ABI.validate
+func (wasiHTTPABI) validate(compiled wazero.CompiledModule) error {
+	return validateExports(compiled, export{name: wasiHTTPHandle})
+}
+
+// configure implements ABI.configure
+func (wasiHTTPABI) configure(config wazero.ModuleConfig, inst *instance) wazero.ModuleConfig {
+	return config.WithStdin(&inst.stdin).WithStdout(&inst.stdout)
+}
+
+// handle implements ABI.handle
+func (wasiHTTPABI) handle(ctx context.Context, inst *instance, req []byte) ([]byte, error) {
+	inst.stdin.Reset(req)
+	inst.stdout.Reset()
+	if _, err := inst.mod.ExportedFunction(wasiHTTPHandle).Call(ctx); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), inst.stdout.Bytes()...), nil // copy as stdout is reused
+}
+
+// export is the expected signature of an exported function.
+type export struct {
+	name            string
+	params, results []api.ValueType
+}
+
+// validateExports returns an error unless compiled exports each function with
+// the expected signature.
+func validateExports(compiled wazero.CompiledModule, expected ...export) error {
+	exports := compiled.ExportedFunctions()
+	for _, e := range expected {
+		def, ok := exports[e.name]
+		if !ok {
+			return fmt.Errorf("module[%s] doesn't export function[%s]", compiled.Name(), e.name)
+		}
+		if !bytes.Equal(def.ParamTypes(), e.params) || !bytes.Equal(def.ResultTypes(), e.results) {
+			return fmt.Errorf("module[%s] function[%s] has signature %v => %v, expected %v => %v",
+				compiled.Name(), e.name, typeNames(def.ParamTypes()), typeNames(def.ResultTypes()),
+				typeNames(e.params), typeNames(e.results))
+		}
+	}
+	return nil
+}
+
+func typeNames(types []api.ValueType) []string {
+	names := make([]string, 0, len(types))
+	for _, t := range types {
+		names = append(names, api.ValueTypeName(t))
+	}
+	return names
+}
+
+// readResponse parses the response of the guest in HTTP/1.1 format.
+func readResponse(b []byte, req *http.Request) (*http.Response, error) {
+	res, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(b)), req)
+	if err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	return res, nil
+}
+
+// writeRequest returns the request in HTTP/1.1 format.
+func writeRequest(req *http.Request) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := req.Write(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,284 @@
+// Package wazerohttp serves HTTP requests with a guest, reusing a pool of
+// its instances, so that each request doesn't pay for instantiation.
+//
+// For example, this serves requests with a TinyGo guest compiled with
+// -buildmode=c-shared, which exports "handle", "malloc" and "free":
+//
+//	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCloseOnContextDone(true))
+//	wasi_snapshot_preview1.MustInstantiate(ctx, r)
+//	compiled, _ := r.CompileModule(ctx, wasm)
+//	h, _ := wazerohttp.NewHandler(r, compiled,
+//		wazerohttp.WithABI(wazerohttp.Exports("handle", "malloc", "free")),
+//		wazerohttp.WithTimeout(5*time.Second))
+//	defer h.Close(ctx)
+//	http.ListenAndServe(":8080", h)
+//
+// An instance handles one request at a time, and is reused by later ones,
+// so guests can keep state, e.g. caches, across requests. Instances aren't
+// reused after they fail, e.g. trap or time out, or once recycled due to
+// WithMaxRequests or WithMaxMemory.
+//
+// To run a new instance of a WASI command per request instead, see
+// experimental/wagi.
+//
+// Note: This package is experimental, so may be changed or deleted at any
+// time.
+package wazerohttp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// DefaultPoolSize is the default maximum count of idle instances.
+const DefaultPoolSize = 16
+
+// functionInitialize is exported by reactors, which don't run main when
+// started.
+const functionInitialize = "_initialize"
+
+// Option configures a Handler, e.g. WithABI.
+type Option func(*Handler)
+
+// WithABI sets the ABI which maps requests and responses to calls of the
+// guest. Defaults to WASIHTTP.
+func WithABI(abi ABI) Option {
+	return func(h *Handler) {
+		h.abi = abi
+	}
+}
+
+// WithModuleConfig sets the config of each instance. Defaults to
+// wazero.NewModuleConfig.
+//
+// The name is overridden, as instances can't share one, and the start
+// functions are overridden with "_initialize" if the guest exports it. The
+// ABI may also override the config, e.g. WASIHTTP overrides stdin and stdout.
+func WithModuleConfig(config wazero.ModuleConfig) Option {
+	return func(h *Handler) {
+		h.config = config
+	}
+}
+
+// WithPoolSize sets the maximum count of idle instances, which are kept to
+// handle later requests. Defaults to DefaultPoolSize.
+//
+// This doesn't limit concurrency: a request when no instance is idle
+// instantiates a new one, which is closed after if the pool is full.
+func WithPoolSize(size int) Option {
+	return func(h *Handler) {
+		h.idle = make(chan *instance, size)
+	}
+}
+
+// WithTimeout cancels the context of the call of each request after the
+// timeout, and responds with 504 Gateway Timeout. Defaults to none.
+//
+// Note: This only interrupts the guest when the runtime was configured with
+// wazero.RuntimeConfig WithCloseOnContextDone, which closes the instance.
+func WithTimeout(timeout time.Duration) Option {
+	return func(h *Handler) {
+		h.timeout = timeout
+	}
+}
+
+// WithMaxRequests recycles an instance after it handled the given count of
+// requests, so that state leaked by the guest, such as memory, doesn't
+// accumulate. Defaults to zero, which is unlimited.
+func WithMaxRequests(requests uint32) Option {
+	return func(h *Handler) {
+		h.maxRequests = requests
+	}
+}
+
+// WithMaxMemory recycles an instance once its memory has grown beyond the
+// given bytes, as memory can't shrink. Defaults to zero, which is unlimited.
+func WithMaxMemory(bytes uint32) Option {
+	return func(h *Handler) {
+		h.maxMemory = bytes
+	}
+}
+
+// Handler is an http.Handler which handles each request with an instance of
+// the guest from a pool. Close it to close the idle instances.
+type Handler struct {
+	ns          wazero.Namespace
+	compiled    wazero.CompiledModule
+	abi         ABI
+	config      wazero.ModuleConfig
+	timeout     time.Duration
+	maxRequests uint32
+	maxMemory   uint32
+
+	// idle are the instances not handling a request.
+	idle chan *instance
+	// instances counts the instances, so that each has a unique name.
+	instances uint64
+
+	// mux protects closed, so that no instance is returned to idle after
+	// Close drained it.
+	mux    sync.RWMutex
+	closed bool
+}
+
+// instance is an instance of the guest in the pool.
+type instance struct {
+	mod api.Module
+	// requests is the count of requests handled.
+	requests uint32
+	// stdin and stdout are the stdio of the guest, for ABIs which use them.
+	stdin  bytes.Reader
+	stdout bytes.Buffer
+}
+
+// NewHandler returns a Handler which instantiates compiled in ns as needed
+// to handle requests, or an error if it doesn't export the functions the ABI
+// calls. ns must have instantiated the imports of compiled, e.g.
+// wasi_snapshot_preview1.
+func NewHandler(ns wazero.Namespace, compiled wazero.CompiledModule, opts ...Option) (*Handler, error) {
+	h := &Handler{
+		ns:       ns,
+		compiled: compiled,
+		abi:      WASIHTTP(),
+		config:   wazero.NewModuleConfig(),
+		idle:     make(chan *instance, DefaultPoolSize),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if err := h.abi.validate(compiled); err != nil {
+		return nil, err
+	}
+	if _, ok := compiled.ExportedFunctions()[functionInitialize]; ok {
+		h.config = h.config.WithStartFunctions(functionInitialize)
+	}
+	return h, nil
+}
+
+// ServeHTTP implements http.Handler ServeHTTP
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+
+	res, err := h.handle(ctx, r)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			http.Error(w, http.StatusText(http.StatusGatewayTimeout), http.StatusGatewayTimeout)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	defer res.Body.Close()
+
+	for k, v := range res.Header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(res.StatusCode)
+	_, _ = io.Copy(w, res.Body)
+}
+
+// handle handles the request with an idle or new instance.
+func (h *Handler) handle(ctx context.Context, r *http.Request) (*http.Response, error) {
+	req, err := writeRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	inst, err := h.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	b, err := h.abi.handle(ctx, inst, req)
+	if err != nil {
+		// The guest may be in an inconsistent state, so don't reuse it.
+		_ = inst.mod.Close(ctx)
+		return nil, err
+	}
+	h.put(ctx, inst)
+	return readResponse(b, r)
+}
+
+// get returns an idle instance, or instantiates a new one.
+func (h *Handler) get(ctx context.Context) (*instance, error) {
+	select {
+	case inst := <-h.idle:
+		return inst, nil
+	default:
+	}
+
+	h.mux.RLock()
+	closed := h.closed
+	h.mux.RUnlock()
+	if closed {
+		return nil, errors.New("handler closed")
+	}
+
+	inst := &instance{}
+	name := h.compiled.Name()
+	if name == "" {
+		name = "wazerohttp"
+	}
+	config := h.abi.configure(h.config, inst).
+		WithName(fmt.Sprintf("%s-%d", name, atomic.AddUint64(&h.instances, 1)))
+	mod, err := h.ns.InstantiateModule(ctx, h.compiled, config)
+	if err != nil {
+		return nil, err
+	}
+	inst.mod = mod
+	return inst, nil
+}
+
+// put returns the instance to the pool, unless recycled or the pool is full,
+// in which case it is closed.
+func (h *Handler) put(ctx context.Context, inst *instance) {
+	inst.requests++
+	recycle := h.maxRequests > 0 && inst.requests >= h.maxRequests
+	if mem := inst.mod.Memory(); mem != nil && h.maxMemory > 0 && mem.Size() > h.maxMemory {
+		recycle = true
+	}
+
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+	if !recycle && !h.closed {
+		select {
+		case h.idle <- inst:
+			return
+		default:
+		}
+	}
+	_ = inst.mod.Close(ctx)
+}
+
+// Close closes the idle instances. Instances handling a request are closed
+// when done, and later requests fail.
+func (h *Handler) Close(ctx context.Context) (err error) {
+	h.mux.Lock()
+	h.closed = true
+	h.mux.Unlock()
+
+	for {
+		select {
+		case inst := <-h.idle:
+			if e := inst.mod.Close(ctx); e != nil && err == nil {
+				err = e
+			}
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package wazerohttp
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/internal/leb128"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	binaryformat "github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+// testCtx is an arbitrary, non-default context. Non-nil also prevents linter errors.
+var testCtx = context.WithValue(context.Background(), struct{}{}, "arbitrary")
+
+// responsePrefix is the status line and headers of the responses of
+// echoWasm, followed by the request as the body.
+const responsePrefix = "HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\n\r\n"
+
+// requestOffset is where the request is read or allocated by echoWasm, which
+// is immediately after responsePrefix, so that the echoed response is
+// contiguous in memory.
+const requestOffset = 1024
+
+// echoWasm is a guest which echoes the request after responsePrefix, with
+// both ABIs:
+//   - WASIHTTP: "handle".
+//   - Exports: "echo", "malloc" and "free". "malloc" always returns
+//     requestOffset, as there is one request at a time.
+//
+// It also exports "spin", with the signature of "echo", which never returns.
+var echoWasm = func() []byte {
+	i32, i64 := wasm.ValueTypeI32, wasm.ValueTypeI64
+	prefixOffset := uint32(requestOffset - len(responsePrefix))
+
+	iovecs := make([]byte, 16)
+	binary.LittleEndian.PutUint32(iovecs, prefixOffset)
+	binary.LittleEndian.PutUint32(iovecs[4:], uint32(len(responsePrefix)))
+	binary.LittleEndian.PutUint32(iovecs[8:], requestOffset)
+	binary.LittleEndian.PutUint32(iovecs[12:], 4096)
+
+	echo := []byte{wasm.OpcodeLocalGet, 1, wasm.OpcodeI64ExtendI32U, wasm.OpcodeI64Const}
+	echo = append(echo, leb128.EncodeInt64(int64(prefixOffset)<<32|int64(len(responsePrefix)))...)
+	echo = append(echo, wasm.OpcodeI64Add, wasm.OpcodeEnd)
+
+	return binaryformat.EncodeModule(&wasm.Module{
+		TypeSection: []*wasm.FunctionType{
+			{Params: []wasm.ValueType{i32, i32, i32, i32}, Results: []wasm.ValueType{i32}},
+			{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+			{Params: []wasm.ValueType{i32}},
+			{},
+			{Params: []wasm.ValueType{i32, i32}, Results: []wasm.ValueType{i64}},
+		},
+		ImportSection: []*wasm.Import{
+			{Module: wasi_snapshot_preview1.ModuleName, Name: "fd_read", Type: wasm.ExternTypeFunc, DescFunc: 0},
+			{Module: wasi_snapshot_preview1.ModuleName, Name: "fd_write", Type: wasm.ExternTypeFunc, DescFunc: 0},
+		},
+		FunctionSection: []wasm.Index{1, 2, 3, 4, 4},
+		MemorySection:   &wasm.Memory{Min: 1},
+		CodeSection: []*wasm.Code{
+			{Body: append(append([]byte{wasm.OpcodeI32Const}, leb128.EncodeInt32(requestOffset)...), wasm.OpcodeEnd)}, // malloc
+			{Body: []byte{wasm.OpcodeEnd}}, // free
+			{Body: []byte{ // handle
+				// fd_write(stdout, iovs=0, iovs_len=1, nwritten=16)
+				wasm.OpcodeI32Const, 1, wasm.OpcodeI32Const, 0, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Const, 16,
+				wasm.OpcodeCall, 1, wasm.OpcodeDrop,
+				// fd_read(stdin, iovs=8, iovs_len=1, nread=20)
+				wasm.OpcodeI32Const, 0, wasm.OpcodeI32Const, 8, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Const, 20,
+				wasm.OpcodeCall, 0, wasm.OpcodeDrop,
+				// set the length of the second iovec to nread
+				wasm.OpcodeI32Const, 12, wasm.OpcodeI32Const, 20, wasm.OpcodeI32Load, 2, 0, wasm.OpcodeI32Store, 2, 0,
+				// fd_write(stdout, iovs=8, iovs_len=1, nwritten=16)
+				wasm.OpcodeI32Const, 1, wasm.OpcodeI32Const, 8, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Const, 16,
+				wasm.OpcodeCall, 1, wasm.OpcodeDrop,
+				// restore the length of the second iovec
+				wasm.OpcodeI32Const, 12, wasm.OpcodeI32Const, 0x80, 0x20, wasm.OpcodeI32Store, 2, 0,
+				wasm.OpcodeEnd,
+			}},
+			{Body: echo},
+			{Body: []byte{wasm.OpcodeLoop, 0x40, wasm.OpcodeBr, 0, wasm.OpcodeEnd, wasm.OpcodeUnreachable, wasm.OpcodeEnd}}, // spin
+		},
+		DataSection: []*wasm.DataSegment{
+			{
+				OffsetExpression: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0}},
+				Init:             iovecs,
+			},
+			{
+				OffsetExpression: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: leb128.EncodeInt32(int32(prefixOffset))},
+				Init:             []byte(responsePrefix),
+			},
+		},
+		ExportSection: []*wasm.Export{
+			{Name: "memory", Type: wasm.ExternTypeMemory, Index: 0},
+			{Name: "malloc", Type: wasm.ExternTypeFunc, Index: 2},
+			{Name: "free", Type: wasm.ExternTypeFunc, Index: 3},
+			{Name: "handle", Type: wasm.ExternTypeFunc, Index: 4},
+			{Name: "echo", Type: wasm.ExternTypeFunc, Index: 5},
+			{Name: "spin", Type: wasm.ExternTypeFunc, Index: 6},
+		},
+		NameSection: &wasm.NameSection{ModuleName: "echo"},
+	})
+}()
+
+// newHandler returns a Handler of echoWasm in a new runtime.
+func newHandler(t *testing.T, opts ...Option) (wazero.Runtime, *Handler) {
+	r := wazero.NewRuntimeWithConfig(testCtx, wazero.NewRuntimeConfig().WithCloseOnContextDone(true))
+	t.Cleanup(func() { _ = r.Close(testCtx) })
+
+	wasi_snapshot_preview1.MustInstantiate(testCtx, r)
+	compiled, err := r.CompileModule(testCtx, echoWasm)
+	require.NoError(t, err)
+
+	h, err := NewHandler(r, compiled, opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = h.Close(testCtx) })
+	return r, h
+}
+
+// post posts the body to h, returning the status and body of the response.
+func post(t *testing.T, h http.Handler, body string) (int, string) {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/echo?name=wazero", strings.NewReader(body)))
+	res := w.Result()
+	b, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	return res.StatusCode, string(b)
+}
+
+func TestHandler_ABI(t *testing.T) {
+	tests := []struct {
+		name string
+		abi  ABI
+	}{
+		{name: "WASIHTTP", abi: WASIHTTP()},
+		{name: "Exports", abi: Exports("echo", "malloc", "free")},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			r, h := newHandler(t, WithABI(tc.abi))
+
+			// The same instance handles both requests.
+			for _, body := range []string{"hello", "world"} {
+				status, res := post(t, h, body)
+				require.Equal(t, http.StatusOK, status)
+				require.True(t, strings.HasPrefix(res, "POST /echo?name=wazero HTTP/1.1\r\n"), res)
+				require.True(t, strings.HasSuffix(res, "\r\n\r\n"+body), res)
+			}
+			require.NotNil(t, r.Module("echo-1"))
+			require.Nil(t, r.Module("echo-2"))
+		})
+	}
+}
+
+func TestHandler_Recycle(t *testing.T) {
+	t.Run("WithMaxRequests", func(t *testing.T) {
+		r, h := newHandler(t, WithMaxRequests(2))
+
+		for i := 0; i < 3; i++ {
+			status, _ := post(t, h, "hello")
+			require.Equal(t, http.StatusOK, status)
+		}
+		require.Nil(t, r.Module("echo-1")) // recycled after two requests
+		require.NotNil(t, r.Module("echo-2"))
+	})
+
+	t.Run("WithMaxMemory", func(t *testing.T) {
+		r, h := newHandler(t, WithMaxMemory(wasm.MemoryPageSize-1))
+
+		status, _ := post(t, h, "hello")
+		require.Equal(t, http.StatusOK, status)
+		require.Nil(t, r.Module("echo-1")) // recycled as memory is one page
+	})
+
+	t.Run("WithPoolSize", func(t *testing.T) {
+		r, h := newHandler(t, WithPoolSize(0))
+
+		status, _ := post(t, h, "hello")
+		require.Equal(t, http.StatusOK, status)
+		require.Nil(t, r.Module("echo-1")) // closed as the pool is full
+	})
+}
+
+func TestHandler_Timeout(t *testing.T) {
+	r, h := newHandler(t, WithABI(Exports("spin", "malloc", "free")), WithTimeout(10*time.Millisecond))
+
+	status, body := post(t, h, "hello")
+	require.Equal(t, http.StatusGatewayTimeout, status)
+	require.Equal(t, "Gateway Timeout\n", body)
+	require.Nil(t, r.Module("echo-1")) // not reused after it failed
+}
+
+func TestHandler_Close(t *testing.T) {
+	r, h := newHandler(t)
+
+	status, _ := post(t, h, "hello")
+	require.Equal(t, http.StatusOK, status)
+	require.NotNil(t, r.Module("echo-1"))
+
+	require.NoError(t, h.Close(testCtx))
+	require.Nil(t, r.Module("echo-1"))
+
+	status, body := post(t, h, "hello")
+	require.Equal(t, http.StatusInternalServerError, status)
+	require.Equal(t, "handler closed\n", body)
+}
+
+func TestNewHandler_Errors(t *testing.T) {
+	r := wazero.NewRuntime(testCtx)
+	defer r.Close(testCtx)
+
+	compiled, err := r.CompileModule(testCtx, echoWasm)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name, expectedErr string
+		abi               ABI
+	}{
+		{
+			name:        "missing export",
+			abi:         Exports("handle_request", "malloc", "free"),
+			expectedErr: "module[echo] doesn't export function[handle_request]",
+		},
+		{
+			name:        "wrong signature",
+			abi:         Exports("handle", "malloc", "free"),
+			expectedErr: "module[echo] function[handle] has signature [] => [], expected [i32 i32] => [i64]",
+		},
+		{
+			name:        "wrong allocator signature",
+			abi:         Exports("echo", "free", "free"),
+			expectedErr: "module[echo] function[free] has signature [i32] => [], expected [i32] => [i32]",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewHandler(r, compiled, WithABI(tc.abi))
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package wazero
+
+import (
+	"io/fs"
+
+	"github.com/tetratelabs/wazero/internal/sysfs"
+)
+
+// FSConfig configures the file systems a module can access, each mounted at
+// a guest path, with the default implementation as NewFSConfig. Paths
+// outside any mount don't exist.
+//
+// The example below mounts a host directory at "/" read-only, and another at
+// "/tmp" writable, like `wasmtime --mapdir`:
+//
+//	fsConfig := wazero.NewFSConfig().
+//		WithReadOnlyDirMount("/work/appA", "/").
+//		WithDirMount("/tmp/appA", "/tmp")
+//	config := wazero.NewModuleConfig().WithFSConfig(fsConfig)
+//
+// # Guest paths
+//
+// Guest paths are absolute or relative to "/", so "tmp" is the same as
+// "/tmp". Invalid paths, such as those containing "..", fail on
+// instantiation. When the same guest path is mounted more than once, the
+// last mount wins.
+//
+// Mounts can be nested, e.g. "/" and "/tmp", in which case the longest guest
+// path including a file wins. Directories leading to a mount point which
+// don't otherwise exist, such as "/var" when only "/var/log" is mounted, are
+// read-only and only include their mount points. A mount point can't be
+// removed or renamed, and files can't be renamed or linked across mounts.
+//
+// Note: FSConfig is immutable. Each WithXXX function returns a new instance
+// including the corresponding change.
+type FSConfig interface {
+	// WithDirMount mounts the host directory dir at guestPath, writable.
+	//
+	// Unlike os.DirFS, symbolic links in dir can't be followed outside it:
+	// they are resolved relative to dir, so an absolute target is under it,
+	// and a target which escapes it with ".." doesn't exist. Use
+	// experimental.VerifyDirMountConfinement to check this on a host.
+	WithDirMount(dir, guestPath string) FSConfig
+
+	// WithReadOnlyDirMount is like WithDirMount, except writes fail with
+	// syscall.EROFS.
+	WithReadOnlyDirMount(dir, guestPath string) FSConfig
+
+	// WithFSMount mounts fs at guestPath. As with ModuleConfig.WithFS, fs is
+	// only writable if it implements methods like OpenFile and Mkdir.
+	WithFSMount(fs fs.FS, guestPath string) FSConfig
+}
+
+type fsConfig struct {
+	// mounts are in the order configured.
+	mounts []sysfs.Mount
+}
+
+// NewFSConfig returns a FSConfig with no mounts.
+func NewFSConfig() FSConfig {
+	return &fsConfig{}
+}
+
+// clone makes a deep copy of this fs config.
+func (c *fsConfig) clone() *fsConfig {
+	ret := *c // copy except slices which share a ref
+	ret.mounts = make([]sysfs.Mount, 0, len(c.mounts)+1)
+	ret.mounts = append(ret.mounts, c.mounts...)
+	return &ret
+}
+
+// WithDirMount implements FSConfig.WithDirMount
+func (c *fsConfig) WithDirMount(dir, guestPath string) FSConfig {
+	return c.withMount(sysfs.NewDirFS(dir), guestPath, false)
+}
+
+// WithReadOnlyDirMount implements FSConfig.WithReadOnlyDirMount
+func (c *fsConfig) WithReadOnlyDirMount(dir, guestPath string) FSConfig {
+	return c.withMount(sysfs.NewDirFS(dir), guestPath, true)
+}
+
+// WithFSMount implements FSConfig.WithFSMount
+func (c *fsConfig) WithFSMount(fs fs.FS, guestPath string) FSConfig {
+	return c.withMount(fs, guestPath, false)
+}
+
+func (c *fsConfig) withMount(fs fs.FS, guestPath string, readOnly bool) FSConfig {
+	ret := c.clone()
+	ret.mounts = append(ret.mounts, sysfs.Mount{GuestPath: guestPath, FS: fs, ReadOnly: readOnly})
+	return ret
+}
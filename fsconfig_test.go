@@ -0,0 +1,75 @@
+package wazero
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/sysfs"
+	testfs "github.com/tetratelabs/wazero/internal/testing/fs"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+// TestFSConfig only tests the cases that change the inputs to sysfs.NewRootFS.
+func TestFSConfig(t *testing.T) {
+	base := NewFSConfig()
+	testFS := testfs.FS{}
+
+	tests := []struct {
+		name     string
+		input    FSConfig
+		expected []sysfs.Mount
+	}{
+		{
+			name:  "empty",
+			input: base,
+		},
+		{
+			name:     "WithDirMount",
+			input:    base.WithDirMount(".", "/"),
+			expected: []sysfs.Mount{{GuestPath: "/", FS: sysfs.NewDirFS(".")}},
+		},
+		{
+			name:     "WithReadOnlyDirMount",
+			input:    base.WithReadOnlyDirMount(".", "/"),
+			expected: []sysfs.Mount{{GuestPath: "/", FS: sysfs.NewDirFS("."), ReadOnly: true}},
+		},
+		{
+			name:     "WithFSMount",
+			input:    base.WithFSMount(testFS, "/tmp"),
+			expected: []sysfs.Mount{{GuestPath: "/tmp", FS: testFS}},
+		},
+		{
+			name:  "multiple mounts retain order",
+			input: base.WithReadOnlyDirMount(".", "/").WithFSMount(testFS, "/tmp"),
+			expected: []sysfs.Mount{
+				{GuestPath: "/", FS: sysfs.NewDirFS("."), ReadOnly: true},
+				{GuestPath: "/tmp", FS: testFS},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			mounts := tc.input.(*fsConfig).mounts
+			if len(tc.expected) == 0 {
+				require.Equal(t, 0, len(mounts))
+			} else {
+				require.Equal(t, tc.expected, mounts)
+			}
+		})
+	}
+
+	// The receiver wasn't modified.
+	require.Equal(t, 0, len(base.(*fsConfig).mounts))
+}
+
+func TestFSConfig_clone(t *testing.T) {
+	fc := NewFSConfig().WithDirMount(".", "/").(*fsConfig)
+	cloned := fc.clone()
+
+	// Appending to the clone doesn't affect the original.
+	cloned.mounts = append(cloned.mounts, sysfs.Mount{GuestPath: "/tmp"})
+	require.Equal(t, 1, len(fc.mounts))
+	require.Equal(t, 2, len(cloned.mounts))
+}
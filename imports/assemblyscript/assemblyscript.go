@@ -7,8 +7,10 @@
 // Note: Sometimes only "abort" is imported.
 //
 //   - "abort" - exits with 255 with an abort message written to
-//     wazero.ModuleConfig WithStderr.
-//   - "trace" - no output unless.
+//     wazero.ModuleConfig WithStderr, or FunctionExporter
+//     WithAbortMessageWriter.
+//   - "trace" - no output unless configured with FunctionExporter, e.g.
+//     WithTraceToStdout.
 //   - "seed" - uses wazero.ModuleConfig WithRandSource as the source of seed
 //     values.
 //
@@ -91,6 +93,22 @@ type FunctionExporter interface {
 	// appropriate to use WithTraceToStdout instead.
 	WithTraceToStderr() FunctionExporter
 
+	// WithAbortMessageWriter configures the AssemblyScript abort function to
+	// write messages to the given writer, instead of Stderr as configured by
+	// wazero.ModuleConfig WithStderr.
+	//
+	// For example, this collects abort messages of all modules in a log:
+	//
+	//	assemblyscript.NewFunctionExporter().
+	//		WithAbortMessageWriter(log.Writer()).
+	//		ExportFunctions(envBuilder)
+	WithAbortMessageWriter(io.Writer) FunctionExporter
+
+	// WithTraceWriter configures the AssemblyScript trace function to output
+	// messages to the given writer, instead of a stream configured by
+	// wazero.ModuleConfig.
+	WithTraceWriter(io.Writer) FunctionExporter
+
 	// ExportFunctions builds functions to export with a wazero.HostModuleBuilder
 	// named "env".
 	ExportFunctions(wazero.HostModuleBuilder)
@@ -120,6 +138,22 @@ func (e *functionExporter) WithTraceToStderr() FunctionExporter {
 	return &functionExporter{abortFn: e.abortFn, traceFn: traceStderr}
 }
 
+// WithAbortMessageWriter implements FunctionExporter.WithAbortMessageWriter
+func (e *functionExporter) WithAbortMessageWriter(w io.Writer) FunctionExporter {
+	abortFn := abortMessageEnabled.WithGoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+		abortWithMessageTo(ctx, mod, stack, w)
+	})
+	return &functionExporter{abortFn: abortFn, traceFn: e.traceFn}
+}
+
+// WithTraceWriter implements FunctionExporter.WithTraceWriter
+func (e *functionExporter) WithTraceWriter(w io.Writer) FunctionExporter {
+	traceFn := traceStdout.WithGoModuleFunc(func(_ context.Context, mod api.Module, stack []uint64) {
+		traceTo(mod, stack, w)
+	})
+	return &functionExporter{abortFn: e.abortFn, traceFn: traceFn}
+}
+
 // ExportFunctions implements FunctionExporter.ExportFunctions
 func (e *functionExporter) ExportFunctions(builder wazero.HostModuleBuilder) {
 	exporter := builder.(wasm.HostFuncExporter)
@@ -157,6 +191,11 @@ var abortMessageDisabled = abortMessageEnabled.WithGoModuleFunc(abort)
 // abortWithMessage implements functionAbort
 func abortWithMessage(ctx context.Context, mod api.Module, stack []uint64) {
 	fsc := mod.(*wasm.CallContext).Sys.FS()
+	abortWithMessageTo(ctx, mod, stack, fsc.FdWriter(internalsys.FdStderr))
+}
+
+// abortWithMessageTo implements functionAbort, writing the message to w.
+func abortWithMessageTo(ctx context.Context, mod api.Module, stack []uint64, w io.Writer) {
 	mem := mod.Memory()
 
 	message := uint32(stack[0])
@@ -165,10 +204,9 @@ func abortWithMessage(ctx context.Context, mod api.Module, stack []uint64) {
 	columnNumber := uint32(stack[3])
 
 	// Don't panic if there was a problem reading the message
-	stderr := fsc.FdWriter(internalsys.FdStderr)
 	if msg, msgOk := readAssemblyScriptString(mem, message); msgOk {
 		if fn, fnOk := readAssemblyScriptString(mem, fileName); fnOk {
-			_, _ = fmt.Fprintf(stderr, "%s at %s:%d:%d\n", msg, fn, lineNumber, columnNumber)
+			_, _ = fmt.Fprintf(w, "%s at %s:%d:%d\n", msg, fn, lineNumber, columnNumber)
 		}
 	}
 	abort(ctx, mod, stack)
@@ -61,6 +61,7 @@ func TestAbort(t *testing.T) {
 			require.Equal(t, uint32(255), sysErr.ExitCode())
 			require.Equal(t, `
 ==> env.~lib/builtins/abort(message=4,fileName=22,lineNumber=1,columnNumber=2)
+<== error: module "internal/testing/proxy/proxy.go" closed with exit_code(255)
 `, "\n"+log.String())
 
 			require.Equal(t, tc.expected, stderr.String())
@@ -68,6 +69,24 @@ func TestAbort(t *testing.T) {
 	}
 }
 
+func TestAbort_messageWriter(t *testing.T) {
+	var stderr, w bytes.Buffer
+	exporter := NewFunctionExporter().WithAbortMessageWriter(&w)
+	mod, r, _ := requireProxyModule(t, exporter, wazero.NewModuleConfig().WithStderr(&stderr))
+	defer r.Close(testCtx)
+
+	messageOff, filenameOff := writeAbortMessageAndFileName(t, mod.Memory(), encodeUTF16("message"), encodeUTF16("filename"))
+
+	_, err := mod.ExportedFunction(functionAbort).
+		Call(testCtx, uint64(messageOff), uint64(filenameOff), uint64(1), uint64(2))
+	sysErr, ok := err.(*sys.ExitError)
+	require.True(t, ok, err)
+	require.Equal(t, uint32(255), sysErr.ExitCode())
+
+	require.Equal(t, "message at filename:1:2\n", w.String())
+	require.Equal(t, "", stderr.String())
+}
+
 func TestAbort_Error(t *testing.T) {
 	var stderr bytes.Buffer
 	mod, r, log := requireProxyModule(t, NewFunctionExporter(), wazero.NewModuleConfig().WithStderr(&stderr))
@@ -85,6 +104,7 @@ func TestAbort_Error(t *testing.T) {
 			fileNameUTF16: encodeUTF16("filename"),
 			expectedLog: `
 ==> env.~lib/builtins/abort(message=4,fileName=13,lineNumber=1,columnNumber=2)
+<== error: module "internal/testing/proxy/proxy.go" closed with exit_code(255)
 `,
 		},
 		{
@@ -93,6 +113,7 @@ func TestAbort_Error(t *testing.T) {
 			fileNameUTF16: encodeUTF16("filename")[:5],
 			expectedLog: `
 ==> env.~lib/builtins/abort(message=4,fileName=22,lineNumber=1,columnNumber=2)
+<== error: module "internal/testing/proxy/proxy.go" closed with exit_code(255)
 `,
 		},
 	}
@@ -138,6 +159,7 @@ func TestSeed_error(t *testing.T) {
 		name        string
 		source      io.Reader
 		expectedErr string
+		expectedLog string
 	}{
 		{
 			name:   "not 8 bytes",
@@ -146,6 +168,10 @@ func TestSeed_error(t *testing.T) {
 wasm stack trace:
 	env.~lib/builtins/seed() f64
 	internal/testing/proxy/proxy.go.seed() f64`,
+			expectedLog: `
+==> env.~lib/builtins/seed()
+<== error: error reading random seed: unexpected EOF (recovered by wazero)
+`,
 		},
 		{
 			name:   "error reading",
@@ -154,6 +180,10 @@ wasm stack trace:
 wasm stack trace:
 	env.~lib/builtins/seed() f64
 	internal/testing/proxy/proxy.go.seed() f64`,
+			expectedLog: `
+==> env.~lib/builtins/seed()
+<== error: error reading random seed: ice cream (recovered by wazero)
+`,
 		},
 	}
 
@@ -166,15 +196,14 @@ wasm stack trace:
 
 			_, err := mod.ExportedFunction(functionSeed).Call(testCtx)
 			require.EqualError(t, err, tc.expectedErr)
-			require.Equal(t, `
-==> env.~lib/builtins/seed()
-`, "\n"+log.String())
+			require.Equal(t, tc.expectedLog, "\n"+log.String())
 		})
 	}
 }
 
 // TestFunctionExporter_Trace ensures the trace output is according to configuration.
 func TestFunctionExporter_Trace(t *testing.T) {
+	var traceWriter bytes.Buffer
 	noArgs := []uint64{4, 0, 0, 0, 0, 0, 0}
 	noArgsLog := `
 ==> env.~lib/builtins/trace(message=4,nArgs=0,arg0=0,arg1=0,arg2=0,arg3=0,arg4=0)
@@ -211,6 +240,13 @@ func TestFunctionExporter_Trace(t *testing.T) {
 			expected:    "trace: hello\n",
 			expectedLog: noArgsLog,
 		},
+		{
+			name:        "ToWriter",
+			exporter:    NewFunctionExporter().WithTraceWriter(&traceWriter),
+			params:      noArgs,
+			expected:    "trace: hello\n",
+			expectedLog: noArgsLog,
+		},
 		{
 			name:     "ToStdout - one arg",
 			exporter: NewFunctionExporter().WithTraceToStdout(),
@@ -295,6 +331,10 @@ func TestFunctionExporter_Trace(t *testing.T) {
 
 			_, err := mod.ExportedFunction(functionTrace).Call(testCtx, tc.params...)
 			require.NoError(t, err)
+			if tc.name == "ToWriter" {
+				require.Equal(t, "", out.String())
+				out = traceWriter
+			}
 			require.Equal(t, tc.expected, out.String())
 			require.Equal(t, tc.expectedLog, "\n"+log.String())
 		})
@@ -320,6 +360,27 @@ func Test_readAssemblyScriptString(t *testing.T) {
 			expected:   "hello",
 			expectedOk: true,
 		},
+		{
+			name: "surrogate pair",
+			memory: func(memory api.Memory) {
+				b := encodeUTF16("hi 👋")
+				memory.WriteUint32Le(0, uint32(len(b)))
+				memory.Write(4, b)
+			},
+			offset:     4,
+			expected:   "hi 👋",
+			expectedOk: true,
+		},
+		{
+			name: "unpaired surrogate",
+			memory: func(memory api.Memory) {
+				memory.WriteUint32Le(0, 4)
+				memory.Write(4, []byte{'h', 0, 0x3d, 0xd8}) // "h" then a high surrogate
+			},
+			offset:     4,
+			expected:   "h\uFFFD",
+			expectedOk: true,
+		},
 		{
 			name: "can't read size",
 			memory: func(memory api.Memory) {
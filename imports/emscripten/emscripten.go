@@ -1,8 +1,16 @@
 // Package emscripten contains Go-defined special functions imported by
 // Emscripten under the module name "env".
 //
-// Emscripten has many imports which are triggered on build flags. Use
-// FunctionExporter, instead of Instantiate, to define more "env" functions.
+// Emscripten has many imports which are triggered on build flags. This
+// includes the common ones emitted without `-s STANDALONE_WASM`, such as
+// "abort", "emscripten_resize_heap" and file descriptor syscalls like
+// "__syscall_openat". Use FunctionExporter, instead of Instantiate, to define
+// more "env" functions.
+//
+// Emscripten also imports an `invoke_` function per signature called in a
+// try/catch block or via setjmp. Instantiate defines those with only i32
+// parameters and results. Use NewFunctionExporterForModule to define those
+// imported by a specific module, regardless of signature.
 //
 // # Relationship to WASI
 //
@@ -14,10 +22,14 @@ package emscripten
 
 import (
 	"context"
+	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/tetratelabs/wazero"
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasmruntime"
 )
 
 // MustInstantiate calls Instantiate or panics on error.
@@ -58,11 +70,54 @@ func NewFunctionExporter() FunctionExporter {
 	return &functionExporter{}
 }
 
-type functionExporter struct{}
+// NewFunctionExporterForModule returns a FunctionExporter, which also exports
+// an `invoke_` function for each imported by the guest, e.g.
+// "invoke_vijf". The signature of each is that of the import, so that
+// signatures with i64, f32 or f64 parameters or results work.
+//
+//	guest, _ := r.CompileModule(ctx, wasm)
+//	exporter, _ := emscripten.NewFunctionExporterForModule(guest)
+//	env := r.NewHostModuleBuilder("env")
+//	exporter.ExportFunctions(env)
+//	_, _ = env.Instantiate(ctx, r)
+//
+// This returns an error if an `invoke_` function imported by the guest
+// doesn't have an i32 "index" as its first parameter.
+func NewFunctionExporterForModule(guest wazero.CompiledModule) (FunctionExporter, error) {
+	var invokeFns []*wasm.HostFunc
+	for _, fn := range guest.ImportedFunctions() {
+		moduleName, name, _ := fn.Import()
+		if moduleName != "env" || !strings.HasPrefix(name, invokePrefix) {
+			continue
+		}
+		params := fn.ParamTypes()
+		if len(params) == 0 || params[0] != i32 {
+			return nil, fmt.Errorf("%s.%s: first parameter must be an i32 index", moduleName, name)
+		}
+		invokeFns = append(invokeFns, newInvokeFunc(name, params, fn.ResultTypes()))
+	}
+	return &functionExporter{invokeFns: invokeFns}, nil
+}
+
+type functionExporter struct {
+	// invokeFns are `invoke_` functions generated for a specific guest.
+	invokeFns []*wasm.HostFunc
+}
 
 // ExportFunctions implements FunctionExporter.ExportFunctions
-func (functionExporter) ExportFunctions(builder wazero.HostModuleBuilder) {
+func (e *functionExporter) ExportFunctions(builder wazero.HostModuleBuilder) {
 	exporter := builder.(wasm.HostFuncExporter)
+	exporter.ExportHostFunc(abort)
+	exporter.ExportHostFunc(abortJS)
+	exporter.ExportHostFunc(assertFail)
+	exporter.ExportHostFunc(memcpyBig)
+	exporter.ExportHostFunc(memcpyJS)
+	exporter.ExportHostFunc(resizeHeap)
+	exporter.ExportHostFunc(dateNow)
+	exporter.ExportHostFunc(getNow)
+	exporter.ExportHostFunc(syscallOpenat)
+	exporter.ExportHostFunc(syscallFcntl64)
+	exporter.ExportHostFunc(syscallIoctl)
 	exporter.ExportHostFunc(notifyMemoryGrowth)
 	exporter.ExportHostFunc(invokeI)
 	exporter.ExportHostFunc(invokeIi)
@@ -74,6 +129,9 @@ func (functionExporter) ExportFunctions(builder wazero.HostModuleBuilder) {
 	exporter.ExportHostFunc(invokeVii)
 	exporter.ExportHostFunc(invokeViii)
 	exporter.ExportHostFunc(invokeViiii)
+	for _, fn := range e.invokeFns {
+		exporter.ExportHostFunc(fn) // overrides any of the same name above
+	}
 }
 
 // emscriptenNotifyMemoryGrowth is called when wasm is compiled with
@@ -121,6 +179,8 @@ var notifyMemoryGrowth = &wasm.HostFunc{
 const (
 	i32 = wasm.ValueTypeI32
 
+	invokePrefix = "invoke_"
+
 	functionInvokeI     = "invoke_i"
 	functionInvokeIi    = "invoke_ii"
 	functionInvokeIii   = "invoke_iii"
@@ -349,3 +409,50 @@ func callDynamic(ctx context.Context, callCtx *wasm.CallContext, typeID wasm.Fun
 	}
 	return callCtx.Function(idx).Call(ctx, params...)
 }
+
+// newInvokeFunc returns an `invoke_` function of the given signature, where
+// the first parameter is the table index and the remaining are parameters of
+// the funcref, which has the given results.
+func newInvokeFunc(name string, params, results []api.ValueType) *wasm.HostFunc {
+	paramNames := make([]string, len(params))
+	paramNames[0] = "index"
+	for i := 1; i < len(params); i++ {
+		paramNames[i] = "a" + strconv.Itoa(i)
+	}
+
+	funcParams := params[1:]
+	return &wasm.HostFunc{
+		ExportNames: []string{name},
+		Name:        name,
+		ParamTypes:  params,
+		ParamNames:  paramNames,
+		ResultTypes: results,
+		Code: &wasm.Code{
+			IsHostFunction: true,
+			GoFunc: api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+				callCtx := mod.(*wasm.CallContext)
+				typeID, ok := functionTypeID(callCtx.Module(), funcParams, results)
+				if !ok {
+					panic(wasmruntime.ErrRuntimeIndirectCallTypeMismatch)
+				}
+				ret, err := callDynamic(ctx, callCtx, typeID, wasm.Index(stack[0]), stack[1:len(params)])
+				if err != nil {
+					panic(err)
+				}
+				copy(stack, ret)
+			}),
+		},
+	}
+}
+
+// functionTypeID returns the FunctionTypeID of the given signature in the
+// module, or false if no function in it has that signature. In the latter
+// case, no funcref in its table can either.
+func functionTypeID(m *wasm.ModuleInstance, params, results []api.ValueType) (wasm.FunctionTypeID, bool) {
+	for i := range m.Functions {
+		if f := &m.Functions[i]; f.Type.EqualsSignature(params, results) {
+			return f.TypeID, true
+		}
+	}
+	return 0, false
+}
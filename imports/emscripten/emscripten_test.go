@@ -7,10 +7,13 @@ import (
 	"testing"
 
 	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
 	. "github.com/tetratelabs/wazero/experimental"
 	"github.com/tetratelabs/wazero/experimental/logging"
 	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
 	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
 	"github.com/tetratelabs/wazero/sys"
 )
 
@@ -231,3 +234,108 @@ func TestInvoke(t *testing.T) {
 		})
 	}
 }
+
+// invokeJjdWasm imports "invoke_jjd", which has i64 and f64 parameters, and
+// calls it via its exported function "call_jjd" with a table offset of the
+// function "jd_j" or "unreachable".
+var invokeJjdWasm = binary.EncodeModule(&wasm.Module{
+	TypeSection: []*wasm.FunctionType{
+		{Params: []wasm.ValueType{i32, wasm.ValueTypeI64, f64}, Results: []wasm.ValueType{wasm.ValueTypeI64}},
+		{Params: []wasm.ValueType{wasm.ValueTypeI64, f64}, Results: []wasm.ValueType{wasm.ValueTypeI64}},
+	},
+	ImportSection: []*wasm.Import{
+		{Module: "env", Name: "invoke_jjd", Type: wasm.ExternTypeFunc, DescFunc: 0},
+	},
+	FunctionSection: []wasm.Index{1, 1, 0},
+	CodeSection: []*wasm.Code{
+		{Body: []byte{ // jd_j: a1 + int64(a2)
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeLocalGet, 1,
+			wasm.OpcodeI64TruncF64S,
+			wasm.OpcodeI64Add,
+			wasm.OpcodeEnd,
+		}},
+		{Body: []byte{wasm.OpcodeUnreachable, wasm.OpcodeEnd}},
+		{Body: []byte{ // call_jjd
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeLocalGet, 1,
+			wasm.OpcodeLocalGet, 2,
+			wasm.OpcodeCall, 0,
+			wasm.OpcodeEnd,
+		}},
+	},
+	TableSection: []*wasm.Table{{Min: 2, Type: wasm.RefTypeFuncref}},
+	ElementSection: []*wasm.ElementSegment{{
+		OffsetExpr: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0}},
+		Init:       []*wasm.Index{uint32Ptr(1), uint32Ptr(2)},
+		Type:       wasm.RefTypeFuncref,
+	}},
+	ExportSection: []*wasm.Export{{Name: "call_jjd", Type: wasm.ExternTypeFunc, Index: 3}},
+	NameSection: &wasm.NameSection{
+		FunctionNames: wasm.NameMap{
+			{Index: 1, Name: "jd_j"},
+			{Index: 2, Name: "unreachable"},
+			{Index: 3, Name: "call_jjd"},
+		},
+	},
+})
+
+func uint32Ptr(v uint32) *uint32 {
+	return &v
+}
+
+func TestNewFunctionExporterForModule(t *testing.T) {
+	var log bytes.Buffer
+
+	// Set context to one that has an experimental listener
+	ctx := context.WithValue(testCtx, FunctionListenerFactoryKey{}, logging.NewLoggingListenerFactory(&log))
+
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+
+	guest, err := r.CompileModule(ctx, invokeJjdWasm)
+	require.NoError(t, err)
+
+	exporter, err := NewFunctionExporterForModule(guest)
+	require.NoError(t, err)
+	env := r.NewHostModuleBuilder("env")
+	exporter.ExportFunctions(env)
+	_, err = env.Instantiate(ctx, r)
+	require.NoError(t, err)
+
+	mod, err := r.InstantiateModule(ctx, guest, wazero.NewModuleConfig())
+	require.NoError(t, err)
+
+	results, err := mod.ExportedFunction("call_jjd").Call(ctx, 0, 40, api.EncodeF64(2.5))
+	require.NoError(t, err)
+	require.Equal(t, []uint64{42}, results)
+	require.Equal(t, `--> .call_jjd(0,40,2.5)
+	==> env.invoke_jjd(index=0,a1=40,a2=2.5)
+		--> .jd_j(40,2.5)
+		<-- 42
+	<== 42
+<-- 42
+`, log.String())
+
+	// We expect an unreachable function to err
+	_, err = mod.ExportedFunction("call_jjd").Call(ctx, 1, 40, api.EncodeF64(2.5))
+	require.Contains(t, err.Error(), "unreachable")
+}
+
+func TestNewFunctionExporterForModule_Errors(t *testing.T) {
+	r := wazero.NewRuntime(testCtx)
+	defer r.Close(testCtx)
+
+	guest, err := r.CompileModule(testCtx, binary.EncodeModule(&wasm.Module{
+		TypeSection: []*wasm.FunctionType{{}},
+		ImportSection: []*wasm.Import{
+			{Module: "env", Name: "invoke_v", Type: wasm.ExternTypeFunc, DescFunc: 0},
+		},
+		FunctionSection: []wasm.Index{0},
+		CodeSection:     []*wasm.Code{{Body: []byte{wasm.OpcodeEnd}}},
+	}))
+	require.NoError(t, err)
+
+	_, err = NewFunctionExporterForModule(guest)
+	require.EqualError(t, err, "env.invoke_v: first parameter must be an i32 index")
+}
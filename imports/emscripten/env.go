@@ -0,0 +1,205 @@
+package emscripten
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+const (
+	f64 = wasm.ValueTypeF64
+
+	functionAbort      = "abort"
+	functionAbortJS    = "_abort_js"
+	functionAssertFail = "__assert_fail"
+	functionMemcpyBig  = "emscripten_memcpy_big"
+	functionMemcpyJS   = "emscripten_memcpy_js"
+	functionResizeHeap = "emscripten_resize_heap"
+	functionDateNow    = "emscripten_date_now"
+	functionGetNow     = "emscripten_get_now"
+)
+
+// errAbort is the error of a trap raised by abort.
+var errAbort = errors.New("abort")
+
+// abort is called when the guest calls `abort` in C, e.g. on an unhandled
+// C++ exception. This traps, the same as `Aborted()` in JavaScript.
+//
+//	(import "env" "abort" (func $abort))
+var abort = &wasm.HostFunc{
+	ExportNames: []string{functionAbort},
+	Name:        functionAbort,
+	Code: &wasm.Code{
+		IsHostFunction: true,
+		GoFunc:         api.GoModuleFunc(abortFn),
+	},
+}
+
+// abortJS is the name of abort in newer versions of Emscripten.
+var abortJS = &wasm.HostFunc{
+	ExportNames: []string{functionAbortJS},
+	Name:        functionAbortJS,
+	Code: &wasm.Code{
+		IsHostFunction: true,
+		GoFunc:         api.GoModuleFunc(abortFn),
+	},
+}
+
+func abortFn(context.Context, api.Module, []uint64) {
+	panic(errAbort)
+}
+
+// assertFail is called when an `assert` in C fails. This traps with the
+// failed condition and its location.
+//
+//	(import "env" "__assert_fail" (func $__assert_fail
+//	  (param $condition i32) (param $filename i32) (param $line i32) (param $func i32)))
+var assertFail = &wasm.HostFunc{
+	ExportNames: []string{functionAssertFail},
+	Name:        functionAssertFail,
+	ParamTypes:  []api.ValueType{i32, i32, i32, i32},
+	ParamNames:  []string{"condition", "filename", "line", "func"},
+	Code: &wasm.Code{
+		IsHostFunction: true,
+		GoFunc:         api.GoModuleFunc(assertFailFn),
+	},
+}
+
+func assertFailFn(_ context.Context, mod api.Module, stack []uint64) {
+	mem := mod.Memory()
+	condition := readCString(mem, uint32(stack[0]))
+	filename := readCString(mem, uint32(stack[1]))
+	line := int32(stack[2])
+	fn := readCString(mem, uint32(stack[3]))
+	panic(fmt.Errorf("assertion failed: %s, at: %s:%d (%s)", condition, filename, line, fn))
+}
+
+// readCString reads a NUL-terminated string at offset, or returns "?" if
+// offset is out of memory.
+func readCString(mem api.Memory, offset uint32) string {
+	buf, ok := mem.Read(offset, mem.Size()-offset)
+	if !ok {
+		return "?"
+	}
+	if i := bytes.IndexByte(buf, 0); i >= 0 {
+		buf = buf[:i]
+	}
+	return string(buf)
+}
+
+// memcpyBig copies `num` bytes of memory from `src` to `dest`, when Emscripten
+// decides a large copy is faster on the host. This traps if either range is
+// out of memory.
+//
+//	(import "env" "emscripten_memcpy_big" (func $emscripten_memcpy_big
+//	  (param $dest i32) (param $src i32) (param $num i32)))
+var memcpyBig = &wasm.HostFunc{
+	ExportNames: []string{functionMemcpyBig},
+	Name:        functionMemcpyBig,
+	ParamTypes:  []api.ValueType{i32, i32, i32},
+	ParamNames:  []string{"dest", "src", "num"},
+	Code: &wasm.Code{
+		IsHostFunction: true,
+		GoFunc:         api.GoModuleFunc(memcpyFn),
+	},
+}
+
+// memcpyJS is the name of memcpyBig in newer versions of Emscripten.
+var memcpyJS = &wasm.HostFunc{
+	ExportNames: []string{functionMemcpyJS},
+	Name:        functionMemcpyJS,
+	ParamTypes:  []api.ValueType{i32, i32, i32},
+	ParamNames:  []string{"dest", "src", "num"},
+	Code: &wasm.Code{
+		IsHostFunction: true,
+		GoFunc:         api.GoModuleFunc(memcpyFn),
+	},
+}
+
+func memcpyFn(_ context.Context, mod api.Module, stack []uint64) {
+	mem := mod.Memory()
+	dest, src, num := uint32(stack[0]), uint32(stack[1]), uint32(stack[2])
+	srcBuf, ok := mem.Read(src, num)
+	if !ok {
+		panic(fmt.Errorf("out of memory reading %d bytes at %d", num, src))
+	}
+	if !mem.Write(dest, srcBuf) { // Write uses copy, so overlap is ok.
+		panic(fmt.Errorf("out of memory writing %d bytes at %d", num, dest))
+	}
+}
+
+// resizeHeap grows memory to at least `requested_size` bytes, returning 1 on
+// success or 0 on failure, e.g. when `-s MAXIMUM_MEMORY` would be exceeded.
+// This is called by `malloc` when `-s ALLOW_MEMORY_GROWTH` is set.
+//
+//	(import "env" "emscripten_resize_heap" (func $emscripten_resize_heap
+//	  (param $requested_size i32) (result i32)))
+var resizeHeap = &wasm.HostFunc{
+	ExportNames: []string{functionResizeHeap},
+	Name:        functionResizeHeap,
+	ParamTypes:  []api.ValueType{i32},
+	ParamNames:  []string{"requested_size"},
+	ResultTypes: []api.ValueType{i32},
+	Code: &wasm.Code{
+		IsHostFunction: true,
+		GoFunc:         api.GoModuleFunc(resizeHeapFn),
+	},
+}
+
+func resizeHeapFn(ctx context.Context, mod api.Module, stack []uint64) {
+	mem := mod.Memory()
+	requestedSize := uint64(uint32(stack[0]))
+	size := uint64(mem.Size())
+
+	stack[0] = 1 // success
+	if requestedSize <= size {
+		return
+	}
+	const pageSize = 65536
+	deltaPages := (requestedSize - size + pageSize - 1) / pageSize
+	if _, ok := mem.Grow(uint32(deltaPages)); !ok {
+		stack[0] = 0 // failure
+	}
+}
+
+// dateNow returns the wall clock time in milliseconds since the epoch, which
+// backs `time` and `gettimeofday` in C.
+//
+//	(import "env" "emscripten_date_now" (func $emscripten_date_now (result f64)))
+var dateNow = &wasm.HostFunc{
+	ExportNames: []string{functionDateNow},
+	Name:        functionDateNow,
+	ResultTypes: []api.ValueType{f64},
+	Code: &wasm.Code{
+		IsHostFunction: true,
+		GoFunc:         api.GoModuleFunc(dateNowFn),
+	},
+}
+
+func dateNowFn(_ context.Context, mod api.Module, stack []uint64) {
+	sec, nsec := mod.(*wasm.CallContext).Sys.Walltime()
+	stack[0] = api.EncodeF64(float64(sec)*1e3 + float64(nsec)/1e6)
+}
+
+// getNow returns the monotonic clock time in milliseconds, which backs
+// `clock_gettime(CLOCK_MONOTONIC)` in C.
+//
+//	(import "env" "emscripten_get_now" (func $emscripten_get_now (result f64)))
+var getNow = &wasm.HostFunc{
+	ExportNames: []string{functionGetNow},
+	Name:        functionGetNow,
+	ResultTypes: []api.ValueType{f64},
+	Code: &wasm.Code{
+		IsHostFunction: true,
+		GoFunc:         api.GoModuleFunc(getNowFn),
+	},
+}
+
+func getNowFn(_ context.Context, mod api.Module, stack []uint64) {
+	nanos := mod.(*wasm.CallContext).Sys.Nanotime()
+	stack[0] = api.EncodeF64(float64(nanos) / 1e6)
+}
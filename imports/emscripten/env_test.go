@@ -0,0 +1,129 @@
+package emscripten
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/testing/proxy"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+// requireProxyModule instantiates the "env" module and a proxy module which
+// exports its functions and a memory.
+func requireProxyModule(t *testing.T, config wazero.ModuleConfig) (api.Module, api.Closer, *bytes.Buffer) {
+	var log bytes.Buffer
+
+	// Set context to one that has an experimental listener
+	ctx := context.WithValue(testCtx, experimental.FunctionListenerFactoryKey{}, proxy.NewLoggingListenerFactory(&log))
+
+	r := wazero.NewRuntime(ctx)
+
+	builder := r.NewHostModuleBuilder("env")
+	NewFunctionExporter().ExportFunctions(builder)
+	compiled, err := builder.Compile(ctx)
+	require.NoError(t, err)
+
+	_, err = r.InstantiateModule(ctx, compiled, wazero.NewModuleConfig())
+	require.NoError(t, err)
+
+	proxyCompiled, err := r.CompileModule(ctx, proxy.NewModuleBinary("env", compiled))
+	require.NoError(t, err)
+
+	mod, err := r.InstantiateModule(ctx, proxyCompiled, config)
+	require.NoError(t, err)
+
+	return mod, r, &log
+}
+
+func Test_abort(t *testing.T) {
+	mod, r, _ := requireProxyModule(t, wazero.NewModuleConfig())
+	defer r.Close(testCtx)
+
+	for _, name := range []string{functionAbort, functionAbortJS} {
+		_, err := mod.ExportedFunction(name).Call(testCtx)
+		require.Contains(t, err.Error(), "abort")
+	}
+}
+
+func Test_assertFail(t *testing.T) {
+	mod, r, _ := requireProxyModule(t, wazero.NewModuleConfig())
+	defer r.Close(testCtx)
+
+	mem := mod.Memory()
+	require.True(t, mem.Write(0, []byte("x > 0\x00")))
+	require.True(t, mem.Write(16, []byte("main.c\x00")))
+	require.True(t, mem.Write(32, []byte("main\x00")))
+
+	_, err := mod.ExportedFunction(functionAssertFail).Call(testCtx, 0, 16, 12, 32)
+	require.Contains(t, err.Error(), "assertion failed: x > 0, at: main.c:12 (main)")
+}
+
+func Test_memcpy(t *testing.T) {
+	mod, r, log := requireProxyModule(t, wazero.NewModuleConfig())
+	defer r.Close(testCtx)
+
+	mem := mod.Memory()
+	for _, name := range []string{functionMemcpyBig, functionMemcpyJS} {
+		log.Reset()
+		require.True(t, mem.Write(0, []byte("wazero\x00\x00\x00\x00\x00\x00")))
+
+		_, err := mod.ExportedFunction(name).Call(testCtx, 6, 0, 6)
+		require.NoError(t, err)
+		buf, ok := mem.Read(0, 12)
+		require.True(t, ok)
+		require.Equal(t, "wazerowazero", string(buf))
+		require.Equal(t, `
+==> env.`+name+`(dest=6,src=0,num=6)
+<==
+`, "\n"+log.String())
+
+		_, err = mod.ExportedFunction(name).Call(testCtx, 0, uint64(mem.Size()), 6)
+		require.Contains(t, err.Error(), "out of memory")
+	}
+}
+
+func Test_resizeHeap(t *testing.T) {
+	mod, r, _ := requireProxyModule(t, wazero.NewModuleConfig())
+	defer r.Close(testCtx)
+
+	mem := mod.Memory()
+	require.Equal(t, uint32(65536), mem.Size())
+
+	// No growth is needed when the memory is already large enough.
+	results, err := mod.ExportedFunction(functionResizeHeap).Call(testCtx, 100)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1}, results)
+	require.Equal(t, uint32(65536), mem.Size())
+
+	// Growth is rounded up to the next page.
+	results, err = mod.ExportedFunction(functionResizeHeap).Call(testCtx, 65536*2+1)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1}, results)
+	require.Equal(t, uint32(65536*3), mem.Size())
+}
+
+func Test_clocks(t *testing.T) {
+	mod, r, log := requireProxyModule(t, wazero.NewModuleConfig().
+		WithWalltime(func() (int64, int32) { return 1640995200, 500000000 }, 1).
+		WithNanotime(func() int64 { return 1500000 }, 1))
+	defer r.Close(testCtx)
+
+	results, err := mod.ExportedFunction(functionDateNow).Call(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, 1640995200500.0, api.DecodeF64(results[0]))
+
+	results, err = mod.ExportedFunction(functionGetNow).Call(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, 1.5, api.DecodeF64(results[0]))
+
+	require.Equal(t, `
+==> env.emscripten_date_now()
+<== 1.6409952005e+12
+==> env.emscripten_get_now()
+<== 1.5
+`, "\n"+log.String())
+}
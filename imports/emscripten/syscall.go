@@ -0,0 +1,340 @@
+package emscripten
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"syscall"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/internal/platform"
+	internalsys "github.com/tetratelabs/wazero/internal/sys"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// Emscripten implements libc syscalls, which aren't in WASI, as functions
+// named "__syscall_" followed by the syscall name. Each returns zero or a
+// positive result on success, or a negated errno on failure. Emscripten's
+// libc uses the same errno values as WASI, e.g. -44 is -ENOENT.
+//
+// Variadic arguments are passed as an offset to them in memory, "varargs".
+//
+// See https://github.com/emscripten-core/emscripten/blob/3.1.16/src/library_syscall.js
+const (
+	functionSyscallOpenat  = "__syscall_openat"
+	functionSyscallFcntl64 = "__syscall_fcntl64"
+	functionSyscallIoctl   = "__syscall_ioctl"
+)
+
+// Flags of open in Emscripten's libc, which are the same as musl's generic
+// values.
+//
+// See https://github.com/emscripten-core/emscripten/blob/3.1.16/system/lib/libc/musl/arch/emscripten/bits/fcntl.h
+const (
+	oAccmode   = 03
+	oWronly    = 01
+	oRdwr      = 02
+	oCreat     = 0o100
+	oExcl      = 0o200
+	oTrunc     = 0o1000
+	oAppend    = 0o2000
+	oNonblock  = 0o4000
+	oDirectory = 0o200000
+)
+
+// atFdcwd is the dirfd which means the current working directory, which is
+// the root of the FS.
+const atFdcwd = -100
+
+// syscallOpenat opens the file at `path`, relative to the directory `dirfd`,
+// and returns its file descriptor. When `flags` includes O_CREAT, the mode is
+// read from varargs.
+//
+//	(import "env" "__syscall_openat" (func $__syscall_openat
+//	  (param $dirfd i32) (param $path i32) (param $flags i32) (param $varargs i32)
+//	  (result i32)))
+var syscallOpenat = &wasm.HostFunc{
+	ExportNames: []string{functionSyscallOpenat},
+	Name:        functionSyscallOpenat,
+	ParamTypes:  []api.ValueType{i32, i32, i32, i32},
+	ParamNames:  []string{"dirfd", "path", "flags", "varargs"},
+	ResultTypes: []api.ValueType{i32},
+	Code: &wasm.Code{
+		IsHostFunction: true,
+		GoFunc:         api.GoModuleFunc(syscallOpenatFn),
+	},
+}
+
+func syscallOpenatFn(_ context.Context, mod api.Module, stack []uint64) {
+	mem := mod.Memory()
+	fsc := mod.(*wasm.CallContext).Sys.FS()
+
+	dirfd := int32(stack[0])
+	pathName := readCString(mem, uint32(stack[1]))
+	flags := uint32(stack[2])
+	varargs := uint32(stack[3])
+
+	dir := "/"
+	if dirfd != atFdcwd && !path.IsAbs(pathName) {
+		if f, ok := fsc.OpenedFile(uint32(dirfd)); !ok {
+			stack[0] = syscallResult(0, wasi_snapshot_preview1.ErrnoBadf)
+			return
+		} else if _, ok := f.File.(fs.ReadDirFile); !ok {
+			stack[0] = syscallResult(0, wasi_snapshot_preview1.ErrnoNotdir)
+			return
+		} else {
+			dir = f.Name
+		}
+	}
+	pathName = path.Join(dir, pathName)
+
+	var flag int
+	switch flags & oAccmode {
+	case oWronly:
+		flag = os.O_WRONLY
+	case oRdwr:
+		flag = os.O_RDWR
+	}
+	var perm fs.FileMode
+	if flags&oCreat != 0 {
+		flag |= os.O_CREATE
+		if flags&oExcl != 0 {
+			flag |= os.O_EXCL
+		}
+		mode, ok := mem.ReadUint32Le(varargs)
+		if !ok {
+			stack[0] = syscallResult(0, wasi_snapshot_preview1.ErrnoFault)
+			return
+		}
+		perm = fs.FileMode(mode) & fs.ModePerm
+	}
+	if flags&oTrunc != 0 {
+		flag |= os.O_TRUNC
+	}
+	if flags&oAppend != 0 {
+		flag |= os.O_APPEND
+	}
+	if flags&oNonblock != 0 {
+		flag |= platform.O_NONBLOCK
+	}
+
+	fd, err := fsc.OpenFile(pathName, flag, perm)
+	if err != nil {
+		stack[0] = syscallResult(0, syscallErrno(err))
+		return
+	}
+	if flags&oDirectory != 0 {
+		if f, _ := fsc.OpenedFile(fd); !isDir(f) {
+			_ = fsc.CloseFile(fd)
+			stack[0] = syscallResult(0, wasi_snapshot_preview1.ErrnoNotdir)
+			return
+		}
+	}
+	stack[0] = syscallResult(fd, wasi_snapshot_preview1.ErrnoSuccess)
+}
+
+func isDir(f *internalsys.FileEntry) bool {
+	_, ok := f.File.(fs.ReadDirFile)
+	return ok
+}
+
+// Commands of fcntl in Emscripten's libc.
+const (
+	fDupfd  = 0
+	fGetfd  = 1
+	fSetfd  = 2
+	fGetfl  = 3
+	fSetfl  = 4
+	fGetlk  = 5
+	fSetlk  = 6
+	fSetlkw = 7
+)
+
+// syscallFcntl64 gets or sets the flags of file descriptor `fd`. Only
+// O_APPEND and O_NONBLOCK can be set. Locks always succeed, as files aren't
+// shared with other processes, and F_DUPFD isn't supported.
+//
+//	(import "env" "__syscall_fcntl64" (func $__syscall_fcntl64
+//	  (param $fd i32) (param $cmd i32) (param $varargs i32) (result i32)))
+var syscallFcntl64 = &wasm.HostFunc{
+	ExportNames: []string{functionSyscallFcntl64},
+	Name:        functionSyscallFcntl64,
+	ParamTypes:  []api.ValueType{i32, i32, i32},
+	ParamNames:  []string{"fd", "cmd", "varargs"},
+	ResultTypes: []api.ValueType{i32},
+	Code: &wasm.Code{
+		IsHostFunction: true,
+		GoFunc:         api.GoModuleFunc(syscallFcntl64Fn),
+	},
+}
+
+func syscallFcntl64Fn(_ context.Context, mod api.Module, stack []uint64) {
+	fsc := mod.(*wasm.CallContext).Sys.FS()
+
+	fd := uint32(stack[0])
+	cmd := uint32(stack[1])
+	varargs := uint32(stack[2])
+
+	f, ok := fsc.OpenedFile(fd)
+	if !ok {
+		stack[0] = syscallResult(0, wasi_snapshot_preview1.ErrnoBadf)
+		return
+	}
+
+	switch cmd {
+	case fGetfd, fSetfd, fGetlk, fSetlk, fSetlkw:
+		stack[0] = syscallResult(0, wasi_snapshot_preview1.ErrnoSuccess)
+	case fGetfl:
+		var flags uint32
+		switch f.Flag & (os.O_RDONLY | os.O_WRONLY | os.O_RDWR) {
+		case os.O_WRONLY:
+			flags = oWronly
+		case os.O_RDWR:
+			flags = oRdwr
+		}
+		if f.Flag&os.O_APPEND != 0 {
+			flags |= oAppend
+		}
+		if f.Flag&platform.O_NONBLOCK != 0 {
+			flags |= oNonblock
+		}
+		stack[0] = syscallResult(flags, wasi_snapshot_preview1.ErrnoSuccess)
+	case fSetfl:
+		arg, ok := mod.Memory().ReadUint32Le(varargs)
+		if !ok {
+			stack[0] = syscallResult(0, wasi_snapshot_preview1.ErrnoFault)
+			return
+		}
+		var flag int
+		if arg&oAppend != 0 {
+			flag |= os.O_APPEND
+		}
+		if arg&oNonblock != 0 {
+			flag |= platform.O_NONBLOCK
+		}
+		stack[0] = syscallResult(0, syscallErrno(fsc.SetFlag(fd, flag)))
+	default: // including fDupfd
+		stack[0] = syscallResult(0, wasi_snapshot_preview1.ErrnoInval)
+	}
+}
+
+// Operations of ioctl in Emscripten's libc which apply to terminals.
+const (
+	tcgets     = 0x5401
+	tcsets     = 0x5402
+	tcsetsw    = 0x5403
+	tcsetsf    = 0x5404
+	tiocgpgrp  = 0x540f
+	tiocspgrp  = 0x5410
+	tiocgwinsz = 0x5413
+	tiocswinsz = 0x5414
+)
+
+// syscallIoctl controls the terminal of file descriptor `fd`. This is mainly
+// used by `isatty`, via TIOCGWINSZ, which writes the terminal size. Other
+// terminal operations succeed without effect. Any operation on a file
+// descriptor which isn't a host terminal fails with ENOTTY.
+//
+//	(import "env" "__syscall_ioctl" (func $__syscall_ioctl
+//	  (param $fd i32) (param $op i32) (param $varargs i32) (result i32)))
+var syscallIoctl = &wasm.HostFunc{
+	ExportNames: []string{functionSyscallIoctl},
+	Name:        functionSyscallIoctl,
+	ParamTypes:  []api.ValueType{i32, i32, i32},
+	ParamNames:  []string{"fd", "op", "varargs"},
+	ResultTypes: []api.ValueType{i32},
+	Code: &wasm.Code{
+		IsHostFunction: true,
+		GoFunc:         api.GoModuleFunc(syscallIoctlFn),
+	},
+}
+
+func syscallIoctlFn(_ context.Context, mod api.Module, stack []uint64) {
+	mem := mod.Memory()
+	fsc := mod.(*wasm.CallContext).Sys.FS()
+
+	fd := uint32(stack[0])
+	op := uint32(stack[1])
+	varargs := uint32(stack[2])
+
+	if _, ok := fsc.OpenedFile(fd); !ok {
+		stack[0] = syscallResult(0, wasi_snapshot_preview1.ErrnoBadf)
+		return
+	} else if !fsc.IsTerminal(fd) {
+		stack[0] = syscallResult(0, wasi_snapshot_preview1.ErrnoNotty)
+		return
+	}
+
+	switch op {
+	case tcgets, tcsets, tcsetsw, tcsetsf, tiocgpgrp, tiocspgrp, tiocswinsz:
+		stack[0] = syscallResult(0, wasi_snapshot_preview1.ErrnoSuccess)
+	case tiocgwinsz:
+		rows, columns, err := fsc.TerminalSize(fd)
+		if err != nil {
+			stack[0] = syscallResult(0, syscallErrno(err))
+			return
+		}
+		// struct winsize is four uint16: rows, columns, then unused pixels.
+		argp, ok := mem.ReadUint32Le(varargs)
+		if !ok || !mem.WriteUint32Le(argp, uint32(rows)|uint32(columns)<<16) ||
+			!mem.WriteUint32Le(argp+4, 0) {
+			stack[0] = syscallResult(0, wasi_snapshot_preview1.ErrnoFault)
+			return
+		}
+		stack[0] = syscallResult(0, wasi_snapshot_preview1.ErrnoSuccess)
+	default:
+		stack[0] = syscallResult(0, wasi_snapshot_preview1.ErrnoInval)
+	}
+}
+
+// syscallResult returns the result of a syscall: ret on success, or the
+// negated errno.
+func syscallResult(ret uint32, errno wasi_snapshot_preview1.Errno) uint64 {
+	if errno != wasi_snapshot_preview1.ErrnoSuccess {
+		return api.EncodeI32(-int32(errno))
+	}
+	return api.EncodeU32(ret)
+}
+
+// syscallErrno converts an error from internalsys.FSContext.
+func syscallErrno(err error) wasi_snapshot_preview1.Errno {
+	if err == nil {
+		return wasi_snapshot_preview1.ErrnoSuccess
+	}
+	switch platform.UnwrapOSError(err) {
+	case syscall.EACCES:
+		return wasi_snapshot_preview1.ErrnoAcces
+	case syscall.EBADF:
+		return wasi_snapshot_preview1.ErrnoBadf
+	case syscall.EEXIST:
+		return wasi_snapshot_preview1.ErrnoExist
+	case syscall.EINVAL:
+		return wasi_snapshot_preview1.ErrnoInval
+	case syscall.EISDIR:
+		return wasi_snapshot_preview1.ErrnoIsdir
+	case syscall.ENOENT:
+		return wasi_snapshot_preview1.ErrnoNoent
+	case syscall.ENOTDIR:
+		return wasi_snapshot_preview1.ErrnoNotdir
+	case syscall.ENOTTY:
+		return wasi_snapshot_preview1.ErrnoNotty
+	case syscall.EPERM:
+		return wasi_snapshot_preview1.ErrnoPerm
+	case syscall.EROFS:
+		return wasi_snapshot_preview1.ErrnoRofs
+	}
+
+	// handle errors of fs.FS, which aren't syscall errors.
+	switch {
+	case errors.Is(err, fs.ErrInvalid):
+		return wasi_snapshot_preview1.ErrnoInval
+	case errors.Is(err, fs.ErrNotExist):
+		return wasi_snapshot_preview1.ErrnoNoent
+	case errors.Is(err, fs.ErrExist):
+		return wasi_snapshot_preview1.ErrnoExist
+	}
+	return wasi_snapshot_preview1.ErrnoIo
+}
@@ -0,0 +1,92 @@
+package emscripten
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+// requireSyscall calls the syscall and requires its result.
+func requireSyscall(t *testing.T, mod api.Module, expected int32, name string, params ...uint64) {
+	results, err := mod.ExportedFunction(name).Call(testCtx, params...)
+	require.NoError(t, err)
+	require.Equal(t, expected, int32(results[0]))
+}
+
+func errnoResult(errno wasi_snapshot_preview1.Errno) int32 {
+	return -int32(errno)
+}
+
+func Test_syscallOpenat(t *testing.T) {
+	testFS := fstest.MapFS{"dir": {Mode: 0o755 | 1<<31}, "dir/file": {Data: []byte("wazero")}}
+	mod, r, log := requireProxyModule(t, wazero.NewModuleConfig().WithFS(testFS))
+	defer r.Close(testCtx)
+
+	mem := mod.Memory()
+	require.True(t, mem.Write(0, []byte("dir/file\x00")))
+	require.True(t, mem.Write(16, []byte("file\x00")))
+	require.True(t, mem.Write(32, []byte("missing\x00")))
+
+	// Relative to the current directory, which is root.
+	requireSyscall(t, mod, 4, functionSyscallOpenat, uint64(api.EncodeI32(atFdcwd)), 0, 0, 0)
+	require.Equal(t, `
+==> env.__syscall_openat(dirfd=-100,path=0,flags=0,varargs=0)
+<== 4
+`, "\n"+log.String())
+
+	// Relative to the pre-opened root.
+	requireSyscall(t, mod, 5, functionSyscallOpenat, 3, 0, 0, 0)
+
+	requireSyscall(t, mod, errnoResult(wasi_snapshot_preview1.ErrnoNoent),
+		functionSyscallOpenat, uint64(api.EncodeI32(atFdcwd)), 32, 0, 0)
+	requireSyscall(t, mod, errnoResult(wasi_snapshot_preview1.ErrnoNotdir),
+		functionSyscallOpenat, uint64(api.EncodeI32(atFdcwd)), 0, oDirectory, 0)
+	requireSyscall(t, mod, errnoResult(wasi_snapshot_preview1.ErrnoNotdir),
+		functionSyscallOpenat, 4, 16, 0, 0) // fd 4 is a file
+	requireSyscall(t, mod, errnoResult(wasi_snapshot_preview1.ErrnoBadf),
+		functionSyscallOpenat, 42, 16, 0, 0)
+	requireSyscall(t, mod, errnoResult(wasi_snapshot_preview1.ErrnoRofs),
+		functionSyscallOpenat, uint64(api.EncodeI32(atFdcwd)), 32, oCreat|oWronly, 48)
+}
+
+func Test_syscallFcntl64(t *testing.T) {
+	testFS := fstest.MapFS{"file": {Data: []byte("wazero")}}
+	mod, r, _ := requireProxyModule(t, wazero.NewModuleConfig().WithFS(testFS))
+	defer r.Close(testCtx)
+
+	mem := mod.Memory()
+	require.True(t, mem.Write(0, []byte("file\x00")))
+	requireSyscall(t, mod, 4, functionSyscallOpenat, uint64(api.EncodeI32(atFdcwd)), 0, 0, 0)
+
+	requireSyscall(t, mod, 0, functionSyscallFcntl64, 4, fGetfd, 0)
+	requireSyscall(t, mod, 0, functionSyscallFcntl64, 4, fSetlk, 0)
+	requireSyscall(t, mod, 0, functionSyscallFcntl64, 4, fGetfl, 0)
+
+	require.True(t, mem.WriteUint32Le(16, oNonblock))
+	requireSyscall(t, mod, 0, functionSyscallFcntl64, 4, fSetfl, 16)
+	requireSyscall(t, mod, oNonblock, functionSyscallFcntl64, 4, fGetfl, 0)
+
+	requireSyscall(t, mod, errnoResult(wasi_snapshot_preview1.ErrnoInval),
+		functionSyscallFcntl64, 4, fDupfd, 0)
+	requireSyscall(t, mod, errnoResult(wasi_snapshot_preview1.ErrnoBadf),
+		functionSyscallFcntl64, 42, fGetfl, 0)
+	requireSyscall(t, mod, errnoResult(wasi_snapshot_preview1.ErrnoFault),
+		functionSyscallFcntl64, 4, fSetfl, uint64(mem.Size()))
+}
+
+func Test_syscallIoctl(t *testing.T) {
+	mod, r, _ := requireProxyModule(t, wazero.NewModuleConfig())
+	defer r.Close(testCtx)
+
+	// stdout is an io.Writer, not a terminal, so isatty is false.
+	requireSyscall(t, mod, errnoResult(wasi_snapshot_preview1.ErrnoNotty),
+		functionSyscallIoctl, 1, tiocgwinsz, 0)
+	requireSyscall(t, mod, errnoResult(wasi_snapshot_preview1.ErrnoNotty),
+		functionSyscallIoctl, 1, tcgets, 0)
+	requireSyscall(t, mod, errnoResult(wasi_snapshot_preview1.ErrnoBadf),
+		functionSyscallIoctl, 42, tiocgwinsz, 0)
+}
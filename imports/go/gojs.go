@@ -19,9 +19,17 @@ import (
 	"github.com/tetratelabs/wazero/internal/wasm"
 )
 
+// ModuleName is the module name `GOARCH=wasm GOOS=js` imports functions from
+// since Go 1.21.
+const ModuleName = "gojs"
+
+// LegacyModuleName is the module name `GOARCH=wasm GOOS=js` imported functions
+// from before Go 1.21. Use FunctionExporter to instantiate it.
+const LegacyModuleName = "go"
+
 // MustInstantiate calls Instantiate or panics on error.
 //
-// This is a simpler function for those who know the module "go" is not
+// This is a simpler function for those who know the module ModuleName is not
 // already instantiated, and don't need to unload it.
 func MustInstantiate(ctx context.Context, r wazero.Runtime) {
 	if _, err := Instantiate(ctx, r); err != nil {
@@ -29,26 +37,28 @@ func MustInstantiate(ctx context.Context, r wazero.Runtime) {
 	}
 }
 
-// Instantiate instantiates the "go" module, used by `GOARCH=wasm GOOS=js`,
-// into the runtime default namespace.
+// Instantiate instantiates the ModuleName module, used by
+// `GOARCH=wasm GOOS=js`, into the runtime default namespace.
 //
 // # Notes
 //
 //   - Failure cases are documented on wazero.Namespace InstantiateModule.
 //   - Closing the wazero.Runtime has the same effect as closing the result.
-//   - To add more functions to the "env" module, use FunctionExporter.
+//   - To add more functions to the ModuleName module, use FunctionExporter.
 //   - To instantiate into another wazero.Namespace, use FunctionExporter.
+//   - To run binaries compiled before Go 1.21, use FunctionExporter with a
+//     wazero.HostModuleBuilder named LegacyModuleName.
 func Instantiate(ctx context.Context, r wazero.Runtime) (api.Closer, error) {
-	builder := r.NewHostModuleBuilder("go")
+	builder := r.NewHostModuleBuilder(ModuleName)
 	NewFunctionExporter().ExportFunctions(builder)
 	return builder.Instantiate(ctx, r)
 }
 
-// FunctionExporter configures the functions in the "go" module used by
+// FunctionExporter configures the functions in the ModuleName module used by
 // `GOARCH=wasm GOOS=js`.
 type FunctionExporter interface {
 	// ExportFunctions builds functions to export with a
-	// wazero.HostModuleBuilder named "go".
+	// wazero.HostModuleBuilder named ModuleName or LegacyModuleName.
 	ExportFunctions(wazero.HostModuleBuilder)
 }
 
@@ -152,6 +162,11 @@ func Run(ctx context.Context, ns wazero.Namespace, compiled wazero.CompiledModul
 		return err
 	}
 	// Invoke the run function.
-	_, err = mod.ExportedFunction("run").Call(ctx, uint64(argc), uint64(argv))
-	return err
+	if _, err = mod.ExportedFunction("run").Call(ctx, uint64(argc), uint64(argv)); err != nil {
+		return err
+	}
+
+	// "run" returns when all goroutines are blocked, so continue until there
+	// are no more timeouts, e.g. from time.Sleep.
+	return RunTimeouts(ctx, mod)
 }
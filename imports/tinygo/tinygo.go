@@ -0,0 +1,239 @@
+// Package tinygo contains helpers for the ABI conventions of guests compiled
+// by TinyGo, e.g. `tinygo build -o X.wasm -target=wasi X.go`.
+//
+// TinyGo guests import WASI, so instantiate wasi_snapshot_preview1 first.
+// This package doesn't define any host functions: it helps call the guest.
+//
+// # Exports
+//
+// TinyGo exports a function defined in Go under the name in its
+// `//export name` comment, not its Go name, e.g. "main.greet", which is only
+// in the name section. Besides "memory", TinyGo exports these functions:
+//
+//   - "malloc", "free", "calloc" and "realloc" - the allocator of the guest,
+//     used to pass strings and byte slices. See Module.AllocString.
+//   - "_start" - runs main, when compiled as a command (the default).
+//   - "_initialize" - initializes the runtime and packages, when compiled
+//     as a reactor (-buildmode=c-shared). main isn't run.
+//
+// Use UserExports to list the exports defined in Go.
+//
+// # Strings and slices
+//
+// Parameters and results of exported functions are numeric, so a string or
+// byte slice is passed as the offset and size of its bytes in memory: two
+// parameters, or one i64 result packed with EncodePtrSize. A string or slice
+// in the memory of the guest, such as a field of a struct, is a header: the
+// offset and length of its bytes as little-endian uint32s, followed by the
+// capacity for a slice. Read them with Module.StringHeader and
+// Module.SliceHeader.
+//
+// See https://tinygo.org/docs/guides/webassembly/
+package tinygo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+const (
+	functionMalloc     = "malloc"
+	functionFree       = "free"
+	functionCalloc     = "calloc"
+	functionRealloc    = "realloc"
+	functionStart      = "_start"
+	functionInitialize = "_initialize"
+)
+
+// runtimeExports are the functions exported by TinyGo itself, as opposed to
+// those exported with `//export name`.
+var runtimeExports = map[string]struct{}{
+	functionMalloc:     {},
+	functionFree:       {},
+	functionCalloc:     {},
+	functionRealloc:    {},
+	functionStart:      {},
+	functionInitialize: {},
+	// Exported when compiled with -scheduler=asyncify.
+	"asyncify_start_unwind": {},
+	"asyncify_stop_unwind":  {},
+	"asyncify_start_rewind": {},
+	"asyncify_stop_rewind":  {},
+	"asyncify_get_state":    {},
+}
+
+// UserExports returns the names of the functions compiled exports with
+// `//export name`, excluding those TinyGo exports itself, in lexicographical
+// order.
+func UserExports(compiled wazero.CompiledModule) []string {
+	var names []string
+	for name := range compiled.ExportedFunctions() {
+		if _, ok := runtimeExports[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Module is a TinyGo guest, with helpers to pass strings and byte slices to
+// its exported functions using its allocator.
+type Module struct {
+	api.Module
+	malloc, free api.Function
+}
+
+// Instantiate instantiates the TinyGo guest compiled in ns, calling the start
+// function TinyGo exports: "_initialize" if compiled as a reactor, or
+// "_start" otherwise, unless the config sets start functions explicitly.
+//
+// An error is returned if the guest exits when started, e.g. a command whose
+// main returns on TinyGo versions which then exit. Compile such guests with
+// -buildmode=c-shared to call their functions after initialization.
+func Instantiate(ctx context.Context, ns wazero.Namespace, compiled wazero.CompiledModule, config wazero.ModuleConfig) (*Module, error) {
+	if _, ok := compiled.ExportedFunctions()[functionInitialize]; ok {
+		config = config.WithStartFunctions(functionInitialize)
+	}
+	mod, err := ns.InstantiateModule(ctx, compiled, config)
+	if exitErr, ok := err.(*sys.ExitError); ok && exitErr.ExitCode() == 0 {
+		return nil, fmt.Errorf("module[%s] exited when started: compile with -buildmode=c-shared to call its functions", exitErr.ModuleName())
+	} else if err != nil {
+		return nil, err
+	}
+	m, err := New(mod)
+	if err != nil {
+		_ = mod.Close(ctx)
+		return nil, err
+	}
+	return m, nil
+}
+
+// New returns a Module for the instantiated TinyGo guest mod, or an error if
+// it doesn't export the allocator.
+func New(mod api.Module) (*Module, error) {
+	m := &Module{Module: mod, malloc: mod.ExportedFunction(functionMalloc), free: mod.ExportedFunction(functionFree)}
+	switch {
+	case m.malloc == nil || m.free == nil:
+		return nil, fmt.Errorf("module[%s] doesn't export %s and %s: not compiled by TinyGo", mod.Name(), functionMalloc, functionFree)
+	case mod.Memory() == nil:
+		return nil, fmt.Errorf("module[%s] doesn't export memory", mod.Name())
+	}
+	return m, nil
+}
+
+// Malloc allocates size bytes in the guest, returning their offset in memory.
+// Call Free when the guest no longer uses them, as TinyGo's garbage collector
+// is unaware of references held by the host.
+func (m *Module) Malloc(ctx context.Context, size uint32) (uint32, error) {
+	results, err := m.malloc.Call(ctx, uint64(size))
+	if err != nil {
+		return 0, err
+	}
+	ptr := uint32(results[0])
+	if ptr == 0 && size != 0 {
+		return 0, fmt.Errorf("malloc(%d) failed", size)
+	}
+	return ptr, nil
+}
+
+// Free frees the bytes at the offset ptr, allocated with Malloc.
+func (m *Module) Free(ctx context.Context, ptr uint32) error {
+	_, err := m.free.Call(ctx, uint64(ptr))
+	return err
+}
+
+// AllocString allocates and writes s in the guest, returning the offset and
+// size of its bytes, e.g. to pass to a function with the parameters
+// (ptr, size uint32). Call Free with the offset when the guest no longer uses
+// it.
+func (m *Module) AllocString(ctx context.Context, s string) (ptr, size uint32, err error) {
+	return m.AllocBytes(ctx, []byte(s))
+}
+
+// AllocBytes is like AllocString, except for a byte slice.
+func (m *Module) AllocBytes(ctx context.Context, b []byte) (ptr, size uint32, err error) {
+	size = uint32(len(b))
+	if ptr, err = m.Malloc(ctx, size); err != nil {
+		return 0, 0, err
+	}
+	if !m.Memory().Write(ptr, b) {
+		_ = m.Free(ctx, ptr)
+		return 0, 0, fmt.Errorf("allocated %d bytes at %d out of range of memory size %d", size, ptr, m.Memory().Size())
+	}
+	return
+}
+
+// String returns a copy of the size bytes at the offset ptr as a string.
+func (m *Module) String(ptr, size uint32) (string, error) {
+	b, err := m.read(ptr, size)
+	return string(b), err
+}
+
+// Bytes returns a copy of the size bytes at the offset ptr.
+func (m *Module) Bytes(ptr, size uint32) ([]byte, error) {
+	b, err := m.read(ptr, size)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), b...), nil
+}
+
+// StringHeader returns a copy of the string whose header is at offset.
+func (m *Module) StringHeader(offset uint32) (string, error) {
+	ptr, size, err := m.header(offset)
+	if err != nil {
+		return "", err
+	}
+	return m.String(ptr, size)
+}
+
+// SliceHeader returns a copy of the bytes of the slice whose header is at
+// offset, up to its length.
+func (m *Module) SliceHeader(offset uint32) ([]byte, error) {
+	ptr, size, err := m.header(offset)
+	if err != nil {
+		return nil, err
+	}
+	return m.Bytes(ptr, size)
+}
+
+// header reads the offset and length of a string or slice header.
+func (m *Module) header(offset uint32) (ptr, size uint32, err error) {
+	var ok bool
+	if ptr, ok = m.Memory().ReadUint32Le(offset); ok {
+		size, ok = m.Memory().ReadUint32Le(offset + 4)
+	}
+	if !ok {
+		err = fmt.Errorf("header at %d out of range of memory size %d", offset, m.Memory().Size())
+	}
+	return
+}
+
+func (m *Module) read(ptr, size uint32) ([]byte, error) {
+	b, ok := m.Memory().Read(ptr, size)
+	if !ok {
+		return nil, fmt.Errorf("Memory.Read(%d, %d) out of range of memory size %d", ptr, size, m.Memory().Size())
+	}
+	return b, nil
+}
+
+// EncodePtrSize packs the offset and size of bytes in memory into one result,
+// as returned by a TinyGo function like:
+//
+//	//export greeting
+//	func greeting() uint64 {
+//		return uint64(uintptr(unsafe.Pointer(&b[0])))<<32 | uint64(len(b))
+//	}
+func EncodePtrSize(ptr, size uint32) uint64 {
+	return uint64(ptr)<<32 | uint64(size)
+}
+
+// DecodePtrSize unpacks a result encoded like EncodePtrSize.
+func DecodePtrSize(ptrSize uint64) (ptr, size uint32) {
+	return uint32(ptrSize >> 32), uint32(ptrSize)
+}
@@ -0,0 +1,231 @@
+package tinygo
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	binaryformat "github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+// testCtx is an arbitrary, non-default context. Non-nil also prevents linter errors.
+var testCtx = context.WithValue(context.Background(), struct{}{}, "arbitrary")
+
+func TestInstantiate(t *testing.T) {
+	// greet.wasm exports "greet" and "greeting", which take a name as the
+	// offset and size of its bytes. "greet" logs the greeting with "env.log",
+	// and "greeting" returns it packed with EncodePtrSize.
+	greetWasm, err := os.ReadFile("../../examples/allocation/tinygo/testdata/greet.wasm")
+	require.NoError(t, err)
+
+	r := wazero.NewRuntime(testCtx)
+	defer r.Close(testCtx)
+
+	var logged string
+	_, err = r.NewHostModuleBuilder("env").
+		NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, ptr, size uint32) {
+		mod, err := New(m)
+		require.NoError(t, err)
+		logged, err = mod.String(ptr, size)
+		require.NoError(t, err)
+	}).Export("log").
+		Instantiate(testCtx, r)
+	require.NoError(t, err)
+	wasi_snapshot_preview1.MustInstantiate(testCtx, r)
+
+	compiled, err := r.CompileModule(testCtx, greetWasm)
+	require.NoError(t, err)
+	require.Equal(t, []string{"greet", "greeting"}, UserExports(compiled))
+
+	mod, err := Instantiate(testCtx, r, compiled, wazero.NewModuleConfig())
+	require.NoError(t, err)
+
+	ptr, size, err := mod.AllocString(testCtx, "wazero")
+	require.NoError(t, err)
+	defer mod.Free(testCtx, ptr)
+
+	_, err = mod.ExportedFunction("greet").Call(testCtx, uint64(ptr), uint64(size))
+	require.NoError(t, err)
+	require.Equal(t, "wasm >> Hello, wazero!", logged)
+
+	results, err := mod.ExportedFunction("greeting").Call(testCtx, uint64(ptr), uint64(size))
+	require.NoError(t, err)
+	greeting, err := mod.String(DecodePtrSize(results[0]))
+	require.NoError(t, err)
+	require.Equal(t, "Hello, wazero!", greeting)
+}
+
+func TestInstantiate_initialize(t *testing.T) {
+	r := wazero.NewRuntime(testCtx)
+	defer r.Close(testCtx)
+
+	wasi_snapshot_preview1.MustInstantiate(testCtx, r)
+
+	// "_initialize" writes 1 at offset 0, and "_start" panics.
+	compiled, err := r.CompileModule(testCtx, guestWasm(
+		[]byte{wasm.OpcodeI32Const, 0, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Store8, 0, 0, wasm.OpcodeEnd},
+		[]byte{wasm.OpcodeUnreachable, wasm.OpcodeEnd},
+	))
+	require.NoError(t, err)
+	require.Equal(t, []string{"greet"}, UserExports(compiled))
+
+	mod, err := Instantiate(testCtx, r, compiled, wazero.NewModuleConfig())
+	require.NoError(t, err)
+	initialized, _ := mod.Memory().ReadByte(0)
+	require.Equal(t, byte(1), initialized)
+}
+
+func TestInstantiate_exited(t *testing.T) {
+	r := wazero.NewRuntime(testCtx)
+	defer r.Close(testCtx)
+
+	wasi_snapshot_preview1.MustInstantiate(testCtx, r)
+
+	// "_start" exits with code zero, as if main returned.
+	compiled, err := r.CompileModule(testCtx, guestWasm(
+		nil,
+		[]byte{wasm.OpcodeI32Const, 0, wasm.OpcodeCall, 0, wasm.OpcodeEnd},
+	))
+	require.NoError(t, err)
+
+	_, err = Instantiate(testCtx, r, compiled, wazero.NewModuleConfig().WithName("exits"))
+	require.EqualError(t, err, "module[exits] exited when started: compile with -buildmode=c-shared to call its functions")
+}
+
+func TestNew_Errors(t *testing.T) {
+	r := wazero.NewRuntime(testCtx)
+	defer r.Close(testCtx)
+
+	mod, err := r.InstantiateModuleFromBinary(testCtx, binaryformat.EncodeModule(&wasm.Module{
+		NameSection: &wasm.NameSection{ModuleName: "rust"},
+	}))
+	require.NoError(t, err)
+
+	_, err = New(mod)
+	require.EqualError(t, err, "module[rust] doesn't export malloc and free: not compiled by TinyGo")
+}
+
+func TestModule_headers(t *testing.T) {
+	r := wazero.NewRuntime(testCtx)
+	defer r.Close(testCtx)
+
+	wasi_snapshot_preview1.MustInstantiate(testCtx, r)
+
+	compiled, err := r.CompileModule(testCtx, guestWasm(nil, []byte{wasm.OpcodeEnd}))
+	require.NoError(t, err)
+	mod, err := Instantiate(testCtx, r, compiled, wazero.NewModuleConfig())
+	require.NoError(t, err)
+
+	// A string header at 16 and a slice header at 24, both of "hello" at 64.
+	mem := mod.Memory()
+	require.True(t, mem.Write(64, []byte("hello")))
+	require.True(t, mem.Write(16, []byte{64, 0, 0, 0, 5, 0, 0, 0}))
+	require.True(t, mem.Write(24, []byte{64, 0, 0, 0, 5, 0, 0, 0, 8, 0, 0, 0}))
+
+	s, err := mod.StringHeader(16)
+	require.NoError(t, err)
+	require.Equal(t, "hello", s)
+
+	b, err := mod.SliceHeader(24)
+	require.NoError(t, err)
+	require.Equal(t, []byte("hello"), b)
+
+	// The result is a copy.
+	b[0] = 'j'
+	s, err = mod.StringHeader(16)
+	require.NoError(t, err)
+	require.Equal(t, "hello", s)
+
+	_, err = mod.StringHeader(mem.Size() - 4)
+	require.EqualError(t, err, "header at 65532 out of range of memory size 65536")
+
+	require.True(t, mem.Write(16, []byte{0xff, 0xff, 0, 0, 5, 0, 0, 0}))
+	_, err = mod.StringHeader(16)
+	require.EqualError(t, err, "Memory.Read(65535, 5) out of range of memory size 65536")
+}
+
+func TestPtrSize(t *testing.T) {
+	ptrSize := EncodePtrSize(1024, 5)
+	require.Equal(t, uint64(1024<<32|5), ptrSize)
+	ptr, size := DecodePtrSize(ptrSize)
+	require.Equal(t, uint32(1024), ptr)
+	require.Equal(t, uint32(5), size)
+}
+
+func TestModule_AllocBytes(t *testing.T) {
+	r := wazero.NewRuntime(testCtx)
+	defer r.Close(testCtx)
+
+	wasi_snapshot_preview1.MustInstantiate(testCtx, r)
+
+	compiled, err := r.CompileModule(testCtx, guestWasm(nil, []byte{wasm.OpcodeEnd}))
+	require.NoError(t, err)
+	mod, err := Instantiate(testCtx, r, compiled, wazero.NewModuleConfig())
+	require.NoError(t, err)
+
+	ptr, size, err := mod.AllocBytes(testCtx, []byte("hello"))
+	require.NoError(t, err)
+	require.Equal(t, uint32(5), size)
+	b, err := mod.Bytes(ptr, size)
+	require.NoError(t, err)
+	require.True(t, bytes.Equal([]byte("hello"), b))
+
+	// malloc returns zero on failure.
+	_, _, err = mod.AllocBytes(testCtx, make([]byte, 2000))
+	require.EqualError(t, err, "malloc(2000) failed")
+}
+
+// guestWasm returns a module exported like a TinyGo guest, with a bump
+// allocator which fails over 1024 bytes, and the given "_initialize" and
+// "_start" bodies. "_initialize" isn't exported when nil. "_start" can call
+// the imported "wasi_snapshot_preview1.proc_exit".
+func guestWasm(initialize, start []byte) []byte {
+	i32 := wasm.ValueTypeI32
+	m := &wasm.Module{
+		TypeSection: []*wasm.FunctionType{
+			{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+			{Params: []wasm.ValueType{i32}},
+			{},
+		},
+		ImportSection: []*wasm.Import{{
+			Module: "wasi_snapshot_preview1", Name: "proc_exit", Type: wasm.ExternTypeFunc, DescFunc: 1,
+		}},
+		FunctionSection: []wasm.Index{0, 1, 2, 2},
+		MemorySection:   &wasm.Memory{Min: 1, Cap: 1, Max: 1, IsMaxEncoded: true},
+		GlobalSection: []*wasm.Global{{
+			Type: &wasm.GlobalType{ValType: i32, Mutable: true},
+			Init: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0x80, 0x8}}, // 1024
+		}},
+		CodeSection: []*wasm.Code{
+			{Body: []byte{ // malloc returns the global, and adds size to it, or zero if over 1024.
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Const, 0x80, 0x8, wasm.OpcodeI32GtU,
+				wasm.OpcodeIf, 0x40, wasm.OpcodeI32Const, 0, wasm.OpcodeReturn, wasm.OpcodeEnd,
+				wasm.OpcodeGlobalGet, 0,
+				wasm.OpcodeGlobalGet, 0, wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Add, wasm.OpcodeGlobalSet, 0,
+				wasm.OpcodeEnd,
+			}},
+			{Body: []byte{wasm.OpcodeEnd}}, // free
+			{Body: start},
+			{Body: []byte{wasm.OpcodeEnd}}, // greet
+		},
+		ExportSection: []*wasm.Export{
+			{Name: "memory", Type: wasm.ExternTypeMemory, Index: 0},
+			{Name: "malloc", Type: wasm.ExternTypeFunc, Index: 1},
+			{Name: "free", Type: wasm.ExternTypeFunc, Index: 2},
+			{Name: "_start", Type: wasm.ExternTypeFunc, Index: 3},
+			{Name: "greet", Type: wasm.ExternTypeFunc, Index: 4},
+		},
+	}
+	if initialize != nil {
+		m.FunctionSection = append(m.FunctionSection, 2)
+		m.CodeSection = append(m.CodeSection, &wasm.Code{Body: initialize})
+		m.ExportSection = append(m.ExportSection, &wasm.Export{Name: "_initialize", Type: wasm.ExternTypeFunc, Index: 5})
+	}
+	return binaryformat.EncodeModule(m)
+}
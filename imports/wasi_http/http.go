@@ -0,0 +1,323 @@
+package wasi_http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// Errno is the same as wasi_snapshot_preview1.Errno, as guests use both
+// modules together.
+type Errno = wasi_snapshot_preview1.Errno
+
+const (
+	requestName         = "request"
+	responseHeadersName = "response_headers"
+)
+
+// errHostNotAllowed is returned by CheckRedirect when a redirect is to a host
+// not allowed by Builder.WithAllowedHosts.
+var errHostNotAllowed = errors.New("host not allowed")
+
+// handler makes requests on behalf of the guest, to allowed hosts only.
+type handler struct {
+	client       *http.Client
+	allowedHosts []string
+}
+
+func newHandler(client *http.Client, allowedHosts []string) *handler {
+	h := &handler{allowedHosts: allowedHosts}
+
+	// Copy the client, so that redirects are checked without affecting the
+	// caller's client.
+	c := *client
+	checkRedirect := client.CheckRedirect
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if !h.isAllowed(req.URL) {
+			return errHostNotAllowed
+		} else if checkRedirect != nil {
+			return checkRedirect(req, via)
+		} else if len(via) >= 10 { // the same as the default policy.
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+	h.client = &c
+	return h
+}
+
+// isAllowed returns true if the host of u matches an allowed host.
+func (h *handler) isAllowed(u *url.URL) bool {
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	for _, allowed := range h.allowedHosts {
+		allowed = strings.ToLower(allowed)
+		if allowed == "*" {
+			return true
+		}
+
+		allowedHost, allowedPort, err := net.SplitHostPort(allowed)
+		if err != nil { // no port
+			allowedHost, allowedPort = strings.Trim(allowed, "[]"), ""
+		}
+		if allowedPort != "" && allowedPort != port {
+			continue
+		}
+
+		if strings.HasPrefix(allowedHost, "*.") {
+			if strings.HasSuffix(host, allowedHost[1:]) {
+				return true
+			}
+		} else if allowedHost == host {
+			return true
+		}
+	}
+	return false
+}
+
+// request returns the function named requestName which makes an HTTP request
+// and waits for the response headers.
+//
+// # Parameters
+//
+//   - method: offset in api.Memory of the method, e.g. "GET"
+//   - methodLen: length of the method, where zero is "GET"
+//   - url: offset in api.Memory of the absolute "http" or "https" URL
+//   - urlLen: length of the URL
+//   - headers: offset in api.Memory of the request headers
+//   - headersLen: length of the request headers
+//   - body: offset in api.Memory of the request body
+//   - bodyLen: length of the request body
+//   - resultStatus: offset to write the status code as a uint32le, e.g. 200
+//   - resultFd: offset to write the file descriptor of the response body
+//
+// Result (Errno)
+//
+// The return value is ErrnoSuccess except the following error conditions:
+//   - ErrnoInval: the method, URL or headers are invalid
+//   - ErrnoAcces: the host, or that of a redirect, isn't allowed
+//   - ErrnoConnrefused: the host refused the connection
+//   - ErrnoTimedout: the client or context timed out
+//   - ErrnoNfile: there are no file descriptors left
+//   - ErrnoFault: a parameter points to an offset out of memory
+//   - ErrnoIo: the request failed
+//
+// The response body is read with "fd_read" and must be closed with
+// "fd_close". Use response_headers to read the response headers.
+//
+// Note: The "Host" header is ignored, as the host is that of the URL.
+func (h *handler) request() *wasm.HostFunc {
+	return newHostFunc(
+		requestName, h.requestFn,
+		[]api.ValueType{i32, i32, i32, i32, i32, i32, i32, i32, i32, i32},
+		"method", "method_len", "url", "url_len", "headers", "headers_len",
+		"body", "body_len", "result.status", "result.fd",
+	)
+}
+
+func (h *handler) requestFn(ctx context.Context, mod api.Module, params []uint64) Errno {
+	mem := mod.Memory()
+	fsc := mod.(*wasm.CallContext).Sys.FS()
+
+	method, ok := mem.Read(uint32(params[0]), uint32(params[1]))
+	if !ok {
+		return wasi_snapshot_preview1.ErrnoFault
+	}
+	rawURL, ok := mem.Read(uint32(params[2]), uint32(params[3]))
+	if !ok {
+		return wasi_snapshot_preview1.ErrnoFault
+	}
+	headersBuf, ok := mem.Read(uint32(params[4]), uint32(params[5]))
+	if !ok {
+		return wasi_snapshot_preview1.ErrnoFault
+	}
+	body, ok := mem.Read(uint32(params[6]), uint32(params[7]))
+	if !ok {
+		return wasi_snapshot_preview1.ErrnoFault
+	}
+	resultStatus := uint32(params[8])
+	resultFd := uint32(params[9])
+
+	u, err := url.Parse(string(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return wasi_snapshot_preview1.ErrnoInval
+	}
+	header, ok := parseHeaders(headersBuf)
+	if !ok {
+		return wasi_snapshot_preview1.ErrnoInval
+	}
+	if !h.isAllowed(u) {
+		return wasi_snapshot_preview1.ErrnoAcces
+	}
+
+	// Copy the body, as memory may change while the request is in flight.
+	req, err := http.NewRequestWithContext(ctx, string(method), u.String(),
+		bytes.NewReader(append([]byte{}, body...)))
+	if err != nil {
+		return wasi_snapshot_preview1.ErrnoInval
+	}
+	req.Header = header
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return httpErrno(err)
+	}
+
+	fd, err := fsc.OpenStream(u.String(), &responseFile{resp})
+	if err != nil {
+		_ = resp.Body.Close()
+		return wasi_snapshot_preview1.ErrnoNfile
+	}
+
+	if !mem.WriteUint32Le(resultStatus, uint32(resp.StatusCode)) ||
+		!mem.WriteUint32Le(resultFd, fd) {
+		_ = fsc.CloseFile(fd)
+		return wasi_snapshot_preview1.ErrnoFault
+	}
+	return wasi_snapshot_preview1.ErrnoSuccess
+}
+
+// parseHeaders parses headers in HTTP/1.1 format, or returns false if they
+// are invalid.
+func parseHeaders(buf []byte) (http.Header, bool) {
+	header := http.Header{}
+	if !utf8.Valid(buf) {
+		return nil, false
+	}
+	for _, line := range strings.Split(string(buf), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if line == "" {
+			continue
+		}
+		i := strings.IndexByte(line, ':')
+		if i <= 0 || strings.ContainsAny(line[:i], " \t") {
+			return nil, false
+		}
+		header.Add(line[:i], strings.TrimSpace(line[i+1:]))
+	}
+	return header, true
+}
+
+// responseHeaders is the function named responseHeadersName which reads the
+// headers of a response.
+//
+// # Parameters
+//
+//   - fd: file descriptor of the response body, returned by request
+//   - buf: offset in api.Memory to write the response headers
+//   - bufLen: maximum length of the response headers to write
+//   - resultLen: offset to write the length of the response headers
+//
+// Result (Errno)
+//
+// The return value is ErrnoSuccess except the following error conditions:
+//   - ErrnoBadf: `fd` is invalid
+//   - ErrnoInval: `fd` isn't a response body
+//   - ErrnoNobufs: `bufLen` is less than the length of the headers, which is
+//     still written to `resultLen`, so that the guest can retry
+//   - ErrnoFault: a parameter points to an offset out of memory
+//
+// The headers are sorted by name, and each line ends in "\r\n".
+var responseHeaders = newHostFunc(
+	responseHeadersName, responseHeadersFn,
+	[]api.ValueType{i32, i32, i32, i32},
+	"fd", "buf", "buf_len", "result.len",
+)
+
+func responseHeadersFn(_ context.Context, mod api.Module, params []uint64) Errno {
+	mem := mod.Memory()
+	fsc := mod.(*wasm.CallContext).Sys.FS()
+
+	fd := uint32(params[0])
+	buf := uint32(params[1])
+	bufLen := uint32(params[2])
+	resultLen := uint32(params[3])
+
+	f, ok := fsc.OpenedFile(fd)
+	if !ok {
+		return wasi_snapshot_preview1.ErrnoBadf
+	}
+	rf, ok := f.File.(*responseFile)
+	if !ok {
+		return wasi_snapshot_preview1.ErrnoInval
+	}
+
+	var header bytes.Buffer
+	_ = rf.resp.Header.Write(&header) // bytes.Buffer never errs.
+
+	if !mem.WriteUint32Le(resultLen, uint32(header.Len())) {
+		return wasi_snapshot_preview1.ErrnoFault
+	} else if bufLen < uint32(header.Len()) {
+		return wasi_snapshot_preview1.ErrnoNobufs
+	} else if !mem.Write(buf, header.Bytes()) {
+		return wasi_snapshot_preview1.ErrnoFault
+	}
+	return wasi_snapshot_preview1.ErrnoSuccess
+}
+
+// responseFile adapts an *http.Response to fs.File, so that its body can be
+// opened as a file descriptor.
+type responseFile struct {
+	resp *http.Response
+}
+
+// Stat implements fs.File
+func (f *responseFile) Stat() (fs.FileInfo, error) { return responseStat{f.resp}, nil }
+
+// Read implements fs.File
+func (f *responseFile) Read(p []byte) (int, error) { return f.resp.Body.Read(p) }
+
+// Close implements fs.File
+func (f *responseFile) Close() error { return f.resp.Body.Close() }
+
+// responseStat is the fs.FileInfo of a response body, which is a stream.
+type responseStat struct {
+	resp *http.Response
+}
+
+func (s responseStat) Name() string       { return "" }
+func (s responseStat) Mode() fs.FileMode  { return fs.ModeNamedPipe }
+func (s responseStat) ModTime() time.Time { return time.Unix(0, 0) }
+func (s responseStat) IsDir() bool        { return false }
+func (s responseStat) Sys() interface{}   { return nil }
+
+// Size returns the Content-Length of the response, or zero if unknown.
+func (s responseStat) Size() int64 {
+	if s.resp.ContentLength < 0 {
+		return 0
+	}
+	return s.resp.ContentLength
+}
+
+// httpErrno converts an error from http.Client Do.
+func httpErrno(err error) Errno {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, errHostNotAllowed):
+		return wasi_snapshot_preview1.ErrnoAcces
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return wasi_snapshot_preview1.ErrnoConnrefused
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return wasi_snapshot_preview1.ErrnoTimedout
+	}
+	return wasi_snapshot_preview1.ErrnoIo
+}
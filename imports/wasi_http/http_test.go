@@ -0,0 +1,250 @@
+package wasi_http
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/internal/testing/proxy"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// testCtx is an arbitrary, non-default context. Non-nil also prevents linter errors.
+var testCtx = context.WithValue(context.Background(), struct{}{}, "arbitrary")
+
+// Arbitrary offsets of request parameters and results used in tests.
+const (
+	methodOffset, urlOffset, headersOffset, bodyOffset = 0, 16, 128, 256
+	resultStatus, resultFd, resultLen, bufOffset       = 512, 516, 520, 1024
+)
+
+// requireProxyModule instantiates ModuleName, configured by the given
+// function, and a proxy module which exports its functions.
+func requireProxyModule(t *testing.T, configure func(Builder) Builder) (api.Module, api.Closer, *bytes.Buffer) {
+	var log bytes.Buffer
+
+	// Set context to one that has an experimental listener
+	ctx := context.WithValue(testCtx, experimental.FunctionListenerFactoryKey{}, proxy.NewLoggingListenerFactory(&log))
+
+	r := wazero.NewRuntime(ctx)
+
+	compiled, err := configure(NewBuilder(r)).(*builder).hostModuleBuilder().Compile(ctx)
+	require.NoError(t, err)
+
+	_, err = r.InstantiateModule(ctx, compiled, wazero.NewModuleConfig())
+	require.NoError(t, err)
+
+	proxyCompiled, err := r.CompileModule(ctx, proxy.NewModuleBinary(ModuleName, compiled))
+	require.NoError(t, err)
+
+	mod, err := r.InstantiateModule(ctx, proxyCompiled, wazero.NewModuleConfig())
+	require.NoError(t, err)
+
+	return mod, r, &log
+}
+
+func requireErrno(t *testing.T, expectedErrno Errno, mod api.Module, funcName string, params ...uint64) {
+	results, err := mod.ExportedFunction(funcName).Call(testCtx, params...)
+	require.NoError(t, err)
+	errno := Errno(results[0])
+	require.Equal(t, expectedErrno, errno, wasi_snapshot_preview1.ErrnoName(errno))
+}
+
+// requireRequest writes the request parameters to memory and calls request.
+func requireRequest(t *testing.T, expectedErrno Errno, mod api.Module, method, url, headers, body string) {
+	mem := mod.Memory()
+	require.True(t, mem.Write(methodOffset, []byte(method)))
+	require.True(t, mem.Write(urlOffset, []byte(url)))
+	require.True(t, mem.Write(headersOffset, []byte(headers)))
+	require.True(t, mem.Write(bodyOffset, []byte(body)))
+	requireErrno(t, expectedErrno, mod, requestName,
+		methodOffset, uint64(len(method)), urlOffset, uint64(len(url)),
+		headersOffset, uint64(len(headers)), bodyOffset, uint64(len(body)),
+		resultStatus, resultFd)
+}
+
+func newEchoServer(t *testing.T) *httptest.Server {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect":
+			http.Redirect(w, r, r.URL.Query().Get("to"), http.StatusFound)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		w.Header().Set("X-Wazero", r.Header.Get("X-Wazero"))
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write(body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func Test_request(t *testing.T) {
+	s := newEchoServer(t)
+
+	mod, r, log := requireProxyModule(t, func(b Builder) Builder {
+		return b.WithClient(s.Client()).WithAllowedHosts("127.0.0.1")
+	})
+	defer r.Close(testCtx)
+
+	requireRequest(t, wasi_snapshot_preview1.ErrnoSuccess, mod,
+		"POST", s.URL+"/echo", "X-Wazero: yes\r\n", "wazero")
+	require.Equal(t, `
+==> wasi_http.request(method=0,method_len=4,url=16,url_len=27,headers=128,headers_len=15,body=256,body_len=6,result.status=512,result.fd=516)
+<== errno=0
+`, "\n"+log.String())
+
+	status, ok := mod.Memory().ReadUint32Le(resultStatus)
+	require.True(t, ok)
+	require.Equal(t, uint32(http.StatusCreated), status)
+	fd, ok := mod.Memory().ReadUint32Le(resultFd)
+	require.True(t, ok)
+
+	// The body is read like any other file descriptor.
+	fsc := mod.(*wasm.CallContext).Sys.FS()
+	body, err := io.ReadAll(fsc.FdReader(fd))
+	require.NoError(t, err)
+	require.Equal(t, "wazero", string(body))
+
+	// The headers are read into a buffer.
+	requireErrno(t, wasi_snapshot_preview1.ErrnoNobufs, mod, responseHeadersName,
+		uint64(fd), bufOffset, 1, resultLen)
+	headersLen, ok := mod.Memory().ReadUint32Le(resultLen)
+	require.True(t, ok)
+	requireErrno(t, wasi_snapshot_preview1.ErrnoSuccess, mod, responseHeadersName,
+		uint64(fd), bufOffset, uint64(headersLen), resultLen)
+	headers, ok := mod.Memory().Read(bufOffset, headersLen)
+	require.True(t, ok)
+	require.Contains(t, string(headers), "X-Method: POST\r\n")
+	require.Contains(t, string(headers), "X-Wazero: yes\r\n")
+
+	require.True(t, fsc.CloseFile(fd))
+	requireErrno(t, wasi_snapshot_preview1.ErrnoBadf, mod, responseHeadersName,
+		uint64(fd), bufOffset, uint64(headersLen), resultLen)
+}
+
+func Test_request_Errors(t *testing.T) {
+	s := newEchoServer(t)
+	u, err := url.Parse(s.URL)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name                string
+		allowedHosts        []string
+		method, url, header string
+		expectedErrno       Errno
+	}{
+		{
+			name:          "no allowed hosts",
+			url:           s.URL,
+			expectedErrno: wasi_snapshot_preview1.ErrnoAcces,
+		},
+		{
+			name:          "host not allowed",
+			allowedHosts:  []string{"localhost"},
+			url:           s.URL,
+			expectedErrno: wasi_snapshot_preview1.ErrnoAcces,
+		},
+		{
+			name:          "port not allowed",
+			allowedHosts:  []string{"127.0.0.1:1"},
+			url:           s.URL,
+			expectedErrno: wasi_snapshot_preview1.ErrnoAcces,
+		},
+		{
+			name:          "redirect not allowed",
+			allowedHosts:  []string{u.Host},
+			url:           s.URL + "/redirect?to=http://localhost:" + u.Port(),
+			expectedErrno: wasi_snapshot_preview1.ErrnoAcces,
+		},
+		{
+			name:          "not absolute",
+			allowedHosts:  []string{"*"},
+			url:           "/echo",
+			expectedErrno: wasi_snapshot_preview1.ErrnoInval,
+		},
+		{
+			name:          "not http",
+			allowedHosts:  []string{"*"},
+			url:           "file:///etc/passwd",
+			expectedErrno: wasi_snapshot_preview1.ErrnoInval,
+		},
+		{
+			name:          "invalid method",
+			allowedHosts:  []string{"*"},
+			method:        "GET /",
+			url:           s.URL,
+			expectedErrno: wasi_snapshot_preview1.ErrnoInval,
+		},
+		{
+			name:          "invalid header",
+			allowedHosts:  []string{"*"},
+			url:           s.URL,
+			header:        "no colon",
+			expectedErrno: wasi_snapshot_preview1.ErrnoInval,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			mod, r, _ := requireProxyModule(t, func(b Builder) Builder {
+				return b.WithClient(s.Client()).WithAllowedHosts(tc.allowedHosts...)
+			})
+			defer r.Close(testCtx)
+
+			requireRequest(t, tc.expectedErrno, mod, tc.method, tc.url, tc.header, "")
+		})
+	}
+}
+
+func Test_responseHeaders_notResponse(t *testing.T) {
+	mod, r, _ := requireProxyModule(t, func(b Builder) Builder { return b })
+	defer r.Close(testCtx)
+
+	// stdin is open, but isn't a response.
+	requireErrno(t, wasi_snapshot_preview1.ErrnoInval, mod, responseHeadersName,
+		0, bufOffset, 0, resultLen)
+}
+
+func TestHandler_isAllowed(t *testing.T) {
+	tests := []struct {
+		allowed  string
+		url      string
+		expected bool
+	}{
+		{allowed: "*", url: "https://wazero.io", expected: true},
+		{allowed: "wazero.io", url: "https://wazero.io", expected: true},
+		{allowed: "wazero.io", url: "http://WAZERO.io:8080/path", expected: true},
+		{allowed: "wazero.io", url: "https://api.wazero.io", expected: false},
+		{allowed: "wazero.io:443", url: "https://wazero.io", expected: true},
+		{allowed: "wazero.io:443", url: "http://wazero.io", expected: false},
+		{allowed: "*.wazero.io", url: "https://api.wazero.io", expected: true},
+		{allowed: "*.wazero.io", url: "https://wazero.io", expected: false},
+		{allowed: "*.wazero.io", url: "https://notwazero.io", expected: false},
+		{allowed: "[::1]:8080", url: "http://[::1]:8080", expected: true},
+		{allowed: "::1", url: "http://[::1]:8080", expected: true},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.allowed+" "+tc.url, func(t *testing.T) {
+			u, err := url.Parse(tc.url)
+			require.NoError(t, err)
+			h := newHandler(http.DefaultClient, []string{tc.allowed})
+			require.Equal(t, tc.expected, h.isAllowed(u))
+		})
+	}
+}
@@ -0,0 +1,170 @@
+// Package wasi_http contains Go-defined functions which allow a guest to make
+// outgoing HTTP requests, as proposed by wasi-http. These are accessible from
+// WebAssembly-defined functions via importing ModuleName.
+//
+// This module is optional, as it grants the guest access to the host network.
+// Requests are made with an http.Client configured by the host, and only to
+// hosts it allows. Instantiate it in addition to wasi_snapshot_preview1,
+// which is used for everything else, such as "fd_read" and "fd_close" on the
+// response body.
+//
+//	wasi_snapshot_preview1.MustInstantiate(ctx, r)
+//	wasi_http.NewBuilder(r).
+//		WithClient(&http.Client{Timeout: 10 * time.Second}).
+//		WithAllowedHosts("api.example.com", "*.example.org").
+//		Instantiate(ctx, r)
+//	mod, _ := r.InstantiateModuleFromBinary(ctx, wasm)
+//
+// # ABI
+//
+// wasi-http is defined in WIT for the component model, which wazero doesn't
+// implement. Instead, this module defines the outgoing-handler with
+// wasi_snapshot_preview1 conventions: each function returns a
+// wasi_snapshot_preview1.Errno and writes results to memory offsets passed as
+// parameters prefixed "result.".
+//
+// Headers are UTF-8 text in memory, in HTTP/1.1 format: each is a line of the
+// name, a colon and the value, where lines end in "\r\n" or "\n".
+//
+// See https://github.com/WebAssembly/wasi-http
+package wasi_http
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// ModuleName is the module name the functions are exported into.
+const (
+	ModuleName = "wasi_http"
+	i32        = wasm.ValueTypeI32
+)
+
+// MustInstantiate calls Instantiate or panics on error.
+//
+// This is a simpler function for those who know the module ModuleName is not
+// already instantiated, and don't need to unload it.
+func MustInstantiate(ctx context.Context, r wazero.Runtime) {
+	if _, err := Instantiate(ctx, r); err != nil {
+		panic(err)
+	}
+}
+
+// Instantiate instantiates the ModuleName module into the runtime default
+// namespace.
+//
+// # Notes
+//
+//   - Failure cases are documented on wazero.Namespace InstantiateModule.
+//   - Closing the wazero.Runtime has the same effect as closing the result.
+//   - No hosts are allowed, so use NewBuilder to allow requests.
+func Instantiate(ctx context.Context, r wazero.Runtime) (api.Closer, error) {
+	return NewBuilder(r).Instantiate(ctx, r)
+}
+
+// Builder configures the ModuleName module for later use via Compile or
+// Instantiate. Each instantiation has its own client and allowed hosts, so
+// guests in different namespaces can have different policies.
+type Builder interface {
+	// WithClient sets the client which makes requests. Defaults to
+	// http.DefaultClient.
+	//
+	// Note: Redirects are checked against WithAllowedHosts before the
+	// client's CheckRedirect, if any.
+	WithClient(*http.Client) Builder
+
+	// WithAllowedHosts allows requests to the given hosts. Defaults to none,
+	// so all requests fail with ErrnoAcces.
+	//
+	// Each entry matches the host of the request URL, ignoring case:
+	//   - "example.com" matches the host on any port.
+	//   - "example.com:8443" matches the host only on port 8443.
+	//   - "*.example.com" matches subdomains of "example.com", but not itself.
+	//   - "*" matches any host.
+	WithAllowedHosts(hosts ...string) Builder
+
+	// Compile compiles the ModuleName module that can instantiated in any
+	// namespace (wazero.Namespace).
+	//
+	// Note: This has the same effect as the same function on wazero.HostModuleBuilder.
+	Compile(context.Context) (wazero.CompiledModule, error)
+
+	// Instantiate instantiates the ModuleName module into the given namespace.
+	//
+	// Note: This has the same effect as the same function on wazero.HostModuleBuilder.
+	Instantiate(context.Context, wazero.Namespace) (api.Closer, error)
+}
+
+// NewBuilder returns a new Builder.
+func NewBuilder(r wazero.Runtime) Builder {
+	return &builder{r: r, client: http.DefaultClient}
+}
+
+type builder struct {
+	r            wazero.Runtime
+	client       *http.Client
+	allowedHosts []string
+}
+
+// WithClient implements Builder.WithClient
+func (b *builder) WithClient(client *http.Client) Builder {
+	b.client = client
+	return b
+}
+
+// WithAllowedHosts implements Builder.WithAllowedHosts
+func (b *builder) WithAllowedHosts(hosts ...string) Builder {
+	b.allowedHosts = append(b.allowedHosts, hosts...)
+	return b
+}
+
+// hostModuleBuilder returns a new wazero.HostModuleBuilder for ModuleName
+func (b *builder) hostModuleBuilder() wazero.HostModuleBuilder {
+	ret := b.r.NewHostModuleBuilder(ModuleName)
+	h := newHandler(b.client, b.allowedHosts)
+	exporter := ret.(wasm.HostFuncExporter)
+	exporter.ExportHostFunc(h.request())
+	exporter.ExportHostFunc(responseHeaders)
+	return ret
+}
+
+// Compile implements Builder.Compile
+func (b *builder) Compile(ctx context.Context) (wazero.CompiledModule, error) {
+	return b.hostModuleBuilder().Compile(ctx)
+}
+
+// Instantiate implements Builder.Instantiate
+func (b *builder) Instantiate(ctx context.Context, ns wazero.Namespace) (api.Closer, error) {
+	return b.hostModuleBuilder().Instantiate(ctx, ns)
+}
+
+func newHostFunc(
+	name string,
+	goFunc httpFunc,
+	paramTypes []api.ValueType,
+	paramNames ...string,
+) *wasm.HostFunc {
+	return &wasm.HostFunc{
+		ExportNames: []string{name},
+		Name:        name,
+		ParamTypes:  paramTypes,
+		ParamNames:  paramNames,
+		ResultTypes: []api.ValueType{i32},
+		ResultNames: []string{"errno"},
+		Code:        &wasm.Code{IsHostFunction: true, GoFunc: goFunc},
+	}
+}
+
+// httpFunc special cases that all functions return a single Errno result.
+// The returned value will be written back to the stack at index zero.
+type httpFunc func(ctx context.Context, mod api.Module, params []uint64) Errno
+
+// Call implements the same method as documented on api.GoModuleFunction.
+func (f httpFunc) Call(ctx context.Context, mod api.Module, stack []uint64) {
+	// Write the result back onto the stack
+	stack[0] = uint64(f(ctx, mod, stack))
+}
@@ -0,0 +1,131 @@
+package wasi_snapshot_preview1
+
+import (
+	"strings"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/leb128"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// Capability is a group of WASI functions which can be denied with
+// Builder.WithDeniedCapabilities. Each group is all functions whose name
+// begins with the same prefix.
+type Capability uint32
+
+const (
+	// CapabilityArgs is functions prefixed "args_", e.g. "args_get".
+	CapabilityArgs Capability = 1 << iota
+	// CapabilityEnviron is functions prefixed "environ_", e.g. "environ_get".
+	CapabilityEnviron
+	// CapabilityClock is functions prefixed "clock_", e.g. "clock_time_get".
+	CapabilityClock
+	// CapabilityFd is functions prefixed "fd_", e.g. "fd_write".
+	//
+	// Note: Denying this prevents use of stdio as well as files.
+	CapabilityFd
+	// CapabilityPath is functions prefixed "path_", e.g. "path_open".
+	CapabilityPath
+	// CapabilityPoll is functions prefixed "poll_", e.g. "poll_oneoff".
+	CapabilityPoll
+	// CapabilityRandom is functions prefixed "random_", e.g. "random_get".
+	CapabilityRandom
+	// CapabilitySched is functions prefixed "sched_", e.g. "sched_yield".
+	CapabilitySched
+	// CapabilitySock is functions prefixed "sock_", e.g. "sock_accept".
+	CapabilitySock
+)
+
+// capabilityPrefixes are the function name prefixes of each Capability.
+var capabilityPrefixes = map[Capability]string{
+	CapabilityArgs:    "args_",
+	CapabilityEnviron: "environ_",
+	CapabilityClock:   "clock_",
+	CapabilityFd:      "fd_",
+	CapabilityPath:    "path_",
+	CapabilityPoll:    "poll_",
+	CapabilityRandom:  "random_",
+	CapabilitySched:   "sched_",
+	CapabilitySock:    "sock_",
+}
+
+func capabilitySet(capabilities []Capability) (ret Capability) {
+	for _, c := range capabilities {
+		ret |= c
+	}
+	return
+}
+
+// capabilityPolicy decides which WASI functions are replaced with ones that
+// return ErrnoNotcapable.
+type capabilityPolicy struct {
+	denied          Capability
+	deniedFunctions map[string]struct{}
+}
+
+func (p *capabilityPolicy) denyFunctions(names []string) {
+	if p.deniedFunctions == nil {
+		p.deniedFunctions = make(map[string]struct{}, len(names))
+	}
+	for _, name := range names {
+		p.deniedFunctions[name] = struct{}{}
+	}
+}
+
+// isDenied returns true if the WASI function of the given name is denied.
+func (p *capabilityPolicy) isDenied(name string) bool {
+	if _, ok := p.deniedFunctions[name]; ok {
+		return true
+	}
+	for c, prefix := range capabilityPrefixes {
+		if p.denied&c != 0 && strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// exporter returns a wasm.HostFuncExporter which applies this policy to
+// functions before exporting them.
+func (p *capabilityPolicy) exporter(exporter wasm.HostFuncExporter) wasm.HostFuncExporter {
+	if p.denied == 0 && len(p.deniedFunctions) == 0 {
+		return exporter
+	}
+	return &policyExporter{exporter: exporter, policy: p}
+}
+
+type policyExporter struct {
+	exporter wasm.HostFuncExporter
+	policy   *capabilityPolicy
+}
+
+// ExportHostFunc implements wasm.HostFuncExporter
+func (e *policyExporter) ExportHostFunc(fn *wasm.HostFunc) {
+	if e.policy.isDenied(fn.Name) {
+		fn = notCapableFunction(fn)
+	}
+	e.exporter.ExportHostFunc(fn)
+}
+
+// notCapableFunction returns a function with the same signature as fn, which
+// returns ErrnoNotcapable, or traps if fn has no errno result.
+func notCapableFunction(fn *wasm.HostFunc) *wasm.HostFunc {
+	var body []byte
+	if len(fn.ResultTypes) == 1 && fn.ResultTypes[0] == api.ValueTypeI32 {
+		body = append([]byte{wasm.OpcodeI32Const}, leb128.EncodeInt32(int32(ErrnoNotcapable))...)
+	} else {
+		body = []byte{wasm.OpcodeUnreachable}
+	}
+	return &wasm.HostFunc{
+		ExportNames: fn.ExportNames,
+		Name:        fn.Name,
+		ParamTypes:  fn.ParamTypes,
+		ParamNames:  fn.ParamNames,
+		ResultTypes: fn.ResultTypes,
+		ResultNames: fn.ResultNames,
+		Code: &wasm.Code{
+			IsHostFunction: true,
+			Body:           append(body, wasm.OpcodeEnd),
+		},
+	}
+}
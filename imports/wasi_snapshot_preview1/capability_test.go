@@ -0,0 +1,68 @@
+package wasi_snapshot_preview1
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/internal/testing/proxy"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestCapabilityPolicy_isDenied(t *testing.T) {
+	p := &capabilityPolicy{}
+	p.denied = capabilitySet([]Capability{CapabilitySock, CapabilityPath})
+	p.denyFunctions([]string{fdWriteName})
+
+	tests := []struct {
+		name     string
+		expected bool
+	}{
+		{name: sockAcceptName, expected: true},
+		{name: sockShutdownName, expected: true},
+		{name: pathOpenName, expected: true},
+		{name: pathUnlinkFileName, expected: true},
+		{name: fdWriteName, expected: true},
+		{name: fdReadName, expected: false},
+		{name: randomGetName, expected: false},
+		{name: procExitName, expected: false},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, p.isDenied(tc.name))
+		})
+	}
+}
+
+func TestBuilder_WithDeniedCapabilities(t *testing.T) {
+	r := wazero.NewRuntime(testCtx)
+	defer r.Close(testCtx)
+
+	wasiModuleCompiled, err := NewBuilder(r).
+		WithDeniedCapabilities(CapabilitySock).
+		WithDeniedFunctions(pathUnlinkFileName, procExitName).(*builder).
+		hostModuleBuilder().Compile(testCtx)
+	require.NoError(t, err)
+
+	_, err = r.InstantiateModule(testCtx, wasiModuleCompiled, wazero.NewModuleConfig())
+	require.NoError(t, err)
+
+	proxyCompiled, err := r.CompileModule(testCtx, proxy.NewModuleBinary(ModuleName, wasiModuleCompiled))
+	require.NoError(t, err)
+
+	mod, err := r.InstantiateModule(testCtx, proxyCompiled, wazero.NewModuleConfig())
+	require.NoError(t, err)
+
+	// Denied functions return ENOTCAPABLE regardless of their parameters.
+	requireErrno(t, ErrnoNotcapable, mod, sockAcceptName, 0, 0, 0)
+	requireErrno(t, ErrnoNotcapable, mod, sockShutdownName, 0, 0)
+	requireErrno(t, ErrnoNotcapable, mod, pathUnlinkFileName, 0, 0, 0)
+
+	// Other functions are unaffected.
+	requireErrno(t, ErrnoSuccess, mod, randomGetName, 0, 0)
+
+	// A denied function without an errno result traps.
+	_, err = mod.ExportedFunction(procExitName).Call(testCtx, 0)
+	require.Contains(t, err.Error(), "wasm error: unreachable")
+}
@@ -19,8 +19,16 @@ const (
 	clockIDRealtime = iota
 	// clockIDMonotonic is the name ID named "monotonic" like sys.Nanotime
 	clockIDMonotonic
-	// Note: clockIDProcessCputime and clockIDThreadCputime were removed by
-	// WASI maintainers: https://github.com/WebAssembly/wasi-libc/pull/294
+	// clockIDProcessCputime is the name ID named "process_cputime_id" like
+	// sys.Cputime
+	clockIDProcessCputime
+	// clockIDThreadCputime is the name ID named "thread_cputime_id" like
+	// sys.Cputime, as a module only has one thread.
+	//
+	// Note: clockIDProcessCputime and clockIDThreadCputime were removed from
+	// wasi-libc, but are still defined in wasi_snapshot_preview1:
+	// https://github.com/WebAssembly/wasi-libc/pull/294
+	clockIDThreadCputime
 )
 
 // clockResGet is the WASI function named clockResGetName that returns the
@@ -63,6 +71,8 @@ func clockResGetFn(_ context.Context, mod api.Module, params []uint64) Errno {
 		resolution = uint64(sysCtx.WalltimeResolution())
 	case clockIDMonotonic:
 		resolution = uint64(sysCtx.NanotimeResolution())
+	case clockIDProcessCputime, clockIDThreadCputime:
+		resolution = uint64(sysCtx.CputimeResolution())
 	default:
 		return ErrnoInval
 	}
@@ -101,13 +111,15 @@ func clockResGetFn(_ context.Context, mod api.Module, params []uint64) Errno {
 //	        []byte{?, 0x0, 0x0, 0x1f, 0xa6, 0x70, 0xfc, 0xc5, 0x16, ?}
 //	resultTimestamp --^
 //
-// Note: This is similar to `clock_gettime` in POSIX.
+// Note: This is similar to `clock_gettime` in POSIX. The CPU time clocks are
+// configured with wazero.ModuleConfig WithCputime.
 // See https://github.com/WebAssembly/WASI/blob/snapshot-01/phases/snapshot/docs.md#-clock_time_getid-clockid-precision-timestamp---errno-timestamp
 // See https://linux.die.net/man/3/clock_gettime
 var clockTimeGet = newHostFunc(clockTimeGetName, clockTimeGetFn, []api.ValueType{i32, i64, i32}, "id", "precision", "result.timestamp")
 
-func clockTimeGetFn(_ context.Context, mod api.Module, params []uint64) Errno {
-	sysCtx := mod.(*wasm.CallContext).Sys
+func clockTimeGetFn(ctx context.Context, mod api.Module, params []uint64) Errno {
+	callCtx := mod.(*wasm.CallContext)
+	sysCtx := callCtx.Sys
 	id := uint32(params[0])
 	// TODO: precision is currently ignored.
 	// precision = params[1]
@@ -120,6 +132,8 @@ func clockTimeGetFn(_ context.Context, mod api.Module, params []uint64) Errno {
 		val = (sec * time.Second.Nanoseconds()) + int64(nsec)
 	case clockIDMonotonic:
 		val = sysCtx.Nanotime()
+	case clockIDProcessCputime, clockIDThreadCputime:
+		val = callCtx.Cputime(ctx)
 	default:
 		return ErrnoInval
 	}
@@ -3,6 +3,7 @@ package wasi_snapshot_preview1
 import (
 	_ "embed"
 	"testing"
+	"time"
 
 	"github.com/tetratelabs/wazero"
 	"github.com/tetratelabs/wazero/internal/testing/require"
@@ -35,7 +36,7 @@ func Test_clockResGet(t *testing.T) {
 			clockID:        clockIDRealtime,
 			expectedMemory: expectedMemoryMicro,
 			expectedLog: `
-==> wasi_snapshot_preview1.clock_res_get(id=0,result.resolution=16)
+==> wasi_snapshot_preview1.clock_res_get(id=REALTIME,result.resolution=16)
 <== ESUCCESS
 `,
 		},
@@ -44,7 +45,25 @@ func Test_clockResGet(t *testing.T) {
 			clockID:        clockIDMonotonic,
 			expectedMemory: expectedMemoryNano,
 			expectedLog: `
-==> wasi_snapshot_preview1.clock_res_get(id=1,result.resolution=16)
+==> wasi_snapshot_preview1.clock_res_get(id=MONOTONIC,result.resolution=16)
+<== ESUCCESS
+`,
+		},
+		{
+			name:           "ProcessCputime",
+			clockID:        clockIDProcessCputime,
+			expectedMemory: expectedMemoryNano,
+			expectedLog: `
+==> wasi_snapshot_preview1.clock_res_get(id=PROCESS_CPUTIME_ID,result.resolution=16)
+<== ESUCCESS
+`,
+		},
+		{
+			name:           "ThreadCputime",
+			clockID:        clockIDThreadCputime,
+			expectedMemory: expectedMemoryNano,
+			expectedLog: `
+==> wasi_snapshot_preview1.clock_res_get(id=THREAD_CPUTIME_ID,result.resolution=16)
 <== ESUCCESS
 `,
 		},
@@ -79,30 +98,12 @@ func Test_clockResGet_Unsupported(t *testing.T) {
 		expectedErrno Errno
 		expectedLog   string
 	}{
-		{
-			name:          "process cputime",
-			clockID:       2,
-			expectedErrno: ErrnoInval,
-			expectedLog: `
-==> wasi_snapshot_preview1.clock_res_get(id=2,result.resolution=16)
-<== EINVAL
-`,
-		},
-		{
-			name:          "thread cputime",
-			clockID:       3,
-			expectedErrno: ErrnoInval,
-			expectedLog: `
-==> wasi_snapshot_preview1.clock_res_get(id=3,result.resolution=16)
-<== EINVAL
-`,
-		},
 		{
 			name:          "undefined",
 			clockID:       100,
 			expectedErrno: ErrnoInval,
 			expectedLog: `
-==> wasi_snapshot_preview1.clock_res_get(id=100,result.resolution=16)
+==> wasi_snapshot_preview1.clock_res_get(id=clockid(100),result.resolution=16)
 <== EINVAL
 `,
 		},
@@ -140,7 +141,7 @@ func Test_clockTimeGet(t *testing.T) {
 				'?', // stopped after encoding
 			},
 			expectedLog: `
-==> wasi_snapshot_preview1.clock_time_get(id=0,precision=0,result.timestamp=16)
+==> wasi_snapshot_preview1.clock_time_get(id=REALTIME,precision=0,result.timestamp=16)
 <== ESUCCESS
 `,
 		},
@@ -153,7 +154,33 @@ func Test_clockTimeGet(t *testing.T) {
 				'?', // stopped after encoding
 			},
 			expectedLog: `
-==> wasi_snapshot_preview1.clock_time_get(id=1,precision=0,result.timestamp=16)
+==> wasi_snapshot_preview1.clock_time_get(id=MONOTONIC,precision=0,result.timestamp=16)
+<== ESUCCESS
+`,
+		},
+		{
+			name:    "ProcessCputime",
+			clockID: clockIDProcessCputime,
+			expectedMemory: []byte{
+				'?',                                    // resultTimestamp is after this
+				0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, // fake cputime starts at zero
+				'?', // stopped after encoding
+			},
+			expectedLog: `
+==> wasi_snapshot_preview1.clock_time_get(id=PROCESS_CPUTIME_ID,precision=0,result.timestamp=16)
+<== ESUCCESS
+`,
+		},
+		{
+			name:    "ThreadCputime",
+			clockID: clockIDThreadCputime,
+			expectedMemory: []byte{
+				'?',                                      // resultTimestamp is after this
+				0x40, 0x42, 0xf, 0x0, 0x0, 0x0, 0x0, 0x0, // same clock as process, so 1ms later
+				'?', // stopped after encoding
+			},
+			expectedLog: `
+==> wasi_snapshot_preview1.clock_time_get(id=THREAD_CPUTIME_ID,precision=0,result.timestamp=16)
 <== ESUCCESS
 `,
 		},
@@ -177,6 +204,29 @@ func Test_clockTimeGet(t *testing.T) {
 	}
 }
 
+func Test_clockTimeGet_sysCputime(t *testing.T) {
+	mod, r, _ := requireProxyModule(t, wazero.NewModuleConfig().WithSysCputime())
+	defer r.Close(testCtx)
+
+	// Spin outside the module, which isn't included in its CPU time.
+	const spin = 50 * time.Millisecond
+	for start := time.Now(); time.Since(start) < spin; {
+	}
+
+	resultTimestamp := uint32(16) // arbitrary offset
+	var readings []uint64
+	for _, clockID := range []uint32{clockIDProcessCputime, clockIDThreadCputime} {
+		requireErrno(t, ErrnoSuccess, mod, clockTimeGetName, uint64(clockID), 0 /* TODO: precision */, uint64(resultTimestamp))
+		nanos, ok := mod.Memory().ReadUint64Le(resultTimestamp)
+		require.True(t, ok)
+		readings = append(readings, nanos)
+	}
+
+	require.True(t, readings[0] < uint64(spin), time.Duration(readings[0]).String())
+	// The first call is included in the second reading.
+	require.True(t, readings[1] >= readings[0], "%d < %d", readings[1], readings[0])
+}
+
 func Test_clockTimeGet_Unsupported(t *testing.T) {
 	mod, r, log := requireProxyModule(t, wazero.NewModuleConfig())
 	defer r.Close(testCtx)
@@ -187,30 +237,12 @@ func Test_clockTimeGet_Unsupported(t *testing.T) {
 		expectedErrno Errno
 		expectedLog   string
 	}{
-		{
-			name:          "process cputime",
-			clockID:       2,
-			expectedErrno: ErrnoInval,
-			expectedLog: `
-==> wasi_snapshot_preview1.clock_time_get(id=2,precision=0,result.timestamp=16)
-<== EINVAL
-`,
-		},
-		{
-			name:          "thread cputime",
-			clockID:       3,
-			expectedErrno: ErrnoInval,
-			expectedLog: `
-==> wasi_snapshot_preview1.clock_time_get(id=3,precision=0,result.timestamp=16)
-<== EINVAL
-`,
-		},
 		{
 			name:          "undefined",
 			clockID:       100,
 			expectedErrno: ErrnoInval,
 			expectedLog: `
-==> wasi_snapshot_preview1.clock_time_get(id=100,precision=0,result.timestamp=16)
+==> wasi_snapshot_preview1.clock_time_get(id=clockid(100),precision=0,result.timestamp=16)
 <== EINVAL
 `,
 		},
@@ -244,7 +276,7 @@ func Test_clockTimeGet_Errors(t *testing.T) {
 			name:            "resultTimestamp out-of-memory",
 			resultTimestamp: memorySize,
 			expectedLog: `
-==> wasi_snapshot_preview1.clock_time_get(id=0,precision=0,result.timestamp=65536)
+==> wasi_snapshot_preview1.clock_time_get(id=REALTIME,precision=0,result.timestamp=65536)
 <== EFAULT
 `,
 		},
@@ -252,7 +284,7 @@ func Test_clockTimeGet_Errors(t *testing.T) {
 			name:            "resultTimestamp exceeds the maximum valid address by 1",
 			resultTimestamp: memorySize - 4 + 1, // 4 is the size of uint32, the type of the count of args
 			expectedLog: `
-==> wasi_snapshot_preview1.clock_time_get(id=0,precision=0,result.timestamp=65533)
+==> wasi_snapshot_preview1.clock_time_get(id=REALTIME,precision=0,result.timestamp=65533)
 <== EFAULT
 `,
 		},
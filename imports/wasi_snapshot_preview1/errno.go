@@ -177,7 +177,11 @@ const (
 	ErrnoTxtbsy
 	// ErrnoXdev Cross-device link.
 	ErrnoXdev
-
-	// Note: ErrnoNotcapable was removed by WASI maintainers.
+	// ErrnoNotcapable Extension: Capabilities insufficient.
+	//
+	// Note: This is returned by functions denied by Builder.WithDeniedFunctions
+	// or Builder.WithDeniedCapabilities. wasi-libc no longer defines this, so
+	// guests may report it as an unknown error.
 	// See https://github.com/WebAssembly/wasi-libc/pull/294
+	ErrnoNotcapable
 )
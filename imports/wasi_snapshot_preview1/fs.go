@@ -6,10 +6,13 @@ import (
 	"io"
 	"io/fs"
 	"math"
+	"os"
 	"path"
+	"sync"
 	"syscall"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/platform"
 	internalsys "github.com/tetratelabs/wazero/internal/sys"
 	"github.com/tetratelabs/wazero/internal/wasm"
 )
@@ -52,23 +55,112 @@ const (
 // fdAdvise is the WASI function named fdAdviseName which provides file
 // advisory information on a file descriptor.
 //
+// # Parameters
+//
+//   - fd: file descriptor to advise
+//   - offset: offset of the data the advice applies to
+//   - len: length of the data the advice applies to, or zero until the end
+//   - advice: the access pattern, e.g. wasiAdviceSequential
+//
+// Result (Errno)
+//
+// The return value is ErrnoSuccess except the following error conditions:
+//   - ErrnoBadf: `fd` is invalid
+//   - ErrnoInval: `advice` is invalid
+//
+// Note: This is similar to `posix_fadvise` in POSIX, which is only called on
+// Linux, as the advice is a hint.
 // See https://github.com/WebAssembly/WASI/blob/snapshot-01/phases/snapshot/docs.md#-fd_advisefd-fd-offset-filesize-len-filesize-advice-advice---errno
-var fdAdvise = stubFunction(
-	fdAdviseName,
+// and https://linux.die.net/man/2/posix_fadvise
+var fdAdvise = newHostFunc(
+	fdAdviseName, fdAdviseFn,
 	[]wasm.ValueType{i32, i64, i64, i32},
 	"fd", "offset", "len", "advice",
 )
 
+// wasiAdvice is the advice used by fdAdvise.
+//
+// See https://github.com/WebAssembly/WASI/blob/snapshot-01/phases/snapshot/docs.md#-advice-enumu8
+type wasiAdvice = uint32
+
+const (
+	wasiAdviceNormal wasiAdvice = iota
+	wasiAdviceSequential
+	wasiAdviceRandom
+	wasiAdviceWillNeed
+	wasiAdviceDontNeed
+	wasiAdviceNoReuse
+)
+
+func fdAdviseFn(_ context.Context, mod api.Module, params []uint64) Errno {
+	fsc := mod.(*wasm.CallContext).Sys.FS()
+	fd := uint32(params[0])
+	offset := int64(params[1])
+	length := int64(params[2])
+
+	var advice int
+	switch uint32(params[3]) {
+	case wasiAdviceNormal:
+		advice = platform.FadviseNormal
+	case wasiAdviceSequential:
+		advice = platform.FadviseSequential
+	case wasiAdviceRandom:
+		advice = platform.FadviseRandom
+	case wasiAdviceWillNeed:
+		advice = platform.FadviseWillNeed
+	case wasiAdviceDontNeed:
+		advice = platform.FadviseDontNeed
+	case wasiAdviceNoReuse:
+		advice = platform.FadviseNoReuse
+	default:
+		return ErrnoInval
+	}
+
+	if err := fsc.Advise(fd, offset, length, advice); err != nil {
+		return toErrno(err)
+	}
+	return ErrnoSuccess
+}
+
 // fdAllocate is the WASI function named fdAllocateName which forces the
 // allocation of space in a file.
 //
+// # Parameters
+//
+//   - fd: file descriptor opened for writing
+//   - offset: offset to allocate space from
+//   - len: length of space to allocate
+//
+// Result (Errno)
+//
+// The return value is ErrnoSuccess except the following error conditions:
+//   - ErrnoBadf: `fd` is invalid or not open for writing
+//   - ErrnoInval: `len` is zero
+//   - ErrnoFbig: `offset` plus `len` is too large
+//   - ErrnoNodev: `fd` isn't a regular file
+//
+// Note: This is similar to `posix_fallocate` in POSIX, except implemented by
+// growing the file with `ftruncate` when it is smaller than offset plus len.
 // See https://github.com/WebAssembly/WASI/blob/snapshot-01/phases/snapshot/docs.md#-fd_allocatefd-fd-offset-filesize-len-filesize---errno
-var fdAllocate = stubFunction(
-	fdAllocateName,
+// and https://linux.die.net/man/3/posix_fallocate
+var fdAllocate = newHostFunc(
+	fdAllocateName, fdAllocateFn,
 	[]wasm.ValueType{i32, i64, i64},
 	"fd", "offset", "len",
 )
 
+func fdAllocateFn(_ context.Context, mod api.Module, params []uint64) Errno {
+	fsc := mod.(*wasm.CallContext).Sys.FS()
+	fd := uint32(params[0])
+	offset := int64(params[1])
+	length := int64(params[2])
+
+	if err := fsc.Allocate(fd, offset, length); err != nil {
+		return toErrno(err)
+	}
+	return ErrnoSuccess
+}
+
 // fdClose is the WASI function named fdCloseName which closes a file
 // descriptor.
 //
@@ -120,8 +212,9 @@ var fdDatasync = stubFunction(fdDatasyncName, []api.ValueType{i32}, "fd")
 //   - fs_filetype 1 byte: the file type
 //   - fs_flags 2 bytes: the file descriptor flag
 //   - 5 pad bytes
-//   - fs_right_base 8 bytes: ignored as rights were removed from WASI.
-//   - fs_right_inheriting 8 bytes: ignored as rights were removed from WASI.
+//   - fs_right_base 8 bytes: the rights of the file descriptor
+//   - fs_right_inheriting 8 bytes: the maximum rights of file descriptors
+//     opened from this one with path_open.
 //
 // For example, with a file corresponding with `fd` was a directory (=3) opened
 // with `fd_read` right (=1) and no fs_flags (=0), parameter resultFdstat=1,
@@ -137,6 +230,10 @@ var fdDatasync = stubFunction(fdDatasyncName, []api.ValueType{i32}, "fd")
 //
 // Note: fdFdstatGet returns similar flags to `fsync(fd, F_GETFL)` in POSIX, as
 // well as additional fields.
+//
+// Note: A host terminal, such as stdout in an interactive shell, is a
+// character device without the fd_seek or fd_tell rights, which is how
+// `isatty` is implemented in wasi-libc.
 // See https://github.com/WebAssembly/WASI/blob/snapshot-01/phases/snapshot/docs.md#fdstat
 // and https://linux.die.net/man/3/fsync
 var fdFdstatGet = newHostFunc(fdFdstatGetName, fdFdstatGetFn, []api.ValueType{i32, i32}, "fd", "result.stat")
@@ -158,31 +255,140 @@ func fdFdstatGetFn(_ context.Context, mod api.Module, params []uint64) Errno {
 	if err != nil {
 		return toErrno(err)
 	}
+	f, _ := fsc.OpenedFile(fd) // present as StatFile succeeded.
 
-	filetype := getWasiFiletype(stat.Mode())
-	var fdflags wasiFdflags
+	filetype := fdFiletype(fsc, fd, stat)
+	fdflags := toWasiFdflags(f.Flag)
 
-	// Determine if it is writeable
-	if w := fsc.FdWriter(fd); w != nil {
-		// TODO: maybe cache flags to open instead
-		fdflags = wasiFdflagsAppend
+	// stdout and stderr are written in sequence, similar to append.
+	if fd == internalsys.FdStdout || fd == internalsys.FdStderr {
+		fdflags |= wasiFdflagsAppend
 	}
 
-	writeFdstat(buf, filetype, fdflags)
+	rightsBase, rightsInheriting := fdRights(f, filetype)
+
+	// A terminal can't seek, which is how wasi-libc implements isatty.
+	if filetype == wasiFiletypeCharacterDevice && fsc.IsTerminal(fd) {
+		rightsBase &^= wasiRightsFdSeek | wasiRightsFdTell
+	}
+	writeFdstat(buf, filetype, fdflags, rightsBase, rightsInheriting)
 
 	return ErrnoSuccess
 }
 
 type wasiFdflags = byte // actually 16-bit, but there aren't that many.
 const (
-	wasiFdflagsNone wasiFdflags = 1<<iota - 1
-	wasiFdflagsAppend
+	wasiFdflagsNone   wasiFdflags = 0
+	wasiFdflagsAppend wasiFdflags = 1 << (iota - 1)
 	wasiFdflagsDsync
 	wasiFdflagsNonblock
 	wasiFdflagsRsync
 	wasiFdflagsSync
 )
 
+// toWasiFdflags returns the fdflags corresponding to the flag a file was
+// opened with. Notably, wasiFdflagsDsync and wasiFdflagsRsync are opened as
+// os.O_SYNC, so are returned as wasiFdflagsSync.
+func toWasiFdflags(flag int) (fdflags wasiFdflags) {
+	if flag&os.O_APPEND != 0 {
+		fdflags |= wasiFdflagsAppend
+	}
+	if flag&platform.O_NONBLOCK != 0 {
+		fdflags |= wasiFdflagsNonblock
+	}
+	if flag&os.O_SYNC == os.O_SYNC {
+		fdflags |= wasiFdflagsSync
+	}
+	return
+}
+
+// wasiRights are the operations allowed on a file descriptor. Guests such as
+// wasi-libc read them with fd_fdstat_get to choose the rights to request in
+// path_open.
+//
+// See https://github.com/WebAssembly/WASI/blob/snapshot-01/phases/snapshot/docs.md#-rights-flagsu64
+type wasiRights = uint64
+
+const (
+	wasiRightsFdDatasync wasiRights = 1 << iota
+	wasiRightsFdRead
+	wasiRightsFdSeek
+	wasiRightsFdFdstatSetFlags
+	wasiRightsFdSync
+	wasiRightsFdTell
+	wasiRightsFdWrite
+	wasiRightsFdAdvise
+	wasiRightsFdAllocate
+	wasiRightsPathCreateDirectory
+	wasiRightsPathCreateFile
+	wasiRightsPathLinkSource
+	wasiRightsPathLinkTarget
+	wasiRightsPathOpen
+	wasiRightsFdReaddir
+	wasiRightsPathReadlink
+	wasiRightsPathRenameSource
+	wasiRightsPathRenameTarget
+	wasiRightsPathFilestatGet
+	wasiRightsPathFilestatSetSize
+	wasiRightsPathFilestatSetTimes
+	wasiRightsFdFilestatGet
+	wasiRightsFdFilestatSetSize
+	wasiRightsFdFilestatSetTimes
+	wasiRightsPathSymlink
+	wasiRightsPathRemoveDirectory
+	wasiRightsPathUnlinkFile
+	wasiRightsPollFdReadwrite
+	wasiRightsSockShutdown
+	wasiRightsSockAccept
+
+	// wasiRightsAll are all rights defined, used to ignore undefined bits.
+	wasiRightsAll = wasiRightsSockAccept<<1 - 1
+)
+
+const (
+	// wasiRightsFile are the default rights of a file which isn't a directory.
+	wasiRightsFile = wasiRightsFdDatasync | wasiRightsFdRead | wasiRightsFdSeek |
+		wasiRightsFdFdstatSetFlags | wasiRightsFdSync | wasiRightsFdTell |
+		wasiRightsFdWrite | wasiRightsFdAdvise | wasiRightsFdAllocate |
+		wasiRightsFdFilestatGet | wasiRightsFdFilestatSetSize |
+		wasiRightsFdFilestatSetTimes | wasiRightsPollFdReadwrite
+
+	// wasiRightsDir are the default rights of a directory.
+	wasiRightsDir = wasiRightsFdFdstatSetFlags | wasiRightsFdSync |
+		wasiRightsFdAdvise | wasiRightsPathCreateDirectory |
+		wasiRightsPathCreateFile | wasiRightsPathLinkSource |
+		wasiRightsPathLinkTarget | wasiRightsPathOpen | wasiRightsFdReaddir |
+		wasiRightsPathReadlink | wasiRightsPathRenameSource |
+		wasiRightsPathRenameTarget | wasiRightsPathFilestatGet |
+		wasiRightsPathFilestatSetSize | wasiRightsPathFilestatSetTimes |
+		wasiRightsFdFilestatGet | wasiRightsFdFilestatSetTimes |
+		wasiRightsPathSymlink | wasiRightsPathRemoveDirectory |
+		wasiRightsPathUnlinkFile
+
+	// wasiRightsSocket are the default rights of a socket.
+	wasiRightsSocket = wasiRightsFdRead | wasiRightsFdWrite |
+		wasiRightsFdFdstatSetFlags | wasiRightsFdFilestatGet |
+		wasiRightsPollFdReadwrite | wasiRightsSockShutdown | wasiRightsSockAccept
+)
+
+// fdRights returns the rights of an opened file. When the file wasn't opened
+// with path_open, such as pre-opens, these default based on the filetype.
+// Directories default to inherit all rights, so that guests can request any
+// rights when opening files in them.
+func fdRights(f *internalsys.FileEntry, filetype wasiFiletype) (base, inheriting wasiRights) {
+	if f.RightsBase != 0 || f.RightsInheriting != 0 {
+		return f.RightsBase, f.RightsInheriting
+	}
+	switch filetype {
+	case wasiFiletypeDirectory:
+		return wasiRightsDir, wasiRightsAll
+	case wasiFiletypeSocketStream, wasiFiletypeSocketDgram:
+		return wasiRightsSocket, 0
+	default:
+		return wasiRightsFile, 0
+	}
+}
+
 var blockFdstat = []byte{
 	byte(wasiFiletypeBlockDevice), 0, // filetype
 	0, 0, 0, 0, 0, 0, // fdflags
@@ -190,18 +396,87 @@ var blockFdstat = []byte{
 	0, 0, 0, 0, 0, 0, 0, 0, // fs_rights_inheriting
 }
 
-func writeFdstat(buf []byte, filetype wasiFiletype, fdflags wasiFdflags) {
+func writeFdstat(buf []byte, filetype wasiFiletype, fdflags wasiFdflags, rightsBase, rightsInheriting wasiRights) {
 	// memory is re-used, so ensure the result is defaulted.
 	copy(buf, blockFdstat)
 	buf[0] = uint8(filetype)
 	buf[2] = fdflags
+	le.PutUint64(buf[8:], rightsBase)
+	le.PutUint64(buf[16:], rightsInheriting)
 }
 
 // fdFdstatSetFlags is the WASI function named fdFdstatSetFlagsName which
 // adjusts the flags associated with a file descriptor.
 //
-// See https://github.com/WebAssembly/WASI/blob/snapshot-01/phases/snapshot/docs.md#-fd_fdstat_set_flagsfd-fd-flags-fdflags---errnoand is stubbed for GrainLang per #271
-var fdFdstatSetFlags = stubFunction(fdFdstatSetFlagsName, []wasm.ValueType{i32, i32}, "fd", "flags")
+// # Parameters
+//
+//   - fd: file descriptor to adjust the flags of
+//   - flags: fdflags to set, where only wasiFdflagsAppend and
+//     wasiFdflagsNonblock are supported
+//
+// Result (Errno)
+//
+// The return value is ErrnoSuccess except the following error conditions:
+//   - ErrnoBadf: `fd` is invalid
+//   - ErrnoInval: `flags` include wasiFdflagsDsync, wasiFdflagsRsync or
+//     wasiFdflagsSync, which can only be set with path_open
+//
+// Notes
+//   - This is similar to `fcntl` with F_SETFL in POSIX.
+//   - The flags are emulated: fd_write seeks to the end of a file before
+//     writing when wasiFdflagsAppend is set, and fd_read, sock_recv and
+//     sock_accept return ErrnoAgain instead of blocking when
+//     wasiFdflagsNonblock is set. Clearing wasiFdflagsAppend has no effect on
+//     a file opened with it by path_open, as the host appends.
+//
+// See https://github.com/WebAssembly/WASI/blob/snapshot-01/phases/snapshot/docs.md#-fd_fdstat_set_flagsfd-fd-flags-fdflags---errno
+var fdFdstatSetFlags = newHostFunc(
+	fdFdstatSetFlagsName, fdFdstatSetFlagsFn,
+	[]wasm.ValueType{i32, i32},
+	"fd", "flags",
+)
+
+func fdFdstatSetFlagsFn(_ context.Context, mod api.Module, params []uint64) Errno {
+	fsc := mod.(*wasm.CallContext).Sys.FS()
+
+	fd := uint32(params[0])
+	fdflags := uint16(params[1])
+
+	if fdflags&^uint16(wasiFdflagsAppend|wasiFdflagsNonblock) != 0 {
+		return ErrnoInval
+	}
+
+	var flag int
+	if fdflags&uint16(wasiFdflagsAppend) != 0 {
+		flag |= os.O_APPEND
+	}
+	if fdflags&uint16(wasiFdflagsNonblock) != 0 {
+		flag |= platform.O_NONBLOCK
+	}
+	if err := fsc.SetFlag(fd, flag); err != nil {
+		return ErrnoBadf
+	}
+	return ErrnoSuccess
+}
+
+// wouldBlock returns true if the file descriptor is non-blocking and isn't
+// ready to read, in which case callers return ErrnoAgain instead of reading.
+// This is false for files that never block, such as regular files.
+func wouldBlock(fsc *internalsys.FSContext, fd uint32) bool {
+	if f, ok := fsc.OpenedFile(fd); !ok || f.Flag&platform.O_NONBLOCK == 0 {
+		return false
+	}
+	if ready, ok := fsc.PollRead(fd, 0); ok {
+		return !ready
+	}
+	hostFd, blocking := fsc.HostFdIfBlocking(fd)
+	if !blocking {
+		return false
+	}
+	ready, err := platform.PollRead([]uintptr{hostFd}, 0)
+	// On error, report the file ready, so that the guest reads the error.
+	return err == nil && !ready[0]
+}
 
 // fdFdstatSetRights will not be implemented as rights were removed from WASI.
 //
@@ -294,19 +569,32 @@ func fdFilestatGetFunc(mod api.Module, fd, resultBuf uint32) Errno {
 		return toErrno(err)
 	}
 
-	writeFilestat(buf, stat)
+	writeFilestat(buf, stat, fdFiletype(fsc, fd, stat))
 
 	return ErrnoSuccess
 }
 
+// fdFiletype returns the filetype of an open file descriptor. Unlike
+// getWasiFiletype, this distinguishes datagram from stream sockets.
+func fdFiletype(fsc *internalsys.FSContext, fd uint32, stat fs.FileInfo) wasiFiletype {
+	filetype := getWasiFiletype(stat.Mode())
+	if filetype == wasiFiletypeSocketStream && fsc.IsDatagram(fd) {
+		filetype = wasiFiletypeSocketDgram
+	}
+	return filetype
+}
+
 func getWasiFiletype(fileMode fs.FileMode) wasiFiletype {
 	wasiFileType := wasiFiletypeUnknown
-	if fileMode&fs.ModeDevice != 0 {
-		wasiFileType = wasiFiletypeBlockDevice
-	} else if fileMode&fs.ModeCharDevice != 0 {
+	// Check fs.ModeCharDevice first, as it is set with fs.ModeDevice.
+	if fileMode&fs.ModeCharDevice != 0 {
 		wasiFileType = wasiFiletypeCharacterDevice
+	} else if fileMode&fs.ModeDevice != 0 {
+		wasiFileType = wasiFiletypeBlockDevice
 	} else if fileMode&fs.ModeDir != 0 {
 		wasiFileType = wasiFiletypeDirectory
+	} else if fileMode&fs.ModeSocket != 0 {
+		wasiFileType = wasiFiletypeSocketStream
 	} else if fileMode&fs.ModeType == 0 {
 		wasiFileType = wasiFiletypeRegularFile
 	} else if fileMode&fs.ModeSymlink != 0 {
@@ -326,8 +614,7 @@ var blockFilestat = []byte{
 	0, 0, 0, 0, 0, 0, 0, 0, // ctim
 }
 
-func writeFilestat(buf []byte, stat fs.FileInfo) {
-	filetype := getWasiFiletype(stat.Mode())
+func writeFilestat(buf []byte, stat fs.FileInfo, filetype wasiFiletype) {
 	filesize := uint64(stat.Size())
 	mtim := stat.ModTime().UnixNano()
 
@@ -493,12 +780,16 @@ func fdPrestatDirNameFn(_ context.Context, mod api.Module, params []uint64) Errn
 // descriptor, without using and updating the file descriptor's offset.
 //
 // See https://github.com/WebAssembly/WASI/blob/snapshot-01/phases/snapshot/docs.md#-fd_pwritefd-fd-iovs-ciovec_array-offset-filesize---errno-size
-var fdPwrite = stubFunction(
-	fdPwriteName,
-	[]wasm.ValueType{i32, i32, i32, i64, i32},
+var fdPwrite = newHostFunc(
+	fdPwriteName, fdPwriteFn,
+	[]api.ValueType{i32, i32, i32, i64, i32},
 	"fd", "iovs", "iovs_len", "offset", "result.nwritten",
 )
 
+func fdPwriteFn(_ context.Context, mod api.Module, params []uint64) Errno {
+	return fdWriteOrPwrite(mod, params, true)
+}
+
 // fdRead is the WASI function named fdReadName which reads from a file
 // descriptor.
 //
@@ -518,6 +809,7 @@ var fdPwrite = stubFunction(
 //   - ErrnoBadf: `fd` is invalid
 //   - ErrnoFault: `iovs` or `resultNread` point to an offset out of memory
 //   - ErrnoIo: a file system error
+//   - ErrnoAgain: `fd` is non-blocking and there is no data to read
 //
 // For example, this function needs to first read `iovs` to determine where
 // to write contents. If parameters iovs=1 iovsCount=2, this function reads two
@@ -544,7 +836,8 @@ var fdPwrite = stubFunction(
 //	                         iovs[1].offset --+           |
 //	                                        resultNread --+
 //
-// Note: This is similar to `readv` in POSIX. https://linux.die.net/man/3/readv
+// Note: This is similar to `readv` in POSIX, as the file is read with a
+// single call, even if there's more than one iovec. https://linux.die.net/man/3/readv
 //
 // See fdWrite
 // and https://github.com/WebAssembly/WASI/blob/snapshot-01/phases/snapshot/docs.md#fd_read
@@ -580,6 +873,10 @@ func fdReadOrPread(mod api.Module, params []uint64, isPread bool) Errno {
 		return ErrnoBadf
 	}
 
+	if !isPread && wouldBlock(fsc, fd) {
+		return ErrnoAgain
+	}
+
 	if isPread {
 		if s, ok := r.(io.Seeker); ok {
 			if _, err := s.Seek(offset, io.SeekStart); err != nil {
@@ -590,54 +887,129 @@ func fdReadOrPread(mod api.Module, params []uint64, isPread bool) Errno {
 		}
 	}
 
-	var nread uint32
+	bufs, ok := readIovecs(mem, iovs, iovsCount)
+	if !ok {
+		return ErrnoFault
+	}
+
+	// Note: When there are both bytes read and an error, this succeeds.
+	// See /RATIONALE.md "Why ignore the error returned by io.Reader when n > 1?"
+	n, err := readv(r, bufs)
+	if err != nil && n == 0 && !errors.Is(err, io.EOF) {
+		return ErrnoIo
+	}
+	if !mem.WriteUint32Le(resultNread, uint32(n)) {
+		return ErrnoFault
+	}
+	return ErrnoSuccess
+}
+
+// readIovecs returns the memory of each iovec in the array at iovs, or false
+// if any is out of range.
+func readIovecs(mem api.Memory, iovs, iovsCount uint32) ([][]byte, bool) {
 	iovsStop := iovsCount << 3 // iovsCount * 8
 	iovsBuf, ok := mem.Read(iovs, iovsStop)
 	if !ok {
-		return ErrnoFault
+		return nil, false
 	}
 
+	bufs := make([][]byte, 0, iovsCount)
 	for iovsPos := uint32(0); iovsPos < iovsStop; iovsPos += 8 {
 		offset := le.Uint32(iovsBuf[iovsPos:])
 		l := le.Uint32(iovsBuf[iovsPos+4:])
 
 		b, ok := mem.Read(offset, l)
 		if !ok {
-			return ErrnoFault
+			return nil, false
 		}
+		bufs = append(bufs, b)
+	}
+	return bufs, true
+}
 
-		n, err := r.Read(b)
-		nread += uint32(n)
+// iovecBufPool has buffers to read or write more than one iovec with a
+// single call, which are reused up to iovecBufMax bytes.
+var iovecBufPool = sync.Pool{New: func() interface{} {
+	b := make([]byte, 0, 4096)
+	return &b
+}}
 
-		shouldContinue, errno := fdRead_shouldContinueRead(uint32(n), l, err)
-		if errno != ErrnoSuccess {
-			return errno
-		} else if !shouldContinue {
-			break
+const iovecBufMax = 64 * 1024
+
+// iovecBuf returns a buffer of the given length, and a function to release it
+// after use.
+func iovecBuf(length int) ([]byte, func()) {
+	if length > iovecBufMax {
+		return make([]byte, length), func() {}
+	}
+	bp := iovecBufPool.Get().(*[]byte)
+	if cap(*bp) < length {
+		*bp = make([]byte, length)
+	}
+	return (*bp)[:length], func() { iovecBufPool.Put(bp) }
+}
+
+// nonEmpty returns the only non-empty buffer in bufs and true, or false if
+// there are more. This is nil if there are none.
+func nonEmpty(bufs [][]byte) (only []byte, ok bool) {
+	for _, b := range bufs {
+		if len(b) == 0 {
+			continue
+		} else if only != nil {
+			return nil, false
 		}
+		only = b
 	}
-	if !mem.WriteUint32Le(resultNread, nread) {
-		return ErrnoFault
-	} else {
-		return ErrnoSuccess
+	return only, true
+}
+
+// readv reads into bufs with a single call to r, like `readv` in POSIX. This
+// reads into a temporary buffer when there's more than one to fill, then
+// copies what was read into each in order.
+func readv(r io.Reader, bufs [][]byte) (int, error) {
+	if b, ok := nonEmpty(bufs); ok {
+		if b == nil {
+			return 0, nil
+		}
+		return r.Read(b)
+	}
+
+	var length int
+	for _, b := range bufs {
+		length += len(b)
 	}
+	buf, release := iovecBuf(length)
+	defer release()
+
+	n, err := r.Read(buf)
+	for i, b := 0, buf[:n]; len(b) > 0; i++ {
+		b = b[copy(bufs[i], b):]
+	}
+	return n, err
 }
 
-// fdRead_shouldContinueRead decides whether to continue reading the next iovec
-// based on the amount read (n/l) and a possible error returned from io.Reader.
-//
-// Note: When there are both bytes read (n) and an error, this continues.
-// See /RATIONALE.md "Why ignore the error returned by io.Reader when n > 1?"
-func fdRead_shouldContinueRead(n, l uint32, err error) (bool, Errno) {
-	if errors.Is(err, io.EOF) {
-		return false, ErrnoSuccess // EOF isn't an error, and we shouldn't continue.
-	} else if err != nil && n == 0 {
-		return false, ErrnoIo
-	} else if err != nil {
-		return false, ErrnoSuccess // Allow the caller to process n bytes.
-	}
-	// Continue reading, unless there's a partial read or nothing to read.
-	return n == l && n != 0, ErrnoSuccess
+// writev writes bufs with a single call to w, like `writev` in POSIX. This
+// copies them into a temporary buffer when there's more than one to write.
+func writev(w io.Writer, bufs [][]byte) (int, error) {
+	if b, ok := nonEmpty(bufs); ok {
+		if b == nil {
+			return 0, nil
+		}
+		return w.Write(b)
+	}
+
+	var length int
+	for _, b := range bufs {
+		length += len(b)
+	}
+	buf, release := iovecBuf(length)
+	defer release()
+
+	pos := 0
+	for _, b := range bufs {
+		pos += copy(buf[pos:], b)
+	}
+	return w.Write(buf)
 }
 
 // fdReaddir is the WASI function named fdReaddirName which reads directory
@@ -663,21 +1035,23 @@ func fdReaddirFn(_ context.Context, mod api.Module, params []uint64) Errno {
 	cookie := int64(params[3])
 	resultBufused := uint32(params[4])
 
-	// The bufLen must be enough to write a dirent. Otherwise, the caller can't
-	// read what the next cookie is.
-	if bufLen < direntSize {
-		return ErrnoInval
-	}
-
 	// Validate the FD is a directory
 	rd, dir, errno := openedDir(fsc, fd)
 	if errno != ErrnoSuccess {
 		return errno
 	}
 
-	// expect a cookie only if we are continuing a read.
-	if cookie == 0 && dir.CountRead > 0 {
-		return ErrnoInval // cookie is minimally one.
+	if cookie < 0 {
+		return ErrnoInval // invalid as we will never send a negative cookie.
+	}
+
+	// Cookies are the absolute position of an entry in the directory. When
+	// the caller asks for a position before our window of cached entries,
+	// such as rewinddir (cookie=0), re-open the directory and skip to it.
+	if firstPos := int64(dir.CountRead) - int64(len(dir.Entries)); cookie < firstPos {
+		if rd, dir, errno = rewindDir(fsc, fd, cookie); errno != ErrnoSuccess {
+			return errno
+		}
 	}
 
 	// First, determine the maximum directory entries that can be encoded as
@@ -693,8 +1067,9 @@ func fdReaddirFn(_ context.Context, mod api.Module, params []uint64) Errno {
 	//	>> directory has been reached.
 	maxDirEntries += 1
 
-	// The host keeps state for any unread entries from the prior call because
-	// we cannot seek to a previous directory position. Collect these entries.
+	// The host keeps state for any unread entries from the prior call, as
+	// seeking to a previous directory position requires re-reading it.
+	// Collect these entries.
 	entries, errno := lastDirEntries(dir, cookie)
 	if errno != ErrnoSuccess {
 		return errno
@@ -714,7 +1089,8 @@ func fdReaddirFn(_ context.Context, mod api.Module, params []uint64) Errno {
 	}
 
 	// Determine how many dirents we can write, excluding a potentially
-	// truncated entry.
+	// truncated entry. When bufLen is smaller than a dirent, only the part of
+	// the first header that fits is written.
 	bufused, direntCount, writeTruncatedEntry := maxDirents(entries, bufLen)
 
 	// Now, write entries to the underlying buffer.
@@ -742,6 +1118,37 @@ func fdReaddirFn(_ context.Context, mod api.Module, params []uint64) Errno {
 
 const largestDirent = int64(math.MaxUint32 - direntSize)
 
+// rewindDir re-opens the directory at fd and skips entries until cookie, so
+// that the next read begins at that position.
+func rewindDir(fsc *internalsys.FSContext, fd uint32, cookie int64) (fs.ReadDirFile, *internalsys.ReadDir, Errno) {
+	if err := fsc.RewindDir(fd); err != nil {
+		return nil, nil, toErrno(err)
+	}
+	rd, dir, errno := openedDir(fsc, fd)
+	if errno != ErrnoSuccess {
+		return nil, nil, errno
+	}
+	for remaining := cookie; remaining > 0; {
+		n := remaining
+		if n > maxDirentsSkip {
+			n = maxDirentsSkip
+		}
+		l, err := rd.ReadDir(int(n))
+		if err == io.EOF {
+			break // lastDirEntries returns ErrnoInval as cookie is past EOF.
+		} else if err != nil {
+			return nil, nil, ErrnoIo
+		}
+		dir.CountRead += uint64(len(l))
+		remaining -= int64(len(l))
+	}
+	return rd, dir, ErrnoSuccess
+}
+
+// maxDirentsSkip bounds the entries read at a time when skipping to a cookie
+// in a large directory.
+const maxDirentsSkip = 1024
+
 // lastDirEntries is broken out from fdReaddirFn for testability.
 func lastDirEntries(dir *internalsys.ReadDir, cookie int64) (entries []fs.DirEntry, errno Errno) {
 	if cookie < 0 {
@@ -750,8 +1157,8 @@ func lastDirEntries(dir *internalsys.ReadDir, cookie int64) (entries []fs.DirEnt
 	}
 
 	entryCount := int64(len(dir.Entries))
-	if entryCount == 0 { // there was no prior call
-		if cookie != 0 {
+	if entryCount == 0 { // no entries are cached, e.g. there was no prior call
+		if cookie != int64(dir.CountRead) {
 			errno = ErrnoInval // invalid as we haven't sent that cookie
 		}
 		return
@@ -763,13 +1170,12 @@ func lastDirEntries(dir *internalsys.ReadDir, cookie int64) (entries []fs.DirEnt
 
 	switch {
 	case cookiePos < 0: // cookie is asking for results outside our window.
-		errno = ErrnoNosys // we can't implement directory seeking backwards.
-	case cookiePos == 0: // cookie is asking for the next page.
+		errno = ErrnoInval // the caller should have used rewindDir.
 	case cookiePos > entryCount:
 		errno = ErrnoInval // invalid as we read that far, yet.
 	case cookiePos > 0: // truncate so to avoid large lists.
 		entries = dir.Entries[cookiePos:]
-	default:
+	default: // cookie is retrying the last page, e.g. with a larger buffer.
 		entries = dir.Entries
 	}
 	if len(entries) == 0 {
@@ -796,11 +1202,14 @@ func maxDirents(entries []fs.DirEntry, bufLen uint32) (bufused, direntCount uint
 	for _, e := range entries {
 		if lenRemaining < direntSize {
 			// We don't have enough space in bufLen for another struct,
-			// entry. A caller who wants more will retry.
+			// entry. A caller who wants more will retry. Write what fits of
+			// the header anyway, as some callers use a buffer smaller than
+			// direntSize and resize based on bufused.
 
 			// bufused == bufLen means more entries exist, which is the case
 			// when the dirent is larger than bytes remaining.
 			bufused = bufLen
+			writeTruncatedEntry = lenRemaining > 0
 			break
 		}
 
@@ -846,7 +1255,8 @@ func maxDirents(entries []fs.DirEntry, bufLen uint32) (bufused, direntCount uint
 
 // writeDirents writes the directory entries to the buffer, which is pre-sized
 // based on maxDirents.	truncatedEntryLen means write one past entryCount,
-// without its name. See maxDirents for why
+// without its name, and possibly only part of its header. See maxDirents for
+// why
 func writeDirents(
 	entries []fs.DirEntry,
 	entryCount uint32,
@@ -1044,7 +1454,8 @@ var fdTell = stubFunction(fdTellName, []wasm.ValueType{i32, i32}, "fd", "result.
 //	          |            |            |           |
 //	 offset --+   length --+   offset --+  length --+
 //
-// This function reads those chunks api.Memory into the `fd` sequentially.
+// This function writes those chunks of api.Memory to the `fd` with a single
+// call, in order.
 //
 //	                    iovs[0].length        iovs[1].length
 //	                   +--------------+       +----+
@@ -1073,64 +1484,133 @@ var fdWrite = newHostFunc(
 	"fd", "iovs", "iovs_len", "result.nwritten",
 )
 
-func fdWriteFn(ctx context.Context, mod api.Module, params []uint64) Errno {
+func fdWriteFn(_ context.Context, mod api.Module, params []uint64) Errno {
+	return fdWriteOrPwrite(mod, params, false)
+}
+
+func fdWriteOrPwrite(mod api.Module, params []uint64, isPwrite bool) Errno {
 	mem := mod.Memory()
 	fsc := mod.(*wasm.CallContext).Sys.FS()
 
 	fd := uint32(params[0])
 	iovs := uint32(params[1])
 	iovsCount := uint32(params[2])
-	resultNwritten := uint32(params[3])
+
+	var offset int64
+	var resultNwritten uint32
+	if isPwrite {
+		offset = int64(params[3])
+		resultNwritten = uint32(params[4])
+	} else {
+		resultNwritten = uint32(params[3])
+	}
 
 	writer := fsc.FdWriter(fd)
 	if writer == nil {
 		return ErrnoBadf
 	}
 
-	var err error
-	var nwritten uint32
-	iovsStop := iovsCount << 3 // iovsCount * 8
-	iovsBuf, ok := mem.Read(iovs, iovsStop)
-	if !ok {
-		return ErrnoFault
-	}
-
-	for iovsPos := uint32(0); iovsPos < iovsStop; iovsPos += 8 {
-		offset := le.Uint32(iovsBuf[iovsPos:])
-		l := le.Uint32(iovsBuf[iovsPos+4:])
-
-		var n int
-		if writer == io.Discard { // special-case default
-			n = int(l)
+	if isPwrite {
+		if wa, ok := writer.(io.WriterAt); ok {
+			writer = &offsetWriter{wa, offset}
 		} else {
-			b, ok := mem.Read(offset, l)
-			if !ok {
-				return ErrnoFault
-			}
-			n, err = writer.Write(b)
-			if err != nil {
+			return ErrnoInval
+		}
+	} else if f, _ := fsc.OpenedFile(fd); f.Flag&os.O_APPEND != 0 {
+		// Emulate append set by fd_fdstat_set_flags by seeking to the end first.
+		if s, ok := writer.(io.Seeker); ok {
+			if _, err := s.Seek(0, io.SeekEnd); err != nil {
 				return ErrnoIo
 			}
 		}
-		nwritten += uint32(n)
 	}
 
-	if !mod.Memory().WriteUint32Le(resultNwritten, nwritten) {
+	var nwritten uint32
+	if writer == io.Discard { // special-case default
+		iovsStop := iovsCount << 3 // iovsCount * 8
+		iovsBuf, ok := mem.Read(iovs, iovsStop)
+		if !ok {
+			return ErrnoFault
+		}
+		for iovsPos := uint32(0); iovsPos < iovsStop; iovsPos += 8 {
+			nwritten += le.Uint32(iovsBuf[iovsPos+4:])
+		}
+	} else {
+		bufs, ok := readIovecs(mem, iovs, iovsCount)
+		if !ok {
+			return ErrnoFault
+		}
+		n, err := writev(writer, bufs)
+		if err != nil {
+			return ErrnoIo
+		}
+		nwritten = uint32(n)
+	}
+
+	if !mem.WriteUint32Le(resultNwritten, nwritten) {
 		return ErrnoFault
 	}
 	return ErrnoSuccess
 }
 
+// offsetWriter is an io.Writer which writes at offset via io.WriterAt.
+type offsetWriter struct {
+	w      io.WriterAt
+	offset int64
+}
+
+// Write implements io.Writer
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	return w.w.WriteAt(p, w.offset)
+}
+
 // pathCreateDirectory is the WASI function named pathCreateDirectoryName
 // which creates a directory.
 //
+// # Parameters
+//
+//   - fd: file descriptor of a directory that `path` is relative to
+//   - path: offset in api.Memory to read the path of the new directory
+//   - pathLen: length of `path`
+//
+// Result (Errno)
+//
+// The return value is ErrnoSuccess except the following error conditions:
+//   - ErrnoBadf: `fd` is invalid
+//   - ErrnoNotdir: `fd` is not a directory
+//   - ErrnoFault: `path` points to an offset out of memory
+//   - ErrnoExist: `path` already exists
+//   - ErrnoNoent: the parent of `path` does not exist
+//   - ErrnoRofs: the file system doesn't support creating directories
+//
+// Note: This is similar to `mkdirat` in POSIX.
 // See https://github.com/WebAssembly/WASI/blob/snapshot-01/phases/snapshot/docs.md#-path_create_directoryfd-fd-path-string---errno
-var pathCreateDirectory = stubFunction(
-	pathCreateDirectoryName,
+// and https://linux.die.net/man/2/mkdirat
+var pathCreateDirectory = newHostFunc(
+	pathCreateDirectoryName, pathCreateDirectoryFn,
 	[]wasm.ValueType{i32, i32, i32},
 	"fd", "path", "path_len",
 )
 
+func pathCreateDirectoryFn(_ context.Context, mod api.Module, params []uint64) Errno {
+	fsc := mod.(*wasm.CallContext).Sys.FS()
+
+	fd := uint32(params[0])
+	pathOffset := uint32(params[1])
+	pathLen := uint32(params[2])
+
+	name, errno := readAtPath(fsc, mod.Memory(), fd, pathOffset, pathLen)
+	if errno != ErrnoSuccess {
+		return errno
+	}
+
+	// Like path_open, this relies on the umask of the host, if any.
+	if err := fsc.Mkdir(name, 0o777); err != nil {
+		return toErrno(err)
+	}
+	return ErrnoSuccess
+}
+
 // pathFilestatGet is the WASI function named pathFilestatGetName which
 // returns the stat attributes of a file or directory.
 //
@@ -1190,13 +1670,9 @@ func pathFilestatGetFn(_ context.Context, mod api.Module, params []uint64) Errno
 	pathName := string(b)
 
 	// Prepend the path if necessary.
-	if dir, ok := fsc.OpenedFile(dirfd); !ok {
-		return ErrnoBadf
-	} else if _, ok := dir.File.(fs.ReadDirFile); !ok {
-		return ErrnoNotdir // TODO: cache filetype instead of poking.
-	} else {
-		// TODO: consolidate "at" logic with path_open as same issues occur.
-		pathName = path.Join(dir.Name, pathName)
+	pathName, errno := atPath(fsc, dirfd, pathName)
+	if errno != ErrnoSuccess {
+		return errno
 	}
 
 	// Stat the file without allocating a file descriptor
@@ -1210,7 +1686,7 @@ func pathFilestatGetFn(_ context.Context, mod api.Module, params []uint64) Errno
 	if !ok {
 		return ErrnoFault
 	}
-	writeFilestat(buf, stat)
+	writeFilestat(buf, stat, getWasiFiletype(stat.Mode()))
 
 	return ErrnoSuccess
 }
@@ -1225,16 +1701,91 @@ var pathFilestatSetTimes = stubFunction(
 	"fd", "flags", "path", "path_len", "atim", "mtim", "fst_flags",
 )
 
-// pathLink is the WASI function named pathLinkName which adjusts the
-// timestamps of a file or directory.
+// atPath returns pathName relative to the directory dirfd.
+//
+// The return value is ErrnoSuccess except the following error conditions:
+//   - ErrnoBadf: `dirfd` is invalid
+//   - ErrnoNotdir: `dirfd` is not a directory
+func atPath(fsc *internalsys.FSContext, dirfd uint32, pathName string) (string, Errno) {
+	if dir, ok := fsc.OpenedFile(dirfd); !ok {
+		return "", ErrnoBadf
+	} else if _, ok := dir.File.(fs.ReadDirFile); !ok {
+		return "", ErrnoNotdir // TODO: cache filetype instead of poking.
+	} else {
+		// TODO: consolidate "at" logic with path_open as same issues occur.
+		return path.Join(dir.Name, pathName), ErrnoSuccess
+	}
+}
+
+// pathLink is the WASI function named pathLinkName which creates a hard
+// link.
+//
+// # Parameters
+//
+//   - oldFd: file descriptor of a directory that `oldPath` is relative to
+//   - oldFlags: flags to indicate how to resolve `oldPath`
+//   - oldPath: offset in api.Memory to read the path of the existing file
+//   - oldPathLen: length of `oldPath`
+//   - newFd: file descriptor of a directory that `newPath` is relative to
+//   - newPath: offset in api.Memory to read the path of the new link
+//   - newPathLen: length of `newPath`
+//
+// Result (Errno)
 //
+// The return value is ErrnoSuccess except the following error conditions:
+//   - ErrnoBadf: `oldFd` or `newFd` is invalid
+//   - ErrnoNotdir: `oldFd` or `newFd` is not a directory
+//   - ErrnoFault: `oldPath` or `newPath` point to an offset out of memory
+//   - ErrnoNoent: `oldPath` does not exist
+//   - ErrnoExist: `newPath` already exists
+//   - ErrnoRofs: the file system doesn't support links
+//
+// Note: This is similar to `linkat` in POSIX.
 // See https://github.com/WebAssembly/WASI/blob/snapshot-01/phases/snapshot/docs.md#path_link
-var pathLink = stubFunction(
-	pathLinkName,
+// and https://linux.die.net/man/2/linkat
+var pathLink = newHostFunc(
+	pathLinkName, pathLinkFn,
 	[]wasm.ValueType{i32, i32, i32, i32, i32, i32, i32},
 	"old_fd", "old_flags", "old_path", "old_path_len", "new_fd", "new_path", "new_path_len",
 )
 
+func pathLinkFn(_ context.Context, mod api.Module, params []uint64) Errno {
+	fsc := mod.(*wasm.CallContext).Sys.FS()
+
+	oldFd := uint32(params[0])
+	// TODO: old_flags is a lookupflags and it only has one bit: symlink_follow
+	_ /* oldFlags */ = uint32(params[1])
+	oldPath := uint32(params[2])
+	oldPathLen := uint32(params[3])
+	newFd := uint32(params[4])
+	newPath := uint32(params[5])
+	newPathLen := uint32(params[6])
+
+	oldName, errno := readAtPath(fsc, mod.Memory(), oldFd, oldPath, oldPathLen)
+	if errno != ErrnoSuccess {
+		return errno
+	}
+	newName, errno := readAtPath(fsc, mod.Memory(), newFd, newPath, newPathLen)
+	if errno != ErrnoSuccess {
+		return errno
+	}
+
+	if err := fsc.Link(oldName, newName); err != nil {
+		return toErrno(err)
+	}
+	return ErrnoSuccess
+}
+
+// readAtPath reads the path from memory and returns it relative to the
+// directory dirfd. See atPath
+func readAtPath(fsc *internalsys.FSContext, mem api.Memory, dirfd, pathOffset, pathLen uint32) (string, Errno) {
+	b, ok := mem.Read(pathOffset, pathLen)
+	if !ok {
+		return "", ErrnoFault
+	}
+	return atPath(fsc, dirfd, string(b))
+}
+
 // pathOpen is the WASI function named pathOpenName which opens a file or
 // directory. This returns ErrnoBadf if the fd is invalid.
 //
@@ -1245,10 +1796,12 @@ var pathLink = stubFunction(
 //   - path: offset in api.Memory to read the path string from
 //   - pathLen: length of `path`
 //   - oFlags: open flags to indicate the method by which to open the file
-//   - fsRightsBase: ignored as rights were removed from WASI.
-//   - fsRightsInheriting: ignored as rights were removed from WASI.
-//     created file descriptor for `path`
-//   - fdFlags: file descriptor flags
+//   - fsRightsBase: rights of the new file descriptor. wasiRightsFdWrite
+//     opens the file for writing and wasiRightsFdRead or wasiRightsFdReaddir
+//     for reading.
+//   - fsRightsInheriting: maximum rights of file descriptors opened from the
+//     new one.
+//   - fdFlags: file descriptor flags, such as wasiFdflagsAppend
 //   - resultOpenedFd: offset in api.Memory to write the newly created file
 //     descriptor to.
 //   - The result FD value is guaranteed to be less than 2**31
@@ -1257,10 +1810,17 @@ var pathLink = stubFunction(
 //
 // The return value is ErrnoSuccess except the following error conditions:
 //   - ErrnoBadf: `fd` is invalid
+//   - ErrnoNotdir: `fd` is not a directory
 //   - ErrnoFault: `resultOpenedFd` points to an offset out of memory
+//   - ErrnoInval: `oFlags` includes wasiOflagsDirectory and a flag that
+//     creates or truncates a file.
+//   - ErrnoNotcapable: the rights requested exceed the inheriting rights of
+//     `fd`.
 //   - ErrnoNoent: `path` does not exist.
 //   - ErrnoExist: `path` exists, while `oFlags` requires that it must not.
 //   - ErrnoNotdir: `path` is not a directory, while `oFlags` requires it.
+//   - ErrnoRofs: the file system is read-only, and `oFlags`, `fsRightsBase`
+//     or `fdFlags` require write access.
 //   - ErrnoIo: a file system error
 //
 // For example, this function needs to first read `path` to determine the file
@@ -1294,22 +1854,41 @@ var pathOpen = newHostFunc(
 	"fd", "dirflags", "path", "path_len", "oflags", "fs_rights_base", "fs_rights_inheriting", "fdflags", "result.opened_fd",
 )
 
+// pathOpenLock is pathOpen, except it places an advisory lock on each regular
+// file opened, which is exclusive if opened for writing. This returns
+// ErrnoAgain if another open file holds a conflicting lock, such as a
+// database opened by another process. Files which can't be locked, such as
+// those not backed by a host file, are opened as usual.
+var pathOpenLock = newHostFunc(
+	pathOpenName, pathOpenLockFn,
+	[]api.ValueType{i32, i32, i32, i32, i32, i64, i64, i32, i32},
+	"fd", "dirflags", "path", "path_len", "oflags", "fs_rights_base", "fs_rights_inheriting", "fdflags", "result.opened_fd",
+)
+
 // wasiOflags are open flags used by pathOpen
 // See https://github.com/WebAssembly/WASI/blob/snapshot-01/phases/snapshot/docs.md#-oflags-flagsu16
 type wasiOflags = byte // actually 16-bit, but there aren't that many.
 const (
-	wasiOflagsNone wasiFdflags = 1<<iota - 1 // nolint
+	wasiOflagsNone wasiOflags = 0 // nolint
 	// wasiOflagsCreat creates a file if it does not exist.
-	wasiOflagsCreat // nolint
+	wasiOflagsCreat wasiOflags = 1 << (iota - 1)
 	// wasiOflagsDirectory fails if not a directory.
 	wasiOflagsDirectory
 	// wasiOflagsExcl fails if file already exists.
-	wasiOflagsExcl // nolint
+	wasiOflagsExcl
 	// wasiOflagsTrunc truncates the file to size 0.
-	wasiOflagsTrunc // nolint
+	wasiOflagsTrunc
 )
 
 func pathOpenFn(_ context.Context, mod api.Module, params []uint64) Errno {
+	return pathOpenFunc(mod, params, false)
+}
+
+func pathOpenLockFn(_ context.Context, mod api.Module, params []uint64) Errno {
+	return pathOpenFunc(mod, params, true)
+}
+
+func pathOpenFunc(mod api.Module, params []uint64, lock bool) Errno {
 	fsc := mod.(*wasm.CallContext).Sys.FS()
 
 	dirfd := uint32(params[0])
@@ -1321,16 +1900,13 @@ func pathOpenFn(_ context.Context, mod api.Module, params []uint64) Errno {
 	path := uint32(params[2])
 	pathLen := uint32(params[3])
 
-	// oflags are currently not something we can pass to the filesystem to
-	// enforce, because fs.FS has no flags parameter. So, we have to validate
-	// them externally, in worst case after the file was already allocated.
 	oflags := wasiOflags(uint32(params[4]))
 
-	// rights aren't used
-	_, _ = params[5], params[6]
+	// Ignore undefined rights, as some compilers request all bits.
+	fsRightsBase := wasiRights(params[5]) & wasiRightsAll
+	fsRightsInheriting := wasiRights(params[6]) & wasiRightsAll
 
-	// TODO: only notable fdflag for opening is wasiFdflagsAppend
-	_ /* fdflags */ = wasiFdflags(uint32(params[7]))
+	fdflags := wasiFdflags(uint32(params[7]))
 	resultOpenedFd := uint32(params[8])
 
 	// Note: We don't handle AT_FDCWD, as that's resolved in the compiler.
@@ -1338,10 +1914,31 @@ func pathOpenFn(_ context.Context, mod api.Module, params []uint64) Errno {
 	// here in any way except assuming it is "/".
 	//
 	// See https://github.com/WebAssembly/wasi-libc/blob/659ff414560721b1660a19685110e484a081c3d4/libc-bottom-half/sources/at_fdcwd.c#L24-L26
-	if _, ok := fsc.OpenedFile(dirfd); !ok {
+	dir, ok := fsc.OpenedFile(dirfd)
+	if !ok {
 		return ErrnoBadf
 	}
 
+	dirStat, err := dir.File.Stat()
+	if err != nil {
+		return toErrno(err)
+	}
+	dirFiletype := getWasiFiletype(dirStat.Mode())
+	if dirFiletype != wasiFiletypeDirectory {
+		return ErrnoNotdir
+	}
+
+	// The rights of the new file descriptor can't exceed what the directory
+	// allows to be inherited.
+	if _, inheriting := fdRights(dir, dirFiletype); (fsRightsBase|fsRightsInheriting)&^inheriting != 0 {
+		return ErrnoNotcapable
+	}
+
+	flag, errno := openFlags(oflags, fsRightsBase, fdflags)
+	if errno != ErrnoSuccess {
+		return errno
+	}
+
 	b, ok := mod.Memory().Read(path, pathLen)
 	if !ok {
 		return ErrnoFault
@@ -1353,7 +1950,7 @@ func pathOpenFn(_ context.Context, mod api.Module, params []uint64) Errno {
 	// path="bar", this should open "/tmp/foo/bar" not "/bar".
 	//
 	// See https://linux.die.net/man/2/openat
-	newFD, errno := openFile(fsc, string(b))
+	newFD, errno := openFile(fsc, string(b), flag, 0o666)
 	if errno != ErrnoSuccess {
 		return errno
 	}
@@ -1365,6 +1962,16 @@ func pathOpenFn(_ context.Context, mod api.Module, params []uint64) Errno {
 		}
 	}
 
+	if lock {
+		if errno = lockFile(fsc, newFD, flag); errno != ErrnoSuccess {
+			_ = fsc.CloseFile(newFD)
+			return errno
+		}
+	}
+
+	f, _ := fsc.OpenedFile(newFD)
+	f.RightsBase, f.RightsInheriting = fsRightsBase, fsRightsInheriting
+
 	if !mod.Memory().WriteUint32Le(resultOpenedFd, newFD) {
 		_ = fsc.CloseFile(newFD)
 		return ErrnoFault
@@ -1372,6 +1979,66 @@ func pathOpenFn(_ context.Context, mod api.Module, params []uint64) Errno {
 	return ErrnoSuccess
 }
 
+// lockFile places an advisory lock on the regular file opened as fd, which
+// is exclusive if it was opened for writing. This succeeds without a lock if
+// the file can't be locked.
+func lockFile(fsc *internalsys.FSContext, fd uint32, flag int) Errno {
+	f, _ := fsc.OpenedFile(fd)
+	if st, err := f.File.Stat(); err != nil {
+		return toErrno(err)
+	} else if !st.Mode().IsRegular() {
+		return ErrnoSuccess
+	}
+	exclusive := flag&(os.O_WRONLY|os.O_RDWR) != 0
+	if err := fsc.Lock(fd, exclusive); err != nil && !errors.Is(err, syscall.ENOSYS) {
+		return toErrno(err)
+	}
+	return ErrnoSuccess
+}
+
+// openFlags returns the flag to open a file with, as defined in os.OpenFile,
+// corresponding to the parameters of pathOpen.
+func openFlags(oflags wasiOflags, fsRightsBase wasiRights, fdflags wasiFdflags) (flag int, errno Errno) {
+	if oflags&wasiOflagsDirectory != 0 {
+		// Directories are only opened for reading.
+		if oflags&(wasiOflagsCreat|wasiOflagsExcl|wasiOflagsTrunc) != 0 {
+			return 0, ErrnoInval
+		}
+	} else {
+		// Determine the access mode from the rights, like other runtimes.
+		// Appending or truncating implies write access.
+		read := fsRightsBase&(wasiRightsFdRead|wasiRightsFdReaddir) != 0
+		write := fsRightsBase&wasiRightsFdWrite != 0 ||
+			fdflags&wasiFdflagsAppend != 0 || oflags&wasiOflagsTrunc != 0
+		switch {
+		case read && write:
+			flag = os.O_RDWR
+		case write:
+			flag = os.O_WRONLY
+		}
+	}
+
+	if oflags&wasiOflagsCreat != 0 {
+		flag |= os.O_CREATE
+		if oflags&wasiOflagsExcl != 0 {
+			flag |= os.O_EXCL // only defined with os.O_CREATE
+		}
+	}
+	if oflags&wasiOflagsTrunc != 0 {
+		flag |= os.O_TRUNC
+	}
+	if fdflags&wasiFdflagsAppend != 0 {
+		flag |= os.O_APPEND
+	}
+	if fdflags&wasiFdflagsNonblock != 0 {
+		flag |= platform.O_NONBLOCK
+	}
+	if fdflags&(wasiFdflagsDsync|wasiFdflagsRsync|wasiFdflagsSync) != 0 {
+		flag |= os.O_SYNC
+	}
+	return flag, ErrnoSuccess
+}
+
 func failIfNotDirectory(fsc *internalsys.FSContext, fd uint32) Errno {
 	// Lookup the previous file
 	if f, ok := fsc.OpenedFile(fd); !ok {
@@ -1386,55 +2053,284 @@ func failIfNotDirectory(fsc *internalsys.FSContext, fd uint32) Errno {
 // pathReadlink is the WASI function named pathReadlinkName that reads the
 // contents of a symbolic link.
 //
+// # Parameters
+//
+//   - fd: file descriptor of a directory that `path` is relative to
+//   - path: offset in api.Memory to read the path of the symbolic link
+//   - pathLen: length of `path`
+//   - buf: offset in api.Memory to write the contents of the symbolic link
+//   - bufLen: maximum length to write to `buf`. Longer contents are
+//     truncated.
+//   - resultBufused: offset in api.Memory to write the length written to `buf`
+//
+// Result (Errno)
+//
+// The return value is ErrnoSuccess except the following error conditions:
+//   - ErrnoBadf: `fd` is invalid
+//   - ErrnoNotdir: `fd` is not a directory
+//   - ErrnoFault: `path`, `buf` or `resultBufused` point to an offset out of
+//     memory
+//   - ErrnoNoent: `path` does not exist
+//   - ErrnoInval: `path` is not a symbolic link
+//   - ErrnoNosys: the file system doesn't support symbolic links
+//
+// Note: This is similar to `readlinkat` in POSIX.
 // See: https://github.com/WebAssembly/WASI/blob/snapshot-01/phases/snapshot/docs.md#-path_readlinkfd-fd-path-string-buf-pointeru8-buf_len-size---errno-size
-var pathReadlink = stubFunction(
-	pathReadlinkName,
+// and https://linux.die.net/man/2/readlinkat
+var pathReadlink = newHostFunc(
+	pathReadlinkName, pathReadlinkFn,
 	[]wasm.ValueType{i32, i32, i32, i32, i32, i32},
 	"fd", "path", "path_len", "buf", "buf_len", "result.bufused",
 )
 
+func pathReadlinkFn(_ context.Context, mod api.Module, params []uint64) Errno {
+	fsc := mod.(*wasm.CallContext).Sys.FS()
+	mem := mod.Memory()
+
+	fd := uint32(params[0])
+	pathOffset := uint32(params[1])
+	pathLen := uint32(params[2])
+	buf := uint32(params[3])
+	bufLen := uint32(params[4])
+	resultBufused := uint32(params[5])
+
+	pathName, errno := readAtPath(fsc, mem, fd, pathOffset, pathLen)
+	if errno != ErrnoSuccess {
+		return errno
+	}
+
+	target, err := fsc.Readlink(pathName)
+	if err != nil {
+		return toErrno(err)
+	}
+
+	// Like readlink, truncate the contents when buf is too small.
+	if uint32(len(target)) > bufLen {
+		target = target[:bufLen]
+	}
+	if !mem.Write(buf, []byte(target)) {
+		return ErrnoFault
+	}
+	if !mem.WriteUint32Le(resultBufused, uint32(len(target))) {
+		return ErrnoFault
+	}
+	return ErrnoSuccess
+}
+
 // pathRemoveDirectory is the WASI function named pathRemoveDirectoryName
 // which removes a directory.
 //
+// # Parameters
+//
+//   - fd: file descriptor of a directory that `path` is relative to
+//   - path: offset in api.Memory to read the path of the directory to remove
+//   - pathLen: length of `path`
+//
+// Result (Errno)
+//
+// The return value is ErrnoSuccess except the following error conditions:
+//   - ErrnoBadf: `fd` is invalid
+//   - ErrnoFault: `path` points to an offset out of memory
+//   - ErrnoNoent: `path` does not exist
+//   - ErrnoNotdir: `fd` or `path` is not a directory
+//   - ErrnoNotempty: `path` is not an empty directory
+//   - ErrnoRofs: the file system doesn't support removing files
+//
+// Note: This is similar to `unlinkat` with AT_REMOVEDIR in POSIX.
 // See https://github.com/WebAssembly/WASI/blob/snapshot-01/phases/snapshot/docs.md#-path_remove_directoryfd-fd-path-string---errno
-var pathRemoveDirectory = stubFunction(
-	pathRemoveDirectoryName,
+// and https://linux.die.net/man/2/unlinkat
+var pathRemoveDirectory = newHostFunc(
+	pathRemoveDirectoryName, pathRemoveDirectoryFn,
 	[]wasm.ValueType{i32, i32, i32},
 	"fd", "path", "path_len",
 )
 
+func pathRemoveDirectoryFn(_ context.Context, mod api.Module, params []uint64) Errno {
+	fsc := mod.(*wasm.CallContext).Sys.FS()
+
+	fd := uint32(params[0])
+	pathOffset := uint32(params[1])
+	pathLen := uint32(params[2])
+
+	name, errno := readAtPath(fsc, mod.Memory(), fd, pathOffset, pathLen)
+	if errno != ErrnoSuccess {
+		return errno
+	}
+
+	if err := fsc.Rmdir(name); err != nil {
+		return toErrno(err)
+	}
+	return ErrnoSuccess
+}
+
 // pathRename is the WASI function named pathRenameName which renames a
 // file or directory.
-var pathRename = stubFunction(
-	pathRenameName,
+//
+// # Parameters
+//
+//   - fd: file descriptor of a directory that `oldPath` is relative to
+//   - oldPath: offset in api.Memory to read the path of the file to rename
+//   - oldPathLen: length of `oldPath`
+//   - newFd: file descriptor of a directory that `newPath` is relative to
+//   - newPath: offset in api.Memory to read the new path of the file
+//   - newPathLen: length of `newPath`
+//
+// Result (Errno)
+//
+// The return value is ErrnoSuccess except the following error conditions:
+//   - ErrnoBadf: `fd` or `newFd` is invalid
+//   - ErrnoFault: `oldPath` or `newPath` point to an offset out of memory
+//   - ErrnoNoent: `oldPath` or the parent of `newPath` does not exist
+//   - ErrnoNotdir: `fd` or `newFd` is not a directory, or `oldPath` is a
+//     directory and `newPath` is a file
+//   - ErrnoIsdir: `oldPath` is a file and `newPath` is a directory
+//   - ErrnoNotempty: `newPath` is not an empty directory
+//   - ErrnoRofs: the file system doesn't support renaming files
+//
+// Notes
+//   - This is similar to `renameat` in POSIX.
+//   - If `newPath` exists, it is replaced.
+//
+// See https://github.com/WebAssembly/WASI/blob/snapshot-01/phases/snapshot/docs.md#-path_renamefd-fd-old_path-string-new_fd-fd-new_path-string---errno
+// and https://linux.die.net/man/2/renameat
+var pathRename = newHostFunc(
+	pathRenameName, pathRenameFn,
 	[]wasm.ValueType{i32, i32, i32, i32, i32, i32},
 	"fd", "old_path", "old_path_len", "new_fd", "new_path", "new_path_len",
 )
 
+func pathRenameFn(_ context.Context, mod api.Module, params []uint64) Errno {
+	fsc := mod.(*wasm.CallContext).Sys.FS()
+
+	fd := uint32(params[0])
+	oldPath := uint32(params[1])
+	oldPathLen := uint32(params[2])
+	newFd := uint32(params[3])
+	newPath := uint32(params[4])
+	newPathLen := uint32(params[5])
+
+	oldName, errno := readAtPath(fsc, mod.Memory(), fd, oldPath, oldPathLen)
+	if errno != ErrnoSuccess {
+		return errno
+	}
+	newName, errno := readAtPath(fsc, mod.Memory(), newFd, newPath, newPathLen)
+	if errno != ErrnoSuccess {
+		return errno
+	}
+
+	if err := fsc.Rename(oldName, newName); err != nil {
+		return toErrno(err)
+	}
+	return ErrnoSuccess
+}
+
 // pathSymlink is the WASI function named pathSymlinkName which creates a
 // symbolic link.
 //
+// # Parameters
+//
+//   - oldPath: offset in api.Memory to read the contents of the symbolic link
+//   - oldPathLen: length of `oldPath`
+//   - fd: file descriptor of a directory that `newPath` is relative to
+//   - newPath: offset in api.Memory to read the path of the symbolic link
+//   - newPathLen: length of `newPath`
+//
+// Result (Errno)
+//
+// The return value is ErrnoSuccess except the following error conditions:
+//   - ErrnoBadf: `fd` is invalid
+//   - ErrnoNotdir: `fd` is not a directory
+//   - ErrnoFault: `oldPath` or `newPath` point to an offset out of memory
+//   - ErrnoPerm: `oldPath` is absolute or escapes the file system root, so
+//     following the link would escape the sandbox.
+//   - ErrnoExist: `newPath` already exists
+//   - ErrnoRofs: the file system doesn't support symbolic links
+//
+// Note: This is similar to `symlinkat` in POSIX.
 // See https://github.com/WebAssembly/WASI/blob/snapshot-01/phases/snapshot/docs.md#path_symlink
-var pathSymlink = stubFunction(
-	pathSymlinkName,
+// and https://linux.die.net/man/2/symlinkat
+var pathSymlink = newHostFunc(
+	pathSymlinkName, pathSymlinkFn,
 	[]wasm.ValueType{i32, i32, i32, i32, i32},
 	"old_path", "old_path_len", "fd", "new_path", "new_path_len",
 )
 
+func pathSymlinkFn(_ context.Context, mod api.Module, params []uint64) Errno {
+	fsc := mod.(*wasm.CallContext).Sys.FS()
+	mem := mod.Memory()
+
+	oldPath := uint32(params[0])
+	oldPathLen := uint32(params[1])
+	fd := uint32(params[2])
+	newPath := uint32(params[3])
+	newPathLen := uint32(params[4])
+
+	target, ok := mem.Read(oldPath, oldPathLen)
+	if !ok {
+		return ErrnoFault
+	}
+	linkName, errno := readAtPath(fsc, mem, fd, newPath, newPathLen)
+	if errno != ErrnoSuccess {
+		return errno
+	}
+
+	if err := fsc.Symlink(string(target), linkName); err != nil {
+		return toErrno(err)
+	}
+	return ErrnoSuccess
+}
+
 // pathUnlinkFile is the WASI function named pathUnlinkFileName which
 // unlinks a file.
 //
+// # Parameters
+//
+//   - fd: file descriptor of a directory that `path` is relative to
+//   - path: offset in api.Memory to read the path of the file to unlink
+//   - pathLen: length of `path`
+//
+// Result (Errno)
+//
+// The return value is ErrnoSuccess except the following error conditions:
+//   - ErrnoBadf: `fd` is invalid
+//   - ErrnoNotdir: `fd` is not a directory
+//   - ErrnoFault: `path` points to an offset out of memory
+//   - ErrnoNoent: `path` does not exist
+//   - ErrnoIsdir: `path` is a directory
+//   - ErrnoRofs: the file system doesn't support removing files
+//
+// Note: This is similar to `unlinkat` in POSIX. A symbolic link is removed,
+// not its target.
 // See https://github.com/WebAssembly/WASI/blob/snapshot-01/phases/snapshot/docs.md#-path_unlink_filefd-fd-path-string---errno
-var pathUnlinkFile = stubFunction(
-	pathUnlinkFileName,
+// and https://linux.die.net/man/2/unlinkat
+var pathUnlinkFile = newHostFunc(
+	pathUnlinkFileName, pathUnlinkFileFn,
 	[]wasm.ValueType{i32, i32, i32},
 	"fd", "path", "path_len",
 )
 
+func pathUnlinkFileFn(_ context.Context, mod api.Module, params []uint64) Errno {
+	fsc := mod.(*wasm.CallContext).Sys.FS()
+
+	fd := uint32(params[0])
+	pathOffset := uint32(params[1])
+	pathLen := uint32(params[2])
+
+	name, errno := readAtPath(fsc, mod.Memory(), fd, pathOffset, pathLen)
+	if errno != ErrnoSuccess {
+		return errno
+	}
+
+	if err := fsc.Unlink(name); err != nil {
+		return toErrno(err)
+	}
+	return ErrnoSuccess
+}
+
 // openFile attempts to open the file at the given path. Errors coerce to WASI
 // Errno.
-func openFile(fsc *internalsys.FSContext, name string) (fd uint32, errno Errno) {
-	newFD, err := fsc.OpenFile(name)
+func openFile(fsc *internalsys.FSContext, name string, flag int, perm fs.FileMode) (fd uint32, errno Errno) {
+	newFD, err := fsc.OpenFile(name, flag, perm)
 	if err == nil {
 		fd = newFD
 		errno = ErrnoSuccess
@@ -1461,6 +2357,48 @@ func statFile(fsc *internalsys.FSContext, name string) (stat fs.FileInfo, errno
 // error codes. For example, wasi-filesystem and GOOS=js don't map to these
 // Errno.
 func toErrno(err error) Errno {
+	// handle errors from the host, such as os.OpenFile, first.
+	switch platform.UnwrapOSError(err) {
+	case syscall.EACCES:
+		return ErrnoAcces
+	case syscall.EAGAIN:
+		return ErrnoAgain
+	case syscall.EBUSY:
+		return ErrnoBusy
+	case syscall.EPERM:
+		return ErrnoPerm
+	case syscall.EEXIST:
+		return ErrnoExist
+	case syscall.EFBIG:
+		return ErrnoFbig
+	case syscall.EINVAL:
+		return ErrnoInval
+	case syscall.EISDIR:
+		return ErrnoIsdir
+	case syscall.ELOOP:
+		return ErrnoLoop
+	case syscall.ENAMETOOLONG:
+		return ErrnoNametoolong
+	case syscall.ENODEV:
+		return ErrnoNodev
+	case syscall.ENOENT:
+		return ErrnoNoent
+	case syscall.ENOSPC:
+		return ErrnoNospc
+	case syscall.ENOSYS:
+		return ErrnoNosys
+	case syscall.ENOTDIR:
+		return ErrnoNotdir
+	case syscall.ENOTEMPTY:
+		return ErrnoNotempty
+	case syscall.EROFS:
+		return ErrnoRofs
+	case syscall.ESPIPE:
+		return ErrnoSpipe
+	case syscall.EXDEV:
+		return ErrnoXdev
+	}
+
 	// handle all the cases of FS.Open or internal to FSContext.OpenFile
 	switch {
 	case errors.Is(err, fs.ErrInvalid):
@@ -2,40 +2,135 @@ package wasi_snapshot_preview1
 
 import (
 	"bytes"
+	"context"
 	_ "embed"
+	"errors"
+	"fmt"
 	"io"
 	"io/fs"
 	"math"
+	"net"
 	"os"
 	"path"
+	"runtime"
 	"testing"
 	"testing/fstest"
 	"time"
 
 	"github.com/tetratelabs/wazero"
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/experimental/memfs"
 	"github.com/tetratelabs/wazero/internal/leb128"
+	"github.com/tetratelabs/wazero/internal/platform"
 	internalsys "github.com/tetratelabs/wazero/internal/sys"
+	"github.com/tetratelabs/wazero/internal/testing/proxy"
 	"github.com/tetratelabs/wazero/internal/testing/require"
 	"github.com/tetratelabs/wazero/internal/wasm"
 )
 
-// Test_fdAdvise only tests it is stubbed for GrainLang per #271
 func Test_fdAdvise(t *testing.T) {
-	log := requireErrnoNosys(t, fdAdviseName, 0, 0, 0, 0)
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(path.Join(tmpDir, "file"), []byte("wazero"), 0o600))
+
+	mod, r, log := requireProxyModule(t, wazero.NewModuleConfig().WithFS(writeableDirFS(tmpDir)))
+	defer r.Close(testCtx)
+	fsc := mod.(*wasm.CallContext).Sys.FS()
+
+	fd, err := fsc.OpenFile("file", os.O_RDONLY, 0)
+	require.NoError(t, err)
+
+	requireErrno(t, ErrnoSuccess, mod, fdAdviseName, uint64(fd), 0, 0, uint64(wasiAdviceSequential))
 	require.Equal(t, `
---> wasi_snapshot_preview1.fd_advise(fd=0,offset=0,len=0,advice=0)
-<-- ENOSYS
-`, log)
+==> wasi_snapshot_preview1.fd_advise(fd=4,offset=0,len=0,advice=SEQUENTIAL)
+<== ESUCCESS
+`, "\n"+log.String())
+
+	for _, advice := range []wasiAdvice{
+		wasiAdviceNormal, wasiAdviceRandom, wasiAdviceWillNeed, wasiAdviceDontNeed, wasiAdviceNoReuse,
+	} {
+		requireErrno(t, ErrnoSuccess, mod, fdAdviseName, uint64(fd), 2, 3, uint64(advice))
+	}
+}
+
+func Test_fdAdvise_Errors(t *testing.T) {
+	mod, fd, log, r := requireOpenFile(t, "/test_path", []byte("wazero"))
+	defer r.Close(testCtx)
+
+	tests := []struct {
+		name          string
+		fd, advice    uint32
+		expectedErrno Errno
+		expectedLog   string
+	}{
+		{
+			name:          "invalid fd",
+			fd:            42, // arbitrary invalid fd
+			expectedErrno: ErrnoBadf,
+			expectedLog: `
+==> wasi_snapshot_preview1.fd_advise(fd=42,offset=0,len=0,advice=NORMAL)
+<== EBADF
+`,
+		},
+		{
+			name:          "invalid advice",
+			fd:            fd,
+			advice:        wasiAdviceNoReuse + 1,
+			expectedErrno: ErrnoInval,
+			expectedLog: `
+==> wasi_snapshot_preview1.fd_advise(fd=4,offset=0,len=0,advice=advice(6))
+<== EINVAL
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			defer log.Reset()
+
+			requireErrno(t, tc.expectedErrno, mod, fdAdviseName, uint64(tc.fd), 0, 0, uint64(tc.advice))
+			require.Equal(t, tc.expectedLog, "\n"+log.String())
+		})
+	}
 }
 
-// Test_fdAllocate only tests it is stubbed for GrainLang per #271
 func Test_fdAllocate(t *testing.T) {
-	log := requireErrnoNosys(t, fdAllocateName, 0, 0, 0)
+	tmpDir := t.TempDir()
+	filePath := path.Join(tmpDir, "file")
+	require.NoError(t, os.WriteFile(filePath, []byte("wazero"), 0o600))
+
+	mod, r, log := requireProxyModule(t, wazero.NewModuleConfig().WithFS(writeableDirFS(tmpDir)))
+	defer r.Close(testCtx)
+	fsc := mod.(*wasm.CallContext).Sys.FS()
+
+	fd, err := fsc.OpenFile("file", os.O_RDWR, 0)
+	require.NoError(t, err)
+	readOnlyFd, err := fsc.OpenFile("file", os.O_RDONLY, 0)
+	require.NoError(t, err)
+
+	requireErrno(t, ErrnoSuccess, mod, fdAllocateName, uint64(fd), 4, 6)
 	require.Equal(t, `
---> wasi_snapshot_preview1.fd_allocate(fd=0,offset=0,len=0)
-<-- ENOSYS
-`, log)
+==> wasi_snapshot_preview1.fd_allocate(fd=4,offset=4,len=6)
+<== ESUCCESS
+`, "\n"+log.String())
+
+	// The file grew to offset+len, keeping its contents.
+	b, err := os.ReadFile(filePath)
+	require.NoError(t, err)
+	require.Equal(t, append([]byte("wazero"), 0, 0, 0, 0), b)
+
+	// Space already allocated doesn't shrink the file.
+	requireErrno(t, ErrnoSuccess, mod, fdAllocateName, uint64(fd), 0, 1)
+	st, err := os.Stat(filePath)
+	require.NoError(t, err)
+	require.Equal(t, int64(10), st.Size())
+
+	requireErrno(t, ErrnoBadf, mod, fdAllocateName, 42, 0, 1)
+	requireErrno(t, ErrnoBadf, mod, fdAllocateName, uint64(readOnlyFd), 0, 1)
+	requireErrno(t, ErrnoBadf, mod, fdAllocateName, uint64(internalsys.FdRoot), 0, 1)
+	requireErrno(t, ErrnoInval, mod, fdAllocateName, uint64(fd), 0, 0)
+	requireErrno(t, ErrnoFbig, mod, fdAllocateName, uint64(fd), math.MaxInt64, 1)
 }
 
 func Test_fdClose(t *testing.T) {
@@ -49,10 +144,10 @@ func Test_fdClose(t *testing.T) {
 	// open both paths without using WASI
 	fsc := mod.(*wasm.CallContext).Sys.FS()
 
-	fdToClose, err := fsc.OpenFile(path1)
+	fdToClose, err := fsc.OpenFile(path1, os.O_RDONLY, 0)
 	require.NoError(t, err)
 
-	fdToKeep, err := fsc.OpenFile(path2)
+	fdToKeep, err := fsc.OpenFile(path2, os.O_RDONLY, 0)
 	require.NoError(t, err)
 
 	// Close
@@ -100,10 +195,10 @@ func Test_fdFdstatGet(t *testing.T) {
 	// open both paths without using WASI
 	fsc := mod.(*wasm.CallContext).Sys.FS()
 
-	fileFd, err := fsc.OpenFile(file)
+	fileFd, err := fsc.OpenFile(file, os.O_RDONLY, 0)
 	require.NoError(t, err)
 
-	dirFd, err := fsc.OpenFile(dir)
+	dirFd, err := fsc.OpenFile(dir, os.O_RDONLY, 0)
 	require.NoError(t, err)
 
 	tests := []struct {
@@ -119,12 +214,12 @@ func Test_fdFdstatGet(t *testing.T) {
 			expectedMemory: []byte{
 				1, 0, // fs_filetype
 				0, 0, 0, 0, 0, 0, // fs_flags
-				0, 0, 0, 0, 0, 0, 0, 0, // fs_rights_base
+				0xff, 0x1, 0xe0, 0x8, 0, 0, 0, 0, // fs_rights_base = wasiRightsFile
 				0, 0, 0, 0, 0, 0, 0, 0, // fs_rights_inheriting
 			},
 			expectedLog: `
 ==> wasi_snapshot_preview1.fd_fdstat_get(fd=0,result.stat=0)
-<== ESUCCESS
+<== (result.stat={filetype=BLOCK_DEVICE,fs_flags=},ESUCCESS)
 `,
 		},
 		{
@@ -133,12 +228,12 @@ func Test_fdFdstatGet(t *testing.T) {
 			expectedMemory: []byte{
 				1, 0, // fs_filetype
 				1, 0, 0, 0, 0, 0, // fs_flags
-				0, 0, 0, 0, 0, 0, 0, 0, // fs_rights_base
+				0xff, 0x1, 0xe0, 0x8, 0, 0, 0, 0, // fs_rights_base = wasiRightsFile
 				0, 0, 0, 0, 0, 0, 0, 0, // fs_rights_inheriting
 			},
 			expectedLog: `
 ==> wasi_snapshot_preview1.fd_fdstat_get(fd=1,result.stat=0)
-<== ESUCCESS
+<== (result.stat={filetype=BLOCK_DEVICE,fs_flags=APPEND},ESUCCESS)
 `,
 		},
 		{
@@ -147,12 +242,12 @@ func Test_fdFdstatGet(t *testing.T) {
 			expectedMemory: []byte{
 				1, 0, // fs_filetype
 				1, 0, 0, 0, 0, 0, // fs_flags
-				0, 0, 0, 0, 0, 0, 0, 0, // fs_rights_base
+				0xff, 0x1, 0xe0, 0x8, 0, 0, 0, 0, // fs_rights_base = wasiRightsFile
 				0, 0, 0, 0, 0, 0, 0, 0, // fs_rights_inheriting
 			},
 			expectedLog: `
 ==> wasi_snapshot_preview1.fd_fdstat_get(fd=2,result.stat=0)
-<== ESUCCESS
+<== (result.stat={filetype=BLOCK_DEVICE,fs_flags=APPEND},ESUCCESS)
 `,
 		},
 		{
@@ -161,12 +256,12 @@ func Test_fdFdstatGet(t *testing.T) {
 			expectedMemory: []byte{
 				3, 0, // fs_filetype
 				0, 0, 0, 0, 0, 0, // fs_flags
-				0, 0, 0, 0, 0, 0, 0, 0, // fs_rights_base
-				0, 0, 0, 0, 0, 0, 0, 0, // fs_rights_inheriting
+				0x98, 0xfe, 0xbf, 0x7, 0, 0, 0, 0, // fs_rights_base = wasiRightsDir
+				0xff, 0xff, 0xff, 0x3f, 0, 0, 0, 0, // fs_rights_inheriting = wasiRightsAll
 			},
 			expectedLog: `
 ==> wasi_snapshot_preview1.fd_fdstat_get(fd=3,result.stat=0)
-<== ESUCCESS
+<== (result.stat={filetype=DIRECTORY,fs_flags=},ESUCCESS)
 `,
 		},
 		{
@@ -175,12 +270,12 @@ func Test_fdFdstatGet(t *testing.T) {
 			expectedMemory: []byte{
 				4, 0, // fs_filetype
 				0, 0, 0, 0, 0, 0, // fs_flags
-				0, 0, 0, 0, 0, 0, 0, 0, // fs_rights_base
+				0xff, 0x1, 0xe0, 0x8, 0, 0, 0, 0, // fs_rights_base = wasiRightsFile
 				0, 0, 0, 0, 0, 0, 0, 0, // fs_rights_inheriting
 			},
 			expectedLog: `
 ==> wasi_snapshot_preview1.fd_fdstat_get(fd=4,result.stat=0)
-<== ESUCCESS
+<== (result.stat={filetype=REGULAR_FILE,fs_flags=},ESUCCESS)
 `,
 		},
 		{
@@ -189,12 +284,12 @@ func Test_fdFdstatGet(t *testing.T) {
 			expectedMemory: []byte{
 				3, 0, // fs_filetype
 				0, 0, 0, 0, 0, 0, // fs_flags
-				0, 0, 0, 0, 0, 0, 0, 0, // fs_rights_base
-				0, 0, 0, 0, 0, 0, 0, 0, // fs_rights_inheriting
+				0x98, 0xfe, 0xbf, 0x7, 0, 0, 0, 0, // fs_rights_base = wasiRightsDir
+				0xff, 0xff, 0xff, 0x3f, 0, 0, 0, 0, // fs_rights_inheriting = wasiRightsAll
 			},
 			expectedLog: `
 ==> wasi_snapshot_preview1.fd_fdstat_get(fd=5,result.stat=0)
-<== ESUCCESS
+<== (result.stat={filetype=DIRECTORY,fs_flags=},ESUCCESS)
 `,
 		},
 		{
@@ -236,13 +331,245 @@ func Test_fdFdstatGet(t *testing.T) {
 	}
 }
 
-// Test_fdFdstatSetFlags only tests it is stubbed for GrainLang per #271
+func Test_fdFdstatGet_inheritedFiles(t *testing.T) {
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer r.Close()
+	defer w.Close()
+
+	devNull, err := os.Open(os.DevNull)
+	require.NoError(t, err)
+	defer devNull.Close()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer pc.Close()
+	udp, err := pc.(*net.UDPConn).File()
+	require.NoError(t, err)
+	defer udp.Close()
+
+	mod, closer, log := requireProxyModule(t, wazero.NewModuleConfig().
+		WithInheritedFile(r).
+		WithInheritedFile(devNull).
+		WithInheritedFile(udp))
+	defer closer.Close(testCtx)
+
+	tests := []struct {
+		name           string
+		fd             uint32
+		expectedMemory []byte
+		expectedLog    string
+	}{
+		{
+			name: "pipe",
+			fd:   3,
+			expectedMemory: []byte{
+				0, 0, // fs_filetype = unknown
+				0, 0, 0, 0, 0, 0, // fs_flags
+				0xff, 0x1, 0xe0, 0x8, 0, 0, 0, 0, // fs_rights_base = wasiRightsFile
+				0, 0, 0, 0, 0, 0, 0, 0, // fs_rights_inheriting
+			},
+			expectedLog: `
+==> wasi_snapshot_preview1.fd_fdstat_get(fd=3,result.stat=0)
+<== (result.stat={filetype=UNKNOWN,fs_flags=},ESUCCESS)
+`,
+		},
+		{
+			name: "character device",
+			fd:   4,
+			expectedMemory: []byte{
+				2, 0, // fs_filetype = character_device
+				0, 0, 0, 0, 0, 0, // fs_flags
+				0xff, 0x1, 0xe0, 0x8, 0, 0, 0, 0, // fs_rights_base = wasiRightsFile
+				0, 0, 0, 0, 0, 0, 0, 0, // fs_rights_inheriting
+			},
+			expectedLog: `
+==> wasi_snapshot_preview1.fd_fdstat_get(fd=4,result.stat=0)
+<== (result.stat={filetype=CHARACTER_DEVICE,fs_flags=},ESUCCESS)
+`,
+		},
+		{
+			name: "datagram socket",
+			fd:   5,
+			expectedMemory: []byte{
+				5, 0, // fs_filetype = socket_dgram
+				0, 0, 0, 0, 0, 0, // fs_flags
+				0x4a, 0x0, 0x20, 0x38, 0, 0, 0, 0, // fs_rights_base = wasiRightsSocket
+				0, 0, 0, 0, 0, 0, 0, 0, // fs_rights_inheriting
+			},
+			expectedLog: `
+==> wasi_snapshot_preview1.fd_fdstat_get(fd=5,result.stat=0)
+<== (result.stat={filetype=SOCKET_DGRAM,fs_flags=},ESUCCESS)
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			defer log.Reset()
+
+			maskMemory(t, mod, len(tc.expectedMemory))
+
+			requireErrno(t, ErrnoSuccess, mod, fdFdstatGetName, uint64(tc.fd), 0)
+			require.Equal(t, tc.expectedLog, "\n"+log.String())
+
+			actual, ok := mod.Memory().Read(0, uint32(len(tc.expectedMemory)))
+			require.True(t, ok)
+			require.Equal(t, tc.expectedMemory, actual)
+		})
+	}
+
+	// Reading the pipe works as for any other file descriptor.
+	_, err = w.Write([]byte("wazero"))
+	require.NoError(t, err)
+	ok := mod.Memory().Write(0, []byte{8, 0, 0, 0, 6, 0, 0, 0}) // iovs[0] = 6 bytes at offset 8
+	require.True(t, ok)
+	requireErrno(t, ErrnoSuccess, mod, fdReadName, 3, 0, 1, 16)
+	buf, ok := mod.Memory().Read(8, 6)
+	require.True(t, ok)
+	require.Equal(t, "wazero", string(buf))
+
+	// Closing the file descriptor doesn't close the host file.
+	requireErrno(t, ErrnoSuccess, mod, fdCloseName, 3)
+	_, err = r.Stat()
+	require.NoError(t, err)
+}
+
 func Test_fdFdstatSetFlags(t *testing.T) {
-	log := requireErrnoNosys(t, fdFdstatSetFlagsName, 0, 0)
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(path.Join(tmpDir, "file"), []byte("wazero"), 0o600))
+
+	mod, r, log := requireProxyModule(t, wazero.NewModuleConfig().WithFS(writeableDirFS(tmpDir)))
+	defer r.Close(testCtx)
+	fsc := mod.(*wasm.CallContext).Sys.FS()
+
+	fd, err := fsc.OpenFile("file", os.O_RDWR, 0)
+	require.NoError(t, err)
+
+	requireErrno(t, ErrnoSuccess, mod, fdFdstatSetFlagsName, uint64(fd), uint64(wasiFdflagsAppend|wasiFdflagsNonblock))
 	require.Equal(t, `
---> wasi_snapshot_preview1.fd_fdstat_set_flags(fd=0,flags=0)
-<-- ENOSYS
-`, log)
+==> wasi_snapshot_preview1.fd_fdstat_set_flags(fd=4,flags=APPEND|NONBLOCK)
+<== ESUCCESS
+`, "\n"+log.String())
+	log.Reset()
+
+	// The access mode is retained, and the flags are visible in fd_fdstat_get.
+	f, ok := fsc.OpenedFile(fd)
+	require.True(t, ok)
+	require.Equal(t, os.O_RDWR|os.O_APPEND|platform.O_NONBLOCK, f.Flag)
+	requireErrno(t, ErrnoSuccess, mod, fdFdstatGetName, uint64(fd), 0)
+	fdflags, ok := mod.Memory().ReadByte(2)
+	require.True(t, ok)
+	require.Equal(t, wasiFdflagsAppend|wasiFdflagsNonblock, fdflags)
+	log.Reset()
+
+	// Writing appends, even though the file offset is at the beginning.
+	ok = mod.Memory().Write(0, []byte{8, 0, 0, 0, 1, 0, 0, 0, '!'}) // iovs[0] = "!"
+	require.True(t, ok)
+	requireErrno(t, ErrnoSuccess, mod, fdWriteName, uint64(fd), 0, 1, 16)
+	buf, err := os.ReadFile(path.Join(tmpDir, "file"))
+	require.NoError(t, err)
+	require.Equal(t, "wazero!", string(buf))
+
+	// A regular file never blocks, so reads succeed despite non-blocking.
+	ok = mod.Memory().Write(0, []byte{8, 0, 0, 0, 1, 0, 0, 0})
+	require.True(t, ok)
+	_, err = f.File.(io.Seeker).Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	requireErrno(t, ErrnoSuccess, mod, fdReadName, uint64(fd), 0, 1, 16)
+
+	// Clearing the flags restores the default.
+	requireErrno(t, ErrnoSuccess, mod, fdFdstatSetFlagsName, uint64(fd), 0)
+	require.Equal(t, os.O_RDWR, f.Flag)
+}
+
+func Test_fdFdstatSetFlags_Errors(t *testing.T) {
+	mod, r, log := requireProxyModule(t, wazero.NewModuleConfig())
+	defer r.Close(testCtx)
+
+	tests := []struct {
+		name          string
+		fd, flags     uint64
+		expectedErrno Errno
+		expectedLog   string
+	}{
+		{
+			name:          "invalid fd",
+			fd:            42,
+			expectedErrno: ErrnoBadf,
+			expectedLog: `
+==> wasi_snapshot_preview1.fd_fdstat_set_flags(fd=42,flags=0)
+<== EBADF
+`,
+		},
+		{
+			name:          "sync",
+			fd:            uint64(internalsys.FdStdout),
+			flags:         uint64(wasiFdflagsSync),
+			expectedErrno: ErrnoInval,
+			expectedLog: `
+==> wasi_snapshot_preview1.fd_fdstat_set_flags(fd=1,flags=SYNC)
+<== EINVAL
+`,
+		},
+		{
+			name:          "undefined",
+			fd:            uint64(internalsys.FdStdout),
+			flags:         1 << 8,
+			expectedErrno: ErrnoInval,
+			expectedLog: `
+==> wasi_snapshot_preview1.fd_fdstat_set_flags(fd=1,flags=0x100)
+<== EINVAL
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			defer log.Reset()
+
+			requireErrno(t, tc.expectedErrno, mod, fdFdstatSetFlagsName, tc.fd, tc.flags)
+			require.Equal(t, tc.expectedLog, "\n"+log.String())
+		})
+	}
+}
+
+func Test_fdRead_nonblock(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip() // because pipes are always reported readable
+	}
+
+	stdinR, stdinW, err := os.Pipe()
+	require.NoError(t, err)
+	defer stdinR.Close()
+	defer stdinW.Close()
+
+	mod, r, log := requireProxyModule(t, wazero.NewModuleConfig().WithStdin(stdinR))
+	defer r.Close(testCtx)
+
+	fd := uint64(internalsys.FdStdin)
+	requireErrno(t, ErrnoSuccess, mod, fdFdstatSetFlagsName, fd, uint64(wasiFdflagsNonblock))
+	log.Reset()
+
+	ok := mod.Memory().Write(0, []byte{8, 0, 0, 0, 6, 0, 0, 0}) // iovs[0] = 8..14
+	require.True(t, ok)
+
+	// Reading an empty pipe would block, so it fails instead.
+	requireErrno(t, ErrnoAgain, mod, fdReadName, fd, 0, 1, 16)
+	require.Equal(t, `
+==> wasi_snapshot_preview1.fd_read(fd=0,iovs=0,iovs_len=1,result.nread=16)
+<== EAGAIN
+`, "\n"+log.String())
+
+	_, err = stdinW.Write([]byte("wazero"))
+	require.NoError(t, err)
+	requireErrno(t, ErrnoSuccess, mod, fdReadName, fd, 0, 1, 16)
+	buf, ok := mod.Memory().Read(8, 6)
+	require.True(t, ok)
+	require.Equal(t, "wazero", string(buf))
 }
 
 // Test_fdFdstatSetRights only tests it is stubbed for GrainLang per #271
@@ -269,10 +596,10 @@ func Test_fdFilestatGet(t *testing.T) {
 	// open both paths without using WASI
 	fsc := mod.(*wasm.CallContext).Sys.FS()
 
-	fileFd, err := fsc.OpenFile(file)
+	fileFd, err := fsc.OpenFile(file, os.O_RDONLY, 0)
 	require.NoError(t, err)
 
-	dirFd, err := fsc.OpenFile(dir)
+	dirFd, err := fsc.OpenFile(dir, os.O_RDONLY, 0)
 	require.NoError(t, err)
 
 	tests := []struct {
@@ -298,7 +625,7 @@ func Test_fdFilestatGet(t *testing.T) {
 			},
 			expectedLog: `
 ==> wasi_snapshot_preview1.fd_filestat_get(fd=0,result.buf=0)
-<== ESUCCESS
+<== (result.buf={filetype=BLOCK_DEVICE,size=0},ESUCCESS)
 `,
 		},
 		{
@@ -317,7 +644,7 @@ func Test_fdFilestatGet(t *testing.T) {
 			},
 			expectedLog: `
 ==> wasi_snapshot_preview1.fd_filestat_get(fd=1,result.buf=0)
-<== ESUCCESS
+<== (result.buf={filetype=BLOCK_DEVICE,size=0},ESUCCESS)
 `,
 		},
 		{
@@ -336,7 +663,7 @@ func Test_fdFilestatGet(t *testing.T) {
 			},
 			expectedLog: `
 ==> wasi_snapshot_preview1.fd_filestat_get(fd=2,result.buf=0)
-<== ESUCCESS
+<== (result.buf={filetype=BLOCK_DEVICE,size=0},ESUCCESS)
 `,
 		},
 		{
@@ -354,7 +681,7 @@ func Test_fdFilestatGet(t *testing.T) {
 			},
 			expectedLog: `
 ==> wasi_snapshot_preview1.fd_filestat_get(fd=3,result.buf=0)
-<== ESUCCESS
+<== (result.buf={filetype=DIRECTORY,size=0},ESUCCESS)
 `,
 		},
 		{
@@ -372,7 +699,7 @@ func Test_fdFilestatGet(t *testing.T) {
 			},
 			expectedLog: `
 ==> wasi_snapshot_preview1.fd_filestat_get(fd=4,result.buf=0)
-<== ESUCCESS
+<== (result.buf={filetype=REGULAR_FILE,size=10},ESUCCESS)
 `,
 		},
 		{
@@ -390,7 +717,7 @@ func Test_fdFilestatGet(t *testing.T) {
 			},
 			expectedLog: `
 ==> wasi_snapshot_preview1.fd_filestat_get(fd=5,result.buf=0)
-<== ESUCCESS
+<== (result.buf={filetype=DIRECTORY,size=0},ESUCCESS)
 `,
 		},
 		{
@@ -827,17 +1154,10 @@ func Test_fdPrestatDirName_Errors(t *testing.T) {
 	}
 }
 
-// Test_fdPwrite only tests it is stubbed for GrainLang per #271
 func Test_fdPwrite(t *testing.T) {
-	log := requireErrnoNosys(t, fdPwriteName, 0, 0, 0, 0, 0)
-	require.Equal(t, `
---> wasi_snapshot_preview1.fd_pwrite(fd=0,iovs=0,iovs_len=0,offset=0,result.nwritten=0)
-<-- ENOSYS
-`, log)
-}
-
-func Test_fdRead(t *testing.T) {
-	mod, fd, log, r := requireOpenFile(t, "/test_path", []byte("wazero"))
+	tmpDir := t.TempDir() // open before loop to ensure no locking problems.
+	pathName := "test_path"
+	mod, fd, log, r := requireOpenWritableFile(t, tmpDir, pathName)
 	defer r.Close(testCtx)
 
 	iovs := uint32(1) // arbitrary offset
@@ -847,44 +1167,108 @@ func Test_fdRead(t *testing.T) {
 		4, 0, 0, 0, // = iovs[0].length
 		23, 0, 0, 0, // = iovs[1].offset
 		2, 0, 0, 0, // = iovs[1].length
+		'?',                // iovs[0].offset is after this
+		'w', 'a', 'z', 'e', // iovs[0].length bytes
+		'?',      // iovs[1].offset is after this
+		'r', 'o', // iovs[1].length bytes
 		'?',
 	}
-	iovsCount := uint32(2)    // The count of iovs
-	resultNread := uint32(26) // arbitrary offset
+	iovsCount := uint32(2)       // The count of iovs
+	resultNwritten := uint32(26) // arbitrary offset
 	expectedMemory := append(
 		initialMemory,
-		'w', 'a', 'z', 'e', // iovs[0].length bytes
-		'?',      // iovs[1].offset is after this
-		'r', 'o', // iovs[1].length bytes
-		'?',        // resultNread is after this
 		6, 0, 0, 0, // sum(iovs[...].length) == length of "wazero"
 		'?',
 	)
 
 	maskMemory(t, mod, len(expectedMemory))
-
 	ok := mod.Memory().Write(0, initialMemory)
 	require.True(t, ok)
 
-	requireErrno(t, ErrnoSuccess, mod, fdReadName, uint64(fd), uint64(iovs), uint64(iovsCount), uint64(resultNread))
+	requireErrno(t, ErrnoSuccess, mod, fdPwriteName, uint64(fd), uint64(iovs), uint64(iovsCount), 2, uint64(resultNwritten))
 	require.Equal(t, `
-==> wasi_snapshot_preview1.fd_read(fd=4,iovs=1,iovs_len=2,result.nread=26)
+==> wasi_snapshot_preview1.fd_pwrite(fd=4,iovs=1,iovs_len=2,offset=2,result.nwritten=26)
 <== ESUCCESS
 `, "\n"+log.String())
 
 	actual, ok := mod.Memory().Read(0, uint32(len(expectedMemory)))
 	require.True(t, ok)
 	require.Equal(t, expectedMemory, actual)
+
+	// The file offset isn't used or updated, so fd_write writes at zero.
+	requireErrno(t, ErrnoSuccess, mod, fdWriteName, uint64(fd), uint64(iovs), 1, uint64(resultNwritten))
+
+	// Since we initialized this file, we know we can read it by path
+	buf, err := os.ReadFile(path.Join(tmpDir, pathName))
+	require.NoError(t, err)
+	require.Equal(t, []byte("wazezero"), buf)
 }
 
-func Test_fdRead_Errors(t *testing.T) {
+func Test_fdPwrite_Errors(t *testing.T) {
 	mod, fd, log, r := requireOpenFile(t, "/test_path", []byte("wazero"))
 	defer r.Close(testCtx)
 
-	tests := []struct {
-		name                             string
-		fd, iovs, iovsCount, resultNread uint32
-		memory                           []byte
+	// Not writable, as fstest.MapFS is read-only.
+	requireErrno(t, ErrnoBadf, mod, fdPwriteName, uint64(fd), 0, 0, 0, 0)
+	// Writable, but not at an offset.
+	requireErrno(t, ErrnoInval, mod, fdPwriteName, uint64(internalsys.FdStdout), 0, 0, 0, 0)
+	require.Equal(t, `
+==> wasi_snapshot_preview1.fd_pwrite(fd=4,iovs=0,iovs_len=0,offset=0,result.nwritten=0)
+<== EBADF
+==> wasi_snapshot_preview1.fd_pwrite(fd=1,iovs=0,iovs_len=0,offset=0,result.nwritten=0)
+<== EINVAL
+`, "\n"+log.String())
+}
+
+func Test_fdRead(t *testing.T) {
+	mod, fd, log, r := requireOpenFile(t, "/test_path", []byte("wazero"))
+	defer r.Close(testCtx)
+
+	iovs := uint32(1) // arbitrary offset
+	initialMemory := []byte{
+		'?',         // `iovs` is after this
+		18, 0, 0, 0, // = iovs[0].offset
+		4, 0, 0, 0, // = iovs[0].length
+		23, 0, 0, 0, // = iovs[1].offset
+		2, 0, 0, 0, // = iovs[1].length
+		'?',
+	}
+	iovsCount := uint32(2)    // The count of iovs
+	resultNread := uint32(26) // arbitrary offset
+	expectedMemory := append(
+		initialMemory,
+		'w', 'a', 'z', 'e', // iovs[0].length bytes
+		'?',      // iovs[1].offset is after this
+		'r', 'o', // iovs[1].length bytes
+		'?',        // resultNread is after this
+		6, 0, 0, 0, // sum(iovs[...].length) == length of "wazero"
+		'?',
+	)
+
+	maskMemory(t, mod, len(expectedMemory))
+
+	ok := mod.Memory().Write(0, initialMemory)
+	require.True(t, ok)
+
+	requireErrno(t, ErrnoSuccess, mod, fdReadName, uint64(fd), uint64(iovs), uint64(iovsCount), uint64(resultNread))
+	require.Equal(t, `
+==> wasi_snapshot_preview1.fd_read(fd=4,iovs=1,iovs_len=2,result.nread=26)
+<== ESUCCESS
+`, "\n"+log.String())
+
+	actual, ok := mod.Memory().Read(0, uint32(len(expectedMemory)))
+	require.True(t, ok)
+	require.Equal(t, expectedMemory, actual)
+}
+
+func Test_fdRead_Errors(t *testing.T) {
+	mod, fd, log, r := requireOpenFile(t, "/test_path", []byte("wazero"))
+	defer r.Close(testCtx)
+
+	tests := []struct {
+		name                             string
+		fd, iovs, iovsCount, resultNread uint32
+		memory                           []byte
 		expectedErrno                    Errno
 		expectedLog                      string
 	}{
@@ -989,91 +1373,144 @@ func Test_fdRead_Errors(t *testing.T) {
 	}
 }
 
-func Test_fdRead_shouldContinueRead(t *testing.T) {
+// countingReadWriter counts calls to Read and Write, to verify iovecs are
+// read or written with a single call.
+type countingReadWriter struct {
+	bytes.Buffer
+	reads, writes int
+}
+
+func (c *countingReadWriter) Read(p []byte) (int, error) {
+	c.reads++
+	return c.Buffer.Read(p)
+}
+
+func (c *countingReadWriter) Write(p []byte) (int, error) {
+	c.writes++
+	return c.Buffer.Write(p)
+}
+
+func Test_readv(t *testing.T) {
 	tests := []struct {
 		name          string
-		n, l          uint32
-		err           error
-		expectedOk    bool
-		expectedErrno Errno
+		lengths       []int
+		expectedBufs  []string
+		expectedN     int
+		expectedReads int
 	}{
 		{
-			name: "break when nothing to read",
-			n:    0,
-			l:    0,
-		},
-		{
-			name: "break when nothing read",
-			n:    0,
-			l:    4,
+			name:         "no iovecs",
+			expectedBufs: []string{},
 		},
 		{
-			name: "break on partial read",
-			n:    3,
-			l:    4,
+			name:         "empty iovecs",
+			lengths:      []int{0, 0},
+			expectedBufs: []string{"", ""},
 		},
 		{
-			name:       "continue on full read",
-			n:          4,
-			l:          4,
-			expectedOk: true,
+			name:          "one iovec",
+			lengths:       []int{0, 4, 0},
+			expectedBufs:  []string{"", "waze", ""},
+			expectedN:     4,
+			expectedReads: 1,
 		},
 		{
-			name: "break on EOF on nothing to read",
-			err:  io.EOF,
-		},
-		{
-			name: "break on EOF on nothing read",
-			l:    4,
-			err:  io.EOF,
+			name:          "iovecs",
+			lengths:       []int{4, 0, 1, 3},
+			expectedBufs:  []string{"waze", "", "r", "o\x00\x00"},
+			expectedN:     6,
+			expectedReads: 1,
 		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			r := &countingReadWriter{}
+			r.WriteString("wazero")
+
+			bufs := make([][]byte, len(tc.lengths))
+			for i, l := range tc.lengths {
+				bufs[i] = make([]byte, l)
+			}
+
+			n, err := readv(r, bufs)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedN, n)
+			require.Equal(t, tc.expectedReads, r.reads)
+
+			actual := make([]string, len(bufs))
+			for i, b := range bufs {
+				actual[i] = string(b)
+			}
+			require.Equal(t, tc.expectedBufs, actual)
+		})
+	}
+}
+
+func Test_readv_EOF(t *testing.T) {
+	n, err := readv(&countingReadWriter{}, [][]byte{make([]byte, 1), make([]byte, 1)})
+	require.Equal(t, 0, n)
+	require.Equal(t, io.EOF, err)
+}
+
+func Test_writev(t *testing.T) {
+	tests := []struct {
+		name           string
+		bufs           []string
+		expectedWrites int
+	}{
 		{
-			name: "break on EOF on partial read",
-			n:    3,
-			l:    4,
-			err:  io.EOF,
+			name: "no iovecs",
 		},
 		{
-			name: "break on EOF on full read",
-			n:    4,
-			l:    4,
-			err:  io.EOF,
+			name: "empty iovecs",
+			bufs: []string{"", ""},
 		},
 		{
-			name:          "return ErrnoIo on error on nothing to read",
-			err:           io.ErrClosedPipe,
-			expectedErrno: ErrnoIo,
+			name:           "one iovec",
+			bufs:           []string{"", "wazero"},
+			expectedWrites: 1,
 		},
 		{
-			name:          "return ErrnoIo on error on nothing read",
-			l:             4,
-			err:           io.ErrClosedPipe,
-			expectedErrno: ErrnoIo,
-		},
-		{ // Special case, allows processing data before err
-			name: "break on error on partial read",
-			n:    3,
-			l:    4,
-			err:  io.ErrClosedPipe,
-		},
-		{ // Special case, allows processing data before err
-			name: "break on error on full read",
-			n:    4,
-			l:    4,
-			err:  io.ErrClosedPipe,
+			name:           "iovecs",
+			bufs:           []string{"waze", "", "r", "o"},
+			expectedWrites: 1,
 		},
 	}
+
 	for _, tt := range tests {
 		tc := tt
-
 		t.Run(tc.name, func(t *testing.T) {
-			ok, errno := fdRead_shouldContinueRead(tc.n, tc.l, tc.err)
-			require.Equal(t, tc.expectedOk, ok)
-			require.Equal(t, tc.expectedErrno, errno)
+			w := &countingReadWriter{}
+
+			var bufs [][]byte
+			var expected string
+			for _, b := range tc.bufs {
+				bufs = append(bufs, []byte(b))
+				expected += b
+			}
+
+			n, err := writev(w, bufs)
+			require.NoError(t, err)
+			require.Equal(t, len(expected), n)
+			require.Equal(t, tc.expectedWrites, w.writes)
+			require.Equal(t, expected, w.String())
 		})
 	}
 }
 
+func Test_writev_large(t *testing.T) {
+	w := &countingReadWriter{}
+	large := bytes.Repeat([]byte{'a'}, iovecBufMax)
+
+	n, err := writev(w, [][]byte{large, []byte("b")})
+	require.NoError(t, err)
+	require.Equal(t, iovecBufMax+1, n)
+	require.Equal(t, 1, w.writes)
+	require.Equal(t, append(large, 'b'), w.Bytes())
+}
+
 var (
 	fdReadDirFs = fstest.MapFS{
 		"notdir":   {},
@@ -1121,7 +1558,7 @@ func Test_fdReaddir(t *testing.T) {
 
 	fsc := mod.(*wasm.CallContext).Sys.FS()
 
-	fd, err := fsc.OpenFile("dir")
+	fd, err := fsc.OpenFile("dir", os.O_RDONLY, 0)
 	require.NoError(t, err)
 
 	tests := []struct {
@@ -1182,6 +1619,48 @@ func Test_fdReaddir(t *testing.T) {
 				Entries:   testDirEntries,
 			},
 		},
+		{
+			name: "buffer smaller than a dirent",
+			dir: func() *internalsys.FileEntry {
+				dir, err := fdReadDirFs.Open("dir")
+				require.NoError(t, err)
+
+				return &internalsys.FileEntry{File: dir}
+			},
+			bufLen:          1, // not long enough for the header of first.
+			cookie:          0,
+			expectedBufused: 1,           // == bufLen, so the caller resizes.
+			expectedMem:     dirent1[:1], // partial header
+			expectedReadDir: &internalsys.ReadDir{
+				CountRead: 2,
+				Entries:   testDirEntries[:2],
+			},
+		},
+		{
+			name: "retry first with a larger buffer",
+			dir: func() *internalsys.FileEntry {
+				dir, err := fdReadDirFs.Open("dir")
+				require.NoError(t, err)
+				entries, err := dir.(fs.ReadDirFile).ReadDir(-1)
+				require.NoError(t, err)
+
+				return &internalsys.FileEntry{
+					File: dir,
+					ReadDir: &internalsys.ReadDir{
+						CountRead: 3,
+						Entries:   entries,
+					},
+				}
+			},
+			bufLen:          4096,
+			cookie:          0,  // same cookie as the prior call
+			expectedBufused: 78, // length of all entries
+			expectedMem:     append(append(dirent1, dirent2...), dirent3...),
+			expectedReadDir: &internalsys.ReadDir{
+				CountRead: 3,
+				Entries:   testDirEntries,
+			},
+		},
 		{
 			name: "read exactly first",
 			dir: func() *internalsys.FileEntry {
@@ -1350,6 +1829,56 @@ func Test_fdReaddir(t *testing.T) {
 				Entries:   testDirEntries[2:],
 			},
 		},
+		{
+			name: "rewind",
+			dir: func() *internalsys.FileEntry {
+				dir, err := fdReadDirFs.Open("dir")
+				require.NoError(t, err)
+				entries, err := dir.(fs.ReadDirFile).ReadDir(-1)
+				require.NoError(t, err)
+
+				return &internalsys.FileEntry{
+					File: dir,
+					ReadDir: &internalsys.ReadDir{
+						CountRead: 3,
+						Entries:   entries[2:],
+					},
+				}
+			},
+			bufLen:          4096,
+			cookie:          0,  // before the cached entries
+			expectedBufused: 78, // length of all entries
+			expectedMem:     append(append(dirent1, dirent2...), dirent3...),
+			expectedReadDir: &internalsys.ReadDir{
+				CountRead: 3,
+				Entries:   testDirEntries,
+			},
+		},
+		{
+			name: "seek to second from the end",
+			dir: func() *internalsys.FileEntry {
+				dir, err := fdReadDirFs.Open("dir")
+				require.NoError(t, err)
+				entries, err := dir.(fs.ReadDirFile).ReadDir(-1)
+				require.NoError(t, err)
+
+				return &internalsys.FileEntry{
+					File: dir,
+					ReadDir: &internalsys.ReadDir{
+						CountRead: 3,
+						Entries:   entries[2:],
+					},
+				}
+			},
+			bufLen:          4096,
+			cookie:          1,  // d_next of first, before the cached entries
+			expectedBufused: 53, // length to read second and third.
+			expectedMem:     append(dirent2, dirent3...),
+			expectedReadDir: &internalsys.ReadDir{
+				CountRead: 3,
+				Entries:   testDirEntries[1:],
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -1393,6 +1922,66 @@ func Test_fdReaddir(t *testing.T) {
 	}
 }
 
+// Test_fdReaddir_pagination ensures callers that page through a large
+// directory with a small buffer see each entry exactly once, including after
+// rewinding to the first entry.
+func Test_fdReaddir_pagination(t *testing.T) {
+	tmpDir := t.TempDir()
+	const fileCount = 2000
+	for i := 0; i < fileCount; i++ {
+		require.NoError(t, os.WriteFile(path.Join(tmpDir, fmt.Sprintf("%04d", i)), nil, 0o600))
+	}
+
+	mod, r, log := requireProxyModule(t, wazero.NewModuleConfig().WithFS(os.DirFS(tmpDir)))
+	defer r.Close(testCtx)
+
+	fd := uint32(internalsys.FdRoot)
+	resultBufused := uint32(0)
+	buf := uint32(8)
+	bufLen := uint32(100) // enough for a few entries
+
+	readAll := func() (names []string) {
+		defer log.Reset()
+
+		cookie := uint64(0)
+		for {
+			requireErrno(t, ErrnoSuccess, mod, fdReaddirName,
+				uint64(fd), uint64(buf), uint64(bufLen), cookie, uint64(resultBufused))
+
+			bufused, ok := mod.Memory().ReadUint32Le(resultBufused)
+			require.True(t, ok)
+			dirents, ok := mod.Memory().Read(buf, bufused)
+			require.True(t, ok)
+
+			// Consume whole entries, like wasi-libc, ignoring a truncated one.
+			for pos := uint32(0); pos+direntSize <= bufused; {
+				nameLen := le.Uint32(dirents[pos+16:])
+				if pos+direntSize+nameLen > bufused {
+					break
+				}
+				cookie = le.Uint64(dirents[pos:])
+				names = append(names, string(dirents[pos+direntSize:pos+direntSize+nameLen]))
+				pos += direntSize + nameLen
+			}
+
+			if bufused < bufLen {
+				return // end of the directory
+			}
+		}
+	}
+
+	names := readAll()
+	require.Equal(t, fileCount, len(names))
+	seen := map[string]struct{}{}
+	for _, n := range names {
+		seen[n] = struct{}{}
+	}
+	require.Equal(t, fileCount, len(seen), "expected no duplicate entries")
+
+	// Reading again from cookie zero rewinds the directory.
+	require.Equal(t, names, readAll())
+}
+
 func Test_fdReaddir_Errors(t *testing.T) {
 	mod, r, log := requireProxyModule(t, wazero.NewModuleConfig().WithFS(fdReadDirFs))
 	defer r.Close(testCtx)
@@ -1400,10 +1989,10 @@ func Test_fdReaddir_Errors(t *testing.T) {
 
 	fsc := mod.(*wasm.CallContext).Sys.FS()
 
-	dirFD, err := fsc.OpenFile("dir")
+	dirFD, err := fsc.OpenFile("dir", os.O_RDONLY, 0)
 	require.NoError(t, err)
 
-	fileFD, err := fsc.OpenFile("notdir")
+	fileFD, err := fsc.OpenFile("notdir", os.O_RDONLY, 0)
 	require.NoError(t, err)
 
 	tests := []struct {
@@ -1468,17 +2057,6 @@ func Test_fdReaddir_Errors(t *testing.T) {
 			expectedLog: `
 ==> wasi_snapshot_preview1.fd_readdir(fd=4,buf=65535,buf_len=1000,cookie=0,result.bufused=0)
 <== EFAULT
-`,
-		},
-		{
-			name: "bufLen must be enough to write a struct",
-			fd:   dirFD,
-			buf:  0, bufLen: 1,
-			resultBufused: 1000,
-			expectedErrno: ErrnoInval,
-			expectedLog: `
-==> wasi_snapshot_preview1.fd_readdir(fd=4,buf=0,buf_len=1,cookie=0,result.bufused=1000)
-<== EINVAL
 `,
 		},
 		{
@@ -1601,7 +2179,23 @@ func Test_lastDirEntries(t *testing.T) {
 				Entries:   testDirEntries,
 			},
 			cookie:        1,
-			expectedErrno: ErrnoNosys, // not implemented
+			expectedErrno: ErrnoInval, // needs rewindDir
+		},
+		{
+			name: "cookie is first pos",
+			f: &internalsys.ReadDir{
+				CountRead: 3,
+				Entries:   testDirEntries[1:],
+			},
+			cookie:          1,
+			expectedEntries: testDirEntries[1:],
+		},
+		{
+			name: "cookie is count read with no entries",
+			f: &internalsys.ReadDir{
+				CountRead: 3,
+			},
+			cookie: 3,
 		},
 	}
 
@@ -1637,7 +2231,7 @@ func Test_maxDirents(t *testing.T) {
 			entries:                     testDirEntries,
 			maxLen:                      23,
 			expectedBufused:             23,
-			expectedwriteTruncatedEntry: false,
+			expectedwriteTruncatedEntry: true, // partial header
 		},
 		{
 			name:                        "only fits header",
@@ -1673,7 +2267,7 @@ func Test_maxDirents(t *testing.T) {
 			entries:                     testDirEntries,
 			maxLen:                      25 + 26 + 20,
 			expectedCount:               2,
-			expectedwriteTruncatedEntry: false, // 20 + 4 == direntSize
+			expectedwriteTruncatedEntry: true, // partial header as 20 < direntSize
 			expectedBufused:             25 + 26 + 20,
 		},
 		{
@@ -1799,7 +2393,7 @@ func Test_fdSeek(t *testing.T) {
 				'?',
 			},
 			expectedLog: `
-==> wasi_snapshot_preview1.fd_seek(fd=4,offset=4,whence=0,result.newoffset=1)
+==> wasi_snapshot_preview1.fd_seek(fd=4,offset=4,whence=SET,result.newoffset=1)
 <== ESUCCESS
 `,
 		},
@@ -1814,7 +2408,7 @@ func Test_fdSeek(t *testing.T) {
 				'?',
 			},
 			expectedLog: `
-==> wasi_snapshot_preview1.fd_seek(fd=4,offset=1,whence=1,result.newoffset=1)
+==> wasi_snapshot_preview1.fd_seek(fd=4,offset=1,whence=CUR,result.newoffset=1)
 <== ESUCCESS
 `,
 		},
@@ -1829,7 +2423,7 @@ func Test_fdSeek(t *testing.T) {
 				'?',
 			},
 			expectedLog: `
-==> wasi_snapshot_preview1.fd_seek(fd=4,offset=-1,whence=2,result.newoffset=1)
+==> wasi_snapshot_preview1.fd_seek(fd=4,offset=-1,whence=END,result.newoffset=1)
 <== ESUCCESS
 `,
 		},
@@ -1886,7 +2480,7 @@ func Test_fdSeek_Errors(t *testing.T) {
 			fd:            42, // arbitrary invalid fd
 			expectedErrno: ErrnoBadf,
 			expectedLog: `
-==> wasi_snapshot_preview1.fd_seek(fd=42,offset=0,whence=0,result.newoffset=0)
+==> wasi_snapshot_preview1.fd_seek(fd=42,offset=0,whence=SET,result.newoffset=0)
 <== EBADF
 `,
 		},
@@ -1896,7 +2490,7 @@ func Test_fdSeek_Errors(t *testing.T) {
 			whence:        3, // invalid whence, the largest whence io.SeekEnd(2) + 1
 			expectedErrno: ErrnoInval,
 			expectedLog: `
-==> wasi_snapshot_preview1.fd_seek(fd=4,offset=0,whence=3,result.newoffset=0)
+==> wasi_snapshot_preview1.fd_seek(fd=4,offset=0,whence=whence(3),result.newoffset=0)
 <== EINVAL
 `,
 		},
@@ -1906,7 +2500,7 @@ func Test_fdSeek_Errors(t *testing.T) {
 			resultNewoffset: memorySize,
 			expectedErrno:   ErrnoFault,
 			expectedLog: `
-==> wasi_snapshot_preview1.fd_seek(fd=4,offset=0,whence=0,result.newoffset=65536)
+==> wasi_snapshot_preview1.fd_seek(fd=4,offset=0,whence=SET,result.newoffset=65536)
 <== EFAULT
 `,
 		},
@@ -1974,7 +2568,7 @@ func Test_fdWrite(t *testing.T) {
 
 	requireErrno(t, ErrnoSuccess, mod, fdWriteName, uint64(fd), uint64(iovs), uint64(iovsCount), uint64(resultNwritten))
 	require.Equal(t, `
-==> wasi_snapshot_preview1.fd_write(fd=4,iovs=1,iovs_len=2,result.nwritten=26)
+==> wasi_snapshot_preview1.fd_write(fd=4,iovs=[4:"waze",2:"ro"],result.nwritten=26)
 <== ESUCCESS
 `, "\n"+log.String())
 
@@ -2025,7 +2619,7 @@ func Test_fdWrite_discard(t *testing.T) {
 	fd := 1 // stdout
 	requireErrno(t, ErrnoSuccess, mod, fdWriteName, uint64(fd), uint64(iovs), uint64(iovsCount), uint64(resultNwritten))
 	require.Equal(t, `
-==> wasi_snapshot_preview1.fd_write(fd=1,iovs=1,iovs_len=2,result.nwritten=26)
+==> wasi_snapshot_preview1.fd_write(fd=1,iovs=[4:"waze",2:"ro"],result.nwritten=26)
 <== ESUCCESS
 `, "\n"+log.String())
 
@@ -2055,7 +2649,7 @@ func Test_fdWrite_Errors(t *testing.T) {
 			fd:            42, // arbitrary invalid fd
 			expectedErrno: ErrnoBadf,
 			expectedLog: `
-==> wasi_snapshot_preview1.fd_write(fd=42,iovs=0,iovs_len=1,result.nwritten=0)
+==> wasi_snapshot_preview1.fd_write(fd=42,iovs=[6907904:"\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"...],result.nwritten=0)
 <== EBADF
 `,
 		},
@@ -2105,7 +2699,7 @@ func Test_fdWrite_Errors(t *testing.T) {
 			resultNwritten: memSize, // read was ok, but there wasn't enough memory to write the result.
 			expectedErrno:  ErrnoFault,
 			expectedLog: `
-==> wasi_snapshot_preview1.fd_write(fd=4,iovs=0,iovs_len=1,result.nwritten=65536)
+==> wasi_snapshot_preview1.fd_write(fd=4,iovs=[6907904:"\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"...],result.nwritten=65536)
 <== EFAULT
 `,
 		},
@@ -2130,59 +2724,145 @@ func Test_fdWrite_Errors(t *testing.T) {
 	}
 }
 
-// Test_pathCreateDirectory only tests it is stubbed for GrainLang per #271
-func Test_pathCreateDirectory(t *testing.T) {
-	log := requireErrnoNosys(t, pathCreateDirectoryName, 0, 0, 0)
-	require.Equal(t, `
---> wasi_snapshot_preview1.path_create_directory(fd=0,path=0,path_len=0)
-<-- ENOSYS
-`, log)
+// requireMemFSModule returns a module whose root is an in-memory file
+// system with a regular file "file", a directory "dir" containing a file and
+// an empty directory "empty". Their names are written to memory at the
+// offsets pathFile, pathDir and pathEmpty, and "miss" at pathMissing.
+func requireMemFSModule(t *testing.T) (api.Module, api.Closer, *bytes.Buffer, *memfs.FS) {
+	m := memfs.New()
+	require.NoError(t, m.WriteFile("file", []byte("wazero"), 0o600))
+	require.NoError(t, m.Mkdir("dir", 0o755))
+	require.NoError(t, m.WriteFile("dir/file", nil, 0o600))
+	require.NoError(t, m.Mkdir("empty", 0o755))
+
+	mod, r, log := requireProxyModule(t, wazero.NewModuleConfig().WithFS(m))
+	require.True(t, mod.Memory().Write(pathFile, []byte("file")))
+	require.True(t, mod.Memory().Write(pathDir, []byte("dir")))
+	require.True(t, mod.Memory().Write(pathEmpty, []byte("empty")))
+	require.True(t, mod.Memory().Write(pathMissing, []byte("miss")))
+	return mod, r, log, m
 }
 
-func Test_pathFilestatGet(t *testing.T) {
-	file, dir := "a", "b"
-	testFS := fstest.MapFS{
-		file:             {Data: make([]byte, 10), ModTime: time.Unix(1667482413, 0)},
-		dir:              {Mode: fs.ModeDir, ModTime: time.Unix(1667482413, 0)},
-		dir + "/" + file: {Data: make([]byte, 20), ModTime: time.Unix(1667482413, 0)},
-	}
-
-	initialMemoryFile := append([]byte{'?'}, file...)
-	initialMemoryDir := append([]byte{'?'}, dir...)
-	initialMemoryNotExists := []byte{'?', '?'}
+// offsets of the paths written by requireMemFSModule.
+const pathFile, pathDir, pathEmpty, pathMissing = 0, 8, 16, 24
 
-	mod, r, log := requireProxyModule(t, wazero.NewModuleConfig().WithFS(testFS))
+func Test_pathCreateDirectory(t *testing.T) {
+	mod, r, log, m := requireMemFSModule(t)
 	defer r.Close(testCtx)
-	memorySize := mod.Memory().Size()
-
-	// open both paths without using WASI
-	fsc := mod.(*wasm.CallContext).Sys.FS()
 
-	rootFd := uint32(3) // after stderr
+	requireErrno(t, ErrnoSuccess, mod, pathCreateDirectoryName, uint64(internalsys.FdRoot), pathMissing, 4)
+	require.Equal(t, `
+==> wasi_snapshot_preview1.path_create_directory(fd=3,path=24,path_len=4)
+<== ESUCCESS
+`, "\n"+log.String())
 
-	fileFd, err := fsc.OpenFile(file)
+	st, err := m.Stat("miss")
 	require.NoError(t, err)
+	require.True(t, st.IsDir())
+}
 
-	dirFd, err := fsc.OpenFile(dir)
-	require.NoError(t, err)
+func Test_pathCreateDirectory_Errors(t *testing.T) {
+	mod, r, log, _ := requireMemFSModule(t)
+	defer r.Close(testCtx)
+	memorySize := mod.Memory().Size()
 
 	tests := []struct {
-		name                        string
-		fd, pathLen, resultFilestat uint32
-		memory, expectedMemory      []byte
-		expectedErrno               Errno
-		expectedLog                 string
+		name          string
+		fd, path      uint32
+		pathLen       uint32
+		expectedErrno Errno
+		expectedLog   string
 	}{
 		{
-			name:           "file under root",
-			fd:             rootFd,
-			memory:         initialMemoryFile,
-			pathLen:        1,
-			resultFilestat: 2,
-			expectedMemory: append(
-				initialMemoryFile,
-				0, 0, 0, 0, 0, 0, 0, 0, // dev
-				0, 0, 0, 0, 0, 0, 0, 0, // ino
+			name:          "invalid fd",
+			fd:            42,
+			path:          pathMissing,
+			pathLen:       4,
+			expectedErrno: ErrnoBadf,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_create_directory(fd=42,path=24,path_len=4)
+<== EBADF
+`,
+		},
+		{
+			name:          "out-of-memory reading path",
+			fd:            internalsys.FdRoot,
+			path:          memorySize,
+			pathLen:       4,
+			expectedErrno: ErrnoFault,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_create_directory(fd=3,path=65536,path_len=4)
+<== EFAULT
+`,
+		},
+		{
+			name:          "path exists",
+			fd:            internalsys.FdRoot,
+			path:          pathFile,
+			pathLen:       4,
+			expectedErrno: ErrnoExist,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_create_directory(fd=3,path=0,path_len=4)
+<== EEXIST
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			defer log.Reset()
+
+			requireErrno(t, tc.expectedErrno, mod, pathCreateDirectoryName, uint64(tc.fd), uint64(tc.path), uint64(tc.pathLen))
+			require.Equal(t, tc.expectedLog, "\n"+log.String())
+		})
+	}
+}
+
+func Test_pathFilestatGet(t *testing.T) {
+	file, dir := "a", "b"
+	testFS := fstest.MapFS{
+		file:             {Data: make([]byte, 10), ModTime: time.Unix(1667482413, 0)},
+		dir:              {Mode: fs.ModeDir, ModTime: time.Unix(1667482413, 0)},
+		dir + "/" + file: {Data: make([]byte, 20), ModTime: time.Unix(1667482413, 0)},
+	}
+
+	initialMemoryFile := append([]byte{'?'}, file...)
+	initialMemoryDir := append([]byte{'?'}, dir...)
+	initialMemoryNotExists := []byte{'?', '?'}
+
+	mod, r, log := requireProxyModule(t, wazero.NewModuleConfig().WithFS(testFS))
+	defer r.Close(testCtx)
+	memorySize := mod.Memory().Size()
+
+	// open both paths without using WASI
+	fsc := mod.(*wasm.CallContext).Sys.FS()
+
+	rootFd := uint32(3) // after stderr
+
+	fileFd, err := fsc.OpenFile(file, os.O_RDONLY, 0)
+	require.NoError(t, err)
+
+	dirFd, err := fsc.OpenFile(dir, os.O_RDONLY, 0)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name                        string
+		fd, pathLen, resultFilestat uint32
+		memory, expectedMemory      []byte
+		expectedErrno               Errno
+		expectedLog                 string
+	}{
+		{
+			name:           "file under root",
+			fd:             rootFd,
+			memory:         initialMemoryFile,
+			pathLen:        1,
+			resultFilestat: 2,
+			expectedMemory: append(
+				initialMemoryFile,
+				0, 0, 0, 0, 0, 0, 0, 0, // dev
+				0, 0, 0, 0, 0, 0, 0, 0, // ino
 				4, 0, 0, 0, 0, 0, 0, 0, // filetype + padding
 				1, 0, 0, 0, 0, 0, 0, 0, // nlink
 				10, 0, 0, 0, 0, 0, 0, 0, // size
@@ -2192,7 +2872,7 @@ func Test_pathFilestatGet(t *testing.T) {
 			),
 			expectedLog: `
 ==> wasi_snapshot_preview1.path_filestat_get(fd=3,flags=0,path=1,path_len=1,result.buf=2)
-<== ESUCCESS
+<== (result.buf={filetype=REGULAR_FILE,size=10},ESUCCESS)
 `,
 		},
 		{
@@ -2214,7 +2894,7 @@ func Test_pathFilestatGet(t *testing.T) {
 			),
 			expectedLog: `
 ==> wasi_snapshot_preview1.path_filestat_get(fd=5,flags=0,path=1,path_len=1,result.buf=2)
-<== ESUCCESS
+<== (result.buf={filetype=REGULAR_FILE,size=20},ESUCCESS)
 `,
 		},
 		{
@@ -2236,7 +2916,7 @@ func Test_pathFilestatGet(t *testing.T) {
 			),
 			expectedLog: `
 ==> wasi_snapshot_preview1.path_filestat_get(fd=3,flags=0,path=1,path_len=1,result.buf=2)
-<== ESUCCESS
+<== (result.buf={filetype=DIRECTORY,size=0},ESUCCESS)
 `,
 		},
 		{
@@ -2350,13 +3030,123 @@ func Test_pathFilestatSetTimes(t *testing.T) {
 `, log)
 }
 
-// Test_pathLink only tests it is stubbed for GrainLang per #271
 func Test_pathLink(t *testing.T) {
-	log := requireErrnoNosys(t, pathLinkName, 0, 0, 0, 0, 0, 0, 0)
+	mod, r, log, tmpDir := requireLinksModule(t)
+	defer r.Close(testCtx)
+
+	oldPath, newPath := uint32(0), uint32(16)
+	require.True(t, mod.Memory().Write(oldPath, []byte("file")))
+	require.True(t, mod.Memory().Write(newPath, []byte("hard")))
+
+	rootFD := uint64(internalsys.FdRoot)
+	requireErrno(t, ErrnoSuccess, mod, pathLinkName, rootFD, 0, uint64(oldPath), 4, rootFD, uint64(newPath), 4)
 	require.Equal(t, `
---> wasi_snapshot_preview1.path_link(old_fd=0,old_flags=0,old_path=0,old_path_len=0,new_fd=0,new_path=0,new_path_len=0)
-<-- ENOSYS
-`, log)
+==> wasi_snapshot_preview1.path_link(old_fd=3,old_flags=0,old_path=0,old_path_len=4,new_fd=3,new_path=16,new_path_len=4)
+<== ESUCCESS
+`, "\n"+log.String())
+
+	oldStat, err := os.Stat(path.Join(tmpDir, "file"))
+	require.NoError(t, err)
+	newStat, err := os.Stat(path.Join(tmpDir, "hard"))
+	require.NoError(t, err)
+	require.True(t, os.SameFile(oldStat, newStat))
+}
+
+func Test_pathLink_Errors(t *testing.T) {
+	mod, r, log, _ := requireLinksModule(t)
+	defer r.Close(testCtx)
+	memorySize := mod.Memory().Size()
+
+	file, missing := uint32(0), uint32(16)
+	require.True(t, mod.Memory().Write(file, []byte("file")))
+	require.True(t, mod.Memory().Write(missing, []byte("miss")))
+
+	rootFD := uint32(internalsys.FdRoot)
+
+	tests := []struct {
+		name                       string
+		oldFd, oldPath, oldPathLen uint32
+		newFd, newPath, newPathLen uint32
+		expectedErrno              Errno
+		expectedLog                string
+	}{
+		{
+			name:  "invalid old fd",
+			oldFd: 42, oldPath: file, oldPathLen: 4,
+			newFd: rootFD, newPath: missing, newPathLen: 4,
+			expectedErrno: ErrnoBadf,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_link(old_fd=42,old_flags=0,old_path=0,old_path_len=4,new_fd=3,new_path=16,new_path_len=4)
+<== EBADF
+`,
+		},
+		{
+			name:  "new fd not a directory",
+			oldFd: rootFD, oldPath: file, oldPathLen: 4,
+			newFd: internalsys.FdStdout, newPath: missing, newPathLen: 4,
+			expectedErrno: ErrnoNotdir,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_link(old_fd=3,old_flags=0,old_path=0,old_path_len=4,new_fd=1,new_path=16,new_path_len=4)
+<== ENOTDIR
+`,
+		},
+		{
+			name:  "out-of-memory reading old path",
+			oldFd: rootFD, oldPath: memorySize, oldPathLen: 4,
+			newFd: rootFD, newPath: missing, newPathLen: 4,
+			expectedErrno: ErrnoFault,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_link(old_fd=3,old_flags=0,old_path=65536,old_path_len=4,new_fd=3,new_path=16,new_path_len=4)
+<== EFAULT
+`,
+		},
+		{
+			name:  "old path doesn't exist",
+			oldFd: rootFD, oldPath: missing, oldPathLen: 4,
+			newFd: rootFD, newPath: missing, newPathLen: 3,
+			expectedErrno: ErrnoNoent,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_link(old_fd=3,old_flags=0,old_path=16,old_path_len=4,new_fd=3,new_path=16,new_path_len=3)
+<== ENOENT
+`,
+		},
+		{
+			name:  "new path exists",
+			oldFd: rootFD, oldPath: file, oldPathLen: 4,
+			newFd: rootFD, newPath: file, newPathLen: 4,
+			expectedErrno: ErrnoExist,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_link(old_fd=3,old_flags=0,old_path=0,old_path_len=4,new_fd=3,new_path=0,new_path_len=4)
+<== EEXIST
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			defer log.Reset()
+
+			requireErrno(t, tc.expectedErrno, mod, pathLinkName, uint64(tc.oldFd), 0, uint64(tc.oldPath),
+				uint64(tc.oldPathLen), uint64(tc.newFd), uint64(tc.newPath), uint64(tc.newPathLen))
+			require.Equal(t, tc.expectedLog, "\n"+log.String())
+		})
+	}
+}
+
+// Test_pathLinks_readOnly ensures links are not supported on a read-only
+// file system.
+func Test_pathLinks_readOnly(t *testing.T) {
+	mod, r, _ := requireProxyModule(t, wazero.NewModuleConfig().WithFS(fstest.MapFS{"file": &fstest.MapFile{}}))
+	defer r.Close(testCtx)
+
+	require.True(t, mod.Memory().Write(0, []byte("file")))
+	require.True(t, mod.Memory().Write(16, []byte("link")))
+	rootFD := uint64(internalsys.FdRoot)
+
+	requireErrno(t, ErrnoRofs, mod, pathLinkName, rootFD, 0, 0, 4, rootFD, 16, 4)
+	requireErrno(t, ErrnoRofs, mod, pathSymlinkName, 0, 4, rootFD, 16, 4)
+	requireErrno(t, ErrnoNosys, mod, pathReadlinkName, rootFD, 0, 4, 32, 16, 48)
 }
 
 func Test_pathOpen(t *testing.T) {
@@ -2377,8 +3167,8 @@ func Test_pathOpen(t *testing.T) {
 	path := uint32(1)
 	pathLen := uint32(len(pathName))
 	oflags := uint32(0)
-	fsRightsBase := uint64(1)       // ignored: rights were removed from WASI.
-	fsRightsInheriting := uint64(2) // ignored: rights were removed from WASI.
+	fsRightsBase := uint64(wasiRightsFdReaddir)
+	fsRightsInheriting := uint64(wasiRightsFdRead)
 	fdflags := uint32(0)
 	resultOpenedFd := uint32(len(initialMemory) + 1)
 
@@ -2393,7 +3183,7 @@ func Test_pathOpen(t *testing.T) {
 	requireErrno(t, ErrnoSuccess, mod, pathOpenName, uint64(rootFD), uint64(dirflags), uint64(path),
 		uint64(pathLen), uint64(oflags), fsRightsBase, fsRightsInheriting, uint64(fdflags), uint64(resultOpenedFd))
 	require.Equal(t, `
-==> wasi_snapshot_preview1.path_open(fd=3,dirflags=0,path=1,path_len=6,oflags=0,fs_rights_base=1,fs_rights_inheriting=2,fdflags=0,result.opened_fd=8)
+==> wasi_snapshot_preview1.path_open(fd=3,dirflags=0,path=wazero,oflags=0,fs_rights_base=FD_READDIR,fs_rights_inheriting=FD_READ,fdflags=0,result.opened_fd=8)
 <== ESUCCESS
 `, "\n"+log.String())
 
@@ -2406,6 +3196,230 @@ func Test_pathOpen(t *testing.T) {
 	f, ok := fsc.OpenedFile(expectedFD)
 	require.True(t, ok)
 	require.Equal(t, pathName, f.Name)
+	require.Equal(t, os.O_RDONLY, f.Flag)
+	require.Equal(t, fsRightsBase, f.RightsBase)
+	require.Equal(t, fsRightsInheriting, f.RightsInheriting)
+}
+
+// writeableDirFS is like os.DirFS, except it supports OpenFile.
+type writeableDirFS string
+
+func (d writeableDirFS) Open(name string) (fs.File, error) {
+	return os.Open(path.Join(string(d), name))
+}
+
+func (d writeableDirFS) OpenFile(name string, flag int, perm fs.FileMode) (fs.File, error) {
+	return os.OpenFile(path.Join(string(d), name), flag, perm)
+}
+
+func (d writeableDirFS) Symlink(oldname, newname string) error {
+	return os.Symlink(oldname, path.Join(string(d), newname))
+}
+
+func (d writeableDirFS) Readlink(name string) (string, error) {
+	return os.Readlink(path.Join(string(d), name))
+}
+
+func (d writeableDirFS) Link(oldname, newname string) error {
+	return os.Link(path.Join(string(d), oldname), path.Join(string(d), newname))
+}
+
+// requireLinksModule returns a module whose root is a temporary directory
+// with a regular file "file" and a symbolic link "link" to it.
+func requireLinksModule(t *testing.T) (api.Module, api.Closer, *bytes.Buffer, string) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(path.Join(tmpDir, "file"), []byte("wazero"), 0o600))
+	require.NoError(t, os.Symlink("file", path.Join(tmpDir, "link")))
+
+	mod, r, log := requireProxyModule(t, wazero.NewModuleConfig().WithFS(writeableDirFS(tmpDir)))
+	return mod, r, log, tmpDir
+}
+
+func TestBuilder_WithFileLocks(t *testing.T) {
+	switch runtime.GOOS {
+	case "darwin", "linux", "freebsd":
+	default:
+		t.Skip("flock unsupported")
+	}
+
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(path.Join(tmpDir, "file"), []byte("wazero"), 0o600))
+
+	var log bytes.Buffer
+	ctx := context.WithValue(testCtx, experimental.FunctionListenerFactoryKey{}, proxy.NewLoggingListenerFactory(&log))
+
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+
+	wasiModuleCompiled, err := NewBuilder(r).WithFileLocks().(*builder).
+		hostModuleBuilder().Compile(ctx)
+	require.NoError(t, err)
+
+	config := wazero.NewModuleConfig().WithFS(writeableDirFS(tmpDir))
+	_, err = r.InstantiateModule(ctx, wasiModuleCompiled, config)
+	require.NoError(t, err)
+
+	proxyCompiled, err := r.CompileModule(ctx, proxy.NewModuleBinary(ModuleName, wasiModuleCompiled))
+	require.NoError(t, err)
+
+	mod, err := r.InstantiateModule(ctx, proxyCompiled, config)
+	require.NoError(t, err)
+
+	rootFD := uint64(internalsys.FdRoot)
+	readOnly := uint64(wasiRightsFdRead)
+	readWrite := uint64(wasiRightsFdRead | wasiRightsFdWrite)
+	resultOpenedFd := uint32(8)
+	require.True(t, mod.Memory().WriteString(0, "file"))
+
+	// Open the file for writing, which locks it exclusively.
+	requireErrno(t, ErrnoSuccess, mod, pathOpenName, rootFD, 0, 0, 4, 0, readWrite, 0, 0, uint64(resultOpenedFd))
+	fd, ok := mod.Memory().ReadUint32Le(resultOpenedFd)
+	require.True(t, ok)
+
+	// Another open can't take a shared lock until it is closed.
+	requireErrno(t, ErrnoAgain, mod, pathOpenName, rootFD, 0, 0, 4, 0, readOnly, 0, 0, uint64(resultOpenedFd))
+	requireErrno(t, ErrnoSuccess, mod, fdCloseName, uint64(fd))
+	requireErrno(t, ErrnoSuccess, mod, pathOpenName, rootFD, 0, 0, 4, 0, readOnly, 0, 0, uint64(resultOpenedFd))
+	require.Equal(t, `
+==> wasi_snapshot_preview1.path_open(fd=3,dirflags=0,path=file,oflags=0,fs_rights_base=FD_READ|FD_WRITE,fs_rights_inheriting=0,fdflags=0,result.opened_fd=8)
+<== ESUCCESS
+==> wasi_snapshot_preview1.path_open(fd=3,dirflags=0,path=file,oflags=0,fs_rights_base=FD_READ,fs_rights_inheriting=0,fdflags=0,result.opened_fd=8)
+<== EAGAIN
+==> wasi_snapshot_preview1.fd_close(fd=4)
+<== ESUCCESS
+==> wasi_snapshot_preview1.path_open(fd=3,dirflags=0,path=file,oflags=0,fs_rights_base=FD_READ,fs_rights_inheriting=0,fdflags=0,result.opened_fd=8)
+<== ESUCCESS
+`, "\n"+log.String())
+}
+
+func Test_pathOpen_flags(t *testing.T) {
+	rootFD := uint64(internalsys.FdRoot)
+	readWrite := uint64(wasiRightsFdRead | wasiRightsFdWrite)
+
+	tests := []struct {
+		name                 string
+		oflags, fdflags      uint32
+		fsRightsBase         uint64
+		expectedFlag         int
+		expectedFdflags      wasiFdflags
+		expectedErrno        Errno
+		expectedContents     string
+		expectedCreatedExist bool
+	}{
+		{
+			name:             "read-only",
+			fsRightsBase:     uint64(wasiRightsFdRead),
+			expectedFlag:     os.O_RDONLY,
+			expectedContents: "wazero",
+		},
+		{
+			name:             "read-write",
+			fsRightsBase:     readWrite,
+			expectedFlag:     os.O_RDWR,
+			expectedContents: "wazero",
+		},
+		{
+			name:             "write-only",
+			fsRightsBase:     uint64(wasiRightsFdWrite),
+			expectedFlag:     os.O_WRONLY,
+			expectedContents: "wazero",
+		},
+		{
+			name:             "trunc",
+			oflags:           uint32(wasiOflagsTrunc),
+			fsRightsBase:     readWrite,
+			expectedFlag:     os.O_RDWR | os.O_TRUNC,
+			expectedContents: "",
+		},
+		{
+			name:             "append",
+			fdflags:          uint32(wasiFdflagsAppend),
+			fsRightsBase:     uint64(wasiRightsFdRead),
+			expectedFlag:     os.O_RDWR | os.O_APPEND,
+			expectedFdflags:  wasiFdflagsAppend,
+			expectedContents: "wazero",
+		},
+		{
+			name:             "sync",
+			fdflags:          uint32(wasiFdflagsSync),
+			fsRightsBase:     readWrite,
+			expectedFlag:     os.O_RDWR | os.O_SYNC,
+			expectedFdflags:  wasiFdflagsSync,
+			expectedContents: "wazero",
+		},
+		{
+			name:             "create existing",
+			oflags:           uint32(wasiOflagsCreat),
+			fsRightsBase:     readWrite,
+			expectedFlag:     os.O_RDWR | os.O_CREATE,
+			expectedContents: "wazero",
+		},
+		{
+			name:          "create exclusive existing",
+			oflags:        uint32(wasiOflagsCreat | wasiOflagsExcl),
+			fsRightsBase:  readWrite,
+			expectedErrno: ErrnoExist,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			pathName := "file"
+			realPath := path.Join(tmpDir, pathName)
+			require.NoError(t, os.WriteFile(realPath, []byte("wazero"), 0o600))
+
+			mod, r, log := requireProxyModule(t, wazero.NewModuleConfig().WithFS(writeableDirFS(tmpDir)))
+			defer r.Close(testCtx)
+			defer log.Reset()
+
+			ok := mod.Memory().Write(0, []byte(pathName))
+			require.True(t, ok)
+			resultOpenedFd := uint32(16)
+
+			requireErrno(t, tc.expectedErrno, mod, pathOpenName, rootFD, 0, 0, uint64(len(pathName)),
+				uint64(tc.oflags), tc.fsRightsBase, 0, uint64(tc.fdflags), uint64(resultOpenedFd))
+			if tc.expectedErrno != ErrnoSuccess {
+				return
+			}
+
+			fd, ok := mod.Memory().ReadUint32Le(resultOpenedFd)
+			require.True(t, ok)
+			f, ok := mod.(*wasm.CallContext).Sys.FS().OpenedFile(fd)
+			require.True(t, ok)
+			require.Equal(t, tc.expectedFlag, f.Flag)
+
+			// Verify the flags and rights are visible to the guest.
+			resultFdstat := uint32(32)
+			requireErrno(t, ErrnoSuccess, mod, fdFdstatGetName, uint64(fd), uint64(resultFdstat))
+			fdstat, ok := mod.Memory().Read(resultFdstat, 24)
+			require.True(t, ok)
+			require.Equal(t, tc.expectedFdflags, fdstat[2])
+			require.Equal(t, tc.fsRightsBase, le.Uint64(fdstat[8:]))
+
+			contents, err := os.ReadFile(realPath)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedContents, string(contents))
+		})
+	}
+
+	t.Run("create", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		pathName := "new"
+
+		mod, r, _ := requireProxyModule(t, wazero.NewModuleConfig().WithFS(writeableDirFS(tmpDir)))
+		defer r.Close(testCtx)
+
+		ok := mod.Memory().Write(0, []byte(pathName))
+		require.True(t, ok)
+
+		requireErrno(t, ErrnoSuccess, mod, pathOpenName, rootFD, 0, 0, uint64(len(pathName)),
+			uint64(wasiOflagsCreat|wasiOflagsExcl), readWrite, 0, 0, 16)
+
+		_, err := os.Stat(path.Join(tmpDir, pathName))
+		require.NoError(t, err)
+	})
 }
 
 func Test_pathOpen_Errors(t *testing.T) {
@@ -2419,12 +3433,21 @@ func Test_pathOpen_Errors(t *testing.T) {
 	mod, r, log := requireProxyModule(t, wazero.NewModuleConfig().WithFS(testFS))
 	defer r.Close(testCtx)
 
+	// Open a directory which only allows files to be read from it.
+	fsc := mod.(*wasm.CallContext).Sys.FS()
+	restrictedFD, err := fsc.OpenFile(dirName, os.O_RDONLY, 0)
+	require.NoError(t, err)
+	restricted, _ := fsc.OpenedFile(restrictedFD)
+	restricted.RightsBase, restricted.RightsInheriting = wasiRightsDir, wasiRightsFdRead
+
 	validPath := uint32(0)    // arbitrary offset
 	validPathLen := uint32(6) // the length of dirName
 
 	tests := []struct {
 		name, pathName                            string
 		fd, path, pathLen, oflags, resultOpenedFd uint32
+		fsRightsBase                              uint64
+		fdflags                                   uint32
 		expectedErrno                             Errno
 		expectedLog                               string
 	}{
@@ -2433,7 +3456,7 @@ func Test_pathOpen_Errors(t *testing.T) {
 			fd:            42, // arbitrary invalid fd
 			expectedErrno: ErrnoBadf,
 			expectedLog: `
-==> wasi_snapshot_preview1.path_open(fd=42,dirflags=0,path=0,path_len=0,oflags=0,fs_rights_base=0,fs_rights_inheriting=0,fdflags=0,result.opened_fd=0)
+==> wasi_snapshot_preview1.path_open(fd=42,dirflags=0,path=,oflags=0,fs_rights_base=0,fs_rights_inheriting=0,fdflags=0,result.opened_fd=0)
 <== EBADF
 `,
 		},
@@ -2456,7 +3479,7 @@ func Test_pathOpen_Errors(t *testing.T) {
 			// fstest.MapFS returns file not found instead of invalid on invalid path
 			expectedErrno: ErrnoNoent,
 			expectedLog: `
-==> wasi_snapshot_preview1.path_open(fd=3,dirflags=0,path=0,path_len=6,oflags=0,fs_rights_base=0,fs_rights_inheriting=0,fdflags=0,result.opened_fd=0)
+==> wasi_snapshot_preview1.path_open(fd=3,dirflags=0,path=../foo,oflags=0,fs_rights_base=0,fs_rights_inheriting=0,fdflags=0,result.opened_fd=0)
 <== ENOENT
 `,
 		},
@@ -2479,7 +3502,7 @@ func Test_pathOpen_Errors(t *testing.T) {
 			pathLen:       validPathLen - 1, // this make the path "wazer", which doesn't exit
 			expectedErrno: ErrnoNoent,
 			expectedLog: `
-==> wasi_snapshot_preview1.path_open(fd=3,dirflags=0,path=0,path_len=5,oflags=0,fs_rights_base=0,fs_rights_inheriting=0,fdflags=0,result.opened_fd=0)
+==> wasi_snapshot_preview1.path_open(fd=3,dirflags=0,path=wazer,oflags=0,fs_rights_base=0,fs_rights_inheriting=0,fdflags=0,result.opened_fd=0)
 <== ENOENT
 `,
 		},
@@ -2492,7 +3515,7 @@ func Test_pathOpen_Errors(t *testing.T) {
 			resultOpenedFd: mod.Memory().Size(), // path and pathLen correctly point to the right path, but where to write the opened FD is outside memory.
 			expectedErrno:  ErrnoFault,
 			expectedLog: `
-==> wasi_snapshot_preview1.path_open(fd=3,dirflags=0,path=0,path_len=6,oflags=0,fs_rights_base=0,fs_rights_inheriting=0,fdflags=0,result.opened_fd=65536)
+==> wasi_snapshot_preview1.path_open(fd=3,dirflags=0,path=wazero,oflags=0,fs_rights_base=0,fs_rights_inheriting=0,fdflags=0,result.opened_fd=65536)
 <== EFAULT
 `,
 		},
@@ -2505,69 +3528,610 @@ func Test_pathOpen_Errors(t *testing.T) {
 			pathLen:       validPathLen,
 			expectedErrno: ErrnoNotdir,
 			expectedLog: `
-==> wasi_snapshot_preview1.path_open(fd=3,dirflags=0,path=0,path_len=6,oflags=3,fs_rights_base=0,fs_rights_inheriting=0,fdflags=0,result.opened_fd=0)
+==> wasi_snapshot_preview1.path_open(fd=3,dirflags=0,path=notdir,oflags=DIRECTORY,fs_rights_base=0,fs_rights_inheriting=0,fdflags=0,result.opened_fd=0)
 <== ENOTDIR
 `,
 		},
-	}
-
-	for _, tt := range tests {
-		tc := tt
-		t.Run(tc.name, func(t *testing.T) {
-			defer log.Reset()
-
-			mod.Memory().Write(validPath, []byte(tc.pathName))
-
-			requireErrno(t, tc.expectedErrno, mod, pathOpenName, uint64(tc.fd), uint64(0), uint64(tc.path),
-				uint64(tc.pathLen), uint64(tc.oflags), 0, 0, 0, uint64(tc.resultOpenedFd))
-			require.Equal(t, tc.expectedLog, "\n"+log.String())
-		})
-	}
-}
-
-// Test_pathReadlink only tests it is stubbed for GrainLang per #271
-func Test_pathReadlink(t *testing.T) {
-	log := requireErrnoNosys(t, pathReadlinkName, 0, 0, 0, 0, 0, 0)
-	require.Equal(t, `
---> wasi_snapshot_preview1.path_readlink(fd=0,path=0,path_len=0,buf=0,buf_len=0,result.bufused=0)
-<-- ENOSYS
-`, log)
-}
-
-// Test_pathRemoveDirectory only tests it is stubbed for GrainLang per #271
-func Test_pathRemoveDirectory(t *testing.T) {
-	log := requireErrnoNosys(t, pathRemoveDirectoryName, 0, 0, 0)
-	require.Equal(t, `
---> wasi_snapshot_preview1.path_remove_directory(fd=0,path=0,path_len=0)
-<-- ENOSYS
-`, log)
-}
-
-// Test_pathRename only tests it is stubbed for GrainLang per #271
-func Test_pathRename(t *testing.T) {
-	log := requireErrnoNosys(t, pathRenameName, 0, 0, 0, 0, 0, 0)
-	require.Equal(t, `
---> wasi_snapshot_preview1.path_rename(fd=0,old_path=0,old_path_len=0,new_fd=0,new_path=0,new_path_len=0)
-<-- ENOSYS
-`, log)
+		{
+			name:          "fd not a directory",
+			fd:            internalsys.FdStdout,
+			pathName:      dirName,
+			path:          validPath,
+			pathLen:       validPathLen,
+			expectedErrno: ErrnoNotdir,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_open(fd=1,dirflags=0,path=wazero,oflags=0,fs_rights_base=0,fs_rights_inheriting=0,fdflags=0,result.opened_fd=0)
+<== ENOTDIR
+`,
+		},
+		{
+			name:          "oflags=directory and create",
+			oflags:        uint32(wasiOflagsDirectory | wasiOflagsCreat),
+			fd:            validFD,
+			pathName:      dirName,
+			path:          validPath,
+			pathLen:       validPathLen,
+			expectedErrno: ErrnoInval,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_open(fd=3,dirflags=0,path=wazero,oflags=CREAT|DIRECTORY,fs_rights_base=0,fs_rights_inheriting=0,fdflags=0,result.opened_fd=0)
+<== EINVAL
+`,
+		},
+		{
+			name:          "oflags=create and exclusive, but exists",
+			oflags:        uint32(wasiOflagsCreat | wasiOflagsExcl),
+			fd:            validFD,
+			pathName:      fileName,
+			path:          validPath,
+			pathLen:       validPathLen,
+			expectedErrno: ErrnoExist,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_open(fd=3,dirflags=0,path=notdir,oflags=CREAT|EXCL,fs_rights_base=0,fs_rights_inheriting=0,fdflags=0,result.opened_fd=0)
+<== EEXIST
+`,
+		},
+		{
+			name:          "oflags=create, but read-only",
+			oflags:        uint32(wasiOflagsCreat),
+			fd:            validFD,
+			pathName:      dirName,
+			path:          validPath,
+			pathLen:       validPathLen - 1, // this make the path "wazer", which doesn't exit
+			expectedErrno: ErrnoRofs,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_open(fd=3,dirflags=0,path=wazer,oflags=CREAT,fs_rights_base=0,fs_rights_inheriting=0,fdflags=0,result.opened_fd=0)
+<== EROFS
+`,
+		},
+		{
+			name:          "oflags=trunc, but read-only",
+			oflags:        uint32(wasiOflagsTrunc),
+			fd:            validFD,
+			pathName:      fileName,
+			path:          validPath,
+			pathLen:       validPathLen,
+			expectedErrno: ErrnoRofs,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_open(fd=3,dirflags=0,path=notdir,oflags=TRUNC,fs_rights_base=0,fs_rights_inheriting=0,fdflags=0,result.opened_fd=0)
+<== EROFS
+`,
+		},
+		{
+			name:          "fdflags=append, but read-only",
+			fdflags:       uint32(wasiFdflagsAppend),
+			fd:            validFD,
+			pathName:      fileName,
+			path:          validPath,
+			pathLen:       validPathLen,
+			expectedErrno: ErrnoRofs,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_open(fd=3,dirflags=0,path=notdir,oflags=0,fs_rights_base=0,fs_rights_inheriting=0,fdflags=APPEND,result.opened_fd=0)
+<== EROFS
+`,
+		},
+		{
+			name:          "fd_write right, but read-only",
+			fsRightsBase:  wasiRightsFdWrite,
+			fd:            validFD,
+			pathName:      fileName,
+			path:          validPath,
+			pathLen:       validPathLen,
+			expectedErrno: ErrnoRofs,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_open(fd=3,dirflags=0,path=notdir,oflags=0,fs_rights_base=FD_WRITE,fs_rights_inheriting=0,fdflags=0,result.opened_fd=0)
+<== EROFS
+`,
+		},
+		{
+			name:          "rights not inheritable",
+			fsRightsBase:  wasiRightsFdRead | wasiRightsFdWrite,
+			fd:            restrictedFD,
+			pathName:      fileName,
+			path:          validPath,
+			pathLen:       validPathLen,
+			expectedErrno: ErrnoNotcapable,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_open(fd=4,dirflags=0,path=notdir,oflags=0,fs_rights_base=FD_READ|FD_WRITE,fs_rights_inheriting=0,fdflags=0,result.opened_fd=0)
+<== ENOTCAPABLE
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			defer log.Reset()
+
+			mod.Memory().Write(validPath, []byte(tc.pathName))
+
+			requireErrno(t, tc.expectedErrno, mod, pathOpenName, uint64(tc.fd), uint64(0), uint64(tc.path),
+				uint64(tc.pathLen), uint64(tc.oflags), tc.fsRightsBase, 0, uint64(tc.fdflags), uint64(tc.resultOpenedFd))
+			require.Equal(t, tc.expectedLog, "\n"+log.String())
+		})
+	}
+}
+
+func Test_pathReadlink(t *testing.T) {
+	mod, r, log, _ := requireLinksModule(t)
+	defer r.Close(testCtx)
+
+	pathName := "link"
+	path, buf, resultBufused := uint32(0), uint32(16), uint32(32)
+
+	tests := []struct {
+		name            string
+		bufLen          uint32
+		expectedBufused uint32
+		expectedLog     string
+	}{
+		{
+			name:            "fits",
+			bufLen:          8,
+			expectedBufused: 4,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_readlink(fd=3,path=0,path_len=4,buf=16,buf_len=8,result.bufused=32)
+<== ESUCCESS
+`,
+		},
+		{
+			name:            "truncated",
+			bufLen:          2,
+			expectedBufused: 2,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_readlink(fd=3,path=0,path_len=4,buf=16,buf_len=2,result.bufused=32)
+<== ESUCCESS
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			defer log.Reset()
+
+			maskMemory(t, mod, 64)
+			require.True(t, mod.Memory().Write(path, []byte(pathName)))
+
+			requireErrno(t, ErrnoSuccess, mod, pathReadlinkName, uint64(internalsys.FdRoot),
+				uint64(path), uint64(len(pathName)), uint64(buf), uint64(tc.bufLen), uint64(resultBufused))
+			require.Equal(t, tc.expectedLog, "\n"+log.String())
+
+			bufused, ok := mod.Memory().ReadUint32Le(resultBufused)
+			require.True(t, ok)
+			require.Equal(t, tc.expectedBufused, bufused)
+
+			target, ok := mod.Memory().Read(buf, bufused)
+			require.True(t, ok)
+			require.Equal(t, "file"[:bufused], string(target))
+		})
+	}
+}
+
+func Test_pathReadlink_Errors(t *testing.T) {
+	mod, r, log, _ := requireLinksModule(t)
+	defer r.Close(testCtx)
+	memorySize := mod.Memory().Size()
+
+	file, link := uint32(0), uint32(16)
+	require.True(t, mod.Memory().Write(file, []byte("file")))
+	require.True(t, mod.Memory().Write(link, []byte("link")))
+
+	tests := []struct {
+		name                                 string
+		fd, path, buf, bufLen, resultBufused uint32
+		expectedErrno                        Errno
+		expectedLog                          string
+	}{
+		{
+			name: "invalid fd",
+			fd:   42, path: link, buf: 32, bufLen: 8, resultBufused: 48,
+			expectedErrno: ErrnoBadf,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_readlink(fd=42,path=16,path_len=4,buf=32,buf_len=8,result.bufused=48)
+<== EBADF
+`,
+		},
+		{
+			name: "out-of-memory reading path",
+			fd:   internalsys.FdRoot, path: memorySize, buf: 32, bufLen: 8, resultBufused: 48,
+			expectedErrno: ErrnoFault,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_readlink(fd=3,path=65536,path_len=4,buf=32,buf_len=8,result.bufused=48)
+<== EFAULT
+`,
+		},
+		{
+			name: "not a symbolic link",
+			fd:   internalsys.FdRoot, path: file, buf: 32, bufLen: 8, resultBufused: 48,
+			expectedErrno: ErrnoInval,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_readlink(fd=3,path=0,path_len=4,buf=32,buf_len=8,result.bufused=48)
+<== EINVAL
+`,
+		},
+		{
+			name: "out-of-memory writing buf",
+			fd:   internalsys.FdRoot, path: link, buf: memorySize, bufLen: 8, resultBufused: 48,
+			expectedErrno: ErrnoFault,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_readlink(fd=3,path=16,path_len=4,buf=65536,buf_len=8,result.bufused=48)
+<== EFAULT
+`,
+		},
+		{
+			name: "out-of-memory writing resultBufused",
+			fd:   internalsys.FdRoot, path: link, buf: 32, bufLen: 8, resultBufused: memorySize,
+			expectedErrno: ErrnoFault,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_readlink(fd=3,path=16,path_len=4,buf=32,buf_len=8,result.bufused=65536)
+<== EFAULT
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			defer log.Reset()
+
+			requireErrno(t, tc.expectedErrno, mod, pathReadlinkName, uint64(tc.fd), uint64(tc.path), 4,
+				uint64(tc.buf), uint64(tc.bufLen), uint64(tc.resultBufused))
+			require.Equal(t, tc.expectedLog, "\n"+log.String())
+		})
+	}
+}
+
+func Test_pathRemoveDirectory(t *testing.T) {
+	mod, r, log, m := requireMemFSModule(t)
+	defer r.Close(testCtx)
+
+	requireErrno(t, ErrnoSuccess, mod, pathRemoveDirectoryName, uint64(internalsys.FdRoot), pathEmpty, 5)
+	require.Equal(t, `
+==> wasi_snapshot_preview1.path_remove_directory(fd=3,path=16,path_len=5)
+<== ESUCCESS
+`, "\n"+log.String())
+
+	_, err := m.Stat("empty")
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+}
+
+func Test_pathRemoveDirectory_Errors(t *testing.T) {
+	mod, r, log, _ := requireMemFSModule(t)
+	defer r.Close(testCtx)
+
+	tests := []struct {
+		name          string
+		path, pathLen uint32
+		expectedErrno Errno
+		expectedLog   string
+	}{
+		{
+			name:          "path doesn't exist",
+			path:          pathMissing,
+			pathLen:       4,
+			expectedErrno: ErrnoNoent,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_remove_directory(fd=3,path=24,path_len=4)
+<== ENOENT
+`,
+		},
+		{
+			name:          "path is a file",
+			path:          pathFile,
+			pathLen:       4,
+			expectedErrno: ErrnoNotdir,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_remove_directory(fd=3,path=0,path_len=4)
+<== ENOTDIR
+`,
+		},
+		{
+			name:          "directory not empty",
+			path:          pathDir,
+			pathLen:       3,
+			expectedErrno: ErrnoNotempty,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_remove_directory(fd=3,path=8,path_len=3)
+<== ENOTEMPTY
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			defer log.Reset()
+
+			requireErrno(t, tc.expectedErrno, mod, pathRemoveDirectoryName, uint64(internalsys.FdRoot), uint64(tc.path), uint64(tc.pathLen))
+			require.Equal(t, tc.expectedLog, "\n"+log.String())
+		})
+	}
+}
+
+func Test_pathRename(t *testing.T) {
+	mod, r, log, m := requireMemFSModule(t)
+	defer r.Close(testCtx)
+
+	rootFD := uint64(internalsys.FdRoot)
+	requireErrno(t, ErrnoSuccess, mod, pathRenameName, rootFD, pathFile, 4, rootFD, pathMissing, 4)
+	require.Equal(t, `
+==> wasi_snapshot_preview1.path_rename(fd=3,old_path=0,old_path_len=4,new_fd=3,new_path=24,new_path_len=4)
+<== ESUCCESS
+`, "\n"+log.String())
+
+	b, err := m.ReadFile("miss")
+	require.NoError(t, err)
+	require.Equal(t, "wazero", string(b))
+	_, err = m.Stat("file")
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+}
+
+func Test_pathRename_Errors(t *testing.T) {
+	mod, r, log, _ := requireMemFSModule(t)
+	defer r.Close(testCtx)
+	memorySize := mod.Memory().Size()
+
+	rootFD := uint32(internalsys.FdRoot)
+
+	tests := []struct {
+		name                       string
+		oldFd, oldPath, oldPathLen uint32
+		newFd, newPath, newPathLen uint32
+		expectedErrno              Errno
+		expectedLog                string
+	}{
+		{
+			name:  "invalid new fd",
+			oldFd: rootFD, oldPath: pathFile, oldPathLen: 4,
+			newFd: 42, newPath: pathMissing, newPathLen: 4,
+			expectedErrno: ErrnoBadf,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_rename(fd=3,old_path=0,old_path_len=4,new_fd=42,new_path=24,new_path_len=4)
+<== EBADF
+`,
+		},
+		{
+			name:  "out-of-memory reading new path",
+			oldFd: rootFD, oldPath: pathFile, oldPathLen: 4,
+			newFd: rootFD, newPath: memorySize, newPathLen: 4,
+			expectedErrno: ErrnoFault,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_rename(fd=3,old_path=0,old_path_len=4,new_fd=3,new_path=65536,new_path_len=4)
+<== EFAULT
+`,
+		},
+		{
+			name:  "old path doesn't exist",
+			oldFd: rootFD, oldPath: pathMissing, oldPathLen: 4,
+			newFd: rootFD, newPath: pathFile, newPathLen: 4,
+			expectedErrno: ErrnoNoent,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_rename(fd=3,old_path=24,old_path_len=4,new_fd=3,new_path=0,new_path_len=4)
+<== ENOENT
+`,
+		},
+		{
+			name:  "file over directory",
+			oldFd: rootFD, oldPath: pathFile, oldPathLen: 4,
+			newFd: rootFD, newPath: pathEmpty, newPathLen: 5,
+			expectedErrno: ErrnoIsdir,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_rename(fd=3,old_path=0,old_path_len=4,new_fd=3,new_path=16,new_path_len=5)
+<== EISDIR
+`,
+		},
+		{
+			name:  "directory over non-empty directory",
+			oldFd: rootFD, oldPath: pathEmpty, oldPathLen: 5,
+			newFd: rootFD, newPath: pathDir, newPathLen: 3,
+			expectedErrno: ErrnoNotempty,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_rename(fd=3,old_path=16,old_path_len=5,new_fd=3,new_path=8,new_path_len=3)
+<== ENOTEMPTY
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			defer log.Reset()
+
+			requireErrno(t, tc.expectedErrno, mod, pathRenameName, uint64(tc.oldFd), uint64(tc.oldPath),
+				uint64(tc.oldPathLen), uint64(tc.newFd), uint64(tc.newPath), uint64(tc.newPathLen))
+			require.Equal(t, tc.expectedLog, "\n"+log.String())
+		})
+	}
 }
 
-// Test_pathSymlink only tests it is stubbed for GrainLang per #271
 func Test_pathSymlink(t *testing.T) {
-	log := requireErrnoNosys(t, pathSymlinkName, 0, 0, 0, 0, 0)
+	mod, r, log, tmpDir := requireLinksModule(t)
+	defer r.Close(testCtx)
+
+	oldPath, newPath := uint32(0), uint32(16)
+	require.True(t, mod.Memory().Write(oldPath, []byte("file")))
+	require.True(t, mod.Memory().Write(newPath, []byte("soft")))
+
+	requireErrno(t, ErrnoSuccess, mod, pathSymlinkName, uint64(oldPath), 4, uint64(internalsys.FdRoot), uint64(newPath), 4)
 	require.Equal(t, `
---> wasi_snapshot_preview1.path_symlink(old_path=0,old_path_len=0,fd=0,new_path=0,new_path_len=0)
-<-- ENOSYS
-`, log)
+==> wasi_snapshot_preview1.path_symlink(old_path=0,old_path_len=4,fd=3,new_path=16,new_path_len=4)
+<== ESUCCESS
+`, "\n"+log.String())
+
+	target, err := os.Readlink(path.Join(tmpDir, "soft"))
+	require.NoError(t, err)
+	require.Equal(t, "file", target)
+}
+
+func Test_pathSymlink_Errors(t *testing.T) {
+	mod, r, log, _ := requireLinksModule(t)
+	defer r.Close(testCtx)
+	memorySize := mod.Memory().Size()
+
+	tests := []struct {
+		name, target, linkName string
+		fd, oldPath            uint32
+		expectedErrno          Errno
+		expectedLog            string
+	}{
+		{
+			name:   "invalid fd",
+			target: "file", linkName: "soft",
+			fd:            42,
+			expectedErrno: ErrnoBadf,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_symlink(old_path=0,old_path_len=4,fd=42,new_path=64,new_path_len=4)
+<== EBADF
+`,
+		},
+		{
+			name:   "out-of-memory reading old path",
+			target: "file", linkName: "soft",
+			fd:            internalsys.FdRoot,
+			oldPath:       memorySize,
+			expectedErrno: ErrnoFault,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_symlink(old_path=65536,old_path_len=4,fd=3,new_path=64,new_path_len=4)
+<== EFAULT
+`,
+		},
+		{
+			name:   "absolute target",
+			target: "/etc/passwd", linkName: "soft",
+			fd:            internalsys.FdRoot,
+			expectedErrno: ErrnoPerm,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_symlink(old_path=0,old_path_len=11,fd=3,new_path=64,new_path_len=4)
+<== EPERM
+`,
+		},
+		{
+			name:   "target escapes the root",
+			target: "../file", linkName: "soft",
+			fd:            internalsys.FdRoot,
+			expectedErrno: ErrnoPerm,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_symlink(old_path=0,old_path_len=7,fd=3,new_path=64,new_path_len=4)
+<== EPERM
+`,
+		},
+		{
+			name:   "link exists",
+			target: "file", linkName: "link",
+			fd:            internalsys.FdRoot,
+			expectedErrno: ErrnoExist,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_symlink(old_path=0,old_path_len=4,fd=3,new_path=64,new_path_len=4)
+<== EEXIST
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			defer log.Reset()
+
+			newPath := uint32(64)
+			require.True(t, mod.Memory().Write(0, []byte(tc.target)))
+			require.True(t, mod.Memory().Write(newPath, []byte(tc.linkName)))
+
+			requireErrno(t, tc.expectedErrno, mod, pathSymlinkName, uint64(tc.oldPath), uint64(len(tc.target)),
+				uint64(tc.fd), uint64(newPath), uint64(len(tc.linkName)))
+			require.Equal(t, tc.expectedLog, "\n"+log.String())
+		})
+	}
 }
 
-// Test_pathUnlinkFile only tests it is stubbed for GrainLang per #271
 func Test_pathUnlinkFile(t *testing.T) {
-	log := requireErrnoNosys(t, pathUnlinkFileName, 0, 0, 0)
+	mod, r, log, m := requireMemFSModule(t)
+	defer r.Close(testCtx)
+
+	requireErrno(t, ErrnoSuccess, mod, pathUnlinkFileName, uint64(internalsys.FdRoot), pathFile, 4)
 	require.Equal(t, `
---> wasi_snapshot_preview1.path_unlink_file(fd=0,path=0,path_len=0)
-<-- ENOSYS
-`, log)
+==> wasi_snapshot_preview1.path_unlink_file(fd=3,path=0,path_len=4)
+<== ESUCCESS
+`, "\n"+log.String())
+
+	_, err := m.Stat("file")
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+}
+
+func Test_pathUnlinkFile_Errors(t *testing.T) {
+	mod, r, log, _ := requireMemFSModule(t)
+	defer r.Close(testCtx)
+
+	tests := []struct {
+		name          string
+		fd, path      uint32
+		pathLen       uint32
+		expectedErrno Errno
+		expectedLog   string
+	}{
+		{
+			name:          "fd not a directory",
+			fd:            internalsys.FdStdout,
+			path:          pathFile,
+			pathLen:       4,
+			expectedErrno: ErrnoNotdir,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_unlink_file(fd=1,path=0,path_len=4)
+<== ENOTDIR
+`,
+		},
+		{
+			name:          "path doesn't exist",
+			fd:            internalsys.FdRoot,
+			path:          pathMissing,
+			pathLen:       4,
+			expectedErrno: ErrnoNoent,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_unlink_file(fd=3,path=24,path_len=4)
+<== ENOENT
+`,
+		},
+		{
+			name:          "path is a directory",
+			fd:            internalsys.FdRoot,
+			path:          pathEmpty,
+			pathLen:       5,
+			expectedErrno: ErrnoIsdir,
+			expectedLog: `
+==> wasi_snapshot_preview1.path_unlink_file(fd=3,path=16,path_len=5)
+<== EISDIR
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			defer log.Reset()
+
+			requireErrno(t, tc.expectedErrno, mod, pathUnlinkFileName, uint64(tc.fd), uint64(tc.path), uint64(tc.pathLen))
+			require.Equal(t, tc.expectedLog, "\n"+log.String())
+		})
+	}
+}
+
+// Test_pathWrites_readOnly ensures functions that change the file system are
+// not supported on a read-only one.
+func Test_pathWrites_readOnly(t *testing.T) {
+	mod, r, _ := requireProxyModule(t, wazero.NewModuleConfig().WithFS(fstest.MapFS{
+		"file": &fstest.MapFile{},
+		"dir":  &fstest.MapFile{Mode: fs.ModeDir},
+	}))
+	defer r.Close(testCtx)
+
+	require.True(t, mod.Memory().Write(0, []byte("file")))
+	require.True(t, mod.Memory().Write(8, []byte("dir")))
+	require.True(t, mod.Memory().Write(16, []byte("new")))
+	rootFD := uint64(internalsys.FdRoot)
+
+	requireErrno(t, ErrnoRofs, mod, pathCreateDirectoryName, rootFD, 16, 3)
+	requireErrno(t, ErrnoRofs, mod, pathRemoveDirectoryName, rootFD, 8, 3)
+	requireErrno(t, ErrnoRofs, mod, pathRenameName, rootFD, 0, 4, rootFD, 16, 3)
+	requireErrno(t, ErrnoRofs, mod, pathUnlinkFileName, rootFD, 0, 4)
 }
 
 func requireOpenFile(t *testing.T, pathName string, data []byte) (api.Module, uint32, *bytes.Buffer, api.Closer) {
@@ -2578,7 +4142,7 @@ func requireOpenFile(t *testing.T, pathName string, data []byte) (api.Module, ui
 	testFS := fstest.MapFS{pathName[1:]: mapFile} // strip the leading slash
 	mod, r, log := requireProxyModule(t, wazero.NewModuleConfig().WithFS(testFS))
 	fsc := mod.(*wasm.CallContext).Sys.FS()
-	fd, err := fsc.OpenFile(pathName)
+	fd, err := fsc.OpenFile(pathName, os.O_RDONLY, 0)
 	require.NoError(t, err)
 	return mod, fd, log, r
 }
@@ -2588,7 +4152,7 @@ func requireOpenWritableFile(t *testing.T, tmpDir string, pathName string) (api.
 	writeable, testFS := createWriteableFile(t, tmpDir, pathName, []byte{})
 	mod, r, log := requireProxyModule(t, wazero.NewModuleConfig().WithFS(testFS))
 	fsc := mod.(*wasm.CallContext).Sys.FS()
-	fd, err := fsc.OpenFile(pathName)
+	fd, err := fsc.OpenFile(pathName, os.O_RDONLY, 0)
 	require.NoError(t, err)
 
 	// Swap the read-only file with a writeable one until #390
@@ -2611,3 +4175,34 @@ func createWriteableFile(t *testing.T, tmpDir string, pathName string, data []by
 	require.NoError(t, err)
 	return f, os.DirFS(tmpDir)
 }
+
+func Test_fdRead_nonblock_reader(t *testing.T) {
+	// stdin isn't a host file, so it is read ahead in a goroutine.
+	stdinR, stdinW := io.Pipe()
+	defer stdinR.Close()
+
+	mod, r, log := requireProxyModule(t, wazero.NewModuleConfig().WithStdin(stdinR))
+	defer r.Close(testCtx)
+
+	fd := uint64(internalsys.FdStdin)
+	requireErrno(t, ErrnoSuccess, mod, fdFdstatSetFlagsName, fd, uint64(wasiFdflagsNonblock))
+	log.Reset()
+
+	ok := mod.Memory().Write(0, []byte{8, 0, 0, 0, 6, 0, 0, 0}) // iovs[0] = 8..14
+	require.True(t, ok)
+
+	// Reading an empty pipe would block, so it fails instead.
+	requireErrno(t, ErrnoAgain, mod, fdReadName, fd, 0, 1, 16)
+	require.Equal(t, `
+==> wasi_snapshot_preview1.fd_read(fd=0,iovs=0,iovs_len=1,result.nread=16)
+<== EAGAIN
+`, "\n"+log.String())
+
+	go func() {
+		_, _ = stdinW.Write([]byte("wazero"))
+	}()
+	requireSuccessAfterErrnoAgain(t, mod, fdReadName, fd, 0, 1, 16)
+	buf, ok := mod.Memory().Read(8, 6)
+	require.True(t, ok)
+	require.Equal(t, "wazero", string(buf))
+}
@@ -2,8 +2,12 @@ package wasi_snapshot_preview1
 
 import (
 	"context"
+	"math"
+	"time"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/platform"
+	internalsys "github.com/tetratelabs/wazero/internal/sys"
 	"github.com/tetratelabs/wazero/internal/wasm"
 )
 
@@ -33,13 +37,23 @@ const (
 //
 // The return value is ErrnoSuccess except the following error conditions:
 //   - ErrnoInval: the parameters are invalid
-//   - ErrnoNotsup: a parameters is valid, but not yet supported.
 //   - ErrnoFault: there is not enough memory to read the subscriptions or
 //     write results.
 //
 // # Notes
 //
 //   - Since the `out` pointer nests Errno, the result is always ErrnoSuccess.
+//   - Only events which occurred are written to `out`, so resultNevents can
+//     be less than nsubscriptions.
+//   - Subscriptions with an invalid parameter, such as a closed file
+//     descriptor, occur immediately with an event Errno, e.g. ErrnoBadf.
+//   - fd_write subscriptions, and fd_read on regular files, are always ready.
+//   - fd_read on a pipe, terminal or socket, such as a piped stdin, blocks
+//     until data is available or the earliest clock subscription elapses.
+//     This includes stdin configured with an io.Reader that isn't an
+//     *os.File, such as an io.Pipe, which is read ahead in a goroutine.
+//   - Only relative clock subscriptions are supported. Absolute ones occur
+//     immediately with ErrnoNotsup.
 //   - importPollOneoff shows this signature in the WebAssembly 1.0 Text Format.
 //   - This is similar to `poll` in POSIX.
 //
@@ -51,6 +65,24 @@ var pollOneoff = newHostFunc(
 	"in", "out", "nsubscriptions", "result.nevents",
 )
 
+// subscription is a parsed subscription which didn't occur immediately.
+type subscription struct {
+	// userdata is the 8 bytes copied to the corresponding event.
+	userdata  []byte
+	eventType byte
+	// timeout is the relative clock timeout in nanoseconds.
+	timeout int64
+	// hostFd is the host file descriptor of an fd_read subscription.
+	hostFd uintptr
+	// fd is the file descriptor of an fd_read subscription which isn't backed
+	// by a host file, such as stdin configured with an io.Pipe.
+	fd uint32
+}
+
+// pollInterval is how often fd_read subscriptions are checked when waiting
+// on more than one which isn't backed by the same host poll.
+const pollInterval = 10 * time.Millisecond
+
 func pollOneoffFn(ctx context.Context, mod api.Module, params []uint64) Errno {
 	in := uint32(params[0])
 	out := uint32(params[1])
@@ -72,45 +104,164 @@ func pollOneoffFn(ctx context.Context, mod api.Module, params []uint64) Errno {
 	if !ok {
 		return ErrnoFault
 	}
-
-	// Eagerly write the number of events which will equal subscriptions unless
-	// there's a fault in parsing (not processing).
-	if !mod.Memory().WriteUint32Le(resultNevents, nsubscriptions) {
+	if _, ok = mem.ReadUint32Le(resultNevents); !ok {
 		return ErrnoFault
 	}
 
-	// Loop through all subscriptions and write their output.
+	sysCtx := mod.(*wasm.CallContext).Sys
+	fsc := sysCtx.FS()
+
+	// Loop through all subscriptions, writing events which occur immediately
+	// and collecting the clocks and blocking reads to wait on.
+	var nevents uint32
+	var clocks, reads, readers []*subscription
 	for i := uint32(0); i < nsubscriptions; i++ {
 		inOffset := i * 48
-		outOffset := i * 32
+		userdata := inBuf[inOffset : inOffset+8]
 
 		eventType := inBuf[inOffset+8] // +8 past userdata
 		var errno Errno                // errno for this specific event
 		switch eventType {
-		case eventTypeClock: // handle later
+		case eventTypeClock:
 			// +8 past userdata +8 name alignment
-			errno = processClockEvent(ctx, mod, inBuf[inOffset+8+8:])
+			var timeout int64
+			if timeout, errno = processClockEvent(inBuf[inOffset+8+8:]); errno == ErrnoSuccess {
+				clocks = append(clocks, &subscription{userdata: userdata, eventType: eventType, timeout: timeout})
+				continue
+			}
 		case eventTypeFdRead, eventTypeFdWrite:
 			// +8 past userdata +4 FD alignment
-			errno = processFDEvent(mod, eventType, inBuf[inOffset+8+4:])
+			fd := le.Uint32(inBuf[inOffset+8+4:])
+			if errno = processFDEvent(fsc, eventType, fd); errno != ErrnoSuccess {
+				break
+			}
+			if eventType == eventTypeFdRead {
+				if ready, ok := fsc.PollRead(fd, 0); ok {
+					if !ready {
+						readers = append(readers, &subscription{userdata: userdata, eventType: eventType, fd: fd})
+						continue
+					}
+				} else if hostFd, blocking := fsc.HostFdIfBlocking(fd); blocking {
+					reads = append(reads, &subscription{userdata: userdata, eventType: eventType, hostFd: hostFd})
+					continue
+				}
+			}
 		default:
 			return ErrnoInval
 		}
 
-		// Write the event corresponding to the processed subscription.
-		// https://github.com/WebAssembly/WASI/blob/snapshot-01/phases/snapshot/docs.md#-event-struct
-		copy(outBuf, inBuf[inOffset:inOffset+8]) // userdata
-		outBuf[outOffset+8] = byte(errno)        // uint16, but safe as < 255
-		outBuf[outOffset+9] = 0
-		le.PutUint32(outBuf[outOffset+10:], uint32(eventType))
-		// TODO: When FD events are supported, write outOffset+16
+		writeEvent(outBuf[nevents*32:], userdata, errno, eventType)
+		nevents++
+	}
+
+	// Wait for the earliest clock unless another event already occurred.
+	timeout := int64(-1) // indefinitely
+	for _, c := range clocks {
+		if timeout == -1 || c.timeout < timeout {
+			timeout = c.timeout
+		}
+	}
+	if nevents > 0 {
+		timeout = 0
+	}
+
+	if len(reads) > 0 || len(readers) > 0 {
+		nevents += pollReads(fsc, outBuf[nevents*32:], reads, readers, time.Duration(timeout))
+	} else if timeout > 0 {
+		sysCtx.Nanosleep(timeout)
+	}
+
+	// Clocks which elapsed occur when nothing else did.
+	if nevents == 0 {
+		for _, c := range clocks {
+			if c.timeout <= timeout {
+				writeEvent(outBuf[nevents*32:], c.userdata, ErrnoSuccess, c.eventType)
+				nevents++
+			}
+		}
+	}
+
+	if !mem.WriteUint32Le(resultNevents, nevents) {
+		return ErrnoFault
 	}
 	return ErrnoSuccess
 }
 
+// pollReads waits up to timeout for any fd_read subscription to be ready,
+// writing an event for each that is, and returning the count of events. A
+// negative timeout waits indefinitely.
+//
+// reads are backed by host file descriptors, and readers by an io.Reader, as
+// returned by FSContext.PollRead. When there is only one poll to make, this
+// waits on it. Otherwise, each is checked every pollInterval.
+func pollReads(fsc *internalsys.FSContext, outBuf []byte, reads, readers []*subscription, timeout time.Duration) (nevents uint32) {
+	hostFds := make([]uintptr, len(reads))
+	for i, r := range reads {
+		hostFds[i] = r.hostFd
+	}
+	polls := len(readers)
+	if len(reads) > 0 {
+		polls++
+	}
+
+	for {
+		wait := timeout
+		if polls > 1 && (wait < 0 || wait > pollInterval) {
+			wait = pollInterval
+		}
+
+		// Only the first poll waits, as otherwise the total wait would be
+		// longer than the timeout.
+		next := wait
+		var ready []bool
+		var err error
+		if len(reads) > 0 {
+			ready, err = platform.PollRead(hostFds, next)
+			next = 0
+		}
+		for _, r := range readers {
+			if readerReady, _ := fsc.PollRead(r.fd, next); readerReady {
+				writeEvent(outBuf[nevents*32:], r.userdata, ErrnoSuccess, r.eventType)
+				nevents++
+			}
+			next = 0
+		}
+		for i, r := range reads {
+			// On error, report the read as ready, so that the guest reads it
+			// and sees the error, as opposed to spinning.
+			if err != nil || ready[i] {
+				writeEvent(outBuf[nevents*32:], r.userdata, ErrnoSuccess, r.eventType)
+				nevents++
+			}
+		}
+
+		if nevents > 0 || timeout == wait {
+			return
+		} else if timeout > 0 {
+			timeout -= wait
+		}
+	}
+}
+
+// writeEvent writes the event corresponding to a subscription.
+//
+// See https://github.com/WebAssembly/WASI/blob/snapshot-01/phases/snapshot/docs.md#-event-struct
+func writeEvent(outBuf, userdata []byte, errno Errno, eventType byte) {
+	copy(outBuf, userdata)
+	outBuf[8] = byte(errno) // uint16, but safe as < 255
+	outBuf[9] = 0
+	le.PutUint32(outBuf[10:], uint32(eventType))
+	// Leave fd_readwrite (outBuf[16:]) zero, as the number of bytes available
+	// isn't portably known and no flags are set.
+	for i := 16; i < 32; i++ {
+		outBuf[i] = 0
+	}
+}
+
 // processClockEvent supports only relative name events, as that's what's used
-// to implement sleep in various compilers including Rust, Zig and TinyGo.
-func processClockEvent(ctx context.Context, mod api.Module, inBuf []byte) Errno {
+// to implement sleep in various compilers including Rust, Zig and TinyGo. This
+// returns the timeout in nanoseconds or an error.
+func processClockEvent(inBuf []byte) (int64, Errno) {
 	_ /* ID */ = le.Uint32(inBuf[0:8])          // See below
 	timeout := le.Uint64(inBuf[8:16])           // nanos if relative
 	_ /* precision */ = le.Uint64(inBuf[16:24]) // Unused
@@ -120,34 +271,27 @@ func processClockEvent(ctx context.Context, mod api.Module, inBuf []byte) Errno
 	switch flags {
 	case 0: // relative time
 	case 1: // subscription_clock_abstime
-		return ErrnoNotsup
+		return 0, ErrnoNotsup
 	default: // subclockflags has only one flag defined.
-		return ErrnoInval
+		return 0, ErrnoInval
 	}
 
 	// https://linux.die.net/man/3/clock_settime says relative timers are
 	// unaffected. Since this function only supports relative timeout, we can
 	// skip name ID validation and use a single sleep function.
-
-	sysCtx := mod.(*wasm.CallContext).Sys
-	sysCtx.Nanosleep(int64(timeout))
-	return ErrnoSuccess
+	if timeout > math.MaxInt64 {
+		timeout = math.MaxInt64
+	}
+	return int64(timeout), ErrnoSuccess
 }
 
-// processFDEvent returns a validation error or ErrnoNotsup as file or socket
-// subscriptions are not yet supported.
-func processFDEvent(mod api.Module, eventType byte, inBuf []byte) Errno {
-	fd := le.Uint32(inBuf)
-	fsc := mod.(*wasm.CallContext).Sys.FS()
-
-	// Choose the best error, which falls back to unsupported, until we support
-	// files.
-	errno := ErrnoNotsup
+// processFDEvent returns ErrnoBadf if the file descriptor isn't open for the
+// given event type.
+func processFDEvent(fsc *internalsys.FSContext, eventType byte, fd uint32) Errno {
 	if eventType == eventTypeFdRead && fsc.FdReader(fd) == nil {
-		errno = ErrnoBadf
+		return ErrnoBadf
 	} else if eventType == eventTypeFdWrite && fsc.FdWriter(fd) == nil {
-		errno = ErrnoBadf
+		return ErrnoBadf
 	}
-
-	return errno
+	return ErrnoSuccess
 }
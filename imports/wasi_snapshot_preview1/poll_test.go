@@ -1,7 +1,11 @@
 package wasi_snapshot_preview1
 
 import (
+	"io"
+	"os"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/tetratelabs/wazero"
 	internalsys "github.com/tetratelabs/wazero/internal/sys"
@@ -41,7 +45,7 @@ func Test_pollOneoff(t *testing.T) {
 	requireErrno(t, ErrnoSuccess, mod, pollOneoffName, uint64(in), uint64(out), uint64(nsubscriptions),
 		uint64(resultNevents))
 	require.Equal(t, `
-==> wasi_snapshot_preview1.poll_oneoff(in=0,out=128,nsubscriptions=1,result.nevents=512)
+==> wasi_snapshot_preview1.poll_oneoff(in=[clock(timeout=65536)],out=128,result.nevents=512)
 <== ESUCCESS
 `, "\n"+log.String())
 
@@ -85,7 +89,7 @@ func Test_pollOneoff_Errors(t *testing.T) {
 			nsubscriptions: 1,
 			expectedErrno:  ErrnoFault,
 			expectedLog: `
-==> wasi_snapshot_preview1.poll_oneoff(in=0,out=65536,nsubscriptions=1,result.nevents=512)
+==> wasi_snapshot_preview1.poll_oneoff(in=[unknown(type=63)],out=65536,result.nevents=512)
 <== EFAULT
 `,
 		},
@@ -95,7 +99,7 @@ func Test_pollOneoff_Errors(t *testing.T) {
 			nsubscriptions: 1,
 			expectedErrno:  ErrnoFault,
 			expectedLog: `
-==> wasi_snapshot_preview1.poll_oneoff(in=0,out=0,nsubscriptions=1,result.nevents=65536)
+==> wasi_snapshot_preview1.poll_oneoff(in=[unknown(type=63)],out=0,result.nevents=65536)
 <== EFAULT
 `,
 		},
@@ -105,17 +109,17 @@ func Test_pollOneoff_Errors(t *testing.T) {
 			resultNevents: 512, // past out
 			expectedErrno: ErrnoInval,
 			expectedLog: `
-==> wasi_snapshot_preview1.poll_oneoff(in=0,out=128,nsubscriptions=0,result.nevents=512)
+==> wasi_snapshot_preview1.poll_oneoff(in=[],out=128,result.nevents=512)
 <== EINVAL
 `,
 		},
 		{
-			name:           "unsupported eventTypeFdRead",
+			name:           "eventTypeFdRead invalid FD",
 			nsubscriptions: 1,
 			mem: []byte{
 				0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, // userdata
 				eventTypeFdRead, 0x0, 0x0, 0x0,
-				byte(internalsys.FdStdin), 0x0, 0x0, 0x0, // valid readable FD
+				byte(internalsys.FdStdout), 0x0, 0x0, 0x0, // not readable FD
 				'?', // stopped after encoding
 			},
 			expectedErrno: ErrnoSuccess,
@@ -123,12 +127,12 @@ func Test_pollOneoff_Errors(t *testing.T) {
 			resultNevents: 512, // past out
 			expectedMem: []byte{
 				0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, // userdata
-				byte(ErrnoNotsup), 0x0, // errno is 16 bit
+				byte(ErrnoBadf), 0x0, // errno is 16 bit
 				eventTypeFdRead, 0x0, 0x0, 0x0, // 4 bytes for type enum
 				'?', // stopped after encoding
 			},
 			expectedLog: `
-==> wasi_snapshot_preview1.poll_oneoff(in=0,out=128,nsubscriptions=1,result.nevents=512)
+==> wasi_snapshot_preview1.poll_oneoff(in=[fd_read(fd=1)],out=128,result.nevents=512)
 <== ESUCCESS
 `,
 		},
@@ -162,3 +166,217 @@ func Test_pollOneoff_Errors(t *testing.T) {
 		})
 	}
 }
+
+func Test_pollOneoff_fd(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip() // because pipes are always reported readable
+	}
+
+	stdinR, stdinW, err := os.Pipe()
+	require.NoError(t, err)
+	defer stdinR.Close()
+	defer stdinW.Close()
+
+	mod, r, log := requireProxyModule(t, wazero.NewModuleConfig().WithStdin(stdinR))
+	defer r.Close(testCtx)
+
+	fdSub := func(userdata, eventType byte, fd uint32) []byte {
+		sub := make([]byte, 48)
+		sub[0] = userdata
+		sub[8] = eventType
+		le.PutUint32(sub[12:], fd)
+		return sub
+	}
+	clockSub := func(userdata byte, timeout uint64) []byte {
+		sub := make([]byte, 48)
+		sub[0] = userdata
+		sub[8] = eventTypeClock
+		le.PutUint64(sub[24:], timeout)
+		return sub
+	}
+
+	tests := []struct {
+		name           string
+		stdin          []byte // written to stdin prior to the call
+		subscriptions  [][]byte
+		expectedEvents [][2]byte // userdata and event type
+	}{
+		{
+			name:           "fd_write stdout is ready",
+			subscriptions:  [][]byte{clockSub(1, 1), fdSub(2, eventTypeFdWrite, internalsys.FdStdout)},
+			expectedEvents: [][2]byte{{2, eventTypeFdWrite}},
+		},
+		{
+			name:           "fd_read piped stdin times out",
+			subscriptions:  [][]byte{fdSub(1, eventTypeFdRead, internalsys.FdStdin), clockSub(2, 1)},
+			expectedEvents: [][2]byte{{2, eventTypeClock}},
+		},
+		{
+			name:           "fd_read piped stdin with data",
+			stdin:          []byte("wazero"),
+			subscriptions:  [][]byte{clockSub(1, uint64(time.Hour)), fdSub(2, eventTypeFdRead, internalsys.FdStdin)},
+			expectedEvents: [][2]byte{{2, eventTypeFdRead}},
+		},
+		{
+			name:           "only the earliest clock occurs",
+			subscriptions:  [][]byte{clockSub(1, 2), clockSub(2, 1)},
+			expectedEvents: [][2]byte{{2, eventTypeClock}},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			defer log.Reset()
+
+			if tc.stdin != nil {
+				_, err := stdinW.Write(tc.stdin)
+				require.NoError(t, err)
+				defer func() {
+					_, err := stdinR.Read(make([]byte, len(tc.stdin)))
+					require.NoError(t, err)
+				}()
+			}
+
+			maskMemory(t, mod, 1024)
+			var in []byte
+			for _, sub := range tc.subscriptions {
+				in = append(in, sub...)
+			}
+			mod.Memory().Write(0, in)
+
+			out, resultNevents := uint32(512), uint32(1020)
+			requireErrno(t, ErrnoSuccess, mod, pollOneoffName, 0, uint64(out),
+				uint64(len(tc.subscriptions)), uint64(resultNevents))
+
+			nevents, ok := mod.Memory().ReadUint32Le(resultNevents)
+			require.True(t, ok)
+			require.Equal(t, uint32(len(tc.expectedEvents)), nevents)
+
+			for i, expected := range tc.expectedEvents {
+				event, ok := mod.Memory().Read(out+uint32(i)*32, 32)
+				require.True(t, ok)
+				require.Equal(t, expected[0], event[0])        // userdata
+				require.Equal(t, byte(ErrnoSuccess), event[8]) // errno
+				require.Equal(t, uint32(expected[1]), le.Uint32(event[10:]))
+			}
+		})
+	}
+}
+
+func Test_pollOneoff_reader(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip() // because pipes are always reported readable
+	}
+
+	// stdin isn't a host file, so it is read ahead in a goroutine.
+	stdinR, stdinW := io.Pipe()
+	defer stdinR.Close()
+	hostR, hostW, err := os.Pipe()
+	require.NoError(t, err)
+	defer hostR.Close()
+	defer hostW.Close()
+
+	mod, r, log := requireProxyModule(t, wazero.NewModuleConfig().
+		WithStdin(stdinR).WithInheritedFile(hostR))
+	defer r.Close(testCtx)
+	hostFd := uint32(3)
+
+	fdSub := func(userdata byte, fd uint32) []byte {
+		sub := make([]byte, 48)
+		sub[0] = userdata
+		sub[8] = eventTypeFdRead
+		le.PutUint32(sub[12:], fd)
+		return sub
+	}
+	clockSub := func(userdata byte, timeout uint64) []byte {
+		sub := make([]byte, 48)
+		sub[0] = userdata
+		sub[8] = eventTypeClock
+		le.PutUint64(sub[24:], timeout)
+		return sub
+	}
+
+	tests := []struct {
+		name                string
+		stdin, host         []byte // written prior to the call
+		subscriptions       [][]byte
+		expectedUserdata    []byte
+		expectedEventsTypes []byte
+	}{
+		{
+			name:                "fd_read stdin times out",
+			subscriptions:       [][]byte{fdSub(1, internalsys.FdStdin), clockSub(2, uint64(time.Millisecond))},
+			expectedUserdata:    []byte{2},
+			expectedEventsTypes: []byte{eventTypeClock},
+		},
+		{
+			name:                "fd_read stdin with data",
+			stdin:               []byte("wazero"),
+			subscriptions:       [][]byte{clockSub(1, uint64(time.Hour)), fdSub(2, internalsys.FdStdin)},
+			expectedUserdata:    []byte{2},
+			expectedEventsTypes: []byte{eventTypeFdRead},
+		},
+		{
+			name:                "fd_read stdin and host pipe times out",
+			subscriptions:       [][]byte{fdSub(1, internalsys.FdStdin), fdSub(2, hostFd), clockSub(3, uint64(15*time.Millisecond))},
+			expectedUserdata:    []byte{3},
+			expectedEventsTypes: []byte{eventTypeClock},
+		},
+		{
+			name:                "fd_read stdin and host pipe with data",
+			host:                []byte("wazero"),
+			subscriptions:       [][]byte{fdSub(1, internalsys.FdStdin), fdSub(2, hostFd), clockSub(3, uint64(time.Hour))},
+			expectedUserdata:    []byte{2},
+			expectedEventsTypes: []byte{eventTypeFdRead},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			defer log.Reset()
+
+			if tc.stdin != nil {
+				go func() {
+					_, _ = stdinW.Write(tc.stdin)
+				}()
+				defer func() {
+					_, err := io.ReadFull(mod.(*wasm.CallContext).Sys.FS().FdReader(internalsys.FdStdin), make([]byte, len(tc.stdin)))
+					require.NoError(t, err)
+				}()
+			}
+			if tc.host != nil {
+				_, err := hostW.Write(tc.host)
+				require.NoError(t, err)
+				defer func() {
+					_, err := hostR.Read(make([]byte, len(tc.host)))
+					require.NoError(t, err)
+				}()
+			}
+
+			maskMemory(t, mod, 1024)
+			var in []byte
+			for _, sub := range tc.subscriptions {
+				in = append(in, sub...)
+			}
+			mod.Memory().Write(0, in)
+
+			out, resultNevents := uint32(512), uint32(1020)
+			requireErrno(t, ErrnoSuccess, mod, pollOneoffName, 0, uint64(out),
+				uint64(len(tc.subscriptions)), uint64(resultNevents))
+
+			nevents, ok := mod.Memory().ReadUint32Le(resultNevents)
+			require.True(t, ok)
+			require.Equal(t, uint32(len(tc.expectedUserdata)), nevents)
+
+			for i, userdata := range tc.expectedUserdata {
+				event, ok := mod.Memory().Read(out+uint32(i)*32, 32)
+				require.True(t, ok)
+				require.Equal(t, userdata, event[0])
+				require.Equal(t, byte(ErrnoSuccess), event[8]) // errno
+				require.Equal(t, uint32(tc.expectedEventsTypes[i]), le.Uint32(event[10:]))
+			}
+		})
+	}
+}
@@ -22,6 +22,7 @@ func Test_procExit(t *testing.T) {
 			exitCode: 0,
 			expectedLog: `
 ==> wasi_snapshot_preview1.proc_exit(rval=0)
+<== error: module "internal/testing/proxy/proxy.go" closed with exit_code(0)
 `,
 		},
 		{
@@ -29,6 +30,7 @@ func Test_procExit(t *testing.T) {
 			exitCode: 42,
 			expectedLog: `
 ==> wasi_snapshot_preview1.proc_exit(rval=42)
+<== error: module "internal/testing/proxy/proxy.go" closed with exit_code(42)
 `,
 		},
 	}
@@ -2,7 +2,9 @@ package wasi_snapshot_preview1
 
 import (
 	"context"
+	"errors"
 	"io"
+	"syscall"
 
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/internal/wasm"
@@ -22,6 +24,8 @@ const randomGetName = "random_get"
 //
 // The return value is ErrnoSuccess except the following error conditions:
 //   - ErrnoFault: `buf` or `bufLen` point to an offset out of memory
+//   - ErrnoNotsup: random bytes were disabled by wazero.ModuleConfig
+//     WithoutRandSource
 //   - ErrnoIo: a file system error
 //
 // For example, if underlying random source was seeded like
@@ -47,7 +51,9 @@ func randomGetFn(ctx context.Context, mod api.Module, params []uint64) Errno {
 	}
 
 	// We can ignore the returned n as it only != byteCount on error
-	if _, err := io.ReadAtLeast(randSource, randomBytes, int(bufLen)); err != nil {
+	if _, err := io.ReadAtLeast(randSource, randomBytes, int(bufLen)); errors.Is(err, syscall.ENOTSUP) {
+		return ErrnoNotsup
+	} else if err != nil {
 		return ErrnoIo
 	}
 
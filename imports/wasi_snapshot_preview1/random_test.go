@@ -117,3 +117,41 @@ func Test_randomGet_SourceError(t *testing.T) {
 		})
 	}
 }
+
+func Test_randomGet_WithRandSeed(t *testing.T) {
+	config := wazero.NewModuleConfig().WithRandSeed(7)
+
+	// Each instantiation reads the same bytes, regardless of how reads are
+	// split.
+	var expected []byte
+	for _, lengths := range [][]uint32{{6}, {2, 4}, {1, 1, 1, 3}} {
+		mod, r, _ := requireProxyModule(t, config)
+
+		offset := uint32(0)
+		for _, length := range lengths {
+			requireErrno(t, ErrnoSuccess, mod, randomGetName, uint64(offset), uint64(length))
+			offset += length
+		}
+		actual, ok := mod.Memory().Read(0, offset)
+		require.True(t, ok)
+		actual = append([]byte(nil), actual...) // copy before closing
+		require.NoError(t, r.Close(testCtx))
+
+		if expected == nil {
+			expected = actual
+		} else {
+			require.Equal(t, expected, actual)
+		}
+	}
+}
+
+func Test_randomGet_WithoutRandSource(t *testing.T) {
+	mod, r, log := requireProxyModule(t, wazero.NewModuleConfig().WithoutRandSource())
+	defer r.Close(testCtx)
+
+	requireErrno(t, ErrnoNotsup, mod, randomGetName, uint64(1), uint64(5)) // arbitrary offset and length
+	require.Equal(t, `
+==> wasi_snapshot_preview1.random_get(buf=1,buf_len=5)
+<== ENOTSUP
+`, "\n"+log.String())
+}
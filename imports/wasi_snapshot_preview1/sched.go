@@ -1,9 +1,28 @@
 package wasi_snapshot_preview1
 
+import (
+	"context"
+	"runtime"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
 const schedYieldName = "sched_yield"
 
 // schedYield is the WASI function named schedYieldName which temporarily
 // yields execution of the calling thread.
 //
+// This is stubbed by default, as wazero runs each guest on one goroutine. Use
+// Builder.WithSchedYield to export schedYieldGosched instead.
+//
 // See https://github.com/WebAssembly/WASI/blob/snapshot-01/phases/snapshot/docs.md#-sched_yield---errno
 var schedYield = stubFunction(schedYieldName, nil)
+
+// schedYieldGosched is schedYield implemented with runtime.Gosched, which
+// yields to other goroutines.
+var schedYieldGosched = newHostFunc(schedYieldName, schedYieldFn, nil)
+
+func schedYieldFn(context.Context, api.Module, []uint64) Errno {
+	runtime.Gosched()
+	return ErrnoSuccess
+}
@@ -1,8 +1,13 @@
 package wasi_snapshot_preview1
 
 import (
+	"bytes"
+	"context"
 	"testing"
 
+	"github.com/tetratelabs/wazero"
+	. "github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/testing/proxy"
 	"github.com/tetratelabs/wazero/internal/testing/require"
 )
 
@@ -14,3 +19,51 @@ func Test_schedYield(t *testing.T) {
 <-- ENOSYS
 `, log)
 }
+
+func TestBuilder_WithSchedYield(t *testing.T) {
+	var log bytes.Buffer
+	ctx := context.WithValue(testCtx, FunctionListenerFactoryKey{}, proxy.NewLoggingListenerFactory(&log))
+
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+
+	wasiModuleCompiled, err := NewBuilder(r).WithSchedYield().(*builder).
+		hostModuleBuilder().Compile(ctx)
+	require.NoError(t, err)
+
+	_, err = r.InstantiateModule(ctx, wasiModuleCompiled, wazero.NewModuleConfig())
+	require.NoError(t, err)
+
+	proxyCompiled, err := r.CompileModule(ctx, proxy.NewModuleBinary(ModuleName, wasiModuleCompiled))
+	require.NoError(t, err)
+
+	mod, err := r.InstantiateModule(ctx, proxyCompiled, wazero.NewModuleConfig())
+	require.NoError(t, err)
+
+	requireErrno(t, ErrnoSuccess, mod, schedYieldName)
+	require.Equal(t, `
+==> wasi_snapshot_preview1.sched_yield()
+<== ESUCCESS
+`, "\n"+log.String())
+}
+
+func TestBuilder_WithSchedYield_Denied(t *testing.T) {
+	r := wazero.NewRuntime(testCtx)
+	defer r.Close(testCtx)
+
+	wasiModuleCompiled, err := NewBuilder(r).WithSchedYield().
+		WithDeniedCapabilities(CapabilitySched).(*builder).
+		hostModuleBuilder().Compile(testCtx)
+	require.NoError(t, err)
+
+	_, err = r.InstantiateModule(testCtx, wasiModuleCompiled, wazero.NewModuleConfig())
+	require.NoError(t, err)
+
+	proxyCompiled, err := r.CompileModule(testCtx, proxy.NewModuleBinary(ModuleName, wasiModuleCompiled))
+	require.NoError(t, err)
+
+	mod, err := r.InstantiateModule(testCtx, proxyCompiled, wazero.NewModuleConfig())
+	require.NoError(t, err)
+
+	requireErrno(t, ErrnoNotcapable, mod, schedYieldName)
+}
@@ -1,6 +1,16 @@
 package wasi_snapshot_preview1
 
-import "github.com/tetratelabs/wazero/internal/wasm"
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
 
 const (
 	sockAcceptName   = "sock_accept"
@@ -9,39 +19,327 @@ const (
 	sockShutdownName = "sock_shutdown"
 )
 
+// https://github.com/WebAssembly/WASI/blob/snapshot-01/phases/snapshot/docs.md#-riflags-flagsu16
+const (
+	// riflagsRecvPeek returns the message without removing it from the
+	// socket's receive queue.
+	riflagsRecvPeek = 1 << iota
+	// riflagsRecvWaitall waits until the full request is satisfied.
+	riflagsRecvWaitall
+)
+
+// https://github.com/WebAssembly/WASI/blob/snapshot-01/phases/snapshot/docs.md#-sdflags-flagsu8
+const (
+	// sdflagsRd disables further receive operations.
+	sdflagsRd = 1 << iota
+	// sdflagsWr disables further send operations.
+	sdflagsWr
+)
+
 // sockAccept is the WASI function named sockAcceptName which accepts a new
 // incoming connection.
 //
+// # Parameters
+//
+//   - fd: file descriptor of a listener, opened via experimental.Sockets
+//   - flags: fdflags of the new connection, where only wasiFdflagsNonblock is
+//     allowed
+//   - resultFd: offset to write the file descriptor of the connection
+//
+// Result (Errno)
+//
+// The return value is ErrnoSuccess except the following error conditions:
+//   - ErrnoBadf: `fd` is invalid
+//   - ErrnoNotsock: `fd` is not a listener
+//   - ErrnoInval: `flags` are invalid
+//   - ErrnoFault: `resultFd` points to an offset out of memory
+//   - ErrnoIo: the listener failed to accept, e.g. as it was closed
+//   - ErrnoAgain: `fd` is non-blocking and there is no pending connection
+//
+// Note: This is similar to `accept` in POSIX.
 // See: https://github.com/WebAssembly/WASI/blob/0ba0c5e2e37625ca5a6d3e4255a998dfaa3efc52/phases/snapshot/docs.md#sock_accept
 // and https://github.com/WebAssembly/WASI/pull/458
-var sockAccept = stubFunction(
-	sockAcceptName,
+var sockAccept = newHostFunc(
+	sockAcceptName, sockAcceptFn,
 	[]wasm.ValueType{i32, i32, i32},
 	"fd", "flags", "result.fd",
 )
 
+func sockAcceptFn(_ context.Context, mod api.Module, params []uint64) Errno {
+	fsc := mod.(*wasm.CallContext).Sys.FS()
+
+	fd := uint32(params[0])
+	flags := uint32(params[1])
+	resultFd := uint32(params[2])
+
+	if flags&^uint32(wasiFdflagsNonblock) != 0 {
+		return ErrnoInval
+	}
+
+	l, err := fsc.Listener(fd)
+	if err != nil {
+		return sockErrno(err)
+	} else if wouldBlock(fsc, fd) {
+		return ErrnoAgain
+	}
+
+	conn, err := l.Accept()
+	if err != nil {
+		return ErrnoIo
+	}
+
+	connFd, err := fsc.OpenConn(conn)
+	if err != nil {
+		_ = conn.Close()
+		return toErrno(err)
+	}
+	if flags&uint32(wasiFdflagsNonblock) != 0 {
+		_ = fsc.SetFlag(connFd, platform.O_NONBLOCK) // connFd was just opened.
+	}
+
+	if !mod.Memory().WriteUint32Le(resultFd, connFd) {
+		_ = fsc.CloseFile(connFd)
+		return ErrnoFault
+	}
+	return ErrnoSuccess
+}
+
 // sockRecv is the WASI function named sockRecvName which receives a
 // message from a socket.
 //
+// # Parameters
+//
+//   - fd: file descriptor of a connection
+//   - riData: offset in api.Memory of iovecs to write data into, in the same
+//     format as fdRead
+//   - riDataCount: count of iovecs starting at riData
+//   - riFlags: riflags, where only riflagsRecvWaitall is supported
+//   - resultRoDatalen: offset to write the number of bytes received
+//   - resultRoFlags: offset to write the roflags, which are always zero
+//
+// Result (Errno)
+//
+// The return value is ErrnoSuccess except the following error conditions:
+//   - ErrnoBadf: `fd` is invalid
+//   - ErrnoNotsock: `fd` is not a connection
+//   - ErrnoNotsup: `riFlags` includes riflagsRecvPeek
+//   - ErrnoInval: `riFlags` are invalid
+//   - ErrnoFault: a parameter points to an offset out of memory
+//   - ErrnoIo: the connection failed to read
+//   - ErrnoAgain: `fd` is non-blocking and there is no data to receive
+//
+// Note: This is similar to `recv` in POSIX. When the peer closed the
+// connection, this succeeds with zero bytes received.
 // See: https://github.com/WebAssembly/WASI/blob/snapshot-01/phases/snapshot/docs.md#-sock_recvfd-fd-ri_data-iovec_array-ri_flags-riflags---errno-size-roflags
-var sockRecv = stubFunction(
-	sockRecvName,
+var sockRecv = newHostFunc(
+	sockRecvName, sockRecvFn,
 	[]wasm.ValueType{i32, i32, i32, i32, i32, i32},
 	"fd", "ri_data", "ri_data_count", "ri_flags", "result.ro_datalen", "result.ro_flags",
 )
 
+func sockRecvFn(_ context.Context, mod api.Module, params []uint64) Errno {
+	mem := mod.Memory()
+	fsc := mod.(*wasm.CallContext).Sys.FS()
+
+	fd := uint32(params[0])
+	riData := uint32(params[1])
+	riDataCount := uint32(params[2])
+	riFlags := uint32(params[3])
+	resultRoDatalen := uint32(params[4])
+	resultRoFlags := uint32(params[5])
+
+	if riFlags&riflagsRecvPeek != 0 {
+		return ErrnoNotsup
+	} else if riFlags&^(riflagsRecvPeek|riflagsRecvWaitall) != 0 {
+		return ErrnoInval
+	}
+
+	conn, err := fsc.Conn(fd)
+	if err != nil {
+		return sockErrno(err)
+	} else if wouldBlock(fsc, fd) {
+		return ErrnoAgain
+	}
+
+	bufs, ok := readIovecs(mem, riData, riDataCount)
+	if !ok {
+		return ErrnoFault
+	}
+
+	var r io.Reader = conn
+	if riFlags&riflagsRecvWaitall != 0 {
+		r = &fullReader{conn}
+	}
+	n, err := readv(r, bufs)
+	if err != nil && n == 0 && !errors.Is(err, io.EOF) {
+		return ErrnoIo
+	}
+	nread := uint32(n)
+
+	if !mem.WriteUint32Le(resultRoDatalen, nread) {
+		return ErrnoFault
+	} else if !mem.WriteUint16Le(resultRoFlags, 0) {
+		return ErrnoFault
+	}
+	return ErrnoSuccess
+}
+
+// fullReader is an io.Reader which reads until the buffer is full, EOF or an
+// error, for riflagsRecvWaitall.
+type fullReader struct {
+	r io.Reader
+}
+
+// Read implements io.Reader
+func (f *fullReader) Read(p []byte) (int, error) {
+	n, err := io.ReadFull(f.r, p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
+
 // sockSend is the WASI function named sockSendName which sends a message
 // on a socket.
 //
+// # Parameters
+//
+//   - fd: file descriptor of a connection
+//   - siData: offset in api.Memory of iovecs to read data from, in the same
+//     format as fdWrite
+//   - siDataCount: count of iovecs starting at siData
+//   - siFlags: siflags, which must be zero as none are defined
+//   - resultSoDatalen: offset to write the number of bytes sent
+//
+// Result (Errno)
+//
+// The return value is ErrnoSuccess except the following error conditions:
+//   - ErrnoBadf: `fd` is invalid
+//   - ErrnoNotsock: `fd` is not a connection
+//   - ErrnoInval: `siFlags` are invalid
+//   - ErrnoFault: a parameter points to an offset out of memory
+//   - ErrnoIo: the connection failed to write
+//
+// Note: This is similar to `send` in POSIX.
 // See: https://github.com/WebAssembly/WASI/blob/snapshot-01/phases/snapshot/docs.md#-sock_sendfd-fd-si_data-ciovec_array-si_flags-siflags---errno-size
-var sockSend = stubFunction(
-	sockSendName,
+var sockSend = newHostFunc(
+	sockSendName, sockSendFn,
 	[]wasm.ValueType{i32, i32, i32, i32, i32},
 	"fd", "si_data", "si_data_count", "si_flags", "result.so_datalen",
 )
 
+func sockSendFn(_ context.Context, mod api.Module, params []uint64) Errno {
+	mem := mod.Memory()
+	fsc := mod.(*wasm.CallContext).Sys.FS()
+
+	fd := uint32(params[0])
+	siData := uint32(params[1])
+	siDataCount := uint32(params[2])
+	siFlags := uint32(params[3])
+	resultSoDatalen := uint32(params[4])
+
+	if siFlags != 0 {
+		return ErrnoInval
+	}
+
+	conn, err := fsc.Conn(fd)
+	if err != nil {
+		return sockErrno(err)
+	}
+
+	bufs, ok := readIovecs(mem, siData, siDataCount)
+	if !ok {
+		return ErrnoFault
+	}
+	n, err := writev(conn, bufs)
+	if err != nil {
+		return ErrnoIo
+	}
+	nwritten := uint32(n)
+
+	if !mem.WriteUint32Le(resultSoDatalen, nwritten) {
+		return ErrnoFault
+	}
+	return ErrnoSuccess
+}
+
 // sockShutdown is the WASI function named sockShutdownName which shuts
 // down socket send and receive channels.
 //
+// # Parameters
+//
+//   - fd: file descriptor of a connection
+//   - how: sdflags of which channels to shut down
+//
+// Result (Errno)
+//
+// The return value is ErrnoSuccess except the following error conditions:
+//   - ErrnoBadf: `fd` is invalid
+//   - ErrnoNotsock: `fd` is not a connection
+//   - ErrnoInval: `how` is invalid
+//   - ErrnoNotsup: the connection can't shut down only one channel, e.g. it
+//     isn't a *net.TCPConn or *net.UnixConn
+//   - ErrnoIo: the connection failed to shut down
+//
+// Note: This is similar to `shutdown` in POSIX. The file descriptor remains
+// open until closed with fd_close.
 // See: https://github.com/WebAssembly/WASI/blob/snapshot-01/phases/snapshot/docs.md#-sock_shutdownfd-fd-how-sdflags---errno
-var sockShutdown = stubFunction(sockShutdownName, []wasm.ValueType{i32, i32}, "fd", "how")
+var sockShutdown = newHostFunc(
+	sockShutdownName, sockShutdownFn,
+	[]wasm.ValueType{i32, i32},
+	"fd", "how",
+)
+
+// halfCloser is implemented by *net.TCPConn and *net.UnixConn.
+type halfCloser interface {
+	CloseRead() error
+	CloseWrite() error
+}
+
+func sockShutdownFn(_ context.Context, mod api.Module, params []uint64) Errno {
+	fsc := mod.(*wasm.CallContext).Sys.FS()
+
+	fd := uint32(params[0])
+	how := uint32(params[1])
+
+	if how == 0 || how&^(sdflagsRd|sdflagsWr) != 0 {
+		return ErrnoInval
+	}
+
+	conn, err := fsc.Conn(fd)
+	if err != nil {
+		return sockErrno(err)
+	}
+
+	hc, ok := conn.(halfCloser)
+	if !ok {
+		return ErrnoNotsup
+	}
+
+	if how&sdflagsRd != 0 {
+		err = hc.CloseRead()
+	}
+	if how&sdflagsWr != 0 {
+		if e := hc.CloseWrite(); err == nil {
+			err = e
+		}
+	}
+	if err != nil {
+		return ErrnoIo
+	}
+	return ErrnoSuccess
+}
+
+// sockErrno converts an error from looking up a socket file descriptor.
+func sockErrno(err error) Errno {
+	if errors.Is(err, syscall.ENOTSOCK) {
+		return ErrnoNotsock
+	}
+	return toErrno(err)
+}
+
+// compile-time check to ensure the types used by sockShutdown implement it.
+var (
+	_ halfCloser = (*net.TCPConn)(nil)
+	_ halfCloser = (*net.UnixConn)(nil)
+)
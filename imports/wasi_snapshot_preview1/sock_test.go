@@ -1,43 +1,477 @@
 package wasi_snapshot_preview1
 
 import (
+	"bytes"
+	"context"
+	"io"
+	"net"
 	"testing"
+	"time"
 
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/testing/proxy"
 	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
 )
 
-// Test_sockAccept only tests it is stubbed for GrainLang per #271
+// requireSocketsModule is like requireProxyModule, except the proxy module is
+// instantiated with the given sockets. With no file system, the first socket
+// is file descriptor 3.
+func requireSocketsModule(t *testing.T, sockets experimental.Sockets) (api.Module, api.Closer, *bytes.Buffer) {
+	var log bytes.Buffer
+
+	// Set context to one that has an experimental listener
+	ctx := context.WithValue(testCtx, experimental.FunctionListenerFactoryKey{}, proxy.NewLoggingListenerFactory(&log))
+
+	r := wazero.NewRuntime(ctx)
+
+	wasiModuleCompiled, err := (&builder{r: r}).hostModuleBuilder().Compile(ctx)
+	require.NoError(t, err)
+
+	_, err = r.InstantiateModule(ctx, wasiModuleCompiled, wazero.NewModuleConfig())
+	require.NoError(t, err)
+
+	proxyBin := proxy.NewModuleBinary(ModuleName, wasiModuleCompiled)
+
+	proxyCompiled, err := r.CompileModule(ctx, proxyBin)
+	require.NoError(t, err)
+
+	ctx = context.WithValue(ctx, experimental.SocketsKey{}, sockets)
+	mod, err := r.InstantiateModule(ctx, proxyCompiled, wazero.NewModuleConfig())
+	require.NoError(t, err)
+
+	return mod, r, &log
+}
+
+// tcpConnPair returns both ends of a loopback TCP connection.
+func tcpConnPair(t *testing.T) (server, client net.Conn) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	client, err = net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	server, err = l.Accept()
+	require.NoError(t, err)
+	return
+}
+
 func Test_sockAccept(t *testing.T) {
-	log := requireErrnoNosys(t, sockAcceptName, 0, 0, 0)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	mod, r, log := requireSocketsModule(t, experimental.Sockets{Listeners: []net.Listener{l}})
+	defer r.Close(testCtx)
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	listenerFd, resultFd := uint64(3), uint64(16)
+	requireErrno(t, ErrnoSuccess, mod, sockAcceptName, listenerFd, 0, resultFd)
+	require.Equal(t, `
+==> wasi_snapshot_preview1.sock_accept(fd=3,flags=0,result.fd=16)
+<== ESUCCESS
+`, "\n"+log.String())
+
+	connFd, ok := mod.Memory().ReadUint32Le(uint32(resultFd))
+	require.True(t, ok)
+	require.Equal(t, uint32(4), connFd)
+
+	// Closing the module closes the listener and the accepted connection.
+	require.NoError(t, mod.Close(testCtx))
+	_, err = client.Read(make([]byte, 1))
+	require.Equal(t, io.EOF, err)
+	_, err = l.Accept()
+	require.Error(t, err)
+}
+
+func Test_sockAccept_nonblock(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	mod, r, log := requireSocketsModule(t, experimental.Sockets{Listeners: []net.Listener{l}})
+	defer r.Close(testCtx)
+
+	listenerFd, resultFd := uint64(3), uint64(16)
+	requireErrno(t, ErrnoSuccess, mod, fdFdstatSetFlagsName, listenerFd, uint64(wasiFdflagsNonblock))
+	log.Reset()
+
+	// Accepting without a pending connection would block, so it fails instead.
+	requireErrno(t, ErrnoAgain, mod, sockAcceptName, listenerFd, uint64(wasiFdflagsNonblock), resultFd)
 	require.Equal(t, `
---> wasi_snapshot_preview1.sock_accept(fd=0,flags=0,result.fd=0)
-<-- ENOSYS
-`, log)
+==> wasi_snapshot_preview1.sock_accept(fd=3,flags=NONBLOCK,result.fd=16)
+<== EAGAIN
+`, "\n"+log.String())
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	// The connection may not be pending immediately, so retry.
+	requireSuccessAfterErrnoAgain(t, mod, sockAcceptName, listenerFd, uint64(wasiFdflagsNonblock), resultFd)
+
+	// The accepted connection inherits the non-blocking flag.
+	connFd, ok := mod.Memory().ReadUint32Le(uint32(resultFd))
+	require.True(t, ok)
+	f, ok := mod.(*wasm.CallContext).Sys.FS().OpenedFile(connFd)
+	require.True(t, ok)
+	require.Equal(t, platform.O_NONBLOCK, f.Flag)
+}
+
+// requireSuccessAfterErrnoAgain calls the function until it no longer returns
+// ErrnoAgain, and requires it then succeeds.
+func requireSuccessAfterErrnoAgain(t *testing.T, mod api.Module, funcName string, params ...uint64) {
+	deadline := time.Now().Add(time.Second)
+	for {
+		results, err := mod.ExportedFunction(funcName).Call(testCtx, params...)
+		require.NoError(t, err)
+		if errno := Errno(results[0]); errno != ErrnoAgain {
+			require.Equal(t, ErrnoSuccess, errno, ErrnoName(errno))
+			return
+		}
+		require.True(t, time.Now().Before(deadline), "timed out waiting for %s", funcName)
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func Test_sockAccept_Errors(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	mod, r, log := requireSocketsModule(t, experimental.Sockets{Listeners: []net.Listener{l}})
+	defer r.Close(testCtx)
+
+	tests := []struct {
+		name          string
+		fd, flags     uint64
+		expectedErrno Errno
+		expectedLog   string
+	}{
+		{
+			name:          "invalid fd",
+			fd:            42,
+			expectedErrno: ErrnoBadf,
+			expectedLog: `
+==> wasi_snapshot_preview1.sock_accept(fd=42,flags=0,result.fd=0)
+<== EBADF
+`,
+		},
+		{
+			name:          "not a listener",
+			fd:            0, // stdin
+			expectedErrno: ErrnoNotsock,
+			expectedLog: `
+==> wasi_snapshot_preview1.sock_accept(fd=0,flags=0,result.fd=0)
+<== ENOTSOCK
+`,
+		},
+		{
+			name:          "invalid flags",
+			fd:            3,
+			flags:         uint64(wasiFdflagsAppend),
+			expectedErrno: ErrnoInval,
+			expectedLog: `
+==> wasi_snapshot_preview1.sock_accept(fd=3,flags=APPEND,result.fd=0)
+<== EINVAL
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			defer log.Reset()
+
+			requireErrno(t, tc.expectedErrno, mod, sockAcceptName, tc.fd, tc.flags, 0)
+			require.Equal(t, tc.expectedLog, "\n"+log.String())
+		})
+	}
 }
 
-// Test_sockRecv only tests it is stubbed for GrainLang per #271
 func Test_sockRecv(t *testing.T) {
-	log := requireErrnoNosys(t, sockRecvName, 0, 0, 0, 0, 0, 0)
+	server, client := tcpConnPair(t)
+	defer client.Close()
+
+	mod, r, log := requireSocketsModule(t, experimental.Sockets{Conns: []net.Conn{server}})
+	defer r.Close(testCtx)
+
+	_, err := client.Write([]byte("wazero"))
+	require.NoError(t, err)
+
+	iovs := uint32(1) // arbitrary offset
+	initialMemory := []byte{
+		'?',         // `iovs` is after this
+		18, 0, 0, 0, // = iovs[0].offset
+		4, 0, 0, 0, // = iovs[0].length
+		23, 0, 0, 0, // = iovs[1].offset
+		2, 0, 0, 0, // = iovs[1].length
+		'?',
+	}
+	iovsCount := uint32(2)        // The count of iovs
+	resultRoDatalen := uint32(26) // arbitrary offset
+	resultRoFlags := uint32(30)   // arbitrary offset
+	expectedMemory := append(
+		initialMemory,
+		'w', 'a', 'z', 'e', // iovs[0].length bytes
+		'?',      // iovs[1].offset is after this
+		'r', 'o', // iovs[1].length bytes
+		'?',        // resultRoDatalen is after this
+		6, 0, 0, 0, // sum(iovs[...].length) == length of "wazero"
+		0, 0, // roflags
+		'?',
+	)
+
+	maskMemory(t, mod, len(expectedMemory))
+	ok := mod.Memory().Write(0, initialMemory)
+	require.True(t, ok)
+
+	// Wait for all the data, as TCP could otherwise deliver it in pieces.
+	requireErrno(t, ErrnoSuccess, mod, sockRecvName, 3, uint64(iovs), uint64(iovsCount),
+		riflagsRecvWaitall, uint64(resultRoDatalen), uint64(resultRoFlags))
+	require.Equal(t, `
+==> wasi_snapshot_preview1.sock_recv(fd=3,ri_data=1,ri_data_count=2,ri_flags=RECV_WAITALL,result.ro_datalen=26,result.ro_flags=30)
+<== ESUCCESS
+`, "\n"+log.String())
+
+	actual, ok := mod.Memory().Read(0, uint32(len(expectedMemory)))
+	require.True(t, ok)
+	require.Equal(t, expectedMemory, actual)
+}
+
+func Test_sockRecv_nonblock(t *testing.T) {
+	server, client := tcpConnPair(t)
+	defer client.Close()
+
+	mod, r, log := requireSocketsModule(t, experimental.Sockets{Conns: []net.Conn{server}})
+	defer r.Close(testCtx)
+
+	connFd := uint64(3)
+	requireErrno(t, ErrnoSuccess, mod, fdFdstatSetFlagsName, connFd, uint64(wasiFdflagsNonblock))
+	log.Reset()
+
+	ok := mod.Memory().Write(0, []byte{8, 0, 0, 0, 6, 0, 0, 0}) // iovs[0] = 8..14
+	require.True(t, ok)
+
+	// Receiving without data would block, so it fails instead.
+	requireErrno(t, ErrnoAgain, mod, sockRecvName, connFd, 0, 1, 0, 16, 20)
 	require.Equal(t, `
---> wasi_snapshot_preview1.sock_recv(fd=0,ri_data=0,ri_data_count=0,ri_flags=0,result.ro_datalen=0,result.ro_flags=0)
-<-- ENOSYS
-`, log)
+==> wasi_snapshot_preview1.sock_recv(fd=3,ri_data=0,ri_data_count=1,ri_flags=0,result.ro_datalen=16,result.ro_flags=20)
+<== EAGAIN
+`, "\n"+log.String())
+
+	_, err := client.Write([]byte("wazero"))
+	require.NoError(t, err)
+
+	// The data may not arrive immediately, so retry.
+	requireSuccessAfterErrnoAgain(t, mod, sockRecvName, connFd, 0, 1, riflagsRecvWaitall, 16, 20)
+
+	buf, ok := mod.Memory().Read(8, 6)
+	require.True(t, ok)
+	require.Equal(t, "wazero", string(buf))
+}
+
+func Test_sockRecv_Errors(t *testing.T) {
+	server, client := tcpConnPair(t)
+	defer client.Close()
+
+	mod, r, log := requireSocketsModule(t, experimental.Sockets{Conns: []net.Conn{server}})
+	defer r.Close(testCtx)
+
+	tests := []struct {
+		name          string
+		fd, riFlags   uint64
+		expectedErrno Errno
+		expectedLog   string
+	}{
+		{
+			name:          "invalid fd",
+			fd:            42,
+			expectedErrno: ErrnoBadf,
+			expectedLog: `
+==> wasi_snapshot_preview1.sock_recv(fd=42,ri_data=0,ri_data_count=0,ri_flags=0,result.ro_datalen=0,result.ro_flags=0)
+<== EBADF
+`,
+		},
+		{
+			name:          "not a connection",
+			fd:            0, // stdin
+			expectedErrno: ErrnoNotsock,
+			expectedLog: `
+==> wasi_snapshot_preview1.sock_recv(fd=0,ri_data=0,ri_data_count=0,ri_flags=0,result.ro_datalen=0,result.ro_flags=0)
+<== ENOTSOCK
+`,
+		},
+		{
+			name:          "peek unsupported",
+			fd:            3,
+			riFlags:       riflagsRecvPeek,
+			expectedErrno: ErrnoNotsup,
+			expectedLog: `
+==> wasi_snapshot_preview1.sock_recv(fd=3,ri_data=0,ri_data_count=0,ri_flags=RECV_PEEK,result.ro_datalen=0,result.ro_flags=0)
+<== ENOTSUP
+`,
+		},
+		{
+			name:          "invalid flags",
+			fd:            3,
+			riFlags:       4,
+			expectedErrno: ErrnoInval,
+			expectedLog: `
+==> wasi_snapshot_preview1.sock_recv(fd=3,ri_data=0,ri_data_count=0,ri_flags=0x4,result.ro_datalen=0,result.ro_flags=0)
+<== EINVAL
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			defer log.Reset()
+
+			requireErrno(t, tc.expectedErrno, mod, sockRecvName, tc.fd, 0, 0, tc.riFlags, 0, 0)
+			require.Equal(t, tc.expectedLog, "\n"+log.String())
+		})
+	}
 }
 
-// Test_sockSend only tests it is stubbed for GrainLang per #271
 func Test_sockSend(t *testing.T) {
-	log := requireErrnoNosys(t, sockSendName, 0, 0, 0, 0, 0)
+	server, client := tcpConnPair(t)
+	defer client.Close()
+
+	mod, r, log := requireSocketsModule(t, experimental.Sockets{Conns: []net.Conn{server}})
+	defer r.Close(testCtx)
+
+	iovs := uint32(1) // arbitrary offset
+	initialMemory := []byte{
+		'?',         // `iovs` is after this
+		18, 0, 0, 0, // = iovs[0].offset
+		4, 0, 0, 0, // = iovs[0].length
+		23, 0, 0, 0, // = iovs[1].offset
+		2, 0, 0, 0, // = iovs[1].length
+		'?',                // iovs[0].offset is after this
+		'w', 'a', 'z', 'e', // iovs[0].length bytes
+		'?',      // iovs[1].offset is after this
+		'r', 'o', // iovs[1].length bytes
+		'?',
+	}
+	iovsCount := uint32(2)        // The count of iovs
+	resultSoDatalen := uint32(26) // arbitrary offset
+
+	maskMemory(t, mod, len(initialMemory)+4)
+	ok := mod.Memory().Write(0, initialMemory)
+	require.True(t, ok)
+
+	requireErrno(t, ErrnoSuccess, mod, sockSendName, 3, uint64(iovs), uint64(iovsCount), 0,
+		uint64(resultSoDatalen))
 	require.Equal(t, `
---> wasi_snapshot_preview1.sock_send(fd=0,si_data=0,si_data_count=0,si_flags=0,result.so_datalen=0)
-<-- ENOSYS
-`, log)
+==> wasi_snapshot_preview1.sock_send(fd=3,si_data=1,si_data_count=2,si_flags=0,result.so_datalen=26)
+<== ESUCCESS
+`, "\n"+log.String())
+
+	sent, ok := mod.Memory().ReadUint32Le(resultSoDatalen)
+	require.True(t, ok)
+	require.Equal(t, uint32(6), sent)
+
+	buf := make([]byte, 6)
+	_, err := io.ReadFull(client, buf)
+	require.NoError(t, err)
+	require.Equal(t, "wazero", string(buf))
+}
+
+func Test_sockSend_Errors(t *testing.T) {
+	server, client := tcpConnPair(t)
+	defer client.Close()
+
+	mod, r, log := requireSocketsModule(t, experimental.Sockets{Conns: []net.Conn{server}})
+	defer r.Close(testCtx)
+
+	tests := []struct {
+		name          string
+		fd, siFlags   uint64
+		expectedErrno Errno
+		expectedLog   string
+	}{
+		{
+			name:          "invalid fd",
+			fd:            42,
+			expectedErrno: ErrnoBadf,
+			expectedLog: `
+==> wasi_snapshot_preview1.sock_send(fd=42,si_data=0,si_data_count=0,si_flags=0,result.so_datalen=0)
+<== EBADF
+`,
+		},
+		{
+			name:          "not a connection",
+			fd:            1, // stdout
+			expectedErrno: ErrnoNotsock,
+			expectedLog: `
+==> wasi_snapshot_preview1.sock_send(fd=1,si_data=0,si_data_count=0,si_flags=0,result.so_datalen=0)
+<== ENOTSOCK
+`,
+		},
+		{
+			name:          "invalid flags",
+			fd:            3,
+			siFlags:       1,
+			expectedErrno: ErrnoInval,
+			expectedLog: `
+==> wasi_snapshot_preview1.sock_send(fd=3,si_data=0,si_data_count=0,si_flags=1,result.so_datalen=0)
+<== EINVAL
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			defer log.Reset()
+
+			requireErrno(t, tc.expectedErrno, mod, sockSendName, tc.fd, 0, 0, tc.siFlags, 0)
+			require.Equal(t, tc.expectedLog, "\n"+log.String())
+		})
+	}
 }
 
-// Test_sockShutdown only tests it is stubbed for GrainLang per #271
 func Test_sockShutdown(t *testing.T) {
-	log := requireErrnoNosys(t, sockShutdownName, 0, 0)
+	server, client := tcpConnPair(t)
+	defer client.Close()
+
+	pipeServer, pipeClient := net.Pipe()
+	defer pipeClient.Close()
+
+	mod, r, log := requireSocketsModule(t, experimental.Sockets{Conns: []net.Conn{server, pipeServer}})
+	defer r.Close(testCtx)
+
+	requireErrno(t, ErrnoSuccess, mod, sockShutdownName, 3, sdflagsWr)
 	require.Equal(t, `
---> wasi_snapshot_preview1.sock_shutdown(fd=0,how=0)
-<-- ENOSYS
-`, log)
+==> wasi_snapshot_preview1.sock_shutdown(fd=3,how=WR)
+<== ESUCCESS
+`, "\n"+log.String())
+
+	// The peer sees EOF, as the write side was shut down.
+	_, err := client.Read(make([]byte, 1))
+	require.Equal(t, io.EOF, err)
+
+	tests := []struct {
+		name          string
+		fd, how       uint64
+		expectedErrno Errno
+	}{
+		{name: "invalid fd", fd: 42, how: sdflagsRd, expectedErrno: ErrnoBadf},
+		{name: "not a connection", fd: 0, how: sdflagsRd, expectedErrno: ErrnoNotsock},
+		{name: "how zero", fd: 3, how: 0, expectedErrno: ErrnoInval},
+		{name: "how invalid", fd: 3, how: 4, expectedErrno: ErrnoInval},
+		{name: "can't half-close", fd: 4, how: sdflagsRd, expectedErrno: ErrnoNotsup},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			requireErrno(t, tc.expectedErrno, mod, sockShutdownName, tc.fd, tc.how)
+		})
+	}
 }
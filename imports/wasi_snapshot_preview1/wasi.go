@@ -69,19 +69,96 @@ type Builder interface {
 	//
 	// Note: This has the same effect as the same function on wazero.HostModuleBuilder.
 	Instantiate(context.Context, wazero.Namespace) (api.Closer, error)
+
+	// WithDeniedFunctions denies calls to the WASI functions of the given
+	// names, e.g. "path_unlink_file". A denied function returns
+	// ErrnoNotcapable without side effects.
+	//
+	// Note: A denied function without an errno result, such as "proc_exit",
+	// traps instead.
+	WithDeniedFunctions(names ...string) Builder
+
+	// WithDeniedCapabilities denies calls to all WASI functions in the given
+	// capability groups, the same as WithDeniedFunctions. e.g.
+	//
+	//	// Run an untrusted module without network or filesystem access.
+	//	wasi_snapshot_preview1.NewBuilder(r).
+	//		WithDeniedCapabilities(CapabilitySock, CapabilityPath).
+	//		Instantiate(ctx, r)
+	WithDeniedCapabilities(capabilities ...Capability) Builder
+
+	// WithSchedYield makes "sched_yield" call runtime.Gosched and return
+	// ErrnoSuccess. Defaults to return ErrnoNosys without side effects.
+	//
+	// Use this for guests compiled with thread support, but run on a single
+	// thread, which spin on sched_yield while waiting, e.g. for a lock. This
+	// lets other goroutines in the host, such as one writing to stdin, make
+	// progress.
+	//
+	// Note: This has no effect if CapabilitySched is denied.
+	WithSchedYield() Builder
+
+	// WithFileLocks makes "path_open" place an advisory lock on each regular
+	// file it opens, released when the file is closed. The lock is exclusive
+	// if the file is opened for writing, otherwise shared. Defaults to not
+	// lock files.
+	//
+	// WASI has no functions to lock files, so this lets guests such as
+	// SQLite, which assume no other process uses the same database, fail
+	// with ErrnoAgain instead of corrupting it.
+	//
+	// Note: This has no effect if CapabilityPath is denied, or on files which
+	// aren't on the host, or on hosts without flock, such as Windows.
+	WithFileLocks() Builder
 }
 
 // NewBuilder returns a new Builder.
 func NewBuilder(r wazero.Runtime) Builder {
-	return &builder{r}
+	return &builder{r: r}
+}
+
+type builder struct {
+	r          wazero.Runtime
+	policy     capabilityPolicy
+	schedYield bool
+	fileLocks  bool
+}
+
+// WithDeniedFunctions implements Builder.WithDeniedFunctions
+func (b *builder) WithDeniedFunctions(names ...string) Builder {
+	b.policy.denyFunctions(names)
+	return b
 }
 
-type builder struct{ r wazero.Runtime }
+// WithDeniedCapabilities implements Builder.WithDeniedCapabilities
+func (b *builder) WithDeniedCapabilities(capabilities ...Capability) Builder {
+	b.policy.denied |= capabilitySet(capabilities)
+	return b
+}
+
+// WithSchedYield implements Builder.WithSchedYield
+func (b *builder) WithSchedYield() Builder {
+	b.schedYield = true
+	return b
+}
+
+// WithFileLocks implements Builder.WithFileLocks
+func (b *builder) WithFileLocks() Builder {
+	b.fileLocks = true
+	return b
+}
 
 // hostModuleBuilder returns a new wazero.HostModuleBuilder for ModuleName
 func (b *builder) hostModuleBuilder() wazero.HostModuleBuilder {
 	ret := b.r.NewHostModuleBuilder(ModuleName)
-	exportFunctions(ret)
+	exporter := b.policy.exporter(ret.(wasm.HostFuncExporter))
+	exportFunctions(exporter)
+	if b.schedYield {
+		exporter.ExportHostFunc(schedYieldGosched) // overrides the stub
+	}
+	if b.fileLocks {
+		exporter.ExportHostFunc(pathOpenLock)
+	}
 	return ret
 }
 
@@ -132,7 +209,7 @@ type functionExporter struct{}
 
 // ExportFunctions implements FunctionExporter.ExportFunctions
 func (functionExporter) ExportFunctions(builder wazero.HostModuleBuilder) {
-	exportFunctions(builder)
+	exportFunctions(builder.(wasm.HostFuncExporter))
 }
 
 // ## Translation notes
@@ -164,9 +241,7 @@ func (functionExporter) ExportFunctions(builder wazero.HostModuleBuilder) {
 
 // exportFunctions adds all go functions that implement wasi.
 // These should be exported in the module named ModuleName.
-func exportFunctions(builder wazero.HostModuleBuilder) {
-	exporter := builder.(wasm.HostFuncExporter)
-
+func exportFunctions(exporter wasm.HostFuncExporter) {
 	// Note: these are ordered per spec for consistency even if the resulting
 	// map can't guarantee that.
 	// See https://github.com/WebAssembly/WASI/blob/snapshot-01/phases/snapshot/docs.md#functions
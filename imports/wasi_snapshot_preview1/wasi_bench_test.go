@@ -172,7 +172,7 @@ func Benchmark_fdReaddir(b *testing.B) {
 
 			// Open the root directory as a file-descriptor.
 			fsc := mod.(*wasm.CallContext).Sys.FS()
-			fd, err := fsc.OpenFile(".")
+			fd, err := fsc.OpenFile(".", os.O_RDONLY, 0)
 			if err != nil {
 				b.Fatal(err)
 			}
@@ -278,7 +278,7 @@ func Benchmark_pathFilestat(b *testing.B) {
 			fd := sys.FdRoot
 			if bc.fd != sys.FdRoot {
 				fsc := mod.(*wasm.CallContext).Sys.FS()
-				fd, err = fsc.OpenFile("zig")
+				fd, err = fsc.OpenFile("zig", os.O_RDONLY, 0)
 				if err != nil {
 					b.Fatal(err)
 				}
@@ -389,7 +389,7 @@ func Benchmark_fdWrite(b *testing.B) {
 
 // instantiateProxyModule instantiates a guest that re-exports WASI functions.
 func instantiateProxyModule(r wazero.Runtime, config wazero.ModuleConfig) (api.Module, error) {
-	wasiModuleCompiled, err := (&builder{r}).hostModuleBuilder().Compile(testCtx)
+	wasiModuleCompiled, err := (&builder{r: r}).hostModuleBuilder().Compile(testCtx)
 	if err != nil {
 		return nil, err
 	}
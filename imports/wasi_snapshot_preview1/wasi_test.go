@@ -88,7 +88,7 @@ func requireProxyModule(t *testing.T, config wazero.ModuleConfig) (api.Module, a
 
 	r := wazero.NewRuntime(ctx)
 
-	wasiModuleCompiled, err := (&builder{r}).hostModuleBuilder().Compile(ctx)
+	wasiModuleCompiled, err := (&builder{r: r}).hostModuleBuilder().Compile(ctx)
 	require.NoError(t, err)
 
 	_, err = r.InstantiateModule(ctx, wasiModuleCompiled, config)
@@ -118,7 +118,7 @@ func requireErrnoNosys(t *testing.T, funcName string, params ...uint64) string {
 	defer r.Close(ctx)
 
 	// Instantiate the wasi module.
-	wasiModuleCompiled, err := (&builder{r}).hostModuleBuilder().Compile(ctx)
+	wasiModuleCompiled, err := (&builder{r: r}).hostModuleBuilder().Compile(ctx)
 	require.NoError(t, err)
 
 	_, err = r.InstantiateModule(ctx, wasiModuleCompiled, wazero.NewModuleConfig())
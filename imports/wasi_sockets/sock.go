@@ -0,0 +1,606 @@
+package wasi_sockets
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"unicode/utf8"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	internalsys "github.com/tetratelabs/wazero/internal/sys"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// Errno is the same as wasi_snapshot_preview1.Errno, as guests use both
+// modules together.
+type Errno = wasi_snapshot_preview1.Errno
+
+const (
+	resolveAddressesName = "resolve_addresses"
+	tcpCreateSocketName  = "tcp_create_socket"
+	udpCreateSocketName  = "udp_create_socket"
+	bindName             = "bind"
+	listenName           = "listen"
+	connectName          = "connect"
+	localAddressName     = "local_address"
+	remoteAddressName    = "remote_address"
+)
+
+// https://github.com/WebAssembly/wasi-sockets/blob/main/wit/network.wit
+const (
+	// addressFamilyIPv4 is the ip-address-family "ipv4".
+	addressFamilyIPv4 = iota
+	// addressFamilyIPv6 is the ip-address-family "ipv6".
+	addressFamilyIPv6
+)
+
+// socketAddressLen is the size in bytes of an ip-socket-address.
+const socketAddressLen = 20
+
+// resolver resolves host names and connects on behalf of the guest, to
+// allowed names and addresses only.
+type resolver struct {
+	resolver *net.Resolver
+	// allowedHosts are the patterns of names allowed, or nil for any.
+	allowedHosts []string
+	// allowedAddresses are the host:port addresses the guest can connect to,
+	// or nil for any.
+	allowedAddresses []string
+}
+
+// isAllowed returns true if the host name matches an allowed host.
+func (r *resolver) isAllowed(name string) bool {
+	if r.allowedHosts == nil {
+		return true
+	}
+
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	for _, allowed := range r.allowedHosts {
+		allowed = strings.ToLower(allowed)
+		if allowed == "*" {
+			return true
+		} else if strings.HasPrefix(allowed, "*.") {
+			if strings.HasSuffix(name, allowed[1:]) {
+				return true
+			}
+		} else if allowed == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isAllowedAddress returns true if the IP address and port match an allowed
+// address. Host names are resolved now, so that they match their current IP
+// addresses.
+func (r *resolver) isAllowedAddress(ctx context.Context, ip net.IP, port int) bool {
+	if r.allowedAddresses == nil {
+		return true
+	}
+
+	for _, allowed := range r.allowedAddresses {
+		host, allowedPort, err := net.SplitHostPort(allowed)
+		if err != nil || allowedPort != strconv.Itoa(port) {
+			continue
+		}
+		if allowedIP := net.ParseIP(host); allowedIP != nil {
+			if allowedIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+		addrs, err := r.resolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if addr.IP.Equal(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resolveAddresses returns the function named resolveAddressesName which
+// resolves a host name to IP addresses.
+//
+// # Parameters
+//
+//   - name: offset in api.Memory of the UTF-8 host name, e.g. "localhost"
+//   - nameLen: length of the host name
+//   - buf: offset in api.Memory to write the ip-socket-addresses, with a zero
+//     port
+//   - bufLen: maximum count of ip-socket-addresses to write
+//   - resultNresolved: offset to write the count of ip-socket-addresses
+//     written
+//
+// Result (Errno)
+//
+// The return value is ErrnoSuccess except the following error conditions:
+//   - ErrnoInval: `name` is empty or not valid UTF-8
+//   - ErrnoAcces: `name` isn't allowed by Builder.WithAllowedHosts
+//   - ErrnoNoent: `name` could not be resolved
+//   - ErrnoAgain: resolving `name` failed temporarily
+//   - ErrnoFault: a parameter points to an offset out of memory
+//   - ErrnoIo: resolving `name` failed
+//
+// Note: This is similar to `getaddrinfo` in POSIX, except addresses beyond
+// bufLen are dropped. An IP address is returned as is, without checking
+// whether it's allowed, as no lookup is needed.
+func (r *resolver) resolveAddresses() *wasm.HostFunc {
+	return newHostFunc(
+		resolveAddressesName, r.resolveAddressesFn,
+		[]api.ValueType{i32, i32, i32, i32, i32},
+		"name", "name_len", "buf", "buf_len", "result.nresolved",
+	)
+}
+
+func (r *resolver) resolveAddressesFn(ctx context.Context, mod api.Module, params []uint64) Errno {
+	mem := mod.Memory()
+
+	name := uint32(params[0])
+	nameLen := uint32(params[1])
+	buf := uint32(params[2])
+	bufLen := uint32(params[3])
+	resultNresolved := uint32(params[4])
+
+	nameBuf, ok := mem.Read(name, nameLen)
+	if !ok {
+		return wasi_snapshot_preview1.ErrnoFault
+	} else if nameLen == 0 || !utf8.Valid(nameBuf) {
+		return wasi_snapshot_preview1.ErrnoInval
+	}
+	outBuf, ok := mem.Read(buf, bufLen*socketAddressLen)
+	if !ok {
+		return wasi_snapshot_preview1.ErrnoFault
+	}
+
+	host := string(nameBuf)
+	if net.ParseIP(host) == nil && !r.isAllowed(host) {
+		return wasi_snapshot_preview1.ErrnoAcces
+	}
+
+	addrs, err := r.resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		var dnsErr *net.DNSError
+		switch {
+		case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+			return wasi_snapshot_preview1.ErrnoNoent
+		case errors.As(err, &dnsErr) && dnsErr.IsTemporary:
+			return wasi_snapshot_preview1.ErrnoAgain
+		}
+		return wasi_snapshot_preview1.ErrnoIo
+	}
+
+	var nresolved uint32
+	for _, addr := range addrs {
+		if nresolved == bufLen {
+			break
+		}
+		writeSocketAddress(outBuf[nresolved*socketAddressLen:], addr.IP, 0)
+		nresolved++
+	}
+
+	if !mem.WriteUint32Le(resultNresolved, nresolved) {
+		return wasi_snapshot_preview1.ErrnoFault
+	}
+	return wasi_snapshot_preview1.ErrnoSuccess
+}
+
+// tcpCreateSocket is the function named tcpCreateSocketName which creates a
+// TCP socket. Use bind, then listen to accept connections with
+// "sock_accept", or connect to use it as a client.
+//
+// # Parameters
+//
+//   - addressFamily: ip-address-family of the socket
+//   - resultFd: offset to write the file descriptor of the socket
+//
+// Result (Errno)
+//
+// The return value is ErrnoSuccess except the following error conditions:
+//   - ErrnoAfnosupport: `addressFamily` is invalid
+//   - ErrnoNfile: there are no file descriptors left
+//   - ErrnoFault: `resultFd` points to an offset out of memory
+//
+// Note: This is similar to `socket` in POSIX.
+var tcpCreateSocket = newHostFunc(
+	tcpCreateSocketName, tcpCreateSocketFn,
+	[]api.ValueType{i32, i32},
+	"address_family", "result.fd",
+)
+
+func tcpCreateSocketFn(_ context.Context, mod api.Module, params []uint64) Errno {
+	return createSocket(mod, "tcp", uint32(params[0]), uint32(params[1]))
+}
+
+// udpCreateSocket is the function named udpCreateSocketName which creates a
+// UDP socket. Use bind to receive datagrams with "sock_recv", or connect to
+// also send them with "sock_send".
+//
+// See tcpCreateSocket for parameters and results.
+var udpCreateSocket = newHostFunc(
+	udpCreateSocketName, udpCreateSocketFn,
+	[]api.ValueType{i32, i32},
+	"address_family", "result.fd",
+)
+
+func udpCreateSocketFn(_ context.Context, mod api.Module, params []uint64) Errno {
+	return createSocket(mod, "udp", uint32(params[0]), uint32(params[1]))
+}
+
+func createSocket(mod api.Module, network string, addressFamily, resultFd uint32) Errno {
+	fsc := mod.(*wasm.CallContext).Sys.FS()
+
+	switch addressFamily {
+	case addressFamilyIPv4:
+		network += "4"
+	case addressFamilyIPv6:
+		network += "6"
+	default:
+		return wasi_snapshot_preview1.ErrnoAfnosupport
+	}
+
+	fd, err := fsc.OpenSocket(&internalsys.Socket{Network: network})
+	if err != nil {
+		return wasi_snapshot_preview1.ErrnoNfile
+	}
+
+	if !mod.Memory().WriteUint32Le(resultFd, fd) {
+		_ = fsc.CloseFile(fd)
+		return wasi_snapshot_preview1.ErrnoFault
+	}
+	return wasi_snapshot_preview1.ErrnoSuccess
+}
+
+// bind is the function named bindName which assigns a local address to a
+// socket.
+//
+// # Parameters
+//
+//   - fd: file descriptor of a socket created by this module
+//   - address: offset in api.Memory of the ip-socket-address, where a zero
+//     port chooses any available port
+//
+// Result (Errno)
+//
+// The return value is ErrnoSuccess except the following error conditions:
+//   - ErrnoBadf: `fd` is invalid
+//   - ErrnoNotsock: `fd` is not a socket
+//   - ErrnoInval: the socket is already bound, listening or connected
+//   - ErrnoAfnosupport: `address` is not of the socket's address family
+//   - ErrnoAddrinuse: the UDP address is already in use
+//   - ErrnoFault: `address` points to an offset out of memory
+//
+// Note: This is similar to `bind` in POSIX. The address of a TCP socket is
+// only reserved on listen or connect, so ErrnoAddrinuse is returned from
+// those instead.
+var bind = newHostFunc(
+	bindName, bindFn,
+	[]api.ValueType{i32, i32},
+	"fd", "address",
+)
+
+func bindFn(_ context.Context, mod api.Module, params []uint64) Errno {
+	fsc := mod.(*wasm.CallContext).Sys.FS()
+
+	fd := uint32(params[0])
+	address := uint32(params[1])
+
+	s, err := fsc.Socket(fd)
+	if errors.Is(err, syscall.EISCONN) {
+		return wasi_snapshot_preview1.ErrnoInval
+	} else if err != nil {
+		return socketErrno(err)
+	} else if s.LocalAddr != nil {
+		return wasi_snapshot_preview1.ErrnoInval
+	}
+
+	ip, port, errno := readSocketAddress(mod.Memory(), address, s.Network)
+	if errno != wasi_snapshot_preview1.ErrnoSuccess {
+		return errno
+	}
+
+	switch s.Network {
+	case "tcp4", "tcp6":
+		s.LocalAddr = &net.TCPAddr{IP: ip, Port: port}
+	case "udp4", "udp6":
+		conn, err := net.ListenUDP(s.Network, &net.UDPAddr{IP: ip, Port: port})
+		if err != nil {
+			return socketErrno(err)
+		}
+		fsc.SetConn(fd, conn)
+	}
+	return wasi_snapshot_preview1.ErrnoSuccess
+}
+
+// listen is the function named listenName which starts listening for TCP
+// connections, which the guest accepts with "sock_accept".
+//
+// # Parameters
+//
+//   - fd: file descriptor of a TCP socket created by this module
+//   - backlog: ignored, as the host decides the backlog size
+//
+// Result (Errno)
+//
+// The return value is ErrnoSuccess except the following error conditions:
+//   - ErrnoBadf: `fd` is invalid
+//   - ErrnoNotsock: `fd` is not a socket
+//   - ErrnoInval: the socket is already listening or connected, or is a
+//     bound UDP socket
+//   - ErrnoNotsup: the socket is a UDP socket
+//   - ErrnoAddrinuse: the bound address is already in use
+//
+// Note: This is similar to `listen` in POSIX. If the socket wasn't bound,
+// it is bound to any available port of the unspecified address.
+var listen = newHostFunc(
+	listenName, listenFn,
+	[]api.ValueType{i32, i32},
+	"fd", "backlog",
+)
+
+func listenFn(_ context.Context, mod api.Module, params []uint64) Errno {
+	fsc := mod.(*wasm.CallContext).Sys.FS()
+
+	fd := uint32(params[0])
+
+	s, err := fsc.Socket(fd)
+	if errors.Is(err, syscall.EISCONN) {
+		return wasi_snapshot_preview1.ErrnoInval
+	} else if err != nil {
+		return socketErrno(err)
+	}
+
+	var laddr *net.TCPAddr
+	switch s.Network {
+	case "tcp4", "tcp6":
+		if s.LocalAddr != nil {
+			laddr = s.LocalAddr.(*net.TCPAddr)
+		}
+	default:
+		return wasi_snapshot_preview1.ErrnoNotsup
+	}
+
+	l, err := net.ListenTCP(s.Network, laddr)
+	if err != nil {
+		return socketErrno(err)
+	}
+	fsc.SetListener(fd, l)
+	return wasi_snapshot_preview1.ErrnoSuccess
+}
+
+// connect returns the function named connectName which connects a socket to
+// a remote address, blocking until it is connected.
+//
+// # Parameters
+//
+//   - fd: file descriptor of a socket created by this module. A UDP socket
+//     may also be bound already.
+//   - address: offset in api.Memory of the remote ip-socket-address
+//
+// Result (Errno)
+//
+// The return value is ErrnoSuccess except the following error conditions:
+//   - ErrnoBadf: `fd` is invalid
+//   - ErrnoNotsock: `fd` is not a socket
+//   - ErrnoIsconn: the socket is already listening or connected
+//   - ErrnoAfnosupport: `address` is not of the socket's address family
+//   - ErrnoAcces: `address` isn't allowed by Builder.WithAllowedAddresses
+//   - ErrnoConnrefused: the remote address refused the connection
+//   - ErrnoFault: `address` points to an offset out of memory
+//   - ErrnoIo: the connection failed for another reason
+//
+// Note: This is similar to `connect` in POSIX.
+func (r *resolver) connect() *wasm.HostFunc {
+	return newHostFunc(
+		connectName, r.connectFn,
+		[]api.ValueType{i32, i32},
+		"fd", "address",
+	)
+}
+
+func (r *resolver) connectFn(ctx context.Context, mod api.Module, params []uint64) Errno {
+	fsc := mod.(*wasm.CallContext).Sys.FS()
+
+	fd := uint32(params[0])
+	address := uint32(params[1])
+
+	var network string
+	var laddr net.Addr
+	if s, err := fsc.Socket(fd); err == nil {
+		network, laddr = s.Network, s.LocalAddr
+	} else if conn, connErr := fsc.Conn(fd); connErr == nil && isUnconnectedUDP(conn) {
+		// Reconnect a bound UDP socket from the same local address.
+		network, laddr = conn.LocalAddr().Network(), conn.LocalAddr()
+		if conn.LocalAddr().(*net.UDPAddr).IP.To4() != nil {
+			network += "4"
+		} else {
+			network += "6"
+		}
+	} else {
+		return socketErrno(err)
+	}
+
+	ip, port, errno := readSocketAddress(mod.Memory(), address, network)
+	if errno != wasi_snapshot_preview1.ErrnoSuccess {
+		return errno
+	} else if !r.isAllowedAddress(ctx, ip, port) {
+		return wasi_snapshot_preview1.ErrnoAcces
+	}
+
+	var conn net.Conn
+	var err error
+	switch network {
+	case "tcp4", "tcp6":
+		d := net.Dialer{}
+		if laddr != nil {
+			d.LocalAddr = laddr
+		}
+		conn, err = d.DialContext(ctx, network, (&net.TCPAddr{IP: ip, Port: port}).String())
+	case "udp4", "udp6":
+		if bound, connErr := fsc.Conn(fd); connErr == nil {
+			_ = bound.Close() // release the local address to connect from it.
+		}
+		var udpAddr *net.UDPAddr
+		if laddr != nil {
+			udpAddr = laddr.(*net.UDPAddr)
+		}
+		conn, err = net.DialUDP(network, udpAddr, &net.UDPAddr{IP: ip, Port: port})
+	}
+	if err != nil {
+		return socketErrno(err)
+	}
+	fsc.SetConn(fd, conn)
+	return wasi_snapshot_preview1.ErrnoSuccess
+}
+
+// isUnconnectedUDP returns true if the connection is a UDP socket bound, but
+// not connected, to an address.
+func isUnconnectedUDP(conn net.Conn) bool {
+	udp, ok := conn.(*net.UDPConn)
+	return ok && udp.RemoteAddr() == nil
+}
+
+// localAddress is the function named localAddressName which writes the
+// address a socket is bound to.
+//
+// # Parameters
+//
+//   - fd: file descriptor of a socket
+//   - resultAddress: offset to write the ip-socket-address
+//
+// Result (Errno)
+//
+// The return value is ErrnoSuccess except the following error conditions:
+//   - ErrnoBadf: `fd` is invalid
+//   - ErrnoNotsock: `fd` is not a socket
+//   - ErrnoInval: the socket isn't bound
+//   - ErrnoFault: `resultAddress` points to an offset out of memory
+//
+// Note: This is similar to `getsockname` in POSIX.
+var localAddress = newHostFunc(
+	localAddressName, localAddressFn,
+	[]api.ValueType{i32, i32},
+	"fd", "result.address",
+)
+
+func localAddressFn(_ context.Context, mod api.Module, params []uint64) Errno {
+	return socketAddress(mod, uint32(params[0]), uint32(params[1]), false)
+}
+
+// remoteAddress is the function named remoteAddressName which writes the
+// address a socket is connected to.
+//
+// # Parameters
+//
+//   - fd: file descriptor of a socket
+//   - resultAddress: offset to write the ip-socket-address
+//
+// Result (Errno)
+//
+// The return value is ErrnoSuccess except the following error conditions:
+//   - ErrnoBadf: `fd` is invalid
+//   - ErrnoNotsock: `fd` is not a socket
+//   - ErrnoNotconn: the socket isn't connected
+//   - ErrnoFault: `resultAddress` points to an offset out of memory
+//
+// Note: This is similar to `getpeername` in POSIX.
+var remoteAddress = newHostFunc(
+	remoteAddressName, remoteAddressFn,
+	[]api.ValueType{i32, i32},
+	"fd", "result.address",
+)
+
+func remoteAddressFn(_ context.Context, mod api.Module, params []uint64) Errno {
+	return socketAddress(mod, uint32(params[0]), uint32(params[1]), true)
+}
+
+func socketAddress(mod api.Module, fd, resultAddress uint32, remote bool) Errno {
+	fsc := mod.(*wasm.CallContext).Sys.FS()
+
+	buf, ok := mod.Memory().Read(resultAddress, socketAddressLen)
+	if !ok {
+		return wasi_snapshot_preview1.ErrnoFault
+	}
+
+	addr, err := fsc.SocketAddr(fd, remote)
+	if errors.Is(err, syscall.ENOTCONN) && !remote {
+		return wasi_snapshot_preview1.ErrnoInval
+	} else if err != nil {
+		return socketErrno(err)
+	}
+
+	switch addr := addr.(type) {
+	case *net.TCPAddr:
+		writeSocketAddress(buf, addr.IP, addr.Port)
+	case *net.UDPAddr:
+		writeSocketAddress(buf, addr.IP, addr.Port)
+	default: // e.g. a unix socket opened by the host.
+		return wasi_snapshot_preview1.ErrnoAfnosupport
+	}
+	return wasi_snapshot_preview1.ErrnoSuccess
+}
+
+// readSocketAddress reads the ip-socket-address at the offset, which must be
+// of the same address family as the network.
+func readSocketAddress(mem api.Memory, offset uint32, network string) (net.IP, int, Errno) {
+	buf, ok := mem.Read(offset, socketAddressLen)
+	if !ok {
+		return nil, 0, wasi_snapshot_preview1.ErrnoFault
+	}
+
+	port := int(le.Uint16(buf[2:]))
+	switch family, ipv6 := buf[0], network[len(network)-1] == '6'; {
+	case family == addressFamilyIPv4 && !ipv6:
+		return net.IP(append([]byte(nil), buf[4:8]...)), port, wasi_snapshot_preview1.ErrnoSuccess
+	case family == addressFamilyIPv6 && ipv6:
+		return net.IP(append([]byte(nil), buf[4:20]...)), port, wasi_snapshot_preview1.ErrnoSuccess
+	}
+	return nil, 0, wasi_snapshot_preview1.ErrnoAfnosupport
+}
+
+// writeSocketAddress writes the ip-socket-address to the buffer.
+func writeSocketAddress(buf []byte, ip net.IP, port int) {
+	// memory is re-used, so ensure the result is defaulted.
+	for i := 0; i < socketAddressLen; i++ {
+		buf[i] = 0
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		buf[0] = addressFamilyIPv4
+		copy(buf[4:], ip4)
+	} else {
+		buf[0] = addressFamilyIPv6
+		copy(buf[4:], ip.To16())
+	}
+	le.PutUint16(buf[2:], uint16(port))
+}
+
+// socketErrno converts an error from a socket operation.
+func socketErrno(err error) Errno {
+	switch {
+	case errors.Is(err, syscall.EBADF):
+		return wasi_snapshot_preview1.ErrnoBadf
+	case errors.Is(err, syscall.ENOTSOCK):
+		return wasi_snapshot_preview1.ErrnoNotsock
+	case errors.Is(err, syscall.EISCONN):
+		return wasi_snapshot_preview1.ErrnoIsconn
+	case errors.Is(err, syscall.ENOTCONN):
+		return wasi_snapshot_preview1.ErrnoNotconn
+	case errors.Is(err, syscall.EADDRINUSE):
+		return wasi_snapshot_preview1.ErrnoAddrinuse
+	case errors.Is(err, syscall.EADDRNOTAVAIL):
+		return wasi_snapshot_preview1.ErrnoAddrnotavail
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return wasi_snapshot_preview1.ErrnoConnrefused
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		return wasi_snapshot_preview1.ErrnoTimedout
+	}
+	return wasi_snapshot_preview1.ErrnoIo
+}
@@ -0,0 +1,470 @@
+package wasi_sockets
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	internalsys "github.com/tetratelabs/wazero/internal/sys"
+	"github.com/tetratelabs/wazero/internal/testing/proxy"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// testCtx is an arbitrary, non-default context. Non-nil also prevents linter errors.
+var testCtx = context.WithValue(context.Background(), struct{}{}, "arbitrary")
+
+// address is an arbitrary offset of an ip-socket-address used in tests.
+const address = uint32(64)
+
+func requireProxyModule(t *testing.T) (api.Module, api.Closer, *bytes.Buffer) {
+	return requireBuilderProxyModule(t, func(b Builder) Builder { return b })
+}
+
+// requireBuilderProxyModule is like requireProxyModule, except the module is
+// compiled from a Builder with the given configuration.
+func requireBuilderProxyModule(t *testing.T, configure func(Builder) Builder) (api.Module, api.Closer, *bytes.Buffer) {
+	var log bytes.Buffer
+
+	// Set context to one that has an experimental listener
+	ctx := context.WithValue(testCtx, experimental.FunctionListenerFactoryKey{}, proxy.NewLoggingListenerFactory(&log))
+
+	r := wazero.NewRuntime(ctx)
+
+	compiled, err := configure(NewBuilder(r)).Compile(ctx)
+	require.NoError(t, err)
+
+	_, err = r.InstantiateModule(ctx, compiled, wazero.NewModuleConfig())
+	require.NoError(t, err)
+
+	proxyCompiled, err := r.CompileModule(ctx, proxy.NewModuleBinary(ModuleName, compiled))
+	require.NoError(t, err)
+
+	mod, err := r.InstantiateModule(ctx, proxyCompiled, wazero.NewModuleConfig())
+	require.NoError(t, err)
+
+	return mod, r, &log
+}
+
+func requireErrno(t *testing.T, expectedErrno Errno, mod api.Module, funcName string, params ...uint64) {
+	results, err := mod.ExportedFunction(funcName).Call(testCtx, params...)
+	require.NoError(t, err)
+	errno := Errno(results[0])
+	require.Equal(t, expectedErrno, errno, wasi_snapshot_preview1.ErrnoName(errno))
+}
+
+// requireSocket creates a socket and returns its file descriptor.
+func requireSocket(t *testing.T, mod api.Module, funcName string, addressFamily uint32) uint32 {
+	resultFd := uint32(16) // arbitrary offset
+	requireErrno(t, wasi_snapshot_preview1.ErrnoSuccess, mod, funcName, uint64(addressFamily), uint64(resultFd))
+	fd, ok := mod.Memory().ReadUint32Le(resultFd)
+	require.True(t, ok)
+	return fd
+}
+
+// writeAddress writes the address to the memory at offset address.
+func writeAddress(t *testing.T, mod api.Module, addr net.Addr) {
+	buf, ok := mod.Memory().Read(address, socketAddressLen)
+	require.True(t, ok)
+	switch addr := addr.(type) {
+	case *net.TCPAddr:
+		writeSocketAddress(buf, addr.IP, addr.Port)
+	case *net.UDPAddr:
+		writeSocketAddress(buf, addr.IP, addr.Port)
+	}
+}
+
+// requireAddress reads the address written by funcName.
+func requireAddress(t *testing.T, mod api.Module, funcName string, fd uint32) (net.IP, int) {
+	requireErrno(t, wasi_snapshot_preview1.ErrnoSuccess, mod, funcName, uint64(fd), uint64(address))
+	buf, ok := mod.Memory().Read(address, socketAddressLen)
+	require.True(t, ok)
+	family := addressFamilyIPv4
+	if buf[0] == addressFamilyIPv6 {
+		family = addressFamilyIPv6
+	}
+	ip, port, errno := readSocketAddress(mod.Memory(), address, []string{"tcp4", "tcp6"}[family])
+	require.Equal(t, wasi_snapshot_preview1.ErrnoSuccess, errno)
+	return ip, port
+}
+
+func fsContext(mod api.Module) *internalsys.FSContext {
+	return mod.(*wasm.CallContext).Sys.FS()
+}
+
+func Test_resolveAddresses(t *testing.T) {
+	mod, r, log := requireProxyModule(t)
+	defer r.Close(testCtx)
+
+	name := []byte("127.0.0.1")
+	buf, bufLen, resultNresolved := uint32(128), uint32(2), uint32(256)
+	require.True(t, mod.Memory().Write(0, name))
+
+	requireErrno(t, wasi_snapshot_preview1.ErrnoSuccess, mod, resolveAddressesName,
+		0, uint64(len(name)), uint64(buf), uint64(bufLen), uint64(resultNresolved))
+	require.Equal(t, `
+==> wasi_sockets.resolve_addresses(name=0,name_len=9,buf=128,buf_len=2,result.nresolved=256)
+<== errno=0
+`, "\n"+log.String())
+
+	nresolved, ok := mod.Memory().ReadUint32Le(resultNresolved)
+	require.True(t, ok)
+	require.Equal(t, uint32(1), nresolved)
+
+	resolved, ok := mod.Memory().Read(buf, socketAddressLen)
+	require.True(t, ok)
+	require.Equal(t, []byte{
+		addressFamilyIPv4, 0, // family and padding
+		0, 0, // port
+		127, 0, 0, 1, // address
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, // unused by IPv4
+	}, resolved)
+}
+
+func Test_resolveAddresses_allowedHosts(t *testing.T) {
+	tests := []struct {
+		name          string
+		host          string
+		allowedHosts  []string
+		expectedErrno Errno
+	}{
+		{
+			name:          "none allowed",
+			host:          "localhost",
+			allowedHosts:  []string{},
+			expectedErrno: wasi_snapshot_preview1.ErrnoAcces,
+		},
+		{
+			name:          "not allowed",
+			host:          "localhost",
+			allowedHosts:  []string{"example.com"},
+			expectedErrno: wasi_snapshot_preview1.ErrnoAcces,
+		},
+		{
+			name:          "allowed",
+			host:          "localhost",
+			allowedHosts:  []string{"example.com", "LOCALHOST"},
+			expectedErrno: wasi_snapshot_preview1.ErrnoSuccess,
+		},
+		{
+			name:          "allowed any",
+			host:          "localhost",
+			allowedHosts:  []string{"*"},
+			expectedErrno: wasi_snapshot_preview1.ErrnoSuccess,
+		},
+		{
+			name:          "IP address",
+			host:          "127.0.0.1",
+			allowedHosts:  []string{},
+			expectedErrno: wasi_snapshot_preview1.ErrnoSuccess,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			mod, r, _ := requireBuilderProxyModule(t, func(b Builder) Builder {
+				return b.WithAllowedHosts(tc.allowedHosts...)
+			})
+			defer r.Close(testCtx)
+
+			name := []byte(tc.host)
+			require.True(t, mod.Memory().Write(0, name))
+			requireErrno(t, tc.expectedErrno, mod, resolveAddressesName,
+				0, uint64(len(name)), 128, 2, 256)
+		})
+	}
+}
+
+func Test_resolver_isAllowed(t *testing.T) {
+	r := &resolver{allowedHosts: []string{"api.example.com", "*.example.org"}}
+
+	require.True(t, r.isAllowed("api.example.com"))
+	require.True(t, r.isAllowed("API.example.com."))
+	require.True(t, r.isAllowed("www.example.org"))
+	require.True(t, r.isAllowed("a.b.example.org"))
+	require.False(t, r.isAllowed("example.org"))
+	require.False(t, r.isAllowed("example.com"))
+	require.False(t, r.isAllowed("api.example.com.evil"))
+	require.True(t, (&resolver{}).isAllowed("example.com"))
+}
+
+func TestBuilder_WithResolver(t *testing.T) {
+	var dialed bool
+	mod, r, _ := requireBuilderProxyModule(t, func(b Builder) Builder {
+		return b.WithResolver(&net.Resolver{
+			PreferGo: true,
+			Dial: func(context.Context, string, string) (net.Conn, error) {
+				dialed = true
+				return nil, errors.New("unreachable")
+			},
+		})
+	})
+	defer r.Close(testCtx)
+
+	name := []byte("wazero.invalid")
+	require.True(t, mod.Memory().Write(0, name))
+	results, err := mod.ExportedFunction(resolveAddressesName).Call(testCtx, 0, uint64(len(name)), 128, 2, 256)
+	require.NoError(t, err)
+	require.NotEqual(t, uint64(wasi_snapshot_preview1.ErrnoSuccess), results[0])
+	require.True(t, dialed)
+}
+
+func Test_tcpServer(t *testing.T) {
+	mod, r, _ := requireProxyModule(t)
+	defer r.Close(testCtx)
+
+	fd := requireSocket(t, mod, tcpCreateSocketName, addressFamilyIPv4)
+	require.Equal(t, uint32(3), fd) // after stdio as there's no file system
+
+	writeAddress(t, mod, &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	requireErrno(t, wasi_snapshot_preview1.ErrnoSuccess, mod, bindName, uint64(fd), uint64(address))
+	requireErrno(t, wasi_snapshot_preview1.ErrnoSuccess, mod, listenName, uint64(fd), 128)
+
+	ip, port := requireAddress(t, mod, localAddressName, fd)
+	require.Equal(t, "127.0.0.1", ip.String())
+	require.NotEqual(t, 0, port)
+
+	// The guest would accept this with "sock_accept".
+	conn, err := net.Dial("tcp", (&net.TCPAddr{IP: ip, Port: port}).String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	l, err := fsContext(mod).Listener(fd)
+	require.NoError(t, err)
+	accepted, err := l.Accept()
+	require.NoError(t, err)
+	require.NoError(t, accepted.Close())
+}
+
+func Test_tcpClient(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	mod, r, _ := requireProxyModule(t)
+	defer r.Close(testCtx)
+
+	fd := requireSocket(t, mod, tcpCreateSocketName, addressFamilyIPv4)
+	writeAddress(t, mod, l.Addr())
+	requireErrno(t, wasi_snapshot_preview1.ErrnoSuccess, mod, connectName, uint64(fd), uint64(address))
+
+	ip, port := requireAddress(t, mod, remoteAddressName, fd)
+	require.Equal(t, l.Addr().String(), (&net.TCPAddr{IP: ip, Port: port}).String())
+
+	// The guest would write this with "sock_send".
+	conn, err := fsContext(mod).Conn(fd)
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("wazero"))
+	require.NoError(t, err)
+
+	accepted, err := l.Accept()
+	require.NoError(t, err)
+	defer accepted.Close()
+	buf := make([]byte, 6)
+	_, err = accepted.Read(buf)
+	require.NoError(t, err)
+	require.Equal(t, "wazero", string(buf))
+}
+
+func Test_connect_allowedAddresses(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	port := strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+
+	tests := []struct {
+		name             string
+		allowedAddresses []string
+		expectedErrno    Errno
+	}{
+		{
+			name:             "none allowed",
+			allowedAddresses: []string{},
+			expectedErrno:    wasi_snapshot_preview1.ErrnoAcces,
+		},
+		{
+			name:             "other port",
+			allowedAddresses: []string{"127.0.0.1:1"},
+			expectedErrno:    wasi_snapshot_preview1.ErrnoAcces,
+		},
+		{
+			name:             "IP address",
+			allowedAddresses: []string{"127.0.0.1:" + port},
+			expectedErrno:    wasi_snapshot_preview1.ErrnoSuccess,
+		},
+		{
+			name:             "host name",
+			allowedAddresses: []string{"wazero.invalid:" + port, "localhost:" + port},
+			expectedErrno:    wasi_snapshot_preview1.ErrnoSuccess,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			mod, r, _ := requireBuilderProxyModule(t, func(b Builder) Builder {
+				return b.WithAllowedAddresses(tc.allowedAddresses...)
+			})
+			defer r.Close(testCtx)
+
+			fd := requireSocket(t, mod, tcpCreateSocketName, addressFamilyIPv4)
+			writeAddress(t, mod, l.Addr())
+			requireErrno(t, tc.expectedErrno, mod, connectName, uint64(fd), uint64(address))
+		})
+	}
+}
+
+func Test_udp(t *testing.T) {
+	peer, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer peer.Close()
+
+	mod, r, _ := requireProxyModule(t)
+	defer r.Close(testCtx)
+
+	fd := requireSocket(t, mod, udpCreateSocketName, addressFamilyIPv4)
+	writeAddress(t, mod, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	requireErrno(t, wasi_snapshot_preview1.ErrnoSuccess, mod, bindName, uint64(fd), uint64(address))
+
+	ip, port := requireAddress(t, mod, localAddressName, fd)
+	local := &net.UDPAddr{IP: ip, Port: port}
+
+	// The guest would receive this with "sock_recv".
+	_, err = peer.WriteTo([]byte("wazero"), local)
+	require.NoError(t, err)
+	conn, err := fsContext(mod).Conn(fd)
+	require.NoError(t, err)
+	buf := make([]byte, 6)
+	_, err = conn.Read(buf)
+	require.NoError(t, err)
+	require.Equal(t, "wazero", string(buf))
+
+	// Connecting keeps the local address, and allows sending.
+	writeAddress(t, mod, peer.LocalAddr())
+	requireErrno(t, wasi_snapshot_preview1.ErrnoSuccess, mod, connectName, uint64(fd), uint64(address))
+
+	ip, port = requireAddress(t, mod, localAddressName, fd)
+	require.Equal(t, local.String(), (&net.UDPAddr{IP: ip, Port: port}).String())
+	ip, port = requireAddress(t, mod, remoteAddressName, fd)
+	require.Equal(t, peer.LocalAddr().String(), (&net.UDPAddr{IP: ip, Port: port}).String())
+}
+
+func Test_Errors(t *testing.T) {
+	mod, r, _ := requireProxyModule(t)
+	defer r.Close(testCtx)
+
+	listening := requireSocket(t, mod, tcpCreateSocketName, addressFamilyIPv4)
+	requireErrno(t, wasi_snapshot_preview1.ErrnoSuccess, mod, listenName, uint64(listening), 0)
+
+	ipv6 := requireSocket(t, mod, tcpCreateSocketName, addressFamilyIPv6)
+	udp := requireSocket(t, mod, udpCreateSocketName, addressFamilyIPv4)
+
+	writeAddress(t, mod, &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+
+	tests := []struct {
+		name          string
+		funcName      string
+		params        []uint64
+		expectedErrno Errno
+	}{
+		{
+			name:          "resolve_addresses empty name",
+			funcName:      resolveAddressesName,
+			params:        []uint64{0, 0, 0, 0, 0},
+			expectedErrno: wasi_snapshot_preview1.ErrnoInval,
+		},
+		{
+			name:          "resolve_addresses name out of range",
+			funcName:      resolveAddressesName,
+			params:        []uint64{uint64(wasm.MemoryPageSize), 1, 0, 0, 0},
+			expectedErrno: wasi_snapshot_preview1.ErrnoFault,
+		},
+		{
+			name:          "tcp_create_socket invalid family",
+			funcName:      tcpCreateSocketName,
+			params:        []uint64{2, 0},
+			expectedErrno: wasi_snapshot_preview1.ErrnoAfnosupport,
+		},
+		{
+			name:          "tcp_create_socket result out of range",
+			funcName:      tcpCreateSocketName,
+			params:        []uint64{addressFamilyIPv4, uint64(wasm.MemoryPageSize)},
+			expectedErrno: wasi_snapshot_preview1.ErrnoFault,
+		},
+		{
+			name:          "bind invalid fd",
+			funcName:      bindName,
+			params:        []uint64{42, uint64(address)},
+			expectedErrno: wasi_snapshot_preview1.ErrnoBadf,
+		},
+		{
+			name:          "bind not a socket",
+			funcName:      bindName,
+			params:        []uint64{uint64(internalsys.FdStdin), uint64(address)},
+			expectedErrno: wasi_snapshot_preview1.ErrnoNotsock,
+		},
+		{
+			name:          "bind listening",
+			funcName:      bindName,
+			params:        []uint64{uint64(listening), uint64(address)},
+			expectedErrno: wasi_snapshot_preview1.ErrnoInval,
+		},
+		{
+			name:          "bind wrong family",
+			funcName:      bindName,
+			params:        []uint64{uint64(ipv6), uint64(address)},
+			expectedErrno: wasi_snapshot_preview1.ErrnoAfnosupport,
+		},
+		{
+			name:          "bind address out of range",
+			funcName:      bindName,
+			params:        []uint64{uint64(ipv6), uint64(wasm.MemoryPageSize)},
+			expectedErrno: wasi_snapshot_preview1.ErrnoFault,
+		},
+		{
+			name:          "listen udp",
+			funcName:      listenName,
+			params:        []uint64{uint64(udp), 0},
+			expectedErrno: wasi_snapshot_preview1.ErrnoNotsup,
+		},
+		{
+			name:          "connect listening",
+			funcName:      connectName,
+			params:        []uint64{uint64(listening), uint64(address)},
+			expectedErrno: wasi_snapshot_preview1.ErrnoIsconn,
+		},
+		{
+			name:          "local_address not bound",
+			funcName:      localAddressName,
+			params:        []uint64{uint64(ipv6), uint64(address)},
+			expectedErrno: wasi_snapshot_preview1.ErrnoInval,
+		},
+		{
+			name:          "remote_address listening",
+			funcName:      remoteAddressName,
+			params:        []uint64{uint64(listening), uint64(address)},
+			expectedErrno: wasi_snapshot_preview1.ErrnoNotconn,
+		},
+		{
+			name:          "remote_address not a socket",
+			funcName:      remoteAddressName,
+			params:        []uint64{uint64(internalsys.FdStdout), uint64(address)},
+			expectedErrno: wasi_snapshot_preview1.ErrnoNotsock,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			requireErrno(t, tc.expectedErrno, mod, tc.funcName, tc.params...)
+		})
+	}
+}
@@ -0,0 +1,239 @@
+// Package wasi_sockets contains Go-defined functions which allow a guest to
+// resolve host names and create TCP or UDP sockets, as proposed by
+// wasi-sockets. These are accessible from WebAssembly-defined functions via
+// importing ModuleName.
+//
+// This module is optional, as it grants the guest access to the host network.
+// Instantiate it in addition to wasi_snapshot_preview1, which is used for
+// everything else, such as "sock_accept", "sock_recv", "sock_send",
+// "fd_read", "fd_write" and "fd_close" on the file descriptors created here.
+//
+//	wasi_snapshot_preview1.MustInstantiate(ctx, r)
+//	wasi_sockets.MustInstantiate(ctx, r)
+//	mod, _ := r.InstantiateModuleFromBinary(ctx, wasm)
+//
+// Use NewBuilder to control how host names are resolved, and which addresses
+// the guest can connect to, e.g. to only allow some of them:
+//
+//	wasi_sockets.NewBuilder(r).
+//		WithAllowedHosts("api.example.com", "*.example.org").
+//		WithAllowedAddresses("api.example.com:443").
+//		Instantiate(ctx, r)
+//
+// # ABI
+//
+// wasi-sockets is defined in WIT for the component model, which wazero doesn't
+// implement. Instead, this module defines the same operations with
+// wasi_snapshot_preview1 conventions: each function returns a
+// wasi_snapshot_preview1.Errno and writes results to memory offsets passed as
+// parameters prefixed "result.".
+//
+// An ip-address-family is an i32 where 0 is IPv4 and 1 is IPv6.
+//
+// An ip-socket-address is 20 bytes in memory:
+//   - family: 1 byte ip-address-family
+//   - 1 padding byte
+//   - port: 2 bytes little-endian
+//   - address: 16 bytes, where IPv4 uses only the first 4, in network order.
+//
+// See https://github.com/WebAssembly/wasi-sockets
+package wasi_sockets
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// ModuleName is the module name the functions are exported into.
+const (
+	ModuleName = "wasi_sockets"
+	i32        = wasm.ValueTypeI32
+)
+
+var le = binary.LittleEndian
+
+// MustInstantiate calls Instantiate or panics on error.
+//
+// This is a simpler function for those who know the module ModuleName is not
+// already instantiated, and don't need to unload it.
+func MustInstantiate(ctx context.Context, r wazero.Runtime) {
+	if _, err := Instantiate(ctx, r); err != nil {
+		panic(err)
+	}
+}
+
+// Instantiate instantiates the ModuleName module into the runtime default
+// namespace.
+//
+// # Notes
+//
+//   - Failure cases are documented on wazero.Namespace InstantiateModule.
+//   - Closing the wazero.Runtime has the same effect as closing the result.
+//   - Any host name is resolved, so use NewBuilder to restrict them.
+//   - To instantiate into another wazero.Namespace, use FunctionExporter.
+func Instantiate(ctx context.Context, r wazero.Runtime) (api.Closer, error) {
+	return NewBuilder(r).Instantiate(ctx, r)
+}
+
+// Builder configures the ModuleName module for later use via Compile or
+// Instantiate. Each instantiation has its own resolver and allowed hosts, so
+// guests in different namespaces can have different policies.
+type Builder interface {
+	// WithResolver sets the resolver which looks up host names for
+	// "resolve_addresses". Defaults to net.DefaultResolver.
+	WithResolver(*net.Resolver) Builder
+
+	// WithAllowedHosts only allows "resolve_addresses" to look up the given
+	// host names, failing others with ErrnoAcces. Defaults to any, as the
+	// guest can connect to any IP address anyway, unless restricted with
+	// WithAllowedAddresses.
+	//
+	// Each entry matches the host name, ignoring case and a trailing dot:
+	//   - "example.com" matches only the host name itself.
+	//   - "*.example.com" matches subdomains of "example.com", but not itself.
+	//   - "*" matches any host name.
+	//
+	// Note: An IP address, e.g. "127.0.0.1", is always resolved to itself.
+	WithAllowedHosts(hosts ...string) Builder
+
+	// WithAllowedAddresses only allows "connect" to the given addresses in the
+	// form host:port, failing others with ErrnoAcces. Defaults to any.
+	//
+	// A host name is resolved with the resolver each time the guest connects,
+	// so the guest can connect to any of its current IP addresses. For
+	// example, "localhost:8080" allows "127.0.0.1:8080" and "[::1]:8080".
+	WithAllowedAddresses(addresses ...string) Builder
+
+	// Compile compiles the ModuleName module that can instantiated in any
+	// namespace (wazero.Namespace).
+	//
+	// Note: This has the same effect as the same function on wazero.HostModuleBuilder.
+	Compile(context.Context) (wazero.CompiledModule, error)
+
+	// Instantiate instantiates the ModuleName module into the given namespace.
+	//
+	// Note: This has the same effect as the same function on wazero.HostModuleBuilder.
+	Instantiate(context.Context, wazero.Namespace) (api.Closer, error)
+}
+
+// NewBuilder returns a new Builder.
+func NewBuilder(r wazero.Runtime) Builder {
+	return &builder{r: r, resolver: net.DefaultResolver}
+}
+
+type builder struct {
+	r                wazero.Runtime
+	resolver         *net.Resolver
+	allowedHosts     []string
+	allowedAddresses []string
+}
+
+// WithResolver implements Builder.WithResolver
+func (b *builder) WithResolver(resolver *net.Resolver) Builder {
+	b.resolver = resolver
+	return b
+}
+
+// WithAllowedHosts implements Builder.WithAllowedHosts
+func (b *builder) WithAllowedHosts(hosts ...string) Builder {
+	b.allowedHosts = append(b.allowedHosts, hosts...)
+	if b.allowedHosts == nil {
+		b.allowedHosts = []string{} // allow none
+	}
+	return b
+}
+
+// WithAllowedAddresses implements Builder.WithAllowedAddresses
+func (b *builder) WithAllowedAddresses(addresses ...string) Builder {
+	b.allowedAddresses = append(b.allowedAddresses, addresses...)
+	if b.allowedAddresses == nil {
+		b.allowedAddresses = []string{} // allow none
+	}
+	return b
+}
+
+// hostModuleBuilder returns a new wazero.HostModuleBuilder for ModuleName
+func (b *builder) hostModuleBuilder() wazero.HostModuleBuilder {
+	ret := b.r.NewHostModuleBuilder(ModuleName)
+	exportFunctions(ret, &resolver{
+		resolver:         b.resolver,
+		allowedHosts:     b.allowedHosts,
+		allowedAddresses: b.allowedAddresses,
+	})
+	return ret
+}
+
+// Compile implements Builder.Compile
+func (b *builder) Compile(ctx context.Context) (wazero.CompiledModule, error) {
+	return b.hostModuleBuilder().Compile(ctx)
+}
+
+// Instantiate implements Builder.Instantiate
+func (b *builder) Instantiate(ctx context.Context, ns wazero.Namespace) (api.Closer, error) {
+	return b.hostModuleBuilder().Instantiate(ctx, ns)
+}
+
+// FunctionExporter exports functions into a wazero.HostModuleBuilder named
+// ModuleName.
+type FunctionExporter interface {
+	// ExportFunctions builds functions to export with a
+	// wazero.HostModuleBuilder named ModuleName.
+	ExportFunctions(wazero.HostModuleBuilder)
+}
+
+// NewFunctionExporter returns a FunctionExporter of all functions in this
+// package, which resolve any host name with net.DefaultResolver.
+func NewFunctionExporter() FunctionExporter {
+	return &functionExporter{}
+}
+
+type functionExporter struct{}
+
+// ExportFunctions implements FunctionExporter.ExportFunctions
+func (functionExporter) ExportFunctions(builder wazero.HostModuleBuilder) {
+	exportFunctions(builder, &resolver{resolver: net.DefaultResolver})
+}
+
+func exportFunctions(builder wazero.HostModuleBuilder, r *resolver) {
+	exporter := builder.(wasm.HostFuncExporter)
+	exporter.ExportHostFunc(r.resolveAddresses())
+	exporter.ExportHostFunc(tcpCreateSocket)
+	exporter.ExportHostFunc(udpCreateSocket)
+	exporter.ExportHostFunc(bind)
+	exporter.ExportHostFunc(listen)
+	exporter.ExportHostFunc(r.connect())
+	exporter.ExportHostFunc(localAddress)
+	exporter.ExportHostFunc(remoteAddress)
+}
+
+func newHostFunc(
+	name string,
+	goFunc sockFunc,
+	paramTypes []api.ValueType,
+	paramNames ...string,
+) *wasm.HostFunc {
+	return &wasm.HostFunc{
+		ExportNames: []string{name},
+		Name:        name,
+		ParamTypes:  paramTypes,
+		ParamNames:  paramNames,
+		ResultTypes: []api.ValueType{i32},
+		ResultNames: []string{"errno"},
+		Code:        &wasm.Code{IsHostFunction: true, GoFunc: goFunc},
+	}
+}
+
+// sockFunc special cases that all functions return a single Errno result.
+// The returned value will be written back to the stack at index zero.
+type sockFunc func(ctx context.Context, mod api.Module, params []uint64) Errno
+
+// Call implements the same method as documented on api.GoModuleFunction.
+func (f sockFunc) Call(ctx context.Context, mod api.Module, stack []uint64) {
+	// Write the result back onto the stack
+	stack[0] = uint64(f(ctx, mod, stack))
+}
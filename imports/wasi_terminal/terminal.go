@@ -0,0 +1,75 @@
+package wasi_terminal
+
+import (
+	"context"
+	"syscall"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// Errno is the same as wasi_snapshot_preview1.Errno, as guests use both
+// modules together.
+type Errno = wasi_snapshot_preview1.Errno
+
+const terminalSizeName = "terminal_size"
+
+// terminalSize is the function named terminalSizeName which returns the size
+// of the terminal of a file descriptor.
+//
+// # Parameters
+//
+//   - fd: file descriptor of the terminal, e.g. 1 for stdout
+//   - resultRows: offset to write the count of rows as a uint32le
+//   - resultColumns: offset to write the count of columns as a uint32le
+//
+// Result (Errno)
+//
+// The return value is ErrnoSuccess except the following error conditions:
+//   - ErrnoBadf: `fd` is invalid
+//   - ErrnoNotty: `fd` isn't a host terminal, e.g. stdout is an io.Writer
+//   - ErrnoFault: a result offset is out of memory
+//
+// Note: This is similar to `ioctl(fd, TIOCGWINSZ, ...)` in POSIX. Either
+// count can be zero, e.g. on a serial console.
+var terminalSize = newHostFunc(
+	terminalSizeName, terminalSizeFn,
+	[]api.ValueType{i32, i32, i32},
+	"fd", "result.rows", "result.columns",
+)
+
+func terminalSizeFn(_ context.Context, mod api.Module, params []uint64) Errno {
+	fsc := mod.(*wasm.CallContext).Sys.FS()
+
+	fd := uint32(params[0])
+	resultRows := uint32(params[1])
+	resultColumns := uint32(params[2])
+
+	rows, columns, err := fsc.TerminalSize(fd)
+	if err != nil {
+		return terminalErrno(err)
+	}
+
+	mem := mod.Memory()
+	if !mem.WriteUint32Le(resultRows, uint32(rows)) {
+		return wasi_snapshot_preview1.ErrnoFault
+	}
+	if !mem.WriteUint32Le(resultColumns, uint32(columns)) {
+		return wasi_snapshot_preview1.ErrnoFault
+	}
+	return wasi_snapshot_preview1.ErrnoSuccess
+}
+
+// terminalErrno coerces an error from sys.FSContext TerminalSize to an Errno.
+func terminalErrno(err error) Errno {
+	switch platform.UnwrapOSError(err) {
+	case syscall.EBADF:
+		return wasi_snapshot_preview1.ErrnoBadf
+	case syscall.ENOTTY:
+		return wasi_snapshot_preview1.ErrnoNotty
+	default:
+		return wasi_snapshot_preview1.ErrnoIo
+	}
+}
@@ -0,0 +1,144 @@
+package wasi_terminal
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/internal/platform"
+	internalsys "github.com/tetratelabs/wazero/internal/sys"
+	"github.com/tetratelabs/wazero/internal/testing/proxy"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+// testCtx is an arbitrary, non-default context. Non-nil also prevents linter errors.
+var testCtx = context.WithValue(context.Background(), struct{}{}, "arbitrary")
+
+func requireProxyModule(t *testing.T, config wazero.ModuleConfig) (api.Module, api.Closer, *bytes.Buffer) {
+	var log bytes.Buffer
+
+	// Set context to one that has an experimental listener
+	ctx := context.WithValue(testCtx, experimental.FunctionListenerFactoryKey{}, proxy.NewLoggingListenerFactory(&log))
+
+	r := wazero.NewRuntime(ctx)
+
+	builder := r.NewHostModuleBuilder(ModuleName)
+	NewFunctionExporter().ExportFunctions(builder)
+	compiled, err := builder.Compile(ctx)
+	require.NoError(t, err)
+
+	_, err = r.InstantiateModule(ctx, compiled, wazero.NewModuleConfig())
+	require.NoError(t, err)
+
+	proxyCompiled, err := r.CompileModule(ctx, proxy.NewModuleBinary(ModuleName, compiled))
+	require.NoError(t, err)
+
+	mod, err := r.InstantiateModule(ctx, proxyCompiled, config)
+	require.NoError(t, err)
+
+	return mod, r, &log
+}
+
+func requireErrno(t *testing.T, expectedErrno Errno, mod api.Module, funcName string, params ...uint64) {
+	results, err := mod.ExportedFunction(funcName).Call(testCtx, params...)
+	require.NoError(t, err)
+	errno := Errno(results[0])
+	require.Equal(t, expectedErrno, errno, wasi_snapshot_preview1.ErrnoName(errno))
+}
+
+func Test_terminalSize(t *testing.T) {
+	// We aren't guaranteed to have a terminal device for os.Stdout, due to how
+	// `go test` forks processes. Instead, we test if this is consistent.
+	mod, r, log := requireProxyModule(t, wazero.NewModuleConfig().WithStdout(os.Stdout))
+	defer r.Close(testCtx)
+
+	resultRows, resultColumns := uint32(16), uint32(20) // arbitrary offsets
+	if !platform.IsTerminal(os.Stdout.Fd()) {
+		requireErrno(t, wasi_snapshot_preview1.ErrnoNotty, mod, terminalSizeName,
+			uint64(internalsys.FdStdout), uint64(resultRows), uint64(resultColumns))
+		return
+	}
+
+	requireErrno(t, wasi_snapshot_preview1.ErrnoSuccess, mod, terminalSizeName,
+		uint64(internalsys.FdStdout), uint64(resultRows), uint64(resultColumns))
+	rows, ok := mod.Memory().ReadUint32Le(resultRows)
+	require.True(t, ok)
+	columns, ok := mod.Memory().ReadUint32Le(resultColumns)
+	require.True(t, ok)
+	expectedRows, expectedColumns, err := platform.TerminalSize(os.Stdout.Fd())
+	require.NoError(t, err)
+	require.Equal(t, uint32(expectedRows), rows)
+	require.Equal(t, uint32(expectedColumns), columns)
+	require.Equal(t, `
+==> wasi_terminal.terminal_size(fd=1,result.rows=16,result.columns=20)
+<== errno=0
+`, "\n"+log.String())
+}
+
+func Test_terminalSize_Errors(t *testing.T) {
+	f, err := os.Create(path.Join(t.TempDir(), "stdout"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	mod, r, log := requireProxyModule(t, wazero.NewModuleConfig().WithStdout(f))
+	defer r.Close(testCtx)
+
+	validAddress := uint64(0) // arbitrary valid address
+
+	tests := []struct {
+		name                       string
+		fd, resultRows, resultCols uint64
+		expectedErrno              Errno
+		expectedLog                string
+	}{
+		{
+			name:          "invalid fd",
+			fd:            42,
+			resultRows:    validAddress,
+			resultCols:    validAddress,
+			expectedErrno: wasi_snapshot_preview1.ErrnoBadf,
+			expectedLog: `
+==> wasi_terminal.terminal_size(fd=42,result.rows=0,result.columns=0)
+<== errno=8
+`,
+		},
+		{
+			name:          "stdout is a regular file",
+			fd:            uint64(internalsys.FdStdout),
+			resultRows:    validAddress,
+			resultCols:    validAddress,
+			expectedErrno: wasi_snapshot_preview1.ErrnoNotty,
+			expectedLog: `
+==> wasi_terminal.terminal_size(fd=1,result.rows=0,result.columns=0)
+<== errno=59
+`,
+		},
+		{
+			name:          "stderr is an io.Writer",
+			fd:            uint64(internalsys.FdStderr),
+			resultRows:    validAddress,
+			resultCols:    validAddress,
+			expectedErrno: wasi_snapshot_preview1.ErrnoNotty,
+			expectedLog: `
+==> wasi_terminal.terminal_size(fd=2,result.rows=0,result.columns=0)
+<== errno=59
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			defer log.Reset()
+
+			requireErrno(t, tc.expectedErrno, mod, terminalSizeName, tc.fd, tc.resultRows, tc.resultCols)
+			require.Equal(t, tc.expectedLog, "\n"+log.String())
+		})
+	}
+}
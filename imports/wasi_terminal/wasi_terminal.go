@@ -0,0 +1,108 @@
+// Package wasi_terminal contains Go-defined functions which allow a guest to
+// query the host terminal, such as its size, which CLI guests like shells and
+// editors need to render correctly. These are accessible from
+// WebAssembly-defined functions via importing ModuleName.
+//
+// This module is optional, and there is no corresponding WASI proposal.
+// Instantiate it in addition to wasi_snapshot_preview1, which is used for
+// everything else, such as "fd_read" and "fd_write" on the terminal. Whether
+// a file descriptor is a terminal, as needed by `isatty`, is already known
+// via "fd_fdstat_get" in wasi_snapshot_preview1.
+//
+//	wasi_snapshot_preview1.MustInstantiate(ctx, r)
+//	wasi_terminal.MustInstantiate(ctx, r)
+//	mod, _ := r.InstantiateModuleFromBinary(ctx, wasm)
+//
+// # ABI
+//
+// Functions in this module follow wasi_snapshot_preview1 conventions: each
+// returns a wasi_snapshot_preview1.Errno and writes results to memory offsets
+// passed as parameters prefixed "result.".
+package wasi_terminal
+
+import (
+	"context"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// ModuleName is the module name the functions are exported into.
+const (
+	ModuleName = "wasi_terminal"
+	i32        = wasm.ValueTypeI32
+)
+
+// MustInstantiate calls Instantiate or panics on error.
+//
+// This is a simpler function for those who know the module ModuleName is not
+// already instantiated, and don't need to unload it.
+func MustInstantiate(ctx context.Context, r wazero.Runtime) {
+	if _, err := Instantiate(ctx, r); err != nil {
+		panic(err)
+	}
+}
+
+// Instantiate instantiates the ModuleName module into the runtime default
+// namespace.
+//
+// # Notes
+//
+//   - Failure cases are documented on wazero.Namespace InstantiateModule.
+//   - Closing the wazero.Runtime has the same effect as closing the result.
+//   - To instantiate into another wazero.Namespace, use FunctionExporter.
+func Instantiate(ctx context.Context, r wazero.Runtime) (api.Closer, error) {
+	builder := r.NewHostModuleBuilder(ModuleName)
+	NewFunctionExporter().ExportFunctions(builder)
+	return builder.Instantiate(ctx, r)
+}
+
+// FunctionExporter exports functions into a wazero.HostModuleBuilder named
+// ModuleName.
+type FunctionExporter interface {
+	// ExportFunctions builds functions to export with a
+	// wazero.HostModuleBuilder named ModuleName.
+	ExportFunctions(wazero.HostModuleBuilder)
+}
+
+// NewFunctionExporter returns a FunctionExporter of all functions in this
+// package.
+func NewFunctionExporter() FunctionExporter {
+	return &functionExporter{}
+}
+
+type functionExporter struct{}
+
+// ExportFunctions implements FunctionExporter.ExportFunctions
+func (functionExporter) ExportFunctions(builder wazero.HostModuleBuilder) {
+	exporter := builder.(wasm.HostFuncExporter)
+	exporter.ExportHostFunc(terminalSize)
+}
+
+func newHostFunc(
+	name string,
+	goFunc terminalFunc,
+	paramTypes []api.ValueType,
+	paramNames ...string,
+) *wasm.HostFunc {
+	return &wasm.HostFunc{
+		ExportNames: []string{name},
+		Name:        name,
+		ParamTypes:  paramTypes,
+		ParamNames:  paramNames,
+		ResultTypes: []api.ValueType{i32},
+		ResultNames: []string{"errno"},
+		Code:        &wasm.Code{IsHostFunction: true, GoFunc: goFunc},
+	}
+}
+
+// terminalFunc special cases that all functions return a single Errno result.
+// The returned value will be written back to the stack at index zero.
+type terminalFunc func(ctx context.Context, mod api.Module, params []uint64) Errno
+
+// Call implements the same method as documented on api.GoModuleFunction.
+func (f terminalFunc) Call(ctx context.Context, mod api.Module, stack []uint64) {
+	// Write the result back onto the stack
+	stack[0] = uint64(f(ctx, mod, stack))
+}
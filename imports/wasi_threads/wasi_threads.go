@@ -0,0 +1,116 @@
+// Package wasi_threads contains a stub of the "thread-spawn" function from the
+// wasi-threads proposal, accessible from WebAssembly-defined functions via
+// importing ModuleName.
+//
+// wazero doesn't implement the threads proposal, i.e. shared memory and atomic
+// instructions, which threads need to safely access the same memory. So, it
+// runs each guest on one goroutine.
+// However, guests compiled with thread support, e.g. `--target=wasm32-wasi-threads`,
+// import "thread-spawn" even if they never create a thread. Without this
+// module, they fail to instantiate. With it, they run single-threaded, and
+// each attempt to create a thread fails predictably: `pthread_create` returns
+// EAGAIN, as if the host ran out of threads.
+//
+//	wasi_snapshot_preview1.NewBuilder(r).WithSchedYield().Instantiate(ctx, r)
+//	wasi_threads.MustInstantiate(ctx, r)
+//	mod, _ := r.InstantiateModuleFromBinary(ctx, wasm)
+//
+// Note: Use wasi_snapshot_preview1.Builder WithSchedYield for such guests, as
+// they may spin on "sched_yield" while waiting.
+//
+// See https://github.com/WebAssembly/wasi-threads
+package wasi_threads
+
+import (
+	"context"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/internal/leb128"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// ModuleName is the module name the functions are exported into.
+//
+// Note: This is "wasi", not "wasi_threads", per the wasi-threads proposal.
+const (
+	ModuleName = "wasi"
+	i32        = wasm.ValueTypeI32
+)
+
+// MustInstantiate calls Instantiate or panics on error.
+//
+// This is a simpler function for those who know the module ModuleName is not
+// already instantiated, and don't need to unload it.
+func MustInstantiate(ctx context.Context, r wazero.Runtime) {
+	if _, err := Instantiate(ctx, r); err != nil {
+		panic(err)
+	}
+}
+
+// Instantiate instantiates the ModuleName module into the runtime default
+// namespace.
+//
+// # Notes
+//
+//   - Failure cases are documented on wazero.Namespace InstantiateModule.
+//   - Closing the wazero.Runtime has the same effect as closing the result.
+//   - To instantiate into another wazero.Namespace, use FunctionExporter.
+func Instantiate(ctx context.Context, r wazero.Runtime) (api.Closer, error) {
+	builder := r.NewHostModuleBuilder(ModuleName)
+	NewFunctionExporter().ExportFunctions(builder)
+	return builder.Instantiate(ctx, r)
+}
+
+// FunctionExporter exports functions into a wazero.HostModuleBuilder named
+// ModuleName.
+type FunctionExporter interface {
+	// ExportFunctions builds functions to export with a
+	// wazero.HostModuleBuilder named ModuleName.
+	ExportFunctions(wazero.HostModuleBuilder)
+}
+
+// NewFunctionExporter returns a FunctionExporter of all functions in this
+// package.
+func NewFunctionExporter() FunctionExporter {
+	return &functionExporter{}
+}
+
+type functionExporter struct{}
+
+// ExportFunctions implements FunctionExporter.ExportFunctions
+func (functionExporter) ExportFunctions(builder wazero.HostModuleBuilder) {
+	exporter := builder.(wasm.HostFuncExporter)
+	exporter.ExportHostFunc(threadSpawn)
+}
+
+const threadSpawnName = "thread-spawn"
+
+// threadSpawn is the function named threadSpawnName, which starts a thread
+// that calls the exported function "wasi_thread_start" with startArg.
+//
+// # Parameters
+//
+//   - startArg: opaque value passed to "wasi_thread_start"
+//
+// # Result
+//
+// A positive thread ID on success, or a negative value on failure. This is
+// stubbed to always fail with the negated wasi_snapshot_preview1.ErrnoAgain.
+//
+// See https://github.com/WebAssembly/wasi-threads#design-choice-thread-ids
+var threadSpawn = &wasm.HostFunc{
+	ExportNames: []string{threadSpawnName},
+	Name:        threadSpawnName,
+	ParamTypes:  []api.ValueType{i32},
+	ParamNames:  []string{"start_arg"},
+	ResultTypes: []api.ValueType{i32},
+	ResultNames: []string{"tid"},
+	Code: &wasm.Code{
+		IsHostFunction: true,
+		Body: append(append([]byte{wasm.OpcodeI32Const},
+			leb128.EncodeInt32(-int32(wasi_snapshot_preview1.ErrnoAgain))...),
+			wasm.OpcodeEnd),
+	},
+}
@@ -0,0 +1,48 @@
+package wasi_threads
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/internal/testing/proxy"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+// testCtx is an arbitrary, non-default context. Non-nil also prevents linter errors.
+var testCtx = context.WithValue(context.Background(), struct{}{}, "arbitrary")
+
+func Test_threadSpawn(t *testing.T) {
+	var log bytes.Buffer
+
+	// Set context to one that has an experimental listener
+	ctx := context.WithValue(testCtx, experimental.FunctionListenerFactoryKey{}, proxy.NewLoggingListenerFactory(&log))
+
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+
+	builder := r.NewHostModuleBuilder(ModuleName)
+	NewFunctionExporter().ExportFunctions(builder)
+	compiled, err := builder.Compile(ctx)
+	require.NoError(t, err)
+
+	_, err = r.InstantiateModule(ctx, compiled, wazero.NewModuleConfig())
+	require.NoError(t, err)
+
+	proxyCompiled, err := r.CompileModule(ctx, proxy.NewModuleBinary(ModuleName, compiled))
+	require.NoError(t, err)
+
+	mod, err := r.InstantiateModule(ctx, proxyCompiled, wazero.NewModuleConfig())
+	require.NoError(t, err)
+
+	results, err := mod.ExportedFunction(threadSpawnName).Call(testCtx, 42)
+	require.NoError(t, err)
+	require.Equal(t, -int32(wasi_snapshot_preview1.ErrnoAgain), int32(results[0]))
+	require.Equal(t, `
+--> wasi.thread-spawn(start_arg=42)
+<-- tid=-6
+`, "\n"+log.String())
+}
@@ -0,0 +1,131 @@
+// Package abi computes how values are laid out in memory and passed as core
+// values with the canonical ABI of the component model, which is shared by
+// the experimental canonabi package and the bindings wit-bindgen generates.
+//
+// See https://github.com/WebAssembly/component-model/blob/main/design/mvp/CanonicalABI.md
+package abi
+
+import "github.com/tetratelabs/wazero/api"
+
+const (
+	// MaxFlatParams is the maximum count of core parameters of a function,
+	// beyond which they are passed in memory.
+	MaxFlatParams = 16
+	// MaxFlatResults is the maximum count of core results of a function,
+	// beyond which they are returned in memory.
+	MaxFlatResults = 1
+)
+
+// Layout is how values of a type are laid out in memory, and the core types
+// they are passed as.
+type Layout struct {
+	Size, Alignment uint32
+	Flat            []api.ValueType
+}
+
+// Slice is the layout of a string or list, which is passed as its offset in
+// memory and length.
+var Slice = Layout{Size: 8, Alignment: 4, Flat: []api.ValueType{api.ValueTypeI32, api.ValueTypeI32}}
+
+// Primitive returns the layout of a primitive type of size bytes, passed as
+// vt.
+func Primitive(size uint32, vt api.ValueType) Layout {
+	return Layout{Size: size, Alignment: size, Flat: []api.ValueType{vt}}
+}
+
+// Record returns the layout of a record or tuple with fields, and the
+// offsets of its fields in memory, followed by the offset after the last.
+func Record(fields []Layout) (Layout, []uint32) {
+	l := Layout{Alignment: 1}
+	offsets := make([]uint32, 0, len(fields)+1)
+	var offset uint32
+	for _, f := range fields {
+		if f.Alignment > l.Alignment {
+			l.Alignment = f.Alignment
+		}
+		l.Flat = append(l.Flat, f.Flat...)
+		offset = AlignTo(offset, f.Alignment)
+		offsets = append(offsets, offset)
+		offset += f.Size
+	}
+	offsets = append(offsets, offset)
+	l.Size = AlignTo(offset, l.Alignment)
+	return l, offsets
+}
+
+// Variant returns the layout of a variant with n cases, including options,
+// results and enums, and the offset in memory of the value of a case. cases
+// are the layouts of the values of the cases, which are nil for cases without
+// one.
+func Variant(n int, cases []*Layout) (Layout, uint32) {
+	l := Layout{Alignment: DiscriminantSize(n)}
+	payloadAlignment := uint32(1)
+	var payloadSize uint32
+	var payload []api.ValueType
+	for _, c := range cases {
+		if c == nil {
+			continue
+		}
+		if c.Alignment > payloadAlignment {
+			payloadAlignment = c.Alignment
+		}
+		if c.Size > payloadSize {
+			payloadSize = c.Size
+		}
+		for i, vt := range c.Flat {
+			if i < len(payload) {
+				payload[i] = join(payload[i], vt)
+			} else {
+				payload = append(payload, vt)
+			}
+		}
+	}
+	if payloadAlignment > l.Alignment {
+		l.Alignment = payloadAlignment
+	}
+	payloadOffset := AlignTo(DiscriminantSize(n), payloadAlignment)
+	l.Size = AlignTo(payloadOffset+payloadSize, l.Alignment)
+	l.Flat = append([]api.ValueType{api.ValueTypeI32}, payload...)
+	return l, payloadOffset
+}
+
+// Flags returns the layout of n flags, which are at most 32.
+func Flags(n int) Layout {
+	size := uint32(4)
+	if n <= 8 {
+		size = 1
+	} else if n <= 16 {
+		size = 2
+	}
+	return Primitive(size, api.ValueTypeI32)
+}
+
+// DiscriminantSize returns the size of the discriminant of a variant with n
+// cases.
+func DiscriminantSize(n int) uint32 {
+	switch {
+	case n <= 1<<8:
+		return 1
+	case n <= 1<<16:
+		return 2
+	default:
+		return 4
+	}
+}
+
+// AlignTo returns offset rounded up to a multiple of align.
+func AlignTo(offset, align uint32) uint32 {
+	return (offset + align - 1) / align * align
+}
+
+// join returns the core type which can hold values of both types.
+func join(a, b api.ValueType) api.ValueType {
+	switch {
+	case a == b:
+		return a
+	case a == api.ValueTypeI32 && b == api.ValueTypeF32, a == api.ValueTypeF32 && b == api.ValueTypeI32:
+		return api.ValueTypeI32
+	default:
+		return api.ValueTypeI64
+	}
+}
@@ -0,0 +1,84 @@
+package abi
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestRecord(t *testing.T) {
+	u8, u64 := Primitive(1, api.ValueTypeI32), Primitive(8, api.ValueTypeI64)
+
+	l, offsets := Record([]Layout{u8, u64, u8})
+	require.Equal(t, Layout{
+		Size: 24, Alignment: 8,
+		Flat: []api.ValueType{api.ValueTypeI32, api.ValueTypeI64, api.ValueTypeI32},
+	}, l)
+	require.Equal(t, []uint32{0, 8, 16, 17}, offsets)
+
+	l, offsets = Record(nil)
+	require.Equal(t, Layout{Alignment: 1}, l)
+	require.Equal(t, []uint32{0}, offsets)
+}
+
+func TestVariant(t *testing.T) {
+	f32, u64 := Primitive(4, api.ValueTypeF32), Primitive(8, api.ValueTypeI64)
+
+	tests := []struct {
+		name                  string
+		n                     int
+		cases                 []*Layout
+		expected              Layout
+		expectedPayloadOffset uint32
+	}{
+		{
+			name:                  "enum",
+			n:                     3,
+			expected:              Layout{Size: 1, Alignment: 1, Flat: []api.ValueType{api.ValueTypeI32}},
+			expectedPayloadOffset: 1,
+		},
+		{
+			name:                  "enum with a two byte discriminant",
+			n:                     257,
+			expected:              Layout{Size: 2, Alignment: 2, Flat: []api.ValueType{api.ValueTypeI32}},
+			expectedPayloadOffset: 2,
+		},
+		{
+			name:                  "option",
+			n:                     2,
+			cases:                 []*Layout{nil, &f32},
+			expected:              Layout{Size: 8, Alignment: 4, Flat: []api.ValueType{api.ValueTypeI32, api.ValueTypeF32}},
+			expectedPayloadOffset: 4,
+		},
+		{
+			name:                  "f32 and i32 join to i32",
+			n:                     2,
+			cases:                 []*Layout{&f32, &Slice},
+			expected:              Layout{Size: 12, Alignment: 4, Flat: []api.ValueType{api.ValueTypeI32, api.ValueTypeI32, api.ValueTypeI32}},
+			expectedPayloadOffset: 4,
+		},
+		{
+			name:                  "f32 and i64 join to i64",
+			n:                     3,
+			cases:                 []*Layout{&f32, &u64, nil},
+			expected:              Layout{Size: 16, Alignment: 8, Flat: []api.ValueType{api.ValueTypeI32, api.ValueTypeI64}},
+			expectedPayloadOffset: 8,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			l, payloadOffset := Variant(tc.n, tc.cases)
+			require.Equal(t, tc.expected, l)
+			require.Equal(t, tc.expectedPayloadOffset, payloadOffset)
+		})
+	}
+}
+
+func TestFlags(t *testing.T) {
+	require.Equal(t, uint32(1), Flags(8).Size)
+	require.Equal(t, uint32(2), Flags(9).Size)
+	require.Equal(t, uint32(4), Flags(17).Size)
+}
@@ -0,0 +1,50 @@
+package compilationcache
+
+import (
+	"compress/flate"
+	"io"
+)
+
+// Compression compresses the entries of a file cache, as the native code of
+// large modules can be hundreds of megabytes.
+//
+// This is structurally the same as experimental.CompilationCacheCompression,
+// which embedders implement, e.g. to use zstd.
+type Compression interface {
+	// Name identifies the compression of an entry, so that it is
+	// decompressed the same way, e.g. "zstd". It must be at most 255 bytes.
+	Name() string
+	// NewWriter returns a writer which compresses to w when written, until
+	// closed.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+	// NewReader returns a reader which decompresses from r.
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+// compressedMagic starts a compressed entry. It is followed by the length of
+// the name of the Compression, the name, then the compressed entry.
+//
+// Note: Uncompressed entries start with "WAZERO" instead, so entries written
+// before compression was enabled are still read.
+const compressedMagic = "ZWAZERO"
+
+// Deflate is a Compression with DEFLATE, optimized for speed, which needs no
+// dependencies. Entries compressed with it can always be read.
+var Deflate Compression = deflate{}
+
+type deflate struct{}
+
+// Name implements Compression.Name
+func (deflate) Name() string {
+	return "deflate"
+}
+
+// NewWriter implements Compression.NewWriter
+func (deflate) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return flate.NewWriter(w, flate.BestSpeed)
+}
+
+// NewReader implements Compression.NewReader
+func (deflate) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return flate.NewReader(r), nil
+}
@@ -1,9 +1,11 @@
 package compilationcache
 
 import (
+	"bufio"
 	"context"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path"
@@ -15,10 +17,16 @@ import (
 // representing the compilation cache directory.
 type FileCachePathKey struct{}
 
+// FileCacheCompressionKey is a context.Context Value key. Its value is a
+// Compression to compress new entries of the file cache with.
+type FileCacheCompressionKey struct{}
+
 // NewFileCache returns a new Cache implemented by fileCache.
 func NewFileCache(ctx context.Context) Cache {
 	if fsValue := ctx.Value(FileCachePathKey{}); fsValue != nil {
-		return newFileCache(fsValue.(string))
+		fc := newFileCache(fsValue.(string))
+		fc.compression, _ = ctx.Value(FileCacheCompressionKey{}).(Compression)
+		return fc
 	}
 	return nil
 }
@@ -32,12 +40,18 @@ func newFileCache(dir string) *fileCache {
 // Note: this can be expanded to do binary signing/verification, set TTL on each entry, etc.
 type fileCache struct {
 	dirPath string
-	mux     sync.RWMutex
+	// compression compresses new entries, unless nil.
+	compression Compression
+	mux         sync.RWMutex
 }
 
+// fileReadCloser reads an entry, decompressing it if compressed.
 type fileReadCloser struct {
-	*os.File
-	fc *fileCache
+	io.Reader
+	file *os.File
+	// decompressor is closed before file, unless nil.
+	decompressor io.Closer
+	fc           *fileCache
 }
 
 func (fc *fileCache) path(key Key) string {
@@ -59,17 +73,62 @@ func (fc *fileCache) Get(key Key) (content io.ReadCloser, ok bool, err error) {
 		return nil, false, nil
 	} else if err != nil {
 		return nil, false, err
+	}
+
+	rc := &fileReadCloser{file: f, fc: fc}
+	if rc.Reader, rc.decompressor, ok, err = fc.decompress(f); !ok || err != nil {
+		_ = f.Close()
+		return nil, false, err
+	}
+	// Unlock is done inside the content.Close() at the call site.
+	unlock = nil
+	return rc, true, nil
+}
+
+// decompress returns a reader of the entry in f, which is compressed if it
+// starts with compressedMagic. This returns ok=false if the entry was
+// compressed with an unknown Compression, so that it's compiled again.
+func (fc *fileCache) decompress(f *os.File) (r io.Reader, decompressor io.Closer, ok bool, err error) {
+	br := bufio.NewReader(f)
+	if magic, _ := br.Peek(len(compressedMagic)); string(magic) != compressedMagic {
+		return br, nil, true, nil
+	}
+
+	header := make([]byte, len(compressedMagic)+1)
+	if _, err = io.ReadFull(br, header); err != nil {
+		return
+	}
+	name := make([]byte, header[len(compressedMagic)])
+	if _, err = io.ReadFull(br, name); err != nil {
+		return
+	}
+
+	var c Compression
+	if fc.compression != nil && fc.compression.Name() == string(name) {
+		c = fc.compression
+	} else if Deflate.Name() == string(name) {
+		c = Deflate
 	} else {
-		// Unlock is done inside the content.Close() at the call site.
-		unlock = nil
-		return &fileReadCloser{File: f, fc: fc}, true, nil
+		return nil, nil, false, nil
 	}
+
+	rc, err := c.NewReader(br)
+	if err != nil {
+		return
+	}
+	return rc, rc, true, nil
 }
 
-// Close wraps the os.File Close to release the read lock on fileCache.
+// Close closes the file and any decompressor, and releases the read lock on
+// fileCache.
 func (f *fileReadCloser) Close() (err error) {
 	defer f.fc.mux.RUnlock()
-	err = f.File.Close()
+	if f.decompressor != nil {
+		err = f.decompressor.Close()
+	}
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
 	return
 }
 
@@ -83,8 +142,28 @@ func (fc *fileCache) Add(key Key, content io.Reader) (err error) {
 		return
 	}
 	defer file.Close()
-	_, err = io.Copy(file, content)
-	return
+
+	if fc.compression == nil {
+		_, err = io.Copy(file, content)
+		return
+	}
+
+	name := fc.compression.Name()
+	if len(name) > 255 {
+		return fmt.Errorf("compression name %s is longer than 255 bytes", name)
+	}
+	if _, err = file.WriteString(compressedMagic + string(byte(len(name))) + name); err != nil {
+		return
+	}
+	w, err := fc.compression.NewWriter(file)
+	if err != nil {
+		return
+	}
+	if _, err = io.Copy(w, content); err != nil {
+		_ = w.Close()
+		return
+	}
+	return w.Close()
 }
 
 func (fc *fileCache) Delete(key Key) (err error) {
@@ -146,3 +146,92 @@ func TestFileCache_path(t *testing.T) {
 	require.True(t, strings.HasPrefix(actual, fc.dirPath))
 	require.True(t, strings.HasSuffix(actual, "0102030405000000000000000000000000000000000000000000000000000000"))
 }
+
+// testCompression is a Compression which doesn't compress, but counts how
+// many entries were written and read.
+type testCompression struct{ written, read int }
+
+func (c *testCompression) Name() string { return "test" }
+
+func (c *testCompression) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	c.written++
+	return nopWriteCloser{w}, nil
+}
+
+func (c *testCompression) NewReader(r io.Reader) (io.ReadCloser, error) {
+	c.read++
+	return io.NopCloser(r), nil
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestFileCache_Compression(t *testing.T) {
+	dir := t.TempDir()
+	content := bytes.Repeat([]byte("WAZERO"), 100)
+
+	get := func(fc *fileCache, key Key) ([]byte, bool) {
+		c, ok, err := fc.Get(key)
+		require.NoError(t, err)
+		if !ok {
+			return nil, false
+		}
+		defer func() { require.NoError(t, c.Close()) }()
+		actual, err := io.ReadAll(c)
+		require.NoError(t, err)
+		return actual, true
+	}
+
+	t.Run("deflate", func(t *testing.T) {
+		fc := newFileCache(dir)
+		fc.compression = Deflate
+		key := Key{1}
+		require.NoError(t, fc.Add(key, bytes.NewReader(content)))
+
+		cached, err := os.ReadFile(fc.path(key))
+		require.NoError(t, err)
+		require.True(t, bytes.HasPrefix(cached, []byte("ZWAZERO\x07deflate")))
+		require.True(t, len(cached) < len(content)/2)
+
+		actual, ok := get(fc, key)
+		require.True(t, ok)
+		require.Equal(t, content, actual)
+
+		// DEFLATE entries are read even if not configured.
+		actual, ok = get(newFileCache(dir), key)
+		require.True(t, ok)
+		require.Equal(t, content, actual)
+	})
+
+	t.Run("custom", func(t *testing.T) {
+		c := &testCompression{}
+		fc := newFileCache(dir)
+		fc.compression = c
+		key := Key{2}
+		require.NoError(t, fc.Add(key, bytes.NewReader(content)))
+
+		actual, ok := get(fc, key)
+		require.True(t, ok)
+		require.Equal(t, content, actual)
+		require.Equal(t, 1, c.written)
+		require.Equal(t, 1, c.read)
+
+		// An entry of an unknown compression is a miss, so it's compiled
+		// again.
+		_, ok = get(newFileCache(dir), key)
+		require.False(t, ok)
+	})
+
+	t.Run("uncompressed", func(t *testing.T) {
+		key := Key{3}
+		require.NoError(t, newFileCache(dir).Add(key, bytes.NewReader(content)))
+
+		// Uncompressed entries are read when compression is configured.
+		fc := newFileCache(dir)
+		fc.compression = Deflate
+		actual, ok := get(fc, key)
+		require.True(t, ok)
+		require.Equal(t, content, actual)
+	})
+}
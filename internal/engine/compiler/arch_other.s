@@ -1,3 +1,3 @@
 //go:build !arm64 && !amd64
 
-TEXT ·nativecall(SB),$0-16
+TEXT ·nativecall(SB),$0-24
@@ -2,6 +2,7 @@ package compiler
 
 import (
 	"github.com/tetratelabs/wazero/internal/asm"
+	"github.com/tetratelabs/wazero/internal/wasm"
 	"github.com/tetratelabs/wazero/internal/wazeroir"
 )
 
@@ -152,7 +153,7 @@ type compiler interface {
 	// compileMemorySize adds instruction to perform wazeroir.OperationMemoryGrow.
 	compileMemoryGrow() error
 	// compileMemorySize adds instruction to perform wazeroir.OperationMemorySize.
-	compileMemorySize() error
+	compileMemorySize(o *wazeroir.OperationMemorySize) error
 	// compileConstI32 adds instruction to perform wazeroir.OperationConstI32.
 	compileConstI32(o *wazeroir.OperationConstI32) error
 	// compileConstI64 adds instruction to perform wazeroir.OperationConstI64.
@@ -299,6 +300,13 @@ type compiler interface {
 	compileV128Narrow(o *wazeroir.OperationV128Narrow) error
 	// compileV128ITruncSatFromF adds instructions to perform wazeroir.OperationV128ITruncSatFromF.
 	compileV128ITruncSatFromF(o *wazeroir.OperationV128ITruncSatFromF) error
+	// compileBuiltinFunctionCheckExitCode adds instructions to perform wazeroir.OperationBuiltinFunctionCheckExitCode.
+	compileBuiltinFunctionCheckExitCode() error
+	// compileBuiltinFunctionConsumeFuel adds instructions to perform wazeroir.OperationBuiltinFunctionConsumeFuel.
+	compileBuiltinFunctionConsumeFuel() error
+	// compileBuiltinFunctionMemoryAccess adds instructions to perform wazeroir.OperationBuiltinFunctionMemoryAccess.
+	// index is the builtin function index identifying the operation, as documented on builtinFunctionIndexMemoryAccess.
+	compileBuiltinFunctionMemoryAccess(index wasm.Index) error
 
 	// compileReleaseRegisterToStack adds instructions to write the value on a register back to memory stack region.
 	compileReleaseRegisterToStack(loc *runtimeValueLocation)
@@ -51,28 +51,42 @@ func TestCompiler_compileMemoryGrow(t *testing.T) {
 }
 
 func TestCompiler_compileMemorySize(t *testing.T) {
-	env := newCompilerEnvironment()
-	compiler := env.requireNewCompiler(t, newCompiler, &wazeroir.CompilationResult{HasMemory: true, Signature: &wasm.FunctionType{}})
+	tests := []struct {
+		name           string
+		pageSizeInBits uint32
+		expected       uint32
+	}{
+		{name: "default", pageSizeInBits: wasm.MemoryPageSizeInBits, expected: defaultMemoryPageNumInTest},
+		{name: "1 byte", pageSizeInBits: 0, expected: defaultMemoryPageNumInTest * wasm.MemoryPageSize},
+	}
 
-	err := compiler.compilePreamble()
-	require.NoError(t, err)
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			env := newCompilerEnvironment()
+			compiler := env.requireNewCompiler(t, newCompiler, &wazeroir.CompilationResult{HasMemory: true, Signature: &wasm.FunctionType{}})
 
-	// Emit memory.size instructions.
-	err = compiler.compileMemorySize()
-	require.NoError(t, err)
-	// At this point, the size of memory should be pushed onto the stack.
-	requireRuntimeLocationStackPointerEqual(t, uint64(1), compiler)
+			err := compiler.compilePreamble()
+			require.NoError(t, err)
 
-	err = compiler.compileReturnFunction()
-	require.NoError(t, err)
+			// Emit memory.size instructions.
+			err = compiler.compileMemorySize(&wazeroir.OperationMemorySize{PageSizeInBits: tc.pageSizeInBits})
+			require.NoError(t, err)
+			// At this point, the size of memory should be pushed onto the stack.
+			requireRuntimeLocationStackPointerEqual(t, uint64(1), compiler)
 
-	// Generate and run the code under test.
-	code, _, err := compiler.compile()
-	require.NoError(t, err)
-	env.exec(code)
+			err = compiler.compileReturnFunction()
+			require.NoError(t, err)
 
-	require.Equal(t, nativeCallStatusCodeReturned, env.compilerStatus())
-	require.Equal(t, uint32(defaultMemoryPageNumInTest), env.stackTopAsUint32())
+			// Generate and run the code under test.
+			code, _, err := compiler.compile()
+			require.NoError(t, err)
+			env.exec(code)
+
+			require.Equal(t, nativeCallStatusCodeReturned, env.compilerStatus())
+			require.Equal(t, tc.expected, env.stackTopAsUint32())
+		})
+	}
 }
 
 func TestCompiler_compileLoad(t *testing.T) {
@@ -14,6 +14,7 @@ import (
 	"github.com/tetratelabs/wazero/experimental"
 	"github.com/tetratelabs/wazero/internal/asm"
 	"github.com/tetratelabs/wazero/internal/compilationcache"
+	"github.com/tetratelabs/wazero/internal/gdbjit"
 	"github.com/tetratelabs/wazero/internal/platform"
 	"github.com/tetratelabs/wazero/internal/version"
 	"github.com/tetratelabs/wazero/internal/wasm"
@@ -35,6 +36,9 @@ type (
 		// setFinalizer defaults to runtime.SetFinalizer, but overridable for tests.
 		setFinalizer  func(obj interface{}, finalizer interface{})
 		wazeroVersion string
+		// gdbJIT is true if code is registered with the GDB JIT interface.
+		// See gdbjit.EnabledKey.
+		gdbJIT bool
 	}
 
 	// moduleEngine implements wasm.ModuleEngine
@@ -134,6 +138,10 @@ type (
 		// contextStack is a stack of contexts which is pushed and popped by function listeners.
 		// This is used and modified when there are function listeners.
 		contextStack *contextStack
+
+		// stackIterator is passed to function listeners, and reset before each
+		// call to experimental.FunctionListener Before.
+		stackIterator stackIterator
 	}
 
 	// contextStack is a stack of context.Context.
@@ -141,6 +149,9 @@ type (
 		// See note at top of file before modifying this struct.
 
 		self context.Context
+		// fn is the function whose listener returned the context above self,
+		// and is used to notify it via Abort when its frame is unwound.
+		fn   *function
 		prev *contextStack
 	}
 
@@ -286,6 +297,22 @@ type (
 		listener experimental.FunctionListener
 
 		sourceOffsetMap *sourceOffsetMap
+
+		// ensureTermination is true if this code was compiled with ensureTermination.
+		// See the doc on wasm.Engine CompileModule.
+		ensureTermination bool
+
+		// memoryListener is notified of loads and stores which overlap any of
+		// watchRegions, when not nil. See wasm.Module MemoryListener.
+		memoryListener experimental.MemoryListener
+		watchRegions   []experimental.WatchRegion
+		// memoryAccesses are the wazeroir.OperationBuiltinFunctionMemoryAccess
+		// of this function, in the order of their builtin function index.
+		memoryAccesses []*wazeroir.OperationBuiltinFunctionMemoryAccess
+
+		// gdbJITEntry is the registration of codeSegment with the GDB JIT
+		// interface, or nil if not registered.
+		gdbJITEntry *gdbjit.Entry
 	}
 
 	// sourceOffsetMap holds the information to retrieve the original offset in the Wasm binary from the
@@ -464,6 +491,11 @@ func releaseCode(compiledFn *code) {
 
 	// Setting this to nil allows tests to know the correct finalizer function was called.
 	compiledFn.codeSegment = nil
+	if e := compiledFn.gdbJITEntry; e != nil {
+		// Unregister before munmap, so a debugger doesn't read stale code.
+		compiledFn.gdbJITEntry = nil
+		gdbjit.Unregister(e)
+	}
 	if err := platform.MunmapCodeSegment(codeSegment); err != nil {
 		// munmap failure cannot recover, and happen asynchronously on the finalizer thread. While finalizer
 		// functions can return errors, they are ignored. To make these visible for troubleshooting, we panic
@@ -484,14 +516,14 @@ func (e *engine) DeleteCompiledModule(module *wasm.Module) {
 }
 
 // CompileModule implements the same method as documented on wasm.Engine.
-func (e *engine) CompileModule(ctx context.Context, module *wasm.Module, listeners []experimental.FunctionListener) error {
+func (e *engine) CompileModule(ctx context.Context, module *wasm.Module, listeners []experimental.FunctionListener, ensureTermination bool) error {
 	if _, ok, err := e.getCodes(module); ok { // cache hit!
 		return nil
 	} else if err != nil {
 		return err
 	}
 
-	irs, err := wazeroir.CompileFunctions(ctx, e.enabledFeatures, callFrameDataSizeInUint64, module)
+	irs, err := wazeroir.CompileFunctions(ctx, e.enabledFeatures, callFrameDataSizeInUint64, module, ensureTermination)
 	if err != nil {
 		return err
 	}
@@ -499,6 +531,10 @@ func (e *engine) CompileModule(ctx context.Context, module *wasm.Module, listene
 	importedFuncs := module.ImportFuncCount()
 	funcs := make([]*code, len(module.FunctionSection))
 	ln := len(listeners)
+	var watchRegions []experimental.WatchRegion
+	if ml := module.MemoryListener; ml != nil {
+		watchRegions = ml.WatchRegions()
+	}
 	cmp := newCompiler()
 	for i, ir := range irs {
 		var lsn experimental.FunctionListener
@@ -524,11 +560,45 @@ func (e *engine) CompileModule(ctx context.Context, module *wasm.Module, listene
 		compiled.listener = lsn
 		compiled.indexInModule = funcIndex
 		compiled.sourceModule = module
+		compiled.ensureTermination = ensureTermination
+		compiled.memoryListener = module.MemoryListener
+		compiled.watchRegions = watchRegions
 		funcs[funcIndex] = compiled
 	}
 	return e.addCodes(module, funcs)
 }
 
+// NativeCode compiles the functions defined in the module, and returns a copy
+// of the native code of each, e.g. to disassemble it.
+func NativeCode(ctx context.Context, enabledFeatures api.CoreFeatures, module *wasm.Module) ([][]byte, error) {
+	irs, err := wazeroir.CompileFunctions(ctx, enabledFeatures, callFrameDataSizeInUint64, module, false)
+	if err != nil {
+		return nil, err
+	}
+
+	importedFuncs := module.ImportFuncCount()
+	ret := make([][]byte, len(irs))
+	cmp := newCompiler()
+	for i, ir := range irs {
+		cmp.Init(ir, false)
+		var compiled *code
+		if ir.GoFunc != nil {
+			compiled, err = compileGoDefinedHostFunction(cmp)
+		} else {
+			compiled, err = compileWasmFunction(cmp, ir)
+		}
+		if err != nil {
+			def := module.FunctionDefinitionSection[wasm.Index(i)+importedFuncs]
+			return nil, fmt.Errorf("error compiling func[%s]: %w", def.DebugName(), err)
+		}
+		ret[i] = append([]byte(nil), compiled.codeSegment...)
+		compiled.indexInModule = wasm.Index(i)
+		compiled.sourceModule = module
+		releaseCode(compiled)
+	}
+	return ret, nil
+}
+
 // NewModuleEngine implements the same method as documented on wasm.Engine.
 func (e *engine) NewModuleEngine(name string, module *wasm.Module, functions []wasm.FunctionInstance) (wasm.ModuleEngine, error) {
 	me := &moduleEngine{
@@ -642,12 +712,21 @@ func (ce *callEngine) Call(ctx context.Context, callCtx *wasm.CallContext, param
 		return nil, fmt.Errorf("expected %d params, but passed %d", ce.initialFn.source.Type.ParamNumInUint64, paramCount)
 	}
 
+	if ce.initialFn.parent.ensureTermination && ctx.Done() != nil {
+		done := callCtx.CloseModuleOnCanceledOrTimeout(ctx)
+		defer done()
+	}
+
 	// We ensure that this Call method never panics as
 	// this Call method is indirectly invoked by embedders via store.CallFunction,
 	// and we have to make sure that all the runtime errors, including the one happening inside
 	// host functions, will be captured as errors, not panics.
 	defer func() {
-		err = ce.deferredOnCall(recover())
+		v := recover()
+		err = ce.deferredOnCall(ctx, callCtx, v)
+		if p, ok := v.(*wasm.HostFunctionPanic); ok {
+			panic(p.Recovered)
+		}
 		if err == nil {
 			// If the module closed during the call, and the call didn't err for another reason, set an ExitError.
 			err = callCtx.FailIfClosed()
@@ -717,53 +796,127 @@ func callFrameOffset(funcType *wasm.FunctionType) (ret int) {
 }
 
 // deferredOnCall takes the recovered value `recovered`, and wraps it
-// with the call frame stack traces when not nil. This also resets
-// the state of callEngine so that it can be used for the subsequent calls.
+// with the call frame stack traces when not nil. This also notifies the
+// function listeners of unwound frames via Abort, and resets the state of
+// callEngine so that it can be used for the subsequent calls.
 //
 // This is defined for testability.
-func (ce *callEngine) deferredOnCall(recovered interface{}) (err error) {
+func (ce *callEngine) deferredOnCall(ctx context.Context, mod api.Module, recovered interface{}) (err error) {
+	if trap, ok := recovered.(*wasmruntime.Error); ok {
+		if handler := ce.initialFn.parent.sourceModule.TrapHandler; handler != nil {
+			recovered = ce.onTrap(ctx, handler, trap)
+		}
+	}
 	if recovered != nil {
 		builder := wasmdebug.NewErrorBuilder()
+		handler := wasm.CoredumpHandler(ctx, recovered)
+		var coredump []wasm.CoredumpFrame
 
 		// Unwinds call frames from the values stack, starting from the
 		// current function `ce.fn`, and the current stack base pointer `ce.stackBasePointerInBytes`.
-		fn := ce.fn
-		pc := uint64(ce.returnAddress)
-		stackBasePointer := int(ce.stackBasePointerInBytes >> 3)
-		for {
-			source := fn.source
-			def := source.Definition
+		si := &ce.stackIterator
+		si.reset(ce.stack, ce.fn, uint64(ce.returnAddress), int(ce.stackBasePointerInBytes>>3))
+		for si.Next() {
+			fn, pc := si.fn, si.pc
+			def := fn.source.Definition
 
 			// sourceInfo holds the source code information corresponding to the frame.
 			// It is not empty only when the DWARF is enabled.
+			var offset uint64
 			var sources []string
 			if p := fn.parent; p.codeSegment != nil {
 				if p.sourceOffsetMap != nil {
-					offset := fn.getSourceOffsetInWasmBinary(pc)
-					sources = p.sourceModule.DWARFLines.Line(offset)
+					offset = fn.getSourceOffsetInWasmBinary(pc)
+					sources = p.sourceModule.SourceLines(offset)
 				}
 			}
-			builder.AddFrame(def.DebugName(), def.ParamTypes(), def.ResultTypes(), sources)
-
-			callFrameOffset := callFrameOffset(source.Type)
-			if stackBasePointer != 0 {
-				frame := *(*callFrame)(unsafe.Pointer(&ce.stack[stackBasePointer+callFrameOffset]))
-				fn = frame.function
-				pc = uint64(frame.returnAddress)
-				stackBasePointer = int(frame.returnStackBasePointerInBytes >> 3)
-			} else { // base == 0 means that this was the last call frame stacked.
-				break
+			builder.AddFrame(def.DebugName(), def.Index(), offset, def.ParamTypes(), def.ResultTypes(), sources)
+			if handler != nil {
+				// Locals may be in registers, so aren't dumped.
+				coredump = append(coredump, wasm.CoredumpFrame{Function: fn.source, Offset: offset})
 			}
 		}
 		err = builder.FromRecovered(recovered)
+		if handler != nil {
+			handler(ctx, wasm.Coredump(mod.Name(), coredump))
+		}
+
+		// The context of each listener is the one pushed above its caller's.
+		lctx := ce.ctx
+		for s := ce.contextStack; s != nil; s = s.prev {
+			s.fn.parent.listener.Abort(lctx, mod, s.fn.source.Definition, err)
+			lctx = s.self
+		}
 	}
 
 	// Allows the reuse of CallEngine.
 	ce.stackBasePointerInBytes, ce.stackPointer, ce.moduleInstanceAddress = 0, 0, 0
 	ce.moduleContext.fn = ce.initialFn
+	ce.contextStack = nil
 	return
 }
 
+// onTrap invokes the trap handler, which can't resume the trap as the state
+// of native code isn't recoverable. This returns the trap, or the value the
+// handler panicked with.
+func (ce *callEngine) onTrap(ctx context.Context, handler experimental.TrapHandler, err *wasmruntime.Error) (recovered interface{}) {
+	trap := &experimental.Trap{Err: err, Module: ce.fn.source.Module.CallCtx, Function: ce.fn.source.Definition}
+	si := &ce.stackIterator
+	si.reset(ce.stack, ce.fn, uint64(ce.returnAddress), int(ce.stackBasePointerInBytes>>3))
+	for si.Next() {
+		if p := si.fn.parent; len(trap.Stack) == 0 && p.codeSegment != nil && p.sourceOffsetMap != nil {
+			trap.Offset = si.fn.getSourceOffsetInWasmBinary(si.pc)
+		}
+		trap.Stack = append(trap.Stack, si.fn.source.Definition)
+	}
+
+	recovered = err
+	defer func() {
+		if v := recover(); v != nil {
+			recovered = v
+		}
+	}()
+	handler(ctx, trap)
+	return
+}
+
+// stackIterator implements experimental.StackIterator by unwinding call
+// frames from the values stack.
+type stackIterator struct {
+	stack []uint64
+	// fn, pc and stackBasePointer are of the current function, or of the
+	// function called before the first call to Next.
+	fn               *function
+	pc               uint64
+	stackBasePointer int
+	started          bool
+}
+
+func (si *stackIterator) reset(stack []uint64, fn *function, pc uint64, stackBasePointer int) {
+	si.stack, si.fn, si.pc, si.stackBasePointer, si.started = stack, fn, pc, stackBasePointer, false
+}
+
+// Next implements the same method as documented on experimental.StackIterator.
+func (si *stackIterator) Next() bool {
+	if !si.started {
+		si.started = true
+		return true
+	}
+	if si.stackBasePointer == 0 { // base == 0 means that this was the last call frame stacked.
+		return false
+	}
+	frame := *(*callFrame)(unsafe.Pointer(&si.stack[si.stackBasePointer+callFrameOffset(si.fn.source.Type)]))
+	si.fn = frame.function
+	si.pc = uint64(frame.returnAddress)
+	si.stackBasePointer = int(frame.returnStackBasePointerInBytes >> 3)
+	return true
+}
+
+// FunctionDefinition implements the same method as documented on experimental.StackIterator.
+func (si *stackIterator) FunctionDefinition() api.FunctionDefinition {
+	return si.fn.source.Definition
+}
+
 // getSourceOffsetInWasmBinary returns the corresponding offset in the original Wasm binary's code section
 // for the given pc (which is an absolute address in the memory).
 // If needPreviousInstr equals true, this returns the previous instruction's offset for the given pc.
@@ -809,6 +962,7 @@ func newEngine(ctx context.Context, enabledFeatures api.CoreFeatures) *engine {
 		setFinalizer:    runtime.SetFinalizer,
 		Cache:           compilationcache.NewFileCache(ctx),
 		wazeroVersion:   wazeroVersion,
+		gdbJIT:          gdbjit.Enabled(ctx),
 	}
 }
 
@@ -878,8 +1032,13 @@ const (
 	builtinFunctionIndexTableGrow
 	builtinFunctionIndexFunctionListenerBefore
 	builtinFunctionIndexFunctionListenerAfter
+	builtinFunctionIndexCheckExitCode
+	builtinFunctionIndexConsumeFuel
 	// builtinFunctionIndexBreakPoint is internal (only for wazero developers). Disabled by default.
 	builtinFunctionIndexBreakPoint
+	// builtinFunctionIndexMemoryAccess is the index of the first wazeroir.OperationBuiltinFunctionMemoryAccess
+	// of a function. The index of each is this plus its position in code.memoryAccesses, so this must be last.
+	builtinFunctionIndexMemoryAccess
 )
 
 func (ce *callEngine) execWasmFunction(ctx context.Context, callCtx *wasm.CallContext) {
@@ -908,13 +1067,7 @@ entry:
 			}
 			stack := ce.stack[base : base+stackLen]
 
-			fn := calleeHostFunction.source.GoFunc
-			switch fn := fn.(type) {
-			case api.GoModuleFunction:
-				fn.Call(ce.ctx, callCtx.WithMemory(ce.memoryInstance), stack)
-			case api.GoFunction:
-				fn.Call(ce.ctx, stack)
-			}
+			wasm.CallGoFunc(ce.ctx, callCtx, ce.memoryInstance, calleeHostFunction.source, stack)
 
 			codeAddr, modAddr = ce.returnAddress, ce.moduleInstanceAddress
 			goto entry
@@ -928,9 +1081,22 @@ entry:
 			case builtinFunctionIndexTableGrow:
 				ce.builtinFunctionTableGrow(caller.source.Module.Tables)
 			case builtinFunctionIndexFunctionListenerBefore:
-				ce.builtinFunctionFunctionListenerBefore(ce.ctx, caller)
+				ce.builtinFunctionFunctionListenerBefore(ce.ctx, callCtx, caller)
 			case builtinFunctionIndexFunctionListenerAfter:
-				ce.builtinFunctionFunctionListenerAfter(ce.ctx, caller)
+				ce.builtinFunctionFunctionListenerAfter(ce.ctx, callCtx, caller)
+			case builtinFunctionIndexCheckExitCode:
+				// Note: this operation must be done in Go, not native code. The reason is that
+				// native code cannot be preempted and that means it can block forever if there are not
+				// enough OS threads (which we don't have control over).
+				if err := callCtx.FailIfClosed(); err != nil {
+					panic(err)
+				}
+			case builtinFunctionIndexConsumeFuel:
+				wasm.ConsumeFuel(ce.ctx)
+			default:
+				if index := ce.exitContext.builtinFunctionCallIndex; index >= builtinFunctionIndexMemoryAccess {
+					ce.builtinFunctionMemoryAccess(ce.ctx, callCtx, caller, caller.parent.memoryAccesses[index-builtinFunctionIndexMemoryAccess])
+				}
 			}
 			if false {
 				if ce.exitContext.builtinFunctionCallIndex == builtinFunctionIndexBreakPoint {
@@ -995,21 +1161,45 @@ func (ce *callEngine) builtinFunctionTableGrow(tables []*wasm.TableInstance) {
 	ce.pushValue(uint64(res))
 }
 
-func (ce *callEngine) builtinFunctionFunctionListenerBefore(ctx context.Context, fn *function) {
+func (ce *callEngine) builtinFunctionFunctionListenerBefore(ctx context.Context, mod api.Module, fn *function) {
 	base := int(ce.stackBasePointerInBytes >> 3)
-	listerCtx := fn.parent.listener.Before(ctx, fn.source.Definition, ce.stack[base:base+fn.source.Type.ParamNumInUint64])
+	ce.stackIterator.reset(ce.stack, fn, uint64(ce.returnAddress), base)
+	listerCtx := fn.parent.listener.Before(ctx, mod, fn.source.Definition, ce.stack[base:base+fn.source.Type.ParamNumInUint64], &ce.stackIterator)
 	prevStackTop := ce.contextStack
-	ce.contextStack = &contextStack{self: ctx, prev: prevStackTop}
+	ce.contextStack = &contextStack{self: ctx, fn: fn, prev: prevStackTop}
 	ce.ctx = listerCtx
 }
 
-func (ce *callEngine) builtinFunctionFunctionListenerAfter(ctx context.Context, fn *function) {
+func (ce *callEngine) builtinFunctionFunctionListenerAfter(ctx context.Context, mod api.Module, fn *function) {
 	base := int(ce.stackBasePointerInBytes >> 3)
-	fn.parent.listener.After(ctx, fn.source.Definition, nil, ce.stack[base:base+fn.source.Type.ResultNumInUint64])
+	fn.parent.listener.After(ctx, mod, fn.source.Definition, ce.stack[base:base+fn.source.Type.ResultNumInUint64])
 	ce.ctx = ce.contextStack.self
 	ce.contextStack = ce.contextStack.prev
 }
 
+// builtinFunctionMemoryAccess notifies the memory listener of fn if the access
+// by the next operation overlaps any of its watch regions.
+func (ce *callEngine) builtinFunctionMemoryAccess(ctx context.Context, mod api.Module, fn *function, o *wazeroir.OperationBuiltinFunctionMemoryAccess) {
+	// All values were released to the stack, where the address operand is an
+	// i32 below o.Depth values.
+	address := uint32(ce.stack[ce.stackTopIndex()-1-uint64(o.Depth)])
+	access := experimental.MemoryAccess{Offset: uint64(address) + uint64(o.Arg.Offset), Size: o.Size, Store: o.Store}
+	if o.Bulk { // The size is the top operand.
+		access.Size = uint32(ce.stack[ce.stackTopIndex()-1])
+	}
+	if access.Store && fn.source.Module.Memory.IsReadOnly(access.Offset, uint64(access.Size)) {
+		panic(wasmruntime.ErrRuntimeReadOnlyMemoryAccess)
+	} else if o.Bulk {
+		return // Bulk instructions are only instrumented to protect memory.
+	}
+	for _, r := range fn.parent.watchRegions {
+		if r.Overlaps(access.Offset, access.Size) {
+			fn.parent.memoryListener.OnMemoryAccess(ctx, mod, fn.source.Definition, access)
+			return
+		}
+	}
+}
+
 func compileGoDefinedHostFunction(cmp compiler) (*code, error) {
 	if err := cmp.compileGoDefinedHostFunction(); err != nil {
 		return nil, err
@@ -1034,6 +1224,7 @@ func compileWasmFunction(cmp compiler, ir *wazeroir.CompilationResult) (*code, e
 		irOpBegins = make([]asm.Node, len(ir.Operations))
 	}
 
+	var memoryAccesses []*wazeroir.OperationBuiltinFunctionMemoryAccess
 	var skip bool
 	for i, op := range ir.Operations {
 		if needSourceOffsets {
@@ -1101,7 +1292,7 @@ func compileWasmFunction(cmp compiler, ir *wazeroir.CompilationResult) (*code, e
 		case *wazeroir.OperationStore32:
 			err = cmp.compileStore32(o)
 		case *wazeroir.OperationMemorySize:
-			err = cmp.compileMemorySize()
+			err = cmp.compileMemorySize(o)
 		case *wazeroir.OperationMemoryGrow:
 			err = cmp.compileMemoryGrow()
 		case *wazeroir.OperationConstI32:
@@ -1334,6 +1525,13 @@ func compileWasmFunction(cmp compiler, ir *wazeroir.CompilationResult) (*code, e
 			err = cmp.compileV128Narrow(o)
 		case *wazeroir.OperationV128ITruncSatFromF:
 			err = cmp.compileV128ITruncSatFromF(o)
+		case *wazeroir.OperationBuiltinFunctionCheckExitCode:
+			err = cmp.compileBuiltinFunctionCheckExitCode()
+		case *wazeroir.OperationBuiltinFunctionConsumeFuel:
+			err = cmp.compileBuiltinFunctionConsumeFuel()
+		case *wazeroir.OperationBuiltinFunctionMemoryAccess:
+			err = cmp.compileBuiltinFunctionMemoryAccess(builtinFunctionIndexMemoryAccess + wasm.Index(len(memoryAccesses)))
+			memoryAccesses = append(memoryAccesses, o)
 		default:
 			err = errors.New("unsupported")
 		}
@@ -1347,7 +1545,7 @@ func compileWasmFunction(cmp compiler, ir *wazeroir.CompilationResult) (*code, e
 		return nil, fmt.Errorf("failed to compile: %w", err)
 	}
 
-	ret := &code{codeSegment: c, stackPointerCeil: stackPointerCeil}
+	ret := &code{codeSegment: c, stackPointerCeil: stackPointerCeil, memoryAccesses: memoryAccesses}
 	if needSourceOffsets {
 		offsetInNativeBin := make([]uint64, len(irOpBegins))
 		for i, nop := range irOpBegins {
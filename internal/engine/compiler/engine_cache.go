@@ -3,9 +3,11 @@ package compiler
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 
+	"github.com/tetratelabs/wazero/internal/gdbjit"
 	"github.com/tetratelabs/wazero/internal/platform"
 	"github.com/tetratelabs/wazero/internal/u32"
 	"github.com/tetratelabs/wazero/internal/u64"
@@ -40,6 +42,15 @@ func (e *engine) getCodes(module *wasm.Module) (codes []*code, ok bool, err erro
 }
 
 func (e *engine) addCodesToMemory(module *wasm.Module, codes []*code) {
+	if e.gdbJIT {
+		// Codes are added to memory once after compilation or loading from
+		// the cache, so register them here.
+		importedFuncs := module.ImportFuncCount()
+		for i, c := range codes {
+			def := module.FunctionDefinitionSection[wasm.Index(i)+importedFuncs]
+			c.gdbJITEntry = gdbjit.Register(def.DebugName(), c.codeSegment)
+		}
+	}
 	e.mux.Lock()
 	defer e.mux.Unlock()
 	e.codes[module.ID] = codes
@@ -53,7 +64,9 @@ func (e *engine) getCodesFromMemory(module *wasm.Module) (codes []*code, ok bool
 }
 
 func (e *engine) addCodesToCache(module *wasm.Module, codes []*code) (err error) {
-	if e.Cache == nil || module.IsHostModule {
+	// Code instrumented for a memory listener or memory protection refers to
+	// memory access operations, which aren't serialized, so can't be cached.
+	if e.Cache == nil || module.IsHostModule || module.MemoryListener != nil || module.ProtectMemory {
 		return
 	}
 	err = e.Cache.Add(module.ID, serializeCodes(e.wazeroVersion, codes))
@@ -61,7 +74,7 @@ func (e *engine) addCodesToCache(module *wasm.Module, codes []*code) (err error)
 }
 
 func (e *engine) getCodesFromCache(module *wasm.Module) (codes []*code, hit bool, err error) {
-	if e.Cache == nil || module.IsHostModule {
+	if e.Cache == nil || module.IsHostModule || module.MemoryListener != nil || module.ProtectMemory {
 		return
 	}
 
@@ -101,6 +114,12 @@ func serializeCodes(wazeroVersion string, codes []*code) io.Reader {
 	buf.WriteByte(byte(len(wazeroVersion)))
 	// Version of wazero.
 	buf.WriteString(wazeroVersion)
+	// Whether the codes were compiled with ensureTermination: 1 byte.
+	if len(codes) > 0 && codes[0].ensureTermination {
+		buf.WriteByte(1)
+	} else {
+		buf.WriteByte(0)
+	}
 	// Number of *code (== locally defined functions in the module): 4 bytes.
 	buf.Write(u32.LeBytes(uint32(len(codes))))
 	for _, c := range codes {
@@ -115,17 +134,16 @@ func serializeCodes(wazeroVersion string, codes []*code) io.Reader {
 }
 
 func deserializeCodes(wazeroVersion string, reader io.Reader) (codes []*code, staleCache bool, err error) {
-	cacheHeaderSize := len(wazeroMagic) + 1 /* version size */ + len(wazeroVersion) + 4 /* number of functions */
+	cacheHeaderSize := len(wazeroMagic) + 1 /* version size */ + len(wazeroVersion) + 1 /* ensureTermination */ + 4 /* number of functions */
 
-	// Read the header before the native code.
+	// Read the header before the native code. Note: reader may return less
+	// than requested per Read, e.g. when decompressing, so read fully.
 	header := make([]byte, cacheHeaderSize)
-	n, err := reader.Read(header)
-	if err != nil {
-		return nil, false, fmt.Errorf("compilationcache: error reading header: %v", err)
-	}
-
-	if n != cacheHeaderSize {
+	n, err := io.ReadFull(reader, header)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
 		return nil, false, fmt.Errorf("compilationcache: invalid header length: %d", n)
+	} else if err != nil {
+		return nil, false, fmt.Errorf("compilationcache: error reading header: %v", err)
 	}
 
 	// Check the version compatibility.
@@ -140,13 +158,14 @@ func deserializeCodes(wazeroVersion string, reader io.Reader) (codes []*code, st
 		return
 	}
 
+	ensureTermination := header[cachedVersionEnd] != 0
 	functionsNum := binary.LittleEndian.Uint32(header[len(header)-4:])
 	codes = make([]*code, 0, functionsNum)
 
 	var eightBytes [8]byte
 	var nativeCodeLen uint64
 	for i := uint32(0); i < functionsNum; i++ {
-		c := &code{}
+		c := &code{ensureTermination: ensureTermination}
 
 		// Read the stack pointer ceil.
 		if c.stackPointerCeil, err = readUint64(reader, &eightBytes); err != nil {
@@ -184,11 +203,10 @@ func deserializeCodes(wazeroVersion string, reader io.Reader) (codes []*code, st
 // given array as a buffer. This returns io.EOF if less than 8 bytes were read.
 func readUint64(reader io.Reader, b *[8]byte) (uint64, error) {
 	s := b[0:8]
-	n, err := reader.Read(s)
-	if err != nil {
-		return 0, err
-	} else if n < 8 { // more strict than reader.Read
+	if _, err := io.ReadFull(reader, s); errors.Is(err, io.ErrUnexpectedEOF) {
 		return 0, io.EOF
+	} else if err != nil {
+		return 0, err
 	}
 
 	// read the u64 from the underlying buffer
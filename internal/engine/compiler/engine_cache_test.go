@@ -37,6 +37,7 @@ func TestSerializeCodes(t *testing.T) {
 				[]byte(wazeroMagic),
 				[]byte{byte(len(testVersion))},
 				[]byte(testVersion),
+				[]byte{0},             // ensureTermination.
 				u32.LeBytes(1),        // number of functions.
 				u64.LeBytes(12345),    // stack pointer ceil.
 				u64.LeBytes(5),        // length of code.
@@ -52,6 +53,7 @@ func TestSerializeCodes(t *testing.T) {
 				[]byte(wazeroMagic),
 				[]byte{byte(len(testVersion))},
 				[]byte(testVersion),
+				[]byte{0},      // ensureTermination.
 				u32.LeBytes(2), // number of functions.
 				// Function index = 0.
 				u64.LeBytes(12345),    // stack pointer ceil.
@@ -63,6 +65,19 @@ func TestSerializeCodes(t *testing.T) {
 				[]byte{1, 2, 3},         // code.
 			),
 		},
+		{
+			in: []*code{{stackPointerCeil: 12345, codeSegment: []byte{1, 2, 3, 4, 5}, ensureTermination: true}},
+			exp: concat(
+				[]byte(wazeroMagic),
+				[]byte{byte(len(testVersion))},
+				[]byte(testVersion),
+				[]byte{1},             // ensureTermination.
+				u32.LeBytes(1),        // number of functions.
+				u64.LeBytes(12345),    // stack pointer ceil.
+				u64.LeBytes(5),        // length of code.
+				[]byte{1, 2, 3, 4, 5}, // code.
+			),
+		},
 	}
 
 	for i, tc := range tests {
@@ -91,6 +106,7 @@ func TestDeserializeCodes(t *testing.T) {
 				[]byte(wazeroMagic),
 				[]byte{byte(len("1233123.1.1"))},
 				[]byte("1233123.1.1"),
+				[]byte{0},      // ensureTermination.
 				u32.LeBytes(1), // number of functions.
 			),
 			expStaleCache: true,
@@ -101,6 +117,7 @@ func TestDeserializeCodes(t *testing.T) {
 				[]byte(wazeroMagic),
 				[]byte{byte(len("1"))},
 				[]byte("1"),
+				[]byte{0},      // ensureTermination.
 				u32.LeBytes(1), // number of functions.
 			),
 			expStaleCache: true,
@@ -111,6 +128,7 @@ func TestDeserializeCodes(t *testing.T) {
 				[]byte(wazeroMagic),
 				[]byte{byte(len(testVersion))},
 				[]byte(testVersion),
+				[]byte{0},             // ensureTermination.
 				u32.LeBytes(1),        // number of functions.
 				u64.LeBytes(12345),    // stack pointer ceil.
 				u64.LeBytes(5),        // length of code.
@@ -122,12 +140,31 @@ func TestDeserializeCodes(t *testing.T) {
 			expStaleCache: false,
 			expErr:        "",
 		},
+		{
+			name: "one function with ensureTermination",
+			in: concat(
+				[]byte(wazeroMagic),
+				[]byte{byte(len(testVersion))},
+				[]byte(testVersion),
+				[]byte{1},             // ensureTermination.
+				u32.LeBytes(1),        // number of functions.
+				u64.LeBytes(12345),    // stack pointer ceil.
+				u64.LeBytes(5),        // length of code.
+				[]byte{1, 2, 3, 4, 5}, // code.
+			),
+			expCodes: []*code{
+				{stackPointerCeil: 12345, codeSegment: []byte{1, 2, 3, 4, 5}, ensureTermination: true},
+			},
+			expStaleCache: false,
+			expErr:        "",
+		},
 		{
 			name: "two functions",
 			in: concat(
 				[]byte(wazeroMagic),
 				[]byte{byte(len(testVersion))},
 				[]byte(testVersion),
+				[]byte{0},      // ensureTermination.
 				u32.LeBytes(2), // number of functions.
 				// Function index = 0.
 				u64.LeBytes(12345),    // stack pointer ceil.
@@ -151,6 +188,7 @@ func TestDeserializeCodes(t *testing.T) {
 				[]byte(wazeroMagic),
 				[]byte{byte(len(testVersion))},
 				[]byte(testVersion),
+				[]byte{0},      // ensureTermination.
 				u32.LeBytes(2), // number of functions.
 				// Function index = 0.
 				u64.LeBytes(12345),    // stack pointer ceil.
@@ -166,6 +204,7 @@ func TestDeserializeCodes(t *testing.T) {
 				[]byte(wazeroMagic),
 				[]byte{byte(len(testVersion))},
 				[]byte(testVersion),
+				[]byte{0},      // ensureTermination.
 				u32.LeBytes(2), // number of functions.
 				// Function index = 0.
 				u64.LeBytes(12345),    // stack pointer ceil.
@@ -182,6 +221,7 @@ func TestDeserializeCodes(t *testing.T) {
 				[]byte(wazeroMagic),
 				[]byte{byte(len(testVersion))},
 				[]byte(testVersion),
+				[]byte{0},      // ensureTermination.
 				u32.LeBytes(2), // number of functions.
 				// Function index = 0.
 				u64.LeBytes(12345),    // stack pointer ceil.
@@ -217,6 +257,7 @@ func TestEngine_getCodesFromCache(t *testing.T) {
 		[]byte(wazeroMagic),
 		[]byte{byte(len(testVersion))},
 		[]byte(testVersion),
+		[]byte{0},      // ensureTermination.
 		u32.LeBytes(2), // number of functions.
 		// Function index = 0.
 		u64.LeBytes(12345),    // stack pointer ceil.
@@ -264,6 +305,7 @@ func TestEngine_getCodesFromCache(t *testing.T) {
 				[]byte(wazeroMagic),
 				[]byte{byte(len("1233123.1.1"))},
 				[]byte("1233123.1.1"),
+				[]byte{0},      // ensureTermination.
 				u32.LeBytes(1), // number of functions.
 			)}},
 			expDeleted: true,
@@ -343,6 +385,7 @@ func TestEngine_addCodesToCache(t *testing.T) {
 			[]byte(wazeroMagic),
 			[]byte{byte(len(testVersion))},
 			[]byte(testVersion),
+			[]byte{0},        // ensureTermination.
 			u32.LeBytes(1),   // number of functions.
 			u64.LeBytes(123), // stack pointer ceil.
 			u64.LeBytes(3),   // length of code.
@@ -385,6 +428,11 @@ func Test_readUint64(t *testing.T) {
 			// ensure the buffer was cleared
 			var expectedB [8]byte
 			require.Equal(t, expectedB, b)
+
+			// short reads, e.g. when decompressing, are read fully
+			n, err = readUint64(iotest.OneByteReader(bytes.NewReader(input)), &b)
+			require.NoError(t, err)
+			require.Equal(t, tc.input, n)
 		})
 	}
 }
@@ -12,6 +12,7 @@ import (
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/experimental"
 	"github.com/tetratelabs/wazero/experimental/logging"
+	"github.com/tetratelabs/wazero/internal/gdbjit"
 	"github.com/tetratelabs/wazero/internal/platform"
 	"github.com/tetratelabs/wazero/internal/testing/enginetest"
 	"github.com/tetratelabs/wazero/internal/testing/require"
@@ -84,22 +85,21 @@ func TestCompiler_ModuleEngine_Call_Errors(t *testing.T) {
 	requireSupportedOSArch(t)
 	enginetest.RunTestModuleEngine_Call_Errors(t, et)
 
-	// TODO: Currently, the listener doesn't get notified on errors as they are
-	// implemented with panic. This means the end hooks aren't make resulting
-	// in dangling logs like this:
-	//	==> host.host_div_by(-1)
-	// instead of seeing a return like
-	//	<== DivByZero
+	// Frames unwound by errors are logged by Abort, so there are no dangling
+	// logs even though errors are implemented with panic.
 	require.Equal(t, `
 --> imported.div_by.wasm(1)
 <-- 1
 --> imported.div_by.wasm(1)
 <-- 1
 --> imported.div_by.wasm(0)
+<-- error: wasm error: integer divide by zero
 --> imported.div_by.wasm(1)
 <-- 1
 --> imported.call->div_by.go(-1)
 	==> host.div_by.go(-1)
+	<== error: host-function panic (recovered by wazero)
+<-- error: host-function panic (recovered by wazero)
 --> imported.call->div_by.go(1)
 	==> host.div_by.go(1)
 	<== 1
@@ -107,6 +107,9 @@ func TestCompiler_ModuleEngine_Call_Errors(t *testing.T) {
 --> importing.call_import->call->div_by.go(0)
 	--> imported.call->div_by.go(0)
 		==> host.div_by.go(0)
+		<== error: runtime error: integer divide by zero (recovered by wazero)
+	<-- error: runtime error: integer divide by zero (recovered by wazero)
+<-- error: runtime error: integer divide by zero (recovered by wazero)
 --> importing.call_import->call->div_by.go(1)
 	--> imported.call->div_by.go(1)
 		==> host.div_by.go(1)
@@ -116,6 +119,9 @@ func TestCompiler_ModuleEngine_Call_Errors(t *testing.T) {
 --> importing.call_import->call->div_by.go(-1)
 	--> imported.call->div_by.go(-1)
 		==> host.div_by.go(-1)
+		<== error: host-function panic (recovered by wazero)
+	<-- error: host-function panic (recovered by wazero)
+<-- error: host-function panic (recovered by wazero)
 --> importing.call_import->call->div_by.go(1)
 	--> imported.call->div_by.go(1)
 		==> host.div_by.go(1)
@@ -125,6 +131,9 @@ func TestCompiler_ModuleEngine_Call_Errors(t *testing.T) {
 --> importing.call_import->call->div_by.go(0)
 	--> imported.call->div_by.go(0)
 		==> host.div_by.go(0)
+		<== error: runtime error: integer divide by zero (recovered by wazero)
+	<-- error: runtime error: integer divide by zero (recovered by wazero)
+<-- error: runtime error: integer divide by zero (recovered by wazero)
 --> importing.call_import->call->div_by.go(1)
 	--> imported.call->div_by.go(1)
 		==> host.div_by.go(1)
@@ -175,11 +184,11 @@ func TestCompiler_CompileModule(t *testing.T) {
 			ID: wasm.ModuleID{},
 		}
 
-		err := e.CompileModule(testCtx, okModule, nil)
+		err := e.CompileModule(testCtx, okModule, nil, false)
 		require.NoError(t, err)
 
 		// Compiling same module shouldn't be compiled again, but instead should be cached.
-		err = e.CompileModule(testCtx, okModule, nil)
+		err = e.CompileModule(testCtx, okModule, nil, false)
 		require.NoError(t, err)
 
 		compiled, ok := e.codes[okModule.ID]
@@ -206,7 +215,7 @@ func TestCompiler_CompileModule(t *testing.T) {
 		errModule.BuildFunctionDefinitions()
 
 		e := et.NewEngine(api.CoreFeaturesV1).(*engine)
-		err := e.CompileModule(testCtx, errModule, nil)
+		err := e.CompileModule(testCtx, errModule, nil, false)
 		require.EqualError(t, err, "failed to lower func[.$2] to wazeroir: handling instruction: apply stack failed for call: reading immediates: EOF")
 
 		// On the compilation failure, the compiled functions must not be cached.
@@ -215,6 +224,57 @@ func TestCompiler_CompileModule(t *testing.T) {
 	})
 }
 
+func TestCompiler_CompileModule_gdbJIT(t *testing.T) {
+	requireSupportedOSArch(t)
+	ctx := context.WithValue(testCtx, gdbjit.EnabledKey{}, true)
+	e := newEngine(ctx, api.CoreFeaturesV1)
+	ff := fakeFinalizer{}
+	e.setFinalizer = ff.setFinalizer
+
+	m := &wasm.Module{
+		TypeSection:     []*wasm.FunctionType{{}},
+		FunctionSection: []wasm.Index{0, 0},
+		CodeSection:     []*wasm.Code{{Body: []byte{wasm.OpcodeEnd}}, {Body: []byte{wasm.OpcodeEnd}}},
+		NameSection:     &wasm.NameSection{ModuleName: "test"},
+	}
+	m.BuildFunctionDefinitions()
+
+	require.NoError(t, e.CompileModule(testCtx, m, nil, false))
+	codes := e.codes[m.ID]
+	for _, c := range codes {
+		require.NotNil(t, c.gdbJITEntry)
+	}
+
+	// The finalizer unregisters the code before releasing it.
+	for k, v := range ff {
+		v(k)
+	}
+	for _, c := range codes {
+		require.Nil(t, c.gdbJITEntry)
+	}
+}
+
+func TestNativeCode(t *testing.T) {
+	requireSupportedOSArch(t)
+
+	m := &wasm.Module{
+		TypeSection:     []*wasm.FunctionType{{}},
+		FunctionSection: []wasm.Index{0, 0},
+		CodeSection: []*wasm.Code{
+			{Body: []byte{wasm.OpcodeEnd}},
+			{Body: []byte{wasm.OpcodeCall, 0, wasm.OpcodeEnd}},
+		},
+	}
+	m.BuildFunctionDefinitions()
+
+	code, err := NativeCode(testCtx, api.CoreFeaturesV1, m)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(code))
+	// The function which calls the other has more code.
+	require.True(t, len(code[0]) > 0)
+	require.True(t, len(code[1]) > len(code[0]))
+}
+
 // TestCompiler_Releasecode_Panic tests that an unexpected panic has some identifying information in it.
 func TestCompiler_Releasecode_Panic(t *testing.T) {
 	captured := require.CapturePanic(func() {
@@ -256,7 +316,7 @@ func TestCompiler_SliceAllocatedOnHeap(t *testing.T) {
 	}}, map[string]*wasm.HostFuncNames{hostFnName: {}}, enabledFeatures)
 	require.NoError(t, err)
 
-	err = s.Engine.CompileModule(testCtx, hm, nil)
+	err = s.Engine.CompileModule(testCtx, hm, nil, false)
 	require.NoError(t, err)
 
 	_, err = s.Instantiate(testCtx, ns, hm, hostModuleName, nil)
@@ -312,7 +372,7 @@ func TestCompiler_SliceAllocatedOnHeap(t *testing.T) {
 	}
 	m.BuildFunctionDefinitions()
 
-	err = s.Engine.CompileModule(testCtx, m, nil)
+	err = s.Engine.CompileModule(testCtx, m, nil, false)
 	require.NoError(t, err)
 
 	mi, err := s.Instantiate(testCtx, ns, m, t.Name(), nil)
@@ -400,7 +460,7 @@ func TestCallEngine_deferredOnCall(t *testing.T) {
 
 	beforeRecoverStack := ce.stack
 
-	err := ce.deferredOnCall(errors.New("some error"))
+	err := ce.deferredOnCall(testCtx, &wasm.CallContext{}, errors.New("some error"))
 	require.EqualError(t, err, `some error (recovered by wazero)
 wasm stack trace:
 	3()
@@ -421,6 +481,82 @@ wasm stack trace:
 	runtime.KeepAlive(f3)
 }
 
+func TestCallEngine_deferredOnCall_abort(t *testing.T) {
+	type key struct{}
+	callCtx, ctx1, ctx2 := context.Background(), context.WithValue(context.Background(), key{}, 1), context.WithValue(context.Background(), key{}, 2)
+
+	var aborted []string
+	var abortedCtxs []context.Context
+	lsn := mockListener{
+		abort: func(ctx context.Context, def api.FunctionDefinition, err error) {
+			require.EqualError(t, err, "some error (recovered by wazero)\nwasm stack trace:\n\t2()")
+			aborted = append(aborted, def.DebugName())
+			abortedCtxs = append(abortedCtxs, ctx)
+		},
+	}
+	f1 := &function{source: &wasm.FunctionInstance{Definition: newMockFunctionDefinition("1")}, parent: &code{listener: lsn}}
+	f2 := &function{
+		source: &wasm.FunctionInstance{Definition: newMockFunctionDefinition("2"), Type: &wasm.FunctionType{}},
+		parent: &code{sourceModule: &wasm.Module{}, listener: lsn},
+	}
+
+	ce := &callEngine{
+		ctx:           ctx2,
+		stack:         []uint64{0, 0, 0},
+		moduleContext: moduleContext{fn: f2},
+		// f1 called f2, and both listeners returned a new context.
+		contextStack: &contextStack{self: ctx1, fn: f2, prev: &contextStack{self: callCtx, fn: f1}},
+	}
+
+	_ = ce.deferredOnCall(testCtx, &wasm.CallContext{}, errors.New("some error"))
+
+	// Listeners must be notified from the innermost, with the context they returned.
+	require.Equal(t, []string{"2", "1"}, aborted)
+	require.Equal(t, []context.Context{ctx2, ctx1}, abortedCtxs)
+	require.Nil(t, ce.contextStack)
+}
+
+func TestStackIterator(t *testing.T) {
+	f1 := &function{source: &wasm.FunctionInstance{
+		Definition: newMockFunctionDefinition("1"),
+		Type:       &wasm.FunctionType{ParamNumInUint64: 2},
+	}}
+	f2 := &function{source: &wasm.FunctionInstance{
+		Definition: newMockFunctionDefinition("2"),
+		Type:       &wasm.FunctionType{ParamNumInUint64: 2, ResultNumInUint64: 3},
+	}}
+	f3 := &function{source: &wasm.FunctionInstance{
+		Definition: newMockFunctionDefinition("3"),
+		Type:       &wasm.FunctionType{ResultNumInUint64: 1},
+	}}
+
+	stack := []uint64{
+		0xff, 0xff, // dummy argument for f1
+		0, 0, 0, 0,
+		0xcc, 0xcc, // local variable for f1.
+		// <----- stack base point of f2 (top) == index 8.
+		0xaa, 0xaa, 0xdeadbeaf, // dummy argument for f2 (0xaa, 0xaa) and the reserved slot for result 0xdeadbeaf)
+		0, 0, ptrAsUint64(f1), 0, // callFrame
+		0xcc, 0xcc, 0xcc, // local variable for f2.
+		// <----- stack base point of f3 (top) == index 18
+		0xdeadbeaf,                    // the reserved slot for result 0xdeadbeaf) from f3.
+		0, 8 << 3, ptrAsUint64(f2), 0, // callFrame
+	}
+
+	var si stackIterator
+	si.reset(stack, f3, 0, 18)
+	var names []string
+	for si.Next() {
+		names = append(names, si.FunctionDefinition().DebugName())
+	}
+	require.Equal(t, []string{"3", "2", "1"}, names)
+
+	// Keep f1, f2, and f3 alive as we access them from the uint64 raw pointers in the stack.
+	runtime.KeepAlive(f1)
+	runtime.KeepAlive(f2)
+	runtime.KeepAlive(f3)
+}
+
 func newMockFunctionDefinition(name string) api.FunctionDefinition {
 	return &mockFunctionDefinition{debugName: name, FunctionDefinition: &wasm.FunctionDefinition{}}
 }
@@ -590,7 +726,7 @@ func TestCallEngine_builtinFunctionFunctionListenerBefore(t *testing.T) {
 		stackContext: stackContext{stackBasePointerInBytes: 16},
 		contextStack: &contextStack{self: prevContext},
 	}
-	ce.builtinFunctionFunctionListenerBefore(ce.ctx, f)
+	ce.builtinFunctionFunctionListenerBefore(ce.ctx, &wasm.CallContext{}, f)
 
 	// Contexts must be stacked.
 	require.Equal(t, currentContext, ce.contextStack.self)
@@ -606,7 +742,7 @@ func TestCallEngine_builtinFunctionFunctionListenerAfter(t *testing.T) {
 		},
 		parent: &code{
 			listener: mockListener{
-				after: func(ctx context.Context, def api.FunctionDefinition, err error, resultValues []uint64) {
+				after: func(ctx context.Context, def api.FunctionDefinition, resultValues []uint64) {
 					require.Equal(t, currentContext, ctx)
 					require.Equal(t, []uint64{5}, resultValues)
 				},
@@ -619,7 +755,7 @@ func TestCallEngine_builtinFunctionFunctionListenerAfter(t *testing.T) {
 		stackContext: stackContext{stackBasePointerInBytes: 40},
 		contextStack: &contextStack{self: prevContext},
 	}
-	ce.builtinFunctionFunctionListenerAfter(ce.ctx, f)
+	ce.builtinFunctionFunctionListenerAfter(ce.ctx, &wasm.CallContext{}, f)
 
 	// Contexts must be popped.
 	require.Nil(t, ce.contextStack)
@@ -628,15 +764,20 @@ func TestCallEngine_builtinFunctionFunctionListenerAfter(t *testing.T) {
 
 type mockListener struct {
 	before func(ctx context.Context, def api.FunctionDefinition, paramValues []uint64) context.Context
-	after  func(ctx context.Context, def api.FunctionDefinition, err error, resultValues []uint64)
+	after  func(ctx context.Context, def api.FunctionDefinition, resultValues []uint64)
+	abort  func(ctx context.Context, def api.FunctionDefinition, err error)
 }
 
-func (m mockListener) Before(ctx context.Context, def api.FunctionDefinition, paramValues []uint64) context.Context {
+func (m mockListener) Before(ctx context.Context, _ api.Module, def api.FunctionDefinition, paramValues []uint64, _ experimental.StackIterator) context.Context {
 	return m.before(ctx, def, paramValues)
 }
 
-func (m mockListener) After(ctx context.Context, def api.FunctionDefinition, err error, resultValues []uint64) {
-	m.after(ctx, def, err, resultValues)
+func (m mockListener) After(ctx context.Context, _ api.Module, def api.FunctionDefinition, resultValues []uint64) {
+	m.after(ctx, def, resultValues)
+}
+
+func (m mockListener) Abort(ctx context.Context, _ api.Module, def api.FunctionDefinition, err error) {
+	m.abort(ctx, def, err)
 }
 
 func TestFunction_getSourceOffsetInWasmBinary(t *testing.T) {
@@ -3437,7 +3437,7 @@ func (c *amd64Compiler) compileMemoryGrow() error {
 }
 
 // compileMemorySize implements compiler.compileMemorySize for the amd64 architecture.
-func (c *amd64Compiler) compileMemorySize() error {
+func (c *amd64Compiler) compileMemorySize(o *wazeroir.OperationMemorySize) error {
 	if err := c.maybeCompileMoveTopConditionalToGeneralPurposeRegister(); err != nil {
 		return err
 	}
@@ -3452,8 +3452,11 @@ func (c *amd64Compiler) compileMemorySize() error {
 
 	// WebAssembly's memory.size returns the page size (65536) of memory region.
 	// That is equivalent to divide the len of memory slice by 65536 and
-	// that can be calculated as SHR by 16 bits as 65536 = 2^16.
-	c.assembler.CompileConstToRegister(amd64.SHRQ, wasm.MemoryPageSizeInBits, loc.register)
+	// that can be calculated as SHR by 16 bits as 65536 = 2^16. With a custom
+	// page size of 1 byte, the len is the page size.
+	if o.PageSizeInBits != 0 {
+		c.assembler.CompileConstToRegister(amd64.SHRQ, int64(o.PageSizeInBits), loc.register)
+	}
 	return nil
 }
 
@@ -4192,6 +4195,42 @@ func (c *amd64Compiler) compileTableGrow(o *wazeroir.OperationTableGrow) error {
 	return nil
 }
 
+// compileBuiltinFunctionCheckExitCode implements compiler.compileBuiltinFunctionCheckExitCode for the amd64 architecture.
+func (c *amd64Compiler) compileBuiltinFunctionCheckExitCode() error {
+	if err := c.compileCallBuiltinFunction(builtinFunctionIndexCheckExitCode); err != nil {
+		return err
+	}
+
+	// After return, we re-initialize reserved registers just like preamble of functions.
+	c.compileReservedStackBasePointerInitialization()
+	c.compileReservedMemoryPointerInitialization()
+	return nil
+}
+
+// compileBuiltinFunctionConsumeFuel implements compiler.compileBuiltinFunctionConsumeFuel for the amd64 architecture.
+func (c *amd64Compiler) compileBuiltinFunctionConsumeFuel() error {
+	if err := c.compileCallBuiltinFunction(builtinFunctionIndexConsumeFuel); err != nil {
+		return err
+	}
+
+	// After return, we re-initialize reserved registers just like preamble of functions.
+	c.compileReservedStackBasePointerInitialization()
+	c.compileReservedMemoryPointerInitialization()
+	return nil
+}
+
+// compileBuiltinFunctionMemoryAccess implements compiler.compileBuiltinFunctionMemoryAccess for the amd64 architecture.
+func (c *amd64Compiler) compileBuiltinFunctionMemoryAccess(index wasm.Index) error {
+	if err := c.compileCallBuiltinFunction(index); err != nil {
+		return err
+	}
+
+	// After return, we re-initialize reserved registers just like preamble of functions.
+	c.compileReservedStackBasePointerInitialization()
+	c.compileReservedMemoryPointerInitialization()
+	return nil
+}
+
 // compileTableSize implements compiler.compileTableSize for the amd64 architecture.
 func (c *amd64Compiler) compileTableSize(o *wazeroir.OperationTableSize) error {
 	if err := c.maybeCompileMoveTopConditionalToGeneralPurposeRegister(); err != nil {
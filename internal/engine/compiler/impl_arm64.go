@@ -2754,7 +2754,7 @@ func (c *arm64Compiler) compileMemoryGrow() error {
 }
 
 // compileMemorySize implements compileMemorySize variants for arm64 architecture.
-func (c *arm64Compiler) compileMemorySize() error {
+func (c *arm64Compiler) compileMemorySize(o *wazeroir.OperationMemorySize) error {
 	if err := c.maybeCompileMoveTopConditionalToGeneralPurposeRegister(); err != nil {
 		return err
 	}
@@ -2772,12 +2772,14 @@ func (c *arm64Compiler) compileMemorySize() error {
 	)
 
 	// memory.size loads the page size of memory, so we have to divide by the page size.
-	// "reg = reg >> wasm.MemoryPageSizeInBits (== reg / wasm.MemoryPageSize) "
-	c.assembler.CompileConstToRegister(
-		arm64.LSR,
-		wasm.MemoryPageSizeInBits,
-		reg,
-	)
+	// "reg = reg >> o.PageSizeInBits (== reg / wasm.MemoryPageSize, unless the page size is custom) "
+	if o.PageSizeInBits != 0 {
+		c.assembler.CompileConstToRegister(
+			arm64.LSR,
+			int64(o.PageSizeInBits),
+			reg,
+		)
+	}
 
 	c.pushRuntimeValueLocationOnRegister(reg, runtimeValueTypeI32)
 	return nil
@@ -3754,6 +3756,42 @@ func (c *arm64Compiler) compileTableGrow(o *wazeroir.OperationTableGrow) error {
 	return nil
 }
 
+// compileBuiltinFunctionCheckExitCode implements compiler.compileBuiltinFunctionCheckExitCode for the arm64 architecture.
+func (c *arm64Compiler) compileBuiltinFunctionCheckExitCode() error {
+	if err := c.compileCallGoFunction(nativeCallStatusCodeCallBuiltInFunction, builtinFunctionIndexCheckExitCode); err != nil {
+		return err
+	}
+
+	// After return, we re-initialize reserved registers just like preamble of functions.
+	c.compileReservedStackBasePointerRegisterInitialization()
+	c.compileReservedMemoryRegisterInitialization()
+	return nil
+}
+
+// compileBuiltinFunctionConsumeFuel implements compiler.compileBuiltinFunctionConsumeFuel for the arm64 architecture.
+func (c *arm64Compiler) compileBuiltinFunctionConsumeFuel() error {
+	if err := c.compileCallGoFunction(nativeCallStatusCodeCallBuiltInFunction, builtinFunctionIndexConsumeFuel); err != nil {
+		return err
+	}
+
+	// After return, we re-initialize reserved registers just like preamble of functions.
+	c.compileReservedStackBasePointerRegisterInitialization()
+	c.compileReservedMemoryRegisterInitialization()
+	return nil
+}
+
+// compileBuiltinFunctionMemoryAccess implements compiler.compileBuiltinFunctionMemoryAccess for the arm64 architecture.
+func (c *arm64Compiler) compileBuiltinFunctionMemoryAccess(index wasm.Index) error {
+	if err := c.compileCallGoFunction(nativeCallStatusCodeCallBuiltInFunction, index); err != nil {
+		return err
+	}
+
+	// After return, we re-initialize reserved registers just like preamble of functions.
+	c.compileReservedStackBasePointerRegisterInitialization()
+	c.compileReservedMemoryRegisterInitialization()
+	return nil
+}
+
 // compileTableSize implements compiler.compileTableSize for the arm64 architecture.
 func (c *arm64Compiler) compileTableSize(o *wazeroir.OperationTableSize) error {
 	if err := c.maybeCompileMoveTopConditionalToGeneralPurposeRegister(); err != nil {
@@ -95,10 +95,18 @@ type callEngine struct {
 	compiled *function
 	// source is the FunctionInstance from which compiled is created from.
 	source *wasm.FunctionInstance
+
+	// stackIterator is passed to function listeners, and reset before each
+	// call to experimental.FunctionListener Before.
+	stackIterator stackIterator
+
+	// trapHandler is invoked when a function traps, when not nil. This is
+	// the same for all functions called, as they're in the same runtime.
+	trapHandler experimental.TrapHandler
 }
 
 func (e *moduleEngine) newCallEngine(source *wasm.FunctionInstance, compiled *function) *callEngine {
-	return &callEngine{source: source, compiled: compiled}
+	return &callEngine{source: source, compiled: compiled, trapHandler: compiled.parent.trapHandler}
 }
 
 func (ce *callEngine) pushValue(v uint64) {
@@ -166,6 +174,50 @@ type callFrame struct {
 	pc uint64
 	// f is the compiled function used in this function frame.
 	f *function
+	// ctx is the context.Context returned by the function listener of f, if
+	// any. This is passed to its Abort hook if the frame is unwound.
+	ctx context.Context
+	// height is the height of the stack when f was called, so its parameters
+	// are below it, followed by its locals. This is only set for functions
+	// which aren't Go functions.
+	height int
+	// opHeight is the height of the stack before the operation at pc, so
+	// that the operands it popped can be restored to resume it after a trap.
+	// This is only set when callEngine.trapHandler is not nil.
+	opHeight int
+}
+
+// stackIterator implements experimental.StackIterator.
+type stackIterator struct {
+	// frames are the callers of fn, the outermost first.
+	frames []*callFrame
+	// fn is the current function, or the function called before the first
+	// call to Next.
+	fn      *function
+	started bool
+}
+
+func (si *stackIterator) reset(frames []*callFrame, f *function) {
+	si.frames, si.fn, si.started = frames, f, false
+}
+
+// Next implements the same method as documented on experimental.StackIterator.
+func (si *stackIterator) Next() bool {
+	if !si.started {
+		si.started = true
+		return true
+	}
+	last := len(si.frames) - 1
+	if last < 0 {
+		return false
+	}
+	si.fn, si.frames = si.frames[last].f, si.frames[:last]
+	return true
+}
+
+// FunctionDefinition implements the same method as documented on experimental.StackIterator.
+func (si *stackIterator) FunctionDefinition() api.FunctionDefinition {
+	return si.fn.source.Definition
 }
 
 type code struct {
@@ -173,6 +225,16 @@ type code struct {
 	body     []*interpreterOp
 	listener experimental.FunctionListener
 	hostFn   interface{}
+	// ensureTermination is true if this code was compiled with ensureTermination.
+	// See the doc on wasm.Engine CompileModule.
+	ensureTermination bool
+	// memoryListener is notified of loads and stores which overlap any of
+	// watchRegions, when not nil. See wasm.Module MemoryListener.
+	memoryListener experimental.MemoryListener
+	watchRegions   []experimental.WatchRegion
+	// trapHandler is invoked when a function traps, when not nil. See
+	// wasm.Module TrapHandler.
+	trapHandler experimental.TrapHandler
 }
 
 type function struct {
@@ -224,16 +286,20 @@ type interpreterOp struct {
 const callFrameStackSize = 0
 
 // CompileModule implements the same method as documented on wasm.Engine.
-func (e *engine) CompileModule(ctx context.Context, module *wasm.Module, listeners []experimental.FunctionListener) error {
+func (e *engine) CompileModule(ctx context.Context, module *wasm.Module, listeners []experimental.FunctionListener, ensureTermination bool) error {
 	if _, ok := e.getCodes(module); ok { // cache hit!
 		return nil
 	}
 
 	funcs := make([]*code, len(module.FunctionSection))
-	irs, err := wazeroir.CompileFunctions(ctx, e.enabledFeatures, callFrameStackSize, module)
+	irs, err := wazeroir.CompileFunctions(ctx, e.enabledFeatures, callFrameStackSize, module, ensureTermination)
 	if err != nil {
 		return err
 	}
+	var watchRegions []experimental.WatchRegion
+	if ml := module.MemoryListener; ml != nil {
+		watchRegions = ml.WatchRegions()
+	}
 	for i, ir := range irs {
 		var lsn experimental.FunctionListener
 		if i < len(listeners) {
@@ -255,6 +321,10 @@ func (e *engine) CompileModule(ctx context.Context, module *wasm.Module, listene
 			compiled.listener = lsn
 		}
 		compiled.source = module
+		compiled.ensureTermination = ensureTermination
+		compiled.memoryListener = module.MemoryListener
+		compiled.watchRegions = watchRegions
+		compiled.trapHandler = module.TrapHandler
 		funcs[i] = compiled
 	}
 	e.addCodes(module, funcs)
@@ -717,6 +787,17 @@ func (e *engine) lowerIR(ir *wazeroir.CompilationResult) (*code, error) {
 		case *wazeroir.OperationV128ITruncSatFromF:
 			op.b1 = o.OriginShape
 			op.b3 = o.Signed
+		case *wazeroir.OperationBuiltinFunctionCheckExitCode:
+		case *wazeroir.OperationBuiltinFunctionConsumeFuel:
+		case *wazeroir.OperationBuiltinFunctionMemoryAccess:
+			op.b3 = o.Store
+			if o.Bulk {
+				op.b1 = 1
+			}
+			op.us = make([]uint64, 3)
+			op.us[0] = uint64(o.Arg.Offset)
+			op.us[1] = uint64(o.Size)
+			op.us[2] = uint64(o.Depth)
 		default:
 			panic(fmt.Errorf("BUG: unimplemented operation %s", op.kind.String()))
 		}
@@ -806,6 +887,11 @@ func (ce *callEngine) call(ctx context.Context, m *wasm.CallContext, tf *functio
 		return nil, fmt.Errorf("expected %d params, but passed %d", paramSignature, paramCount)
 	}
 
+	if tf.parent.ensureTermination && ctx.Done() != nil {
+		done := m.CloseModuleOnCanceledOrTimeout(ctx)
+		defer done()
+	}
+
 	defer func() {
 		// If the module closed during the call, and the call didn't err for another reason, set an ExitError.
 		if err == nil {
@@ -814,7 +900,10 @@ func (ce *callEngine) call(ctx context.Context, m *wasm.CallContext, tf *functio
 		// TODO: ^^ Will not fail if the function was imported from a closed module.
 
 		if v := recover(); v != nil {
-			err = ce.recoverOnCall(v)
+			err = ce.recoverOnCall(ctx, m, v)
+			if p, ok := v.(*wasm.HostFunctionPanic); ok {
+				panic(p.Recovered)
+			}
 		}
 	}()
 
@@ -832,21 +921,43 @@ func (ce *callEngine) call(ctx context.Context, m *wasm.CallContext, tf *functio
 }
 
 // recoverOnCall takes the recovered value `recoverOnCall`, and wraps it
-// with the call frame stack traces. Also, reset the state of callEngine
-// so that it can be used for the subsequent calls.
-func (ce *callEngine) recoverOnCall(v interface{}) (err error) {
+// with the call frame stack traces. This also notifies the function listeners
+// of unwound frames via Abort. Also, reset the state of callEngine so that it
+// can be used for the subsequent calls.
+func (ce *callEngine) recoverOnCall(ctx context.Context, m *wasm.CallContext, v interface{}) (err error) {
 	builder := wasmdebug.NewErrorBuilder()
 	frameCount := len(ce.frames)
-	for i := 0; i < frameCount; i++ {
-		frame := ce.popFrame()
+	handler := wasm.CoredumpHandler(ctx, v)
+	var coredump []wasm.CoredumpFrame
+	for i := frameCount - 1; i >= 0; i-- {
+		frame := ce.frames[i]
 		def := frame.f.source.Definition
+		var offset uint64
 		var sources []string
 		if frame.f.body != nil {
-			sources = frame.f.parent.source.DWARFLines.Line(frame.f.body[frame.pc].sourcePC)
+			offset = frame.f.body[frame.pc].sourcePC
+			sources = frame.f.parent.source.SourceLines(offset)
+		}
+		builder.AddFrame(def.DebugName(), def.Index(), offset, def.ParamTypes(), def.ResultTypes(), sources)
+		if handler != nil {
+			var locals []uint64
+			if base := frame.height - frame.f.source.Type.ParamNumInUint64; frame.f.body != nil && base <= len(ce.stack) {
+				locals = ce.stack[base:]
+			}
+			coredump = append(coredump, wasm.CoredumpFrame{Function: frame.f.source, Offset: offset, Locals: locals})
 		}
-		builder.AddFrame(def.DebugName(), def.ParamTypes(), def.ResultTypes(), sources)
 	}
 	err = builder.FromRecovered(v)
+	if handler != nil {
+		handler(ctx, wasm.Coredump(m.Name(), coredump))
+	}
+
+	for i := frameCount - 1; i >= 0; i-- {
+		frame := ce.popFrame()
+		if lsn := frame.f.parent.listener; lsn != nil {
+			lsn.Abort(frame.ctx, m, frame.f.source.Definition, err)
+		}
+	}
 
 	// Allows the reuse of CallEngine.
 	ce.stack, ce.frames = ce.stack[:0], ce.frames[:0]
@@ -867,29 +978,81 @@ func (ce *callEngine) callGoFunc(ctx context.Context, callCtx *wasm.CallContext,
 	lsn := f.parent.listener
 	if lsn != nil {
 		params := stack[:f.source.Type.ParamNumInUint64]
-		ctx = lsn.Before(ctx, f.source.Definition, params)
+		ce.stackIterator.reset(ce.frames, f)
+		ctx = lsn.Before(ctx, callCtx, f.source.Definition, params, &ce.stackIterator)
 	}
-	frame := &callFrame{f: f}
+	frame := &callFrame{f: f, ctx: ctx}
 	ce.pushFrame(frame)
 
-	fn := f.source.GoFunc
-	switch fn := fn.(type) {
-	case api.GoModuleFunction:
-		fn.Call(ctx, callCtx.WithMemory(ce.callerMemory()), stack)
-	case api.GoFunction:
-		fn.Call(ctx, stack)
-	}
+	wasm.CallGoFunc(ctx, callCtx, ce.callerMemory(), f.source, stack)
 
 	ce.popFrame()
 	if lsn != nil {
-		// TODO: This doesn't get the error due to use of panic to propagate them.
 		results := stack[:f.source.Type.ResultNumInUint64]
-		lsn.After(ctx, f.source.Definition, nil, results)
+		lsn.After(ctx, callCtx, f.source.Definition, results)
 	}
 }
 
 func (ce *callEngine) callNativeFunc(ctx context.Context, callCtx *wasm.CallContext, f *function) {
-	frame := &callFrame{f: f}
+	frame := &callFrame{f: f, ctx: ctx, height: len(ce.stack)}
+	ce.pushFrame(frame)
+	if ce.trapHandler != nil {
+		for !ce.runUntilTrap(ctx, callCtx, frame) {
+		}
+	} else {
+		ce.run(ctx, callCtx, frame)
+	}
+}
+
+// runUntilTrap runs the frame on the top of the stack, like run, except it
+// invokes the trap handler if the frame traps. This returns false if the
+// handler resumed it, so it must be run again from the operation which
+// trapped.
+func (ce *callEngine) runUntilTrap(ctx context.Context, callCtx *wasm.CallContext, frame *callFrame) (done bool) {
+	defer func() {
+		if v := recover(); v != nil && !ce.onTrap(ctx, frame, v) {
+			panic(v)
+		}
+	}()
+	ce.run(ctx, callCtx, frame)
+	return true
+}
+
+// onTrap invokes the trap handler if the recovered value is a trap of the
+// frame, as opposed to a trap already handled by a frame it called. This
+// returns true if the handler resumed it.
+func (ce *callEngine) onTrap(ctx context.Context, frame *callFrame, recovered interface{}) bool {
+	err, ok := recovered.(*wasmruntime.Error)
+	if !ok || ce.frames[len(ce.frames)-1] != frame {
+		return false
+	}
+
+	// Restores the operands of the operation, as popping only shrinks the
+	// stack, so they weren't overwritten.
+	ce.stack = ce.stack[:frame.opHeight]
+
+	f := frame.f
+	trap := &experimental.Trap{
+		Err:       err,
+		Module:    f.source.Module.CallCtx,
+		Function:  f.source.Definition,
+		Offset:    f.body[frame.pc].sourcePC,
+		Values:    ce.stack[frame.height-f.source.Type.ParamNumInUint64:],
+		Resumable: err != wasmruntime.ErrRuntimeStackOverflow,
+	}
+	for i := len(ce.frames) - 1; i >= 0; i-- {
+		trap.Stack = append(trap.Stack, ce.frames[i].f.source.Definition)
+	}
+	if !trap.Resumable {
+		trap.Values = nil
+	}
+	return ce.trapHandler(ctx, trap) == experimental.TrapResume && trap.Resumable
+}
+
+// run executes the frame on the top of the stack from its pc, then pops it.
+func (ce *callEngine) run(ctx context.Context, callCtx *wasm.CallContext, frame *callFrame) {
+	f := frame.f
+	trackHeight := ce.trapHandler != nil
 	moduleInst := f.source.Module
 	functions := moduleInst.Engine.(*moduleEngine).functions
 	var memoryInst *wasm.MemoryInstance
@@ -903,10 +1066,12 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, callCtx *wasm.CallCont
 	typeIDs := f.source.Module.TypeIDs
 	dataInstances := f.source.Module.DataInstances
 	elementInstances := f.source.Module.ElementInstances
-	ce.pushFrame(frame)
 	bodyLen := uint64(len(frame.f.body))
 	for frame.pc < bodyLen {
 		op := frame.f.body[frame.pc]
+		if trackHeight {
+			frame.opHeight = len(ce.stack)
+		}
 		// TODO: add description of each operation/case
 		// on, for example, how many args are used,
 		// how the stack is modified, etc.
@@ -4153,6 +4318,17 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, callCtx *wasm.CallCont
 			ce.pushValue(retLo)
 			ce.pushValue(retHi)
 			frame.pc++
+		case wazeroir.OperationKindBuiltinFunctionCheckExitCode:
+			if err := callCtx.FailIfClosed(); err != nil {
+				panic(err)
+			}
+			frame.pc++
+		case wazeroir.OperationKindBuiltinFunctionMemoryAccess:
+			ce.onMemoryAccess(ctx, callCtx, frame.f, op)
+			frame.pc++
+		case wazeroir.OperationKindBuiltinFunctionConsumeFuel:
+			wasm.ConsumeFuel(ctx)
+			frame.pc++
 		}
 	}
 	ce.popFrame()
@@ -4367,13 +4543,35 @@ func i32Abs(v uint32) uint32 {
 }
 
 func (ce *callEngine) callNativeFuncWithListener(ctx context.Context, callCtx *wasm.CallContext, f *function, fnl experimental.FunctionListener) context.Context {
-	ctx = fnl.Before(ctx, f.source.Definition, ce.peekValues(len(f.source.Type.Params)))
+	ce.stackIterator.reset(ce.frames, f)
+	ctx = fnl.Before(ctx, callCtx, f.source.Definition, ce.peekValues(len(f.source.Type.Params)), &ce.stackIterator)
 	ce.callNativeFunc(ctx, callCtx, f)
-	// TODO: This doesn't get the error due to use of panic to propagate them.
-	fnl.After(ctx, f.source.Definition, nil, ce.peekValues(len(f.source.Type.Results)))
+	fnl.After(ctx, callCtx, f.source.Definition, ce.peekValues(len(f.source.Type.Results)))
 	return ctx
 }
 
+// onMemoryAccess notifies the memory listener of f if the access by the next
+// operation overlaps any of its watch regions.
+func (ce *callEngine) onMemoryAccess(ctx context.Context, callCtx *wasm.CallContext, f *function, op *interpreterOp) {
+	// The address operand is an i32 below op.us[2] values on the stack.
+	address := uint32(ce.stack[len(ce.stack)-1-int(op.us[2])])
+	access := experimental.MemoryAccess{Offset: uint64(address) + op.us[0], Size: uint32(op.us[1]), Store: op.b3}
+	if op.b1 == 1 { // A bulk instruction, whose size is the top operand.
+		access.Size = uint32(ce.stack[len(ce.stack)-1])
+	}
+	if access.Store && f.source.Module.Memory.IsReadOnly(access.Offset, uint64(access.Size)) {
+		panic(wasmruntime.ErrRuntimeReadOnlyMemoryAccess)
+	} else if op.b1 == 1 {
+		return // Bulk instructions are only instrumented to protect memory.
+	}
+	for _, r := range f.parent.watchRegions {
+		if r.Overlaps(access.Offset, access.Size) {
+			f.parent.memoryListener.OnMemoryAccess(ctx, callCtx, f.source.Definition, access)
+			return
+		}
+	}
+}
+
 // popMemoryOffset takes a memory offset off the stack for use in load and store instructions.
 // As the top of stack value is 64-bit, this ensures it is in range before returning it.
 func (ce *callEngine) popMemoryOffset(op *interpreterOp) uint32 {
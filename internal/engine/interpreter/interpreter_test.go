@@ -121,22 +121,21 @@ func TestInterpreter_ModuleEngine_Call_Errors(t *testing.T) {
 	defer functionLog.Reset()
 	enginetest.RunTestModuleEngine_Call_Errors(t, et)
 
-	// TODO: Currently, the listener doesn't get notified on errors as they are
-	// implemented with panic. This means the end hooks aren't make resulting
-	// in dangling logs like this:
-	//	==> host.host_div_by(-1)
-	// instead of seeing a return like
-	//	<== DivByZero
+	// Frames unwound by errors are logged by Abort, so there are no dangling
+	// logs even though errors are implemented with panic.
 	require.Equal(t, `
 --> imported.div_by.wasm(1)
 <-- 1
 --> imported.div_by.wasm(1)
 <-- 1
 --> imported.div_by.wasm(0)
+<-- error: wasm error: integer divide by zero
 --> imported.div_by.wasm(1)
 <-- 1
 --> imported.call->div_by.go(-1)
 	==> host.div_by.go(-1)
+	<== error: host-function panic (recovered by wazero)
+<-- error: host-function panic (recovered by wazero)
 --> imported.call->div_by.go(1)
 	==> host.div_by.go(1)
 	<== 1
@@ -144,6 +143,9 @@ func TestInterpreter_ModuleEngine_Call_Errors(t *testing.T) {
 --> importing.call_import->call->div_by.go(0)
 	--> imported.call->div_by.go(0)
 		==> host.div_by.go(0)
+		<== error: runtime error: integer divide by zero (recovered by wazero)
+	<-- error: runtime error: integer divide by zero (recovered by wazero)
+<-- error: runtime error: integer divide by zero (recovered by wazero)
 --> importing.call_import->call->div_by.go(1)
 	--> imported.call->div_by.go(1)
 		==> host.div_by.go(1)
@@ -153,6 +155,9 @@ func TestInterpreter_ModuleEngine_Call_Errors(t *testing.T) {
 --> importing.call_import->call->div_by.go(-1)
 	--> imported.call->div_by.go(-1)
 		==> host.div_by.go(-1)
+		<== error: host-function panic (recovered by wazero)
+	<-- error: host-function panic (recovered by wazero)
+<-- error: host-function panic (recovered by wazero)
 --> importing.call_import->call->div_by.go(1)
 	--> imported.call->div_by.go(1)
 		==> host.div_by.go(1)
@@ -162,6 +167,9 @@ func TestInterpreter_ModuleEngine_Call_Errors(t *testing.T) {
 --> importing.call_import->call->div_by.go(0)
 	--> imported.call->div_by.go(0)
 		==> host.div_by.go(0)
+		<== error: runtime error: integer divide by zero (recovered by wazero)
+	<-- error: runtime error: integer divide by zero (recovered by wazero)
+<-- error: runtime error: integer divide by zero (recovered by wazero)
 --> importing.call_import->call->div_by.go(1)
 	--> imported.call->div_by.go(1)
 		==> host.div_by.go(1)
@@ -514,7 +522,7 @@ func TestInterpreter_Compile(t *testing.T) {
 		}
 		errModule.BuildFunctionDefinitions()
 
-		err := e.CompileModule(testCtx, errModule, nil)
+		err := e.CompileModule(testCtx, errModule, nil, false)
 		require.EqualError(t, err, "failed to lower func[.$2] to wazeroir: handling instruction: apply stack failed for call: reading immediates: EOF")
 
 		// On the compilation failure, all the compiled functions including succeeded ones must be released.
@@ -535,7 +543,7 @@ func TestInterpreter_Compile(t *testing.T) {
 			},
 			ID: wasm.ModuleID{},
 		}
-		err := e.CompileModule(testCtx, okModule, nil)
+		err := e.CompileModule(testCtx, okModule, nil, false)
 		require.NoError(t, err)
 
 		compiled, ok := e.codes[okModule.ID]
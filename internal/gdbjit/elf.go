@@ -0,0 +1,114 @@
+package gdbjit
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"runtime"
+)
+
+// Indexes of the sections of a symfile.
+const (
+	sectionNull = iota
+	sectionText
+	sectionSymtab
+	sectionStrtab
+	sectionShstrtab
+	sectionCount
+)
+
+// newSymfile returns an ELF relocatable object file, whose .text section is
+// at the address of the machine code, so it needn't be copied, and whose
+// only symbol is the function spanning it.
+func newSymfile(name string, addr, size uint64) []byte {
+	// The string tables begin with an empty string, for index zero.
+	shstrtab := []byte("\x00.text\x00.symtab\x00.strtab\x00.shstrtab\x00")
+	strtab := append(append([]byte{0}, name...), 0)
+	syms := []elf.Sym64{
+		{}, // index zero is reserved.
+		{
+			Name:  1,
+			Info:  elf.ST_INFO(elf.STB_GLOBAL, elf.STT_FUNC),
+			Shndx: sectionText,
+			Size:  size,
+			// Value is relative to the .text section, so zero.
+		},
+	}
+
+	const headerSize, sectionHeaderSize, symSize = 64, 64, 24
+	symtabOffset := uint64(headerSize)
+	strtabOffset := symtabOffset + uint64(len(syms)*symSize)
+	shstrtabOffset := strtabOffset + uint64(len(strtab))
+	sectionsOffset := align8(shstrtabOffset + uint64(len(shstrtab)))
+
+	header := elf.Header64{
+		Type:      uint16(elf.ET_REL),
+		Machine:   uint16(machine()),
+		Version:   uint32(elf.EV_CURRENT),
+		Shoff:     sectionsOffset,
+		Ehsize:    headerSize,
+		Shentsize: sectionHeaderSize,
+		Shnum:     sectionCount,
+		Shstrndx:  sectionShstrtab,
+	}
+	copy(header.Ident[:], elf.ELFMAG)
+	header.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	header.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	header.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+
+	sections := [sectionCount]elf.Section64{
+		sectionText: {
+			Name:      1, // ".text"
+			Type:      uint32(elf.SHT_NOBITS),
+			Flags:     uint64(elf.SHF_ALLOC | elf.SHF_EXECINSTR),
+			Addr:      addr,
+			Size:      size,
+			Addralign: 16,
+		},
+		sectionSymtab: {
+			Name:      7, // ".symtab"
+			Type:      uint32(elf.SHT_SYMTAB),
+			Off:       symtabOffset,
+			Size:      uint64(len(syms) * symSize),
+			Link:      sectionStrtab,
+			Info:      1, // the index of the first global symbol.
+			Addralign: 8,
+			Entsize:   symSize,
+		},
+		sectionStrtab: {
+			Name:      15, // ".strtab"
+			Type:      uint32(elf.SHT_STRTAB),
+			Off:       strtabOffset,
+			Size:      uint64(len(strtab)),
+			Addralign: 1,
+		},
+		sectionShstrtab: {
+			Name:      23, // ".shstrtab"
+			Type:      uint32(elf.SHT_STRTAB),
+			Off:       shstrtabOffset,
+			Size:      uint64(len(shstrtab)),
+			Addralign: 1,
+		},
+	}
+
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.LittleEndian, &header)
+	_ = binary.Write(&buf, binary.LittleEndian, syms)
+	buf.Write(strtab)
+	buf.Write(shstrtab)
+	buf.Write(make([]byte, sectionsOffset-uint64(buf.Len())))
+	_ = binary.Write(&buf, binary.LittleEndian, &sections)
+	return buf.Bytes()
+}
+
+func align8(n uint64) uint64 {
+	return (n + 7) &^ 7
+}
+
+// machine returns the ELF machine of the code generated by the compiler.
+func machine() elf.Machine {
+	if runtime.GOARCH == "arm64" {
+		return elf.EM_AARCH64
+	}
+	return elf.EM_X86_64
+}
@@ -0,0 +1,131 @@
+// Package gdbjit implements the GDB JIT compilation interface, which native
+// debuggers, such as GDB and LLDB, use to find symbols of code generated at
+// runtime: https://sourceware.org/gdb/onlinedocs/gdb/JIT-Interface.html
+//
+// Each function registered is described by an in-memory ELF object file
+// with a symbol spanning its machine code, so debuggers show the wasm
+// function name instead of an anonymous address.
+package gdbjit
+
+import (
+	"context"
+	"sync"
+	"unsafe"
+)
+
+// EnabledKey is a context.Context Value key. Its associated value should be
+// a bool. When true in the context passed to wazero.NewRuntimeWithConfig, the
+// compiler registers the machine code of each function with Register.
+type EnabledKey struct{}
+
+// Enabled returns true if the context enables registration of code.
+func Enabled(ctx context.Context) bool {
+	enabled, _ := ctx.Value(EnabledKey{}).(bool)
+	return enabled
+}
+
+// The below types and symbols must be laid out as the interface defines:
+//
+//	typedef enum {
+//		JIT_NOACTION = 0,
+//		JIT_REGISTER_FN,
+//		JIT_UNREGISTER_FN
+//	} jit_actions_t;
+//
+//	struct jit_code_entry {
+//		struct jit_code_entry *next_entry;
+//		struct jit_code_entry *prev_entry;
+//		const char *symfile_addr;
+//		uint64_t symfile_size;
+//	};
+//
+//	struct jit_descriptor {
+//		uint32_t version;
+//		uint32_t action_flag;
+//		struct jit_code_entry *relevant_entry;
+//		struct jit_code_entry *first_entry;
+//	};
+//
+//	void __attribute__((noinline)) __jit_debug_register_code() { };
+//	struct jit_descriptor __jit_debug_descriptor = { 1, 0, 0, 0 };
+const (
+	jitNoAction = iota
+	jitRegisterFn
+	jitUnregisterFn
+)
+
+// Entry is a jit_code_entry, returned by Register for Unregister.
+type Entry struct {
+	next, prev  *Entry
+	symfileAddr *byte
+	symfileSize uint64
+
+	// symfile is the ELF object file, kept here so that it isn't collected
+	// while the debugger may read it.
+	symfile []byte
+}
+
+type jitDescriptor struct {
+	version       uint32
+	actionFlag    uint32
+	relevantEntry *Entry
+	firstEntry    *Entry
+}
+
+// descriptor is read by the debugger via its symbol name, so must not be
+// renamed.
+//
+//go:linkname descriptor __jit_debug_descriptor
+var descriptor = jitDescriptor{version: 1}
+
+// mu serializes changes to descriptor, as the interface requires.
+var mu sync.Mutex
+
+// jitDebugRegisterCode is where the debugger sets a breakpoint to be
+// notified of changes to descriptor, so must not be renamed or inlined.
+//
+//go:linkname jitDebugRegisterCode __jit_debug_register_code
+//go:noinline
+func jitDebugRegisterCode() {}
+
+// Register notifies an attached debugger of the machine code of a function
+// with the given name, returning the Entry to Unregister before the code is
+// released.
+func Register(name string, code []byte) *Entry {
+	symfile := newSymfile(name, uint64(uintptr(unsafe.Pointer(&code[0]))), uint64(len(code)))
+	e := &Entry{symfileAddr: &symfile[0], symfileSize: uint64(len(symfile)), symfile: symfile}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if first := descriptor.firstEntry; first != nil {
+		e.next, first.prev = first, e
+	}
+	descriptor.firstEntry = e
+	notify(jitRegisterFn, e)
+	return e
+}
+
+// Unregister notifies an attached debugger the code of an Entry returned by
+// Register is no longer valid.
+func Unregister(e *Entry) {
+	mu.Lock()
+	defer mu.Unlock()
+	if e.prev != nil {
+		e.prev.next = e.next
+	} else {
+		descriptor.firstEntry = e.next
+	}
+	if e.next != nil {
+		e.next.prev = e.prev
+	}
+	notify(jitUnregisterFn, e)
+	e.next, e.prev = nil, nil
+}
+
+// notify calls jitDebugRegisterCode with descriptor describing the action.
+// This must be called with mu held.
+func notify(action uint32, e *Entry) {
+	descriptor.actionFlag, descriptor.relevantEntry = action, e
+	jitDebugRegisterCode()
+	descriptor.actionFlag, descriptor.relevantEntry = jitNoAction, nil
+}
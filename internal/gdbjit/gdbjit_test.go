@@ -0,0 +1,74 @@
+package gdbjit
+
+import (
+	"bytes"
+	"context"
+	"debug/elf"
+	"testing"
+	"unsafe"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestEnabled(t *testing.T) {
+	require.False(t, Enabled(context.Background()))
+	require.False(t, Enabled(context.WithValue(context.Background(), EnabledKey{}, false)))
+	require.True(t, Enabled(context.WithValue(context.Background(), EnabledKey{}, true)))
+}
+
+func TestRegister(t *testing.T) {
+	code := []byte{1, 2, 3, 4}
+
+	e1 := Register("a", code)
+	e2 := Register("b", code)
+	e3 := Register("c", code)
+	require.Equal(t, e3, descriptor.firstEntry)
+	require.Equal(t, []*Entry{e3, e2, e1}, entries())
+	require.Equal(t, uint32(jitNoAction), descriptor.actionFlag)
+	require.Nil(t, descriptor.relevantEntry)
+
+	Unregister(e2) // middle
+	require.Equal(t, []*Entry{e3, e1}, entries())
+	Unregister(e3) // first
+	require.Equal(t, []*Entry{e1}, entries())
+	Unregister(e1) // last
+	require.Nil(t, descriptor.firstEntry)
+}
+
+// entries returns the registered entries, checking they are doubly linked.
+func entries() (ret []*Entry) {
+	var prev *Entry
+	for e := descriptor.firstEntry; e != nil; e = e.next {
+		if e.prev != prev {
+			panic("BUG: entries aren't doubly linked")
+		}
+		ret = append(ret, e)
+		prev = e
+	}
+	return
+}
+
+func TestNewSymfile(t *testing.T) {
+	code := make([]byte, 100)
+	addr := uint64(uintptr(unsafe.Pointer(&code[0])))
+
+	f, err := elf.NewFile(bytes.NewReader(newSymfile("test.fn", addr, 100)))
+	require.NoError(t, err)
+	require.Equal(t, elf.ET_REL, f.Type)
+	require.Equal(t, machine(), f.Machine)
+
+	text := f.Section(".text")
+	require.NotNil(t, text)
+	require.Equal(t, elf.SHT_NOBITS, text.Type)
+	require.Equal(t, addr, text.Addr)
+	require.Equal(t, uint64(100), text.Size)
+
+	syms, err := f.Symbols()
+	require.NoError(t, err)
+	require.Equal(t, []elf.Symbol{{
+		Name:    "test.fn",
+		Info:    elf.ST_INFO(elf.STB_GLOBAL, elf.STT_FUNC),
+		Section: elf.SectionIndex(sectionText),
+		Size:    100,
+	}}, syms)
+}
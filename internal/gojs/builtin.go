@@ -27,6 +27,8 @@ const (
 	idJsDate
 	idHttpFetch
 	idHttpHeaders
+	idJsPath
+	idJsConsole
 	nextID
 )
 
@@ -50,6 +52,8 @@ const (
 	refJsDate                 = (nanHead|ref(typeFlagObject))<<32 | ref(idJsDate)
 	refHttpFetch              = (nanHead|ref(typeFlagFunction))<<32 | ref(idHttpFetch)
 	refHttpHeadersConstructor = (nanHead|ref(typeFlagFunction))<<32 | ref(idHttpHeaders)
+	refJsPath                 = (nanHead|ref(typeFlagObject))<<32 | ref(idJsPath)
+	refJsConsole              = (nanHead|ref(typeFlagObject))<<32 | ref(idJsConsole)
 )
 
 // newJsGlobal = js.Global() // js.go init
@@ -68,8 +72,10 @@ func newJsGlobal(rt http.RoundTripper) *jsVal {
 			"AbortController": undefined,
 			"Headers":         headersConstructor,
 			"process":         jsProcess,
+			"path":            jsPath,
 			"fs":              jsfs,
 			"Date":            jsDateConstructor,
+			"console":         jsConsole,
 		}).
 		addFunction("fetch", &fetch{})
 }
@@ -91,14 +97,16 @@ var (
 	// jsProcess = js.Global().Get("process") // fs_js.go init
 	jsProcess = newJsVal(refJsProcess, "process").
 			addProperties(map[string]interface{}{
-			"pid":  float64(1),   // Get("pid").Int() in syscall_js.go for syscall.Getpid
-			"ppid": refValueZero, // Get("ppid").Int() in syscall_js.go for syscall.Getppid
+			"pid":   float64(1),   // Get("pid").Int() in syscall_js.go for syscall.Getpid
+			"ppid":  refValueZero, // Get("ppid").Int() in syscall_js.go for syscall.Getppid
+			"argv0": undefined,    // Get("argv0").String() in roundtrip_js.go init
 		}).
 		addFunction("cwd", &cwd{}).                     // syscall.Cwd in fs_js.go
 		addFunction("chdir", &chdir{}).                 // syscall.Chdir in fs_js.go
 		addFunction("getuid", &returnZero{}).           // syscall.Getuid in syscall_js.go
 		addFunction("getgid", &returnZero{}).           // syscall.Getgid in syscall_js.go
 		addFunction("geteuid", &returnZero{}).          // syscall.Geteuid in syscall_js.go
+		addFunction("getegid", &returnZero{}).          // syscall.Getegid in syscall_js.go
 		addFunction("getgroups", &returnSliceOfZero{}). // syscall.Getgroups in syscall_js.go
 		addFunction("umask", &returnArg0{})             // syscall.Umask in syscall_js.go
 
@@ -107,4 +115,15 @@ var (
 	//
 	// It has only one invocation pattern: `buf := uint8Array.New(len(b))`
 	uint8ArrayConstructor = newJsVal(refUint8ArrayConstructor, "Uint8Array")
+
+	// jsPath = js.Global().Get("path") // fs_js.go init
+	jsPath = newJsVal(refJsPath, "path").
+		addFunction("resolve", &resolve{}) // syscall.Open in fs_js.go
+
+	// jsConsole = js.Global().Get("console") // func.go handleEvent
+	//
+	// It has only one invocation pattern, when an event targets a released
+	// function: `Call("error", "call to released function")`
+	jsConsole = newJsVal(refJsConsole, "console").
+			addFunction("error", &consoleError{})
 )
@@ -25,7 +25,7 @@ func compileAndRun(ctx context.Context, arg string, config wazero.ModuleConfig)
 	var stdoutBuf, stderrBuf bytes.Buffer
 
 	ns := rt.NewNamespace(ctx)
-	builder := rt.NewHostModuleBuilder("go")
+	builder := rt.NewHostModuleBuilder(gojs.ModuleName)
 	gojs.NewFunctionExporter().
 		ExportFunctions(builder)
 	if _, err = builder.Instantiate(ctx, ns); err != nil {
@@ -14,6 +14,11 @@ func Test_crypto(t *testing.T) {
 
 	require.Zero(t, stderr)
 	require.EqualError(t, err, `module "" closed with exit_code(0)`)
-	require.Equal(t, `7a0c9f9f0d
-`, stdout)
+	require.Equal(t, 11, len(stdout)) // 5 bytes hex-encoded and a newline
+
+	// Go 1.24+ mixes the random data with a DRBG, so the output differs
+	// per version. However, it is still deterministic for the same binary.
+	stdout2, _, err := compileAndRun(testCtx, "crypto", wazero.NewModuleConfig())
+	require.EqualError(t, err, `module "" closed with exit_code(0)`)
+	require.Equal(t, stdout, stdout2)
 }
@@ -6,6 +6,8 @@ import (
 	"io"
 	"io/fs"
 	"os"
+	"path"
+	"strings"
 	"syscall"
 
 	"github.com/tetratelabs/wazero/api"
@@ -22,6 +24,7 @@ var (
 	jsfs = newJsVal(refJsfs, "fs").
 		addProperties(map[string]interface{}{
 			"constants": jsfsConstants, // = jsfs.Get("constants") // init
+			"lchown":    undefined,     // = jsfs.Get("lchown").IsUndefined() // syscall.Lchown
 		}).
 		addFunction("open", &jsfsOpen{}).
 		addFunction("stat", &jsfsStat{}).
@@ -40,7 +43,6 @@ var (
 	//	* _, err := fsCall("fchmod", fd, mode) // syscall.Fchmod
 	//	* _, err := fsCall("chown", path, uint32(uid), uint32(gid)) // syscall.Chown
 	//	* _, err := fsCall("fchown", fd, uint32(uid), uint32(gid)) // syscall.Fchown
-	//	* _, err := fsCall("lchown", path, uint32(uid), uint32(gid)) // syscall.Lchown
 	//	* _, err := fsCall("utimes", path, atime, mtime) // syscall.UtimesNano
 	//	* _, err := fsCall("rename", from, to) // syscall.Rename
 	//	* _, err := fsCall("truncate", path, length) // syscall.Truncate
@@ -53,12 +55,13 @@ var (
 	// jsfsConstants = jsfs Get("constants") // fs_js.go init
 	jsfsConstants = newJsVal(refJsfsConstants, "constants").
 			addProperties(map[string]interface{}{
-			"O_WRONLY": oWRONLY,
-			"O_RDWR":   oRDWR,
-			"O_CREAT":  oCREAT,
-			"O_TRUNC":  oTRUNC,
-			"O_APPEND": oAPPEND,
-			"O_EXCL":   oEXCL,
+			"O_WRONLY":    oWRONLY,
+			"O_RDWR":      oRDWR,
+			"O_CREAT":     oCREAT,
+			"O_TRUNC":     oTRUNC,
+			"O_APPEND":    oAPPEND,
+			"O_EXCL":      oEXCL,
+			"O_DIRECTORY": oDIRECTORY,
 		})
 
 	// oWRONLY = jsfsConstants Get("O_WRONLY").Int() // fs_js.go init
@@ -78,6 +81,12 @@ var (
 
 	// oEXCL = jsfsConstants Get("O_EXCL").Int() // fs_js.go init
 	oEXCL = api.EncodeF64(float64(os.O_EXCL))
+
+	// oDIRECTORY = jsfsConstants Get("O_DIRECTORY").Int() // fs_js.go init
+	//
+	// Note: This is the value Node.js uses on Linux, as os.O_DIRECTORY isn't
+	// defined on all platforms.
+	oDIRECTORY = api.EncodeF64(float64(0o200000))
 )
 
 // jsfsOpen implements fs.Open
@@ -113,7 +122,7 @@ func (*jsfsStat) invoke(ctx context.Context, mod api.Module, args ...interface{}
 // syscallStat is like syscall.Stat
 func syscallStat(mod api.Module, name string) (*jsSt, error) {
 	fsc := mod.(*wasm.CallContext).Sys.FS()
-	if fd, err := fsc.OpenFile(name); err != nil {
+	if fd, err := fsc.OpenFile(name, os.O_RDONLY, 0); err != nil {
 		return nil, err
 	} else {
 		defer fsc.CloseFile(fd)
@@ -289,7 +298,7 @@ func (*jsfsReaddir) invoke(ctx context.Context, mod api.Module, args ...interfac
 func syscallReaddir(_ context.Context, mod api.Module, name string) (*objectArray, error) {
 	fsc := mod.(*wasm.CallContext).Sys.FS()
 
-	fd, err := fsc.OpenFile(name)
+	fd, err := fsc.OpenFile(name, os.O_RDONLY, 0)
 	if err != nil {
 		return nil, err
 	}
@@ -349,7 +358,7 @@ func (*chdir) invoke(ctx context.Context, mod api.Module, args ...interface{}) (
 	path := args[0].(string)
 
 	// TODO: refactor so that sys has path-based ops, also needed in WASI.
-	if fd, err := fsc.OpenFile(path); err != nil {
+	if fd, err := fsc.OpenFile(path, os.O_RDONLY, 0); err != nil {
 		return nil, syscall.ENOENT
 	} else if f, ok := fsc.OpenedFile(fd); !ok {
 		return nil, syscall.ENOENT
@@ -365,6 +374,37 @@ func (*chdir) invoke(ctx context.Context, mod api.Module, args ...interface{}) (
 	}
 }
 
+// resolve for fs.Open in fs_js.go, which calls `jsPath.Call("resolve", path)`
+// to update the name of the opened file relative to cwd.
+type resolve struct{}
+
+// invoke implements jsFn.invoke
+func (*resolve) invoke(ctx context.Context, _ api.Module, args ...interface{}) (interface{}, error) {
+	name := args[0].(string)
+	if !path.IsAbs(name) {
+		name = path.Join(getState(ctx).cwd, name)
+	}
+	return path.Clean(name), nil
+}
+
+// consoleError writes its arguments to stderr, like console.error in
+// JavaScript.
+type consoleError struct{}
+
+// invoke implements jsFn.invoke
+func (*consoleError) invoke(_ context.Context, mod api.Module, args ...interface{}) (interface{}, error) {
+	fsc := mod.(*wasm.CallContext).Sys.FS()
+
+	if w := fsc.FdWriter(internalsys.FdStderr); w != nil {
+		msg := make([]string, 0, len(args))
+		for _, arg := range args {
+			msg = append(msg, valueString(arg))
+		}
+		_, _ = fmt.Fprintln(w, strings.Join(msg, " "))
+	}
+	return undefined, nil
+}
+
 // jsSt is pre-parsed from fs_js.go setStat to avoid thrashing
 type jsSt struct {
 	isDir bool
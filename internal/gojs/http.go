@@ -1,6 +1,7 @@
 package gojs
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -32,6 +33,10 @@ func getRoundTripper(ctx context.Context) http.RoundTripper {
 // In http.Transport RoundTrip, this returns a promise
 //
 //	fetchPromise := js.Global().Call("fetch", req.URL.String(), opt)
+//
+// The "method", "headers" and "body" options are sent to the
+// http.RoundTripper configured by the host. Browser-specific options, such as
+// "credentials" and "mode", are ignored.
 type fetch struct{}
 
 // invoke implements jsFn.invoke
@@ -42,11 +47,18 @@ func (*fetch) invoke(ctx context.Context, _ api.Module, args ...interface{}) (in
 	}
 	url := args[0].(string)
 	properties := args[1].(*object).properties
-	req, err := http.NewRequestWithContext(ctx, properties["method"].(string), url, nil)
+
+	var body io.Reader
+	if b, ok := properties["body"].(*byteArray); ok {
+		body = bytes.NewReader(b.slice) // sets the ContentLength
+	}
+	req, err := http.NewRequestWithContext(ctx, properties["method"].(string), url, body)
 	if err != nil {
 		return nil, err
 	}
-	// TODO: headers properties[headers]
+	if h, ok := properties["headers"].(*headers); ok {
+		req.Header = h.headers
+	}
 	v := &fetchPromise{rt: rt, req: req}
 	return v, nil
 }
@@ -65,13 +77,14 @@ func (p *fetchPromise) call(ctx context.Context, mod api.Module, this ref, metho
 			return failure.invoke(ctx, mod, this, err)
 		} else {
 			success := args[0].(funcWrapper)
-			return success.invoke(ctx, mod, this, &fetchResult{res: res})
+			return success.invoke(ctx, mod, this, &fetchResult{req: p.req, res: res})
 		}
 	}
 	panic(fmt.Sprintf("TODO: fetchPromise.%s", method))
 }
 
 type fetchResult struct {
+	req *http.Request
 	res *http.Response
 }
 
@@ -79,23 +92,31 @@ type fetchResult struct {
 func (s *fetchResult) get(ctx context.Context, propertyKey string) interface{} {
 	switch propertyKey {
 	case "headers":
-		names := make([]string, 0, len(s.res.Header))
-		for k := range s.res.Header {
-			names = append(names, k)
-		}
-		// Sort names for consistent iteration
-		sort.Strings(names)
-		h := &headers{names: names, headers: s.res.Header}
-		return h
+		return &headers{headers: s.res.Header}
 	case "body":
 		// return undefined as arrayPromise is more complicated than an array.
 		return undefined
 	case "status":
 		return uint32(s.res.StatusCode)
+	case "redirected":
+		// A http.RoundTripper doesn't usually follow redirects, but one
+		// backed by a http.Client does.
+		return s.url() != s.req.URL.String()
+	case "url":
+		return s.url()
 	}
 	panic(fmt.Sprintf("TODO: get fetchResult.%s", propertyKey))
 }
 
+// url returns the URL of the final request, which differs from the original
+// when the http.RoundTripper followed a redirect.
+func (s *fetchResult) url() string {
+	if req := s.res.Request; req != nil && req.URL != nil {
+		return req.URL.String()
+	}
+	return s.req.URL.String()
+}
+
 // call implements jsCall.call
 func (s *fetchResult) call(ctx context.Context, _ api.Module, this ref, method string, _ ...interface{}) (interface{}, error) {
 	switch method {
@@ -108,7 +129,9 @@ func (s *fetchResult) call(ctx context.Context, _ api.Module, this ref, method s
 
 type headers struct {
 	headers http.Header
-	names   []string
+
+	// entries are name-value pairs iterated via "next", built by "entries".
+	entries [][2]string
 	i       int
 }
 
@@ -116,12 +139,11 @@ type headers struct {
 func (h *headers) get(_ context.Context, propertyKey string) interface{} {
 	switch propertyKey {
 	case "done":
-		return h.i == len(h.names)
+		return h.i == len(h.entries)
 	case "value":
-		name := h.names[h.i]
-		value := h.headers.Get(name)
+		entry := h.entries[h.i]
 		h.i++
-		return &objectArray{[]interface{}{name, value}}
+		return &objectArray{[]interface{}{entry[0], entry[1]}}
 	}
 	panic(fmt.Sprintf("TODO: get headers.%s", propertyKey))
 }
@@ -130,15 +152,24 @@ func (h *headers) get(_ context.Context, propertyKey string) interface{} {
 func (h *headers) call(_ context.Context, _ api.Module, this ref, method string, args ...interface{}) (interface{}, error) {
 	switch method {
 	case "entries":
+		names := make([]string, 0, len(h.headers))
+		for k := range h.headers {
+			names = append(names, k)
+		}
 		// Sort names for consistent iteration
-		sort.Strings(h.names)
+		sort.Strings(names)
+		h.entries, h.i = h.entries[:0], 0
+		for _, name := range names {
+			for _, value := range h.headers[name] {
+				h.entries = append(h.entries, [2]string{name, value})
+			}
+		}
 		return h, nil
 	case "next":
 		return h, nil
 	case "append":
 		name := textproto.CanonicalMIMEHeaderKey(args[0].(string))
 		value := args[1].(string)
-		h.names = append(h.names, name)
 		h.headers.Add(name, value)
 		return nil, nil
 	}
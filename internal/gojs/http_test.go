@@ -22,19 +22,30 @@ func Test_http(t *testing.T) {
 	t.Parallel()
 
 	ctx := gojs.WithRoundTripper(testCtx, roundTripperFunc(func(req *http.Request) (*http.Response, error) {
-		if req.URL.Path == "/error" {
+		switch req.URL.Path {
+		case "/error":
 			return nil, errors.New("error")
+		case "/redirect":
+			// Simulate a http.RoundTripper that followed a redirect.
+			followed := req.Clone(req.Context())
+			followed.URL.Path = "/redirected"
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader("")),
+				Request:    followed,
+			}, nil
 		}
-		if req.Body != nil {
-			require.Equal(t, http.MethodPost, req.Method)
-			bytes, err := io.ReadAll(req.Body)
-			require.NoError(t, err)
-			require.Equal(t, "ice cream", string(bytes))
-		}
+		require.Equal(t, http.MethodPost, req.Method)
+		require.Equal(t, "text/plain", req.Header.Get("Content-Type"))
+		require.Equal(t, []string{"vanilla", "chocolate"}, req.Header.Values("Flavor"))
+		require.Equal(t, int64(9), req.ContentLength)
+		bytes, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		require.Equal(t, "ice cream", string(bytes))
 		return &http.Response{
-			StatusCode:    http.StatusOK,
-			Status:        http.StatusText(http.StatusOK),
-			Header:        http.Header{"Custom": {"1"}},
+			StatusCode:    http.StatusCreated,
+			Status:        http.StatusText(http.StatusCreated),
+			Header:        http.Header{"Custom": {"1", "2"}},
 			Body:          io.NopCloser(strings.NewReader("abcdef")),
 			ContentLength: 6,
 		}, nil
@@ -46,7 +57,8 @@ func Test_http(t *testing.T) {
 	require.EqualError(t, err, `module "" closed with exit_code(0)`)
 	require.Zero(t, stderr)
 	require.Equal(t, `Get "http://host/error": net/http: fetch() failed: error
-1
+201 [1 2]
 abcdef
+http://host/redirected
 `, stdout)
 }
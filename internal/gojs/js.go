@@ -87,6 +87,11 @@ func (v *jsVal) get(_ context.Context, propertyKey string) interface{} {
 	if v, ok := v.properties[propertyKey]; ok {
 		return v
 	}
+	// Guests commonly probe the global object for features, such as
+	// js.Global().Get("document").IsUndefined(), so don't panic.
+	if v.ref == refValueGlobal {
+		return undefined
+	}
 	panic(fmt.Sprintf("TODO: get %s.%s", v.name, propertyKey))
 }
 
@@ -95,6 +100,10 @@ func (v *jsVal) call(ctx context.Context, mod api.Module, this ref, method strin
 	if v, ok := v.functions[method]; ok {
 		return v.invoke(ctx, mod, args...)
 	}
+	// A guest can set a function property, e.g. js.Global().Set("add", f).
+	if f, ok := v.properties[method].(funcWrapper); ok {
+		return f.invoke(ctx, mod, append([]interface{}{this}, args...)...)
+	}
 	panic(fmt.Sprintf("TODO: call %s.%s", v.name, method))
 }
 
@@ -109,7 +118,7 @@ type byteArray struct {
 // get implements jsGet.get
 func (a *byteArray) get(_ context.Context, propertyKey string) interface{} {
 	switch propertyKey {
-	case "byteLength":
+	case "byteLength", "length":
 		return uint32(len(a.slice))
 	}
 	panic(fmt.Sprintf("TODO: get byteArray.%s", propertyKey))
@@ -123,6 +132,15 @@ type objectArray struct {
 	slice []interface{}
 }
 
+// get implements jsGet.get
+func (a *objectArray) get(_ context.Context, propertyKey string) interface{} {
+	switch propertyKey {
+	case "length":
+		return uint32(len(a.slice))
+	}
+	panic(fmt.Sprintf("TODO: get objectArray.%s", propertyKey))
+}
+
 // object is a result of objectConstructor typically used to pass named
 // arguments.
 //
@@ -130,3 +148,22 @@ type objectArray struct {
 type object struct {
 	properties map[string]interface{}
 }
+
+// get implements jsGet.get
+func (o *object) get(_ context.Context, propertyKey string) interface{} {
+	if v, ok := o.properties[propertyKey]; ok {
+		return v
+	}
+	return undefined
+}
+
+// call implements jsCall.call
+func (o *object) call(ctx context.Context, mod api.Module, this ref, method string, args ...interface{}) (interface{}, error) {
+	switch f := o.properties[method].(type) {
+	case funcWrapper:
+		return f.invoke(ctx, mod, append([]interface{}{this}, args...)...)
+	case jsFn:
+		return f.invoke(ctx, mod, args...)
+	}
+	return nil, fmt.Errorf("%s is not a function", method)
+}
@@ -0,0 +1,32 @@
+package gojs_test
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func Test_js(t *testing.T) {
+	t.Parallel()
+
+	stdout, stderr, err := compileAndRun(testCtx, "js", wazero.NewModuleConfig())
+
+	require.EqualError(t, err, `module "" closed with exit_code(0)`)
+	require.Zero(t, stderr)
+	require.Equal(t, `document: undefined
+obj.name: wazero
+obj.stars: 1
+obj.tags.length: 2 go true
+obj.tags instanceof Array: true
+obj instanceof Object: true
+obj instanceof Array: false
+obj.name after delete: undefined
+buf: 2 0 7
+add(1, 2): 3
+add.Invoke(3, 4): 7
+obj.add(5, 6): 11
+add after delete: undefined
+slept
+`, stdout)
+}
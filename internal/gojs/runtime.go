@@ -3,6 +3,7 @@ package gojs
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/internal/wasm"
@@ -14,8 +15,8 @@ const (
 	resetMemoryDataViewName  = "runtime.resetMemoryDataView"
 	nanotime1Name            = "runtime.nanotime1"
 	walltimeName             = "runtime.walltime"
-	scheduleTimeoutEventName = "runtime.scheduleTimeoutEvent" // TODO: trigger usage
-	clearTimeoutEventName    = "runtime.clearTimeoutEvent"    // TODO: trigger usage
+	scheduleTimeoutEventName = "runtime.scheduleTimeoutEvent"
+	clearTimeoutEventName    = "runtime.clearTimeoutEvent"
 	getRandomDataName        = "runtime.getRandomData"
 )
 
@@ -104,23 +105,72 @@ func walltime(_ context.Context, mod api.Module, sp []uint64) {
 }
 
 // ScheduleTimeoutEvent implements runtime.scheduleTimeoutEvent which supports
-// runtime.notetsleepg used by runtime.signal_recv.
+// runtime.notetsleepg used by runtime.signal_recv, and runtime.beforeIdle used
+// when all goroutines are blocked, e.g. on time.Sleep.
 //
-// Unlike other most functions prefixed by "runtime.", this both launches a
-// goroutine and invokes code compiled into wasm "resume".
+// Unlike other most functions prefixed by "runtime.", this schedules a call to
+// the wasm function "resume", which happens in RunTimeouts.
 //
 // See https://github.com/golang/go/blob/go1.19/src/runtime/sys_wasm.s#L192
-var ScheduleTimeoutEvent = stubFunction(scheduleTimeoutEventName)
+// and https://github.com/golang/go/blob/go1.19/misc/wasm/wasm_exec.js#L281-L300
+var ScheduleTimeoutEvent = newSPFunc(scheduleTimeoutEventName, scheduleTimeoutEvent)
 
-// ^^ stubbed because signal handling is not implemented in GOOS=js
+func scheduleTimeoutEvent(ctx context.Context, mod api.Module, sp []uint64) {
+	sysCtx := mod.(*wasm.CallContext).Sys
+	mem := mod.Memory()
+
+	// Read (param + result count) * 8 memory starting at SP+8
+	stack := mustRead(mem, "sp", uint32(sp[0]+8), 16)
+
+	delayMs := int64(le.Uint64(stack))
+	deadline := sysCtx.Nanotime() + delayMs*int64(time.Millisecond)
+
+	id := getState(ctx).scheduleTimeout(deadline)
+
+	// Write the results to memory at positions after the parameters.
+	le.PutUint32(stack[8:], id)
+}
 
-// ClearTimeoutEvent implements runtime.clearTimeoutEvent which supports
-// runtime.notetsleepg used by runtime.signal_recv.
+// ClearTimeoutEvent implements runtime.clearTimeoutEvent which cancels an ID
+// returned by ScheduleTimeoutEvent.
 //
 // See https://github.com/golang/go/blob/go1.19/src/runtime/sys_wasm.s#L196
-var ClearTimeoutEvent = stubFunction(clearTimeoutEventName)
+// and https://github.com/golang/go/blob/go1.19/misc/wasm/wasm_exec.js#L303-L307
+var ClearTimeoutEvent = newSPFunc(clearTimeoutEventName, clearTimeoutEvent)
 
-// ^^ stubbed because signal handling is not implemented in GOOS=js
+func clearTimeoutEvent(ctx context.Context, mod api.Module, sp []uint64) {
+	mem := mod.Memory()
+
+	// Read the id from offset SP+8
+	id := mustReadUint32Le(mem, "id", uint32(sp[0]+8))
+
+	getState(ctx).clearTimeout(id)
+}
+
+// RunTimeouts waits for each timeout scheduled by ScheduleTimeoutEvent and
+// calls the wasm function "resume", like setTimeout in JavaScript. This
+// returns when no timeouts remain or the module exits.
+//
+// This should be called after the wasm function "run" returns, as that only
+// means all goroutines are blocked. For example, main could be in time.Sleep.
+func RunTimeouts(ctx context.Context, mod api.Module) error {
+	sysCtx := mod.(*wasm.CallContext).Sys
+	s := getState(ctx)
+	for {
+		id, deadline, ok := s.nextTimeout()
+		if !ok {
+			return nil
+		}
+		if delay := deadline - sysCtx.Nanotime(); delay > 0 {
+			sysCtx.Nanosleep(delay)
+		}
+		// Clear the timeout before resuming, as Go may schedule another.
+		s.clearTimeout(id)
+		if _, err := mod.ExportedFunction("resume").Call(ctx); err != nil {
+			return err
+		}
+	}
+}
 
 // GetRandomData implements runtime.getRandomData, which initializes the seed
 // for runtime.fastrand.
@@ -13,6 +13,9 @@ func WithState(ctx context.Context) context.Context {
 		values:      &values{ids: map[interface{}]uint32{}},
 		valueGlobal: newJsGlobal(getRoundTripper(ctx)),
 		cwd:         "/",
+
+		nextCallbackTimeoutID: 1,
+		scheduledTimeouts:     map[uint32]int64{},
 	}
 	return context.WithValue(ctx, stateKey{}, s)
 }
@@ -92,6 +95,10 @@ func loadValue(ctx context.Context, ref ref) interface{} { // nolint
 		return jsDate
 	case refHttpHeadersConstructor:
 		return headersConstructor
+	case refJsPath:
+		return jsPath
+	case refJsConsole:
+		return jsConsole
 	default:
 		if (ref>>32)&nanHead != nanHead { // numbers are passed through as a ref
 			return api.DecodeF64(uint64(ref))
@@ -219,12 +226,46 @@ type state struct {
 
 	// cwd is initially "/"
 	cwd string
+
+	// nextCallbackTimeoutID is the ID returned by the next scheduleTimeout.
+	nextCallbackTimeoutID uint32
+
+	// scheduledTimeouts are deadlines in sys.Nanotime, keyed by timeout ID.
+	scheduledTimeouts map[uint32]int64
+}
+
+// scheduleTimeout records the deadline for runtime.scheduleTimeoutEvent and
+// returns its ID.
+func (s *state) scheduleTimeout(deadline int64) uint32 {
+	id := s.nextCallbackTimeoutID
+	s.nextCallbackTimeoutID++
+	s.scheduledTimeouts[id] = deadline
+	return id
+}
+
+// clearTimeout implements runtime.clearTimeoutEvent.
+func (s *state) clearTimeout(id uint32) {
+	delete(s.scheduledTimeouts, id)
+}
+
+// nextTimeout returns the timeout with the earliest deadline, or false if
+// none are scheduled.
+func (s *state) nextTimeout() (id uint32, deadline int64, ok bool) {
+	for i, d := range s.scheduledTimeouts {
+		if !ok || d < deadline || (d == deadline && i < id) {
+			id, deadline, ok = i, d, true
+		}
+	}
+	return
 }
 
 // get implements jsGet.get
 func (s *state) get(_ context.Context, propertyKey string) interface{} {
 	switch propertyKey {
 	case "_pendingEvent":
+		if s._pendingEvent == nil { // e.g. resumed by a timeout
+			return nil
+		}
 		return s._pendingEvent
 	}
 	panic(fmt.Sprintf("TODO: state.%s", propertyKey))
@@ -247,6 +288,9 @@ func (s *state) clear() {
 	}
 	s.values.idPool = s.values.idPool[:0]
 	s._pendingEvent = nil
+	for k := range s.scheduledTimeouts {
+		delete(s.scheduledTimeouts, k)
+	}
 }
 
 func toInt64(arg interface{}) int64 {
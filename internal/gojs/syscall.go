@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io/fs"
 	"net/http"
+	"os"
 	"syscall"
 
 	"github.com/tetratelabs/wazero/api"
@@ -19,16 +20,16 @@ const (
 	stringValName          = "syscall/js.stringVal"
 	valueGetName           = "syscall/js.valueGet"
 	valueSetName           = "syscall/js.valueSet"
-	valueDeleteName        = "syscall/js.valueDelete" // stubbed
+	valueDeleteName        = "syscall/js.valueDelete"
 	valueIndexName         = "syscall/js.valueIndex"
-	valueSetIndexName      = "syscall/js.valueSetIndex" // stubbed
+	valueSetIndexName      = "syscall/js.valueSetIndex"
 	valueCallName          = "syscall/js.valueCall"
-	valueInvokeName        = "syscall/js.valueInvoke" // stubbed
+	valueInvokeName        = "syscall/js.valueInvoke"
 	valueNewName           = "syscall/js.valueNew"
 	valueLengthName        = "syscall/js.valueLength"
 	valuePrepareStringName = "syscall/js.valuePrepareString"
 	valueLoadStringName    = "syscall/js.valueLoadString"
-	valueInstanceOfName    = "syscall/js.valueInstanceOf" // stubbed
+	valueInstanceOfName    = "syscall/js.valueInstanceOf"
 	copyBytesToGoName      = "syscall/js.copyBytesToGo"
 	copyBytesToJSName      = "syscall/js.copyBytesToJS"
 )
@@ -104,6 +105,8 @@ func valueGet(ctx context.Context, mod api.Module, sp []uint64) {
 			result = e.Error()
 		case "code": // syscall (GOARCH=wasm) error, must match key in mapJSError in fs_js.go
 			result = mapJSError(e).Error()
+		case "cause": // js (GOOS=js) error, optional in roundtrip_js.go
+			result = undefined
 		default:
 			panic(fmt.Errorf("TODO: valueGet(v=%v, p=%s)", v, p))
 		}
@@ -156,14 +159,41 @@ func valueSet(ctx context.Context, mod api.Module, sp []uint64) {
 	} else if m, ok := v.(*object); ok {
 		m.properties[p] = x // e.g. opt.Set("method", req.Method)
 		return
+	} else if g, ok := v.(*jsVal); ok && g.ref == refValueGlobal {
+		g.properties[p] = x // e.g. js.Global().Set("add", js.FuncOf(add))
+		return
 	}
 	panic(fmt.Errorf("TODO: valueSet(v=%v, p=%s, x=%v)", v, p, x))
 }
 
-// ValueDelete is stubbed as it isn't used in Go's main source tree.
+// ValueDelete implements js.valueDelete, which is used to delete a js.Value
+// property by name, e.g. `v.Delete("address")`.
 //
 // See https://github.com/golang/go/blob/go1.19/src/syscall/js/js.go#L321
-var ValueDelete = stubFunction(valueDeleteName)
+// and https://github.com/golang/go/blob/go1.19/misc/wasm/wasm_exec.js#L324-L328
+var ValueDelete = newSPFunc(valueDeleteName, valueDelete)
+
+func valueDelete(ctx context.Context, mod api.Module, sp []uint64) {
+	mem := mod.Memory()
+
+	// Read param count * 8 memory starting at SP+8
+	stack := mustRead(mem, "sp", uint32(sp[0]+8), 24)
+
+	vRef := le.Uint64(stack)
+	pAddr := le.Uint32(stack[8:])
+	pLen := le.Uint32(stack[16:])
+
+	v := loadValue(ctx, ref(vRef))
+	p := string(mustRead(mem, "p", pAddr, pLen))
+	if m, ok := v.(*object); ok {
+		delete(m.properties, p)
+		return
+	} else if g, ok := v.(*jsVal); ok && g.ref == refValueGlobal {
+		delete(g.properties, p)
+		return
+	}
+	panic(fmt.Errorf("TODO: valueDelete(v=%v, p=%s)", v, p))
+}
 
 // ValueIndex implements js.valueIndex, which is used to load a js.Value property
 // by index, e.g. `v.Index(0)`. Notably, this is used by js.handleEvent to read
@@ -183,7 +213,20 @@ func valueIndex(ctx context.Context, mod api.Module, sp []uint64) {
 	i := le.Uint32(stack[8:])
 
 	v := loadValue(ctx, ref(vRef))
-	result := v.(*objectArray).slice[i]
+
+	var result interface{} = undefined // like JavaScript, when out of range
+	switch v := v.(type) {
+	case *objectArray:
+		if i < uint32(len(v.slice)) {
+			result = v.slice[i]
+		}
+	case *byteArray:
+		if i < uint32(len(v.slice)) {
+			result = uint32(v.slice[i])
+		}
+	default:
+		panic(fmt.Errorf("TODO: valueIndex(v=%v, i=%d)", v, i))
+	}
 
 	ref := storeRef(ctx, result)
 
@@ -191,11 +234,42 @@ func valueIndex(ctx context.Context, mod api.Module, sp []uint64) {
 	le.PutUint64(stack[16:], ref)
 }
 
-// ValueSetIndex is stubbed as it is only used for js.ValueOf when the input is
-// []interface{}, which doesn't appear to occur in Go's source tree.
+// ValueSetIndex implements js.valueSetIndex, which is used to store a
+// js.Value property by index, e.g. `v.SetIndex(0, x)`. Notably, this is used
+// by js.ValueOf when the input is []interface{}.
 //
 // See https://github.com/golang/go/blob/go1.19/src/syscall/js/js.go#L348
-var ValueSetIndex = stubFunction(valueSetIndexName)
+// and https://github.com/golang/go/blob/go1.19/misc/wasm/wasm_exec.js#L336-L340
+var ValueSetIndex = newSPFunc(valueSetIndexName, valueSetIndex)
+
+func valueSetIndex(ctx context.Context, mod api.Module, sp []uint64) {
+	mem := mod.Memory()
+
+	// Read param count * 8 memory starting at SP+8
+	stack := mustRead(mem, "sp", uint32(sp[0]+8), 24)
+
+	vRef := le.Uint64(stack)
+	i := le.Uint32(stack[8:])
+	xRef := le.Uint64(stack[16:])
+
+	v := loadValue(ctx, ref(vRef))
+	x := loadValue(ctx, ref(xRef))
+	switch v := v.(type) {
+	case *objectArray:
+		// Like JavaScript, setting past the end grows the array.
+		for uint32(len(v.slice)) <= i {
+			v.slice = append(v.slice, undefined)
+		}
+		v.slice[i] = x
+	case *byteArray:
+		// Like JavaScript, setting past the end of a Uint8Array is ignored.
+		if i < uint32(len(v.slice)) {
+			v.slice[i] = byte(toUint32(x))
+		}
+	default:
+		panic(fmt.Errorf("TODO: valueSetIndex(v=%v, i=%d, x=%v)", v, i, x))
+	}
+}
 
 // ValueCall implements js.valueCall, which is used to call a js.Value function
 // by name, e.g. `document.Call("createElement", "div")`.
@@ -223,7 +297,12 @@ func valueCall(ctx context.Context, mod api.Module, sp []uint64) {
 
 	var xRef uint64
 	var ok uint32
-	if c, isCall := v.(jsCall); !isCall {
+	if e, isError := v.(error); isError && m == "toString" {
+		// js (GOOS=js) error, e.g. err.Call("toString") in roundtrip_js.go.
+		// This returns the message, as the error has no JavaScript type name.
+		xRef = storeRef(ctx, e.Error())
+		ok = 1
+	} else if c, isCall := v.(jsCall); !isCall {
 		panic(fmt.Errorf("TODO: valueCall(v=%v, m=%v, args=%v)", v, m, args))
 	} else if result, err := c.call(ctx, mod, this, m, args...); err != nil {
 		xRef = storeRef(ctx, err)
@@ -241,10 +320,55 @@ func valueCall(ctx context.Context, mod api.Module, sp []uint64) {
 	le.PutUint32(results[8:], ok)
 }
 
-// ValueInvoke is stubbed as it isn't used in Go's main source tree.
+// ValueInvoke implements js.valueInvoke, which is used to call a js.Value
+// function, e.g. `fn.Invoke(1, 2)`. Notably, this can invoke a function made
+// by js.FuncOf.
 //
 // See https://github.com/golang/go/blob/go1.19/src/syscall/js/js.go#L413
-var ValueInvoke = stubFunction(valueInvokeName)
+// and https://github.com/golang/go/blob/go1.19/misc/wasm/wasm_exec.js#L362-L377
+var ValueInvoke = newSPFunc(valueInvokeName, valueInvoke)
+
+func valueInvoke(ctx context.Context, mod api.Module, sp []uint64) {
+	mem := mod.Memory()
+
+	// Read param count * 8 memory starting at SP+8
+	params := mustRead(mem, "sp", uint32(sp[0]+8), 24)
+
+	vRef := le.Uint64(params)
+	argsArray := le.Uint32(params[8:])
+	argsLen := le.Uint32(params[16:])
+
+	args := loadArgs(ctx, mod, argsArray, argsLen)
+	v := loadValue(ctx, ref(vRef))
+
+	var result interface{}
+	var err error
+	switch v := v.(type) {
+	case funcWrapper:
+		result, err = v.invoke(ctx, mod, append([]interface{}{refValueUndefined}, args...)...)
+	case jsFn:
+		result, err = v.invoke(ctx, mod, args...)
+	default:
+		panic(fmt.Errorf("TODO: valueInvoke(v=%v, args=%v)", v, args))
+	}
+
+	var xRef uint64
+	var ok uint32
+	if err != nil {
+		xRef = storeRef(ctx, err)
+		ok = 0
+	} else {
+		xRef = storeRef(ctx, result)
+		ok = 1
+	}
+
+	// On refresh, start to write results 16 bytes after the last parameter.
+	results := mustRead(mem, "sp", refreshSP(mod)+40, 16)
+
+	// Write the results back to the stack
+	le.PutUint64(results, xRef)
+	le.PutUint32(results[8:], ok)
+}
 
 // ValueNew implements js.valueNew, which is used to call a js.Value, e.g.
 // `array.New(2)`.
@@ -272,6 +396,16 @@ func valueNew(ctx context.Context, mod api.Module, sp []uint64) {
 	switch ref {
 	case refArrayConstructor:
 		result := &objectArray{}
+		if len(args) == 1 {
+			// Like JavaScript, a single argument is the length, e.g. when
+			// js.ValueOf calls arrayConstructor.New(len(x)).
+			result.slice = make([]interface{}, toUint32(args[0]))
+			for i := range result.slice {
+				result.slice[i] = undefined
+			}
+		} else {
+			result.slice = args
+		}
 		xRef = storeRef(ctx, result)
 		ok = 1
 	case refUint8ArrayConstructor:
@@ -328,7 +462,16 @@ func valueLength(ctx context.Context, mod api.Module, sp []uint64) {
 	vRef := le.Uint64(stack)
 
 	v := loadValue(ctx, ref(vRef))
-	l := uint32(len(v.(*objectArray).slice))
+
+	var l uint32
+	switch v := v.(type) {
+	case *objectArray:
+		l = uint32(len(v.slice))
+	case *byteArray:
+		l = uint32(len(v.slice))
+	default:
+		panic(fmt.Errorf("TODO: valueLength(v=%v)", v))
+	}
 
 	// Write the results to memory at positions after the parameters.
 	le.PutUint32(stack[8:], l)
@@ -386,10 +529,52 @@ func valueLoadString(ctx context.Context, mod api.Module, sp []uint64) {
 	copy(b, s)
 }
 
-// ValueInstanceOf is stubbed as it isn't used in Go's main source tree.
+// ValueInstanceOf implements js.valueInstanceOf, which is used to check the
+// constructor of a js.Value, e.g. `v.InstanceOf(js.Global().Get("Array"))`.
 //
 // See https://github.com/golang/go/blob/go1.19/src/syscall/js/js.go#L543
-var ValueInstanceOf = stubFunction(valueInstanceOfName)
+// and https://github.com/golang/go/blob/go1.19/misc/wasm/wasm_exec.js#L414-L417
+var ValueInstanceOf = newSPFunc(valueInstanceOfName, valueInstanceOf)
+
+func valueInstanceOf(ctx context.Context, mod api.Module, sp []uint64) {
+	mem := mod.Memory()
+
+	// Read (param + result count) * 8 memory starting at SP+8
+	stack := mustRead(mem, "sp", uint32(sp[0]+8), 24)
+
+	vRef := ref(le.Uint64(stack))
+	tRef := ref(le.Uint64(stack[8:]))
+
+	var r uint32
+	if instanceOf(loadValue(ctx, vRef), vRef, tRef) {
+		r = 1
+	}
+
+	// Write the results to memory at positions after the parameters.
+	le.PutUint32(stack[16:], r)
+}
+
+// instanceOf returns true if the value v, stored as vRef, was made by the
+// constructor tRef.
+func instanceOf(v interface{}, vRef, tRef ref) bool {
+	switch tRef {
+	case refObjectConstructor:
+		// Like JavaScript, all objects and functions are instances of Object.
+		typeFlag := typeFlag((vRef >> 32) & 7)
+		isRef := (vRef>>32)&nanHead == nanHead
+		return isRef && (typeFlag == typeFlagObject || typeFlag == typeFlagFunction)
+	case refArrayConstructor:
+		_, ok := v.(*objectArray)
+		return ok
+	case refUint8ArrayConstructor:
+		_, ok := v.(*byteArray)
+		return ok
+	case refHttpHeadersConstructor:
+		_, ok := v.(*headers)
+		return ok
+	}
+	return false
+}
 
 // CopyBytesToGo copies a JavaScript managed byte array to linear memory.
 // For example, this is used to read an HTTP response body.
@@ -526,7 +711,7 @@ func mapJSError(err error) *syscallErr {
 // syscallOpen is like syscall.Open
 func syscallOpen(mod api.Module, name string, flags, perm uint32) (uint32, error) {
 	fsc := mod.(*wasm.CallContext).Sys.FS()
-	return fsc.OpenFile(name)
+	return fsc.OpenFile(name, os.O_RDONLY, 0)
 }
 
 // funcWrapper is the result of go's js.FuncOf ("_makeFuncWrapper" here).
@@ -542,11 +727,9 @@ func (f funcWrapper) invoke(ctx context.Context, mod api.Module, args ...interfa
 		e.args = &objectArray{args[1:]}
 		for i, v := range e.args.slice {
 			if s, ok := v.([]byte); ok {
-				args[i] = &byteArray{s}
+				e.args.slice[i] = &byteArray{s}
 			} else if s, ok := v.([]interface{}); ok {
-				args[i] = &objectArray{s}
-			} else if e, ok := v.(error); ok {
-				args[i] = e
+				e.args.slice[i] = &objectArray{s}
 			}
 		}
 	}
@@ -19,8 +19,9 @@ syscall.Getppid()=0
 syscall.Getuid()=0
 syscall.Getgid()=0
 syscall.Geteuid()=0
+syscall.Getegid()=0
 syscall.Umask(0077)=0o77
 syscall.Getgroups()=[0]
-os.FindProcess(pid)=&{1 0 0 {{0 0} 0 0 0 0}}
+os.FindProcess(pid).Pid=1
 `, stdout)
 }
@@ -17,7 +17,14 @@ func Main() {
 	}
 	fmt.Println(err)
 
-	res, err = http.Post(url, "text/plain", io.NopCloser(strings.NewReader("ice cream")))
+	req, err := http.NewRequest(http.MethodPost, url, io.NopCloser(strings.NewReader("ice cream")))
+	if err != nil {
+		log.Panicln(err)
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Add("Flavor", "vanilla")
+	req.Header.Add("Flavor", "chocolate")
+	res, err = http.DefaultClient.Do(req)
 	if err != nil {
 		log.Panicln(err)
 	}
@@ -27,6 +34,13 @@ func Main() {
 	}
 	res.Body.Close()
 
-	fmt.Println(res.Header.Get("Custom"))
+	fmt.Println(res.StatusCode, res.Header.Values("Custom"))
 	fmt.Println(string(body))
+
+	res, err = http.Get(url + "/redirect")
+	if err != nil {
+		log.Panicln(err)
+	}
+	res.Body.Close()
+	fmt.Println(res.Request.URL)
 }
@@ -0,0 +1,50 @@
+package js
+
+import (
+	"fmt"
+	"syscall/js"
+	"time"
+)
+
+func Main() {
+	global := js.Global()
+	fmt.Println("document:", global.Get("document").Type())
+
+	// js.ValueOf uses Object and Array constructors, Set and SetIndex.
+	obj := js.ValueOf(map[string]interface{}{
+		"name":  "wazero",
+		"stars": 1,
+		"tags":  []interface{}{"go", true},
+	})
+	fmt.Println("obj.name:", obj.Get("name").String())
+	fmt.Println("obj.stars:", obj.Get("stars").Int())
+	tags := obj.Get("tags")
+	fmt.Println("obj.tags.length:", tags.Length(), tags.Index(0).String(), tags.Index(1).Bool())
+	fmt.Println("obj.tags instanceof Array:", tags.InstanceOf(global.Get("Array")))
+	fmt.Println("obj instanceof Object:", obj.InstanceOf(global.Get("Object")))
+	fmt.Println("obj instanceof Array:", obj.InstanceOf(global.Get("Array")))
+	obj.Delete("name")
+	fmt.Println("obj.name after delete:", obj.Get("name").Type())
+
+	// Uint8Array supports indexed access.
+	buf := global.Get("Uint8Array").New(2)
+	buf.SetIndex(1, 7)
+	fmt.Println("buf:", buf.Length(), buf.Index(0).Int(), buf.Index(1).Int())
+
+	// Callbacks made by js.FuncOf can be invoked from a property or directly.
+	add := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		return args[0].Int() + args[1].Int()
+	})
+	defer add.Release()
+	global.Set("add", add)
+	fmt.Println("add(1, 2):", global.Call("add", 1, 2).Int())
+	fmt.Println("add.Invoke(3, 4):", add.Invoke(3, 4).Int())
+	obj.Set("add", add)
+	fmt.Println("obj.add(5, 6):", obj.Call("add", 5, 6).Int())
+	global.Delete("add")
+	fmt.Println("add after delete:", global.Get("add").Type())
+
+	// Timeouts resume the module after run returns.
+	time.Sleep(10 * time.Millisecond)
+	fmt.Println("slept")
+}
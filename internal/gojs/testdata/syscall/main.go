@@ -13,6 +13,7 @@ func Main() {
 	fmt.Printf("syscall.Getuid()=%d\n", syscall.Getuid())
 	fmt.Printf("syscall.Getgid()=%d\n", syscall.Getgid())
 	fmt.Printf("syscall.Geteuid()=%d\n", syscall.Geteuid())
+	fmt.Printf("syscall.Getegid()=%d\n", syscall.Getegid())
 	fmt.Printf("syscall.Umask(0077)=%O\n", syscall.Umask(0o077))
 	if g, err := syscall.Getgroups(); err != nil {
 		log.Panicln(err)
@@ -23,6 +24,6 @@ func Main() {
 	if p, err := os.FindProcess(syscall.Getpid()); err != nil {
 		log.Panicln(err)
 	} else {
-		fmt.Printf("os.FindProcess(pid)=%v\n", p)
+		fmt.Printf("os.FindProcess(pid).Pid=%d\n", p.Pid)
 	}
 }
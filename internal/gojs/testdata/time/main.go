@@ -9,4 +9,5 @@ func Main() {
 	fmt.Println(time.Local.String()) // trigger initLocal
 	t := time.Now()                  // uses walltime
 	fmt.Println(time.Since(t))       // uses nanotime1
+	fmt.Println(t.Zone())            // uses getTimezoneOffset
 }
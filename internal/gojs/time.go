@@ -2,8 +2,11 @@ package gojs
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/wasm"
 )
 
 var (
@@ -21,6 +24,24 @@ var (
 type getTimezoneOffset struct{}
 
 // invoke implements jsFn.invoke
+//
+// This returns the minutes to add to the local time to get UTC, in the time
+// zone named by the environment variable "TZ", e.g. set by
+// wazero.ModuleConfig WithTimezone. This is zero (UTC) if "TZ" is unset or
+// the host can't load it.
 func (*getTimezoneOffset) invoke(ctx context.Context, mod api.Module, args ...interface{}) (interface{}, error) {
+	sysCtx := mod.(*wasm.CallContext).Sys
+	for _, kv := range sysCtx.Environ() {
+		if !strings.HasPrefix(string(kv), "TZ=") {
+			continue
+		}
+		loc, err := time.LoadLocation(strings.TrimPrefix(string(kv[3:]), ":"))
+		if err != nil {
+			break
+		}
+		sec, nsec := sysCtx.Walltime()
+		_, offset := time.Unix(sec, int64(nsec)).In(loc).Zone()
+		return float64(-offset / 60), nil
+	}
 	return uint32(0), nil // UTC
 }
@@ -2,6 +2,7 @@ package gojs_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/tetratelabs/wazero"
 	"github.com/tetratelabs/wazero/internal/testing/require"
@@ -16,5 +17,24 @@ func Test_time(t *testing.T) {
 	require.Zero(t, stderr)
 	require.Equal(t, `Local
 1ms
+UTC+0 0
+`, stdout)
+}
+
+func Test_time_WithTimezone(t *testing.T) {
+	t.Parallel()
+
+	if _, err := time.LoadLocation("Asia/Tokyo"); err != nil {
+		t.Skip("host has no time zone database")
+	}
+
+	stdout, stderr, err := compileAndRun(testCtx, "time", wazero.NewModuleConfig().
+		WithTimezone("Asia/Tokyo"))
+
+	require.EqualError(t, err, `module "" closed with exit_code(0)`)
+	require.Zero(t, stderr)
+	require.Equal(t, `Local
+1ms
+UTC+9 32400
 `, stdout)
 }
@@ -3,6 +3,7 @@ package adhoc
 import (
 	"context"
 	_ "embed"
+	"errors"
 	"math"
 	"strconv"
 	"testing"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/tetratelabs/wazero"
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
 	"github.com/tetratelabs/wazero/internal/platform"
 	"github.com/tetratelabs/wazero/internal/testing/require"
 	"github.com/tetratelabs/wazero/internal/wasm"
@@ -42,6 +44,8 @@ var tests = map[string]func(t *testing.T, r wazero.Runtime){
 	"import functions with reference type in signature": testReftypeImports,
 	"overflow integer addition":                         testOverflow,
 	"un-signed extend global":                           testGlobalExtend,
+	"little-endian memory on any host":                  testByteOrder,
+	"fuel bounds an infinite loop":                      testFuel,
 }
 
 func TestEngineCompiler(t *testing.T) {
@@ -650,3 +654,115 @@ func testMultipleInstantiation(t *testing.T, r wazero.Runtime) {
 		require.Equal(t, uint64(1000), after)
 	}
 }
+
+// testByteOrder ensures memory is little-endian, as defined by the spec, even
+// on a big-endian host such as s390x.
+func testByteOrder(t *testing.T, r wazero.Runtime) {
+	v128 := wasm.ValueTypeV128
+	data := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	vecConst := append([]byte{wasm.OpcodeVecPrefix, wasm.OpcodeVecV128Const}, data...)
+	bin := binary.EncodeModule(&wasm.Module{
+		TypeSection: []*wasm.FunctionType{
+			{Results: []wasm.ValueType{i32}},
+			{Results: []wasm.ValueType{i64}},
+			{Results: []wasm.ValueType{v128}},
+			{},
+		},
+		FunctionSection: []wasm.Index{0, 1, 0, 1, 2, 3},
+		MemorySection:   &wasm.Memory{Min: 1, Cap: 1, Max: 1, IsMaxEncoded: true},
+		DataSection: []*wasm.DataSegment{{
+			OffsetExpression: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0}},
+			Init:             data,
+		}},
+		CodeSection: []*wasm.Code{
+			{Body: []byte{wasm.OpcodeI32Const, 0, wasm.OpcodeI32Load, 0x2, 0x0, wasm.OpcodeEnd}},
+			{Body: []byte{wasm.OpcodeI32Const, 0, wasm.OpcodeI64Load, 0x3, 0x0, wasm.OpcodeEnd}},
+			{Body: []byte{wasm.OpcodeI32Const, 0, wasm.OpcodeI32Load16U, 0x1, 0x0, wasm.OpcodeEnd}},
+			{Body: []byte{
+				wasm.OpcodeI32Const, 0,
+				wasm.OpcodeVecPrefix, wasm.OpcodeVecV128Load, 0x4, 0x0,
+				wasm.OpcodeVecPrefix, wasm.OpcodeVecI64x2ExtractLane, 1,
+				wasm.OpcodeEnd,
+			}},
+			{Body: append(vecConst, wasm.OpcodeEnd)},
+			{Body: append(append([]byte{
+				wasm.OpcodeI32Const, 16, wasm.OpcodeI32Const, 0xc4, 0xe6, 0x88, 0x89, 0x1, // 0x11223344
+				wasm.OpcodeI32Store, 0x2, 0x0,
+				wasm.OpcodeI32Const, 20, wasm.OpcodeF32Const, 0x0, 0x0, 0x80, 0x3f, // 1.0
+				wasm.OpcodeF32Store, 0x2, 0x0,
+				wasm.OpcodeI32Const, 24, wasm.OpcodeF64Const, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0xf0, 0x3f, // 1.0
+				wasm.OpcodeF64Store, 0x3, 0x0,
+				wasm.OpcodeI32Const, 32,
+			}, vecConst...),
+				wasm.OpcodeVecPrefix, wasm.OpcodeVecV128Store, 0x4, 0x0,
+				wasm.OpcodeEnd,
+			)},
+		},
+		ExportSection: []*wasm.Export{
+			{Name: "i32.load", Type: wasm.ExternTypeFunc, Index: 0},
+			{Name: "i64.load", Type: wasm.ExternTypeFunc, Index: 1},
+			{Name: "i32.load16_u", Type: wasm.ExternTypeFunc, Index: 2},
+			{Name: "v128.load", Type: wasm.ExternTypeFunc, Index: 3},
+			{Name: "v128.const", Type: wasm.ExternTypeFunc, Index: 4},
+			{Name: "store", Type: wasm.ExternTypeFunc, Index: 5},
+		},
+	})
+	module, err := r.InstantiateModuleFromBinary(testCtx, bin)
+	require.NoError(t, err)
+	defer module.Close(testCtx)
+
+	for _, tc := range []struct {
+		name     string
+		expected []uint64
+	}{
+		{name: "i32.load", expected: []uint64{0x04030201}},
+		{name: "i64.load", expected: []uint64{0x0807060504030201}},
+		{name: "i32.load16_u", expected: []uint64{0x0201}},
+		{name: "v128.load", expected: []uint64{0x100f0e0d0c0b0a09}},
+		{name: "v128.const", expected: []uint64{0x0807060504030201, 0x100f0e0d0c0b0a09}},
+	} {
+		results, err := module.ExportedFunction(tc.name).Call(testCtx)
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, results, tc.name)
+	}
+
+	_, err = module.ExportedFunction("store").Call(testCtx)
+	require.NoError(t, err)
+	stored, ok := module.Memory().Read(16, 32)
+	require.True(t, ok)
+	require.Equal(t, append([]byte{
+		0x44, 0x33, 0x22, 0x11, // i32.store
+		0x0, 0x0, 0x80, 0x3f, // f32.store
+		0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0xf0, 0x3f, // f64.store
+	}, data...), stored) // v128.store
+}
+
+// testFuel ensures a metered loop consumes fuel on each iteration, so traps
+// once it runs out instead of running forever.
+func testFuel(t *testing.T, r wazero.Runtime) {
+	bin := binary.EncodeModule(&wasm.Module{
+		TypeSection:     []*wasm.FunctionType{{}},
+		FunctionSection: []wasm.Index{0},
+		CodeSection: []*wasm.Code{{Body: []byte{
+			wasm.OpcodeLoop, 0x40,
+			wasm.OpcodeBr, 0,
+			wasm.OpcodeEnd,
+			wasm.OpcodeEnd,
+		}}},
+		ExportSection: []*wasm.Export{{Name: "loop", Type: wasm.ExternTypeFunc, Index: 0}},
+	})
+
+	fuel := &experimental.Fuel{Remaining: 1000}
+	ctx := context.WithValue(testCtx, experimental.FuelKey{}, fuel)
+	compiled, err := r.CompileModule(ctx, bin)
+	require.NoError(t, err)
+	module, err := r.InstantiateModule(ctx, compiled, moduleConfig)
+	require.NoError(t, err)
+	defer module.Close(testCtx)
+
+	_, err = module.ExportedFunction("loop").Call(ctx)
+	var trapErr *sys.TrapError
+	require.True(t, errors.As(err, &trapErr))
+	require.Equal(t, sys.TrapKindFuelExhausted, trapErr.Kind())
+	require.Zero(t, fuel.Remaining)
+}
@@ -3,6 +3,7 @@ package platform
 import (
 	"io"
 	"math/rand"
+	"syscall"
 )
 
 // seed is a fixed seed value for NewFakeRandSource.
@@ -13,5 +14,26 @@ const seed = int64(42)
 
 // NewFakeRandSource returns a deterministic source of random values.
 func NewFakeRandSource() io.Reader {
+	return NewSeededRandSource(seed)
+}
+
+// NewSeededRandSource returns a deterministic source of random values, which
+// returns the same bytes for the same seed, regardless of how they are split
+// across reads.
+//
+// Note: This uses math/rand, whose sequence for a seed is covered by the Go 1
+// compatibility promise, so it is the same on all platforms and versions.
+func NewSeededRandSource(seed int64) io.Reader {
 	return rand.New(rand.NewSource(seed))
 }
+
+// NoRandSource is a source of random values that fails each read with
+// syscall.ENOTSUP.
+var NoRandSource io.Reader = noRandSource{}
+
+type noRandSource struct{}
+
+// Read implements io.Reader
+func (noRandSource) Read([]byte) (int, error) {
+	return 0, syscall.ENOTSUP
+}
@@ -0,0 +1,37 @@
+package platform
+
+import (
+	"io"
+	"syscall"
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestNewSeededRandSource(t *testing.T) {
+	// The sequence for a seed must never change, as users rely on it.
+	buf := make([]byte, 5)
+	_, err := io.ReadFull(NewSeededRandSource(42), buf)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x53, 0x8c, 0x7f, 0x96, 0xb1}, buf)
+
+	// The same bytes are returned regardless of how reads are split.
+	r := NewSeededRandSource(42)
+	split := make([]byte, 5)
+	for _, b := range [][]byte{split[:1], split[1:4], split[4:]} {
+		_, err = io.ReadFull(r, b)
+		require.NoError(t, err)
+	}
+	require.Equal(t, buf, split)
+
+	// A different seed returns different bytes.
+	_, err = io.ReadFull(NewSeededRandSource(43), split)
+	require.NoError(t, err)
+	require.NotEqual(t, buf, split)
+}
+
+func TestNoRandSource(t *testing.T) {
+	n, err := NoRandSource.Read(make([]byte, 5))
+	require.Zero(t, n)
+	require.ErrorIs(t, err, syscall.ENOTSUP)
+}
@@ -0,0 +1,19 @@
+package platform
+
+import (
+	"errors"
+	"syscall"
+)
+
+// UnwrapOSError returns the syscall.Errno wrapped by err, or zero if there
+// isn't one, e.g. from an fs.PathError returned by os.OpenFile.
+//
+// Windows error codes are adjusted to their POSIX equivalent where one
+// exists, so callers can map errors the same way on all platforms.
+func UnwrapOSError(err error) syscall.Errno {
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		return adjustErrno(errno)
+	}
+	return 0
+}
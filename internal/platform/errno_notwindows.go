@@ -0,0 +1,9 @@
+//go:build !windows
+
+package platform
+
+import "syscall"
+
+func adjustErrno(err syscall.Errno) syscall.Errno {
+	return err
+}
@@ -0,0 +1,52 @@
+package platform
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"syscall"
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestUnwrapOSError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected syscall.Errno
+	}{
+		{name: "nil"},
+		{name: "not an errno", err: errors.New("ice cream")},
+		{name: "errno", err: syscall.EISDIR, expected: syscall.EISDIR},
+		{
+			name:     "wrapped errno",
+			err:      &fs.PathError{Op: "open", Path: "foo", Err: syscall.EROFS},
+			expected: syscall.EROFS,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, UnwrapOSError(tc.err))
+		})
+	}
+}
+
+func TestUnwrapOSError_OpenFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	file := path.Join(tmpDir, "file")
+	require.NoError(t, os.WriteFile(file, nil, 0o600))
+
+	t.Run("exists", func(t *testing.T) {
+		_, err := os.OpenFile(file, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0o600)
+		require.Equal(t, syscall.EEXIST, UnwrapOSError(err))
+	})
+
+	t.Run("not exist", func(t *testing.T) {
+		_, err := os.OpenFile(path.Join(tmpDir, "missing"), os.O_RDONLY, 0)
+		require.Equal(t, syscall.ENOENT, UnwrapOSError(err))
+	})
+}
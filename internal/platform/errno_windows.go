@@ -0,0 +1,33 @@
+package platform
+
+import "syscall"
+
+// See https://learn.microsoft.com/en-us/windows/win32/debug/system-error-codes--0-499-
+const (
+	_ERROR_FILE_NOT_FOUND       = syscall.Errno(0x2)
+	_ERROR_PATH_NOT_FOUND       = syscall.Errno(0x3)
+	_ERROR_ACCESS_DENIED        = syscall.Errno(0x5)
+	_ERROR_FILE_EXISTS          = syscall.Errno(0x50)
+	_ERROR_DIR_NOT_EMPTY        = syscall.Errno(0x91)
+	_ERROR_ALREADY_EXISTS       = syscall.Errno(0xB7)
+	_ERROR_FILENAME_EXCED_RANGE = syscall.Errno(0xCE)
+	_ERROR_DIRECTORY            = syscall.Errno(0x10B)
+)
+
+func adjustErrno(err syscall.Errno) syscall.Errno {
+	switch err {
+	case _ERROR_FILE_NOT_FOUND, _ERROR_PATH_NOT_FOUND:
+		return syscall.ENOENT
+	case _ERROR_ACCESS_DENIED:
+		return syscall.EACCES
+	case _ERROR_FILE_EXISTS, _ERROR_ALREADY_EXISTS:
+		return syscall.EEXIST
+	case _ERROR_DIR_NOT_EMPTY:
+		return syscall.ENOTEMPTY
+	case _ERROR_FILENAME_EXCED_RANGE:
+		return syscall.ENAMETOOLONG
+	case _ERROR_DIRECTORY:
+		return syscall.ENOTDIR // the directory name is invalid
+	}
+	return err
+}
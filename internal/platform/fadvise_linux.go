@@ -0,0 +1,12 @@
+//go:build linux && (amd64 || arm64 || riscv64)
+
+package platform
+
+import "syscall"
+
+func fadvise(fd uintptr, offset, length int64, advice int) error {
+	if _, _, errno := syscall.Syscall6(syscall.SYS_FADVISE64, fd, uintptr(offset), uintptr(length), uintptr(advice), 0, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
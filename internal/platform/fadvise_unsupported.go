@@ -0,0 +1,7 @@
+//go:build !(linux && (amd64 || arm64 || riscv64))
+
+package platform
+
+func fadvise(fd uintptr, offset, length int64, advice int) error {
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build darwin || linux || freebsd
+
+package platform
+
+import "syscall"
+
+func flock(fd uintptr, exclusive bool) error {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	return syscall.Flock(int(fd), how|syscall.LOCK_NB)
+}
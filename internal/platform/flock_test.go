@@ -0,0 +1,56 @@
+package platform
+
+import (
+	"os"
+	"path"
+	"runtime"
+	"syscall"
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func Test_Flock(t *testing.T) {
+	name := path.Join(t.TempDir(), "foo")
+	require.NoError(t, os.WriteFile(name, []byte("wazero"), 0o600))
+
+	f1, err := os.Open(name)
+	require.NoError(t, err)
+	defer f1.Close()
+	f2, err := os.Open(name)
+	require.NoError(t, err)
+	defer f2.Close()
+
+	switch runtime.GOOS {
+	case "darwin", "linux", "freebsd":
+	default:
+		require.Equal(t, syscall.ENOSYS, Flock(f1.Fd(), true))
+		return
+	}
+
+	// Shared locks don't conflict.
+	require.NoError(t, Flock(f1.Fd(), false))
+	require.NoError(t, Flock(f2.Fd(), false))
+
+	// An exclusive lock conflicts with a shared lock on another open file.
+	require.Equal(t, syscall.EWOULDBLOCK, Flock(f1.Fd(), true))
+
+	// Closing the other file releases its lock.
+	require.NoError(t, f2.Close())
+	require.NoError(t, Flock(f1.Fd(), true))
+}
+
+func Test_Fadvise(t *testing.T) {
+	name := path.Join(t.TempDir(), "foo")
+	require.NoError(t, os.WriteFile(name, []byte("wazero"), 0o600))
+
+	f, err := os.Open(name)
+	require.NoError(t, err)
+	defer f.Close()
+
+	for _, advice := range []int{
+		FadviseNormal, FadviseRandom, FadviseSequential, FadviseWillNeed, FadviseDontNeed, FadviseNoReuse,
+	} {
+		require.NoError(t, Fadvise(f.Fd(), 0, 0, advice))
+	}
+}
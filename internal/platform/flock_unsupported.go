@@ -0,0 +1,9 @@
+//go:build !(darwin || linux || freebsd)
+
+package platform
+
+import "syscall"
+
+func flock(fd uintptr, exclusive bool) error {
+	return syscall.ENOSYS
+}
@@ -0,0 +1,13 @@
+//go:build darwin || linux || freebsd
+
+package platform
+
+import "syscall"
+
+// O_NOFOLLOW makes os.OpenFile fail with syscall.ELOOP, instead of following
+// the name when it is a symbolic link.
+const O_NOFOLLOW = syscall.O_NOFOLLOW
+
+// O_NONBLOCK is the flag which makes reads and writes return syscall.EAGAIN,
+// instead of blocking.
+const O_NONBLOCK = syscall.O_NONBLOCK
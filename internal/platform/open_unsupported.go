@@ -0,0 +1,12 @@
+//go:build !(darwin || linux || freebsd)
+
+package platform
+
+// O_NOFOLLOW is zero as this platform doesn't support it.
+const O_NOFOLLOW = 0
+
+// O_NONBLOCK is the same value as syscall.O_NONBLOCK on Windows, as some
+// platforms, such as js, don't define it. This is only recorded, e.g. via
+// internalsys.FSContext SetFlag, so the value only needs to not conflict with
+// other flags.
+const O_NONBLOCK = 0o4000
@@ -53,3 +53,35 @@ func MunmapCodeSegment(code []byte) error {
 func IsTerminal(fd uintptr) bool {
 	return isTerminal(fd)
 }
+
+// TerminalSize returns the count of rows and columns of the terminal of the
+// given file descriptor, or syscall.ENOTTY if it isn't a terminal.
+func TerminalSize(fd uintptr) (rows, columns uint16, err error) {
+	return terminalSize(fd)
+}
+
+// Advice values for Fadvise, which are the same as POSIX_FADV_* on Linux.
+const (
+	FadviseNormal = iota
+	FadviseRandom
+	FadviseSequential
+	FadviseWillNeed
+	FadviseDontNeed
+	FadviseNoReuse
+)
+
+// Fadvise declares how the data at offset and length in the given file
+// descriptor will be accessed, where length zero means until the end of the
+// file. As advice is only a hint, this returns nil without side effects where
+// unsupported.
+func Fadvise(fd uintptr, offset, length int64, advice int) error {
+	return fadvise(fd, offset, length, advice)
+}
+
+// Flock places an advisory lock on the given file descriptor, exclusive or
+// shared, which is released when it is closed. This doesn't block: it returns
+// syscall.EWOULDBLOCK if another open file holds a conflicting lock, or
+// syscall.ENOSYS where unsupported.
+func Flock(fd uintptr, exclusive bool) error {
+	return flock(fd, exclusive)
+}
@@ -0,0 +1,19 @@
+package platform
+
+import "time"
+
+// PollRead waits until any of the given host file descriptors are readable or
+// the timeout elapses. A negative timeout waits indefinitely, and zero returns
+// immediately. The result has the same length as fds, and is true for each
+// descriptor which is readable.
+//
+// Note: On platforms which can't wait on file descriptors, or for descriptors
+// that can't be waited on, the descriptor is reported readable. This means a
+// subsequent read could block, but a guest event loop won't spin.
+func PollRead(fds []uintptr, timeout time.Duration) ([]bool, error) {
+	ready := make([]bool, len(fds))
+	if len(fds) == 0 {
+		return ready, nil
+	}
+	return ready, pollRead(fds, timeout, ready)
+}
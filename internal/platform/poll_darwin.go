@@ -0,0 +1,7 @@
+package platform
+
+import "syscall"
+
+func selectRead(nfd int, r *syscall.FdSet, timeout *syscall.Timeval) error {
+	return syscall.Select(nfd, r, nil, nil, timeout)
+}
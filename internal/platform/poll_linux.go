@@ -0,0 +1,8 @@
+package platform
+
+import "syscall"
+
+func selectRead(nfd int, r *syscall.FdSet, timeout *syscall.Timeval) error {
+	_, err := syscall.Select(nfd, r, nil, nil, timeout)
+	return err
+}
@@ -0,0 +1,65 @@
+//go:build darwin || linux
+
+package platform
+
+import (
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+func pollRead(fds []uintptr, timeout time.Duration, ready []bool) error {
+	var set syscall.FdSet
+	bitsPerWord := uintptr(8 * unsafe.Sizeof(set.Bits[0]))
+	maxFd := uintptr(len(set.Bits)) * bitsPerWord
+
+	nfd, waiting := 0, false
+	for i, fd := range fds {
+		if fd >= maxFd { // can't be waited on with select.
+			ready[i] = true
+			continue
+		}
+		set.Bits[fd/bitsPerWord] |= 1 << (fd % bitsPerWord)
+		if int(fd) >= nfd {
+			nfd = int(fd) + 1
+		}
+		waiting = true
+	}
+	if !waiting {
+		return nil
+	} else if anyTrue(ready) {
+		timeout = 0 // something is already ready, so don't wait.
+	}
+
+	var tv *syscall.Timeval
+	if timeout >= 0 {
+		t := syscall.NsecToTimeval(int64(timeout))
+		tv = &t
+	}
+	// select overwrites the set, so pass a copy in case it is interrupted.
+	var result syscall.FdSet
+	for {
+		result = set
+		if err := selectRead(nfd, &result, tv); err == nil {
+			break
+		} else if err != syscall.EINTR {
+			return err
+		}
+	}
+
+	for i, fd := range fds {
+		if fd < maxFd && result.Bits[fd/bitsPerWord]&(1<<(fd%bitsPerWord)) != 0 {
+			ready[i] = true
+		}
+	}
+	return nil
+}
+
+func anyTrue(bs []bool) bool {
+	for _, b := range bs {
+		if b {
+			return true
+		}
+	}
+	return false
+}
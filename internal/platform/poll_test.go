@@ -0,0 +1,46 @@
+package platform
+
+import (
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestPollRead(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip() // because it always reports readable
+	}
+
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer r.Close()
+	defer w.Close()
+
+	t.Run("empty", func(t *testing.T) {
+		ready, err := PollRead(nil, -1)
+		require.NoError(t, err)
+		require.Equal(t, 0, len(ready))
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		ready, err := PollRead([]uintptr{r.Fd()}, time.Millisecond)
+		require.NoError(t, err)
+		require.Equal(t, []bool{false}, ready)
+	})
+
+	t.Run("readable", func(t *testing.T) {
+		_, err := w.Write([]byte{'a'})
+		require.NoError(t, err)
+		defer func() {
+			_, err := r.Read(make([]byte, 1))
+			require.NoError(t, err)
+		}()
+
+		ready, err := PollRead([]uintptr{w.Fd(), r.Fd()}, -1)
+		require.NoError(t, err)
+		require.Equal(t, []bool{false, true}, ready)
+	})
+}
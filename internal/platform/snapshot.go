@@ -0,0 +1,53 @@
+//go:build darwin || linux || freebsd
+
+package platform
+
+import (
+	"os"
+	"syscall"
+)
+
+// Snapshot is an immutable copy of a memory, which Map maps copy-on-write.
+// This means its pages are shared by all mappings until written.
+type Snapshot struct {
+	f    *os.File
+	size int
+}
+
+// NewSnapshot returns a snapshot of buf, padded with zeros to size. Close
+// releases it, but not its mappings.
+func NewSnapshot(buf []byte, size int) (*Snapshot, error) {
+	f, err := os.CreateTemp("", "wazero-snapshot")
+	if err != nil {
+		return nil, err
+	}
+	// The file is only used through its descriptor, so it's removed
+	// immediately to ensure it's deleted once closed and unmapped.
+	if err = os.Remove(f.Name()); err == nil {
+		// Truncating first makes the padding a hole, which takes no space.
+		if err = f.Truncate(int64(size)); err == nil {
+			_, err = f.Write(buf)
+		}
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return &Snapshot{f: f, size: size}, nil
+}
+
+// Map returns a private mapping of the snapshot, whose writes aren't visible
+// to other mappings. It must be released with Unmap.
+func (s *Snapshot) Map() ([]byte, error) {
+	return syscall.Mmap(int(s.f.Fd()), 0, s.size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE)
+}
+
+// Close releases the snapshot.
+func (s *Snapshot) Close() error {
+	return s.f.Close()
+}
+
+// Unmap releases a mapping returned by Snapshot.Map.
+func Unmap(mapping []byte) error {
+	return syscall.Munmap(mapping)
+}
@@ -0,0 +1,34 @@
+package platform
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestSnapshot(t *testing.T) {
+	s, err := NewSnapshot([]byte("hello"), 4096)
+	require.NoError(t, err)
+
+	m1, err := s.Map()
+	require.NoError(t, err)
+	defer Unmap(m1)
+
+	// Closing the snapshot doesn't affect its mappings.
+	m2, err := s.Map()
+	require.NoError(t, err)
+	defer Unmap(m2)
+	require.NoError(t, s.Close())
+
+	require.Equal(t, 4096, len(m1))
+	require.Equal(t, "hello\x00\x00\x00", string(m1[:8]))
+	require.Equal(t, byte(0), m1[4095])
+
+	// Writes aren't visible to other mappings.
+	copy(m1, "world")
+	m2[4095] = 1
+	require.Equal(t, "world", string(m1[:5]))
+	require.Equal(t, byte(0), m1[4095])
+	require.Equal(t, "hello", string(m2[:5]))
+	require.Equal(t, byte(1), m2[4095])
+}
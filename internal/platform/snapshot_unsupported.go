@@ -0,0 +1,34 @@
+//go:build !(darwin || linux || freebsd)
+
+package platform
+
+// Snapshot is an immutable copy of a memory. As copy-on-write mappings aren't
+// supported on this platform, Map copies it.
+type Snapshot struct {
+	buf  []byte
+	size int
+}
+
+// NewSnapshot returns a snapshot of buf, padded with zeros to size. Close
+// releases it, but not its mappings.
+func NewSnapshot(buf []byte, size int) (*Snapshot, error) {
+	return &Snapshot{buf: append([]byte(nil), buf...), size: size}, nil
+}
+
+// Map returns a copy of the snapshot. It must be released with Unmap.
+func (s *Snapshot) Map() ([]byte, error) {
+	ret := make([]byte, s.size)
+	copy(ret, s.buf)
+	return ret, nil
+}
+
+// Close releases the snapshot.
+func (s *Snapshot) Close() error {
+	s.buf = nil
+	return nil
+}
+
+// Unmap releases a mapping returned by Snapshot.Map.
+func Unmap([]byte) error {
+	return nil
+}
@@ -0,0 +1,12 @@
+//go:build darwin || linux || freebsd
+
+package platform
+
+import "syscall"
+
+// IsDatagramSocket returns true if the host file descriptor is a socket of
+// type SOCK_DGRAM, such as UDP.
+func IsDatagramSocket(fd uintptr) bool {
+	typ, err := syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_TYPE)
+	return err == nil && typ == syscall.SOCK_DGRAM
+}
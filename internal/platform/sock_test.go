@@ -0,0 +1,33 @@
+package platform
+
+import (
+	"net"
+	"runtime"
+	"syscall"
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestIsDatagramSocket(t *testing.T) {
+	if runtime.GOOS != "darwin" && runtime.GOOS != "linux" && runtime.GOOS != "freebsd" {
+		t.Skip("unsupported on " + runtime.GOOS)
+	}
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer pc.Close()
+	require.True(t, IsDatagramSocket(requireFd(t, pc.(syscall.Conn))))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	require.False(t, IsDatagramSocket(requireFd(t, l.(syscall.Conn))))
+}
+
+func requireFd(t *testing.T, c syscall.Conn) (fd uintptr) {
+	rc, err := c.SyscallConn()
+	require.NoError(t, err)
+	require.NoError(t, rc.Control(func(f uintptr) { fd = f }))
+	return
+}
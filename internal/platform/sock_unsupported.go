@@ -0,0 +1,8 @@
+//go:build !(darwin || linux || freebsd)
+
+package platform
+
+// IsDatagramSocket returns false as this platform doesn't support it.
+func IsDatagramSocket(uintptr) bool {
+	return false
+}
@@ -12,3 +12,16 @@ func isTerminal(fd uintptr) bool {
 	_, _, err := syscall.Syscall6(syscall.SYS_IOCTL, fd, ioctlReadTermios, uintptr(unsafe.Pointer(&val)), 0, 0, 0)
 	return err == 0
 }
+
+// winsize is the struct winsize in POSIX <sys/ioctl.h>.
+type winsize struct {
+	row, col, xpixel, ypixel uint16
+}
+
+func terminalSize(fd uintptr) (rows, columns uint16, err error) {
+	var ws winsize
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&ws))); errno != 0 {
+		return 0, 0, errno
+	}
+	return ws.row, ws.col, nil
+}
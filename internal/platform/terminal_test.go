@@ -3,6 +3,7 @@ package platform
 import (
 	"os"
 	"path"
+	"syscall"
 	"testing"
 
 	"github.com/tetratelabs/wazero/internal/testing/require"
@@ -42,3 +43,27 @@ func Test_IsTerminal(t *testing.T) {
 		})
 	}
 }
+
+func Test_TerminalSize(t *testing.T) {
+	if !CompilerSupported() {
+		t.Skip() // because it will always return ENOTTY
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(path.Join(dir, "foo"), nil, 0o400))
+	file, err := os.Open(path.Join(dir, "foo"))
+	require.NoError(t, err)
+	defer file.Close()
+
+	_, _, err = TerminalSize(file.Fd())
+	require.ErrorIs(t, err, syscall.ENOTTY)
+
+	// As in Test_IsTerminal, stdout may or may not be a terminal.
+	rows, columns, err := TerminalSize(os.Stdout.Fd())
+	if IsTerminal(os.Stdout.Fd()) {
+		require.NoError(t, err)
+		t.Log(rows, columns) // may be zero, e.g. on a serial console
+	} else {
+		require.ErrorIs(t, err, syscall.ENOTTY)
+	}
+}
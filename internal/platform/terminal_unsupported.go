@@ -2,6 +2,12 @@
 
 package platform
 
+import "syscall"
+
 func isTerminal(fd uintptr) bool {
 	return false
 }
+
+func terminalSize(fd uintptr) (rows, columns uint16, err error) {
+	return 0, 0, syscall.ENOTTY
+}
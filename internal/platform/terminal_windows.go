@@ -5,10 +5,35 @@ import (
 	"unsafe"
 )
 
-var procGetConsoleMode = kernel32.NewProc("GetConsoleMode")
+var (
+	procGetConsoleMode             = kernel32.NewProc("GetConsoleMode")
+	procGetConsoleScreenBufferInfo = kernel32.NewProc("GetConsoleScreenBufferInfo")
+)
 
 func isTerminal(fd uintptr) bool {
 	var st uint32
 	r, _, e := syscall.Syscall(procGetConsoleMode.Addr(), 2, uintptr(fd), uintptr(unsafe.Pointer(&st)), 0)
 	return r != 0 && e == 0
 }
+
+// consoleScreenBufferInfo is the CONSOLE_SCREEN_BUFFER_INFO struct in
+// <wincon.h>. Each field is a SHORT, where the coordinates are inclusive.
+type consoleScreenBufferInfo struct {
+	sizeX, sizeY                           int16
+	cursorPositionX, cursorPositionY       int16
+	attributes                             uint16
+	windowLeft, windowTop                  int16
+	windowRight, windowBottom              int16
+	maximumWindowSizeX, maximumWindowSizeY int16
+}
+
+func terminalSize(fd uintptr) (rows, columns uint16, err error) {
+	var info consoleScreenBufferInfo
+	r, _, _ := syscall.Syscall(procGetConsoleScreenBufferInfo.Addr(), 2, fd, uintptr(unsafe.Pointer(&info)), 0)
+	if r == 0 {
+		return 0, 0, syscall.ENOTTY // e.g. ERROR_INVALID_HANDLE
+	}
+	rows = uint16(info.windowBottom - info.windowTop + 1)
+	columns = uint16(info.windowRight - info.windowLeft + 1)
+	return
+}
@@ -0,0 +1,25 @@
+package platform
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var procGetThreadTimes = kernel32.NewProc("GetThreadTimes")
+
+// currentThread is the pseudo handle GetCurrentThread returns.
+const currentThread = ^uintptr(1)
+
+func threadCputime() int64 {
+	var creation, exit, kernel, user syscall.Filetime
+	// The current thread is a pseudo handle, so errors are ignored.
+	_, _, _ = syscall.Syscall6(procGetThreadTimes.Addr(), 5, currentThread,
+		uintptr(unsafe.Pointer(&creation)), uintptr(unsafe.Pointer(&exit)),
+		uintptr(unsafe.Pointer(&kernel)), uintptr(unsafe.Pointer(&user)), 0)
+	return (filetimeDuration(kernel) + filetimeDuration(user)) * 100
+}
+
+// filetimeDuration returns the count of 100-nanosecond intervals in ft.
+func filetimeDuration(ft syscall.Filetime) int64 {
+	return int64(ft.HighDateTime)<<32 | int64(ft.LowDateTime)
+}
@@ -36,6 +36,13 @@ func NewFakeNanotime() *sys.Nanotime {
 	return &nt
 }
 
+// NewFakeCputime implements sys.Cputime that increases by 1ms each reading.
+// See /RATIONALE.md
+func NewFakeCputime() *sys.Cputime {
+	ct := sys.Cputime(*NewFakeNanotime())
+	return &ct
+}
+
 // FakeNanosleep implements sys.Nanosleep by returning without sleeping.
 func FakeNanosleep(int64) {}
 
@@ -73,3 +80,12 @@ func Nanotime() int64 {
 func Nanosleep(ns int64) {
 	time.Sleep(time.Duration(ns))
 }
+
+// ThreadCputime returns nanoseconds of CPU time consumed by the current
+// thread, where supported, and Nanotime if not.
+//
+// Note: The goroutine must be locked to its thread with runtime.LockOSThread
+// for the difference of two readings to be the CPU time it consumed.
+func ThreadCputime() int64 {
+	return threadCputime()
+}
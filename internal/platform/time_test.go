@@ -1,6 +1,7 @@
 package platform
 
 import (
+	"runtime"
 	"testing"
 	"time"
 
@@ -77,3 +78,30 @@ func Test_Nanosleep(t *testing.T) {
 
 	require.True(t, duration > 0 && duration < max, "Nanosleep(%d) slept for %d", ns, duration)
 }
+
+func Test_NewFakeCputime(t *testing.T) {
+	ct := NewFakeCputime()
+
+	require.Equal(t, int64(0), (*ct)())
+
+	// next reading should increase by 1ms
+	require.Equal(t, int64(time.Millisecond), (*ct)())
+}
+
+func Test_ThreadCputime(t *testing.T) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	before := ThreadCputime()
+	time.Sleep(10 * time.Millisecond)
+	slept := ThreadCputime() - before
+	if runtime.GOOS == "linux" || runtime.GOOS == "windows" { // otherwise, this is Nanotime.
+		require.True(t, slept < int64(10*time.Millisecond), time.Duration(slept).String())
+	}
+
+	// Spin until the CPU time increases, which proves busy work is accounted.
+	deadline := time.Now().Add(5 * time.Second)
+	for ThreadCputime() <= before+slept {
+		require.True(t, time.Now().Before(deadline), "CPU time didn't increase")
+	}
+}
@@ -0,0 +1,64 @@
+package platform
+
+import (
+	"os"
+	"strings"
+)
+
+// zoneinfoDirs are where time zone databases are conventionally installed, in
+// the same order the time package searches them.
+var zoneinfoDirs = []string{
+	"/usr/share/zoneinfo",
+	"/usr/share/lib/zoneinfo",
+	"/usr/lib/locale/TZ",
+	"/etc/zoneinfo",
+}
+
+// Timezone returns the IANA name of the host's time zone, e.g.
+// "America/New_York", or "UTC" if unknown.
+//
+// This is read from the environment variable "TZ", or otherwise the target of
+// the symbolic link "/etc/localtime", which is typical on Linux and macOS.
+func Timezone() string {
+	if tz, ok := os.LookupEnv("TZ"); ok {
+		tz = strings.TrimPrefix(tz, ":")
+		if !strings.HasPrefix(tz, "/") {
+			if tz == "" {
+				return "UTC"
+			}
+			return tz
+		}
+		return zoneName(tz)
+	}
+	if target, err := os.Readlink("/etc/localtime"); err == nil {
+		return zoneName(target)
+	}
+	return "UTC"
+}
+
+// zoneName returns the IANA name of the time zone file at the given path,
+// e.g. "Europe/Paris" for "/usr/share/zoneinfo/Europe/Paris", or "UTC" if it
+// isn't in a zoneinfo directory.
+func zoneName(path string) string {
+	const dir = "zoneinfo/"
+	if i := strings.LastIndex(path, dir); i != -1 && len(path) > i+len(dir) {
+		return path[i+len(dir):]
+	}
+	return "UTC"
+}
+
+// ZoneinfoDir returns the host directory of the time zone database, or empty
+// if not found. This is the environment variable "ZONEINFO", if a directory,
+// or otherwise the first conventional location which exists.
+func ZoneinfoDir() string {
+	dirs := zoneinfoDirs
+	if dir := os.Getenv("ZONEINFO"); dir != "" {
+		dirs = append([]string{dir}, dirs...)
+	}
+	for _, dir := range dirs {
+		if st, err := os.Stat(dir); err == nil && st.IsDir() {
+			return dir
+		}
+	}
+	return ""
+}
@@ -0,0 +1,41 @@
+package platform
+
+import (
+	"os"
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func Test_Timezone(t *testing.T) {
+	tests := []struct {
+		name, tz, expected string
+	}{
+		{name: "name", tz: "America/New_York", expected: "America/New_York"},
+		{name: "colon name", tz: ":Europe/Paris", expected: "Europe/Paris"},
+		{name: "path", tz: "/usr/share/zoneinfo/Asia/Tokyo", expected: "Asia/Tokyo"},
+		{name: "colon path", tz: ":/usr/share/zoneinfo/Asia/Tokyo", expected: "Asia/Tokyo"},
+		{name: "path outside zoneinfo", tz: "/etc/localtime", expected: "UTC"},
+		{name: "empty", tz: "", expected: "UTC"},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("TZ", tc.tz)
+			require.Equal(t, tc.expected, Timezone())
+		})
+	}
+}
+
+func Test_ZoneinfoDir(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("ZONEINFO", dir)
+	require.Equal(t, dir, ZoneinfoDir())
+
+	// Files are skipped, as the time package also accepts a zip.
+	file := dir + "/zoneinfo.zip"
+	require.NoError(t, os.WriteFile(file, nil, 0o600))
+	t.Setenv("ZONEINFO", file)
+	require.NotEqual(t, file, ZoneinfoDir())
+}
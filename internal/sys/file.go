@@ -0,0 +1,73 @@
+package sys
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/tetratelabs/wazero/internal/platform"
+)
+
+// Advise declares how the data at offset and length in the given file
+// descriptor will be accessed, as defined by platform.Fadvise. This returns
+// syscall.EBADF if the file descriptor isn't open, and nil without side
+// effects if it isn't backed by a host file.
+func (c *FSContext) Advise(fd uint32, offset, length int64, advice int) error {
+	if _, ok := c.openedFile(fd); !ok {
+		return syscall.EBADF
+	}
+	if offset < 0 || length < 0 {
+		return syscall.EINVAL
+	}
+	if hostFd, ok := c.hostFd(fd); ok {
+		return platform.Fadvise(hostFd, offset, length, advice)
+	}
+	return nil
+}
+
+// Allocate ensures the file opened as the given file descriptor is at least
+// offset plus length bytes, by extending it with zeros. This is like
+// posix_fallocate, except disk space isn't reserved for existing holes.
+//
+// This returns syscall.EBADF if the file descriptor isn't open for writing,
+// or syscall.ENODEV if it isn't a regular file which can be truncated.
+func (c *FSContext) Allocate(fd uint32, offset, length int64) error {
+	f, ok := c.openedFile(fd)
+	if !ok || f.Flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return syscall.EBADF
+	}
+	if offset < 0 || length <= 0 {
+		return syscall.EINVAL
+	}
+	size := offset + length
+	if size < 0 {
+		return syscall.EFBIG
+	}
+
+	st, err := f.File.Stat()
+	if err != nil {
+		return err
+	}
+	truncater, ok := f.File.(interface{ Truncate(size int64) error })
+	if !ok || !st.Mode().IsRegular() {
+		return syscall.ENODEV
+	}
+	if st.Size() >= size {
+		return nil
+	}
+	return truncater.Truncate(size)
+}
+
+// Lock places an advisory lock on the file opened as the given file
+// descriptor, as defined by platform.Flock. This returns syscall.EBADF if the
+// file descriptor isn't open, or syscall.ENOSYS if it isn't backed by a host
+// file.
+func (c *FSContext) Lock(fd uint32, exclusive bool) error {
+	if _, ok := c.openedFile(fd); !ok {
+		return syscall.EBADF
+	}
+	hostFd, ok := c.hostFd(fd)
+	if !ok {
+		return syscall.ENOSYS
+	}
+	return platform.Flock(hostFd, exclusive)
+}
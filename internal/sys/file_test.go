@@ -0,0 +1,69 @@
+package sys
+
+import (
+	"os"
+	"path"
+	"syscall"
+	"testing"
+	"testing/fstest"
+
+	"github.com/tetratelabs/wazero/internal/sysfs"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestContext_Advise(t *testing.T) {
+	testFS, err := NewFSContext(nil, nil, nil, fstest.MapFS{"foo": {Data: []byte("wazero")}})
+	require.NoError(t, err)
+	defer testFS.Close(testCtx)
+
+	fd, err := testFS.OpenFile("foo", os.O_RDONLY, 0)
+	require.NoError(t, err)
+
+	// Files not on the host ignore advice.
+	require.NoError(t, testFS.Advise(fd, 0, 0, 0))
+	require.Equal(t, syscall.EINVAL, testFS.Advise(fd, -1, 0, 0))
+	require.Equal(t, syscall.EBADF, testFS.Advise(42, 0, 0, 0))
+}
+
+func TestContext_Allocate(t *testing.T) {
+	tmpDir := t.TempDir()
+	name := path.Join(tmpDir, "foo")
+	require.NoError(t, os.WriteFile(name, []byte("wazero"), 0o600))
+
+	testFS, err := NewFSContext(nil, nil, nil, sysfs.NewDirFS(tmpDir))
+	require.NoError(t, err)
+	defer testFS.Close(testCtx)
+
+	fd, err := testFS.OpenFile("foo", os.O_RDWR, 0)
+	require.NoError(t, err)
+
+	require.NoError(t, testFS.Allocate(fd, 6, 2))
+	b, err := os.ReadFile(name)
+	require.NoError(t, err)
+	require.Equal(t, []byte("wazero\x00\x00"), b)
+
+	// Doesn't shrink the file.
+	require.NoError(t, testFS.Allocate(fd, 0, 1))
+	b, err = os.ReadFile(name)
+	require.NoError(t, err)
+	require.Equal(t, 8, len(b))
+
+	require.Equal(t, syscall.EINVAL, testFS.Allocate(fd, 0, 0))
+	require.Equal(t, syscall.EINVAL, testFS.Allocate(fd, -1, 1))
+	require.Equal(t, syscall.EFBIG, testFS.Allocate(fd, 1<<62, 1<<62))
+	require.Equal(t, syscall.EBADF, testFS.Allocate(42, 0, 1))
+	require.Equal(t, syscall.EBADF, testFS.Allocate(FdRoot, 0, 1))
+}
+
+func TestContext_Lock(t *testing.T) {
+	testFS, err := NewFSContext(nil, nil, nil, fstest.MapFS{"foo": {Data: []byte("wazero")}})
+	require.NoError(t, err)
+	defer testFS.Close(testCtx)
+
+	fd, err := testFS.OpenFile("foo", os.O_RDONLY, 0)
+	require.NoError(t, err)
+
+	// Files not on the host can't be locked.
+	require.Equal(t, syscall.ENOSYS, testFS.Lock(fd, true))
+	require.Equal(t, syscall.EBADF, testFS.Lock(42, true))
+}
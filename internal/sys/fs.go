@@ -9,11 +9,15 @@ import (
 	"math"
 	"os"
 	"path"
+	"sort"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/sysfs"
 )
 
 const (
@@ -161,6 +165,19 @@ type FileEntry struct {
 	// ReadDir is present when this File is a fs.ReadDirFile and `ReadDir`
 	// was called.
 	ReadDir *ReadDir
+
+	// Flag are the flags passed to OpenFile, e.g. os.O_RDWR|os.O_APPEND.
+	// This is zero (os.O_RDONLY) for pre-opened files.
+	Flag int
+
+	// RightsBase and RightsInheriting are the WASI rights requested when
+	// this file was opened. When both are zero, such as pre-opened files,
+	// rights are defaulted based on the file type.
+	RightsBase, RightsInheriting uint64
+
+	// openPath is the path File was opened with, used by RewindDir. This is
+	// empty when File isn't in the FS, such as stdio or sockets.
+	openPath string
 }
 
 // ReadDir is the status of a prior fs.ReadDirFile call.
@@ -169,8 +186,9 @@ type ReadDir struct {
 	CountRead uint64
 
 	// Entries is the contents of the last fs.ReadDirFile call. Notably,
-	// directory listing are not rewindable, so we keep entries around in case
-	// the caller mis-estimated their buffer and needs a few still cached.
+	// rewinding a directory listing requires re-opening it, so we keep
+	// entries around in case the caller mis-estimated their buffer and needs
+	// a few still cached.
 	Entries []fs.DirEntry
 }
 
@@ -180,9 +198,13 @@ type FSContext struct {
 
 	// openedFiles is a map of file descriptor numbers (>=FdRoot) to open files
 	// (or directories) and defaults to empty.
-	// TODO: This is unguarded, so not goroutine-safe!
 	openedFiles map[uint32]*FileEntry
 
+	// openedFilesMux guards openedFiles, as threads share this context.
+	//
+	// Note: This doesn't guard the fields of a FileEntry.
+	openedFilesMux sync.RWMutex
+
 	// lastFD is not meant to be read directly. Rather by nextFD.
 	lastFD uint32
 }
@@ -232,7 +254,7 @@ func NewFSContext(stdin io.Reader, stdout, stderr io.Writer, root fs.FS) (fsc *F
 		return
 	}
 
-	fsc.openedFiles[FdRoot] = &FileEntry{Name: "/", File: rootDir}
+	fsc.openedFiles[FdRoot] = &FileEntry{Name: "/", File: rootDir, openPath: "/"}
 	fsc.lastFD = FdRoot
 
 	return fsc, nil
@@ -243,6 +265,11 @@ func stdinReader(r io.Reader) *FileEntry {
 		r = eofReader{}
 	}
 	s := stdioStat(r, noopStdinStat)
+	switch r.(type) {
+	case *os.File, eofReader: // never blocks or is polled via its host fd
+	default:
+		r = &pollReader{r: r} // so that reads can be non-blocking
+	}
 	return &FileEntry{Name: noopStdinStat.Name(), File: &stdioFileReader{r: r, s: s}}
 }
 
@@ -262,7 +289,6 @@ func stdioStat(f interface{}, defaultStat stdioFileInfo) fs.FileInfo {
 }
 
 // nextFD gets the next file descriptor number in a goroutine safe way (monotonically) or zero if we ran out.
-// TODO: openedFiles is still not goroutine safe!
 // TODO: This can return zero if we ran out of file descriptors. A future change can optimize by re-using an FD pool.
 func (c *FSContext) nextFD() uint32 {
 	if c.lastFD == math.MaxUint32 {
@@ -271,14 +297,45 @@ func (c *FSContext) nextFD() uint32 {
 	return atomic.AddUint32(&c.lastFD, 1)
 }
 
+// openedFile returns the entry for the file descriptor, guarded by
+// openedFilesMux.
+func (c *FSContext) openedFile(fd uint32) (*FileEntry, bool) {
+	c.openedFilesMux.RLock()
+	defer c.openedFilesMux.RUnlock()
+	f, ok := c.openedFiles[fd]
+	return f, ok
+}
+
+// setOpenedFile assigns the entry for the file descriptor, guarded by
+// openedFilesMux.
+func (c *FSContext) setOpenedFile(fd uint32, f *FileEntry) {
+	c.openedFilesMux.Lock()
+	defer c.openedFilesMux.Unlock()
+	c.openedFiles[fd] = f
+}
+
+// OpenStream opens f as a new file descriptor, or returns syscall.EBADF if
+// there are no file descriptors left. This is used by host modules for
+// streams which aren't in the FS, such as the body of an HTTP response, so
+// that the guest can use fd_read and fd_close on them. f is closed when the
+// file descriptor or this context is.
+func (c *FSContext) OpenStream(name string, f fs.File) (uint32, error) {
+	newFD := c.nextFD()
+	if newFD == 0 {
+		return 0, syscall.EBADF
+	}
+	c.setOpenedFile(newFD, &FileEntry{Name: name, File: f})
+	return newFD, nil
+}
+
 // OpenedFile returns a file and true if it was opened or nil and false, if syscall.EBADF.
 func (c *FSContext) OpenedFile(fd uint32) (*FileEntry, bool) {
-	f, ok := c.openedFiles[fd]
+	f, ok := c.openedFile(fd)
 	return f, ok
 }
 
 func (c *FSContext) StatFile(fd uint32) (fs.FileInfo, error) {
-	f, ok := c.openedFiles[fd]
+	f, ok := c.openedFile(fd)
 	if !ok {
 		return nil, syscall.EBADF
 	}
@@ -298,13 +355,14 @@ func (s fileModeStat) Sys() interface{}   { return nil }
 func (s fileModeStat) Name() string       { return "" }
 func (s fileModeStat) IsDir() bool        { return false }
 
-// OpenFile is like syscall.Open and returns the file descriptor of the new file or an error.
+// OpenFile is like syscall.Open and returns the file descriptor of the new
+// file or an error. flag and perm are the same as os.OpenFile.
 //
-// TODO: Consider dirflags and oflags. Also, allow non-read-only open based on config about the mount.
-// e.g. allow os.O_RDONLY, os.O_WRONLY, or os.O_RDWR either by config flag or pattern on filename
-// See #390
-func (c *FSContext) OpenFile(name string /* TODO: flags int, perm int */) (uint32, error) {
-	f, err := c.openFile(name)
+// When the file system doesn't implement OpenFile(name string, flag int, perm
+// fs.FileMode) (fs.File, error), it is read-only: flags that need write
+// access fail with syscall.EROFS.
+func (c *FSContext) OpenFile(name string, flag int, perm fs.FileMode) (uint32, error) {
+	f, err := c.openFile(name, flag, perm)
 	if err != nil {
 		return 0, err
 	}
@@ -314,12 +372,35 @@ func (c *FSContext) OpenFile(name string /* TODO: flags int, perm int */) (uint3
 		_ = f.Close()
 		return 0, syscall.EBADF
 	}
-	c.openedFiles[newFD] = &FileEntry{Name: path.Base(name), File: f}
+	c.setOpenedFile(newFD, &FileEntry{Name: path.Base(name), File: f, Flag: flag, openPath: name})
 	return newFD, nil
 }
 
+// RewindDir re-opens the directory at the given file descriptor, so that the
+// next fs.ReadDirFile call starts at its first entry. Any ReadDir state is
+// cleared. This returns syscall.EBADF if the file descriptor isn't a
+// directory opened from the FS.
+func (c *FSContext) RewindDir(fd uint32) error {
+	f, ok := c.openedFile(fd)
+	if !ok || f.openPath == "" {
+		return syscall.EBADF
+	}
+	file, err := c.openFile(f.openPath, os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	if _, ok = file.(fs.ReadDirFile); !ok {
+		_ = file.Close()
+		return syscall.EBADF
+	}
+	_ = f.File.Close()
+	f.File = file
+	f.ReadDir = nil
+	return nil
+}
+
 func (c *FSContext) StatPath(name string) (fs.FileInfo, error) {
-	f, err := c.openFile(name)
+	f, err := c.openFile(name, os.O_RDONLY, 0)
 	if err != nil {
 		return nil, err
 	}
@@ -327,21 +408,122 @@ func (c *FSContext) StatPath(name string) (fs.FileInfo, error) {
 	return f.Stat()
 }
 
-func (c *FSContext) openFile(name string) (fs.File, error) {
+// fsPath returns the name to use with fs.FS, which cannot be rooted.
+func fsPath(name string) string {
 	// fs.ValidFile cannot be rooted (start with '/')
-	fsOpenPath := name
-	if name[0] == '/' {
-		fsOpenPath = name[1:]
+	if len(name) > 0 && name[0] == '/' {
+		name = name[1:]
+	}
+	return path.Clean(name) // e.g. "sub/." -> "sub"
+}
+
+func (c *FSContext) openFile(name string, flag int, perm fs.FileMode) (fs.File, error) {
+	return sysfs.OpenFile(c.fs, fsPath(name), flag, perm)
+}
+
+// Symlink is like os.Symlink, creating a symbolic link at name, whose
+// contents are target. This returns syscall.EROFS unless the file system
+// implements Symlink(oldname, newname string) error.
+//
+// To ensure the link can't be followed outside the file system, this returns
+// syscall.EPERM if the target is absolute or escapes the root with "..".
+func (c *FSContext) Symlink(target, name string) error {
+	fsName := fsPath(name)
+	if !fs.ValidPath(fsName) {
+		return &fs.PathError{Op: "symlink", Path: name, Err: fs.ErrInvalid}
 	}
-	fsOpenPath = path.Clean(fsOpenPath) // e.g. "sub/." -> "sub"
+	if target == "" {
+		return &fs.PathError{Op: "symlink", Path: name, Err: syscall.ENOENT}
+	}
+	if path.IsAbs(target) {
+		return &fs.PathError{Op: "symlink", Path: name, Err: syscall.EPERM}
+	}
+	if resolved := path.Join(path.Dir(fsName), target); resolved == ".." || strings.HasPrefix(resolved, "../") {
+		return &fs.PathError{Op: "symlink", Path: name, Err: syscall.EPERM}
+	}
+	return sysfs.Symlink(c.fs, target, fsName)
+}
 
-	return c.fs.Open(fsOpenPath)
+// Readlink is like os.Readlink. This returns syscall.ENOSYS unless the file
+// system implements Readlink(name string) (string, error).
+func (c *FSContext) Readlink(name string) (string, error) {
+	fsName := fsPath(name)
+	if !fs.ValidPath(fsName) {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return sysfs.Readlink(c.fs, fsName)
+}
+
+// Link is like os.Link, creating a hard link at newName to oldName. This
+// returns syscall.EROFS unless the file system implements Link(oldname,
+// newname string) error.
+func (c *FSContext) Link(oldName, newName string) error {
+	fsOldName, fsNewName := fsPath(oldName), fsPath(newName)
+	if !fs.ValidPath(fsOldName) {
+		return &fs.PathError{Op: "link", Path: oldName, Err: fs.ErrInvalid}
+	} else if !fs.ValidPath(fsNewName) {
+		return &fs.PathError{Op: "link", Path: newName, Err: fs.ErrInvalid}
+	}
+	return sysfs.Link(c.fs, fsOldName, fsNewName)
+}
+
+// Mkdir is like os.Mkdir. This returns syscall.EROFS unless the file system
+// implements Mkdir(name string, perm fs.FileMode) error.
+func (c *FSContext) Mkdir(name string, perm fs.FileMode) error {
+	fsName := fsPath(name)
+	if !fs.ValidPath(fsName) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	return sysfs.Mkdir(c.fs, fsName, perm)
+}
+
+// Unlink removes the file at name, failing with syscall.EISDIR if it is a
+// directory. This returns syscall.EROFS unless the file system implements
+// Remove(name string) error.
+func (c *FSContext) Unlink(name string) error {
+	return c.remove("unlink", name, false)
+}
+
+// Rmdir removes the empty directory at name, failing with syscall.ENOTDIR if
+// it isn't a directory. This returns syscall.EROFS unless the file system
+// implements Remove(name string) error.
+func (c *FSContext) Rmdir(name string) error {
+	return c.remove("rmdir", name, true)
+}
+
+func (c *FSContext) remove(op, name string, isDir bool) error {
+	fsName := fsPath(name)
+	if !fs.ValidPath(fsName) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	// Check the type first, as os.Remove removes either a file or directory.
+	st, err := sysfs.Lstat(c.fs, fsName)
+	if err != nil {
+		return err
+	} else if isDir && !st.IsDir() {
+		return &fs.PathError{Op: op, Path: name, Err: syscall.ENOTDIR}
+	} else if !isDir && st.IsDir() {
+		return &fs.PathError{Op: op, Path: name, Err: syscall.EISDIR}
+	}
+	return sysfs.Remove(c.fs, fsName)
+}
+
+// Rename is like os.Rename. This returns syscall.EROFS unless the file
+// system implements Rename(oldpath, newpath string) error.
+func (c *FSContext) Rename(oldName, newName string) error {
+	fsOldName, fsNewName := fsPath(oldName), fsPath(newName)
+	if !fs.ValidPath(fsOldName) {
+		return &fs.PathError{Op: "rename", Path: oldName, Err: fs.ErrInvalid}
+	} else if !fs.ValidPath(fsNewName) {
+		return &fs.PathError{Op: "rename", Path: newName, Err: fs.ErrInvalid}
+	}
+	return sysfs.Rename(c.fs, fsOldName, fsNewName)
 }
 
 // FdWriter returns a valid writer for the given file descriptor or nil if syscall.EBADF.
 func (c *FSContext) FdWriter(fd uint32) io.Writer {
 	// Check to see if the file descriptor is available
-	if f, ok := c.openedFiles[fd]; !ok {
+	if f, ok := c.openedFile(fd); !ok {
 		return nil
 	} else if writer, ok := f.File.(io.Writer); !ok {
 		// Go's syscall.Write also returns EBADF if the FD is present, but not writeable
@@ -356,24 +538,113 @@ func (c *FSContext) FdReader(fd uint32) io.Reader {
 	switch fd {
 	case FdStdout, FdStderr:
 		return nil // writer, not a readable file.
-	case FdRoot:
-		return nil // directory, not a readable file.
 	}
 
-	if f, ok := c.openedFiles[fd]; !ok {
-		return nil // TODO: could be a directory not a file.
+	if f, ok := c.openedFile(fd); !ok {
+		return nil
+	} else if f.openPath == "/" {
+		return nil // root directory, not a readable file.
 	} else {
-		return f.File
+		return f.File // TODO: could be a directory not a file.
+	}
+}
+
+// PollRead waits up to timeout for a read from the given file descriptor to
+// not block, when it is backed by an io.Reader which isn't a host file, such
+// as stdin configured with an io.Pipe. A negative timeout waits indefinitely.
+//
+// This returns false for ok when the file descriptor isn't such a file, in
+// which case callers should use HostFdIfBlocking, instead.
+func (c *FSContext) PollRead(fd uint32, timeout time.Duration) (ready, ok bool) {
+	f, ok := c.openedFile(fd)
+	if !ok {
+		return false, false
+	}
+	sf, ok := f.File.(*stdioFileReader)
+	if !ok {
+		return false, false
+	}
+	p, ok := sf.r.(*pollReader)
+	if !ok {
+		return false, false
 	}
+	return p.Poll(timeout), true
+}
+
+// HostFdIfBlocking returns the host file descriptor backing the given one,
+// when reads from it can block, such as a pipe, terminal or socket. This
+// returns false for regular files and directories, which never block, and
+// files not backed by an *os.File or syscall.Conn.
+func (c *FSContext) HostFdIfBlocking(fd uint32) (uintptr, bool) {
+	f, ok := c.openedFile(fd)
+	if !ok {
+		return 0, false
+	}
+	var file interface{} = f.File
+	switch sf := file.(type) {
+	case *stdioFileReader:
+		file = sf.r
+	case *hostFile:
+		file = sf.File
+	case *connFile:
+		return rawFd(sf.c)
+	case *listenerFile:
+		return rawFd(sf.l)
+	}
+	osFile, ok := file.(*os.File)
+	if !ok {
+		return 0, false
+	}
+	if st, err := osFile.Stat(); err != nil || st.Mode()&(fs.ModeNamedPipe|fs.ModeSocket|fs.ModeDevice) == 0 {
+		return 0, false
+	}
+	return osFile.Fd(), true
+}
+
+// SetFlag replaces the os.O_APPEND and platform.O_NONBLOCK bits of the flag
+// the given file descriptor was opened with, or returns syscall.EBADF if it
+// isn't open. Other bits, such as the access mode, are unchanged.
+//
+// Note: This only records the flag. Callers emulate it, e.g. by seeking to
+// the end before writing, or polling before reading.
+func (c *FSContext) SetFlag(fd uint32, flag int) error {
+	f, ok := c.openedFile(fd)
+	if !ok {
+		return syscall.EBADF
+	}
+	const mask = os.O_APPEND | platform.O_NONBLOCK
+	f.Flag = f.Flag&^mask | flag&mask
+	return nil
+}
+
+// LastFD returns the last file descriptor assigned, e.g. to compare with
+// OpenedFDs to find files opened afterwards.
+func (c *FSContext) LastFD() uint32 {
+	return atomic.LoadUint32(&c.lastFD)
+}
+
+// OpenedFDs returns the file descriptors of all open files, in ascending
+// order.
+func (c *FSContext) OpenedFDs() []uint32 {
+	c.openedFilesMux.RLock()
+	fds := make([]uint32, 0, len(c.openedFiles))
+	for fd := range c.openedFiles {
+		fds = append(fds, fd)
+	}
+	c.openedFilesMux.RUnlock()
+	sort.Slice(fds, func(i, j int) bool { return fds[i] < fds[j] })
+	return fds
 }
 
 // CloseFile returns true if a file was opened and closed without error, or false if syscall.EBADF.
 func (c *FSContext) CloseFile(fd uint32) bool {
+	c.openedFilesMux.Lock()
 	f, ok := c.openedFiles[fd]
+	delete(c.openedFiles, fd)
+	c.openedFilesMux.Unlock()
 	if !ok {
 		return false
 	}
-	delete(c.openedFiles, fd)
 
 	if err := f.File.Close(); err != nil {
 		return false
@@ -383,6 +654,9 @@ func (c *FSContext) CloseFile(fd uint32) bool {
 
 // Close implements api.Closer
 func (c *FSContext) Close(context.Context) (err error) {
+	c.openedFilesMux.Lock()
+	defer c.openedFilesMux.Unlock()
+
 	// Close any files opened in this context
 	for fd, entry := range c.openedFiles {
 		delete(c.openedFiles, fd)
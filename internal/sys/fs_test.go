@@ -6,11 +6,16 @@ import (
 	"errors"
 	"io"
 	"io/fs"
+	"net"
 	"os"
 	"path"
+	"syscall"
 	"testing"
 	"testing/fstest"
 
+	"github.com/tetratelabs/wazero/experimental/memfs"
+	"github.com/tetratelabs/wazero/internal/platform"
+	"github.com/tetratelabs/wazero/internal/sysfs"
 	testfs "github.com/tetratelabs/wazero/internal/testing/fs"
 	"github.com/tetratelabs/wazero/internal/testing/require"
 )
@@ -104,7 +109,7 @@ func TestEmptyFSContext(t *testing.T) {
 	}
 
 	t.Run("OpenFile doesn't affect state", func(t *testing.T) {
-		fd, err := testFS.OpenFile("foo.txt")
+		fd, err := testFS.OpenFile("foo.txt", os.O_RDONLY, 0)
 		require.Zero(t, fd)
 		require.EqualError(t, err, "open foo.txt: file does not exist")
 
@@ -158,7 +163,7 @@ func TestContext_File(t *testing.T) {
 		tc := tt
 
 		t.Run(tc.name, func(b *testing.T) {
-			fd, err := fsc.OpenFile(tc.name)
+			fd, err := fsc.OpenFile(tc.name, os.O_RDONLY, 0)
 			require.NoError(t, err)
 			defer fsc.CloseFile(fd)
 
@@ -184,13 +189,28 @@ func TestContext_File(t *testing.T) {
 	}
 }
 
+func TestContext_OpenedFDs(t *testing.T) {
+	fsc, err := NewFSContext(nil, nil, nil, testfs.FS{"foo": &testfs.File{}})
+	require.NoError(t, err)
+	require.Equal(t, FdRoot, fsc.LastFD())
+	require.Equal(t, []uint32{FdStdin, FdStdout, FdStderr, FdRoot}, fsc.OpenedFDs())
+
+	fd, err := fsc.OpenFile("foo", os.O_RDONLY, 0)
+	require.NoError(t, err)
+	require.Equal(t, fd, fsc.LastFD())
+	require.Equal(t, []uint32{FdStdin, FdStdout, FdStderr, FdRoot, fd}, fsc.OpenedFDs())
+
+	require.True(t, fsc.CloseFile(FdStdout))
+	require.Equal(t, []uint32{FdStdin, FdStderr, FdRoot, fd}, fsc.OpenedFDs())
+}
+
 func TestContext_Close(t *testing.T) {
 	fsc, err := NewFSContext(nil, nil, nil, testfs.FS{"foo": &testfs.File{}})
 	require.NoError(t, err)
 	// Verify base case
 	require.Equal(t, 1+FdRoot, uint32(len(fsc.openedFiles)))
 
-	_, err = fsc.OpenFile("foo")
+	_, err = fsc.OpenFile("foo", os.O_RDONLY, 0)
 	require.NoError(t, err)
 	require.Equal(t, 2+FdRoot, uint32(len(fsc.openedFiles)))
 
@@ -210,7 +230,7 @@ func TestContext_Close_Error(t *testing.T) {
 	require.NoError(t, err)
 
 	// open another file
-	_, err = fsc.OpenFile("foo")
+	_, err = fsc.OpenFile("foo", os.O_RDONLY, 0)
 	require.NoError(t, err)
 
 	require.EqualError(t, fsc.Close(testCtx), "error closing")
@@ -218,3 +238,478 @@ func TestContext_Close_Error(t *testing.T) {
 	// Paths should clear even under error
 	require.Zero(t, len(fsc.openedFiles), "expected no opened files")
 }
+
+func TestContext_HostFdIfBlocking(t *testing.T) {
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer r.Close()
+	defer w.Close()
+
+	fsc, err := NewFSContext(r, nil, nil, os.DirFS("."))
+	require.NoError(t, err)
+	defer fsc.Close(testCtx)
+
+	regular, err := fsc.OpenFile("fs.go", os.O_RDONLY, 0)
+	require.NoError(t, err)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	listener, err := fsc.OpenListener(l)
+	require.NoError(t, err)
+	listenerFd, ok := rawFd(l)
+	require.True(t, ok)
+
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+	pipeConn, err := fsc.OpenConn(c1)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		fd         uint32
+		expectedFd uintptr
+		expectedOk bool
+	}{
+		{name: "pipe", fd: FdStdin, expectedFd: r.Fd(), expectedOk: true},
+		{name: "listener", fd: listener, expectedFd: listenerFd, expectedOk: true},
+		{name: "net.Pipe", fd: pipeConn},
+		{name: "not os.File", fd: FdStdout},
+		{name: "directory", fd: FdRoot},
+		{name: "regular file", fd: regular},
+		{name: "not opened", fd: 42},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			fd, ok := fsc.HostFdIfBlocking(tc.fd)
+			require.Equal(t, tc.expectedOk, ok)
+			require.Equal(t, tc.expectedFd, fd)
+		})
+	}
+}
+
+func TestContext_SetFlag(t *testing.T) {
+	fsc, err := NewFSContext(nil, nil, nil, fstest.MapFS{"file": &fstest.MapFile{}})
+	require.NoError(t, err)
+	defer fsc.Close(testCtx)
+
+	fd, err := fsc.OpenFile("file", os.O_RDONLY, 0)
+	require.NoError(t, err)
+	f, ok := fsc.OpenedFile(fd)
+	require.True(t, ok)
+	f.Flag = os.O_RDWR | os.O_SYNC // as if opened with these.
+
+	require.NoError(t, fsc.SetFlag(fd, os.O_APPEND|platform.O_NONBLOCK|os.O_TRUNC))
+	require.Equal(t, os.O_RDWR|os.O_SYNC|os.O_APPEND|platform.O_NONBLOCK, f.Flag)
+
+	require.NoError(t, fsc.SetFlag(fd, 0))
+	require.Equal(t, os.O_RDWR|os.O_SYNC, f.Flag)
+
+	require.Equal(t, syscall.EBADF, fsc.SetFlag(42, 0))
+}
+
+func TestContext_SetConn_retainsFlag(t *testing.T) {
+	fsc, err := NewFSContext(nil, nil, nil, EmptyFS)
+	require.NoError(t, err)
+	defer fsc.Close(testCtx)
+
+	fd, err := fsc.OpenSocket(&Socket{Network: "tcp"})
+	require.NoError(t, err)
+	require.NoError(t, fsc.SetFlag(fd, platform.O_NONBLOCK))
+
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+	fsc.SetConn(fd, c1)
+
+	f, ok := fsc.OpenedFile(fd)
+	require.True(t, ok)
+	require.Equal(t, platform.O_NONBLOCK, f.Flag)
+}
+
+func TestContext_RewindDir(t *testing.T) {
+	fsc, err := NewFSContext(nil, nil, nil, fstest.MapFS{
+		"dir/a": &fstest.MapFile{},
+		"dir/b": &fstest.MapFile{},
+		"file":  &fstest.MapFile{},
+	})
+	require.NoError(t, err)
+	defer fsc.Close(testCtx)
+
+	dirFD, err := fsc.OpenFile("dir", os.O_RDONLY, 0)
+	require.NoError(t, err)
+	f, ok := fsc.OpenedFile(dirFD)
+	require.True(t, ok)
+
+	// Exhaust the directory and record state, as fd_readdir would.
+	entries, err := f.File.(fs.ReadDirFile).ReadDir(-1)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(entries))
+	f.ReadDir = &ReadDir{CountRead: 2, Entries: entries}
+
+	require.NoError(t, fsc.RewindDir(dirFD))
+	require.Nil(t, f.ReadDir)
+
+	// Entries are read again from the beginning.
+	rewound, err := f.File.(fs.ReadDirFile).ReadDir(-1)
+	require.NoError(t, err)
+	require.Equal(t, entries, rewound)
+
+	t.Run("not opened", func(t *testing.T) {
+		require.Equal(t, syscall.EBADF, fsc.RewindDir(42))
+	})
+
+	t.Run("not from the FS", func(t *testing.T) {
+		require.Equal(t, syscall.EBADF, fsc.RewindDir(FdStdin))
+	})
+
+	t.Run("not a directory", func(t *testing.T) {
+		fileFD, err := fsc.OpenFile("file", os.O_RDONLY, 0)
+		require.NoError(t, err)
+		require.Equal(t, syscall.EBADF, fsc.RewindDir(fileFD))
+	})
+}
+
+// openFileMapFS is a fstest.MapFS which records the flags passed to OpenFile.
+type openFileMapFS struct {
+	fstest.MapFS
+	flag int
+}
+
+func (m *openFileMapFS) OpenFile(name string, flag int, _ fs.FileMode) (fs.File, error) {
+	m.flag = flag
+	return m.MapFS.Open(name)
+}
+
+func TestContext_OpenFile_flags(t *testing.T) {
+	readOnlyFS := fstest.MapFS{"file": &fstest.MapFile{}}
+
+	tests := []struct {
+		name        string
+		pathName    string
+		flag        int
+		expectedErr error
+	}{
+		{name: "read-only", pathName: "file", flag: os.O_RDONLY},
+		{name: "create existing", pathName: "file", flag: os.O_CREATE},
+		{name: "sync", pathName: "file", flag: os.O_SYNC},
+		{name: "create missing", pathName: "missing", flag: os.O_CREATE, expectedErr: syscall.EROFS},
+		{name: "create exclusive", pathName: "file", flag: os.O_CREATE | os.O_EXCL, expectedErr: syscall.EEXIST},
+		{name: "write-only", pathName: "file", flag: os.O_WRONLY, expectedErr: syscall.EROFS},
+		{name: "read-write", pathName: "file", flag: os.O_RDWR, expectedErr: syscall.EROFS},
+		{name: "append", pathName: "file", flag: os.O_APPEND, expectedErr: syscall.EROFS},
+		{name: "trunc", pathName: "file", flag: os.O_TRUNC, expectedErr: syscall.EROFS},
+		{name: "missing", pathName: "missing", flag: os.O_RDWR, expectedErr: fs.ErrNotExist},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			fsc, err := NewFSContext(nil, nil, nil, readOnlyFS)
+			require.NoError(t, err)
+			defer fsc.Close(testCtx)
+
+			fd, err := fsc.OpenFile(tc.pathName, tc.flag, 0)
+			if tc.expectedErr != nil {
+				require.True(t, errors.Is(err, tc.expectedErr), err)
+				return
+			}
+			require.NoError(t, err)
+			f, ok := fsc.OpenedFile(fd)
+			require.True(t, ok)
+			require.Equal(t, tc.flag, f.Flag)
+		})
+	}
+
+	t.Run("OpenFile", func(t *testing.T) {
+		ofs := &openFileMapFS{MapFS: readOnlyFS}
+		fsc, err := NewFSContext(nil, nil, nil, ofs)
+		require.NoError(t, err)
+		defer fsc.Close(testCtx)
+
+		_, err = fsc.OpenFile("/file", os.O_RDWR|os.O_TRUNC, 0)
+		require.NoError(t, err)
+		require.Equal(t, os.O_RDWR|os.O_TRUNC, ofs.flag)
+	})
+}
+
+// linkDirFS is like os.DirFS, except it supports symbolic and hard links.
+type linkDirFS string
+
+func (d linkDirFS) Open(name string) (fs.File, error) {
+	return os.Open(path.Join(string(d), name))
+}
+
+func (d linkDirFS) Symlink(oldname, newname string) error {
+	return os.Symlink(oldname, path.Join(string(d), newname))
+}
+
+func (d linkDirFS) Readlink(name string) (string, error) {
+	return os.Readlink(path.Join(string(d), name))
+}
+
+func (d linkDirFS) Link(oldname, newname string) error {
+	return os.Link(path.Join(string(d), oldname), path.Join(string(d), newname))
+}
+
+func TestContext_Symlink(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.Mkdir(path.Join(tmpDir, "sub"), 0o700))
+
+	fsc, err := NewFSContext(nil, nil, nil, linkDirFS(tmpDir))
+	require.NoError(t, err)
+	defer fsc.Close(testCtx)
+
+	tests := []struct {
+		name, target, linkName string
+		expectedErr            error
+	}{
+		{name: "relative", target: "file", linkName: "link"},
+		{name: "rooted link name", target: "file", linkName: "/rooted"},
+		{name: "parent in sub directory", target: "../file", linkName: "sub/link"},
+		{name: "dangling", target: "missing", linkName: "dangling"},
+		{name: "absolute target", target: "/etc/passwd", linkName: "abs", expectedErr: syscall.EPERM},
+		{name: "target escapes", target: "../file", linkName: "escape", expectedErr: syscall.EPERM},
+		{name: "target escapes sub directory", target: "../../file", linkName: "sub/escape", expectedErr: syscall.EPERM},
+		{name: "empty target", target: "", linkName: "empty", expectedErr: syscall.ENOENT},
+		{name: "invalid link name", target: "file", linkName: "../link", expectedErr: fs.ErrInvalid},
+		{name: "exists", target: "file", linkName: "sub", expectedErr: fs.ErrExist},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			err := fsc.Symlink(tc.target, tc.linkName)
+			if tc.expectedErr != nil {
+				require.True(t, errors.Is(err, tc.expectedErr), err)
+				return
+			}
+			require.NoError(t, err)
+
+			target, err := fsc.Readlink(tc.linkName)
+			require.NoError(t, err)
+			require.Equal(t, tc.target, target)
+		})
+	}
+}
+
+func TestContext_Link(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(path.Join(tmpDir, "file"), []byte("wazero"), 0o600))
+
+	fsc, err := NewFSContext(nil, nil, nil, linkDirFS(tmpDir))
+	require.NoError(t, err)
+	defer fsc.Close(testCtx)
+
+	require.NoError(t, fsc.Link("file", "/link"))
+	contents, err := os.ReadFile(path.Join(tmpDir, "link"))
+	require.NoError(t, err)
+	require.Equal(t, "wazero", string(contents))
+
+	require.True(t, errors.Is(fsc.Link("../file", "link2"), fs.ErrInvalid))
+	require.True(t, errors.Is(fsc.Link("file", "../link2"), fs.ErrInvalid))
+	require.True(t, errors.Is(fsc.Link("missing", "link2"), fs.ErrNotExist))
+}
+
+func TestContext_links_Unsupported(t *testing.T) {
+	fsc, err := NewFSContext(nil, nil, nil, fstest.MapFS{"file": &fstest.MapFile{}})
+	require.NoError(t, err)
+	defer fsc.Close(testCtx)
+
+	require.True(t, errors.Is(fsc.Symlink("file", "link"), syscall.EROFS))
+	require.True(t, errors.Is(fsc.Link("file", "link"), syscall.EROFS))
+	_, err = fsc.Readlink("file")
+	require.True(t, errors.Is(err, syscall.ENOSYS))
+}
+
+func TestContext_Mkdir_Rename_Remove(t *testing.T) {
+	m := memfs.New()
+	require.NoError(t, m.WriteFile("file", nil, 0o600))
+
+	fsc, err := NewFSContext(nil, nil, nil, m)
+	require.NoError(t, err)
+	defer fsc.Close(testCtx)
+
+	require.NoError(t, fsc.Mkdir("/dir", 0o755))
+	require.True(t, errors.Is(fsc.Mkdir("/dir", 0o755), syscall.EEXIST))
+	require.True(t, errors.Is(fsc.Mkdir("/../dir", 0o755), fs.ErrInvalid))
+
+	require.NoError(t, fsc.Rename("/file", "/dir/file"))
+	require.True(t, errors.Is(fsc.Rename("/file", "/dir/file"), syscall.ENOENT))
+
+	require.True(t, errors.Is(fsc.Unlink("/dir"), syscall.EISDIR))
+	require.True(t, errors.Is(fsc.Rmdir("/dir/file"), syscall.ENOTDIR))
+	require.True(t, errors.Is(fsc.Rmdir("/dir"), syscall.ENOTEMPTY))
+
+	require.NoError(t, fsc.Unlink("/dir/file"))
+	require.NoError(t, fsc.Rmdir("/dir"))
+	require.True(t, errors.Is(fsc.Unlink("/dir"), syscall.ENOENT))
+}
+
+func TestContext_Mkdir_Rename_Remove_Unsupported(t *testing.T) {
+	fsc, err := NewFSContext(nil, nil, nil, fstest.MapFS{
+		"file": &fstest.MapFile{},
+		"dir":  &fstest.MapFile{Mode: fs.ModeDir},
+	})
+	require.NoError(t, err)
+	defer fsc.Close(testCtx)
+
+	require.True(t, errors.Is(fsc.Mkdir("new", 0o755), syscall.EROFS))
+	require.True(t, errors.Is(fsc.Rename("file", "new"), syscall.EROFS))
+	require.True(t, errors.Is(fsc.Unlink("file"), syscall.EROFS))
+	require.True(t, errors.Is(fsc.Rmdir("dir"), syscall.EROFS))
+}
+
+func TestContext_Mkdir_Rename_Remove_Mounts(t *testing.T) {
+	root, tmp := memfs.New(), memfs.New()
+	require.NoError(t, root.WriteFile("file", nil, 0o600))
+	rootFS, err := sysfs.NewRootFS([]sysfs.Mount{
+		{GuestPath: "/", FS: root, ReadOnly: true},
+		{GuestPath: "/tmp", FS: tmp},
+	})
+	require.NoError(t, err)
+
+	fsc, err := NewFSContext(nil, nil, nil, rootFS)
+	require.NoError(t, err)
+	defer fsc.Close(testCtx)
+
+	require.NoError(t, fsc.Mkdir("/tmp/dir", 0o755))
+	require.True(t, errors.Is(fsc.Mkdir("/dir", 0o755), syscall.EROFS))
+	require.True(t, errors.Is(fsc.Mkdir("/tmp", 0o755), syscall.EEXIST))
+
+	require.True(t, errors.Is(fsc.Rename("/file", "/tmp/file"), syscall.EXDEV))
+	require.True(t, errors.Is(fsc.Rename("/tmp", "/temp"), syscall.EBUSY))
+	require.True(t, errors.Is(fsc.Rmdir("/tmp"), syscall.EBUSY))
+	require.True(t, errors.Is(fsc.Unlink("/file"), syscall.EROFS))
+
+	require.NoError(t, fsc.Rmdir("/tmp/dir"))
+}
+
+func TestContext_OpenStream(t *testing.T) {
+	f, err := fstest.MapFS{"stream": {Data: []byte("wazero")}}.Open("stream")
+	require.NoError(t, err)
+
+	fsc, err := NewFSContext(nil, nil, nil, EmptyFS)
+	require.NoError(t, err)
+	defer fsc.Close(testCtx)
+
+	fd, err := fsc.OpenStream("stream", f)
+	require.NoError(t, err)
+	require.Equal(t, uint32(3), fd)
+
+	entry, ok := fsc.OpenedFile(fd)
+	require.True(t, ok)
+	require.Equal(t, f, entry.File)
+
+	buf, err := io.ReadAll(fsc.FdReader(fd))
+	require.NoError(t, err)
+	require.Equal(t, "wazero", string(buf))
+
+	require.True(t, fsc.CloseFile(fd))
+	_, ok = fsc.OpenedFile(fd)
+	require.False(t, ok)
+}
+
+func TestContext_OpenHostFile(t *testing.T) {
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer r.Close()
+	defer w.Close()
+
+	fsc, err := NewFSContext(nil, nil, nil, EmptyFS)
+	require.NoError(t, err)
+
+	fd, err := fsc.OpenHostFile(r)
+	require.NoError(t, err)
+	require.Equal(t, uint32(3), fd)
+
+	// The pipe is read and polled like any other file.
+	_, err = w.Write([]byte("wazero"))
+	require.NoError(t, err)
+	buf := make([]byte, 6)
+	_, err = io.ReadFull(fsc.FdReader(fd), buf)
+	require.NoError(t, err)
+	require.Equal(t, "wazero", string(buf))
+	hostFd, ok := fsc.HostFdIfBlocking(fd)
+	require.True(t, ok)
+	require.Equal(t, r.Fd(), hostFd)
+	require.False(t, fsc.IsDatagram(fd))
+
+	// Neither closing the file descriptor, nor the context, closes the file.
+	require.True(t, fsc.CloseFile(fd))
+	_, err = fsc.OpenHostFile(r)
+	require.NoError(t, err)
+	require.NoError(t, fsc.Close(testCtx))
+	_, err = r.Stat()
+	require.NoError(t, err)
+}
+
+func TestContext_IsDatagram(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer pc.Close()
+	udp, err := pc.(*net.UDPConn).File()
+	require.NoError(t, err)
+	defer udp.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	fsc, err := NewFSContext(nil, nil, nil, EmptyFS)
+	require.NoError(t, err)
+	defer fsc.Close(testCtx)
+
+	udpConnFd, err := fsc.OpenConn(pc.(*net.UDPConn))
+	require.NoError(t, err)
+	udpFileFd, err := fsc.OpenHostFile(udp)
+	require.NoError(t, err)
+	udpSocketFd, err := fsc.OpenSocket(&Socket{Network: "udp4"})
+	require.NoError(t, err)
+	tcpFd, err := fsc.OpenListener(l)
+	require.NoError(t, err)
+
+	require.True(t, fsc.IsDatagram(udpConnFd))
+	require.True(t, fsc.IsDatagram(udpFileFd))
+	require.True(t, fsc.IsDatagram(udpSocketFd))
+	require.False(t, fsc.IsDatagram(tcpFd))
+	require.False(t, fsc.IsDatagram(FdStdin))
+	require.False(t, fsc.IsDatagram(100)) // not open
+}
+
+func TestContext_PollRead(t *testing.T) {
+	r, w := io.Pipe()
+	defer r.Close()
+
+	fsc, err := NewFSContext(r, nil, nil, EmptyFS)
+	require.NoError(t, err)
+
+	// stdin isn't a host file, so it is polled here instead.
+	_, ok := fsc.HostFdIfBlocking(FdStdin)
+	require.False(t, ok)
+	ready, ok := fsc.PollRead(FdStdin, 0)
+	require.True(t, ok)
+	require.False(t, ready)
+
+	go func() {
+		_, _ = w.Write([]byte("wazero"))
+	}()
+	ready, _ = fsc.PollRead(FdStdin, -1)
+	require.True(t, ready)
+	buf := make([]byte, 6)
+	_, err = io.ReadFull(fsc.FdReader(FdStdin), buf)
+	require.NoError(t, err)
+	require.Equal(t, "wazero", string(buf))
+
+	// Other files, such as stdout, aren't polled here.
+	_, ok = fsc.PollRead(FdStdout, 0)
+	require.False(t, ok)
+	_, ok = fsc.PollRead(42, 0)
+	require.False(t, ok)
+
+	// Nor is the default stdin, which never blocks.
+	fsc, err = NewFSContext(nil, nil, nil, EmptyFS)
+	require.NoError(t, err)
+	_, ok = fsc.PollRead(FdStdin, 0)
+	require.False(t, ok)
+}
@@ -0,0 +1,33 @@
+package sys
+
+import (
+	"os"
+	"syscall"
+)
+
+// hostFile is an *os.File inherited from the host, such as a pipe or device.
+// All methods except Close are those of the *os.File, so it is read, written
+// and polled like a file opened from the FS.
+type hostFile struct {
+	*os.File
+}
+
+// Close implements fs.File. This doesn't close the *os.File, as it is owned
+// by the host, which may have configured it for more than one module.
+func (f *hostFile) Close() error { return nil }
+
+// OpenHostFile opens the host file as a new file descriptor, or returns
+// syscall.EBADF if there are no file descriptors left. The file isn't closed
+// when the file descriptor or this context is, as the caller owns it.
+func (c *FSContext) OpenHostFile(f *os.File) (uint32, error) {
+	st, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	newFD := c.nextFD()
+	if newFD == 0 {
+		return 0, syscall.EBADF
+	}
+	c.setOpenedFile(newFD, &FileEntry{Name: st.Name(), File: &hostFile{f}})
+	return newFD, nil
+}
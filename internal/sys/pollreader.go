@@ -0,0 +1,113 @@
+package sys
+
+import (
+	"io"
+	"time"
+)
+
+// pollReaderBufSize is the size of each read ahead by a pollReader.
+const pollReaderBufSize = 4096
+
+// pollReader wraps an io.Reader which may block, such as an io.Pipe, so that
+// whether a read would block can be checked, like poll on a host file
+// descriptor.
+//
+// Until its readiness is first checked, reads pass through to r. Afterwards,
+// a goroutine reads ahead from r, one chunk at a time, and reads consume what
+// it read. The goroutine exits when r returns an error, such as io.EOF.
+//
+// Note: Like FSContext, this isn't goroutine-safe.
+type pollReader struct {
+	r io.Reader
+
+	// chunks receives what the read-ahead goroutine read, or nil if it
+	// wasn't started.
+	chunks chan chunk
+
+	// pending is what was read ahead, but not yet read.
+	pending []byte
+
+	// err is the error which stopped the read-ahead goroutine, returned once
+	// pending is read.
+	err error
+}
+
+// chunk is the result of a read ahead.
+type chunk struct {
+	b   []byte
+	err error
+}
+
+// Read implements io.Reader
+func (p *pollReader) Read(b []byte) (int, error) {
+	if p.chunks == nil {
+		return p.r.Read(b)
+	}
+	if len(p.pending) == 0 && p.err == nil {
+		p.receive(<-p.chunks)
+	}
+	n := copy(b, p.pending)
+	p.pending = p.pending[n:]
+	if len(p.pending) == 0 && p.err != nil {
+		return n, p.err
+	}
+	return n, nil
+}
+
+// Poll waits up to timeout for a read to not block, returning true if it
+// won't. A negative timeout waits indefinitely.
+func (p *pollReader) Poll(timeout time.Duration) bool {
+	if len(p.pending) > 0 || p.err != nil {
+		return true
+	}
+	if p.chunks == nil {
+		p.chunks = make(chan chunk)
+		go p.readAhead()
+	}
+
+	if timeout == 0 {
+		select {
+		case c := <-p.chunks:
+			p.receive(c)
+			return true
+		default:
+			return false
+		}
+	} else if timeout < 0 {
+		p.receive(<-p.chunks)
+		return true
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case c := <-p.chunks:
+		p.receive(c)
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// receive records a chunk read ahead.
+func (p *pollReader) receive(c chunk) {
+	p.pending = c.b
+	p.err = c.err
+}
+
+// readAhead reads from r until it returns an error, sending each chunk read
+// to chunks. This blocks until each chunk is received, so that no more is
+// read than the guest consumes.
+func (p *pollReader) readAhead() {
+	for {
+		b := make([]byte, pollReaderBufSize)
+		n, err := p.r.Read(b)
+		if n == 0 && err == nil {
+			continue // io.Reader discourages this, but it isn't an error.
+		}
+		p.chunks <- chunk{b: b[:n], err: err}
+		if err != nil {
+			return
+		}
+	}
+}
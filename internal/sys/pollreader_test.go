@@ -0,0 +1,54 @@
+package sys
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestPollReader(t *testing.T) {
+	r, w := io.Pipe()
+	defer r.Close()
+	p := &pollReader{r: r}
+
+	// Nothing was written, so a read would block.
+	require.False(t, p.Poll(0))
+	require.False(t, p.Poll(time.Millisecond))
+
+	go func() {
+		_, _ = w.Write([]byte("wazero"))
+		_ = w.Close()
+	}()
+	require.True(t, p.Poll(-1))
+	require.True(t, p.Poll(0)) // still ready until read
+
+	// Reads consume what was read ahead, in order, followed by EOF.
+	buf := make([]byte, 4)
+	n, err := p.Read(buf)
+	require.NoError(t, err)
+	require.Equal(t, "waze", string(buf[:n]))
+	n, err = p.Read(buf)
+	require.NoError(t, err)
+	require.Equal(t, "ro", string(buf[:n]))
+	require.True(t, p.Poll(time.Hour)) // EOF doesn't block
+	_, err = p.Read(buf)
+	require.Equal(t, io.EOF, err)
+}
+
+func TestPollReader_passThrough(t *testing.T) {
+	// Until polled, reads don't start the read-ahead goroutine.
+	p := &pollReader{r: strings.NewReader("wazero")}
+	buf := make([]byte, 4)
+	n, err := p.Read(buf)
+	require.NoError(t, err)
+	require.Equal(t, "waze", string(buf[:n]))
+	require.Nil(t, p.chunks)
+
+	require.True(t, p.Poll(time.Hour))
+	n, err = io.ReadFull(p, buf[:2])
+	require.NoError(t, err)
+	require.Equal(t, "ro", string(buf[:n]))
+}
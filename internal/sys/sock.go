@@ -0,0 +1,226 @@
+package sys
+
+import (
+	"io/fs"
+	"net"
+	"strings"
+	"syscall"
+
+	"github.com/tetratelabs/wazero/internal/platform"
+)
+
+// listenerFile adapts a net.Listener to fs.File, so that it can be opened as
+// a file descriptor. Only sock_accept and fd_close are valid on it.
+type listenerFile struct {
+	l net.Listener
+}
+
+// Stat implements fs.File
+func (f *listenerFile) Stat() (fs.FileInfo, error) { return fileModeStat(fs.ModeSocket), nil }
+
+// Read implements fs.File
+func (f *listenerFile) Read([]byte) (int, error) { return 0, syscall.ENOTCONN }
+
+// Close implements fs.File
+func (f *listenerFile) Close() error { return f.l.Close() }
+
+// connFile adapts a net.Conn to fs.File and io.Writer, so that it can be
+// opened as a file descriptor. This allows fd_read and fd_write to be used in
+// addition to sock_recv and sock_send.
+type connFile struct {
+	c net.Conn
+}
+
+// Stat implements fs.File
+func (f *connFile) Stat() (fs.FileInfo, error) { return fileModeStat(fs.ModeSocket), nil }
+
+// Read implements fs.File
+func (f *connFile) Read(p []byte) (int, error) { return f.c.Read(p) }
+
+// Write implements io.Writer
+func (f *connFile) Write(p []byte) (int, error) { return f.c.Write(p) }
+
+// Close implements fs.File
+func (f *connFile) Close() error { return f.c.Close() }
+
+// OpenListener opens the listener as a new file descriptor, or returns
+// syscall.EBADF if there are no file descriptors left. The listener is closed
+// when the file descriptor or this context is.
+func (c *FSContext) OpenListener(l net.Listener) (uint32, error) {
+	return c.openSocket(l.Addr().String(), &listenerFile{l})
+}
+
+// OpenConn opens the connection as a new file descriptor, or returns
+// syscall.EBADF if there are no file descriptors left. The connection is
+// closed when the file descriptor or this context is.
+func (c *FSContext) OpenConn(conn net.Conn) (uint32, error) {
+	return c.openSocket(conn.LocalAddr().String(), &connFile{conn})
+}
+
+func (c *FSContext) openSocket(name string, f fs.File) (uint32, error) {
+	return c.OpenStream(name, f)
+}
+
+// Listener returns the listener opened as the given file descriptor, or
+// syscall.EBADF if it isn't open, or syscall.ENOTSOCK if it isn't a listener.
+func (c *FSContext) Listener(fd uint32) (net.Listener, error) {
+	f, ok := c.openedFile(fd)
+	if !ok {
+		return nil, syscall.EBADF
+	} else if lf, ok := f.File.(*listenerFile); ok {
+		return lf.l, nil
+	}
+	return nil, syscall.ENOTSOCK
+}
+
+// Conn returns the connection opened as the given file descriptor, or
+// syscall.EBADF if it isn't open, or syscall.ENOTSOCK if it isn't a
+// connection.
+func (c *FSContext) Conn(fd uint32) (net.Conn, error) {
+	f, ok := c.openedFile(fd)
+	if !ok {
+		return nil, syscall.EBADF
+	} else if cf, ok := f.File.(*connFile); ok {
+		return cf.c, nil
+	}
+	return nil, syscall.ENOTSOCK
+}
+
+// IsDatagram returns true if the given file descriptor is a socket of type
+// SOCK_DGRAM, such as a UDP connection. Other sockets are streams.
+func (c *FSContext) IsDatagram(fd uint32) bool {
+	f, ok := c.openedFile(fd)
+	if !ok {
+		return false
+	}
+	switch sf := f.File.(type) {
+	case *connFile:
+		_, ok = sf.c.(net.PacketConn)
+		return ok
+	case *Socket:
+		return strings.HasPrefix(sf.Network, "udp")
+	case *hostFile:
+		if hostFd, ok := rawFd(sf.File); ok {
+			return platform.IsDatagramSocket(hostFd)
+		}
+	}
+	return false
+}
+
+// Socket is a socket created by the guest, which isn't yet listening or
+// connected. Once it is, the file descriptor is replaced using SetListener or
+// SetConn.
+type Socket struct {
+	// Network is the network passed to net.Listen or net.Dial, e.g. "tcp4".
+	Network string
+
+	// LocalAddr is the possibly nil address the socket is bound to.
+	LocalAddr net.Addr
+}
+
+// Stat implements fs.File
+func (s *Socket) Stat() (fs.FileInfo, error) { return fileModeStat(fs.ModeSocket), nil }
+
+// Read implements fs.File
+func (s *Socket) Read([]byte) (int, error) { return 0, syscall.ENOTCONN }
+
+// Close implements fs.File
+func (s *Socket) Close() error { return nil }
+
+// OpenSocket opens the socket as a new file descriptor, or returns
+// syscall.EBADF if there are no file descriptors left.
+func (c *FSContext) OpenSocket(s *Socket) (uint32, error) {
+	return c.openSocket(s.Network, s)
+}
+
+// Socket returns the socket opened as the given file descriptor, or
+// syscall.EBADF if it isn't open, syscall.EISCONN if it is already listening
+// or connected, or syscall.ENOTSOCK if it isn't a socket.
+func (c *FSContext) Socket(fd uint32) (*Socket, error) {
+	f, ok := c.openedFile(fd)
+	if !ok {
+		return nil, syscall.EBADF
+	}
+	switch file := f.File.(type) {
+	case *Socket:
+		return file, nil
+	case *listenerFile, *connFile:
+		return nil, syscall.EISCONN
+	}
+	return nil, syscall.ENOTSOCK
+}
+
+// SocketAddr returns the local or remote address of the socket opened as the
+// given file descriptor, or syscall.EBADF if it isn't open, syscall.ENOTSOCK
+// if it isn't a socket or syscall.ENOTCONN if the address isn't known, e.g.
+// the remote address of a listener.
+func (c *FSContext) SocketAddr(fd uint32, remote bool) (addr net.Addr, err error) {
+	f, ok := c.openedFile(fd)
+	if !ok {
+		return nil, syscall.EBADF
+	}
+	switch file := f.File.(type) {
+	case *Socket:
+		if !remote {
+			addr = file.LocalAddr
+		}
+	case *listenerFile:
+		if !remote {
+			addr = file.l.Addr()
+		}
+	case *connFile:
+		if remote {
+			addr = file.c.RemoteAddr()
+		} else {
+			addr = file.c.LocalAddr()
+		}
+	default:
+		return nil, syscall.ENOTSOCK
+	}
+	if addr == nil {
+		return nil, syscall.ENOTCONN
+	}
+	return addr, nil
+}
+
+// SetListener replaces the socket opened as the given file descriptor with
+// the listener, without closing the former.
+func (c *FSContext) SetListener(fd uint32, l net.Listener) {
+	c.setSocket(fd, l.Addr().String(), &listenerFile{l})
+}
+
+// SetConn replaces the socket opened as the given file descriptor with the
+// connection, without closing the former.
+func (c *FSContext) SetConn(fd uint32, conn net.Conn) {
+	c.setSocket(fd, conn.LocalAddr().String(), &connFile{conn})
+}
+
+// setSocket replaces the file opened as the given file descriptor, retaining
+// any flag set on it, such as platform.O_NONBLOCK.
+func (c *FSContext) setSocket(fd uint32, name string, f fs.File) {
+	c.openedFilesMux.Lock()
+	defer c.openedFilesMux.Unlock()
+	var flag int
+	if old, ok := c.openedFiles[fd]; ok {
+		flag = old.Flag
+	}
+	c.openedFiles[fd] = &FileEntry{Name: name, File: f, Flag: flag}
+}
+
+// rawFd returns the host file descriptor of a socket, or false if it isn't
+// backed by one, such as a net.Pipe.
+func rawFd(s interface{}) (uintptr, bool) {
+	sc, ok := s.(syscall.Conn)
+	if !ok {
+		return 0, false
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return 0, false
+	}
+	var hostFd uintptr
+	if err = rc.Control(func(fd uintptr) { hostFd = fd }); err != nil {
+		return 0, false
+	}
+	return hostFd, true
+}
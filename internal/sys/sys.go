@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"syscall"
 	"time"
 
 	"github.com/tetratelabs/wazero/internal/platform"
@@ -23,6 +24,9 @@ type Context struct {
 	walltimeResolution sys.ClockResolution
 	nanotime           *sys.Nanotime
 	nanotimeResolution sys.ClockResolution
+	cputime            *sys.Cputime
+	cputimeResolution  sys.ClockResolution
+	moduleCputime      bool
 	nanosleep          *sys.Nanosleep
 	randSource         io.Reader
 	fsc                *FSContext
@@ -82,6 +86,31 @@ func (c *Context) NanotimeResolution() sys.ClockResolution {
 	return c.nanotimeResolution
 }
 
+// Cputime implements sys.Cputime.
+func (c *Context) Cputime() int64 {
+	return (*(c.cputime))()
+}
+
+// ModuleCputime returns true if the CPU time clock of the module is the CPU
+// time consumed by calls to it, instead of Cputime.
+//
+// See wazero.ModuleConfig WithSysCputime
+func (c *Context) ModuleCputime() bool {
+	return c.moduleCputime
+}
+
+// UseModuleCputime makes ModuleCputime true, with the resolution of the CPU
+// time clock of the host.
+func (c *Context) UseModuleCputime() {
+	c.moduleCputime = true
+	c.cputimeResolution = sys.ClockResolution(time.Microsecond.Nanoseconds())
+}
+
+// CputimeResolution returns resolution of Cputime.
+func (c *Context) CputimeResolution() sys.ClockResolution {
+	return c.cputimeResolution
+}
+
 // Nanosleep implements sys.Nanosleep.
 func (c *Context) Nanosleep(ns int64) {
 	(*(c.nanosleep))(ns)
@@ -109,7 +138,7 @@ func (eofReader) Read([]byte) (int, error) {
 
 // DefaultContext returns Context with no values set except a possibly nil fs.FS
 func DefaultContext(fs fs.FS) *Context {
-	if sysCtx, err := NewContext(0, nil, nil, nil, nil, nil, nil, nil, 0, nil, 0, nil, fs); err != nil {
+	if sysCtx, err := NewContext(0, 0, nil, nil, nil, nil, nil, nil, nil, 0, nil, 0, nil, 0, nil, fs); err != nil {
 		panic(fmt.Errorf("BUG: DefaultContext should never error: %w", err))
 	} else {
 		return sysCtx
@@ -122,9 +151,10 @@ var (
 )
 
 // NewContext is a factory function which helps avoid needing to know defaults or exporting all fields.
-// Note: max is exposed for testing. max is only used for env/args validation.
+// Note: maxArgsSize and maxEnvironSize limit the count and null-terminated size of args and environ. Exceeding either
+// returns an error wrapping syscall.E2BIG.
 func NewContext(
-	max uint32,
+	maxArgsSize, maxEnvironSize uint32,
 	args, environ [][]byte,
 	stdin io.Reader,
 	stdout, stderr io.Writer,
@@ -133,16 +163,18 @@ func NewContext(
 	walltimeResolution sys.ClockResolution,
 	nanotime *sys.Nanotime,
 	nanotimeResolution sys.ClockResolution,
+	cputime *sys.Cputime,
+	cputimeResolution sys.ClockResolution,
 	nanosleep *sys.Nanosleep,
 	fs fs.FS,
 ) (sysCtx *Context, err error) {
 	sysCtx = &Context{args: args, environ: environ}
 
-	if sysCtx.argsSize, err = nullTerminatedByteCount(max, args); err != nil {
+	if sysCtx.argsSize, err = nullTerminatedByteCount(maxArgsSize, args); err != nil {
 		return nil, fmt.Errorf("args invalid: %w", err)
 	}
 
-	if sysCtx.environSize, err = nullTerminatedByteCount(max, environ); err != nil {
+	if sysCtx.environSize, err = nullTerminatedByteCount(maxEnvironSize, environ); err != nil {
 		return nil, fmt.Errorf("environ invalid: %w", err)
 	}
 
@@ -174,6 +206,17 @@ func NewContext(
 		sysCtx.nanotimeResolution = sys.ClockResolution(time.Nanosecond)
 	}
 
+	if cputime != nil {
+		if clockResolutionInvalid(cputimeResolution) {
+			return nil, fmt.Errorf("invalid Cputime resolution: %d", cputimeResolution)
+		}
+		sysCtx.cputime = cputime
+		sysCtx.cputimeResolution = cputimeResolution
+	} else {
+		sysCtx.cputime = platform.NewFakeCputime()
+		sysCtx.cputimeResolution = sys.ClockResolution(time.Nanosecond)
+	}
+
 	if nanosleep != nil {
 		sysCtx.nanosleep = nanosleep
 	} else {
@@ -199,7 +242,7 @@ func clockResolutionInvalid(resolution sys.ClockResolution) bool {
 func nullTerminatedByteCount(max uint32, elements [][]byte) (uint32, error) {
 	count := uint32(len(elements))
 	if count > max {
-		return 0, errors.New("exceeds maximum count")
+		return 0, fmt.Errorf("exceeds maximum count: %w", syscall.E2BIG)
 	}
 
 	// The buffer size is the total size including null terminators. The null terminator count == value count, sum
@@ -215,7 +258,7 @@ func nullTerminatedByteCount(max uint32, elements [][]byte) (uint32, error) {
 
 		nextSize := bufSize + uint64(len(e))
 		if nextSize > maxSize {
-			return 0, errors.New("exceeds maximum size")
+			return 0, fmt.Errorf("exceeds maximum size: %w", syscall.E2BIG)
 		}
 		bufSize = nextSize
 
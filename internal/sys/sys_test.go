@@ -22,7 +22,7 @@ func TestContext_FS(t *testing.T) {
 
 func TestDefaultSysContext(t *testing.T) {
 	sysCtx, err := NewContext(
-		0,      // max
+		0, 0, // maxArgsSize, maxEnvironSize
 		nil,    // args
 		nil,    // environ
 		nil,    // stdin
@@ -31,6 +31,7 @@ func TestDefaultSysContext(t *testing.T) {
 		nil,    // randSource
 		nil, 0, // walltime, walltimeResolution
 		nil, 0, // nanotime, nanotimeResolution
+		nil, 0, // cputime, cputimeResolution
 		nil,         // nanosleep
 		testfs.FS{}, // fs
 	)
@@ -55,7 +56,7 @@ func TestDefaultSysContext(t *testing.T) {
 		FdStdin:  noopStdin,
 		FdStdout: noopStdout,
 		FdStderr: noopStderr,
-		FdRoot:   {Name: "/", File: emptyRootDir{}},
+		FdRoot:   {Name: "/", File: emptyRootDir{}, openPath: "/"},
 	}, expectedFS.openedFiles)
 	require.Equal(t, expectedFS, sysCtx.FS())
 }
@@ -78,13 +79,13 @@ func TestNewContext_Args(t *testing.T) {
 			name:        "exceeds max count",
 			maxSize:     1,
 			args:        [][]byte{[]byte("a"), []byte("bc")},
-			expectedErr: "args invalid: exceeds maximum count",
+			expectedErr: "args invalid: exceeds maximum count: argument list too long",
 		},
 		{
 			name:        "exceeds max size",
 			maxSize:     4,
 			args:        [][]byte{[]byte("a"), []byte("bc")},
-			expectedErr: "args invalid: exceeds maximum size",
+			expectedErr: "args invalid: exceeds maximum size: argument list too long",
 		},
 		{
 			name:        "null character",
@@ -99,7 +100,7 @@ func TestNewContext_Args(t *testing.T) {
 
 		t.Run(tc.name, func(t *testing.T) {
 			sysCtx, err := NewContext(
-				tc.maxSize, // max
+				tc.maxSize, tc.maxSize, // maxArgsSize, maxEnvironSize
 				tc.args,
 				nil,                              // environ
 				bytes.NewReader(make([]byte, 0)), // stdin
@@ -108,6 +109,7 @@ func TestNewContext_Args(t *testing.T) {
 				nil,                              // randSource
 				nil, 0,                           // walltime, walltimeResolution
 				nil, 0, // nanotime, nanotimeResolution
+				nil, 0, // cputime, cputimeResolution
 				nil, // nanosleep
 				nil, // fs
 			)
@@ -140,13 +142,13 @@ func TestNewContext_Environ(t *testing.T) {
 			name:        "exceeds max count",
 			maxSize:     1,
 			environ:     [][]byte{[]byte("a=b"), []byte("c=de")},
-			expectedErr: "environ invalid: exceeds maximum count",
+			expectedErr: "environ invalid: exceeds maximum count: argument list too long",
 		},
 		{
 			name:        "exceeds max size",
 			maxSize:     4,
 			environ:     [][]byte{[]byte("a=b"), []byte("c=de")},
-			expectedErr: "environ invalid: exceeds maximum size",
+			expectedErr: "environ invalid: exceeds maximum size: argument list too long",
 		},
 		{
 			name:        "null character",
@@ -161,8 +163,8 @@ func TestNewContext_Environ(t *testing.T) {
 
 		t.Run(tc.name, func(t *testing.T) {
 			sysCtx, err := NewContext(
-				tc.maxSize, // max
-				nil,        // args
+				tc.maxSize, tc.maxSize, // maxArgsSize, maxEnvironSize
+				nil, // args
 				tc.environ,
 				bytes.NewReader(make([]byte, 0)), // stdin
 				nil,                              // stdout
@@ -170,6 +172,7 @@ func TestNewContext_Environ(t *testing.T) {
 				nil,                              // randSource
 				nil, 0,                           // walltime, walltimeResolution
 				nil, 0, // nanotime, nanotimeResolution
+				nil, 0, // cputime, cputimeResolution
 				nil, // nanosleep
 				nil, // fs
 			)
@@ -209,7 +212,7 @@ func TestNewContext_Walltime(t *testing.T) {
 
 		t.Run(tc.name, func(t *testing.T) {
 			sysCtx, err := NewContext(
-				0,   // max
+				0, 0, // maxArgsSize, maxEnvironSize
 				nil, // args
 				nil,
 				nil,                    // stdin
@@ -218,6 +221,7 @@ func TestNewContext_Walltime(t *testing.T) {
 				nil,                    // randSource
 				tc.time, tc.resolution, // walltime, walltimeResolution
 				nil, 0, // nanotime, nanotimeResolution
+				nil, 0, // cputime, cputimeResolution
 				nil, // nanosleep
 				nil, // fs
 			)
@@ -257,7 +261,7 @@ func TestNewContext_Nanotime(t *testing.T) {
 
 		t.Run(tc.name, func(t *testing.T) {
 			sysCtx, err := NewContext(
-				0,   // max
+				0, 0, // maxArgsSize, maxEnvironSize
 				nil, // args
 				nil,
 				nil,    // stdin
@@ -266,6 +270,7 @@ func TestNewContext_Nanotime(t *testing.T) {
 				nil,    // randSource
 				nil, 0, // nanotime, nanotimeResolution
 				tc.time, tc.resolution, // nanotime, nanotimeResolution
+				nil, 0, // cputime, cputimeResolution
 				nil, // nanosleep
 				nil, // fs
 			)
@@ -280,6 +285,55 @@ func TestNewContext_Nanotime(t *testing.T) {
 	}
 }
 
+func TestNewContext_Cputime(t *testing.T) {
+	tests := []struct {
+		name        string
+		time        *sys.Cputime
+		resolution  sys.ClockResolution
+		expectedErr string
+	}{
+		{
+			name:       "ok",
+			time:       platform.NewFakeCputime(),
+			resolution: 3,
+		},
+		{
+			name:        "invalid resolution",
+			time:        platform.NewFakeCputime(),
+			resolution:  0,
+			expectedErr: "invalid Cputime resolution: 0",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			sysCtx, err := NewContext(
+				0, 0, // maxArgsSize, maxEnvironSize
+				nil, // args
+				nil,
+				nil,    // stdin
+				nil,    // stdout
+				nil,    // stderr
+				nil,    // randSource
+				nil, 0, // walltime, walltimeResolution
+				nil, 0, // nanotime, nanotimeResolution
+				tc.time, tc.resolution, // cputime, cputimeResolution
+				nil, // nanosleep
+				nil, // fs
+			)
+			if tc.expectedErr == "" {
+				require.Nil(t, err)
+				require.Equal(t, tc.time, sysCtx.cputime)
+				require.Equal(t, tc.resolution, sysCtx.CputimeResolution())
+			} else {
+				require.EqualError(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
+
 func Test_clockResolutionInvalid(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -314,7 +368,7 @@ func Test_clockResolutionInvalid(t *testing.T) {
 func TestNewContext_Nanosleep(t *testing.T) {
 	var aNs sys.Nanosleep = func(int64) {}
 	sysCtx, err := NewContext(
-		0,   // max
+		0, 0, // maxArgsSize, maxEnvironSize
 		nil, // args
 		nil,
 		nil,    // stdin
@@ -323,6 +377,7 @@ func TestNewContext_Nanosleep(t *testing.T) {
 		nil,    // randSource
 		nil, 0, // Nanosleep, NanosleepResolution
 		nil, 0, // Nanosleep, NanosleepResolution
+		nil, 0, // cputime, cputimeResolution
 		&aNs, // nanosleep
 		nil,  // fs
 	)
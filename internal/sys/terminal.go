@@ -0,0 +1,51 @@
+package sys
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/tetratelabs/wazero/internal/platform"
+)
+
+// IsTerminal returns true if the given file descriptor is a host terminal,
+// such as stdin configured with os.Stdin in an interactive shell.
+func (c *FSContext) IsTerminal(fd uint32) bool {
+	hostFd, ok := c.hostFd(fd)
+	return ok && platform.IsTerminal(hostFd)
+}
+
+// TerminalSize returns the count of rows and columns of the terminal of the
+// given file descriptor. This returns syscall.EBADF if the file descriptor
+// isn't open, or syscall.ENOTTY if it isn't a host terminal.
+func (c *FSContext) TerminalSize(fd uint32) (rows, columns uint16, err error) {
+	if _, ok := c.openedFile(fd); !ok {
+		return 0, 0, syscall.EBADF
+	}
+	hostFd, ok := c.hostFd(fd)
+	if !ok {
+		return 0, 0, syscall.ENOTTY
+	}
+	return platform.TerminalSize(hostFd)
+}
+
+// hostFd returns the host file descriptor of the given one, if it is backed
+// by an *os.File, such as stdio configured with os.Stdout.
+func (c *FSContext) hostFd(fd uint32) (uintptr, bool) {
+	f, ok := c.openedFile(fd)
+	if !ok {
+		return 0, false
+	}
+	var file interface{} = f.File
+	switch sf := file.(type) {
+	case *stdioFileReader:
+		file = sf.r
+	case *stdioFileWriter:
+		file = sf.w
+	case *hostFile:
+		file = sf.File
+	}
+	if osFile, ok := file.(*os.File); ok {
+		return osFile.Fd(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,40 @@
+package sys
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"syscall"
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestContext_TerminalSize(t *testing.T) {
+	f, err := os.Create(path.Join(t.TempDir(), "stdout"))
+	require.NoError(t, err)
+	defer f.Close()
+
+	fsc, err := NewFSContext(nil, f, &bytes.Buffer{}, EmptyFS)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		fd          uint32
+		expectedErr syscall.Errno
+	}{
+		{name: "host file", fd: FdStdout, expectedErr: syscall.ENOTTY},
+		{name: "io.Writer", fd: FdStderr, expectedErr: syscall.ENOTTY},
+		{name: "io.Reader", fd: FdStdin, expectedErr: syscall.ENOTTY},
+		{name: "not open", fd: 42, expectedErr: syscall.EBADF},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			require.False(t, fsc.IsTerminal(tc.fd))
+			_, _, err := fsc.TerminalSize(tc.fd)
+			require.ErrorIs(t, err, tc.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package sysfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// secret is the content of the file outside the directory VerifyConfinement
+// mounts, which must never be read or written through it.
+var secret = []byte("secret")
+
+// escape is a symbolic link, created in the mounted directory, which points
+// outside it, and the name used to access the secret through it.
+type escape struct {
+	link, target, name string
+}
+
+// VerifyConfinement checks that a directory mounted with NewDirFS can't be
+// escaped via symbolic links on this host, returning an error describing the
+// first escape, if any.
+//
+// This creates a temporary directory in tmpDir, which contains a secret file
+// and the mounted directory. The latter has symbolic links which point to the
+// secret in various ways, such as with ".." or an absolute host path. Each is
+// then read, written, and used as a parent directory, through the mount. The
+// temporary directory is removed on return.
+func VerifyConfinement(tmpDir string) error {
+	return verifyConfinement(tmpDir, NewDirFS)
+}
+
+// verifyConfinement is VerifyConfinement, except the mount is created by
+// newDirFS.
+func verifyConfinement(tmpDir string, newDirFS func(dir string) fs.FS) (err error) {
+	dir, err := os.MkdirTemp(tmpDir, "confinement")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if rmErr := os.RemoveAll(dir); err == nil {
+			err = rmErr
+		}
+	}()
+
+	secretPath := filepath.Join(dir, "secret.txt")
+	if err = os.WriteFile(secretPath, secret, 0o600); err != nil {
+		return err
+	}
+	root := filepath.Join(dir, "root")
+	if err = os.MkdirAll(filepath.Join(root, "sub"), 0o700); err != nil {
+		return err
+	}
+
+	escapes := []escape{
+		{link: "parent", target: "../secret.txt", name: "parent"},
+		{link: "absolute", target: secretPath, name: "absolute"},
+		{link: "rooted", target: "/../secret.txt", name: "rooted"},
+		{link: "chain", target: "parent", name: "chain"},
+		{link: "sub/deep", target: "../../secret.txt", name: "sub/deep"},
+		{link: "up", target: "..", name: "up/secret.txt"},
+		{link: "absoluteDir", target: dir, name: "absoluteDir/secret.txt"},
+		{link: "sub/up", target: "../..", name: "sub/up/secret.txt"},
+	}
+	for _, e := range escapes {
+		if err = os.Symlink(e.target, filepath.Join(root, filepath.FromSlash(e.link))); err != nil {
+			return err
+		}
+	}
+
+	fsys := newDirFS(root)
+	for _, e := range escapes {
+		if err = verifyEscape(fsys, e); err != nil {
+			return err
+		}
+	}
+
+	// Finally, make sure nothing was changed by a write that didn't fail.
+	if b, err := os.ReadFile(secretPath); errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("secret.txt was removed via a symbolic link")
+	} else if err != nil {
+		return err
+	} else if !bytes.Equal(secret, b) {
+		return fmt.Errorf("secret.txt was overwritten via a symbolic link")
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	} else if len(entries) != 2 {
+		return fmt.Errorf("a file was created outside the mount via a symbolic link")
+	}
+	return nil
+}
+
+// verifyEscape returns an error if the secret is reachable via e.
+func verifyEscape(fsys fs.FS, e escape) error {
+	if f, err := fsys.Open(e.name); err == nil {
+		b, _ := io.ReadAll(f)
+		_ = f.Close()
+		if bytes.Equal(secret, b) {
+			return fmt.Errorf("read %s via symbolic link %s -> %s", e.name, e.link, e.target)
+		}
+	}
+	if info, err := fs.Stat(fsys, e.name); err == nil && info.Size() == int64(len(secret)) {
+		return fmt.Errorf("stat %s via symbolic link %s -> %s", e.name, e.link, e.target)
+	}
+	if f, err := OpenFile(fsys, e.name, os.O_WRONLY|os.O_APPEND, 0); err == nil {
+		_, _ = f.(io.Writer).Write(secret)
+		_ = f.Close()
+	}
+	_ = Mkdir(fsys, e.name+"/escaped", 0o700)
+	if f, err := OpenFile(fsys, e.name+"/escaped.txt", os.O_CREATE|os.O_WRONLY, 0o600); err == nil {
+		_ = f.Close()
+	}
+	// Only when the link is a parent, as otherwise these change the link.
+	if strings.Contains(e.name, "/") {
+		_ = Rename(fsys, e.name, "stolen.txt")
+		_ = Remove(fsys, e.name)
+	}
+	return nil
+}
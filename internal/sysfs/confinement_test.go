@@ -0,0 +1,32 @@
+package sysfs
+
+import (
+	"io/fs"
+	"os"
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestVerifyConfinement(t *testing.T) {
+	require.NoError(t, VerifyConfinement(t.TempDir()))
+}
+
+// unconfinedFS is like os.DirFS, except writable, and symbolic links are
+// followed by the host, so can escape it.
+type unconfinedFS string
+
+func (u unconfinedFS) Open(name string) (fs.File, error) {
+	return os.Open(string(u) + "/" + name)
+}
+
+func (u unconfinedFS) OpenFile(name string, flag int, perm fs.FileMode) (fs.File, error) {
+	return os.OpenFile(string(u)+"/"+name, flag, perm)
+}
+
+func TestVerifyConfinement_Escape(t *testing.T) {
+	err := verifyConfinement(t.TempDir(), func(dir string) fs.FS {
+		return unconfinedFS(dir)
+	})
+	require.EqualError(t, err, "read parent via symbolic link parent -> ../secret.txt")
+}
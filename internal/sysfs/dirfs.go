@@ -0,0 +1,305 @@
+package sysfs
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/tetratelabs/wazero/internal/platform"
+)
+
+// maxSymlinks is the maximum number of symbolic links followed when resolving
+// a path, before failing with syscall.ELOOP. This is the same as Linux.
+const maxSymlinks = 40
+
+// NewDirFS is like os.DirFS, except the result is writable: it implements
+// OpenFile, Mkdir, Remove, Rename, Symlink, Readlink, Link, Lstat and
+// Chtimes.
+//
+// # Confinement
+//
+// Unlike os.DirFS, symbolic links can't be followed outside dir. Names are
+// resolved one component at a time, and the target of each symbolic link is
+// resolved relative to dir: an absolute target, such as "/etc/passwd", is
+// under dir, and a target which escapes it with "..", fails with
+// syscall.ENOENT, as if it were dangling. See VerifyConfinement.
+//
+// On Linux, each component is opened relative to the directory before it,
+// without following symbolic links, and the last is then opened, created or
+// removed relative to its parent. So, nothing can escape dir by replacing a
+// parent directory with a symbolic link during resolution, including the
+// guest itself, e.g. via another instance on the same mount.
+//
+// Note: On other platforms, resolution isn't atomic: if anything with write
+// access to dir, including a guest it is mounted into, concurrently replaces
+// a parent directory of a name with a symbolic link, it could be followed.
+func NewDirFS(dir string) fs.FS {
+	return dirFS(dir)
+}
+
+type dirFS string
+
+// resolve returns the directory which contains name, and the last component
+// of name, after resolving any symbolic links in its parent directories, and
+// in name itself if followLast. The caller must close the directory.
+func (d dirFS) resolve(op, name string, followLast bool) (*hostDir, string, error) {
+	if !fs.ValidPath(name) || runtime.GOOS == "windows" && strings.ContainsAny(name, `\:`) {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: syscall.EINVAL}
+	}
+	root, err := openRoot(string(d))
+	if err != nil {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: osErr(err)}
+	}
+	links := 0
+	dir, _, last, err := walk(root, ".", name, followLast, &links, root)
+	if dir != root {
+		root.close()
+	}
+	if err != nil {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return dir, last, nil
+}
+
+// walk returns the directory which contains name, relative to dir, its path
+// relative to the root, and the last component of name. Any symbolic links
+// in the parent directories of name, and in the last component if
+// followLast, are replaced with their targets. links counts the symbolic
+// links followed. Directories other than root are closed unless returned.
+func walk(dir *hostDir, dirPath, name string, followLast bool, links *int, root *hostDir) (*hostDir, string, string, error) {
+	elems := strings.Split(name, "/")
+	for i, elem := range elems {
+		if i == len(elems)-1 {
+			if !followLast {
+				return dir, dirPath, elem, nil
+			}
+			target, err := dir.readlink(elem)
+			if err != nil { // not a symbolic link, or doesn't exist, e.g. a file to create
+				return dir, dirPath, elem, nil
+			}
+			release(dir, root)
+			return follow(dirPath, target, links, root)
+		}
+
+		sub, err := dir.openDir(elem)
+		subPath := path.Join(dirPath, elem)
+		if err != nil {
+			target, linkErr := dir.readlink(elem)
+			if linkErr != nil { // not a symbolic link
+				release(dir, root)
+				return nil, "", "", osErr(err)
+			}
+			parent, parentPath, last, err := follow(dirPath, target, links, root)
+			if err != nil {
+				release(dir, root)
+				return nil, "", "", err
+			}
+			sub, err = parent.openDir(last)
+			release(parent, root)
+			if err != nil {
+				release(dir, root)
+				return nil, "", "", osErr(err)
+			}
+			subPath = path.Join(parentPath, last)
+		}
+		release(dir, root)
+		dir, dirPath = sub, subPath
+	}
+	panic("unreachable") // strings.Split returns at least one element
+}
+
+// follow walks the target of a symbolic link in the directory dirPath,
+// relative to the root.
+func follow(dirPath, target string, links *int, root *hostDir) (*hostDir, string, string, error) {
+	if *links++; *links > maxSymlinks {
+		return nil, "", "", syscall.ELOOP
+	}
+	target, ok := resolveLink(dirPath, target)
+	if !ok {
+		return nil, "", "", syscall.ENOENT
+	}
+	return walk(root, ".", target, true, links, root)
+}
+
+// release closes dir, unless it is the root, which is closed by resolve.
+func release(dir, root *hostDir) {
+	if dir != root {
+		dir.close()
+	}
+}
+
+// osErr returns the syscall.Errno wrapped by err, if there is one.
+func osErr(err error) error {
+	if errno := platform.UnwrapOSError(err); errno != 0 {
+		return errno
+	}
+	return err
+}
+
+// resolveLink returns the target of a symbolic link in dir relative to the
+// root, or false if it would be outside the root.
+func resolveLink(dir, target string) (string, bool) {
+	target = strings.ReplaceAll(target, "\\", "/") // Windows
+	if path.IsAbs(target) {
+		target = path.Clean(strings.TrimLeft(target, "/"))
+	} else {
+		target = path.Join(dir, target)
+	}
+	if target == ".." || strings.HasPrefix(target, "../") {
+		return "", false
+	}
+	return target, true
+}
+
+// Open implements fs.FS
+func (d dirFS) Open(name string) (fs.File, error) {
+	return d.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// OpenFile is like os.OpenFile, except name is relative to the directory.
+func (d dirFS) OpenFile(name string, flag int, perm fs.FileMode) (fs.File, error) {
+	// Like POSIX, exclusive create fails on a symbolic link, even dangling.
+	followLast := flag&(os.O_CREATE|os.O_EXCL) != os.O_CREATE|os.O_EXCL
+	dir, last, err := d.resolve("open", name, followLast)
+	if err != nil {
+		return nil, err
+	}
+	defer dir.close()
+	f, err := dir.openFile(last, flag, perm)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: osErr(err)}
+	}
+	return f, nil // don't return a typed nil
+}
+
+// Stat implements fs.StatFS
+func (d dirFS) Stat(name string) (fs.FileInfo, error) {
+	return d.lstat("stat", name, true)
+}
+
+// Lstat is like os.Lstat, except name is relative to the directory.
+func (d dirFS) Lstat(name string) (fs.FileInfo, error) {
+	return d.lstat("lstat", name, false)
+}
+
+func (d dirFS) lstat(op, name string, followLast bool) (fs.FileInfo, error) {
+	dir, last, err := d.resolve(op, name, followLast)
+	if err != nil {
+		return nil, err
+	}
+	defer dir.close()
+	info, err := dir.lstat(last) // already resolved
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: osErr(err)}
+	}
+	return info, nil
+}
+
+// Mkdir is like os.Mkdir, except name is relative to the directory.
+func (d dirFS) Mkdir(name string, perm fs.FileMode) error {
+	dir, last, err := d.resolve("mkdir", name, false)
+	if err != nil {
+		return err
+	}
+	defer dir.close()
+	if err = dir.mkdir(last, perm); err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: osErr(err)}
+	}
+	return nil
+}
+
+// Remove is like os.Remove, except name is relative to the directory.
+func (d dirFS) Remove(name string) error {
+	dir, last, err := d.resolve("remove", name, false)
+	if err != nil {
+		return err
+	}
+	defer dir.close()
+	if err = dir.remove(last); err != nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: osErr(err)}
+	}
+	return nil
+}
+
+// Rename is like os.Rename, except the names are relative to the directory.
+func (d dirFS) Rename(oldName, newName string) error {
+	oldDir, oldLast, err := d.resolve("rename", oldName, false)
+	if err != nil {
+		return err
+	}
+	defer oldDir.close()
+	newDir, newLast, err := d.resolve("rename", newName, false)
+	if err != nil {
+		return err
+	}
+	defer newDir.close()
+	if err = rename(oldDir, oldLast, newDir, newLast); err != nil {
+		return &os.LinkError{Op: "rename", Old: oldName, New: newName, Err: osErr(err)}
+	}
+	return nil
+}
+
+// Symlink is like os.Symlink, except name is relative to the directory. The
+// target is written as-is, and resolved relative to the directory when
+// followed.
+func (d dirFS) Symlink(target, name string) error {
+	dir, last, err := d.resolve("symlink", name, false)
+	if err != nil {
+		return err
+	}
+	defer dir.close()
+	if err = dir.symlink(target, last); err != nil {
+		return &os.LinkError{Op: "symlink", Old: target, New: name, Err: osErr(err)}
+	}
+	return nil
+}
+
+// Readlink is like os.Readlink, except name is relative to the directory.
+func (d dirFS) Readlink(name string) (string, error) {
+	dir, last, err := d.resolve("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	defer dir.close()
+	target, err := dir.readlink(last)
+	if err != nil {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: osErr(err)}
+	}
+	return target, nil
+}
+
+// Link is like os.Link, except the names are relative to the directory. Like
+// Linux, a symbolic link at oldName isn't followed.
+func (d dirFS) Link(oldName, newName string) error {
+	oldDir, oldLast, err := d.resolve("link", oldName, false)
+	if err != nil {
+		return err
+	}
+	defer oldDir.close()
+	newDir, newLast, err := d.resolve("link", newName, false)
+	if err != nil {
+		return err
+	}
+	defer newDir.close()
+	if err = link(oldDir, oldLast, newDir, newLast); err != nil {
+		return &os.LinkError{Op: "link", Old: oldName, New: newName, Err: osErr(err)}
+	}
+	return nil
+}
+
+// Chtimes is like os.Chtimes, except name is relative to the directory.
+func (d dirFS) Chtimes(name string, atime, mtime time.Time) error {
+	dir, last, err := d.resolve("chtimes", name, true)
+	if err != nil {
+		return err
+	}
+	defer dir.close()
+	if err = dir.chtimes(last, atime, mtime); err != nil {
+		return &fs.PathError{Op: "chtimes", Path: name, Err: osErr(err)}
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package sysfs
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	// atFdcwd is AT_FDCWD, which syscall doesn't define on all architectures.
+	atFdcwd = -0x64
+	// oPath is O_PATH, which syscall doesn't define on all architectures.
+	oPath = 0x200000
+	// atSymlinkNofollow is AT_SYMLINK_NOFOLLOW.
+	atSymlinkNofollow = 0x100
+	// atRemovedir is AT_REMOVEDIR.
+	atRemovedir = 0x200
+)
+
+// hostDir is a directory descriptor, which names are opened relative to.
+type hostDir struct {
+	fd int
+	// path is only used for the names of opened files.
+	path string
+}
+
+func openRoot(dir string) (*hostDir, error) {
+	fd, err := open(atFdcwd, dir, oPath|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &hostDir{fd: fd, path: dir}, nil
+}
+
+// openDir opens the directory name, failing if it is a symbolic link.
+func (d *hostDir) openDir(name string) (*hostDir, error) {
+	fd, err := open(d.fd, name, oPath|syscall.O_DIRECTORY|syscall.O_NOFOLLOW|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &hostDir{fd: fd, path: path.Join(d.path, name)}, nil
+}
+
+// openFile is like os.OpenFile, except it fails if name is a symbolic link.
+func (d *hostDir) openFile(name string, flag int, perm fs.FileMode) (*os.File, error) {
+	fd, err := open(d.fd, name, flag|syscall.O_NOFOLLOW|syscall.O_CLOEXEC|syscall.O_LARGEFILE, syscallMode(perm))
+	if err != nil {
+		return nil, err
+	}
+	return os.NewFile(uintptr(fd), path.Join(d.path, name)), nil
+}
+
+func (d *hostDir) lstat(name string) (fs.FileInfo, error) {
+	fd, err := open(d.fd, name, oPath|syscall.O_NOFOLLOW|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	f := os.NewFile(uintptr(fd), name) // so that Name is the base name
+	defer f.Close()
+	return f.Stat()
+}
+
+func (d *hostDir) readlink(name string) (string, error) {
+	p, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return "", err
+	}
+	for size := 128; ; size *= 2 {
+		buf := make([]byte, size)
+		n, _, errno := syscall.Syscall6(syscall.SYS_READLINKAT, uintptr(d.fd), uintptr(unsafe.Pointer(p)),
+			uintptr(unsafe.Pointer(&buf[0])), uintptr(size), 0, 0)
+		if errno != 0 {
+			return "", errno
+		}
+		if int(n) < size {
+			return string(buf[:n]), nil
+		}
+	}
+}
+
+func (d *hostDir) mkdir(name string, perm fs.FileMode) error {
+	return syscall.Mkdirat(d.fd, name, syscallMode(perm))
+}
+
+// remove is like os.Remove, which tries both unlink and rmdir.
+func (d *hostDir) remove(name string) error {
+	err := unlinkat(d.fd, name, 0)
+	if err == nil {
+		return nil
+	}
+	err1 := unlinkat(d.fd, name, atRemovedir)
+	if err1 == nil {
+		return nil
+	}
+	// Like os.Remove, both failed: pick the error of the one which applies.
+	if err1 != syscall.ENOTDIR {
+		err = err1
+	}
+	return err
+}
+
+func (d *hostDir) symlink(target, name string) error {
+	p0, err := syscall.BytePtrFromString(target)
+	if err != nil {
+		return err
+	}
+	p1, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return err
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_SYMLINKAT, uintptr(unsafe.Pointer(p0)), uintptr(d.fd),
+		uintptr(unsafe.Pointer(p1))); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// chtimes is like os.Chtimes, except name is already resolved, so isn't
+// followed.
+func (d *hostDir) chtimes(name string, atime, mtime time.Time) error {
+	p, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return err
+	}
+	ts := [2]syscall.Timespec{
+		syscall.NsecToTimespec(atime.UnixNano()),
+		syscall.NsecToTimespec(mtime.UnixNano()),
+	}
+	if _, _, errno := syscall.Syscall6(syscall.SYS_UTIMENSAT, uintptr(d.fd), uintptr(unsafe.Pointer(p)),
+		uintptr(unsafe.Pointer(&ts[0])), atSymlinkNofollow, 0, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func (d *hostDir) close() {
+	_ = syscall.Close(d.fd)
+}
+
+func rename(oldDir *hostDir, oldName string, newDir *hostDir, newName string) error {
+	return syscall.Renameat(oldDir.fd, oldName, newDir.fd, newName)
+}
+
+// link is like os.Link, so doesn't follow a symbolic link at oldName.
+func link(oldDir *hostDir, oldName string, newDir *hostDir, newName string) error {
+	p0, err := syscall.BytePtrFromString(oldName)
+	if err != nil {
+		return err
+	}
+	p1, err := syscall.BytePtrFromString(newName)
+	if err != nil {
+		return err
+	}
+	if _, _, errno := syscall.Syscall6(syscall.SYS_LINKAT, uintptr(oldDir.fd), uintptr(unsafe.Pointer(p0)),
+		uintptr(newDir.fd), uintptr(unsafe.Pointer(p1)), 0, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// open is like syscall.Openat, except it retries on syscall.EINTR, like
+// os.OpenFile.
+func open(dirfd int, name string, flag int, mode uint32) (int, error) {
+	for {
+		fd, err := syscall.Openat(dirfd, name, flag, mode)
+		if err != syscall.EINTR {
+			return fd, err
+		}
+	}
+}
+
+func unlinkat(dirfd int, name string, flag int) error {
+	p, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return err
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_UNLINKAT, uintptr(dirfd), uintptr(unsafe.Pointer(p)), uintptr(flag)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// syscallMode is like the unexported function of the same name in os.
+func syscallMode(perm fs.FileMode) (mode uint32) {
+	mode = uint32(perm.Perm())
+	if perm&fs.ModeSetuid != 0 {
+		mode |= syscall.S_ISUID
+	}
+	if perm&fs.ModeSetgid != 0 {
+		mode |= syscall.S_ISGID
+	}
+	if perm&fs.ModeSticky != 0 {
+		mode |= syscall.S_ISVTX
+	}
+	return
+}
@@ -0,0 +1,218 @@
+package sysfs
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"runtime"
+	"syscall"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestDirFS(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(path.Join(dir, "bear.txt"), []byte("pooh"), 0o600))
+	require.NoError(t, os.Mkdir(path.Join(dir, "animals"), 0o700))
+
+	require.NoError(t, fstest.TestFS(NewDirFS(dir), "bear.txt", "animals"))
+}
+
+func TestDirFS_writes(t *testing.T) {
+	dir := t.TempDir()
+	fsys := NewDirFS(dir)
+
+	f, err := OpenFile(fsys, "bear.txt", os.O_CREATE|os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	_, err = f.(*os.File).WriteString("pooh")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	require.NoError(t, Mkdir(fsys, "animals", 0o700))
+	require.NoError(t, Rename(fsys, "bear.txt", "animals/bear.txt"))
+	b, err := os.ReadFile(path.Join(dir, "animals", "bear.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "pooh", string(b))
+
+	require.NoError(t, Link(fsys, "animals/bear.txt", "pooh.txt"))
+	require.NoError(t, Symlink(fsys, "animals/bear.txt", "link"))
+	target, err := Readlink(fsys, "link")
+	require.NoError(t, err)
+	require.Equal(t, "animals/bear.txt", target)
+	info, err := Lstat(fsys, "link")
+	require.NoError(t, err)
+	require.Equal(t, fs.ModeSymlink, info.Mode().Type())
+
+	mtime := time.Unix(1667482413, 0)
+	require.NoError(t, Chtimes(fsys, "pooh.txt", mtime, mtime))
+	info, err = fs.Stat(fsys, "pooh.txt")
+	require.NoError(t, err)
+	require.Equal(t, mtime, info.ModTime())
+
+	require.NoError(t, Remove(fsys, "link"))
+	require.NoError(t, Remove(fsys, "pooh.txt"))
+	require.NoError(t, Remove(fsys, "animals/bear.txt"))
+	require.NoError(t, Remove(fsys, "animals"))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(entries))
+}
+
+func TestDirFS_invalidPath(t *testing.T) {
+	fsys := NewDirFS(t.TempDir())
+
+	_, err := fsys.Open("../bear.txt")
+	require.ErrorIs(t, err, syscall.EINVAL)
+	require.ErrorIs(t, Mkdir(fsys, "/animals", 0o700), syscall.EINVAL)
+	require.ErrorIs(t, Remove(fsys, "animals/.."), syscall.EINVAL)
+	require.ErrorIs(t, Rename(fsys, "bear.txt", "../bear.txt"), syscall.EINVAL)
+	require.ErrorIs(t, Symlink(fsys, "bear.txt", "../link"), syscall.EINVAL)
+	require.ErrorIs(t, Link(fsys, "../bear.txt", "pooh.txt"), syscall.EINVAL)
+}
+
+func TestDirFS_symlinks(t *testing.T) {
+	tmpDir := t.TempDir()
+	require.NoError(t, os.WriteFile(path.Join(tmpDir, "secret.txt"), []byte("secret"), 0o600))
+	dir := path.Join(tmpDir, "root")
+	require.NoError(t, os.MkdirAll(path.Join(dir, "animals", "mammals"), 0o700))
+	require.NoError(t, os.WriteFile(path.Join(dir, "bear.txt"), []byte("pooh"), 0o600))
+
+	for link, target := range map[string]string{
+		"relative":         "bear.txt",
+		"rooted":           "/bear.txt",
+		"animals/parent":   "../bear.txt",
+		"animals/up":       "..",
+		"chain":            "animals/parent",
+		"escape":           "../secret.txt",
+		"escapeAbsolute":   path.Join(tmpDir, "secret.txt"),
+		"escapeRooted":     "/../secret.txt",
+		"loop":             "loop",
+		"dangling":         "panda.txt",
+		"animals/mammals2": "mammals",
+	} {
+		require.NoError(t, os.Symlink(target, path.Join(dir, link)))
+	}
+	fsys := NewDirFS(dir)
+
+	tests := []struct {
+		name, expected string
+		expectedErr    syscall.Errno
+	}{
+		{name: "relative", expected: "pooh"},
+		{name: "rooted", expected: "pooh"},
+		{name: "animals/parent", expected: "pooh"},
+		{name: "animals/up/bear.txt", expected: "pooh"},
+		{name: "animals/up/animals/up/bear.txt", expected: "pooh"},
+		{name: "chain", expected: "pooh"},
+		{name: "escape", expectedErr: syscall.ENOENT},
+		{name: "escapeAbsolute", expectedErr: syscall.ENOENT},
+		{name: "escapeRooted", expectedErr: syscall.ENOENT},
+		{name: "loop", expectedErr: syscall.ELOOP},
+		{name: "dangling", expectedErr: syscall.ENOENT},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := fs.ReadFile(fsys, tc.name)
+			if tc.expectedErr != 0 {
+				require.ErrorIs(t, err, tc.expectedErr)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tc.expected, string(b))
+			}
+		})
+	}
+
+	t.Run("stat follows links", func(t *testing.T) {
+		info, err := fs.Stat(fsys, "animals/mammals2")
+		require.NoError(t, err)
+		require.True(t, info.IsDir())
+	})
+
+	t.Run("lstat doesn't follow the last link", func(t *testing.T) {
+		info, err := Lstat(fsys, "animals/up")
+		require.NoError(t, err)
+		require.Equal(t, fs.ModeSymlink, info.Mode().Type())
+	})
+
+	t.Run("create via dangling link", func(t *testing.T) {
+		f, err := OpenFile(fsys, "dangling", os.O_CREATE|os.O_WRONLY, 0o600)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+		_, err = os.Stat(path.Join(dir, "panda.txt"))
+		require.NoError(t, err)
+	})
+
+	t.Run("exclusive create on link", func(t *testing.T) {
+		_, err := OpenFile(fsys, "relative", os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		require.ErrorIs(t, err, syscall.EEXIST)
+	})
+
+	t.Run("create via escaping parent", func(t *testing.T) {
+		require.NoError(t, os.Symlink("..", path.Join(dir, "out")))
+		_, err := OpenFile(fsys, "out/escaped.txt", os.O_CREATE|os.O_WRONLY, 0o600)
+		require.ErrorIs(t, err, syscall.ENOENT)
+		require.ErrorIs(t, Mkdir(fsys, "out/escaped", 0o700), syscall.ENOENT)
+		require.ErrorIs(t, Remove(fsys, "out/secret.txt"), syscall.ENOENT)
+
+		entries, err := os.ReadDir(tmpDir)
+		require.NoError(t, err)
+		require.Equal(t, 2, len(entries))
+	})
+}
+
+func TestDirFS_symlinkRace(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("resolution is only atomic on linux")
+	}
+
+	tmpDir := t.TempDir()
+	outside := path.Join(tmpDir, "outside")
+	require.NoError(t, os.Mkdir(outside, 0o700))
+	require.NoError(t, os.WriteFile(path.Join(outside, "file.txt"), []byte("secret"), 0o600))
+	dir := path.Join(tmpDir, "root")
+	sub := path.Join(dir, "sub")
+	require.NoError(t, os.MkdirAll(sub, 0o700))
+	require.NoError(t, os.WriteFile(path.Join(sub, "file.txt"), []byte("pooh"), 0o600))
+	fsys := NewDirFS(dir)
+
+	// Concurrently replace the parent directory with a symbolic link to a
+	// directory outside the root, e.g. like a guest could via path_symlink.
+	done := make(chan struct{})
+	swapped := make(chan struct{})
+	go func() {
+		defer close(swapped)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			_ = os.Rename(sub, sub+".real")
+			_ = os.Symlink(outside, sub)
+			_ = os.Remove(sub)
+			_ = os.Rename(sub+".real", sub)
+		}
+	}()
+	defer func() {
+		close(done)
+		<-swapped
+	}()
+
+	for i := 0; i < 10000; i++ {
+		f, err := fsys.Open("sub/file.txt")
+		if err != nil {
+			continue // e.g. ENOENT while swapped
+		}
+		b, err := io.ReadAll(f)
+		require.NoError(t, f.Close())
+		require.NoError(t, err)
+		require.Equal(t, "pooh", string(b))
+	}
+}
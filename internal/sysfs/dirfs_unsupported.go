@@ -0,0 +1,84 @@
+//go:build !linux
+
+package sysfs
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/tetratelabs/wazero/internal/platform"
+)
+
+// hostDir is the path of a directory, as this platform doesn't support
+// opening names relative to a directory descriptor.
+type hostDir struct {
+	path string
+}
+
+func openRoot(dir string) (*hostDir, error) {
+	return &hostDir{path: dir}, nil
+}
+
+func (d *hostDir) join(name string) string {
+	return filepath.Join(d.path, filepath.FromSlash(name))
+}
+
+// openDir returns the directory name, failing if it is a symbolic link.
+func (d *hostDir) openDir(name string) (*hostDir, error) {
+	p := d.join(name)
+	info, err := os.Lstat(p)
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		return nil, syscall.ELOOP
+	} else if !info.IsDir() {
+		return nil, syscall.ENOTDIR
+	}
+	return &hostDir{path: p}, nil
+}
+
+// openFile is like os.OpenFile, except it fails if name is a symbolic link,
+// on platforms which support it.
+func (d *hostDir) openFile(name string, flag int, perm fs.FileMode) (*os.File, error) {
+	return os.OpenFile(d.join(name), flag|platform.O_NOFOLLOW, perm)
+}
+
+func (d *hostDir) lstat(name string) (fs.FileInfo, error) {
+	return os.Lstat(d.join(name))
+}
+
+func (d *hostDir) readlink(name string) (string, error) {
+	return os.Readlink(d.join(name))
+}
+
+func (d *hostDir) mkdir(name string, perm fs.FileMode) error {
+	return os.Mkdir(d.join(name), perm)
+}
+
+func (d *hostDir) remove(name string) error {
+	return os.Remove(d.join(name))
+}
+
+func (d *hostDir) symlink(target, name string) error {
+	return os.Symlink(target, d.join(name))
+}
+
+// chtimes is like os.Chtimes, except name is already resolved.
+func (d *hostDir) chtimes(name string, atime, mtime time.Time) error {
+	return os.Chtimes(d.join(name), atime, mtime)
+}
+
+func (d *hostDir) close() {}
+
+func rename(oldDir *hostDir, oldName string, newDir *hostDir, newName string) error {
+	return os.Rename(oldDir.join(oldName), newDir.join(newName))
+}
+
+// link is like os.Link, so doesn't follow a symbolic link at oldName.
+func link(oldDir *hostDir, oldName string, newDir *hostDir, newName string) error {
+	return os.Link(oldDir.join(oldName), newDir.join(newName))
+}
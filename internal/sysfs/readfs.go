@@ -0,0 +1,38 @@
+package sysfs
+
+import "io/fs"
+
+// NewReadFS returns a view of fsys which hides any write methods it
+// implements, such as OpenFile or Mkdir. Functions in this package fail with
+// syscall.EROFS when given the result.
+func NewReadFS(fsys fs.FS) fs.FS {
+	if _, ok := fsys.(*readFS); ok {
+		return fsys
+	}
+	return &readFS{fsys}
+}
+
+type readFS struct {
+	fs fs.FS
+}
+
+// Open implements fs.FS
+func (r *readFS) Open(name string) (fs.File, error) {
+	return r.fs.Open(name)
+}
+
+// Stat implements fs.StatFS
+func (r *readFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(r.fs, name)
+}
+
+// Lstat is like Lstat, except name is relative to the underlying file system.
+func (r *readFS) Lstat(name string) (fs.FileInfo, error) {
+	return Lstat(r.fs, name)
+}
+
+// Readlink is like Readlink, except name is relative to the underlying file
+// system.
+func (r *readFS) Readlink(name string) (string, error) {
+	return Readlink(r.fs, name)
+}
@@ -0,0 +1,63 @@
+package sysfs
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero/experimental/memfs"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestReadFS(t *testing.T) {
+	m := memfs.New()
+	require.NoError(t, m.WriteFile("bear.txt", []byte("pooh"), 0o600))
+	require.NoError(t, m.Symlink("bear.txt", "link"))
+	fsys := NewReadFS(m)
+
+	// Wrapping twice is the same as once.
+	require.Equal(t, fsys, NewReadFS(fsys))
+
+	b, err := fs.ReadFile(fsys, "bear.txt")
+	require.NoError(t, err)
+	require.Equal(t, "pooh", string(b))
+
+	target, err := Readlink(fsys, "link")
+	require.NoError(t, err)
+	require.Equal(t, "bear.txt", target)
+	info, err := Lstat(fsys, "link")
+	require.NoError(t, err)
+	require.Equal(t, fs.ModeSymlink, info.Mode().Type())
+
+	_, err = OpenFile(fsys, "bear.txt", os.O_RDWR, 0)
+	require.ErrorIs(t, err, syscall.EROFS)
+	_, err = OpenFile(fsys, "panda.txt", os.O_CREATE|os.O_WRONLY, 0o600)
+	require.ErrorIs(t, err, syscall.EROFS)
+	require.ErrorIs(t, Mkdir(fsys, "animals", 0o700), syscall.EROFS)
+	require.ErrorIs(t, Remove(fsys, "bear.txt"), syscall.EROFS)
+	require.ErrorIs(t, Rename(fsys, "bear.txt", "pooh.txt"), syscall.EROFS)
+	require.ErrorIs(t, Symlink(fsys, "bear.txt", "pooh.txt"), syscall.EROFS)
+	require.ErrorIs(t, Link(fsys, "bear.txt", "pooh.txt"), syscall.EROFS)
+	require.ErrorIs(t, Chtimes(fsys, "bear.txt", time.Time{}, time.Time{}), syscall.EROFS)
+
+	// The underlying file system is unchanged.
+	entries, err := fs.ReadDir(m, ".")
+	require.NoError(t, err)
+	require.Equal(t, 2, len(entries))
+}
+
+func TestReadFS_DirFS(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(path.Join(dir, "bear.txt"), []byte("pooh"), 0o600))
+	fsys := NewReadFS(NewDirFS(dir))
+
+	_, err := OpenFile(fsys, "bear.txt", os.O_WRONLY|os.O_TRUNC, 0)
+	require.ErrorIs(t, err, syscall.EROFS)
+
+	b, err := os.ReadFile(path.Join(dir, "bear.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "pooh", string(b))
+}
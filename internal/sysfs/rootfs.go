@@ -0,0 +1,515 @@
+package sysfs
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Mount is a file system visible to the guest at GuestPath.
+type Mount struct {
+	// GuestPath is the path the guest sees FS at, e.g. "/" or "/tmp".
+	GuestPath string
+
+	// FS is the file system to mount.
+	FS fs.FS
+
+	// ReadOnly hides any write methods FS implements, as if by NewReadFS.
+	ReadOnly bool
+}
+
+// NewRootFS returns a file system which joins the mounts at their guest
+// paths. Mounts can be nested, e.g. "/" and "/tmp", in which case the longest
+// guest path matching a name wins. Any directories needed to reach a mount
+// point, which don't exist in a parent mount, are synthesized read-only.
+//
+// When a guest path is repeated, the last mount wins. This returns nil when
+// there are no mounts.
+func NewRootFS(mounts []Mount) (fs.FS, error) {
+	byPath := map[string]int{}
+	var ms []mount
+	for _, m := range mounts {
+		p, err := normalizeGuestPath(m.GuestPath)
+		if err != nil {
+			return nil, err
+		}
+		fsys := m.FS
+		if m.ReadOnly {
+			fsys = NewReadFS(fsys)
+		}
+		if i, ok := byPath[p]; ok {
+			ms[i].fs = fsys
+		} else {
+			byPath[p] = len(ms)
+			ms = append(ms, mount{path: p, fs: fsys})
+		}
+	}
+
+	switch len(ms) {
+	case 0:
+		return nil, nil
+	case 1:
+		if ms[0].path == "." {
+			return ms[0].fs, nil
+		}
+	}
+
+	// Sort deepest paths first, so that the first match is the longest.
+	sort.Slice(ms, func(i, j int) bool {
+		if li, lj := len(ms[i].path), len(ms[j].path); li != lj {
+			return li > lj
+		}
+		return ms[i].path < ms[j].path
+	})
+	return &rootFS{mounts: ms}, nil
+}
+
+// normalizeGuestPath returns the fs.FS name of the guest path, e.g. "." for
+// "/" and "tmp" for "/tmp/".
+func normalizeGuestPath(guestPath string) (string, error) {
+	for _, elem := range strings.Split(guestPath, "/") {
+		if elem == ".." {
+			return "", fmt.Errorf("invalid guest path: %s", guestPath)
+		}
+	}
+	if p := path.Clean("/" + guestPath)[1:]; p != "" {
+		return p, nil
+	}
+	return ".", nil
+}
+
+type mount struct {
+	// path is the fs.FS name of the mount point, e.g. "." or "tmp".
+	path string
+	fs   fs.FS
+}
+
+// rel returns the name in the mount that corresponds to name, or false if
+// name isn't under the mount point.
+func (m *mount) rel(name string) (string, bool) {
+	switch {
+	case m.path == ".":
+		return name, true
+	case name == m.path:
+		return ".", true
+	case strings.HasPrefix(name, m.path+"/"):
+		return name[len(m.path)+1:], true
+	}
+	return "", false
+}
+
+type rootFS struct {
+	// mounts are sorted deepest path first.
+	mounts []mount
+}
+
+// resolve returns the mount of name and the name relative to it, or nil if
+// no mount includes name.
+func (r *rootFS) resolve(name string) (*mount, string) {
+	for i := range r.mounts {
+		m := &r.mounts[i]
+		if rel, ok := m.rel(name); ok {
+			return m, rel
+		}
+	}
+	return nil, ""
+}
+
+// children returns the base names of directories under name which are, or
+// lead to, a mount point deeper than name. These shadow any entry of the same
+// name in the mount of name.
+func (r *rootFS) children(name string) (names []string) {
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+	seen := map[string]struct{}{}
+	for i := range r.mounts {
+		p := r.mounts[i].path
+		if p == "." || !strings.HasPrefix(p, prefix) || p == name {
+			continue
+		}
+		child := p[len(prefix):]
+		if j := strings.IndexByte(child, '/'); j != -1 {
+			child = child[:j]
+		}
+		if _, ok := seen[child]; !ok {
+			seen[child] = struct{}{}
+			names = append(names, child)
+		}
+	}
+	sort.Strings(names)
+	return
+}
+
+// isMountPoint returns true if name is a mount point or leads to one.
+func (r *rootFS) isMountPoint(name string) bool {
+	for i := range r.mounts {
+		if p := r.mounts[i].path; name == "." || p == name || strings.HasPrefix(p, name+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Open implements fs.FS
+func (r *rootFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	m, rel := r.resolve(name)
+	children := r.children(name)
+	// The root of a mount is wrapped, so that its name isn't ".".
+	isMountRoot := m != nil && rel == "." && name != "."
+	if len(children) == 0 && !isMountRoot {
+		if m == nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+		return m.fs.Open(rel)
+	}
+
+	d := &mountDir{r: r, name: name, children: children}
+	if m != nil {
+		f, err := m.fs.Open(rel)
+		switch {
+		case err != nil:
+			if len(children) == 0 {
+				return nil, err
+			}
+		case isReadDirFile(f):
+			d.f = f.(fs.ReadDirFile)
+		default:
+			_ = f.Close() // shadowed by the synthesized directory
+		}
+	}
+	return d, nil
+}
+
+func isReadDirFile(f fs.File) bool {
+	_, ok := f.(fs.ReadDirFile)
+	return ok
+}
+
+// Stat implements fs.StatFS
+func (r *rootFS) Stat(name string) (fs.FileInfo, error) {
+	return r.stat("stat", name, fs.Stat)
+}
+
+// Lstat is like os.Lstat, except name is relative to the root.
+func (r *rootFS) Lstat(name string) (fs.FileInfo, error) {
+	return r.stat("lstat", name, Lstat)
+}
+
+func (r *rootFS) stat(op, name string, stat func(fs.FS, string) (fs.FileInfo, error)) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if r.isMountPoint(name) {
+		return r.mountPointInfo(name), nil
+	}
+	m, rel := r.resolve(name)
+	if m == nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return stat(m.fs, rel)
+}
+
+// mountPointInfo returns the info of a directory which is or leads to a mount
+// point. When the directory exists in a mount, its info is used.
+func (r *rootFS) mountPointInfo(name string) fs.FileInfo {
+	if m, rel := r.resolve(name); m != nil {
+		if info, err := fs.Stat(m.fs, rel); err == nil && info.IsDir() {
+			return &namedInfo{FileInfo: info, name: path.Base(name)}
+		}
+	}
+	return &syntheticDirInfo{name: path.Base(name)}
+}
+
+// OpenFile is like os.OpenFile, except name is relative to the root.
+func (r *rootFS) OpenFile(name string, flag int, perm fs.FileMode) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if r.isMountPoint(name) {
+		var err error
+		switch {
+		case flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+			err = syscall.EEXIST
+		case flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_TRUNC) != 0:
+			err = syscall.EISDIR
+		default:
+			return r.Open(name)
+		}
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	m, rel := r.resolve(name)
+	if m == nil {
+		return nil, r.unmountedError("open", name, flag&os.O_CREATE != 0)
+	}
+	return OpenFile(m.fs, rel, flag, perm)
+}
+
+// unmountedError returns the error of op on name, which isn't in any mount.
+// When creating, and the parent is a synthesized directory, this is
+// syscall.EROFS, as synthesized directories are read-only.
+func (r *rootFS) unmountedError(op, name string, create bool) error {
+	err := syscall.ENOENT
+	if create && r.isMountPoint(path.Dir(name)) {
+		err = syscall.EROFS
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+// Mkdir is like os.Mkdir, except name is relative to the root.
+func (r *rootFS) Mkdir(name string, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	if r.isMountPoint(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: syscall.EEXIST}
+	}
+	m, rel := r.resolve(name)
+	if m == nil {
+		return r.unmountedError("mkdir", name, true)
+	}
+	return Mkdir(m.fs, rel, perm)
+}
+
+// Remove is like os.Remove, except name is relative to the root.
+func (r *rootFS) Remove(name string) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	if r.isMountPoint(name) {
+		return &fs.PathError{Op: "remove", Path: name, Err: syscall.EBUSY}
+	}
+	m, rel := r.resolve(name)
+	if m == nil {
+		return r.unmountedError("remove", name, false)
+	}
+	return Remove(m.fs, rel)
+}
+
+// Rename is like os.Rename, except the names are relative to the root. This
+// returns syscall.EXDEV if they aren't in the same mount.
+func (r *rootFS) Rename(oldName, newName string) error {
+	m, oldRel, newRel, err := r.resolveLink("rename", oldName, newName, syscall.EBUSY)
+	if err != nil {
+		return err
+	}
+	return Rename(m.fs, oldRel, newRel)
+}
+
+// Link is like os.Link, except the names are relative to the root. This
+// returns syscall.EXDEV if they aren't in the same mount.
+func (r *rootFS) Link(oldName, newName string) error {
+	m, oldRel, newRel, err := r.resolveLink("link", oldName, newName, syscall.EEXIST)
+	if err != nil {
+		return err
+	}
+	return Link(m.fs, oldRel, newRel)
+}
+
+// resolveLink resolves the names of a rename or link to the same mount. busy
+// is the error when newName is a mount point.
+func (r *rootFS) resolveLink(op, oldName, newName string, busy syscall.Errno) (*mount, string, string, error) {
+	var err error
+	var oldM, newM *mount
+	var oldRel, newRel string
+	switch {
+	case !fs.ValidPath(oldName) || !fs.ValidPath(newName):
+		err = fs.ErrInvalid
+	case r.isMountPoint(oldName):
+		err = syscall.EBUSY
+	case r.isMountPoint(newName):
+		err = busy
+	default:
+		if oldM, oldRel = r.resolve(oldName); oldM == nil {
+			err = syscall.ENOENT
+		} else if newM, newRel = r.resolve(newName); newM != oldM {
+			err = syscall.EXDEV
+		}
+	}
+	if err != nil {
+		return nil, "", "", &os.LinkError{Op: op, Old: oldName, New: newName, Err: err}
+	}
+	return oldM, oldRel, newRel, nil
+}
+
+// Symlink is like os.Symlink, except name is relative to the root. This
+// returns syscall.EPERM if the target would resolve outside the mount of
+// name, as the mount may not be able to follow it.
+func (r *rootFS) Symlink(target, name string) error {
+	var err error
+	var m *mount
+	var rel string
+	switch {
+	case !fs.ValidPath(name):
+		err = fs.ErrInvalid
+	case r.isMountPoint(name):
+		err = syscall.EEXIST
+	default:
+		if m, rel = r.resolve(name); m == nil {
+			return r.unmountedError("symlink", name, true)
+		}
+		resolved := path.Join(path.Dir(rel), target)
+		if path.IsAbs(target) || resolved == ".." || strings.HasPrefix(resolved, "../") {
+			err = syscall.EPERM
+		}
+	}
+	if err != nil {
+		return &fs.PathError{Op: "symlink", Path: name, Err: err}
+	}
+	return Symlink(m.fs, target, rel)
+}
+
+// Readlink is like os.Readlink, except name is relative to the root.
+func (r *rootFS) Readlink(name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	if r.isMountPoint(name) {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: syscall.EINVAL}
+	}
+	m, rel := r.resolve(name)
+	if m == nil {
+		return "", r.unmountedError("readlink", name, false)
+	}
+	return Readlink(m.fs, rel)
+}
+
+// Chtimes is like os.Chtimes, except name is relative to the root.
+func (r *rootFS) Chtimes(name string, atime, mtime time.Time) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "chtimes", Path: name, Err: fs.ErrInvalid}
+	}
+	m, rel := r.resolve(name)
+	if m == nil {
+		if r.isMountPoint(name) {
+			return &fs.PathError{Op: "chtimes", Path: name, Err: syscall.EROFS}
+		}
+		return r.unmountedError("chtimes", name, false)
+	}
+	return Chtimes(m.fs, rel, atime, mtime)
+}
+
+// mountDir is a directory which is, or leads to, a mount point. Its entries
+// are those of the underlying directory, if any, with mount points replacing
+// any entries of the same name.
+type mountDir struct {
+	r        *rootFS
+	name     string
+	children []string
+
+	// f is the underlying directory, or nil if it is synthesized.
+	f fs.ReadDirFile
+
+	// entries are read on the first call to ReadDir.
+	entries []fs.DirEntry
+	read    bool
+}
+
+// Stat implements fs.File
+func (d *mountDir) Stat() (fs.FileInfo, error) {
+	return d.r.mountPointInfo(d.name), nil
+}
+
+// Read implements fs.File
+func (d *mountDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: syscall.EISDIR}
+}
+
+// Close implements fs.File
+func (d *mountDir) Close() error {
+	if d.f != nil {
+		return d.f.Close()
+	}
+	return nil
+}
+
+// ReadDir implements fs.ReadDirFile
+func (d *mountDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.read {
+		if err := d.readEntries(); err != nil {
+			return nil, err
+		}
+		d.read = true
+	}
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+func (d *mountDir) readEntries() error {
+	shadowed := make(map[string]struct{}, len(d.children))
+	for _, c := range d.children {
+		shadowed[c] = struct{}{}
+	}
+	if d.f != nil {
+		entries, err := d.f.ReadDir(-1)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if _, ok := shadowed[e.Name()]; !ok {
+				d.entries = append(d.entries, e)
+			}
+		}
+	}
+	for _, c := range d.children {
+		d.entries = append(d.entries, fs.FileInfoToDirEntry(d.r.mountPointInfo(path.Join(d.name, c))))
+	}
+	sort.Slice(d.entries, func(i, j int) bool { return d.entries[i].Name() < d.entries[j].Name() })
+	return nil
+}
+
+// namedInfo overrides the name of a fs.FileInfo, such as the root of a
+// mount, whose name is otherwise ".".
+type namedInfo struct {
+	fs.FileInfo
+	name string
+}
+
+// Name implements fs.FileInfo
+func (i *namedInfo) Name() string { return i.name }
+
+// syntheticDirInfo is the fs.FileInfo of a directory which only exists to
+// reach a mount point.
+type syntheticDirInfo struct {
+	name string
+}
+
+// Name implements fs.FileInfo
+func (i *syntheticDirInfo) Name() string { return i.name }
+
+// Size implements fs.FileInfo
+func (i *syntheticDirInfo) Size() int64 { return 0 }
+
+// Mode implements fs.FileInfo
+func (i *syntheticDirInfo) Mode() fs.FileMode { return fs.ModeDir | 0o555 }
+
+// ModTime implements fs.FileInfo
+func (i *syntheticDirInfo) ModTime() time.Time { return time.Unix(0, 0) }
+
+// IsDir implements fs.FileInfo
+func (i *syntheticDirInfo) IsDir() bool { return true }
+
+// Sys implements fs.FileInfo
+func (i *syntheticDirInfo) Sys() interface{} { return nil }
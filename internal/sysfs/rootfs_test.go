@@ -0,0 +1,388 @@
+package sysfs
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"syscall"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/tetratelabs/wazero/experimental/memfs"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+var animalsFS = fstest.MapFS{
+	"bear.txt":                 {Data: []byte("pooh")},
+	"fish/clownfish.txt":       {Data: []byte("nemo")},
+	"mammals/whale.txt":        {Data: []byte("moby dick")},
+	"mammals/primates/ape.txt": {Data: []byte("king kong")},
+}
+
+func subFS(dir string) fs.FS {
+	f, err := fs.Sub(animalsFS, dir)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+func TestNewRootFS(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		rootFS, err := NewRootFS(nil)
+		require.NoError(t, err)
+		require.Nil(t, rootFS)
+	})
+
+	t.Run("single root mount is unwrapped", func(t *testing.T) {
+		rootFS, err := NewRootFS([]Mount{{GuestPath: "/", FS: animalsFS}})
+		require.NoError(t, err)
+		require.Equal(t, fs.FS(animalsFS), rootFS)
+	})
+
+	t.Run("single read-only root mount", func(t *testing.T) {
+		rootFS, err := NewRootFS([]Mount{{GuestPath: "/", FS: animalsFS, ReadOnly: true}})
+		require.NoError(t, err)
+		require.Equal(t, NewReadFS(animalsFS), rootFS)
+	})
+
+	t.Run("last mount wins", func(t *testing.T) {
+		rootFS, err := NewRootFS([]Mount{
+			{GuestPath: "/animals", FS: subFS("fish")},
+			{GuestPath: "animals/", FS: subFS("mammals")},
+		})
+		require.NoError(t, err)
+		_, err = fs.Stat(rootFS, "animals/whale.txt")
+		require.NoError(t, err)
+		_, err = fs.Stat(rootFS, "animals/clownfish.txt")
+		require.ErrorIs(t, err, fs.ErrNotExist)
+	})
+
+	t.Run("invalid guest path", func(t *testing.T) {
+		_, err := NewRootFS([]Mount{{GuestPath: "/animals/../..", FS: animalsFS}})
+		require.EqualError(t, err, "invalid guest path: /animals/../..")
+	})
+}
+
+func TestRootFS_Open(t *testing.T) {
+	tests := []struct {
+		name    string
+		mounts  []Mount
+		path    string
+		content string
+	}{
+		{
+			name:    "single mount to root",
+			mounts:  []Mount{{GuestPath: "/", FS: animalsFS}},
+			path:    "mammals/primates/ape.txt",
+			content: "king kong",
+		},
+		{
+			name:    "single mount to path",
+			mounts:  []Mount{{GuestPath: "/mammals", FS: animalsFS}},
+			path:    "mammals/bear.txt",
+			content: "pooh",
+		},
+		{
+			name:   "single mount to path",
+			mounts: []Mount{{GuestPath: "/mammals", FS: animalsFS}},
+			path:   "mammals/whale.txt",
+		},
+		{
+			name:   "single mount to path",
+			mounts: []Mount{{GuestPath: "/mammals", FS: animalsFS}},
+			path:   "bear.txt",
+		},
+		{
+			name:   "single mount to path, not a prefix",
+			mounts: []Mount{{GuestPath: "/mammals", FS: animalsFS}},
+			path:   "mammalsbear.txt",
+		},
+		{
+			name: "non-overlapping mounts",
+			mounts: []Mount{
+				{GuestPath: "/fish", FS: subFS("fish")},
+				{GuestPath: "/mammals", FS: subFS("mammals")},
+			},
+			path:    "mammals/primates/ape.txt",
+			content: "king kong",
+		},
+		{
+			name: "non-overlapping mounts",
+			mounts: []Mount{
+				{GuestPath: "/fish", FS: subFS("fish")},
+				{GuestPath: "/mammals", FS: subFS("mammals")},
+			},
+			path: "bear.txt",
+		},
+		{
+			name: "overlapping mounts, deep first",
+			mounts: []Mount{
+				{GuestPath: "/animals/fish", FS: subFS("fish")},
+				{GuestPath: "/animals", FS: subFS("mammals")},
+			},
+			path:    "animals/fish/clownfish.txt",
+			content: "nemo",
+		},
+		{
+			name: "overlapping mounts, shallow first",
+			mounts: []Mount{
+				{GuestPath: "/animals", FS: subFS("mammals")},
+				{GuestPath: "/animals/fish", FS: subFS("fish")},
+			},
+			path:    "animals/fish/clownfish.txt",
+			content: "nemo",
+		},
+		{
+			name: "overlapping mounts, shallow first",
+			mounts: []Mount{
+				{GuestPath: "/animals", FS: subFS("mammals")},
+				{GuestPath: "/animals/fish", FS: subFS("fish")},
+			},
+			path:    "animals/whale.txt",
+			content: "moby dick",
+		},
+		{
+			name: "overlapping mounts, shallow first",
+			mounts: []Mount{
+				{GuestPath: "/animals", FS: subFS("mammals")},
+				{GuestPath: "/animals/fish", FS: subFS("fish")},
+			},
+			path: "animals/bear.txt",
+		},
+		{
+			name: "mount shadows a file of the same name",
+			mounts: []Mount{
+				{GuestPath: "/", FS: animalsFS},
+				{GuestPath: "/bear.txt", FS: subFS("fish")},
+			},
+			path:    "bear.txt/clownfish.txt",
+			content: "nemo",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name+" - "+tc.path, func(t *testing.T) {
+			rootFS, err := NewRootFS(tc.mounts)
+			require.NoError(t, err)
+
+			content, err := fs.ReadFile(rootFS, tc.path)
+			if tc.content != "" {
+				require.NoError(t, err)
+				require.Equal(t, tc.content, string(content))
+			} else {
+				require.ErrorIs(t, err, fs.ErrNotExist)
+			}
+		})
+	}
+}
+
+func TestRootFS_TestFS(t *testing.T) {
+	rootFS, err := NewRootFS([]Mount{
+		{GuestPath: "/", FS: subFS("mammals")},
+		{GuestPath: "/animals/fish", FS: subFS("fish")},
+		{GuestPath: "/primates", FS: animalsFS},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, fstest.TestFS(rootFS,
+		"whale.txt",
+		"animals/fish/clownfish.txt",
+		"primates/bear.txt",
+		"primates/mammals/primates/ape.txt",
+	))
+}
+
+func TestRootFS_ReadDir(t *testing.T) {
+	rootFS, err := NewRootFS([]Mount{
+		{GuestPath: "/", FS: animalsFS},
+		{GuestPath: "/fish", FS: subFS("mammals")},
+		{GuestPath: "/var/log", FS: memfs.New()},
+	})
+	require.NoError(t, err)
+
+	requireNames := func(t *testing.T, dir string, expected ...string) {
+		entries, err := fs.ReadDir(rootFS, dir)
+		require.NoError(t, err)
+		var names []string
+		for _, e := range entries {
+			require.True(t, e.IsDir() || e.Type().IsRegular())
+			names = append(names, e.Name())
+		}
+		require.Equal(t, expected, names)
+	}
+
+	// The "fish" mount point shadows the directory of the same name, and
+	// "var" leads to the "/var/log" mount point.
+	requireNames(t, ".", "bear.txt", "fish", "mammals", "var")
+	requireNames(t, "fish", "primates", "whale.txt")
+	requireNames(t, "var", "log")
+	requireNames(t, "var/log")
+
+	info, err := fs.Stat(rootFS, "var")
+	require.NoError(t, err)
+	require.Equal(t, "var", info.Name())
+	require.Equal(t, fs.ModeDir|0o555, info.Mode())
+
+	t.Run("pagination", func(t *testing.T) {
+		f, err := rootFS.Open(".")
+		require.NoError(t, err)
+		defer f.Close()
+
+		d := f.(fs.ReadDirFile)
+		entries, err := d.ReadDir(3)
+		require.NoError(t, err)
+		require.Equal(t, 3, len(entries))
+		entries, err = d.ReadDir(3)
+		require.NoError(t, err)
+		require.Equal(t, 1, len(entries))
+		require.Equal(t, "var", entries[0].Name())
+		_, err = d.ReadDir(3)
+		require.Equal(t, io.EOF, err)
+	})
+}
+
+func TestRootFS_writes(t *testing.T) {
+	root, tmp := memfs.New(), memfs.New()
+	require.NoError(t, root.WriteFile("bear.txt", []byte("pooh"), 0o600))
+	require.NoError(t, tmp.WriteFile("whale.txt", []byte("moby dick"), 0o600))
+
+	rootFS, err := NewRootFS([]Mount{
+		{GuestPath: "/", FS: root, ReadOnly: true},
+		{GuestPath: "/tmp", FS: tmp},
+		{GuestPath: "/var/log", FS: memfs.New()},
+	})
+	require.NoError(t, err)
+
+	t.Run("writable mount", func(t *testing.T) {
+		f, err := OpenFile(rootFS, "tmp/ape.txt", os.O_CREATE|os.O_WRONLY, 0o600)
+		require.NoError(t, err)
+		_, err = f.(io.Writer).Write([]byte("king kong"))
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		b, err := tmp.ReadFile("ape.txt")
+		require.NoError(t, err)
+		require.Equal(t, "king kong", string(b))
+
+		require.NoError(t, Mkdir(rootFS, "tmp/primates", 0o700))
+		require.NoError(t, Rename(rootFS, "tmp/ape.txt", "tmp/primates/ape.txt"))
+		require.NoError(t, Link(rootFS, "tmp/primates/ape.txt", "tmp/kong.txt"))
+		require.NoError(t, Symlink(rootFS, "primates/ape.txt", "tmp/link"))
+		target, err := Readlink(rootFS, "tmp/link")
+		require.NoError(t, err)
+		require.Equal(t, "primates/ape.txt", target)
+		require.NoError(t, Chtimes(rootFS, "tmp/kong.txt", time.Unix(0, 0), time.Unix(0, 0)))
+		require.NoError(t, Remove(rootFS, "tmp/link"))
+		require.NoError(t, Remove(rootFS, "tmp/kong.txt"))
+		require.NoError(t, Remove(rootFS, "tmp/primates/ape.txt"))
+		require.NoError(t, Remove(rootFS, "tmp/primates"))
+	})
+
+	tests := []struct {
+		name        string
+		fn          func() error
+		expectedErr syscall.Errno
+	}{
+		{
+			name: "create in read-only mount",
+			fn: func() error {
+				_, err := OpenFile(rootFS, "panda.txt", os.O_CREATE|os.O_WRONLY, 0o600)
+				return err
+			},
+			expectedErr: syscall.EROFS,
+		},
+		{
+			name: "create in synthesized directory",
+			fn: func() error {
+				_, err := OpenFile(rootFS, "var/panda.txt", os.O_CREATE|os.O_WRONLY, 0o600)
+				return err
+			},
+			expectedErr: syscall.EROFS,
+		},
+		{
+			name: "write mount point",
+			fn: func() error {
+				_, err := OpenFile(rootFS, "tmp", os.O_RDWR, 0)
+				return err
+			},
+			expectedErr: syscall.EISDIR,
+		},
+		{
+			name:        "mkdir read-only mount",
+			fn:          func() error { return Mkdir(rootFS, "animals", 0o700) },
+			expectedErr: syscall.EROFS,
+		},
+		{
+			name:        "mkdir mount point",
+			fn:          func() error { return Mkdir(rootFS, "tmp", 0o700) },
+			expectedErr: syscall.EEXIST,
+		},
+		{
+			name:        "mkdir synthesized directory",
+			fn:          func() error { return Mkdir(rootFS, "var", 0o700) },
+			expectedErr: syscall.EEXIST,
+		},
+		{
+			name:        "remove read-only mount",
+			fn:          func() error { return Remove(rootFS, "bear.txt") },
+			expectedErr: syscall.EROFS,
+		},
+		{
+			name:        "remove mount point",
+			fn:          func() error { return Remove(rootFS, "tmp") },
+			expectedErr: syscall.EBUSY,
+		},
+		{
+			name:        "remove directory leading to mount point",
+			fn:          func() error { return Remove(rootFS, "var") },
+			expectedErr: syscall.EBUSY,
+		},
+		{
+			name:        "rename mount point",
+			fn:          func() error { return Rename(rootFS, "tmp", "temp") },
+			expectedErr: syscall.EBUSY,
+		},
+		{
+			name:        "rename across mounts",
+			fn:          func() error { return Rename(rootFS, "tmp/whale.txt", "var/log/whale.txt") },
+			expectedErr: syscall.EXDEV,
+		},
+		{
+			name:        "link across mounts",
+			fn:          func() error { return Link(rootFS, "bear.txt", "tmp/bear.txt") },
+			expectedErr: syscall.EXDEV,
+		},
+		{
+			name:        "link onto mount point",
+			fn:          func() error { return Link(rootFS, "tmp/whale.txt", "var/log") },
+			expectedErr: syscall.EEXIST,
+		},
+		{
+			name:        "symlink escapes mount",
+			fn:          func() error { return Symlink(rootFS, "../bear.txt", "tmp/link") },
+			expectedErr: syscall.EPERM,
+		},
+		{
+			name:        "symlink absolute",
+			fn:          func() error { return Symlink(rootFS, "/bear.txt", "tmp/link") },
+			expectedErr: syscall.EPERM,
+		},
+		{
+			name:        "chtimes synthesized directory",
+			fn:          func() error { return Chtimes(rootFS, "var", time.Time{}, time.Time{}) },
+			expectedErr: syscall.EROFS,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			require.ErrorIs(t, tc.fn(), tc.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,168 @@
+// Package sysfs includes fs.FS implementations which compose the file system
+// of a module: a writable host directory, a read-only view of another file
+// system, and a mount table which joins them at guest paths.
+//
+// Writes are supported by methods named like and with the same signature as
+// functions in package os, such as OpenFile and Mkdir. These are optional, so
+// any fs.FS can be used, read-only.
+package sysfs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"syscall"
+	"time"
+)
+
+// openFileFS is implemented by a fs.FS which supports os.OpenFile.
+type openFileFS interface {
+	OpenFile(name string, flag int, perm fs.FileMode) (fs.File, error)
+}
+
+// mkdirFS is implemented by a fs.FS which supports os.Mkdir.
+type mkdirFS interface {
+	Mkdir(name string, perm fs.FileMode) error
+}
+
+// removeFS is implemented by a fs.FS which supports os.Remove.
+type removeFS interface {
+	Remove(name string) error
+}
+
+// renameFS is implemented by a fs.FS which supports os.Rename.
+type renameFS interface {
+	Rename(oldpath, newpath string) error
+}
+
+// symlinkFS is implemented by a fs.FS which supports os.Symlink.
+type symlinkFS interface {
+	Symlink(oldname, newname string) error
+}
+
+// readlinkFS is implemented by a fs.FS which supports os.Readlink.
+type readlinkFS interface {
+	Readlink(name string) (string, error)
+}
+
+// linkFS is implemented by a fs.FS which supports os.Link.
+type linkFS interface {
+	Link(oldname, newname string) error
+}
+
+// lstatFS is implemented by a fs.FS which supports os.Lstat.
+type lstatFS interface {
+	Lstat(name string) (fs.FileInfo, error)
+}
+
+// chtimesFS is implemented by a fs.FS which supports os.Chtimes.
+type chtimesFS interface {
+	Chtimes(name string, atime, mtime time.Time) error
+}
+
+// OpenFile is like os.OpenFile, except name is relative to fsys.
+//
+// When fsys doesn't implement OpenFile(name string, flag int, perm
+// fs.FileMode) (fs.File, error), it is read-only: flags which don't write are
+// emulated and those that need write access fail with syscall.EROFS.
+func OpenFile(fsys fs.FS, name string, flag int, perm fs.FileMode) (fs.File, error) {
+	if flag == os.O_RDONLY {
+		return fsys.Open(name)
+	} else if ofs, ok := fsys.(openFileFS); ok {
+		return ofs.OpenFile(name, flag, perm)
+	}
+
+	f, err := fsys.Open(name)
+	switch {
+	case err != nil:
+		if flag&os.O_CREATE != 0 && errors.Is(err, fs.ErrNotExist) {
+			err = &fs.PathError{Op: "open", Path: name, Err: syscall.EROFS}
+		}
+		return nil, err
+	case flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		err = syscall.EEXIST
+	case flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_TRUNC) != 0:
+		err = syscall.EROFS
+	default:
+		return f, nil
+	}
+	_ = f.Close()
+	return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+}
+
+// Lstat is like os.Lstat, except name is relative to fsys. This falls back to
+// fs.Stat when fsys doesn't implement Lstat(name string) (fs.FileInfo, error).
+func Lstat(fsys fs.FS, name string) (fs.FileInfo, error) {
+	if lfs, ok := fsys.(lstatFS); ok {
+		return lfs.Lstat(name)
+	}
+	return fs.Stat(fsys, name)
+}
+
+// Mkdir is like os.Mkdir, except name is relative to fsys. This returns
+// syscall.EROFS unless fsys implements Mkdir(name string, perm fs.FileMode)
+// error.
+func Mkdir(fsys fs.FS, name string, perm fs.FileMode) error {
+	if mfs, ok := fsys.(mkdirFS); ok {
+		return mfs.Mkdir(name, perm)
+	}
+	return &fs.PathError{Op: "mkdir", Path: name, Err: syscall.EROFS}
+}
+
+// Remove is like os.Remove, except name is relative to fsys. This returns
+// syscall.EROFS unless fsys implements Remove(name string) error.
+func Remove(fsys fs.FS, name string) error {
+	if rfs, ok := fsys.(removeFS); ok {
+		return rfs.Remove(name)
+	}
+	return &fs.PathError{Op: "remove", Path: name, Err: syscall.EROFS}
+}
+
+// Rename is like os.Rename, except the names are relative to fsys. This
+// returns syscall.EROFS unless fsys implements Rename(oldpath, newpath
+// string) error.
+func Rename(fsys fs.FS, oldName, newName string) error {
+	if rfs, ok := fsys.(renameFS); ok {
+		return rfs.Rename(oldName, newName)
+	}
+	return &os.LinkError{Op: "rename", Old: oldName, New: newName, Err: syscall.EROFS}
+}
+
+// Symlink is like os.Symlink, except name is relative to fsys. This returns
+// syscall.EROFS unless fsys implements Symlink(oldname, newname string)
+// error.
+func Symlink(fsys fs.FS, target, name string) error {
+	if sfs, ok := fsys.(symlinkFS); ok {
+		return sfs.Symlink(target, name)
+	}
+	return &os.LinkError{Op: "symlink", Old: target, New: name, Err: syscall.EROFS}
+}
+
+// Readlink is like os.Readlink, except name is relative to fsys. This
+// returns syscall.ENOSYS unless fsys implements Readlink(name string)
+// (string, error).
+func Readlink(fsys fs.FS, name string) (string, error) {
+	if rfs, ok := fsys.(readlinkFS); ok {
+		return rfs.Readlink(name)
+	}
+	return "", &fs.PathError{Op: "readlink", Path: name, Err: syscall.ENOSYS}
+}
+
+// Link is like os.Link, except the names are relative to fsys. This returns
+// syscall.EROFS unless fsys implements Link(oldname, newname string) error.
+func Link(fsys fs.FS, oldName, newName string) error {
+	if lfs, ok := fsys.(linkFS); ok {
+		return lfs.Link(oldName, newName)
+	}
+	return &os.LinkError{Op: "link", Old: oldName, New: newName, Err: syscall.EROFS}
+}
+
+// Chtimes is like os.Chtimes, except name is relative to fsys. This returns
+// syscall.EROFS unless fsys implements Chtimes(name string, atime, mtime
+// time.Time) error.
+func Chtimes(fsys fs.FS, name string, atime, mtime time.Time) error {
+	if cfs, ok := fsys.(chtimesFS); ok {
+		return cfs.Chtimes(name, atime, mtime)
+	}
+	return &fs.PathError{Op: "chtimes", Path: name, Err: syscall.EROFS}
+}
@@ -1,5 +1,5 @@
 // Package wasi_snapshot_preview1 is an internal helper to remove package
-// cycles re-using errno
+// cycles re-using errno and the names of other constants.
 package wasi_snapshot_preview1
 
 import (
@@ -0,0 +1,168 @@
+package wasi_snapshot_preview1
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// OflagsString returns the names of the set oflags, e.g. "CREAT|TRUNC", or
+// an empty string if none are set.
+func OflagsString(oflags uint64) string {
+	return flagsString(oflags, oflagNames[:])
+}
+
+var oflagNames = [...]string{"CREAT", "DIRECTORY", "EXCL", "TRUNC"}
+
+// FdflagsString returns the names of the set fdflags, e.g. "APPEND|NONBLOCK",
+// or an empty string if none are set.
+func FdflagsString(fdflags uint64) string {
+	return flagsString(fdflags, fdflagNames[:])
+}
+
+var fdflagNames = [...]string{"APPEND", "DSYNC", "NONBLOCK", "RSYNC", "SYNC"}
+
+// LookupflagsString returns the names of the set lookupflags, e.g.
+// "SYMLINK_FOLLOW", or an empty string if none are set.
+func LookupflagsString(lookupflags uint64) string {
+	return flagsString(lookupflags, lookupflagNames[:])
+}
+
+var lookupflagNames = [...]string{"SYMLINK_FOLLOW"}
+
+// FstflagsString returns the names of the set fstflags, e.g. "ATIM|MTIM_NOW",
+// or an empty string if none are set.
+func FstflagsString(fstflags uint64) string {
+	return flagsString(fstflags, fstflagNames[:])
+}
+
+var fstflagNames = [...]string{"ATIM", "ATIM_NOW", "MTIM", "MTIM_NOW"}
+
+// SdflagsString returns the names of the set sdflags, e.g. "RD|WR", or an
+// empty string if none are set.
+func SdflagsString(sdflags uint64) string {
+	return flagsString(sdflags, sdflagNames[:])
+}
+
+var sdflagNames = [...]string{"RD", "WR"}
+
+// RiflagsString returns the names of the set riflags, e.g. "RECV_PEEK", or an
+// empty string if none are set.
+func RiflagsString(riflags uint64) string {
+	return flagsString(riflags, riflagNames[:])
+}
+
+var riflagNames = [...]string{"RECV_PEEK", "RECV_WAITALL"}
+
+// RightsString returns the names of the set rights, e.g. "FD_READ|FD_WRITE",
+// or an empty string if none are set.
+func RightsString(rights uint64) string {
+	return flagsString(rights, rightNames[:])
+}
+
+var rightNames = [...]string{
+	"FD_DATASYNC",
+	"FD_READ",
+	"FD_SEEK",
+	"FD_FDSTAT_SET_FLAGS",
+	"FD_SYNC",
+	"FD_TELL",
+	"FD_WRITE",
+	"FD_ADVISE",
+	"FD_ALLOCATE",
+	"PATH_CREATE_DIRECTORY",
+	"PATH_CREATE_FILE",
+	"PATH_LINK_SOURCE",
+	"PATH_LINK_TARGET",
+	"PATH_OPEN",
+	"FD_READDIR",
+	"PATH_READLINK",
+	"PATH_RENAME_SOURCE",
+	"PATH_RENAME_TARGET",
+	"PATH_FILESTAT_GET",
+	"PATH_FILESTAT_SET_SIZE",
+	"PATH_FILESTAT_SET_TIMES",
+	"FD_FILESTAT_GET",
+	"FD_FILESTAT_SET_SIZE",
+	"FD_FILESTAT_SET_TIMES",
+	"PATH_SYMLINK",
+	"PATH_REMOVE_DIRECTORY",
+	"PATH_UNLINK_FILE",
+	"POLL_FD_READWRITE",
+	"SOCK_SHUTDOWN",
+	"SOCK_ACCEPT",
+}
+
+// flagsString joins the names of the set bits, where names[i] is the name
+// of bit i. Unknown bits are appended in hex, e.g. "CREAT|0x100".
+func flagsString(flags uint64, names []string) string {
+	var ret strings.Builder
+	for i, name := range names {
+		bit := uint64(1) << i
+		if flags&bit == 0 {
+			continue
+		}
+		if ret.Len() > 0 {
+			ret.WriteByte('|')
+		}
+		ret.WriteString(name)
+		flags &^= bit
+	}
+	if flags != 0 {
+		if ret.Len() > 0 {
+			ret.WriteByte('|')
+		}
+		ret.WriteString("0x")
+		ret.WriteString(strconv.FormatUint(flags, 16))
+	}
+	return ret.String()
+}
+
+// ClockIDName returns the name of the clock ID, e.g. "MONOTONIC".
+func ClockIDName(id uint32) string {
+	if int(id) < len(clockIDNames) {
+		return clockIDNames[id]
+	}
+	return fmt.Sprintf("clockid(%d)", id)
+}
+
+var clockIDNames = [...]string{"REALTIME", "MONOTONIC", "PROCESS_CPUTIME_ID", "THREAD_CPUTIME_ID"}
+
+// WhenceName returns the name of the whence value of fd_seek, e.g. "SET".
+func WhenceName(whence uint32) string {
+	if int(whence) < len(whenceNames) {
+		return whenceNames[whence]
+	}
+	return fmt.Sprintf("whence(%d)", whence)
+}
+
+var whenceNames = [...]string{"SET", "CUR", "END"}
+
+// AdviceName returns the name of the advice of fd_advise, e.g. "SEQUENTIAL".
+func AdviceName(advice uint32) string {
+	if int(advice) < len(adviceNames) {
+		return adviceNames[advice]
+	}
+	return fmt.Sprintf("advice(%d)", advice)
+}
+
+var adviceNames = [...]string{"NORMAL", "SEQUENTIAL", "RANDOM", "WILLNEED", "DONTNEED", "NOREUSE"}
+
+// FiletypeName returns the name of the filetype, e.g. "REGULAR_FILE".
+func FiletypeName(filetype uint8) string {
+	if int(filetype) < len(filetypeNames) {
+		return filetypeNames[filetype]
+	}
+	return fmt.Sprintf("filetype(%d)", filetype)
+}
+
+var filetypeNames = [...]string{
+	"UNKNOWN",
+	"BLOCK_DEVICE",
+	"CHARACTER_DEVICE",
+	"DIRECTORY",
+	"REGULAR_FILE",
+	"SOCKET_DGRAM",
+	"SOCKET_STREAM",
+	"SYMBOLIC_LINK",
+}
@@ -0,0 +1,31 @@
+package wasi_snapshot_preview1
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestFlagsString(t *testing.T) {
+	require.Equal(t, "", OflagsString(0))
+	require.Equal(t, "CREAT|TRUNC", OflagsString(9))
+	require.Equal(t, "0x10", OflagsString(0x10))
+	require.Equal(t, "DIRECTORY|0x30", OflagsString(0x32))
+	require.Equal(t, "APPEND|SYNC", FdflagsString(17))
+	require.Equal(t, "SYMLINK_FOLLOW", LookupflagsString(1))
+	require.Equal(t, "ATIM_NOW|MTIM", FstflagsString(6))
+	require.Equal(t, "RD|WR", SdflagsString(3))
+	require.Equal(t, "RECV_WAITALL", RiflagsString(2))
+	require.Equal(t, "FD_DATASYNC|SOCK_ACCEPT", RightsString(1|1<<29))
+}
+
+func TestNames(t *testing.T) {
+	require.Equal(t, "THREAD_CPUTIME_ID", ClockIDName(3))
+	require.Equal(t, "clockid(4)", ClockIDName(4))
+	require.Equal(t, "END", WhenceName(2))
+	require.Equal(t, "whence(3)", WhenceName(3))
+	require.Equal(t, "NOREUSE", AdviceName(5))
+	require.Equal(t, "advice(6)", AdviceName(6))
+	require.Equal(t, "SYMBOLIC_LINK", FiletypeName(7))
+	require.Equal(t, "filetype(8)", FiletypeName(8))
+}
@@ -0,0 +1,138 @@
+package wasm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// canonicalABIAlign is the alignment passed to canonical_abi_realloc and
+// canonical_abi_free. This is the largest alignment of a core value type, so
+// it is safe for any data the host writes.
+const canonicalABIAlign = 8
+
+// Allocator implements the same method as documented on api.Module.
+func (m *CallContext) Allocator() api.Allocator {
+	if malloc, free := m.allocatorFuncs("malloc", []ValueType{ValueTypeI32}, "free", []ValueType{ValueTypeI32}); malloc != nil {
+		return &mallocAllocator{malloc: malloc, free: free}
+	}
+	if realloc, free := m.allocatorFuncs(
+		"canonical_abi_realloc", []ValueType{ValueTypeI32, ValueTypeI32, ValueTypeI32, ValueTypeI32},
+		"canonical_abi_free", []ValueType{ValueTypeI32, ValueTypeI32, ValueTypeI32},
+	); realloc != nil {
+		return &canonicalABIAllocator{realloc: realloc, free: free}
+	}
+	if allocate, deallocate := m.allocatorFuncs("allocate", []ValueType{ValueTypeI32}, "deallocate", []ValueType{ValueTypeI32, ValueTypeI32}); allocate != nil {
+		return &rustAllocator{allocate: allocate, deallocate: deallocate}
+	}
+	return nil
+}
+
+// allocatorFuncs returns the allocate and free functions exported with the
+// given names, or nil if either is missing or has an unexpected signature.
+//
+// The allocate function must return a single i32 and the free function must
+// return nothing.
+func (m *CallContext) allocatorFuncs(allocName string, allocParams []ValueType, freeName string, freeParams []ValueType) (alloc, free api.Function) {
+	if !m.hasExportedFunction(allocName, allocParams, []ValueType{ValueTypeI32}) ||
+		!m.hasExportedFunction(freeName, freeParams, nil) {
+		return nil, nil
+	}
+	if alloc = m.ExportedFunction(allocName); alloc == nil {
+		return nil, nil
+	}
+	if free = m.ExportedFunction(freeName); free == nil {
+		return nil, nil
+	}
+	return
+}
+
+// hasExportedFunction returns true if a function is exported with the given
+// name and signature.
+func (m *CallContext) hasExportedFunction(name string, params, results []ValueType) bool {
+	exp, err := m.module.getExport(name, ExternTypeFunc)
+	if err != nil {
+		return false
+	}
+	t := m.module.Functions[exp.Index].Type
+	return valueTypesEqual(t.Params, params) && valueTypesEqual(t.Results, results)
+}
+
+func valueTypesEqual(a, b []ValueType) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// mallocAllocator implements api.Allocator with the C "malloc" and "free"
+// functions.
+type mallocAllocator struct {
+	malloc, free api.Function
+}
+
+// Allocate implements api.Allocator Allocate
+func (a *mallocAllocator) Allocate(ctx context.Context, size uint32) (uint32, error) {
+	return callAllocate(ctx, a.malloc, size, uint64(size))
+}
+
+// Free implements api.Allocator Free
+func (a *mallocAllocator) Free(ctx context.Context, offset, _ uint32) error {
+	_, err := a.free.Call(ctx, uint64(offset))
+	return err
+}
+
+// canonicalABIAllocator implements api.Allocator with the functions generated
+// by wit-bindgen.
+type canonicalABIAllocator struct {
+	realloc, free api.Function
+}
+
+// Allocate implements api.Allocator Allocate
+func (a *canonicalABIAllocator) Allocate(ctx context.Context, size uint32) (uint32, error) {
+	// A zero original pointer and size means allocate.
+	return callAllocate(ctx, a.realloc, size, 0, 0, canonicalABIAlign, uint64(size))
+}
+
+// Free implements api.Allocator Free
+func (a *canonicalABIAllocator) Free(ctx context.Context, offset, size uint32) error {
+	_, err := a.free.Call(ctx, uint64(offset), uint64(size), canonicalABIAlign)
+	return err
+}
+
+// rustAllocator implements api.Allocator with "allocate" and "deallocate"
+// functions commonly exported from Rust guests.
+type rustAllocator struct {
+	allocate, deallocate api.Function
+}
+
+// Allocate implements api.Allocator Allocate
+func (a *rustAllocator) Allocate(ctx context.Context, size uint32) (uint32, error) {
+	return callAllocate(ctx, a.allocate, size, uint64(size))
+}
+
+// Free implements api.Allocator Free
+func (a *rustAllocator) Free(ctx context.Context, offset, size uint32) error {
+	_, err := a.deallocate.Call(ctx, uint64(offset), uint64(size))
+	return err
+}
+
+// callAllocate calls the allocation function, returning an error if the guest
+// couldn't allocate a non-empty region, signaled by a zero offset.
+func callAllocate(ctx context.Context, fn api.Function, size uint32, params ...uint64) (uint32, error) {
+	results, err := fn.Call(ctx, params...)
+	if err != nil {
+		return 0, err
+	}
+	offset := uint32(results[0])
+	if offset == 0 && size != 0 {
+		return 0, fmt.Errorf("%s: out of memory", fn.Definition().DebugName())
+	}
+	return offset, nil
+}
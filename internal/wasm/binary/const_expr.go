@@ -105,6 +105,9 @@ func decodeConstantExpression(r *bytes.Reader, enabledFeatures api.CoreFeatures)
 }
 
 func encodeConstantExpression(expr *wasm.ConstantExpression) (ret []byte) {
+	if expr.Opcode == wasm.OpcodeVecV128Const { // decoded without its prefix.
+		ret = append(ret, wasm.OpcodeVecPrefix)
+	}
 	ret = append(ret, expr.Opcode)
 	ret = append(ret, expr.Data...)
 	ret = append(ret, wasm.OpcodeEnd)
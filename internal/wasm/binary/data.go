@@ -79,9 +79,13 @@ func decodeDataSegment(r *bytes.Reader, enabledFeatures api.CoreFeatures) (*wasm
 }
 
 func encodeDataSegment(d *wasm.DataSegment) (ret []byte) {
-	// Currently multiple memories are not supported.
-	ret = append(ret, leb128.EncodeInt32(0)...)
-	ret = append(ret, encodeConstantExpression(d.OffsetExpression)...)
+	if d.IsPassive() {
+		ret = append(ret, byte(dataSegmentPrefixPassive))
+	} else {
+		// Currently multiple memories are not supported.
+		ret = append(ret, byte(dataSegmentPrefixActive))
+		ret = append(ret, encodeConstantExpression(d.OffsetExpression)...)
+	}
 	ret = append(ret, leb128.EncodeUint32(uint32(len(d.Init)))...)
 	ret = append(ret, d.Init...)
 	return
@@ -136,3 +136,37 @@ func Test_decodeDataSegment(t *testing.T) {
 		})
 	}
 }
+
+func Test_encodeDataSegment(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    *wasm.DataSegment
+		expected []byte
+	}{
+		{
+			name: "active",
+			input: &wasm.DataSegment{
+				OffsetExpression: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0x1}},
+				Init:             []byte{0xf, 0xf},
+			},
+			expected: []byte{0x0, wasm.OpcodeI32Const, 0x1, wasm.OpcodeEnd, 0x2, 0xf, 0xf},
+		},
+		{
+			name:     "passive",
+			input:    &wasm.DataSegment{Init: []byte{0xf, 0xf}},
+			expected: []byte{0x1, 0x2, 0xf, 0xf},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			bin := encodeDataSegment(tc.input)
+			require.Equal(t, tc.expected, bin)
+
+			decoded, err := decodeDataSegment(bytes.NewReader(bin), api.CoreFeatureBulkMemoryOperations)
+			require.NoError(t, err)
+			require.Equal(t, tc.input, decoded)
+		})
+	}
+}
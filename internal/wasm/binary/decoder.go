@@ -93,6 +93,11 @@ func DecodeModule(
 							abbrev = c.Data
 						case ".debug_ranges":
 							ranges = c.Data
+						case "sourceMappingURL":
+							// Like DWARF, this is only for stack traces, so a malformed URL is ignored.
+							if url, _, urlErr := decodeUTF8(bytes.NewReader(c.Data), "source mapping URL"); urlErr == nil {
+								m.SourceMappingURL = url
+							}
 						}
 					}
 				} else {
@@ -114,7 +119,7 @@ func DecodeModule(
 		case wasm.SectionIDTable:
 			m.TableSection, err = decodeTableSection(r, enabledFeatures)
 		case wasm.SectionIDMemory:
-			m.MemorySection, err = decodeMemorySection(r, memorySizer, memoryLimitPages)
+			m.MemorySection, err = decodeMemorySection(r, memorySizer, memoryLimitPages, enabledFeatures)
 		case wasm.SectionIDGlobal:
 			if m.GlobalSection, err = decodeGlobalSection(r, enabledFeatures); err != nil {
 				return nil, err // avoid re-wrapping the error.
@@ -129,6 +134,7 @@ func DecodeModule(
 		case wasm.SectionIDElement:
 			m.ElementSection, err = decodeElementSection(r, enabledFeatures)
 		case wasm.SectionIDCode:
+			m.CodeSectionOffset = uint64(len(binary) - sectionContentStart)
 			m.CodeSection, err = decodeCodeSection(r)
 		case wasm.SectionIDData:
 			m.DataSection, err = decodeDataSection(r, enabledFeatures)
@@ -169,6 +169,21 @@ func TestDecodeModule(t *testing.T) {
 		require.Nil(t, m.DWARFLines)
 	})
 
+	t.Run("source mapping URL", func(t *testing.T) {
+		input := append(append(Magic, version...),
+			wasm.SectionIDCustom, 0x17, // 23 bytes in this section
+			0x10, 's', 'o', 'u', 'r', 'c', 'e', 'M', 'a', 'p', 'p', 'i', 'n', 'g', 'U', 'R', 'L',
+			0x05, 'a', '.', 'm', 'a', 'p')
+		m, e := DecodeModule(input, api.CoreFeaturesV2, wasm.MemoryLimitPages, false, true, false)
+		require.NoError(t, e)
+		require.Equal(t, "a.map", m.SourceMappingURL)
+
+		// Like DWARF, it's only decoded when enabled.
+		m, e = DecodeModule(input, api.CoreFeaturesV2, wasm.MemoryLimitPages, false, false, false)
+		require.NoError(t, e)
+		require.Equal(t, "", m.SourceMappingURL)
+	})
+
 	t.Run("data count section disabled", func(t *testing.T) {
 		input := append(append(Magic, version...),
 			wasm.SectionIDDataCount, 1, 0)
@@ -177,6 +192,26 @@ func TestDecodeModule(t *testing.T) {
 	})
 }
 
+// TestDecodeModule_Coredump ensures a coredump decodes to a memory of the same
+// page size and size as dumped, even when the page size is custom.
+func TestDecodeModule_Coredump(t *testing.T) {
+	mem := wasm.NewMemoryInstance(&wasm.Memory{Min: 3, Cap: 3, Max: 3, IsPageSizeEncoded: true, PageSizeLog2: 0})
+	copy(mem.Buffer, []byte{1, 2, 3})
+	m := &wasm.ModuleInstance{Name: "test", Memory: mem}
+	f := &wasm.FunctionInstance{Module: m, Type: &wasm.FunctionType{}}
+
+	coredump := wasm.Coredump("test", []wasm.CoredumpFrame{{Function: f}})
+
+	decoded, err := DecodeModule(coredump, api.CoreFeaturesV2|api.CoreFeatureCustomPageSizes,
+		wasm.MemoryLimitPages, false, false, false)
+	require.NoError(t, err)
+	require.Equal(t, uint32(3), decoded.MemorySection.Min)
+	require.True(t, decoded.MemorySection.IsPageSizeEncoded)
+	require.Equal(t, uint32(0), decoded.MemorySection.PageSizeLog2)
+	require.Equal(t, 1, len(decoded.DataSection))
+	require.Equal(t, []byte{1, 2, 3}, decoded.DataSection[0].Init)
+}
+
 func TestDecodeModule_Errors(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -282,19 +282,73 @@ func decodeElementSegment(r *bytes.Reader, enabledFeatures api.CoreFeatures) (*w
 	}
 }
 
-// encodeCode returns the wasm.ElementSegment encoded in WebAssembly 1.0 (20191205) Binary Format.
+// encodeElement returns the wasm.ElementSegment encoded in WebAssembly 2.0 Binary Format, using the WebAssembly 1.0
+// (20191205) encoding when possible.
 //
-// https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#element-section%E2%91%A0
+// See https://www.w3.org/TR/2022/WD-wasm-core-2-20220419/binary/modules.html#element-section
 func encodeElement(e *wasm.ElementSegment) (ret []byte) {
+	// Function indexes are encoded as such, unless there are null references, which need const expressions.
+	asIndexes := e.Type == wasm.RefTypeFuncref
+	for _, idx := range e.Init {
+		if idx == nil {
+			asIndexes = false
+			break
+		}
+	}
+
+	var prefix uint32
+	switch e.Mode {
+	case wasm.ElementModeActive:
+		switch {
+		case asIndexes && e.TableIndex == 0:
+			prefix = elementSegmentPrefixLegacy
+		case asIndexes:
+			prefix = elementSegmentPrefixActiveFuncrefValueVectorWithTableIndex
+		case e.Type == wasm.RefTypeFuncref && e.TableIndex == 0:
+			prefix = elementSegmentPrefixActiveFuncrefConstExprVector
+		default:
+			prefix = elementSegmentPrefixActiveConstExprVector
+		}
+	case wasm.ElementModePassive:
+		if prefix = elementSegmentPrefixPassiveFuncrefValueVector; !asIndexes {
+			prefix = elementSegmentPrefixPassiveConstExprVector
+		}
+	case wasm.ElementModeDeclarative:
+		if prefix = elementSegmentPrefixDeclarativeFuncrefValueVector; !asIndexes {
+			prefix = elementSegmentPrefixDeclarativeConstExprVector
+		}
+	}
+	ret = leb128.EncodeUint32(prefix)
+
+	if prefix == elementSegmentPrefixActiveFuncrefValueVectorWithTableIndex || prefix == elementSegmentPrefixActiveConstExprVector {
+		ret = append(ret, leb128.EncodeUint32(e.TableIndex)...)
+	}
 	if e.Mode == wasm.ElementModeActive {
-		ret = append(ret, leb128.EncodeInt32(int32(e.TableIndex))...)
 		ret = append(ret, encodeConstantExpression(e.OffsetExpr)...)
-		ret = append(ret, leb128.EncodeUint32(uint32(len(e.Init)))...)
-		for _, idx := range e.Init {
-			ret = append(ret, leb128.EncodeInt32(int32(*idx))...)
+	}
+	switch prefix {
+	case elementSegmentPrefixLegacy, elementSegmentPrefixActiveFuncrefConstExprVector:
+		// The element kind or type is implicitly funcref.
+	case elementSegmentPrefixActiveFuncrefValueVectorWithTableIndex,
+		elementSegmentPrefixPassiveFuncrefValueVector,
+		elementSegmentPrefixDeclarativeFuncrefValueVector:
+		ret = append(ret, 0x0) // element kind funcref
+	default:
+		ret = append(ret, e.Type)
+	}
+
+	ret = append(ret, leb128.EncodeUint32(uint32(len(e.Init)))...)
+	for _, idx := range e.Init {
+		switch {
+		case asIndexes:
+			ret = append(ret, leb128.EncodeUint32(*idx)...)
+		case idx == nil:
+			ret = append(ret, wasm.OpcodeRefNull, e.Type, wasm.OpcodeEnd)
+		default:
+			ret = append(ret, wasm.OpcodeRefFunc)
+			ret = append(ret, leb128.EncodeUint32(*idx)...)
+			ret = append(ret, wasm.OpcodeEnd)
 		}
-	} else {
-		panic("TODO: support encoding for non-active elements in bulk-memory-operations proposal")
 	}
 	return
 }
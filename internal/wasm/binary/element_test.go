@@ -441,3 +441,93 @@ func TestDecodeElementSegment_errors(t *testing.T) {
 	_, err := decodeElementSegment(bytes.NewReader([]byte{1}), api.CoreFeatureMultiValue)
 	require.EqualError(t, err, `non-zero prefix for element segment is invalid as feature "bulk-memory-operations" is disabled`)
 }
+
+func TestEncodeElement(t *testing.T) {
+	offset := &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{1}}
+	tests := []struct {
+		name     string
+		input    *wasm.ElementSegment
+		expected []byte
+	}{
+		{
+			name: "active",
+			input: &wasm.ElementSegment{
+				OffsetExpr: offset, Init: []*wasm.Index{uint32Ptr(1), uint32Ptr(2)},
+				Mode: wasm.ElementModeActive, Type: wasm.RefTypeFuncref,
+			},
+			expected: []byte{0, wasm.OpcodeI32Const, 1, wasm.OpcodeEnd, 2, 1, 2},
+		},
+		{
+			name: "active table index",
+			input: &wasm.ElementSegment{
+				OffsetExpr: offset, Init: []*wasm.Index{uint32Ptr(1)}, TableIndex: 1,
+				Mode: wasm.ElementModeActive, Type: wasm.RefTypeFuncref,
+			},
+			expected: []byte{2, 1, wasm.OpcodeI32Const, 1, wasm.OpcodeEnd, 0, 1, 1},
+		},
+		{
+			name: "active null",
+			input: &wasm.ElementSegment{
+				OffsetExpr: offset, Init: []*wasm.Index{uint32Ptr(1), nil},
+				Mode: wasm.ElementModeActive, Type: wasm.RefTypeFuncref,
+			},
+			expected: []byte{
+				4, wasm.OpcodeI32Const, 1, wasm.OpcodeEnd, 2,
+				wasm.OpcodeRefFunc, 1, wasm.OpcodeEnd,
+				wasm.OpcodeRefNull, wasm.RefTypeFuncref, wasm.OpcodeEnd,
+			},
+		},
+		{
+			name: "active externref",
+			input: &wasm.ElementSegment{
+				OffsetExpr: offset, Init: []*wasm.Index{nil}, TableIndex: 1,
+				Mode: wasm.ElementModeActive, Type: wasm.RefTypeExternref,
+			},
+			expected: []byte{
+				6, 1, wasm.OpcodeI32Const, 1, wasm.OpcodeEnd, wasm.RefTypeExternref, 1,
+				wasm.OpcodeRefNull, wasm.RefTypeExternref, wasm.OpcodeEnd,
+			},
+		},
+		{
+			name: "passive",
+			input: &wasm.ElementSegment{
+				Init: []*wasm.Index{uint32Ptr(1)}, Mode: wasm.ElementModePassive, Type: wasm.RefTypeFuncref,
+			},
+			expected: []byte{1, 0, 1, 1},
+		},
+		{
+			name: "passive null",
+			input: &wasm.ElementSegment{
+				Init: []*wasm.Index{nil}, Mode: wasm.ElementModePassive, Type: wasm.RefTypeFuncref,
+			},
+			expected: []byte{5, wasm.RefTypeFuncref, 1, wasm.OpcodeRefNull, wasm.RefTypeFuncref, wasm.OpcodeEnd},
+		},
+		{
+			name: "declarative",
+			input: &wasm.ElementSegment{
+				Init: []*wasm.Index{uint32Ptr(1)}, Mode: wasm.ElementModeDeclarative, Type: wasm.RefTypeFuncref,
+			},
+			expected: []byte{3, 0, 1, 1},
+		},
+		{
+			name: "declarative null",
+			input: &wasm.ElementSegment{
+				Init: []*wasm.Index{nil}, Mode: wasm.ElementModeDeclarative, Type: wasm.RefTypeFuncref,
+			},
+			expected: []byte{7, wasm.RefTypeFuncref, 1, wasm.OpcodeRefNull, wasm.RefTypeFuncref, wasm.OpcodeEnd},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			bin := encodeElement(tc.input)
+			require.Equal(t, tc.expected, bin)
+
+			// The encoding decodes back to the same segment.
+			decoded, err := decodeElementSegment(bytes.NewReader(bin), api.CoreFeaturesV2)
+			require.NoError(t, err)
+			require.Equal(t, tc.input, decoded)
+		})
+	}
+}
@@ -38,6 +38,9 @@ func EncodeModule(m *wasm.Module) (bytes []byte) {
 	if m.SectionElementCount(wasm.SectionIDElement) > 0 {
 		bytes = append(bytes, encodeElementSection(m.ElementSection)...)
 	}
+	if m.SectionElementCount(wasm.SectionIDDataCount) > 0 {
+		bytes = append(bytes, encodeDataCountSection(*m.DataCountSection)...)
+	}
 	if m.SectionElementCount(wasm.SectionIDCode) > 0 {
 		bytes = append(bytes, encodeCodeSection(m.CodeSection)...)
 	}
@@ -196,6 +196,30 @@ func TestModule_Encode(t *testing.T) {
 				wasm.ExternTypeGlobal, 0x00, // global[0]
 			),
 		},
+		{
+			name: "data count and v128 global",
+			input: &wasm.Module{
+				GlobalSection: []*wasm.Global{
+					{
+						Type: &wasm.GlobalType{ValType: wasm.ValueTypeV128},
+						Init: &wasm.ConstantExpression{Opcode: wasm.OpcodeVecV128Const, Data: make([]byte, 16)},
+					},
+				},
+				DataSection:      []*wasm.DataSegment{{Init: []byte{1}}},
+				DataCountSection: uint32Ptr(1),
+			},
+			expected: append(append(append(append(Magic, version...),
+				wasm.SectionIDGlobal, 0x16, // 22 bytes in this section
+				0x01, wasm.ValueTypeV128, 0x00, // 1 global v128 immutable
+				wasm.OpcodeVecPrefix, wasm.OpcodeVecV128Const), // v128.const
+				make([]byte, 16)...),
+				wasm.OpcodeEnd,
+				wasm.SectionIDDataCount, 0x01, 0x01, // 1 byte in this section, 1 data segment
+				wasm.SectionIDData, 0x04, // 4 bytes in this section
+				0x01,             // 1 data segment
+				0x01, 0x01, 0x01, // passive, 1 byte
+			),
+		},
 	}
 
 	for _, tt := range tests {
@@ -108,7 +108,7 @@ func TestEncodeImport(t *testing.T) {
 				Type:      wasm.ExternTypeTable,
 				Module:    "my",
 				Name:      "table",
-				DescTable: &wasm.Table{Min: 1, Max: ptrOfUint32(2)},
+				DescTable: &wasm.Table{Min: 1, Max: ptrOfUint32(2), Type: wasm.RefTypeFuncref},
 			},
 			expected: []byte{
 				0x02, 'm', 'y',
@@ -118,6 +118,22 @@ func TestEncodeImport(t *testing.T) {
 				0x1, 0x1, 0x2, // Limit with max.
 			},
 		},
+		{
+			name: "table externref",
+			input: &wasm.Import{
+				Type:      wasm.ExternTypeTable,
+				Module:    "my",
+				Name:      "table",
+				DescTable: &wasm.Table{Min: 1, Type: wasm.RefTypeExternref},
+			},
+			expected: []byte{
+				0x02, 'm', 'y',
+				0x05, 't', 'a', 'b', 'l', 'e',
+				wasm.ExternTypeTable,
+				wasm.RefTypeExternref,
+				0x0, 0x1, // Limit without max.
+			},
+		},
 		{
 			name: "memory",
 			input: &wasm.Import{
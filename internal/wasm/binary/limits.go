@@ -16,7 +16,11 @@ func decodeLimitsType(r *bytes.Reader) (min uint32, max *uint32, err error) {
 		err = fmt.Errorf("read leading byte: %v", err)
 		return
 	}
+	return decodeLimits(r, flag)
+}
 
+// decodeLimits is decodeLimitsType after its leading flag byte, which is read separately by decodeMemory.
+func decodeLimits(r *bytes.Reader, flag byte) (min uint32, max *uint32, err error) {
 	switch flag {
 	case 0x00:
 		min, _, err = leb128.DecodeUint32(r)
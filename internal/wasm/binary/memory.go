@@ -2,10 +2,18 @@ package binary
 
 import (
 	"bytes"
+	"fmt"
 
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/leb128"
 	"github.com/tetratelabs/wazero/internal/wasm"
 )
 
+// memoryLimitsFlagPageSize is set in the leading byte of the limits of a memory whose page size follows them.
+//
+// See https://github.com/WebAssembly/custom-page-sizes/blob/main/proposals/custom-page-sizes/Overview.md
+const memoryLimitsFlagPageSize = 0x08
+
 // decodeMemory returns the api.Memory decoded with the WebAssembly 1.0 (20191205) Binary Format.
 //
 // See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#binary-memory
@@ -13,14 +21,38 @@ func decodeMemory(
 	r *bytes.Reader,
 	memorySizer func(minPages uint32, maxPages *uint32) (min, capacity, max uint32),
 	memoryLimitPages uint32,
+	enabledFeatures api.CoreFeatures,
 ) (*wasm.Memory, error) {
-	min, maxP, err := decodeLimitsType(r)
+	flag, err := r.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("read leading byte: %v", err)
+	}
+
+	isPageSizeEncoded := flag&memoryLimitsFlagPageSize != 0
+	if isPageSizeEncoded {
+		if err = enabledFeatures.RequireEnabled(api.CoreFeatureCustomPageSizes); err != nil {
+			return nil, fmt.Errorf("custom page size is invalid as %w", err)
+		}
+		flag &^= memoryLimitsFlagPageSize
+	}
+
+	min, maxP, err := decodeLimits(r, flag)
 	if err != nil {
 		return nil, err
 	}
 
-	min, capacity, max := memorySizer(min, maxP)
-	mem := &wasm.Memory{Min: min, Cap: capacity, Max: max, IsMaxEncoded: maxP != nil}
+	mem := &wasm.Memory{IsMaxEncoded: maxP != nil, IsPageSizeEncoded: isPageSizeEncoded}
+	if isPageSizeEncoded {
+		if mem.PageSizeLog2, _, err = leb128.DecodeUint32(r); err != nil {
+			return nil, fmt.Errorf("read page size: %v", err)
+		}
+	}
+
+	mem.Min, mem.Cap, mem.Max = memorySizer(min, maxP)
+	if maxP == nil && isPageSizeEncoded {
+		// The sizer defaults the max to the limit in pages of MemoryPageSize.
+		mem.Max = mem.LimitPages(memoryLimitPages)
+	}
 
 	return mem, mem.Validate(memoryLimitPages)
 }
@@ -33,5 +65,10 @@ func encodeMemory(i *wasm.Memory) []byte {
 	if !i.IsMaxEncoded {
 		maxPtr = nil
 	}
-	return encodeLimitsType(i.Min, maxPtr)
+	ret := encodeLimitsType(i.Min, maxPtr)
+	if i.IsPageSizeEncoded {
+		ret[0] |= memoryLimitsFlagPageSize
+		ret = append(ret, leb128.EncodeUint32(i.PageSizeLog2)...)
+	}
+	return ret
 }
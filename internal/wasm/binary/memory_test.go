@@ -3,8 +3,10 @@ package binary
 import (
 	"bytes"
 	"fmt"
+	"math"
 	"testing"
 
+	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/internal/testing/require"
 	"github.com/tetratelabs/wazero/internal/wasm"
 )
@@ -122,6 +124,21 @@ func TestMemoryType(t *testing.T) {
 			input:    &wasm.Memory{Min: max, Cap: max, Max: max, IsMaxEncoded: true},
 			expected: []byte{0x1, 0x80, 0x80, 0x4, 0x80, 0x80, 0x4},
 		},
+		{
+			name:     "1 byte pages",
+			input:    &wasm.Memory{Min: 3, Cap: 3, Max: 5, IsMaxEncoded: true, IsPageSizeEncoded: true},
+			expected: []byte{0x9, 3, 5, 0},
+		},
+		{
+			name:     "1 byte pages default max",
+			input:    &wasm.Memory{Min: 3, Cap: 3, Max: math.MaxUint32, IsPageSizeEncoded: true},
+			expected: []byte{0x8, 3, 0},
+		},
+		{
+			name:     "64KiB pages encoded",
+			input:    &wasm.Memory{Min: 1, Cap: 1, Max: max, IsPageSizeEncoded: true, PageSizeLog2: 16},
+			expected: []byte{0x8, 1, 16},
+		},
 	}
 
 	for _, tt := range tests {
@@ -133,7 +150,8 @@ func TestMemoryType(t *testing.T) {
 		})
 
 		t.Run(fmt.Sprintf("decode %s", tc.name), func(t *testing.T) {
-			binary, err := decodeMemory(bytes.NewReader(b), newMemorySizer(max, false), max)
+			binary, err := decodeMemory(bytes.NewReader(b), newMemorySizer(max, false), max,
+				api.CoreFeaturesV2|api.CoreFeatureCustomPageSizes)
 			require.NoError(t, err)
 			require.Equal(t, binary, tc.input)
 		})
@@ -163,14 +181,30 @@ func TestDecodeMemoryType_Errors(t *testing.T) {
 			input:       []byte{0x1, 0, 0xff, 0xff, 0xff, 0xff, 0xf},
 			expectedErr: "max 4294967295 pages (3 Ti) over limit of 65536 pages (4 Gi)",
 		},
+		{
+			name:        "1 byte pages max < min",
+			input:       []byte{0x9, 2, 1, 0},
+			expectedErr: "min 2 pages (2 bytes) > max 1 pages (1 bytes)",
+		},
+		{
+			name:        "invalid page size",
+			input:       []byte{0x8, 0, 2},
+			expectedErr: "invalid custom page size: 2^2 bytes",
+		},
 	}
 
 	for _, tt := range tests {
 		tc := tt
 
 		t.Run(tc.name, func(t *testing.T) {
-			_, err := decodeMemory(bytes.NewReader(tc.input), newMemorySizer(max, false), max)
+			_, err := decodeMemory(bytes.NewReader(tc.input), newMemorySizer(max, false), max,
+				api.CoreFeaturesV2|api.CoreFeatureCustomPageSizes)
 			require.EqualError(t, err, tc.expectedErr)
 		})
 	}
+
+	t.Run("custom page size disabled", func(t *testing.T) {
+		_, err := decodeMemory(bytes.NewReader([]byte{0x8, 0, 0}), newMemorySizer(max, false), max, api.CoreFeaturesV2)
+		require.EqualError(t, err, `custom page size is invalid as feature "custom-page-sizes" is disabled`)
+	})
 }
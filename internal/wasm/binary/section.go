@@ -86,6 +86,7 @@ func decodeMemorySection(
 	r *bytes.Reader,
 	memorySizer func(minPages uint32, maxPages *uint32) (min, capacity, max uint32),
 	memoryLimitPages uint32,
+	enabledFeatures api.CoreFeatures,
 ) (*wasm.Memory, error) {
 	vs, _, err := leb128.DecodeUint32(r)
 	if err != nil {
@@ -98,7 +99,7 @@ func decodeMemorySection(
 		return nil, nil
 	}
 
-	return decodeMemory(r, memorySizer, memoryLimitPages)
+	return decodeMemory(r, memorySizer, memoryLimitPages, enabledFeatures)
 }
 
 func decodeGlobalSection(r *bytes.Reader, enabledFeatures api.CoreFeatures) ([]*wasm.Global, error) {
@@ -330,6 +331,14 @@ func encodeElementSection(elements []*wasm.ElementSegment) []byte {
 	return encodeSection(wasm.SectionIDElement, contents)
 }
 
+// encodeDataCountSection encodes a wasm.SectionIDDataCount for the count of data segments, which is required by
+// instructions such as memory.init.
+//
+// See https://www.w3.org/TR/2022/WD-wasm-core-2-20220419/binary/modules.html#data-count-section
+func encodeDataCountSection(count uint32) []byte {
+	return encodeSection(wasm.SectionIDDataCount, leb128.EncodeUint32(count))
+}
+
 // encodeDataSection encodes a wasm.SectionIDData for the data in WebAssembly 1.0 (20191205)
 // Binary Format.
 //
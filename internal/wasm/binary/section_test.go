@@ -103,7 +103,7 @@ func TestMemorySection(t *testing.T) {
 		tc := tt
 
 		t.Run(tc.name, func(t *testing.T) {
-			memories, err := decodeMemorySection(bytes.NewReader(tc.input), newMemorySizer(max, false), max)
+			memories, err := decodeMemorySection(bytes.NewReader(tc.input), newMemorySizer(max, false), max, api.CoreFeaturesV2)
 			require.NoError(t, err)
 			require.Equal(t, tc.expected, memories)
 		})
@@ -133,7 +133,7 @@ func TestMemorySection_Errors(t *testing.T) {
 		tc := tt
 
 		t.Run(tc.name, func(t *testing.T) {
-			_, err := decodeMemorySection(bytes.NewReader(tc.input), newMemorySizer(max, false), max)
+			_, err := decodeMemorySection(bytes.NewReader(tc.input), newMemorySizer(max, false), max, api.CoreFeaturesV2)
 			require.EqualError(t, err, tc.expectedErr)
 		})
 	}
@@ -2,10 +2,12 @@ package wasm
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync/atomic"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
 	internalsys "github.com/tetratelabs/wazero/internal/sys"
 	"github.com/tetratelabs/wazero/sys"
 )
@@ -14,8 +16,8 @@ import (
 var _ api.Module = &CallContext{}
 
 func NewCallContext(ns *Namespace, instance *ModuleInstance, sys *internalsys.Context) *CallContext {
-	zero := uint64(0)
-	return &CallContext{memory: instance.Memory, module: instance, ns: ns, Sys: sys, closed: &zero}
+	zero, one := uint64(0), int32(1)
+	return &CallContext{memory: instance.Memory, module: instance, ns: ns, Sys: sys, closed: &zero, sysRefs: &one}
 }
 
 // CallContext is a function call context bound to a module. This is important as one module's functions can call
@@ -45,6 +47,9 @@ type CallContext struct {
 	//	  parameter) because we haven't thought through capabilities based
 	//	  security implications.
 	Sys *internalsys.Context
+	// sysRefs counts the modules sharing Sys, i.e. the module which created it and its clones. Sys is closed with
+	// the last of them. This is nil if Sys isn't owned, e.g. in tests.
+	sysRefs *int32
 
 	// closed is the pointer used both to guard moduleEngine.CloseWithExitCode and to store the exit code.
 	//
@@ -56,6 +61,46 @@ type CallContext struct {
 
 	// CodeCloser is non-nil when the code should be closed after this module.
 	CodeCloser api.Closer
+
+	// hostFunctionPanicPolicy and hostFunctionPanicHandler are copied from the Store. See CallGoFunc.
+	hostFunctionPanicPolicy  HostFunctionPanicPolicy
+	hostFunctionPanicHandler HostFunctionPanicHandler
+
+	// cpuTime is non-nil when Store.CPUTimeAccounting is enabled. See CPUTime.
+	cpuTime *cpuTime
+
+	// events is Store.EventListener, set after the module is instantiated without error.
+	events experimental.EventListener
+
+	// differential is the same module instantiated in the differential store,
+	// when enabled, and divergenceHandler is called when a call to a function
+	// diverges. See Store.EnableDifferential.
+	differential      *CallContext
+	divergenceHandler DivergenceHandler
+
+	// clone is true when this is the result of Clone, which isn't in a Namespace, and shares Sys with its module.
+	clone bool
+}
+
+// retainSys notes that a clone shares Sys, or returns false if it was already closed.
+func (m *CallContext) retainSys() bool {
+	if m.sysRefs == nil {
+		return true
+	}
+	for {
+		refs := atomic.LoadInt32(m.sysRefs)
+		if refs == 0 {
+			return false
+		}
+		if atomic.CompareAndSwapInt32(m.sysRefs, refs, refs+1) {
+			return true
+		}
+	}
+}
+
+// releaseSys returns true if this was the last module sharing Sys, so it should be closed.
+func (m *CallContext) releaseSys() bool {
+	return m.sysRefs == nil || atomic.AddInt32(m.sysRefs, -1) == 0
 }
 
 // FailIfClosed returns a sys.ExitError if CloseWithExitCode was called.
@@ -74,7 +119,11 @@ func (m *CallContext) Name() string {
 // WithMemory allows overriding memory without re-allocation when the result would be the same.
 func (m *CallContext) WithMemory(memory *MemoryInstance) *CallContext {
 	if memory != nil && memory != m.memory { // only re-allocate if it will change the effective memory
-		return &CallContext{module: m.module, memory: memory, Sys: m.Sys, closed: m.closed}
+		return &CallContext{
+			module: m.module, memory: memory, Sys: m.Sys, sysRefs: m.sysRefs, closed: m.closed,
+			hostFunctionPanicPolicy: m.hostFunctionPanicPolicy, hostFunctionPanicHandler: m.hostFunctionPanicHandler,
+			cpuTime: m.cpuTime, clone: m.clone,
+		}
 	}
 	return m
 }
@@ -95,7 +144,9 @@ func (m *CallContext) CloseWithExitCode(ctx context.Context, exitCode uint32) er
 	if !closed {
 		return nil
 	}
-	_ = m.ns.deleteModule(m.Name())
+	if !m.clone {
+		_ = m.ns.deleteModule(m.Name())
+	}
 	if m.CodeCloser == nil {
 		return err
 	}
@@ -105,6 +156,44 @@ func (m *CallContext) CloseWithExitCode(ctx context.Context, exitCode uint32) er
 	return err
 }
 
+// CloseModuleOnCanceledOrTimeout takes a context `ctx`, which might be a Cancel or Timeout context, and spawns the
+// goroutine to check the context is canceled or deadline exceeded. If it reaches one of the conditions, it closes the
+// module with the appropriate exit code, which interrupts the execution of code compiled with ensureTermination.
+//
+// Callers of this function must invoke the returned context.CancelFunc to release the spawned goroutine.
+func (m *CallContext) CloseModuleOnCanceledOrTimeout(ctx context.Context) context.CancelFunc {
+	// Creating an empty channel in this case is a bit more efficient than
+	// creating a context.Context and canceling it with the same effect. We
+	// really just need to be notified when to stop listening to the users
+	// context. Closing the channel will unblock the select in the goroutine
+	// causing it to return and stop listening to ctx.Done().
+	cancelChan := make(chan struct{})
+	go m.closeModuleOnCanceledOrTimeout(ctx, cancelChan)
+	return func() { close(cancelChan) }
+}
+
+// closeModuleOnCanceledOrTimeout is extracted from CloseModuleOnCanceledOrTimeout for testing.
+func (m *CallContext) closeModuleOnCanceledOrTimeout(ctx context.Context, cancelChan <-chan struct{}) {
+	select {
+	case <-ctx.Done():
+		m.CloseWithCtxErr(ctx)
+	case <-cancelChan:
+	}
+}
+
+// CloseWithCtxErr closes the module with an exit code based on the type of
+// error reported by the context.
+//
+// If the context's error is unknown or nil, the module does not close.
+func (m *CallContext) CloseWithCtxErr(ctx context.Context) {
+	switch {
+	case errors.Is(ctx.Err(), context.Canceled):
+		_ = m.CloseWithExitCode(ctx, sys.ExitCodeContextCanceled)
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		_ = m.CloseWithExitCode(ctx, sys.ExitCodeDeadlineExceeded)
+	}
+}
+
 // close marks this CallContext as closed and releases underlying system resources.
 //
 // Note: The caller is responsible for removing the module from the Namespace.
@@ -114,15 +203,43 @@ func (m *CallContext) close(ctx context.Context, exitCode uint32) (c bool, err e
 		return false, nil
 	}
 	c = true
-	if sysCtx := m.Sys; sysCtx != nil { // nil if from HostModuleBuilder
+	if sysCtx := m.Sys; sysCtx != nil && m.releaseSys() { // nil if from HostModuleBuilder
 		err = sysCtx.FS().Close(ctx)
 	}
+	// Only the memory defined by this module is released, as an imported one may still be cloned by its module.
+	if src := m.module.source; src != nil && src.definesMemory() {
+		m.module.Memory.closeSnapshot()
+		// A clone holds a reference to its mapped memory until closed. See ModuleInstance.retainMapping.
+		if m.clone && atomic.LoadInt32(&m.module.mappedCalls) > 0 {
+			m.module.releaseMapping()
+		}
+	}
+	if d := m.differential; d != nil {
+		_ = d.CloseWithExitCode(ctx, exitCode)
+	}
+	if l := m.events; l != nil {
+		l.OnEvent(ctx, experimental.Event{Type: experimental.EventModuleClosed, ModuleName: m.Name(), Module: m, ExitCode: exitCode})
+	}
 	return
 }
 
 // Memory implements the same method as documented on api.Module.
 func (m *CallContext) Memory() api.Memory {
-	return m.module.Memory
+	if mem := m.module.Memory; mem != nil {
+		return mem
+	}
+	return nil // not a typed nil, so that callers can check it.
+}
+
+// MemoryStats implements the same method as documented on api.Module.
+func (m *CallContext) MemoryStats() api.MemoryStats {
+	mem := m.module.Memory
+	if mem == nil {
+		return api.MemoryStats{}
+	}
+	ret := mem.stats()
+	ret.DataSegmentSize = m.module.dataSegmentSize
+	return ret
 }
 
 // ExportedMemory implements the same method as documented on api.Module.
@@ -179,7 +296,24 @@ func (f *function) Definition() api.FunctionDefinition {
 
 // Call implements the same method as documented on api.Function.
 func (f *function) Call(ctx context.Context, params ...uint64) (ret []uint64, err error) {
-	return f.ce.Call(ctx, f.fi.Module.CallCtx, params)
+	mod := f.fi.Module
+	m := mod.CallCtx
+	if mod.retainMapping() {
+		defer mod.releaseMapping()
+	}
+	// Touch the memories the call can write, so that a later clone doesn't reuse a stale snapshot.
+	defer mod.touchMemories()
+	if m.differential != nil && ctx.Value(differentialKey{}) == nil {
+		return f.callDifferential(ctx, m, params)
+	}
+	return f.call(ctx, m, params)
+}
+
+func (f *function) call(ctx context.Context, m *CallContext, params []uint64) ([]uint64, error) {
+	if m.cpuTime != nil {
+		return f.callWithCPUTime(ctx, m, params)
+	}
+	return f.ce.Call(ctx, m, params)
 }
 
 // GlobalVal is an internal hack to get the lower 64 bits of a global.
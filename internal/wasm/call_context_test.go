@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"testing"
 
 	"github.com/tetratelabs/wazero/internal/sys"
@@ -121,7 +122,7 @@ func TestCallContext_Close(t *testing.T) {
 		t.Run(fmt.Sprintf("%s calls ns.CloseWithExitCode(module.name))", tc.name), func(t *testing.T) {
 			for _, ctx := range []context.Context{nil, testCtx} { // Ensure it doesn't crash on nil!
 				moduleName := t.Name()
-				m, err := s.Instantiate(ctx, ns, &Module{}, moduleName, nil)
+				m, err := s.Instantiate(testCtx, ns, &Module{}, moduleName, nil)
 				require.NoError(t, err)
 
 				// We use side effects to see if Close called ns.CloseWithExitCode (without repeating store_test.go).
@@ -146,7 +147,7 @@ func TestCallContext_Close(t *testing.T) {
 		sysCtx := sys.DefaultContext(testfs.FS{"foo": &testfs.File{}})
 		fsCtx := sysCtx.FS()
 
-		_, err := fsCtx.OpenFile("/foo")
+		_, err := fsCtx.OpenFile("/foo", os.O_RDONLY, 0)
 		require.NoError(t, err)
 
 		m, err := s.Instantiate(context.Background(), ns, &Module{}, t.Name(), sysCtx)
@@ -174,7 +175,7 @@ func TestCallContext_Close(t *testing.T) {
 		sysCtx := sys.DefaultContext(testFS)
 		fsCtx := sysCtx.FS()
 
-		_, err := fsCtx.OpenFile("/foo")
+		_, err := fsCtx.OpenFile("/foo", os.O_RDONLY, 0)
 		require.NoError(t, err)
 
 		m, err := s.Instantiate(context.Background(), ns, &Module{}, t.Name(), sysCtx)
@@ -186,6 +187,28 @@ func TestCallContext_Close(t *testing.T) {
 		_, ok := fsCtx.OpenedFile(3)
 		require.False(t, ok, "expected no opened files")
 	})
+
+	t.Run("closes Context with the last clone", func(t *testing.T) {
+		sysCtx := sys.DefaultContext(testfs.FS{"foo": &testfs.File{}})
+		fsCtx := sysCtx.FS()
+
+		_, err := fsCtx.OpenFile("/foo", os.O_RDONLY, 0)
+		require.NoError(t, err)
+
+		m, err := s.Instantiate(testCtx, ns, &Module{}, t.Name(), sysCtx)
+		require.NoError(t, err)
+		clone, err := m.Clone(testCtx)
+		require.NoError(t, err)
+
+		// Closing the module leaves the files open for the clone.
+		require.NoError(t, m.Close(testCtx))
+		_, ok := fsCtx.OpenedFile(3)
+		require.True(t, ok, "expected the file to be open")
+
+		require.NoError(t, clone.Close(testCtx))
+		_, ok = fsCtx.OpenedFile(3)
+		require.False(t, ok, "expected no opened files")
+	})
 }
 
 func TestCallContext_CallDynamic(t *testing.T) {
@@ -217,7 +240,7 @@ func TestCallContext_CallDynamic(t *testing.T) {
 		t.Run(fmt.Sprintf("%s calls ns.CloseWithExitCode(module.name))", tc.name), func(t *testing.T) {
 			for _, ctx := range []context.Context{nil, testCtx} { // Ensure it doesn't crash on nil!
 				moduleName := t.Name()
-				m, err := s.Instantiate(ctx, ns, &Module{}, moduleName, nil)
+				m, err := s.Instantiate(testCtx, ns, &Module{}, moduleName, nil)
 				require.NoError(t, err)
 
 				// We use side effects to see if Close called ns.CloseWithExitCode (without repeating store_test.go).
@@ -242,7 +265,7 @@ func TestCallContext_CallDynamic(t *testing.T) {
 		sysCtx := sys.DefaultContext(testfs.FS{"foo": &testfs.File{}})
 		fsCtx := sysCtx.FS()
 
-		_, err := fsCtx.OpenFile("/foo")
+		_, err := fsCtx.OpenFile("/foo", os.O_RDONLY, 0)
 		require.NoError(t, err)
 
 		m, err := s.Instantiate(context.Background(), ns, &Module{}, t.Name(), sysCtx)
@@ -270,7 +293,7 @@ func TestCallContext_CallDynamic(t *testing.T) {
 		sysCtx := sys.DefaultContext(testFS)
 		fsCtx := sysCtx.FS()
 
-		_, err := fsCtx.OpenFile("/foo")
+		_, err := fsCtx.OpenFile("/foo", os.O_RDONLY, 0)
 		require.NoError(t, err)
 
 		m, err := s.Instantiate(context.Background(), ns, &Module{}, t.Name(), sysCtx)
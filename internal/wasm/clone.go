@@ -0,0 +1,173 @@
+package wasm
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+)
+
+// Clone implements the same method as documented on api.Module.
+func (m *CallContext) Clone(ctx context.Context) (api.Module, error) {
+	if err := m.FailIfClosed(); err != nil {
+		return nil, err
+	}
+	if !m.retainSys() {
+		return nil, m.FailIfClosed()
+	}
+	c, err := m.module.clone()
+	if err != nil {
+		if m.releaseSys() {
+			_ = m.Sys.FS().Close(ctx)
+		}
+		return nil, err
+	}
+	s := c.store
+
+	callCtx := NewCallContext(nil, c, m.Sys)
+	callCtx.sysRefs = m.sysRefs
+	callCtx.clone = true
+	callCtx.hostFunctionPanicPolicy, callCtx.hostFunctionPanicHandler = m.hostFunctionPanicPolicy, m.hostFunctionPanicHandler
+	if m.cpuTime != nil {
+		callCtx.cpuTime = &cpuTime{}
+	}
+	c.CallCtx = callCtx
+
+	if c.Memory != m.module.Memory {
+		s.notifyGrown(ctx, callCtx, c.Memory)
+	}
+	if l := s.EventListener; l != nil {
+		callCtx.events = l
+		l.OnEvent(ctx, experimental.Event{Type: experimental.EventModuleInstantiated, ModuleName: c.Name, Module: callCtx})
+	}
+	return callCtx, nil
+}
+
+// retainMapping notes that a call to a function of this module is in progress, so that its mapped memory isn't
+// unmapped until releaseMapping. This returns false if the memory isn't mapped, or the module is closed.
+func (m *ModuleInstance) retainMapping() bool {
+	for {
+		calls := atomic.LoadInt32(&m.mappedCalls)
+		if calls == 0 {
+			return false
+		}
+		if atomic.CompareAndSwapInt32(&m.mappedCalls, calls, calls+1) {
+			return true
+		}
+	}
+}
+
+// releaseMapping ends a call started with retainMapping, or releases the reference of the module when it's closed.
+// The memory is unmapped when the last of them ends.
+func (m *ModuleInstance) releaseMapping() {
+	if atomic.AddInt32(&m.mappedCalls, -1) == 0 {
+		m.Memory.unmap()
+	}
+}
+
+// clone returns a copy of this module, which shares its imports and compiled functions, but has a copy of the globals,
+// tables and memory it defines. Copied references to its functions are updated to the functions of the copy.
+func (m *ModuleInstance) clone() (*ModuleInstance, error) {
+	src := m.source
+	c := &ModuleInstance{
+		Name:            m.Name,
+		Exports:         m.Exports,
+		TypeIDs:         m.TypeIDs,
+		DataInstances:   append([]DataInstance(nil), m.DataInstances...),
+		dataSegmentSize: m.dataSegmentSize,
+		source:          src,
+		store:           m.store,
+	}
+
+	importCount := src.ImportFuncCount()
+	importedFunctions := make([]*FunctionInstance, importCount)
+	for i := range importedFunctions {
+		importedFunctions[i] = &m.Functions[i]
+	}
+	functions := c.BuildFunctions(src, importedFunctions)
+
+	// The compiled functions of the source module are cached, so this doesn't compile them again.
+	var err error
+	if c.Engine, err = m.store.Engine.NewModuleEngine(c.Name, src, functions); err != nil {
+		return nil, err
+	}
+
+	var refs map[Reference]Reference
+	ref := func(r Reference) Reference {
+		if refs == nil {
+			refs = make(map[Reference]Reference, len(functions)-int(importCount))
+			for i := importCount; i < uint32(len(functions)); i++ {
+				refs[m.Engine.FunctionInstanceReference(i)] = c.Engine.FunctionInstanceReference(i)
+			}
+		}
+		if cr, ok := refs[r]; ok {
+			return cr
+		}
+		return r // null or imported
+	}
+
+	importedGlobals := src.ImportGlobalCount()
+	c.Globals = make([]*GlobalInstance, len(m.Globals))
+	for i, g := range m.Globals {
+		if uint32(i) < importedGlobals {
+			c.Globals[i] = g
+			continue
+		}
+		cg := *g
+		if g.Type.ValType == ValueTypeFuncref {
+			cg.Val = uint64(ref(Reference(g.Val)))
+		}
+		c.Globals[i] = &cg
+	}
+
+	importedTables := src.ImportTableCount()
+	c.Tables = make([]*TableInstance, len(m.Tables))
+	for i, t := range m.Tables {
+		if uint32(i) < importedTables {
+			c.Tables[i] = t
+			continue
+		}
+		t.mux.RLock()
+		ct := &TableInstance{References: make([]Reference, len(t.References)), Min: t.Min, Max: t.Max, Type: t.Type, limiter: t.limiter}
+		for j, r := range t.References {
+			if t.Type == RefTypeFuncref {
+				r = ref(r)
+			}
+			ct.References[j] = r
+		}
+		t.mux.RUnlock()
+		c.Tables[i] = ct
+	}
+
+	c.ElementInstances = make([]ElementInstance, len(m.ElementInstances))
+	for i, e := range m.ElementInstances {
+		c.ElementInstances[i] = e
+		if e.References == nil { // not passive or dropped
+			continue
+		}
+		c.ElementInstances[i].References = make([]Reference, len(e.References))
+		for j, r := range e.References {
+			if e.Type == RefTypeFuncref {
+				r = ref(r)
+			}
+			c.ElementInstances[i].References[j] = r
+		}
+	}
+
+	if c.Memory = m.Memory; c.Memory != nil && src.definesMemory() {
+		if c.Memory, err = m.Memory.clone(); err != nil {
+			return nil, err
+		}
+		if c.Memory.mapping != nil {
+			c.mappedCalls = 1 // released when the clone is closed.
+		}
+	}
+	for _, mem := range m.memories {
+		if mem == m.Memory {
+			mem = c.Memory
+		}
+		c.memories = append(c.memories, mem)
+	}
+	return c, nil
+}
@@ -0,0 +1,332 @@
+package wasm
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/leb128"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// CoredumpFrame is a frame of the call stack of a coredump.
+type CoredumpFrame struct {
+	// Function is the function executing in this frame.
+	Function *FunctionInstance
+
+	// Offset is the offset of the current instruction in the code section, or
+	// zero if unknown.
+	Offset uint64
+
+	// Locals are the values of the parameters and locals of Function, in the
+	// same encoding as the stack, or nil if unknown.
+	Locals []uint64
+}
+
+// CoredumpHandler returns the experimental.CoredumpHandler of the context, or
+// nil if there's none or the recovered value isn't a trap to dump.
+//
+// Note: A trap error was already dumped by the call which returned it.
+func CoredumpHandler(ctx context.Context, recovered interface{}) experimental.CoredumpHandler {
+	switch recovered.(type) {
+	case nil, *sys.ExitError, *sys.TrapError, *HostFunctionPanic:
+		return nil
+	}
+	h, _ := ctx.Value(experimental.CoredumpHandlerKey{}).(experimental.CoredumpHandler)
+	return h
+}
+
+// Coredump encodes a wasm coredump of the frames, ordered from the innermost,
+// in the format of https://github.com/WebAssembly/tool-conventions/blob/main/Coredump.md
+//
+// The process is named after the module called, and the memories and globals
+// of the modules of the frames are included, each once if shared.
+func Coredump(name string, frames []CoredumpFrame) []byte {
+	d := &coredump{
+		memoryIndices: map[*MemoryInstance]uint32{},
+		globalIndices: map[*GlobalInstance]uint32{},
+		moduleIndices: map[*ModuleInstance]uint32{},
+	}
+	for i := range frames {
+		d.addModule(frames[i].Function.Module)
+	}
+
+	var stack bytes.Buffer
+	stack.WriteByte(0)
+	writeName(&stack, "main")
+	writeUint32(&stack, uint32(len(frames)))
+	for i := range frames {
+		d.writeFrame(&stack, &frames[i])
+	}
+
+	var process bytes.Buffer
+	process.WriteByte(0)
+	writeName(&process, name)
+
+	var buf bytes.Buffer
+	buf.WriteString("\x00asm\x01\x00\x00\x00")
+	writeCustomSection(&buf, "core", process.Bytes())
+	writeCustomSection(&buf, "coremodules", d.vec(&d.modules))
+	writeCustomSection(&buf, "coreinstances", d.vec(&d.instances))
+	writeCustomSection(&buf, "corestack", stack.Bytes())
+	writeSection(&buf, SectionIDMemory, d.memorySection())
+	writeSection(&buf, SectionIDGlobal, d.globalSection())
+	writeSection(&buf, SectionIDData, d.dataSection())
+	return buf.Bytes()
+}
+
+// coredump accumulates the modules of the frames of a coredump, and their
+// memories and globals.
+type coredump struct {
+	modules, instances bytes.Buffer
+	moduleCount        uint32
+	moduleIndices      map[*ModuleInstance]uint32
+	memories           []*MemoryInstance
+	memoryIndices      map[*MemoryInstance]uint32
+	globals            []*GlobalInstance
+	globalIndices      map[*GlobalInstance]uint32
+}
+
+// vec prefixes the modules or instances with their count.
+func (d *coredump) vec(entries *bytes.Buffer) []byte {
+	return append(leb128.EncodeUint32(d.moduleCount), entries.Bytes()...)
+}
+
+func (d *coredump) addModule(m *ModuleInstance) {
+	if _, ok := d.moduleIndices[m]; ok {
+		return
+	}
+	// As a module is only instantiated once per name, each instance has its
+	// own module.
+	d.moduleIndices[m] = d.moduleCount
+	d.moduleCount++
+
+	var memories []uint32
+	if mem := m.Memory; mem != nil {
+		idx, ok := d.memoryIndices[mem]
+		if !ok {
+			idx = uint32(len(d.memories))
+			d.memoryIndices[mem] = idx
+			d.memories = append(d.memories, mem)
+		}
+		memories = append(memories, idx)
+	}
+
+	globals := make([]uint32, 0, len(m.Globals))
+	for _, g := range m.Globals {
+		idx, ok := d.globalIndices[g]
+		if !ok {
+			idx = uint32(len(d.globals))
+			d.globalIndices[g] = idx
+			d.globals = append(d.globals, g)
+		}
+		globals = append(globals, idx)
+	}
+
+	d.modules.WriteByte(0)
+	writeName(&d.modules, m.Name)
+
+	d.instances.WriteByte(0)
+	writeUint32(&d.instances, d.moduleIndices[m])
+	writeUint32(&d.instances, uint32(len(memories)))
+	for _, idx := range memories {
+		writeUint32(&d.instances, idx)
+	}
+	writeUint32(&d.instances, uint32(len(globals)))
+	for _, idx := range globals {
+		writeUint32(&d.instances, idx)
+	}
+}
+
+func (d *coredump) writeFrame(buf *bytes.Buffer, frame *CoredumpFrame) {
+	f := frame.Function
+	var code *Code
+	if src := f.Module.source; src != nil {
+		if i := f.Idx - src.ImportFuncCount(); int(i) < len(src.CodeSection) {
+			code = src.CodeSection[i]
+		}
+	}
+
+	var codeOffset uint64
+	if code != nil && frame.Offset != 0 && frame.Offset >= code.BodyOffsetInCodeSection {
+		codeOffset = frame.Offset - code.BodyOffsetInCodeSection
+	}
+
+	buf.WriteByte(0)
+	writeUint32(buf, d.moduleIndices[f.Module])
+	writeUint32(buf, f.Idx)
+	writeUint32(buf, uint32(codeOffset))
+
+	types := f.Type.Params
+	if code != nil && len(code.LocalTypes) > 0 {
+		types = append(append([]ValueType(nil), types...), code.LocalTypes...)
+	}
+	writeUint32(buf, uint32(len(types)))
+	locals := frame.Locals
+	for _, t := range types {
+		n := 1
+		if t == ValueTypeV128 {
+			n = 2
+		}
+		if len(locals) < n {
+			locals = nil
+			buf.WriteByte(0x01) // missing
+			continue
+		}
+		writeValue(buf, t, locals[0])
+		locals = locals[n:]
+	}
+
+	// The operand stack isn't dumped, as it's not in a portable form.
+	writeUint32(buf, 0)
+}
+
+// writeValue writes a value of a local in the coredump encoding, which can
+// only represent numbers, so otherwise writes it as missing.
+func writeValue(buf *bytes.Buffer, t ValueType, v uint64) {
+	switch t {
+	case ValueTypeI32:
+		buf.WriteByte(t)
+		buf.Write(leb128.EncodeInt32(int32(v)))
+	case ValueTypeI64:
+		buf.WriteByte(t)
+		buf.Write(leb128.EncodeInt64(int64(v)))
+	case ValueTypeF32:
+		buf.WriteByte(t)
+		_ = binary.Write(buf, binary.LittleEndian, uint32(v))
+	case ValueTypeF64:
+		buf.WriteByte(t)
+		_ = binary.Write(buf, binary.LittleEndian, v)
+	default:
+		buf.WriteByte(0x01) // missing
+	}
+}
+
+// memoryLimitsFlagPageSize is the flag of the limits of a memory whose page
+// size follows them, as defined in the binary package.
+const memoryLimitsFlagPageSize = 0x08
+
+func (d *coredump) memorySection() []byte {
+	var buf bytes.Buffer
+	writeUint32(&buf, uint32(len(d.memories)))
+	for _, mem := range d.memories {
+		// There's no max, and a custom page size is kept, so the size is exact.
+		if mem.isPageSizeEncoded {
+			buf.WriteByte(memoryLimitsFlagPageSize)
+			writeUint32(&buf, mem.PageSize())
+			writeUint32(&buf, mem.pageSizeLog2)
+		} else {
+			buf.WriteByte(0)
+			writeUint32(&buf, mem.PageSize())
+		}
+	}
+	return buf.Bytes()
+}
+
+func (d *coredump) globalSection() []byte {
+	var buf bytes.Buffer
+	writeUint32(&buf, uint32(len(d.globals)))
+	for _, g := range d.globals {
+		buf.WriteByte(g.Type.ValType)
+		if g.Type.Mutable {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+		switch t := g.Type.ValType; t {
+		case ValueTypeI32:
+			buf.WriteByte(OpcodeI32Const)
+			buf.Write(leb128.EncodeInt32(int32(g.Val)))
+		case ValueTypeI64:
+			buf.WriteByte(OpcodeI64Const)
+			buf.Write(leb128.EncodeInt64(int64(g.Val)))
+		case ValueTypeF32:
+			buf.WriteByte(OpcodeF32Const)
+			_ = binary.Write(&buf, binary.LittleEndian, uint32(g.Val))
+		case ValueTypeF64:
+			buf.WriteByte(OpcodeF64Const)
+			_ = binary.Write(&buf, binary.LittleEndian, g.Val)
+		case ValueTypeV128:
+			buf.WriteByte(OpcodeVecPrefix)
+			buf.WriteByte(OpcodeVecV128Const)
+			_ = binary.Write(&buf, binary.LittleEndian, [2]uint64{g.Val, g.ValHi})
+		default: // references are addresses in this process, so dumped as null.
+			buf.WriteByte(OpcodeRefNull)
+			buf.WriteByte(t)
+		}
+		buf.WriteByte(OpcodeEnd)
+	}
+	return buf.Bytes()
+}
+
+// dataSection returns the data of the memories as active segments of each
+// page which isn't zero.
+func (d *coredump) dataSection() []byte {
+	type segment struct {
+		memIdx, offset uint32
+		data           []byte
+	}
+	var segments []segment
+	for i, mem := range d.memories {
+		mem.mux.RLock()
+		for offset := 0; offset < len(mem.Buffer); offset += int(MemoryPageSize) {
+			page := mem.Buffer[offset:]
+			if len(page) > int(MemoryPageSize) {
+				page = page[:MemoryPageSize]
+			}
+			if !isZero(page) {
+				segments = append(segments, segment{uint32(i), uint32(offset), append([]byte(nil), page...)})
+			}
+		}
+		mem.mux.RUnlock()
+	}
+
+	var buf bytes.Buffer
+	writeUint32(&buf, uint32(len(segments)))
+	for _, s := range segments {
+		if s.memIdx == 0 {
+			buf.WriteByte(0)
+		} else {
+			buf.WriteByte(2)
+			writeUint32(&buf, s.memIdx)
+		}
+		buf.WriteByte(OpcodeI32Const)
+		buf.Write(leb128.EncodeInt32(int32(s.offset)))
+		buf.WriteByte(OpcodeEnd)
+		writeUint32(&buf, uint32(len(s.data)))
+		buf.Write(s.data)
+	}
+	return buf.Bytes()
+}
+
+func isZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func writeCustomSection(buf *bytes.Buffer, name string, contents []byte) {
+	var section bytes.Buffer
+	writeName(&section, name)
+	section.Write(contents)
+	writeSection(buf, SectionIDCustom, section.Bytes())
+}
+
+func writeSection(buf *bytes.Buffer, id SectionID, contents []byte) {
+	buf.WriteByte(id)
+	writeUint32(buf, uint32(len(contents)))
+	buf.Write(contents)
+}
+
+func writeName(buf *bytes.Buffer, name string) {
+	writeUint32(buf, uint32(len(name)))
+	buf.WriteString(name)
+}
+
+func writeUint32(buf *bytes.Buffer, v uint32) {
+	buf.Write(leb128.EncodeUint32(v))
+}
@@ -0,0 +1,43 @@
+package wasm
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestCoredump(t *testing.T) {
+	mem := &MemoryInstance{Buffer: make([]byte, 2*MemoryPageSize)}
+	mem.Buffer[MemoryPageSize+1] = 1 // the first page is zero, so skipped.
+	m := &ModuleInstance{
+		Name:    "test",
+		Memory:  mem,
+		Globals: []*GlobalInstance{{Type: &GlobalType{ValType: ValueTypeI32, Mutable: true}, Val: 3}},
+		source: &Module{CodeSection: []*Code{
+			{LocalTypes: []ValueType{ValueTypeI64, ValueTypeF32}, BodyOffsetInCodeSection: 10},
+		}},
+	}
+	f := &FunctionInstance{Module: m, Type: &FunctionType{Params: []ValueType{ValueTypeI32}}}
+
+	// The last local is missing.
+	coredump := Coredump("test", []CoredumpFrame{{Function: f, Offset: 15, Locals: []uint64{1, 2}}})
+
+	expected := []byte{
+		0x00, 'a', 's', 'm', 0x01, 0x00, 0x00, 0x00,
+		SectionIDCustom, 0x0b, 0x04, 'c', 'o', 'r', 'e', 0x00, 0x04, 't', 'e', 's', 't',
+		SectionIDCustom, 0x13, 0x0b, 'c', 'o', 'r', 'e', 'm', 'o', 'd', 'u', 'l', 'e', 's',
+		0x01, 0x00, 0x04, 't', 'e', 's', 't',
+		SectionIDCustom, 0x15, 0x0d, 'c', 'o', 'r', 'e', 'i', 'n', 's', 't', 'a', 'n', 'c', 'e', 's',
+		0x01, 0x00, 0x00, 0x01, 0x00, 0x01, 0x00,
+		SectionIDCustom, 0x1c, 0x09, 'c', 'o', 'r', 'e', 's', 't', 'a', 'c', 'k',
+		0x00, 0x04, 'm', 'a', 'i', 'n',
+		0x01, 0x00, 0x00, 0x00, 0x05, // instance, function and code offset
+		0x03, ValueTypeI32, 0x01, ValueTypeI64, 0x02, 0x01, // locals
+		0x00, // stack
+		SectionIDMemory, 0x03, 0x01, 0x00, 0x02,
+		SectionIDGlobal, 0x06, 0x01, ValueTypeI32, 0x01, OpcodeI32Const, 0x03, OpcodeEnd,
+		SectionIDData, 0x8a, 0x80, 0x04, 0x01, 0x00, OpcodeI32Const, 0x80, 0x80, 0x04, OpcodeEnd, 0x80, 0x80, 0x04,
+	}
+	expected = append(expected, mem.Buffer[MemoryPageSize:]...)
+	require.Equal(t, expected, coredump)
+}
@@ -0,0 +1,107 @@
+package wasm
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/platform"
+)
+
+// cpuTime accumulates the api.CPUTime of a module instance in nanoseconds.
+//
+// Note: Exclusively reading and updating this with atomics guarantees cross-goroutine observations.
+type cpuTime struct {
+	guest, host int64
+}
+
+// cpuTimeKey is a context.Context Value key. Its associated value is the *cpuTimeFrame of the current call.
+type cpuTimeKey struct{}
+
+// cpuTimeFrame accumulates the time of a single api.Function Call.
+type cpuTimeFrame struct {
+	// parent is the frame of the call a host function made this call from, or nil.
+	parent *cpuTimeFrame
+	// cpuTime is that of the module the called function is defined in.
+	cpuTime *cpuTime
+	// start is the CPU time of the thread when the call started.
+	start int64
+	// host is the time spent in host functions, excluding nested calls.
+	host int64
+	// nested is the time spent in calls back into wasm from the current host function.
+	nested int64
+	// excluded is the total time spent in calls back into wasm, attributed to the called module.
+	excluded int64
+}
+
+// CPUTime implements the same method as documented on api.Module.
+func (m *CallContext) CPUTime() api.CPUTime {
+	if m.cpuTime == nil {
+		return api.CPUTime{}
+	}
+	return api.CPUTime{
+		Guest: time.Duration(atomic.LoadInt64(&m.cpuTime.guest)),
+		Host:  time.Duration(atomic.LoadInt64(&m.cpuTime.host)),
+	}
+}
+
+// Cputime returns the CPU time clock of the module, as read by the host function called with ctx.
+//
+// When configured with wazero.ModuleConfig WithSysCputime, this is the CPU time consumed by calls to the module,
+// including those in progress, so that it doesn't reveal the CPU time of the host or other modules. Otherwise, this
+// is the configured sys.Cputime.
+func (m *CallContext) Cputime(ctx context.Context) int64 {
+	if m.cpuTime == nil || !m.Sys.ModuleCputime() {
+		return m.Sys.Cputime()
+	}
+	ret := atomic.LoadInt64(&m.cpuTime.guest) + atomic.LoadInt64(&m.cpuTime.host)
+	// Add the time of calls in progress, which are only added to cpuTime when they return.
+	end := platform.ThreadCputime()
+	for f, _ := ctx.Value(cpuTimeKey{}).(*cpuTimeFrame); f != nil; f = f.parent {
+		if f.cpuTime == m.cpuTime {
+			ret += end - f.start - f.excluded - f.nested
+		}
+		end = f.start
+	}
+	return ret
+}
+
+// callWithCPUTime calls the function, adding the time spent to the module it is defined in.
+//
+// The goroutine is locked to its thread during the call, so that the CPU time of the thread is that of the call.
+func (f *function) callWithCPUTime(ctx context.Context, m *CallContext, params []uint64) (ret []uint64, err error) {
+	parent, _ := ctx.Value(cpuTimeKey{}).(*cpuTimeFrame)
+	frame := &cpuTimeFrame{parent: parent, cpuTime: m.cpuTime}
+	ctx = context.WithValue(ctx, cpuTimeKey{}, frame)
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	frame.start = platform.ThreadCputime()
+	defer func() {
+		elapsed := platform.ThreadCputime() - frame.start
+		atomic.AddInt64(&m.cpuTime.guest, elapsed-frame.host-frame.excluded)
+		atomic.AddInt64(&m.cpuTime.host, frame.host)
+		if parent != nil { // called back from a host function.
+			parent.nested += elapsed
+		}
+	}()
+	return f.ce.Call(ctx, m, params)
+}
+
+// hostCallStarted returns a function to invoke when the host function called with ctx returns, or nil if CPU time
+// isn't tracked.
+func hostCallStarted(ctx context.Context) func() {
+	frame, ok := ctx.Value(cpuTimeKey{}).(*cpuTimeFrame)
+	if !ok {
+		return nil
+	}
+	start := platform.ThreadCputime()
+	return func() {
+		elapsed := platform.ThreadCputime() - start
+		frame.host += elapsed - frame.nested
+		frame.excluded += frame.nested
+		frame.nested = 0
+	}
+}
@@ -0,0 +1,164 @@
+package wasm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/tetratelabs/wazero/api"
+	internalsys "github.com/tetratelabs/wazero/internal/sys"
+)
+
+// DivergenceHandler is documented on wazero.DivergenceHandler.
+type DivergenceHandler func(ctx context.Context, def api.FunctionDefinition, divergence string)
+
+// differentialKey is a context.Context Value key set while a function is
+// called on both engines, so that nested calls, e.g. by a host function, are
+// only made on the engine of their caller.
+type differentialKey struct{}
+
+// EnableDifferential instantiates each module instantiated in this store in d
+// too, which should use another engine, and makes each call to a function of
+// the module on both engines, passing any difference in the results, traps,
+// memory or globals to handler.
+//
+// Note: Host functions are called on both engines, sharing the same system
+// context, so their side effects, such as writing to stdout, happen twice.
+func (s *Store) EnableDifferential(d *Store, handler DivergenceHandler) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.differential, s.divergenceHandler = d, handler
+	for _, ns := range s.namespaces {
+		ns.differential = d.NewNamespace(context.Background())
+	}
+}
+
+// instantiateDifferential instantiates the module of callCtx, which was just
+// instantiated in ns, in the differential store.
+func (s *Store) instantiateDifferential(ctx context.Context, ns *Namespace, module *Module, name string, sys *internalsys.Context, callCtx *CallContext) error {
+	d := s.differential
+	if err := d.Engine.CompileModule(ctx, module, nil, false); err != nil {
+		return fmt.Errorf("differential: %w", err)
+	}
+	dCallCtx, err := d.Instantiate(ctx, ns.differential, module, name, sys)
+	if err != nil {
+		return fmt.Errorf("differential: %w", err)
+	}
+	callCtx.differential, callCtx.divergenceHandler = dCallCtx, s.divergenceHandler
+	return nil
+}
+
+// callDifferential calls the function on both engines, returning the results
+// of this one after passing any divergence to the DivergenceHandler.
+func (f *function) callDifferential(ctx context.Context, m *CallContext, params []uint64) ([]uint64, error) {
+	ctx = context.WithValue(ctx, differentialKey{}, struct{}{})
+	dParams := append([]uint64(nil), params...) // in case params are overwritten by results.
+
+	results, err := f.call(ctx, m, params)
+	results = append([]uint64(nil), results...) // in case the engine reuses the slice.
+
+	d := m.differential
+	fi := &d.module.Functions[f.fi.Idx]
+	ce, dErr := fi.Module.Engine.NewCallEngine(d, fi)
+	var dResults []uint64
+	if dErr == nil {
+		dResults, dErr = ce.Call(ctx, d, dParams)
+	}
+
+	if divergence := diverges(f.fi, results, dResults, err, dErr, m.module, d.module); divergence != "" {
+		m.divergenceHandler(ctx, f.fi.Definition, divergence)
+	}
+	return results, err
+}
+
+// diverges returns a description of the difference between the calls of the
+// function fi on each engine, or "" if there's none.
+func diverges(fi *FunctionInstance, results, dResults []uint64, err, dErr error, m, d *ModuleInstance) string {
+	if errMsg, dErrMsg := firstLine(err), firstLine(dErr); errMsg != dErrMsg {
+		return fmt.Sprintf("error %q != %q", errMsg, dErrMsg)
+	}
+	if err == nil {
+		if len(results) != len(dResults) {
+			return fmt.Sprintf("%d results != %d", len(results), len(dResults))
+		}
+		i := 0
+		for _, t := range fi.Type.Results {
+			n := 1
+			if t == ValueTypeV128 { // two slots, compared as i64s
+				n, t = 2, ValueTypeI64
+			}
+			for ; n > 0; n-- {
+				if !valueEq(t, results[i], dResults[i]) {
+					return fmt.Sprintf("result[%d] %#x != %#x", i, results[i], dResults[i])
+				}
+				i++
+			}
+		}
+	}
+
+	if m.Memory != nil && d.Memory != nil {
+		buf, dBuf := m.Memory.Buffer, d.Memory.Buffer
+		if len(buf) != len(dBuf) {
+			return fmt.Sprintf("memory size %d != %d", len(buf), len(dBuf))
+		}
+		if !bytes.Equal(buf, dBuf) {
+			for i := range buf {
+				if buf[i] != dBuf[i] {
+					return fmt.Sprintf("memory[%d] %#x != %#x", i, buf[i], dBuf[i])
+				}
+			}
+		}
+	}
+
+	for i, g := range m.Globals {
+		dg := d.Globals[i]
+		switch g.Type.ValType {
+		case ValueTypeFuncref, ValueTypeExternref: // references are specific to each engine
+		case ValueTypeF32, ValueTypeF64:
+			if !valueEq(g.Type.ValType, g.Val, dg.Val) {
+				return fmt.Sprintf("global[%d] %#x != %#x", i, g.Val, dg.Val)
+			}
+		default:
+			if g.Val != dg.Val || g.ValHi != dg.ValHi {
+				return fmt.Sprintf("global[%d] %#x%016x != %#x%016x", i, g.ValHi, g.Val, dg.ValHi, dg.Val)
+			}
+		}
+	}
+	return ""
+}
+
+// valueEq returns true if the values of type t are equal. Any NaNs are equal,
+// as the bits of a NaN produced by an instruction are non-deterministic.
+func valueEq(t ValueType, v, dv uint64) bool {
+	switch t {
+	case ValueTypeI32:
+		return uint32(v) == uint32(dv)
+	case ValueTypeF32:
+		if math.IsNaN(float64(math.Float32frombits(uint32(v)))) {
+			return math.IsNaN(float64(math.Float32frombits(uint32(dv))))
+		}
+		return uint32(v) == uint32(dv)
+	case ValueTypeF64:
+		if math.IsNaN(math.Float64frombits(v)) {
+			return math.IsNaN(math.Float64frombits(dv))
+		}
+	case ValueTypeFuncref, ValueTypeExternref: // references are specific to each engine
+		return true
+	}
+	return v == dv
+}
+
+// firstLine returns the first line of the error, which excludes the stack
+// trace, or "" if nil.
+func firstLine(err error) string {
+	if err == nil {
+		return ""
+	}
+	msg := err.Error()
+	if i := strings.IndexByte(msg, '\n'); i >= 0 {
+		return msg[:i]
+	}
+	return msg
+}
@@ -0,0 +1,43 @@
+package wasm
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func Test_valueEq(t *testing.T) {
+	nan32, otherNaN32 := uint64(math.Float32bits(float32(math.NaN()))), uint64(0x7fc00001)
+	nan64, otherNaN64 := math.Float64bits(math.NaN()), uint64(0x7ff8000000000001)
+
+	tests := []struct {
+		name  string
+		t     ValueType
+		v, dv uint64
+		eq    bool
+	}{
+		{name: "i32", t: ValueTypeI32, v: 1, dv: 1, eq: true},
+		{name: "i32 upper bits", t: ValueTypeI32, v: 1, dv: 1 | 1<<32, eq: true},
+		{name: "i32 different", t: ValueTypeI32, v: 1, dv: 2},
+		{name: "i64 upper bits", t: ValueTypeI64, v: 1, dv: 1 | 1<<32},
+		{name: "f32 NaNs", t: ValueTypeF32, v: nan32, dv: otherNaN32, eq: true},
+		{name: "f32 NaN and zero", t: ValueTypeF32, v: nan32, dv: 0},
+		{name: "f64 NaNs", t: ValueTypeF64, v: nan64, dv: otherNaN64, eq: true},
+		{name: "f64 zeros", t: ValueTypeF64, v: 0, dv: math.Float64bits(math.Copysign(0, -1))},
+		{name: "funcref", t: ValueTypeFuncref, v: 1, dv: 2, eq: true},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.eq, valueEq(tt.t, tt.v, tt.dv))
+		})
+	}
+}
+
+func Test_firstLine(t *testing.T) {
+	require.Equal(t, "", firstLine(nil))
+	require.Equal(t, "wasm error: unreachable", firstLine(errors.New("wasm error: unreachable\nwasm stack trace:\n\t.$0()")))
+}
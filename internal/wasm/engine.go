@@ -10,7 +10,11 @@ import (
 // This is a top-level type implemented by an interpreter or compiler.
 type Engine interface {
 	// CompileModule implements the same method as documented on wasm.Engine.
-	CompileModule(ctx context.Context, module *Module, listeners []experimental.FunctionListener) error
+	//
+	// When ensureTermination is true, the compiled code checks if the module
+	// was closed at each loop header, so that an infinite loop can be
+	// interrupted by closing the module. See CallContext.CloseModuleOnCanceledOrTimeout.
+	CompileModule(ctx context.Context, module *Module, listeners []experimental.FunctionListener, ensureTermination bool) error
 
 	// CompiledModuleCount is exported for testing, to track the size of the compilation cache.
 	CompiledModuleCount() uint32
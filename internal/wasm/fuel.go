@@ -0,0 +1,24 @@
+package wasm
+
+import (
+	"context"
+
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/wasmruntime"
+)
+
+// ConsumeFuel consumes one unit of the experimental.Fuel of a call to a
+// function compiled with Module.MeterFuel, if it has any, or panics with
+// wasmruntime.ErrRuntimeFuelExhausted when none remains.
+//
+// Note: This implements wazeroir.OperationBuiltinFunctionConsumeFuel for the
+// engines.
+func ConsumeFuel(ctx context.Context) {
+	fuel, ok := ctx.Value(experimental.FuelKey{}).(*experimental.Fuel)
+	if !ok {
+		return
+	} else if fuel.Remaining == 0 {
+		panic(wasmruntime.ErrRuntimeFuelExhausted)
+	}
+	fuel.Remaining--
+}
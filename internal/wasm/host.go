@@ -105,11 +105,22 @@ func NewHostModule(
 	}
 
 	m.IsHostModule = true
-	m.AssignModuleID([]byte(fmt.Sprintf("%s:%v:%v", moduleName, nameToGoFunc, enabledFeatures)))
+	m.AssignModuleID([]byte(fmt.Sprintf("%s:%v:%v", moduleName, nameToGoFunc, enabledFeatures)), false)
 	m.BuildFunctionDefinitions()
 	return
 }
 
+// ExportHostMemory makes the host module export the memory under the names. The memory is shared with the modules
+// importing it, rather than defined by the host module.
+func (m *Module) ExportHostMemory(names []string, memory *MemoryInstance) {
+	m.HostMemory = memory
+	m.MemorySection = &Memory{Min: memory.PageSize(), Cap: memory.Cap, Max: memory.Max, IsMaxEncoded: true}
+	for _, name := range names {
+		m.ExportSection = append(m.ExportSection, &Export{Type: ExternTypeMemory, Name: name})
+	}
+	m.BuildMemoryDefinitions()
+}
+
 func addFuncs(
 	m *Module,
 	nameToGoFunc map[string]interface{},
@@ -0,0 +1,84 @@
+package wasm
+
+import (
+	"context"
+	"runtime/debug"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// HostFunctionPanicPolicy is documented on wazero.HostFunctionPanicPolicy.
+type HostFunctionPanicPolicy byte
+
+const (
+	// HostFunctionPanicPolicyTrap is documented on wazero.HostFunctionPanicPolicyTrap.
+	HostFunctionPanicPolicyTrap HostFunctionPanicPolicy = iota
+	// HostFunctionPanicPolicyRepanic is documented on wazero.HostFunctionPanicPolicyRepanic.
+	HostFunctionPanicPolicyRepanic
+	// HostFunctionPanicPolicyHandler is documented on wazero.HostFunctionPanicPolicyHandler.
+	HostFunctionPanicPolicyHandler
+)
+
+// HostFunctionPanicHandler is documented on wazero.HostFunctionPanicHandler.
+type HostFunctionPanicHandler func(ctx context.Context, def api.FunctionDefinition, recovered interface{}, stack []byte) error
+
+// HostFunctionPanic is panicked by CallGoFunc under HostFunctionPanicPolicyRepanic. Engines must re-panic Recovered
+// after resetting their state, instead of converting it to an error.
+type HostFunctionPanic struct {
+	// Recovered is the original value passed to panic by the host function.
+	Recovered interface{}
+}
+
+// CallGoFunc invokes the Go function of the host function f, which is either an api.GoModuleFunction or
+// api.GoFunction. callCtx with the callerMemory is passed to an api.GoModuleFunction.
+//
+// A panic inside the function is handled according to the HostFunctionPanicPolicy of callCtx.
+func CallGoFunc(ctx context.Context, callCtx *CallContext, callerMemory *MemoryInstance, f *FunctionInstance, stack []uint64) {
+	if callCtx.hostFunctionPanicPolicy != HostFunctionPanicPolicyTrap {
+		defer callCtx.onHostFunctionPanic(ctx, f)
+	}
+	if callCtx.cpuTime != nil {
+		if done := hostCallStarted(ctx); done != nil {
+			defer done()
+		}
+	}
+
+	switch fn := f.GoFunc.(type) {
+	case api.GoModuleFunction:
+		fn.Call(ctx, callCtx.WithMemory(callerMemory), stack)
+	case api.GoFunction:
+		fn.Call(ctx, stack)
+	}
+}
+
+// onHostFunctionPanic must be deferred, as it recovers any panic from the host function f.
+func (m *CallContext) onHostFunctionPanic(ctx context.Context, f *FunctionInstance) {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+
+	// An exit error isn't a fault, rather how functions such as proc_exit stop execution.
+	if _, ok := recovered.(*sys.ExitError); ok {
+		panic(recovered)
+	}
+	// A nested api.Function call already handled the panic.
+	if _, ok := recovered.(*HostFunctionPanic); ok {
+		panic(recovered)
+	}
+
+	switch m.hostFunctionPanicPolicy {
+	case HostFunctionPanicPolicyRepanic:
+		panic(&HostFunctionPanic{Recovered: recovered})
+	case HostFunctionPanicPolicyHandler:
+		if h := m.hostFunctionPanicHandler; h != nil {
+			// Note: The stack of the goroutine is not yet unwound, so this includes where the panic happened.
+			if err := h(ctx, f.Definition, recovered, debug.Stack()); err != nil {
+				panic(err)
+			}
+		}
+	}
+	// Otherwise, convert the panic into a trap, the same as HostFunctionPanicPolicyTrap.
+	panic(recovered)
+}
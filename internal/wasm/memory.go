@@ -6,9 +6,12 @@ import (
 	"math"
 	"reflect"
 	"sync"
+	"sync/atomic"
 	"unsafe"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/platform"
 )
 
 const (
@@ -32,26 +35,72 @@ var _ api.Memory = &MemoryInstance{}
 // wasm.Store Memories index zero: `store.Memories[0]`
 // See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#memory-instances%E2%91%A0.
 type MemoryInstance struct {
-	Buffer        []byte
+	Buffer []byte
+	// Min, Cap and Max are in pages, which are MemoryPageSize bytes unless the memory has a custom page size.
 	Min, Cap, Max uint32
+	// generation is incremented when the memory may have been written since it was first cloned, so that snapshot is
+	// only reused while it's unchanged. See touch.
+	//
+	// Note: This follows 24 bytes on 32-bit platforms, so it's aligned for atomics. Buffer must be first for the
+	// compiler.
+	generation uint64
 	// mux is used to prevent overlapping calls to Grow.
 	mux sync.RWMutex
 	// definition is known at compile time.
 	definition api.MemoryDefinition
+	// limiter is consulted before Grow when not nil.
+	limiter experimental.ResourceLimiter
+
+	// grown is called after Grow succeeds with a non-zero delta, when not nil. It is called without holding mux, so
+	// that it can read the memory.
+	grown func(previousPages, pages uint32)
+	// growCount and peakPages are updated by Grow. See api.MemoryStats.
+	growCount, peakPages uint32
+
+	// snapshot is the copy of this memory at snapshotGeneration mapped by clone, or nil before the first clone.
+	snapshot           *platform.Snapshot
+	snapshotGeneration uint64
+	// mapping is the mapping of a snapshot this memory was cloned from, which is unmapped by unmap.
+	mapping []byte
+	// cloned is non-zero once clone was called, so that touch only costs anything when a snapshot may be reused.
+	cloned uint32
+
+	// Protectable is true if the functions which may write this memory trap on writes to read-only pages, as they
+	// were compiled with Module.ProtectMemory. Otherwise, Protect fails.
+	Protectable bool
+	// readOnly holds a []uint64 with a bit set for each read-only page, or nothing if there never were any. It is
+	// replaced by Protect while holding mux, and loaded atomically by IsReadOnly, which is called on each guest store.
+	readOnly atomic.Value
+
+	// isPageSizeEncoded and pageSizeLog2 are the same as documented on Memory.
+	isPageSizeEncoded bool
+	pageSizeLog2      uint32
 }
 
 // NewMemoryInstance creates a new instance based on the parameters in the SectionIDMemory.
 func NewMemoryInstance(memSec *Memory) *MemoryInstance {
-	min := MemoryPagesToBytesNum(memSec.Min)
-	capacity := MemoryPagesToBytesNum(memSec.Cap)
+	bits := memSec.PageSizeInBits()
+	min := uint64(memSec.Min) << bits
+	capacity := uint64(memSec.Cap) << bits
 	return &MemoryInstance{
-		Buffer: make([]byte, min, capacity),
-		Min:    memSec.Min,
-		Cap:    memSec.Cap,
-		Max:    memSec.Max,
+		Buffer:            make([]byte, min, capacity),
+		Min:               memSec.Min,
+		Cap:               memSec.Cap,
+		Max:               memSec.Max,
+		isPageSizeEncoded: memSec.IsPageSizeEncoded,
+		pageSizeLog2:      memSec.PageSizeLog2,
 	}
 }
 
+// PageSizeInBits returns the log2 of the page size in bytes, which is MemoryPageSizeInBits unless the memory has a
+// custom page size.
+func (m *MemoryInstance) PageSizeInBits() uint32 {
+	if m.isPageSizeEncoded {
+		return m.pageSizeLog2
+	}
+	return MemoryPageSizeInBits
+}
+
 // Definition implements the same method as documented on api.Memory.
 func (m *MemoryInstance) Definition() api.MemoryDefinition {
 	return m.definition
@@ -108,6 +157,7 @@ func (m *MemoryInstance) ReadFloat64Le(offset uint32) (float64, bool) {
 
 // Read implements the same method as documented on api.Memory.
 func (m *MemoryInstance) Read(offset, byteCount uint32) ([]byte, bool) {
+	m.touch()
 	if !m.hasSize(offset, byteCount) {
 		return nil, false
 	}
@@ -116,7 +166,8 @@ func (m *MemoryInstance) Read(offset, byteCount uint32) ([]byte, bool) {
 
 // WriteByte implements the same method as documented on api.Memory.
 func (m *MemoryInstance) WriteByte(offset uint32, v byte) bool {
-	if offset >= m.size() {
+	m.touch()
+	if offset >= m.size() || m.IsReadOnly(uint64(offset), 1) {
 		return false
 	}
 	m.Buffer[offset] = v
@@ -125,7 +176,8 @@ func (m *MemoryInstance) WriteByte(offset uint32, v byte) bool {
 
 // WriteUint16Le implements the same method as documented on api.Memory.
 func (m *MemoryInstance) WriteUint16Le(offset uint32, v uint16) bool {
-	if !m.hasSize(offset, 2) {
+	m.touch()
+	if !m.hasSize(offset, 2) || m.IsReadOnly(uint64(offset), 2) {
 		return false
 	}
 	binary.LittleEndian.PutUint16(m.Buffer[offset:], v)
@@ -134,27 +186,32 @@ func (m *MemoryInstance) WriteUint16Le(offset uint32, v uint16) bool {
 
 // WriteUint32Le implements the same method as documented on api.Memory.
 func (m *MemoryInstance) WriteUint32Le(offset, v uint32) bool {
+	m.touch()
 	return m.writeUint32Le(offset, v)
 }
 
 // WriteFloat32Le implements the same method as documented on api.Memory.
 func (m *MemoryInstance) WriteFloat32Le(offset uint32, v float32) bool {
+	m.touch()
 	return m.writeUint32Le(offset, math.Float32bits(v))
 }
 
 // WriteUint64Le implements the same method as documented on api.Memory.
 func (m *MemoryInstance) WriteUint64Le(offset uint32, v uint64) bool {
+	m.touch()
 	return m.writeUint64Le(offset, v)
 }
 
 // WriteFloat64Le implements the same method as documented on api.Memory.
 func (m *MemoryInstance) WriteFloat64Le(offset uint32, v float64) bool {
+	m.touch()
 	return m.writeUint64Le(offset, math.Float64bits(v))
 }
 
 // Write implements the same method as documented on api.Memory.
 func (m *MemoryInstance) Write(offset uint32, val []byte) bool {
-	if !m.hasSize(offset, uint32(len(val))) {
+	m.touch()
+	if !m.hasSize(offset, uint32(len(val))) || m.IsReadOnly(uint64(offset), uint64(len(val))) {
 		return false
 	}
 	copy(m.Buffer[offset:], val)
@@ -163,13 +220,66 @@ func (m *MemoryInstance) Write(offset uint32, val []byte) bool {
 
 // WriteString implements the same method as documented on api.Memory.
 func (m *MemoryInstance) WriteString(offset uint32, val string) bool {
-	if !m.hasSize(offset, uint32(len(val))) {
+	m.touch()
+	if !m.hasSize(offset, uint32(len(val))) || m.IsReadOnly(uint64(offset), uint64(len(val))) {
 		return false
 	}
 	copy(m.Buffer[offset:], val)
 	return true
 }
 
+// Protect implements the same method as documented on api.Memory.
+func (m *MemoryInstance) Protect(offset, byteCount uint32, readOnly bool) bool {
+	if !m.Protectable || !m.hasSize(offset, byteCount) {
+		return false
+	} else if byteCount == 0 {
+		return true
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	first, last := offset>>MemoryPageSizeInBits, uint32((uint64(offset)+uint64(byteCount)-1)>>MemoryPageSizeInBits)
+	// The bitmap is replaced instead of updated, as IsReadOnly may be reading it concurrently.
+	prev := m.readOnlyBits()
+	bits := make([]uint64, last/64+1)
+	if len(prev) > len(bits) {
+		bits = make([]uint64, len(prev))
+	}
+	copy(bits, prev)
+	for page := first; page <= last; page++ {
+		if readOnly {
+			bits[page/64] |= 1 << (page % 64)
+		} else {
+			bits[page/64] &^= 1 << (page % 64)
+		}
+	}
+	m.readOnly.Store(bits)
+	return true
+}
+
+// readOnlyBits returns the bitmap of read-only pages, or nil if there never were any.
+func (m *MemoryInstance) readOnlyBits() []uint64 {
+	bits, _ := m.readOnly.Load().([]uint64)
+	return bits
+}
+
+// IsReadOnly returns true if any of the byteCount bytes at the offset are in a page made read-only by Protect. The
+// offset may be out of range, such as the one of a store which traps.
+func (m *MemoryInstance) IsReadOnly(offset, byteCount uint64) bool {
+	bits := m.readOnlyBits()
+	if bits == nil || byteCount == 0 {
+		return false
+	}
+	first, last := offset>>MemoryPageSizeInBits, (offset+byteCount-1)>>MemoryPageSizeInBits
+	for page := first; page <= last && page/64 < uint64(len(bits)); page++ {
+		if bits[page/64]&(1<<(page%64)) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
 // MemoryPagesToBytesNum converts the given pages into the number of bytes contained in these pages.
 func MemoryPagesToBytesNum(pages uint32) (bytesNum uint64) {
 	return uint64(pages) << MemoryPageSizeInBits
@@ -177,33 +287,134 @@ func MemoryPagesToBytesNum(pages uint32) (bytesNum uint64) {
 
 // Grow implements the same method as documented on api.Memory.
 func (m *MemoryInstance) Grow(delta uint32) (result uint32, ok bool) {
+	if result, ok = m.grow(delta); ok && delta != 0 && m.grown != nil {
+		m.grown(result, result+delta)
+	}
+	return
+}
+
+// grow is extracted from Grow to release the lock before calling grown.
+func (m *MemoryInstance) grow(delta uint32) (result uint32, ok bool) {
 	// We take write-lock here as the following might result in a new slice
 	m.mux.Lock()
 	defer m.mux.Unlock()
 
-	currentPages := memoryBytesNumToPages(uint64(len(m.Buffer)))
+	bits := m.PageSizeInBits()
+	currentPages := uint32(uint64(len(m.Buffer)) >> bits)
 	if delta == 0 {
 		return currentPages, true
 	}
 
-	// If exceeds the max of memory size, we push -1 according to the spec.
+	// If exceeds the max of memory size, we push -1 according to the spec. This compares in 64-bits, as a memory
+	// with 1 byte pages can overflow.
+	if uint64(currentPages)+uint64(delta) > uint64(m.Max) {
+		return 0, false
+	}
 	newPages := currentPages + delta
-	if newPages > m.Max {
+	if m.limiter != nil && !m.limiter.MemoryGrowing(currentPages, newPages, m.Max) {
 		return 0, false
 	} else if newPages > m.Cap { // grow the memory.
-		m.Buffer = append(m.Buffer, make([]byte, MemoryPagesToBytesNum(delta))...)
+		m.Buffer = append(m.Buffer, make([]byte, uint64(delta)<<bits)...)
 		m.Cap = newPages
-		return currentPages, true
 	} else { // We already have the capacity we need.
 		sp := (*reflect.SliceHeader)(unsafe.Pointer(&m.Buffer))
-		sp.Len = int(MemoryPagesToBytesNum(newPages))
-		return currentPages, true
+		sp.Len = int(uint64(newPages) << bits)
+	}
+	m.touch()
+	m.growCount++
+	if newPages > m.peakPages {
+		m.peakPages = newPages
 	}
+	return currentPages, true
+}
+
+// touch notes that the memory may have been written, so that clone takes a new snapshot. This does nothing until
+// the memory is first cloned, as there's no snapshot to invalidate before.
+func (m *MemoryInstance) touch() {
+	if atomic.LoadUint32(&m.cloned) != 0 {
+		atomic.AddUint64(&m.generation, 1)
+	}
+}
+
+// clone returns a copy of this memory. Where supported, the copy is a copy-on-write mapping of a snapshot of this
+// memory, which is reused by later clones until this memory may have been written.
+func (m *MemoryInstance) clone() (*MemoryInstance, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	ret := &MemoryInstance{
+		Min: m.Min, Cap: m.Cap, Max: m.Max,
+		definition: m.definition, limiter: m.limiter,
+		growCount: m.growCount, peakPages: m.peakPages,
+		Protectable:       m.Protectable,
+		isPageSizeEncoded: m.isPageSizeEncoded, pageSizeLog2: m.pageSizeLog2,
+	}
+	if bits := m.readOnlyBits(); bits != nil {
+		ret.readOnly.Store(append([]uint64(nil), bits...))
+	}
+	size := int(uint64(m.Cap) << m.PageSizeInBits())
+	if size == 0 {
+		ret.Buffer = []byte{}
+		return ret, nil
+	}
+
+	// Writes are only tracked from now on, so the first clone always takes a snapshot.
+	atomic.StoreUint32(&m.cloned, 1)
+	if generation := atomic.LoadUint64(&m.generation); m.snapshot == nil || m.snapshotGeneration != generation {
+		s, err := platform.NewSnapshot(m.Buffer, size)
+		if err != nil {
+			return nil, fmt.Errorf("snapshot memory: %w", err)
+		}
+		if m.snapshot != nil {
+			_ = m.snapshot.Close()
+		}
+		m.snapshot, m.snapshotGeneration = s, generation
+	}
+	mapping, err := m.snapshot.Map()
+	if err != nil {
+		return nil, fmt.Errorf("map memory: %w", err)
+	}
+	ret.Buffer, ret.mapping = mapping[:len(m.Buffer)], mapping
+	return ret, nil
+}
+
+// unmap releases the mapping this memory was cloned from, if any, after which the memory is empty. This is called
+// when the module which defines it is closed and its calls returned, so views returned by Read are invalid after.
+func (m *MemoryInstance) unmap() {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if m.mapping != nil {
+		_ = platform.Unmap(m.mapping)
+		m.Buffer, m.mapping = nil, nil
+	}
+}
+
+// closeSnapshot releases the snapshot taken by clone, if any, which doesn't affect the clones mapping it.
+func (m *MemoryInstance) closeSnapshot() {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if s := m.snapshot; s != nil {
+		_ = s.Close()
+		m.snapshot = nil
+	}
+}
+
+// stats returns the api.MemoryStats of this memory, except DataSegmentSize.
+func (m *MemoryInstance) stats() api.MemoryStats {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	size := uint64(len(m.Buffer))
+	peak := uint64(m.peakPages) << m.PageSizeInBits()
+	if size > peak { // The initial size isn't a grow event.
+		peak = size
+	}
+	return api.MemoryStats{Size: size, PeakSize: peak, GrowCount: m.growCount}
 }
 
 // PageSize returns the current memory buffer size in pages.
 func (m *MemoryInstance) PageSize() (result uint32) {
-	return memoryBytesNumToPages(uint64(len(m.Buffer)))
+	return uint32(uint64(len(m.Buffer)) >> m.PageSizeInBits())
 }
 
 // PagesToUnitOfBytes converts the pages to a human-readable form similar to what's specified. e.g. 1 -> "64Ki"
@@ -265,7 +476,7 @@ func (m *MemoryInstance) readUint64Le(offset uint32) (uint64, bool) {
 // writeUint32Le implements WriteUint32Le without using a context. This is extracted as both ints and floats are stored
 // in memory as uint32le.
 func (m *MemoryInstance) writeUint32Le(offset uint32, v uint32) bool {
-	if !m.hasSize(offset, 4) {
+	if !m.hasSize(offset, 4) || m.IsReadOnly(uint64(offset), 4) {
 		return false
 	}
 	binary.LittleEndian.PutUint32(m.Buffer[offset:], v)
@@ -275,7 +486,7 @@ func (m *MemoryInstance) writeUint32Le(offset uint32, v uint32) bool {
 // writeUint64Le implements WriteUint64Le without using a context. This is extracted as both ints and floats are stored
 // in memory as uint64le.
 func (m *MemoryInstance) writeUint64Le(offset uint32, v uint64) bool {
-	if !m.hasSize(offset, 8) {
+	if !m.hasSize(offset, 8) || m.IsReadOnly(uint64(offset), 8) {
 		return false
 	}
 	binary.LittleEndian.PutUint64(m.Buffer[offset:], v)
@@ -27,6 +27,41 @@ func TestMemoryBytesNumToPages(t *testing.T) {
 	}
 }
 
+func TestMemoryInstance_Grow_Limiter(t *testing.T) {
+	limiter := &testResourceLimiter{maxMemoryPages: 3}
+	m := &MemoryInstance{Max: 10, Buffer: make([]byte, 0), limiter: limiter}
+
+	res, ok := m.Grow(2)
+	require.True(t, ok)
+	require.Equal(t, uint32(0), res)
+
+	// Vetoed by the limiter, even though within Max.
+	_, ok = m.Grow(2)
+	require.False(t, ok)
+	require.Equal(t, uint32(2), m.PageSize())
+
+	// The limiter isn't consulted when exceeding Max.
+	_, ok = m.Grow(20)
+	require.False(t, ok)
+	require.Equal(t, [][3]uint32{{0, 2, 10}, {2, 4, 10}}, limiter.memoryCalls)
+}
+
+func TestMemoryInstance_stats(t *testing.T) {
+	m := &MemoryInstance{Max: 10, Buffer: make([]byte, MemoryPagesToBytesNum(1))}
+	require.Equal(t, api.MemoryStats{Size: 65536, PeakSize: 65536}, m.stats())
+
+	_, ok := m.Grow(2)
+	require.True(t, ok)
+
+	// Neither zero nor failed grows are counted.
+	_, ok = m.Grow(0)
+	require.True(t, ok)
+	_, ok = m.Grow(10)
+	require.False(t, ok)
+
+	require.Equal(t, api.MemoryStats{Size: 3 * 65536, PeakSize: 3 * 65536, GrowCount: 1}, m.stats())
+}
+
 func TestMemoryInstance_Grow_Size(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -88,6 +123,33 @@ func TestMemoryInstance_Grow_Size(t *testing.T) {
 	}
 }
 
+func TestMemoryInstance_Grow_customPageSize(t *testing.T) {
+	m := NewMemoryInstance(&Memory{Min: 3, Cap: 3, Max: 10, IsPageSizeEncoded: true})
+	require.Equal(t, uint32(0), m.PageSizeInBits())
+	require.Equal(t, 3, len(m.Buffer))
+	require.Equal(t, uint32(3), m.PageSize())
+
+	// Pages are bytes.
+	res, ok := m.Grow(4)
+	require.True(t, ok)
+	require.Equal(t, uint32(3), res)
+	require.Equal(t, 7, len(m.Buffer))
+	require.Equal(t, uint32(7), m.PageSize())
+
+	_, ok = m.Grow(4)
+	require.False(t, ok)
+
+	// The page count can't overflow.
+	m.Max = math.MaxUint32
+	_, ok = m.Grow(math.MaxUint32)
+	require.False(t, ok)
+	require.Equal(t, uint32(7), m.PageSize())
+
+	c, err := m.clone()
+	require.NoError(t, err)
+	require.Equal(t, uint32(7), c.PageSize())
+}
+
 func TestMemoryInstance_ReadByte(t *testing.T) {
 	mem := &MemoryInstance{Buffer: []byte{0, 0, 0, 0, 0, 0, 0, 16}, Min: 1}
 	v, ok := mem.ReadByte(7)
@@ -749,6 +811,96 @@ func TestMemoryInstance_WriteString(t *testing.T) {
 	require.False(t, ok)
 }
 
+func TestMemoryInstance_Protect(t *testing.T) {
+	mem := NewMemoryInstance(&Memory{Min: 3, Cap: 3, Max: 3})
+	page := uint64(MemoryPageSize)
+
+	// Memory which isn't protectable can't be protected.
+	require.False(t, mem.Protect(0, 1, true))
+
+	mem.Protectable = true
+	require.False(t, mem.Protect(0, 3*MemoryPageSize+1, true)) // out of range
+	require.False(t, mem.IsReadOnly(0, 3*page))
+
+	// Protecting a byte protects the whole page.
+	require.True(t, mem.Protect(MemoryPageSize+10, 1, true))
+	require.False(t, mem.IsReadOnly(0, page))
+	require.True(t, mem.IsReadOnly(page, 1))
+	require.True(t, mem.IsReadOnly(page-1, 2)) // overlaps
+	require.True(t, mem.IsReadOnly(2*page-1, 1))
+	require.False(t, mem.IsReadOnly(2*page, page))
+	require.False(t, mem.IsReadOnly(1<<40, 8)) // beyond the bitmap
+
+	// Host writes to a read-only page fail, but reads don't.
+	require.True(t, mem.WriteByte(MemoryPageSize-1, 1))
+	require.False(t, mem.WriteByte(MemoryPageSize, 1))
+	require.False(t, mem.WriteUint16Le(MemoryPageSize-1, 1))
+	require.False(t, mem.WriteUint32Le(MemoryPageSize, 1))
+	require.False(t, mem.WriteUint64Le(MemoryPageSize, 1))
+	require.False(t, mem.Write(MemoryPageSize, []byte{1}))
+	require.False(t, mem.WriteString(MemoryPageSize, "a"))
+	_, ok := mem.ReadUint32Le(MemoryPageSize)
+	require.True(t, ok)
+
+	// Clones keep the protection.
+	c, err := mem.clone()
+	require.NoError(t, err)
+	require.True(t, c.Protectable)
+	require.True(t, c.IsReadOnly(page, 1))
+
+	// Pages can be made writable again.
+	require.True(t, mem.Protect(0, 3*MemoryPageSize, false))
+	require.False(t, mem.IsReadOnly(0, 3*page))
+	require.True(t, mem.WriteByte(MemoryPageSize, 1))
+	require.True(t, c.IsReadOnly(page, 1))
+}
+
+func TestMemoryInstance_clone(t *testing.T) {
+	mem := NewMemoryInstance(&Memory{Min: 1, Cap: 2, Max: 3})
+	require.True(t, mem.WriteString(0, "hello"))
+	// Writes aren't tracked until the memory is cloned.
+	require.Equal(t, uint64(0), mem.generation)
+
+	c1, err := mem.clone()
+	require.NoError(t, err)
+	require.Equal(t, len(mem.Buffer), len(c1.Buffer))
+	require.Equal(t, 2*int(MemoryPageSize), cap(c1.Buffer))
+	require.Equal(t, []byte("hello"), c1.Buffer[:5])
+	snapshot := mem.snapshot
+
+	// The snapshot is reused until the memory may have been written.
+	c2, err := mem.clone()
+	require.NoError(t, err)
+	require.Equal(t, snapshot, mem.snapshot)
+
+	_, _ = mem.Read(0, 5)
+	c3, err := mem.clone()
+	require.NoError(t, err)
+	require.NotEqual(t, snapshot, mem.snapshot)
+
+	// Writes to clones aren't visible to the memory or other clones.
+	require.True(t, c1.WriteString(0, "world"))
+	require.Equal(t, []byte("hello"), mem.Buffer[:5])
+	require.Equal(t, []byte("hello"), c2.Buffer[:5])
+	require.Equal(t, []byte("hello"), c3.Buffer[:5])
+
+	// Growing within the capacity stays in the mapping.
+	_, ok := c1.Grow(1)
+	require.True(t, ok)
+	require.Equal(t, []byte("world"), c1.Buffer[:5])
+
+	mem.closeSnapshot()
+	require.Nil(t, mem.snapshot)
+
+	// Clones stay valid after the snapshot is closed, until unmapped.
+	require.Equal(t, []byte("hello"), c2.Buffer[:5])
+	for _, c := range []*MemoryInstance{c1, c2, c3} {
+		c.unmap()
+		require.Nil(t, c.mapping)
+		require.Equal(t, uint32(0), c.Size())
+	}
+}
+
 func BenchmarkWriteString(b *testing.B) {
 	tests := []string{
 		"",
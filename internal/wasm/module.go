@@ -3,13 +3,17 @@ package wasm
 import (
 	"bytes"
 	"crypto/sha256"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"sort"
 	"strings"
+	"sync/atomic"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
 	"github.com/tetratelabs/wazero/internal/ieee754"
 	"github.com/tetratelabs/wazero/internal/leb128"
 	"github.com/tetratelabs/wazero/internal/wasmdebug"
@@ -177,12 +181,17 @@ type Module struct {
 	// See https://www.w3.org/TR/2022/WD-wasm-core-2-20220419/appendix/changes.html#bulk-memory-and-table-instructions
 	DataCountSection *uint32
 
-	// ID is the sha256 value of the source wasm and is used for caching.
+	// ID is the sha256 value of the source wasm plus the configurations which affect the runtime representation of
+	// Wasm binary. This is used for caching.
 	ID ModuleID
 
 	// IsHostModule true if this is the host module, false otherwise.
 	IsHostModule bool
 
+	// HostMemory is the memory a host module shares with the modules importing it, instead of defining the memory
+	// in MemorySection. See ExportHostMemory.
+	HostMemory *MemoryInstance
+
 	// FunctionDefinitionSection is a wazero-specific section built on Validate.
 	FunctionDefinitionSection []*FunctionDefinition
 
@@ -193,6 +202,43 @@ type Module struct {
 	// as described in https://yurydelendik.github.io/webassembly-dwarf/, though it is not specified in the Wasm
 	// specification: https://github.com/WebAssembly/debugging/issues/1
 	DWARFLines *wasmdebug.DWARFLines
+
+	// SourceMappingURL is the URL of the source map of the binary, decoded
+	// from the "sourceMappingURL" custom section when DWARF is enabled.
+	SourceMappingURL string
+
+	// SourceMap is used to emit source map based stack trace, when the binary
+	// has no DWARF. This is loaded from SourceMappingURL before compilation,
+	// via the context key experimental.SourceMapLoaderKey.
+	SourceMap *wasmdebug.SourceMap
+
+	// CodeSectionOffset is the offset of the code section in the binary,
+	// which offsets in SourceMap are relative to.
+	CodeSectionOffset uint64
+
+	// MemoryListener is notified of loads and stores within its watch regions
+	// when not nil. This is set before compilation from the context key
+	// experimental.MemoryListenerKey, and before AssignModuleID.
+	MemoryListener experimental.MemoryListener
+
+	// TrapHandler is invoked when a function traps, when not nil. This is set
+	// before compilation from the RuntimeConfig, and before AssignModuleID.
+	TrapHandler experimental.TrapHandler
+
+	// ProtectMemory instruments each store to trap if it writes a read-only
+	// page of memory when true. This is set before compilation from the
+	// RuntimeConfig, and before AssignModuleID.
+	ProtectMemory bool
+
+	// CanonicalizeNaN replaces a NaN result of each float instruction with
+	// the canonical NaN when true. This is set before compilation from the
+	// RuntimeConfig, and before AssignModuleID.
+	CanonicalizeNaN bool
+
+	// MeterFuel instruments each function to consume fuel on entry and at
+	// each loop header when true. This is set before compilation from the
+	// context, and before AssignModuleID. See experimental.FuelKey.
+	MeterFuel bool
 }
 
 // ModuleID represents sha256 hash value uniquely assigned to Module.
@@ -206,9 +252,43 @@ const (
 	MaximumTableIndex    = uint32(1 << 27)
 )
 
-// AssignModuleID calculates a sha256 checksum on `wasm` and set Module.ID to the result.
-func (m *Module) AssignModuleID(wasm []byte) {
-	m.ID = sha256.Sum256(wasm)
+// instrumentedModules is the count of modules assigned an ID with a
+// MemoryListener or TrapHandler.
+var instrumentedModules uint64
+
+// AssignModuleID calculates a sha256 checksum on `wasm` and other args, and set Module.ID to the result.
+// See the doc on Module.ID on what it's used for.
+//
+// When Module.MemoryListener or Module.TrapHandler is set, the ID is unique,
+// as the compiled code refers to it, so can't be shared with other
+// compilations.
+func (m *Module) AssignModuleID(wasm []byte, withEnsureTermination bool) {
+	if !withEnsureTermination && m.MemoryListener == nil && m.TrapHandler == nil && !m.CanonicalizeNaN && !m.ProtectMemory && !m.MeterFuel {
+		m.ID = sha256.Sum256(wasm)
+		return
+	}
+	h := sha256.New()
+	h.Write(wasm)
+	if withEnsureTermination {
+		// Use the constant byte to differentiate the ID from the one without ensureTermination.
+		h.Write([]byte{1})
+	}
+	if m.CanonicalizeNaN {
+		h.Write([]byte{3}) // differentiates from the ensureTermination and MemoryListener bytes.
+	}
+	if m.ProtectMemory {
+		h.Write([]byte{4}) // differentiates from the bytes above.
+	}
+	if m.MeterFuel {
+		h.Write([]byte{5}) // differentiates from the bytes above.
+	}
+	if m.MemoryListener != nil || m.TrapHandler != nil {
+		var nonce [9]byte
+		nonce[0] = 2 // differentiates from the ensureTermination byte.
+		binary.LittleEndian.PutUint64(nonce[1:], atomic.AddUint64(&instrumentedModules, 1))
+		h.Write(nonce[:])
+	}
+	copy(m.ID[:], h.Sum(nil))
 }
 
 // TypeOfFunction returns the wasm.SectionIDType index for the given function namespace index or nil.
@@ -654,13 +734,33 @@ func paramNames(localNames IndirectNameMap, funcIdx uint32, paramLen int) []stri
 }
 
 func (m *Module) buildMemory() (mem *MemoryInstance) {
-	memSec := m.MemorySection
-	if memSec != nil {
-		mem = NewMemoryInstance(memSec)
+	if m.definesMemory() {
+		mem = NewMemoryInstance(m.MemorySection)
+		mem.Protectable = m.ProtectMemory
 	}
 	return
 }
 
+// NeedsSourceOffsets returns true if the offsets of instructions in the
+// binary are needed to emit stack traces with source positions.
+func (m *Module) NeedsSourceOffsets() bool {
+	return m.DWARFLines != nil || m.SourceMap != nil
+}
+
+// SourceLines returns the source positions of the instruction at the given
+// offset in the code section, from DWARF or else the source map.
+func (m *Module) SourceLines(instructionOffset uint64) []string {
+	if lines := m.DWARFLines.Line(instructionOffset); len(lines) > 0 {
+		return lines
+	}
+	return m.SourceMap.Line(instructionOffset)
+}
+
+// definesMemory returns true if the module defines its memory, as opposed to importing or sharing one.
+func (m *Module) definesMemory() bool {
+	return m.MemorySection != nil && m.HostMemory == nil
+}
+
 // Index is the offset in an index namespace, not necessarily an absolute position in a Module section. This is because
 // index namespaces are often preceded by a corresponding type in the Module.ImportSection.
 //
@@ -770,27 +870,62 @@ type Memory struct {
 	Min, Cap, Max uint32
 	// IsMaxEncoded true if the Max is encoded in the original source (binary or text).
 	IsMaxEncoded bool
+	// IsPageSizeEncoded true if PageSizeLog2 is encoded in the original source (binary), as allowed by
+	// api.CoreFeatureCustomPageSizes. Otherwise, the page size is MemoryPageSize.
+	IsPageSizeEncoded bool
+	// PageSizeLog2 is the log2 of the page size in bytes when IsPageSizeEncoded. Min, Cap and Max are in these pages.
+	PageSizeLog2 uint32
+}
+
+// PageSizeInBits returns the log2 of the page size in bytes, which is MemoryPageSizeInBits unless the page size is
+// encoded.
+func (m *Memory) PageSizeInBits() uint32 {
+	if m.IsPageSizeEncoded {
+		return m.PageSizeLog2
+	}
+	return MemoryPageSizeInBits
+}
+
+// LimitPages returns memoryLimitPages, which is in pages of MemoryPageSize, in pages of this memory. This is capped
+// to math.MaxUint32, as the page count must fit in the i32 result of "memory.size".
+func (m *Memory) LimitPages(memoryLimitPages uint32) uint32 {
+	limit := MemoryPagesToBytesNum(memoryLimitPages) >> m.PageSizeInBits()
+	if limit > math.MaxUint32 {
+		return math.MaxUint32
+	}
+	return uint32(limit)
 }
 
 // Validate ensures values assigned to Min, Cap and Max are within valid thresholds.
 func (m *Memory) Validate(memoryLimitPages uint32) error {
 	min, capacity, max := m.Min, m.Cap, m.Max
 
+	unit := PagesToUnitOfBytes
+	if m.IsPageSizeEncoded {
+		if m.PageSizeLog2 != 0 && m.PageSizeLog2 != MemoryPageSizeInBits {
+			return fmt.Errorf("invalid custom page size: 2^%d bytes", m.PageSizeLog2)
+		}
+		memoryLimitPages = m.LimitPages(memoryLimitPages)
+		unit = func(pages uint32) string {
+			return fmt.Sprintf("%d bytes", uint64(pages)<<m.PageSizeLog2)
+		}
+	}
+
 	if max > memoryLimitPages {
 		return fmt.Errorf("max %d pages (%s) over limit of %d pages (%s)",
-			max, PagesToUnitOfBytes(max), memoryLimitPages, PagesToUnitOfBytes(memoryLimitPages))
+			max, unit(max), memoryLimitPages, unit(memoryLimitPages))
 	} else if min > memoryLimitPages {
 		return fmt.Errorf("min %d pages (%s) over limit of %d pages (%s)",
-			min, PagesToUnitOfBytes(min), memoryLimitPages, PagesToUnitOfBytes(memoryLimitPages))
+			min, unit(min), memoryLimitPages, unit(memoryLimitPages))
 	} else if min > max {
 		return fmt.Errorf("min %d pages (%s) > max %d pages (%s)",
-			min, PagesToUnitOfBytes(min), max, PagesToUnitOfBytes(max))
+			min, unit(min), max, unit(max))
 	} else if capacity < min {
 		return fmt.Errorf("capacity %d pages (%s) less than minimum %d pages (%s)",
-			capacity, PagesToUnitOfBytes(capacity), min, PagesToUnitOfBytes(min))
+			capacity, unit(capacity), min, unit(min))
 	} else if capacity > memoryLimitPages {
 		return fmt.Errorf("capacity %d pages (%s) over limit of %d pages (%s)",
-			capacity, PagesToUnitOfBytes(capacity), memoryLimitPages, PagesToUnitOfBytes(memoryLimitPages))
+			capacity, unit(capacity), memoryLimitPages, unit(memoryLimitPages))
 	}
 	return nil
 }
@@ -1041,12 +1176,11 @@ func SectionIDName(sectionID SectionID) string {
 type ValueType = api.ValueType
 
 const (
-	ValueTypeI32 = api.ValueTypeI32
-	ValueTypeI64 = api.ValueTypeI64
-	ValueTypeF32 = api.ValueTypeF32
-	ValueTypeF64 = api.ValueTypeF64
-	// TODO: ValueTypeV128 is not exposed in the api pkg yet.
-	ValueTypeV128 ValueType = 0x7b
+	ValueTypeI32  = api.ValueTypeI32
+	ValueTypeI64  = api.ValueTypeI64
+	ValueTypeF32  = api.ValueTypeF32
+	ValueTypeF64  = api.ValueTypeF64
+	ValueTypeV128 = api.ValueTypeV128
 	// TODO: ValueTypeFuncref is not exposed in the api pkg yet.
 	ValueTypeFuncref   ValueType = 0x70
 	ValueTypeExternref           = api.ValueTypeExternref
@@ -1056,8 +1190,6 @@ const (
 func ValueTypeName(t ValueType) string {
 	if t == ValueTypeFuncref {
 		return "funcref"
-	} else if t == ValueTypeV128 {
-		return "v128"
 	}
 	return api.ValueTypeName(t)
 }
@@ -29,6 +29,10 @@ type Namespace struct {
 	// mux is used to guard the fields from concurrent access.
 	mux sync.RWMutex
 
+	// differential is the namespace in the differential store, when enabled.
+	// See Store.EnableDifferential.
+	differential *Namespace
+
 	// closed is the pointer used both to guard Namespace.CloseWithExitCode.
 	//
 	// Note: Exclusively reading and updating this with atomics guarantees cross-goroutine observations.
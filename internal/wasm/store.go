@@ -4,9 +4,11 @@ import (
 	"context"
 	"encoding/binary"
 	"fmt"
+	"math"
 	"sync"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
 	"github.com/tetratelabs/wazero/internal/ieee754"
 	"github.com/tetratelabs/wazero/internal/leb128"
 	internalsys "github.com/tetratelabs/wazero/internal/sys"
@@ -45,6 +47,22 @@ type (
 
 		// mux is used to guard the fields from concurrent access.
 		mux sync.RWMutex
+
+		// HostFunctionPanicPolicy controls how a panic in a host function is handled. See CallGoFunc.
+		HostFunctionPanicPolicy HostFunctionPanicPolicy
+
+		// HostFunctionPanicHandler is called on panic when HostFunctionPanicPolicy is HostFunctionPanicPolicyHandler.
+		HostFunctionPanicHandler HostFunctionPanicHandler
+
+		// CPUTimeAccounting enables CallContext.CPUTime for modules instantiated after it is set.
+		CPUTimeAccounting bool
+
+		// EventListener is notified of the lifecycle of modules instantiated after it is set, when not nil.
+		EventListener experimental.EventListener
+
+		// differential and divergenceHandler are set by EnableDifferential.
+		differential      *Store
+		divergenceHandler DivergenceHandler
 	}
 
 	// ModuleInstance represents instantiated wasm module.
@@ -80,6 +98,21 @@ type (
 		// ElementInstances holds the element instance, and each holds the references to either functions
 		// or external objects (unimplemented).
 		ElementInstances []ElementInstance
+
+		// dataSegmentSize is the total bytes of active data segments copied into Memory by applyData.
+		dataSegmentSize uint32
+
+		// source and store are the module and Store this was instantiated from, used by CallContext.Clone.
+		source *Module
+		store  *Store
+
+		// memories are the memories calls to functions of this module can write: Memory and the memories of
+		// the modules it imports from. They are touched by calls, so that a clone doesn't reuse a stale snapshot.
+		memories []*MemoryInstance
+
+		// mappedCalls is, when this is a clone whose memory is mapped, the count of calls in progress plus one
+		// until it's closed, and zero otherwise. The memory is unmapped when this drops to zero.
+		mappedCalls int32
 	}
 
 	// DataInstance holds bytes corresponding to the data segment in a module.
@@ -240,6 +273,7 @@ func (m *ModuleInstance) applyData(data []*DataSegment) error {
 				return fmt.Errorf("%s[%d]: out of bounds memory access", SectionIDName(SectionIDData), i)
 			}
 			copy(m.Memory.Buffer[offset:], d.Init)
+			m.dataSegmentSize += uint32(len(d.Init))
 		}
 	}
 	return nil
@@ -274,10 +308,13 @@ func NewStore(enabledFeatures api.CoreFeatures, engine Engine) (*Store, *Namespa
 }
 
 // NewNamespace implements the same method as documented on wazero.Runtime.
-func (s *Store) NewNamespace(context.Context) *Namespace {
+func (s *Store) NewNamespace(ctx context.Context) *Namespace {
 	ns := newNamespace()
 	s.mux.Lock()
 	defer s.mux.Unlock()
+	if s.differential != nil {
+		ns.differential = s.differential.NewNamespace(ctx)
+	}
 	s.namespaces = append(s.namespaces, ns)
 	return ns
 }
@@ -325,10 +362,41 @@ func (s *Store) Instantiate(
 			callCtx.Close(ctx)
 			return nil, err
 		}
+		if ns.differential != nil {
+			if err := s.instantiateDifferential(ctx, ns, module, name, sys, callCtx); err != nil {
+				callCtx.Close(ctx)
+				return nil, err
+			}
+		}
+		if l := s.EventListener; l != nil {
+			callCtx.events = l
+			l.OnEvent(ctx, experimental.Event{Type: experimental.EventModuleInstantiated, ModuleName: name, Module: callCtx})
+		}
 		return callCtx, nil
 	}
 }
 
+// checkResourceLimits consults the limiter before instantiating the module, including the initial sizes of the memory
+// and tables it defines.
+func checkResourceLimits(ctx context.Context, limiter experimental.ResourceLimiter, module *Module, name string) error {
+	if err := limiter.InstanceCreating(ctx, name); err != nil {
+		return err
+	}
+	if mem := module.MemorySection; module.definesMemory() && !limiter.MemoryGrowing(0, mem.Min, mem.Max) {
+		return fmt.Errorf("memory[0] min %d pages denied by resource limiter", mem.Min)
+	}
+	for i, t := range module.TableSection {
+		max := uint32(math.MaxUint32)
+		if t.Max != nil {
+			max = *t.Max
+		}
+		if !limiter.TableGrowing(0, t.Min, max) {
+			return fmt.Errorf("table[%d] min %d elements denied by resource limiter", i, t.Min)
+		}
+	}
+	return nil
+}
+
 func (s *Store) instantiate(
 	ctx context.Context,
 	ns *Namespace,
@@ -342,10 +410,21 @@ func (s *Store) instantiate(
 		return nil, err
 	}
 
+	limiter, _ := ctx.Value(experimental.ResourceLimiterKey{}).(experimental.ResourceLimiter)
+	if limiter != nil {
+		if err = checkResourceLimits(ctx, limiter, module, name); err != nil {
+			return nil, err
+		}
+	}
+
 	importedFunctions, importedGlobals, importedTables, importedMemory, err := resolveImports(module, modules)
 	if err != nil {
 		return nil, err
 	}
+	if module.HostMemory != nil {
+		// The memory shared by a host module is handled like an imported one, as the module doesn't define it.
+		importedMemory = module.HostMemory
+	}
 
 	tables, tableInit, err := module.buildTables(importedTables, importedGlobals,
 		// As of reference-types proposal, boundary check must be done after instantiation.
@@ -354,7 +433,7 @@ func (s *Store) instantiate(
 		return nil, err
 	}
 
-	m := &ModuleInstance{Name: name, TypeIDs: typeIDs}
+	m := &ModuleInstance{Name: name, TypeIDs: typeIDs, source: module, store: s}
 	functions := m.BuildFunctions(module, importedFunctions)
 
 	// Plus, we are ready to compile functions.
@@ -365,8 +444,21 @@ func (s *Store) instantiate(
 
 	globals, memory := module.buildGlobals(importedGlobals, m.Engine.FunctionInstanceReference), module.buildMemory()
 
+	// Only the memory and tables defined by this module are limited, as imported ones are limited by their module.
+	if limiter != nil {
+		if memory != nil {
+			memory.limiter = limiter
+		}
+		for _, t := range tables[len(importedTables):] {
+			t.limiter = limiter
+		}
+	}
+
 	// Now we have all instances from imports and local ones, so ready to create a new ModuleInstance.
 	m.addSections(module, importedGlobals, globals, tables, importedMemory, memory)
+	m.memories = reachableMemories(m.Memory, modules)
+	// Data segments and the start function may write the memories, even if instantiation fails.
+	defer m.touchMemories()
 
 	// As of reference types proposal, data segment validation must happen after instantiation,
 	// and the side effect must persist even if there's out of bounds error after instantiation.
@@ -389,8 +481,17 @@ func (s *Store) instantiate(
 
 	// Compile the default context for calls to this module.
 	callCtx := NewCallContext(ns, m, sysCtx)
+	callCtx.hostFunctionPanicPolicy, callCtx.hostFunctionPanicHandler = s.HostFunctionPanicPolicy, s.HostFunctionPanicHandler
+	if s.CPUTimeAccounting || (sysCtx != nil && sysCtx.ModuleCputime()) {
+		callCtx.cpuTime = &cpuTime{}
+	}
 	m.CallCtx = callCtx
 
+	// Only the memory defined by this module notifies, as an imported one notifies as its module.
+	if memory != nil {
+		s.notifyGrown(ctx, callCtx, memory)
+	}
+
 	// Execute the start function.
 	if module.StartSection != nil {
 		funcIdx := *module.StartSection
@@ -413,6 +514,53 @@ func (s *Store) instantiate(
 	return m.CallCtx, nil
 }
 
+// notifyGrown sets the memory defined by the module to notify the EventListener, if any, when grown.
+func (s *Store) notifyGrown(ctx context.Context, callCtx *CallContext, memory *MemoryInstance) {
+	if l := s.EventListener; l != nil {
+		memory.grown = func(previousPages, pages uint32) {
+			l.OnEvent(ctx, experimental.Event{
+				Type:          experimental.EventMemoryGrown,
+				ModuleName:    callCtx.Name(),
+				Module:        callCtx,
+				PreviousPages: previousPages,
+				Pages:         pages,
+			})
+		}
+	}
+}
+
+// reachableMemories returns the memory of a module, if any, and the memories of the modules it imports from, without
+// duplicates.
+func reachableMemories(memory *MemoryInstance, modules map[string]*ModuleInstance) (ret []*MemoryInstance) {
+	if memory != nil {
+		ret = append(ret, memory)
+	}
+	for _, im := range modules {
+		for _, mem := range im.memories {
+			if !containsMemory(ret, mem) {
+				ret = append(ret, mem)
+			}
+		}
+	}
+	return
+}
+
+func containsMemory(memories []*MemoryInstance, memory *MemoryInstance) bool {
+	for _, m := range memories {
+		if m == memory {
+			return true
+		}
+	}
+	return false
+}
+
+// touchMemories notes that the memories calls to functions of this module can write may have been written.
+func (m *ModuleInstance) touchMemories() {
+	for _, mem := range m.memories {
+		mem.touch()
+	}
+}
+
 func resolveImports(module *Module, modules map[string]*ModuleInstance) (
 	importedFunctions []*FunctionInstance,
 	importedGlobals []*GlobalInstance,
@@ -480,7 +628,13 @@ func resolveImports(module *Module, modules map[string]*ModuleInstance) (
 			expected := i.DescMem
 			importedMemory = m.Memory
 
-			if expected.Min > memoryBytesNumToPages(uint64(len(importedMemory.Buffer))) {
+			if expected.PageSizeInBits() != importedMemory.PageSizeInBits() {
+				err = errorInvalidImport(i, idx, fmt.Errorf("page size mismatch: %d != %d",
+					uint64(1)<<expected.PageSizeInBits(), uint64(1)<<importedMemory.PageSizeInBits()))
+				return
+			}
+
+			if expected.Min > importedMemory.PageSize() {
 				err = errorMinSizeMismatch(i, idx, expected.Min, importedMemory.Min)
 				return
 			}
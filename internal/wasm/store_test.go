@@ -118,6 +118,90 @@ func TestStore_Instantiate(t *testing.T) {
 	})
 }
 
+func TestStore_Instantiate_ResourceLimiter(t *testing.T) {
+	max := uint32(5)
+	m := &Module{
+		MemorySection: &Memory{Min: 2, Cap: 2, Max: 10},
+		TableSection:  []*Table{{Min: 1, Max: &max}},
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		s, ns := newStore()
+		limiter := &testResourceLimiter{maxMemoryPages: 2, maxTableElements: 1}
+		ctx := context.WithValue(testCtx, experimental.ResourceLimiterKey{}, experimental.ResourceLimiter(limiter))
+
+		mod, err := s.Instantiate(ctx, ns, m, "test", nil)
+		require.NoError(t, err)
+		require.Equal(t, []string{"test"}, limiter.instances)
+		require.Equal(t, [][3]uint32{{0, 2, 10}}, limiter.memoryCalls)
+		require.Equal(t, [][3]uint32{{0, 1, 5}}, limiter.tableCalls)
+
+		// The limiter remains in effect for the memory and tables of the module.
+		_, ok := mod.Memory().Grow(1)
+		require.False(t, ok)
+		require.Equal(t, uint32(0xffff_ffff), mod.module.Tables[0].Grow(1, 0))
+	})
+
+	tests := []struct {
+		name        string
+		limiter     *testResourceLimiter
+		expectedErr string
+	}{
+		{
+			name:        "instance",
+			limiter:     &testResourceLimiter{instanceErr: errors.New("too many instances")},
+			expectedErr: "too many instances",
+		},
+		{
+			name:        "memory",
+			limiter:     &testResourceLimiter{maxMemoryPages: 1, maxTableElements: 1},
+			expectedErr: "memory[0] min 2 pages denied by resource limiter",
+		},
+		{
+			name:        "table",
+			limiter:     &testResourceLimiter{maxMemoryPages: 2},
+			expectedErr: "table[0] min 1 elements denied by resource limiter",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			s, ns := newStore()
+			ctx := context.WithValue(testCtx, experimental.ResourceLimiterKey{}, experimental.ResourceLimiter(tc.limiter))
+
+			_, err := s.Instantiate(ctx, ns, m, "test", nil)
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+// testResourceLimiter records calls and vetoes growth beyond its maximums.
+type testResourceLimiter struct {
+	instanceErr                      error
+	maxMemoryPages, maxTableElements uint32
+	instances                        []string
+	memoryCalls, tableCalls          [][3]uint32
+}
+
+// InstanceCreating implements experimental.ResourceLimiter InstanceCreating
+func (l *testResourceLimiter) InstanceCreating(_ context.Context, moduleName string) error {
+	l.instances = append(l.instances, moduleName)
+	return l.instanceErr
+}
+
+// MemoryGrowing implements experimental.ResourceLimiter MemoryGrowing
+func (l *testResourceLimiter) MemoryGrowing(current, desired, maximum uint32) bool {
+	l.memoryCalls = append(l.memoryCalls, [3]uint32{current, desired, maximum})
+	return desired <= l.maxMemoryPages
+}
+
+// TableGrowing implements experimental.ResourceLimiter TableGrowing
+func (l *testResourceLimiter) TableGrowing(current, desired, maximum uint32) bool {
+	l.tableCalls = append(l.tableCalls, [3]uint32{current, desired, maximum})
+	return desired <= l.maxTableElements
+}
+
 func TestStore_CloseWithExitCode(t *testing.T) {
 	const importedModuleName = "imported"
 	const importingModuleName = "test"
@@ -345,7 +429,7 @@ func newStore() (*Store, *Namespace) {
 }
 
 // CompileModule implements the same method as documented on wasm.Engine.
-func (e *mockEngine) CompileModule(context.Context, *Module, []experimental.FunctionListener) error {
+func (e *mockEngine) CompileModule(context.Context, *Module, []experimental.FunctionListener, bool) error {
 	return nil
 }
 
@@ -756,6 +840,20 @@ func Test_resolveImports(t *testing.T) {
 			_, _, _, _, err := resolveImports(&Module{ImportSection: []*Import{{Module: moduleName, Name: name, Type: ExternTypeMemory, DescMem: importMemoryType}}}, modules)
 			require.EqualError(t, err, "import[0] memory[test.target]: maximum size mismatch: 10 < 65536")
 		})
+		t.Run("page size mismatch", func(t *testing.T) {
+			importMemoryType := &Memory{Max: 10, IsPageSizeEncoded: true}
+			modules := map[string]*ModuleInstance{
+				moduleName: {
+					Memory: &MemoryInstance{Max: 10},
+					Exports: map[string]ExportInstance{name: {
+						Type: ExternTypeMemory,
+					}},
+					Name: moduleName,
+				},
+			}
+			_, _, _, _, err := resolveImports(&Module{ImportSection: []*Import{{Module: moduleName, Name: name, Type: ExternTypeMemory, DescMem: importMemoryType}}}, modules)
+			require.EqualError(t, err, "import[0] memory[test.target]: page size mismatch: 1 != 65536")
+		})
 	})
 }
 
@@ -6,6 +6,7 @@ import (
 	"sync"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
 	"github.com/tetratelabs/wazero/internal/leb128"
 )
 
@@ -103,6 +104,9 @@ type TableInstance struct {
 
 	// mux is used to prevent overlapping calls to Grow.
 	mux sync.RWMutex
+
+	// limiter is consulted before Grow when not nil.
+	limiter experimental.ResourceLimiter
 }
 
 // ElementInstance represents an element instance in a module.
@@ -352,6 +356,8 @@ func (t *TableInstance) Grow(delta uint32, initialRef Reference) (currentLen uin
 	if newLen := int64(currentLen) + int64(delta); // adding as 64bit ints to avoid overflow.
 	newLen >= math.MaxUint32 || (t.Max != nil && newLen > int64(*t.Max)) {
 		return 0xffffffff // = -1 in signed 32-bit integer.
+	} else if t.limiter != nil && !t.limiter.TableGrowing(currentLen, uint32(newLen), t.maxOrUnbounded()) {
+		return 0xffffffff
 	}
 	t.References = append(t.References, make([]uintptr, delta)...)
 
@@ -364,3 +370,11 @@ func (t *TableInstance) Grow(delta uint32, initialRef Reference) (currentLen uin
 	}
 	return
 }
+
+// maxOrUnbounded returns Max or math.MaxUint32 if the table is unbounded.
+func (t *TableInstance) maxOrUnbounded() uint32 {
+	if t.Max != nil {
+		return *t.Max
+	}
+	return math.MaxUint32
+}
@@ -1026,6 +1026,18 @@ func TestModule_buildTable_Errors(t *testing.T) {
 	}
 }
 
+func TestTableInstance_Grow_Limiter(t *testing.T) {
+	limiter := &testResourceLimiter{maxTableElements: 3}
+	table := &TableInstance{limiter: limiter}
+
+	require.Equal(t, uint32(0), table.Grow(2, 0))
+
+	// Vetoed by the limiter.
+	require.Equal(t, uint32(0xffff_ffff), table.Grow(2, 0))
+	require.Equal(t, 2, len(table.References))
+	require.Equal(t, [][3]uint32{{0, 2, math.MaxUint32}, {2, 4, math.MaxUint32}}, limiter.tableCalls)
+}
+
 func TestTableInstance_Grow(t *testing.T) {
 	expOnErr := uint32(0xffff_ffff) // -1 as signed i32.
 	max10 := uint32(10)
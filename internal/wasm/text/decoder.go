@@ -0,0 +1,964 @@
+package text
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tetratelabs/wazero/internal/leb128"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// DecodeModule parses the WebAssembly Text Format (%.wat) into a wasm.Module, whose NameSection includes the
+// identifiers in the source, e.g. "$main".
+//
+// The result isn't validated, e.g. a function body may not be well-typed. Encode it in the binary format and decode
+// that to validate it.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#text-format%E2%91%A0
+func DecodeModule(source []byte) (*wasm.Module, error) {
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := parseSExprs(tokens)
+	if err != nil {
+		return nil, err
+	}
+	return decodeModule(nodes)
+}
+
+// decodeModule decodes either a single "(module ...)" or its fields.
+func decodeModule(nodes []*node) (*wasm.Module, error) {
+	p := &moduleParser{m: &wasm.Module{}}
+	for i := range p.ids {
+		p.ids[i] = map[string]wasm.Index{}
+	}
+
+	fields := nodes
+	if len(nodes) > 0 && isList(nodes[0], "module") {
+		if len(nodes) > 1 {
+			return nil, nodes[1].errorf("unexpected %s after module", describe(nodes[1]))
+		}
+		fields = nodes[0].list[1:]
+		if len(fields) > 0 && fields[0].typ == tokenID {
+			p.names.ModuleName = fields[0].text
+			fields = fields[1:]
+		}
+	}
+	for _, f := range fields {
+		if !f.isList() {
+			return nil, f.errorf("unexpected %s", describe(f))
+		}
+	}
+
+	if err := p.declare(fields); err != nil {
+		return nil, err
+	}
+	if err := p.define(fields); err != nil {
+		return nil, err
+	}
+
+	if p.usesDataCount {
+		count := uint32(len(p.m.DataSection))
+		p.m.DataCountSection = &count
+	}
+	if p.names.ModuleName != "" || len(p.names.FunctionNames) > 0 || len(p.names.LocalNames) > 0 {
+		p.m.NameSection = &p.names
+	}
+	return p.m, nil
+}
+
+// indexSpace is a kind of definition which is referenced by index, e.g. "call 1" or "call $main".
+type indexSpace byte
+
+const (
+	spaceType indexSpace = iota
+	spaceFunc
+	spaceTable
+	spaceMemory
+	spaceGlobal
+	spaceElem
+	spaceData
+	spaceCount
+)
+
+var spaceNames = [spaceCount]string{"type", "func", "table", "memory", "global", "elem", "data"}
+
+// externSpaces are the index spaces of the definitions which can be imported or exported.
+var externSpaces = map[string]struct {
+	space      indexSpace
+	externType wasm.ExternType
+}{
+	wasm.ExternTypeFuncName:   {spaceFunc, wasm.ExternTypeFunc},
+	wasm.ExternTypeTableName:  {spaceTable, wasm.ExternTypeTable},
+	wasm.ExternTypeMemoryName: {spaceMemory, wasm.ExternTypeMemory},
+	wasm.ExternTypeGlobalName: {spaceGlobal, wasm.ExternTypeGlobal},
+}
+
+var valueTypes = map[string]wasm.ValueType{
+	"i32":       wasm.ValueTypeI32,
+	"i64":       wasm.ValueTypeI64,
+	"f32":       wasm.ValueTypeF32,
+	"f64":       wasm.ValueTypeF64,
+	"v128":      wasm.ValueTypeV128,
+	"funcref":   wasm.ValueTypeFuncref,
+	"externref": wasm.ValueTypeExternref,
+}
+
+var refTypes = map[string]wasm.RefType{
+	"funcref":   wasm.RefTypeFuncref,
+	"externref": wasm.RefTypeExternref,
+	"anyfunc":   wasm.RefTypeFuncref, // legacy name of funcref
+}
+
+// moduleParser builds a wasm.Module from the fields of a module in two passes: declare assigns the indices of
+// identifiers, so that define can resolve those used before their definition, e.g. "call $f" above "(func $f)".
+type moduleParser struct {
+	m *wasm.Module
+	// ids map the identifiers of each index space to their index, e.g. ids[spaceFunc]["main"].
+	ids [spaceCount]map[string]wasm.Index
+	// counts are the sizes of each index space, as declared.
+	counts [spaceCount]uint32
+	// defined are the sizes of each index space, as defined so far.
+	defined [spaceCount]uint32
+	names   wasm.NameSection
+	// usesDataCount is true when an instruction requires the data count section, e.g. "memory.init".
+	usesDataCount bool
+}
+
+// declare assigns the indices of the fields to their identifiers, and adds the explicit types, which implicit types
+// are added after.
+func (p *moduleParser) declare(fields []*node) error {
+	var defined bool // a function, table, memory or global which isn't imported, which imports can't follow.
+	for _, f := range fields {
+		id := fieldID(f)
+		switch kw := f.head(); kw {
+		case "type":
+			if err := p.typeDef(f); err != nil {
+				return err
+			}
+			if err := p.declareID(spaceType, id, f); err != nil {
+				return err
+			}
+		case "import":
+			if len(f.list) != 4 || !isName(f.list[1]) || !isName(f.list[2]) {
+				return f.errorf("expected (import \"module\" \"name\" desc)")
+			}
+			desc := f.list[3]
+			e, ok := externSpaces[desc.head()]
+			if !ok {
+				return desc.errorf("unexpected %s in import", describe(desc))
+			}
+			if defined {
+				return f.errorf("import after definition")
+			}
+			if err := p.declareID(e.space, fieldID(desc), desc); err != nil {
+				return err
+			}
+		case wasm.ExternTypeFuncName, wasm.ExternTypeTableName, wasm.ExternTypeMemoryName, wasm.ExternTypeGlobalName:
+			rest := skipExports(fieldRest(f))
+			if len(rest) > 0 && isList(rest[0], "import") {
+				if defined {
+					return rest[0].errorf("import after definition")
+				}
+			} else {
+				defined = true
+			}
+			if err := p.declareID(externSpaces[kw].space, id, f); err != nil {
+				return err
+			}
+			// An inline element or data segment, e.g. "(table funcref (elem $f))", is defined by the table or memory.
+			if kw == wasm.ExternTypeTableName && len(rest) == 2 && isList(rest[1], "elem") ||
+				kw == wasm.ExternTypeMemoryName && len(rest) == 1 && isList(rest[0], "data") {
+				space := spaceElem
+				if kw == wasm.ExternTypeMemoryName {
+					space = spaceData
+				}
+				if err := p.declareID(space, "", f); err != nil {
+					return err
+				}
+			}
+		case "elem":
+			if _, ok := p.ids[spaceTable][id]; ok { // legacy table index, e.g. "(elem $t (i32.const 0) $f)"
+				id = ""
+			}
+			if err := p.declareID(spaceElem, id, f); err != nil {
+				return err
+			}
+		case "data":
+			if _, ok := p.ids[spaceMemory][id]; ok { // legacy memory index, e.g. "(data $m (i32.const 0) "hi")"
+				id = ""
+			}
+			if err := p.declareID(spaceData, id, f); err != nil {
+				return err
+			}
+		case "export", "start":
+		default:
+			return f.errorf("unexpected %s in module", describe(f))
+		}
+	}
+	return nil
+}
+
+// declareID assigns the next index of the space to the identifier, which is empty if the definition has none.
+func (p *moduleParser) declareID(space indexSpace, id string, n *node) error {
+	idx := p.counts[space]
+	p.counts[space]++
+	if id == "" {
+		return nil
+	}
+	if _, ok := p.ids[space][id]; ok {
+		return n.errorf("duplicate %s $%s", spaceNames[space], id)
+	}
+	p.ids[space][id] = idx
+	if space == spaceFunc {
+		p.names.FunctionNames = append(p.names.FunctionNames, &wasm.NameAssoc{Index: idx, Name: id})
+	}
+	return nil
+}
+
+// define adds the fields to the module in the order of the source.
+func (p *moduleParser) define(fields []*node) (err error) {
+	for _, f := range fields {
+		switch kw := f.head(); kw {
+		case "type":
+		case "import":
+			desc := f.list[3]
+			err = p.defineImport(desc, desc.head(), f.list[1].text, f.list[2].text, fieldRest(desc))
+		case wasm.ExternTypeFuncName, wasm.ExternTypeTableName, wasm.ExternTypeMemoryName, wasm.ExternTypeGlobalName:
+			err = p.defineExtern(f, kw)
+		case "export":
+			err = p.defineExport(f)
+		case "start":
+			err = p.defineStart(f)
+		case "elem":
+			err = p.defineElem(f)
+		case "data":
+			err = p.defineData(f)
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// defineExtern defines a function, table, memory or global, which can be imported and exported inline, e.g.
+// "(func (export "main") ...)".
+func (p *moduleParser) defineExtern(f *node, kw string) error {
+	e := externSpaces[kw]
+	idx := p.defined[e.space]
+
+	rest := fieldRest(f)
+	for ; len(rest) > 0 && isList(rest[0], "export"); rest = rest[1:] {
+		name, err := exportName(rest[0])
+		if err != nil {
+			return err
+		}
+		p.m.ExportSection = append(p.m.ExportSection, &wasm.Export{Type: e.externType, Name: name, Index: idx})
+	}
+
+	if len(rest) > 0 && isList(rest[0], "import") {
+		imp := rest[0]
+		if len(imp.list) != 3 || !isName(imp.list[1]) || !isName(imp.list[2]) {
+			return imp.errorf("expected (import \"module\" \"name\")")
+		}
+		return p.defineImport(f, kw, imp.list[1].text, imp.list[2].text, rest[1:])
+	}
+
+	p.defined[e.space]++
+	switch kw {
+	case wasm.ExternTypeFuncName:
+		return p.defineFunc(f, idx, rest)
+	case wasm.ExternTypeTableName:
+		return p.defineTable(f, rest)
+	case wasm.ExternTypeMemoryName:
+		return p.defineMemory(f, rest)
+	default: // global
+		gt, rest, err := globalType(f, rest)
+		if err != nil {
+			return err
+		}
+		init, err := p.constExpr(f, rest)
+		if err != nil {
+			return err
+		}
+		p.m.GlobalSection = append(p.m.GlobalSection, &wasm.Global{Type: gt, Init: init})
+		return nil
+	}
+}
+
+// defineImport defines an import of the kind, e.g. "func", from the nodes describing its type.
+func (p *moduleParser) defineImport(parent *node, kind, module, name string, nodes []*node) (err error) {
+	e := externSpaces[kind]
+	p.defined[e.space]++
+
+	imp := &wasm.Import{Type: e.externType, Module: module, Name: name}
+	var rest []*node
+	switch kind {
+	case wasm.ExternTypeFuncName:
+		imp.DescFunc, _, rest, err = p.typeUse(nodes)
+	case wasm.ExternTypeTableName:
+		imp.DescTable, rest, err = tableType(parent, nodes)
+	case wasm.ExternTypeMemoryName:
+		imp.DescMem, rest, err = memoryType(parent, nodes)
+	default: // global
+		imp.DescGlobal, rest, err = globalType(parent, nodes)
+	}
+	if err != nil {
+		return
+	}
+	if err = expectEnd(rest); err != nil {
+		return
+	}
+	p.m.ImportSection = append(p.m.ImportSection, imp)
+	return
+}
+
+// defineExport defines an export, e.g. "(export "main" (func $main))".
+func (p *moduleParser) defineExport(f *node) error {
+	if len(f.list) != 3 || !isName(f.list[1]) {
+		return f.errorf("expected (export \"name\" desc)")
+	}
+	desc := f.list[2]
+	e, ok := externSpaces[desc.head()]
+	if !ok || len(desc.list) != 2 {
+		return desc.errorf("unexpected %s in export", describe(desc))
+	}
+	idx, err := p.index(e.space, desc.list[1])
+	if err != nil {
+		return err
+	}
+	p.m.ExportSection = append(p.m.ExportSection, &wasm.Export{Type: e.externType, Name: f.list[1].text, Index: idx})
+	return nil
+}
+
+// defineStart defines the start function, e.g. "(start $main)".
+func (p *moduleParser) defineStart(f *node) error {
+	if len(f.list) != 2 {
+		return f.errorf("expected (start funcidx)")
+	}
+	if p.m.StartSection != nil {
+		return f.errorf("multiple start sections")
+	}
+	idx, err := p.index(spaceFunc, f.list[1])
+	if err != nil {
+		return err
+	}
+	p.m.StartSection = &idx
+	return nil
+}
+
+// defineFunc defines a function from its type use, locals and body.
+func (p *moduleParser) defineFunc(f *node, idx wasm.Index, nodes []*node) error {
+	typeIdx, paramIDs, rest, err := p.typeUse(nodes)
+	if err != nil {
+		return err
+	}
+
+	fp := &funcParser{p: p, localIDs: map[string]wasm.Index{}}
+	localIDs := paramIDs
+	var localTypes []wasm.ValueType
+	for ; len(rest) > 0 && isList(rest[0], "local"); rest = rest[1:] {
+		id, types, err := valueTypeList(rest[0])
+		if err != nil {
+			return err
+		}
+		localTypes = append(localTypes, types...)
+		if id != "" {
+			localIDs = append(localIDs, id)
+		} else {
+			localIDs = append(localIDs, make([]string, len(types))...)
+		}
+	}
+
+	var localNames wasm.NameMap
+	for i, id := range localIDs {
+		if id == "" {
+			continue
+		}
+		if _, ok := fp.localIDs[id]; ok {
+			return f.errorf("duplicate local $%s", id)
+		}
+		fp.localIDs[id] = wasm.Index(i)
+		localNames = append(localNames, &wasm.NameAssoc{Index: wasm.Index(i), Name: id})
+	}
+	if localNames != nil {
+		p.names.LocalNames = append(p.names.LocalNames, &wasm.NameMapAssoc{Index: idx, NameMap: localNames})
+	}
+
+	if err = fp.instrs(rest); err != nil {
+		return err
+	}
+	if len(fp.labels) > 0 {
+		return f.errorf("missing end of %s", wasm.InstructionName(fp.labels[len(fp.labels)-1].op))
+	}
+	fp.body = append(fp.body, wasm.OpcodeEnd)
+
+	p.m.FunctionSection = append(p.m.FunctionSection, typeIdx)
+	p.m.CodeSection = append(p.m.CodeSection, &wasm.Code{LocalTypes: localTypes, Body: fp.body})
+	return nil
+}
+
+// defineTable defines a table from its type, or from its reference type and the elements which initialize it, e.g.
+// "(table funcref (elem $f $g))".
+func (p *moduleParser) defineTable(f *node, nodes []*node) error {
+	if len(nodes) != 2 || !isList(nodes[1], "elem") {
+		t, rest, err := tableType(f, nodes)
+		if err != nil {
+			return err
+		}
+		if err = expectEnd(rest); err != nil {
+			return err
+		}
+		p.m.TableSection = append(p.m.TableSection, t)
+		return nil
+	}
+
+	refType, ok := refTypes[nodes[0].text]
+	if nodes[0].typ != tokenKeyword || !ok {
+		return nodes[0].errorf("expected reference type, but was %s", describe(nodes[0]))
+	}
+	elems := nodes[1].list[1:]
+	var init []*wasm.Index
+	var err error
+	if len(elems) > 0 && elems[0].isList() {
+		init, err = p.elemExprs(refType, elems)
+	} else {
+		init, err = p.funcIndices(elems)
+	}
+	if err != nil {
+		return err
+	}
+
+	size := uint32(len(init))
+	p.m.TableSection = append(p.m.TableSection, &wasm.Table{Min: size, Max: &size, Type: refType})
+	p.defined[spaceElem]++
+	p.m.ElementSection = append(p.m.ElementSection, &wasm.ElementSegment{
+		OffsetExpr: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0}},
+		TableIndex: p.defined[spaceTable] - 1,
+		Init:       init,
+		Type:       refType,
+		Mode:       wasm.ElementModeActive,
+	})
+	return nil
+}
+
+// defineMemory defines a memory from its type, or from the data which initializes it, e.g. "(memory (data "hi"))".
+func (p *moduleParser) defineMemory(f *node, nodes []*node) error {
+	if p.m.MemorySection != nil {
+		return f.errorf("multiple memories")
+	}
+	if len(nodes) != 1 || !isList(nodes[0], "data") {
+		mem, rest, err := memoryType(f, nodes)
+		if err != nil {
+			return err
+		}
+		if err = expectEnd(rest); err != nil {
+			return err
+		}
+		p.m.MemorySection = mem
+		return nil
+	}
+
+	init, err := dataStrings(nodes[0].list[1:])
+	if err != nil {
+		return err
+	}
+	size := uint32((uint64(len(init)) + uint64(wasm.MemoryPageSize) - 1) / uint64(wasm.MemoryPageSize))
+	p.m.MemorySection = &wasm.Memory{Min: size, Cap: size, Max: size, IsMaxEncoded: true}
+	p.defined[spaceData]++
+	p.m.DataSection = append(p.m.DataSection, &wasm.DataSegment{
+		OffsetExpression: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0}},
+		Init:             init,
+	})
+	return nil
+}
+
+// defineElem defines an element segment, e.g. "(elem (i32.const 0) $f $g)" or "(elem declare func $f)".
+func (p *moduleParser) defineElem(f *node) (err error) {
+	seg := &wasm.ElementSegment{Mode: wasm.ElementModePassive}
+	rest := p.segmentRest(f, spaceElem)
+
+	if len(rest) > 0 && rest[0].isKeyword("declare") {
+		seg.Mode = wasm.ElementModeDeclarative
+		rest = rest[1:]
+	} else {
+		hasTable := false
+		if len(rest) > 0 && isList(rest[0], "table") {
+			if len(rest[0].list) != 2 {
+				return rest[0].errorf("expected (table tableidx)")
+			}
+			if seg.TableIndex, err = p.index(spaceTable, rest[0].list[1]); err != nil {
+				return
+			}
+			hasTable, rest = true, rest[1:]
+		} else if len(rest) > 0 && isIndex(rest[0]) { // legacy abbreviation of (table x)
+			if seg.TableIndex, err = p.index(spaceTable, rest[0]); err != nil {
+				return
+			}
+			hasTable, rest = true, rest[1:]
+		}
+		if len(rest) > 0 && rest[0].isList() {
+			seg.Mode = wasm.ElementModeActive
+			if seg.OffsetExpr, err = p.offset(rest[0]); err != nil {
+				return
+			}
+			rest = rest[1:]
+		} else if hasTable {
+			return f.errorf("missing offset of elem")
+		}
+	}
+
+	switch {
+	case len(rest) > 0 && rest[0].isKeyword("func"):
+		seg.Type = wasm.RefTypeFuncref
+		seg.Init, err = p.funcIndices(rest[1:])
+	case len(rest) > 0 && rest[0].typ == tokenKeyword && refTypes[rest[0].text] != 0:
+		seg.Type = refTypes[rest[0].text]
+		seg.Init, err = p.elemExprs(seg.Type, rest[1:])
+	case seg.Mode == wasm.ElementModeActive: // legacy abbreviation of "func" followed by indices
+		seg.Type = wasm.RefTypeFuncref
+		seg.Init, err = p.funcIndices(rest)
+	default:
+		return f.errorf("missing element type")
+	}
+	if err != nil {
+		return
+	}
+	p.m.ElementSection = append(p.m.ElementSection, seg)
+	return
+}
+
+// defineData defines a data segment, e.g. "(data (i32.const 8) "hello")" or "(data $passive "hello")".
+func (p *moduleParser) defineData(f *node) (err error) {
+	seg := &wasm.DataSegment{}
+	rest := p.segmentRest(f, spaceData)
+
+	hasMemory := false
+	if len(rest) > 0 && isList(rest[0], "memory") {
+		if len(rest[0].list) != 2 {
+			return rest[0].errorf("expected (memory memidx)")
+		}
+		if _, err = p.index(spaceMemory, rest[0].list[1]); err != nil {
+			return
+		}
+		hasMemory, rest = true, rest[1:]
+	} else if len(rest) > 0 && isIndex(rest[0]) {
+		if _, err = p.index(spaceMemory, rest[0]); err != nil {
+			return
+		}
+		hasMemory, rest = true, rest[1:]
+	}
+	if len(rest) > 0 && rest[0].isList() {
+		if seg.OffsetExpression, err = p.offset(rest[0]); err != nil {
+			return
+		}
+		rest = rest[1:]
+	} else if hasMemory {
+		return f.errorf("missing offset of data")
+	}
+
+	if seg.Init, err = dataStrings(rest); err != nil {
+		return
+	}
+	p.m.DataSection = append(p.m.DataSection, seg)
+	return
+}
+
+// segmentRest returns the nodes after the keyword and identifier of an element or data segment, unless declare found
+// the identifier to be that of a table or memory, which is a legacy index after the keyword.
+func (p *moduleParser) segmentRest(f *node, space indexSpace) []*node {
+	idx := p.defined[space]
+	p.defined[space]++
+	if id := fieldID(f); id != "" {
+		if i, ok := p.ids[space][id]; !ok || i != idx {
+			return f.list[1:]
+		}
+	}
+	return fieldRest(f)
+}
+
+// offset returns the offset of an active segment, which is either "(offset instr)" or an abbreviated "(instr)".
+func (p *moduleParser) offset(n *node) (*wasm.ConstantExpression, error) {
+	if isList(n, "offset") {
+		return p.constExpr(n, n.list[1:])
+	}
+	return p.constExpr(n, []*node{n})
+}
+
+// constExpr returns the constant expression of the nodes, which must be a single constant instruction.
+func (p *moduleParser) constExpr(parent *node, nodes []*node) (*wasm.ConstantExpression, error) {
+	fp := &funcParser{p: p}
+	if err := fp.instrs(nodes); err != nil {
+		return nil, err
+	}
+	if fp.count != 1 {
+		return nil, parent.errorf("constant expression must be a single instruction, but had %d", fp.count)
+	}
+	expr := &wasm.ConstantExpression{Opcode: fp.body[0], Data: fp.body[1:]}
+	switch expr.Opcode {
+	case wasm.OpcodeI32Const, wasm.OpcodeI64Const, wasm.OpcodeF32Const, wasm.OpcodeF64Const,
+		wasm.OpcodeGlobalGet, wasm.OpcodeRefNull, wasm.OpcodeRefFunc:
+	case wasm.OpcodeVecPrefix:
+		if fp.body[1] == wasm.OpcodeVecV128Const {
+			expr.Opcode, expr.Data = wasm.OpcodeVecV128Const, fp.body[2:]
+			break
+		}
+		fallthrough
+	default:
+		return nil, parent.errorf("non-constant instruction in constant expression")
+	}
+	return expr, nil
+}
+
+// funcIndices resolves the nodes as function indices of an element segment.
+func (p *moduleParser) funcIndices(nodes []*node) ([]*wasm.Index, error) {
+	ret := make([]*wasm.Index, 0, len(nodes))
+	for _, n := range nodes {
+		idx, err := p.index(spaceFunc, n)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, &idx)
+	}
+	return ret, nil
+}
+
+// elemExprs returns the items of an element segment, which are either "(item instr)" or an abbreviated "(instr)".
+// A nil item is "ref.null".
+func (p *moduleParser) elemExprs(refType wasm.RefType, nodes []*node) ([]*wasm.Index, error) {
+	ret := make([]*wasm.Index, 0, len(nodes))
+	for _, n := range nodes {
+		var expr *wasm.ConstantExpression
+		var err error
+		if isList(n, "item") {
+			expr, err = p.constExpr(n, n.list[1:])
+		} else if n.isList() {
+			expr, err = p.constExpr(n, []*node{n})
+		} else {
+			return nil, n.errorf("expected element expression, but was %s", describe(n))
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch expr.Opcode {
+		case wasm.OpcodeRefFunc:
+			idx, _, _ := leb128.LoadUint32(expr.Data)
+			ret = append(ret, &idx)
+		case wasm.OpcodeRefNull:
+			// A null of another type can't be represented, so is invalid here rather than when validated.
+			if expr.Data[0] != refType {
+				return nil, n.errorf("type mismatch: ref.null %s in %s elem", wasm.RefTypeName(expr.Data[0]), wasm.RefTypeName(refType))
+			}
+			ret = append(ret, nil)
+		default:
+			return nil, n.errorf("unsupported element expression %s", wasm.InstructionName(expr.Opcode))
+		}
+	}
+	return ret, nil
+}
+
+// typeDef adds an explicit type, e.g. "(type $t (func (param i32)))".
+func (p *moduleParser) typeDef(f *node) error {
+	rest := fieldRest(f)
+	if len(rest) != 1 || !isList(rest[0], "func") {
+		return f.errorf("expected (type (func ...))")
+	}
+	ft, _, rest, err := funcType(fieldRest(rest[0]))
+	if err != nil {
+		return err
+	}
+	if err = expectEnd(rest); err != nil {
+		return err
+	}
+	p.m.TypeSection = append(p.m.TypeSection, ft)
+	return nil
+}
+
+// typeUse returns the type index of a function signature, which is an explicit "(type x)" and/or parameters and
+// results. When there's no explicit type, this adds the signature to the types unless it already exists.
+//
+// paramIDs are the identifiers of the parameters, which are empty if they have none.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#type-uses%E2%91%A0
+func (p *moduleParser) typeUse(nodes []*node) (typeIdx wasm.Index, paramIDs []string, rest []*node, err error) {
+	explicit := len(nodes) > 0 && isList(nodes[0], "type")
+	if explicit {
+		t := nodes[0]
+		if len(t.list) != 2 {
+			return 0, nil, nil, t.errorf("expected (type typeidx)")
+		}
+		if typeIdx, err = p.index(spaceType, t.list[1]); err != nil {
+			return
+		}
+		if typeIdx >= uint32(len(p.m.TypeSection)) {
+			return 0, nil, nil, t.list[1].errorf("unknown type %d", typeIdx)
+		}
+		nodes = nodes[1:]
+	}
+
+	var ft *wasm.FunctionType
+	if ft, paramIDs, rest, err = funcType(nodes); err != nil {
+		return
+	}
+	if !explicit {
+		typeIdx = p.typeIndex(ft)
+		return
+	}
+
+	if len(ft.Params) > 0 || len(ft.Results) > 0 {
+		if !p.m.TypeSection[typeIdx].EqualsSignature(ft.Params, ft.Results) {
+			return 0, nil, nil, nodes[0].errorf("signature doesn't match type %d", typeIdx)
+		}
+	} else {
+		paramIDs = make([]string, len(p.m.TypeSection[typeIdx].Params))
+	}
+	return
+}
+
+// typeIndex returns the index of the first type with the signature, adding it if there is none.
+func (p *moduleParser) typeIndex(ft *wasm.FunctionType) wasm.Index {
+	for i, t := range p.m.TypeSection {
+		if t.EqualsSignature(ft.Params, ft.Results) {
+			return wasm.Index(i)
+		}
+	}
+	p.m.TypeSection = append(p.m.TypeSection, ft)
+	return wasm.Index(len(p.m.TypeSection) - 1)
+}
+
+// funcType returns the signature of the leading "(param ...)" and "(result ...)" lists.
+func funcType(nodes []*node) (ft *wasm.FunctionType, paramIDs []string, rest []*node, err error) {
+	ft = &wasm.FunctionType{}
+	for rest = nodes; len(rest) > 0 && isList(rest[0], "param"); rest = rest[1:] {
+		id, types, err := valueTypeList(rest[0])
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		ft.Params = append(ft.Params, types...)
+		if id != "" {
+			paramIDs = append(paramIDs, id)
+		} else {
+			paramIDs = append(paramIDs, make([]string, len(types))...)
+		}
+	}
+	for ; len(rest) > 0 && isList(rest[0], "result"); rest = rest[1:] {
+		id, types, err := valueTypeList(rest[0])
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if id != "" {
+			return nil, nil, nil, rest[0].errorf("unexpected identifier $%s in result", id)
+		}
+		ft.Results = append(ft.Results, types...)
+	}
+	if len(rest) > 0 && isList(rest[0], "param") {
+		return nil, nil, nil, rest[0].errorf("param after result")
+	}
+	return
+}
+
+// valueTypeList returns the types of a list like "(param i32 i64)", or the identifier and type of one like
+// "(local $x i32)".
+func valueTypeList(n *node) (id string, types []wasm.ValueType, err error) {
+	nodes := n.list[1:]
+	if len(nodes) > 0 && nodes[0].typ == tokenID {
+		id, nodes = nodes[0].text, nodes[1:]
+		if len(nodes) != 1 {
+			return "", nil, n.errorf("expected one type after $%s", id)
+		}
+	}
+	for _, t := range nodes {
+		vt, ok := valueTypes[t.text]
+		if t.typ != tokenKeyword || !ok {
+			return "", nil, t.errorf("unknown value type %s", describe(t))
+		}
+		types = append(types, vt)
+	}
+	return
+}
+
+// tableType returns the table of limits followed by a reference type, e.g. "1 10 funcref".
+func tableType(parent *node, nodes []*node) (*wasm.Table, []*node, error) {
+	min, max, rest, err := limits(parent, nodes)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(rest) == 0 {
+		return nil, nil, parent.errorf("missing reference type")
+	}
+	refType, ok := refTypes[rest[0].text]
+	if rest[0].typ != tokenKeyword || !ok {
+		return nil, nil, rest[0].errorf("expected reference type, but was %s", describe(rest[0]))
+	}
+	return &wasm.Table{Min: min, Max: max, Type: refType}, rest[1:], nil
+}
+
+// memoryType returns the memory of limits, optionally followed by a page size, e.g. "1 2 (pagesize 1)".
+func memoryType(parent *node, nodes []*node) (*wasm.Memory, []*node, error) {
+	min, max, rest, err := limits(parent, nodes)
+	if err != nil {
+		return nil, nil, err
+	}
+	mem := &wasm.Memory{Min: min, Cap: min, IsMaxEncoded: max != nil}
+	if len(rest) > 0 && isList(rest[0], "pagesize") {
+		ps := rest[0]
+		if len(ps.list) != 2 || ps.list[1].typ != tokenKeyword {
+			return nil, nil, ps.errorf("expected (pagesize n)")
+		}
+		size, err := parseNat(ps.list[1].text, 32)
+		if err != nil || size == 0 || size&(size-1) != 0 {
+			return nil, nil, ps.list[1].errorf("invalid page size %s", ps.list[1].text)
+		}
+		for size > 1 {
+			size >>= 1
+			mem.PageSizeLog2++
+		}
+		mem.IsPageSizeEncoded = true
+		rest = rest[1:]
+	}
+	if max != nil {
+		mem.Max = *max
+	} else {
+		mem.Max = mem.LimitPages(wasm.MemoryLimitPages)
+	}
+	return mem, rest, nil
+}
+
+// limits returns the minimum and the optional maximum, e.g. "1" or "1 10".
+func limits(parent *node, nodes []*node) (min uint32, max *uint32, rest []*node, err error) {
+	var values []uint32
+	for rest = nodes; len(values) < 2 && len(rest) > 0 && isIndex(rest[0]) && rest[0].typ == tokenKeyword; rest = rest[1:] {
+		v, err := parseNat(rest[0].text, 32)
+		if err != nil {
+			return 0, nil, nil, rest[0].errorf("invalid limit %s: %v", rest[0].text, err)
+		}
+		values = append(values, uint32(v))
+	}
+	switch len(values) {
+	case 0:
+		return 0, nil, nil, parent.errorf("missing limits")
+	case 2:
+		max = &values[1]
+	}
+	return values[0], max, rest, nil
+}
+
+// globalType returns the type of a global, e.g. "i32" or "(mut i32)".
+func globalType(parent *node, nodes []*node) (*wasm.GlobalType, []*node, error) {
+	if len(nodes) == 0 {
+		return nil, nil, parent.errorf("missing global type")
+	}
+	n, gt := nodes[0], &wasm.GlobalType{}
+	if isList(n, "mut") {
+		if len(n.list) != 2 {
+			return nil, nil, n.errorf("expected (mut valtype)")
+		}
+		n, gt.Mutable = n.list[1], true
+	}
+	vt, ok := valueTypes[n.text]
+	if n.typ != tokenKeyword || !ok {
+		return nil, nil, n.errorf("unknown value type %s", describe(n))
+	}
+	gt.ValType = vt
+	return gt, nodes[1:], nil
+}
+
+// index resolves an identifier or a numeric index in the space.
+func (p *moduleParser) index(space indexSpace, n *node) (wasm.Index, error) {
+	switch n.typ {
+	case tokenID:
+		if idx, ok := p.ids[space][n.text]; ok {
+			return idx, nil
+		}
+		return 0, n.errorf("unknown %s $%s", spaceNames[space], n.text)
+	case tokenKeyword:
+		if v, err := parseNat(n.text, 32); err == nil {
+			return wasm.Index(v), nil
+		}
+	}
+	return 0, n.errorf("expected %s index, but was %s", spaceNames[space], describe(n))
+}
+
+// isIndex returns true if the node is an identifier or starts like a numeric index.
+func isIndex(n *node) bool {
+	return n.typ == tokenID || n.typ == tokenKeyword && n.text[0] >= '0' && n.text[0] <= '9'
+}
+
+// fieldID returns the identifier following the keyword of a list, e.g. "main" for "(func $main)", or empty if there
+// is none.
+func fieldID(f *node) string {
+	if len(f.list) > 1 && f.list[1].typ == tokenID {
+		return f.list[1].text
+	}
+	return ""
+}
+
+// fieldRest returns the nodes after the keyword and identifier of a list.
+func fieldRest(f *node) []*node {
+	if fieldID(f) != "" {
+		return f.list[2:]
+	}
+	return f.list[1:]
+}
+
+// skipExports returns the nodes after any inline exports, e.g. "(export "main")".
+func skipExports(nodes []*node) []*node {
+	for len(nodes) > 0 && isList(nodes[0], "export") {
+		nodes = nodes[1:]
+	}
+	return nodes
+}
+
+// exportName returns the name of an inline export, e.g. "main" for "(export "main")".
+func exportName(n *node) (string, error) {
+	if len(n.list) != 2 || !isName(n.list[1]) {
+		return "", n.errorf("expected (export \"name\")")
+	}
+	return n.list[1].text, nil
+}
+
+// isName returns true if the node is a string of valid UTF-8, as required for the name of an import or export.
+func isName(n *node) bool {
+	return n.typ == tokenString && utf8.ValidString(n.text)
+}
+
+// dataStrings returns the concatenated strings of a data segment.
+func dataStrings(nodes []*node) ([]byte, error) {
+	var ret []byte
+	for _, n := range nodes {
+		if n.typ != tokenString {
+			return nil, n.errorf("expected string, but was %s", describe(n))
+		}
+		ret = append(ret, n.text...)
+	}
+	if ret == nil {
+		ret = []byte{}
+	}
+	return ret, nil
+}
+
+// expectEnd returns an error unless there are no remaining nodes.
+func expectEnd(rest []*node) error {
+	if len(rest) > 0 {
+		return rest[0].errorf("unexpected %s", describe(rest[0]))
+	}
+	return nil
+}
+
+// describe returns a short description of the node for errors, e.g. "$main" or "(func".
+func describe(n *node) string {
+	switch n.typ {
+	case tokenLParen:
+		if h := n.head(); h != "" {
+			return "(" + h
+		}
+		return "list"
+	case tokenID:
+		return "$" + n.text
+	case tokenString:
+		return "string"
+	}
+	return strings.TrimSpace(n.text)
+}
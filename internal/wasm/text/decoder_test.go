@@ -0,0 +1,396 @@
+package text
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+func TestDecodeModule(t *testing.T) {
+	i32, i64 := wasm.ValueTypeI32, wasm.ValueTypeI64
+	zero := uint32(0)
+	two := uint32(2)
+	const0 := &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0}}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected *wasm.Module
+	}{
+		{
+			name:     "empty",
+			input:    "(module)",
+			expected: &wasm.Module{},
+		},
+		{
+			name:     "fields without module",
+			input:    "(memory 1)",
+			expected: &wasm.Module{MemorySection: &wasm.Memory{Min: 1, Cap: 1, Max: wasm.MemoryLimitPages}},
+		},
+		{
+			name: "function names, exports and implicit types",
+			input: `(module $math
+	(import "env" "log" (func $log (param i32)))
+	(func $add (export "add") (param $x i32) (param $y i32) (result i32)
+		local.get $x
+		local.get $y
+		i32.add)
+	(func (param i32)
+		(call $log (local.get 0)))
+	(export "log" (func $log)))`,
+			expected: &wasm.Module{
+				TypeSection: []*wasm.FunctionType{
+					{Params: []wasm.ValueType{i32}},
+					{Params: []wasm.ValueType{i32, i32}, Results: []wasm.ValueType{i32}},
+				},
+				ImportSection: []*wasm.Import{
+					{Type: wasm.ExternTypeFunc, Module: "env", Name: "log", DescFunc: 0},
+				},
+				FunctionSection: []wasm.Index{1, 0},
+				CodeSection: []*wasm.Code{
+					{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Add, wasm.OpcodeEnd}},
+					{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeCall, 0, wasm.OpcodeEnd}},
+				},
+				ExportSection: []*wasm.Export{
+					{Type: wasm.ExternTypeFunc, Name: "add", Index: 1},
+					{Type: wasm.ExternTypeFunc, Name: "log", Index: 0},
+				},
+				NameSection: &wasm.NameSection{
+					ModuleName:    "math",
+					FunctionNames: wasm.NameMap{{Index: 0, Name: "log"}, {Index: 1, Name: "add"}},
+					LocalNames: wasm.IndirectNameMap{
+						{Index: 1, NameMap: wasm.NameMap{{Index: 0, Name: "x"}, {Index: 1, Name: "y"}}},
+					},
+				},
+			},
+		},
+		{
+			name: "explicit type and locals",
+			input: `(module
+	(type $t (func (param i64) (result i64)))
+	(func (type $t) (local i32 i32) (local $l i64)
+		local.get $l))`,
+			expected: &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{{Params: []wasm.ValueType{i64}, Results: []wasm.ValueType{i64}}},
+				FunctionSection: []wasm.Index{0},
+				CodeSection: []*wasm.Code{
+					{LocalTypes: []wasm.ValueType{i32, i32, i64}, Body: []byte{wasm.OpcodeLocalGet, 3, wasm.OpcodeEnd}},
+				},
+				NameSection: &wasm.NameSection{
+					LocalNames: wasm.IndirectNameMap{{Index: 0, NameMap: wasm.NameMap{{Index: 3, Name: "l"}}}},
+				},
+			},
+		},
+		{
+			name: "blocks and labels",
+			input: `(func (param i32) (result i32)
+	(block $outer (result i32)
+		(if (local.get 0)
+			(then (br $outer (i32.const 1)))
+			(else nop))
+		loop $l
+			(br_if $l (local.get 0))
+			br_table 0 1 $outer
+		end
+		i32.const 2))`,
+			expected: &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}}},
+				FunctionSection: []wasm.Index{0},
+				CodeSection: []*wasm.Code{{Body: []byte{
+					wasm.OpcodeBlock, i32,
+					wasm.OpcodeLocalGet, 0,
+					wasm.OpcodeIf, 0x40,
+					wasm.OpcodeI32Const, 1, wasm.OpcodeBr, 1,
+					wasm.OpcodeElse, wasm.OpcodeNop,
+					wasm.OpcodeEnd,
+					wasm.OpcodeLoop, 0x40,
+					wasm.OpcodeLocalGet, 0, wasm.OpcodeBrIf, 0,
+					wasm.OpcodeBrTable, 2, 0, 1, 1,
+					wasm.OpcodeEnd,
+					wasm.OpcodeI32Const, 2,
+					wasm.OpcodeEnd,
+					wasm.OpcodeEnd,
+				}}},
+			},
+		},
+		{
+			name: "multi-value block",
+			input: `(func
+	(block (result i32 i64) (i32.const 0) (i64.const 0))
+	drop drop)`,
+			expected: &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{{}, {Results: []wasm.ValueType{i32, i64}}},
+				FunctionSection: []wasm.Index{0},
+				CodeSection: []*wasm.Code{{Body: []byte{
+					wasm.OpcodeBlock, 1, wasm.OpcodeI32Const, 0, wasm.OpcodeI64Const, 0, wasm.OpcodeEnd,
+					wasm.OpcodeDrop, wasm.OpcodeDrop, wasm.OpcodeEnd,
+				}}},
+			},
+		},
+		{
+			name: "immediates",
+			input: `(func
+	i32.const -1 i64.const 0x80 f32.const 1.0 f64.const -inf drop drop drop drop
+	i32.const 0 i32.load offset=8 align=2 i64.load8_u drop drop
+	memory.size memory.grow drop
+	ref.null extern drop
+	i32.const 1 i32.const 2 i32.const 0 select (result i32) drop
+	v128.const i32x4 1 2 3 0xffffffff i8x16.extract_lane_u 15 drop)`,
+			expected: &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{{}},
+				FunctionSection: []wasm.Index{0},
+				CodeSection: []*wasm.Code{{Body: []byte{
+					wasm.OpcodeI32Const, 0x7f,
+					wasm.OpcodeI64Const, 0x80, 0x01,
+					wasm.OpcodeF32Const, 0x00, 0x00, 0x80, 0x3f,
+					wasm.OpcodeF64Const, 0, 0, 0, 0, 0, 0, 0xf0, 0xff,
+					wasm.OpcodeDrop, wasm.OpcodeDrop, wasm.OpcodeDrop, wasm.OpcodeDrop,
+					wasm.OpcodeI32Const, 0,
+					wasm.OpcodeI32Load, 1, 8,
+					wasm.OpcodeI64Load8U, 0, 0,
+					wasm.OpcodeDrop, wasm.OpcodeDrop,
+					wasm.OpcodeMemorySize, 0, wasm.OpcodeMemoryGrow, 0, wasm.OpcodeDrop,
+					wasm.OpcodeRefNull, wasm.RefTypeExternref, wasm.OpcodeDrop,
+					wasm.OpcodeI32Const, 1, wasm.OpcodeI32Const, 2, wasm.OpcodeI32Const, 0,
+					wasm.OpcodeTypedSelect, 1, i32, wasm.OpcodeDrop,
+					wasm.OpcodeVecPrefix, wasm.OpcodeVecV128Const,
+					1, 0, 0, 0, 2, 0, 0, 0, 3, 0, 0, 0, 0xff, 0xff, 0xff, 0xff,
+					wasm.OpcodeVecPrefix, wasm.OpcodeVecI8x16ExtractLaneU, 15,
+					wasm.OpcodeDrop,
+					wasm.OpcodeEnd,
+				}}},
+			},
+		},
+		{
+			name: "tables and element segments",
+			input: `(module
+	(table $t 2 funcref)
+	(table funcref (elem $f $f))
+	(func $f)
+	(elem (i32.const 0) $f)
+	(elem (table 1) (offset (i32.const 1)) func 0)
+	(elem $p funcref (ref.func $f) (ref.null func))
+	(elem declare func $f)
+	(func
+		(table.init 1 $p (i32.const 0) (i32.const 0) (i32.const 1))
+		(elem.drop $p)))`,
+			expected: &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{{}},
+				FunctionSection: []wasm.Index{0, 0},
+				CodeSection: []*wasm.Code{
+					{Body: []byte{wasm.OpcodeEnd}},
+					{Body: []byte{
+						wasm.OpcodeI32Const, 0, wasm.OpcodeI32Const, 0, wasm.OpcodeI32Const, 1,
+						wasm.OpcodeMiscPrefix, wasm.OpcodeMiscTableInit, 3, 1,
+						wasm.OpcodeMiscPrefix, wasm.OpcodeMiscElemDrop, 3,
+						wasm.OpcodeEnd,
+					}},
+				},
+				TableSection: []*wasm.Table{
+					{Min: 2, Type: wasm.RefTypeFuncref},
+					{Min: 2, Max: &two, Type: wasm.RefTypeFuncref},
+				},
+				ElementSection: []*wasm.ElementSegment{
+					{OffsetExpr: const0, TableIndex: 1, Init: []*wasm.Index{&zero, &zero}, Type: wasm.RefTypeFuncref},
+					{OffsetExpr: const0, Init: []*wasm.Index{&zero}, Type: wasm.RefTypeFuncref},
+					{
+						OffsetExpr: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{1}},
+						TableIndex: 1, Init: []*wasm.Index{&zero}, Type: wasm.RefTypeFuncref,
+					},
+					{Init: []*wasm.Index{&zero, nil}, Type: wasm.RefTypeFuncref, Mode: wasm.ElementModePassive},
+					{Init: []*wasm.Index{&zero}, Type: wasm.RefTypeFuncref, Mode: wasm.ElementModeDeclarative},
+				},
+				NameSection: &wasm.NameSection{FunctionNames: wasm.NameMap{{Index: 0, Name: "f"}}},
+			},
+		},
+		{
+			name: "memory, data and globals",
+			input: `(module
+	(global (import "env" "v") v128)
+	(memory (export "mem") 1 2 (pagesize 1))
+	(global $g (mut i32) (i32.const 8))
+	(data (i32.const 0) "a" "b")
+	(data $d "\00")
+	(func (memory.init $d (i32.const 0) (i32.const 0) (i32.const 1))))`,
+			expected: &wasm.Module{
+				TypeSection: []*wasm.FunctionType{{}},
+				ImportSection: []*wasm.Import{
+					{Type: wasm.ExternTypeGlobal, Module: "env", Name: "v", DescGlobal: &wasm.GlobalType{ValType: wasm.ValueTypeV128}},
+				},
+				FunctionSection: []wasm.Index{0},
+				CodeSection: []*wasm.Code{{Body: []byte{
+					wasm.OpcodeI32Const, 0, wasm.OpcodeI32Const, 0, wasm.OpcodeI32Const, 1,
+					wasm.OpcodeMiscPrefix, wasm.OpcodeMiscMemoryInit, 1, 0,
+					wasm.OpcodeEnd,
+				}}},
+				MemorySection: &wasm.Memory{Min: 1, Cap: 1, Max: 2, IsMaxEncoded: true, IsPageSizeEncoded: true},
+				GlobalSection: []*wasm.Global{
+					{
+						Type: &wasm.GlobalType{ValType: i32, Mutable: true},
+						Init: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{8}},
+					},
+				},
+				ExportSection: []*wasm.Export{{Type: wasm.ExternTypeMemory, Name: "mem", Index: 0}},
+				DataSection: []*wasm.DataSegment{
+					{OffsetExpression: const0, Init: []byte("ab")},
+					{Init: []byte{0}},
+				},
+				DataCountSection: &two,
+			},
+		},
+		{
+			name:  "inline data",
+			input: `(memory (data "hello"))`,
+			expected: &wasm.Module{
+				MemorySection: &wasm.Memory{Min: 1, Cap: 1, Max: 1, IsMaxEncoded: true},
+				DataSection:   []*wasm.DataSegment{{OffsetExpression: const0, Init: []byte("hello")}},
+			},
+		},
+		{
+			name:  "start",
+			input: `(module (func $main) (start $main))`,
+			expected: &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{{}},
+				FunctionSection: []wasm.Index{0},
+				CodeSection:     []*wasm.Code{{Body: []byte{wasm.OpcodeEnd}}},
+				StartSection:    &zero,
+				NameSection:     &wasm.NameSection{FunctionNames: wasm.NameMap{{Index: 0, Name: "main"}}},
+			},
+		},
+		{
+			name:  "legacy memory index of data",
+			input: `(module (memory $m 1) (data $m (i32.const 1) "a"))`,
+			expected: &wasm.Module{
+				MemorySection: &wasm.Memory{Min: 1, Cap: 1, Max: wasm.MemoryLimitPages},
+				DataSection: []*wasm.DataSegment{
+					{OffsetExpression: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{1}}, Init: []byte("a")},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := DecodeModule([]byte(tc.input))
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, m)
+		})
+	}
+}
+
+func TestDecodeModule_Errors(t *testing.T) {
+	tests := []struct {
+		name, input, expectedErr string
+	}{
+		{
+			name:        "lexer",
+			input:       "(module [)",
+			expectedErr: "1:9: unexpected character '['",
+		},
+		{
+			name:        "after module",
+			input:       "(module) (func)",
+			expectedErr: "1:10: unexpected (func after module",
+		},
+		{
+			name:        "unknown field",
+			input:       "(module (funk))",
+			expectedErr: "1:9: unexpected (funk in module",
+		},
+		{
+			name:        "import after definition",
+			input:       `(module (func) (import "m" "n" (func)))`,
+			expectedErr: "1:16: import after definition",
+		},
+		{
+			name:        "duplicate function",
+			input:       `(module (func $f) (func $f))`,
+			expectedErr: "1:19: duplicate func $f",
+		},
+		{
+			name:        "unknown function",
+			input:       `(module (func call $g))`,
+			expectedErr: "1:20: unknown func $g",
+		},
+		{
+			name:        "unknown instruction",
+			input:       `(module (func i32.foo))`,
+			expectedErr: "1:15: unknown instruction i32.foo",
+		},
+		{
+			name:        "missing immediate",
+			input:       `(module (func local.get))`,
+			expectedErr: "1:15: missing immediate of local.get",
+		},
+		{
+			name:        "unknown label",
+			input:       `(module (func block br $l end))`,
+			expectedErr: "1:24: unknown label $l",
+		},
+		{
+			name:        "missing end",
+			input:       `(module (func block))`,
+			expectedErr: "1:9: missing end of block",
+		},
+		{
+			name:        "unexpected end",
+			input:       `(module (func end))`,
+			expectedErr: "1:15: unexpected end",
+		},
+		{
+			name:        "mismatching label",
+			input:       `(module (func block $a end $b))`,
+			expectedErr: "1:28: mismatching label $b",
+		},
+		{
+			name:        "constant out of range",
+			input:       `(module (func i32.const 4294967296 drop))`,
+			expectedErr: "1:25: invalid constant 4294967296: constant out of range",
+		},
+		{
+			name:        "type mismatch",
+			input:       `(module (type (func)) (func (type 0) (param i32)))`,
+			expectedErr: "1:38: signature doesn't match type 0",
+		},
+		{
+			name:        "param after result",
+			input:       `(module (func (result i32) (param i32)))`,
+			expectedErr: "1:28: param after result",
+		},
+		{
+			name:        "non-constant expression",
+			input:       `(module (global i32 (i32.add)))`,
+			expectedErr: "1:9: non-constant instruction in constant expression",
+		},
+		{
+			name:        "multiple instructions in constant expression",
+			input:       `(module (global i32 (i32.const 1) (i32.const 2)))`,
+			expectedErr: "1:9: constant expression must be a single instruction, but had 2",
+		},
+		{
+			name:        "null of another type in element segment",
+			input:       `(module (elem funcref (ref.null extern)))`,
+			expectedErr: "1:23: type mismatch: ref.null externref in funcref elem",
+		},
+		{
+			name:        "invalid UTF-8 name",
+			input:       `(module (func (export "\ff")))`,
+			expectedErr: `1:15: expected (export "name")`,
+		},
+		{
+			name:        "named block parameter",
+			input:       `(module (func block (param $x i32) end))`,
+			expectedErr: "1:15: unexpected identifier $x of parameter",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := DecodeModule([]byte(tc.input))
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
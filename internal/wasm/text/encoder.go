@@ -0,0 +1,606 @@
+package text
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/tetratelabs/wazero/internal/leb128"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// EncodeModule encodes the module in the WebAssembly Text Format (%.wat), in the plain form of instructions.
+//
+// Functions and their locals are referenced by the identifiers in the NameSection, e.g. "call $main", unless they are
+// missing, invalid or duplicated, in which case they are referenced by index. Other definitions are referenced by
+// index, which follows their keyword as a comment, e.g. "(memory (;0;) 1)".
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#text-format%E2%91%A0
+func EncodeModule(m *wasm.Module) ([]byte, error) {
+	e := &encoder{m: m, localNames: map[wasm.Index]map[wasm.Index]string{}}
+	if m.NameSection != nil {
+		e.funcNames = idNames(m.NameSection.FunctionNames)
+		for _, l := range m.NameSection.LocalNames {
+			e.localNames[l.Index] = idNames(l.NameMap)
+		}
+	}
+
+	e.buf.WriteString("(module")
+	if m.NameSection != nil && isID(m.NameSection.ModuleName) {
+		e.buf.WriteString(" $" + m.NameSection.ModuleName)
+	}
+	for i, t := range m.TypeSection {
+		e.newline(1)
+		fmt.Fprintf(&e.buf, "(type (;%d;) (func%s))", i, signature(t, nil))
+	}
+
+	var counts [spaceCount]wasm.Index
+	for _, imp := range m.ImportSection {
+		e.newline(1)
+		fmt.Fprintf(&e.buf, "(import %s %s ", quote(imp.Module), quote(imp.Name))
+		switch imp.Type {
+		case wasm.ExternTypeFunc:
+			idx := counts[spaceFunc]
+			counts[spaceFunc]++
+			fmt.Fprintf(&e.buf, "(func %s (type %d)", e.funcID(idx), imp.DescFunc)
+			if imp.DescFunc < uint32(len(m.TypeSection)) {
+				e.buf.WriteString(signature(m.TypeSection[imp.DescFunc], e.localNames[idx]))
+			}
+		case wasm.ExternTypeTable:
+			fmt.Fprintf(&e.buf, "(table (;%d;) %s", counts[spaceTable], formatTable(imp.DescTable))
+			counts[spaceTable]++
+		case wasm.ExternTypeMemory:
+			fmt.Fprintf(&e.buf, "(memory (;%d;) %s", counts[spaceMemory], formatMemory(imp.DescMem))
+			counts[spaceMemory]++
+		case wasm.ExternTypeGlobal:
+			fmt.Fprintf(&e.buf, "(global (;%d;) %s", counts[spaceGlobal], formatGlobalType(imp.DescGlobal))
+			counts[spaceGlobal]++
+		}
+		e.buf.WriteString("))")
+	}
+
+	if len(m.FunctionSection) != len(m.CodeSection) {
+		return nil, fmt.Errorf("function and code section have inconsistent lengths: %d != %d",
+			len(m.FunctionSection), len(m.CodeSection))
+	}
+	for i, typeIdx := range m.FunctionSection {
+		idx := counts[spaceFunc] + wasm.Index(i)
+		if err := e.function(idx, typeIdx, m.CodeSection[i]); err != nil {
+			return nil, fmt.Errorf("func[%d]: %w", idx, err)
+		}
+	}
+
+	for _, t := range m.TableSection {
+		e.newline(1)
+		fmt.Fprintf(&e.buf, "(table (;%d;) %s)", counts[spaceTable], formatTable(t))
+		counts[spaceTable]++
+	}
+	if mem := m.MemorySection; mem != nil {
+		e.newline(1)
+		fmt.Fprintf(&e.buf, "(memory (;%d;) %s)", counts[spaceMemory], formatMemory(mem))
+	}
+	for _, g := range m.GlobalSection {
+		e.newline(1)
+		init, err := e.constExpr(g.Init)
+		if err != nil {
+			return nil, fmt.Errorf("global[%d]: %w", counts[spaceGlobal], err)
+		}
+		fmt.Fprintf(&e.buf, "(global (;%d;) %s %s)", counts[spaceGlobal], formatGlobalType(g.Type), init)
+		counts[spaceGlobal]++
+	}
+
+	for _, exp := range m.ExportSection {
+		e.newline(1)
+		ref := strconv.FormatUint(uint64(exp.Index), 10)
+		if exp.Type == wasm.ExternTypeFunc {
+			ref = e.funcRef(exp.Index)
+		}
+		fmt.Fprintf(&e.buf, "(export %s (%s %s))", quote(exp.Name), wasm.ExternTypeName(exp.Type), ref)
+	}
+	if m.StartSection != nil {
+		e.newline(1)
+		fmt.Fprintf(&e.buf, "(start %s)", e.funcRef(*m.StartSection))
+	}
+
+	for i, seg := range m.ElementSection {
+		if err := e.elem(wasm.Index(i), seg); err != nil {
+			return nil, fmt.Errorf("elem[%d]: %w", i, err)
+		}
+	}
+	for i, seg := range m.DataSection {
+		e.newline(1)
+		fmt.Fprintf(&e.buf, "(data (;%d;)", i)
+		if seg.OffsetExpression != nil {
+			offset, err := e.constExpr(seg.OffsetExpression)
+			if err != nil {
+				return nil, fmt.Errorf("data[%d]: %w", i, err)
+			}
+			e.buf.WriteString(" " + offset)
+		}
+		e.buf.WriteString(" " + quote(string(seg.Init)) + ")")
+	}
+
+	e.buf.WriteString(")\n")
+	return e.buf.Bytes(), nil
+}
+
+// encoder writes the text format of a module, indenting nested lists and blocks by two spaces.
+type encoder struct {
+	m   *wasm.Module
+	buf bytes.Buffer
+	// funcNames are the valid and unique identifiers of functions, without the leading '$'.
+	funcNames map[wasm.Index]string
+	// localNames are the valid and unique identifiers of the parameters and locals of functions.
+	localNames map[wasm.Index]map[wasm.Index]string
+}
+
+func (e *encoder) newline(depth int) {
+	e.buf.WriteByte('\n')
+	e.buf.WriteString(strings.Repeat("  ", depth))
+}
+
+// funcID returns the identifier of a function where it is defined, e.g. "$main" or "(;1;)".
+func (e *encoder) funcID(idx wasm.Index) string {
+	if name, ok := e.funcNames[idx]; ok {
+		return "$" + name
+	}
+	return fmt.Sprintf("(;%d;)", idx)
+}
+
+// funcRef returns the reference to a function, e.g. "$main" or "1".
+func (e *encoder) funcRef(idx wasm.Index) string {
+	if name, ok := e.funcNames[idx]; ok {
+		return "$" + name
+	}
+	return strconv.FormatUint(uint64(idx), 10)
+}
+
+// function writes a function defined in the module and its body.
+func (e *encoder) function(idx, typeIdx wasm.Index, code *wasm.Code) error {
+	if typeIdx >= uint32(len(e.m.TypeSection)) {
+		return fmt.Errorf("unknown type %d", typeIdx)
+	}
+	ft := e.m.TypeSection[typeIdx]
+	names := e.localNames[idx]
+
+	e.newline(1)
+	fmt.Fprintf(&e.buf, "(func %s (type %d)%s", e.funcID(idx), typeIdx, signature(ft, names))
+	if len(code.LocalTypes) > 0 {
+		e.newline(2)
+		e.buf.WriteString(typeLists("local", code.LocalTypes, wasm.Index(len(ft.Params)), names)[1:])
+	}
+
+	r := bytes.NewReader(code.Body)
+	depth := 0
+	for r.Len() > 0 {
+		op, _ := r.ReadByte()
+		switch op {
+		case wasm.OpcodeEnd:
+			if depth == 0 {
+				if r.Len() > 0 {
+					return fmt.Errorf("unexpected instructions after end")
+				}
+				e.buf.WriteByte(')')
+				return nil
+			}
+			depth--
+			e.newline(depth + 2)
+			e.buf.WriteString(wasm.OpcodeEndName)
+		case wasm.OpcodeElse:
+			e.newline(depth + 1)
+			e.buf.WriteString(wasm.OpcodeElseName)
+		default:
+			text, err := e.instr(op, r, names)
+			if err != nil {
+				return err
+			}
+			e.newline(depth + 2)
+			e.buf.WriteString(text)
+			if op == wasm.OpcodeBlock || op == wasm.OpcodeLoop || op == wasm.OpcodeIf {
+				depth++
+			}
+		}
+	}
+	return fmt.Errorf("missing end")
+}
+
+// elem writes an element segment, whose items are function indices unless they include a null reference, e.g.
+// "(elem (;0;) (i32.const 0) func 1 2)" or "(elem (;0;) funcref (ref.func 1) (ref.null func))".
+func (e *encoder) elem(idx wasm.Index, seg *wasm.ElementSegment) error {
+	e.newline(1)
+	fmt.Fprintf(&e.buf, "(elem (;%d;)", idx)
+	switch seg.Mode {
+	case wasm.ElementModeActive:
+		if seg.TableIndex != 0 {
+			fmt.Fprintf(&e.buf, " (table %d)", seg.TableIndex)
+		}
+		offset, err := e.constExpr(seg.OffsetExpr)
+		if err != nil {
+			return err
+		}
+		e.buf.WriteString(" " + offset)
+	case wasm.ElementModeDeclarative:
+		e.buf.WriteString(" declare")
+	}
+
+	indices := seg.Type == wasm.RefTypeFuncref
+	for _, init := range seg.Init {
+		indices = indices && init != nil
+	}
+	if indices {
+		e.buf.WriteString(" func")
+		for _, init := range seg.Init {
+			e.buf.WriteString(" " + e.funcRef(*init))
+		}
+	} else {
+		e.buf.WriteString(" " + wasm.RefTypeName(seg.Type))
+		null := "(ref.null func)"
+		if seg.Type == wasm.RefTypeExternref {
+			null = "(ref.null extern)"
+		}
+		for _, init := range seg.Init {
+			if init == nil {
+				e.buf.WriteString(" " + null)
+			} else {
+				fmt.Fprintf(&e.buf, " (ref.func %s)", e.funcRef(*init))
+			}
+		}
+	}
+	e.buf.WriteByte(')')
+	return nil
+}
+
+// constExpr returns the folded instruction of a constant expression, e.g. "(i32.const 1)".
+func (e *encoder) constExpr(expr *wasm.ConstantExpression) (string, error) {
+	op, data := expr.Opcode, expr.Data
+	if op == wasm.OpcodeVecV128Const {
+		op, data = wasm.OpcodeVecPrefix, append([]byte{wasm.OpcodeVecV128Const}, data...)
+	}
+	r := bytes.NewReader(data)
+	text, err := e.instr(op, r, nil)
+	if err != nil {
+		return "", err
+	} else if r.Len() > 0 {
+		return "", fmt.Errorf("invalid constant expression")
+	}
+	return "(" + text + ")", nil
+}
+
+// instr returns the text of the instruction of the opcode, reading its immediates.
+func (e *encoder) instr(op wasm.Opcode, r *bytes.Reader, localNames map[wasm.Index]string) (string, error) {
+	in := instruction{op: op}
+	if op == wasm.OpcodeMiscPrefix || op == wasm.OpcodeVecPrefix {
+		sub, _, err := leb128.DecodeUint32(r)
+		if err != nil || sub > math.MaxUint8 {
+			return "", fmt.Errorf("invalid opcode after prefix %#x", op)
+		}
+		in = instruction{prefix: op, op: byte(sub)}
+	}
+	name := in.name()
+	if name == "" {
+		return "", fmt.Errorf("unknown opcode %#x", op)
+	}
+
+	ir := &immediateReader{r: r}
+	var sb strings.Builder
+	sb.WriteString(name)
+	switch in.prefix {
+	case 0:
+		e.immediates(&sb, in, ir, localNames)
+	case wasm.OpcodeMiscPrefix:
+		switch in.op {
+		case wasm.OpcodeMiscMemoryInit:
+			fmt.Fprintf(&sb, " %d", ir.u32())
+			ir.byte() // memory index
+		case wasm.OpcodeMiscDataDrop, wasm.OpcodeMiscElemDrop:
+			fmt.Fprintf(&sb, " %d", ir.u32())
+		case wasm.OpcodeMiscMemoryCopy:
+			ir.byte()
+			ir.byte()
+		case wasm.OpcodeMiscMemoryFill:
+			ir.byte()
+		case wasm.OpcodeMiscTableInit:
+			elemIdx, tableIdx := ir.u32(), ir.u32()
+			if tableIdx != 0 {
+				fmt.Fprintf(&sb, " %d", tableIdx)
+			}
+			fmt.Fprintf(&sb, " %d", elemIdx)
+		case wasm.OpcodeMiscTableCopy:
+			if dst, src := ir.u32(), ir.u32(); dst != 0 || src != 0 {
+				fmt.Fprintf(&sb, " %d %d", dst, src)
+			}
+		case wasm.OpcodeMiscTableGrow, wasm.OpcodeMiscTableSize, wasm.OpcodeMiscTableFill:
+			if tableIdx := ir.u32(); tableIdx != 0 {
+				fmt.Fprintf(&sb, " %d", tableIdx)
+			}
+		}
+	default: // wasm.OpcodeVecPrefix
+		if natural, ok := in.naturalAlignment(); ok {
+			writeMemarg(&sb, ir, natural)
+		}
+		if in.hasLane() {
+			fmt.Fprintf(&sb, " %d", ir.byte())
+		}
+		switch in.op {
+		case wasm.OpcodeVecV128Const:
+			sb.WriteString(" i32x4")
+			for i := 0; i < 4; i++ {
+				v := uint32(ir.byte()) | uint32(ir.byte())<<8 | uint32(ir.byte())<<16 | uint32(ir.byte())<<24
+				fmt.Fprintf(&sb, " 0x%08x", v)
+			}
+		case wasm.OpcodeVecV128i8x16Shuffle:
+			for i := 0; i < 16; i++ {
+				fmt.Fprintf(&sb, " %d", ir.byte())
+			}
+		}
+	}
+	if ir.err != nil {
+		return "", fmt.Errorf("read immediate of %s: %w", name, ir.err)
+	}
+	return sb.String(), nil
+}
+
+func (e *encoder) immediates(sb *strings.Builder, in instruction, ir *immediateReader, localNames map[wasm.Index]string) {
+	switch in.op {
+	case wasm.OpcodeBlock, wasm.OpcodeLoop, wasm.OpcodeIf:
+		switch bt := ir.s33(); {
+		case bt >= 0:
+			fmt.Fprintf(sb, " (type %d)", bt)
+		case bt != -0x40: // a value type, e.g. -0x01 is 0x7f, which is i32
+			fmt.Fprintf(sb, " (result %s)", wasm.ValueTypeName(wasm.ValueType(bt&0x7f)))
+		}
+	case wasm.OpcodeBr, wasm.OpcodeBrIf:
+		fmt.Fprintf(sb, " %d", ir.u32())
+	case wasm.OpcodeBrTable:
+		count := ir.u32()
+		for i := uint32(0); i <= count && ir.err == nil; i++ { // the labels and the default
+			fmt.Fprintf(sb, " %d", ir.u32())
+		}
+	case wasm.OpcodeCall, wasm.OpcodeRefFunc:
+		sb.WriteString(" " + e.funcRef(ir.u32()))
+	case wasm.OpcodeCallIndirect:
+		typeIdx, tableIdx := ir.u32(), ir.u32()
+		if tableIdx != 0 {
+			fmt.Fprintf(sb, " %d", tableIdx)
+		}
+		fmt.Fprintf(sb, " (type %d)", typeIdx)
+	case wasm.OpcodeLocalGet, wasm.OpcodeLocalSet, wasm.OpcodeLocalTee:
+		idx := ir.u32()
+		if name, ok := localNames[idx]; ok {
+			sb.WriteString(" $" + name)
+		} else {
+			fmt.Fprintf(sb, " %d", idx)
+		}
+	case wasm.OpcodeGlobalGet, wasm.OpcodeGlobalSet:
+		fmt.Fprintf(sb, " %d", ir.u32())
+	case wasm.OpcodeTableGet, wasm.OpcodeTableSet:
+		if tableIdx := ir.u32(); tableIdx != 0 {
+			fmt.Fprintf(sb, " %d", tableIdx)
+		}
+	case wasm.OpcodeMemorySize, wasm.OpcodeMemoryGrow:
+		ir.byte() // memory index
+	case wasm.OpcodeI32Const:
+		fmt.Fprintf(sb, " %d", ir.s32())
+	case wasm.OpcodeI64Const:
+		fmt.Fprintf(sb, " %d", ir.s64())
+	case wasm.OpcodeF32Const:
+		v := uint32(ir.byte()) | uint32(ir.byte())<<8 | uint32(ir.byte())<<16 | uint32(ir.byte())<<24
+		sb.WriteString(" " + formatFloat(uint64(v), f32Format))
+	case wasm.OpcodeF64Const:
+		var v uint64
+		for i := 0; i < 8; i++ {
+			v |= uint64(ir.byte()) << (8 * i)
+		}
+		sb.WriteString(" " + formatFloat(v, f64Format))
+	case wasm.OpcodeRefNull:
+		if ir.byte() == wasm.RefTypeExternref {
+			sb.WriteString(" extern")
+		} else {
+			sb.WriteString(" func")
+		}
+	case wasm.OpcodeTypedSelect:
+		count := ir.u32()
+		for i := uint32(0); i < count && ir.err == nil; i++ {
+			fmt.Fprintf(sb, " (result %s)", wasm.ValueTypeName(ir.byte()))
+		}
+	default:
+		if natural, ok := in.naturalAlignment(); ok {
+			writeMemarg(sb, ir, natural)
+		}
+	}
+}
+
+// writeMemarg writes the offset and alignment of a memory instruction, unless they are the defaults.
+func writeMemarg(sb *strings.Builder, ir *immediateReader, natural uint32) {
+	align, offset := ir.u32(), ir.u32()
+	if offset != 0 {
+		fmt.Fprintf(sb, " offset=%d", offset)
+	}
+	if align != natural && align < 32 {
+		fmt.Fprintf(sb, " align=%d", uint32(1)<<align)
+	}
+}
+
+// immediateReader reads the immediates of an instruction, retaining the first error.
+type immediateReader struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (ir *immediateReader) byte() byte {
+	if ir.err != nil {
+		return 0
+	}
+	b, err := ir.r.ReadByte()
+	ir.err = err
+	return b
+}
+
+func (ir *immediateReader) u32() uint32 {
+	if ir.err != nil {
+		return 0
+	}
+	v, _, err := leb128.DecodeUint32(ir.r)
+	ir.err = err
+	return v
+}
+
+func (ir *immediateReader) s32() int32 {
+	if ir.err != nil {
+		return 0
+	}
+	v, _, err := leb128.DecodeInt32(ir.r)
+	ir.err = err
+	return v
+}
+
+func (ir *immediateReader) s33() int64 {
+	if ir.err != nil {
+		return 0
+	}
+	v, _, err := leb128.DecodeInt33AsInt64(ir.r)
+	ir.err = err
+	return v
+}
+
+func (ir *immediateReader) s64() int64 {
+	if ir.err != nil {
+		return 0
+	}
+	v, _, err := leb128.DecodeInt64(ir.r)
+	ir.err = err
+	return v
+}
+
+// signature returns the parameters and results of a function type, e.g. " (param $x i32) (result i32)".
+func signature(ft *wasm.FunctionType, paramNames map[wasm.Index]string) string {
+	ret := typeLists("param", ft.Params, 0, paramNames)
+	if len(ft.Results) > 0 {
+		ret += typeLists("result", ft.Results, 0, nil)
+	}
+	return ret
+}
+
+// typeLists returns the lists of the types, which are grouped unless they have a name, e.g.
+// " (param $x i32) (param i32 i64)". start is the index of the first type, which is non-zero for locals.
+func typeLists(keyword string, types []wasm.ValueType, start wasm.Index, names map[wasm.Index]string) string {
+	var lists, group []string
+	flush := func() {
+		if len(group) > 0 {
+			lists = append(lists, fmt.Sprintf("(%s %s)", keyword, strings.Join(group, " ")))
+			group = nil
+		}
+	}
+	for i, t := range types {
+		if name, ok := names[start+wasm.Index(i)]; ok {
+			flush()
+			lists = append(lists, fmt.Sprintf("(%s $%s %s)", keyword, name, wasm.ValueTypeName(t)))
+		} else {
+			group = append(group, wasm.ValueTypeName(t))
+		}
+	}
+	flush()
+	if len(lists) == 0 {
+		return ""
+	}
+	return " " + strings.Join(lists, " ")
+}
+
+func formatTable(t *wasm.Table) string {
+	if t.Max != nil {
+		return fmt.Sprintf("%d %d %s", t.Min, *t.Max, wasm.RefTypeName(t.Type))
+	}
+	return fmt.Sprintf("%d %s", t.Min, wasm.RefTypeName(t.Type))
+}
+
+func formatMemory(mem *wasm.Memory) string {
+	ret := strconv.FormatUint(uint64(mem.Min), 10)
+	if mem.IsMaxEncoded {
+		ret += " " + strconv.FormatUint(uint64(mem.Max), 10)
+	}
+	if mem.IsPageSizeEncoded {
+		ret += fmt.Sprintf(" (pagesize %d)", uint64(1)<<mem.PageSizeLog2)
+	}
+	return ret
+}
+
+func formatGlobalType(gt *wasm.GlobalType) string {
+	if gt.Mutable {
+		return "(mut " + wasm.ValueTypeName(gt.ValType) + ")"
+	}
+	return wasm.ValueTypeName(gt.ValType)
+}
+
+// formatFloat returns the shortest decimal which parses to the bits of the float, or "inf", "nan" or "nan:0x"
+// followed by the payload of a non-canonical NaN.
+func formatFloat(v uint64, f floatFormat) string {
+	sign := ""
+	if v>>(f.bits-1) != 0 {
+		sign = "-"
+	}
+	exponent := uint64(1)<<(f.bits-1) - 1<<f.mantissaBits // all ones
+	payload := v & (1<<f.mantissaBits - 1)
+	switch {
+	case v&exponent != exponent:
+	case payload == 0:
+		return sign + "inf"
+	case payload == 1<<(f.mantissaBits-1):
+		return sign + "nan"
+	default:
+		return fmt.Sprintf("%snan:%#x", sign, payload)
+	}
+	if f.bits == 32 {
+		return strconv.FormatFloat(float64(math.Float32frombits(uint32(v))), 'g', -1, 32)
+	}
+	return strconv.FormatFloat(math.Float64frombits(v), 'g', -1, 64)
+}
+
+// quote returns the string in quotes, escaping quotes, backslashes and bytes which aren't printable ASCII, e.g.
+// "\00asm".
+func quote(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case c >= 0x20 && c < 0x7f:
+			sb.WriteByte(c)
+		default:
+			fmt.Fprintf(&sb, "\\%02x", c)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// idNames returns the names which are valid identifiers, except those used more than once.
+func idNames(names wasm.NameMap) map[wasm.Index]string {
+	ret := make(map[wasm.Index]string, len(names))
+	uses := make(map[string]int, len(names))
+	for _, n := range names {
+		uses[n.Name]++
+	}
+	for _, n := range names {
+		if isID(n.Name) && uses[n.Name] == 1 {
+			ret[n.Index] = n.Name
+		}
+	}
+	return ret
+}
+
+// isID returns true if the name is a valid identifier, excluding the leading '$'.
+func isID(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isIDChar(name[i]) {
+			return false
+		}
+	}
+	return true
+}
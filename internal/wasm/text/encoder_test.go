@@ -0,0 +1,245 @@
+package text
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+func TestEncodeModule(t *testing.T) {
+	i32 := wasm.ValueTypeI32
+	zero, one := uint32(0), uint32(1)
+
+	tests := []struct {
+		name     string
+		input    *wasm.Module
+		expected string
+	}{
+		{
+			name:     "empty",
+			input:    &wasm.Module{},
+			expected: "(module)\n",
+		},
+		{
+			name: "names",
+			input: &wasm.Module{
+				TypeSection: []*wasm.FunctionType{
+					{Params: []wasm.ValueType{i32, i32, i32}, Results: []wasm.ValueType{i32}},
+				},
+				ImportSection: []*wasm.Import{
+					{Type: wasm.ExternTypeFunc, Module: "env", Name: "f", DescFunc: 0},
+				},
+				FunctionSection: []wasm.Index{0},
+				CodeSection: []*wasm.Code{
+					{
+						LocalTypes: []wasm.ValueType{i32, i32},
+						Body: []byte{
+							wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeLocalGet, 4,
+							wasm.OpcodeCall, 0, wasm.OpcodeEnd,
+						},
+					},
+				},
+				ExportSection: []*wasm.Export{{Type: wasm.ExternTypeFunc, Name: "add", Index: 1}},
+				NameSection: &wasm.NameSection{
+					ModuleName: "m",
+					FunctionNames: wasm.NameMap{
+						{Index: 0, Name: "not an id"},
+						{Index: 1, Name: "add"},
+					},
+					LocalNames: wasm.IndirectNameMap{
+						{Index: 1, NameMap: wasm.NameMap{
+							{Index: 0, Name: "x"},
+							{Index: 2, Name: "dup"},
+							{Index: 3, Name: "dup"},
+							{Index: 4, Name: "l"},
+						}},
+					},
+				},
+			},
+			expected: `(module $m
+  (type (;0;) (func (param i32 i32 i32) (result i32)))
+  (import "env" "f" (func (;0;) (type 0) (param i32 i32 i32) (result i32)))
+  (func $add (type 0) (param $x i32) (param i32 i32) (result i32)
+    (local i32) (local $l i32)
+    local.get $x
+    local.get 1
+    local.get $l
+    call 0)
+  (export "add" (func $add)))
+`,
+		},
+		{
+			name: "blocks and immediates",
+			input: &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{{}, {Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}}},
+				FunctionSection: []wasm.Index{1},
+				CodeSection: []*wasm.Code{{Body: []byte{
+					wasm.OpcodeBlock, i32,
+					wasm.OpcodeLocalGet, 0,
+					wasm.OpcodeIf, 0x40,
+					wasm.OpcodeI32Const, 0x7f, wasm.OpcodeBr, 1,
+					wasm.OpcodeElse, wasm.OpcodeNop,
+					wasm.OpcodeEnd,
+					wasm.OpcodeLoop, 1,
+					wasm.OpcodeBrTable, 1, 0, 1,
+					wasm.OpcodeEnd,
+					wasm.OpcodeI32Load, 1, 8,
+					wasm.OpcodeI32Load8U, 0, 0,
+					wasm.OpcodeF32Const, 0x00, 0x00, 0xc0, 0x7f,
+					wasm.OpcodeF64Const, 0x9a, 0x99, 0x99, 0x99, 0x99, 0x99, 0xb9, 0x3f,
+					wasm.OpcodeTypedSelect, 1, i32,
+					wasm.OpcodeVecPrefix, wasm.OpcodeVecV128Const,
+					1, 0, 0, 0, 2, 0, 0, 0, 3, 0, 0, 0, 0xff, 0xff, 0xff, 0xff,
+					wasm.OpcodeVecPrefix, wasm.OpcodeVecI8x16ExtractLaneU, 15,
+					wasm.OpcodeMiscPrefix, wasm.OpcodeMiscMemoryCopy, 0, 0,
+					wasm.OpcodeEnd,
+					wasm.OpcodeEnd,
+				}}},
+			},
+			expected: `(module
+  (type (;0;) (func))
+  (type (;1;) (func (param i32) (result i32)))
+  (func (;0;) (type 1) (param i32) (result i32)
+    block (result i32)
+      local.get 0
+      if
+        i32.const -1
+        br 1
+      else
+        nop
+      end
+      loop (type 1)
+        br_table 0 1
+      end
+      i32.load offset=8 align=2
+      i32.load8_u
+      f32.const nan
+      f64.const 0.1
+      select (result i32)
+      v128.const i32x4 0x00000001 0x00000002 0x00000003 0xffffffff
+      i8x16.extract_lane_u 15
+      memory.copy
+    end))
+`,
+		},
+		{
+			name: "tables, memory, globals and segments",
+			input: &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{{}},
+				FunctionSection: []wasm.Index{0},
+				CodeSection:     []*wasm.Code{{Body: []byte{wasm.OpcodeEnd}}},
+				TableSection: []*wasm.Table{
+					{Min: 1, Type: wasm.RefTypeFuncref},
+					{Min: 1, Max: &one, Type: wasm.RefTypeExternref},
+				},
+				MemorySection: &wasm.Memory{Min: 1, Cap: 1, Max: 2, IsMaxEncoded: true, IsPageSizeEncoded: true},
+				GlobalSection: []*wasm.Global{
+					{
+						Type: &wasm.GlobalType{ValType: i32, Mutable: true},
+						Init: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{8}},
+					},
+				},
+				StartSection: &zero,
+				ElementSection: []*wasm.ElementSegment{
+					{
+						OffsetExpr: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0}},
+						Init:       []*wasm.Index{&zero},
+						Type:       wasm.RefTypeFuncref,
+					},
+					{
+						OffsetExpr: &wasm.ConstantExpression{Opcode: wasm.OpcodeGlobalGet, Data: []byte{0}},
+						TableIndex: 1,
+						Init:       []*wasm.Index{nil},
+						Type:       wasm.RefTypeExternref,
+					},
+					{Init: []*wasm.Index{&zero, nil}, Type: wasm.RefTypeFuncref, Mode: wasm.ElementModePassive},
+					{Init: []*wasm.Index{&zero}, Type: wasm.RefTypeFuncref, Mode: wasm.ElementModeDeclarative},
+				},
+				DataSection: []*wasm.DataSegment{
+					{
+						OffsetExpression: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0}},
+						Init:             []byte("\x00asm\"\\"),
+					},
+					{Init: []byte("hi")},
+				},
+			},
+			expected: `(module
+  (type (;0;) (func))
+  (func (;0;) (type 0))
+  (table (;0;) 1 funcref)
+  (table (;1;) 1 1 externref)
+  (memory (;0;) 1 2 (pagesize 1))
+  (global (;0;) (mut i32) (i32.const 8))
+  (start 0)
+  (elem (;0;) (i32.const 0) func 0)
+  (elem (;1;) (table 1) (global.get 0) externref (ref.null extern))
+  (elem (;2;) funcref (ref.func 0) (ref.null func))
+  (elem (;3;) declare func 0)
+  (data (;0;) (i32.const 0) "\00asm\"\\")
+  (data (;1;) "hi"))
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			wat, err := EncodeModule(tc.input)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, string(wat))
+
+			// The function bodies survive a round trip through the text format.
+			m, err := DecodeModule(wat)
+			require.NoError(t, err)
+			require.Equal(t, len(tc.input.CodeSection), len(m.CodeSection))
+			for i, code := range tc.input.CodeSection {
+				require.Equal(t, code.Body, m.CodeSection[i].Body)
+			}
+		})
+	}
+}
+
+func TestEncodeModule_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       *wasm.Module
+		expectedErr string
+	}{
+		{
+			name: "unknown opcode",
+			input: &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{{}},
+				FunctionSection: []wasm.Index{0},
+				CodeSection:     []*wasm.Code{{Body: []byte{0xff, wasm.OpcodeEnd}}},
+			},
+			expectedErr: "func[0]: unknown opcode 0xff",
+		},
+		{
+			name: "missing end",
+			input: &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{{}},
+				FunctionSection: []wasm.Index{0},
+				CodeSection:     []*wasm.Code{{Body: []byte{wasm.OpcodeNop}}},
+			},
+			expectedErr: "func[0]: missing end",
+		},
+		{
+			name: "truncated immediate",
+			input: &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{{}},
+				FunctionSection: []wasm.Index{0},
+				CodeSection:     []*wasm.Code{{Body: []byte{wasm.OpcodeI32Const}}},
+			},
+			expectedErr: "func[0]: read immediate of i32.const: readByte failed: EOF",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := EncodeModule(tc.input)
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,644 @@
+package text
+
+import (
+	"math/bits"
+	"strings"
+
+	"github.com/tetratelabs/wazero/internal/leb128"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// funcParser encodes the instructions of a function body or a constant expression, in either the plain or the folded
+// form, e.g. "local.get 0 i32.eqz" or "(i32.eqz (local.get 0))".
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#instructions%E2%91%A3
+type funcParser struct {
+	p *moduleParser
+	// localIDs map the identifiers of parameters and locals to their index.
+	localIDs map[string]wasm.Index
+	// labels are the blocks enclosing the current instruction, innermost last.
+	labels []label
+	body   []byte
+	// count is the number of instructions in body, including "else" and "end".
+	count int
+}
+
+// label is a block, which can be referenced by its identifier or its depth, e.g. "br $l" or "br 0".
+type label struct {
+	// id is the optional identifier of the block.
+	id string
+	// op is wasm.OpcodeBlock, wasm.OpcodeLoop or wasm.OpcodeIf.
+	op wasm.Opcode
+	// elseEnd is the length of the body after the "else" of wasm.OpcodeIf, or zero if none was encoded yet.
+	elseEnd int
+}
+
+func (f *funcParser) emit(encoded ...byte) {
+	f.body = append(f.body, encoded...)
+	f.count++
+}
+
+// instrs encodes the instructions, which can mix plain and folded forms.
+func (f *funcParser) instrs(nodes []*node) (err error) {
+	for len(nodes) > 0 {
+		if nodes[0].isList() {
+			if err = f.folded(nodes[0]); err != nil {
+				return
+			}
+			nodes = nodes[1:]
+		} else if nodes, err = f.plain(nodes); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// plain encodes the leading instruction in the plain form, returning the nodes after it.
+func (f *funcParser) plain(nodes []*node) ([]*node, error) {
+	n, rest := nodes[0], nodes[1:]
+	if n.typ != tokenKeyword {
+		return nil, n.errorf("expected instruction, but was %s", describe(n))
+	}
+
+	switch n.text {
+	case wasm.OpcodeBlockName, wasm.OpcodeLoopName, wasm.OpcodeIfName:
+		l := label{op: instructions[n.text].op}
+		if len(rest) > 0 && rest[0].typ == tokenID {
+			l.id, rest = rest[0].text, rest[1:]
+		}
+		bt, rest, err := f.blockType(n, rest)
+		if err != nil {
+			return nil, err
+		}
+		f.emit(append([]byte{l.op}, bt...)...)
+		f.labels = append(f.labels, l)
+		return rest, nil
+	case wasm.OpcodeElseName:
+		if len(f.labels) == 0 || f.labels[len(f.labels)-1].op != wasm.OpcodeIf || f.labels[len(f.labels)-1].elseEnd > 0 {
+			return nil, n.errorf("unexpected else")
+		}
+		l := &f.labels[len(f.labels)-1]
+		rest, err := closingID(l, rest)
+		if err != nil {
+			return nil, err
+		}
+		f.emit(wasm.OpcodeElse)
+		l.elseEnd = len(f.body)
+		return rest, nil
+	case wasm.OpcodeEndName:
+		if len(f.labels) == 0 {
+			return nil, n.errorf("unexpected end")
+		}
+		rest, err := closingID(&f.labels[len(f.labels)-1], rest)
+		if err != nil {
+			return nil, err
+		}
+		f.end()
+		return rest, nil
+	}
+
+	encoded, rest, err := f.instr(n, rest)
+	if err != nil {
+		return nil, err
+	}
+	f.emit(encoded...)
+	return rest, nil
+}
+
+// end encodes the end of the innermost block, dropping an "else" without instructions, which has no effect.
+func (f *funcParser) end() {
+	l := f.labels[len(f.labels)-1]
+	f.labels = f.labels[:len(f.labels)-1]
+	if l.elseEnd > 0 && l.elseEnd == len(f.body) {
+		f.body = f.body[:l.elseEnd-1]
+		f.count--
+	}
+	f.emit(wasm.OpcodeEnd)
+}
+
+// closingID skips the optional identifier after "else" or "end", which must match that of the block.
+func closingID(l *label, rest []*node) ([]*node, error) {
+	if len(rest) > 0 && rest[0].typ == tokenID {
+		if rest[0].text != l.id {
+			return nil, rest[0].errorf("mismatching label $%s", rest[0].text)
+		}
+		return rest[1:], nil
+	}
+	return rest, nil
+}
+
+// folded encodes an instruction in the folded form, whose operands are encoded before it, e.g.
+// "(i32.add (local.get 0) (i32.const 1))".
+func (f *funcParser) folded(n *node) error {
+	kw := n.head()
+	if kw == "" {
+		return n.errorf("expected instruction")
+	}
+	rest := n.list[1:]
+
+	switch kw {
+	case wasm.OpcodeBlockName, wasm.OpcodeLoopName, wasm.OpcodeIfName:
+		l := label{op: instructions[kw].op}
+		if len(rest) > 0 && rest[0].typ == tokenID {
+			l.id, rest = rest[0].text, rest[1:]
+		}
+		bt, rest, err := f.blockType(n, rest)
+		if err != nil {
+			return err
+		}
+
+		if l.op == wasm.OpcodeIf {
+			// The condition precedes the "then" branch, e.g. "(if (local.get 0) (then ...) (else ...))".
+			for ; len(rest) > 0 && rest[0].isList() && !isList(rest[0], "then"); rest = rest[1:] {
+				if err = f.folded(rest[0]); err != nil {
+					return err
+				}
+			}
+			if len(rest) == 0 {
+				return n.errorf("missing then")
+			}
+			then := rest[0]
+			if !isList(then, "then") {
+				return then.errorf("expected (then ...), but was %s", describe(then))
+			}
+			f.emit(append([]byte{l.op}, bt...)...)
+			f.labels = append(f.labels, l)
+			if err = f.instrs(then.list[1:]); err != nil {
+				return err
+			}
+			if rest = rest[1:]; len(rest) > 0 && isList(rest[0], "else") {
+				f.emit(wasm.OpcodeElse)
+				f.labels[len(f.labels)-1].elseEnd = len(f.body)
+				if err = f.instrs(rest[0].list[1:]); err != nil {
+					return err
+				}
+				rest = rest[1:]
+			}
+			if err = expectEnd(rest); err != nil {
+				return err
+			}
+		} else {
+			f.emit(append([]byte{l.op}, bt...)...)
+			f.labels = append(f.labels, l)
+			if err = f.instrs(rest); err != nil {
+				return err
+			}
+		}
+		if len(f.labels) == 0 || f.labels[len(f.labels)-1].op != l.op {
+			return n.errorf("unbalanced %s", kw)
+		}
+		f.end()
+		return nil
+	case wasm.OpcodeElseName, wasm.OpcodeEndName, "then":
+		return n.errorf("unexpected %s", kw)
+	}
+
+	encoded, rest, err := f.instr(n.list[0], rest)
+	if err != nil {
+		return err
+	}
+	for _, operand := range rest {
+		if !operand.isList() {
+			return operand.errorf("unexpected %s", describe(operand))
+		}
+		if err = f.folded(operand); err != nil {
+			return err
+		}
+	}
+	f.emit(encoded...)
+	return nil
+}
+
+// blockType returns the encoded type of a block, which is a value type or empty unless it has parameters or multiple
+// results, in which case it is a type index.
+func (f *funcParser) blockType(n *node, nodes []*node) ([]byte, []*node, error) {
+	var ft *wasm.FunctionType
+	var typeIdx wasm.Index
+	var paramIDs []string
+	var rest []*node
+	var err error
+	explicit := len(nodes) > 0 && isList(nodes[0], "type")
+	if explicit {
+		if typeIdx, paramIDs, rest, err = f.p.typeUse(nodes); err != nil {
+			return nil, nil, err
+		}
+		ft = f.p.m.TypeSection[typeIdx]
+	} else if ft, paramIDs, rest, err = funcType(nodes); err != nil {
+		return nil, nil, err
+	}
+	if err = anonymousParams(n, paramIDs); err != nil {
+		return nil, nil, err
+	}
+
+	switch {
+	case len(ft.Params) > 0 || len(ft.Results) > 1:
+		if !explicit {
+			typeIdx = f.p.typeIndex(ft)
+		}
+		return leb128.EncodeInt64(int64(typeIdx)), rest, nil
+	case len(ft.Results) == 1:
+		return []byte{ft.Results[0]}, rest, nil
+	}
+	return []byte{0x40}, rest, nil // empty
+}
+
+// anonymousParams returns an error if a parameter has an identifier, which only those of a function can have.
+func anonymousParams(n *node, paramIDs []string) error {
+	for _, id := range paramIDs {
+		if id != "" {
+			return n.errorf("unexpected identifier $%s of parameter", id)
+		}
+	}
+	return nil
+}
+
+// instr encodes an instruction other than a block and its immediates, returning the nodes after it.
+func (f *funcParser) instr(n *node, rest []*node) (encoded []byte, _ []*node, err error) {
+	if n.typ != tokenKeyword {
+		return nil, nil, n.errorf("expected instruction, but was %s", describe(n))
+	}
+	if n.text == wasm.OpcodeSelectName {
+		return f.selectInstr(rest)
+	}
+	in, ok := instructions[n.text]
+	if !ok {
+		return nil, nil, n.errorf("unknown instruction %s", n.text)
+	}
+
+	switch in.prefix {
+	case 0:
+		encoded = []byte{in.op}
+		return f.immediates(n, in, encoded, rest)
+	case wasm.OpcodeMiscPrefix:
+		encoded = append([]byte{in.prefix}, leb128.EncodeUint32(uint32(in.op))...)
+		return f.miscImmediates(n, in, encoded, rest)
+	default: // wasm.OpcodeVecPrefix
+		encoded = append([]byte{in.prefix}, leb128.EncodeUint32(uint32(in.op))...)
+		return f.vectorImmediates(n, in, encoded, rest)
+	}
+}
+
+// selectInstr encodes "select", which is wasm.OpcodeTypedSelect when followed by result types.
+func (f *funcParser) selectInstr(rest []*node) ([]byte, []*node, error) {
+	var types []wasm.ValueType
+	typed := false
+	for ; len(rest) > 0 && isList(rest[0], "result"); rest = rest[1:] {
+		_, ts, err := valueTypeList(rest[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		types, typed = append(types, ts...), true
+	}
+	if !typed {
+		return []byte{wasm.OpcodeSelect}, rest, nil
+	}
+	encoded := append([]byte{wasm.OpcodeTypedSelect}, leb128.EncodeUint32(uint32(len(types)))...)
+	return append(encoded, types...), rest, nil
+}
+
+func (f *funcParser) immediates(n *node, in instruction, encoded []byte, rest []*node) ([]byte, []*node, error) {
+	var imm *node
+	var idx wasm.Index
+	var err error
+	switch in.op {
+	case wasm.OpcodeBlock, wasm.OpcodeLoop, wasm.OpcodeIf, wasm.OpcodeElse, wasm.OpcodeEnd:
+		return nil, nil, n.errorf("unexpected %s", n.text)
+	case wasm.OpcodeBr, wasm.OpcodeBrIf:
+		if imm, rest, err = immediate(n, rest); err == nil {
+			idx, err = f.label(imm)
+		}
+		encoded = append(encoded, leb128.EncodeUint32(idx)...)
+	case wasm.OpcodeBrTable:
+		var labels []wasm.Index
+		for ; len(rest) > 0 && isIndex(rest[0]); rest = rest[1:] {
+			if idx, err = f.label(rest[0]); err != nil {
+				return nil, nil, err
+			}
+			labels = append(labels, idx)
+		}
+		if len(labels) == 0 {
+			return nil, nil, n.errorf("missing labels of %s", n.text)
+		}
+		encoded = append(encoded, leb128.EncodeUint32(uint32(len(labels)-1))...)
+		for _, l := range labels {
+			encoded = append(encoded, leb128.EncodeUint32(l)...)
+		}
+	case wasm.OpcodeCall, wasm.OpcodeRefFunc:
+		if imm, rest, err = immediate(n, rest); err == nil {
+			idx, err = f.p.index(spaceFunc, imm)
+		}
+		encoded = append(encoded, leb128.EncodeUint32(idx)...)
+	case wasm.OpcodeCallIndirect:
+		var tableIdx, typeIdx wasm.Index
+		if tableIdx, rest, err = f.optionalIndex(spaceTable, rest); err != nil {
+			return nil, nil, err
+		}
+		var paramIDs []string
+		if typeIdx, paramIDs, rest, err = f.p.typeUse(rest); err != nil {
+			return nil, nil, err
+		}
+		if err = anonymousParams(n, paramIDs); err != nil {
+			return nil, nil, err
+		}
+		encoded = append(encoded, leb128.EncodeUint32(typeIdx)...)
+		encoded = append(encoded, leb128.EncodeUint32(tableIdx)...)
+	case wasm.OpcodeLocalGet, wasm.OpcodeLocalSet, wasm.OpcodeLocalTee:
+		if imm, rest, err = immediate(n, rest); err == nil {
+			idx, err = f.local(imm)
+		}
+		encoded = append(encoded, leb128.EncodeUint32(idx)...)
+	case wasm.OpcodeGlobalGet, wasm.OpcodeGlobalSet:
+		if imm, rest, err = immediate(n, rest); err == nil {
+			idx, err = f.p.index(spaceGlobal, imm)
+		}
+		encoded = append(encoded, leb128.EncodeUint32(idx)...)
+	case wasm.OpcodeTableGet, wasm.OpcodeTableSet:
+		idx, rest, err = f.optionalIndex(spaceTable, rest)
+		encoded = append(encoded, leb128.EncodeUint32(idx)...)
+	case wasm.OpcodeMemorySize, wasm.OpcodeMemoryGrow:
+		_, rest, err = f.optionalIndex(spaceMemory, rest)
+		encoded = append(encoded, 0x00) // memory index
+	case wasm.OpcodeI32Const, wasm.OpcodeI64Const, wasm.OpcodeF32Const, wasm.OpcodeF64Const:
+		if imm, rest, err = immediate(n, rest); err == nil {
+			encoded, err = constant(imm, in.op, encoded)
+		}
+	case wasm.OpcodeRefNull:
+		if imm, rest, err = immediate(n, rest); err == nil {
+			switch imm.text {
+			case "func":
+				encoded = append(encoded, wasm.RefTypeFuncref)
+			case "extern":
+				encoded = append(encoded, wasm.RefTypeExternref)
+			default:
+				err = imm.errorf("expected func or extern, but was %s", describe(imm))
+			}
+		}
+	default:
+		if natural, ok := in.naturalAlignment(); ok {
+			encoded, rest, err = memarg(natural, encoded, rest)
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return encoded, rest, nil
+}
+
+func (f *funcParser) miscImmediates(n *node, in instruction, encoded []byte, rest []*node) ([]byte, []*node, error) {
+	var idx wasm.Index
+	var err error
+	switch in.op {
+	case wasm.OpcodeMiscMemoryInit, wasm.OpcodeMiscDataDrop:
+		f.p.usesDataCount = true
+		var indices []*node
+		if indices, rest, err = leadingIndices(n, rest, 1, 2); err != nil {
+			return nil, nil, err
+		}
+		if len(indices) == 2 {
+			if _, err = f.p.index(spaceMemory, indices[0]); err != nil {
+				return nil, nil, err
+			}
+		}
+		if idx, err = f.p.index(spaceData, indices[len(indices)-1]); err != nil {
+			return nil, nil, err
+		}
+		encoded = append(encoded, leb128.EncodeUint32(idx)...)
+		if in.op == wasm.OpcodeMiscMemoryInit {
+			encoded = append(encoded, 0x00) // memory index
+		}
+	case wasm.OpcodeMiscMemoryCopy, wasm.OpcodeMiscMemoryFill:
+		var indices []*node
+		if indices, rest, err = leadingIndices(n, rest, 0, 2); err != nil {
+			return nil, nil, err
+		}
+		for _, i := range indices {
+			if _, err = f.p.index(spaceMemory, i); err != nil {
+				return nil, nil, err
+			}
+		}
+		encoded = append(encoded, 0x00) // memory index
+		if in.op == wasm.OpcodeMiscMemoryCopy {
+			encoded = append(encoded, 0x00)
+		}
+	case wasm.OpcodeMiscTableInit:
+		var indices []*node
+		if indices, rest, err = leadingIndices(n, rest, 1, 2); err != nil {
+			return nil, nil, err
+		}
+		var tableIdx wasm.Index
+		if len(indices) == 2 {
+			if tableIdx, err = f.p.index(spaceTable, indices[0]); err != nil {
+				return nil, nil, err
+			}
+		}
+		if idx, err = f.p.index(spaceElem, indices[len(indices)-1]); err != nil {
+			return nil, nil, err
+		}
+		encoded = append(encoded, leb128.EncodeUint32(idx)...)
+		encoded = append(encoded, leb128.EncodeUint32(tableIdx)...)
+	case wasm.OpcodeMiscElemDrop:
+		var imm *node
+		if imm, rest, err = immediate(n, rest); err != nil {
+			return nil, nil, err
+		}
+		if idx, err = f.p.index(spaceElem, imm); err != nil {
+			return nil, nil, err
+		}
+		encoded = append(encoded, leb128.EncodeUint32(idx)...)
+	case wasm.OpcodeMiscTableCopy:
+		var indices []*node
+		if indices, rest, err = leadingIndices(n, rest, 0, 2); err != nil {
+			return nil, nil, err
+		}
+		if len(indices) == 1 {
+			return nil, nil, n.errorf("expected both tables of %s", n.text)
+		}
+		dst, src := wasm.Index(0), wasm.Index(0)
+		if len(indices) == 2 {
+			if dst, err = f.p.index(spaceTable, indices[0]); err != nil {
+				return nil, nil, err
+			}
+			if src, err = f.p.index(spaceTable, indices[1]); err != nil {
+				return nil, nil, err
+			}
+		}
+		encoded = append(encoded, leb128.EncodeUint32(dst)...)
+		encoded = append(encoded, leb128.EncodeUint32(src)...)
+	case wasm.OpcodeMiscTableGrow, wasm.OpcodeMiscTableSize, wasm.OpcodeMiscTableFill:
+		if idx, rest, err = f.optionalIndex(spaceTable, rest); err != nil {
+			return nil, nil, err
+		}
+		encoded = append(encoded, leb128.EncodeUint32(idx)...)
+	}
+	return encoded, rest, nil
+}
+
+func (f *funcParser) vectorImmediates(n *node, in instruction, encoded []byte, rest []*node) ([]byte, []*node, error) {
+	var err error
+	if natural, ok := in.naturalAlignment(); ok {
+		if encoded, rest, err = memarg(natural, encoded, rest); err != nil {
+			return nil, nil, err
+		}
+	}
+	if in.hasLane() {
+		var imm *node
+		if imm, rest, err = immediate(n, rest); err != nil {
+			return nil, nil, err
+		}
+		lane, err := parseNat(imm.text, 8)
+		if err != nil {
+			return nil, nil, imm.errorf("invalid lane index %s: %v", imm.text, err)
+		}
+		encoded = append(encoded, byte(lane))
+	}
+
+	switch in.op {
+	case wasm.OpcodeVecV128Const:
+		var imm *node
+		if imm, rest, err = immediate(n, rest); err != nil {
+			return nil, nil, err
+		}
+		shape, ok := vectorShapes[imm.text]
+		if !ok {
+			return nil, nil, imm.errorf("unknown vector shape %s", describe(imm))
+		}
+		for i := 0; i < shape.lanes; i++ {
+			if imm, rest, err = immediate(n, rest); err != nil {
+				return nil, nil, err
+			}
+			b, err := shape.parse(imm.text)
+			if err != nil {
+				return nil, nil, imm.errorf("invalid lane %s: %v", imm.text, err)
+			}
+			encoded = append(encoded, b...)
+		}
+	case wasm.OpcodeVecV128i8x16Shuffle:
+		for i := 0; i < 16; i++ {
+			var imm *node
+			if imm, rest, err = immediate(n, rest); err != nil {
+				return nil, nil, err
+			}
+			lane, err := parseNat(imm.text, 8)
+			if err != nil {
+				return nil, nil, imm.errorf("invalid lane index %s: %v", imm.text, err)
+			}
+			encoded = append(encoded, byte(lane))
+		}
+	}
+	return encoded, rest, nil
+}
+
+// immediate returns the leading atom, which is an immediate of the instruction.
+func immediate(n *node, rest []*node) (*node, []*node, error) {
+	if len(rest) == 0 || rest[0].isList() {
+		return nil, nil, n.errorf("missing immediate of %s", n.text)
+	}
+	return rest[0], rest[1:], nil
+}
+
+// leadingIndices returns the leading indices, of which there must be between min and max.
+func leadingIndices(n *node, rest []*node, min, max int) (indices []*node, _ []*node, err error) {
+	for ; len(indices) < max && len(rest) > 0 && isIndex(rest[0]); rest = rest[1:] {
+		indices = append(indices, rest[0])
+	}
+	if len(indices) < min {
+		return nil, nil, n.errorf("missing immediate of %s", n.text)
+	}
+	return indices, rest, nil
+}
+
+// optionalIndex returns the leading index in the space, or zero if there is none.
+func (f *funcParser) optionalIndex(space indexSpace, rest []*node) (wasm.Index, []*node, error) {
+	if len(rest) == 0 || !isIndex(rest[0]) {
+		return 0, rest, nil
+	}
+	idx, err := f.p.index(space, rest[0])
+	return idx, rest[1:], err
+}
+
+// label resolves a label to its depth, counting from the innermost block.
+func (f *funcParser) label(n *node) (wasm.Index, error) {
+	if n.typ == tokenID {
+		for i := len(f.labels) - 1; i >= 0; i-- {
+			if f.labels[i].id == n.text {
+				return wasm.Index(len(f.labels) - 1 - i), nil
+			}
+		}
+		return 0, n.errorf("unknown label $%s", n.text)
+	}
+	if v, err := parseNat(n.text, 32); n.typ == tokenKeyword && err == nil {
+		return wasm.Index(v), nil
+	}
+	return 0, n.errorf("expected label, but was %s", describe(n))
+}
+
+// local resolves a parameter or local.
+func (f *funcParser) local(n *node) (wasm.Index, error) {
+	if n.typ == tokenID {
+		if idx, ok := f.localIDs[n.text]; ok {
+			return idx, nil
+		}
+		return 0, n.errorf("unknown local $%s", n.text)
+	}
+	if v, err := parseNat(n.text, 32); n.typ == tokenKeyword && err == nil {
+		return wasm.Index(v), nil
+	}
+	return 0, n.errorf("expected local index, but was %s", describe(n))
+}
+
+// constant encodes the value of a numeric constant instruction.
+func constant(n *node, op wasm.Opcode, encoded []byte) ([]byte, error) {
+	var err error
+	switch op {
+	case wasm.OpcodeI32Const:
+		var v uint64
+		if v, err = parseInt(n.text, 32); err == nil {
+			encoded = append(encoded, leb128.EncodeInt32(int32(uint32(v)))...)
+		}
+	case wasm.OpcodeI64Const:
+		var v uint64
+		if v, err = parseInt(n.text, 64); err == nil {
+			encoded = append(encoded, leb128.EncodeInt64(int64(v))...)
+		}
+	case wasm.OpcodeF32Const:
+		var v uint32
+		if v, err = parseF32(n.text); err == nil {
+			encoded = append(encoded, littleEndian(uint64(v), 4)...)
+		}
+	default: // wasm.OpcodeF64Const
+		var v uint64
+		if v, err = parseF64(n.text); err == nil {
+			encoded = append(encoded, littleEndian(v, 8)...)
+		}
+	}
+	if err != nil {
+		return nil, n.errorf("invalid constant %s: %v", n.text, err)
+	}
+	return encoded, nil
+}
+
+// memarg encodes the optional "offset=" and "align=" of a memory instruction, e.g. "i32.load offset=4 align=2".
+// The default alignment is the natural one, whose log2 is given.
+func memarg(natural uint32, encoded []byte, rest []*node) ([]byte, []*node, error) {
+	offset, align := uint64(0), uint64(1)<<natural
+	for ; len(rest) > 0 && rest[0].typ == tokenKeyword; rest = rest[1:] {
+		t := rest[0].text
+		if v := strings.TrimPrefix(t, "offset="); v != t {
+			o, err := parseNat(v, 32)
+			if err != nil {
+				return nil, nil, rest[0].errorf("invalid offset %s: %v", v, err)
+			}
+			offset = o
+		} else if v = strings.TrimPrefix(t, "align="); v != t {
+			a, err := parseNat(v, 32)
+			if err != nil || a == 0 || a&(a-1) != 0 {
+				return nil, nil, rest[0].errorf("invalid alignment %s", v)
+			}
+			align = a
+		} else {
+			break
+		}
+	}
+	encoded = append(encoded, leb128.EncodeUint32(uint32(bits.TrailingZeros64(align)))...)
+	return append(encoded, leb128.EncodeUint32(uint32(offset))...), rest, nil
+}
@@ -0,0 +1,128 @@
+package text
+
+import "github.com/tetratelabs/wazero/internal/wasm"
+
+// instruction identifies an instruction by its opcode, which follows the prefix unless that is zero.
+type instruction struct {
+	// prefix is zero, wasm.OpcodeMiscPrefix or wasm.OpcodeVecPrefix.
+	prefix wasm.Opcode
+	op     byte
+}
+
+// instructions maps the names of instructions to their opcodes, except for "select" with a result type, which is
+// wasm.OpcodeTypedSelect.
+var instructions = map[string]instruction{}
+
+func init() {
+	for i := 0; i < 256; i++ {
+		switch op := wasm.Opcode(i); op {
+		case wasm.OpcodeTypedSelect, wasm.OpcodeMiscPrefix, wasm.OpcodeVecPrefix:
+		default:
+			if name := wasm.InstructionName(op); name != "" {
+				instructions[name] = instruction{op: op}
+			}
+		}
+		if name := wasm.MiscInstructionName(wasm.OpcodeMisc(i)); name != "" {
+			instructions[name] = instruction{prefix: wasm.OpcodeMiscPrefix, op: byte(i)}
+		}
+		if name := wasm.VectorInstructionName(wasm.OpcodeVec(i)); name != "" {
+			instructions[name] = instruction{prefix: wasm.OpcodeVecPrefix, op: byte(i)}
+		}
+	}
+}
+
+// name returns the name of the instruction, or empty if it is unknown.
+func (i instruction) name() string {
+	switch i.prefix {
+	case wasm.OpcodeMiscPrefix:
+		return wasm.MiscInstructionName(i.op)
+	case wasm.OpcodeVecPrefix:
+		return wasm.VectorInstructionName(i.op)
+	case 0:
+		if i.op == wasm.OpcodeTypedSelect {
+			return wasm.OpcodeSelectName
+		}
+		return wasm.InstructionName(i.op)
+	}
+	return ""
+}
+
+// naturalAlignment returns the log2 of the size of the memory accessed by the instruction, which is the default
+// alignment of its memory argument, or false if it has none.
+func (i instruction) naturalAlignment() (uint32, bool) {
+	switch i.prefix {
+	case 0:
+		switch i.op {
+		case wasm.OpcodeI32Load8S, wasm.OpcodeI32Load8U, wasm.OpcodeI64Load8S, wasm.OpcodeI64Load8U,
+			wasm.OpcodeI32Store8, wasm.OpcodeI64Store8:
+			return 0, true
+		case wasm.OpcodeI32Load16S, wasm.OpcodeI32Load16U, wasm.OpcodeI64Load16S, wasm.OpcodeI64Load16U,
+			wasm.OpcodeI32Store16, wasm.OpcodeI64Store16:
+			return 1, true
+		case wasm.OpcodeI32Load, wasm.OpcodeF32Load, wasm.OpcodeI64Load32S, wasm.OpcodeI64Load32U,
+			wasm.OpcodeI32Store, wasm.OpcodeF32Store, wasm.OpcodeI64Store32:
+			return 2, true
+		case wasm.OpcodeI64Load, wasm.OpcodeF64Load, wasm.OpcodeI64Store, wasm.OpcodeF64Store:
+			return 3, true
+		}
+	case wasm.OpcodeVecPrefix:
+		switch i.op {
+		case wasm.OpcodeVecV128Load8Splat, wasm.OpcodeVecV128Load8Lane, wasm.OpcodeVecV128Store8Lane:
+			return 0, true
+		case wasm.OpcodeVecV128Load16Splat, wasm.OpcodeVecV128Load16Lane, wasm.OpcodeVecV128Store16Lane:
+			return 1, true
+		case wasm.OpcodeVecV128Load32Splat, wasm.OpcodeVecV128Load32zero, wasm.OpcodeVecV128Load32Lane,
+			wasm.OpcodeVecV128Store32Lane:
+			return 2, true
+		case wasm.OpcodeVecV128Load8x8s, wasm.OpcodeVecV128Load8x8u, wasm.OpcodeVecV128Load16x4s,
+			wasm.OpcodeVecV128Load16x4u, wasm.OpcodeVecV128Load32x2s, wasm.OpcodeVecV128Load32x2u,
+			wasm.OpcodeVecV128Load64Splat, wasm.OpcodeVecV128Load64zero, wasm.OpcodeVecV128Load64Lane,
+			wasm.OpcodeVecV128Store64Lane:
+			return 3, true
+		case wasm.OpcodeVecV128Load, wasm.OpcodeVecV128Store:
+			return 4, true
+		}
+	}
+	return 0, false
+}
+
+// hasLane returns true if the instruction has a lane index immediate, e.g. "i32x4.extract_lane 1".
+func (i instruction) hasLane() bool {
+	return i.prefix == wasm.OpcodeVecPrefix &&
+		(i.op >= wasm.OpcodeVecV128Load8Lane && i.op <= wasm.OpcodeVecV128Store64Lane ||
+			i.op >= wasm.OpcodeVecI8x16ExtractLaneS && i.op <= wasm.OpcodeVecF64x2ReplaceLane)
+}
+
+// vectorShapes are the interpretations of the lanes of a v128.const, e.g. "i32x4 1 2 3 4".
+var vectorShapes = map[string]struct {
+	lanes int
+	// parse parses the text of a lane into its little-endian bytes.
+	parse func(string) ([]byte, error)
+}{
+	"i8x16": {16, func(s string) ([]byte, error) { return intLane(s, 8) }},
+	"i16x8": {8, func(s string) ([]byte, error) { return intLane(s, 16) }},
+	"i32x4": {4, func(s string) ([]byte, error) { return intLane(s, 32) }},
+	"i64x2": {2, func(s string) ([]byte, error) { return intLane(s, 64) }},
+	"f32x4": {4, func(s string) ([]byte, error) {
+		v, err := parseF32(s)
+		return littleEndian(uint64(v), 4), err
+	}},
+	"f64x2": {2, func(s string) ([]byte, error) {
+		v, err := parseF64(s)
+		return littleEndian(v, 8), err
+	}},
+}
+
+func intLane(s string, bits int) ([]byte, error) {
+	v, err := parseInt(s, bits)
+	return littleEndian(v, bits/8), err
+}
+
+// littleEndian returns the low size bytes of v in little-endian order.
+func littleEndian(v uint64, size int) []byte {
+	ret := make([]byte, size)
+	for i := range ret {
+		ret[i] = byte(v >> (8 * i))
+	}
+	return ret
+}
@@ -0,0 +1,256 @@
+package text
+
+import (
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+)
+
+// tokenType is the type of a token of the text format.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#tokens%E2%91%A0
+type tokenType byte
+
+const (
+	tokenLParen tokenType = iota + 1
+	tokenRParen
+	// tokenKeyword is an atom which is neither an identifier nor a string, e.g. "module", "i32.add" or "1".
+	tokenKeyword
+	// tokenID is an identifier, e.g. "$main".
+	tokenID
+	// tokenString is a string, e.g. "hello\n".
+	tokenString
+)
+
+// token is a token of the text format and its position in the source.
+type token struct {
+	typ tokenType
+	// text is the source of the token, except for a tokenID, which excludes the leading '$', and a tokenString,
+	// which is unquoted and unescaped. As strings can include arbitrary bytes, text isn't necessarily valid UTF-8.
+	text string
+	// line and col are the one-based position of the token in the source. col counts bytes, not runes.
+	line, col int
+}
+
+// errorf returns an error prefixed with the position of the token, e.g. "1:2: unexpected ...".
+func (t *token) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%d:%d: %s", t.line, t.col, fmt.Sprintf(format, args...))
+}
+
+// lex returns the tokens of the source, skipping whitespace and comments.
+func lex(source []byte) ([]token, error) {
+	l := &lexer{source: source, line: 1, col: 1}
+	var tokens []token
+	for {
+		if err := l.skipSpace(); err != nil {
+			return nil, err
+		}
+		if l.pos == len(source) {
+			return tokens, nil
+		}
+		t := token{line: l.line, col: l.col}
+		switch c := source[l.pos]; {
+		case c == '(':
+			t.typ, t.text = tokenLParen, "("
+			l.advance(1)
+		case c == ')':
+			t.typ, t.text = tokenRParen, ")"
+			l.advance(1)
+		case c == '"':
+			s, err := l.string()
+			if err != nil {
+				return nil, err
+			}
+			t.typ, t.text = tokenString, s
+		case isIDChar(c):
+			start := l.pos
+			for l.pos < len(source) && isIDChar(source[l.pos]) {
+				l.advance(1)
+			}
+			t.typ, t.text = tokenKeyword, string(source[start:l.pos])
+			if c == '$' {
+				if len(t.text) == 1 {
+					return nil, t.errorf("empty identifier")
+				}
+				t.typ, t.text = tokenID, t.text[1:]
+			}
+		default:
+			return nil, t.errorf("unexpected character %s", strconv.QuoteRune(l.rune()))
+		}
+		if t.typ != tokenLParen && t.typ != tokenRParen && l.pos < len(source) {
+			switch source[l.pos] {
+			case ' ', '\t', '\n', '\r', '(', ')', ';':
+			default: // atoms must be separated, e.g. "$a"b"" isn't an identifier followed by a string.
+				return nil, l.errorf("unexpected character %s", strconv.QuoteRune(l.rune()))
+			}
+		}
+		tokens = append(tokens, t)
+	}
+}
+
+// lexer tracks the position in the source of lex.
+type lexer struct {
+	source    []byte
+	pos       int
+	line, col int
+}
+
+// advance moves the position forward by n bytes, which don't include a newline.
+func (l *lexer) advance(n int) {
+	l.pos += n
+	l.col += n
+}
+
+// rune returns the rune at the current position, or utf8.RuneError if it isn't valid UTF-8.
+func (l *lexer) rune() rune {
+	r, _ := utf8.DecodeRune(l.source[l.pos:])
+	return r
+}
+
+func (l *lexer) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%d:%d: %s", l.line, l.col, fmt.Sprintf(format, args...))
+}
+
+// skipSpace skips whitespace, line comments and block comments, which can be nested.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#white-space%E2%91%A0
+func (l *lexer) skipSpace() error {
+	depth := 0 // of block comments
+	for l.pos < len(l.source) {
+		rest := l.source[l.pos:]
+		switch {
+		case rest[0] == '\n':
+			l.pos++
+			l.line, l.col = l.line+1, 1
+		case depth == 0 && (rest[0] == ' ' || rest[0] == '\t' || rest[0] == '\r'):
+			l.advance(1)
+		case depth == 0 && len(rest) > 1 && rest[0] == ';' && rest[1] == ';':
+			for l.pos < len(l.source) && l.source[l.pos] != '\n' {
+				l.advance(1)
+			}
+		case len(rest) > 1 && rest[0] == '(' && rest[1] == ';':
+			depth++
+			l.advance(2)
+		case depth > 0 && len(rest) > 1 && rest[0] == ';' && rest[1] == ')':
+			depth--
+			l.advance(2)
+		case depth > 0:
+			l.advance(1)
+		default:
+			return nil
+		}
+	}
+	if depth > 0 {
+		return l.errorf("unterminated block comment")
+	}
+	return nil
+}
+
+// string reads a string token, returning its unescaped bytes.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#strings%E2%91%A0
+func (l *lexer) string() (string, error) {
+	l.advance(1) // opening quote
+	var ret []byte
+	for {
+		if l.pos == len(l.source) {
+			return "", l.errorf("unterminated string")
+		}
+		c := l.source[l.pos]
+		switch {
+		case c == '"':
+			l.advance(1)
+			return string(ret), nil
+		case c == '\\':
+			b, err := l.escape()
+			if err != nil {
+				return "", err
+			}
+			ret = append(ret, b...)
+		case c < 0x20 || c == 0x7f:
+			return "", l.errorf("invalid character in string: %#x", c)
+		case c < utf8.RuneSelf:
+			ret = append(ret, c)
+			l.advance(1)
+		default:
+			r, size := utf8.DecodeRune(l.source[l.pos:])
+			if r == utf8.RuneError && size == 1 {
+				return "", l.errorf("invalid UTF-8 in string")
+			}
+			ret = append(ret, l.source[l.pos:l.pos+size]...)
+			l.advance(size)
+		}
+	}
+}
+
+// escape reads an escape sequence in a string, returning the bytes it represents.
+func (l *lexer) escape() ([]byte, error) {
+	rest := l.source[l.pos+1:]
+	if len(rest) == 0 {
+		return nil, l.errorf("unterminated string")
+	}
+	switch rest[0] {
+	case 't':
+		l.advance(2)
+		return []byte{'\t'}, nil
+	case 'n':
+		l.advance(2)
+		return []byte{'\n'}, nil
+	case 'r':
+		l.advance(2)
+		return []byte{'\r'}, nil
+	case '"', '\'', '\\':
+		l.advance(2)
+		return rest[:1], nil
+	case 'u':
+		end := 0
+		for i, c := range rest {
+			if c == '}' {
+				end = i
+				break
+			}
+		}
+		if len(rest) < 4 || rest[1] != '{' || end < 3 {
+			return nil, l.errorf("invalid unicode escape")
+		}
+		r, err := parseUint(string(rest[2:end]), 16, 32)
+		if err != nil || r >= 0xd800 && r < 0xe000 || r > utf8.MaxRune {
+			return nil, l.errorf("invalid unicode escape")
+		}
+		l.advance(end + 2)
+		return []byte(string(rune(r))), nil
+	}
+	if len(rest) < 2 || hexDigit(rest[0]) < 0 || hexDigit(rest[1]) < 0 {
+		return nil, l.errorf("invalid escape")
+	}
+	l.advance(3)
+	return []byte{byte(hexDigit(rest[0])<<4 | hexDigit(rest[1]))}, nil
+}
+
+// hexDigit returns the value of the hexadecimal digit, or -1 if it isn't one.
+func hexDigit(c byte) int {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0')
+	case c >= 'a' && c <= 'f':
+		return int(c-'a') + 10
+	case c >= 'A' && c <= 'F':
+		return int(c-'A') + 10
+	}
+	return -1
+}
+
+// isIDChar returns true if the character can be in a keyword or an identifier.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#text-idchar
+func isIDChar(c byte) bool {
+	switch {
+	case c >= '0' && c <= '9', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		return true
+	}
+	switch c {
+	case '!', '#', '$', '%', '&', '\'', '*', '+', '-', '.', '/', ':', '<', '=', '>', '?', '@', '\\', '^', '_', '`', '|', '~':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package text
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestLex(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []token
+	}{
+		{
+			name:  "empty",
+			input: "",
+		},
+		{
+			name:  "comments and whitespace",
+			input: ";; line\n(; block (; nested ;) ;)\t\r\n",
+		},
+		{
+			name:  "module",
+			input: "(module $m)",
+			expected: []token{
+				{typ: tokenLParen, text: "(", line: 1, col: 1},
+				{typ: tokenKeyword, text: "module", line: 1, col: 2},
+				{typ: tokenID, text: "m", line: 1, col: 9},
+				{typ: tokenRParen, text: ")", line: 1, col: 11},
+			},
+		},
+		{
+			name:  "position after newline",
+			input: "(func\n  i32.const -1)",
+			expected: []token{
+				{typ: tokenLParen, text: "(", line: 1, col: 1},
+				{typ: tokenKeyword, text: "func", line: 1, col: 2},
+				{typ: tokenKeyword, text: "i32.const", line: 2, col: 3},
+				{typ: tokenKeyword, text: "-1", line: 2, col: 13},
+				{typ: tokenRParen, text: ")", line: 2, col: 15},
+			},
+		},
+		{
+			name:  "string escapes",
+			input: `"\t\n\r\"\'\\\00\ff\u{1F600}é"`,
+			expected: []token{
+				{typ: tokenString, text: "\t\n\r\"'\\\x00\xff😀é", line: 1, col: 1},
+			},
+		},
+		{
+			name:  "comment after keyword",
+			input: "nop;; comment",
+			expected: []token{
+				{typ: tokenKeyword, text: "nop", line: 1, col: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			tokens, err := lex([]byte(tc.input))
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, tokens)
+		})
+	}
+}
+
+func TestLex_Errors(t *testing.T) {
+	tests := []struct {
+		name, input, expectedErr string
+	}{
+		{
+			name:        "unexpected character",
+			input:       "(module [)",
+			expectedErr: "1:9: unexpected character '['",
+		},
+		{
+			name:        "empty identifier",
+			input:       "(func $ )",
+			expectedErr: "1:7: empty identifier",
+		},
+		{
+			name:        "unterminated block comment",
+			input:       "(; (; ;)",
+			expectedErr: "1:9: unterminated block comment",
+		},
+		{
+			name:        "unterminated string",
+			input:       `"abc`,
+			expectedErr: "1:5: unterminated string",
+		},
+		{
+			name:        "control character in string",
+			input:       "\"a\tb\"",
+			expectedErr: "1:3: invalid character in string: 0x9",
+		},
+		{
+			name:        "invalid escape",
+			input:       `"\x"`,
+			expectedErr: "1:2: invalid escape",
+		},
+		{
+			name:        "surrogate unicode escape",
+			input:       `"\u{d800}"`,
+			expectedErr: "1:2: invalid unicode escape",
+		},
+		{
+			name:        "invalid UTF-8",
+			input:       "\"\xff\"",
+			expectedErr: "1:2: invalid UTF-8 in string",
+		},
+		{
+			name:        "atoms not separated",
+			input:       `(data $d"a")`,
+			expectedErr: "1:9: unexpected character '\"'",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := lex([]byte(tc.input))
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestParseSExprs(t *testing.T) {
+	tokens, err := lex([]byte("(module (func $f nop)) (memory 1)"))
+	require.NoError(t, err)
+	nodes, err := parseSExprs(tokens)
+	require.NoError(t, err)
+
+	require.Equal(t, 2, len(nodes))
+	require.True(t, isList(nodes[0], "module"))
+	require.True(t, isList(nodes[1], "memory"))
+	fn := nodes[0].list[1]
+	require.Equal(t, "func", fn.head())
+	require.Equal(t, "f", fieldID(fn))
+	require.True(t, fieldRest(fn)[0].isKeyword("nop"))
+
+	for input, expectedErr := range map[string]string{
+		"(module":   "1:1: missing )",
+		"(module))": "1:9: unexpected )",
+	} {
+		tokens, err = lex([]byte(input))
+		require.NoError(t, err)
+		_, err = parseSExprs(tokens)
+		require.EqualError(t, err, expectedErr)
+	}
+}
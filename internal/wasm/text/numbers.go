@@ -0,0 +1,186 @@
+package text
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var errMalformedNumber = errors.New("malformed number")
+
+// parseUint parses digits in the base, which can be separated by underscores, returning an error unless the value
+// fits in the bit size.
+func parseUint(s string, base, bits int) (uint64, error) {
+	digits, ok := stripUnderscores(s, base)
+	if !ok {
+		return 0, errMalformedNumber
+	}
+	v, err := strconv.ParseUint(digits, base, bits)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			return 0, errors.New("constant out of range")
+		}
+		return 0, errMalformedNumber
+	}
+	return v, nil
+}
+
+// stripUnderscores returns the digits in the base without the underscores separating them, or false if the
+// underscores don't separate digits, or there are no digits.
+func stripUnderscores(s string, base int) (string, bool) {
+	if s == "" {
+		return "", false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] == '_' {
+			if i == 0 || i == len(s)-1 || s[i-1] == '_' {
+				return "", false
+			}
+		} else if d := hexDigit(s[i]); d < 0 || d >= base {
+			return "", false
+		}
+	}
+	return strings.ReplaceAll(s, "_", ""), true
+}
+
+// parseNat parses an unsigned decimal or hexadecimal integer which fits in the bit size, e.g. "1_000" or "0xff".
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#integers%E2%91%A6
+func parseNat(s string, bits int) (uint64, error) {
+	if hex := strings.TrimPrefix(s, "0x"); hex != s {
+		return parseUint(hex, 16, bits)
+	}
+	return parseUint(s, 10, bits)
+}
+
+// parseInt parses a signed or unsigned integer of the bit size, returning its two's complement, e.g. both "-1" and
+// "0xffffffff" are 0xffffffff for 32 bits.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#integers%E2%91%A6
+func parseInt(s string, bits int) (uint64, error) {
+	neg := strings.HasPrefix(s, "-")
+	if neg || strings.HasPrefix(s, "+") {
+		s = s[1:]
+	}
+	v, err := parseNat(s, bits)
+	if err != nil {
+		return 0, err
+	}
+	if neg {
+		if v > 1<<(bits-1) {
+			return 0, errors.New("constant out of range")
+		}
+		v = -v
+		if bits < 64 {
+			v &= 1<<bits - 1
+		}
+	}
+	return v, nil
+}
+
+// floatFormat describes the bits of an IEEE 754 binary floating point number.
+type floatFormat struct {
+	// bits is the size of the number, i.e. 32 or 64.
+	bits int
+	// mantissaBits is the count of bits of the significand which are stored.
+	mantissaBits uint
+}
+
+var (
+	f32Format = floatFormat{bits: 32, mantissaBits: 23}
+	f64Format = floatFormat{bits: 64, mantissaBits: 52}
+)
+
+// parseF32 parses a floating point number, returning the bits of the float32 nearest to it.
+func parseF32(s string) (uint32, error) {
+	v, err := parseFloat(s, f32Format)
+	return uint32(v), err
+}
+
+// parseF64 parses a floating point number, returning the bits of the float64 nearest to it.
+func parseF64(s string) (uint64, error) {
+	return parseFloat(s, f64Format)
+}
+
+// parseFloat parses a decimal or hexadecimal floating point number, or "inf", "nan" or "nan:0x" followed by the
+// payload of a NaN, which can be signed.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#floating-point%E2%91%A6
+func parseFloat(s string, f floatFormat) (uint64, error) {
+	var sign uint64
+	if strings.HasPrefix(s, "-") {
+		sign = 1 << (f.bits - 1)
+	}
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		s = s[1:]
+	}
+
+	exponent := uint64(1)<<(f.bits-1) - 1<<f.mantissaBits // all ones
+	switch {
+	case s == "inf":
+		return sign | exponent, nil
+	case s == "nan":
+		return sign | exponent | 1<<(f.mantissaBits-1), nil // canonical NaN
+	case strings.HasPrefix(s, "nan:0x"):
+		payload, err := parseUint(s[len("nan:0x"):], 16, 64)
+		if err != nil || payload == 0 || payload >= 1<<f.mantissaBits {
+			return 0, errors.New("invalid NaN payload")
+		}
+		return sign | exponent | payload, nil
+	}
+
+	var ok bool
+	if hex := strings.TrimPrefix(s, "0x"); hex != s {
+		s, ok = floatDigits(hex, 16, 'p')
+		if ok {
+			s = "0x" + s
+			if !strings.ContainsAny(s, "pP") { // required by strconv
+				s += "p0"
+			}
+		}
+	} else {
+		s, ok = floatDigits(s, 10, 'e')
+	}
+	if !ok {
+		return 0, errMalformedNumber
+	}
+
+	v, err := strconv.ParseFloat(s, f.bits)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) && math.IsInf(v, 0) {
+			return 0, errors.New("constant out of range")
+		}
+		return 0, errMalformedNumber
+	}
+	if f.bits == 32 {
+		return sign | uint64(math.Float32bits(float32(v))), nil
+	}
+	return sign | math.Float64bits(v), nil
+}
+
+// floatDigits validates the unsigned number "digits(.digits?)?(E[+-]?digits)?" with digits in the base and the
+// exponent marker E in either case, returning it without underscores.
+func floatDigits(s string, base int, marker byte) (string, bool) {
+	mantissa, exp := s, ""
+	if i := strings.IndexAny(s, string([]byte{marker, marker - 'a' + 'A'})); i >= 0 {
+		mantissa, exp = s[:i], s[i+1:]
+		if exp != "" && (exp[0] == '+' || exp[0] == '-') {
+			exp = exp[1:]
+		}
+		if _, ok := stripUnderscores(exp, 10); !ok {
+			return "", false
+		}
+	}
+	whole, frac := mantissa, ""
+	if i := strings.IndexByte(mantissa, '.'); i >= 0 {
+		whole, frac = mantissa[:i], mantissa[i+1:]
+	}
+	if _, ok := stripUnderscores(whole, base); !ok {
+		return "", false
+	}
+	if _, ok := stripUnderscores(frac, base); frac != "" && !ok {
+		return "", false
+	}
+	return strings.ReplaceAll(s, "_", ""), true
+}
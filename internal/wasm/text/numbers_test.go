@@ -0,0 +1,127 @@
+package text
+
+import (
+	"math"
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestParseInt(t *testing.T) {
+	tests := []struct {
+		input    string
+		bits     int
+		expected uint64
+	}{
+		{input: "0", bits: 32, expected: 0},
+		{input: "+42", bits: 32, expected: 42},
+		{input: "1_000", bits: 32, expected: 1000},
+		{input: "0xff_ff", bits: 32, expected: 0xffff},
+		{input: "-1", bits: 32, expected: 0xffffffff},
+		{input: "0xffffffff", bits: 32, expected: 0xffffffff},
+		{input: "-2147483648", bits: 32, expected: 0x80000000},
+		{input: "-1", bits: 64, expected: math.MaxUint64},
+		{input: "-9223372036854775808", bits: 64, expected: 1 << 63},
+		{input: "18446744073709551615", bits: 64, expected: math.MaxUint64},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.input, func(t *testing.T) {
+			v, err := parseInt(tc.input, tc.bits)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, v)
+		})
+	}
+}
+
+func TestParseInt_Errors(t *testing.T) {
+	tests := []struct {
+		input       string
+		bits        int
+		expectedErr string
+	}{
+		{input: "", bits: 32, expectedErr: "malformed number"},
+		{input: "1__0", bits: 32, expectedErr: "malformed number"},
+		{input: "_1", bits: 32, expectedErr: "malformed number"},
+		{input: "0x", bits: 32, expectedErr: "malformed number"},
+		{input: "1a", bits: 32, expectedErr: "malformed number"},
+		{input: "4294967296", bits: 32, expectedErr: "constant out of range"},
+		{input: "-2147483649", bits: 32, expectedErr: "constant out of range"},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.input, func(t *testing.T) {
+			_, err := parseInt(tc.input, tc.bits)
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestParseFloat(t *testing.T) {
+	tests := []struct {
+		input      string
+		expected32 uint32
+		expected64 uint64
+	}{
+		{input: "0", expected32: 0, expected64: 0},
+		{input: "-0", expected32: 0x80000000, expected64: 1 << 63},
+		{input: "1.5", expected32: math.Float32bits(1.5), expected64: math.Float64bits(1.5)},
+		{input: "1_000.25e-2", expected32: math.Float32bits(10.0025), expected64: math.Float64bits(10.0025)},
+		{input: "0x1p-1", expected32: math.Float32bits(0.5), expected64: math.Float64bits(0.5)},
+		{input: "0x1.8", expected32: math.Float32bits(1.5), expected64: math.Float64bits(1.5)},
+		{input: "inf", expected32: 0x7f800000, expected64: 0x7ff0000000000000},
+		{input: "-inf", expected32: 0xff800000, expected64: 0xfff0000000000000},
+		{input: "nan", expected32: 0x7fc00000, expected64: 0x7ff8000000000000},
+		{input: "-nan:0x1", expected32: 0xff800001, expected64: 0xfff0000000000001},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.input, func(t *testing.T) {
+			v32, err := parseF32(tc.input)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected32, v32)
+
+			v64, err := parseF64(tc.input)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected64, v64)
+		})
+	}
+}
+
+func TestParseFloat_Errors(t *testing.T) {
+	tests := []struct {
+		input, expectedErr string
+	}{
+		{input: "", expectedErr: "malformed number"},
+		{input: "1.e", expectedErr: "malformed number"},
+		{input: "._1", expectedErr: "malformed number"},
+		{input: "0x1p", expectedErr: "malformed number"},
+		{input: "nan:0x0", expectedErr: "invalid NaN payload"},
+		{input: "nan:0x800000", expectedErr: "invalid NaN payload"},
+		{input: "1e39", expectedErr: "constant out of range"},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.input, func(t *testing.T) {
+			_, err := parseF32(tc.input)
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	for _, input := range []string{"0", "-0", "1.5", "0.1", "3.4028235e+38", "1e-45", "inf", "-inf", "nan", "-nan:0x1"} {
+		v32, err := parseF32(input)
+		require.NoError(t, err)
+		require.Equal(t, input, formatFloat(uint64(v32), f32Format))
+	}
+	for _, input := range []string{"0", "-0", "0.1", "1.7976931348623157e+308", "5e-324", "inf", "nan", "nan:0x4000000000000"} {
+		v64, err := parseF64(input)
+		require.NoError(t, err)
+		require.Equal(t, input, formatFloat(v64, f64Format))
+	}
+}
@@ -0,0 +1,329 @@
+package text
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// ScriptCommand is a command of a script in the text format (%.wast), which the specification tests are written in,
+// e.g. "(assert_return (invoke "add" (i32.const 1) (i32.const 2)) (i32.const 3))".
+//
+// See https://github.com/WebAssembly/spec/tree/main/interpreter#scripts
+type ScriptCommand struct {
+	// Type is the keyword of the command, e.g. "assert_return", or "action" for an action which isn't asserted.
+	// "assert_trap" on a module is "assert_uninstantiable", as the trap is in its start function or segments.
+	Type string
+	// Line is the line of the command in the source.
+	Line int
+
+	// Module is set for "module" and assertions about a module, e.g. "assert_invalid".
+	Module *ScriptModule
+
+	// Name is the identifier of the module a "register" registers, e.g. "$M", or empty for the last module.
+	Name string
+	// As is the name a "register" registers the module as, which imports use.
+	As string
+
+	// Action is set for "action" and assertions about an action, e.g. "assert_return".
+	Action *ScriptAction
+	// Expected are the results an "assert_return" expects.
+	Expected []ScriptValue
+
+	// Text is the message of the error an assertion expects, e.g. "integer divide by zero".
+	Text string
+}
+
+// ScriptModule is a module defined by a script.
+type ScriptModule struct {
+	// Name is the identifier of the module, e.g. "$M" for "(module $M)", or empty if it has none.
+	Name string
+	// Module is the decoded module, unless it's in the binary format or is quoted text, e.g. "(module quote ...)".
+	Module *wasm.Module
+	// Binary is the module in the binary format, e.g. "(module binary "\00asm" ...)".
+	Binary []byte
+	// Quote is the source of a module which is quoted text in an assertion. Unlike other modules, it's not decoded,
+	// as the assertion may be that it's malformed.
+	Quote []byte
+	// Err is the error decoding a module in an assertion, which may be what it asserts, e.g. "unknown type".
+	Err error
+}
+
+// ScriptAction is an action of a script, which is either "invoke" of an exported function or "get" of an exported
+// global.
+type ScriptAction struct {
+	// Type is "invoke" or "get".
+	Type string
+	// Module is the identifier of the module, e.g. "$M", or empty for the last module.
+	Module string
+	// Field is the name of the export.
+	Field string
+	// Args are the arguments of an "invoke".
+	Args []ScriptValue
+}
+
+// ScriptValue is a constant argument or expected result of an action, e.g. "(i32.const 1)".
+type ScriptValue struct {
+	// Type is the name of the value type, e.g. "i32" or "externref".
+	Type string
+	// LaneType is the type of the lanes of a "v128", e.g. "i32" for "(v128.const i32x4 1 2 3 4)".
+	LaneType string
+	// Values are the bits of the value in decimal, or of each lane of a "v128", like wast2json writes them. This is
+	// "null" for a null reference, and either "nan:canonical" or "nan:arithmetic" for an expected NaN.
+	Values []string
+}
+
+// DecodeScript parses a script in the text format (%.wast) into its commands. Modules are decoded, but not validated,
+// except those which are quoted text in an assertion. An error decoding a module in an assertion is its ScriptModule.Err
+// rather than an error of the script.
+//
+// See https://github.com/WebAssembly/spec/tree/main/interpreter#scripts
+func DecodeScript(source []byte) ([]*ScriptCommand, error) {
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := parseSExprs(tokens)
+	if err != nil {
+		return nil, err
+	}
+	// A script may be the fields of a module, e.g. "(func) (memory 0)", like the text format.
+	if len(nodes) > 0 && isModuleField(nodes[0]) {
+		m, err := decodeModule(nodes)
+		if err != nil {
+			return nil, err
+		}
+		return []*ScriptCommand{{Type: "module", Line: nodes[0].line, Module: &ScriptModule{Module: m}}}, nil
+	}
+	ret := make([]*ScriptCommand, 0, len(nodes))
+	for _, n := range nodes {
+		c, err := scriptCommand(n)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, c)
+	}
+	return ret, nil
+}
+
+func scriptCommand(n *node) (c *ScriptCommand, err error) {
+	c = &ScriptCommand{Type: n.head(), Line: n.line}
+	switch c.Type {
+	case "module":
+		c.Module, err = scriptModule(n, false)
+	case "register":
+		if len(n.list) < 2 || len(n.list) > 3 || !isName(n.list[1]) {
+			return nil, n.errorf("expected (register \"name\" $module?)")
+		}
+		c.As = n.list[1].text
+		if len(n.list) == 3 {
+			if n.list[2].typ != tokenID {
+				return nil, n.list[2].errorf("expected module identifier, but was %s", describe(n.list[2]))
+			}
+			c.Name = "$" + n.list[2].text
+		}
+	case "invoke", "get":
+		c.Type = "action"
+		c.Action, err = scriptAction(n)
+	case "assert_return":
+		if len(n.list) < 2 {
+			return nil, n.errorf("expected (assert_return action result*)")
+		}
+		if c.Action, err = scriptAction(n.list[1]); err != nil {
+			return nil, err
+		}
+		c.Expected, err = scriptValues(n.list[2:])
+	case "assert_trap", "assert_exhaustion":
+		if len(n.list) != 3 || n.list[2].typ != tokenString {
+			return nil, n.errorf("expected (%s action \"failure\")", c.Type)
+		}
+		c.Text = n.list[2].text
+		if c.Type == "assert_trap" && isList(n.list[1], "module") {
+			c.Type = "assert_uninstantiable"
+			c.Module, err = scriptModule(n.list[1], true)
+		} else {
+			c.Action, err = scriptAction(n.list[1])
+		}
+	case "assert_malformed", "assert_invalid", "assert_unlinkable":
+		if len(n.list) != 3 || !isList(n.list[1], "module") || n.list[2].typ != tokenString {
+			return nil, n.errorf("expected (%s (module ...) \"failure\")", c.Type)
+		}
+		c.Text = n.list[2].text
+		c.Module, err = scriptModule(n.list[1], true)
+	default:
+		return nil, n.errorf("unexpected %s in script", describe(n))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// scriptModule decodes a module, unless it is quoted text in an assertion.
+func scriptModule(n *node, assertion bool) (*ScriptModule, error) {
+	m := &ScriptModule{}
+	rest := n.list[1:]
+	if len(rest) > 0 && rest[0].typ == tokenID {
+		m.Name = "$" + rest[0].text
+		rest = rest[1:]
+	}
+	if len(rest) > 0 && (rest[0].isKeyword("binary") || rest[0].isKeyword("quote")) {
+		b, err := dataStrings(rest[1:])
+		if err != nil {
+			return nil, err
+		}
+		if rest[0].text == "binary" {
+			m.Binary = b
+			return m, nil
+		} else if assertion {
+			m.Quote = b
+			return m, nil
+		}
+		decoded, err := DecodeModule(b)
+		if err != nil {
+			return nil, n.errorf("invalid quoted module: %v", err)
+		}
+		m.Module = decoded
+		return m, nil
+	}
+	decoded, err := decodeModule([]*node{n})
+	if err != nil {
+		if !assertion {
+			return nil, err
+		}
+		m.Err = err
+	}
+	m.Module = decoded
+	return m, nil
+}
+
+// isModuleField returns true if the node is a field of a module, e.g. "(func)".
+func isModuleField(n *node) bool {
+	switch n.head() {
+	case "type", "import", "export", "start", "elem", "data",
+		wasm.ExternTypeFuncName, wasm.ExternTypeTableName, wasm.ExternTypeMemoryName, wasm.ExternTypeGlobalName:
+		return true
+	}
+	return false
+}
+
+// scriptAction parses an action, e.g. "(invoke $M "add" (i32.const 1) (i32.const 2))" or "(get "global")".
+func scriptAction(n *node) (*ScriptAction, error) {
+	a := &ScriptAction{Type: n.head()}
+	if a.Type != "invoke" && a.Type != "get" {
+		return nil, n.errorf("expected (invoke ...) or (get ...), but was %s", describe(n))
+	}
+	rest := n.list[1:]
+	if len(rest) > 0 && rest[0].typ == tokenID {
+		a.Module = "$" + rest[0].text
+		rest = rest[1:]
+	}
+	if len(rest) == 0 || !isName(rest[0]) {
+		return nil, n.errorf("expected (%s $module? \"name\" ...)", a.Type)
+	}
+	a.Field = rest[0].text
+	rest = rest[1:]
+	if a.Type == "get" {
+		if err := expectEnd(rest); err != nil {
+			return nil, err
+		}
+		return a, nil
+	}
+	var err error
+	a.Args, err = scriptValues(rest)
+	return a, err
+}
+
+func scriptValues(nodes []*node) ([]ScriptValue, error) {
+	var ret []ScriptValue
+	for _, n := range nodes {
+		v, err := scriptValue(n)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, v)
+	}
+	return ret, nil
+}
+
+// scriptValue parses a constant, e.g. "(i32.const 1)", "(f32.const nan:canonical)" or "(ref.null extern)".
+func scriptValue(n *node) (ScriptValue, error) {
+	var v ScriptValue
+	args := n.list[1:]
+	switch kw := n.head(); kw {
+	case "i32.const", "i64.const", "f32.const", "f64.const":
+		if len(args) != 1 || args[0].typ != tokenKeyword {
+			return v, n.errorf("expected (%s value)", kw)
+		}
+		v.Type = strings.TrimSuffix(kw, ".const")
+		lane, err := scriptLane(v.Type, args[0].text)
+		if err != nil {
+			return v, args[0].errorf("invalid constant %s: %v", args[0].text, err)
+		}
+		v.Values = []string{lane}
+	case "v128.const":
+		if len(args) == 0 {
+			return v, n.errorf("expected (v128.const shape lane*)")
+		}
+		shape, ok := vectorShapes[args[0].text]
+		if !ok {
+			return v, args[0].errorf("unknown vector shape %s", describe(args[0]))
+		}
+		if len(args) != shape.lanes+1 {
+			return v, n.errorf("expected %d lanes, but was %d", shape.lanes, len(args)-1)
+		}
+		v.Type, v.LaneType = "v128", args[0].text[:strings.IndexByte(args[0].text, 'x')]
+		for _, a := range args[1:] {
+			lane, err := scriptLane(v.LaneType, a.text)
+			if err != nil {
+				return v, a.errorf("invalid lane %s: %v", a.text, err)
+			}
+			v.Values = append(v.Values, lane)
+		}
+	case "ref.null":
+		if len(args) != 1 || (!args[0].isKeyword("func") && !args[0].isKeyword("extern")) {
+			return v, n.errorf("expected (ref.null func) or (ref.null extern)")
+		}
+		v.Type, v.Values = args[0].text+"ref", []string{"null"}
+	case "ref.extern":
+		if len(args) != 1 || args[0].typ != tokenKeyword {
+			return v, n.errorf("expected (ref.extern value)")
+		}
+		bits, err := parseNat(args[0].text, 32)
+		if err != nil {
+			return v, args[0].errorf("invalid constant %s: %v", args[0].text, err)
+		}
+		v.Type, v.Values = "externref", []string{strconv.FormatUint(bits, 10)}
+	default:
+		return v, n.errorf("unexpected %s in script", describe(n))
+	}
+	return v, nil
+}
+
+// scriptLane returns the bits of a number of the type in decimal, e.g. "4294967295" for "i32" "-1", or the pattern of
+// an expected NaN as is.
+func scriptLane(typ, s string) (string, error) {
+	var bits uint64
+	var err error
+	switch typ {
+	case "i8", "i16", "i32", "i64":
+		size, _ := strconv.Atoi(typ[1:])
+		bits, err = parseInt(s, size)
+	case "f32", "f64":
+		if s == "nan:canonical" || s == "nan:arithmetic" {
+			return s, nil
+		}
+		if typ == "f32" {
+			var b uint32
+			b, err = parseF32(s)
+			bits = uint64(b)
+		} else {
+			bits, err = parseF64(s)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatUint(bits, 10), nil
+}
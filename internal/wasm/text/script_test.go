@@ -0,0 +1,169 @@
+package text
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+func TestDecodeScript(t *testing.T) {
+	commands, err := DecodeScript([]byte(`(module $M (func (export "f") (param i32) (result i32) local.get 0))
+(register "m" $M)
+(assert_return (invoke $M "f" (i32.const -1)) (i32.const 0xffffffff))
+(assert_return (invoke "g" (f32.const 1.5) (v128.const i16x8 0 1 2 3 4 5 6 -1))
+  (f64.const nan:canonical) (ref.null extern) (ref.extern 1))
+(get "global")
+(assert_trap (invoke "div" (i32.const 0)) "integer divide by zero")
+(assert_trap (module (func $start unreachable) (start $start)) "unreachable")
+(assert_exhaustion (invoke "loop") "call stack exhausted")
+(assert_malformed (module quote "(func (i32.ad))") "unknown operator")
+(assert_malformed (module binary "\00asm") "unexpected end")
+(assert_invalid (module (func (call 1))) "unknown function")
+(assert_invalid (module (func (call $f))) "unknown function")`))
+	require.NoError(t, err)
+
+	i32 := wasm.ValueTypeI32
+	require.Equal(t, []*ScriptCommand{
+		{
+			Type: "module",
+			Line: 1,
+			Module: &ScriptModule{Name: "$M", Module: &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}}},
+				FunctionSection: []wasm.Index{0},
+				CodeSection:     []*wasm.Code{{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeEnd}}},
+				ExportSection:   []*wasm.Export{{Type: wasm.ExternTypeFunc, Name: "f", Index: 0}},
+				NameSection:     &wasm.NameSection{ModuleName: "M"},
+			}},
+		},
+		{Type: "register", Line: 2, Name: "$M", As: "m"},
+		{
+			Type:     "assert_return",
+			Line:     3,
+			Action:   &ScriptAction{Type: "invoke", Module: "$M", Field: "f", Args: []ScriptValue{{Type: "i32", Values: []string{"4294967295"}}}},
+			Expected: []ScriptValue{{Type: "i32", Values: []string{"4294967295"}}},
+		},
+		{
+			Type: "assert_return",
+			Line: 4,
+			Action: &ScriptAction{Type: "invoke", Field: "g", Args: []ScriptValue{
+				{Type: "f32", Values: []string{"1069547520"}},
+				{Type: "v128", LaneType: "i16", Values: []string{"0", "1", "2", "3", "4", "5", "6", "65535"}},
+			}},
+			Expected: []ScriptValue{
+				{Type: "f64", Values: []string{"nan:canonical"}},
+				{Type: "externref", Values: []string{"null"}},
+				{Type: "externref", Values: []string{"1"}},
+			},
+		},
+		{Type: "action", Line: 6, Action: &ScriptAction{Type: "get", Field: "global"}},
+		{
+			Type:   "assert_trap",
+			Line:   7,
+			Action: &ScriptAction{Type: "invoke", Field: "div", Args: []ScriptValue{{Type: "i32", Values: []string{"0"}}}},
+			Text:   "integer divide by zero",
+		},
+		{
+			Type: "assert_uninstantiable",
+			Line: 8,
+			Module: &ScriptModule{Module: &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{{}},
+				FunctionSection: []wasm.Index{0},
+				CodeSection:     []*wasm.Code{{Body: []byte{wasm.OpcodeUnreachable, wasm.OpcodeEnd}}},
+				StartSection:    &[]wasm.Index{0}[0],
+				NameSection:     &wasm.NameSection{FunctionNames: wasm.NameMap{{Index: 0, Name: "start"}}},
+			}},
+			Text: "unreachable",
+		},
+		{Type: "assert_exhaustion", Line: 9, Action: &ScriptAction{Type: "invoke", Field: "loop"}, Text: "call stack exhausted"},
+		{Type: "assert_malformed", Line: 10, Module: &ScriptModule{Quote: []byte("(func (i32.ad))")}, Text: "unknown operator"},
+		{Type: "assert_malformed", Line: 11, Module: &ScriptModule{Binary: []byte("\x00asm")}, Text: "unexpected end"},
+		{
+			Type: "assert_invalid",
+			Line: 12,
+			Module: &ScriptModule{Module: &wasm.Module{
+				TypeSection:     []*wasm.FunctionType{{}},
+				FunctionSection: []wasm.Index{0},
+				CodeSection:     []*wasm.Code{{Body: []byte{wasm.OpcodeCall, 1, wasm.OpcodeEnd}}},
+			}},
+			Text: "unknown function",
+		},
+		{
+			Type:   "assert_invalid",
+			Line:   13,
+			Module: &ScriptModule{Err: commands[11].Module.Err},
+			Text:   "unknown function",
+		},
+	}, commands)
+	require.EqualError(t, commands[11].Module.Err, "13:37: unknown func $f")
+}
+
+func TestDecodeScript_Module(t *testing.T) {
+	commands, err := DecodeScript([]byte("\n(memory 1)"))
+	require.NoError(t, err)
+	require.Equal(t, []*ScriptCommand{
+		{Type: "module", Line: 2, Module: &ScriptModule{Module: &wasm.Module{
+			MemorySection: &wasm.Memory{Min: 1, Cap: 1, Max: wasm.MemoryLimitPages},
+		}}},
+	}, commands)
+}
+
+func TestDecodeScript_Errors(t *testing.T) {
+	tests := []struct {
+		name, input, expectedErr string
+	}{
+		{
+			name:        "unbalanced",
+			input:       `(module`,
+			expectedErr: "1:1: missing )",
+		},
+		{
+			name:        "unknown command",
+			input:       `(assert_fail (invoke "f"))`,
+			expectedErr: "1:1: unexpected (assert_fail in script",
+		},
+		{
+			name:        "invalid module",
+			input:       `(module (func $f) (func $f))`,
+			expectedErr: "1:19: duplicate func $f",
+		},
+		{
+			name:        "invalid quoted module",
+			input:       `(module quote "(func $f) (func $f)")`,
+			expectedErr: "1:1: invalid quoted module: 1:11: duplicate func $f",
+		},
+		{
+			name:        "missing name of invoke",
+			input:       `(invoke $M)`,
+			expectedErr: `1:1: expected (invoke $module? "name" ...)`,
+		},
+		{
+			name:        "unknown constant",
+			input:       `(invoke "f" (i128.const 1))`,
+			expectedErr: "1:13: unexpected (i128.const in script",
+		},
+		{
+			name:        "constant out of range",
+			input:       `(invoke "f" (i32.const 4294967296))`,
+			expectedErr: "1:24: invalid constant 4294967296: constant out of range",
+		},
+		{
+			name:        "missing lanes",
+			input:       `(invoke "f" (v128.const i64x2 1))`,
+			expectedErr: "1:13: expected 2 lanes, but was 1",
+		},
+		{
+			name:        "missing failure",
+			input:       `(assert_invalid (module))`,
+			expectedErr: `1:1: expected (assert_invalid (module ...) "failure")`,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := DecodeScript([]byte(tc.input))
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
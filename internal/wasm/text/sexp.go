@@ -0,0 +1,59 @@
+package text
+
+// node is an atom or a list of the S-expressions the text format consists of.
+type node struct {
+	// token is the atom, or the left parenthesis which starts the list.
+	token
+	// list are the nodes in the list, which is empty for an atom.
+	list []*node
+}
+
+func (n *node) isList() bool {
+	return n.typ == tokenLParen
+}
+
+// isKeyword returns true if the node is the keyword atom.
+func (n *node) isKeyword(keyword string) bool {
+	return n.typ == tokenKeyword && n.text == keyword
+}
+
+// head returns the keyword starting a list, e.g. "func" for "(func ...)", or empty if there is none.
+func (n *node) head() string {
+	if n.isList() && len(n.list) > 0 && n.list[0].typ == tokenKeyword {
+		return n.list[0].text
+	}
+	return ""
+}
+
+// isList returns true if the node is a list starting with the keyword.
+func isList(n *node, keyword string) bool {
+	return n.head() == keyword
+}
+
+// parseSExprs returns the S-expressions of the tokens, which must be balanced.
+func parseSExprs(tokens []token) ([]*node, error) {
+	var stack []*node
+	root := &node{}
+	current := root
+	for i := range tokens {
+		t := tokens[i]
+		switch t.typ {
+		case tokenLParen:
+			n := &node{token: t}
+			current.list = append(current.list, n)
+			stack = append(stack, current)
+			current = n
+		case tokenRParen:
+			if len(stack) == 0 {
+				return nil, t.errorf("unexpected )")
+			}
+			current, stack = stack[len(stack)-1], stack[:len(stack)-1]
+		default:
+			current.list = append(current.list, &node{token: t})
+		}
+	}
+	if len(stack) > 0 {
+		return nil, current.errorf("missing )")
+	}
+	return root.list, nil
+}
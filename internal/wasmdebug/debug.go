@@ -18,7 +18,8 @@ import (
 // FuncName returns the naming convention of "moduleName.funcName".
 //
 //   - moduleName is the possibly empty name the module was instantiated with.
-//   - funcName is the name in the Custom Name section.
+//   - funcName is the name in the Custom Name section, which is demangled if
+//     it was mangled by the Rust or a C++ compiler.
 //   - funcIdx is the position in the function index namespace, prefixed with
 //     imported functions.
 //
@@ -34,7 +35,7 @@ func FuncName(moduleName, funcName string, funcIdx uint32) string {
 		ret.WriteByte('$')
 		ret.WriteString(strconv.Itoa(int(funcIdx)))
 	} else {
-		ret.WriteString(funcName)
+		ret.WriteString(Demangle(funcName))
 	}
 
 	return ret.String()
@@ -93,14 +94,17 @@ type ErrorBuilder interface {
 	// AddFrame adds the next frame.
 	//
 	// * funcName should be from FuncName
+	// * funcIdx is the position of the function in its module's index namespace.
+	// * offset is the offset of the current instruction in the wasm binary, or zero if unknown.
 	// * paramTypes should be from wasm.FunctionType
 	// * resultTypes should be from wasm.FunctionType
 	// * sources is the source code information for this frame and can be empty.
 	//
 	// Note: paramTypes and resultTypes are present because signature misunderstanding, mismatch or overflow are common.
-	AddFrame(funcName string, paramTypes, resultTypes []api.ValueType, sources []string)
+	AddFrame(funcName string, funcIdx uint32, offset uint64, paramTypes, resultTypes []api.ValueType, sources []string)
 
-	// FromRecovered returns an error with the wasm stack trace appended to it.
+	// FromRecovered returns a sys.TrapError with the wasm stack trace appended to its message, or the recovered
+	// sys.ExitError as-is.
 	FromRecovered(recovered interface{}) error
 }
 
@@ -109,7 +113,21 @@ func NewErrorBuilder() ErrorBuilder {
 }
 
 type stackTrace struct {
-	frames []string
+	frames      []sys.StackFrame
+	traceFrames []string
+}
+
+// trapKinds maps the wasmruntime errors to the corresponding sys.TrapKind.
+var trapKinds = map[*wasmruntime.Error]sys.TrapKind{
+	wasmruntime.ErrRuntimeStackOverflow:              sys.TrapKindStackOverflow,
+	wasmruntime.ErrRuntimeInvalidConversionToInteger: sys.TrapKindInvalidConversionToInteger,
+	wasmruntime.ErrRuntimeIntegerOverflow:            sys.TrapKindIntegerOverflow,
+	wasmruntime.ErrRuntimeIntegerDivideByZero:        sys.TrapKindIntegerDivideByZero,
+	wasmruntime.ErrRuntimeUnreachable:                sys.TrapKindUnreachable,
+	wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess:    sys.TrapKindOutOfBoundsMemoryAccess,
+	wasmruntime.ErrRuntimeInvalidTableAccess:         sys.TrapKindInvalidTableAccess,
+	wasmruntime.ErrRuntimeIndirectCallTypeMismatch:   sys.TrapKindIndirectCallTypeMismatch,
+	wasmruntime.ErrRuntimeFuelExhausted:              sys.TrapKindFuelExhausted,
 }
 
 func (s *stackTrace) FromRecovered(recovered interface{}) error {
@@ -121,33 +139,49 @@ func (s *stackTrace) FromRecovered(recovered interface{}) error {
 		return exitErr
 	}
 
-	stack := strings.Join(s.frames, "\n\t")
+	stack := strings.Join(s.traceFrames, "\n\t")
 
 	// If the error was internal, don't mention it was recovered.
 	if wasmErr, ok := recovered.(*wasmruntime.Error); ok {
-		return fmt.Errorf("wasm error: %w\nwasm stack trace:\n\t%s", wasmErr, stack)
+		kind, ok := trapKinds[wasmErr]
+		if !ok {
+			kind = sys.TrapKindPanic
+		}
+		return s.trapError(kind, wasmErr, fmt.Sprintf("wasm error: %s\nwasm stack trace:\n\t%s", wasmErr, stack))
 	}
 
 	// If we have a runtime.Error, something severe happened which should include the stack trace. This could be
 	// a nil pointer from wazero or a user-defined function from HostModuleBuilder.
 	if runtimeErr, ok := recovered.(runtime.Error); ok {
 		// TODO: consider adding debug.Stack(), but last time we attempted, some tests became unstable.
-		return fmt.Errorf("%w (recovered by wazero)\nwasm stack trace:\n\t%s", runtimeErr, stack)
+		return s.trapError(sys.TrapKindPanic, runtimeErr, fmt.Sprintf("%s (recovered by wazero)\nwasm stack trace:\n\t%s", runtimeErr, stack))
 	}
 
 	// At this point we expect the error was from a function defined by HostModuleBuilder that intentionally called panic.
 	if runtimeErr, ok := recovered.(error); ok { // e.g. panic(errors.New("whoops"))
-		return fmt.Errorf("%w (recovered by wazero)\nwasm stack trace:\n\t%s", runtimeErr, stack)
+		return s.trapError(sys.TrapKindPanic, runtimeErr, fmt.Sprintf("%s (recovered by wazero)\nwasm stack trace:\n\t%s", runtimeErr, stack))
 	} else { // e.g. panic("whoops")
-		return fmt.Errorf("%v (recovered by wazero)\nwasm stack trace:\n\t%s", recovered, stack)
+		return s.trapError(sys.TrapKindPanic, nil, fmt.Sprintf("%v (recovered by wazero)\nwasm stack trace:\n\t%s", recovered, stack))
 	}
 }
 
+func (s *stackTrace) trapError(kind sys.TrapKind, cause error, message string) error {
+	return sys.NewTrapError(kind, cause, s.frames, message)
+}
+
 // AddFrame implements ErrorBuilder.AddFrame
-func (s *stackTrace) AddFrame(funcName string, paramTypes, resultTypes []api.ValueType, sources []string) {
+func (s *stackTrace) AddFrame(funcName string, funcIdx uint32, offset uint64, paramTypes, resultTypes []api.ValueType, sources []string) {
+	s.frames = append(s.frames, sys.StackFrame{
+		FunctionIndex: funcIdx,
+		FunctionName:  funcName,
+		ParamTypes:    paramTypes,
+		ResultTypes:   resultTypes,
+		Offset:        offset,
+		Sources:       sources,
+	})
 	sig := signature(funcName, paramTypes, resultTypes)
-	s.frames = append(s.frames, sig)
+	s.traceFrames = append(s.traceFrames, sig)
 	for _, source := range sources {
-		s.frames = append(s.frames, "\t"+source)
+		s.traceFrames = append(s.traceFrames, "\t"+source)
 	}
 }
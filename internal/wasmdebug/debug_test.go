@@ -8,6 +8,7 @@ import (
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/internal/testing/require"
 	"github.com/tetratelabs/wazero/internal/wasmruntime"
+	"github.com/tetratelabs/wazero/sys"
 )
 
 func TestFuncName(t *testing.T) {
@@ -25,6 +26,7 @@ func TestFuncName(t *testing.T) {
 		{name: "dots in function", moduleName: "x", funcName: "y.z", expected: "x.y.z"},
 		{name: "spaces in module", moduleName: "w x", funcName: "y", expected: "w x.y"},
 		{name: "spaces in function", moduleName: "x", funcName: "y z", expected: "x.y z"},
+		{name: "mangled function", moduleName: "x", funcName: "_ZN3foo3barEv", expected: "x.foo::bar()"},
 	}
 
 	for _, tt := range tests {
@@ -64,6 +66,28 @@ func TestAddSignature(t *testing.T) {
 	}
 }
 
+func TestErrorBuilder_Frames(t *testing.T) {
+	builder := NewErrorBuilder()
+	builder.AddFrame("x.z", 1, 0, []api.ValueType{api.ValueTypeI32}, nil, nil)
+	builder.AddFrame("x.y", 2, 0x2a, nil, nil, []string{"main.go:1:2"})
+	err := builder.FromRecovered(wasmruntime.ErrRuntimeUnreachable)
+
+	trapErr, ok := err.(*sys.TrapError)
+	require.True(t, ok)
+	require.Equal(t, sys.TrapKindUnreachable, trapErr.Kind())
+	require.Equal(t, []sys.StackFrame{
+		{FunctionIndex: 1, FunctionName: "x.z", ParamTypes: []api.ValueType{api.ValueTypeI32}},
+		{FunctionIndex: 2, FunctionName: "x.y", Offset: 0x2a, Sources: []string{"main.go:1:2"}},
+	}, trapErr.Frames())
+}
+
+func TestErrorBuilder_ExitError(t *testing.T) {
+	exitErr := sys.NewExitError("x", 1)
+	builder := NewErrorBuilder()
+	builder.AddFrame("x.y", 0, 0, nil, nil, nil)
+	require.Equal(t, exitErr, builder.FromRecovered(exitErr))
+}
+
 func TestErrorBuilder(t *testing.T) {
 	argErr := errors.New("invalid argument")
 	rteErr := testRuntimeErr("index out of bounds")
@@ -75,11 +99,12 @@ func TestErrorBuilder(t *testing.T) {
 		build        func(ErrorBuilder) error
 		expectedErr  string
 		expectUnwrap error
+		expectKind   sys.TrapKind
 	}{
 		{
 			name: "one",
 			build: func(builder ErrorBuilder) error {
-				builder.AddFrame("x.y", nil, nil, nil)
+				builder.AddFrame("x.y", 0, 0, nil, nil, nil)
 				return builder.FromRecovered(argErr)
 			},
 			expectedErr: `invalid argument (recovered by wazero)
@@ -90,8 +115,8 @@ wasm stack trace:
 		{
 			name: "two",
 			build: func(builder ErrorBuilder) error {
-				builder.AddFrame("wasi_snapshot_preview1.fd_write", i32i32i32i32, []api.ValueType{i32}, nil)
-				builder.AddFrame("x.y", nil, nil, nil)
+				builder.AddFrame("wasi_snapshot_preview1.fd_write", 0, 0, i32i32i32i32, []api.ValueType{i32}, nil)
+				builder.AddFrame("x.y", 0, 0, nil, nil, nil)
 				return builder.FromRecovered(argErr)
 			},
 			expectedErr: `invalid argument (recovered by wazero)
@@ -103,8 +128,8 @@ wasm stack trace:
 		{
 			name: "runtime.Error",
 			build: func(builder ErrorBuilder) error {
-				builder.AddFrame("wasi_snapshot_preview1.fd_write", i32i32i32i32, []api.ValueType{i32}, nil)
-				builder.AddFrame("x.y", nil, nil, nil)
+				builder.AddFrame("wasi_snapshot_preview1.fd_write", 0, 0, i32i32i32i32, []api.ValueType{i32}, nil)
+				builder.AddFrame("x.y", 0, 0, nil, nil, nil)
 				return builder.FromRecovered(rteErr)
 			},
 			expectedErr: `index out of bounds (recovered by wazero)
@@ -116,9 +141,9 @@ wasm stack trace:
 		{
 			name: "wasmruntime.Error",
 			build: func(builder ErrorBuilder) error {
-				builder.AddFrame("wasi_snapshot_preview1.fd_write", i32i32i32i32, []api.ValueType{i32},
+				builder.AddFrame("wasi_snapshot_preview1.fd_write", 0, 0, i32i32i32i32, []api.ValueType{i32},
 					[]string{"/opt/homebrew/Cellar/tinygo/0.26.0/src/runtime/runtime_tinygowasm.go:73:6"})
-				builder.AddFrame("x.y", nil, nil, nil)
+				builder.AddFrame("x.y", 0, 0, nil, nil, nil)
 				return builder.FromRecovered(wasmruntime.ErrRuntimeStackOverflow)
 			},
 			expectedErr: `wasm error: stack overflow
@@ -127,6 +152,7 @@ wasm stack trace:
 		/opt/homebrew/Cellar/tinygo/0.26.0/src/runtime/runtime_tinygowasm.go:73:6
 	x.y()`,
 			expectUnwrap: wasmruntime.ErrRuntimeStackOverflow,
+			expectKind:   sys.TrapKindStackOverflow,
 		},
 	}
 
@@ -136,6 +162,11 @@ wasm stack trace:
 			withStackTrace := tc.build(NewErrorBuilder())
 			require.Equal(t, tc.expectUnwrap, errors.Unwrap(withStackTrace))
 			require.EqualError(t, withStackTrace, tc.expectedErr)
+
+			trapErr, ok := withStackTrace.(*sys.TrapError)
+			require.True(t, ok)
+			require.Equal(t, tc.expectKind, trapErr.Kind())
+			require.Equal(t, "x.y", trapErr.Frames()[len(trapErr.Frames())-1].FunctionName)
 		})
 	}
 }
@@ -0,0 +1,45 @@
+package wasmdebug
+
+import "strings"
+
+// Demangle returns the human-readable form of a function name mangled by the
+// Rust compiler (legacy or v0) or a C++ compiler (Itanium ABI), as opposed
+// to the name in the source. Otherwise, or if the name is malformed, the name
+// is returned unchanged.
+//
+// For example, "_ZN4core3fmt5write17h0123456789abcdefE" demangles to
+// "core::fmt::write::h0123456789abcdef", and "_ZNK3Foo3getEi" to
+// "Foo::get(int) const".
+func Demangle(name string) string {
+	// LLVM appends suffixes such as ".llvm.123" to local symbols. These
+	// begin with a single '.', as legacy Rust symbols escape "::" as "..".
+	mangled, suffix := name, ""
+	for i := 1; i < len(name); i++ {
+		if name[i] != '.' {
+			continue
+		} else if i+1 < len(name) && name[i+1] == '.' {
+			i++
+			continue
+		}
+		mangled, suffix = name[:i], name[i:]
+		break
+	}
+
+	var demangled string
+	var ok bool
+	switch {
+	case strings.HasPrefix(mangled, "_R"):
+		demangled, ok = demangleRust(mangled[2:])
+	case strings.HasPrefix(mangled, "_ZN") && strings.HasSuffix(mangled, "E") && isRustLegacyHash(mangled):
+		demangled, ok = demangleRustLegacy(mangled[3 : len(mangled)-1])
+	case strings.HasPrefix(mangled, "_Z"):
+		demangled, ok = demangleCXX(mangled[2:])
+		if ok && suffix != "" {
+			demangled += " [clone " + suffix + "]"
+		}
+	}
+	if !ok {
+		return name
+	}
+	return demangled
+}
@@ -0,0 +1,608 @@
+package wasmdebug
+
+import (
+	"strconv"
+	"strings"
+)
+
+// demangleCXX demangles a C++ symbol of the Itanium ABI, without its "_Z"
+// prefix. This supports the names of functions and variables, and the common
+// special names, but not expressions, such as in decltype.
+//
+// See https://itanium-cxx-abi.github.io/cxx-abi/abi.html#mangling
+func demangleCXX(s string) (string, bool) {
+	p := &cxxParser{s: s}
+	ret := p.encoding()
+	if p.err || p.pos != len(s) {
+		return "", false
+	}
+	return ret, true
+}
+
+// cxxParser parses a C++ symbol, printing each part as it goes.
+type cxxParser struct {
+	s   string
+	pos int
+	err bool
+	// subs are the components which can be substituted, in order.
+	subs []cxxType
+	// templateArgs are the arguments of the template of the function, which
+	// template parameters refer to.
+	tmplArgs []cxxType
+	// depth bounds the recursion of nested names.
+	depth int
+}
+
+// cxxType is a printed type, whose right part must follow any declarator, e.g.
+// the parameters of a function type: "void (*)(int)".
+type cxxType struct {
+	left, right string
+	// wrap is true if a declarator must be parenthesized, as for a function
+	// or array type.
+	wrap bool
+}
+
+func (t cxxType) String() string {
+	return t.left + t.right
+}
+
+// cxxName is a printed name, and how it's declared.
+type cxxName struct {
+	name string
+	// template is true if the name ends with template arguments, and isn't a
+	// constructor, destructor or conversion operator. The first type of the
+	// parameters of a template function is its result.
+	template bool
+	// quals are the qualifiers of a member function, e.g. " const".
+	quals string
+}
+
+var cxxBuiltinTypes = map[byte]string{
+	'v': "void", 'w': "wchar_t", 'b': "bool", 'c': "char", 'a': "signed char", 'h': "unsigned char",
+	's': "short", 't': "unsigned short", 'i': "int", 'j': "unsigned int", 'l': "long", 'm': "unsigned long",
+	'x': "long long", 'y': "unsigned long long", 'n': "__int128", 'o': "unsigned __int128", 'f': "float",
+	'd': "double", 'e': "long double", 'g': "__float128", 'z': "...",
+}
+
+var cxxExtendedBuiltinTypes = map[byte]string{
+	'n': "decltype(nullptr)", 'i': "char32_t", 's': "char16_t", 'u': "char8_t", 'a': "auto",
+	'c': "decltype(auto)", 'd': "decimal64", 'e': "decimal128", 'f': "decimal32", 'h': "half",
+}
+
+var cxxStdSubstitutions = map[byte]string{
+	'a': "std::allocator", 'b': "std::basic_string", 's': "std::string",
+	'i': "std::istream", 'o': "std::ostream", 'd': "std::iostream",
+}
+
+var cxxOperators = map[string]string{
+	"nw": "new", "na": "new[]", "dl": "delete", "da": "delete[]", "ps": "+", "ng": "-", "ad": "&", "de": "*",
+	"co": "~", "pl": "+", "mi": "-", "ml": "*", "dv": "/", "rm": "%", "an": "&", "or": "|", "eo": "^",
+	"aS": "=", "pL": "+=", "mI": "-=", "mL": "*=", "dV": "/=", "rM": "%=", "aN": "&=", "oR": "|=", "eO": "^=",
+	"ls": "<<", "rs": ">>", "lS": "<<=", "rS": ">>=", "eq": "==", "ne": "!=", "lt": "<", "gt": ">",
+	"le": "<=", "ge": ">=", "ss": "<=>", "nt": "!", "aa": "&&", "oo": "||", "pp": "++", "mm": "--",
+	"cm": ",", "pm": "->*", "pt": "->", "cl": "()", "ix": "[]", "qu": "?", "aw": "co_await",
+}
+
+func (p *cxxParser) peek() byte {
+	if p.err || p.pos >= len(p.s) {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *cxxParser) next() byte {
+	c := p.peek()
+	if c == 0 {
+		p.err = true
+		return 0
+	}
+	p.pos++
+	return c
+}
+
+func (p *cxxParser) eat(prefix string) bool {
+	if !p.err && strings.HasPrefix(p.s[p.pos:], prefix) {
+		p.pos += len(prefix)
+		return true
+	}
+	return false
+}
+
+func (p *cxxParser) encoding() string {
+	if special, ok := p.specialName(); ok {
+		return special
+	}
+
+	n := p.name(true)
+	if c := p.peek(); c == 0 || c == 'E' { // not a function
+		return n.name
+	}
+
+	if n.template { // skip the result
+		p.typ()
+	}
+	var params []string
+	for c := p.peek(); c != 0 && c != 'E'; c = p.peek() {
+		params = append(params, p.typ().String())
+	}
+	if len(params) == 1 && params[0] == "void" {
+		params = nil
+	}
+	return n.name + "(" + strings.Join(params, ", ") + ")" + n.quals
+}
+
+func (p *cxxParser) specialName() (string, bool) {
+	switch {
+	case p.eat("TV"):
+		return "vtable for " + p.typ().String(), true
+	case p.eat("TT"):
+		return "VTT for " + p.typ().String(), true
+	case p.eat("TI"):
+		return "typeinfo for " + p.typ().String(), true
+	case p.eat("TS"):
+		return "typeinfo name for " + p.typ().String(), true
+	case p.eat("Th"):
+		p.callOffset()
+		return "non-virtual thunk to " + p.encoding(), true
+	case p.eat("Tv"):
+		p.callOffset()
+		p.callOffset()
+		return "virtual thunk to " + p.encoding(), true
+	case p.eat("GV"):
+		return "guard variable for " + p.name(true).name, true
+	}
+	return "", false
+}
+
+// callOffset skips an offset of a thunk, e.g. "n8_".
+func (p *cxxParser) callOffset() {
+	p.eat("n")
+	p.number()
+	if p.next() != '_' {
+		p.err = true
+	}
+}
+
+func (p *cxxParser) name(top bool) cxxName {
+	if p.depth++; p.depth > 100 {
+		p.err = true
+	}
+	defer func() { p.depth-- }()
+
+	switch c := p.peek(); {
+	case c == 'N':
+		p.pos++
+		return p.nestedName(top)
+	case c == 'Z':
+		p.pos++
+		return p.localName()
+	case p.eat("St"):
+		return p.unscopedName(top, "std::")
+	case c == 'S':
+		sub := p.substitution().String()
+		if p.peek() != 'I' {
+			p.err = true // only a template name can be substituted here
+		}
+		return cxxName{name: sub + p.templateArgs(top), template: true}
+	}
+	return p.unscopedName(top, "")
+}
+
+func (p *cxxParser) unscopedName(top bool, prefix string) cxxName {
+	name, _ := p.unqualifiedName("")
+	name = prefix + name
+	if p.peek() != 'I' {
+		return cxxName{name: name}
+	}
+	p.subs = append(p.subs, cxxType{left: name})
+	return cxxName{name: name + p.templateArgs(top), template: true}
+}
+
+func (p *cxxParser) nestedName(top bool) cxxName {
+	var ret cxxName
+	if p.eat("r") {
+		ret.quals += " restrict"
+	}
+	if p.eat("V") {
+		ret.quals += " volatile"
+	}
+	if p.eat("K") {
+		ret.quals += " const"
+	}
+	if p.eat("R") {
+		ret.quals += " &"
+	} else if p.eat("O") {
+		ret.quals += " &&"
+	}
+
+	var prefix, last string
+	for !p.err && !p.eat("E") {
+		candidate := true
+		ret.template = false
+		switch c := p.peek(); {
+		case p.eat("St"):
+			prefix, candidate = "std", false
+			continue
+		case c == 'S':
+			prefix, candidate = p.substitution().String(), false
+			last = prefix[strings.LastIndex(prefix, "::")+1:]
+		case c == 'I':
+			if prefix == "" {
+				p.err = true
+			}
+			prefix += p.templateArgs(top)
+			ret.template = last != ""
+		case c == 'T':
+			prefix = p.templateParam().String()
+		case c == 'L': // internal linkage
+			p.pos++
+			continue
+		default:
+			name, ctorDtor := p.unqualifiedName(last)
+			if prefix != "" {
+				prefix += "::"
+			}
+			prefix += name
+			if last = name; ctorDtor {
+				last = ""
+			}
+		}
+		if candidate && p.peek() != 'E' {
+			p.subs = append(p.subs, cxxType{left: prefix})
+		}
+	}
+	if prefix == "" {
+		p.err = true
+	}
+	ret.name = prefix
+	return ret
+}
+
+func (p *cxxParser) localName() cxxName {
+	function := p.encoding()
+	if p.next() != 'E' {
+		p.err = true
+	}
+	var entity cxxName
+	if p.eat("s") {
+		entity.name = "string literal"
+	} else {
+		entity = p.name(false)
+	}
+	// Skip the discriminator.
+	if p.eat("__") {
+		p.number()
+		p.eat("_")
+	} else if p.eat("_") {
+		p.number()
+	}
+	entity.name = function + "::" + entity.name
+	return entity
+}
+
+// unqualifiedName returns the name, and true if it's a constructor or
+// destructor, whose name is the one of its class, enclosing is.
+func (p *cxxParser) unqualifiedName(enclosing string) (name string, ctorDtor bool) {
+	switch c := p.peek(); {
+	case isDigit(c):
+		name = p.sourceName()
+		if strings.HasPrefix(name, "_GLOBAL__N") {
+			name = "(anonymous namespace)"
+		}
+	case c == 'C' || c == 'D' && isDigit(p.s[min(p.pos+1, len(p.s)-1)]):
+		p.pos++
+		p.eat("I") // inheriting constructor
+		if d := p.next(); !isDigit(d) || enclosing == "" {
+			p.err = true
+		}
+		// Remove any template arguments of the class.
+		if i := strings.IndexByte(enclosing, '<'); i > 0 {
+			enclosing = enclosing[:i]
+		}
+		if c == 'D' {
+			enclosing = "~" + enclosing
+		}
+		name, ctorDtor = enclosing, true
+	case p.eat("Ut"):
+		name = "{unnamed type#" + p.discriminator() + "}"
+	case p.eat("Ul"):
+		var params []string
+		for !p.err && !p.eat("E") {
+			params = append(params, p.typ().String())
+		}
+		if len(params) == 1 && params[0] == "void" {
+			params = nil
+		}
+		name = "{lambda(" + strings.Join(params, ", ") + ")#" + p.discriminator() + "}"
+	case p.eat("cv"):
+		name, ctorDtor = "operator "+p.typ().String(), true
+	case p.eat("li"):
+		name = "operator\"\" " + p.sourceName()
+	case isLower(c):
+		if p.pos+2 > len(p.s) {
+			p.err = true
+			break
+		}
+		op, ok := cxxOperators[p.s[p.pos:p.pos+2]]
+		if !ok {
+			p.err = true
+			break
+		}
+		p.pos += 2
+		if isLower(op[0]) {
+			name = "operator " + op
+		} else {
+			name = "operator" + op
+		}
+	default:
+		p.err = true
+	}
+	for p.eat("B") { // ABI tags
+		name += "[abi:" + p.sourceName() + "]"
+	}
+	return
+}
+
+// discriminator returns the one-based number of an unnamed type or lambda.
+func (p *cxxParser) discriminator() string {
+	n := 1
+	if !p.eat("_") {
+		n = p.number() + 2
+		if p.next() != '_' {
+			p.err = true
+		}
+	}
+	return strconv.Itoa(n)
+}
+
+func (p *cxxParser) sourceName() string {
+	length := p.number()
+	if p.err || length <= 0 || p.pos+length > len(p.s) {
+		p.err = true
+		return ""
+	}
+	name := p.s[p.pos : p.pos+length]
+	p.pos += length
+	return name
+}
+
+func (p *cxxParser) number() int {
+	start := p.pos
+	for p.pos < len(p.s) && isDigit(p.s[p.pos]) {
+		p.pos++
+	}
+	n, err := strconv.Atoi(p.s[start:p.pos])
+	if err != nil {
+		p.err = true
+	}
+	return n
+}
+
+// seqID returns the index of a substitution or template parameter, which is
+// zero for "_", or otherwise one plus the number in base 36 before "_".
+func (p *cxxParser) seqID() int {
+	if p.eat("_") {
+		return 0
+	}
+	start := p.pos
+	for c := p.peek(); isDigit(c) || isUpper(c); c = p.peek() {
+		p.pos++
+	}
+	n, err := strconv.ParseUint(p.s[start:p.pos], 36, 32)
+	if err != nil || p.next() != '_' {
+		p.err = true
+	}
+	return int(n) + 1
+}
+
+func (p *cxxParser) substitution() cxxType {
+	if p.next() != 'S' {
+		p.err = true
+		return cxxType{}
+	}
+	if sub, ok := cxxStdSubstitutions[p.peek()]; ok {
+		p.pos++
+		return cxxType{left: sub}
+	}
+	i := p.seqID()
+	if p.err || i >= len(p.subs) {
+		p.err = true
+		return cxxType{}
+	}
+	return p.subs[i]
+}
+
+func (p *cxxParser) templateParam() cxxType {
+	if p.next() != 'T' {
+		p.err = true
+		return cxxType{}
+	}
+	i := p.seqID()
+	if p.err || i >= len(p.tmplArgs) {
+		p.err = true
+		return cxxType{}
+	}
+	return p.tmplArgs[i]
+}
+
+// templateArgs returns the printed template arguments. When top is true,
+// they are the ones template parameters refer to.
+func (p *cxxParser) templateArgs(top bool) string {
+	if p.next() != 'I' {
+		p.err = true
+		return ""
+	}
+	args := p.templateArgList()
+	if top {
+		p.tmplArgs = args
+	}
+	printed := make([]string, len(args))
+	for i, a := range args {
+		printed[i] = a.String()
+	}
+	return "<" + strings.Join(printed, ", ") + ">"
+}
+
+func (p *cxxParser) templateArgList() (args []cxxType) {
+	for !p.err && !p.eat("E") {
+		switch {
+		case p.eat("L"):
+			args = append(args, cxxType{left: p.literal()})
+		case p.eat("J"): // pack
+			args = append(args, p.templateArgList()...)
+		default:
+			args = append(args, p.typ())
+		}
+	}
+	return
+}
+
+// literal returns a literal template argument, after its "L" prefix.
+func (p *cxxParser) literal() string {
+	if p.eat("_Z") {
+		ret := p.encoding()
+		if p.next() != 'E' {
+			p.err = true
+		}
+		return ret
+	}
+	t := p.typ().String()
+	negative := p.eat("n")
+	value := strconv.Itoa(p.number())
+	if p.next() != 'E' {
+		p.err = true
+	}
+	if negative {
+		value = "-" + value
+	}
+	switch t {
+	case "bool":
+		if value == "0" {
+			return "false"
+		}
+		return "true"
+	case "int":
+		return value
+	case "unsigned int":
+		return value + "u"
+	case "long":
+		return value + "l"
+	case "unsigned long":
+		return value + "ul"
+	}
+	return "(" + t + ")" + value
+}
+
+func (p *cxxParser) typ() cxxType {
+	if p.depth++; p.depth > 100 {
+		p.err = true
+	}
+	defer func() { p.depth-- }()
+
+	c := p.peek()
+	if t, ok := cxxBuiltinTypes[c]; ok {
+		p.pos++
+		return cxxType{left: t}
+	}
+
+	var ret cxxType
+	switch c {
+	case 'r', 'V', 'K':
+		var quals string
+		for q := p.peek(); q == 'r' || q == 'V' || q == 'K'; q = p.peek() {
+			p.pos++
+			quals = map[byte]string{'r': " restrict", 'V': " volatile", 'K': " const"}[q] + quals
+		}
+		ret = p.typ()
+		ret.left += quals
+	case 'P', 'R', 'O':
+		p.pos++
+		declarator := map[byte]string{'P': "*", 'R': "&", 'O': "&&"}[c]
+		ret = p.typ()
+		if ret.wrap {
+			ret = cxxType{left: ret.left + "(" + declarator, right: ")" + ret.right}
+		} else {
+			ret.left += declarator
+		}
+	case 'F':
+		p.pos++
+		p.eat("Y") // extern "C"
+		result := p.typ().String()
+		var params []string
+		for !p.err && !p.eat("E") {
+			if p.eat("R") || p.eat("O") { // ref-qualifier
+				continue
+			}
+			params = append(params, p.typ().String())
+		}
+		if len(params) == 1 && params[0] == "void" {
+			params = nil
+		}
+		ret = cxxType{left: result + " ", right: "(" + strings.Join(params, ", ") + ")", wrap: true}
+	case 'A':
+		p.pos++
+		var size string
+		if !p.eat("_") {
+			size = strconv.Itoa(p.number())
+			if p.next() != '_' {
+				p.err = true
+			}
+		}
+		ret = cxxType{left: p.typ().String() + " ", right: "[" + size + "]", wrap: true}
+	case 'M':
+		p.pos++
+		class := p.typ().String()
+		member := p.typ()
+		if member.wrap {
+			ret = cxxType{left: member.left + "(" + class + "::*", right: ")" + member.right}
+		} else {
+			ret = cxxType{left: member.left + " " + class + "::*", right: member.right}
+		}
+	case 'T':
+		ret = p.templateParam()
+		if p.peek() == 'I' {
+			p.subs = append(p.subs, ret)
+			ret = cxxType{left: ret.String() + p.templateArgs(false)}
+		}
+	case 'S':
+		if p.eat("St") {
+			ret = cxxType{left: p.unscopedName(false, "std::").name}
+			break
+		}
+		ret = p.substitution()
+		if p.peek() != 'I' {
+			return ret // already a candidate
+		}
+		ret = cxxType{left: ret.String() + p.templateArgs(false)}
+	case 'D':
+		p.pos++
+		if p.eat("p") { // pack expansion
+			ret = p.typ()
+			ret.left += "..."
+			break
+		}
+		t, ok := cxxExtendedBuiltinTypes[p.next()]
+		if !ok {
+			p.err = true
+		}
+		return cxxType{left: t}
+	case 'u': // vendor extended type
+		p.pos++
+		return cxxType{left: p.sourceName()}
+	default:
+		if c != 'N' && c != 'Z' && !isDigit(c) {
+			p.err = true
+			return cxxType{}
+		}
+		ret = cxxType{left: p.name(false).name}
+	}
+	p.subs = append(p.subs, ret)
+	return ret
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
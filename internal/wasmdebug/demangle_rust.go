@@ -0,0 +1,468 @@
+package wasmdebug
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// isRustLegacyHash returns true if the mangled name ends with the hash the
+// Rust compiler appends to legacy symbols, e.g. "17h0123456789abcdefE". This
+// distinguishes them from C++ names, which use the same mangling.
+func isRustLegacyHash(mangled string) bool {
+	const hashLen = len("17h0123456789abcdefE")
+	if len(mangled) < hashLen {
+		return false
+	}
+	hash := mangled[len(mangled)-hashLen:]
+	if !strings.HasPrefix(hash, "17h") {
+		return false
+	}
+	for i := 3; i < hashLen-1; i++ {
+		if !isHexDigit(hash[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// demangleRustLegacy demangles the path of a legacy Rust symbol, without its
+// "_ZN" prefix and "E" suffix, e.g. "4core3fmt5write17h0123456789abcdef".
+func demangleRustLegacy(s string) (string, bool) {
+	var ret strings.Builder
+	for len(s) > 0 {
+		n := 0
+		for n < len(s) && isDigit(s[n]) {
+			n++
+		}
+		length, err := strconv.Atoi(s[:n])
+		if err != nil || length == 0 || n+length > len(s) {
+			return "", false
+		}
+		ident := s[n : n+length]
+		s = s[n+length:]
+
+		if ret.Len() > 0 {
+			ret.WriteString("::")
+		}
+		if !unescapeRustLegacy(&ret, ident) {
+			return "", false
+		}
+	}
+	return ret.String(), true
+}
+
+// rustLegacyEscapes are the characters escaped in identifiers of legacy Rust
+// symbols, as "$name$".
+var rustLegacyEscapes = map[string]string{
+	"SP": "@", "BP": "*", "RF": "&", "LT": "<", "GT": ">", "LP": "(", "RP": ")", "C": ",",
+}
+
+func unescapeRustLegacy(ret *strings.Builder, ident string) bool {
+	// A leading '$' is escaped with an underscore.
+	if strings.HasPrefix(ident, "_$") {
+		ident = ident[1:]
+	}
+	for i := 0; i < len(ident); {
+		switch {
+		case ident[i] == '$':
+			end := strings.IndexByte(ident[i+1:], '$')
+			if end < 0 {
+				return false
+			}
+			escape := ident[i+1 : i+1+end]
+			if r, ok := rustLegacyEscapes[escape]; ok {
+				ret.WriteString(r)
+			} else if strings.HasPrefix(escape, "u") {
+				c, err := strconv.ParseUint(escape[1:], 16, 32)
+				if err != nil || !utf8.ValidRune(rune(c)) {
+					return false
+				}
+				ret.WriteRune(rune(c))
+			} else {
+				return false
+			}
+			i += end + 2
+		case strings.HasPrefix(ident[i:], ".."):
+			ret.WriteString("::")
+			i += 2
+		default:
+			ret.WriteByte(ident[i])
+			i++
+		}
+	}
+	return true
+}
+
+// demangleRust demangles a Rust v0 symbol, without its "_R" prefix.
+//
+// See https://doc.rust-lang.org/rustc/symbol-mangling/v0.html
+func demangleRust(s string) (string, bool) {
+	p := &rustParser{s: s}
+	for p.pos < len(s) && isDigit(s[p.pos]) { // encoding version
+		p.pos++
+	}
+	ret := p.path(true)
+	if p.pos < len(s) && isUpper(s[p.pos]) { // instantiating crate
+		p.path(false)
+	}
+	if p.err || p.pos != len(s) {
+		return "", false
+	}
+	return ret, true
+}
+
+// rustParser parses a Rust v0 symbol, printing each part as it goes.
+type rustParser struct {
+	s   string
+	pos int
+	err bool
+	// depth bounds the recursion of backrefs.
+	depth int
+}
+
+// rustBasicTypes are the names of the types encoded in one lower case letter.
+var rustBasicTypes = map[byte]string{
+	'a': "i8", 'b': "bool", 'c': "char", 'd': "f64", 'e': "str", 'f': "f32", 'h': "u8", 'i': "isize",
+	'j': "usize", 'l': "i32", 'm': "u32", 'n': "i128", 'o': "u128", 's': "i16", 't': "u16", 'u': "()",
+	'v': "...", 'x': "i64", 'y': "u64", 'z': "!", 'p': "_",
+}
+
+func (p *rustParser) next() byte {
+	if p.err || p.pos >= len(p.s) {
+		p.err = true
+		return 0
+	}
+	c := p.s[p.pos]
+	p.pos++
+	return c
+}
+
+func (p *rustParser) eat(c byte) bool {
+	if !p.err && p.pos < len(p.s) && p.s[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// path prints a path, with "::" before generic arguments if value is true, as
+// opposed to a path in a type.
+func (p *rustParser) path(value bool) string {
+	switch p.next() {
+	case 'C': // crate root
+		p.disambiguator()
+		return p.ident()
+	case 'M': // <T>
+		p.disambiguator()
+		p.path(false)
+		return "<" + p.typ() + ">"
+	case 'X': // <T as Trait>
+		p.disambiguator()
+		p.path(false)
+		t := p.typ()
+		return "<" + t + " as " + p.path(false) + ">"
+	case 'Y': // <T as Trait>
+		t := p.typ()
+		return "<" + t + " as " + p.path(false) + ">"
+	case 'N': // prefix::name
+		ns := p.next()
+		prefix := p.path(value)
+		dis := p.disambiguator()
+		name := p.ident()
+		switch {
+		case isLower(ns):
+			return prefix + "::" + name
+		case ns == 'C':
+			if name != "" {
+				name = ":" + name
+			}
+			return prefix + "::{closure" + name + "#" + strconv.FormatUint(dis, 10) + "}"
+		case ns == 'S':
+			if name != "" {
+				name = ":" + name
+			}
+			return prefix + "::{shim" + name + "#" + strconv.FormatUint(dis, 10) + "}"
+		case isUpper(ns):
+			return prefix + "::{" + string(ns) + ":" + name + "#" + strconv.FormatUint(dis, 10) + "}"
+		}
+		p.err = true
+		return ""
+	case 'I': // prefix<args>
+		prefix := p.path(value)
+		var args []string
+		for !p.err && !p.eat('E') {
+			args = append(args, p.genericArg())
+		}
+		if value {
+			prefix += "::"
+		}
+		return prefix + "<" + strings.Join(args, ", ") + ">"
+	case 'B':
+		var ret string
+		p.backref(func() { ret = p.path(value) })
+		return ret
+	}
+	p.err = true
+	return ""
+}
+
+func (p *rustParser) genericArg() string {
+	if p.eat('L') {
+		p.base62()
+		return "'_"
+	} else if p.eat('K') {
+		return p.constant()
+	}
+	return p.typ()
+}
+
+func (p *rustParser) typ() string {
+	if p.err || p.pos >= len(p.s) {
+		p.err = true
+		return ""
+	}
+	if t, ok := rustBasicTypes[p.s[p.pos]]; ok {
+		p.pos++
+		return t
+	}
+	switch c := p.s[p.pos]; c {
+	case 'A':
+		p.pos++
+		t := p.typ()
+		return "[" + t + "; " + p.constant() + "]"
+	case 'S':
+		p.pos++
+		return "[" + p.typ() + "]"
+	case 'T':
+		p.pos++
+		var types []string
+		for !p.err && !p.eat('E') {
+			types = append(types, p.typ())
+		}
+		if len(types) == 1 {
+			return "(" + types[0] + ",)"
+		}
+		return "(" + strings.Join(types, ", ") + ")"
+	case 'R', 'Q':
+		p.pos++
+		ref := "&"
+		if p.eat('L') {
+			if p.base62() != 0 {
+				ref += "'_ "
+			}
+		}
+		if c == 'Q' {
+			ref += "mut "
+		}
+		return ref + p.typ()
+	case 'P':
+		p.pos++
+		return "*const " + p.typ()
+	case 'O':
+		p.pos++
+		return "*mut " + p.typ()
+	case 'F':
+		p.pos++
+		return p.fnSig()
+	case 'D':
+		p.pos++
+		return p.dynBounds()
+	case 'B':
+		p.pos++
+		var ret string
+		p.backref(func() { ret = p.typ() })
+		return ret
+	}
+	return p.path(false)
+}
+
+func (p *rustParser) fnSig() string {
+	var ret strings.Builder
+	if p.eat('G') { // binder
+		p.base62()
+	}
+	if p.eat('U') {
+		ret.WriteString("unsafe ")
+	}
+	if p.eat('K') {
+		abi := "C"
+		if !p.eat('C') {
+			abi = strings.ReplaceAll(p.undisambiguatedIdent(), "_", "-")
+		}
+		ret.WriteString("extern \"" + abi + "\" ")
+	}
+	var params []string
+	for !p.err && !p.eat('E') {
+		params = append(params, p.typ())
+	}
+	ret.WriteString("fn(" + strings.Join(params, ", ") + ")")
+	if result := p.typ(); result != "()" {
+		ret.WriteString(" -> " + result)
+	}
+	return ret.String()
+}
+
+func (p *rustParser) dynBounds() string {
+	if p.eat('G') { // binder
+		p.base62()
+	}
+	var traits []string
+	for !p.err && !p.eat('E') {
+		trait := p.path(false)
+		var bindings []string
+		for p.eat('p') {
+			name := p.undisambiguatedIdent()
+			bindings = append(bindings, name+" = "+p.typ())
+		}
+		if len(bindings) > 0 {
+			trait += "<" + strings.Join(bindings, ", ") + ">"
+		}
+		traits = append(traits, trait)
+	}
+	if p.next() != 'L' { // lifetime
+		p.err = true
+	}
+	p.base62()
+	return "dyn " + strings.Join(traits, " + ")
+}
+
+func (p *rustParser) constant() string {
+	if p.eat('p') {
+		return "_"
+	} else if p.eat('B') {
+		var ret string
+		p.backref(func() { ret = p.constant() })
+		return ret
+	}
+
+	t := p.next()
+	negative := p.eat('n')
+	start := p.pos
+	for !p.err && !p.eat('_') {
+		if !isHexDigit(p.next()) {
+			p.err = true
+		}
+	}
+	if p.err {
+		return ""
+	}
+	v, err := strconv.ParseUint(p.s[start:p.pos-1], 16, 64)
+	if err != nil && p.pos-1 > start {
+		p.err = true
+		return ""
+	}
+	switch t {
+	case 'b':
+		if v > 1 {
+			p.err = true
+		}
+		return strconv.FormatBool(v == 1)
+	case 'c':
+		if !utf8.ValidRune(rune(v)) {
+			p.err = true
+		}
+		return strconv.QuoteRune(rune(v))
+	case 'a', 's', 'l', 'x', 'n', 'i', 'h', 't', 'm', 'y', 'o', 'j':
+		if negative {
+			return "-" + strconv.FormatUint(v, 10)
+		}
+		return strconv.FormatUint(v, 10)
+	}
+	p.err = true
+	return ""
+}
+
+// backref calls parse at the position of the backref, which must be before
+// it, then restores the position.
+func (p *rustParser) backref(parse func()) {
+	start := p.pos - 1
+	target := p.base62()
+	if p.err || target >= uint64(start) || p.depth > 100 {
+		p.err = true
+		return
+	}
+	pos := p.pos
+	p.pos = int(target)
+	p.depth++
+	parse()
+	p.depth--
+	p.pos = pos
+}
+
+// disambiguator returns the optional disambiguator, or zero if absent.
+func (p *rustParser) disambiguator() uint64 {
+	if !p.eat('s') {
+		return 0
+	}
+	return p.base62() + 1
+}
+
+func (p *rustParser) base62() uint64 {
+	if p.eat('_') {
+		return 0
+	}
+	var v uint64
+	for !p.err && !p.eat('_') {
+		c := p.next()
+		var digit byte
+		switch {
+		case isDigit(c):
+			digit = c - '0'
+		case isLower(c):
+			digit = 10 + c - 'a'
+		case isUpper(c):
+			digit = 36 + c - 'A'
+		default:
+			p.err = true
+			return 0
+		}
+		v = v*62 + uint64(digit)
+	}
+	return v + 1
+}
+
+func (p *rustParser) ident() string {
+	p.disambiguator()
+	return p.undisambiguatedIdent()
+}
+
+func (p *rustParser) undisambiguatedIdent() string {
+	if p.eat('u') { // punycode isn't supported
+		p.err = true
+		return ""
+	}
+	start := p.pos
+	for p.pos < len(p.s) && isDigit(p.s[p.pos]) {
+		p.pos++
+	}
+	length, err := strconv.Atoi(p.s[start:p.pos])
+	if err != nil {
+		p.err = true
+		return ""
+	}
+	p.eat('_')
+	if p.pos+length > len(p.s) {
+		p.err = true
+		return ""
+	}
+	ident := p.s[p.pos : p.pos+length]
+	p.pos += length
+	return ident
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isLower(c byte) bool {
+	return 'a' <= c && c <= 'z'
+}
+
+func isUpper(c byte) bool {
+	return 'A' <= c && c <= 'Z'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || ('a' <= c && c <= 'f')
+}
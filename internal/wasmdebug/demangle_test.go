@@ -0,0 +1,63 @@
+package wasmdebug
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestDemangle(t *testing.T) {
+	tests := []struct {
+		name, input, expected string
+	}{
+		{name: "not mangled", input: "main", expected: "main"},
+		{name: "not mangled with prefix", input: "_Zfoo", expected: "_Zfoo"},
+		{name: "already demangled", input: "std::panicking::rust_panic_with_hook::h93e119628869d575", expected: "std::panicking::rust_panic_with_hook::h93e119628869d575"},
+		{name: "c++ truncated", input: "_ZN3foo3bar", expected: "_ZN3foo3bar"},
+		{name: "c++ function", input: "_Z3addii", expected: "add(int, int)"},
+		{name: "c++ nested", input: "_ZN3foo3barEv", expected: "foo::bar()"},
+		{name: "c++ const method", input: "_ZNK3Foo3getEv", expected: "Foo::get() const"},
+		{name: "c++ constructor", input: "_ZN3FooC2Ev", expected: "Foo::Foo()"},
+		{name: "c++ destructor", input: "_ZN3FooD1Ev", expected: "Foo::~Foo()"},
+		{name: "c++ operator", input: "_ZN3FooplERKS_", expected: "Foo::operator+(Foo const&)"},
+		{name: "c++ template function", input: "_Z3maxIiET_S0_S0_", expected: "max<int>(int, int)"},
+		{
+			name:     "c++ std",
+			input:    "_ZNSt6vectorIiSaIiEE9push_backERKi",
+			expected: "std::vector<int, std::allocator<int>>::push_back(int const&)",
+		},
+		{
+			name:     "c++ substitutions",
+			input:    "_ZN9__gnu_cxx13new_allocatorIcE8allocateEmPKv",
+			expected: "__gnu_cxx::new_allocator<char>::allocate(unsigned long, void const*)",
+		},
+		{
+			name:     "c++ libc++",
+			input:    "_ZNKSt3__112basic_stringIcNS_11char_traitsIcEENS_9allocatorIcEEE4sizeEv",
+			expected: "std::__1::basic_string<char, std::__1::char_traits<char>, std::__1::allocator<char>>::size() const",
+		},
+		{name: "c++ function pointer", input: "_Z5applyPFviE", expected: "apply(void (*)(int))"},
+		{name: "c++ anonymous namespace", input: "_ZN12_GLOBAL__N_13fooEv", expected: "(anonymous namespace)::foo()"},
+		{name: "c++ vtable", input: "_ZTV3Foo", expected: "vtable for Foo"},
+		{name: "c++ clone", input: "_Z3fooi.cold", expected: "foo(int) [clone .cold]"},
+		{name: "rust legacy", input: "_ZN4core3fmt5write17h0123456789abcdefE", expected: "core::fmt::write::h0123456789abcdef"},
+		{
+			name:     "rust legacy escapes",
+			input:    "_ZN66_$LT$alloc..vec..Vec$LT$T$GT$$u20$as$u20$core..ops..drop..Drop$GT$4drop17h0123456789abcdefE",
+			expected: "<alloc::vec::Vec<T> as core::ops::drop::Drop>::drop::h0123456789abcdef",
+		},
+		{name: "rust legacy llvm suffix", input: "_ZN4core3fmt5write17h0123456789abcdefE.llvm.123", expected: "core::fmt::write::h0123456789abcdef"},
+		{name: "rust v0", input: "_RNvNtCs1234_7mycrate3foo3bar", expected: "mycrate::foo::bar"},
+		{name: "rust v0 generic", input: "_RINvCs1234_7mycrate3fooNtB2_3BarE", expected: "mycrate::foo::<mycrate::Bar>"},
+		{name: "rust v0 closure", input: "_RNCNvCs1234_7mycrate4main0", expected: "mycrate::main::{closure#0}"},
+		{name: "rust v0 basic types", input: "_RINvCs1234_7mycrate3fooplE", expected: "mycrate::foo::<_, i32>"},
+		{name: "rust v0 invalid", input: "_RNvC", expected: "_RNvC"},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, Demangle(tc.input))
+		})
+	}
+}
@@ -75,6 +75,7 @@ func TestDWARFLines_Line_Zig(t *testing.T) {
 	// codeSecStart is the beginning of the code section in the Wasm binary.
 	// If dwarftestdata.ZigWasm has been changed, we need to inspect by `wasm-tools objdump`.
 	const codeSecStart = 0x108
+	require.Equal(t, uint64(codeSecStart), mod.CodeSectionOffset)
 
 	// These cases are crafted by matching the stack trace result from wasmtime. To verify, run:
 	//
@@ -0,0 +1,144 @@
+package wasmdebug
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SourceMap is used to retrieve source code line information from a source
+// map, as emitted by AssemblyScript or Emscripten instead of DWARF.
+//
+// The format is https://sourcemaps.info/spec.html, where the generated column
+// of each mapping is an offset in the Wasm binary, all in the first line.
+type SourceMap struct {
+	// codeSectionOffset is the offset of the code section in the Wasm binary,
+	// which the offsets of instructions are relative to.
+	codeSectionOffset uint64
+	sources           []string
+	// mappings are sorted in the increasing order by the offset.
+	mappings []mapping
+}
+
+type mapping struct {
+	// offset is the offset in the Wasm binary.
+	offset uint64
+	// source is the index in SourceMap.sources, or -1 if unmapped.
+	source       int
+	line, column int64
+}
+
+// NewSourceMap returns a SourceMap decoded from the JSON of a source map, for
+// a Wasm binary whose code section begins at codeSectionOffset.
+func NewSourceMap(data []byte, codeSectionOffset uint64) (*SourceMap, error) {
+	var sm struct {
+		Version    int      `json:"version"`
+		SourceRoot string   `json:"sourceRoot"`
+		Sources    []string `json:"sources"`
+		Mappings   string   `json:"mappings"`
+	}
+	if err := json.Unmarshal(data, &sm); err != nil {
+		return nil, fmt.Errorf("invalid source map: %w", err)
+	} else if sm.Version != 3 {
+		return nil, fmt.Errorf("unsupported source map version: %d", sm.Version)
+	}
+
+	ret := &SourceMap{codeSectionOffset: codeSectionOffset, sources: sm.Sources}
+	if root := sm.SourceRoot; root != "" {
+		if !strings.HasSuffix(root, "/") {
+			root += "/"
+		}
+		for i, s := range ret.sources {
+			ret.sources[i] = root + s
+		}
+	}
+
+	// The fields of each segment are relative to the previous segment, and
+	// only the first line has offsets in the Wasm binary.
+	var offset, source, line, column int64
+	mappings := sm.Mappings
+	if i := strings.IndexByte(mappings, ';'); i >= 0 {
+		mappings = mappings[:i]
+	}
+	for _, segment := range strings.Split(mappings, ",") {
+		if segment == "" {
+			continue
+		}
+		fields, err := decodeVLQ(segment)
+		if err != nil {
+			return nil, err
+		}
+		offset += fields[0]
+		m := mapping{offset: uint64(offset), source: -1}
+		switch len(fields) {
+		case 1: // unmapped
+		case 4, 5:
+			source, line, column = source+fields[1], line+fields[2], column+fields[3]
+			if source < 0 || source >= int64(len(ret.sources)) {
+				return nil, fmt.Errorf("invalid source map: source %d out of range", source)
+			}
+			m.source, m.line, m.column = int(source), line, column
+		default:
+			return nil, fmt.Errorf("invalid source map: segment %s has %d fields", segment, len(fields))
+		}
+		ret.mappings = append(ret.mappings, m)
+	}
+	sort.SliceStable(ret.mappings, func(i, j int) bool { return ret.mappings[i].offset < ret.mappings[j].offset })
+	return ret, nil
+}
+
+// Line returns the line information for the given instructionOffset which is an offset in
+// the code section of the original Wasm binary. Returns empty string if the info is not found.
+func (s *SourceMap) Line(instructionOffset uint64) (ret []string) {
+	if s == nil {
+		return
+	}
+
+	// The mapping which contains the instruction is the last one before it.
+	offset := s.codeSectionOffset + instructionOffset
+	index := sort.Search(len(s.mappings), func(i int) bool { return s.mappings[i].offset > offset })
+	if index == 0 {
+		return
+	}
+	m := s.mappings[index-1]
+	if m.source < 0 {
+		return
+	}
+
+	// Lines and columns are zero-based in source maps.
+	prefix := fmt.Sprintf("%#x: ", instructionOffset)
+	return []string{formatLine(prefix, s.sources[m.source], m.line+1, m.column+1, false)}
+}
+
+const base64Alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// decodeVLQ decodes the Base64 VLQ fields of a source map segment.
+func decodeVLQ(segment string) (fields []int64, err error) {
+	var value int64
+	var shift uint
+	for i := 0; i < len(segment); i++ {
+		digit := strings.IndexByte(base64Alphabet, segment[i])
+		if digit < 0 {
+			return nil, fmt.Errorf("invalid source map: invalid character %q in segment %s", segment[i], segment)
+		} else if shift > 60 {
+			return nil, fmt.Errorf("invalid source map: overflow in segment %s", segment)
+		}
+		value |= int64(digit&0x1f) << shift
+		if digit&0x20 != 0 { // continuation
+			shift += 5
+			continue
+		}
+		// The least significant bit is the sign.
+		if value&1 != 0 {
+			fields = append(fields, -(value >> 1))
+		} else {
+			fields = append(fields, value>>1)
+		}
+		value, shift = 0, 0
+	}
+	if shift != 0 {
+		return nil, fmt.Errorf("invalid source map: truncated segment %s", segment)
+	}
+	return
+}
@@ -0,0 +1,93 @@
+package wasmdebug_test
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasmdebug"
+)
+
+func TestSourceMap_Line(t *testing.T) {
+	// The code section begins at 10 in the binary, and the segments map:
+	//	* 10 to a.ts:1:1
+	//	* 12 to a.ts:3:2
+	//	* 14 to nothing
+	//	* 16 to b.ts:2:1
+	sm, err := wasmdebug.NewSourceMap([]byte(`{
+	"version": 3,
+	"sourceRoot": "src",
+	"sources": ["a.ts", "b.ts"],
+	"names": [],
+	"mappings": "UAAA,EAEC,E,ECDD"
+}`), 10)
+	require.NoError(t, err)
+
+	tests := []struct {
+		offset uint64
+		exp    []string
+	}{
+		{offset: 0, exp: []string{"0x0: src/a.ts:1:1"}},
+		{offset: 1, exp: []string{"0x1: src/a.ts:1:1"}},
+		{offset: 2, exp: []string{"0x2: src/a.ts:3:2"}},
+		{offset: 4},
+		{offset: 6, exp: []string{"0x6: src/b.ts:2:1"}},
+		{offset: 100, exp: []string{"0x64: src/b.ts:2:1"}},
+	}
+	for _, tc := range tests {
+		require.Equal(t, tc.exp, sm.Line(tc.offset), tc.offset)
+	}
+
+	// Nothing is mapped before the first segment.
+	sm, err = wasmdebug.NewSourceMap([]byte(`{"version":3,"sources":["a.ts"],"mappings":"UAAA"}`), 0)
+	require.NoError(t, err)
+	require.Nil(t, sm.Line(5))
+	require.Equal(t, []string{"0xa: a.ts:1:1"}, sm.Line(10))
+
+	// A nil source map has no lines, like a nil DWARFLines.
+	require.Nil(t, (*wasmdebug.SourceMap)(nil).Line(0))
+}
+
+func TestNewSourceMap_Errors(t *testing.T) {
+	tests := []struct {
+		name, input, expectedErr string
+	}{
+		{
+			name:        "invalid json",
+			input:       `{`,
+			expectedErr: "invalid source map: unexpected end of JSON input",
+		},
+		{
+			name:        "unsupported version",
+			input:       `{"version":2}`,
+			expectedErr: "unsupported source map version: 2",
+		},
+		{
+			name:        "invalid character",
+			input:       `{"version":3,"sources":["a.ts"],"mappings":"A!AA"}`,
+			expectedErr: `invalid source map: invalid character '!' in segment A!AA`,
+		},
+		{
+			name:        "truncated segment",
+			input:       `{"version":3,"sources":["a.ts"],"mappings":"g"}`,
+			expectedErr: "invalid source map: truncated segment g",
+		},
+		{
+			name:        "invalid field count",
+			input:       `{"version":3,"sources":["a.ts"],"mappings":"AA"}`,
+			expectedErr: "invalid source map: segment AA has 2 fields",
+		},
+		{
+			name:        "source out of range",
+			input:       `{"version":3,"sources":["a.ts"],"mappings":"ACAA"}`,
+			expectedErr: "invalid source map: source 1 out of range",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := wasmdebug.NewSourceMap([]byte(tc.input), 0)
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
@@ -27,6 +27,12 @@ var (
 	ErrRuntimeInvalidTableAccess = New("invalid table access")
 	// ErrRuntimeIndirectCallTypeMismatch indicates that the type check failed during call_indirect.
 	ErrRuntimeIndirectCallTypeMismatch = New("indirect call type mismatch")
+	// ErrRuntimeReadOnlyMemoryAccess indicates that the program tried to write a
+	// page of the memory made read-only by api.Memory Protect.
+	ErrRuntimeReadOnlyMemoryAccess = New("read-only memory access")
+	// ErrRuntimeFuelExhausted indicates that a metered function call consumed
+	// all of its experimental.Fuel.
+	ErrRuntimeFuelExhausted = New("fuel exhausted")
 )
 
 // Error is returned by a wasm.Engine during the execution of Wasm functions, and they indicate that the Wasm runtime
@@ -0,0 +1,330 @@
+package wast
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/leb128"
+	"github.com/tetratelabs/wazero/internal/sys"
+	"github.com/tetratelabs/wazero/internal/u64"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	binaryformat "github.com/tetratelabs/wazero/internal/wasm/binary"
+	"github.com/tetratelabs/wazero/internal/wasmruntime"
+)
+
+// ErrSkipped is returned by Runner.Exec for assertions which can't be run, as
+// their module is quoted text, e.g. "(assert_malformed (module quote ...))".
+var ErrSkipped = errors.New("quoted module is not supported")
+
+// Runner runs the commands of a Script in order against a store, whose
+// namespace includes the "spectest" module of the default test harness.
+type Runner struct {
+	ctx   context.Context
+	store *wasm.Store
+	ns    *wasm.Namespace
+	// readFile reads a module file named by a command.
+	readFile func(filename string) ([]byte, error)
+
+	// lastModuleName is the name of the module actions apply to by default.
+	lastModuleName string
+	// uninstantiable counts modules instantiated by "assert_uninstantiable",
+	// which need a unique name.
+	uninstantiable int
+}
+
+// NewRunner returns a new Runner for a Script, where readFile reads the
+// module files it names, usually relative to the script.
+func NewRunner(ctx context.Context, engine wasm.Engine, enabledFeatures api.CoreFeatures, readFile func(filename string) ([]byte, error)) (*Runner, error) {
+	s, ns := wasm.NewStore(enabledFeatures, engine)
+	r := &Runner{ctx: ctx, store: s, ns: ns, readFile: readFile}
+	if err := r.addSpectestModule(); err != nil {
+		return nil, fmt.Errorf("spectest module: %w", err)
+	}
+	return r, nil
+}
+
+// Exec runs the command, returning an error describing why it failed, or
+// ErrSkipped if it couldn't be run.
+func (r *Runner) Exec(c *Command) error {
+	switch c.CommandType {
+	case "module":
+		buf, err := r.readFile(c.Filename)
+		if err != nil {
+			return err
+		}
+		moduleName := c.Name
+		if moduleName == "" {
+			// Use the file name as the name.
+			moduleName = c.Filename
+		}
+		if err = r.instantiate(buf, moduleName); err != nil {
+			return err
+		}
+		r.lastModuleName = moduleName
+	case "register":
+		src := c.Name
+		if src == "" {
+			src = r.lastModuleName
+		}
+		if err := r.ns.AliasModule(src, c.As); err != nil {
+			return err
+		}
+		r.lastModuleName = c.As
+	case "assert_return", "action":
+		switch c.Action.ActionType {
+		case "invoke":
+			args, exps := c.getAssertReturnArgsExps()
+			vals, types, err := r.callFunction(c, args)
+			if err != nil {
+				return fmt.Errorf("%s: %w", c.invocation(), err)
+			}
+			if len(exps) != len(vals) {
+				return fmt.Errorf("%s: have %d results, want %d", c.invocation(), len(vals), len(exps))
+			}
+			laneTypes := map[int]laneType{}
+			for i, expV := range c.Exps {
+				if expV.ValType == "v128" {
+					laneTypes[i] = expV.LaneType
+				}
+			}
+			if matched, valuesMsg := valuesEq(vals, exps, types, laneTypes); !matched {
+				return fmt.Errorf("%s: unexpected results\n%s", c.invocation(), valuesMsg)
+			}
+		case "get":
+			_, exps := c.getAssertReturnArgsExps()
+			if len(exps) != 1 {
+				return fmt.Errorf("get %s: want 1 expected value, have %d", c.Action.Field, len(exps))
+			}
+			module := r.ns.Module(r.moduleName(c))
+			if module == nil {
+				return fmt.Errorf("get %s: module %q not found", c.Action.Field, r.moduleName(c))
+			}
+			global := module.ExportedGlobal(c.Action.Field)
+			if global == nil {
+				return fmt.Errorf("get %s: global not found", c.Action.Field)
+			}
+			var expType wasm.ValueType
+			switch c.Exps[0].ValType {
+			case "i32":
+				expType = wasm.ValueTypeI32
+			case "i64":
+				expType = wasm.ValueTypeI64
+			case "f32":
+				expType = wasm.ValueTypeF32
+			case "f64":
+				expType = wasm.ValueTypeF64
+			}
+			if global.Type() != expType {
+				return fmt.Errorf("get %s: have type %s, want %s", c.Action.Field,
+					wasm.ValueTypeName(global.Type()), wasm.ValueTypeName(expType))
+			}
+			if global.Get() != exps[0] {
+				return fmt.Errorf("get %s: have %d, want %d", c.Action.Field, global.Get(), exps[0])
+			}
+		default:
+			return fmt.Errorf("unsupported action type: %s", c.Action.ActionType)
+		}
+	case "assert_malformed", "assert_invalid", "assert_unlinkable":
+		if c.ModuleType == "text" {
+			return ErrSkipped
+		}
+		if c.moduleErr != nil && c.CommandType != "assert_unlinkable" {
+			return nil // the module is malformed or invalid, as asserted.
+		}
+		return r.requireInstantiationError(c)
+	case "assert_trap", "assert_exhaustion":
+		if c.Action.ActionType != "invoke" {
+			return fmt.Errorf("unsupported action type: %s", c.Action.ActionType)
+		}
+		var expectedErr error = wasmruntime.ErrRuntimeStackOverflow
+		if c.CommandType == "assert_trap" {
+			expectedErr = c.expectedError()
+		}
+		_, _, err := r.callFunction(c, c.getAssertReturnArgs())
+		if !errors.Is(err, expectedErr) {
+			return fmt.Errorf("%s: have error %v, want %v", c.invocation(), err, expectedErr)
+		}
+	case "assert_uninstantiable":
+		if c.moduleErr != nil || c.Text != "out of bounds table access" {
+			return r.requireInstantiationError(c)
+		}
+		// This is not actually an instantiation error, but assert_trap in the original wast, but wast2json translates it to assert_uninstantiable.
+		// Anyway, this spectest case expects the error due to active element offset ouf of bounds
+		// "after" instantiation while retaining function instances used for elements.
+		// https://github.com/WebAssembly/spec/blob/d39195773112a22b245ffbe864bab6d1182ccb06/test/core/linking.wast#L264-L274
+		//
+		// In practice, such a module instance can be used for invoking functions without any issue. In addition, we have to
+		// retain functions after the expected "instantiation" failure, so in wazero we choose to not raise error in that case.
+		buf, err := r.readFile(c.Filename)
+		if err != nil {
+			return err
+		}
+		return r.instantiate(buf, r.uninstantiableName())
+	default:
+		return fmt.Errorf("unsupported command type: %s", c.CommandType)
+	}
+	return nil
+}
+
+// instantiate decodes, validates, compiles and instantiates the module in
+// the namespace with the given name.
+func (r *Runner) instantiate(buf []byte, moduleName string) error {
+	mod, err := r.compile(buf)
+	if err != nil {
+		return err
+	}
+	_, err = r.store.Instantiate(r.ctx, r.ns, mod, moduleName, nil)
+	return err
+}
+
+func (r *Runner) compile(buf []byte) (*wasm.Module, error) {
+	mod, err := binaryformat.DecodeModule(buf, r.store.EnabledFeatures, wasm.MemoryLimitPages, false, false, false)
+	if err != nil {
+		return nil, err
+	}
+	if err = mod.Validate(r.store.EnabledFeatures); err != nil {
+		return nil, err
+	}
+	mod.AssignModuleID(buf, false)
+
+	maybeSetMemoryCap(mod)
+	mod.BuildFunctionDefinitions()
+	if err = r.store.Engine.CompileModule(r.ctx, mod, nil, false); err != nil {
+		return nil, err
+	}
+	return mod, nil
+}
+
+// requireInstantiationError returns an error unless the module of the
+// command fails to decode, validate, compile or instantiate.
+func (r *Runner) requireInstantiationError(c *Command) error {
+	if c.moduleErr != nil {
+		return fmt.Errorf("%s: %w", c.CommandType, c.moduleErr)
+	}
+	buf, err := r.readFile(c.Filename)
+	if err != nil {
+		return err
+	}
+	if err = r.instantiate(buf, r.uninstantiableName()); err == nil {
+		return fmt.Errorf("%s: expected an error instantiating %s", c.CommandType, c.Filename)
+	}
+	return nil
+}
+
+// uninstantiableName returns a unique name for a module expected to fail
+// instantiation, in case it doesn't.
+func (r *Runner) uninstantiableName() string {
+	r.uninstantiable++
+	return "uninstantiable" + strconv.Itoa(r.uninstantiable)
+}
+
+// moduleName returns the name of the module the action of the command
+// applies to.
+func (r *Runner) moduleName(c *Command) string {
+	if c.Action.Module != "" {
+		return c.Action.Module
+	}
+	return r.lastModuleName
+}
+
+// callFunction is inlined here as the spectest needs to validate the signature was correct
+// TODO: This is likely already covered with unit tests!
+func (r *Runner) callFunction(c *Command, params []uint64) ([]uint64, []wasm.ValueType, error) {
+	moduleName := r.moduleName(c)
+	module := r.ns.Module(moduleName)
+	if module == nil {
+		return nil, nil, fmt.Errorf("module %q not found", moduleName)
+	}
+	fn := module.ExportedFunction(c.Action.Field)
+	if fn == nil {
+		return nil, nil, fmt.Errorf("function %q not found in module %q", c.Action.Field, moduleName)
+	}
+	results, err := fn.Call(r.ctx, params...)
+	return results, fn.Definition().ResultTypes(), err
+}
+
+// invocation describes the function invoked by the command, for error
+// messages.
+func (c *Command) invocation() string {
+	msg := fmt.Sprintf("invoke %s (%s)", c.Action.Field, c.Action.Args)
+	if c.Action.Module != "" {
+		msg += " in module " + c.Action.Module
+	}
+	return msg
+}
+
+// spectestWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names spectest.wat
+//
+//go:embed testdata/spectest.wasm
+var spectestWasm []byte
+
+// addSpectestModule adds a module that drops inputs and returns globals as 666 per the default test harness.
+//
+// See https://github.com/WebAssembly/spec/blob/wg-1.0/test/core/imports.wast
+// See https://github.com/WebAssembly/spec/blob/wg-1.0/interpreter/script/js.ml#L13-L25
+func (r *Runner) addSpectestModule() error {
+	mod, err := binaryformat.DecodeModule(spectestWasm, api.CoreFeaturesV2, wasm.MemoryLimitPages, false, false, false)
+	if err != nil {
+		return err
+	}
+
+	// (global (export "global_i32") i32 (i32.const 666))
+	mod.GlobalSection = append(mod.GlobalSection, &wasm.Global{
+		Type: &wasm.GlobalType{ValType: wasm.ValueTypeI32},
+		Init: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: leb128.EncodeInt32(666)},
+	})
+	mod.ExportSection = append(mod.ExportSection, &wasm.Export{Name: "global_i32", Index: 0, Type: wasm.ExternTypeGlobal})
+
+	// (global (export "global_i64") i64 (i32.const 666))
+	mod.GlobalSection = append(mod.GlobalSection, &wasm.Global{
+		Type: &wasm.GlobalType{ValType: wasm.ValueTypeI64},
+		Init: &wasm.ConstantExpression{Opcode: wasm.OpcodeI64Const, Data: leb128.EncodeInt32(666)},
+	})
+	mod.ExportSection = append(mod.ExportSection, &wasm.Export{Name: "global_i64", Index: 1, Type: wasm.ExternTypeGlobal})
+
+	// (global (export "global_f32") f32 (f32.const 666))
+	mod.GlobalSection = append(mod.GlobalSection, &wasm.Global{
+		Type: &wasm.GlobalType{ValType: wasm.ValueTypeF32},
+		Init: &wasm.ConstantExpression{Opcode: wasm.OpcodeF32Const, Data: u64.LeBytes(api.EncodeF32(666))},
+	})
+	mod.ExportSection = append(mod.ExportSection, &wasm.Export{Name: "global_f32", Index: 2, Type: wasm.ExternTypeGlobal})
+
+	// (global (export "global_f64") f64 (f64.const 666))
+	mod.GlobalSection = append(mod.GlobalSection, &wasm.Global{
+		Type: &wasm.GlobalType{ValType: wasm.ValueTypeF64},
+		Init: &wasm.ConstantExpression{Opcode: wasm.OpcodeF64Const, Data: u64.LeBytes(api.EncodeF64(666))},
+	})
+	mod.ExportSection = append(mod.ExportSection, &wasm.Export{Name: "global_f64", Index: 3, Type: wasm.ExternTypeGlobal})
+
+	//  (table (export "table") 10 20 funcref)
+	tableLimitMax := uint32(20)
+	mod.TableSection = []*wasm.Table{{Min: 10, Max: &tableLimitMax, Type: wasm.RefTypeFuncref}}
+	mod.ExportSection = append(mod.ExportSection, &wasm.Export{Name: "table", Index: 0, Type: wasm.ExternTypeTable})
+
+	maybeSetMemoryCap(mod)
+	mod.BuildFunctionDefinitions()
+
+	if err = mod.Validate(r.store.EnabledFeatures); err != nil {
+		return err
+	}
+
+	if err = r.store.Engine.CompileModule(r.ctx, mod, nil, false); err != nil {
+		return err
+	}
+
+	_, err = r.store.Instantiate(r.ctx, r.ns, mod, mod.NameSection.ModuleName, sys.DefaultContext(nil))
+	return err
+}
+
+// maybeSetMemoryCap assigns wasm.Memory Cap to Min, which is what wazero.CompileModule would do.
+func maybeSetMemoryCap(mod *wasm.Module) {
+	if mem := mod.MemorySection; mem != nil {
+		mem.Cap = mem.Min
+	}
+}
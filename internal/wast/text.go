@@ -0,0 +1,76 @@
+package wast
+
+import (
+	"fmt"
+	"strings"
+
+	binaryformat "github.com/tetratelabs/wazero/internal/wasm/binary"
+	"github.com/tetratelabs/wazero/internal/wasm/text"
+)
+
+// DecodeScript decodes a script in the text format (%.wast) into the Script
+// wast2json would convert it to, and the module files the Script names,
+// which are like those wast2json writes, e.g. "i32.0.wasm".
+func DecodeScript(sourceFile string, source []byte) (*Script, map[string][]byte, error) {
+	commands, err := text.DecodeScript(source)
+	if err != nil {
+		return nil, nil, err
+	}
+	base := strings.TrimSuffix(sourceFile, ".wast")
+	script := &Script{SourceFile: sourceFile, Commands: make([]Command, 0, len(commands))}
+	files := map[string][]byte{}
+	var modules int
+	for _, tc := range commands {
+		c := Command{
+			CommandType: tc.Type,
+			Line:        tc.Line,
+			Name:        tc.Name,
+			As:          tc.As,
+			Exps:        values(tc.Expected),
+			Text:        tc.Text,
+		}
+		if m := tc.Module; m != nil {
+			c.Name = m.Name
+			c.ModuleType = "binary"
+			c.Filename = fmt.Sprintf("%s.%d.wasm", base, modules)
+			switch {
+			case m.Err != nil:
+				c.moduleErr = m.Err
+			case m.Quote != nil:
+				c.ModuleType = "text"
+				c.Filename = fmt.Sprintf("%s.%d.wat", base, modules)
+				files[c.Filename] = m.Quote
+			case m.Binary != nil:
+				files[c.Filename] = m.Binary
+			default:
+				files[c.Filename] = binaryformat.EncodeModule(m.Module)
+			}
+			modules++
+		}
+		if a := tc.Action; a != nil {
+			c.Action = Action{ActionType: a.Type, Module: a.Module, Field: a.Field, Args: values(a.Args)}
+		}
+		script.Commands = append(script.Commands, c)
+	}
+	return script, files, nil
+}
+
+// values converts constants of the text format to the Value wast2json would
+// convert them to.
+func values(vs []text.ScriptValue) []Value {
+	ret := make([]Value, 0, len(vs))
+	for _, v := range vs {
+		value := Value{ValType: v.Type, LaneType: v.LaneType}
+		if v.Type == "v128" {
+			lanes := make([]interface{}, 0, len(v.Values))
+			for _, l := range v.Values {
+				lanes = append(lanes, l)
+			}
+			value.Value = lanes
+		} else {
+			value.Value = v.Values[0]
+		}
+		ret = append(ret, value)
+	}
+	return ret
+}
@@ -0,0 +1,48 @@
+package wast
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+	"github.com/tetratelabs/wazero/internal/wasm/text"
+)
+
+func TestDecodeScript(t *testing.T) {
+	script, files, err := DecodeScript("add.wast", []byte(`(module $M (func (export "add") (param i32 i32) (result i32)
+  (i32.add (local.get 0) (local.get 1))))
+(register "m")
+(assert_return (invoke $M "add" (i32.const 1) (i32.const -1)) (i32.const 0))
+(assert_return (get "g") (v128.const f32x4 nan:arithmetic 0 1 -0))
+(assert_malformed (module quote "(func (i32.ad))") "unknown operator")
+(assert_invalid (module (func (call $f))) "unknown function")`))
+	require.NoError(t, err)
+
+	// What wast2json would convert the script to, except there's no file for
+	// the module of the last assertion, which the text decoder rejects.
+	var expected Script
+	require.NoError(t, json.Unmarshal([]byte(`{"source_filename": "add.wast", "commands": [
+ {"type": "module", "line": 1, "name": "$M", "filename": "add.0.wasm", "module_type": "binary", "expected": []},
+ {"type": "register", "line": 3, "as": "m", "expected": []},
+ {"type": "assert_return", "line": 4, "action": {"type": "invoke", "module": "$M", "field": "add", "args": [{"type": "i32", "value": "1"}, {"type": "i32", "value": "4294967295"}]}, "expected": [{"type": "i32", "value": "0"}]},
+ {"type": "assert_return", "line": 5, "action": {"type": "get", "field": "g", "args": []}, "expected": [{"type": "v128", "lane_type": "f32", "value": ["nan:arithmetic", "0", "1065353216", "2147483648"]}]},
+ {"type": "assert_malformed", "line": 6, "filename": "add.1.wat", "text": "unknown operator", "module_type": "text", "expected": []},
+ {"type": "assert_invalid", "line": 7, "filename": "add.2.wasm", "text": "unknown function", "module_type": "binary", "expected": []}]}`), &expected))
+	expected.Commands[5].moduleErr = script.Commands[5].moduleErr
+	require.Equal(t, expected, *script)
+	require.EqualError(t, script.Commands[5].moduleErr, "7:37: unknown func $f")
+
+	m, err := text.DecodeModule([]byte(`(module $M (func (export "add") (param i32 i32) (result i32)
+  (i32.add (local.get 0) (local.get 1))))`))
+	require.NoError(t, err)
+	require.Equal(t, map[string][]byte{
+		"add.0.wasm": binary.EncodeModule(m),
+		"add.1.wat":  []byte("(func (i32.ad))"),
+	}, files)
+}
+
+func TestDecodeScript_Error(t *testing.T) {
+	_, _, err := DecodeScript("add.wast", []byte(`(assert_return (call "add"))`))
+	require.EqualError(t, err, "1:16: expected (invoke ...) or (get ...), but was (call")
+}
@@ -1,4 +1,4 @@
-package spectest
+package wast
 
 import (
 	"encoding/json"
@@ -569,7 +569,7 @@ func TestCommandActionVal_toUint64s(t *testing.T) {
 	for _, tc := range tests {
 		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
-			var c commandActionVal
+			var c Value
 			err := json.Unmarshal([]byte(tc.rawCommandActionVal), &c)
 			require.NoError(t, err)
 			actual := c.toUint64s()
@@ -617,7 +617,7 @@ func TestCommand_getAssertReturnArgsExps(t *testing.T) {
 	for _, tc := range tests {
 		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
-			var c command
+			var c Command
 			err := json.Unmarshal([]byte(tc.rawCommand), &c)
 			require.NoError(t, err)
 			actualArgs, actualExps := c.getAssertReturnArgsExps()
@@ -187,6 +187,20 @@ type compiler struct {
 	needSourceOffset bool
 	// bodyOffsetInCodeSection is the offset of the body of this function in the original Wasm binary's code section.
 	bodyOffsetInCodeSection uint64
+
+	// ensureTermination is true if OperationBuiltinFunctionCheckExitCode should be emitted at each loop header.
+	ensureTermination bool
+	// instrumentMemory is true if OperationBuiltinFunctionMemoryAccess should be emitted before each load and store.
+	instrumentMemory bool
+	// protectMemory is true if OperationBuiltinFunctionMemoryAccess should be emitted before each store, including
+	// bulk memory operations.
+	protectMemory bool
+	// canonicalizeNaN is true if a NaN result of each float operation should be replaced with the canonical NaN.
+	canonicalizeNaN bool
+	// meterFuel is true if OperationBuiltinFunctionConsumeFuel should be emitted at the entry and each loop header.
+	meterFuel bool
+	// memoryPageSizeInBits is the log2 of the page size of the memory in bytes.
+	memoryPageSizeInBits uint32
 }
 
 //lint:ignore U1000 for debugging only.
@@ -259,7 +273,24 @@ type CompilationResult struct {
 	HasElementInstances bool
 }
 
-func CompileFunctions(ctx context.Context, enabledFeatures api.CoreFeatures, callFrameStackSizeInUint64 int, module *wasm.Module) ([]*CompilationResult, error) {
+// CompileFunctions lowers the functions defined in the module to wazeroir.
+//
+// When ensureTermination is true, OperationBuiltinFunctionCheckExitCode is
+// emitted at each loop header, so that a module closed during the execution,
+// e.g. due to context cancellation, can interrupt an infinite loop.
+//
+// When the module has a wasm.Module MemoryListener,
+// OperationBuiltinFunctionMemoryAccess is emitted before each load and store.
+// When wasm.Module ProtectMemory is true, it's emitted before each store,
+// including memory.init, memory.copy and memory.fill.
+//
+// When wasm.Module CanonicalizeNaN is true, operations replacing a NaN result
+// with the canonical NaN are emitted after each float operation.
+//
+// When wasm.Module MeterFuel is true, OperationBuiltinFunctionConsumeFuel is
+// emitted at the entry of each function and at each loop header, which are
+// the only points where the execution can repeat.
+func CompileFunctions(ctx context.Context, enabledFeatures api.CoreFeatures, callFrameStackSizeInUint64 int, module *wasm.Module, ensureTermination bool) ([]*CompilationResult, error) {
 	functions, globals, mem, tables, err := module.AllDeclarations()
 	if err != nil {
 		return nil, err
@@ -268,6 +299,11 @@ func CompileFunctions(ctx context.Context, enabledFeatures api.CoreFeatures, cal
 	hasMemory, hasTable, hasDataInstances, hasElementInstances := mem != nil, len(tables) > 0,
 		len(module.DataSection) > 0, len(module.ElementSection) > 0
 
+	memoryPageSizeInBits := uint32(wasm.MemoryPageSizeInBits)
+	if hasMemory {
+		memoryPageSizeInBits = mem.PageSizeInBits()
+	}
+
 	tableTypes := make([]wasm.ValueType, len(tables))
 	for i := range tableTypes {
 		tableTypes[i] = tables[i].Type
@@ -297,7 +333,8 @@ func CompileFunctions(ctx context.Context, enabledFeatures api.CoreFeatures, cal
 			continue
 		}
 		r, err := compile(enabledFeatures, callFrameStackSizeInUint64, sig, code.Body,
-			code.LocalTypes, module.TypeSection, functions, globals, code.BodyOffsetInCodeSection, module.DWARFLines != nil)
+			code.LocalTypes, module.TypeSection, functions, globals, code.BodyOffsetInCodeSection, module.NeedsSourceOffsets(), ensureTermination,
+			module.MemoryListener != nil, module.ProtectMemory, module.CanonicalizeNaN, module.MeterFuel, memoryPageSizeInBits)
 		if err != nil {
 			def := module.FunctionDefinitionSection[uint32(funcIndex)+module.ImportFuncCount()]
 			return nil, fmt.Errorf("failed to lower func[%s] to wazeroir: %w", def.DebugName(), err)
@@ -329,6 +366,12 @@ func compile(enabledFeatures api.CoreFeatures,
 	functions []uint32, globals []*wasm.GlobalType,
 	bodyOffsetInCodeSection uint64,
 	needSourceOffset bool,
+	ensureTermination bool,
+	instrumentMemory bool,
+	protectMemory bool,
+	canonicalizeNaN bool,
+	meterFuel bool,
+	memoryPageSizeInBits uint32,
 ) (*CompilationResult, error) {
 	c := compiler{
 		enabledFeatures:            enabledFeatures,
@@ -343,10 +386,20 @@ func compile(enabledFeatures api.CoreFeatures,
 		types:                      types,
 		needSourceOffset:           needSourceOffset,
 		bodyOffsetInCodeSection:    bodyOffsetInCodeSection,
+		ensureTermination:          ensureTermination,
+		instrumentMemory:           instrumentMemory,
+		protectMemory:              protectMemory,
+		canonicalizeNaN:            canonicalizeNaN,
+		meterFuel:                  meterFuel,
+		memoryPageSizeInBits:       memoryPageSizeInBits,
 	}
 
 	c.initializeStack()
 
+	if c.meterFuel {
+		c.emit(&OperationBuiltinFunctionConsumeFuel{})
+	}
+
 	// Emit const expressions for locals.
 	// Note that here we don't take function arguments
 	// into account, meaning that callers must push
@@ -472,6 +525,23 @@ operatorSwitch:
 			&OperationLabel{Label: loopLabel},
 		)
 
+		// Insert the exit code check on the loop header, which is the only
+		// necessary point in the function body to prevent infinite loop.
+		//
+		// Note that this is a little aggressive: this checks the exit code
+		// regardless the loop header is actually the loop. In other words,
+		// this checks even when no br/br_if/br_table instructions jumping to
+		// this loop exist. However, in reality, that shouldn't be an issue
+		// since such "noop" loop header will highly likely be optimized out
+		// by almost all guest language compilers which have the control flow
+		// optimization passes.
+		if c.ensureTermination {
+			c.emit(&OperationBuiltinFunctionCheckExitCode{})
+		}
+		if c.meterFuel {
+			c.emit(&OperationBuiltinFunctionConsumeFuel{})
+		}
+
 	case wasm.OpcodeIf:
 		bt, num, err := wasm.DecodeBlockType(c.types, bytes.NewReader(c.body[c.pc+1:]), c.enabledFeatures)
 		if err != nil {
@@ -1079,7 +1149,7 @@ operatorSwitch:
 		c.result.UsesMemory = true
 		c.pc++ // Skip the reserved one byte.
 		c.emit(
-			&OperationMemorySize{},
+			&OperationMemorySize{PageSizeInBits: c.memoryPageSizeInBits},
 		)
 	case wasm.OpcodeMemoryGrow:
 		c.result.UsesMemory = true
@@ -3020,19 +3090,40 @@ func (c *compiler) emit(ops ...Operation) {
 					continue
 				}
 			}
-			c.result.Operations = append(c.result.Operations, op)
-			if c.needSourceOffset {
-				c.result.IROperationSourceOffsetsInWasmBinary = append(c.result.IROperationSourceOffsetsInWasmBinary,
-					c.currentOpPC+c.bodyOffsetInCodeSection)
+			if access := memoryAccessOf(op); access != nil && c.instruments(access) {
+				c.appendOperation(access)
 			}
-			if false {
-				fmt.Printf("emitting ")
-				formatOperation(os.Stdout, op)
+			c.appendOperation(op)
+			if c.canonicalizeNaN {
+				for _, o := range nanCanonicalizationOf(op) {
+					c.appendOperation(o)
+				}
 			}
 		}
 	}
 }
 
+// instruments returns true if the access should be emitted before its load or
+// store.
+func (c *compiler) instruments(access *OperationBuiltinFunctionMemoryAccess) bool {
+	if access.Bulk {
+		return c.protectMemory
+	}
+	return c.instrumentMemory || c.protectMemory && access.Store
+}
+
+func (c *compiler) appendOperation(op Operation) {
+	c.result.Operations = append(c.result.Operations, op)
+	if c.needSourceOffset {
+		c.result.IROperationSourceOffsetsInWasmBinary = append(c.result.IROperationSourceOffsetsInWasmBinary,
+			c.currentOpPC+c.bodyOffsetInCodeSection)
+	}
+	if false {
+		fmt.Printf("emitting ")
+		formatOperation(os.Stdout, op)
+	}
+}
+
 // Emit const expression with default values of the given type.
 func (c *compiler) emitDefaultValue(t wasm.ValueType) {
 	switch t {
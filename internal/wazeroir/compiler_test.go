@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
 	"github.com/tetratelabs/wazero/internal/leb128"
 	"github.com/tetratelabs/wazero/internal/testing/require"
 	"github.com/tetratelabs/wazero/internal/wasm"
@@ -228,7 +229,7 @@ func TestCompile(t *testing.T) {
 			for _, tp := range tc.module.TypeSection {
 				tp.CacheNumInUint64()
 			}
-			res, err := CompileFunctions(ctx, enabledFeatures, 0, tc.module)
+			res, err := CompileFunctions(ctx, enabledFeatures, 0, tc.module, false)
 			require.NoError(t, err)
 
 			fn := res[0]
@@ -369,7 +370,7 @@ func TestCompile_BulkMemoryOperations(t *testing.T) {
 		TableTypes:       []wasm.RefType{},
 	}
 
-	res, err := CompileFunctions(ctx, api.CoreFeatureBulkMemoryOperations, 0, module)
+	res, err := CompileFunctions(ctx, api.CoreFeatureBulkMemoryOperations, 0, module, false)
 	require.NoError(t, err)
 	require.Equal(t, expected, res[0])
 }
@@ -665,7 +666,7 @@ func TestCompile_MultiValue(t *testing.T) {
 			for _, tp := range tc.module.TypeSection {
 				tp.CacheNumInUint64()
 			}
-			res, err := CompileFunctions(ctx, enabledFeatures, 0, tc.module)
+			res, err := CompileFunctions(ctx, enabledFeatures, 0, tc.module, false)
 			require.NoError(t, err)
 			require.Equal(t, tc.expected, res[0])
 		})
@@ -703,7 +704,7 @@ func TestCompile_NonTrappingFloatToIntConversion(t *testing.T) {
 	for _, tp := range module.TypeSection {
 		tp.CacheNumInUint64()
 	}
-	res, err := CompileFunctions(ctx, api.CoreFeatureNonTrappingFloatToIntConversion, 0, module)
+	res, err := CompileFunctions(ctx, api.CoreFeatureNonTrappingFloatToIntConversion, 0, module, false)
 	require.NoError(t, err)
 	require.Equal(t, expected, res[0])
 }
@@ -734,16 +735,256 @@ func TestCompile_SignExtensionOps(t *testing.T) {
 	for _, tp := range module.TypeSection {
 		tp.CacheNumInUint64()
 	}
-	res, err := CompileFunctions(ctx, api.CoreFeatureSignExtensionOps, 0, module)
+	res, err := CompileFunctions(ctx, api.CoreFeatureSignExtensionOps, 0, module, false)
 	require.NoError(t, err)
 	require.Equal(t, expected, res[0])
 }
 
+func TestCompile_ensureTermination(t *testing.T) {
+	module := &wasm.Module{
+		TypeSection:     []*wasm.FunctionType{v_v},
+		FunctionSection: []wasm.Index{0},
+		CodeSection: []*wasm.Code{{Body: []byte{
+			wasm.OpcodeLoop, 0x40,
+			wasm.OpcodeBr, 0,
+			wasm.OpcodeEnd,
+			wasm.OpcodeEnd,
+		}}},
+	}
+	for _, tp := range module.TypeSection {
+		tp.CacheNumInUint64()
+	}
+
+	for _, ensureTermination := range []bool{true, false} {
+		et := ensureTermination
+		t.Run(fmt.Sprintf("%v", et), func(t *testing.T) {
+			res, err := CompileFunctions(ctx, api.CoreFeaturesV2, 0, module, et)
+			require.NoError(t, err)
+
+			var checks int
+			for i, op := range res[0].Operations {
+				if _, ok := op.(*OperationBuiltinFunctionCheckExitCode); ok {
+					checks++
+					// The check must immediately follow the loop header.
+					require.Equal(t, LabelKindHeader, res[0].Operations[i-1].(*OperationLabel).Label.Kind)
+				}
+			}
+			if et {
+				require.Equal(t, 1, checks)
+			} else {
+				require.Zero(t, checks)
+			}
+		})
+	}
+}
+
+// noopMemoryListener implements experimental.MemoryListener
+type noopMemoryListener struct{}
+
+func (noopMemoryListener) WatchRegions() []experimental.WatchRegion { return nil }
+
+func (noopMemoryListener) OnMemoryAccess(context.Context, api.Module, api.FunctionDefinition, experimental.MemoryAccess) {
+}
+
+func TestCompile_instrumentMemory(t *testing.T) {
+	module := &wasm.Module{
+		TypeSection:     []*wasm.FunctionType{v_v},
+		FunctionSection: []wasm.Index{0},
+		MemorySection:   &wasm.Memory{Min: 1},
+		CodeSection: []*wasm.Code{{Body: []byte{
+			wasm.OpcodeI32Const, 0, wasm.OpcodeI32Const, 0,
+			wasm.OpcodeI32Store8, 0, 4,
+			wasm.OpcodeI32Const, 0,
+			wasm.OpcodeI64Load, 3, 0,
+			wasm.OpcodeDrop,
+			wasm.OpcodeEnd,
+		}}},
+	}
+	for _, tp := range module.TypeSection {
+		tp.CacheNumInUint64()
+	}
+
+	for _, instrument := range []bool{true, false} {
+		in := instrument
+		t.Run(fmt.Sprintf("%v", in), func(t *testing.T) {
+			module.MemoryListener = nil
+			if in {
+				module.MemoryListener = noopMemoryListener{}
+			}
+			res, err := CompileFunctions(ctx, api.CoreFeaturesV2, 0, module, false)
+			require.NoError(t, err)
+
+			var accesses []*OperationBuiltinFunctionMemoryAccess
+			for i, op := range res[0].Operations {
+				if o, ok := op.(*OperationBuiltinFunctionMemoryAccess); ok {
+					accesses = append(accesses, o)
+					// The access must immediately precede the load or store.
+					switch res[0].Operations[i+1].(type) {
+					case *OperationStore8, *OperationLoad:
+					default:
+						t.Fatalf("unexpected operation after access: %v", res[0].Operations[i+1])
+					}
+				}
+			}
+			if in {
+				require.Equal(t, []*OperationBuiltinFunctionMemoryAccess{
+					{Arg: &MemoryArg{Alignment: 0, Offset: 4}, Size: 1, Store: true, Depth: 1},
+					{Arg: &MemoryArg{Alignment: 3, Offset: 0}, Size: 8},
+				}, accesses)
+			} else {
+				require.Nil(t, accesses)
+			}
+		})
+	}
+}
+
+func TestCompile_protectMemory(t *testing.T) {
+	module := &wasm.Module{
+		TypeSection:     []*wasm.FunctionType{v_v},
+		FunctionSection: []wasm.Index{0},
+		MemorySection:   &wasm.Memory{Min: 1},
+		CodeSection: []*wasm.Code{{Body: []byte{
+			wasm.OpcodeI32Const, 0, wasm.OpcodeI32Const, 0,
+			wasm.OpcodeI32Store8, 0, 4,
+			wasm.OpcodeI32Const, 0,
+			wasm.OpcodeI64Load, 3, 0,
+			wasm.OpcodeDrop,
+			wasm.OpcodeI32Const, 0, wasm.OpcodeI32Const, 0, wasm.OpcodeI32Const, 0,
+			wasm.OpcodeMiscPrefix, wasm.OpcodeMiscMemoryFill, 0,
+			wasm.OpcodeEnd,
+		}}},
+	}
+	for _, tp := range module.TypeSection {
+		tp.CacheNumInUint64()
+	}
+
+	for _, protect := range []bool{true, false} {
+		p := protect
+		t.Run(fmt.Sprintf("%v", p), func(t *testing.T) {
+			module.ProtectMemory = p
+			res, err := CompileFunctions(ctx, api.CoreFeaturesV2, 0, module, false)
+			require.NoError(t, err)
+
+			var accesses []*OperationBuiltinFunctionMemoryAccess
+			for i, op := range res[0].Operations {
+				if o, ok := op.(*OperationBuiltinFunctionMemoryAccess); ok {
+					accesses = append(accesses, o)
+					// The access must immediately precede the store.
+					switch res[0].Operations[i+1].(type) {
+					case *OperationStore8, *OperationMemoryFill:
+					default:
+						t.Fatalf("unexpected operation after access: %v", res[0].Operations[i+1])
+					}
+				}
+			}
+			if p {
+				// Only writes are instrumented.
+				require.Equal(t, []*OperationBuiltinFunctionMemoryAccess{
+					{Arg: &MemoryArg{Alignment: 0, Offset: 4}, Size: 1, Store: true, Depth: 1},
+					{Arg: &MemoryArg{}, Store: true, Depth: 2, Bulk: true},
+				}, accesses)
+			} else {
+				require.Nil(t, accesses)
+			}
+		})
+	}
+}
+
+func TestCompile_canonicalizeNaN(t *testing.T) {
+	module := &wasm.Module{
+		TypeSection:     []*wasm.FunctionType{f32_i32},
+		FunctionSection: []wasm.Index{0},
+		CodeSection: []*wasm.Code{{Body: []byte{
+			wasm.OpcodeLocalGet, 0,
+			wasm.OpcodeF32Sqrt,
+			wasm.OpcodeI32ReinterpretF32,
+			wasm.OpcodeEnd,
+		}}},
+	}
+	for _, tp := range module.TypeSection {
+		tp.CacheNumInUint64()
+	}
+
+	for _, canonicalize := range []bool{true, false} {
+		c := canonicalize
+		t.Run(fmt.Sprintf("%v", c), func(t *testing.T) {
+			module.CanonicalizeNaN = c
+			res, err := CompileFunctions(ctx, api.CoreFeaturesV2, 0, module, false)
+			require.NoError(t, err)
+
+			// Compare kinds, as the canonical NaN isn't equal to itself.
+			expected := []OperationKind{OperationKindPick, OperationKindSqrt}
+			if c {
+				expected = append(expected, OperationKindConstF32, OperationKindPick, OperationKindPick,
+					OperationKindEq, OperationKindSelect)
+			}
+			expected = append(expected, OperationKindI32ReinterpretFromF32, OperationKindDrop, OperationKindBr)
+			var kinds []OperationKind
+			for _, op := range res[0].Operations {
+				kinds = append(kinds, op.Kind())
+			}
+			require.Equal(t, expected, kinds)
+			if c {
+				require.Equal(t, uint32(canonicalNaN32), math.Float32bits(res[0].Operations[2].(*OperationConstF32).Value))
+			}
+		})
+	}
+}
+
+func TestCompile_meterFuel(t *testing.T) {
+	module := &wasm.Module{
+		TypeSection:     []*wasm.FunctionType{v_v},
+		FunctionSection: []wasm.Index{0},
+		CodeSection: []*wasm.Code{{Body: []byte{
+			wasm.OpcodeLoop, 0x40,
+			wasm.OpcodeBr, 0,
+			wasm.OpcodeEnd,
+			wasm.OpcodeEnd,
+		}}},
+	}
+	for _, tp := range module.TypeSection {
+		tp.CacheNumInUint64()
+	}
+
+	for _, meterFuel := range []bool{true, false} {
+		m := meterFuel
+		t.Run(fmt.Sprintf("%v", m), func(t *testing.T) {
+			module.MeterFuel = m
+			res, err := CompileFunctions(ctx, api.CoreFeaturesV2, 0, module, false)
+			require.NoError(t, err)
+
+			var consumes []int
+			for i, op := range res[0].Operations {
+				if _, ok := op.(*OperationBuiltinFunctionConsumeFuel); ok {
+					consumes = append(consumes, i)
+				}
+			}
+			if m {
+				// Fuel is consumed on entry, and on each iteration of the loop.
+				require.Equal(t, []int{0, 3}, consumes)
+				require.Equal(t, LabelKindHeader, res[0].Operations[2].(*OperationLabel).Label.Kind)
+			} else {
+				require.Nil(t, consumes)
+			}
+		})
+	}
+}
+
+func Test_nanCanonicalizationOf(t *testing.T) {
+	// Only float operations whose NaN results have non-deterministic bits are canonicalized.
+	require.Equal(t, 5, len(nanCanonicalizationOf(&OperationAdd{Type: UnsignedTypeF64})))
+	require.Equal(t, 5, len(nanCanonicalizationOf(&OperationV128Div{Shape: ShapeF32x4})))
+	require.Nil(t, nanCanonicalizationOf(&OperationAdd{Type: UnsignedTypeI32}))
+	require.Nil(t, nanCanonicalizationOf(&OperationV128Add{Shape: ShapeI32x4}))
+	require.Nil(t, nanCanonicalizationOf(&OperationAbs{Type: Float32}))
+	require.Nil(t, nanCanonicalizationOf(&OperationF32ReinterpretFromI32{}))
+}
+
 func requireCompilationResult(t *testing.T, enabledFeatures api.CoreFeatures, expected *CompilationResult, module *wasm.Module) {
 	if enabledFeatures == 0 {
 		enabledFeatures = api.CoreFeaturesV2
 	}
-	res, err := CompileFunctions(ctx, enabledFeatures, 0, module)
+	res, err := CompileFunctions(ctx, enabledFeatures, 0, module, false)
 	require.NoError(t, err)
 	require.Equal(t, expected, res[0])
 }
@@ -785,7 +1026,7 @@ func TestCompile_CallIndirectNonZeroTableIndex(t *testing.T) {
 		Types: []*wasm.FunctionType{v_v, v_v, v_v},
 	}
 
-	res, err := CompileFunctions(ctx, api.CoreFeatureBulkMemoryOperations, 0, module)
+	res, err := CompileFunctions(ctx, api.CoreFeatureBulkMemoryOperations, 0, module, false)
 	require.NoError(t, err)
 	require.Equal(t, expected, res[0])
 }
@@ -875,7 +1116,7 @@ func TestCompile_Refs(t *testing.T) {
 				FunctionSection: []wasm.Index{0},
 				CodeSection:     []*wasm.Code{{Body: tc.body}},
 			}
-			res, err := CompileFunctions(ctx, api.CoreFeaturesV2, 0, module)
+			res, err := CompileFunctions(ctx, api.CoreFeaturesV2, 0, module, false)
 			require.NoError(t, err)
 			require.Equal(t, tc.expected, res[0].Operations)
 		})
@@ -944,7 +1185,7 @@ func TestCompile_TableGetOrSet(t *testing.T) {
 				CodeSection:     []*wasm.Code{{Body: tc.body}},
 				TableSection:    []*wasm.Table{{}},
 			}
-			res, err := CompileFunctions(ctx, api.CoreFeaturesV2, 0, module)
+			res, err := CompileFunctions(ctx, api.CoreFeaturesV2, 0, module, false)
 			require.NoError(t, err)
 			require.Equal(t, tc.expected, res[0].Operations)
 		})
@@ -1013,7 +1254,7 @@ func TestCompile_TableGrowFillSize(t *testing.T) {
 				CodeSection:     []*wasm.Code{{Body: tc.body}},
 				TableSection:    []*wasm.Table{{}},
 			}
-			res, err := CompileFunctions(ctx, api.CoreFeaturesV2, 0, module)
+			res, err := CompileFunctions(ctx, api.CoreFeaturesV2, 0, module, false)
 			require.NoError(t, err)
 			require.Equal(t, tc.expected, res[0].Operations)
 			require.True(t, res[0].HasTable)
@@ -1221,7 +1462,7 @@ func TestCompile_Locals(t *testing.T) {
 	for _, tt := range tests {
 		tc := tt
 		t.Run(tc.name, func(t *testing.T) {
-			res, err := CompileFunctions(ctx, api.CoreFeaturesV2, 0, tc.mod)
+			res, err := CompileFunctions(ctx, api.CoreFeaturesV2, 0, tc.mod, false)
 			require.NoError(t, err)
 			msg := fmt.Sprintf("\nhave:\n\t%s\nwant:\n\t%s", Format(res[0].Operations), Format(tc.expected))
 			require.Equal(t, tc.expected, res[0].Operations, msg)
@@ -2797,7 +3038,7 @@ func TestCompile_Vec(t *testing.T) {
 				MemorySection:   &wasm.Memory{},
 				CodeSection:     []*wasm.Code{{Body: tc.body}},
 			}
-			res, err := CompileFunctions(ctx, api.CoreFeaturesV2, 0, module)
+			res, err := CompileFunctions(ctx, api.CoreFeaturesV2, 0, module, false)
 			require.NoError(t, err)
 
 			var actual Operation
@@ -2874,7 +3115,7 @@ func TestCompile_unreachable_Br_BrIf_BrTable(t *testing.T) {
 	for _, tt := range tests {
 		tc := tt
 		t.Run(tc.name, func(t *testing.T) {
-			res, err := CompileFunctions(ctx, api.CoreFeaturesV2, 0, tc.mod)
+			res, err := CompileFunctions(ctx, api.CoreFeaturesV2, 0, tc.mod, false)
 			require.NoError(t, err)
 			require.Equal(t, tc.expected, res[0].Operations)
 		})
@@ -2914,7 +3155,7 @@ func TestCompile_drop_vectors(t *testing.T) {
 	for _, tt := range tests {
 		tc := tt
 		t.Run(tc.name, func(t *testing.T) {
-			res, err := CompileFunctions(ctx, api.CoreFeaturesV2, 0, tc.mod)
+			res, err := CompileFunctions(ctx, api.CoreFeaturesV2, 0, tc.mod, false)
 			require.NoError(t, err)
 			require.Equal(t, tc.expected, res[0].Operations)
 		})
@@ -2984,7 +3225,7 @@ func TestCompile_select_vectors(t *testing.T) {
 	for _, tt := range tests {
 		tc := tt
 		t.Run(tc.name, func(t *testing.T) {
-			res, err := CompileFunctions(ctx, api.CoreFeaturesV2, 0, tc.mod)
+			res, err := CompileFunctions(ctx, api.CoreFeaturesV2, 0, tc.mod, false)
 			require.NoError(t, err)
 			require.Equal(t, tc.expected, res[0].Operations)
 		})
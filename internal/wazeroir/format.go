@@ -191,6 +191,12 @@ func formatOperation(w io.StringWriter, b Operation) {
 		} else {
 			str = fmt.Sprintf("v128.ITruncSatFrom%sU", shapeName(o.OriginShape))
 		}
+	case *OperationBuiltinFunctionCheckExitCode:
+		str = "builtin.check_exit_code"
+	case *OperationBuiltinFunctionMemoryAccess:
+		str = fmt.Sprintf("builtin.memory_access (size=%d,store=%v)", o.Size, o.Store)
+	case *OperationBuiltinFunctionConsumeFuel:
+		str = "builtin.consume_fuel"
 	default:
 		panic("unreachable: a bug in wazeroir implementation")
 	}
@@ -1,6 +1,9 @@
 package wazeroir
 
-import "fmt"
+import (
+	"fmt"
+	"math"
+)
 
 // UnsignedInt represents unsigned 32-bit or 64-bit integers.
 type UnsignedInt byte
@@ -416,6 +419,12 @@ func (o OperationKind) String() (ret string) {
 		ret = "V128Narrow"
 	case OperationKindV128ITruncSatFromF:
 		ret = "V128ITruncSatFromF"
+	case OperationKindBuiltinFunctionCheckExitCode:
+		ret = "BuiltinFunctionCheckExitCode"
+	case OperationKindBuiltinFunctionMemoryAccess:
+		ret = "BuiltinFunctionMemoryAccess"
+	case OperationKindBuiltinFunctionConsumeFuel:
+		ret = "BuiltinFunctionConsumeFuel"
 	default:
 		panic(fmt.Errorf("unknown operation %d", o))
 	}
@@ -703,6 +712,13 @@ const (
 	// OperationKindV128ITruncSatFromF is the kind for OperationV128ITruncSatFromF.
 	OperationKindV128ITruncSatFromF
 
+	// OperationKindBuiltinFunctionCheckExitCode is the kind for OperationBuiltinFunctionCheckExitCode.
+	OperationKindBuiltinFunctionCheckExitCode
+	// OperationKindBuiltinFunctionMemoryAccess is the kind for OperationBuiltinFunctionMemoryAccess.
+	OperationKindBuiltinFunctionMemoryAccess
+	// OperationKindBuiltinFunctionConsumeFuel is the kind for OperationBuiltinFunctionConsumeFuel.
+	OperationKindBuiltinFunctionConsumeFuel
+
 	// operationKindEnd is always placed at the bottom of this iota definition to be used in the test.
 	operationKindEnd
 )
@@ -1144,7 +1160,11 @@ func (OperationStore32) Kind() OperationKind {
 // This corresponds to wasm.OpcodeMemorySize.
 //
 // The engines are expected to push the current page size of the memory onto the stack.
-type OperationMemorySize struct{}
+type OperationMemorySize struct {
+	// PageSizeInBits is the log2 of the page size of the memory in bytes, which is known at compile time, as an
+	// imported memory must have the same page size.
+	PageSizeInBits uint32
+}
 
 // Kind implements Operation.Kind.
 func (OperationMemorySize) Kind() OperationKind {
@@ -2832,3 +2852,227 @@ type OperationV128ITruncSatFromF struct {
 func (OperationV128ITruncSatFromF) Kind() OperationKind {
 	return OperationKindV128ITruncSatFromF
 }
+
+// OperationBuiltinFunctionCheckExitCode implements Operation.
+//
+// This is only emitted at loop headers when the module is compiled with
+// ensureTermination, as documented on CompileFunctions. The engines are
+// expected to exit the execution with the sys.ExitError if the module was
+// closed, e.g. due to a canceled context.
+type OperationBuiltinFunctionCheckExitCode struct{}
+
+// Kind implements Operation.Kind.
+func (*OperationBuiltinFunctionCheckExitCode) Kind() OperationKind {
+	return OperationKindBuiltinFunctionCheckExitCode
+}
+
+// OperationBuiltinFunctionConsumeFuel implements Operation.
+//
+// This is only emitted at the entry of each function and at loop headers
+// when the module is compiled with wasm.Module MeterFuel, as documented on
+// CompileFunctions. The engines are expected to consume one unit of the
+// experimental.Fuel of the call, if any, or trap with
+// wasmruntime.ErrRuntimeFuelExhausted if none remains.
+type OperationBuiltinFunctionConsumeFuel struct{}
+
+// Kind implements Operation.Kind.
+func (*OperationBuiltinFunctionConsumeFuel) Kind() OperationKind {
+	return OperationKindBuiltinFunctionConsumeFuel
+}
+
+// OperationBuiltinFunctionMemoryAccess implements Operation.
+//
+// This is only emitted before each load and store when the module is
+// compiled with a memory listener, or before each store when compiled with
+// memory protection, as documented on CompileFunctions. The engines are
+// expected to trap if a store writes a read-only page, then notify the
+// listener if the access overlaps any of its watch regions, leaving the stack
+// as is.
+type OperationBuiltinFunctionMemoryAccess struct {
+	// Arg is the memory argument of the next load or store.
+	Arg *MemoryArg
+	// Size is the count of bytes accessed.
+	Size uint32
+	// Store is true if the next operation is a store.
+	Store bool
+	// Depth is the count of uint64 stack values above the address operand,
+	// e.g. one for the value of i32.store.
+	Depth int
+	// Bulk is true if the next operation is memory.init, memory.copy or
+	// memory.fill, whose count of bytes is the i32 operand on the top of the
+	// stack instead of Size. These are only emitted with memory protection,
+	// so the listener isn't notified of them.
+	Bulk bool
+}
+
+// Kind implements Operation.Kind.
+func (*OperationBuiltinFunctionMemoryAccess) Kind() OperationKind {
+	return OperationKindBuiltinFunctionMemoryAccess
+}
+
+// memoryAccessOf returns the OperationBuiltinFunctionMemoryAccess to emit
+// before the operation, or nil if it doesn't load or store memory.
+func memoryAccessOf(op Operation) *OperationBuiltinFunctionMemoryAccess {
+	switch o := op.(type) {
+	case *OperationLoad:
+		return &OperationBuiltinFunctionMemoryAccess{Arg: o.Arg, Size: unsignedTypeSize(o.Type)}
+	case *OperationLoad8:
+		return &OperationBuiltinFunctionMemoryAccess{Arg: o.Arg, Size: 1}
+	case *OperationLoad16:
+		return &OperationBuiltinFunctionMemoryAccess{Arg: o.Arg, Size: 2}
+	case *OperationLoad32:
+		return &OperationBuiltinFunctionMemoryAccess{Arg: o.Arg, Size: 4}
+	case *OperationStore:
+		return &OperationBuiltinFunctionMemoryAccess{Arg: o.Arg, Size: unsignedTypeSize(o.Type), Store: true, Depth: 1}
+	case *OperationStore8:
+		return &OperationBuiltinFunctionMemoryAccess{Arg: o.Arg, Size: 1, Store: true, Depth: 1}
+	case *OperationStore16:
+		return &OperationBuiltinFunctionMemoryAccess{Arg: o.Arg, Size: 2, Store: true, Depth: 1}
+	case *OperationStore32:
+		return &OperationBuiltinFunctionMemoryAccess{Arg: o.Arg, Size: 4, Store: true, Depth: 1}
+	case *OperationV128Load:
+		return &OperationBuiltinFunctionMemoryAccess{Arg: o.Arg, Size: v128LoadTypeSize(o.Type)}
+	case *OperationV128LoadLane: // the address is below the vector.
+		return &OperationBuiltinFunctionMemoryAccess{Arg: o.Arg, Size: uint32(o.LaneSize / 8), Depth: 2}
+	case *OperationV128Store:
+		return &OperationBuiltinFunctionMemoryAccess{Arg: o.Arg, Size: 16, Store: true, Depth: 2}
+	case *OperationV128StoreLane:
+		return &OperationBuiltinFunctionMemoryAccess{Arg: o.Arg, Size: uint32(o.LaneSize / 8), Store: true, Depth: 2}
+	case *OperationMemoryInit, *OperationMemoryCopy, *OperationMemoryFill: // the destination is below two operands.
+		return &OperationBuiltinFunctionMemoryAccess{Arg: &MemoryArg{}, Store: true, Depth: 2, Bulk: true}
+	}
+	return nil
+}
+
+// canonicalNaN32 and canonicalNaN64 are the positive canonical NaNs, whose
+// payload has only the most significant bit set.
+//
+// See https://www.w3.org/TR/2022/WD-wasm-core-2-20220419/syntax/values.html#floating-point
+const (
+	canonicalNaN32 = 0x7fc00000
+	canonicalNaN64 = 0x7ff8000000000000
+)
+
+// nanCanonicalizationOf returns the operations to emit after op to replace a
+// NaN result with the canonical NaN of its type, or nil if op isn't a float
+// operation whose NaN results may have different bits on each platform.
+//
+// A NaN is the only value not equal to itself, so each selects the result
+// if it equals itself, or the canonical NaN otherwise.
+func nanCanonicalizationOf(op Operation) []Operation {
+	var f32, f64, f32x4, f64x2 bool
+	switch o := op.(type) {
+	case *OperationAdd:
+		f32, f64 = o.Type == UnsignedTypeF32, o.Type == UnsignedTypeF64
+	case *OperationSub:
+		f32, f64 = o.Type == UnsignedTypeF32, o.Type == UnsignedTypeF64
+	case *OperationMul:
+		f32, f64 = o.Type == UnsignedTypeF32, o.Type == UnsignedTypeF64
+	case *OperationDiv:
+		f32, f64 = o.Type == SignedTypeFloat32, o.Type == SignedTypeFloat64
+	case *OperationSqrt:
+		f32, f64 = o.Type == Float32, o.Type == Float64
+	case *OperationMin:
+		f32, f64 = o.Type == Float32, o.Type == Float64
+	case *OperationMax:
+		f32, f64 = o.Type == Float32, o.Type == Float64
+	case *OperationCeil:
+		f32, f64 = o.Type == Float32, o.Type == Float64
+	case *OperationFloor:
+		f32, f64 = o.Type == Float32, o.Type == Float64
+	case *OperationTrunc:
+		f32, f64 = o.Type == Float32, o.Type == Float64
+	case *OperationNearest:
+		f32, f64 = o.Type == Float32, o.Type == Float64
+	case *OperationF32DemoteFromF64:
+		f32 = true
+	case *OperationF64PromoteFromF32:
+		f64 = true
+	case *OperationV128Add:
+		f32x4, f64x2 = o.Shape == ShapeF32x4, o.Shape == ShapeF64x2
+	case *OperationV128Sub:
+		f32x4, f64x2 = o.Shape == ShapeF32x4, o.Shape == ShapeF64x2
+	case *OperationV128Mul:
+		f32x4, f64x2 = o.Shape == ShapeF32x4, o.Shape == ShapeF64x2
+	case *OperationV128Div:
+		f32x4, f64x2 = o.Shape == ShapeF32x4, o.Shape == ShapeF64x2
+	case *OperationV128Sqrt:
+		f32x4, f64x2 = o.Shape == ShapeF32x4, o.Shape == ShapeF64x2
+	case *OperationV128Min:
+		f32x4, f64x2 = o.Shape == ShapeF32x4, o.Shape == ShapeF64x2
+	case *OperationV128Max:
+		f32x4, f64x2 = o.Shape == ShapeF32x4, o.Shape == ShapeF64x2
+	case *OperationV128Ceil:
+		f32x4, f64x2 = o.Shape == ShapeF32x4, o.Shape == ShapeF64x2
+	case *OperationV128Floor:
+		f32x4, f64x2 = o.Shape == ShapeF32x4, o.Shape == ShapeF64x2
+	case *OperationV128Trunc:
+		f32x4, f64x2 = o.Shape == ShapeF32x4, o.Shape == ShapeF64x2
+	case *OperationV128Nearest:
+		f32x4, f64x2 = o.Shape == ShapeF32x4, o.Shape == ShapeF64x2
+	case *OperationV128FloatDemote:
+		f32x4 = true
+	case *OperationV128FloatPromote:
+		f64x2 = true
+	}
+
+	switch {
+	case f32: // [x] -> [x, nan, x, x] -> [x, nan, x == x] -> [x or nan]
+		return []Operation{
+			&OperationConstF32{Value: math.Float32frombits(canonicalNaN32)},
+			&OperationPick{Depth: 1},
+			&OperationPick{Depth: 0},
+			&OperationEq{Type: UnsignedTypeF32},
+			&OperationSelect{},
+		}
+	case f64:
+		return []Operation{
+			&OperationConstF64{Value: math.Float64frombits(canonicalNaN64)},
+			&OperationPick{Depth: 1},
+			&OperationPick{Depth: 0},
+			&OperationEq{Type: UnsignedTypeF64},
+			&OperationSelect{},
+		}
+	case f32x4: // the same, but selecting the bits of each lane.
+		return []Operation{
+			&OperationV128Const{Lo: canonicalNaN32<<32 | canonicalNaN32, Hi: canonicalNaN32<<32 | canonicalNaN32},
+			&OperationPick{Depth: 3, IsTargetVector: true},
+			&OperationPick{Depth: 1, IsTargetVector: true},
+			&OperationV128Cmp{Type: V128CmpTypeF32x4Eq},
+			&OperationV128Bitselect{},
+		}
+	case f64x2:
+		return []Operation{
+			&OperationV128Const{Lo: canonicalNaN64, Hi: canonicalNaN64},
+			&OperationPick{Depth: 3, IsTargetVector: true},
+			&OperationPick{Depth: 1, IsTargetVector: true},
+			&OperationV128Cmp{Type: V128CmpTypeF64x2Eq},
+			&OperationV128Bitselect{},
+		}
+	}
+	return nil
+}
+
+func unsignedTypeSize(t UnsignedType) uint32 {
+	switch t {
+	case UnsignedTypeI32, UnsignedTypeF32:
+		return 4
+	default: // UnsignedTypeI64, UnsignedTypeF64
+		return 8
+	}
+}
+
+func v128LoadTypeSize(t V128LoadType) uint32 {
+	switch t {
+	case V128LoadType128:
+		return 16
+	case V128LoadType8Splat:
+		return 1
+	case V128LoadType16Splat:
+		return 2
+	case V128LoadType32Splat, V128LoadType32zero:
+		return 4
+	default: // extending loads, V128LoadType64Splat and V128LoadType64zero.
+		return 8
+	}
+}
@@ -0,0 +1,140 @@
+package wit
+
+import (
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/abi"
+)
+
+// The layout of types is computed by the abi package, which is shared with
+// the experimental canonabi package, so that bindings and hosts using it agree.
+
+// underlying returns the type of an alias, or t if not one.
+func underlying(t *Type) *Type {
+	for t.Kind == KindAlias {
+		t = t.Elem
+	}
+	return t
+}
+
+// cases returns the types of the cases of a variant, option, result or enum,
+// which are nil for cases without one.
+func cases(t *Type) []*Type {
+	switch t = underlying(t); t.Kind {
+	case KindOption:
+		return []*Type{nil, t.Elem}
+	case KindResult:
+		return []*Type{t.Ok, t.Err}
+	default:
+		ret := make([]*Type, len(t.Fields))
+		for i, f := range t.Fields {
+			ret[i] = f.Type
+		}
+		return ret
+	}
+}
+
+// fields returns the types of the fields of a record or tuple.
+func fields(t *Type) []*Type {
+	t = underlying(t)
+	ret := make([]*Type, len(t.Fields))
+	for i, f := range t.Fields {
+		ret[i] = f.Type
+	}
+	return ret
+}
+
+// isVariant returns true if the type is represented as a variant.
+func isVariant(t *Type) bool {
+	switch underlying(t).Kind {
+	case KindVariant, KindOption, KindResult, KindEnum:
+		return true
+	}
+	return false
+}
+
+// layout returns the layout of the type in memory and as core values.
+func layout(t *Type) abi.Layout {
+	switch t = underlying(t); t.Kind {
+	case KindBool, KindS8, KindU8:
+		return abi.Primitive(1, api.ValueTypeI32)
+	case KindS16, KindU16:
+		return abi.Primitive(2, api.ValueTypeI32)
+	case KindS32, KindU32, KindChar:
+		return abi.Primitive(4, api.ValueTypeI32)
+	case KindS64, KindU64:
+		return abi.Primitive(8, api.ValueTypeI64)
+	case KindF32:
+		return abi.Primitive(4, api.ValueTypeF32)
+	case KindF64:
+		return abi.Primitive(8, api.ValueTypeF64)
+	case KindString, KindList:
+		return abi.Slice
+	case KindRecord, KindTuple:
+		l, _ := recordLayout(fields(t))
+		return l
+	case KindFlags:
+		return abi.Flags(len(t.Fields))
+	default:
+		l, _ := variantLayout(t)
+		return l
+	}
+}
+
+// recordLayout returns the layout of a record of the types, and the offsets
+// of its fields followed by the offset after the last.
+func recordLayout(types []*Type) (abi.Layout, []uint32) {
+	layouts := make([]abi.Layout, len(types))
+	for i, t := range types {
+		layouts[i] = layout(t)
+	}
+	return abi.Record(layouts)
+}
+
+// variantLayout returns the layout of a variant, option, result or enum, and
+// the offset of the payload of a case.
+func variantLayout(t *Type) (abi.Layout, uint32) {
+	cs := cases(t)
+	layouts := make([]*abi.Layout, len(cs))
+	for i, c := range cs {
+		if c != nil {
+			l := layout(c)
+			layouts[i] = &l
+		}
+	}
+	return abi.Variant(len(cs), layouts)
+}
+
+// size returns the size of the type in memory.
+func size(t *Type) uint32 {
+	return layout(t).Size
+}
+
+// alignment returns the alignment of the type in memory.
+func alignment(t *Type) uint32 {
+	return layout(t).Alignment
+}
+
+// fieldOffsets returns the offsets of the fields in memory, followed by the
+// offset after the last field.
+func fieldOffsets(types []*Type) []uint32 {
+	_, offsets := recordLayout(types)
+	return offsets
+}
+
+// payloadOffset returns the offset of the payload of a case of a variant in
+// memory.
+func payloadOffset(t *Type) uint32 {
+	_, offset := variantLayout(t)
+	return offset
+}
+
+// flat returns the core types a value of the type is passed as.
+func flat(t *Type) []api.ValueType {
+	return layout(t).Flat
+}
+
+// flatAll returns the core types of the values of the types.
+func flatAll(types []*Type) []api.ValueType {
+	l, _ := recordLayout(types)
+	return l.Flat
+}
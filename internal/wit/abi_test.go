@@ -0,0 +1,99 @@
+package wit
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/abi"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestLayout(t *testing.T) {
+	u8, u32, u64 := &Type{Kind: KindU8}, &Type{Kind: KindU32}, &Type{Kind: KindU64}
+	f32, str := &Type{Kind: KindF32}, &Type{Kind: KindString}
+
+	tests := []struct {
+		name              string
+		typ               *Type
+		expectedSize      uint32
+		expectedAlignment uint32
+		expectedFlat      []api.ValueType
+	}{
+		{name: "u8", typ: u8, expectedSize: 1, expectedAlignment: 1, expectedFlat: []api.ValueType{api.ValueTypeI32}},
+		{name: "u64", typ: u64, expectedSize: 8, expectedAlignment: 8, expectedFlat: []api.ValueType{api.ValueTypeI64}},
+		{
+			name: "string", typ: str, expectedSize: 8, expectedAlignment: 4,
+			expectedFlat: []api.ValueType{api.ValueTypeI32, api.ValueTypeI32},
+		},
+		{
+			name:         "record",
+			typ:          &Type{Kind: KindRecord, Name: "r", Fields: []Field{{Name: "a", Type: u8}, {Name: "b", Type: u64}, {Name: "c", Type: u8}}},
+			expectedSize: 24, expectedAlignment: 8,
+			expectedFlat: []api.ValueType{api.ValueTypeI32, api.ValueTypeI64, api.ValueTypeI32},
+		},
+		{
+			name:         "alias",
+			typ:          &Type{Kind: KindAlias, Name: "a", Elem: &Type{Kind: KindTuple, Fields: []Field{{Type: u8}, {Type: u32}}}},
+			expectedSize: 8, expectedAlignment: 4,
+			expectedFlat: []api.ValueType{api.ValueTypeI32, api.ValueTypeI32},
+		},
+		{
+			name:         "option",
+			typ:          &Type{Kind: KindOption, Elem: u32},
+			expectedSize: 8, expectedAlignment: 4,
+			expectedFlat: []api.ValueType{api.ValueTypeI32, api.ValueTypeI32},
+		},
+		{
+			name:         "result joins f32 and i32",
+			typ:          &Type{Kind: KindResult, Ok: f32, Err: str},
+			expectedSize: 12, expectedAlignment: 4,
+			expectedFlat: []api.ValueType{api.ValueTypeI32, api.ValueTypeI32, api.ValueTypeI32},
+		},
+		{
+			name: "variant joins f32 and i64",
+			typ: &Type{Kind: KindVariant, Name: "v", Fields: []Field{
+				{Name: "a", Type: f32}, {Name: "b", Type: u64}, {Name: "c"},
+			}},
+			expectedSize: 16, expectedAlignment: 8,
+			expectedFlat: []api.ValueType{api.ValueTypeI32, api.ValueTypeI64},
+		},
+		{
+			name:         "enum",
+			typ:          &Type{Kind: KindEnum, Name: "e", Fields: []Field{{Name: "a"}, {Name: "b"}}},
+			expectedSize: 1, expectedAlignment: 1,
+			expectedFlat: []api.ValueType{api.ValueTypeI32},
+		},
+		{
+			name:         "flags",
+			typ:          &Type{Kind: KindFlags, Name: "f", Fields: make([]Field, 9)},
+			expectedSize: 2, expectedAlignment: 2,
+			expectedFlat: []api.ValueType{api.ValueTypeI32},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expectedSize, size(tc.typ))
+			require.Equal(t, tc.expectedAlignment, alignment(tc.typ))
+			require.Equal(t, tc.expectedFlat, flat(tc.typ))
+		})
+	}
+}
+
+func TestCoreSignature(t *testing.T) {
+	u32 := &Type{Kind: KindU32}
+	many := &Function{Name: "many"}
+	for i := 0; i < abi.MaxFlatParams+1; i++ {
+		many.Params = append(many.Params, Field{Name: string(rune('a' + i)), Type: u32})
+	}
+
+	params, results := coreSignature(many)
+	require.Equal(t, []api.ValueType{api.ValueTypeI32}, params)
+	require.Equal(t, 0, len(results))
+
+	// The string is returned in memory, at the pointer passed last.
+	params, results = coreSignature(&Function{Name: "str", Params: many.Params[:1], Result: &Type{Kind: KindString}})
+	require.Equal(t, []api.ValueType{api.ValueTypeI32, api.ValueTypeI32}, params)
+	require.Equal(t, 0, len(results))
+}
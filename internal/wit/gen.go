@@ -0,0 +1,968 @@
+package wit
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	gotoken "go/token"
+	"strings"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/abi"
+)
+
+// rootModuleName is the name of the module of the functions a world imports
+// itself, rather than from an interface.
+const rootModuleName = "$root"
+
+// Generate returns the source of a Go package named goPackage, with the
+// bindings of the world:
+//   - For each imported interface, a Go interface for the host to
+//     implement, and a function to instantiate a host module with it.
+//   - A struct whose methods call the functions the guest exports.
+func Generate(pkg *Package, w *World, goPackage string) ([]byte, error) {
+	g := &generator{
+		pkg:      pkg,
+		helpers:  map[string]bool{},
+		declared: map[string]*Type{},
+	}
+	if err := g.world(w); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by wazero wit-bindgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&out, "package %s\n\nimport (\n\t\"context\"\n\t\"fmt\"\n", goPackage)
+	if g.usesMath {
+		out.WriteString("\t\"math\"\n")
+	}
+	out.WriteString("\n")
+	if g.usesRuntime {
+		out.WriteString("\t\"github.com/tetratelabs/wazero\"\n")
+	}
+	out.WriteString("\t\"github.com/tetratelabs/wazero/api\"\n)\n")
+	out.Write(g.types.Bytes())
+	out.Write(g.funcs.Bytes())
+	out.WriteString(runtime)
+	out.Write(g.helperFuncs.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("error formatting generated code: %w", err)
+	}
+	return src, nil
+}
+
+type generator struct {
+	pkg *Package
+	// types, funcs and helperFuncs are the generated declarations.
+	types, funcs, helperFuncs bytes.Buffer
+	// helpers are the IDs of the types whose helpers were generated.
+	helpers map[string]bool
+	// declared are the declared Go types by name.
+	declared map[string]*Type
+	// usesMath is true if the package "math" is used.
+	usesMath bool
+	// usesRuntime is true if the package "wazero" is used.
+	usesRuntime bool
+}
+
+func (g *generator) world(w *World) error {
+	declared := map[*Interface]bool{}
+	for _, i := range append(append([]*Interface{}, w.Imports...), w.Exports...) {
+		if !declared[i] {
+			declared[i] = true
+			if err := g.declareTypes(i.Types); err != nil {
+				return err
+			}
+		}
+	}
+	if err := g.declareTypes(w.Types); err != nil {
+		return err
+	}
+
+	for _, i := range w.Imports {
+		g.hostModule(goName(i.Name), g.pkg.qualify(i), fmt.Sprintf("the interface %q", i.Name), i.Functions)
+	}
+	if len(w.ImportFunctions) > 0 {
+		g.hostModule(goName(w.Name)+"Imports", rootModuleName, fmt.Sprintf("the world %q", w.Name), w.ImportFunctions)
+	}
+
+	var exports []export
+	for _, fn := range w.ExportFunctions {
+		exports = append(exports, export{method: goName(fn.Name), name: fn.Name, fn: fn})
+	}
+	for _, i := range w.Exports {
+		for _, fn := range i.Functions {
+			exports = append(exports, export{
+				method: goName(i.Name) + goName(fn.Name),
+				name:   g.pkg.qualify(i) + "#" + fn.Name,
+				fn:     fn,
+			})
+		}
+	}
+	g.exports(w, exports)
+	return nil
+}
+
+// declareTypes declares the Go types of named types.
+func (g *generator) declareTypes(types []*Type) error {
+	for _, t := range types {
+		name := goName(t.Name)
+		if g.declared[name] != nil {
+			return fmt.Errorf("duplicate type %s", name)
+		}
+		g.declared[name] = t
+		g.declare(t)
+	}
+	return nil
+}
+
+// declare declares the Go type of a named type, tuple or result.
+func (g *generator) declare(t *Type) {
+	// Declare into a separate buffer, as this may declare other types.
+	b := &bytes.Buffer{}
+	defer func() { g.types.Write(b.Bytes()) }()
+	name := g.goType(t)
+	switch t.Kind {
+	case KindAlias:
+		fmt.Fprintf(b, "\n// %s is the type %s.\ntype %s = %s\n", name, t.Name, name, g.goType(t.Elem))
+	case KindRecord, KindTuple:
+		if t.Kind == KindRecord {
+			fmt.Fprintf(b, "\n// %s is the record %s.\n", name, t.Name)
+		} else {
+			fmt.Fprintf(b, "\n// %s is a tuple.\n", name)
+		}
+		fmt.Fprintf(b, "type %s struct {\n", name)
+		for i, f := range t.Fields {
+			fmt.Fprintf(b, "\t%s %s\n", fieldName(t, i), g.goType(f.Type))
+		}
+		b.WriteString("}\n")
+	case KindResult:
+		fmt.Fprintf(b, "\n// %s is a result, which is an error if IsErr is true.\n", name)
+		fmt.Fprintf(b, "type %s struct {\n\tIsErr bool\n", name)
+		if t.Ok != nil {
+			fmt.Fprintf(b, "\tOk %s\n", g.goType(t.Ok))
+		}
+		if t.Err != nil {
+			fmt.Fprintf(b, "\tErr %s\n", g.goType(t.Err))
+		}
+		b.WriteString("}\n")
+	case KindVariant:
+		fmt.Fprintf(b, "\n// %s is the variant %s. Tag is its case, whose value, if any, is in the\n", name, t.Name)
+		fmt.Fprintf(b, "// field of the case.\ntype %s struct {\n\tTag %sTag\n", name, name)
+		for _, f := range t.Fields {
+			if f.Type != nil {
+				fmt.Fprintf(b, "\t%s %s\n", goName(f.Name), g.goType(f.Type))
+			}
+		}
+		b.WriteString("}\n")
+		fmt.Fprintf(b, "\n// %sTag is a case of %s.\ntype %sTag uint%d\n", name, name, name, 8*abi.DiscriminantSize(len(t.Fields)))
+		constants(b, name+"Tag", name, t.Fields, "iota")
+	case KindEnum:
+		fmt.Fprintf(b, "\n// %s is the enum %s.\ntype %s uint%d\n", name, t.Name, name, 8*abi.DiscriminantSize(len(t.Fields)))
+		constants(b, name, name, t.Fields, "iota")
+	case KindFlags:
+		fmt.Fprintf(b, "\n// %s is the flags %s.\ntype %s uint%d\n", name, t.Name, name, 8*abi.Flags(len(t.Fields)).Size)
+		constants(b, name, name, t.Fields, "1 << iota")
+	}
+}
+
+// constants declares a constant of the type for each field, prefixed with
+// its name.
+func constants(b *bytes.Buffer, typ, prefix string, fields []Field, value string) {
+	b.WriteString("\nconst (\n")
+	for i, f := range fields {
+		if i == 0 {
+			fmt.Fprintf(b, "\t%s%s %s = %s\n", prefix, goName(f.Name), typ, value)
+		} else {
+			fmt.Fprintf(b, "\t%s%s\n", prefix, goName(f.Name))
+		}
+	}
+	b.WriteString(")\n")
+}
+
+// fieldName returns the name of the Go field of a record or tuple.
+func fieldName(t *Type, i int) string {
+	if t = underlying(t); t.Kind == KindTuple {
+		return fmt.Sprintf("F%d", i)
+	}
+	return goName(t.Fields[i].Name)
+}
+
+// goType returns the Go type of values of the type.
+func (g *generator) goType(t *Type) string {
+	switch t.Kind {
+	case KindBool:
+		return "bool"
+	case KindS8, KindS16, KindS32, KindS64:
+		return fmt.Sprintf("int%d", 8*size(t))
+	case KindU8, KindU16, KindU32, KindU64:
+		return fmt.Sprintf("uint%d", 8*size(t))
+	case KindF32:
+		return "float32"
+	case KindF64:
+		return "float64"
+	case KindChar:
+		return "rune"
+	case KindString:
+		return "string"
+	case KindList:
+		return "[]" + g.goType(t.Elem)
+	case KindOption:
+		return "*" + g.goType(t.Elem)
+	case KindTuple, KindResult:
+		// These are declared on demand, as they are anonymous.
+		name := id(t)
+		if g.declared[name] == nil {
+			g.declared[name] = t
+			g.declare(t)
+		}
+		return name
+	default:
+		return goName(t.Name)
+	}
+}
+
+// id returns a name of the type, which is unique among the types of a world,
+// e.g. "ListString".
+func id(t *Type) string {
+	switch t = underlying(t); t.Kind {
+	case KindList:
+		return "List" + id(t.Elem)
+	case KindOption:
+		return "Option" + id(t.Elem)
+	case KindResult:
+		ret := "Result"
+		for _, c := range []*Type{t.Ok, t.Err} {
+			if c == nil {
+				ret += "Empty"
+			} else {
+				ret += id(c)
+			}
+		}
+		return ret
+	case KindTuple:
+		ret := "Tuple"
+		for _, f := range t.Fields {
+			ret += id(f.Type)
+		}
+		return ret
+	case KindRecord, KindVariant, KindEnum, KindFlags:
+		return goName(t.Name)
+	default:
+		return goName(primitives[t.Kind])
+	}
+}
+
+// load returns an expression of the value of the type in memory at the
+// offset, which is an expression of type uint32.
+func (g *generator) load(t *Type, offset string) string {
+	switch t = underlying(t); t.Kind {
+	case KindBool:
+		return fmt.Sprintf("c.loadU8(%s) != 0", offset)
+	case KindS8, KindS16, KindS32, KindS64:
+		return fmt.Sprintf("%s(c.loadU%d(%s))", g.goType(t), 8*size(t), offset)
+	case KindU8, KindU16, KindU32, KindU64:
+		return fmt.Sprintf("c.loadU%d(%s)", 8*size(t), offset)
+	case KindF32:
+		g.usesMath = true
+		return fmt.Sprintf("math.Float32frombits(c.loadU32(%s))", offset)
+	case KindF64:
+		g.usesMath = true
+		return fmt.Sprintf("math.Float64frombits(c.loadU64(%s))", offset)
+	case KindChar:
+		return fmt.Sprintf("rune(c.loadU32(%s))", offset)
+	case KindString:
+		return fmt.Sprintf("c.loadString(%s)", offset)
+	default:
+		g.helper(t)
+		return fmt.Sprintf("c.load%s(%s)", id(t), offset)
+	}
+}
+
+// store returns a statement which stores the value of the type in memory at
+// the offset.
+func (g *generator) store(t *Type, offset, value string) string {
+	switch t = underlying(t); t.Kind {
+	case KindBool:
+		return fmt.Sprintf("c.storeBool(%s, %s)", offset, value)
+	case KindS8, KindS16, KindS32, KindS64, KindChar:
+		return fmt.Sprintf("c.storeU%d(%s, uint%d(%s))", 8*size(t), offset, 8*size(t), value)
+	case KindU8, KindU16, KindU32, KindU64:
+		return fmt.Sprintf("c.storeU%d(%s, %s)", 8*size(t), offset, value)
+	case KindF32:
+		g.usesMath = true
+		return fmt.Sprintf("c.storeU32(%s, math.Float32bits(%s))", offset, value)
+	case KindF64:
+		g.usesMath = true
+		return fmt.Sprintf("c.storeU64(%s, math.Float64bits(%s))", offset, value)
+	case KindString:
+		return fmt.Sprintf("c.storeString(%s, %s)", offset, value)
+	default:
+		g.helper(t)
+		return fmt.Sprintf("c.store%s(%s, %s)", id(t), offset, value)
+	}
+}
+
+// lift returns an expression of the value of the type from the core values
+// in the slice named values, starting at the index.
+func (g *generator) lift(t *Type, values string, i int) string {
+	v := fmt.Sprintf("%s[%d]", values, i)
+	switch t = underlying(t); t.Kind {
+	case KindBool:
+		return v + " != 0"
+	case KindS8, KindU8, KindS16, KindU16, KindS32, KindU32, KindS64:
+		return fmt.Sprintf("%s(%s)", g.goType(t), v)
+	case KindU64:
+		return v
+	case KindF32:
+		g.usesMath = true
+		return fmt.Sprintf("math.Float32frombits(uint32(%s))", v)
+	case KindF64:
+		g.usesMath = true
+		return fmt.Sprintf("math.Float64frombits(%s)", v)
+	case KindChar:
+		return fmt.Sprintf("rune(%s)", v)
+	case KindString:
+		return fmt.Sprintf("c.liftString(%s[%d:%d])", values, i, i+2)
+	default:
+		g.helper(t)
+		return fmt.Sprintf("c.lift%s(%s[%d:%d])", id(t), values, i, i+len(flat(t)))
+	}
+}
+
+// lower returns a statement which lowers the value of the type to the core
+// values in the slice named values, starting at the index.
+func (g *generator) lower(t *Type, value, values string, i int) string {
+	v := fmt.Sprintf("%s[%d]", values, i)
+	switch t = underlying(t); t.Kind {
+	case KindBool:
+		return fmt.Sprintf("%s = flatBool(%s)", v, value)
+	case KindS8, KindS16, KindS32, KindChar:
+		return fmt.Sprintf("%s = uint64(uint32(%s))", v, value)
+	case KindU8, KindU16, KindU32, KindS64:
+		return fmt.Sprintf("%s = uint64(%s)", v, value)
+	case KindU64:
+		return fmt.Sprintf("%s = %s", v, value)
+	case KindF32:
+		g.usesMath = true
+		return fmt.Sprintf("%s = uint64(math.Float32bits(%s))", v, value)
+	case KindF64:
+		g.usesMath = true
+		return fmt.Sprintf("%s = math.Float64bits(%s)", v, value)
+	case KindString:
+		return fmt.Sprintf("c.lowerString(%s, %s[%d:%d])", value, values, i, i+2)
+	default:
+		g.helper(t)
+		return fmt.Sprintf("c.lower%s(%s, %s[%d:%d])", id(t), value, values, i, i+len(flat(t)))
+	}
+}
+
+// helper generates the methods of canon which load, store, lift and lower
+// values of the type, unless already generated.
+func (g *generator) helper(t *Type) {
+	name := id(t)
+	if g.helpers[name] {
+		return
+	}
+	g.helpers[name] = true
+
+	// Generate into a separate buffer, as this may generate other helpers.
+	var b bytes.Buffer
+	goT := g.goType(t)
+	switch t.Kind {
+	case KindList:
+		g.listHelper(&b, t, name, goT)
+	case KindRecord, KindTuple:
+		g.recordHelper(&b, t, name, goT)
+	case KindFlags:
+		bits := 8 * abi.Flags(len(t.Fields)).Size
+		fmt.Fprintf(&b, "\nfunc (c *canon) load%s(off uint32) %s {\n\treturn %s(c.loadU%d(off))\n}\n", name, goT, goT, bits)
+		fmt.Fprintf(&b, "\nfunc (c *canon) store%s(off uint32, v %s) {\n\tc.storeU%d(off, uint%d(v))\n}\n", name, goT, bits, bits)
+		fmt.Fprintf(&b, "\nfunc (c *canon) lift%s(flat []uint64) %s {\n\treturn %s(flat[0])\n}\n", name, goT, goT)
+		fmt.Fprintf(&b, "\nfunc (c *canon) lower%s(v %s, flat []uint64) {\n\tflat[0] = uint64(v)\n}\n", name, goT)
+	default:
+		g.variantHelper(&b, t, name, goT)
+	}
+	g.helperFuncs.Write(b.Bytes())
+}
+
+func (g *generator) listHelper(b *bytes.Buffer, t *Type, name, goT string) {
+	elem := t.Elem
+	if underlying(elem).Kind == KindU8 {
+		fmt.Fprintf(b, "\nfunc (c *canon) read%s(ptr, n uint32) %s {\n\treturn append(%s{}, c.read(ptr, uint64(n))...)\n}\n", name, goT, goT)
+		fmt.Fprintf(b, "\nfunc (c *canon) write%s(v %s) (uint32, uint32) {\n", name, goT)
+		b.WriteString("\tptr := c.alloc(uint32(len(v)), 1)\n\tc.write(ptr, v)\n\treturn ptr, uint32(len(v))\n}\n")
+	} else {
+		elemSize := size(elem)
+		offset := fmt.Sprintf("ptr+uint32(i)*%d", elemSize)
+		fmt.Fprintf(b, "\nfunc (c *canon) read%s(ptr, n uint32) %s {\n", name, goT)
+		fmt.Fprintf(b, "\tc.read(ptr, uint64(n)*%d)\n\tret := make(%s, n)\n\tfor i := range ret {\n", elemSize, goT)
+		fmt.Fprintf(b, "\t\tret[i] = %s\n\t}\n\treturn ret\n}\n", g.load(elem, offset))
+		fmt.Fprintf(b, "\nfunc (c *canon) write%s(v %s) (uint32, uint32) {\n", name, goT)
+		fmt.Fprintf(b, "\tptr := c.alloc(uint32(len(v))*%d, %d)\n\tfor i, e := range v {\n", elemSize, alignment(elem))
+		fmt.Fprintf(b, "\t\t%s\n\t}\n\treturn ptr, uint32(len(v))\n}\n", g.store(elem, offset, "e"))
+	}
+	fmt.Fprintf(b, "\nfunc (c *canon) load%s(off uint32) %s {\n\treturn c.read%s(c.loadU32(off), c.loadU32(off+4))\n}\n", name, goT, name)
+	fmt.Fprintf(b, "\nfunc (c *canon) store%s(off uint32, v %s) {\n", name, goT)
+	fmt.Fprintf(b, "\tptr, n := c.write%s(v)\n\tc.storeU32(off, ptr)\n\tc.storeU32(off+4, n)\n}\n", name)
+	fmt.Fprintf(b, "\nfunc (c *canon) lift%s(flat []uint64) %s {\n\treturn c.read%s(uint32(flat[0]), uint32(flat[1]))\n}\n", name, goT, name)
+	fmt.Fprintf(b, "\nfunc (c *canon) lower%s(v %s, flat []uint64) {\n", name, goT)
+	fmt.Fprintf(b, "\tptr, n := c.write%s(v)\n\tflat[0], flat[1] = uint64(ptr), uint64(n)\n}\n", name)
+}
+
+func (g *generator) recordHelper(b *bytes.Buffer, t *Type, name, goT string) {
+	types := fields(t)
+	offsets := fieldOffsets(types)
+
+	fmt.Fprintf(b, "\nfunc (c *canon) load%s(off uint32) %s {\n\treturn %s{\n", name, goT, goT)
+	for i, f := range types {
+		fmt.Fprintf(b, "\t\t%s: %s,\n", fieldName(t, i), g.load(f, plus("off", offsets[i])))
+	}
+	b.WriteString("\t}\n}\n")
+
+	fmt.Fprintf(b, "\nfunc (c *canon) store%s(off uint32, v %s) {\n", name, goT)
+	for i, f := range types {
+		fmt.Fprintf(b, "\t%s\n", g.store(f, plus("off", offsets[i]), "v."+fieldName(t, i)))
+	}
+	b.WriteString("}\n")
+
+	fmt.Fprintf(b, "\nfunc (c *canon) lift%s(flat []uint64) %s {\n\treturn %s{\n", name, goT, goT)
+	i := 0
+	for j, f := range types {
+		fmt.Fprintf(b, "\t\t%s: %s,\n", fieldName(t, j), g.lift(f, "flat", i))
+		i += len(flat(f))
+	}
+	b.WriteString("\t}\n}\n")
+
+	fmt.Fprintf(b, "\nfunc (c *canon) lower%s(v %s, flat []uint64) {\n", name, goT)
+	i = 0
+	for j, f := range types {
+		fmt.Fprintf(b, "\t%s\n", g.lower(f, "v."+fieldName(t, j), "flat", i))
+		i += len(flat(f))
+	}
+	b.WriteString("}\n")
+}
+
+// variantHelper generates the helpers of variants, options, results and
+// enums.
+func (g *generator) variantHelper(b *bytes.Buffer, t *Type, name, goT string) {
+	cs := cases(t)
+	bits := 8 * abi.DiscriminantSize(len(cs))
+	payload := plus("off", payloadOffset(t))
+	for _, mode := range []struct {
+		op, disc string
+		value    func(c *Type) string
+	}{
+		{op: "load", disc: fmt.Sprintf("uint32(c.loadU%d(off))", bits), value: func(c *Type) string { return g.load(c, payload) }},
+		{op: "lift", disc: "uint32(flat[0])", value: func(c *Type) string { return g.lift(c, "flat", 1) }},
+	} {
+		if mode.op == "load" {
+			fmt.Fprintf(b, "\nfunc (c *canon) load%s(off uint32) (v %s) {\n", name, goT)
+		} else {
+			fmt.Fprintf(b, "\nfunc (c *canon) lift%s(flat []uint64) (v %s) {\n", name, goT)
+		}
+		fmt.Fprintf(b, "\tdisc := %s\n\tc.checkDiscriminant(disc, %d)\n", mode.disc, len(cs))
+		switch t.Kind {
+		case KindOption:
+			fmt.Fprintf(b, "\tif disc == 1 {\n\t\te := %s\n\t\tv = &e\n\t}\n", mode.value(t.Elem))
+		case KindResult:
+			b.WriteString("\tv.IsErr = disc == 1\n")
+			switch {
+			case t.Ok != nil && t.Err != nil:
+				fmt.Fprintf(b, "\tif v.IsErr {\n\t\tv.Err = %s\n\t} else {\n\t\tv.Ok = %s\n\t}\n", mode.value(t.Err), mode.value(t.Ok))
+			case t.Ok != nil:
+				fmt.Fprintf(b, "\tif !v.IsErr {\n\t\tv.Ok = %s\n\t}\n", mode.value(t.Ok))
+			case t.Err != nil:
+				fmt.Fprintf(b, "\tif v.IsErr {\n\t\tv.Err = %s\n\t}\n", mode.value(t.Err))
+			}
+		case KindEnum:
+			fmt.Fprintf(b, "\tv = %s(disc)\n", goT)
+		case KindVariant:
+			fmt.Fprintf(b, "\tv.Tag = %sTag(disc)\n", goT)
+			g.variantSwitch(b, t, func(f Field) string {
+				return fmt.Sprintf("v.%s = %s", goName(f.Name), mode.value(f.Type))
+			})
+		}
+		b.WriteString("\treturn\n}\n")
+	}
+
+	for _, mode := range []struct {
+		op, sig string
+		disc    func(v string) string
+		value   func(c *Type, v string) string
+	}{
+		{
+			op: "store", sig: "off uint32, v " + goT,
+			disc:  func(v string) string { return fmt.Sprintf("c.storeU%d(off, uint%d(%s))", bits, bits, v) },
+			value: func(c *Type, v string) string { return g.store(c, payload, v) },
+		},
+		{
+			op: "lower", sig: "v " + goT + ", flat []uint64",
+			disc:  func(v string) string { return fmt.Sprintf("flat[0] = uint64(%s)", v) },
+			value: func(c *Type, v string) string { return g.lower(c, v, "flat", 1) },
+		},
+	} {
+		fmt.Fprintf(b, "\nfunc (c *canon) %s%s(%s) {\n", mode.op, name, mode.sig)
+		switch t.Kind {
+		case KindOption:
+			fmt.Fprintf(b, "\tif v == nil {\n\t\t%s\n\t\treturn\n\t}\n", mode.disc("0"))
+			fmt.Fprintf(b, "\t%s\n\t%s\n", mode.disc("1"), mode.value(t.Elem, "*v"))
+		case KindResult:
+			b.WriteString("\tif !v.IsErr {\n")
+			fmt.Fprintf(b, "\t\t%s\n", mode.disc("0"))
+			if t.Ok != nil {
+				fmt.Fprintf(b, "\t\t%s\n", mode.value(t.Ok, "v.Ok"))
+			}
+			fmt.Fprintf(b, "\t\treturn\n\t}\n\t%s\n", mode.disc("1"))
+			if t.Err != nil {
+				fmt.Fprintf(b, "\t%s\n", mode.value(t.Err, "v.Err"))
+			}
+		case KindEnum:
+			fmt.Fprintf(b, "\t%s\n", mode.disc("v"))
+		case KindVariant:
+			fmt.Fprintf(b, "\t%s\n", mode.disc("v.Tag"))
+			g.variantSwitch(b, t, func(f Field) string {
+				return mode.value(f.Type, "v."+goName(f.Name))
+			})
+		}
+		b.WriteString("}\n")
+	}
+}
+
+// variantSwitch generates a switch on the tag of a variant, with the
+// statement of each case with a type.
+func (g *generator) variantSwitch(b *bytes.Buffer, t *Type, stmt func(f Field) string) {
+	var body bytes.Buffer
+	for _, f := range t.Fields {
+		if f.Type != nil {
+			fmt.Fprintf(&body, "\tcase %s%s:\n\t\t%s\n", goName(t.Name), goName(f.Name), stmt(f))
+		}
+	}
+	if body.Len() > 0 {
+		fmt.Fprintf(b, "\tswitch v.Tag {\n%s\t}\n", body.String())
+	}
+}
+
+// plus returns an expression of the offset plus n.
+func plus(offset string, n uint32) string {
+	if n == 0 {
+		return offset
+	}
+	return fmt.Sprintf("%s+%d", offset, n)
+}
+
+// hostModule generates a Go interface with the functions, and a function
+// which instantiates a host module named moduleName with an implementation.
+func (g *generator) hostModule(name, moduleName, of string, functions []*Function) {
+	g.usesRuntime = true
+	b := &g.funcs
+	fmt.Fprintf(b, "\n// %s is implemented by the host to provide the functions of %s\n", name, of)
+	fmt.Fprintf(b, "// to guests, with Instantiate%s. Returning an error traps the guest.\n", name)
+	fmt.Fprintf(b, "type %s interface {\n", name)
+	for _, fn := range functions {
+		fmt.Fprintf(b, "\t%s%s\n", goName(fn.Name), g.signature(fn))
+	}
+	b.WriteString("}\n")
+
+	fmt.Fprintf(b, "\n// Instantiate%s instantiates the host module %q, which\n", name, moduleName)
+	fmt.Fprintf(b, "// guests import %s from, implemented by impl.\n", of)
+	fmt.Fprintf(b, "func Instantiate%s(ctx context.Context, r wazero.Runtime, impl %s) (api.Closer, error) {\n", name, name)
+	fmt.Fprintf(b, "\tb := r.NewHostModuleBuilder(%q)\n", moduleName)
+	for _, fn := range functions {
+		g.hostFunction(b, fn)
+	}
+	b.WriteString("\treturn b.Instantiate(ctx, r)\n}\n")
+}
+
+// hostFunction generates the definition of the host function for fn.
+func (g *generator) hostFunction(b *bytes.Buffer, fn *Function) {
+	params, results := coreSignature(fn)
+	b.WriteString("\tb.NewFunctionBuilder().\n")
+	b.WriteString("\t\tWithGoModuleFunction(api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {\n")
+
+	// The body is generated first, as c is only declared if used.
+	outer := b
+	b = &bytes.Buffer{}
+	types := paramTypes(fn)
+	args := []string{"ctx"}
+	if len(flatAll(types)) > abi.MaxFlatParams {
+		b.WriteString("\t\t\tptr := uint32(stack[0])\n")
+		offsets := fieldOffsets(types)
+		for i, t := range types {
+			fmt.Fprintf(b, "\t\t\tp%d := %s\n", i, g.load(t, plus("ptr", offsets[i])))
+			args = append(args, fmt.Sprintf("p%d", i))
+		}
+	} else {
+		i := 0
+		for j, t := range types {
+			fmt.Fprintf(b, "\t\t\tp%d := %s\n", j, g.lift(t, "stack", i))
+			args = append(args, fmt.Sprintf("p%d", j))
+			i += len(flat(t))
+		}
+	}
+
+	call := fmt.Sprintf("impl.%s(%s)", goName(fn.Name), strings.Join(args, ", "))
+	if fn.Result == nil {
+		fmt.Fprintf(b, "\t\t\tif err := %s; err != nil {\n\t\t\t\tpanic(err)\n\t\t\t}\n", call)
+	} else {
+		fmt.Fprintf(b, "\t\t\tr, err := %s\n\t\t\tif err != nil {\n\t\t\t\tpanic(err)\n\t\t\t}\n", call)
+		if len(flat(fn.Result)) > abi.MaxFlatResults {
+			fmt.Fprintf(b, "\t\t\t%s\n", g.store(fn.Result, fmt.Sprintf("uint32(stack[%d])", len(params)-1), "r"))
+		} else {
+			fmt.Fprintf(b, "\t\t\t%s\n", g.lower(fn.Result, "r", "stack", 0))
+		}
+	}
+	if bytes.Contains(b.Bytes(), []byte("c.")) {
+		outer.WriteString("\t\t\tc := &canon{ctx: ctx, mod: mod}\n")
+	}
+	outer.Write(b.Bytes())
+	b = outer
+
+	fmt.Fprintf(b, "\t\t}), %s, %s).\n", valueTypes(params), valueTypes(results))
+	if len(params) > 0 {
+		fmt.Fprintf(b, "\t\tWithParameterNames(%s).\n", parameterNames(fn, params))
+	}
+	fmt.Fprintf(b, "\t\tExport(%q)\n", fn.Name)
+}
+
+// export is a function exported by the guest.
+type export struct {
+	// method is the name of the Go method which calls it.
+	method string
+	// name is the name of the function in the core module.
+	name string
+	fn   *Function
+}
+
+// exports generates a struct whose methods call the exports.
+func (g *generator) exports(w *World, exports []export) {
+	name := goName(w.Name)
+	b := &g.funcs
+	fmt.Fprintf(b, "\n// %s calls the functions an instance of a guest exports for the world %q.\n", name, w.Name)
+	fmt.Fprintf(b, "type %s struct {\n\tmod api.Module\n", name)
+	b.WriteString("\t// Each export fX has a post-return function postX, or nil if none.\n")
+	for _, e := range exports {
+		fmt.Fprintf(b, "\tf%s, post%s api.Function\n", e.method, e.method)
+	}
+	b.WriteString("}\n")
+
+	fmt.Fprintf(b, "\n// New%s returns the exports of mod, which must be an instance of a guest of\n", name)
+	fmt.Fprintf(b, "// the world %q.\n", w.Name)
+	fmt.Fprintf(b, "func New%s(mod api.Module) (*%s, error) {\n\tw := &%s{mod: mod}\n", name, name, name)
+	for _, e := range exports {
+		fmt.Fprintf(b, "\tif w.f%s = mod.ExportedFunction(%q); w.f%s == nil {\n", e.method, e.name, e.method)
+		fmt.Fprintf(b, "\t\treturn nil, fmt.Errorf(\"module[%%s] doesn't export function[%%s]\", mod.Name(), %q)\n\t}\n", e.name)
+		fmt.Fprintf(b, "\tw.post%s = mod.ExportedFunction(%q)\n", e.method, "cabi_post_"+e.name)
+	}
+	b.WriteString("\treturn w, nil\n}\n")
+
+	for _, e := range exports {
+		g.exportMethod(b, name, e)
+	}
+}
+
+// exportMethod generates the method which calls the export.
+func (g *generator) exportMethod(b *bytes.Buffer, world string, e export) {
+	fn := e.fn
+	fmt.Fprintf(b, "\n// %s calls the export %q.\n", e.method, e.name)
+	fmt.Fprintf(b, "func (w *%s) %s%s {\n", world, e.method, g.exportSignature(fn))
+	b.WriteString("\tc := &canon{ctx: ctx, mod: w.mod}\n\tdefer c.recover(&err)\n")
+
+	types := paramTypes(fn)
+	names := paramNames(fn)
+	call := fmt.Sprintf("w.f%s.Call(ctx)", e.method)
+	if flatParams := flatAll(types); len(flatParams) > abi.MaxFlatParams {
+		l, offsets := recordLayout(types)
+		fmt.Fprintf(b, "\tptr := c.alloc(%d, %d)\n", l.Size, l.Alignment)
+		for i, t := range types {
+			fmt.Fprintf(b, "\t%s\n", g.store(t, plus("ptr", offsets[i]), names[i]))
+		}
+		call = fmt.Sprintf("w.f%s.Call(ctx, uint64(ptr))", e.method)
+	} else if len(flatParams) > 0 {
+		fmt.Fprintf(b, "\tparams := make([]uint64, %d)\n", len(flatParams))
+		i := 0
+		for j, t := range types {
+			fmt.Fprintf(b, "\t%s\n", g.lower(t, names[j], "params", i))
+			i += len(flat(t))
+		}
+		call = fmt.Sprintf("w.f%s.Call(ctx, params...)", e.method)
+	}
+
+	fmt.Fprintf(b, "\tresults, err := %s\n\tif err != nil {\n\t\treturn\n\t}\n", call)
+	if fn.Result != nil {
+		if len(flat(fn.Result)) > abi.MaxFlatResults {
+			fmt.Fprintf(b, "\tr = %s\n", g.load(fn.Result, "uint32(results[0])"))
+		} else {
+			fmt.Fprintf(b, "\tr = %s\n", g.lift(fn.Result, "results", 0))
+		}
+	}
+	fmt.Fprintf(b, "\tif w.post%s != nil {\n\t\t_, err = w.post%s.Call(ctx, results...)\n\t}\n\treturn\n}\n", e.method, e.method)
+}
+
+// signature returns the signature of the Go method of an imported function.
+func (g *generator) signature(fn *Function) string {
+	params := []string{"ctx context.Context"}
+	for i, name := range paramNames(fn) {
+		params = append(params, name+" "+g.goType(fn.Params[i].Type))
+	}
+	if fn.Result == nil {
+		return fmt.Sprintf("(%s) error", strings.Join(params, ", "))
+	}
+	return fmt.Sprintf("(%s) (%s, error)", strings.Join(params, ", "), g.goType(fn.Result))
+}
+
+// exportSignature returns the signature of the Go method which calls an
+// exported function, with named results.
+func (g *generator) exportSignature(fn *Function) string {
+	params := []string{"ctx context.Context"}
+	for i, name := range paramNames(fn) {
+		params = append(params, name+" "+g.goType(fn.Params[i].Type))
+	}
+	if fn.Result == nil {
+		return fmt.Sprintf("(%s) (err error)", strings.Join(params, ", "))
+	}
+	return fmt.Sprintf("(%s) (r %s, err error)", strings.Join(params, ", "), g.goType(fn.Result))
+}
+
+// coreSignature returns the types of the core function of fn.
+func coreSignature(fn *Function) (params, results []api.ValueType) {
+	if params = flatAll(paramTypes(fn)); len(params) > abi.MaxFlatParams {
+		params = []api.ValueType{api.ValueTypeI32}
+	}
+	if fn.Result != nil {
+		if results = flat(fn.Result); len(results) > abi.MaxFlatResults {
+			// The results are stored in memory at the pointer passed last.
+			params, results = append(params, api.ValueTypeI32), nil
+		}
+	}
+	return
+}
+
+func paramTypes(fn *Function) []*Type {
+	ret := make([]*Type, len(fn.Params))
+	for i, p := range fn.Params {
+		ret[i] = p.Type
+	}
+	return ret
+}
+
+// reserved are the names of variables of generated functions, which params
+// can't have.
+var reserved = map[string]bool{"ctx": true, "c": true, "w": true, "r": true, "err": true, "ptr": true, "params": true, "results": true}
+
+// paramNames returns the names of the Go parameters of the function.
+func paramNames(fn *Function) []string {
+	ret := make([]string, len(fn.Params))
+	for i, p := range fn.Params {
+		name := goName(p.Name)
+		name = strings.ToLower(name[:1]) + name[1:]
+		if reserved[name] || gotoken.IsKeyword(name) {
+			name += "_"
+		}
+		ret[i] = name
+	}
+	return ret
+}
+
+// parameterNames returns the arguments of WithParameterNames for the core
+// parameters of the function.
+func parameterNames(fn *Function, params []api.ValueType) string {
+	var names []string
+	if len(flatAll(paramTypes(fn))) > abi.MaxFlatParams {
+		names = append(names, "params")
+	} else {
+		for _, p := range fn.Params {
+			n := len(flat(p.Type))
+			for i := 0; i < n; i++ {
+				if n == 1 {
+					names = append(names, p.Name)
+				} else {
+					names = append(names, fmt.Sprintf("%s%d", p.Name, i))
+				}
+			}
+		}
+	}
+	if len(names) < len(params) {
+		names = append(names, "result")
+	}
+	quoted := make([]string, len(names))
+	for i, n := range names {
+		quoted[i] = fmt.Sprintf("%q", n)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func valueTypes(types []api.ValueType) string {
+	if len(types) == 0 {
+		return "nil"
+	}
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = "api.ValueType" + strings.ToUpper(api.ValueTypeName(t))
+	}
+	return "[]api.ValueType{" + strings.Join(names, ", ") + "}"
+}
+
+// goName returns the exported Go name of a WIT identifier, e.g. "GetName"
+// for "get-name".
+func goName(name string) string {
+	parts := strings.Split(name, "-")
+	for i, p := range parts {
+		if p != "" {
+			parts[i] = strings.ToUpper(p[:1]) + p[1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// runtime is the code shared by the generated bindings.
+const runtime = `
+// canon lifts and lowers values with the canonical ABI, in the memory of mod.
+type canon struct {
+	ctx     context.Context
+	mod     api.Module
+	realloc api.Function
+}
+
+// abiError is an error lifting or lowering a value, which traps.
+type abiError struct{ error }
+
+func (c *canon) trap(format string, args ...interface{}) {
+	panic(abiError{fmt.Errorf(format, args...)})
+}
+
+// recover sets err to the error of a trap, if any.
+func (c *canon) recover(err *error) {
+	if r := recover(); r != nil {
+		e, ok := r.(abiError)
+		if !ok {
+			panic(r)
+		}
+		*err = e.error
+	}
+}
+
+// alloc allocates memory with the function "cabi_realloc" of the guest.
+func (c *canon) alloc(size, align uint32) uint32 {
+	if c.realloc == nil {
+		if c.realloc = c.mod.ExportedFunction("cabi_realloc"); c.realloc == nil {
+			c.trap("module[%s] doesn't export function[cabi_realloc]", c.mod.Name())
+		}
+	}
+	results, err := c.realloc.Call(c.ctx, 0, 0, uint64(align), uint64(size))
+	if err != nil {
+		panic(abiError{err})
+	}
+	ptr := uint32(results[0])
+	if ptr%align != 0 {
+		c.trap("cabi_realloc returned unaligned pointer %d", ptr)
+	}
+	return ptr
+}
+
+func (c *canon) checkDiscriminant(disc, cases uint32) {
+	if disc >= cases {
+		c.trap("invalid discriminant %d", disc)
+	}
+}
+
+func (c *canon) read(ptr uint32, size uint64) []byte {
+	if size > 0xffffffff {
+		c.trap("out of range reading %d bytes at offset %d", size, ptr)
+	}
+	b, ok := c.mod.Memory().Read(ptr, uint32(size))
+	if !ok {
+		c.trap("out of range reading %d bytes at offset %d", size, ptr)
+	}
+	return b
+}
+
+func (c *canon) write(ptr uint32, b []byte) {
+	if !c.mod.Memory().Write(ptr, b) {
+		c.trap("out of range writing %d bytes at offset %d", len(b), ptr)
+	}
+}
+
+func (c *canon) loadU8(off uint32) uint8 {
+	return c.read(off, 1)[0]
+}
+
+func (c *canon) loadU16(off uint32) uint16 {
+	v, _ := c.mod.Memory().ReadUint16Le(c.checkOffset(off, 2))
+	return v
+}
+
+func (c *canon) loadU32(off uint32) uint32 {
+	v, _ := c.mod.Memory().ReadUint32Le(c.checkOffset(off, 4))
+	return v
+}
+
+func (c *canon) loadU64(off uint32) uint64 {
+	v, _ := c.mod.Memory().ReadUint64Le(c.checkOffset(off, 8))
+	return v
+}
+
+// checkOffset traps unless the size bytes at the offset are in memory.
+func (c *canon) checkOffset(off, size uint32) uint32 {
+	c.read(off, uint64(size))
+	return off
+}
+
+func (c *canon) storeBool(off uint32, v bool) {
+	c.storeU8(off, uint8(flatBool(v)))
+}
+
+func (c *canon) storeU8(off uint32, v uint8) {
+	c.write(off, []byte{v})
+}
+
+func (c *canon) storeU16(off uint32, v uint16) {
+	c.mod.Memory().WriteUint16Le(c.checkOffset(off, 2), v)
+}
+
+func (c *canon) storeU32(off uint32, v uint32) {
+	c.mod.Memory().WriteUint32Le(c.checkOffset(off, 4), v)
+}
+
+func (c *canon) storeU64(off uint32, v uint64) {
+	c.mod.Memory().WriteUint64Le(c.checkOffset(off, 8), v)
+}
+
+func (c *canon) readString(ptr, n uint32) string {
+	return string(c.read(ptr, uint64(n)))
+}
+
+func (c *canon) writeString(v string) (uint32, uint32) {
+	ptr := c.alloc(uint32(len(v)), 1)
+	c.write(ptr, []byte(v))
+	return ptr, uint32(len(v))
+}
+
+func (c *canon) loadString(off uint32) string {
+	return c.readString(c.loadU32(off), c.loadU32(off+4))
+}
+
+func (c *canon) storeString(off uint32, v string) {
+	ptr, n := c.writeString(v)
+	c.storeU32(off, ptr)
+	c.storeU32(off+4, n)
+}
+
+func (c *canon) liftString(flat []uint64) string {
+	return c.readString(uint32(flat[0]), uint32(flat[1]))
+}
+
+func (c *canon) lowerString(v string, flat []uint64) {
+	ptr, n := c.writeString(v)
+	flat[0], flat[1] = uint64(ptr), uint64(n)
+}
+
+func flatBool(v bool) uint64 {
+	if v {
+		return 1
+	}
+	return 0
+}
+`
@@ -0,0 +1,49 @@
+package wit
+
+import (
+	"os"
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+// TestGenerate_witexample ensures the bindings in witexample, which are
+// tested with a guest, are those generated from roundtrip.wit. Regenerate them
+// with: go run ./cmd/wazero wit-bindgen --package witexample -o internal/wit/witexample/roundtrip.go internal/wit/witexample/roundtrip.wit
+func TestGenerate_witexample(t *testing.T) {
+	source, err := os.ReadFile("witexample/roundtrip.wit")
+	require.NoError(t, err)
+	expected, err := os.ReadFile("witexample/roundtrip.go")
+	require.NoError(t, err)
+
+	pkg, err := Parse(string(source))
+	require.NoError(t, err)
+	code, err := Generate(pkg, pkg.World("roundtrip"), "witexample")
+	require.NoError(t, err)
+	require.Equal(t, string(expected), string(code))
+}
+
+func TestGenerate(t *testing.T) {
+	pkg, err := Parse(`
+package example:types;
+
+world types {
+  flags many { a, b, c, d, e, f, g, h, i }
+  variant value { none, text(string), number(f64) }
+  export check: func(m: many, v: value, l: list<list<u16>>) -> option<result<_, string>>;
+}
+`)
+	require.NoError(t, err)
+	code, err := Generate(pkg, pkg.Worlds[0], "types")
+	require.NoError(t, err)
+
+	src := string(code)
+	for _, expected := range []string{
+		"type Many uint16",
+		"\tTag    ValueTag\n\tText   string\n\tNumber float64\n",
+		"func (w *Types) Check(ctx context.Context, m Many, v Value, l [][]uint16) (r *ResultEmptyString, err error) {",
+		"func (c *canon) loadListListU16(off uint32) [][]uint16 {",
+	} {
+		require.Contains(t, src, expected)
+	}
+}
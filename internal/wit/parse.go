@@ -0,0 +1,742 @@
+package wit
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Parse parses the content of a WIT file.
+func Parse(source string) (*Package, error) {
+	p := &parser{lexer: lexer{src: source, line: 1}}
+	pkg, err := p.parsePackage()
+	if err != nil {
+		return nil, err
+	}
+	if err = resolve(pkg); err != nil {
+		return nil, err
+	}
+	return pkg, nil
+}
+
+// syntaxError is an error at a line of the source.
+type syntaxError struct {
+	line int
+	msg  string
+}
+
+func (e *syntaxError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.line, e.msg)
+}
+
+type token struct {
+	// text is an identifier, a keyword, a version or punctuation.
+	text string
+	line int
+	// ident is true for identifiers and keywords.
+	ident bool
+}
+
+// lexer splits the source into tokens, skipping whitespace and comments.
+type lexer struct {
+	src  string
+	pos  int
+	line int
+	// peeked is the next token, if peek was called.
+	peeked *token
+}
+
+func (l *lexer) next() (token, error) {
+	if l.peeked != nil {
+		t := *l.peeked
+		l.peeked = nil
+		return t, nil
+	}
+	if err := l.skip(); err != nil {
+		return token{}, err
+	}
+	if l.pos == len(l.src) {
+		return token{line: l.line}, nil
+	}
+
+	start, c := l.pos, l.src[l.pos]
+	switch {
+	case c == '-' && strings.HasPrefix(l.src[l.pos:], "->"):
+		l.pos += 2
+	case c == '%' || isIdentChar(c):
+		l.pos++
+		for l.pos < len(l.src) && isIdentChar(l.src[l.pos]) {
+			l.pos++
+		}
+		text := strings.TrimPrefix(l.src[start:l.pos], "%")
+		if text == "" || text[0] == '-' || text[len(text)-1] == '-' {
+			return token{}, &syntaxError{l.line, fmt.Sprintf("invalid identifier %q", l.src[start:l.pos])}
+		}
+		return token{text: text, line: l.line, ident: true}, nil
+	case strings.IndexByte("{}()<>,:;=./@*_", c) >= 0:
+		l.pos++
+	default:
+		return token{}, &syntaxError{l.line, fmt.Sprintf("unexpected character %q", c)}
+	}
+	return token{text: l.src[start:l.pos], line: l.line}, nil
+}
+
+func (l *lexer) peek() (token, error) {
+	if l.peeked == nil {
+		t, err := l.next()
+		if err != nil {
+			return t, err
+		}
+		l.peeked = &t
+	}
+	return *l.peeked, nil
+}
+
+// version reads a semantic version, e.g. "0.2.0-rc.1", after "@".
+func (l *lexer) version() string {
+	start := l.pos
+	for l.pos < len(l.src) && (isIdentChar(l.src[l.pos]) || strings.IndexByte(".+", l.src[l.pos]) >= 0) {
+		l.pos++
+	}
+	return l.src[start:l.pos]
+}
+
+// skip skips whitespace and comments.
+func (l *lexer) skip() error {
+	for l.pos < len(l.src) {
+		switch rest := l.src[l.pos:]; {
+		case rest[0] == '\n':
+			l.line++
+			l.pos++
+		case rest[0] == ' ' || rest[0] == '\t' || rest[0] == '\r':
+			l.pos++
+		case strings.HasPrefix(rest, "//"):
+			if i := strings.IndexByte(rest, '\n'); i >= 0 {
+				l.pos += i
+			} else {
+				l.pos = len(l.src)
+			}
+		case strings.HasPrefix(rest, "/*"):
+			i := strings.Index(rest, "*/")
+			if i < 0 {
+				return &syntaxError{l.line, "unterminated comment"}
+			}
+			l.line += strings.Count(rest[:i], "\n")
+			l.pos += i + 2
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func isIdentChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-'
+}
+
+type parser struct {
+	lexer
+	pkg *Package
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return &syntaxError{t.line, fmt.Sprintf(format, args...)}
+}
+
+// expect reads a token, which must be the text.
+func (p *parser) expect(text string) (token, error) {
+	t, err := p.next()
+	if err != nil {
+		return t, err
+	}
+	if t.text != text {
+		return t, p.errorf(t, "expected %q, but was %s", text, describe(t))
+	}
+	return t, nil
+}
+
+// ident reads an identifier.
+func (p *parser) ident() (string, error) {
+	t, err := p.next()
+	if err != nil {
+		return "", err
+	}
+	if !t.ident {
+		return "", p.errorf(t, "expected an identifier, but was %s", describe(t))
+	}
+	return t.text, nil
+}
+
+// accept reads the next token if it is the text, returning true if it was.
+func (p *parser) accept(text string) (bool, error) {
+	t, err := p.peek()
+	if err != nil || t.text != text {
+		return false, err
+	}
+	_, err = p.next()
+	return true, err
+}
+
+func describe(t token) string {
+	if t.text == "" {
+		return "the end"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+func (p *parser) parsePackage() (*Package, error) {
+	p.pkg = &Package{}
+	if ok, err := p.accept("package"); err != nil {
+		return nil, err
+	} else if ok {
+		if err = p.parsePackageName(); err != nil {
+			return nil, err
+		}
+	}
+
+	for {
+		t, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		switch t.text {
+		case "":
+			return p.pkg, nil
+		case "interface":
+			name, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			i := &Interface{Name: name}
+			if err = p.parseInterface(i); err != nil {
+				return nil, err
+			}
+			p.pkg.Interfaces = append(p.pkg.Interfaces, i)
+		case "world":
+			w, err := p.parseWorld()
+			if err != nil {
+				return nil, err
+			}
+			p.pkg.Worlds = append(p.pkg.Worlds, w)
+		case "use", "package":
+			return nil, p.errorf(t, "%s isn't supported", t.text)
+		default:
+			return nil, p.errorf(t, "expected interface or world, but was %s", describe(t))
+		}
+	}
+}
+
+// parsePackageName parses e.g. "wasi:cli@0.2.0;".
+func (p *parser) parsePackageName() (err error) {
+	if p.pkg.Namespace, err = p.ident(); err != nil {
+		return
+	}
+	if _, err = p.expect(":"); err != nil {
+		return
+	}
+	if p.pkg.Name, err = p.ident(); err != nil {
+		return
+	}
+	if ok, err := p.accept("@"); err != nil {
+		return err
+	} else if ok {
+		p.pkg.Version = p.version()
+	}
+	_, err = p.expect(";")
+	return
+}
+
+// parseInterface parses the body of an interface, from "{" to "}".
+func (p *parser) parseInterface(i *Interface) error {
+	if _, err := p.expect("{"); err != nil {
+		return err
+	}
+	for {
+		t, err := p.next()
+		if err != nil {
+			return err
+		}
+		switch {
+		case t.text == "}":
+			return nil
+		case isTypeKeyword(t.text):
+			typ, err := p.parseTypeDef(t)
+			if err != nil {
+				return err
+			}
+			i.Types = append(i.Types, typ)
+		case t.ident && !isKeyword(t.text):
+			if _, err = p.expect(":"); err != nil {
+				return err
+			}
+			if _, err = p.expect("func"); err != nil {
+				return err
+			}
+			fn, err := p.parseFunction(t.text)
+			if err != nil {
+				return err
+			}
+			i.Functions = append(i.Functions, fn)
+		case t.text == "use" || t.text == "resource":
+			return p.errorf(t, "%s isn't supported", t.text)
+		default:
+			return p.errorf(t, "expected a type or function, but was %s", describe(t))
+		}
+	}
+}
+
+func (p *parser) parseWorld() (*World, error) {
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	w := &World{Name: name}
+	if _, err = p.expect("{"); err != nil {
+		return nil, err
+	}
+	for {
+		t, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case t.text == "}":
+			return w, nil
+		case isTypeKeyword(t.text):
+			typ, err := p.parseTypeDef(t)
+			if err != nil {
+				return nil, err
+			}
+			w.Types = append(w.Types, typ)
+		case t.text == "import" || t.text == "export":
+			if err = p.parseWorldItem(w, t.text == "import"); err != nil {
+				return nil, err
+			}
+		case t.text == "use" || t.text == "include" || t.text == "resource":
+			return nil, p.errorf(t, "%s isn't supported", t.text)
+		default:
+			return nil, p.errorf(t, "expected import, export or a type, but was %s", describe(t))
+		}
+	}
+}
+
+// parseWorldItem parses an import or export of a world, after the keyword.
+func (p *parser) parseWorldItem(w *World, isImport bool) error {
+	t, err := p.next()
+	if err != nil {
+		return err
+	}
+	if !t.ident {
+		return p.errorf(t, "expected an identifier, but was %s", describe(t))
+	}
+
+	var i *Interface
+	if ok, err := p.accept(":"); err != nil {
+		return err
+	} else if !ok {
+		// A reference to an interface of the package.
+		i = &Interface{Name: t.text}
+		if _, err = p.expect(";"); err != nil {
+			return err
+		}
+	} else if ok, err = p.accept("func"); err != nil {
+		return err
+	} else if ok {
+		fn, err := p.parseFunction(t.text)
+		if err != nil {
+			return err
+		}
+		if isImport {
+			w.ImportFunctions = append(w.ImportFunctions, fn)
+		} else {
+			w.ExportFunctions = append(w.ExportFunctions, fn)
+		}
+		return nil
+	} else if ok, err = p.accept("interface"); err != nil {
+		return err
+	} else if ok {
+		i = &Interface{Name: t.text, inline: true}
+		if err = p.parseInterface(i); err != nil {
+			return err
+		}
+	} else {
+		// e.g. "import wasi:cli/stdout;", which is in another package.
+		return p.errorf(t, "interfaces of other packages aren't supported")
+	}
+
+	if isImport {
+		w.Imports = append(w.Imports, i)
+	} else {
+		w.Exports = append(w.Exports, i)
+	}
+	return nil
+}
+
+// parseFunction parses a function, after "func".
+func (p *parser) parseFunction(name string) (*Function, error) {
+	fn := &Function{Name: name}
+	if _, err := p.expect("("); err != nil {
+		return nil, err
+	}
+	for {
+		if ok, err := p.accept(")"); err != nil {
+			return nil, err
+		} else if ok {
+			break
+		}
+		param, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		if _, err = p.expect(":"); err != nil {
+			return nil, err
+		}
+		typ, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		fn.Params = append(fn.Params, Field{Name: param, Type: typ})
+		if err = p.listSeparator(")"); err != nil {
+			return nil, err
+		}
+	}
+	if ok, err := p.accept("->"); err != nil {
+		return nil, err
+	} else if ok {
+		if fn.Result, err = p.parseType(); err != nil {
+			return nil, err
+		}
+	}
+	_, err := p.expect(";")
+	return fn, err
+}
+
+// listSeparator reads a "," unless the next token ends the list.
+func (p *parser) listSeparator(end string) error {
+	t, err := p.peek()
+	if err != nil || t.text == end {
+		return err
+	}
+	_, err = p.expect(",")
+	return err
+}
+
+// parseTypeDef parses a named type, after its keyword.
+func (p *parser) parseTypeDef(keyword token) (*Type, error) {
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	if keyword.text == "type" {
+		if _, err = p.expect("="); err != nil {
+			return nil, err
+		}
+		elem, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		_, err = p.expect(";")
+		return &Type{Kind: KindAlias, Name: name, Elem: elem}, err
+	}
+
+	t := &Type{Name: name}
+	switch keyword.text {
+	case "record":
+		t.Kind = KindRecord
+	case "variant":
+		t.Kind = KindVariant
+	case "enum":
+		t.Kind = KindEnum
+	case "flags":
+		t.Kind = KindFlags
+	}
+	if _, err = p.expect("{"); err != nil {
+		return nil, err
+	}
+	for {
+		if ok, err := p.accept("}"); err != nil {
+			return nil, err
+		} else if ok {
+			break
+		}
+		field := Field{}
+		if field.Name, err = p.ident(); err != nil {
+			return nil, err
+		}
+		switch t.Kind {
+		case KindRecord:
+			if _, err = p.expect(":"); err != nil {
+				return nil, err
+			}
+			if field.Type, err = p.parseType(); err != nil {
+				return nil, err
+			}
+		case KindVariant:
+			if ok, err := p.accept("("); err != nil {
+				return nil, err
+			} else if ok {
+				if field.Type, err = p.parseType(); err != nil {
+					return nil, err
+				}
+				if _, err = p.expect(")"); err != nil {
+					return nil, err
+				}
+			}
+		}
+		t.Fields = append(t.Fields, field)
+		if err = p.listSeparator("}"); err != nil {
+			return nil, err
+		}
+	}
+	if len(t.Fields) == 0 {
+		return nil, p.errorf(keyword, "%s %s has no cases", keyword.text, name)
+	}
+	if t.Kind == KindFlags && len(t.Fields) > 32 {
+		return nil, p.errorf(keyword, "flags %s has more than 32 flags, which isn't supported", name)
+	}
+	return t, nil
+}
+
+// parseType parses a type. Named types are references, which are resolved
+// after parsing.
+func (p *parser) parseType() (*Type, error) {
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if !t.ident {
+		return nil, p.errorf(t, "expected a type, but was %s", describe(t))
+	}
+	for i, name := range primitives {
+		if t.text == name {
+			return &Type{Kind: Kind(i)}, nil
+		}
+	}
+
+	switch t.text {
+	case "list", "option":
+		if _, err = p.expect("<"); err != nil {
+			return nil, err
+		}
+		ret := &Type{Kind: KindList}
+		if t.text == "option" {
+			ret.Kind = KindOption
+		}
+		if ret.Elem, err = p.parseType(); err != nil {
+			return nil, err
+		}
+		_, err = p.expect(">")
+		return ret, err
+	case "result":
+		ret := &Type{Kind: KindResult}
+		if ok, err := p.accept("<"); err != nil || !ok {
+			return ret, err
+		}
+		if ok, err := p.accept("_"); err != nil {
+			return nil, err
+		} else if !ok {
+			if ret.Ok, err = p.parseType(); err != nil {
+				return nil, err
+			}
+		}
+		if ok, err := p.accept(","); err != nil {
+			return nil, err
+		} else if ok {
+			if ret.Err, err = p.parseType(); err != nil {
+				return nil, err
+			}
+		}
+		_, err = p.expect(">")
+		return ret, err
+	case "tuple":
+		if _, err = p.expect("<"); err != nil {
+			return nil, err
+		}
+		ret := &Type{Kind: KindTuple}
+		for {
+			if ok, err := p.accept(">"); err != nil {
+				return nil, err
+			} else if ok {
+				return ret, nil
+			}
+			elem, err := p.parseType()
+			if err != nil {
+				return nil, err
+			}
+			ret.Fields = append(ret.Fields, Field{Type: elem})
+			if err = p.listSeparator(">"); err != nil {
+				return nil, err
+			}
+		}
+	case "borrow", "own", "future", "stream":
+		return nil, p.errorf(t, "%s isn't supported", t.text)
+	}
+	if isKeyword(t.text) {
+		return nil, p.errorf(t, "expected a type, but was %s", describe(t))
+	}
+	return &Type{Kind: kindRef, Name: t.text}, nil
+}
+
+// kindRef is the kind of a reference to a named type, before it is resolved.
+const kindRef Kind = -1
+
+func isTypeKeyword(s string) bool {
+	switch s {
+	case "type", "record", "variant", "enum", "flags":
+		return true
+	}
+	return false
+}
+
+func isKeyword(s string) bool {
+	switch s {
+	case "package", "interface", "world", "import", "export", "use", "include", "func", "resource",
+		"type", "record", "variant", "enum", "flags", "static", "constructor":
+		return true
+	}
+	return false
+}
+
+// resolve resolves references to named types and interfaces.
+func resolve(pkg *Package) error {
+	interfaces := map[string]*Interface{}
+	for _, i := range pkg.Interfaces {
+		if interfaces[i.Name] != nil {
+			return fmt.Errorf("duplicate interface %s", i.Name)
+		}
+		interfaces[i.Name] = i
+		if err := resolveScope(i.Types, i.Functions, nil); err != nil {
+			return fmt.Errorf("interface %s: %w", i.Name, err)
+		}
+	}
+
+	for _, w := range pkg.Worlds {
+		if err := resolveScope(w.Types, append(w.ImportFunctions, w.ExportFunctions...), nil); err != nil {
+			return fmt.Errorf("world %s: %w", w.Name, err)
+		}
+		for _, list := range [][]*Interface{w.Imports, w.Exports} {
+			for j, i := range list {
+				if i.inline {
+					// Inline interfaces can use the types of the world.
+					if err := resolveScope(i.Types, i.Functions, w.Types); err != nil {
+						return fmt.Errorf("world %s: interface %s: %w", w.Name, i.Name, err)
+					}
+				} else if list[j] = interfaces[i.Name]; list[j] == nil {
+					return fmt.Errorf("world %s: interface %s isn't defined", w.Name, i.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// resolveScope resolves the references to named types in the scope of types
+// and outer.
+func resolveScope(types []*Type, functions []*Function, outer []*Type) error {
+	named := map[string]*Type{}
+	for _, t := range outer {
+		named[t.Name] = t
+	}
+	for _, t := range types {
+		if named[t.Name] != nil && !contains(outer, named[t.Name]) {
+			return fmt.Errorf("duplicate type %s", t.Name)
+		}
+		named[t.Name] = t
+	}
+
+	r := &resolver{named: named, resolving: map[*Type]bool{}}
+	for _, t := range types {
+		if name := duplicate(t.Fields); name != "" {
+			return fmt.Errorf("type %s: duplicate name %s", t.Name, name)
+		}
+		if err := r.resolve(t); err != nil {
+			return err
+		}
+	}
+	names := map[string]bool{}
+	for _, fn := range functions {
+		if names[fn.Name] {
+			return fmt.Errorf("duplicate function %s", fn.Name)
+		}
+		names[fn.Name] = true
+		if name := duplicate(fn.Params); name != "" {
+			return fmt.Errorf("function %s: duplicate parameter %s", fn.Name, name)
+		}
+		for _, param := range fn.Params {
+			if err := r.resolve(param.Type); err != nil {
+				return fmt.Errorf("function %s: %w", fn.Name, err)
+			}
+		}
+		if fn.Result != nil {
+			if err := r.resolve(fn.Result); err != nil {
+				return fmt.Errorf("function %s: %w", fn.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// duplicate returns the first name of a field which isn't unique, or empty if
+// all are.
+func duplicate(fields []Field) string {
+	names := map[string]bool{}
+	for _, f := range fields {
+		if names[f.Name] {
+			return f.Name
+		}
+		names[f.Name] = true
+	}
+	return ""
+}
+
+func contains(types []*Type, t *Type) bool {
+	for _, u := range types {
+		if u == t {
+			return true
+		}
+	}
+	return false
+}
+
+type resolver struct {
+	named map[string]*Type
+	// resolving are the named types being resolved, to detect recursion.
+	resolving map[*Type]bool
+}
+
+// resolve replaces references in t, and t itself if a reference, with the
+// named types.
+func (r *resolver) resolve(t *Type) error {
+	if t == nil {
+		return nil
+	}
+	if t.Kind == kindRef {
+		named := r.named[t.Name]
+		if named == nil {
+			return fmt.Errorf("type %s isn't defined", t.Name)
+		}
+		if err := r.resolve(named); err != nil {
+			return err
+		}
+		*t = *named
+		return nil
+	}
+
+	if t.Name != "" {
+		if r.resolving[t] {
+			return fmt.Errorf("type %s is recursive", t.Name)
+		}
+		r.resolving[t] = true
+		defer delete(r.resolving, t)
+	}
+	for _, elem := range []*Type{t.Elem, t.Ok, t.Err} {
+		if err := r.resolve(elem); err != nil {
+			return err
+		}
+	}
+	for _, f := range t.Fields {
+		if err := r.resolve(f.Type); err != nil {
+			return err
+		}
+	}
+	return nil
+}
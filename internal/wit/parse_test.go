@@ -0,0 +1,172 @@
+package wit
+
+import (
+	"testing"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+)
+
+func TestParse(t *testing.T) {
+	pkg, err := Parse(`
+// A package with an interface and a world.
+package example:greeter@0.2.0-rc.1;
+
+interface types {
+  /* A point. */
+  record point { x: s32, y: s32 }
+  type points = list<point>;
+  enum level { debug, info }
+  log: func(level: level, %type: string);
+  closest: func(p: points) -> option<point>;
+}
+
+world greeter {
+  import types;
+  import clock: interface {
+    now: func() -> u64;
+  }
+  export greet: func(name: string) -> result<string>;
+}
+`)
+	require.NoError(t, err)
+
+	point := &Type{Kind: KindRecord, Name: "point", Fields: []Field{
+		{Name: "x", Type: &Type{Kind: KindS32}},
+		{Name: "y", Type: &Type{Kind: KindS32}},
+	}}
+	points := &Type{Kind: KindAlias, Name: "points", Elem: &Type{Kind: KindList, Elem: point}}
+	level := &Type{Kind: KindEnum, Name: "level", Fields: []Field{{Name: "debug"}, {Name: "info"}}}
+	types := &Interface{
+		Name:  "types",
+		Types: []*Type{point, points, level},
+		Functions: []*Function{
+			{Name: "log", Params: []Field{{Name: "level", Type: level}, {Name: "type", Type: &Type{Kind: KindString}}}},
+			{Name: "closest", Params: []Field{{Name: "p", Type: points}}, Result: &Type{Kind: KindOption, Elem: point}},
+		},
+	}
+	clock := &Interface{
+		Name:      "clock",
+		Functions: []*Function{{Name: "now", Result: &Type{Kind: KindU64}}},
+		inline:    true,
+	}
+	require.Equal(t, &Package{
+		Namespace:  "example",
+		Name:       "greeter",
+		Version:    "0.2.0-rc.1",
+		Interfaces: []*Interface{types},
+		Worlds: []*World{{
+			Name:    "greeter",
+			Imports: []*Interface{types, clock},
+			ExportFunctions: []*Function{{
+				Name:   "greet",
+				Params: []Field{{Name: "name", Type: &Type{Kind: KindString}}},
+				Result: &Type{Kind: KindResult, Ok: &Type{Kind: KindString}},
+			}},
+		}},
+	}, pkg)
+
+	require.Equal(t, "example:greeter/types@0.2.0-rc.1", pkg.qualify(types))
+	require.Equal(t, "clock", pkg.qualify(clock))
+	require.Equal(t, pkg.Worlds[0], pkg.World("greeter"))
+	require.Nil(t, pkg.World("missing"))
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		name, source, expectedErr string
+	}{
+		{
+			name:        "missing type",
+			source:      "interface i {\n  f: func(a: u32) -> ;\n}",
+			expectedErr: `line 2: expected a type, but was ";"`,
+		},
+		{
+			name:        "unexpected character",
+			source:      "interface i { f: func() -> #; }",
+			expectedErr: "line 1: unexpected character '#'",
+		},
+		{
+			name:        "invalid identifier",
+			source:      "interface -bad {}",
+			expectedErr: `line 1: invalid identifier "-bad"`,
+		},
+		{
+			name:        "unterminated comment",
+			source:      "/* unterminated",
+			expectedErr: "line 1: unterminated comment",
+		},
+		{
+			name:        "resource",
+			source:      "interface i { resource r; }",
+			expectedErr: "line 1: resource isn't supported",
+		},
+		{
+			name:        "use",
+			source:      "interface i { use other.{t}; }",
+			expectedErr: "line 1: use isn't supported",
+		},
+		{
+			name:        "borrow",
+			source:      "interface i { f: func(x: borrow<r>); }",
+			expectedErr: "line 1: borrow isn't supported",
+		},
+		{
+			name:        "interface of other package",
+			source:      "world w { import other:pkg/iface; }",
+			expectedErr: "line 1: interfaces of other packages aren't supported",
+		},
+		{
+			name:        "no cases",
+			source:      "interface i { variant v {} }",
+			expectedErr: "line 1: variant v has no cases",
+		},
+		{
+			name:        "undefined type",
+			source:      "interface i { type t = u; }",
+			expectedErr: "interface i: type u isn't defined",
+		},
+		{
+			name:        "recursive type",
+			source:      "interface i { type t = list<t>; }",
+			expectedErr: "interface i: type t is recursive",
+		},
+		{
+			name:        "undefined interface",
+			source:      "world w { import missing; }",
+			expectedErr: "world w: interface missing isn't defined",
+		},
+		{
+			name:        "duplicate interface",
+			source:      "interface i {} interface i {}",
+			expectedErr: "duplicate interface i",
+		},
+		{
+			name:        "duplicate type",
+			source:      "interface i { record r { a: u32 } enum r { x } }",
+			expectedErr: "interface i: duplicate type r",
+		},
+		{
+			name:        "duplicate field",
+			source:      "interface i { record r { a: u32, a: u32 } }",
+			expectedErr: "interface i: type r: duplicate name a",
+		},
+		{
+			name:        "duplicate function",
+			source:      "world w { import f: func(); export f: func(); }",
+			expectedErr: "world w: duplicate function f",
+		},
+		{
+			name:        "duplicate parameter",
+			source:      "interface i { f: func(a: u32, a: u32); }",
+			expectedErr: "interface i: function f: duplicate parameter a",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse(tc.source)
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,132 @@
+// Package wit parses WIT, the interface definition language of the component
+// model, and generates Go bindings for it which use the canonical ABI.
+//
+// Only what is needed to bind core modules is supported: interfaces and
+// worlds of one package, and types without resources. See
+// https://github.com/WebAssembly/component-model/blob/main/design/mvp/WIT.md
+package wit
+
+// Kind is the kind of a Type.
+type Kind int
+
+const (
+	KindBool Kind = iota
+	KindS8
+	KindU8
+	KindS16
+	KindU16
+	KindS32
+	KindU32
+	KindS64
+	KindU64
+	KindF32
+	KindF64
+	KindChar
+	KindString
+	// KindList is a list<Elem>.
+	KindList
+	// KindOption is an option<Elem>.
+	KindOption
+	// KindResult is a result<Ok, Err>, where either may be nil.
+	KindResult
+	// KindTuple is a tuple of the types of Fields, which have no names.
+	KindTuple
+	// KindRecord is a record of Fields.
+	KindRecord
+	// KindVariant is a variant of the cases in Fields, whose types may be nil.
+	KindVariant
+	// KindEnum is an enum of the names of Fields.
+	KindEnum
+	// KindFlags is flags with the names of Fields.
+	KindFlags
+	// KindAlias is a type named Name for Elem.
+	KindAlias
+)
+
+// primitives are the names of the types of kinds before KindList.
+var primitives = []string{"bool", "s8", "u8", "s16", "u16", "s32", "u32", "s64", "u64", "f32", "f64", "char", "string"}
+
+// Type is a type of a value.
+type Type struct {
+	Kind Kind
+	// Name is the name of a record, variant, enum, flags or alias, or empty
+	// for other kinds.
+	Name string
+	// Elem is the element of a list or option, or the type of an alias.
+	Elem *Type
+	// Ok and Err are the types of the cases of a result, or nil if absent.
+	Ok, Err *Type
+	// Fields are the fields of a record or tuple, the cases of a variant,
+	// or the names of an enum or flags.
+	Fields []Field
+}
+
+// Field is a field of a record or tuple, or a case of a variant, enum or
+// flags.
+type Field struct {
+	Name string
+	// Type is the type of the field, or nil for cases without one.
+	Type *Type
+}
+
+// Function is a function of an interface or world.
+type Function struct {
+	Name   string
+	Params []Field
+	// Result is the type of the result, or nil if none.
+	Result *Type
+}
+
+// Interface is a named group of types and functions.
+type Interface struct {
+	Name string
+	// Types are the named types, in the order they were defined.
+	Types     []*Type
+	Functions []*Function
+	// inline is true for interfaces defined in a world, which aren't
+	// qualified by the package.
+	inline bool
+}
+
+// World describes what a component imports and exports.
+type World struct {
+	Name string
+	// Types are the named types defined in the world.
+	Types            []*Type
+	Imports, Exports []*Interface
+	// ImportFunctions and ExportFunctions are those of the world itself,
+	// rather than of an interface.
+	ImportFunctions, ExportFunctions []*Function
+}
+
+// Package is the content of a WIT file.
+type Package struct {
+	// Namespace, Name and Version are those of the package declaration, or
+	// empty if none.
+	Namespace, Name, Version string
+	Interfaces               []*Interface
+	Worlds                   []*World
+}
+
+// World returns the world with the name, or nil if there's none.
+func (p *Package) World(name string) *World {
+	for _, w := range p.Worlds {
+		if w.Name == name {
+			return w
+		}
+	}
+	return nil
+}
+
+// qualify returns the name of the interface in the names of core imports
+// and exports, e.g. "wasi:cli/stdout@0.2.0".
+func (p *Package) qualify(i *Interface) string {
+	if i.inline || p.Namespace == "" {
+		return i.Name
+	}
+	name := p.Namespace + ":" + p.Name + "/" + i.Name
+	if p.Version != "" {
+		name += "@" + p.Version
+	}
+	return name
+}
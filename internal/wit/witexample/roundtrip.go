@@ -0,0 +1,1084 @@
+// Code generated by wazero wit-bindgen. DO NOT EDIT.
+
+package witexample
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// Point is the record point.
+type Point struct {
+	X float32
+	Y float32
+}
+
+// Shape is the variant shape. Tag is its case, whose value, if any, is in the
+// field of the case.
+type Shape struct {
+	Tag       ShapeTag
+	Circle    float32
+	Rectangle Point
+}
+
+// ShapeTag is a case of Shape.
+type ShapeTag uint8
+
+const (
+	ShapeCircle ShapeTag = iota
+	ShapeRectangle
+	ShapeEmpty
+)
+
+// Color is the enum color.
+type Color uint8
+
+const (
+	ColorRed Color = iota
+	ColorGreen
+	ColorBlue
+)
+
+// Permissions is the flags permissions.
+type Permissions uint8
+
+const (
+	PermissionsRead Permissions = 1 << iota
+	PermissionsWrite
+	PermissionsExec
+)
+
+// Points is the type points.
+type Points = []Point
+
+// ResultU32String is a result, which is an error if IsErr is true.
+type ResultU32String struct {
+	IsErr bool
+	Ok    uint32
+	Err   string
+}
+
+// TupleBoolCharU8 is a tuple.
+type TupleBoolCharU8 struct {
+	F0 bool
+	F1 rune
+	F2 uint8
+}
+
+// Types is implemented by the host to provide the functions of the interface "types"
+// to guests, with InstantiateTypes. Returning an error traps the guest.
+type Types interface {
+	Add(ctx context.Context, a int32, b int64) (int64, error)
+	Greet(ctx context.Context, name string) (string, error)
+	EchoPoint(ctx context.Context, p Point) (Point, error)
+	EchoShape(ctx context.Context, s Shape) (Shape, error)
+	EchoStrings(ctx context.Context, l []string) ([]string, error)
+	EchoOption(ctx context.Context, o *Color) (*Color, error)
+	EchoResult(ctx context.Context, r_ ResultU32String) (ResultU32String, error)
+	EchoPermissions(ctx context.Context, p Permissions) (Permissions, error)
+	EchoTuple(ctx context.Context, t TupleBoolCharU8) (TupleBoolCharU8, error)
+	EchoBytes(ctx context.Context, b []uint8) ([]uint8, error)
+	EchoPoints(ctx context.Context, p Points) (Points, error)
+	Sum(ctx context.Context, a uint32, b uint32, c_ uint32, d uint32, e uint32, f uint32, g uint32, h uint32, i uint32, j uint32, k uint32, l uint32, m uint32, n uint32, o uint32, p uint32, q uint32) (uint32, error)
+	Nothing(ctx context.Context) error
+}
+
+// InstantiateTypes instantiates the host module "example:roundtrip/types@0.1.0", which
+// guests import the interface "types" from, implemented by impl.
+func InstantiateTypes(ctx context.Context, r wazero.Runtime, impl Types) (api.Closer, error) {
+	b := r.NewHostModuleBuilder("example:roundtrip/types@0.1.0")
+	b.NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			p0 := int32(stack[0])
+			p1 := int64(stack[1])
+			r, err := impl.Add(ctx, p0, p1)
+			if err != nil {
+				panic(err)
+			}
+			stack[0] = uint64(r)
+		}), []api.ValueType{api.ValueTypeI32, api.ValueTypeI64}, []api.ValueType{api.ValueTypeI64}).
+		WithParameterNames("a", "b").
+		Export("add")
+	b.NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			c := &canon{ctx: ctx, mod: mod}
+			p0 := c.liftString(stack[0:2])
+			r, err := impl.Greet(ctx, p0)
+			if err != nil {
+				panic(err)
+			}
+			c.storeString(uint32(stack[2]), r)
+		}), []api.ValueType{api.ValueTypeI32, api.ValueTypeI32, api.ValueTypeI32}, nil).
+		WithParameterNames("name0", "name1", "result").
+		Export("greet")
+	b.NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			c := &canon{ctx: ctx, mod: mod}
+			p0 := c.liftPoint(stack[0:2])
+			r, err := impl.EchoPoint(ctx, p0)
+			if err != nil {
+				panic(err)
+			}
+			c.storePoint(uint32(stack[2]), r)
+		}), []api.ValueType{api.ValueTypeF32, api.ValueTypeF32, api.ValueTypeI32}, nil).
+		WithParameterNames("p0", "p1", "result").
+		Export("echo-point")
+	b.NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			c := &canon{ctx: ctx, mod: mod}
+			p0 := c.liftShape(stack[0:3])
+			r, err := impl.EchoShape(ctx, p0)
+			if err != nil {
+				panic(err)
+			}
+			c.storeShape(uint32(stack[3]), r)
+		}), []api.ValueType{api.ValueTypeI32, api.ValueTypeF32, api.ValueTypeF32, api.ValueTypeI32}, nil).
+		WithParameterNames("s0", "s1", "s2", "result").
+		Export("echo-shape")
+	b.NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			c := &canon{ctx: ctx, mod: mod}
+			p0 := c.liftListString(stack[0:2])
+			r, err := impl.EchoStrings(ctx, p0)
+			if err != nil {
+				panic(err)
+			}
+			c.storeListString(uint32(stack[2]), r)
+		}), []api.ValueType{api.ValueTypeI32, api.ValueTypeI32, api.ValueTypeI32}, nil).
+		WithParameterNames("l0", "l1", "result").
+		Export("echo-strings")
+	b.NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			c := &canon{ctx: ctx, mod: mod}
+			p0 := c.liftOptionColor(stack[0:2])
+			r, err := impl.EchoOption(ctx, p0)
+			if err != nil {
+				panic(err)
+			}
+			c.storeOptionColor(uint32(stack[2]), r)
+		}), []api.ValueType{api.ValueTypeI32, api.ValueTypeI32, api.ValueTypeI32}, nil).
+		WithParameterNames("o0", "o1", "result").
+		Export("echo-option")
+	b.NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			c := &canon{ctx: ctx, mod: mod}
+			p0 := c.liftResultU32String(stack[0:3])
+			r, err := impl.EchoResult(ctx, p0)
+			if err != nil {
+				panic(err)
+			}
+			c.storeResultU32String(uint32(stack[3]), r)
+		}), []api.ValueType{api.ValueTypeI32, api.ValueTypeI32, api.ValueTypeI32, api.ValueTypeI32}, nil).
+		WithParameterNames("r0", "r1", "r2", "result").
+		Export("echo-result")
+	b.NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			c := &canon{ctx: ctx, mod: mod}
+			p0 := c.liftPermissions(stack[0:1])
+			r, err := impl.EchoPermissions(ctx, p0)
+			if err != nil {
+				panic(err)
+			}
+			c.lowerPermissions(r, stack[0:1])
+		}), []api.ValueType{api.ValueTypeI32}, []api.ValueType{api.ValueTypeI32}).
+		WithParameterNames("p").
+		Export("echo-permissions")
+	b.NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			c := &canon{ctx: ctx, mod: mod}
+			p0 := c.liftTupleBoolCharU8(stack[0:3])
+			r, err := impl.EchoTuple(ctx, p0)
+			if err != nil {
+				panic(err)
+			}
+			c.storeTupleBoolCharU8(uint32(stack[3]), r)
+		}), []api.ValueType{api.ValueTypeI32, api.ValueTypeI32, api.ValueTypeI32, api.ValueTypeI32}, nil).
+		WithParameterNames("t0", "t1", "t2", "result").
+		Export("echo-tuple")
+	b.NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			c := &canon{ctx: ctx, mod: mod}
+			p0 := c.liftListU8(stack[0:2])
+			r, err := impl.EchoBytes(ctx, p0)
+			if err != nil {
+				panic(err)
+			}
+			c.storeListU8(uint32(stack[2]), r)
+		}), []api.ValueType{api.ValueTypeI32, api.ValueTypeI32, api.ValueTypeI32}, nil).
+		WithParameterNames("b0", "b1", "result").
+		Export("echo-bytes")
+	b.NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			c := &canon{ctx: ctx, mod: mod}
+			p0 := c.liftListPoint(stack[0:2])
+			r, err := impl.EchoPoints(ctx, p0)
+			if err != nil {
+				panic(err)
+			}
+			c.storeListPoint(uint32(stack[2]), r)
+		}), []api.ValueType{api.ValueTypeI32, api.ValueTypeI32, api.ValueTypeI32}, nil).
+		WithParameterNames("p0", "p1", "result").
+		Export("echo-points")
+	b.NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			c := &canon{ctx: ctx, mod: mod}
+			ptr := uint32(stack[0])
+			p0 := c.loadU32(ptr)
+			p1 := c.loadU32(ptr + 4)
+			p2 := c.loadU32(ptr + 8)
+			p3 := c.loadU32(ptr + 12)
+			p4 := c.loadU32(ptr + 16)
+			p5 := c.loadU32(ptr + 20)
+			p6 := c.loadU32(ptr + 24)
+			p7 := c.loadU32(ptr + 28)
+			p8 := c.loadU32(ptr + 32)
+			p9 := c.loadU32(ptr + 36)
+			p10 := c.loadU32(ptr + 40)
+			p11 := c.loadU32(ptr + 44)
+			p12 := c.loadU32(ptr + 48)
+			p13 := c.loadU32(ptr + 52)
+			p14 := c.loadU32(ptr + 56)
+			p15 := c.loadU32(ptr + 60)
+			p16 := c.loadU32(ptr + 64)
+			r, err := impl.Sum(ctx, p0, p1, p2, p3, p4, p5, p6, p7, p8, p9, p10, p11, p12, p13, p14, p15, p16)
+			if err != nil {
+				panic(err)
+			}
+			stack[0] = uint64(r)
+		}), []api.ValueType{api.ValueTypeI32}, []api.ValueType{api.ValueTypeI32}).
+		WithParameterNames("params").
+		Export("sum")
+	b.NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			if err := impl.Nothing(ctx); err != nil {
+				panic(err)
+			}
+		}), nil, nil).
+		Export("nothing")
+	return b.Instantiate(ctx, r)
+}
+
+// RoundtripImports is implemented by the host to provide the functions of the world "roundtrip"
+// to guests, with InstantiateRoundtripImports. Returning an error traps the guest.
+type RoundtripImports interface {
+	Now(ctx context.Context) (uint64, error)
+}
+
+// InstantiateRoundtripImports instantiates the host module "$root", which
+// guests import the world "roundtrip" from, implemented by impl.
+func InstantiateRoundtripImports(ctx context.Context, r wazero.Runtime, impl RoundtripImports) (api.Closer, error) {
+	b := r.NewHostModuleBuilder("$root")
+	b.NewFunctionBuilder().
+		WithGoModuleFunction(api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+			r, err := impl.Now(ctx)
+			if err != nil {
+				panic(err)
+			}
+			stack[0] = r
+		}), nil, []api.ValueType{api.ValueTypeI64}).
+		Export("now")
+	return b.Instantiate(ctx, r)
+}
+
+// Roundtrip calls the functions an instance of a guest exports for the world "roundtrip".
+type Roundtrip struct {
+	mod api.Module
+	// Each export fX has a post-return function postX, or nil if none.
+	fRun, postRun                                   api.Function
+	fTypesAdd, postTypesAdd                         api.Function
+	fTypesGreet, postTypesGreet                     api.Function
+	fTypesEchoPoint, postTypesEchoPoint             api.Function
+	fTypesEchoShape, postTypesEchoShape             api.Function
+	fTypesEchoStrings, postTypesEchoStrings         api.Function
+	fTypesEchoOption, postTypesEchoOption           api.Function
+	fTypesEchoResult, postTypesEchoResult           api.Function
+	fTypesEchoPermissions, postTypesEchoPermissions api.Function
+	fTypesEchoTuple, postTypesEchoTuple             api.Function
+	fTypesEchoBytes, postTypesEchoBytes             api.Function
+	fTypesEchoPoints, postTypesEchoPoints           api.Function
+	fTypesSum, postTypesSum                         api.Function
+	fTypesNothing, postTypesNothing                 api.Function
+}
+
+// NewRoundtrip returns the exports of mod, which must be an instance of a guest of
+// the world "roundtrip".
+func NewRoundtrip(mod api.Module) (*Roundtrip, error) {
+	w := &Roundtrip{mod: mod}
+	if w.fRun = mod.ExportedFunction("run"); w.fRun == nil {
+		return nil, fmt.Errorf("module[%s] doesn't export function[%s]", mod.Name(), "run")
+	}
+	w.postRun = mod.ExportedFunction("cabi_post_run")
+	if w.fTypesAdd = mod.ExportedFunction("example:roundtrip/types@0.1.0#add"); w.fTypesAdd == nil {
+		return nil, fmt.Errorf("module[%s] doesn't export function[%s]", mod.Name(), "example:roundtrip/types@0.1.0#add")
+	}
+	w.postTypesAdd = mod.ExportedFunction("cabi_post_example:roundtrip/types@0.1.0#add")
+	if w.fTypesGreet = mod.ExportedFunction("example:roundtrip/types@0.1.0#greet"); w.fTypesGreet == nil {
+		return nil, fmt.Errorf("module[%s] doesn't export function[%s]", mod.Name(), "example:roundtrip/types@0.1.0#greet")
+	}
+	w.postTypesGreet = mod.ExportedFunction("cabi_post_example:roundtrip/types@0.1.0#greet")
+	if w.fTypesEchoPoint = mod.ExportedFunction("example:roundtrip/types@0.1.0#echo-point"); w.fTypesEchoPoint == nil {
+		return nil, fmt.Errorf("module[%s] doesn't export function[%s]", mod.Name(), "example:roundtrip/types@0.1.0#echo-point")
+	}
+	w.postTypesEchoPoint = mod.ExportedFunction("cabi_post_example:roundtrip/types@0.1.0#echo-point")
+	if w.fTypesEchoShape = mod.ExportedFunction("example:roundtrip/types@0.1.0#echo-shape"); w.fTypesEchoShape == nil {
+		return nil, fmt.Errorf("module[%s] doesn't export function[%s]", mod.Name(), "example:roundtrip/types@0.1.0#echo-shape")
+	}
+	w.postTypesEchoShape = mod.ExportedFunction("cabi_post_example:roundtrip/types@0.1.0#echo-shape")
+	if w.fTypesEchoStrings = mod.ExportedFunction("example:roundtrip/types@0.1.0#echo-strings"); w.fTypesEchoStrings == nil {
+		return nil, fmt.Errorf("module[%s] doesn't export function[%s]", mod.Name(), "example:roundtrip/types@0.1.0#echo-strings")
+	}
+	w.postTypesEchoStrings = mod.ExportedFunction("cabi_post_example:roundtrip/types@0.1.0#echo-strings")
+	if w.fTypesEchoOption = mod.ExportedFunction("example:roundtrip/types@0.1.0#echo-option"); w.fTypesEchoOption == nil {
+		return nil, fmt.Errorf("module[%s] doesn't export function[%s]", mod.Name(), "example:roundtrip/types@0.1.0#echo-option")
+	}
+	w.postTypesEchoOption = mod.ExportedFunction("cabi_post_example:roundtrip/types@0.1.0#echo-option")
+	if w.fTypesEchoResult = mod.ExportedFunction("example:roundtrip/types@0.1.0#echo-result"); w.fTypesEchoResult == nil {
+		return nil, fmt.Errorf("module[%s] doesn't export function[%s]", mod.Name(), "example:roundtrip/types@0.1.0#echo-result")
+	}
+	w.postTypesEchoResult = mod.ExportedFunction("cabi_post_example:roundtrip/types@0.1.0#echo-result")
+	if w.fTypesEchoPermissions = mod.ExportedFunction("example:roundtrip/types@0.1.0#echo-permissions"); w.fTypesEchoPermissions == nil {
+		return nil, fmt.Errorf("module[%s] doesn't export function[%s]", mod.Name(), "example:roundtrip/types@0.1.0#echo-permissions")
+	}
+	w.postTypesEchoPermissions = mod.ExportedFunction("cabi_post_example:roundtrip/types@0.1.0#echo-permissions")
+	if w.fTypesEchoTuple = mod.ExportedFunction("example:roundtrip/types@0.1.0#echo-tuple"); w.fTypesEchoTuple == nil {
+		return nil, fmt.Errorf("module[%s] doesn't export function[%s]", mod.Name(), "example:roundtrip/types@0.1.0#echo-tuple")
+	}
+	w.postTypesEchoTuple = mod.ExportedFunction("cabi_post_example:roundtrip/types@0.1.0#echo-tuple")
+	if w.fTypesEchoBytes = mod.ExportedFunction("example:roundtrip/types@0.1.0#echo-bytes"); w.fTypesEchoBytes == nil {
+		return nil, fmt.Errorf("module[%s] doesn't export function[%s]", mod.Name(), "example:roundtrip/types@0.1.0#echo-bytes")
+	}
+	w.postTypesEchoBytes = mod.ExportedFunction("cabi_post_example:roundtrip/types@0.1.0#echo-bytes")
+	if w.fTypesEchoPoints = mod.ExportedFunction("example:roundtrip/types@0.1.0#echo-points"); w.fTypesEchoPoints == nil {
+		return nil, fmt.Errorf("module[%s] doesn't export function[%s]", mod.Name(), "example:roundtrip/types@0.1.0#echo-points")
+	}
+	w.postTypesEchoPoints = mod.ExportedFunction("cabi_post_example:roundtrip/types@0.1.0#echo-points")
+	if w.fTypesSum = mod.ExportedFunction("example:roundtrip/types@0.1.0#sum"); w.fTypesSum == nil {
+		return nil, fmt.Errorf("module[%s] doesn't export function[%s]", mod.Name(), "example:roundtrip/types@0.1.0#sum")
+	}
+	w.postTypesSum = mod.ExportedFunction("cabi_post_example:roundtrip/types@0.1.0#sum")
+	if w.fTypesNothing = mod.ExportedFunction("example:roundtrip/types@0.1.0#nothing"); w.fTypesNothing == nil {
+		return nil, fmt.Errorf("module[%s] doesn't export function[%s]", mod.Name(), "example:roundtrip/types@0.1.0#nothing")
+	}
+	w.postTypesNothing = mod.ExportedFunction("cabi_post_example:roundtrip/types@0.1.0#nothing")
+	return w, nil
+}
+
+// Run calls the export "run".
+func (w *Roundtrip) Run(ctx context.Context) (r uint64, err error) {
+	c := &canon{ctx: ctx, mod: w.mod}
+	defer c.recover(&err)
+	results, err := w.fRun.Call(ctx)
+	if err != nil {
+		return
+	}
+	r = results[0]
+	if w.postRun != nil {
+		_, err = w.postRun.Call(ctx, results...)
+	}
+	return
+}
+
+// TypesAdd calls the export "example:roundtrip/types@0.1.0#add".
+func (w *Roundtrip) TypesAdd(ctx context.Context, a int32, b int64) (r int64, err error) {
+	c := &canon{ctx: ctx, mod: w.mod}
+	defer c.recover(&err)
+	params := make([]uint64, 2)
+	params[0] = uint64(uint32(a))
+	params[1] = uint64(b)
+	results, err := w.fTypesAdd.Call(ctx, params...)
+	if err != nil {
+		return
+	}
+	r = int64(results[0])
+	if w.postTypesAdd != nil {
+		_, err = w.postTypesAdd.Call(ctx, results...)
+	}
+	return
+}
+
+// TypesGreet calls the export "example:roundtrip/types@0.1.0#greet".
+func (w *Roundtrip) TypesGreet(ctx context.Context, name string) (r string, err error) {
+	c := &canon{ctx: ctx, mod: w.mod}
+	defer c.recover(&err)
+	params := make([]uint64, 2)
+	c.lowerString(name, params[0:2])
+	results, err := w.fTypesGreet.Call(ctx, params...)
+	if err != nil {
+		return
+	}
+	r = c.loadString(uint32(results[0]))
+	if w.postTypesGreet != nil {
+		_, err = w.postTypesGreet.Call(ctx, results...)
+	}
+	return
+}
+
+// TypesEchoPoint calls the export "example:roundtrip/types@0.1.0#echo-point".
+func (w *Roundtrip) TypesEchoPoint(ctx context.Context, p Point) (r Point, err error) {
+	c := &canon{ctx: ctx, mod: w.mod}
+	defer c.recover(&err)
+	params := make([]uint64, 2)
+	c.lowerPoint(p, params[0:2])
+	results, err := w.fTypesEchoPoint.Call(ctx, params...)
+	if err != nil {
+		return
+	}
+	r = c.loadPoint(uint32(results[0]))
+	if w.postTypesEchoPoint != nil {
+		_, err = w.postTypesEchoPoint.Call(ctx, results...)
+	}
+	return
+}
+
+// TypesEchoShape calls the export "example:roundtrip/types@0.1.0#echo-shape".
+func (w *Roundtrip) TypesEchoShape(ctx context.Context, s Shape) (r Shape, err error) {
+	c := &canon{ctx: ctx, mod: w.mod}
+	defer c.recover(&err)
+	params := make([]uint64, 3)
+	c.lowerShape(s, params[0:3])
+	results, err := w.fTypesEchoShape.Call(ctx, params...)
+	if err != nil {
+		return
+	}
+	r = c.loadShape(uint32(results[0]))
+	if w.postTypesEchoShape != nil {
+		_, err = w.postTypesEchoShape.Call(ctx, results...)
+	}
+	return
+}
+
+// TypesEchoStrings calls the export "example:roundtrip/types@0.1.0#echo-strings".
+func (w *Roundtrip) TypesEchoStrings(ctx context.Context, l []string) (r []string, err error) {
+	c := &canon{ctx: ctx, mod: w.mod}
+	defer c.recover(&err)
+	params := make([]uint64, 2)
+	c.lowerListString(l, params[0:2])
+	results, err := w.fTypesEchoStrings.Call(ctx, params...)
+	if err != nil {
+		return
+	}
+	r = c.loadListString(uint32(results[0]))
+	if w.postTypesEchoStrings != nil {
+		_, err = w.postTypesEchoStrings.Call(ctx, results...)
+	}
+	return
+}
+
+// TypesEchoOption calls the export "example:roundtrip/types@0.1.0#echo-option".
+func (w *Roundtrip) TypesEchoOption(ctx context.Context, o *Color) (r *Color, err error) {
+	c := &canon{ctx: ctx, mod: w.mod}
+	defer c.recover(&err)
+	params := make([]uint64, 2)
+	c.lowerOptionColor(o, params[0:2])
+	results, err := w.fTypesEchoOption.Call(ctx, params...)
+	if err != nil {
+		return
+	}
+	r = c.loadOptionColor(uint32(results[0]))
+	if w.postTypesEchoOption != nil {
+		_, err = w.postTypesEchoOption.Call(ctx, results...)
+	}
+	return
+}
+
+// TypesEchoResult calls the export "example:roundtrip/types@0.1.0#echo-result".
+func (w *Roundtrip) TypesEchoResult(ctx context.Context, r_ ResultU32String) (r ResultU32String, err error) {
+	c := &canon{ctx: ctx, mod: w.mod}
+	defer c.recover(&err)
+	params := make([]uint64, 3)
+	c.lowerResultU32String(r_, params[0:3])
+	results, err := w.fTypesEchoResult.Call(ctx, params...)
+	if err != nil {
+		return
+	}
+	r = c.loadResultU32String(uint32(results[0]))
+	if w.postTypesEchoResult != nil {
+		_, err = w.postTypesEchoResult.Call(ctx, results...)
+	}
+	return
+}
+
+// TypesEchoPermissions calls the export "example:roundtrip/types@0.1.0#echo-permissions".
+func (w *Roundtrip) TypesEchoPermissions(ctx context.Context, p Permissions) (r Permissions, err error) {
+	c := &canon{ctx: ctx, mod: w.mod}
+	defer c.recover(&err)
+	params := make([]uint64, 1)
+	c.lowerPermissions(p, params[0:1])
+	results, err := w.fTypesEchoPermissions.Call(ctx, params...)
+	if err != nil {
+		return
+	}
+	r = c.liftPermissions(results[0:1])
+	if w.postTypesEchoPermissions != nil {
+		_, err = w.postTypesEchoPermissions.Call(ctx, results...)
+	}
+	return
+}
+
+// TypesEchoTuple calls the export "example:roundtrip/types@0.1.0#echo-tuple".
+func (w *Roundtrip) TypesEchoTuple(ctx context.Context, t TupleBoolCharU8) (r TupleBoolCharU8, err error) {
+	c := &canon{ctx: ctx, mod: w.mod}
+	defer c.recover(&err)
+	params := make([]uint64, 3)
+	c.lowerTupleBoolCharU8(t, params[0:3])
+	results, err := w.fTypesEchoTuple.Call(ctx, params...)
+	if err != nil {
+		return
+	}
+	r = c.loadTupleBoolCharU8(uint32(results[0]))
+	if w.postTypesEchoTuple != nil {
+		_, err = w.postTypesEchoTuple.Call(ctx, results...)
+	}
+	return
+}
+
+// TypesEchoBytes calls the export "example:roundtrip/types@0.1.0#echo-bytes".
+func (w *Roundtrip) TypesEchoBytes(ctx context.Context, b []uint8) (r []uint8, err error) {
+	c := &canon{ctx: ctx, mod: w.mod}
+	defer c.recover(&err)
+	params := make([]uint64, 2)
+	c.lowerListU8(b, params[0:2])
+	results, err := w.fTypesEchoBytes.Call(ctx, params...)
+	if err != nil {
+		return
+	}
+	r = c.loadListU8(uint32(results[0]))
+	if w.postTypesEchoBytes != nil {
+		_, err = w.postTypesEchoBytes.Call(ctx, results...)
+	}
+	return
+}
+
+// TypesEchoPoints calls the export "example:roundtrip/types@0.1.0#echo-points".
+func (w *Roundtrip) TypesEchoPoints(ctx context.Context, p Points) (r Points, err error) {
+	c := &canon{ctx: ctx, mod: w.mod}
+	defer c.recover(&err)
+	params := make([]uint64, 2)
+	c.lowerListPoint(p, params[0:2])
+	results, err := w.fTypesEchoPoints.Call(ctx, params...)
+	if err != nil {
+		return
+	}
+	r = c.loadListPoint(uint32(results[0]))
+	if w.postTypesEchoPoints != nil {
+		_, err = w.postTypesEchoPoints.Call(ctx, results...)
+	}
+	return
+}
+
+// TypesSum calls the export "example:roundtrip/types@0.1.0#sum".
+func (w *Roundtrip) TypesSum(ctx context.Context, a uint32, b uint32, c_ uint32, d uint32, e uint32, f uint32, g uint32, h uint32, i uint32, j uint32, k uint32, l uint32, m uint32, n uint32, o uint32, p uint32, q uint32) (r uint32, err error) {
+	c := &canon{ctx: ctx, mod: w.mod}
+	defer c.recover(&err)
+	ptr := c.alloc(68, 4)
+	c.storeU32(ptr, a)
+	c.storeU32(ptr+4, b)
+	c.storeU32(ptr+8, c_)
+	c.storeU32(ptr+12, d)
+	c.storeU32(ptr+16, e)
+	c.storeU32(ptr+20, f)
+	c.storeU32(ptr+24, g)
+	c.storeU32(ptr+28, h)
+	c.storeU32(ptr+32, i)
+	c.storeU32(ptr+36, j)
+	c.storeU32(ptr+40, k)
+	c.storeU32(ptr+44, l)
+	c.storeU32(ptr+48, m)
+	c.storeU32(ptr+52, n)
+	c.storeU32(ptr+56, o)
+	c.storeU32(ptr+60, p)
+	c.storeU32(ptr+64, q)
+	results, err := w.fTypesSum.Call(ctx, uint64(ptr))
+	if err != nil {
+		return
+	}
+	r = uint32(results[0])
+	if w.postTypesSum != nil {
+		_, err = w.postTypesSum.Call(ctx, results...)
+	}
+	return
+}
+
+// TypesNothing calls the export "example:roundtrip/types@0.1.0#nothing".
+func (w *Roundtrip) TypesNothing(ctx context.Context) (err error) {
+	c := &canon{ctx: ctx, mod: w.mod}
+	defer c.recover(&err)
+	results, err := w.fTypesNothing.Call(ctx)
+	if err != nil {
+		return
+	}
+	if w.postTypesNothing != nil {
+		_, err = w.postTypesNothing.Call(ctx, results...)
+	}
+	return
+}
+
+// canon lifts and lowers values with the canonical ABI, in the memory of mod.
+type canon struct {
+	ctx     context.Context
+	mod     api.Module
+	realloc api.Function
+}
+
+// abiError is an error lifting or lowering a value, which traps.
+type abiError struct{ error }
+
+func (c *canon) trap(format string, args ...interface{}) {
+	panic(abiError{fmt.Errorf(format, args...)})
+}
+
+// recover sets err to the error of a trap, if any.
+func (c *canon) recover(err *error) {
+	if r := recover(); r != nil {
+		e, ok := r.(abiError)
+		if !ok {
+			panic(r)
+		}
+		*err = e.error
+	}
+}
+
+// alloc allocates memory with the function "cabi_realloc" of the guest.
+func (c *canon) alloc(size, align uint32) uint32 {
+	if c.realloc == nil {
+		if c.realloc = c.mod.ExportedFunction("cabi_realloc"); c.realloc == nil {
+			c.trap("module[%s] doesn't export function[cabi_realloc]", c.mod.Name())
+		}
+	}
+	results, err := c.realloc.Call(c.ctx, 0, 0, uint64(align), uint64(size))
+	if err != nil {
+		panic(abiError{err})
+	}
+	ptr := uint32(results[0])
+	if ptr%align != 0 {
+		c.trap("cabi_realloc returned unaligned pointer %d", ptr)
+	}
+	return ptr
+}
+
+func (c *canon) checkDiscriminant(disc, cases uint32) {
+	if disc >= cases {
+		c.trap("invalid discriminant %d", disc)
+	}
+}
+
+func (c *canon) read(ptr uint32, size uint64) []byte {
+	if size > 0xffffffff {
+		c.trap("out of range reading %d bytes at offset %d", size, ptr)
+	}
+	b, ok := c.mod.Memory().Read(ptr, uint32(size))
+	if !ok {
+		c.trap("out of range reading %d bytes at offset %d", size, ptr)
+	}
+	return b
+}
+
+func (c *canon) write(ptr uint32, b []byte) {
+	if !c.mod.Memory().Write(ptr, b) {
+		c.trap("out of range writing %d bytes at offset %d", len(b), ptr)
+	}
+}
+
+func (c *canon) loadU8(off uint32) uint8 {
+	return c.read(off, 1)[0]
+}
+
+func (c *canon) loadU16(off uint32) uint16 {
+	v, _ := c.mod.Memory().ReadUint16Le(c.checkOffset(off, 2))
+	return v
+}
+
+func (c *canon) loadU32(off uint32) uint32 {
+	v, _ := c.mod.Memory().ReadUint32Le(c.checkOffset(off, 4))
+	return v
+}
+
+func (c *canon) loadU64(off uint32) uint64 {
+	v, _ := c.mod.Memory().ReadUint64Le(c.checkOffset(off, 8))
+	return v
+}
+
+// checkOffset traps unless the size bytes at the offset are in memory.
+func (c *canon) checkOffset(off, size uint32) uint32 {
+	c.read(off, uint64(size))
+	return off
+}
+
+func (c *canon) storeBool(off uint32, v bool) {
+	c.storeU8(off, uint8(flatBool(v)))
+}
+
+func (c *canon) storeU8(off uint32, v uint8) {
+	c.write(off, []byte{v})
+}
+
+func (c *canon) storeU16(off uint32, v uint16) {
+	c.mod.Memory().WriteUint16Le(c.checkOffset(off, 2), v)
+}
+
+func (c *canon) storeU32(off uint32, v uint32) {
+	c.mod.Memory().WriteUint32Le(c.checkOffset(off, 4), v)
+}
+
+func (c *canon) storeU64(off uint32, v uint64) {
+	c.mod.Memory().WriteUint64Le(c.checkOffset(off, 8), v)
+}
+
+func (c *canon) readString(ptr, n uint32) string {
+	return string(c.read(ptr, uint64(n)))
+}
+
+func (c *canon) writeString(v string) (uint32, uint32) {
+	ptr := c.alloc(uint32(len(v)), 1)
+	c.write(ptr, []byte(v))
+	return ptr, uint32(len(v))
+}
+
+func (c *canon) loadString(off uint32) string {
+	return c.readString(c.loadU32(off), c.loadU32(off+4))
+}
+
+func (c *canon) storeString(off uint32, v string) {
+	ptr, n := c.writeString(v)
+	c.storeU32(off, ptr)
+	c.storeU32(off+4, n)
+}
+
+func (c *canon) liftString(flat []uint64) string {
+	return c.readString(uint32(flat[0]), uint32(flat[1]))
+}
+
+func (c *canon) lowerString(v string, flat []uint64) {
+	ptr, n := c.writeString(v)
+	flat[0], flat[1] = uint64(ptr), uint64(n)
+}
+
+func flatBool(v bool) uint64 {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+func (c *canon) loadPoint(off uint32) Point {
+	return Point{
+		X: math.Float32frombits(c.loadU32(off)),
+		Y: math.Float32frombits(c.loadU32(off + 4)),
+	}
+}
+
+func (c *canon) storePoint(off uint32, v Point) {
+	c.storeU32(off, math.Float32bits(v.X))
+	c.storeU32(off+4, math.Float32bits(v.Y))
+}
+
+func (c *canon) liftPoint(flat []uint64) Point {
+	return Point{
+		X: math.Float32frombits(uint32(flat[0])),
+		Y: math.Float32frombits(uint32(flat[1])),
+	}
+}
+
+func (c *canon) lowerPoint(v Point, flat []uint64) {
+	flat[0] = uint64(math.Float32bits(v.X))
+	flat[1] = uint64(math.Float32bits(v.Y))
+}
+
+func (c *canon) loadShape(off uint32) (v Shape) {
+	disc := uint32(c.loadU8(off))
+	c.checkDiscriminant(disc, 3)
+	v.Tag = ShapeTag(disc)
+	switch v.Tag {
+	case ShapeCircle:
+		v.Circle = math.Float32frombits(c.loadU32(off + 4))
+	case ShapeRectangle:
+		v.Rectangle = c.loadPoint(off + 4)
+	}
+	return
+}
+
+func (c *canon) liftShape(flat []uint64) (v Shape) {
+	disc := uint32(flat[0])
+	c.checkDiscriminant(disc, 3)
+	v.Tag = ShapeTag(disc)
+	switch v.Tag {
+	case ShapeCircle:
+		v.Circle = math.Float32frombits(uint32(flat[1]))
+	case ShapeRectangle:
+		v.Rectangle = c.liftPoint(flat[1:3])
+	}
+	return
+}
+
+func (c *canon) storeShape(off uint32, v Shape) {
+	c.storeU8(off, uint8(v.Tag))
+	switch v.Tag {
+	case ShapeCircle:
+		c.storeU32(off+4, math.Float32bits(v.Circle))
+	case ShapeRectangle:
+		c.storePoint(off+4, v.Rectangle)
+	}
+}
+
+func (c *canon) lowerShape(v Shape, flat []uint64) {
+	flat[0] = uint64(v.Tag)
+	switch v.Tag {
+	case ShapeCircle:
+		flat[1] = uint64(math.Float32bits(v.Circle))
+	case ShapeRectangle:
+		c.lowerPoint(v.Rectangle, flat[1:3])
+	}
+}
+
+func (c *canon) readListString(ptr, n uint32) []string {
+	c.read(ptr, uint64(n)*8)
+	ret := make([]string, n)
+	for i := range ret {
+		ret[i] = c.loadString(ptr + uint32(i)*8)
+	}
+	return ret
+}
+
+func (c *canon) writeListString(v []string) (uint32, uint32) {
+	ptr := c.alloc(uint32(len(v))*8, 4)
+	for i, e := range v {
+		c.storeString(ptr+uint32(i)*8, e)
+	}
+	return ptr, uint32(len(v))
+}
+
+func (c *canon) loadListString(off uint32) []string {
+	return c.readListString(c.loadU32(off), c.loadU32(off+4))
+}
+
+func (c *canon) storeListString(off uint32, v []string) {
+	ptr, n := c.writeListString(v)
+	c.storeU32(off, ptr)
+	c.storeU32(off+4, n)
+}
+
+func (c *canon) liftListString(flat []uint64) []string {
+	return c.readListString(uint32(flat[0]), uint32(flat[1]))
+}
+
+func (c *canon) lowerListString(v []string, flat []uint64) {
+	ptr, n := c.writeListString(v)
+	flat[0], flat[1] = uint64(ptr), uint64(n)
+}
+
+func (c *canon) loadColor(off uint32) (v Color) {
+	disc := uint32(c.loadU8(off))
+	c.checkDiscriminant(disc, 3)
+	v = Color(disc)
+	return
+}
+
+func (c *canon) liftColor(flat []uint64) (v Color) {
+	disc := uint32(flat[0])
+	c.checkDiscriminant(disc, 3)
+	v = Color(disc)
+	return
+}
+
+func (c *canon) storeColor(off uint32, v Color) {
+	c.storeU8(off, uint8(v))
+}
+
+func (c *canon) lowerColor(v Color, flat []uint64) {
+	flat[0] = uint64(v)
+}
+
+func (c *canon) loadOptionColor(off uint32) (v *Color) {
+	disc := uint32(c.loadU8(off))
+	c.checkDiscriminant(disc, 2)
+	if disc == 1 {
+		e := c.loadColor(off + 1)
+		v = &e
+	}
+	return
+}
+
+func (c *canon) liftOptionColor(flat []uint64) (v *Color) {
+	disc := uint32(flat[0])
+	c.checkDiscriminant(disc, 2)
+	if disc == 1 {
+		e := c.liftColor(flat[1:2])
+		v = &e
+	}
+	return
+}
+
+func (c *canon) storeOptionColor(off uint32, v *Color) {
+	if v == nil {
+		c.storeU8(off, uint8(0))
+		return
+	}
+	c.storeU8(off, uint8(1))
+	c.storeColor(off+1, *v)
+}
+
+func (c *canon) lowerOptionColor(v *Color, flat []uint64) {
+	if v == nil {
+		flat[0] = uint64(0)
+		return
+	}
+	flat[0] = uint64(1)
+	c.lowerColor(*v, flat[1:2])
+}
+
+func (c *canon) loadResultU32String(off uint32) (v ResultU32String) {
+	disc := uint32(c.loadU8(off))
+	c.checkDiscriminant(disc, 2)
+	v.IsErr = disc == 1
+	if v.IsErr {
+		v.Err = c.loadString(off + 4)
+	} else {
+		v.Ok = c.loadU32(off + 4)
+	}
+	return
+}
+
+func (c *canon) liftResultU32String(flat []uint64) (v ResultU32String) {
+	disc := uint32(flat[0])
+	c.checkDiscriminant(disc, 2)
+	v.IsErr = disc == 1
+	if v.IsErr {
+		v.Err = c.liftString(flat[1:3])
+	} else {
+		v.Ok = uint32(flat[1])
+	}
+	return
+}
+
+func (c *canon) storeResultU32String(off uint32, v ResultU32String) {
+	if !v.IsErr {
+		c.storeU8(off, uint8(0))
+		c.storeU32(off+4, v.Ok)
+		return
+	}
+	c.storeU8(off, uint8(1))
+	c.storeString(off+4, v.Err)
+}
+
+func (c *canon) lowerResultU32String(v ResultU32String, flat []uint64) {
+	if !v.IsErr {
+		flat[0] = uint64(0)
+		flat[1] = uint64(v.Ok)
+		return
+	}
+	flat[0] = uint64(1)
+	c.lowerString(v.Err, flat[1:3])
+}
+
+func (c *canon) loadPermissions(off uint32) Permissions {
+	return Permissions(c.loadU8(off))
+}
+
+func (c *canon) storePermissions(off uint32, v Permissions) {
+	c.storeU8(off, uint8(v))
+}
+
+func (c *canon) liftPermissions(flat []uint64) Permissions {
+	return Permissions(flat[0])
+}
+
+func (c *canon) lowerPermissions(v Permissions, flat []uint64) {
+	flat[0] = uint64(v)
+}
+
+func (c *canon) loadTupleBoolCharU8(off uint32) TupleBoolCharU8 {
+	return TupleBoolCharU8{
+		F0: c.loadU8(off) != 0,
+		F1: rune(c.loadU32(off + 4)),
+		F2: c.loadU8(off + 8),
+	}
+}
+
+func (c *canon) storeTupleBoolCharU8(off uint32, v TupleBoolCharU8) {
+	c.storeBool(off, v.F0)
+	c.storeU32(off+4, uint32(v.F1))
+	c.storeU8(off+8, v.F2)
+}
+
+func (c *canon) liftTupleBoolCharU8(flat []uint64) TupleBoolCharU8 {
+	return TupleBoolCharU8{
+		F0: flat[0] != 0,
+		F1: rune(flat[1]),
+		F2: uint8(flat[2]),
+	}
+}
+
+func (c *canon) lowerTupleBoolCharU8(v TupleBoolCharU8, flat []uint64) {
+	flat[0] = flatBool(v.F0)
+	flat[1] = uint64(uint32(v.F1))
+	flat[2] = uint64(v.F2)
+}
+
+func (c *canon) readListU8(ptr, n uint32) []uint8 {
+	return append([]uint8{}, c.read(ptr, uint64(n))...)
+}
+
+func (c *canon) writeListU8(v []uint8) (uint32, uint32) {
+	ptr := c.alloc(uint32(len(v)), 1)
+	c.write(ptr, v)
+	return ptr, uint32(len(v))
+}
+
+func (c *canon) loadListU8(off uint32) []uint8 {
+	return c.readListU8(c.loadU32(off), c.loadU32(off+4))
+}
+
+func (c *canon) storeListU8(off uint32, v []uint8) {
+	ptr, n := c.writeListU8(v)
+	c.storeU32(off, ptr)
+	c.storeU32(off+4, n)
+}
+
+func (c *canon) liftListU8(flat []uint64) []uint8 {
+	return c.readListU8(uint32(flat[0]), uint32(flat[1]))
+}
+
+func (c *canon) lowerListU8(v []uint8, flat []uint64) {
+	ptr, n := c.writeListU8(v)
+	flat[0], flat[1] = uint64(ptr), uint64(n)
+}
+
+func (c *canon) readListPoint(ptr, n uint32) []Point {
+	c.read(ptr, uint64(n)*8)
+	ret := make([]Point, n)
+	for i := range ret {
+		ret[i] = c.loadPoint(ptr + uint32(i)*8)
+	}
+	return ret
+}
+
+func (c *canon) writeListPoint(v []Point) (uint32, uint32) {
+	ptr := c.alloc(uint32(len(v))*8, 4)
+	for i, e := range v {
+		c.storePoint(ptr+uint32(i)*8, e)
+	}
+	return ptr, uint32(len(v))
+}
+
+func (c *canon) loadListPoint(off uint32) []Point {
+	return c.readListPoint(c.loadU32(off), c.loadU32(off+4))
+}
+
+func (c *canon) storeListPoint(off uint32, v []Point) {
+	ptr, n := c.writeListPoint(v)
+	c.storeU32(off, ptr)
+	c.storeU32(off+4, n)
+}
+
+func (c *canon) liftListPoint(flat []uint64) []Point {
+	return c.readListPoint(uint32(flat[0]), uint32(flat[1]))
+}
+
+func (c *canon) lowerListPoint(v []Point, flat []uint64) {
+	ptr, n := c.writeListPoint(v)
+	flat[0], flat[1] = uint64(ptr), uint64(n)
+}
@@ -0,0 +1,283 @@
+package witexample
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/internal/leb128"
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	"github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+// testCtx is an arbitrary, non-default context. Non-nil also prevents linter errors.
+var testCtx = context.WithValue(context.Background(), struct{}{}, "arbitrary")
+
+const typesModule = "example:roundtrip/types@0.1.0"
+
+// guestFunction is a function the guest imports and exports with the same
+// name, and the same flattened parameters and results.
+type guestFunction struct {
+	module, name    string
+	params, results []wasm.ValueType
+}
+
+var (
+	i32, i64, f32  = wasm.ValueTypeI32, wasm.ValueTypeI64, wasm.ValueTypeF32
+	guestFunctions = []guestFunction{
+		{typesModule, "add", []wasm.ValueType{i32, i64}, []wasm.ValueType{i64}},
+		{typesModule, "greet", []wasm.ValueType{i32, i32}, []wasm.ValueType{i32, i32}},
+		{typesModule, "echo-point", []wasm.ValueType{f32, f32}, []wasm.ValueType{f32, f32}},
+		{typesModule, "echo-shape", []wasm.ValueType{i32, f32, f32}, []wasm.ValueType{i32, f32, f32}},
+		{typesModule, "echo-strings", []wasm.ValueType{i32, i32}, []wasm.ValueType{i32, i32}},
+		{typesModule, "echo-option", []wasm.ValueType{i32, i32}, []wasm.ValueType{i32, i32}},
+		{typesModule, "echo-result", []wasm.ValueType{i32, i32, i32}, []wasm.ValueType{i32, i32, i32}},
+		{typesModule, "echo-permissions", []wasm.ValueType{i32}, []wasm.ValueType{i32}},
+		{typesModule, "echo-tuple", []wasm.ValueType{i32, i32, i32}, []wasm.ValueType{i32, i32, i32}},
+		{typesModule, "echo-bytes", []wasm.ValueType{i32, i32}, []wasm.ValueType{i32, i32}},
+		{typesModule, "echo-points", []wasm.ValueType{i32, i32}, []wasm.ValueType{i32, i32}},
+		{typesModule, "sum", []wasm.ValueType{i32}, []wasm.ValueType{i32}}, // params in memory
+		{typesModule, "nothing", nil, nil},
+		{"$root", "now", nil, []wasm.ValueType{i64}},
+	}
+)
+
+// retArea is the offset in memory of results returned by a pointer.
+const retArea = 64
+
+// guestWasm is a guest of the world "roundtrip", whose exports forward to
+// the imports of the same name, and whose "cabi_realloc" bumps a pointer.
+var guestWasm = binary.EncodeModule(guestModule())
+
+func guestModule() *wasm.Module {
+	m := &wasm.Module{
+		MemorySection: &wasm.Memory{Min: 1},
+		GlobalSection: []*wasm.Global{{ // the heap pointer
+			Type: &wasm.GlobalType{ValType: i32, Mutable: true},
+			Init: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: leb128.EncodeInt32(1024)},
+		}},
+	}
+	addType := func(params, results []wasm.ValueType) wasm.Index {
+		m.TypeSection = append(m.TypeSection, &wasm.FunctionType{Params: params, Results: results})
+		return wasm.Index(len(m.TypeSection) - 1)
+	}
+
+	for i, f := range guestFunctions {
+		params, results := f.params, f.results
+		retptr := len(f.results) > 1
+		if retptr {
+			params, results = append(append([]wasm.ValueType{}, f.params...), i32), nil
+		}
+		m.ImportSection = append(m.ImportSection, &wasm.Import{
+			Module: f.module, Name: f.name, Type: wasm.ExternTypeFunc, DescFunc: addType(params, results),
+		})
+
+		var body []byte
+		for j := range f.params {
+			body = append(body, wasm.OpcodeLocalGet, byte(j))
+		}
+		if retptr {
+			ptr := append([]byte{wasm.OpcodeI32Const}, leb128.EncodeInt32(retArea)...)
+			body = append(append(append(body, ptr...), wasm.OpcodeCall, byte(i)), ptr...)
+			results = []wasm.ValueType{i32}
+		} else {
+			body = append(body, wasm.OpcodeCall, byte(i))
+		}
+		m.FunctionSection = append(m.FunctionSection, addType(f.params, results))
+		m.CodeSection = append(m.CodeSection, &wasm.Code{Body: append(body, wasm.OpcodeEnd)})
+	}
+
+	// cabi_realloc(old, oldSize, align, size) aligns the heap pointer, and
+	// returns it before adding size.
+	m.FunctionSection = append(m.FunctionSection, addType([]wasm.ValueType{i32, i32, i32, i32}, []wasm.ValueType{i32}))
+	m.CodeSection = append(m.CodeSection, &wasm.Code{LocalTypes: []wasm.ValueType{i32}, Body: []byte{
+		wasm.OpcodeGlobalGet, 0, wasm.OpcodeLocalGet, 2, wasm.OpcodeI32Add,
+		wasm.OpcodeI32Const, 1, wasm.OpcodeI32Sub,
+		wasm.OpcodeI32Const, 0, wasm.OpcodeLocalGet, 2, wasm.OpcodeI32Sub,
+		wasm.OpcodeI32And, wasm.OpcodeLocalTee, 4,
+		wasm.OpcodeLocalGet, 3, wasm.OpcodeI32Add, wasm.OpcodeGlobalSet, 0,
+		wasm.OpcodeLocalGet, 4, wasm.OpcodeEnd,
+	}})
+
+	imported := wasm.Index(len(guestFunctions))
+	for i, f := range guestFunctions {
+		name := f.module + "#" + f.name
+		if f.module == "$root" {
+			name = "run"
+		}
+		m.ExportSection = append(m.ExportSection, &wasm.Export{Name: name, Type: wasm.ExternTypeFunc, Index: imported + wasm.Index(i)})
+	}
+	m.ExportSection = append(m.ExportSection,
+		&wasm.Export{Name: "cabi_realloc", Type: wasm.ExternTypeFunc, Index: imported + wasm.Index(len(guestFunctions))},
+		&wasm.Export{Name: "memory", Type: wasm.ExternTypeMemory},
+	)
+	return m
+}
+
+// host implements the imports by echoing values.
+type host struct{ nothingCalls int }
+
+func (h *host) Add(_ context.Context, a int32, b int64) (int64, error) { return int64(a) + b, nil }
+
+func (h *host) Greet(_ context.Context, name string) (string, error) {
+	if name == "" {
+		return "", errors.New("empty name")
+	}
+	return "Hello, " + name, nil
+}
+
+func (h *host) EchoPoint(_ context.Context, p Point) (Point, error)         { return p, nil }
+func (h *host) EchoShape(_ context.Context, s Shape) (Shape, error)         { return s, nil }
+func (h *host) EchoStrings(_ context.Context, l []string) ([]string, error) { return l, nil }
+func (h *host) EchoOption(_ context.Context, o *Color) (*Color, error)      { return o, nil }
+
+func (h *host) EchoResult(_ context.Context, r ResultU32String) (ResultU32String, error) {
+	return r, nil
+}
+
+func (h *host) EchoPermissions(_ context.Context, p Permissions) (Permissions, error) {
+	return p, nil
+}
+
+func (h *host) EchoTuple(_ context.Context, t TupleBoolCharU8) (TupleBoolCharU8, error) {
+	return t, nil
+}
+
+func (h *host) EchoBytes(_ context.Context, b []uint8) ([]uint8, error) { return b, nil }
+func (h *host) EchoPoints(_ context.Context, p Points) (Points, error)  { return p, nil }
+
+func (h *host) Sum(_ context.Context, a, b, c, d, e, f, g, h_, i, j, k, l, m, n, o, p, q uint32) (uint32, error) {
+	return a + b + c + d + e + f + g + h_ + i + j + k + l + m + n + o + p + q, nil
+}
+
+func (h *host) Nothing(context.Context) error {
+	h.nothingCalls++
+	return nil
+}
+
+func (h *host) Now(context.Context) (uint64, error) { return 42, nil }
+
+func newGuest(t *testing.T, h *host) (api.Module, *Roundtrip) {
+	r := wazero.NewRuntime(testCtx)
+	t.Cleanup(func() { r.Close(testCtx) })
+
+	_, err := InstantiateTypes(testCtx, r, h)
+	require.NoError(t, err)
+	_, err = InstantiateRoundtripImports(testCtx, r, h)
+	require.NoError(t, err)
+
+	mod, err := r.InstantiateModuleFromBinary(testCtx, guestWasm)
+	require.NoError(t, err)
+	w, err := NewRoundtrip(mod)
+	require.NoError(t, err)
+	return mod, w
+}
+
+func TestRoundtrip(t *testing.T) {
+	h := &host{}
+	_, w := newGuest(t, h)
+
+	now, err := w.Run(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), now)
+
+	sum, err := w.TypesAdd(testCtx, -1, 1<<40)
+	require.NoError(t, err)
+	require.Equal(t, int64(1<<40-1), sum)
+
+	greeting, err := w.TypesGreet(testCtx, "wazero")
+	require.NoError(t, err)
+	require.Equal(t, "Hello, wazero", greeting)
+
+	point, err := w.TypesEchoPoint(testCtx, Point{X: 1.5, Y: -2})
+	require.NoError(t, err)
+	require.Equal(t, Point{X: 1.5, Y: -2}, point)
+
+	for _, s := range []Shape{
+		{Tag: ShapeCircle, Circle: 3},
+		{Tag: ShapeRectangle, Rectangle: Point{X: 4, Y: 5}},
+		{Tag: ShapeEmpty},
+	} {
+		shape, err := w.TypesEchoShape(testCtx, s)
+		require.NoError(t, err)
+		require.Equal(t, s, shape)
+	}
+
+	strs, err := w.TypesEchoStrings(testCtx, []string{"a", "", "wazero"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "", "wazero"}, strs)
+
+	blue := ColorBlue
+	for _, o := range []*Color{nil, &blue} {
+		option, err := w.TypesEchoOption(testCtx, o)
+		require.NoError(t, err)
+		require.Equal(t, o, option)
+	}
+
+	for _, r := range []ResultU32String{{Ok: 7}, {IsErr: true, Err: "bad"}} {
+		result, err := w.TypesEchoResult(testCtx, r)
+		require.NoError(t, err)
+		require.Equal(t, r, result)
+	}
+
+	perms, err := w.TypesEchoPermissions(testCtx, PermissionsRead|PermissionsExec)
+	require.NoError(t, err)
+	require.Equal(t, PermissionsRead|PermissionsExec, perms)
+
+	tuple, err := w.TypesEchoTuple(testCtx, TupleBoolCharU8{F0: true, F1: '世', F2: 255})
+	require.NoError(t, err)
+	require.Equal(t, TupleBoolCharU8{F0: true, F1: '世', F2: 255}, tuple)
+
+	bytes, err := w.TypesEchoBytes(testCtx, []byte{1, 2, 3})
+	require.NoError(t, err)
+	require.Equal(t, []byte{1, 2, 3}, bytes)
+
+	points, err := w.TypesEchoPoints(testCtx, Points{{X: 1}, {Y: 2}})
+	require.NoError(t, err)
+	require.Equal(t, Points{{X: 1}, {Y: 2}}, points)
+
+	total, err := w.TypesSum(testCtx, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17)
+	require.NoError(t, err)
+	require.Equal(t, uint32(153), total)
+
+	require.NoError(t, w.TypesNothing(testCtx))
+	require.Equal(t, 1, h.nothingCalls)
+}
+
+func TestRoundtrip_Errors(t *testing.T) {
+	mod, w := newGuest(t, &host{})
+
+	t.Run("host error traps", func(t *testing.T) {
+		_, err := w.TypesGreet(testCtx, "")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "empty name")
+	})
+
+	t.Run("invalid discriminant", func(t *testing.T) {
+		_, err := mod.ExportedFunction(typesModule+"#echo-option").Call(testCtx, 5, 0)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid discriminant 5")
+	})
+
+	t.Run("out of range", func(t *testing.T) {
+		_, err := mod.ExportedFunction(typesModule+"#greet").Call(testCtx, 65536, 1)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "out of range reading 1 bytes at offset 65536")
+	})
+
+	t.Run("missing export", func(t *testing.T) {
+		r := wazero.NewRuntime(testCtx)
+		defer r.Close(testCtx)
+
+		m := guestModule()
+		m.ImportSection, m.FunctionSection, m.CodeSection, m.ExportSection = nil, nil, nil, nil
+		empty, err := r.InstantiateModuleFromBinary(testCtx, binary.EncodeModule(m))
+		require.NoError(t, err)
+
+		_, err = NewRoundtrip(empty)
+		require.EqualError(t, err, "module[] doesn't export function[run]")
+	})
+}
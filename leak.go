@@ -0,0 +1,253 @@
+package wazero
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+
+	internalsys "github.com/tetratelabs/wazero/internal/sys"
+	"github.com/tetratelabs/wazero/internal/wasm"
+)
+
+// LeakKind is the kind of resource in a Leak.
+type LeakKind string
+
+const (
+	// LeakKindModule is an api.Module which was never closed.
+	LeakKindModule LeakKind = "module"
+	// LeakKindCompiledModule is a CompiledModule which was never closed.
+	LeakKindCompiledModule LeakKind = "compiled module"
+	// LeakKindFile is a file opened by a leaked module, e.g. via WASI
+	// "path_open", which it never closed.
+	LeakKindFile LeakKind = "file"
+)
+
+// Leak is a resource which was never closed before Runtime.Close.
+//
+// See RuntimeConfig.WithLeakPolicy
+type Leak struct {
+	// Kind is the kind of resource leaked.
+	Kind LeakKind
+
+	// Name is the name of the module, or for LeakKindFile, the name of the
+	// module which opened it followed by the file descriptor and the file
+	// name, e.g. "app:fd 5 (data.txt)".
+	Name string
+
+	// Stack is the Go stack trace where the module was instantiated or
+	// compiled, formatted as debug.Stack, or nil unless
+	// RuntimeConfig.WithLeakStackTraces is enabled. For LeakKindFile, this is
+	// the stack trace of its module.
+	Stack []byte
+}
+
+// String implements fmt.Stringer
+func (l Leak) String() string {
+	return fmt.Sprintf("%s[%s]", l.Kind, l.Name)
+}
+
+// LeakError is returned by Runtime.Close when resources were never closed
+// before it, and the policy is LeakPolicyError.
+type LeakError struct {
+	Leaks []Leak
+}
+
+// Error implements error
+func (e *LeakError) Error() string {
+	names := make([]string, 0, len(e.Leaks))
+	for _, l := range e.Leaks {
+		names = append(names, l.String())
+	}
+	return fmt.Sprintf("%d resources leaked: %s", len(e.Leaks), strings.Join(names, ", "))
+}
+
+// leakTracker tracks the modules instantiated and compiled by a runtime, so
+// that Runtime.Close can report those which were never closed. All methods
+// are no-ops on a nil leakTracker, which is the case when the policy is
+// LeakPolicyIgnore.
+type leakTracker struct {
+	policy      LeakPolicy
+	handler     LeakHandler
+	stackTraces bool
+
+	mux      sync.Mutex
+	modules  map[*wasm.CallContext]*tracked
+	compiled map[*compiledModule]*tracked
+	// seq orders tracked resources by creation, so that leaks are reported
+	// in that order.
+	seq uint64
+	// pruneAt is the count of tracked modules at which closed ones are
+	// removed, as modules can be closed without notifying the tracker.
+	pruneAt int
+}
+
+// tracked is the state of a tracked resource at creation.
+type tracked struct {
+	seq   uint64
+	stack []byte
+	// lastFD is the last file descriptor assigned before a module was
+	// instantiated, so that files passed by the ModuleConfig aren't reported
+	// as leaks.
+	lastFD uint32
+}
+
+// minPruneAt is the initial leakTracker.pruneAt.
+const minPruneAt = 64
+
+// newLeakTracker returns a leakTracker for the config, or nil if leaks are
+// ignored.
+func newLeakTracker(config *runtimeConfig) *leakTracker {
+	if config.leakPolicy == LeakPolicyIgnore {
+		return nil
+	}
+	return &leakTracker{
+		policy:      config.leakPolicy,
+		handler:     config.leakHandler,
+		stackTraces: config.leakStackTraces,
+		modules:     map[*wasm.CallContext]*tracked{},
+		compiled:    map[*compiledModule]*tracked{},
+		pruneAt:     minPruneAt,
+	}
+}
+
+// stack returns the current stack trace if enabled, or nil.
+func (t *leakTracker) stack() []byte {
+	if t.stackTraces {
+		return debug.Stack()
+	}
+	return nil
+}
+
+// lastFD returns the last file descriptor assigned in sysCtx, or zero if t
+// is nil.
+func (t *leakTracker) lastFD(sysCtx *internalsys.Context) uint32 {
+	if t == nil {
+		return 0
+	}
+	return sysCtx.FS().LastFD()
+}
+
+// trackModule tracks mod, which was just instantiated after the file
+// descriptor lastFD was assigned.
+func (t *leakTracker) trackModule(mod *wasm.CallContext, lastFD uint32) {
+	if t == nil {
+		return
+	}
+	stack := t.stack()
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if len(t.modules) >= t.pruneAt {
+		for m := range t.modules {
+			if m.FailIfClosed() != nil {
+				delete(t.modules, m)
+			}
+		}
+		if t.pruneAt = 2 * len(t.modules); t.pruneAt < minPruneAt {
+			t.pruneAt = minPruneAt
+		}
+	}
+	t.seq++
+	t.modules[mod] = &tracked{seq: t.seq, stack: stack, lastFD: lastFD}
+}
+
+// trackCompiled tracks c, which was just compiled.
+func (t *leakTracker) trackCompiled(c *compiledModule) {
+	if t == nil {
+		return
+	}
+	c.leaks = t
+	stack := t.stack()
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.seq++
+	t.compiled[c] = &tracked{seq: t.seq, stack: stack}
+}
+
+// untrackCompiled stops tracking c, as it was closed.
+func (t *leakTracker) untrackCompiled(c *compiledModule) {
+	if t == nil {
+		return
+	}
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	delete(t.compiled, c)
+}
+
+// leaks returns the modules and compiled modules which weren't closed yet,
+// and files opened by those modules, in the order they were created.
+func (t *leakTracker) leaks() []Leak {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	var leaks []Leak
+	var seqs []uint64
+	add := func(l Leak, seq uint64) {
+		leaks = append(leaks, l)
+		seqs = append(seqs, seq)
+	}
+	for m, tm := range t.modules {
+		if m.FailIfClosed() != nil {
+			continue
+		}
+		add(Leak{Kind: LeakKindModule, Name: m.Name(), Stack: tm.stack}, tm.seq)
+		if m.Sys == nil { // nil if from HostModuleBuilder
+			continue
+		}
+		fsc := m.Sys.FS()
+		for _, fd := range fsc.OpenedFDs() {
+			if fd <= tm.lastFD {
+				continue
+			}
+			if f, ok := fsc.OpenedFile(fd); ok {
+				name := fmt.Sprintf("%s:fd %d (%s)", m.Name(), fd, f.Name)
+				add(Leak{Kind: LeakKindFile, Name: name, Stack: tm.stack}, tm.seq)
+			}
+		}
+	}
+	for c, tc := range t.compiled {
+		if c.closeWithModule { // closed with its module, which is tracked.
+			continue
+		}
+		add(Leak{Kind: LeakKindCompiledModule, Name: c.Name(), Stack: tc.stack}, tc.seq)
+	}
+	// Files of a module share its sequence, and stay after it as they were
+	// appended in order.
+	sort.Stable(leaksBySeq{leaks, seqs})
+	return leaks
+}
+
+// leaksBySeq sorts leaks by their corresponding sequence.
+type leaksBySeq struct {
+	leaks []Leak
+	seqs  []uint64
+}
+
+func (s leaksBySeq) Len() int           { return len(s.leaks) }
+func (s leaksBySeq) Less(i, j int) bool { return s.seqs[i] < s.seqs[j] }
+func (s leaksBySeq) Swap(i, j int) {
+	s.leaks[i], s.leaks[j] = s.leaks[j], s.leaks[i]
+	s.seqs[i], s.seqs[j] = s.seqs[j], s.seqs[i]
+}
+
+// check handles any leaks according to the policy, returning a *LeakError
+// if the policy is LeakPolicyError.
+func (t *leakTracker) check(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	leaks := t.leaks()
+	if len(leaks) == 0 {
+		return nil
+	}
+	switch t.policy {
+	case LeakPolicyError:
+		return &LeakError{Leaks: leaks}
+	case LeakPolicyHandler:
+		if t.handler != nil {
+			t.handler(ctx, leaks)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package wazero
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/tetratelabs/wazero/internal/testing/require"
+	"github.com/tetratelabs/wazero/internal/wasm"
+	binaryformat "github.com/tetratelabs/wazero/internal/wasm/binary"
+)
+
+func TestRuntime_LeakPolicy(t *testing.T) {
+	binary := func(name string) []byte {
+		return binaryformat.EncodeModule(&wasm.Module{NameSection: &wasm.NameSection{ModuleName: name}})
+	}
+
+	// leak compiles "a" without instantiating it, and instantiates "b" which
+	// opens a file, leaving both open. Resources it closes aren't leaks.
+	leak := func(t *testing.T, r Runtime) {
+		_, err := r.CompileModule(testCtx, binary("a"))
+		require.NoError(t, err)
+
+		config := NewModuleConfig().WithFS(fstest.MapFS{"data.txt": {Data: []byte("data")}})
+		b, err := r.InstantiateModule(testCtx, compile(t, r, binary("b")), config)
+		require.NoError(t, err)
+		_, err = b.(*wasm.CallContext).Sys.FS().OpenFile("data.txt", os.O_RDONLY, 0)
+		require.NoError(t, err)
+
+		c, err := r.InstantiateModuleFromBinary(testCtx, binary("c"))
+		require.NoError(t, err)
+		require.NoError(t, c.Close(testCtx))
+
+		env, err := r.NewHostModuleBuilder("env").Instantiate(testCtx, r)
+		require.NoError(t, err)
+		require.NoError(t, env.Close(testCtx))
+	}
+
+	t.Run("ignore", func(t *testing.T) {
+		r := NewRuntime(testCtx)
+		leak(t, r)
+		require.NoError(t, r.Close(testCtx))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		r := NewRuntimeWithConfig(testCtx, NewRuntimeConfig().WithLeakPolicy(LeakPolicyError))
+		leak(t, r)
+		b := r.Module("b")
+
+		err := r.Close(testCtx)
+		require.EqualError(t, err, "4 resources leaked: compiled module[a], compiled module[b], module[b], file[b:fd 4 (data.txt)]")
+		leakErr, ok := err.(*LeakError)
+		require.True(t, ok)
+		require.Nil(t, leakErr.Leaks[0].Stack)
+
+		// Leaked resources are still closed.
+		require.Error(t, b.(*wasm.CallContext).FailIfClosed())
+	})
+
+	t.Run("handler", func(t *testing.T) {
+		var leaks []Leak
+		r := NewRuntimeWithConfig(testCtx, NewRuntimeConfig().WithLeakStackTraces(true).WithLeakHandler(
+			func(ctx context.Context, l []Leak) {
+				leaks = l
+			}))
+		leak(t, r)
+
+		require.NoError(t, r.Close(testCtx))
+		require.Equal(t, 4, len(leaks))
+		for _, l := range leaks {
+			require.True(t, strings.Contains(string(l.Stack), "leak_test.go"), string(l.Stack))
+		}
+	})
+
+	t.Run("no leaks", func(t *testing.T) {
+		r := NewRuntimeWithConfig(testCtx, NewRuntimeConfig().WithLeakPolicy(LeakPolicyError))
+		compiled := compile(t, r, binary("a"))
+		mod, err := r.InstantiateModule(testCtx, compiled, NewModuleConfig())
+		require.NoError(t, err)
+		require.NoError(t, mod.Close(testCtx))
+		require.NoError(t, compiled.Close(testCtx))
+
+		require.NoError(t, r.Close(testCtx))
+	})
+}
+
+func compile(t *testing.T, r Runtime, binary []byte) CompiledModule {
+	compiled, err := r.CompileModule(testCtx, binary)
+	require.NoError(t, err)
+	return compiled
+}
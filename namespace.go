@@ -52,6 +52,7 @@ type Namespace interface {
 type namespace struct {
 	store *wasm.Store
 	ns    *wasm.Namespace
+	leaks *leakTracker
 }
 
 // Module implements Namespace.Module.
@@ -72,6 +73,14 @@ func (ns *namespace) InstantiateModule(
 	if sysCtx, err = config.toSysContext(); err != nil {
 		return
 	}
+	if err = config.openSockets(ctx, sysCtx.FS()); err != nil {
+		_ = sysCtx.FS().Close(ctx) // don't leak sockets already opened.
+		return
+	}
+	if err = config.openInheritedFiles(sysCtx.FS()); err != nil {
+		_ = sysCtx.FS().Close(ctx) // don't leak sockets already opened.
+		return
+	}
 
 	name := config.name
 	if name == "" && code.module.NameSection != nil && code.module.NameSection.ModuleName != "" {
@@ -79,8 +88,10 @@ func (ns *namespace) InstantiateModule(
 	}
 
 	// Instantiate the module in the appropriate namespace.
+	lastFD := ns.leaks.lastFD(sysCtx)
 	mod, err = ns.store.Instantiate(ctx, ns.ns, code.module, name, sysCtx)
 	if err != nil {
+		_ = sysCtx.FS().Close(ctx) // don't leak open files or sockets.
 		// If there was an error, don't leak the compiled module.
 		if code.closeWithModule {
 			_ = code.Close(ctx) // don't overwrite the error
@@ -108,6 +119,7 @@ func (ns *namespace) InstantiateModule(
 			return
 		}
 	}
+	ns.leaks.trackModule(mod.(*wasm.CallContext), lastFD)
 	return
 }
 
@@ -7,9 +7,13 @@ import (
 
 	"github.com/tetratelabs/wazero/api"
 	experimentalapi "github.com/tetratelabs/wazero/experimental"
+	"github.com/tetratelabs/wazero/internal/engine/compiler"
+	"github.com/tetratelabs/wazero/internal/engine/interpreter"
+	"github.com/tetratelabs/wazero/internal/platform"
 	"github.com/tetratelabs/wazero/internal/version"
 	"github.com/tetratelabs/wazero/internal/wasm"
 	binaryformat "github.com/tetratelabs/wazero/internal/wasm/binary"
+	"github.com/tetratelabs/wazero/internal/wasmdebug"
 )
 
 // Runtime allows embedding of WebAssembly modules.
@@ -66,6 +70,21 @@ type Runtime interface {
 	//   - To avoid using configuration defaults, use InstantiateModule instead.
 	InstantiateModuleFromBinary(ctx context.Context, source []byte) (api.Module, error)
 
+	// NewMemory returns a memory of minPages, which can grow to maxPages.
+	// Unlike the memory of a module, it can be exported by any number of host
+	// modules with HostModuleBuilder.ExportMemory, so that modules importing
+	// it under different names share it.
+	//
+	// Here's an example of two modules, which import "env" "memory" and
+	// "sidecar" "memory", exchanging data without copying:
+	//
+	//	mem, _ := r.NewMemory(1, 16)
+	//	_, _ = r.NewHostModuleBuilder("env").ExportMemory("memory", mem).Instantiate(ctx, r)
+	//	_, _ = r.NewHostModuleBuilder("sidecar").ExportMemory("memory", mem).Instantiate(ctx, r)
+	//
+	// Note: This errs if maxPages is over RuntimeConfig.WithMemoryLimitPages.
+	NewMemory(minPages, maxPages uint32) (api.Memory, error)
+
 	// Namespace is the default namespace of this runtime, and is embedded for convenience. Most users will only use the
 	// default namespace.
 	//
@@ -113,6 +132,8 @@ type Runtime interface {
 	//	// Everything below here can be closed, but will anyway due to above.
 	//	_, _ = wasi_snapshot_preview1.InstantiateSnapshotPreview1(ctx, r)
 	//	mod, _ := r.InstantiateModuleFromBinary(ctx, wasm)
+	//
+	// See RuntimeConfig.WithLeakPolicy to find resources which weren't closed before this.
 	CloseWithExitCode(ctx context.Context, exitCode uint32) error
 
 	// Closer closes all namespace and compiled code by delegating to CloseWithExitCode with an exit code of zero.
@@ -131,14 +152,35 @@ func NewRuntimeWithConfig(ctx context.Context, rConfig RuntimeConfig) Runtime {
 	}
 	config := rConfig.(*runtimeConfig)
 	store, ns := wasm.NewStore(config.enabledFeatures, config.newEngine(ctx, config.enabledFeatures))
+	store.HostFunctionPanicPolicy = wasm.HostFunctionPanicPolicy(config.panicPolicy)
+	store.HostFunctionPanicHandler = wasm.HostFunctionPanicHandler(config.panicHandler)
+	store.CPUTimeAccounting = config.cpuTimeAccounting
+	store.EventListener, _ = ctx.Value(experimentalapi.EventListenerKey{}).(experimentalapi.EventListener)
+	if config.divergenceHandler != nil {
+		newEngine := interpreter.NewEngine
+		if config.isInterpreter && platform.CompilerSupported() {
+			newEngine = compiler.NewEngine
+		}
+		differential, _ := wasm.NewStore(config.enabledFeatures, newEngine(ctx, config.enabledFeatures))
+		differential.HostFunctionPanicPolicy, differential.HostFunctionPanicHandler = store.HostFunctionPanicPolicy, store.HostFunctionPanicHandler
+		store.EnableDifferential(differential, wasm.DivergenceHandler(config.divergenceHandler))
+	}
+	leaks := newLeakTracker(config)
 	return &runtime{
 		store:                 store,
-		ns:                    &namespace{store: store, ns: ns},
+		ns:                    &namespace{store: store, ns: ns, leaks: leaks},
 		enabledFeatures:       config.enabledFeatures,
 		memoryLimitPages:      config.memoryLimitPages,
 		memoryCapacityFromMax: config.memoryCapacityFromMax,
 		isInterpreter:         config.isInterpreter,
 		dwarfDisabled:         config.dwarfDisabled,
+		ensureTermination:     config.ensureTermination,
+		listenerFactory:       config.listenerFactory,
+		memoryListener:        config.memoryListener,
+		trapHandler:           config.trapHandler,
+		canonicalizeNaN:       config.canonicalizeNaN,
+		memoryProtection:      config.memoryProtection,
+		leaks:                 leaks,
 	}
 }
 
@@ -151,12 +193,19 @@ type runtime struct {
 	memoryCapacityFromMax bool
 	isInterpreter         bool
 	dwarfDisabled         bool
+	ensureTermination     bool
+	listenerFactory       experimentalapi.FunctionListenerFactory
+	memoryListener        experimentalapi.MemoryListener
+	trapHandler           experimentalapi.TrapHandler
+	canonicalizeNaN       bool
+	memoryProtection      bool
 	compiledModules       []*compiledModule
+	leaks                 *leakTracker
 }
 
 // NewNamespace implements Runtime.NewNamespace.
 func (r *runtime) NewNamespace(ctx context.Context) Namespace {
-	return &namespace{store: r.store, ns: r.store.NewNamespace(ctx)}
+	return &namespace{store: r.store, ns: r.store.NewNamespace(ctx), leaks: r.leaks}
 }
 
 // Module implements Namespace.Module embedded by Runtime.
@@ -164,6 +213,21 @@ func (r *runtime) Module(moduleName string) api.Module {
 	return r.ns.Module(moduleName)
 }
 
+// NewMemory implements Runtime.NewMemory
+func (r *runtime) NewMemory(minPages, maxPages uint32) (api.Memory, error) {
+	capacity := minPages
+	if r.memoryCapacityFromMax {
+		capacity = maxPages
+	}
+	mem := &wasm.Memory{Min: minPages, Cap: capacity, Max: maxPages, IsMaxEncoded: true}
+	if err := mem.Validate(r.memoryLimitPages); err != nil {
+		return nil, err
+	}
+	ret := wasm.NewMemoryInstance(mem)
+	ret.Protectable = r.memoryProtection
+	return ret, nil
+}
+
 // CompileModule implements Runtime.CompileModule
 func (r *runtime) CompileModule(ctx context.Context, binary []byte) (CompiledModule, error) {
 	if binary == nil {
@@ -184,7 +248,16 @@ func (r *runtime) CompileModule(ctx context.Context, binary []byte) (CompiledMod
 		return nil, err
 	}
 
-	internal.AssignModuleID(binary)
+	internal.MemoryListener = r.memoryListener
+	if ml := ctx.Value(experimentalapi.MemoryListenerKey{}); ml != nil {
+		internal.MemoryListener = ml.(experimentalapi.MemoryListener)
+	}
+	internal.TrapHandler = r.trapHandler
+	internal.CanonicalizeNaN = r.canonicalizeNaN
+	internal.ProtectMemory = r.memoryProtection
+	internal.MeterFuel = ctx.Value(experimentalapi.FuelKey{}) != nil
+	loadSourceMap(ctx, internal)
+	internal.AssignModuleID(binary, r.ensureTermination)
 
 	// Now that the module is validated, cache the function and memory definitions.
 	internal.BuildFunctionDefinitions()
@@ -192,26 +265,57 @@ func (r *runtime) CompileModule(ctx context.Context, binary []byte) (CompiledMod
 
 	c := &compiledModule{module: internal, compiledEngine: r.store.Engine}
 
-	listeners, err := buildListeners(ctx, internal)
+	listeners, err := buildListeners(ctx, r.listenerFactory, internal)
 	if err != nil {
 		return nil, err
 	}
 
-	if err = r.store.Engine.CompileModule(ctx, internal, listeners); err != nil {
+	if err = r.store.Engine.CompileModule(ctx, internal, listeners, r.ensureTermination); err != nil {
 		return nil, err
 	}
 
 	r.compiledModules = append(r.compiledModules, c)
+	r.leaks.trackCompiled(c)
+	notifyCompiled(ctx, r.store, c)
 	return c, nil
 }
 
-func buildListeners(ctx context.Context, internal *wasm.Module) ([]experimentalapi.FunctionListener, error) {
+// loadSourceMap sets the source map of the module using the loader in the
+// context, if any, unless the module has DWARF, which takes precedence.
+func loadSourceMap(ctx context.Context, internal *wasm.Module) {
+	if internal.SourceMappingURL == "" || internal.DWARFLines != nil {
+		return
+	}
+	loader, ok := ctx.Value(experimentalapi.SourceMapLoaderKey{}).(experimentalapi.SourceMapLoader)
+	if !ok {
+		return
+	}
+	// Like DWARF, the source map is only used for stack traces, so errors are ignored.
+	if data, err := loader(ctx, internal.SourceMappingURL); err == nil {
+		if sm, err := wasmdebug.NewSourceMap(data, internal.CodeSectionOffset); err == nil {
+			internal.SourceMap = sm
+		}
+	}
+}
+
+// notifyCompiled notifies the experimental.EventListener of the store, if
+// any, that the module was compiled.
+func notifyCompiled(ctx context.Context, store *wasm.Store, c *compiledModule) {
+	if l := store.EventListener; l != nil {
+		l.OnEvent(ctx, experimentalapi.Event{Type: experimentalapi.EventModuleCompiled, ModuleName: c.Name()})
+	}
+}
+
+// buildListeners returns the listeners of the module's functions using the
+// factory in the context, or the given one from RuntimeConfig if none.
+func buildListeners(ctx context.Context, factory experimentalapi.FunctionListenerFactory, internal *wasm.Module) ([]experimentalapi.FunctionListener, error) {
 	// Test to see if internal code are using an experimental feature.
-	fnlf := ctx.Value(experimentalapi.FunctionListenerFactoryKey{})
-	if fnlf == nil {
+	if fnlf := ctx.Value(experimentalapi.FunctionListenerFactoryKey{}); fnlf != nil {
+		factory = fnlf.(experimentalapi.FunctionListenerFactory)
+	}
+	if factory == nil {
 		return nil, nil
 	}
-	factory := fnlf.(experimentalapi.FunctionListenerFactory)
 	importCount := internal.ImportFuncCount()
 	listeners := make([]experimentalapi.FunctionListener, len(internal.FunctionSection))
 	for i := 0; i < len(listeners); i++ {
@@ -246,11 +350,15 @@ func (r *runtime) Close(ctx context.Context) error {
 
 // CloseWithExitCode implements Runtime.CloseWithExitCode
 func (r *runtime) CloseWithExitCode(ctx context.Context, exitCode uint32) error {
+	leakErr := r.leaks.check(ctx) // before closing leaked resources.
 	err := r.store.CloseWithExitCode(ctx, exitCode)
 	for _, c := range r.compiledModules {
 		if e := c.Close(ctx); e != nil && err == nil {
 			err = e
 		}
 	}
+	if err == nil {
+		err = leakErr
+	}
 	return err
 }
@@ -4,16 +4,21 @@ import (
 	"context"
 	_ "embed"
 	"errors"
+	"fmt"
+	"math"
+	goruntime "runtime"
 	"testing"
 	"time"
 
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/experimental"
 	"github.com/tetratelabs/wazero/internal/leb128"
+	"github.com/tetratelabs/wazero/internal/platform"
 	"github.com/tetratelabs/wazero/internal/testing/require"
 	"github.com/tetratelabs/wazero/internal/version"
 	"github.com/tetratelabs/wazero/internal/wasm"
 	binaryformat "github.com/tetratelabs/wazero/internal/wasm/binary"
+	"github.com/tetratelabs/wazero/internal/wasmruntime"
 	"github.com/tetratelabs/wazero/sys"
 )
 
@@ -232,6 +237,111 @@ func TestModule_Memory(t *testing.T) {
 	}
 }
 
+func TestModule_Allocator(t *testing.T) {
+	var calls []string
+	record := func(name string) func(context.Context, api.Module, []uint64) {
+		return func(_ context.Context, _ api.Module, stack []uint64) {
+			calls = append(calls, fmt.Sprintf("%s%v", name, stack))
+			stack[0] = 16
+		}
+	}
+	i32 := api.ValueTypeI32
+
+	tests := []struct {
+		name          string
+		exports       func(HostModuleBuilder) HostModuleBuilder
+		expectedCalls []string
+	}{
+		{
+			name: "none",
+			exports: func(b HostModuleBuilder) HostModuleBuilder {
+				return b
+			},
+		},
+		{
+			name: "malloc without free",
+			exports: func(b HostModuleBuilder) HostModuleBuilder {
+				return b.NewFunctionBuilder().
+					WithGoModuleFunction(api.GoModuleFunc(record("malloc")), []api.ValueType{i32}, []api.ValueType{i32}).
+					Export("malloc")
+			},
+		},
+		{
+			name: "malloc wrong signature",
+			exports: func(b HostModuleBuilder) HostModuleBuilder {
+				return b.NewFunctionBuilder().
+					WithGoModuleFunction(api.GoModuleFunc(record("malloc")), []api.ValueType{api.ValueTypeI64}, []api.ValueType{i32}).
+					Export("malloc").
+					NewFunctionBuilder().
+					WithGoModuleFunction(api.GoModuleFunc(record("free")), []api.ValueType{i32}, nil).
+					Export("free")
+			},
+		},
+		{
+			name: "malloc",
+			exports: func(b HostModuleBuilder) HostModuleBuilder {
+				return b.NewFunctionBuilder().
+					WithGoModuleFunction(api.GoModuleFunc(record("malloc")), []api.ValueType{i32}, []api.ValueType{i32}).
+					Export("malloc").
+					NewFunctionBuilder().
+					WithGoModuleFunction(api.GoModuleFunc(record("free")), []api.ValueType{i32}, nil).
+					Export("free")
+			},
+			expectedCalls: []string{"malloc[5]", "free[16]"},
+		},
+		{
+			name: "canonical_abi",
+			exports: func(b HostModuleBuilder) HostModuleBuilder {
+				return b.NewFunctionBuilder().
+					WithGoModuleFunction(api.GoModuleFunc(record("realloc")), []api.ValueType{i32, i32, i32, i32}, []api.ValueType{i32}).
+					Export("canonical_abi_realloc").
+					NewFunctionBuilder().
+					WithGoModuleFunction(api.GoModuleFunc(record("free")), []api.ValueType{i32, i32, i32}, nil).
+					Export("canonical_abi_free")
+			},
+			expectedCalls: []string{"realloc[0 0 8 5]", "free[16 5 8]"},
+		},
+		{
+			name: "allocate",
+			exports: func(b HostModuleBuilder) HostModuleBuilder {
+				return b.NewFunctionBuilder().
+					WithGoModuleFunction(api.GoModuleFunc(record("allocate")), []api.ValueType{i32}, []api.ValueType{i32}).
+					Export("allocate").
+					NewFunctionBuilder().
+					WithGoModuleFunction(api.GoModuleFunc(record("deallocate")), []api.ValueType{i32, i32}, nil).
+					Export("deallocate")
+			},
+			expectedCalls: []string{"allocate[5]", "deallocate[16 5]"},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			calls = nil
+			r := NewRuntime(testCtx)
+			defer r.Close(testCtx)
+
+			module, err := tc.exports(r.NewHostModuleBuilder("guest")).Instantiate(testCtx, r)
+			require.NoError(t, err)
+
+			a := module.Allocator()
+			if tc.expectedCalls == nil {
+				require.Nil(t, a)
+				return
+			}
+			require.NotNil(t, a)
+
+			offset, err := a.Allocate(testCtx, 5)
+			require.NoError(t, err)
+			require.Equal(t, uint32(16), offset)
+			require.NoError(t, a.Free(testCtx, offset, 5))
+			require.Equal(t, tc.expectedCalls, calls)
+		})
+	}
+}
+
 // TestModule_Global only covers a couple cases to avoid duplication of internal/wasm/global_test.go
 func TestModule_Global(t *testing.T) {
 	globalVal := int64(100) // intentionally a value that differs in signed vs unsigned encoding
@@ -298,7 +408,7 @@ func TestModule_Global(t *testing.T) {
 
 			code := &compiledModule{module: tc.module}
 
-			err := r.store.Engine.CompileModule(testCtx, code.module, nil)
+			err := r.store.Engine.CompileModule(testCtx, code.module, nil, false)
 			require.NoError(t, err)
 
 			// Instantiate the module and get the export of the above global
@@ -648,7 +758,7 @@ type mockEngine struct {
 }
 
 // CompileModule implements the same method as documented on wasm.Engine.
-func (e *mockEngine) CompileModule(_ context.Context, module *wasm.Module, _ []experimental.FunctionListener) error {
+func (e *mockEngine) CompileModule(_ context.Context, module *wasm.Module, _ []experimental.FunctionListener, _ bool) error {
 	e.cachedModules[module] = struct{}{}
 	return nil
 }
@@ -667,3 +777,956 @@ func (e *mockEngine) DeleteCompiledModule(module *wasm.Module) {
 func (e *mockEngine) NewModuleEngine(_ string, _ *wasm.Module, _ []wasm.FunctionInstance) (wasm.ModuleEngine, error) {
 	return nil, nil
 }
+
+func TestRuntime_CloseOnContextDone(t *testing.T) {
+	// infiniteLoop is a function which never returns unless interrupted.
+	bin := binaryformat.EncodeModule(&wasm.Module{
+		TypeSection:     []*wasm.FunctionType{{}},
+		FunctionSection: []wasm.Index{0},
+		CodeSection: []*wasm.Code{{Body: []byte{
+			wasm.OpcodeLoop, 0x40,
+			wasm.OpcodeBr, 0,
+			wasm.OpcodeEnd,
+			wasm.OpcodeEnd,
+		}}},
+		ExportSection: []*wasm.Export{{Name: "infinite_loop", Type: api.ExternTypeFunc, Index: 0}},
+	})
+
+	configs := map[string]RuntimeConfig{"interpreter": NewRuntimeConfigInterpreter()}
+	if platform.CompilerSupported() {
+		configs["compiler"] = NewRuntimeConfigCompiler()
+	}
+
+	for name, config := range configs {
+		config := config.WithCloseOnContextDone(true)
+		t.Run(name, func(t *testing.T) {
+			r := NewRuntimeWithConfig(testCtx, config)
+			defer r.Close(testCtx)
+
+			t.Run("deadline exceeded", func(t *testing.T) {
+				mod, err := r.InstantiateModuleFromBinary(testCtx, bin)
+				require.NoError(t, err)
+
+				ctx, cancel := context.WithTimeout(testCtx, 10*time.Millisecond)
+				defer cancel()
+
+				_, err = mod.ExportedFunction("infinite_loop").Call(ctx)
+				require.Equal(t, sys.NewExitError(mod.Name(), sys.ExitCodeDeadlineExceeded), err)
+				require.True(t, errors.Is(err, context.DeadlineExceeded))
+			})
+
+			t.Run("canceled", func(t *testing.T) {
+				mod, err := r.InstantiateModuleFromBinary(testCtx, bin)
+				require.NoError(t, err)
+
+				ctx, cancel := context.WithCancel(testCtx)
+				go func() {
+					time.Sleep(10 * time.Millisecond)
+					cancel()
+				}()
+
+				_, err = mod.ExportedFunction("infinite_loop").Call(ctx)
+				require.Equal(t, sys.NewExitError(mod.Name(), sys.ExitCodeContextCanceled), err)
+				require.True(t, errors.Is(err, context.Canceled))
+			})
+
+			t.Run("closed", func(t *testing.T) {
+				mod, err := r.InstantiateModuleFromBinary(testCtx, bin)
+				require.NoError(t, err)
+
+				go func() {
+					time.Sleep(10 * time.Millisecond)
+					require.NoError(t, mod.CloseWithExitCode(testCtx, 2))
+				}()
+
+				_, err = mod.ExportedFunction("infinite_loop").Call(testCtx)
+				require.Equal(t, sys.NewExitError(mod.Name(), 2), err)
+			})
+		})
+	}
+}
+
+func TestRuntime_HostFunctionPanicPolicy(t *testing.T) {
+	// callPanic is a function which calls the imported host function "env.panic".
+	bin := binaryformat.EncodeModule(&wasm.Module{
+		TypeSection:     []*wasm.FunctionType{{}},
+		ImportSection:   []*wasm.Import{{Module: "env", Name: "panic", Type: api.ExternTypeFunc, DescFunc: 0}},
+		FunctionSection: []wasm.Index{0},
+		CodeSection:     []*wasm.Code{{Body: []byte{wasm.OpcodeCall, 0, wasm.OpcodeEnd}}},
+		ExportSection:   []*wasm.Export{{Name: "call_panic", Type: api.ExternTypeFunc, Index: 1}},
+	})
+
+	configs := map[string]RuntimeConfig{"interpreter": NewRuntimeConfigInterpreter()}
+	if platform.CompilerSupported() {
+		configs["compiler"] = NewRuntimeConfigCompiler()
+	}
+
+	hostErr := errors.New("host error")
+	callPanic := func(t *testing.T, config RuntimeConfig) (err error) {
+		r := NewRuntimeWithConfig(testCtx, config)
+		defer r.Close(testCtx)
+
+		_, err = r.NewHostModuleBuilder("env").
+			NewFunctionBuilder().WithFunc(func() { panic(hostErr) }).Export("panic").
+			Instantiate(testCtx, r)
+		require.NoError(t, err)
+
+		mod, err := r.InstantiateModuleFromBinary(testCtx, bin)
+		require.NoError(t, err)
+
+		_, err = mod.ExportedFunction("call_panic").Call(testCtx)
+		return
+	}
+
+	for name, config := range configs {
+		config := config
+		t.Run(name, func(t *testing.T) {
+			t.Run("trap", func(t *testing.T) {
+				err := callPanic(t, config.WithHostFunctionPanicPolicy(HostFunctionPanicPolicyTrap))
+				require.ErrorIs(t, err, hostErr)
+				require.Contains(t, err.Error(), "wasm stack trace")
+			})
+
+			t.Run("repanic", func(t *testing.T) {
+				var recovered interface{}
+				func() {
+					defer func() { recovered = recover() }()
+					_ = callPanic(t, config.WithHostFunctionPanicPolicy(HostFunctionPanicPolicyRepanic))
+				}()
+				require.Equal(t, hostErr, recovered)
+			})
+
+			t.Run("handler", func(t *testing.T) {
+				handlerErr := errors.New("handled")
+				var def api.FunctionDefinition
+				var recovered interface{}
+				var stack []byte
+				err := callPanic(t, config.WithHostFunctionPanicHandler(
+					func(_ context.Context, d api.FunctionDefinition, r interface{}, s []byte) error {
+						def, recovered, stack = d, r, s
+						return handlerErr
+					}))
+				require.ErrorIs(t, err, handlerErr)
+				require.Equal(t, "env.panic", def.DebugName())
+				require.Equal(t, hostErr, recovered)
+				require.Contains(t, string(stack), "TestRuntime_HostFunctionPanicPolicy")
+			})
+
+			t.Run("exit", func(t *testing.T) {
+				r := NewRuntimeWithConfig(testCtx, config.WithHostFunctionPanicPolicy(HostFunctionPanicPolicyRepanic))
+				defer r.Close(testCtx)
+
+				_, err := r.NewHostModuleBuilder("env").
+					NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module) {
+					_ = m.CloseWithExitCode(ctx, 3)
+					panic(sys.NewExitError(m.Name(), 3))
+				}).Export("panic").
+					Instantiate(testCtx, r)
+				require.NoError(t, err)
+
+				mod, err := r.InstantiateModuleFromBinary(testCtx, bin)
+				require.NoError(t, err)
+
+				// An exit error isn't a panic to propagate.
+				_, err = mod.ExportedFunction("call_panic").Call(testCtx)
+				require.Equal(t, sys.NewExitError(mod.Name(), 3), err)
+			})
+		})
+	}
+}
+
+func TestRuntime_TrapError(t *testing.T) {
+	// trap (index 1) calls the host function "env.call" (index 0), which calls back into unreachable (index 2).
+	bin := binaryformat.EncodeModule(&wasm.Module{
+		TypeSection:     []*wasm.FunctionType{{}},
+		ImportSection:   []*wasm.Import{{Module: "env", Name: "call", Type: api.ExternTypeFunc, DescFunc: 0}},
+		FunctionSection: []wasm.Index{0, 0},
+		CodeSection: []*wasm.Code{
+			{Body: []byte{wasm.OpcodeCall, 0, wasm.OpcodeEnd}},
+			{Body: []byte{wasm.OpcodeUnreachable, wasm.OpcodeEnd}},
+		},
+		ExportSection: []*wasm.Export{
+			{Name: "trap", Type: api.ExternTypeFunc, Index: 1},
+			{Name: "unreachable", Type: api.ExternTypeFunc, Index: 2},
+		},
+		NameSection: &wasm.NameSection{ModuleName: "test"},
+	})
+
+	configs := map[string]RuntimeConfig{"interpreter": NewRuntimeConfigInterpreter()}
+	if platform.CompilerSupported() {
+		configs["compiler"] = NewRuntimeConfigCompiler()
+	}
+
+	for name, config := range configs {
+		config := config
+		t.Run(name, func(t *testing.T) {
+			r := NewRuntimeWithConfig(testCtx, config)
+			defer r.Close(testCtx)
+
+			_, err := r.NewHostModuleBuilder("env").
+				NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module) {
+				if _, err := m.ExportedFunction("unreachable").Call(ctx); err != nil {
+					panic(err)
+				}
+			}).Export("call").
+				Instantiate(testCtx, r)
+			require.NoError(t, err)
+
+			mod, err := r.InstantiateModuleFromBinary(testCtx, bin)
+			require.NoError(t, err)
+
+			t.Run("wasm", func(t *testing.T) {
+				_, err := mod.ExportedFunction("unreachable").Call(testCtx)
+
+				var trapErr *sys.TrapError
+				require.True(t, errors.As(err, &trapErr))
+				require.Equal(t, sys.TrapKindUnreachable, trapErr.Kind())
+				require.Equal(t, 1, len(trapErr.Frames()))
+				require.Equal(t, uint32(2), trapErr.Frames()[0].FunctionIndex)
+				require.Equal(t, "test.$2", trapErr.Frames()[0].FunctionName)
+			})
+
+			t.Run("host", func(t *testing.T) {
+				_, err := mod.ExportedFunction("trap").Call(testCtx)
+
+				// The host function panicked with the error of the nested call.
+				var trapErr *sys.TrapError
+				require.True(t, errors.As(err, &trapErr))
+				require.Equal(t, sys.TrapKindPanic, trapErr.Kind())
+				require.Equal(t, 2, len(trapErr.Frames()))
+				require.Equal(t, "env.call", trapErr.Frames()[0].FunctionName)
+				require.Equal(t, uint32(1), trapErr.Frames()[1].FunctionIndex)
+
+				// The nested trap is still accessible.
+				nested := errors.Unwrap(trapErr).(*sys.TrapError)
+				require.Equal(t, sys.TrapKindUnreachable, nested.Kind())
+				require.True(t, errors.Is(err, wasmruntime.ErrRuntimeUnreachable))
+			})
+		})
+	}
+}
+
+func TestFunction_Call_V128(t *testing.T) {
+	i32, v128 := api.ValueTypeI32, api.ValueTypeV128
+	bin := binaryformat.EncodeModule(&wasm.Module{
+		TypeSection: []*wasm.FunctionType{
+			{Params: []wasm.ValueType{i32, v128}, Results: []wasm.ValueType{v128, i32}},
+			{Results: []wasm.ValueType{v128}},
+		},
+		FunctionSection: []wasm.Index{0, 1},
+		CodeSection: []*wasm.Code{
+			// swap returns the v128 param and the i32 param.
+			{Body: []byte{wasm.OpcodeLocalGet, 1, wasm.OpcodeLocalGet, 0, wasm.OpcodeEnd}},
+			// constant returns a v128.const with bytes 1 to 16.
+			{Body: []byte{
+				wasm.OpcodeVecPrefix, wasm.OpcodeVecV128Const,
+				1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16,
+				wasm.OpcodeEnd,
+			}},
+		},
+		ExportSection: []*wasm.Export{
+			{Name: "swap", Type: api.ExternTypeFunc, Index: 0},
+			{Name: "constant", Type: api.ExternTypeFunc, Index: 1},
+		},
+	})
+
+	configs := map[string]RuntimeConfig{"interpreter": NewRuntimeConfigInterpreter()}
+	if platform.CompilerSupported() {
+		configs["compiler"] = NewRuntimeConfigCompiler()
+	}
+
+	expected := [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	for name, config := range configs {
+		config := config
+		t.Run(name, func(t *testing.T) {
+			r := NewRuntimeWithConfig(testCtx, config)
+			defer r.Close(testCtx)
+
+			mod, err := r.InstantiateModuleFromBinary(testCtx, bin)
+			require.NoError(t, err)
+
+			results, err := mod.ExportedFunction("constant").Call(testCtx)
+			require.NoError(t, err)
+			require.Equal(t, 2, len(results))
+			require.Equal(t, expected, api.DecodeV128(results[0], results[1]))
+
+			lo, hi := api.EncodeV128(expected)
+			results, err = mod.ExportedFunction("swap").Call(testCtx, 42, lo, hi)
+			require.NoError(t, err)
+			require.Equal(t, 3, len(results))
+			require.Equal(t, expected, api.DecodeV128(results[0], results[1]))
+			require.Equal(t, uint32(42), api.DecodeU32(results[2]))
+
+			// The same using the helpers which map Go values to the signature.
+			swap := mod.ExportedFunction("swap")
+			params, err := api.EncodeParams(swap.Definition(), uint32(42), expected)
+			require.NoError(t, err)
+			results, err = swap.Call(testCtx, params...)
+			require.NoError(t, err)
+			values, err := api.DecodeResults(swap.Definition(), results)
+			require.NoError(t, err)
+			require.Equal(t, []interface{}{expected, int32(42)}, values)
+
+			// A v128 param occupies two values.
+			_, err = mod.ExportedFunction("swap").Call(testCtx, 42, lo)
+			require.EqualError(t, err, "expected 3 params, but passed 2")
+		})
+	}
+}
+
+func TestModule_CPUTime(t *testing.T) {
+	// Each export calls the host function of the same name in "env". "callback" calls the export "spin".
+	bin := binaryformat.EncodeModule(&wasm.Module{
+		TypeSection: []*wasm.FunctionType{{}},
+		ImportSection: []*wasm.Import{
+			{Module: "env", Name: "spin", Type: api.ExternTypeFunc, DescFunc: 0},
+			{Module: "env", Name: "sleep", Type: api.ExternTypeFunc, DescFunc: 0},
+			{Module: "env", Name: "callback", Type: api.ExternTypeFunc, DescFunc: 0},
+		},
+		FunctionSection: []wasm.Index{0, 0, 0},
+		CodeSection: []*wasm.Code{
+			{Body: []byte{wasm.OpcodeCall, 0, wasm.OpcodeEnd}},
+			{Body: []byte{wasm.OpcodeCall, 1, wasm.OpcodeEnd}},
+			{Body: []byte{wasm.OpcodeCall, 2, wasm.OpcodeEnd}},
+		},
+		ExportSection: []*wasm.Export{
+			{Name: "spin", Type: api.ExternTypeFunc, Index: 3},
+			{Name: "sleep", Type: api.ExternTypeFunc, Index: 4},
+			{Name: "callback", Type: api.ExternTypeFunc, Index: 5},
+		},
+	})
+
+	const spin, sleep = 20 * time.Millisecond, 50 * time.Millisecond
+	instantiate := func(t *testing.T, config RuntimeConfig) (api.Module, api.Closer) {
+		r := NewRuntimeWithConfig(testCtx, config)
+		_, err := r.NewHostModuleBuilder("env").
+			NewFunctionBuilder().WithFunc(func() {
+			// The goroutine is locked to its thread during the call.
+			for start := platform.ThreadCputime(); platform.ThreadCputime()-start < int64(spin); {
+			}
+		}).Export("spin").
+			NewFunctionBuilder().WithFunc(func() { time.Sleep(sleep) }).Export("sleep").
+			NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module) {
+			if _, err := m.ExportedFunction("spin").Call(ctx); err != nil {
+				panic(err)
+			}
+		}).Export("callback").
+			Instantiate(testCtx, r)
+		require.NoError(t, err)
+
+		mod, err := r.InstantiateModuleFromBinary(testCtx, bin)
+		require.NoError(t, err)
+		return mod, r
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		mod, r := instantiate(t, NewRuntimeConfig())
+		defer r.Close(testCtx)
+
+		_, err := mod.ExportedFunction("sleep").Call(testCtx)
+		require.NoError(t, err)
+		require.Equal(t, api.CPUTime{}, mod.CPUTime())
+	})
+
+	t.Run("host", func(t *testing.T) {
+		mod, r := instantiate(t, NewRuntimeConfig().WithCPUTimeAccounting(true))
+		defer r.Close(testCtx)
+
+		_, err := mod.ExportedFunction("spin").Call(testCtx)
+		require.NoError(t, err)
+
+		cpuTime := mod.CPUTime()
+		require.True(t, cpuTime.Host >= spin, cpuTime.Host.String())
+		require.True(t, cpuTime.Guest < spin, cpuTime.Guest.String())
+	})
+
+	t.Run("sleep isn't CPU time", func(t *testing.T) {
+		if goruntime.GOOS != "linux" && goruntime.GOOS != "windows" {
+			t.Skip("the CPU time of a thread isn't measured on " + goruntime.GOOS)
+		}
+		mod, r := instantiate(t, NewRuntimeConfig().WithCPUTimeAccounting(true))
+		defer r.Close(testCtx)
+
+		_, err := mod.ExportedFunction("sleep").Call(testCtx)
+		require.NoError(t, err)
+
+		cpuTime := mod.CPUTime()
+		require.True(t, cpuTime.Host < sleep, cpuTime.Host.String())
+		require.True(t, cpuTime.Guest < sleep, cpuTime.Guest.String())
+	})
+
+	t.Run("callback", func(t *testing.T) {
+		mod, r := instantiate(t, NewRuntimeConfig().WithCPUTimeAccounting(true))
+		defer r.Close(testCtx)
+
+		_, err := mod.ExportedFunction("callback").Call(testCtx)
+		require.NoError(t, err)
+
+		// The nested call isn't counted twice.
+		cpuTime := mod.CPUTime()
+		require.True(t, cpuTime.Host >= spin && cpuTime.Host < 2*spin, cpuTime.Host.String())
+		require.True(t, cpuTime.Guest < spin, cpuTime.Guest.String())
+	})
+}
+
+func TestModule_MemoryStats(t *testing.T) {
+	bin := binaryformat.EncodeModule(&wasm.Module{
+		TypeSection:     []*wasm.FunctionType{{}},
+		FunctionSection: []wasm.Index{0},
+		CodeSection: []*wasm.Code{
+			{Body: []byte{wasm.OpcodeI32Const, 1, wasm.OpcodeMemoryGrow, 0, wasm.OpcodeDrop, wasm.OpcodeEnd}},
+		},
+		MemorySection: &wasm.Memory{Min: 1, Cap: 1, Max: 3, IsMaxEncoded: true},
+		DataSection: []*wasm.DataSegment{
+			{OffsetExpression: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0}}, Init: []byte("hello")},
+			{OffsetExpression: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{8}}, Init: []byte("world!")},
+		},
+		ExportSection: []*wasm.Export{{Name: "grow", Type: api.ExternTypeFunc, Index: 0}},
+	})
+
+	r := NewRuntime(testCtx)
+	defer r.Close(testCtx)
+
+	mod, err := r.InstantiateModuleFromBinary(testCtx, bin)
+	require.NoError(t, err)
+	require.Equal(t, api.MemoryStats{Size: 65536, PeakSize: 65536, DataSegmentSize: 11}, mod.MemoryStats())
+
+	grow := mod.ExportedFunction("grow")
+	for i := 0; i < 3; i++ { // The last grow fails as it exceeds the max.
+		_, err = grow.Call(testCtx)
+		require.NoError(t, err)
+	}
+	require.Equal(t, api.MemoryStats{Size: 3 * 65536, PeakSize: 3 * 65536, GrowCount: 2, DataSegmentSize: 11}, mod.MemoryStats())
+
+	t.Run("no memory", func(t *testing.T) {
+		mod, err := r.InstantiateModuleFromBinary(testCtx, binaryformat.EncodeModule(&wasm.Module{NameSection: &wasm.NameSection{ModuleName: "empty"}}))
+		require.NoError(t, err)
+		require.Equal(t, api.MemoryStats{}, mod.MemoryStats())
+	})
+}
+
+func TestModule_Clone(t *testing.T) {
+	i32, inc := api.ValueTypeI32, wasm.Index(0)
+	bin := binaryformat.EncodeModule(&wasm.Module{
+		TypeSection:     []*wasm.FunctionType{{Results: []wasm.ValueType{i32}}},
+		FunctionSection: []wasm.Index{0, 0, 0},
+		CodeSection: []*wasm.Code{
+			{Body: []byte{ // Increments the counter and stores it at offset zero.
+				wasm.OpcodeGlobalGet, 0, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Add, wasm.OpcodeGlobalSet, 0,
+				wasm.OpcodeI32Const, 0, wasm.OpcodeGlobalGet, 0, wasm.OpcodeI32Store, 2, 0,
+				wasm.OpcodeGlobalGet, 0, wasm.OpcodeEnd,
+			}},
+			{Body: []byte{wasm.OpcodeI32Const, 0, wasm.OpcodeCallIndirect, 0, 0, wasm.OpcodeEnd}},
+			{Body: []byte{wasm.OpcodeI32Const, 0, wasm.OpcodeI32Load, 2, 0, wasm.OpcodeEnd}},
+		},
+		GlobalSection: []*wasm.Global{{
+			Type: &wasm.GlobalType{ValType: i32, Mutable: true},
+			Init: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0}},
+		}},
+		TableSection: []*wasm.Table{{Min: 1, Type: wasm.RefTypeFuncref}},
+		ElementSection: []*wasm.ElementSegment{{
+			OffsetExpr: &wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0}},
+			Init:       []*wasm.Index{&inc},
+			Type:       wasm.RefTypeFuncref,
+		}},
+		MemorySection: &wasm.Memory{Min: 1, Cap: 1, Max: 2, IsMaxEncoded: true},
+		ExportSection: []*wasm.Export{
+			{Name: "inc", Type: api.ExternTypeFunc, Index: 0},
+			{Name: "inc_indirect", Type: api.ExternTypeFunc, Index: 1},
+			{Name: "load", Type: api.ExternTypeFunc, Index: 2},
+			{Name: "counter", Type: api.ExternTypeGlobal, Index: 0},
+		},
+	})
+
+	configs := map[string]RuntimeConfig{"interpreter": NewRuntimeConfigInterpreter()}
+	if platform.CompilerSupported() {
+		configs["compiler"] = NewRuntimeConfigCompiler()
+	}
+
+	for name, config := range configs {
+		config := config
+		t.Run(name, func(t *testing.T) {
+			r := NewRuntimeWithConfig(testCtx, config)
+			defer r.Close(testCtx)
+
+			mod, err := r.InstantiateModuleFromBinary(testCtx, bin)
+			require.NoError(t, err)
+			requireCall(t, mod, "inc", 1)
+
+			clone, err := mod.Clone(testCtx)
+			require.NoError(t, err)
+			require.Equal(t, mod.Name(), clone.Name())
+
+			// The clone starts with the state of the module, but changes neither.
+			requireCall(t, clone, "inc", 2)
+			requireCall(t, clone, "inc_indirect", 3)
+			requireCall(t, clone, "load", 3)
+			requireCall(t, mod, "load", 1)
+			require.Equal(t, uint64(1), mod.ExportedGlobal("counter").Get())
+			requireCall(t, mod, "inc_indirect", 2)
+			requireCall(t, clone, "load", 3)
+
+			// Later clones have the current state of the module.
+			require.True(t, mod.Memory().WriteUint32Le(0, 42))
+			clone2, err := mod.Clone(testCtx)
+			require.NoError(t, err)
+			requireCall(t, clone2, "load", 42)
+			clone3, err := mod.Clone(testCtx)
+			require.NoError(t, err)
+			requireCall(t, clone3, "load", 42)
+			requireCall(t, clone2, "inc", 3)
+			requireCall(t, clone3, "load", 42)
+
+			// Grow copies the memory beyond the capacity of the mapping.
+			_, ok := clone3.Memory().Grow(1)
+			require.True(t, ok)
+			requireCall(t, clone3, "load", 42)
+
+			// Closing a clone doesn't close the module.
+			require.NoError(t, clone.Close(testCtx))
+			require.NotNil(t, r.Module(mod.Name()))
+			requireCall(t, mod, "load", 42)
+
+			require.NoError(t, mod.Close(testCtx))
+			_, err = mod.Clone(testCtx)
+			require.EqualError(t, err, `module "" closed with exit_code(0)`)
+			requireCall(t, clone2, "load", 3)
+
+			// Closing a clone releases its memory.
+			require.NoError(t, clone2.Close(testCtx))
+			require.NoError(t, clone3.Close(testCtx))
+		})
+	}
+}
+
+func TestRuntime_NewMemory(t *testing.T) {
+	r := NewRuntimeWithConfig(testCtx, NewRuntimeConfig().WithMemoryLimitPages(4))
+	defer r.Close(testCtx)
+
+	_, err := r.NewMemory(1, 5)
+	require.EqualError(t, err, "max 5 pages (320 Ki) over limit of 4 pages (256 Ki)")
+
+	mem, err := r.NewMemory(1, 4)
+	require.NoError(t, err)
+	require.Equal(t, uint32(65536), mem.Size())
+
+	// Two host modules export the same memory under different names.
+	_, err = r.NewHostModuleBuilder("env").ExportMemory("memory", mem).Instantiate(testCtx, r)
+	require.NoError(t, err)
+	_, err = r.NewHostModuleBuilder("sidecar").ExportMemory("mem", mem).Instantiate(testCtx, r)
+	require.NoError(t, err)
+
+	guest := func(name, importModule, importName string) api.Module {
+		bin := binaryformat.EncodeModule(&wasm.Module{
+			TypeSection: []*wasm.FunctionType{{Results: []wasm.ValueType{api.ValueTypeI32}}},
+			ImportSection: []*wasm.Import{{
+				Module: importModule, Name: importName,
+				Type: api.ExternTypeMemory, DescMem: &wasm.Memory{Min: 1, Max: 4, IsMaxEncoded: true},
+			}},
+			FunctionSection: []wasm.Index{0},
+			CodeSection: []*wasm.Code{{Body: []byte{ // Increments the value at offset zero.
+				wasm.OpcodeI32Const, 0,
+				wasm.OpcodeI32Const, 0, wasm.OpcodeI32Load, 2, 0, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Add,
+				wasm.OpcodeI32Store, 2, 0,
+				wasm.OpcodeI32Const, 0, wasm.OpcodeI32Load, 2, 0, wasm.OpcodeEnd,
+			}}},
+			ExportSection: []*wasm.Export{{Name: "inc", Type: api.ExternTypeFunc, Index: 0}},
+			NameSection:   &wasm.NameSection{ModuleName: name},
+		})
+		mod, err := r.InstantiateModuleFromBinary(testCtx, bin)
+		require.NoError(t, err)
+		return mod
+	}
+	a, b := guest("a", "env", "memory"), guest("b", "sidecar", "mem")
+
+	require.True(t, mem.WriteUint32Le(0, 40))
+	requireCall(t, a, "inc", 41)
+	requireCall(t, b, "inc", 42)
+	v, _ := mem.ReadUint32Le(0)
+	require.Equal(t, uint32(42), v)
+
+	// Growing the memory is visible to all modules.
+	_, ok := b.Memory().Grow(1)
+	require.True(t, ok)
+	require.Equal(t, uint32(2*65536), a.Memory().Size())
+	require.Equal(t, uint32(2*65536), mem.Size())
+}
+
+func requireCall(t *testing.T, mod api.Module, name string, expected uint32) {
+	results, err := mod.ExportedFunction(name).Call(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, expected, api.DecodeU32(results[0]))
+}
+
+// namesListenerFactory is an experimental.FunctionListenerFactory which
+// records the names of functions called.
+type namesListenerFactory struct{ names []string }
+
+func (f *namesListenerFactory) NewListener(api.FunctionDefinition) experimental.FunctionListener {
+	return f
+}
+
+func (f *namesListenerFactory) Before(ctx context.Context, _ api.Module, def api.FunctionDefinition, _ []uint64, _ experimental.StackIterator) context.Context {
+	f.names = append(f.names, def.DebugName())
+	return ctx
+}
+
+func (f *namesListenerFactory) After(context.Context, api.Module, api.FunctionDefinition, []uint64) {}
+
+func (f *namesListenerFactory) Abort(context.Context, api.Module, api.FunctionDefinition, error) {}
+
+func TestRuntime_WithFunctionListenerFactory(t *testing.T) {
+	// "call" calls the host function "env.host".
+	bin := binaryformat.EncodeModule(&wasm.Module{
+		TypeSection:     []*wasm.FunctionType{{}},
+		ImportSection:   []*wasm.Import{{Module: "env", Name: "host", Type: api.ExternTypeFunc, DescFunc: 0}},
+		FunctionSection: []wasm.Index{0},
+		CodeSection:     []*wasm.Code{{Body: []byte{wasm.OpcodeCall, 0, wasm.OpcodeEnd}}},
+		ExportSection:   []*wasm.Export{{Name: "call", Type: api.ExternTypeFunc, Index: 1}},
+		NameSection:     &wasm.NameSection{ModuleName: "test", FunctionNames: wasm.NameMap{{Index: 1, Name: "call"}}},
+	})
+
+	t.Run("config", func(t *testing.T) {
+		f := &namesListenerFactory{}
+		r := NewRuntimeWithConfig(testCtx, NewRuntimeConfig().WithFunctionListenerFactory(f))
+		defer r.Close(testCtx)
+
+		_, err := r.NewHostModuleBuilder("env").
+			NewFunctionBuilder().WithFunc(func() {}).Export("host").
+			Instantiate(testCtx, r)
+		require.NoError(t, err)
+		mod, err := r.InstantiateModuleFromBinary(testCtx, bin)
+		require.NoError(t, err)
+
+		_, err = mod.ExportedFunction("call").Call(testCtx)
+		require.NoError(t, err)
+		require.Equal(t, []string{"test.call", "env.host"}, f.names)
+	})
+
+	t.Run("context takes precedence", func(t *testing.T) {
+		configured, inContext := &namesListenerFactory{}, &namesListenerFactory{}
+		r := NewRuntimeWithConfig(testCtx, NewRuntimeConfig().WithFunctionListenerFactory(configured))
+		defer r.Close(testCtx)
+
+		_, err := r.NewHostModuleBuilder("env").
+			NewFunctionBuilder().WithFunc(func() {}).Export("host").
+			Instantiate(testCtx, r)
+		require.NoError(t, err)
+		ctx := context.WithValue(testCtx, experimental.FunctionListenerFactoryKey{}, inContext)
+		mod, err := r.InstantiateModuleFromBinary(ctx, bin)
+		require.NoError(t, err)
+
+		_, err = mod.ExportedFunction("call").Call(testCtx)
+		require.NoError(t, err)
+		require.Equal(t, []string{"env.host"}, configured.names)
+		require.Equal(t, []string{"test.call"}, inContext.names)
+	})
+}
+
+func TestRuntime_WithDifferentialExecution(t *testing.T) {
+	i32 := api.ValueTypeI32
+	// "next" returns the result of "env.next", which counts its calls, so it
+	// diverges between engines. "store" stores it in memory instead.
+	bin := binaryformat.EncodeModule(&wasm.Module{
+		TypeSection: []*wasm.FunctionType{
+			{Results: []api.ValueType{i32}},
+			{Params: []api.ValueType{i32, i32}, Results: []api.ValueType{i32}},
+			{},
+		},
+		ImportSection:   []*wasm.Import{{Module: "env", Name: "next", Type: api.ExternTypeFunc, DescFunc: 0}},
+		FunctionSection: []wasm.Index{0, 1, 2, 2},
+		MemorySection:   &wasm.Memory{Min: 1, Cap: 1, Max: 1},
+		CodeSection: []*wasm.Code{
+			{Body: []byte{wasm.OpcodeCall, 0, wasm.OpcodeEnd}},
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Add, wasm.OpcodeEnd}},
+			{Body: []byte{
+				wasm.OpcodeI32Const, 8, wasm.OpcodeCall, 0, wasm.OpcodeI32Store, 2, 0,
+				wasm.OpcodeEnd,
+			}},
+			{Body: []byte{wasm.OpcodeUnreachable, wasm.OpcodeEnd}},
+		},
+		ExportSection: []*wasm.Export{
+			{Name: "next", Type: api.ExternTypeFunc, Index: 1},
+			{Name: "add", Type: api.ExternTypeFunc, Index: 2},
+			{Name: "store", Type: api.ExternTypeFunc, Index: 3},
+			{Name: "unreachable", Type: api.ExternTypeFunc, Index: 4},
+		},
+	})
+
+	var divergences []string
+	r := NewRuntimeWithConfig(testCtx, NewRuntimeConfig().WithDifferentialExecution(
+		func(ctx context.Context, def api.FunctionDefinition, divergence string) {
+			divergences = append(divergences, def.ExportNames()[0]+": "+divergence)
+		}))
+	defer r.Close(testCtx)
+
+	var calls uint32
+	_, err := r.NewHostModuleBuilder("env").
+		NewFunctionBuilder().WithFunc(func() uint32 { calls++; return calls }).Export("next").
+		Instantiate(testCtx, r)
+	require.NoError(t, err)
+	mod, err := r.InstantiateModuleFromBinary(testCtx, bin)
+	require.NoError(t, err)
+
+	t.Run("same", func(t *testing.T) {
+		divergences = nil
+		results, err := mod.ExportedFunction("add").Call(testCtx, 1, 2)
+		require.NoError(t, err)
+		require.Equal(t, []uint64{3}, results)
+
+		_, err = mod.ExportedFunction("unreachable").Call(testCtx)
+		require.Contains(t, err.Error(), "unreachable")
+		require.Nil(t, divergences)
+	})
+
+	t.Run("result", func(t *testing.T) {
+		divergences, calls = nil, 0
+		results, err := mod.ExportedFunction("next").Call(testCtx)
+		require.NoError(t, err)
+		require.Equal(t, []uint64{1}, results) // of the engine of the config
+		require.Equal(t, []string{"next: result[0] 0x1 != 0x2"}, divergences)
+	})
+
+	t.Run("memory", func(t *testing.T) {
+		divergences, calls = nil, 0
+		_, err := mod.ExportedFunction("store").Call(testCtx)
+		require.NoError(t, err)
+		require.Equal(t, []string{"store: memory[8] 0x1 != 0x2"}, divergences)
+	})
+}
+
+func TestRuntime_WithNaNCanonicalization(t *testing.T) {
+	f32, f64, i32, i64, v128 := api.ValueTypeF32, api.ValueTypeF64, api.ValueTypeI32, api.ValueTypeI64, api.ValueTypeV128
+	bin := binaryformat.EncodeModule(&wasm.Module{
+		TypeSection: []*wasm.FunctionType{
+			{Params: []api.ValueType{f32}, Results: []api.ValueType{i32}},
+			{Params: []api.ValueType{f64, f64}, Results: []api.ValueType{i64}},
+			{Params: []api.ValueType{f32}, Results: []api.ValueType{v128}},
+		},
+		FunctionSection: []wasm.Index{0, 1, 2},
+		CodeSection: []*wasm.Code{
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeF32Sqrt, wasm.OpcodeI32ReinterpretF32, wasm.OpcodeEnd}},
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeF64Div, wasm.OpcodeI64ReinterpretF64,
+				wasm.OpcodeEnd,
+			}},
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeVecPrefix, wasm.OpcodeVecF32x4Splat,
+				wasm.OpcodeVecPrefix, wasm.OpcodeVecF32x4Sqrt,
+				wasm.OpcodeEnd,
+			}},
+		},
+		ExportSection: []*wasm.Export{
+			{Name: "f32.sqrt", Type: api.ExternTypeFunc, Index: 0},
+			{Name: "f64.div", Type: api.ExternTypeFunc, Index: 1},
+			{Name: "f32x4.sqrt", Type: api.ExternTypeFunc, Index: 2},
+		},
+	})
+
+	configs := map[string]RuntimeConfig{"interpreter": NewRuntimeConfigInterpreter()}
+	if platform.CompilerSupported() {
+		configs["compiler"] = NewRuntimeConfigCompiler()
+	}
+	for name, config := range configs {
+		config := config
+		t.Run(name, func(t *testing.T) {
+			r := NewRuntimeWithConfig(testCtx, config.WithNaNCanonicalization(true))
+			defer r.Close(testCtx)
+			mod, err := r.InstantiateModuleFromBinary(testCtx, bin)
+			require.NoError(t, err)
+
+			results, err := mod.ExportedFunction("f32.sqrt").Call(testCtx, api.EncodeF32(-1))
+			require.NoError(t, err)
+			require.Equal(t, uint64(0x7fc00000), results[0])
+
+			results, err = mod.ExportedFunction("f32.sqrt").Call(testCtx, api.EncodeF32(4))
+			require.NoError(t, err)
+			require.Equal(t, uint64(math.Float32bits(2)), results[0]) // numbers are unaffected.
+
+			results, err = mod.ExportedFunction("f64.div").Call(testCtx, api.EncodeF64(0), api.EncodeF64(0))
+			require.NoError(t, err)
+			require.Equal(t, uint64(0x7ff8000000000000), results[0])
+
+			results, err = mod.ExportedFunction("f32x4.sqrt").Call(testCtx, api.EncodeF32(-1))
+			require.NoError(t, err)
+			require.Equal(t, []uint64{0x7fc000007fc00000, 0x7fc000007fc00000}, results)
+		})
+	}
+}
+
+func TestRuntime_WithMemoryProtection(t *testing.T) {
+	i32 := api.ValueTypeI32
+	bin := binaryformat.EncodeModule(&wasm.Module{
+		TypeSection: []*wasm.FunctionType{
+			{Params: []api.ValueType{i32}},
+			{Params: []api.ValueType{i32, i32}},
+			{Params: []api.ValueType{i32}, Results: []api.ValueType{i32}},
+		},
+		FunctionSection: []wasm.Index{0, 1, 2},
+		MemorySection:   &wasm.Memory{Min: 2, Cap: 2, Max: 2, IsMaxEncoded: true},
+		CodeSection: []*wasm.Code{
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Const, 42, wasm.OpcodeI32Store, 2, 0, wasm.OpcodeEnd}},
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Const, 1, wasm.OpcodeLocalGet, 1,
+				wasm.OpcodeMiscPrefix, wasm.OpcodeMiscMemoryFill, 0,
+				wasm.OpcodeEnd,
+			}},
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Load, 2, 0, wasm.OpcodeEnd}},
+		},
+		ExportSection: []*wasm.Export{
+			{Name: "store", Type: api.ExternTypeFunc, Index: 0},
+			{Name: "fill", Type: api.ExternTypeFunc, Index: 1},
+			{Name: "load", Type: api.ExternTypeFunc, Index: 2},
+		},
+	})
+
+	configs := map[string]RuntimeConfig{"interpreter": NewRuntimeConfigInterpreter()}
+	if platform.CompilerSupported() {
+		configs["compiler"] = NewRuntimeConfigCompiler()
+	}
+	for name, config := range configs {
+		config := config
+		t.Run(name, func(t *testing.T) {
+			r := NewRuntimeWithConfig(testCtx, config)
+			defer r.Close(testCtx)
+			mod, err := r.InstantiateModuleFromBinary(testCtx, bin)
+			require.NoError(t, err)
+
+			// Memory can't be protected unless the runtime was configured to.
+			require.False(t, mod.Memory().Protect(0, 1, true))
+
+			r = NewRuntimeWithConfig(testCtx, config.WithMemoryProtection(true))
+			defer r.Close(testCtx)
+			mod, err = r.InstantiateModuleFromBinary(testCtx, bin)
+			require.NoError(t, err)
+			mem := mod.Memory()
+			store, fill, load := mod.ExportedFunction("store"), mod.ExportedFunction("fill"), mod.ExportedFunction("load")
+
+			// The host publishes a value in the second page, then protects it.
+			require.True(t, mem.WriteUint32Le(65536, 7))
+			require.True(t, mem.Protect(65536, 4, true))
+			require.False(t, mem.WriteUint32Le(65536, 8))
+
+			// Writes to the first page succeed.
+			_, err = store.Call(testCtx, 0)
+			require.NoError(t, err)
+			_, err = fill.Call(testCtx, 0, 16)
+			require.NoError(t, err)
+
+			// Writes to the second page trap, even if they start in the first.
+			for _, call := range []func() error{
+				func() error { _, err := store.Call(testCtx, 65536+100); return err },
+				func() error { _, err := store.Call(testCtx, 65534); return err },
+				func() error { _, err := fill.Call(testCtx, 65530, 10); return err },
+			} {
+				err = call()
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "wasm error: read-only memory access")
+			}
+
+			// The guest can still read the value, which is unchanged.
+			results, err := load.Call(testCtx, 65536)
+			require.NoError(t, err)
+			require.Equal(t, []uint64{7}, results)
+
+			// Once writable again, the guest can write it.
+			require.True(t, mem.Protect(65536, 4, false))
+			_, err = store.Call(testCtx, 65536)
+			require.NoError(t, err)
+			results, err = load.Call(testCtx, 65536)
+			require.NoError(t, err)
+			require.Equal(t, []uint64{42}, results)
+
+			// Protect can be called while the guest writes memory, e.g. when run with -race.
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for i := 0; i < 100; i++ {
+					mem.Protect(65536, 4, i%2 == 0)
+				}
+			}()
+			for i := 0; i < 100; i++ {
+				_, err = store.Call(testCtx, 0)
+				require.NoError(t, err)
+				_, _ = store.Call(testCtx, 65536) // traps while the page is read-only.
+			}
+			<-done
+		})
+	}
+}
+
+func TestRuntime_CustomPageSizes(t *testing.T) {
+	i32 := api.ValueTypeI32
+	bin := binaryformat.EncodeModule(&wasm.Module{
+		TypeSection: []*wasm.FunctionType{
+			{Results: []api.ValueType{i32}},
+			{Params: []api.ValueType{i32}, Results: []api.ValueType{i32}},
+			{Params: []api.ValueType{i32}},
+		},
+		FunctionSection: []wasm.Index{0, 1, 2},
+		MemorySection:   &wasm.Memory{Min: 3, Cap: 3, Max: 10, IsMaxEncoded: true, IsPageSizeEncoded: true},
+		CodeSection: []*wasm.Code{
+			{Body: []byte{wasm.OpcodeMemorySize, 0, wasm.OpcodeEnd}},
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeMemoryGrow, 0, wasm.OpcodeEnd}},
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Store8, 0, 0, wasm.OpcodeEnd}},
+		},
+		ExportSection: []*wasm.Export{
+			{Name: "size", Type: api.ExternTypeFunc, Index: 0},
+			{Name: "grow", Type: api.ExternTypeFunc, Index: 1},
+			{Name: "store", Type: api.ExternTypeFunc, Index: 2},
+			{Name: "memory", Type: api.ExternTypeMemory, Index: 0},
+		},
+	})
+
+	configs := map[string]RuntimeConfig{"interpreter": NewRuntimeConfigInterpreter()}
+	if platform.CompilerSupported() {
+		configs["compiler"] = NewRuntimeConfigCompiler()
+	}
+	for name, config := range configs {
+		config := config
+		t.Run(name, func(t *testing.T) {
+			r := NewRuntimeWithConfig(testCtx, config)
+			defer r.Close(testCtx)
+
+			// The feature is not enabled by default.
+			_, err := r.CompileModule(testCtx, bin)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), `feature "custom-page-sizes" is disabled`)
+
+			r = NewRuntimeWithConfig(testCtx, config.WithCoreFeatures(api.CoreFeaturesV2|api.CoreFeatureCustomPageSizes))
+			defer r.Close(testCtx)
+			compiled, err := r.CompileModule(testCtx, bin)
+			require.NoError(t, err)
+			require.Equal(t, uint32(1), compiled.ExportedMemories()["memory"].PageSize())
+			mod, err := r.InstantiateModule(testCtx, compiled, NewModuleConfig())
+			require.NoError(t, err)
+			size, grow, store := mod.ExportedFunction("size"), mod.ExportedFunction("grow"), mod.ExportedFunction("store")
+
+			// The memory is as many bytes as pages.
+			require.Equal(t, uint32(3), mod.Memory().Size())
+			results, err := size.Call(testCtx)
+			require.NoError(t, err)
+			require.Equal(t, []uint64{3}, results)
+
+			results, err = grow.Call(testCtx, 4)
+			require.NoError(t, err)
+			require.Equal(t, []uint64{3}, results)
+			results, err = size.Call(testCtx)
+			require.NoError(t, err)
+			require.Equal(t, []uint64{7}, results)
+
+			// Growing past the max fails.
+			results, err = grow.Call(testCtx, 4)
+			require.NoError(t, err)
+			require.Equal(t, []uint64{0xffffffff}, results)
+
+			// Accesses are bounds checked to the byte.
+			_, err = store.Call(testCtx, 6)
+			require.NoError(t, err)
+			_, err = store.Call(testCtx, 7)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "out of bounds memory access")
+		})
+	}
+}
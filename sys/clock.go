@@ -18,5 +18,13 @@ type Walltime func() (sec int64, nsec int32)
 // increments. For example, -1 is a valid if the next value is >= 0.
 type Nanotime func() int64
 
+// Cputime returns nanoseconds of CPU time consumed since an arbitrary start
+// point, used to measure how much a module computed, as opposed to how much
+// time elapsed.
+//
+// Note: Like Nanotime, there are no constraints on the value returned except
+// that it increments.
+type Cputime func() int64
+
 // Nanosleep puts the current goroutine to sleep for at least ns nanoseconds.
 type Nanosleep func(ns int64)
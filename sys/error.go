@@ -2,9 +2,19 @@
 package sys
 
 import (
+	"context"
 	"fmt"
 )
 
+// These two special exit codes are reserved by wazero for context Cancel and Timeout integrations.
+// The assumption here is that well-behaving Wasm programs won't use these two exit codes.
+const (
+	// ExitCodeContextCanceled corresponds to context.Canceled and returned by ExitError.ExitCode in that case.
+	ExitCodeContextCanceled uint32 = 0xffffffff
+	// ExitCodeDeadlineExceeded corresponds to context.DeadlineExceeded and returned by ExitError.ExitCode in that case.
+	ExitCodeDeadlineExceeded uint32 = 0xefffffff
+)
+
 // ExitError is returned to a caller of api.Function still running when
 // api.Module CloseWithExitCode was invoked. ExitCode zero value means success,
 // while any other value is an error.
@@ -44,13 +54,29 @@ func (e *ExitError) ExitCode() uint32 {
 
 // Error implements the error interface.
 func (e *ExitError) Error() string {
-	return fmt.Sprintf("module %q closed with exit_code(%d)", e.moduleName, e.exitCode)
+	switch e.exitCode {
+	case ExitCodeContextCanceled:
+		return fmt.Sprintf("module %q closed with %s", e.moduleName, context.Canceled)
+	case ExitCodeDeadlineExceeded:
+		return fmt.Sprintf("module %q closed with %s", e.moduleName, context.DeadlineExceeded)
+	default:
+		return fmt.Sprintf("module %q closed with exit_code(%d)", e.moduleName, e.exitCode)
+	}
 }
 
 // Is allows use via errors.Is
+//
+// Note: An ExitError with ExitCodeContextCanceled or ExitCodeDeadlineExceeded
+// also matches context.Canceled or context.DeadlineExceeded respectively.
 func (e *ExitError) Is(err error) bool {
 	if target, ok := err.(*ExitError); ok {
 		return e.moduleName == target.moduleName && e.exitCode == target.exitCode
 	}
+	switch err {
+	case context.Canceled:
+		return e.exitCode == ExitCodeContextCanceled
+	case context.DeadlineExceeded:
+		return e.exitCode == ExitCodeDeadlineExceeded
+	}
 	return false
 }
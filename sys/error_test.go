@@ -1,6 +1,7 @@
 package sys
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -51,6 +52,11 @@ func TestIs(t *testing.T) {
 			},
 			matches: false,
 		},
+		{
+			name:    "not context canceled",
+			target:  context.Canceled,
+			matches: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -61,3 +67,44 @@ func TestIs(t *testing.T) {
 		})
 	}
 }
+
+func TestIs_Context(t *testing.T) {
+	canceled := NewExitError("some module", ExitCodeContextCanceled)
+	require.True(t, errors.Is(canceled, context.Canceled))
+	require.False(t, errors.Is(canceled, context.DeadlineExceeded))
+
+	deadline := NewExitError("some module", ExitCodeDeadlineExceeded)
+	require.True(t, errors.Is(deadline, context.DeadlineExceeded))
+	require.False(t, errors.Is(deadline, context.Canceled))
+}
+
+func TestExitError_Error(t *testing.T) {
+	tests := []struct {
+		name     string
+		exitCode uint32
+		expected string
+	}{
+		{
+			name:     "exit code",
+			exitCode: 2,
+			expected: `module "foo" closed with exit_code(2)`,
+		},
+		{
+			name:     "context canceled",
+			exitCode: ExitCodeContextCanceled,
+			expected: `module "foo" closed with context canceled`,
+		},
+		{
+			name:     "deadline exceeded",
+			exitCode: ExitCodeDeadlineExceeded,
+			expected: `module "foo" closed with context deadline exceeded`,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, NewExitError("foo", tc.exitCode).Error())
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package sys
+
+import "github.com/tetratelabs/wazero/api"
+
+// TrapKind classifies the cause of a TrapError.
+type TrapKind byte
+
+const (
+	// TrapKindPanic is a Go panic recovered while executing a function, for
+	// example, a host function defined with wazero.HostModuleBuilder calling
+	// panic.
+	TrapKindPanic TrapKind = iota
+	// TrapKindUnreachable means the "unreachable" instruction was executed.
+	TrapKindUnreachable
+	// TrapKindOutOfBoundsMemoryAccess means an instruction accessed a region
+	// beyond the linear memory.
+	TrapKindOutOfBoundsMemoryAccess
+	// TrapKindInvalidTableAccess means either the offset to the table was out
+	// of bounds, or the element was uninitialized during "call_indirect".
+	TrapKindInvalidTableAccess
+	// TrapKindIndirectCallTypeMismatch means the type check failed during
+	// "call_indirect".
+	TrapKindIndirectCallTypeMismatch
+	// TrapKindIntegerDivideByZero means an integer div or rem instruction was
+	// executed with zero as the divisor.
+	TrapKindIntegerDivideByZero
+	// TrapKindIntegerOverflow means an integer arithmetic instruction
+	// overflowed, e.g. truncating a float which doesn't fit in the target.
+	TrapKindIntegerOverflow
+	// TrapKindInvalidConversionToInteger means a trunc instruction was
+	// executed on a NaN.
+	TrapKindInvalidConversionToInteger
+	// TrapKindStackOverflow means there were too many nested function calls.
+	TrapKindStackOverflow
+	// TrapKindFuelExhausted means a metered function call consumed all of
+	// its fuel. See experimental.FuelKey.
+	TrapKindFuelExhausted
+)
+
+// String implements fmt.Stringer.
+func (k TrapKind) String() string {
+	switch k {
+	case TrapKindPanic:
+		return "panic"
+	case TrapKindUnreachable:
+		return "unreachable"
+	case TrapKindOutOfBoundsMemoryAccess:
+		return "out of bounds memory access"
+	case TrapKindInvalidTableAccess:
+		return "invalid table access"
+	case TrapKindIndirectCallTypeMismatch:
+		return "indirect call type mismatch"
+	case TrapKindIntegerDivideByZero:
+		return "integer divide by zero"
+	case TrapKindIntegerOverflow:
+		return "integer overflow"
+	case TrapKindInvalidConversionToInteger:
+		return "invalid conversion to integer"
+	case TrapKindStackOverflow:
+		return "stack overflow"
+	case TrapKindFuelExhausted:
+		return "fuel exhausted"
+	}
+	return "unknown"
+}
+
+// StackFrame is a function call in the wasm stack trace of a TrapError.
+type StackFrame struct {
+	// FunctionIndex is the position of the function in its module's index
+	// namespace, imports first.
+	FunctionIndex uint32
+
+	// FunctionName is the same as api.FunctionDefinition DebugName, e.g.
+	// "env.abort".
+	FunctionName string
+
+	// ParamTypes and ResultTypes are the signature of the function.
+	ParamTypes, ResultTypes []api.ValueType
+
+	// Offset is the position in the wasm binary of the instruction executing
+	// in this frame, or zero if unknown.
+	//
+	// Note: This is only known for functions defined in a wasm binary which
+	// includes DWARF custom sections, as tracking offsets has a cost.
+	Offset uint64
+
+	// Sources is the possibly empty source code information resolved from the
+	// DWARF custom sections, e.g. "/src/main.go:73:6".
+	Sources []string
+}
+
+// TrapError is returned to a caller of api.Function when the execution trapped
+// or a function panicked. The error message includes the wasm stack trace.
+//
+// Here's an example of how to inspect a trap:
+//
+//	if _, err := fn.Call(ctx); err != nil {
+//		var trapErr *sys.TrapError
+//		if errors.As(err, &trapErr) && trapErr.Kind() == sys.TrapKindUnreachable {
+//			faulting := trapErr.Frames()[0]
+//			log.Printf("unreachable in %s at offset %#x", faulting.FunctionName, faulting.Offset)
+//		}
+//	--snip--
+//
+// Note: An ExitError is never wrapped in a TrapError.
+type TrapError struct {
+	kind    TrapKind
+	cause   error
+	frames  []StackFrame
+	message string
+}
+
+// NewTrapError returns a TrapError of the given kind.
+//
+//   - cause is the possibly nil error unwrapped from this one.
+//   - frames is the wasm stack trace, beginning at the frame that trapped.
+//   - message is the full error message, including the stack trace.
+func NewTrapError(kind TrapKind, cause error, frames []StackFrame, message string) *TrapError {
+	return &TrapError{kind: kind, cause: cause, frames: frames, message: message}
+}
+
+// Kind classifies the cause of this trap.
+func (e *TrapError) Kind() TrapKind {
+	return e.kind
+}
+
+// Frames returns the wasm stack trace, beginning at the frame that trapped
+// and ending at the function called by the host.
+func (e *TrapError) Frames() []StackFrame {
+	return e.frames
+}
+
+// Error implements the error interface.
+func (e *TrapError) Error() string {
+	return e.message
+}
+
+// Unwrap allows use via errors.Is and errors.As. This returns nil when a
+// function panicked with a value that isn't an error.
+func (e *TrapError) Unwrap() error {
+	return e.cause
+}